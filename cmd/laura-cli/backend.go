@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mnohosten/laura-db/pkg/client"
+	"github.com/mnohosten/laura-db/pkg/database"
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+// backend is where the CLI sends its commands: an embedded database opened
+// from a data directory or a running server reached through the client
+type backend interface {
+	Collection(name string) collectionBackend
+	ListCollections() ([]string, error)
+	Close() error
+}
+
+// collectionBackend is the set of collection operations the CLI uses
+type collectionBackend interface {
+	InsertOne(doc map[string]interface{}) (interface{}, error)
+	Find(filter map[string]interface{}, options *findOptions) ([]map[string]interface{}, error)
+	Explain(filter map[string]interface{}) (map[string]interface{}, error)
+	UpdateOne(filter, update map[string]interface{}) error
+	DeleteOne(filter map[string]interface{}) error
+	Count(filter map[string]interface{}) (int, error)
+	CreateIndex(field string, unique bool) error
+	ListIndexes() ([]interface{}, error)
+	Stats() (interface{}, error)
+}
+
+// findOptions are the query options of the find command
+type findOptions struct {
+	Projection map[string]bool
+	Sort       []query.SortField
+	Skip       int
+	Limit      int
+}
+
+// embeddedBackend runs commands against a database opened in-process
+type embeddedBackend struct {
+	db *database.Database
+}
+
+// newEmbeddedBackend opens the database in dataDir
+func newEmbeddedBackend(dataDir string) (*embeddedBackend, error) {
+	db, err := database.Open(database.DefaultConfig(dataDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return &embeddedBackend{db: db}, nil
+}
+
+func (b *embeddedBackend) Collection(name string) collectionBackend {
+	return &embeddedCollection{coll: b.db.Collection(name)}
+}
+
+func (b *embeddedBackend) ListCollections() ([]string, error) {
+	return b.db.ListCollections(), nil
+}
+
+func (b *embeddedBackend) Close() error {
+	return b.db.Close()
+}
+
+// embeddedCollection adapts a database collection to collectionBackend
+type embeddedCollection struct {
+	coll *database.Collection
+}
+
+func (c *embeddedCollection) InsertOne(doc map[string]interface{}) (interface{}, error) {
+	return c.coll.InsertOne(doc)
+}
+
+func (c *embeddedCollection) Find(filter map[string]interface{}, options *findOptions) ([]map[string]interface{}, error) {
+	if options == nil {
+		options = &findOptions{}
+	}
+	docs, err := c.coll.FindWithOptions(filter, &database.QueryOptions{
+		Projection: options.Projection,
+		Sort:       options.Sort,
+		Skip:       options.Skip,
+		Limit:      options.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]map[string]interface{}, len(docs))
+	for i, doc := range docs {
+		results[i] = doc.ToMap()
+	}
+	return results, nil
+}
+
+func (c *embeddedCollection) Explain(filter map[string]interface{}) (map[string]interface{}, error) {
+	return c.coll.Explain(filter), nil
+}
+
+func (c *embeddedCollection) UpdateOne(filter, update map[string]interface{}) error {
+	return c.coll.UpdateOne(filter, update)
+}
+
+func (c *embeddedCollection) DeleteOne(filter map[string]interface{}) error {
+	return c.coll.DeleteOne(filter)
+}
+
+func (c *embeddedCollection) Count(filter map[string]interface{}) (int, error) {
+	return c.coll.Count(filter)
+}
+
+func (c *embeddedCollection) CreateIndex(field string, unique bool) error {
+	return c.coll.CreateIndex(field, unique)
+}
+
+func (c *embeddedCollection) ListIndexes() ([]interface{}, error) {
+	indexes := c.coll.ListIndexes()
+	results := make([]interface{}, len(indexes))
+	for i, idx := range indexes {
+		results[i] = idx
+	}
+	return results, nil
+}
+
+func (c *embeddedCollection) Stats() (interface{}, error) {
+	return c.coll.Stats(), nil
+}
+
+// remoteBackend runs commands against a running laura-server
+type remoteBackend struct {
+	client *client.Client
+}
+
+// newRemoteBackend connects to the server and checks that it responds
+func newRemoteBackend(config *client.Config) (*remoteBackend, error) {
+	c := client.NewClient(config)
+	if _, err := c.Health(); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to connect to %s:%d: %w", config.Host, config.Port, err)
+	}
+	return &remoteBackend{client: c}, nil
+}
+
+func (b *remoteBackend) Collection(name string) collectionBackend {
+	return &remoteCollection{coll: b.client.Collection(name)}
+}
+
+func (b *remoteBackend) ListCollections() ([]string, error) {
+	return b.client.ListCollections()
+}
+
+func (b *remoteBackend) Close() error {
+	return b.client.Close()
+}
+
+// remoteCollection adapts a client collection to collectionBackend. The HTTP
+// API updates and deletes single documents by _id, so filter-based commands
+// look up the first matching document first.
+type remoteCollection struct {
+	coll *client.Collection
+}
+
+func (c *remoteCollection) InsertOne(doc map[string]interface{}) (interface{}, error) {
+	return c.coll.InsertOne(doc)
+}
+
+func (c *remoteCollection) Find(filter map[string]interface{}, options *findOptions) ([]map[string]interface{}, error) {
+	if options == nil {
+		options = &findOptions{}
+	}
+	search := &client.SearchOptions{
+		Filter: filter,
+		Skip:   options.Skip,
+		Limit:  options.Limit,
+	}
+	if len(options.Projection) > 0 {
+		search.Projection = make(map[string]interface{}, len(options.Projection))
+		for field, include := range options.Projection {
+			search.Projection[field] = include
+		}
+	}
+	for _, f := range options.Sort {
+		search.SortFields = append(search.SortFields, client.SortField{Field: f.Field, Ascending: f.Ascending})
+	}
+	return c.coll.Search(search)
+}
+
+func (c *remoteCollection) Explain(filter map[string]interface{}) (map[string]interface{}, error) {
+	return c.coll.Explain(filter)
+}
+
+func (c *remoteCollection) UpdateOne(filter, update map[string]interface{}) error {
+	id, err := c.findID(filter)
+	if err != nil {
+		return err
+	}
+	return c.coll.UpdateOne(id, update)
+}
+
+func (c *remoteCollection) DeleteOne(filter map[string]interface{}) error {
+	id, err := c.findID(filter)
+	if err != nil {
+		return err
+	}
+	return c.coll.DeleteOne(id)
+}
+
+func (c *remoteCollection) Count(filter map[string]interface{}) (int, error) {
+	return c.coll.Count(filter)
+}
+
+func (c *remoteCollection) CreateIndex(field string, unique bool) error {
+	return c.coll.CreateBTreeIndex(field+"_1", field, unique)
+}
+
+func (c *remoteCollection) ListIndexes() ([]interface{}, error) {
+	indexes, err := c.coll.ListIndexes()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]interface{}, len(indexes))
+	for i, idx := range indexes {
+		results[i] = idx
+	}
+	return results, nil
+}
+
+func (c *remoteCollection) Stats() (interface{}, error) {
+	return c.coll.Stats()
+}
+
+// findID returns the _id of the first document matching filter
+func (c *remoteCollection) findID(filter map[string]interface{}) (string, error) {
+	docs, err := c.coll.Search(&client.SearchOptions{Filter: filter, Limit: 1})
+	if err != nil {
+		return "", err
+	}
+	if len(docs) == 0 {
+		return "", database.ErrDocumentNotFound
+	}
+	return fmt.Sprintf("%v", docs[0]["_id"]), nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats of the find command
+const (
+	formatJSON  = "json"
+	formatTable = "table"
+	formatCSV   = "csv"
+)
+
+// defaultPageSize is how many documents are printed before pausing
+const defaultPageSize = 20
+
+// maxCellWidth truncates long values in table output
+const maxCellWidth = 40
+
+// outputOptions controls how query results are printed
+type outputOptions struct {
+	Format string
+	Pretty bool
+}
+
+// resultPager holds query results that have not been printed yet
+type resultPager struct {
+	docs    []map[string]interface{}
+	columns []string
+	output  outputOptions
+	printed int
+}
+
+// parseFormat validates an output format name
+func parseFormat(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case formatJSON:
+		return formatJSON, nil
+	case formatTable:
+		return formatTable, nil
+	case formatCSV:
+		return formatCSV, nil
+	}
+	return "", fmt.Errorf("unknown format: %s (use json, table or csv)", s)
+}
+
+// showResults prints the first page of query results
+func (c *CLI) showResults(docs []map[string]interface{}, output outputOptions) error {
+	c.pager = &resultPager{
+		docs:   docs,
+		output: output,
+	}
+	if output.Format != formatJSON {
+		c.pager.columns = documentColumns(docs)
+	}
+
+	if output.Format != formatCSV {
+		fmt.Printf("Found %d document(s):\n", len(docs))
+	}
+	return c.nextPage()
+}
+
+// nextPage prints the next page of the pending query results
+func (c *CLI) nextPage() error {
+	p := c.pager
+	if p == nil || p.printed >= len(p.docs) {
+		c.pager = nil
+		return fmt.Errorf("no more results")
+	}
+
+	end := len(p.docs)
+	if c.pageSize > 0 && p.printed+c.pageSize < end {
+		end = p.printed + c.pageSize
+	}
+
+	var err error
+	switch p.output.Format {
+	case formatTable:
+		err = writeTable(p.columns, p.docs[p.printed:end])
+	case formatCSV:
+		err = writeCSV(p.columns, p.docs[p.printed:end], p.printed == 0)
+	default:
+		err = writeJSON(p.docs[p.printed:end], p.printed, p.output.Pretty)
+	}
+	if err != nil {
+		return err
+	}
+
+	p.printed = end
+	if p.printed < len(p.docs) {
+		fmt.Printf("Type \"it\" for more (%d of %d shown)\n", p.printed, len(p.docs))
+	} else {
+		c.pager = nil
+	}
+	return nil
+}
+
+// writeJSON prints documents as indented or single-line JSON
+func writeJSON(docs []map[string]interface{}, offset int, pretty bool) error {
+	for i, doc := range docs {
+		if !pretty {
+			jsonBytes, err := json.Marshal(doc)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jsonBytes))
+			continue
+		}
+		jsonBytes, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\n[%d] %s\n", offset+i+1, string(jsonBytes))
+	}
+	return nil
+}
+
+// writeTable prints documents as aligned columns
+func writeTable(columns []string, docs []map[string]interface{}) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+
+	dashes := make([]string, len(columns))
+	for i, col := range columns {
+		dashes[i] = strings.Repeat("-", len(col))
+	}
+	fmt.Fprintln(w, strings.Join(dashes, "\t"))
+
+	for _, doc := range docs {
+		cells := make([]string, len(columns))
+		for i, col := range columns {
+			cells[i] = truncateCell(formatCell(doc[col]))
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	return w.Flush()
+}
+
+// writeCSV prints documents as CSV, with a header row on the first page
+func writeCSV(columns []string, docs []map[string]interface{}, header bool) error {
+	w := csv.NewWriter(os.Stdout)
+	if header {
+		if err := w.Write(columns); err != nil {
+			return err
+		}
+	}
+	for _, doc := range docs {
+		record := make([]string, len(columns))
+		for i, col := range columns {
+			record[i] = formatCell(doc[col])
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// documentColumns returns the top-level fields of docs: _id first, then the
+// rest by name
+func documentColumns(docs []map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, doc := range docs {
+		for field := range doc {
+			if !seen[field] && field != "_id" {
+				seen[field] = true
+				columns = append(columns, field)
+			}
+		}
+	}
+	sort.Strings(columns)
+
+	for _, doc := range docs {
+		if _, ok := doc["_id"]; ok {
+			return append([]string{"_id"}, columns...)
+		}
+	}
+	return columns
+}
+
+// formatCell renders a field value for table and CSV output
+func formatCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	default:
+		jsonBytes, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(jsonBytes)
+	}
+}
+
+// truncateCell shortens a cell to maxCellWidth characters
+func truncateCell(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if runes := []rune(s); len(runes) > maxCellWidth {
+		return string(runes[:maxCellWidth-3]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxHistory is how many commands are kept in the history file
+const maxHistory = 1000
+
+// historyFileName is the history file in the user's home directory
+const historyFileName = ".laura_cli_history"
+
+// defaultHistoryFile returns the history file path, or "" if the home
+// directory is unknown
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, historyFileName)
+}
+
+// loadHistory reads the command history saved by previous sessions
+func (c *CLI) loadHistory() error {
+	if c.historyFile == "" {
+		return nil
+	}
+
+	f, err := os.Open(c.historyFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			c.commandHistory = append(c.commandHistory, line)
+		}
+	}
+	if len(c.commandHistory) > maxHistory {
+		c.commandHistory = c.commandHistory[len(c.commandHistory)-maxHistory:]
+	}
+	return scanner.Err()
+}
+
+// saveHistory writes the most recent commands to the history file
+func (c *CLI) saveHistory() error {
+	if c.historyFile == "" {
+		return nil
+	}
+
+	history := c.commandHistory
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+
+	tmp := c.historyFile + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	for _, line := range history {
+		w.WriteString(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return os.Rename(tmp, c.historyFile)
+}
+
+// addHistory records a command, skipping repeats of the previous one
+func (c *CLI) addHistory(line string) {
+	if n := len(c.commandHistory); n > 0 && c.commandHistory[n-1] == line {
+		return
+	}
+	c.commandHistory = append(c.commandHistory, line)
+}
+
+func (c *CLI) showHistory() error {
+	start := 0
+	if len(c.commandHistory) > 50 {
+		start = len(c.commandHistory) - 50
+	}
+	for i := start; i < len(c.commandHistory); i++ {
+		fmt.Printf("%5d  %s\n", i+1, c.commandHistory[i])
+	}
+	return nil
+}
+
+// commandNames are completed at the start of a line
+var commandNames = []string{
+	"clear", "count", "createindex", "delete", "exit", "find", "getindexes",
+	"help", "history", "insert", "it", "pagesize", "quit", "show", "stats",
+	"update", "use", "version",
+}
+
+// collectionMethods are completed after "<collection>."
+var collectionMethods = []string{
+	"count(", "find(", "getIndexes(", "insert(", "insertOne(", "stats(",
+}
+
+// cursorMethods are completed after "<collection>.find(...)."
+var cursorMethods = []string{
+	"explain(", "format(", "limit(", "pretty(", "projection(", "skip(", "sort(",
+}
+
+// findFlags are completed after "--" in a find command
+var findFlags = []string{
+	"--compact", "--explain", "--format", "--limit", "--projection", "--skip", "--sort",
+}
+
+// completeInput completes commands, collection names, methods, find options
+// and the current collection's field names
+func (c *CLI) completeInput(line []rune, pos int) (int, []string) {
+	start := pos
+	for start > 0 && !strings.ContainsRune(" \t{}[](),:.\"", line[start-1]) {
+		start--
+	}
+	word := string(line[start:pos])
+	before := string(line[:start])
+	trimmed := strings.TrimSpace(before)
+
+	var options []string
+	switch {
+	case strings.HasPrefix(word, "-"):
+		options = findFlags
+	case strings.HasSuffix(before, "\""):
+		options = c.fieldNames(c.currentColl)
+	case strings.HasSuffix(before, ".") && strings.Contains(before, ")"):
+		options = cursorMethods
+	case strings.HasSuffix(before, "."):
+		options = collectionMethods
+	case trimmed == "":
+		options = append(c.collectionNames(), commandNames...)
+	case strings.EqualFold(trimmed, "use"):
+		options = c.collectionNames()
+	case strings.EqualFold(trimmed, "show"):
+		options = []string{"collections"}
+	}
+
+	var matches []string
+	for _, option := range options {
+		if strings.HasPrefix(option, word) && option != word {
+			matches = append(matches, option)
+		}
+	}
+	sort.Strings(matches)
+	return start, matches
+}
+
+// collectionNames returns the collections for completion
+func (c *CLI) collectionNames() []string {
+	names, err := c.db.ListCollections()
+	if err != nil {
+		return nil
+	}
+	return names
+}
+
+// fieldNames returns the top-level fields of a sample of documents. It does
+// not touch collections that don't exist yet, since opening one creates it.
+func (c *CLI) fieldNames(collection string) []string {
+	exists := false
+	for _, name := range c.collectionNames() {
+		exists = exists || name == collection
+	}
+	if !exists {
+		return nil
+	}
+	docs, err := c.db.Collection(collection).Find(map[string]interface{}{}, &findOptions{Limit: 20})
+	if err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var fields []string
+	for _, doc := range docs {
+		for field := range doc {
+			if !seen[field] {
+				seen[field] = true
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// errInterrupted is returned when Ctrl-C discards the current input
+var errInterrupted = errors.New("interrupted")
+
+// completer returns completions for the word ending at pos in line. start is
+// where that word begins.
+type completer func(line []rune, pos int) (start int, candidates []string)
+
+// lineEditor reads input lines. On a terminal it switches to raw mode while
+// reading and supports cursor movement, history navigation and tab
+// completion; otherwise it reads plain lines.
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  func() []string
+	complete completer
+	sttyMode string // saved terminal state, empty when stdin is not a terminal
+}
+
+// newLineEditor creates an editor reading stdin. history returns the
+// entries reachable with the up and down keys.
+func newLineEditor(history func() []string, complete completer) *lineEditor {
+	e := &lineEditor{
+		in:       bufio.NewReader(os.Stdin),
+		out:      os.Stdout,
+		history:  history,
+		complete: complete,
+	}
+
+	if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 {
+		if mode, err := stty("-g"); err == nil {
+			e.sttyMode = strings.TrimSpace(mode)
+		}
+	}
+	return e
+}
+
+// stty runs stty against the terminal on stdin
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// ReadLine prints prompt and reads one line of input. It returns io.EOF at
+// the end of input and errInterrupted when the user presses Ctrl-C.
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	if e.sttyMode != "" {
+		if _, err := stty("-icanon", "-echo", "-isig", "min", "1"); err == nil {
+			defer stty(e.sttyMode)
+			return e.readRaw(prompt)
+		}
+	}
+
+	line, err := e.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readRaw implements line editing on a terminal in raw mode
+func (e *lineEditor) readRaw(prompt string) (string, error) {
+	var buf []rune
+	pos := 0
+
+	history := e.history()
+	histIdx := len(history)
+	var draft []rune // the line being typed before navigating history
+
+	redraw := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if back := len(buf) - pos; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	insert := func(text []rune) {
+		atEnd := pos == len(buf)
+		buf = append(buf[:pos], append(text, buf[pos:]...)...)
+		pos += len(text)
+		if atEnd {
+			fmt.Fprint(e.out, string(text))
+		} else {
+			redraw()
+		}
+	}
+	showHistory := func(idx int) {
+		if histIdx == len(history) {
+			draft = append([]rune(nil), buf...)
+		}
+		histIdx = idx
+		if idx == len(history) {
+			buf = append([]rune(nil), draft...)
+		} else {
+			buf = []rune(history[idx])
+		}
+		pos = len(buf)
+		redraw()
+	}
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(buf), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+				redraw()
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+				redraw()
+			}
+		case 1: // Ctrl-A
+			pos = 0
+			redraw()
+		case 5: // Ctrl-E
+			pos = len(buf)
+			redraw()
+		case 11: // Ctrl-K
+			buf = buf[:pos]
+			redraw()
+		case 21: // Ctrl-U
+			buf = buf[pos:]
+			pos = 0
+			redraw()
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+			redraw()
+		case '\t':
+			e.completeWord(buf, pos, insert, redraw)
+		case 27: // escape sequence
+			seq := e.readEscape()
+			switch seq {
+			case "[A", "OA": // Up
+				if histIdx > 0 {
+					showHistory(histIdx - 1)
+				}
+			case "[B", "OB": // Down
+				if histIdx < len(history) {
+					showHistory(histIdx + 1)
+				}
+			case "[C", "OC": // Right
+				if pos < len(buf) {
+					pos++
+					redraw()
+				}
+			case "[D", "OD": // Left
+				if pos > 0 {
+					pos--
+					redraw()
+				}
+			case "[H", "OH", "[1~", "[7~": // Home
+				pos = 0
+				redraw()
+			case "[F", "OF", "[4~", "[8~": // End
+				pos = len(buf)
+				redraw()
+			case "[3~": // Delete
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+					redraw()
+				}
+			}
+		default:
+			if r >= 32 {
+				insert([]rune{r})
+			}
+		}
+	}
+}
+
+// readEscape reads the rest of an escape sequence such as "[A"
+func (e *lineEditor) readEscape() string {
+	first, _, err := e.in.ReadRune()
+	if err != nil || (first != '[' && first != 'O') {
+		return ""
+	}
+	seq := []rune{first}
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return ""
+		}
+		seq = append(seq, r)
+		if (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || r == '~' {
+			return string(seq)
+		}
+	}
+}
+
+// completeWord completes the word before the cursor. A single match is
+// inserted; several matches extend the common prefix or are listed.
+func (e *lineEditor) completeWord(buf []rune, pos int, insert func([]rune), redraw func()) {
+	if e.complete == nil {
+		return
+	}
+	start, candidates := e.complete(buf, pos)
+	if len(candidates) == 0 {
+		return
+	}
+	word := string(buf[start:pos])
+
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	if len(prefix) > len(word) {
+		insert([]rune(prefix[len(word):]))
+		return
+	}
+	if len(candidates) > 1 {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+		redraw()
+	}
+}
+
+// inputComplete reports whether the brackets in input are balanced, so that
+// a command spanning several lines can be executed
+func inputComplete(input string) bool {
+	depth := 0
+	inString := false
+	for i := 0; i < len(input); i++ {
+		ch := input[i]
+		if inString {
+			if ch == '\\' {
+				i++
+			} else if ch == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[', '(':
+			depth++
+		case '}', ']', ')':
+			depth--
+		}
+	}
+	return depth <= 0 && !inString
+}
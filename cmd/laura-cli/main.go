@@ -1,13 +1,17 @@
 package main
 
 import (
-	"bufio"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/mnohosten/laura-db/pkg/database"
+	"github.com/mnohosten/laura-db/pkg/client"
 )
 
 const (
@@ -25,58 +29,90 @@ Type 'exit' or 'quit' to exit
 )
 
 type CLI struct {
-	db             *database.Database
+	db             backend
 	currentDB      string
 	currentColl    string
 	dataDir        string
-	scanner        *bufio.Scanner
+	editor         *lineEditor
+	historyFile    string
 	commandHistory []string
+	pageSize       int
+	pager          *resultPager
 }
 
 func NewCLI(dataDir string) (*CLI, error) {
 	// Open database
-	config := database.DefaultConfig(dataDir)
-	db, err := database.Open(config)
+	db, err := newEmbeddedBackend(dataDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
-	return &CLI{
+	return newCLI(db, dataDir), nil
+}
+
+// NewRemoteCLI creates a CLI that talks to a running server through the client
+func NewRemoteCLI(config *client.Config) (*CLI, error) {
+	db, err := newRemoteBackend(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCLI(db, ""), nil
+}
+
+func newCLI(db backend, dataDir string) *CLI {
+	c := &CLI{
 		db:             db,
 		currentDB:      "default",
 		dataDir:        dataDir,
-		scanner:        bufio.NewScanner(os.Stdin),
 		commandHistory: make([]string, 0),
-	}, nil
+		pageSize:       defaultPageSize,
+	}
+	c.editor = newLineEditor(func() []string { return c.commandHistory }, c.completeInput)
+	return c
 }
 
 func (c *CLI) Close() error {
+	if err := c.saveHistory(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
 	return c.db.Close()
 }
 
+// SetHistoryFile sets where command history persists between sessions and
+// loads the history saved there. An empty path disables persistence.
+func (c *CLI) SetHistoryFile(path string) error {
+	c.historyFile = path
+	return c.loadHistory()
+}
+
 func (c *CLI) Run() error {
 	fmt.Printf(banner, version)
 
 	for {
 		// Display prompt
-		prompt := fmt.Sprintf("laura> ")
+		prompt := "laura> "
 		if c.currentColl != "" {
 			prompt = fmt.Sprintf("laura:%s> ", c.currentColl)
 		}
-		fmt.Print(prompt)
 
-		// Read input
-		if !c.scanner.Scan() {
+		// Read input, continuing on further lines until brackets balance
+		line, err := c.readCommand(prompt)
+		if err == errInterrupted {
+			continue
+		}
+		if err == io.EOF {
 			break
 		}
-
-		line := strings.TrimSpace(c.scanner.Text())
+		if err != nil {
+			return err
+		}
 		if line == "" {
 			continue
 		}
 
 		// Add to history
-		c.commandHistory = append(c.commandHistory, line)
+		c.addHistory(line)
 
 		// Execute command
 		if err := c.executeCommand(line); err != nil {
@@ -88,7 +124,34 @@ func (c *CLI) Run() error {
 		}
 	}
 
-	return c.scanner.Err()
+	return nil
+}
+
+// readCommand reads one command, which may span several lines when a JSON
+// argument is split across them
+func (c *CLI) readCommand(prompt string) (string, error) {
+	var lines []string
+	for {
+		line, err := c.editor.ReadLine(prompt)
+		if err != nil {
+			if err == io.EOF && len(lines) > 0 {
+				return "", fmt.Errorf("unexpected end of input in multi-line command")
+			}
+			return "", err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" && len(lines) == 0 {
+			return "", nil
+		}
+		lines = append(lines, line)
+
+		command := strings.Join(lines, " ")
+		if inputComplete(command) {
+			return command, nil
+		}
+		prompt = strings.Repeat(" ", max(len(prompt)-4, 0)) + "... "
+	}
 }
 
 func (c *CLI) executeCommand(line string) error {
@@ -113,6 +176,12 @@ func (c *CLI) executeCommand(line string) error {
 		return c.collectionCommand(cmd, line)
 	case "createindex", "getindexes", "stats":
 		return c.managementCommand(cmd, line)
+	case "it":
+		return c.nextPage()
+	case "history":
+		return c.showHistory()
+	case "pagesize":
+		return c.setPageSize(parts)
 	case "clear":
 		fmt.Print("\033[H\033[2J") // Clear screen
 		return nil
@@ -138,16 +207,31 @@ Basic Commands:
   clear                    Clear the screen
   version                  Show CLI version
   use <collection>         Switch to a collection
+  history                  Show recent commands
+  pagesize <n>             Documents shown per page (0 = no paging)
+  it                       Show the next page of results
 
 Collection Operations:
   insert <json>            Insert a document
-  find [query]             Find documents (query is optional)
+  find [query] [options]   Find documents (query is optional)
   update <query> <update>  Update documents
   delete <query>           Delete documents
   count [query]            Count documents
 
+Find Options:
+  --limit <n>              Return at most n documents
+  --skip <n>               Skip the first n documents
+  --sort <json>            Sort order, e.g. {"age": -1, "name": 1}
+  --projection <json>      Fields to include or exclude, e.g. {"name": 1}
+  --format <json|table|csv>  Output format (default json)
+  --compact                One line per document instead of indented JSON
+  --explain                Show the query plan instead of the results
+
 Alternative Syntax (MongoDB-like):
-  <collection>.find({query})
+  <collection>.find({query}[, {projection}])
+  <collection>.find({query}).sort({...}).skip(n).limit(n)
+  <collection>.find({query}).pretty()
+  <collection>.find({query}).explain()
   <collection>.insert({document})
   <collection>.update({query}, {update})
   <collection>.delete({query})
@@ -165,9 +249,15 @@ Examples:
   use users
   insert {"name": "Alice", "age": 25}
   find {"age": {"$gte": 21}}
+  find {} --sort {"age": -1} --limit 10 --format table
   users.find({"name": "Alice"})
+  users.find({}).sort({"age": 1}).limit(5).pretty()
   createindex name {"unique": true}
 
+Commands continue on the next line until all brackets are closed.
+Use the up and down arrows to recall earlier commands and Tab to complete
+commands, collection names and field names.
+
 Note: JSON must be properly formatted with double quotes.
 `
 	fmt.Println(help)
@@ -191,10 +281,18 @@ func (c *CLI) showCommand(parts []string) error {
 	subCmd := strings.ToLower(parts[1])
 	switch subCmd {
 	case "collections", "colls":
-		// List all collections (this is a simplified version)
+		names, err := c.db.ListCollections()
+		if err != nil {
+			return err
+		}
 		fmt.Println("Collections:")
-		fmt.Println("  (LauraDB doesn't track collection names globally)")
-		fmt.Println("  Use 'use <name>' to create/access a collection")
+		if len(names) == 0 {
+			fmt.Println("  (no collections)")
+			fmt.Println("  Use 'use <name>' to create/access a collection")
+		}
+		for _, name := range names {
+			fmt.Printf("  %s\n", name)
+		}
 		return nil
 	default:
 		return fmt.Errorf("unknown show command: %s", subCmd)
@@ -218,10 +316,11 @@ func (c *CLI) collectionCommand(cmd, line string) error {
 	case "insert":
 		return c.insertDocument(coll, line[jsonStart:])
 	case "find":
-		if jsonStart == -1 {
-			return c.findDocuments(coll, "{}")
+		req, err := parseFindArgs(strings.TrimSpace(line[len(cmd):]))
+		if err != nil {
+			return err
 		}
-		return c.findDocuments(coll, line[jsonStart:])
+		return c.findDocuments(coll, req)
 	case "update":
 		return c.updateDocuments(coll, line)
 	case "delete":
@@ -237,35 +336,32 @@ func (c *CLI) collectionCommand(cmd, line string) error {
 }
 
 func (c *CLI) parseCollectionSyntax(line string) error {
-	// Parse: collection.method({args})
+	// Parse: collection.method({args})[.cursorMethod(args)...]
 	dotIdx := strings.Index(line, ".")
 	if dotIdx == -1 {
 		return fmt.Errorf("invalid syntax")
 	}
 
 	collName := line[:dotIdx]
-	rest := line[dotIdx+1:]
-
-	parenIdx := strings.Index(rest, "(")
-	if parenIdx == -1 {
-		return fmt.Errorf("invalid syntax: missing '('")
+	calls, err := parseCalls(line[dotIdx+1:])
+	if err != nil {
+		return err
 	}
 
-	method := rest[:parenIdx]
-	args := rest[parenIdx+1:]
-
-	// Remove trailing ')'
-	args = strings.TrimSuffix(strings.TrimSpace(args), ")")
+	method := calls[0].Name
+	args := calls[0].Args
 
 	// Execute on collection
 	coll := c.db.Collection(collName)
 
+	if strings.ToLower(method) == "find" {
+		return c.findWithCursorMethods(coll, args, calls[1:])
+	}
+	if len(calls) > 1 {
+		return fmt.Errorf("%s() does not support .%s()", method, calls[1].Name)
+	}
+
 	switch strings.ToLower(method) {
-	case "find":
-		if args == "" {
-			args = "{}"
-		}
-		return c.findDocuments(coll, args)
 	case "insert", "insertone":
 		return c.insertDocument(coll, args)
 	case "count":
@@ -282,7 +378,40 @@ func (c *CLI) parseCollectionSyntax(line string) error {
 	}
 }
 
-func (c *CLI) insertDocument(coll *database.Collection, jsonStr string) error {
+// findWithCursorMethods runs find(query[, projection]) with chained cursor
+// methods. Results are compact unless .pretty() is given.
+func (c *CLI) findWithCursorMethods(coll collectionBackend, args string, calls []methodCall) error {
+	findArgs, err := splitTopLevel(args)
+	if err != nil {
+		return err
+	}
+	if len(findArgs) > 2 {
+		return fmt.Errorf("find() takes at most a query and a projection")
+	}
+
+	req := &findRequest{Output: outputOptions{Format: formatJSON}}
+	if len(findArgs) > 0 {
+		if req.Filter, err = parseObject(findArgs[0]); err != nil {
+			return fmt.Errorf("invalid JSON query: %w", err)
+		}
+	} else {
+		req.Filter = map[string]interface{}{}
+	}
+	if len(findArgs) > 1 {
+		if req.Options.Projection, err = parseProjection(findArgs[1]); err != nil {
+			return err
+		}
+	}
+
+	for _, call := range calls {
+		if err := applyCursorMethod(req, call); err != nil {
+			return err
+		}
+	}
+	return c.findDocuments(coll, req)
+}
+
+func (c *CLI) insertDocument(coll collectionBackend, jsonStr string) error {
 	var doc map[string]interface{}
 	if err := json.Unmarshal([]byte(jsonStr), &doc); err != nil {
 		return fmt.Errorf("invalid JSON: %w", err)
@@ -297,28 +426,33 @@ func (c *CLI) insertDocument(coll *database.Collection, jsonStr string) error {
 	return nil
 }
 
-func (c *CLI) findDocuments(coll *database.Collection, jsonStr string) error {
-	var query map[string]interface{}
-	if err := json.Unmarshal([]byte(jsonStr), &query); err != nil {
-		return fmt.Errorf("invalid JSON query: %w", err)
+func (c *CLI) findDocuments(coll collectionBackend, req *findRequest) error {
+	if req.Explain {
+		plan, err := coll.Explain(req.Filter)
+		if err != nil {
+			return err
+		}
+		jsonBytes, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Query plan:\n%s\n", string(jsonBytes))
+		return nil
 	}
 
-	docs, err := coll.Find(query)
+	docs, err := coll.Find(req.Filter, &req.Options)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Found %d document(s):\n", len(docs))
-	for i, doc := range docs {
-		jsonBytes, _ := json.MarshalIndent(doc.ToMap(), "", "  ")
-		fmt.Printf("\n[%d] %s\n", i+1, string(jsonBytes))
-	}
-
-	return nil
+	return c.showResults(docs, req.Output)
 }
 
-func (c *CLI) updateDocuments(coll *database.Collection, line string) error {
+func (c *CLI) updateDocuments(coll collectionBackend, line string) error {
 	// Parse: update {query} {update}
+	if jsonStart := strings.Index(line, "{"); jsonStart != -1 {
+		line = line[jsonStart:]
+	}
 	parts := strings.SplitN(line, "}", 2)
 	if len(parts) < 2 {
 		return fmt.Errorf("usage: update <query> <update>")
@@ -344,7 +478,7 @@ func (c *CLI) updateDocuments(coll *database.Collection, line string) error {
 	return nil
 }
 
-func (c *CLI) deleteDocuments(coll *database.Collection, jsonStr string) error {
+func (c *CLI) deleteDocuments(coll collectionBackend, jsonStr string) error {
 	var query map[string]interface{}
 	if err := json.Unmarshal([]byte(jsonStr), &query); err != nil {
 		return fmt.Errorf("invalid JSON query: %w", err)
@@ -359,7 +493,7 @@ func (c *CLI) deleteDocuments(coll *database.Collection, jsonStr string) error {
 	return nil
 }
 
-func (c *CLI) countDocuments(coll *database.Collection, jsonStr string) error {
+func (c *CLI) countDocuments(coll collectionBackend, jsonStr string) error {
 	var query map[string]interface{}
 	if err := json.Unmarshal([]byte(jsonStr), &query); err != nil {
 		return fmt.Errorf("invalid JSON query: %w", err)
@@ -374,6 +508,20 @@ func (c *CLI) countDocuments(coll *database.Collection, jsonStr string) error {
 	return nil
 }
 
+func (c *CLI) setPageSize(parts []string) error {
+	if len(parts) < 2 {
+		fmt.Printf("Page size: %d\n", c.pageSize)
+		return nil
+	}
+	n, err := parseCount("page size", parts[1])
+	if err != nil {
+		return err
+	}
+	c.pageSize = n
+	fmt.Printf("Page size set to %d\n", n)
+	return nil
+}
+
 func (c *CLI) managementCommand(cmd, line string) error {
 	if c.currentColl == "" {
 		return fmt.Errorf("no collection selected")
@@ -393,7 +541,7 @@ func (c *CLI) managementCommand(cmd, line string) error {
 	return nil
 }
 
-func (c *CLI) createIndex(coll *database.Collection, line string) error {
+func (c *CLI) createIndex(coll collectionBackend, line string) error {
 	parts := strings.Fields(line)
 	if len(parts) < 2 {
 		return fmt.Errorf("usage: createindex <field> [options]")
@@ -425,8 +573,11 @@ func (c *CLI) createIndex(coll *database.Collection, line string) error {
 	return nil
 }
 
-func (c *CLI) getIndexes(coll *database.Collection) error {
-	indexes := coll.ListIndexes()
+func (c *CLI) getIndexes(coll collectionBackend) error {
+	indexes, err := coll.ListIndexes()
+	if err != nil {
+		return err
+	}
 
 	fmt.Printf("Indexes on collection '%s':\n", c.currentColl)
 	if len(indexes) == 0 {
@@ -442,8 +593,11 @@ func (c *CLI) getIndexes(coll *database.Collection) error {
 	return nil
 }
 
-func (c *CLI) showStats(coll *database.Collection) error {
-	stats := coll.Stats()
+func (c *CLI) showStats(coll collectionBackend) error {
+	stats, err := coll.Stats()
+	if err != nil {
+		return err
+	}
 
 	jsonBytes, err := json.MarshalIndent(stats, "", "  ")
 	if err != nil {
@@ -455,20 +609,68 @@ func (c *CLI) showStats(coll *database.Collection) error {
 }
 
 func main() {
-	dataDir := "./laura-data"
-	if len(os.Args) > 1 {
-		dataDir = os.Args[1]
+	connect := flag.String("connect", "", "Connect to a running server at host:port instead of opening a data directory")
+	wirePort := flag.Int("wire-port", 0, "Use the server's wire protocol port (with -connect)")
+	token := flag.String("token", "", "Session token for wire protocol authentication (with -connect)")
+	timeout := flag.Duration("timeout", 30*time.Second, "Request timeout (with -connect)")
+	historyFile := flag.String("history", defaultHistoryFile(), "File for persistent command history (empty disables)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] [data-dir]\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Opens data-dir (default ./laura-data) unless -connect is given.")
+		fmt.Fprintln(os.Stderr)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var cli *CLI
+	var err error
+	if *connect != "" {
+		var config *client.Config
+		config, err = parseConnect(*connect)
+		if err == nil {
+			config.WirePort = *wirePort
+			config.AuthToken = *token
+			config.Timeout = *timeout
+			cli, err = NewRemoteCLI(config)
+		}
+	} else {
+		dataDir := "./laura-data"
+		if flag.NArg() > 0 {
+			dataDir = flag.Arg(0)
+		}
+		cli, err = NewCLI(dataDir)
 	}
-
-	cli, err := NewCLI(dataDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 	defer cli.Close()
 
+	if err := cli.SetHistoryFile(*historyFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
 	if err := cli.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// parseConnect builds a client configuration from a host:port address
+func parseConnect(addr string) (*client.Config, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -connect address %q: %w", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid -connect port %q", portStr)
+	}
+
+	config := client.DefaultConfig()
+	if host != "" {
+		config.Host = host
+	}
+	config.Port = port
+	return config, nil
+}
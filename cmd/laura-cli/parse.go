@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+// findRequest is a parsed find command
+type findRequest struct {
+	Filter  map[string]interface{}
+	Options findOptions
+	Output  outputOptions
+	Explain bool
+}
+
+// methodCall is one call of the collection syntax, e.g. sort({"age": -1})
+type methodCall struct {
+	Name string
+	Args string
+}
+
+// closingBracket returns the bracket that closes open, or 0
+func closingBracket(open byte) byte {
+	switch open {
+	case '{':
+		return '}'
+	case '[':
+		return ']'
+	case '(':
+		return ')'
+	}
+	return 0
+}
+
+// matchBracket returns the index of the bracket closing the one at s[start],
+// skipping brackets inside JSON strings
+func matchBracket(s string, start int) (int, error) {
+	var stack []byte
+	inString := false
+	for i := start; i < len(s); i++ {
+		ch := s[i]
+		if inString {
+			if ch == '\\' {
+				i++
+			} else if ch == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[', '(':
+			stack = append(stack, closingBracket(ch))
+		case '}', ']', ')':
+			if len(stack) == 0 || stack[len(stack)-1] != ch {
+				return -1, fmt.Errorf("unbalanced '%c' at position %d", ch, i)
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return i, nil
+			}
+		}
+	}
+	return -1, fmt.Errorf("missing closing bracket for '%c'", s[start])
+}
+
+// splitArgs splits a command line into whitespace separated tokens, keeping
+// JSON objects and arrays together
+func splitArgs(s string) ([]string, error) {
+	var tokens []string
+	i := 0
+	for i < len(s) {
+		if s[i] == ' ' || s[i] == '\t' {
+			i++
+			continue
+		}
+		start := i
+		if s[i] == '{' || s[i] == '[' {
+			end, err := matchBracket(s, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end + 1
+		} else {
+			for i < len(s) && s[i] != ' ' && s[i] != '\t' {
+				i++
+			}
+		}
+		tokens = append(tokens, s[start:i])
+	}
+	return tokens, nil
+}
+
+// splitTopLevel splits s at commas that are not nested in brackets or strings
+func splitTopLevel(s string) ([]string, error) {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{', '[', '(':
+			end, err := matchBracket(s, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			i = end
+		case ',':
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(s[start:]); rest != "" || len(parts) > 0 {
+		parts = append(parts, rest)
+	}
+	return parts, nil
+}
+
+// parseCalls parses a chain of method calls such as find({}).limit(5)
+func parseCalls(s string) ([]methodCall, error) {
+	var calls []methodCall
+	s = strings.TrimSpace(s)
+	for s != "" {
+		parenIdx := strings.Index(s, "(")
+		if parenIdx == -1 {
+			return nil, fmt.Errorf("invalid syntax: missing '('")
+		}
+		end, err := matchBracket(s, parenIdx)
+		if err != nil {
+			return nil, fmt.Errorf("invalid syntax: %w", err)
+		}
+		calls = append(calls, methodCall{
+			Name: strings.TrimSpace(s[:parenIdx]),
+			Args: strings.TrimSpace(s[parenIdx+1 : end]),
+		})
+
+		s = strings.TrimSpace(s[end+1:])
+		s = strings.TrimSuffix(s, ";")
+		if s != "" {
+			if s[0] != '.' {
+				return nil, fmt.Errorf("invalid syntax: unexpected %q", s)
+			}
+			s = s[1:]
+		}
+	}
+	return calls, nil
+}
+
+// parseObject parses a JSON object argument, treating an empty string as {}
+func parseObject(s string) (map[string]interface{}, error) {
+	if strings.TrimSpace(s) == "" {
+		return map[string]interface{}{}, nil
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// parseSort parses a sort specification like {"age": -1, "name": 1},
+// keeping the order of the fields
+func parseSort(s string) ([]query.SortField, error) {
+	dec := json.NewDecoder(bytes.NewReader([]byte(s)))
+	dec.UseNumber()
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("sort must be a JSON object")
+	}
+
+	var fields []query.SortField
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		field := tok.(string)
+
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		ascending := true
+		switch v := value.(type) {
+		case json.Number:
+			n, err := v.Float64()
+			if err != nil || n == 0 {
+				return nil, fmt.Errorf("invalid sort order for %s: %v", field, v)
+			}
+			ascending = n > 0
+		case string:
+			switch strings.ToLower(v) {
+			case "asc":
+			case "desc":
+				ascending = false
+			default:
+				return nil, fmt.Errorf("invalid sort order for %s: %s", field, v)
+			}
+		default:
+			return nil, fmt.Errorf("invalid sort order for %s: %v", field, v)
+		}
+		fields = append(fields, query.SortField{Field: field, Ascending: ascending})
+	}
+	return fields, nil
+}
+
+// parseProjection parses a projection like {"name": 1, "_id": 0}
+func parseProjection(s string) (map[string]bool, error) {
+	obj, err := parseObject(s)
+	if err != nil {
+		return nil, err
+	}
+	projection := make(map[string]bool, len(obj))
+	for field, value := range obj {
+		switch v := value.(type) {
+		case bool:
+			projection[field] = v
+		case float64:
+			projection[field] = v != 0
+		default:
+			return nil, fmt.Errorf("invalid projection value for %s: %v", field, value)
+		}
+	}
+	return projection, nil
+}
+
+// parseCount parses a non-negative limit or skip value
+func parseCount(name, s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s: %s", name, s)
+	}
+	return n, nil
+}
+
+// parseFindArgs parses the arguments of the find command:
+// [query] [--limit n] [--skip n] [--sort {..}] [--projection {..}]
+// [--format json|table|csv] [--compact] [--explain]
+func parseFindArgs(args string) (*findRequest, error) {
+	tokens, err := splitArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &findRequest{Output: outputOptions{Format: formatJSON, Pretty: true}}
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if !strings.HasPrefix(tok, "--") {
+			if req.Filter != nil {
+				return nil, fmt.Errorf("unexpected argument: %s", tok)
+			}
+			if req.Filter, err = parseObject(tok); err != nil {
+				return nil, fmt.Errorf("invalid JSON query: %w", err)
+			}
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(tok[2:], "=")
+		switch name {
+		case "compact":
+			req.Output.Pretty = false
+			continue
+		case "pretty":
+			req.Output.Pretty = true
+			continue
+		case "explain":
+			req.Explain = true
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("--%s requires a value", name)
+			}
+			i++
+			value = tokens[i]
+		}
+
+		switch name {
+		case "limit":
+			req.Options.Limit, err = parseCount("limit", value)
+		case "skip":
+			req.Options.Skip, err = parseCount("skip", value)
+		case "sort":
+			req.Options.Sort, err = parseSort(value)
+		case "projection":
+			req.Options.Projection, err = parseProjection(value)
+		case "format":
+			req.Output.Format, err = parseFormat(value)
+		default:
+			err = fmt.Errorf("unknown option: --%s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Filter == nil {
+		req.Filter = map[string]interface{}{}
+	}
+	return req, nil
+}
+
+// applyCursorMethod applies a chained method of the collection syntax such
+// as .limit(5) or .pretty() to a find request
+func applyCursorMethod(req *findRequest, call methodCall) error {
+	var err error
+	switch strings.ToLower(call.Name) {
+	case "limit":
+		req.Options.Limit, err = parseCount("limit", call.Args)
+	case "skip":
+		req.Options.Skip, err = parseCount("skip", call.Args)
+	case "sort":
+		req.Options.Sort, err = parseSort(call.Args)
+	case "projection":
+		req.Options.Projection, err = parseProjection(call.Args)
+	case "pretty":
+		req.Output.Pretty = true
+	case "explain":
+		req.Explain = true
+	case "format":
+		req.Output.Format, err = parseFormat(strings.Trim(call.Args, `"'`))
+	default:
+		err = fmt.Errorf("unknown cursor method: %s", call.Name)
+	}
+	return err
+}
@@ -265,6 +265,9 @@ func runTrend(args []string) error {
 	dbPath := fs.String("db", "benchmarks", "Database directory")
 	benchmarkName := fs.String("benchmark", "", "Specific benchmark to show trend for (optional)")
 	limit := fs.Int("limit", 10, "Number of historical results to include")
+	format := fs.String("format", "table", "Output format: table, sparkline, html")
+	output := fs.String("output", "", "Write report to file instead of stdout")
+	thresholdMode := fs.String("threshold", "default", "Threshold mode for marking drift: default, strict, relaxed")
 
 	fs.Parse(args)
 
@@ -294,7 +297,39 @@ func runTrend(args []string) error {
 		}
 	}
 
-	return regression.GenerateTrendReport(os.Stdout, trends)
+	// Select thresholds
+	var thresholds *regression.Thresholds
+	switch strings.ToLower(*thresholdMode) {
+	case "default":
+		thresholds = regression.DefaultThresholds()
+	case "strict":
+		thresholds = regression.StrictThresholds()
+	case "relaxed":
+		thresholds = regression.RelaxedThresholds()
+	default:
+		return fmt.Errorf("unknown threshold mode: %s", *thresholdMode)
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch strings.ToLower(*format) {
+	case "table":
+		return regression.GenerateTrendReport(out, trends)
+	case "sparkline":
+		return regression.GenerateSparklineReport(out, trends, thresholds)
+	case "html":
+		return regression.GenerateHTMLTrendReport(out, trends, thresholds)
+	default:
+		return fmt.Errorf("unknown format: %s", *format)
+	}
 }
 
 func runClean(args []string) error {
@@ -381,9 +416,14 @@ TREND - Show performance trends:
       --db <path>             Database directory (default: benchmarks)
       --benchmark <name>      Specific benchmark name (optional)
       --limit <n>             Number of historical results (default: 10)
+      --format <fmt>          Output format: table, sparkline, html (default: table)
+      --output <path>         Write report to file instead of stdout
+      --threshold <mode>      Threshold used to mark drift: default, strict, relaxed
 
     Example:
       laura-regression trend --benchmark BenchmarkInsertOne-8 --limit 20
+      laura-regression trend --format sparkline --limit 30
+      laura-regression trend --format html --output trends.html
 
 CLEAN - Clean old results:
     laura-regression clean [options]
@@ -9,6 +9,7 @@ import (
 
 	"github.com/mnohosten/laura-db/pkg/database"
 	"github.com/mnohosten/laura-db/pkg/repair"
+	"github.com/mnohosten/laura-db/pkg/replication"
 )
 
 const (
@@ -25,6 +26,10 @@ func main() {
 	removeOrphans := flag.Bool("remove-orphans", true, "Remove orphaned index entries")
 	addMissing := flag.Bool("add-missing", true, "Add missing index entries")
 	conflictResolution := flag.String("conflict-resolution", "fail", "Unique conflict resolution: first, last, fail")
+	checksums := flag.Bool("checksums", false, "Verify data page checksums instead of documents and indexes")
+	redo := flag.Bool("redo", false, "Rewrite pages failing checksum verification from the WAL (with -checksums)")
+	quarantine := flag.Bool("quarantine", false, "Quarantine pages failing checksum verification (with -checksums)")
+	oplogPath := flag.String("oplog", "", "Oplog file used to restore documents lost from quarantined pages")
 	verbose := flag.Bool("verbose", false, "Verbose output")
 	showVersion := flag.Bool("version", false, "Show version information")
 
@@ -46,6 +51,12 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  %s -data-dir ./mydb -operation repair -dry-run\n\n", filepath.Base(os.Args[0]))
 		fmt.Fprintf(os.Stderr, "  # Repair with index rebuild\n")
 		fmt.Fprintf(os.Stderr, "  %s -data-dir ./mydb -operation repair -rebuild-indexes\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  # Verify page checksums\n")
+		fmt.Fprintf(os.Stderr, "  %s -data-dir ./mydb -operation validate -checksums\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  # Quarantine corrupt pages, restoring lost documents from the oplog\n")
+		fmt.Fprintf(os.Stderr, "  %s -data-dir ./mydb -operation repair -checksums -quarantine -oplog ./mydb/oplog.log\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  # Redo corrupt pages from the WAL, quarantining those it can't restore\n")
+		fmt.Fprintf(os.Stderr, "  %s -data-dir ./mydb -operation repair -checksums -redo -quarantine\n\n", filepath.Base(os.Args[0]))
 		fmt.Fprintf(os.Stderr, "  # Defragment database\n")
 		fmt.Fprintf(os.Stderr, "  %s -data-dir ./mydb -operation defragment\n\n", filepath.Base(os.Args[0]))
 	}
@@ -94,7 +105,7 @@ func main() {
 	// Execute operation
 	switch *operation {
 	case "validate":
-		if err := runValidate(db, *collection, *verbose); err != nil {
+		if err := runValidate(db, *collection, *checksums, *verbose); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
@@ -105,9 +116,11 @@ func main() {
 			RemoveOrphans:            *removeOrphans,
 			AddMissingEntries:        *addMissing,
 			UniqueConflictResolution: *conflictResolution,
+			RedoFromWAL:              *redo,
+			QuarantineCorruptPages:   *quarantine,
 			DryRun:                   *dryRun,
 		}
-		if err := runRepair(db, *collection, opts, *verbose); err != nil {
+		if err := runRepair(db, *collection, *checksums, *oplogPath, opts, *verbose); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
@@ -122,7 +135,7 @@ func main() {
 	fmt.Printf("\n✓ Operation completed successfully\n")
 }
 
-func runValidate(db *database.Database, collectionName string, verbose bool) error {
+func runValidate(db *database.Database, collectionName string, checksums bool, verbose bool) error {
 	validator := repair.NewValidator(db)
 
 	fmt.Printf("Running validation...\n")
@@ -135,7 +148,9 @@ func runValidate(db *database.Database, collectionName string, verbose bool) err
 	var report *repair.ValidationReport
 	var err error
 
-	if collectionName != "" {
+	if checksums {
+		report, err = validator.ValidatePages()
+	} else if collectionName != "" {
 		report, err = validator.ValidateCollection(collectionName)
 	} else {
 		report, err = validator.Validate()
@@ -213,9 +228,18 @@ func runValidate(db *database.Database, collectionName string, verbose bool) err
 	return nil
 }
 
-func runRepair(db *database.Database, collectionName string, options *repair.RepairOptions, verbose bool) error {
+func runRepair(db *database.Database, collectionName string, checksums bool, oplogPath string, options *repair.RepairOptions, verbose bool) error {
 	repairer := repair.NewRepairer(db)
 
+	if oplogPath != "" {
+		oplog, err := replication.NewOplog(oplogPath)
+		if err != nil {
+			return fmt.Errorf("failed to open oplog: %w", err)
+		}
+		defer oplog.Close()
+		repairer.SetOplog(oplog)
+	}
+
 	fmt.Printf("Running repair operation...\n")
 	if collectionName != "" {
 		fmt.Printf("Target: Collection '%s'\n", collectionName)
@@ -234,12 +258,16 @@ func runRepair(db *database.Database, collectionName string, options *repair.Rep
 	fmt.Printf("  Remove Orphans:        %v\n", options.RemoveOrphans)
 	fmt.Printf("  Add Missing Entries:   %v\n", options.AddMissingEntries)
 	fmt.Printf("  Conflict Resolution:   %s\n", options.UniqueConflictResolution)
+	fmt.Printf("  Redo From WAL:         %v\n", options.RedoFromWAL)
+	fmt.Printf("  Quarantine Pages:      %v\n", options.QuarantineCorruptPages)
 	fmt.Printf("\n")
 
 	var report *repair.RepairReport
 	var err error
 
-	if collectionName != "" {
+	if checksums {
+		report, err = repairer.RepairPages(options)
+	} else if collectionName != "" {
 		report, err = repairer.RepairCollection(collectionName, options)
 	} else {
 		report, err = repairer.Repair(options)
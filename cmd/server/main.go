@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/mnohosten/laura-db/pkg/server"
 )
@@ -13,13 +14,19 @@ func main() {
 	host := flag.String("host", "localhost", "Server host address")
 	port := flag.Int("port", 8080, "Server port")
 	dataDir := flag.String("data-dir", "./data", "Data directory for database storage (persistent disk storage)")
+	dbName := flag.String("db-name", "default", "Name of the database in oplog and change event namespaces")
 	bufferSize := flag.Int("buffer-size", 1000, "Buffer pool size in pages (1 page = 4KB, default 1000 = ~4MB)")
+	durability := flag.String("durability", "none", "Default durability of writes: none, wal (WAL written) or synced (WAL fsynced)")
 	docCache := flag.Int("doc-cache", 1000, "Document cache size per collection (default: 1000 documents)")
 	corsOrigin := flag.String("cors-origin", "*", "CORS allowed origin")
 	enableTLS := flag.Bool("tls", false, "Enable TLS/SSL")
 	tlsCert := flag.String("tls-cert", "", "Path to TLS certificate file")
 	tlsKey := flag.String("tls-key", "", "Path to TLS private key file")
 	enableGraphQL := flag.Bool("graphql", false, "Enable GraphQL API endpoint (/graphql) and GraphiQL playground (/graphiql)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "How long shutdown waits for in-flight requests, change streams and cursors")
+	rateLimit := flag.Bool("rate-limit", false, "Enable per-client rate limiting with default limits")
+	maxConcurrent := flag.Int("max-concurrent", 256, "Maximum concurrent requests when rate limiting is enabled (0 = unlimited)")
+	wirePort := flag.Int("wire-port", 0, "TCP port for the binary wire protocol (0 = disabled)")
 	flag.Parse()
 
 	// Create server configuration
@@ -27,13 +34,21 @@ func main() {
 	config.Host = *host
 	config.Port = *port
 	config.DataDir = *dataDir
+	config.DatabaseName = *dbName
 	config.BufferSize = *bufferSize
+	config.Durability = *durability
 	config.DocumentCache = *docCache
 	config.AllowedOrigins = []string{*corsOrigin}
 	config.EnableTLS = *enableTLS
 	config.TLSCertFile = *tlsCert
 	config.TLSKeyFile = *tlsKey
 	config.EnableGraphQL = *enableGraphQL
+	config.ShutdownTimeout = *shutdownTimeout
+	config.WirePort = *wirePort
+	if *rateLimit {
+		config.RateLimit = server.DefaultRateLimitConfig()
+		config.RateLimit.MaxConcurrentRequests = *maxConcurrent
+	}
 
 	// Create and start server
 	srv, err := server.New(config)
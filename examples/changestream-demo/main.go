@@ -52,7 +52,7 @@ func main() {
 	// Demo 1: Basic Change Stream
 	fmt.Println("Demo 1: Basic Change Stream - Watch all changes")
 	fmt.Println("------------------------------------------------")
-	demo1BasicChangeStream(coll, oplog)
+	demo1BasicChangeStream(coll)
 
 	// Demo 2: Filtered Change Stream
 	fmt.Println("\nDemo 2: Filtered Change Stream - Watch only insert operations")
@@ -77,11 +77,11 @@ func main() {
 	fmt.Println("\n=== Demo Complete ===")
 }
 
-func demo1BasicChangeStream(coll *database.Collection, oplog *replication.Oplog) {
-	// Create change stream
-	cs := changestream.NewChangeStream(oplog, "", "users", nil)
-	if err := cs.Start(); err != nil {
-		log.Fatalf("Failed to start change stream: %v", err)
+func demo1BasicChangeStream(coll *database.Collection) {
+	// Watch the collection; the database records its writes in a change oplog
+	cs, err := coll.Watch(nil)
+	if err != nil {
+		log.Fatalf("Failed to watch collection: %v", err)
 	}
 	defer cs.Close()
 
@@ -106,14 +106,7 @@ func demo1BasicChangeStream(coll *database.Collection, oplog *replication.Oplog)
 	// Insert some documents
 	fmt.Println("Inserting documents...")
 	coll.InsertOne(map[string]interface{}{"_id": "user1", "name": "Alice", "age": int64(30)})
-
-	// Log to oplog
-	oplog.Append(replication.CreateInsertEntry("testdb", "users",
-		map[string]interface{}{"_id": "user1", "name": "Alice", "age": int64(30)}))
-
 	coll.InsertOne(map[string]interface{}{"_id": "user2", "name": "Bob", "age": int64(25)})
-	oplog.Append(replication.CreateInsertEntry("testdb", "users",
-		map[string]interface{}{"_id": "user2", "name": "Bob", "age": int64(25)}))
 
 	// Wait for events to be processed
 	time.Sleep(1500 * time.Millisecond)
@@ -168,7 +168,7 @@ func main() {
 	fmt.Println("Incremented Bob's age by 1")
 
 	// Update many
-	count, _ := users.UpdateMany(
+	updated, _ := users.UpdateMany(
 		map[string]interface{}{
 			"city": "New York",
 		},
@@ -178,7 +178,7 @@ func main() {
 			},
 		},
 	)
-	fmt.Printf("Added timezone to %d users in New York\n", count)
+	fmt.Printf("Added timezone to %d users in New York\n", updated.ModifiedCount)
 
 	// Example 7: Indexes
 	fmt.Println("\n--- Indexes ---")
@@ -213,16 +213,16 @@ func main() {
 	}
 
 	// Count remaining users
-	count, _ = users.Count(map[string]interface{}{})
+	count, _ := users.Count(map[string]interface{}{})
 	fmt.Printf("Remaining users: %d\n", count)
 
 	// Delete many
-	deletedCount, _ := users.DeleteMany(map[string]interface{}{
+	deleted, _ := users.DeleteMany(map[string]interface{}{
 		"age": map[string]interface{}{
 			"$lt": int64(30),
 		},
 	})
-	fmt.Printf("Deleted %d users younger than 30\n", deletedCount)
+	fmt.Printf("Deleted %d users younger than 30\n", deleted.DeletedCount)
 
 	// Example 9: Multiple collections
 	fmt.Println("\n--- Multiple Collections ---")
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mnohosten/laura-db/pkg/database"
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+func main() {
+	// Create temporary directory for demo
+	dataDir := "geo-demo-data"
+	os.RemoveAll(dataDir)
+	defer os.RemoveAll(dataDir)
+
+	// Open database
+	db, err := database.Open(database.DefaultConfig(dataDir))
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	fmt.Println("=== LauraDB Geospatial Demo ===")
+	fmt.Println()
+
+	// Store locations as [lng, lat] arrays
+	stores := db.Collection("stores")
+	locations := []struct {
+		name string
+		lng  float64
+		lat  float64
+		open bool
+	}{
+		{"Mission", -122.4194, 37.7599, true},
+		{"Downtown", -122.4075, 37.7880, false},
+		{"North Beach", -122.4100, 37.8060, true},
+		{"Sunset", -122.4942, 37.7536, true},
+		{"Oakland", -122.2711, 37.8044, true},
+		{"Berkeley", -122.2727, 37.8716, true},
+		{"San Jose", -121.8863, 37.3382, true},
+	}
+	for _, l := range locations {
+		stores.InsertOne(map[string]interface{}{
+			"name": l.name,
+			"loc":  []interface{}{l.lng, l.lat},
+			"open": l.open,
+		})
+	}
+	fmt.Printf("Inserted %d stores\n", len(locations))
+
+	// Grid-based 2d index answers $near by searching outward from the center
+	if err := stores.Create2DIndex("loc"); err != nil {
+		panic(err)
+	}
+	fmt.Println("Created 2d index on 'loc'")
+	fmt.Println()
+
+	union := []interface{}{-122.4075, 37.7880}
+
+	// Demo 1: Closest stores to a point
+	fmt.Println("Demo 1: Three closest stores to Union Square")
+	fmt.Println("--------------------------------------------")
+	results, err := stores.FindWithOptions(map[string]interface{}{
+		"loc": map[string]interface{}{"$near": union},
+	}, &database.QueryOptions{Limit: 3})
+	if err != nil {
+		panic(err)
+	}
+	printStores(results)
+
+	// Demo 2: Within a maximum range, combined with other conditions
+	fmt.Println("Demo 2: Open stores within 0.1 degrees")
+	fmt.Println("--------------------------------------")
+	results, err = stores.Find(map[string]interface{}{
+		"loc": map[string]interface{}{
+			"$near":        union,
+			"$maxDistance": 0.1,
+		},
+		"open": true,
+	})
+	if err != nil {
+		panic(err)
+	}
+	printStores(results)
+
+	// Demo 3: Spherical distance in meters with a GeoJSON point
+	fmt.Println("Demo 3: Stores within 5km (spherical distance)")
+	fmt.Println("----------------------------------------------")
+	results, err = stores.Find(map[string]interface{}{
+		"loc": map[string]interface{}{
+			"$near": map[string]interface{}{
+				"$geometry":    map[string]interface{}{"type": "Point", "coordinates": union},
+				"$maxDistance": 5000.0,
+			},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	printStores(results)
+
+	// Demo 4: Stores inside a rectangle and a polygon
+	fmt.Println("Demo 4: $geoWithin")
+	fmt.Println("------------------")
+	results, err = stores.Find(map[string]interface{}{
+		"loc": map[string]interface{}{
+			"$geoWithin": map[string]interface{}{
+				"$box": []interface{}{[]interface{}{-122.52, 37.70}, []interface{}{-122.35, 37.83}},
+			},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("In San Francisco (box): %d stores\n", len(results))
+
+	results, err = stores.Find(map[string]interface{}{
+		"loc": map[string]interface{}{
+			"$geoWithin": map[string]interface{}{
+				"$polygon": []interface{}{
+					[]interface{}{-122.35, 37.75},
+					[]interface{}{-122.20, 37.75},
+					[]interface{}{-122.20, 37.90},
+					[]interface{}{-122.35, 37.90},
+				},
+			},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("In the East Bay (polygon): %d stores\n", len(results))
+	fmt.Println()
+
+	fmt.Println("=== Demo Complete ===")
+}
+
+func printStores(results []*document.Document) {
+	for i, doc := range results {
+		name, _ := doc.Get("name")
+		loc, _ := doc.Get("loc")
+		fmt.Printf("  %d. %-12s %v\n", i+1, name, loc)
+	}
+	fmt.Println()
+}
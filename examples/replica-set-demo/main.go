@@ -171,10 +171,9 @@ func demo2LeaderElection(tmpDir string) {
 	fmt.Println("\nTriggering election on node1 (priority 10)...")
 	rs1.Start()
 
-	// Manually start election (simplified)
-	// In production, this would be triggered automatically by election timeout
-	// rs1.startElection() - private method, so we can't call it directly
-	// For demo purposes, we'll manually become primary
+	// Elections start automatically after the election timeout, asking the
+	// members connected with ConnectMember for their vote. The members here
+	// aren't connected, so for demo purposes we'll manually become primary
 	fmt.Println("  Node1 collecting votes...")
 
 	// Simulate votes (in real implementation, this happens via RPC)
@@ -0,0 +1,575 @@
+package aggregation
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+// expression computes a value from a document. Expressions are used for
+// computed fields in $project and $addFields, and for the _id and
+// accumulator arguments of $group.
+//
+// An expression is one of:
+//   - a field path such as "$price" or "$item.name"
+//   - an operator such as {"$multiply": ["$price", "$quantity"]}
+//   - an object whose values are expressions
+//   - an array whose elements are expressions
+//   - any other value, which is returned as is
+type expression interface {
+	eval(doc *document.Document) (interface{}, error)
+}
+
+// fieldExpr reads a field, or returns nil if it is missing
+type fieldExpr struct {
+	path string
+}
+
+func (e *fieldExpr) eval(doc *document.Document) (interface{}, error) {
+	if value, exists := doc.GetNested(e.path); exists {
+		return value, nil
+	}
+	return nil, nil
+}
+
+// literalExpr is a constant
+type literalExpr struct {
+	value interface{}
+}
+
+func (e *literalExpr) eval(doc *document.Document) (interface{}, error) {
+	return e.value, nil
+}
+
+// objectExpr builds an embedded document
+type objectExpr struct {
+	fields map[string]expression
+}
+
+func (e *objectExpr) eval(doc *document.Document) (interface{}, error) {
+	result := make(map[string]interface{}, len(e.fields))
+	for name, field := range e.fields {
+		value, err := field.eval(doc)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+// arrayExpr builds an array
+type arrayExpr struct {
+	items []expression
+}
+
+func (e *arrayExpr) eval(doc *document.Document) (interface{}, error) {
+	result := make([]interface{}, len(e.items))
+	for i, item := range e.items {
+		value, err := item.eval(doc)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = value
+	}
+	return result, nil
+}
+
+// operatorExpr applies an operator to its evaluated arguments
+type operatorExpr struct {
+	name string
+	op   operator
+	args []expression
+}
+
+func (e *operatorExpr) eval(doc *document.Document) (interface{}, error) {
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		value, err := arg.eval(doc)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	return e.op.fn(e.name, args)
+}
+
+// condExpr evaluates only the branch selected by its condition
+type condExpr struct {
+	cond, then, otherwise expression
+}
+
+func (e *condExpr) eval(doc *document.Document) (interface{}, error) {
+	cond, err := e.cond.eval(doc)
+	if err != nil {
+		return nil, err
+	}
+	if isTrue(cond) {
+		return e.then.eval(doc)
+	}
+	return e.otherwise.eval(doc)
+}
+
+// ifNullExpr returns the first argument that is not null or missing
+type ifNullExpr struct {
+	args []expression
+}
+
+func (e *ifNullExpr) eval(doc *document.Document) (interface{}, error) {
+	var value interface{}
+	for _, arg := range e.args {
+		var err error
+		if value, err = arg.eval(doc); err != nil {
+			return nil, err
+		}
+		if value != nil {
+			return value, nil
+		}
+	}
+	return value, nil
+}
+
+// operator describes an expression operator taking evaluated arguments
+type operator struct {
+	minArgs int
+	maxArgs int // -1 for any number
+	fn      func(op string, args []interface{}) (interface{}, error)
+}
+
+// operators are the expression operators taking evaluated arguments. $cond,
+// $ifNull and $literal are handled by parseOperator.
+var operators = map[string]operator{
+	// Arithmetic
+	"$add":      {1, -1, evalAdd},
+	"$subtract": {2, 2, evalSubtract},
+	"$multiply": {1, -1, evalMultiply},
+	"$divide":   {2, 2, evalDivide},
+	"$mod":      {2, 2, evalMod},
+
+	// Strings
+	"$concat":  {0, -1, evalConcat},
+	"$toLower": {1, 1, evalCase},
+	"$toUpper": {1, 1, evalCase},
+
+	// Comparison and boolean
+	"$eq":  {2, 2, evalCompare},
+	"$ne":  {2, 2, evalCompare},
+	"$gt":  {2, 2, evalCompare},
+	"$gte": {2, 2, evalCompare},
+	"$lt":  {2, 2, evalCompare},
+	"$lte": {2, 2, evalCompare},
+	"$and": {0, -1, evalLogical},
+	"$or":  {0, -1, evalLogical},
+	"$not": {1, 1, evalLogical},
+
+	// Dates
+	"$year":        {1, 1, evalDatePart},
+	"$month":       {1, 1, evalDatePart},
+	"$dayOfMonth":  {1, 1, evalDatePart},
+	"$dayOfWeek":   {1, 1, evalDatePart},
+	"$dayOfYear":   {1, 1, evalDatePart},
+	"$hour":        {1, 1, evalDatePart},
+	"$minute":      {1, 1, evalDatePart},
+	"$second":      {1, 1, evalDatePart},
+	"$millisecond": {1, 1, evalDatePart},
+}
+
+// parseExpression compiles an expression specification
+func parseExpression(spec interface{}) (expression, error) {
+	switch v := spec.(type) {
+	case string:
+		if len(v) > 1 && v[0] == '$' {
+			return &fieldExpr{path: v[1:]}, nil
+		}
+		return &literalExpr{value: v}, nil
+
+	case []interface{}:
+		items := make([]expression, len(v))
+		for i, item := range v {
+			expr, err := parseExpression(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = expr
+		}
+		return &arrayExpr{items: items}, nil
+
+	case map[string]interface{}:
+		if len(v) == 1 {
+			for name, arg := range v {
+				if strings.HasPrefix(name, "$") {
+					return parseOperator(name, arg)
+				}
+			}
+		}
+		fields := make(map[string]expression, len(v))
+		for name, field := range v {
+			if strings.HasPrefix(name, "$") {
+				return nil, fmt.Errorf("operator %s must be the only field of an expression", name)
+			}
+			expr, err := parseExpression(field)
+			if err != nil {
+				return nil, err
+			}
+			fields[name] = expr
+		}
+		return &objectExpr{fields: fields}, nil
+	}
+
+	return &literalExpr{value: spec}, nil
+}
+
+// parseOperator compiles {name: arg}. A single argument may be given without
+// an array.
+func parseOperator(name string, arg interface{}) (expression, error) {
+	if name == "$literal" {
+		return &literalExpr{value: arg}, nil
+	}
+	if name == "$cond" {
+		return parseCond(arg)
+	}
+
+	specs, ok := arg.([]interface{})
+	if !ok {
+		specs = []interface{}{arg}
+	}
+	args := make([]expression, len(specs))
+	for i, spec := range specs {
+		expr, err := parseExpression(spec)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = expr
+	}
+
+	if name == "$ifNull" {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("$ifNull requires at least 2 arguments")
+		}
+		return &ifNullExpr{args: args}, nil
+	}
+
+	op, exists := operators[name]
+	if !exists {
+		return nil, fmt.Errorf("unsupported expression operator: %s", name)
+	}
+	if len(args) < op.minArgs || (op.maxArgs >= 0 && len(args) > op.maxArgs) {
+		return nil, fmt.Errorf("%s takes %s", name, argCount(op.minArgs, op.maxArgs))
+	}
+	return &operatorExpr{name: name, op: op, args: args}, nil
+}
+
+// parseCond compiles {"$cond": [if, then, else]} or
+// {"$cond": {"if": ..., "then": ..., "else": ...}}
+func parseCond(arg interface{}) (expression, error) {
+	var specs []interface{}
+	switch v := arg.(type) {
+	case []interface{}:
+		specs = v
+	case map[string]interface{}:
+		specs = []interface{}{v["if"], v["then"], v["else"]}
+		for _, key := range []string{"if", "then", "else"} {
+			if _, exists := v[key]; !exists {
+				return nil, fmt.Errorf("$cond requires if, then and else")
+			}
+		}
+	}
+	if len(specs) != 3 {
+		return nil, fmt.Errorf("$cond requires if, then and else")
+	}
+
+	exprs := make([]expression, 3)
+	for i, spec := range specs {
+		expr, err := parseExpression(spec)
+		if err != nil {
+			return nil, err
+		}
+		exprs[i] = expr
+	}
+	return &condExpr{cond: exprs[0], then: exprs[1], otherwise: exprs[2]}, nil
+}
+
+func argCount(min, max int) string {
+	switch {
+	case min == max:
+		return fmt.Sprintf("exactly %d arguments", min)
+	case max < 0:
+		return fmt.Sprintf("at least %d arguments", min)
+	}
+	return fmt.Sprintf("%d to %d arguments", min, max)
+}
+
+// Arithmetic keeps integer results when all operands are integers
+
+func toInt64(v interface{}) (int64, bool) {
+	switch val := v.(type) {
+	case int:
+		return int64(val), true
+	case int32:
+		return int64(val), true
+	case int64:
+		return val, true
+	}
+	return 0, false
+}
+
+func evalAdd(op string, args []interface{}) (interface{}, error) {
+	var date *time.Time
+	numbers := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+			return nil, nil
+		case time.Time:
+			if date != nil {
+				return nil, fmt.Errorf("$add only supports one date")
+			}
+			date = &v
+		default:
+			numbers = append(numbers, arg)
+		}
+	}
+
+	sum, err := foldNumbers(op, numbers, 0, func(a, b int64) int64 { return a + b }, func(a, b float64) float64 { return a + b })
+	if err != nil || sum == nil || date == nil {
+		return sum, err
+	}
+	ms, _ := toFloat64(sum)
+	return date.Add(time.Duration(ms * float64(time.Millisecond))), nil
+}
+
+func evalMultiply(op string, args []interface{}) (interface{}, error) {
+	for _, arg := range args {
+		if arg == nil {
+			return nil, nil
+		}
+	}
+	return foldNumbers(op, args, 1, func(a, b int64) int64 { return a * b }, func(a, b float64) float64 { return a * b })
+}
+
+// foldNumbers combines numeric arguments, as int64 if all are integers
+func foldNumbers(op string, args []interface{}, initial int64, ints func(a, b int64) int64, floats func(a, b float64) float64) (interface{}, error) {
+	intResult, allInts := initial, true
+	floatResult := float64(initial)
+	for _, arg := range args {
+		f, ok := toFloat64(arg)
+		if !ok {
+			return nil, fmt.Errorf("%s only supports numeric types, got %T", op, arg)
+		}
+		if i, ok := toInt64(arg); ok && allInts {
+			intResult = ints(intResult, i)
+		} else {
+			allInts = false
+		}
+		floatResult = floats(floatResult, f)
+	}
+	if allInts {
+		return intResult, nil
+	}
+	return floatResult, nil
+}
+
+func evalSubtract(op string, args []interface{}) (interface{}, error) {
+	a, b := args[0], args[1]
+	if a == nil || b == nil {
+		return nil, nil
+	}
+
+	if date, ok := a.(time.Time); ok {
+		if other, ok := b.(time.Time); ok {
+			return date.Sub(other).Milliseconds(), nil
+		}
+		if ms, ok := toFloat64(b); ok {
+			return date.Add(-time.Duration(ms * float64(time.Millisecond))), nil
+		}
+		return nil, fmt.Errorf("$subtract cannot subtract %T from a date", b)
+	}
+
+	if x, ok := toInt64(a); ok {
+		if y, ok := toInt64(b); ok {
+			return x - y, nil
+		}
+	}
+	x, okA := toFloat64(a)
+	y, okB := toFloat64(b)
+	if !okA || !okB {
+		return nil, fmt.Errorf("$subtract only supports numeric or date types")
+	}
+	return x - y, nil
+}
+
+func evalDivide(op string, args []interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	}
+	x, okA := toFloat64(args[0])
+	y, okB := toFloat64(args[1])
+	if !okA || !okB {
+		return nil, fmt.Errorf("$divide only supports numeric types")
+	}
+	if y == 0 {
+		return nil, fmt.Errorf("$divide by zero")
+	}
+	return x / y, nil
+}
+
+func evalMod(op string, args []interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	}
+	if x, ok := toInt64(args[0]); ok {
+		if y, ok := toInt64(args[1]); ok {
+			if y == 0 {
+				return nil, fmt.Errorf("$mod by zero")
+			}
+			return x % y, nil
+		}
+	}
+	x, okA := toFloat64(args[0])
+	y, okB := toFloat64(args[1])
+	if !okA || !okB {
+		return nil, fmt.Errorf("$mod only supports numeric types")
+	}
+	if y == 0 {
+		return nil, fmt.Errorf("$mod by zero")
+	}
+	return math.Mod(x, y), nil
+}
+
+// Strings
+
+func evalConcat(op string, args []interface{}) (interface{}, error) {
+	var sb strings.Builder
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+			return nil, nil
+		case string:
+			sb.WriteString(v)
+		default:
+			return nil, fmt.Errorf("$concat only supports strings, got %T", arg)
+		}
+	}
+	return sb.String(), nil
+}
+
+func evalCase(op string, args []interface{}) (interface{}, error) {
+	var s string
+	switch v := args[0].(type) {
+	case nil:
+		return "", nil
+	case string:
+		s = v
+	default:
+		s = fmt.Sprintf("%v", v)
+	}
+	if op == "$toUpper" {
+		return strings.ToUpper(s), nil
+	}
+	return strings.ToLower(s), nil
+}
+
+// Comparison and boolean
+
+func evalCompare(op string, args []interface{}) (interface{}, error) {
+	cmp := compareValues(args[0], args[1])
+	switch op {
+	case "$eq":
+		return cmp == 0, nil
+	case "$ne":
+		return cmp != 0, nil
+	case "$gt":
+		return cmp > 0, nil
+	case "$gte":
+		return cmp >= 0, nil
+	case "$lt":
+		return cmp < 0, nil
+	default:
+		return cmp <= 0, nil
+	}
+}
+
+func evalLogical(op string, args []interface{}) (interface{}, error) {
+	switch op {
+	case "$not":
+		return !isTrue(args[0]), nil
+	case "$or":
+		for _, arg := range args {
+			if isTrue(arg) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		for _, arg := range args {
+			if !isTrue(arg) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+}
+
+// isTrue reports whether a value counts as true in a condition: everything
+// except false, null and zero
+func isTrue(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	}
+	if num, ok := toFloat64(v); ok {
+		return num != 0
+	}
+	return true
+}
+
+// Dates
+
+// toTime converts a date value, accepting time.Time and RFC3339 strings
+func toTime(v interface{}) (time.Time, bool) {
+	switch val := v.(type) {
+	case time.Time:
+		return val.UTC(), true
+	case string:
+		if t, err := time.Parse(time.RFC3339, val); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func evalDatePart(op string, args []interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	t, ok := toTime(args[0])
+	if !ok {
+		return nil, fmt.Errorf("%s requires a date, got %T", op, args[0])
+	}
+
+	switch op {
+	case "$year":
+		return int64(t.Year()), nil
+	case "$month":
+		return int64(t.Month()), nil
+	case "$dayOfMonth":
+		return int64(t.Day()), nil
+	case "$dayOfWeek":
+		return int64(t.Weekday()) + 1, nil // 1 (Sunday) to 7 (Saturday)
+	case "$dayOfYear":
+		return int64(t.YearDay()), nil
+	case "$hour":
+		return int64(t.Hour()), nil
+	case "$minute":
+		return int64(t.Minute()), nil
+	case "$second":
+		return int64(t.Second()), nil
+	default:
+		return int64(t.Nanosecond() / int(time.Millisecond)), nil
+	}
+}
@@ -0,0 +1,179 @@
+package aggregation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+func orderDocs() []*document.Document {
+	return []*document.Document{
+		document.NewDocumentFromMap(map[string]interface{}{
+			"_id": "o1", "item": "Apple", "price": 1.5, "quantity": int64(4),
+			"ordered": time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC),
+		}),
+		document.NewDocumentFromMap(map[string]interface{}{
+			"_id": "o2", "item": "Pear", "price": 2.0, "quantity": int64(0),
+			"ordered": "2024-07-01T08:00:00Z",
+		}),
+		document.NewDocumentFromMap(map[string]interface{}{
+			"_id": "o3", "item": "Apple", "price": 1.0, "quantity": int64(10), "discount": 0.5,
+			"ordered": time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC),
+		}),
+	}
+}
+
+func runPipeline(t *testing.T, stages []map[string]interface{}, docs []*document.Document) []*document.Document {
+	t.Helper()
+	p, err := NewPipeline(stages)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	results, err := p.Execute(docs)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	return results
+}
+
+func TestProjectComputedFields(t *testing.T) {
+	results := runPipeline(t, []map[string]interface{}{
+		{"$project": map[string]interface{}{
+			"item":  true,
+			"total": map[string]interface{}{"$multiply": []interface{}{"$price", "$quantity"}},
+			"label": map[string]interface{}{"$concat": []interface{}{map[string]interface{}{"$toLower": "$item"}, "-", "$_id"}},
+			"unit": map[string]interface{}{"$cond": []interface{}{
+				map[string]interface{}{"$eq": []interface{}{"$quantity", 0}},
+				nil,
+				map[string]interface{}{"$divide": []interface{}{"$price", "$quantity"}},
+			}},
+			"discount": map[string]interface{}{"$ifNull": []interface{}{"$discount", 0.0}},
+			"year":     map[string]interface{}{"$year": "$ordered"},
+			"month":    map[string]interface{}{"$month": "$ordered"},
+		}},
+	}, orderDocs())
+
+	expected := []map[string]interface{}{
+		{"item": "Apple", "total": 6.0, "label": "apple-o1", "unit": 0.375, "discount": 0.0, "year": int64(2024), "month": int64(3)},
+		{"item": "Pear", "total": 0.0, "label": "pear-o2", "unit": nil, "discount": 0.0, "year": int64(2024), "month": int64(7)},
+		{"item": "Apple", "total": 10.0, "label": "apple-o3", "unit": 0.1, "discount": 0.5, "year": int64(2023), "month": int64(12)},
+	}
+	for i, want := range expected {
+		for field, value := range want {
+			got, _ := results[i].Get(field)
+			if got != value {
+				t.Errorf("Result %d: expected %s=%v, got %v (%T)", i, field, value, got, got)
+			}
+		}
+		if results[i].Has("price") {
+			t.Errorf("Result %d: expected price to be left out", i)
+		}
+	}
+}
+
+func TestProjectExclusion(t *testing.T) {
+	results := runPipeline(t, []map[string]interface{}{
+		{"$project": map[string]interface{}{"ordered": false, "discount": 0}},
+	}, orderDocs())
+
+	if results[2].Has("ordered") || results[2].Has("discount") {
+		t.Error("Expected ordered and discount to be excluded")
+	}
+	if !results[2].Has("item") || !results[2].Has("_id") {
+		t.Error("Expected other fields to be kept")
+	}
+}
+
+func TestAddFields(t *testing.T) {
+	docs := orderDocs()
+	results := runPipeline(t, []map[string]interface{}{
+		{"$addFields": map[string]interface{}{
+			"quantity":   map[string]interface{}{"$add": []interface{}{"$quantity", 1}},
+			"stats.diff": map[string]interface{}{"$subtract": []interface{}{"$quantity", 2}},
+		}},
+	}, docs)
+
+	if qty, _ := results[0].Get("quantity"); qty != int64(5) {
+		t.Errorf("Expected quantity 5, got %v (%T)", qty, qty)
+	}
+	// Fields are computed from the input, not from other added fields
+	if diff, _ := results[0].GetNested("stats.diff"); diff != int64(2) {
+		t.Errorf("Expected stats.diff 2, got %v", diff)
+	}
+	if !results[0].Has("price") {
+		t.Error("Expected existing fields to be kept")
+	}
+	if qty, _ := docs[0].Get("quantity"); qty != int64(4) {
+		t.Errorf("Expected input document to be unchanged, got quantity %v", qty)
+	}
+}
+
+func TestGroupWithExpressions(t *testing.T) {
+	results := runPipeline(t, []map[string]interface{}{
+		{"$group": map[string]interface{}{
+			"_id":     map[string]interface{}{"item": "$item", "year": map[string]interface{}{"$year": "$ordered"}},
+			"revenue": map[string]interface{}{"$sum": map[string]interface{}{"$multiply": []interface{}{"$price", "$quantity"}}},
+			"maxQty":  map[string]interface{}{"$max": map[string]interface{}{"$add": []interface{}{"$quantity", 0}}},
+		}},
+		{"$sort": map[string]interface{}{"revenue": -1}},
+	}, orderDocs())
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 groups, got %d", len(results))
+	}
+	id, _ := results[0].Get("_id")
+	key, ok := id.(map[string]interface{})
+	if !ok || key["item"] != "Apple" || key["year"] != int64(2023) {
+		t.Errorf("Expected _id {Apple 2023}, got %v", id)
+	}
+	if revenue, _ := results[0].Get("revenue"); revenue != 10.0 {
+		t.Errorf("Expected revenue 10, got %v", revenue)
+	}
+	if maxQty, _ := results[0].Get("maxQty"); maxQty != int64(10) {
+		t.Errorf("Expected maxQty 10, got %v", maxQty)
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	invalid := []map[string]interface{}{
+		{"$project": map[string]interface{}{"x": map[string]interface{}{"$bogus": 1}}},
+		{"$project": map[string]interface{}{"x": map[string]interface{}{"$divide": []interface{}{1}}}},
+		{"$addFields": map[string]interface{}{"x": map[string]interface{}{"$cond": []interface{}{true, 1}}}},
+		{"$addFields": "invalid"},
+		{"$group": map[string]interface{}{"_id": map[string]interface{}{"$toLower": []interface{}{}}}},
+	}
+	for _, stage := range invalid {
+		if _, err := NewPipeline([]map[string]interface{}{stage}); err == nil {
+			t.Errorf("Expected error for %v", stage)
+		}
+	}
+
+	p, err := NewPipeline([]map[string]interface{}{
+		{"$project": map[string]interface{}{"x": map[string]interface{}{"$divide": []interface{}{"$price", "$quantity"}}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	if _, err := p.Execute(orderDocs()); err == nil {
+		t.Error("Expected division by zero error")
+	}
+}
+
+func TestDateArithmetic(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	doc := document.NewDocumentFromMap(map[string]interface{}{"start": start, "end": start.Add(90 * time.Minute)})
+
+	expr, err := parseExpression(map[string]interface{}{"$subtract": []interface{}{"$end", "$start"}})
+	if err != nil {
+		t.Fatalf("Failed to parse expression: %v", err)
+	}
+	if ms, _ := expr.eval(doc); ms != int64(90*60*1000) {
+		t.Errorf("Expected 5400000 ms, got %v", ms)
+	}
+
+	expr, _ = parseExpression(map[string]interface{}{"$add": []interface{}{"$start", int64(60 * 1000)}})
+	if got, _ := expr.eval(doc); got != start.Add(time.Minute) {
+		t.Errorf("Expected %v, got %v", start.Add(time.Minute), got)
+	}
+}
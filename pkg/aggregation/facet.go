@@ -0,0 +1,105 @@
+package aggregation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+// FacetStage runs several sub-pipelines over the same input, producing a
+// single document with one field per facet holding the results of its
+// sub-pipeline. The input is read once and shared by every facet; an empty
+// sub-pipeline returns it unchanged.
+type FacetStage struct {
+	names  []string             // Facet names, sorted
+	facets map[string]*Pipeline // Sub-pipeline of each facet
+}
+
+// newFacetStage parses {"$facet": {"name": [stages...], ...}}. Facets can't
+// be nested, and their sub-pipelines can't write output.
+func newFacetStage(spec interface{}) (*FacetStage, error) {
+	facetSpecs, ok := spec.(map[string]interface{})
+	if !ok || len(facetSpecs) == 0 {
+		return nil, fmt.Errorf("$facet requires an object of sub-pipelines")
+	}
+
+	stage := &FacetStage{
+		names:  make([]string, 0, len(facetSpecs)),
+		facets: make(map[string]*Pipeline, len(facetSpecs)),
+	}
+	for name := range facetSpecs {
+		stage.names = append(stage.names, name)
+	}
+	sort.Strings(stage.names)
+
+	for _, name := range stage.names {
+		if name == "" || strings.HasPrefix(name, "$") || strings.Contains(name, ".") {
+			return nil, fmt.Errorf("$facet name %q must be a plain field name", name)
+		}
+		stageDefs, err := facetStages(facetSpecs[name])
+		if err != nil {
+			return nil, fmt.Errorf("$facet %s: %w", name, err)
+		}
+
+		p, err := NewPipeline(stageDefs)
+		if err != nil {
+			return nil, fmt.Errorf("$facet %s: %w", name, err)
+		}
+		for _, sub := range p.stages {
+			if _, nested := sub.(*FacetStage); nested {
+				return nil, fmt.Errorf("$facet %s: $facet can't be nested", name)
+			}
+			if isOutputStage(sub) {
+				return nil, fmt.Errorf("$facet %s: %s can't be used in a facet", name, sub.Type())
+			}
+		}
+
+		stage.facets[name] = p
+	}
+	return stage, nil
+}
+
+// facetStages reads the stage definitions of a sub-pipeline
+func facetStages(spec interface{}) ([]map[string]interface{}, error) {
+	switch v := spec.(type) {
+	case []map[string]interface{}:
+		return v, nil
+	case []interface{}:
+		stageDefs := make([]map[string]interface{}, len(v))
+		for i, stageSpec := range v {
+			stageDef, ok := stageSpec.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("stage %d is not an object", i)
+			}
+			stageDefs[i] = stageDef
+		}
+		return stageDefs, nil
+	}
+	return nil, fmt.Errorf("sub-pipeline must be an array of stages")
+}
+
+func (s *FacetStage) Execute(docs []*document.Document) ([]*document.Document, error) {
+	result := document.NewDocument()
+	for _, name := range s.names {
+		// Each facet gets its own slice, so none sees another's reordering
+		input := make([]*document.Document, len(docs))
+		copy(input, docs)
+
+		output, err := s.facets[name].Execute(input)
+		if err != nil {
+			return nil, fmt.Errorf("facet %s: %w", name, err)
+		}
+		values := make([]interface{}, len(output))
+		for i, doc := range output {
+			values[i] = doc.ToMap()
+		}
+		result.Set(name, values)
+	}
+	return []*document.Document{result}, nil
+}
+
+func (s *FacetStage) Type() string {
+	return "$facet"
+}
@@ -0,0 +1,73 @@
+package aggregation
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+func TestFacetStage(t *testing.T) {
+	p, err := NewPipeline([]map[string]interface{}{
+		{"$facet": map[string]interface{}{
+			"all": []interface{}{},
+			"top": []interface{}{
+				map[string]interface{}{"$sort": map[string]interface{}{"n": -1}},
+				map[string]interface{}{"$limit": 1},
+			},
+			"count": []map[string]interface{}{
+				{"$group": map[string]interface{}{"_id": nil, "n": map[string]interface{}{"$count": map[string]interface{}{}}}},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+
+	docs := []*document.Document{
+		document.NewDocumentFromMap(map[string]interface{}{"n": int64(1)}),
+		document.NewDocumentFromMap(map[string]interface{}{"n": int64(3)}),
+		document.NewDocumentFromMap(map[string]interface{}{"n": int64(2)}),
+	}
+	results, err := p.Execute(docs)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected a single document, got %d", len(results))
+	}
+	result := results[0].ToMap()
+
+	// An empty sub-pipeline returns its input unchanged, in order, even
+	// though another facet sorted it
+	expected := []interface{}{
+		map[string]interface{}{"n": int64(1)},
+		map[string]interface{}{"n": int64(3)},
+		map[string]interface{}{"n": int64(2)},
+	}
+	if !reflect.DeepEqual(result["all"], expected) {
+		t.Errorf("Expected all %v, got %v", expected, result["all"])
+	}
+	if top := result["top"].([]interface{}); len(top) != 1 || top[0].(map[string]interface{})["n"] != int64(3) {
+		t.Errorf("Expected top n 3, got %v", top)
+	}
+	if count := result["count"].([]interface{}); len(count) != 1 || count[0].(map[string]interface{})["n"] != int64(3) {
+		t.Errorf("Expected count 3, got %v", count)
+	}
+}
+
+func TestFacetStageErrors(t *testing.T) {
+	for name, spec := range map[string]interface{}{
+		"nested": map[string]interface{}{
+			"outer": []interface{}{map[string]interface{}{"$facet": map[string]interface{}{"inner": []interface{}{}}}},
+		},
+		"output":    map[string]interface{}{"f": []interface{}{map[string]interface{}{"$out": "target"}}},
+		"not array": map[string]interface{}{"f": map[string]interface{}{"$limit": 1}},
+		"bad name":  map[string]interface{}{"$f": []interface{}{}},
+		"empty":     map[string]interface{}{},
+	} {
+		if _, err := NewPipeline([]map[string]interface{}{{"$facet": spec}}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
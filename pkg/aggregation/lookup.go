@@ -0,0 +1,150 @@
+package aggregation
+
+import (
+	"fmt"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+// LookupSource finds the documents of a collection whose field equals a
+// value, for $lookup. A nil value matches the documents where the field is
+// null or missing. Whoever runs a pipeline binds a source to its $lookup
+// stages with BindLookups.
+type LookupSource interface {
+	Lookup(from, field string, value interface{}) ([]*document.Document, error)
+}
+
+// LookupStage joins each document with the documents of another collection
+// whose ForeignField equals its LocalField, embedding them as an array in
+// the As field. A document without LocalField joins those where
+// ForeignField is null or missing; one joining no documents gets an empty
+// array.
+type LookupStage struct {
+	From         string
+	LocalField   string
+	ForeignField string
+	As           string
+
+	source LookupSource
+}
+
+// newLookupStage parses {"$lookup": {"from": ..., "localField": ...,
+// "foreignField": ..., "as": ...}}
+func newLookupStage(spec interface{}) (*LookupStage, error) {
+	opts, ok := spec.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("$lookup requires an object")
+	}
+
+	stage := &LookupStage{}
+	fields := []struct {
+		key   string
+		value *string
+	}{
+		{"from", &stage.From},
+		{"localField", &stage.LocalField},
+		{"foreignField", &stage.ForeignField},
+		{"as", &stage.As},
+	}
+	for _, field := range fields {
+		*field.value, _ = opts[field.key].(string)
+		if *field.value == "" {
+			return nil, fmt.Errorf("$lookup requires %s", field.key)
+		}
+	}
+	return stage, nil
+}
+
+func (s *LookupStage) Execute(docs []*document.Document) ([]*document.Document, error) {
+	result := make([]*document.Document, 0, len(docs))
+	for _, doc := range docs {
+		joined, err := s.join(doc)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, joined)
+	}
+	return result, nil
+}
+
+// Stream joins documents as they are read
+func (s *LookupStage) Stream(input Iterator, opts *StreamOptions) (Iterator, error) {
+	return &mapIterator{input: input, apply: s.join}, nil
+}
+
+// join returns a copy of doc with the matching foreign documents set in As
+func (s *LookupStage) join(doc *document.Document) (*document.Document, error) {
+	if s.source == nil {
+		return nil, fmt.Errorf("$lookup from %s has no collections to read", s.From)
+	}
+
+	value, _ := doc.Get(s.LocalField)
+	matches, err := s.source.Lookup(s.From, s.ForeignField, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %w", s.From, err)
+	}
+	joined := make([]interface{}, len(matches))
+	for i, match := range matches {
+		joined[i] = match.ToMap()
+	}
+
+	extended := doc.Clone()
+	if err := extended.SetNested(s.As, joined); err != nil {
+		return nil, fmt.Errorf("failed to set %s: %w", s.As, err)
+	}
+	return extended, nil
+}
+
+func (s *LookupStage) Type() string {
+	return "$lookup"
+}
+
+// BindLookups sets the source the $lookup stages of the pipeline, including
+// those within $facet, read foreign collections from
+func (p *Pipeline) BindLookups(source LookupSource) {
+	for _, stage := range p.stages {
+		switch s := stage.(type) {
+		case *LookupStage:
+			s.source = source
+		case *FacetStage:
+			for _, facet := range s.facets {
+				facet.BindLookups(source)
+			}
+		}
+	}
+}
+
+// LookupTable finds documents by the value of a field, for a LookupSource
+// to join against a collection without an index on the field. Numbers are
+// matched by value whatever their type.
+type LookupTable struct {
+	docs map[interface{}][]*document.Document
+}
+
+// NewLookupTable builds a table of docs by the value of field
+func NewLookupTable(docs []*document.Document, field string) *LookupTable {
+	t := &LookupTable{docs: make(map[interface{}][]*document.Document)}
+	for _, doc := range docs {
+		value, _ := doc.Get(field)
+		key := lookupKey(value)
+		t.docs[key] = append(t.docs[key], doc)
+	}
+	return t
+}
+
+// Find returns the documents whose field equals value; a nil value finds
+// those where it is null or missing
+func (t *LookupTable) Find(value interface{}) []*document.Document {
+	return t.docs[lookupKey(value)]
+}
+
+// lookupKey returns the map key of a field value in a LookupTable
+func lookupKey(value interface{}) interface{} {
+	if f, ok := toFloat64(value); ok {
+		return f
+	}
+	if b, ok := value.([]byte); ok {
+		return fmt.Sprintf("%T%x", b, b)
+	}
+	return hashableKey(value)
+}
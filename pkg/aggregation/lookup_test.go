@@ -0,0 +1,65 @@
+package aggregation
+
+import (
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+// tableSource is a LookupSource over in-memory collections
+type tableSource map[string][]*document.Document
+
+func (s tableSource) Lookup(from, field string, value interface{}) ([]*document.Document, error) {
+	return NewLookupTable(s[from], field).Find(value), nil
+}
+
+func TestLookupStage(t *testing.T) {
+	p, err := NewPipeline([]map[string]interface{}{
+		{"$lookup": map[string]interface{}{"from": "users", "localField": "userId", "foreignField": "_id", "as": "user"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	p.BindLookups(tableSource{"users": {
+		document.NewDocumentFromMap(map[string]interface{}{"_id": int64(1), "name": "Alice"}),
+		document.NewDocumentFromMap(map[string]interface{}{"_id": int64(2), "name": "Bob"}),
+		document.NewDocumentFromMap(map[string]interface{}{"_id": nil, "name": "Nobody"}),
+	}})
+
+	results, err := p.Execute([]*document.Document{
+		document.NewDocumentFromMap(map[string]interface{}{"item": "a", "userId": 2.0}),
+		document.NewDocumentFromMap(map[string]interface{}{"item": "b", "userId": int64(3)}),
+		document.NewDocumentFromMap(map[string]interface{}{"item": "c"}),
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	// Numbers match by value, whatever their type
+	if user, _ := results[0].Get("user"); len(user.([]interface{})) != 1 || user.([]interface{})[0].(map[string]interface{})["name"] != "Bob" {
+		t.Errorf("Expected Bob joined, got %v", user)
+	}
+	if user, ok := results[1].Get("user"); !ok || len(user.([]interface{})) != 0 {
+		t.Errorf("Expected an empty array, got %v", user)
+	}
+	// A missing local field joins documents whose foreign field is null
+	if user, _ := results[2].Get("user"); len(user.([]interface{})) != 1 || user.([]interface{})[0].(map[string]interface{})["name"] != "Nobody" {
+		t.Errorf("Expected Nobody joined, got %v", user)
+	}
+}
+
+func TestLookupStageErrors(t *testing.T) {
+	_, err := NewPipeline([]map[string]interface{}{
+		{"$lookup": map[string]interface{}{"from": "users", "localField": "userId", "as": "user"}},
+	})
+	if err == nil {
+		t.Error("Expected error for $lookup without foreignField")
+	}
+
+	p, _ := NewPipeline([]map[string]interface{}{
+		{"$lookup": map[string]interface{}{"from": "users", "localField": "userId", "foreignField": "_id", "as": "user"}},
+	})
+	if _, err := p.Execute([]*document.Document{document.NewDocument()}); err == nil {
+		t.Error("Expected error for $lookup without a source")
+	}
+}
@@ -0,0 +1,158 @@
+package aggregation
+
+import (
+	"fmt"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+// $merge behaviors for output documents that match an existing document
+const (
+	MergeWhenMatchedReplace      = "replace"      // Replace the existing document
+	MergeWhenMatchedMerge        = "merge"        // Set the output fields on the existing document
+	MergeWhenMatchedKeepExisting = "keepExisting" // Leave the existing document unchanged
+	MergeWhenMatchedFail         = "fail"         // Stop with an error
+)
+
+// $merge behaviors for output documents that match no existing document
+const (
+	MergeWhenNotMatchedInsert  = "insert"  // Insert the document
+	MergeWhenNotMatchedDiscard = "discard" // Drop the document
+	MergeWhenNotMatchedFail    = "fail"    // Stop with an error
+)
+
+// OutStage replaces the contents of a collection with the pipeline output
+type OutStage struct {
+	Collection string
+}
+
+// newOutStage parses {"$out": "name"} or {"$out": {"coll": "name"}}
+func newOutStage(spec interface{}) (*OutStage, error) {
+	name, err := collectionName(spec, "coll")
+	if err != nil {
+		return nil, fmt.Errorf("$out %w", err)
+	}
+	return &OutStage{Collection: name}, nil
+}
+
+// Execute passes documents through; the caller writes them to the collection
+func (s *OutStage) Execute(docs []*document.Document) ([]*document.Document, error) {
+	return docs, nil
+}
+
+// Stream passes documents through; the caller writes them to the collection
+func (s *OutStage) Stream(input Iterator, opts *StreamOptions) (Iterator, error) {
+	return input, nil
+}
+
+func (s *OutStage) Type() string {
+	return "$out"
+}
+
+// MergeStage upserts the pipeline output into a collection
+type MergeStage struct {
+	Into           string   // Target collection
+	On             []string // Fields identifying the matching document (default: _id)
+	WhenMatched    string   // One of the MergeWhenMatched constants (default: merge)
+	WhenNotMatched string   // One of the MergeWhenNotMatched constants (default: insert)
+}
+
+// newMergeStage parses {"$merge": "name"} or
+// {"$merge": {"into": "name", "on": ..., "whenMatched": ..., "whenNotMatched": ...}}
+func newMergeStage(spec interface{}) (*MergeStage, error) {
+	name, err := collectionName(spec, "into")
+	if err != nil {
+		return nil, fmt.Errorf("$merge %w", err)
+	}
+	stage := &MergeStage{
+		Into:           name,
+		On:             []string{"_id"},
+		WhenMatched:    MergeWhenMatchedMerge,
+		WhenNotMatched: MergeWhenNotMatchedInsert,
+	}
+
+	opts, ok := spec.(map[string]interface{})
+	if !ok {
+		return stage, nil
+	}
+
+	switch on := opts["on"].(type) {
+	case nil:
+	case string:
+		stage.On = []string{on}
+	case []interface{}:
+		stage.On = make([]string, 0, len(on))
+		for _, field := range on {
+			name, ok := field.(string)
+			if !ok {
+				return nil, fmt.Errorf("$merge on must be a field name or an array of field names")
+			}
+			stage.On = append(stage.On, name)
+		}
+	case []string:
+		stage.On = on
+	default:
+		return nil, fmt.Errorf("$merge on must be a field name or an array of field names")
+	}
+	if len(stage.On) == 0 {
+		return nil, fmt.Errorf("$merge on must name at least one field")
+	}
+
+	if v, exists := opts["whenMatched"]; exists {
+		stage.WhenMatched, _ = v.(string)
+		switch stage.WhenMatched {
+		case MergeWhenMatchedReplace, MergeWhenMatchedMerge, MergeWhenMatchedKeepExisting, MergeWhenMatchedFail:
+		default:
+			return nil, fmt.Errorf("$merge whenMatched must be replace, merge, keepExisting or fail")
+		}
+	}
+	if v, exists := opts["whenNotMatched"]; exists {
+		stage.WhenNotMatched, _ = v.(string)
+		switch stage.WhenNotMatched {
+		case MergeWhenNotMatchedInsert, MergeWhenNotMatchedDiscard, MergeWhenNotMatchedFail:
+		default:
+			return nil, fmt.Errorf("$merge whenNotMatched must be insert, discard or fail")
+		}
+	}
+
+	return stage, nil
+}
+
+// Execute passes documents through; the caller merges them into the collection
+func (s *MergeStage) Execute(docs []*document.Document) ([]*document.Document, error) {
+	return docs, nil
+}
+
+// Stream passes documents through; the caller merges them into the collection
+func (s *MergeStage) Stream(input Iterator, opts *StreamOptions) (Iterator, error) {
+	return input, nil
+}
+
+func (s *MergeStage) Type() string {
+	return "$merge"
+}
+
+// isOutputStage reports whether a stage writes the pipeline output
+func isOutputStage(stage Stage) bool {
+	switch stage.(type) {
+	case *OutStage, *MergeStage:
+		return true
+	}
+	return false
+}
+
+// collectionName reads the target collection of an output stage, given as a
+// string or as the field key of an object
+func collectionName(spec interface{}, key string) (string, error) {
+	var name string
+	switch v := spec.(type) {
+	case string:
+		name = v
+	case map[string]interface{}:
+		name, _ = v[key].(string)
+	}
+	if name == "" {
+		return "", fmt.Errorf("requires a target collection name")
+	}
+	return name, nil
+}
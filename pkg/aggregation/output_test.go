@@ -0,0 +1,94 @@
+package aggregation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOutputStageMustBeLast(t *testing.T) {
+	_, err := NewPipeline([]map[string]interface{}{
+		{"$out": "target"},
+		{"$limit": 1},
+	})
+	if err == nil {
+		t.Error("Expected error for $out before another stage")
+	}
+
+	p, err := NewPipeline([]map[string]interface{}{{"$limit": 1}})
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	if p.Output() != nil {
+		t.Error("Expected no output stage")
+	}
+}
+
+func TestParseOutStage(t *testing.T) {
+	for _, spec := range []interface{}{"target", map[string]interface{}{"coll": "target"}} {
+		p, err := NewPipeline([]map[string]interface{}{{"$out": spec}})
+		if err != nil {
+			t.Fatalf("Failed to create pipeline: %v", err)
+		}
+		out, ok := p.Output().(*OutStage)
+		if !ok {
+			t.Fatalf("Expected *OutStage, got %T", p.Output())
+		}
+		if out.Collection != "target" {
+			t.Errorf("Expected collection target, got %s", out.Collection)
+		}
+	}
+
+	if _, err := NewPipeline([]map[string]interface{}{{"$out": 1}}); err == nil {
+		t.Error("Expected error for $out without a collection name")
+	}
+}
+
+func TestParseMergeStage(t *testing.T) {
+	p, err := NewPipeline([]map[string]interface{}{{"$merge": "target"}})
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	merge := p.Output().(*MergeStage)
+	if merge.Into != "target" || !reflect.DeepEqual(merge.On, []string{"_id"}) {
+		t.Errorf("Unexpected defaults: %+v", merge)
+	}
+	if merge.WhenMatched != MergeWhenMatchedMerge || merge.WhenNotMatched != MergeWhenNotMatchedInsert {
+		t.Errorf("Unexpected default behaviors: %+v", merge)
+	}
+
+	p, err = NewPipeline([]map[string]interface{}{{"$merge": map[string]interface{}{
+		"into":           "target",
+		"on":             []interface{}{"a", "b"},
+		"whenMatched":    "replace",
+		"whenNotMatched": "discard",
+	}}})
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	merge = p.Output().(*MergeStage)
+	if !reflect.DeepEqual(merge.On, []string{"a", "b"}) {
+		t.Errorf("Expected on [a b], got %v", merge.On)
+	}
+	if merge.WhenMatched != MergeWhenMatchedReplace || merge.WhenNotMatched != MergeWhenNotMatchedDiscard {
+		t.Errorf("Unexpected behaviors: %+v", merge)
+	}
+
+	for _, spec := range []map[string]interface{}{
+		{"into": "target", "whenMatched": "overwrite"},
+		{"into": "target", "whenNotMatched": "upsert"},
+		{"into": "target", "on": 5},
+		{"on": "_id"},
+	} {
+		if _, err := NewPipeline([]map[string]interface{}{{"$merge": spec}}); err == nil {
+			t.Errorf("Expected error for $merge spec %v", spec)
+		}
+	}
+}
+
+func TestOutputStagePassesDocuments(t *testing.T) {
+	docs := numberedDocs(3)
+	results := streamPipeline(t, []map[string]interface{}{{"$out": "target"}}, docs, nil)
+	if len(results) != len(docs) {
+		t.Errorf("Expected %d documents, got %d", len(docs), len(results))
+	}
+}
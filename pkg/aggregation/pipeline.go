@@ -25,17 +25,70 @@ func NewPipeline(stages []map[string]interface{}) (*Pipeline, error) {
 		stages: make([]Stage, 0, len(stages)),
 	}
 
-	for _, stageDef := range stages {
+	for i, stageDef := range stages {
 		stage, err := createStage(stageDef)
 		if err != nil {
 			return nil, err
 		}
+		if isOutputStage(stage) && i != len(stages)-1 {
+			return nil, fmt.Errorf("%s must be the last stage of the pipeline", stage.Type())
+		}
 		pipeline.stages = append(pipeline.stages, stage)
 	}
 
 	return pipeline, nil
 }
 
+// Output returns the terminal $out or $merge stage, or nil if the pipeline
+// returns its results to the caller. The stage itself passes documents
+// through; writing them to the target collection is up to the caller.
+func (p *Pipeline) Output() Stage {
+	if len(p.stages) == 0 {
+		return nil
+	}
+	if last := p.stages[len(p.stages)-1]; isOutputStage(last) {
+		return last
+	}
+	return nil
+}
+
+// LeadingMatch returns the filter of the $match the pipeline starts with and
+// a pipeline of the remaining stages. If the pipeline doesn't start with
+// $match, the filter is nil and the pipeline is returned unchanged.
+func (p *Pipeline) LeadingMatch() (map[string]interface{}, *Pipeline) {
+	if len(p.stages) == 0 {
+		return nil, p
+	}
+	match, ok := p.stages[0].(*MatchStage)
+	if !ok {
+		return nil, p
+	}
+	return match.filter, &Pipeline{stages: p.stages[1:]}
+}
+
+// LeadingSort returns the sort fields of the $sort the pipeline starts with
+// and a pipeline of the remaining stages. If the pipeline doesn't start with
+// $sort, the fields are nil and the pipeline is returned unchanged.
+func (p *Pipeline) LeadingSort() ([]query.SortField, *Pipeline) {
+	if len(p.stages) == 0 {
+		return nil, p
+	}
+	sortStage, ok := p.stages[0].(*SortStage)
+	if !ok {
+		return nil, p
+	}
+	return sortStage.sortFields, &Pipeline{stages: p.stages[1:]}
+}
+
+// StageTypes returns the type of each stage, such as "$match", in order
+func (p *Pipeline) StageTypes() []string {
+	types := make([]string, len(p.stages))
+	for i, stage := range p.stages {
+		types[i] = stage.Type()
+	}
+	return types
+}
+
 // Execute executes the pipeline
 func (p *Pipeline) Execute(docs []*document.Document) ([]*document.Document, error) {
 	result := docs
@@ -59,6 +112,8 @@ func createStage(stageDef map[string]interface{}) (Stage, error) {
 			return newMatchStage(stageSpec)
 		case "$project":
 			return newProjectStage(stageSpec)
+		case "$addFields":
+			return newAddFieldsStage(stageSpec)
 		case "$sort":
 			return newSortStage(stageSpec)
 		case "$limit":
@@ -67,6 +122,14 @@ func createStage(stageDef map[string]interface{}) (Stage, error) {
 			return newSkipStage(stageSpec)
 		case "$group":
 			return newGroupStage(stageSpec)
+		case "$lookup":
+			return newLookupStage(stageSpec)
+		case "$facet":
+			return newFacetStage(stageSpec)
+		case "$out":
+			return newOutStage(stageSpec)
+		case "$merge":
+			return newMergeStage(stageSpec)
 		default:
 			return nil, fmt.Errorf("unsupported stage type: %s", stageType)
 		}
@@ -76,7 +139,8 @@ func createStage(stageDef map[string]interface{}) (Stage, error) {
 
 // MatchStage filters documents
 type MatchStage struct {
-	query *query.Query
+	filter map[string]interface{}
+	query  *query.Query
 }
 
 func newMatchStage(spec interface{}) (*MatchStage, error) {
@@ -86,7 +150,8 @@ func newMatchStage(spec interface{}) (*MatchStage, error) {
 	}
 
 	return &MatchStage{
-		query: query.NewQuery(filter),
+		filter: filter,
+		query:  query.NewQuery(filter),
 	}, nil
 }
 
@@ -104,13 +169,23 @@ func (s *MatchStage) Execute(docs []*document.Document) ([]*document.Document, e
 	return result, nil
 }
 
+// Stream filters documents as they are read
+func (s *MatchStage) Stream(input Iterator, opts *StreamOptions) (Iterator, error) {
+	return &filterIterator{input: input, match: s.query.Matches}, nil
+}
+
 func (s *MatchStage) Type() string {
 	return "$match"
 }
 
-// ProjectStage selects fields
+// ProjectStage selects fields and computes new ones. A field set to true or
+// 1 is copied, false or 0 is left out, and any other value is an expression
+// computing the field. A projection that only leaves out fields copies all
+// the others.
 type ProjectStage struct {
-	projection map[string]interface{}
+	include  []string
+	exclude  map[string]bool
+	computed map[string]expression
 }
 
 func newProjectStage(spec interface{}) (*ProjectStage, error) {
@@ -119,46 +194,162 @@ func newProjectStage(spec interface{}) (*ProjectStage, error) {
 		return nil, fmt.Errorf("$project requires a projection object")
 	}
 
-	return &ProjectStage{
-		projection: projection,
-	}, nil
+	stage := &ProjectStage{
+		exclude:  make(map[string]bool),
+		computed: make(map[string]expression),
+	}
+	for field, fieldSpec := range projection {
+		if include, ok := projectionFlag(fieldSpec); ok {
+			if include {
+				stage.include = append(stage.include, field)
+			} else {
+				stage.exclude[field] = true
+			}
+			continue
+		}
+		expr, err := parseExpression(fieldSpec)
+		if err != nil {
+			return nil, fmt.Errorf("$project field %s: %w", field, err)
+		}
+		stage.computed[field] = expr
+	}
+
+	return stage, nil
+}
+
+// projectionFlag reads an include (true, 1) or exclude (false, 0) flag
+func projectionFlag(spec interface{}) (bool, bool) {
+	if b, ok := spec.(bool); ok {
+		return b, true
+	}
+	if num, ok := toFloat64(spec); ok && (num == 0 || num == 1) {
+		return num == 1, true
+	}
+	return false, false
 }
 
 func (s *ProjectStage) Execute(docs []*document.Document) ([]*document.Document, error) {
 	result := make([]*document.Document, 0, len(docs))
 
 	for _, doc := range docs {
-		projected := document.NewDocument()
+		projected, err := s.project(doc)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, projected)
+	}
 
-		for field, spec := range s.projection {
-			if include, ok := spec.(bool); ok && include {
-				// Include field
-				if value, exists := doc.Get(field); exists {
-					projected.Set(field, value)
-				}
-			} else if include, ok := spec.(int); ok && include == 1 {
-				// Include field (MongoDB style)
-				if value, exists := doc.Get(field); exists {
-					projected.Set(field, value)
-				}
-			} else {
-				// Computed field (simplified - just copy for now)
-				if value, exists := doc.Get(field); exists {
-					projected.Set(field, value)
-				}
+	return result, nil
+}
+
+// Stream projects documents as they are read
+func (s *ProjectStage) Stream(input Iterator, opts *StreamOptions) (Iterator, error) {
+	return &mapIterator{input: input, apply: s.project}, nil
+}
+
+// project builds the projected copy of a document
+func (s *ProjectStage) project(doc *document.Document) (*document.Document, error) {
+	projected := document.NewDocument()
+
+	if len(s.include) == 0 && len(s.computed) == 0 {
+		// Exclusion only
+		for _, field := range doc.Keys() {
+			if !s.exclude[field] {
+				value, _ := doc.Get(field)
+				projected.Set(field, value)
 			}
 		}
+		return projected, nil
+	}
 
-		result = append(result, projected)
+	for _, field := range s.include {
+		if value, exists := doc.Get(field); exists {
+			projected.Set(field, value)
+		}
+	}
+	for field, expr := range s.computed {
+		value, err := expr.eval(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute %s: %w", field, err)
+		}
+		projected.Set(field, value)
 	}
 
-	return result, nil
+	return projected, nil
 }
 
 func (s *ProjectStage) Type() string {
 	return "$project"
 }
 
+// AddFieldsStage adds computed fields to documents, replacing existing fields
+// of the same name
+type AddFieldsStage struct {
+	fields map[string]expression
+}
+
+func newAddFieldsStage(spec interface{}) (*AddFieldsStage, error) {
+	fieldSpecs, ok := spec.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("$addFields requires an object of fields")
+	}
+
+	fields := make(map[string]expression, len(fieldSpecs))
+	for field, fieldSpec := range fieldSpecs {
+		expr, err := parseExpression(fieldSpec)
+		if err != nil {
+			return nil, fmt.Errorf("$addFields field %s: %w", field, err)
+		}
+		fields[field] = expr
+	}
+
+	return &AddFieldsStage{fields: fields}, nil
+}
+
+func (s *AddFieldsStage) Execute(docs []*document.Document) ([]*document.Document, error) {
+	result := make([]*document.Document, 0, len(docs))
+
+	for _, doc := range docs {
+		extended, err := s.addFields(doc)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, extended)
+	}
+
+	return result, nil
+}
+
+// Stream adds fields to documents as they are read
+func (s *AddFieldsStage) Stream(input Iterator, opts *StreamOptions) (Iterator, error) {
+	return &mapIterator{input: input, apply: s.addFields}, nil
+}
+
+// addFields returns a copy of doc with the computed fields set. Every field
+// is computed from the input document.
+func (s *AddFieldsStage) addFields(doc *document.Document) (*document.Document, error) {
+	values := make(map[string]interface{}, len(s.fields))
+	for field, expr := range s.fields {
+		value, err := expr.eval(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute %s: %w", field, err)
+		}
+		values[field] = value
+	}
+
+	extended := doc.Clone()
+	for field, value := range values {
+		if err := extended.SetNested(field, value); err != nil {
+			return nil, fmt.Errorf("failed to set %s: %w", field, err)
+		}
+	}
+	return extended, nil
+}
+
+func (s *AddFieldsStage) Type() string {
+	return "$addFields"
+}
+
 // SortStage sorts documents
 type SortStage struct {
 	sortFields []query.SortField
@@ -199,32 +390,37 @@ func (s *SortStage) Execute(docs []*document.Document) ([]*document.Document, er
 	copy(result, docs)
 
 	sort.Slice(result, func(i, j int) bool {
-		for _, field := range s.sortFields {
-			vi, existsI := result[i].Get(field.Field)
-			vj, existsJ := result[j].Get(field.Field)
+		return s.less(result[i], result[j])
+	})
 
-			if !existsI && !existsJ {
-				continue
-			}
-			if !existsI {
-				return !field.Ascending
-			}
-			if !existsJ {
-				return field.Ascending
-			}
+	return result, nil
+}
 
-			cmp := compareValues(vi, vj)
-			if cmp != 0 {
-				if field.Ascending {
-					return cmp < 0
-				}
-				return cmp > 0
-			}
+// less reports whether a sorts before b
+func (s *SortStage) less(a, b *document.Document) bool {
+	for _, field := range s.sortFields {
+		va, existsA := a.Get(field.Field)
+		vb, existsB := b.Get(field.Field)
+
+		if !existsA && !existsB {
+			continue
+		}
+		if !existsA {
+			return !field.Ascending
+		}
+		if !existsB {
+			return field.Ascending
 		}
-		return false
-	})
 
-	return result, nil
+		cmp := compareValues(va, vb)
+		if cmp != 0 {
+			if field.Ascending {
+				return cmp < 0
+			}
+			return cmp > 0
+		}
+	}
+	return false
 }
 
 func (s *SortStage) Type() string {
@@ -260,6 +456,11 @@ func (s *LimitStage) Execute(docs []*document.Document) ([]*document.Document, e
 	return docs[:s.limit], nil
 }
 
+// Stream stops reading its input after the limit
+func (s *LimitStage) Stream(input Iterator, opts *StreamOptions) (Iterator, error) {
+	return &limitIterator{input: input, remaining: s.limit}, nil
+}
+
 func (s *LimitStage) Type() string {
 	return "$limit"
 }
@@ -293,13 +494,18 @@ func (s *SkipStage) Execute(docs []*document.Document) ([]*document.Document, er
 	return docs[s.skip:], nil
 }
 
+// Stream discards the first documents of its input
+func (s *SkipStage) Stream(input Iterator, opts *StreamOptions) (Iterator, error) {
+	return &skipIterator{input: input, skip: s.skip}, nil
+}
+
 func (s *SkipStage) Type() string {
 	return "$skip"
 }
 
 // GroupStage groups documents
 type GroupStage struct {
-	id     interface{}
+	id     expression
 	fields map[string]interface{}
 }
 
@@ -309,10 +515,14 @@ func newGroupStage(spec interface{}) (*GroupStage, error) {
 		return nil, fmt.Errorf("$group requires a group specification")
 	}
 
-	id, exists := groupSpec["_id"]
+	idSpec, exists := groupSpec["_id"]
 	if !exists {
 		return nil, fmt.Errorf("$group requires an _id field")
 	}
+	id, err := parseExpression(idSpec)
+	if err != nil {
+		return nil, fmt.Errorf("$group _id: %w", err)
+	}
 
 	fields := make(map[string]interface{})
 	for k, v := range groupSpec {
@@ -328,159 +538,207 @@ func newGroupStage(spec interface{}) (*GroupStage, error) {
 }
 
 func (s *GroupStage) Execute(docs []*document.Document) ([]*document.Document, error) {
-	// Group documents by _id field
-	groups := make(map[interface{}][]*document.Document)
+	accs, err := s.accumulators()
+	if err != nil {
+		return nil, err
+	}
 
+	// Group documents by _id field
+	groups := make(map[interface{}]*groupState)
 	for _, doc := range docs {
-		groupKey := s.extractGroupKey(doc)
-		groups[groupKey] = append(groups[groupKey], doc)
+		groupKey, err := s.id.eval(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute _id: %w", err)
+		}
+		state, exists := groups[hashableKey(groupKey)]
+		if !exists {
+			state = newGroupState(groupKey, len(accs))
+			groups[hashableKey(groupKey)] = state
+		}
+		if err := state.add(accs, doc); err != nil {
+			return nil, err
+		}
 	}
 
 	// Create result documents
 	result := make([]*document.Document, 0, len(groups))
-	for groupKey, groupDocs := range groups {
-		groupDoc := document.NewDocument()
-		groupDoc.Set("_id", groupKey)
-
-		// Compute aggregations
-		for fieldName, aggSpec := range s.fields {
-			value, err := s.computeAggregation(aggSpec, groupDocs)
-			if err != nil {
-				return nil, err
-			}
-			groupDoc.Set(fieldName, value)
-		}
-
-		result = append(result, groupDoc)
+	for _, state := range groups {
+		result = append(result, state.result(accs))
 	}
 
 	return result, nil
 }
 
-func (s *GroupStage) extractGroupKey(doc *document.Document) interface{} {
-	if idStr, ok := s.id.(string); ok {
-		if len(idStr) > 0 && idStr[0] == '$' {
-			// Field reference
-			fieldName := idStr[1:]
-			if value, exists := doc.Get(fieldName); exists {
-				return value
-			}
+// hashableKey returns a map key for a group _id. Documents and arrays can't
+// be map keys, so they are keyed by their printed form.
+func hashableKey(key interface{}) interface{} {
+	switch v := key.(type) {
+	case map[string]interface{}, []interface{}:
+		return fmt.Sprintf("%T%v", v, v)
+	case *document.Document:
+		return fmt.Sprintf("%T%v", v.ToMap(), v.ToMap())
+	}
+	return key
+}
+
+// accumulators parses the output fields of the group, ordered by name
+func (s *GroupStage) accumulators() ([]*accumulator, error) {
+	names := make([]string, 0, len(s.fields))
+	for name := range s.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	accs := make([]*accumulator, 0, len(names))
+	for _, name := range names {
+		acc, err := newAccumulator(name, s.fields[name])
+		if err != nil {
+			return nil, err
 		}
-		return idStr
+		accs = append(accs, acc)
 	}
-	return s.id
+	return accs, nil
 }
 
-func (s *GroupStage) computeAggregation(aggSpec interface{}, docs []*document.Document) (interface{}, error) {
-	if aggMap, ok := aggSpec.(map[string]interface{}); ok {
-		for op, fieldRef := range aggMap {
+func (s *GroupStage) Type() string {
+	return "$group"
+}
+
+// accumulator computes one output field of a $group
+type accumulator struct {
+	name string
+	op   string
+	arg  expression // value folded in per document, nil for $count and constant $min/$max
+}
+
+// newAccumulator parses an accumulator such as {"$sum": "$price"} or
+// {"$sum": {"$multiply": ["$price", "$quantity"]}}
+func newAccumulator(name string, spec interface{}) (*accumulator, error) {
+	if aggMap, ok := spec.(map[string]interface{}); ok && len(aggMap) == 1 {
+		for op, argSpec := range aggMap {
 			switch op {
-			case "$sum":
-				return s.computeSum(fieldRef, docs), nil
-			case "$avg":
-				return s.computeAvg(fieldRef, docs), nil
-			case "$min":
-				return s.computeMin(fieldRef, docs), nil
-			case "$max":
-				return s.computeMax(fieldRef, docs), nil
 			case "$count":
-				return int64(len(docs)), nil
+				return &accumulator{name: name, op: op}, nil
+			case "$sum", "$avg", "$min", "$max":
+				arg, err := parseExpression(argSpec)
+				if err != nil {
+					return nil, fmt.Errorf("%s %s: %w", name, op, err)
+				}
+				if _, constant := arg.(*literalExpr); constant && op != "$sum" && op != "$avg" {
+					// $min and $max of a constant stay null
+					arg = nil
+				}
+				return &accumulator{name: name, op: op, arg: arg}, nil
 			}
 		}
 	}
 	return nil, fmt.Errorf("unsupported aggregation operator")
 }
 
-func (s *GroupStage) computeSum(fieldRef interface{}, docs []*document.Document) float64 {
-	sum := 0.0
+// accumulatorState is the running value of an accumulator for one group
+type accumulatorState struct {
+	sum   float64
+	count int64
+	value interface{} // current $min or $max
+}
 
-	if fieldStr, ok := fieldRef.(string); ok && len(fieldStr) > 0 && fieldStr[0] == '$' {
-		fieldName := fieldStr[1:]
-		for _, doc := range docs {
-			if value, exists := doc.Get(fieldName); exists {
-				if num, ok := toFloat64(value); ok {
-					sum += num
-				}
-			}
-		}
-	} else if num, ok := toFloat64(fieldRef); ok {
-		sum = num * float64(len(docs))
+// add folds a document into the state. Non-numeric values are ignored by
+// $sum and $avg, and null or missing values by $min and $max.
+func (a *accumulator) add(st *accumulatorState, doc *document.Document) error {
+	st.count++
+	if a.arg == nil {
+		return nil
 	}
 
-	return sum
+	value, err := a.arg.eval(doc)
+	if err != nil {
+		return fmt.Errorf("failed to compute %s: %w", a.name, err)
+	}
+	switch a.op {
+	case "$sum", "$avg":
+		if num, ok := toFloat64(value); ok {
+			st.sum += num
+		}
+	case "$min", "$max":
+		if value != nil {
+			st.value = a.pick(st.value, value)
+		}
+	}
+	return nil
 }
 
-func (s *GroupStage) computeAvg(fieldRef interface{}, docs []*document.Document) float64 {
-	if len(docs) == 0 {
-		return 0
+// merge combines partial states computed over different documents
+func (a *accumulator) merge(st, other *accumulatorState) {
+	st.sum += other.sum
+	st.count += other.count
+	if other.value != nil {
+		st.value = a.pick(st.value, other.value)
 	}
-	return s.computeSum(fieldRef, docs) / float64(len(docs))
 }
 
-func (s *GroupStage) computeMin(fieldRef interface{}, docs []*document.Document) interface{} {
-	if fieldStr, ok := fieldRef.(string); ok && len(fieldStr) > 0 && fieldStr[0] == '$' {
-		fieldName := fieldStr[1:]
-		var min interface{}
+// pick returns the smaller ($min) or larger ($max) of the current value and v
+func (a *accumulator) pick(current, v interface{}) interface{} {
+	if current == nil {
+		return v
+	}
+	cmp := compareValues(v, current)
+	if (a.op == "$min" && cmp < 0) || (a.op == "$max" && cmp > 0) {
+		return v
+	}
+	return current
+}
 
-		for _, doc := range docs {
-			if value, exists := doc.Get(fieldName); exists {
-				if min == nil || compareValues(value, min) < 0 {
-					min = value
-				}
-			}
+// result returns the final value of the accumulator
+func (a *accumulator) result(st *accumulatorState) interface{} {
+	switch a.op {
+	case "$sum":
+		return st.sum
+	case "$avg":
+		if st.count == 0 {
+			return 0.0
 		}
-		return min
+		return st.sum / float64(st.count)
+	case "$count":
+		return st.count
+	default:
+		return st.value
 	}
-	return nil
 }
 
-func (s *GroupStage) computeMax(fieldRef interface{}, docs []*document.Document) interface{} {
-	if fieldStr, ok := fieldRef.(string); ok && len(fieldStr) > 0 && fieldStr[0] == '$' {
-		fieldName := fieldStr[1:]
-		var max interface{}
+// groupState holds the accumulator states of one group
+type groupState struct {
+	key    interface{}
+	states []accumulatorState
+}
 
-		for _, doc := range docs {
-			if value, exists := doc.Get(fieldName); exists {
-				if max == nil || compareValues(value, max) > 0 {
-					max = value
-				}
-			}
+func newGroupState(key interface{}, n int) *groupState {
+	return &groupState{key: key, states: make([]accumulatorState, n)}
+}
+
+// add folds a document into every accumulator of the group
+func (g *groupState) add(accs []*accumulator, doc *document.Document) error {
+	for i, acc := range accs {
+		if err := acc.add(&g.states[i], doc); err != nil {
+			return err
 		}
-		return max
 	}
 	return nil
 }
 
-func (s *GroupStage) Type() string {
-	return "$group"
+// result builds the output document of the group
+func (g *groupState) result(accs []*accumulator) *document.Document {
+	groupDoc := document.NewDocument()
+	groupDoc.Set("_id", g.key)
+	for i, acc := range accs {
+		groupDoc.Set(acc.name, acc.result(&g.states[i]))
+	}
+	return groupDoc
 }
 
 // Helper functions
 
 func compareValues(a, b interface{}) int {
-	aVal, aOk := toFloat64(a)
-	bVal, bOk := toFloat64(b)
-	if aOk && bOk {
-		if aVal < bVal {
-			return -1
-		} else if aVal > bVal {
-			return 1
-		}
-		return 0
-	}
-
-	aStr, aOk := a.(string)
-	bStr, bOk := b.(string)
-	if aOk && bOk {
-		if aStr < bStr {
-			return -1
-		} else if aStr > bStr {
-			return 1
-		}
-		return 0
-	}
-
-	return 0
+	return document.CompareValues(a, b)
 }
 
 func toFloat64(v interface{}) (float64, bool) {
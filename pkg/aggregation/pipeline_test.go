@@ -462,7 +462,7 @@ func TestCompareValues(t *testing.T) {
 			name:     "MixedTypes",
 			a:        "string",
 			b:        10,
-			expected: 0,
+			expected: 1, // numbers sort before strings
 		},
 		{
 			name:     "IntAndFloat",
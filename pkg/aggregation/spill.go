@@ -0,0 +1,248 @@
+package aggregation
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+// groupStateOverhead approximates the memory of one accumulator state
+const groupStateOverhead = 64
+
+// spillFile is a temporary file of BSON documents written by a blocking
+// stage. Reading it back returns the documents in the order written; the
+// file is removed when it is closed.
+type spillFile struct {
+	file   *os.File
+	reader *bufio.Reader
+}
+
+// writeSpillFile writes docs to a new temporary file in dir
+func writeSpillFile(dir string, docs []*document.Document) (*spillFile, error) {
+	f, err := os.CreateTemp(dir, "laura-agg-*.spill")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spill file: %w", err)
+	}
+	run := &spillFile{file: f}
+
+	w := bufio.NewWriter(f)
+	encoder := document.NewEncoder()
+	for _, doc := range docs {
+		data, err := encoder.Encode(doc)
+		if err != nil {
+			run.Close()
+			return nil, fmt.Errorf("failed to encode spilled document: %w", err)
+		}
+		if _, err := w.Write(data); err != nil {
+			run.Close()
+			return nil, fmt.Errorf("failed to write spill file: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		run.Close()
+		return nil, fmt.Errorf("failed to write spill file: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		run.Close()
+		return nil, fmt.Errorf("failed to rewind spill file: %w", err)
+	}
+
+	run.reader = bufio.NewReader(f)
+	return run, nil
+}
+
+// Next reads the next document of the file. Each BSON document starts with
+// its total length.
+func (r *spillFile) Next() (*document.Document, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r.reader, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated spill file")
+		}
+		return nil, err
+	}
+
+	size := binary.LittleEndian.Uint32(header[:])
+	if size < 5 {
+		return nil, fmt.Errorf("invalid document size in spill file: %d", size)
+	}
+	data := make([]byte, size)
+	copy(data, header[:])
+	if _, err := io.ReadFull(r.reader, data[4:]); err != nil {
+		return nil, fmt.Errorf("truncated spill file")
+	}
+	return document.NewDecoder(data).Decode()
+}
+
+// Close removes the file
+func (r *spillFile) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	name := r.file.Name()
+	r.file.Close()
+	r.file = nil
+	return os.Remove(name)
+}
+
+// mergeIterator merges iterators that are each sorted by less. Ties are
+// returned in source order, keeping the merge stable.
+type mergeIterator struct {
+	sources []Iterator
+	heap    mergeHeap
+}
+
+// mergeItem is the current document of one source
+type mergeItem struct {
+	doc    *document.Document
+	source int
+}
+
+type mergeHeap struct {
+	items []mergeItem
+	less  func(a, b *document.Document) bool
+}
+
+func (h mergeHeap) Len() int { return len(h.items) }
+
+func (h mergeHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.less(a.doc, b.doc) {
+		return true
+	}
+	if h.less(b.doc, a.doc) {
+		return false
+	}
+	return a.source < b.source
+}
+
+func (h mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *mergeHeap) Push(x interface{}) { h.items = append(h.items, x.(mergeItem)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
+}
+
+// newMergeIterator reads the first document of every source. The sources are
+// closed with the returned iterator, or immediately on error.
+func newMergeIterator(sources []Iterator, less func(a, b *document.Document) bool) (Iterator, error) {
+	it := &mergeIterator{
+		sources: sources,
+		heap:    mergeHeap{less: less},
+	}
+	for i, source := range sources {
+		doc, err := source.Next()
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			it.Close()
+			return nil, err
+		}
+		it.heap.items = append(it.heap.items, mergeItem{doc: doc, source: i})
+	}
+	heap.Init(&it.heap)
+	return it, nil
+}
+
+func (it *mergeIterator) Next() (*document.Document, error) {
+	if it.heap.Len() == 0 {
+		return nil, io.EOF
+	}
+
+	item := it.heap.items[0]
+	next, err := it.sources[item.source].Next()
+	switch {
+	case err == io.EOF:
+		heap.Pop(&it.heap)
+	case err != nil:
+		return nil, err
+	default:
+		it.heap.items[0].doc = next
+		heap.Fix(&it.heap, 0)
+	}
+	return item.doc, nil
+}
+
+func (it *mergeIterator) Close() error {
+	var firstErr error
+	for _, source := range it.sources {
+		if err := source.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// encode stores a partial group state as a document for spilling
+func (g *groupState) encode() *document.Document {
+	doc := document.NewDocument()
+	doc.Set("_id", g.key)
+	for i, st := range g.states {
+		doc.Set(fmt.Sprintf("s%d", i), st.sum)
+		doc.Set(fmt.Sprintf("c%d", i), st.count)
+		doc.Set(fmt.Sprintf("v%d", i), st.value)
+	}
+	return doc
+}
+
+// decodeGroupState reads a partial group state written by encode
+func decodeGroupState(doc *document.Document, n int) *groupState {
+	key, _ := doc.Get("_id")
+	g := newGroupState(key, n)
+	for i := range g.states {
+		if sum, ok := doc.Get(fmt.Sprintf("s%d", i)); ok {
+			g.states[i].sum, _ = toFloat64(sum)
+		}
+		if count, ok := doc.Get(fmt.Sprintf("c%d", i)); ok {
+			c, _ := toFloat64(count)
+			g.states[i].count = int64(c)
+		}
+		g.states[i].value, _ = doc.Get(fmt.Sprintf("v%d", i))
+	}
+	return g
+}
+
+// estimateDocumentSize approximates the memory held by a document
+func estimateDocumentSize(doc *document.Document) int64 {
+	size := int64(48)
+	for _, key := range doc.Keys() {
+		value, _ := doc.Get(key)
+		size += int64(len(key)) + estimateValueSize(value)
+	}
+	return size
+}
+
+// estimateValueSize approximates the memory held by a field value
+func estimateValueSize(v interface{}) int64 {
+	switch val := v.(type) {
+	case string:
+		return 16 + int64(len(val))
+	case []byte:
+		return 24 + int64(len(val))
+	case []interface{}:
+		size := int64(24)
+		for _, item := range val {
+			size += estimateValueSize(item)
+		}
+		return size
+	case map[string]interface{}:
+		size := int64(48)
+		for k, item := range val {
+			size += int64(len(k)) + estimateValueSize(item)
+		}
+		return size
+	case *document.Document:
+		return estimateDocumentSize(val)
+	default:
+		return 16
+	}
+}
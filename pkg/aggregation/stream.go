@@ -0,0 +1,509 @@
+package aggregation
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+// DefaultMemoryLimit is the number of bytes $sort and $group buffer before
+// spilling to temporary files
+const DefaultMemoryLimit = 100 * 1024 * 1024
+
+// Iterator produces documents one at a time. Next returns io.EOF after the
+// last document. Close releases the iterator and everything it reads from.
+type Iterator interface {
+	Next() (*document.Document, error)
+	Close() error
+}
+
+// StreamingStage is implemented by stages that can process an iterator
+// instead of a materialized slice
+type StreamingStage interface {
+	Stream(input Iterator, opts *StreamOptions) (Iterator, error)
+}
+
+// StreamOptions controls memory use of a streamed pipeline
+type StreamOptions struct {
+	// MemoryLimit is how many bytes a blocking stage ($sort, $group) holds
+	// in memory before spilling to disk (0 uses DefaultMemoryLimit,
+	// negative never spills)
+	MemoryLimit int64
+
+	// TempDir is where spill files are created ("" uses the system default)
+	TempDir string
+
+	stats *StageStats // Statistics of the stage the options were passed to
+}
+
+// StageStats reports how a stage of a streamed pipeline ran. The statistics
+// are complete once the pipeline's output has been read to the end.
+type StageStats struct {
+	Stage      string // Stage type, such as "$group"
+	Spilled    bool   // Whether the stage exceeded the memory limit
+	SpillFiles int    // Number of temporary files the stage wrote
+}
+
+// recordSpill counts a spill file written by the stage
+func (o *StreamOptions) recordSpill() {
+	if o.stats != nil {
+		o.stats.Spilled = true
+		o.stats.SpillFiles++
+	}
+}
+
+// DefaultStreamOptions returns default streaming options
+func DefaultStreamOptions() *StreamOptions {
+	return &StreamOptions{
+		MemoryLimit: DefaultMemoryLimit,
+	}
+}
+
+// memoryLimit returns the effective memory budget, or 0 for unlimited
+func (o *StreamOptions) memoryLimit() int64 {
+	switch {
+	case o.MemoryLimit == 0:
+		return DefaultMemoryLimit
+	case o.MemoryLimit < 0:
+		return 0
+	}
+	return o.MemoryLimit
+}
+
+// Stream runs the pipeline over input lazily. $match, $project, $skip and
+// $limit pass documents through one at a time; $sort and $group read their
+// whole input, spilling to disk beyond the memory limit. Stages without
+// streaming support are run on their materialized input.
+func (p *Pipeline) Stream(input Iterator, opts *StreamOptions) (Iterator, error) {
+	it, _, err := p.StreamWithStats(input, opts)
+	return it, err
+}
+
+// StreamWithStats runs the pipeline like Stream and also returns the
+// statistics of each stage, in pipeline order, such as whether it spilled
+// to disk. They fill in as the returned iterator is read.
+func (p *Pipeline) StreamWithStats(input Iterator, opts *StreamOptions) (Iterator, []*StageStats, error) {
+	if opts == nil {
+		opts = DefaultStreamOptions()
+	}
+
+	it := input
+	stats := make([]*StageStats, 0, len(p.stages))
+	for _, stage := range p.stages {
+		stageStats := &StageStats{Stage: stage.Type()}
+		stats = append(stats, stageStats)
+
+		streaming, ok := stage.(StreamingStage)
+		if !ok {
+			it = &materializedIterator{input: it, stage: stage}
+			continue
+		}
+		stageOpts := *opts
+		stageOpts.stats = stageStats
+		next, err := streaming.Stream(it, &stageOpts)
+		if err != nil {
+			it.Close()
+			return nil, nil, fmt.Errorf("stage %s failed: %w", stage.Type(), err)
+		}
+		it = next
+	}
+	return it, stats, nil
+}
+
+// Collect reads all remaining documents of an iterator and closes it
+func Collect(it Iterator) ([]*document.Document, error) {
+	defer it.Close()
+
+	var docs []*document.Document
+	for {
+		doc, err := it.Next()
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// sliceIterator iterates over documents in memory
+type sliceIterator struct {
+	docs []*document.Document
+	pos  int
+}
+
+// NewSliceIterator returns an iterator over docs
+func NewSliceIterator(docs []*document.Document) Iterator {
+	return &sliceIterator{docs: docs}
+}
+
+func (it *sliceIterator) Next() (*document.Document, error) {
+	if it.pos >= len(it.docs) {
+		return nil, io.EOF
+	}
+	doc := it.docs[it.pos]
+	it.pos++
+	return doc, nil
+}
+
+func (it *sliceIterator) Close() error {
+	it.docs = nil
+	return nil
+}
+
+// filterIterator passes on the documents accepted by match
+type filterIterator struct {
+	input Iterator
+	match func(*document.Document) (bool, error)
+}
+
+func (it *filterIterator) Next() (*document.Document, error) {
+	for {
+		doc, err := it.input.Next()
+		if err != nil {
+			return nil, err
+		}
+		ok, err := it.match(doc)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return doc, nil
+		}
+	}
+}
+
+func (it *filterIterator) Close() error {
+	return it.input.Close()
+}
+
+// mapIterator transforms each document
+type mapIterator struct {
+	input Iterator
+	apply func(*document.Document) (*document.Document, error)
+}
+
+func (it *mapIterator) Next() (*document.Document, error) {
+	doc, err := it.input.Next()
+	if err != nil {
+		return nil, err
+	}
+	return it.apply(doc)
+}
+
+func (it *mapIterator) Close() error {
+	return it.input.Close()
+}
+
+// skipIterator discards the first skip documents
+type skipIterator struct {
+	input Iterator
+	skip  int
+}
+
+func (it *skipIterator) Next() (*document.Document, error) {
+	for ; it.skip > 0; it.skip-- {
+		if _, err := it.input.Next(); err != nil {
+			return nil, err
+		}
+	}
+	return it.input.Next()
+}
+
+func (it *skipIterator) Close() error {
+	return it.input.Close()
+}
+
+// limitIterator ends after remaining documents
+type limitIterator struct {
+	input     Iterator
+	remaining int
+}
+
+func (it *limitIterator) Next() (*document.Document, error) {
+	if it.remaining <= 0 {
+		return nil, io.EOF
+	}
+	it.remaining--
+	return it.input.Next()
+}
+
+func (it *limitIterator) Close() error {
+	return it.input.Close()
+}
+
+// materializedIterator runs a non-streaming stage on its whole input the
+// first time a document is requested
+type materializedIterator struct {
+	input  Iterator
+	stage  Stage
+	output Iterator
+}
+
+func (it *materializedIterator) Next() (*document.Document, error) {
+	if it.output == nil {
+		docs, err := Collect(it.input)
+		if err != nil {
+			return nil, err
+		}
+		result, err := it.stage.Execute(docs)
+		if err != nil {
+			return nil, fmt.Errorf("stage %s failed: %w", it.stage.Type(), err)
+		}
+		it.output = NewSliceIterator(result)
+	}
+	return it.output.Next()
+}
+
+func (it *materializedIterator) Close() error {
+	if it.output != nil {
+		return it.output.Close()
+	}
+	return it.input.Close()
+}
+
+// lazyIterator builds its output from the input on the first call to Next,
+// so blocking stages don't read anything until results are requested
+type lazyIterator struct {
+	input  Iterator
+	build  func(input Iterator) (Iterator, error)
+	output Iterator
+	err    error
+}
+
+func (it *lazyIterator) Next() (*document.Document, error) {
+	if it.err != nil {
+		return nil, it.err
+	}
+	if it.output == nil {
+		it.output, it.err = it.build(it.input)
+		if it.err != nil {
+			return nil, it.err
+		}
+	}
+	return it.output.Next()
+}
+
+func (it *lazyIterator) Close() error {
+	err := it.input.Close()
+	if it.output != nil {
+		if closeErr := it.output.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Stream sorts the input, writing sorted runs to disk whenever the buffered
+// documents exceed the memory limit and merging them afterwards
+func (s *SortStage) Stream(input Iterator, opts *StreamOptions) (Iterator, error) {
+	return &lazyIterator{input: input, build: func(input Iterator) (Iterator, error) {
+		return s.externalSort(input, opts)
+	}}, nil
+}
+
+// externalSort reads the whole input and returns it in sorted order
+func (s *SortStage) externalSort(input Iterator, opts *StreamOptions) (Iterator, error) {
+	limit := opts.memoryLimit()
+	var buffer []*document.Document
+	var size int64
+	var runs []*spillFile
+
+	cleanup := func() {
+		for _, run := range runs {
+			run.Close()
+		}
+	}
+	sortBuffer := func() {
+		sort.SliceStable(buffer, func(i, j int) bool {
+			return s.less(buffer[i], buffer[j])
+		})
+	}
+
+	for {
+		doc, err := input.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		buffer = append(buffer, doc)
+		size += estimateDocumentSize(doc)
+
+		if limit > 0 && size > limit {
+			sortBuffer()
+			run, err := writeSpillFile(opts.TempDir, buffer)
+			if err != nil {
+				cleanup()
+				return nil, err
+			}
+			runs = append(runs, run)
+			opts.recordSpill()
+			buffer, size = nil, 0
+		}
+	}
+
+	sortBuffer()
+	if len(runs) == 0 {
+		return NewSliceIterator(buffer), nil
+	}
+
+	sources := make([]Iterator, 0, len(runs)+1)
+	for _, run := range runs {
+		sources = append(sources, run)
+	}
+	sources = append(sources, NewSliceIterator(buffer))
+	return newMergeIterator(sources, s.less)
+}
+
+// Stream groups the input keeping one accumulator state per group. When the
+// states exceed the memory limit they are written to disk sorted by group
+// key, and the partial states of each key are combined when the runs are
+// merged.
+func (s *GroupStage) Stream(input Iterator, opts *StreamOptions) (Iterator, error) {
+	accs, err := s.accumulators()
+	if err != nil {
+		return nil, err
+	}
+	return &lazyIterator{input: input, build: func(input Iterator) (Iterator, error) {
+		return s.externalGroup(input, accs, opts)
+	}}, nil
+}
+
+// externalGroup reads the whole input and returns one document per group
+func (s *GroupStage) externalGroup(input Iterator, accs []*accumulator, opts *StreamOptions) (Iterator, error) {
+	limit := opts.memoryLimit()
+	groups := make(map[interface{}]*groupState)
+	var size int64
+	var runs []*spillFile
+
+	cleanup := func() {
+		for _, run := range runs {
+			run.Close()
+		}
+	}
+	sortedStates := func() []*document.Document {
+		keys := make([]*groupState, 0, len(groups))
+		for _, state := range groups {
+			keys = append(keys, state)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return compareValues(keys[i].key, keys[j].key) < 0
+		})
+		docs := make([]*document.Document, len(keys))
+		for i, state := range keys {
+			docs[i] = state.encode()
+		}
+		return docs
+	}
+
+	for {
+		doc, err := input.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+
+		groupKey, err := s.id.eval(doc)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("failed to compute _id: %w", err)
+		}
+		state, exists := groups[hashableKey(groupKey)]
+		if !exists {
+			state = newGroupState(groupKey, len(accs))
+			groups[hashableKey(groupKey)] = state
+			size += estimateValueSize(groupKey) + int64(len(accs))*groupStateOverhead
+		}
+		if err := state.add(accs, doc); err != nil {
+			cleanup()
+			return nil, err
+		}
+
+		if limit > 0 && size > limit {
+			run, err := writeSpillFile(opts.TempDir, sortedStates())
+			if err != nil {
+				cleanup()
+				return nil, err
+			}
+			runs = append(runs, run)
+			opts.recordSpill()
+			groups, size = make(map[interface{}]*groupState), 0
+		}
+	}
+
+	if len(runs) == 0 {
+		results := make([]*document.Document, 0, len(groups))
+		for _, state := range groups {
+			results = append(results, state.result(accs))
+		}
+		return NewSliceIterator(results), nil
+	}
+
+	sources := make([]Iterator, 0, len(runs)+1)
+	for _, run := range runs {
+		sources = append(sources, run)
+	}
+	sources = append(sources, NewSliceIterator(sortedStates()))
+	merged, err := newMergeIterator(sources, func(a, b *document.Document) bool {
+		ka, _ := a.Get("_id")
+		kb, _ := b.Get("_id")
+		return compareValues(ka, kb) < 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &groupMergeIterator{input: merged, accs: accs}, nil
+}
+
+// groupMergeIterator combines consecutive partial states with the same key
+type groupMergeIterator struct {
+	input   Iterator
+	accs    []*accumulator
+	pending *groupState
+}
+
+func (it *groupMergeIterator) Next() (*document.Document, error) {
+	for {
+		doc, err := it.input.Next()
+		if err == io.EOF {
+			if it.pending == nil {
+				return nil, io.EOF
+			}
+			state := it.pending
+			it.pending = nil
+			return state.result(it.accs), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		state := decodeGroupState(doc, len(it.accs))
+		if it.pending == nil {
+			it.pending = state
+			continue
+		}
+		if compareValues(it.pending.key, state.key) == 0 {
+			for i, acc := range it.accs {
+				acc.merge(&it.pending.states[i], &state.states[i])
+			}
+			continue
+		}
+
+		done := it.pending
+		it.pending = state
+		return done.result(it.accs), nil
+	}
+}
+
+func (it *groupMergeIterator) Close() error {
+	return it.input.Close()
+}
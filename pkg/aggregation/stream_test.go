@@ -0,0 +1,244 @@
+package aggregation
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+func numberedDocs(n int) []*document.Document {
+	docs := make([]*document.Document, n)
+	for i := 0; i < n; i++ {
+		docs[i] = document.NewDocumentFromMap(map[string]interface{}{
+			"_id":      fmt.Sprintf("doc%03d", i),
+			"n":        int64((i * 37) % n),
+			"category": fmt.Sprintf("cat%d", i%7),
+			"price":    float64(i % 10),
+		})
+	}
+	return docs
+}
+
+func streamPipeline(t *testing.T, stages []map[string]interface{}, docs []*document.Document, opts *StreamOptions) []*document.Document {
+	t.Helper()
+	p, err := NewPipeline(stages)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	it, err := p.Stream(NewSliceIterator(docs), opts)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	results, err := Collect(it)
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	return results
+}
+
+func TestStreamMatchProjectSkipLimit(t *testing.T) {
+	docs := numberedDocs(50)
+	results := streamPipeline(t, []map[string]interface{}{
+		{"$match": map[string]interface{}{"category": "cat3"}},
+		{"$skip": 2},
+		{"$limit": 3},
+		{"$project": map[string]interface{}{"n": true}},
+	}, docs, nil)
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	// cat3 documents are 3, 10, 17, 24, ...; skipping two leaves 17, 24, 31
+	for i, want := range []int{17, 24, 31} {
+		n, _ := results[i].Get("n")
+		if n != int64((want*37)%50) {
+			t.Errorf("Result %d: expected n=%d, got %v", i, (want*37)%50, n)
+		}
+		if _, exists := results[i].Get("category"); exists {
+			t.Errorf("Result %d: expected category to be projected out", i)
+		}
+	}
+}
+
+// countingIterator counts how many documents were read from it
+type countingIterator struct {
+	Iterator
+	read int
+}
+
+func (it *countingIterator) Next() (*document.Document, error) {
+	doc, err := it.Iterator.Next()
+	if err == nil {
+		it.read++
+	}
+	return doc, err
+}
+
+func TestStreamLimitStopsReading(t *testing.T) {
+	p, err := NewPipeline([]map[string]interface{}{{"$limit": 5}})
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	source := &countingIterator{Iterator: NewSliceIterator(numberedDocs(100))}
+	it, err := p.Stream(source, nil)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	results, err := Collect(it)
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(results) != 5 {
+		t.Errorf("Expected 5 results, got %d", len(results))
+	}
+	if source.read != 5 {
+		t.Errorf("Expected 5 documents read from the source, got %d", source.read)
+	}
+}
+
+func TestStreamSortSpill(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := numberedDocs(200)
+
+	p, err := NewPipeline([]map[string]interface{}{{"$sort": map[string]interface{}{"n": -1}}})
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	it, err := p.Stream(NewSliceIterator(docs), &StreamOptions{MemoryLimit: 2048, TempDir: tmpDir})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	first, err := it.Next()
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) == 0 {
+		t.Fatal("Expected sort to spill to disk with a small memory limit")
+	}
+
+	prev, _ := first.Get("n")
+	count := 1
+	for {
+		doc, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		n, _ := doc.Get("n")
+		if n.(int64) > prev.(int64) {
+			t.Fatalf("Expected descending order, got %v after %v", n, prev)
+		}
+		prev = n
+		count++
+	}
+	if count != len(docs) {
+		t.Errorf("Expected %d documents, got %d", len(docs), count)
+	}
+
+	if err := it.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	entries, _ = os.ReadDir(tmpDir)
+	if len(entries) != 0 {
+		t.Errorf("Expected spill files to be removed, found %d", len(entries))
+	}
+}
+
+func TestStreamGroupSpill(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := numberedDocs(300)
+	stages := []map[string]interface{}{
+		{"$group": map[string]interface{}{
+			"_id":   "$category",
+			"total": map[string]interface{}{"$sum": "$price"},
+			"avg":   map[string]interface{}{"$avg": "$price"},
+			"count": map[string]interface{}{"$count": map[string]interface{}{}},
+			"max":   map[string]interface{}{"$max": "$n"},
+		}},
+	}
+
+	expected := make(map[interface{}]*document.Document)
+	p, _ := NewPipeline(stages)
+	inMemory, err := p.Execute(docs)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	for _, doc := range inMemory {
+		key, _ := doc.Get("_id")
+		expected[key] = doc
+	}
+
+	// A tiny budget spills after every few groups
+	results := streamPipeline(t, stages, docs, &StreamOptions{MemoryLimit: 100, TempDir: tmpDir})
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d groups, got %d", len(expected), len(results))
+	}
+	for _, doc := range results {
+		key, _ := doc.Get("_id")
+		want, ok := expected[key]
+		if !ok {
+			t.Fatalf("Unexpected group %v", key)
+		}
+		for _, field := range []string{"total", "avg", "count", "max"} {
+			got, _ := doc.Get(field)
+			exp, _ := want.Get(field)
+			if compareValues(got, exp) != 0 {
+				t.Errorf("Group %v: expected %s=%v, got %v", key, field, exp, got)
+			}
+		}
+	}
+
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 0 {
+		t.Errorf("Expected spill files to be removed, found %d", len(entries))
+	}
+}
+
+func TestStreamGroupInvalidAccumulator(t *testing.T) {
+	p, err := NewPipeline([]map[string]interface{}{
+		{"$group": map[string]interface{}{"_id": nil, "result": "invalid"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	if _, err := p.Stream(NewSliceIterator(nil), nil); err == nil {
+		t.Error("Expected error for invalid accumulator")
+	}
+}
+
+func TestStreamWithStats(t *testing.T) {
+	p, err := NewPipeline([]map[string]interface{}{
+		{"$match": map[string]interface{}{"price": map[string]interface{}{"$gte": float64(0)}}},
+		{"$group": map[string]interface{}{"_id": "$_id", "total": map[string]interface{}{"$sum": "$price"}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	it, stats, err := p.StreamWithStats(NewSliceIterator(numberedDocs(100)), &StreamOptions{MemoryLimit: 1024, TempDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("StreamWithStats failed: %v", err)
+	}
+	if len(stats) != 2 || stats[0].Stage != "$match" || stats[1].Stage != "$group" {
+		t.Fatalf("Expected stats for $match and $group, got %+v", stats)
+	}
+	if stats[1].Spilled {
+		t.Error("Expected no spill before the output is read")
+	}
+
+	if _, err := Collect(it); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if stats[0].Spilled {
+		t.Error("Expected $match not to spill")
+	}
+	if !stats[1].Spilled || stats[1].SpillFiles < 2 {
+		t.Errorf("Expected $group to spill several files, got %+v", stats[1])
+	}
+}
@@ -13,21 +13,22 @@ import (
 type OperationType string
 
 const (
-	OperationInsert           OperationType = "insert"
-	OperationInsertMany       OperationType = "insertMany"
-	OperationUpdate           OperationType = "update"
-	OperationUpdateMany       OperationType = "updateMany"
-	OperationDelete           OperationType = "delete"
-	OperationDeleteMany       OperationType = "deleteMany"
-	OperationFind             OperationType = "find"
-	OperationFindOne          OperationType = "findOne"
-	OperationAggregate        OperationType = "aggregate"
-	OperationCreateIndex      OperationType = "createIndex"
-	OperationDropIndex        OperationType = "dropIndex"
-	OperationCreateCollection OperationType = "createCollection"
-	OperationDropCollection   OperationType = "dropCollection"
-	OperationTextSearch       OperationType = "textSearch"
-	OperationCount            OperationType = "count"
+	OperationInsert             OperationType = "insert"
+	OperationInsertMany         OperationType = "insertMany"
+	OperationUpdate             OperationType = "update"
+	OperationUpdateMany         OperationType = "updateMany"
+	OperationDelete             OperationType = "delete"
+	OperationDeleteMany         OperationType = "deleteMany"
+	OperationFind               OperationType = "find"
+	OperationFindOne            OperationType = "findOne"
+	OperationAggregate          OperationType = "aggregate"
+	OperationCreateIndex        OperationType = "createIndex"
+	OperationDropIndex          OperationType = "dropIndex"
+	OperationCreateCollection   OperationType = "createCollection"
+	OperationDropCollection     OperationType = "dropCollection"
+	OperationTruncateCollection OperationType = "truncateCollection"
+	OperationTextSearch         OperationType = "textSearch"
+	OperationCount              OperationType = "count"
 )
 
 // Severity represents the severity level of an audit event
@@ -335,14 +336,43 @@ func (l *AuditLogger) IsEnabled() bool {
 	return l.config.Enabled
 }
 
-// shouldLog determines if an event should be logged based on severity
-func (l *AuditLogger) shouldLog(severity Severity) bool {
-	severityLevels := map[Severity]int{
-		SeverityInfo:    1,
-		SeverityWarning: 2,
-		SeverityError:   3,
+// SetMinSeverity changes the minimum severity of the events logged at
+// runtime
+func (l *AuditLogger) SetMinSeverity(severity Severity) error {
+	if _, err := ParseSeverity(string(severity)); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config.MinSeverity = severity
+	return nil
+}
+
+// MinSeverity returns the minimum severity of the events logged
+func (l *AuditLogger) MinSeverity() Severity {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.config.MinSeverity
+}
+
+// severityLevels orders the severities
+var severityLevels = map[Severity]int{
+	SeverityInfo:    1,
+	SeverityWarning: 2,
+	SeverityError:   3,
+}
+
+// ParseSeverity parses a severity name: info, warning or error
+func ParseSeverity(name string) (Severity, error) {
+	severity := Severity(name)
+	if _, ok := severityLevels[severity]; !ok {
+		return "", fmt.Errorf("unknown audit severity %q: expected info, warning or error", name)
 	}
+	return severity, nil
+}
 
+// shouldLog determines if an event should be logged based on severity
+func (l *AuditLogger) shouldLog(severity Severity) bool {
 	return severityLevels[severity] >= severityLevels[l.config.MinSeverity]
 }
 
@@ -505,6 +505,43 @@ func TestSetEnabled(t *testing.T) {
 	}
 }
 
+func TestSetMinSeverity(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewAuditLogger(&Config{
+		Enabled:      true,
+		OutputWriter: &buf,
+		Format:       "json",
+		MinSeverity:  SeverityInfo,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	if err := logger.SetMinSeverity(SeverityError); err != nil {
+		t.Fatalf("SetMinSeverity failed: %v", err)
+	}
+	if logger.MinSeverity() != SeverityError {
+		t.Errorf("Expected minimum severity error, got %s", logger.MinSeverity())
+	}
+
+	// Events below the new minimum are dropped
+	logger.Log(&AuditEvent{Operation: OperationInsert, Success: true, Severity: SeverityInfo})
+	if buf.Len() > 0 {
+		t.Error("Expected no output for an info event")
+	}
+	logger.Log(&AuditEvent{Operation: OperationInsert, Success: false, Severity: SeverityError})
+	if buf.Len() == 0 {
+		t.Error("Expected output for an error event")
+	}
+
+	if err := logger.SetMinSeverity("verbose"); err == nil {
+		t.Error("Expected an unknown severity to be rejected")
+	}
+	if logger.MinSeverity() != SeverityError {
+		t.Errorf("Expected the minimum severity unchanged, got %s", logger.MinSeverity())
+	}
+}
+
 func TestTextFormat(t *testing.T) {
 	var buf bytes.Buffer
 	config := &Config{
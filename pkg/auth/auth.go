@@ -60,6 +60,7 @@ const (
 	PermissionDropCollection   Permission = "dropCollection"
 	PermissionManageUsers  Permission = "manageUsers"
 	PermissionViewStats    Permission = "viewStats"
+	PermissionManageServer Permission = "manageServer"
 )
 
 // rolePermissions maps roles to their permissions
@@ -73,6 +74,7 @@ var rolePermissions = map[Role][]Permission{
 		PermissionDropCollection,
 		PermissionManageUsers,
 		PermissionViewStats,
+		PermissionManageServer,
 	},
 	RoleReadWrite: {
 		PermissionRead,
@@ -320,9 +320,11 @@ func TestHasPermission(t *testing.T) {
 		{RoleAdmin, PermissionRead, true},
 		{RoleAdmin, PermissionWrite, true},
 		{RoleAdmin, PermissionManageUsers, true},
+		{RoleAdmin, PermissionManageServer, true},
 		{RoleReadWrite, PermissionRead, true},
 		{RoleReadWrite, PermissionWrite, true},
 		{RoleReadWrite, PermissionManageUsers, false},
+		{RoleReadWrite, PermissionManageServer, false},
 		{RoleRead, PermissionRead, true},
 		{RoleRead, PermissionWrite, false},
 		{RoleRead, PermissionManageUsers, false},
@@ -575,8 +577,8 @@ func TestGetUserDoesNotExposeSensitiveData(t *testing.T) {
 func TestRolePermissions(t *testing.T) {
 	// Verify role permission mappings are correct
 	adminPerms := rolePermissions[RoleAdmin]
-	if len(adminPerms) != 8 {
-		t.Errorf("Expected 8 admin permissions, got %d", len(adminPerms))
+	if len(adminPerms) != 9 {
+		t.Errorf("Expected 9 admin permissions, got %d", len(adminPerms))
 	}
 
 	rwPerms := rolePermissions[RoleReadWrite]
@@ -25,6 +25,7 @@ type BackupFormat struct {
 type CollectionBackup struct {
 	Name      string            `json:"name"`
 	Documents []DocumentBackup  `json:"documents"`
+	Archived  []DocumentBackup  `json:"archived,omitempty"` // Documents in the cold tier
 	Indexes   []IndexBackup     `json:"indexes"`
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 }
@@ -120,6 +121,21 @@ func (bf *BackupFormat) AddCollection(name string, docs []*document.Document, in
 	bf.Collections = append(bf.Collections, collBackup)
 }
 
+// AddArchivedDocuments adds the documents of a collection's cold tier to the
+// backup. The collection must have been added with AddCollection.
+func (bf *BackupFormat) AddArchivedDocuments(name string, docs []*document.Document) error {
+	for i := range bf.Collections {
+		if bf.Collections[i].Name != name {
+			continue
+		}
+		for _, doc := range docs {
+			bf.Collections[i].Archived = append(bf.Collections[i].Archived, convertDocumentToBackup(doc))
+		}
+		return nil
+	}
+	return fmt.Errorf("collection %s is not in the backup", name)
+}
+
 // convertDocumentToBackup converts a Document to DocumentBackup
 func convertDocumentToBackup(doc *document.Document) DocumentBackup {
 	docMap := doc.ToMap()
@@ -96,6 +96,12 @@ func ConvertDocumentFromBackup(docBackup DocumentBackup) (map[string]interface{}
 	return result, nil
 }
 
+// ConvertValueFromBackup converts a value decoded from backup JSON back to
+// a document value, such as whole numbers to int64 and hex ids to ObjectIDs
+func ConvertValueFromBackup(value interface{}) (interface{}, error) {
+	return convertValueFromBackup(value)
+}
+
 // convertValueFromBackup converts backup values back to document values
 func convertValueFromBackup(value interface{}) (interface{}, error) {
 	switch v := value.(type) {
@@ -2,13 +2,15 @@ package changestream
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/mnohosten/laura-db/pkg/document"
 	"github.com/mnohosten/laura-db/pkg/query"
-	"github.com/mnohosten/laura-db/pkg/replication"
+	"github.com/mnohosten/laura-db/pkg/oplog"
 )
 
 // OperationType represents the type of change operation
@@ -26,13 +28,22 @@ const (
 	OperationTypeCreateIndex        OperationType = "createIndex"
 	OperationTypeDropIndex          OperationType = "dropIndex"
 	OperationTypeCreateCollection   OperationType = "createCollection"
+	OperationTypeTruncate           OperationType = "truncate"
 )
 
+// ErrInvalidated is returned by Next once a stream has delivered its
+// invalidate event: the collection it watched was dropped or renamed, so
+// there is nothing left to watch.
+var ErrInvalidated = errors.New("change stream invalidated")
+
 // ChangeEvent represents a single change in the database
 type ChangeEvent struct {
-	// ID is the resume token (oplog OpID) for this event
+	// ID is the resume token (oplog position) for this event
 	ID ResumeToken `json:"_id"`
 
+	// Shard is the oplog the event came from in a merged stream
+	Shard string `json:"shard,omitempty"`
+
 	// OperationType describes the type of operation
 	OperationType OperationType `json:"operationType"`
 
@@ -50,11 +61,24 @@ type ChangeEvent struct {
 	// or the current version after update (if fullDocument is set to "updateLookup")
 	FullDocument map[string]interface{} `json:"fullDocument,omitempty"`
 
+	// DocumentDeleted is set on update events of an "updateLookup" stream
+	// when the document was deleted before it could be looked up
+	DocumentDeleted bool `json:"documentDeleted,omitempty"`
+
 	// UpdateDescription contains information about updated fields
 	UpdateDescription *UpdateDescription `json:"updateDescription,omitempty"`
 
 	// For index operations
 	IndexDefinition map[string]interface{} `json:"indexDefinition,omitempty"`
+
+	// To is the new namespace of a renamed collection
+	To *Namespace `json:"to,omitempty"`
+}
+
+// Namespace identifies a collection in a database
+type Namespace struct {
+	Database   string `json:"db"`
+	Collection string `json:"coll"`
 }
 
 // UpdateDescription describes what was updated in an update operation
@@ -63,9 +87,22 @@ type UpdateDescription struct {
 	RemovedFields []string               `json:"removedFields"`
 }
 
-// ResumeToken is an opaque token that can be used to resume a change stream
+// ResumeToken is an opaque token that can be used to resume a change stream.
+// A merged stream records its position in each of its oplogs in Shards.
 type ResumeToken struct {
-	OpID replication.OpID `json:"opId"`
+	OpID        oplog.OpID            `json:"opId"`
+	ClusterTime oplog.ClusterTime     `json:"clusterTime,omitempty"`
+	Shards      map[string]oplog.OpID `json:"shards,omitempty"`
+}
+
+// OplogSource returns the oplogs a merged change stream reads, keyed by
+// shard. It is called on every poll, so oplogs added later are picked up.
+type OplogSource func() (map[string]*oplog.Oplog, error)
+
+// shardEntry is an oplog entry of a merged stream waiting to be delivered
+type shardEntry struct {
+	shard string
+	entry *oplog.OplogEntry
 }
 
 // FullDocumentOption controls when to include the full document in change events
@@ -75,7 +112,8 @@ const (
 	// FullDocumentDefault does not include full document (only for inserts)
 	FullDocumentDefault FullDocumentOption = "default"
 
-	// FullDocumentUpdateLookup includes full document after update operations
+	// FullDocumentUpdateLookup includes full document after update operations.
+	// It needs a stream created with NewChangeStreamWithDB.
 	FullDocumentUpdateLookup FullDocumentOption = "updateLookup"
 )
 
@@ -111,11 +149,15 @@ func DefaultChangeStreamOptions() *ChangeStreamOptions {
 
 // ChangeStream represents an active change stream
 type ChangeStream struct {
-	oplog      *replication.Oplog
+	oplog      *oplog.Oplog
+	source     OplogSource           // Oplogs of a merged stream
+	cursors    map[string]oplog.OpID // Last entry read from each oplog of a merged stream
+	pending    []shardEntry          // Entries read past the watermark of a merged stream
 	database   string
 	collection string
 	options    *ChangeStreamOptions
 	filter     *query.Query
+	lookup     DocumentLookup // Source of full documents for FullDocumentUpdateLookup, nil for none
 
 	// Event channel
 	events chan *ChangeEvent
@@ -124,17 +166,21 @@ type ChangeStream struct {
 	// Context for cancellation
 	ctx    context.Context
 	cancel context.CancelFunc
+	done   chan struct{} // closed when the watch loop exits
 
 	// Current position
 	mu              sync.RWMutex
 	currentResumeToken ResumeToken
+	acknowledged       ResumeToken // Token of the last event returned by Next or TryNext
 
 	// State
-	closed bool
+	started     bool
+	closed      bool
+	invalidated bool // The invalidate event was delivered
 }
 
 // NewChangeStream creates a new change stream
-func NewChangeStream(oplog *replication.Oplog, database, collection string, options *ChangeStreamOptions) *ChangeStream {
+func NewChangeStream(oplog *oplog.Oplog, database, collection string, options *ChangeStreamOptions) *ChangeStream {
 	if options == nil {
 		options = DefaultChangeStreamOptions()
 	}
@@ -150,6 +196,7 @@ func NewChangeStream(oplog *replication.Oplog, database, collection string, opti
 		errors:     make(chan error, 10),
 		ctx:        ctx,
 		cancel:     cancel,
+		done:       make(chan struct{}),
 		closed:     false,
 	}
 
@@ -160,10 +207,70 @@ func NewChangeStream(oplog *replication.Oplog, database, collection string, opti
 		// Start from current position
 		cs.currentResumeToken = ResumeToken{OpID: oplog.GetCurrentID()}
 	}
+	cs.acknowledged = cs.currentResumeToken
 
 	return cs
 }
 
+// NewDatabaseChangeStream creates a change stream over every collection of
+// a database, or of all databases if dbName is empty. Events come in oplog
+// order across collections and name their collection, so consumers can
+// route them. Dropping or renaming a collection doesn't end the stream.
+func NewDatabaseChangeStream(oplog *oplog.Oplog, dbName string, options *ChangeStreamOptions) *ChangeStream {
+	return NewChangeStream(oplog, dbName, "", options)
+}
+
+// NewMergedChangeStream creates a change stream over several oplogs, such
+// as those of the shards of a sharded collection. Events of all oplogs are
+// delivered in cluster time order, and the resume token records the
+// position in each oplog. Oplogs the source returns later are read from
+// the point the stream had reached when they were found.
+func NewMergedChangeStream(source OplogSource, database, collection string, options *ChangeStreamOptions) (*ChangeStream, error) {
+	if options == nil {
+		options = DefaultChangeStreamOptions()
+	}
+
+	cursors := make(map[string]oplog.OpID)
+	var token ResumeToken
+	if options.ResumeAfter != nil {
+		token.ClusterTime = options.ResumeAfter.ClusterTime
+		for shard, opID := range options.ResumeAfter.Shards {
+			cursors[shard] = opID
+		}
+	} else {
+		// Start from the current position of each oplog
+		token.ClusterTime = oplog.NextClusterTime()
+		logs, err := source()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list oplogs: %w", err)
+		}
+		for shard, log := range logs {
+			cursors[shard] = log.GetCurrentID()
+		}
+	}
+	token.Shards = make(map[string]oplog.OpID, len(cursors))
+	for shard, opID := range cursors {
+		token.Shards[shard] = opID
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cs := &ChangeStream{
+		source:             source,
+		cursors:            cursors,
+		database:           database,
+		collection:         collection,
+		options:            options,
+		events:             make(chan *ChangeEvent, options.BatchSize),
+		errors:             make(chan error, 10),
+		ctx:                ctx,
+		cancel:             cancel,
+		done:               make(chan struct{}),
+		currentResumeToken: token,
+	}
+	cs.acknowledged = cs.copyResumeToken()
+	return cs, nil
+}
+
 // SetFilter sets a filter for change events
 func (cs *ChangeStream) SetFilter(filter map[string]interface{}) error {
 	if filter == nil {
@@ -182,14 +289,36 @@ func (cs *ChangeStream) Start() error {
 		cs.mu.Unlock()
 		return fmt.Errorf("change stream is closed")
 	}
+	if cs.started {
+		cs.mu.Unlock()
+		return nil
+	}
+	cs.started = true
 	cs.mu.Unlock()
 
+	if cs.oplog != nil {
+		// Entries the stream hasn't read yet are kept when the oplog is trimmed
+		cs.oplog.Retain(cs.retainerName(), func() oplog.OpID {
+			return cs.ResumeToken().OpID
+		})
+	}
+
 	go cs.watchLoop()
 	return nil
 }
 
+// retainerName names the stream among the consumers of its oplog
+func (cs *ChangeStream) retainerName() string {
+	return fmt.Sprintf("changestream-%p", cs)
+}
+
 // watchLoop continuously polls the oplog for new entries
 func (cs *ChangeStream) watchLoop() {
+	defer close(cs.done)
+	if cs.oplog != nil {
+		defer cs.oplog.Release(cs.retainerName())
+	}
+
 	ticker := time.NewTicker(cs.options.MaxAwaitTime)
 	defer ticker.Stop()
 
@@ -198,8 +327,19 @@ func (cs *ChangeStream) watchLoop() {
 		case <-cs.ctx.Done():
 			return
 		case <-ticker.C:
-			if err := cs.pollOplog(); err != nil {
-				cs.errors <- err
+			poll := cs.pollOplog
+			if cs.source != nil {
+				poll = cs.pollMerged
+			}
+			if err := poll(); err != nil {
+				select {
+				case cs.errors <- err:
+				case <-cs.ctx.Done():
+					return
+				}
+			}
+			if cs.isInvalidated() {
+				return
 			}
 		}
 	}
@@ -211,6 +351,11 @@ func (cs *ChangeStream) pollOplog() error {
 	currentToken := cs.currentResumeToken
 	cs.mu.RUnlock()
 
+	// A stream resumed after trimmed entries would silently miss them
+	if err := cs.oplog.CheckRetained(currentToken.OpID); err != nil {
+		return err
+	}
+
 	// Get new entries since last position
 	entries, err := cs.oplog.GetEntriesSince(currentToken.OpID)
 	if err != nil {
@@ -219,61 +364,225 @@ func (cs *ChangeStream) pollOplog() error {
 
 	// Convert entries to change events
 	for _, entry := range entries {
-		// Filter by database and collection
-		if cs.database != "" && entry.Database != cs.database {
-			continue
-		}
-		if cs.collection != "" && entry.Collection != cs.collection {
-			continue
+		event := cs.toChangeEvent(entry)
+		if event != nil {
+			// Update resume token
+			cs.mu.Lock()
+			cs.currentResumeToken = event.ID
+			cs.mu.Unlock()
+
+			if !cs.send(event) {
+				return nil
+			}
 		}
 
-		// Convert to change event
-		event := cs.convertToChangeEvent(entry)
-		if event == nil {
-			continue // Skip unsupported operations
-		}
+		if cs.invalidatedBy(entry) {
+			token := ResumeToken{OpID: entry.OpID, ClusterTime: entry.ClusterTime}
+			cs.mu.Lock()
+			cs.currentResumeToken = token
+			cs.mu.Unlock()
 
-		// Apply filter if set
-		if cs.filter != nil && !cs.matchesFilter(event) {
-			continue
+			cs.invalidate(invalidateEvent(entry, token))
+			return nil
 		}
+	}
+
+	return nil
+}
+
+// pollMerged reads new entries of all oplogs of a merged stream and
+// delivers those before a watermark in cluster time order. Cluster times are
+// taken under the oplog lock, so once the watermark is taken no entry can
+// appear before it: later entries wait for the next poll.
+func (cs *ChangeStream) pollMerged() error {
+	watermark := oplog.NextClusterTime()
+
+	logs, err := cs.source()
+	if err != nil {
+		return fmt.Errorf("failed to list oplogs: %w", err)
+	}
+
+	cs.mu.RLock()
+	reached := cs.currentResumeToken.ClusterTime
+	cs.mu.RUnlock()
 
-		// Apply pipeline transformations if set
-		if len(cs.options.Pipeline) > 0 {
-			event = cs.applyPipeline(event)
-			if event == nil {
-				continue // Filtered out by pipeline
+	for shard, log := range logs {
+		cursor, known := cs.cursors[shard]
+		entries, err := log.GetEntriesSince(cursor)
+		if err != nil {
+			return fmt.Errorf("failed to fetch oplog entries of %s: %w", shard, err)
+		}
+		for _, entry := range entries {
+			cursor = entry.OpID
+			// A newly found oplog starts where the stream is
+			if !known && entry.ClusterTime <= reached {
+				continue
 			}
+			cs.pending = append(cs.pending, shardEntry{shard: shard, entry: entry})
+		}
+		cs.cursors[shard] = cursor
+	}
+
+	sort.SliceStable(cs.pending, func(i, j int) bool {
+		a, b := cs.pending[i], cs.pending[j]
+		if a.entry.ClusterTime != b.entry.ClusterTime {
+			return a.entry.ClusterTime < b.entry.ClusterTime
 		}
+		return a.shard < b.shard
+	})
 
-		// Update resume token
+	ready := 0
+	for ready < len(cs.pending) && cs.pending[ready].entry.ClusterTime <= watermark {
+		ready++
+	}
+
+	for _, pending := range cs.pending[:ready] {
+		// Every entry moves the position, including those filtered out
 		cs.mu.Lock()
-		cs.currentResumeToken = event.ID
+		cs.currentResumeToken.ClusterTime = pending.entry.ClusterTime
+		cs.currentResumeToken.Shards[pending.shard] = pending.entry.OpID
+		token := cs.copyResumeToken()
 		cs.mu.Unlock()
 
-		// Send event (non-blocking)
+		if event := cs.toChangeEvent(pending.entry); event != nil {
+			event.ID = token
+			event.Shard = pending.shard
+
+			if !cs.send(event) {
+				return nil
+			}
+		}
+
+		if cs.invalidatedBy(pending.entry) {
+			event := invalidateEvent(pending.entry, token)
+			event.Shard = pending.shard
+			cs.invalidate(event)
+			return nil
+		}
+	}
+	cs.pending = cs.pending[ready:]
+
+	// All entries up to the watermark have been delivered
+	cs.mu.Lock()
+	cs.currentResumeToken.ClusterTime = watermark
+	cs.mu.Unlock()
+
+	return nil
+}
+
+// copyResumeToken returns a copy of the current resume token. Must be called
+// with cs.mu held.
+func (cs *ChangeStream) copyResumeToken() ResumeToken {
+	token := cs.currentResumeToken
+	if token.Shards != nil {
+		token.Shards = make(map[string]oplog.OpID, len(cs.currentResumeToken.Shards))
+		for shard, opID := range cs.currentResumeToken.Shards {
+			token.Shards[shard] = opID
+		}
+	}
+	return token
+}
+
+// toChangeEvent converts an oplog entry to a change event if it belongs to
+// the stream's namespace and passes its filter and pipeline
+func (cs *ChangeStream) toChangeEvent(entry *oplog.OplogEntry) *ChangeEvent {
+	// Filter by database and collection
+	if cs.database != "" && entry.Database != cs.database {
+		return nil
+	}
+	if cs.collection != "" && entry.Collection != cs.collection {
+		return nil
+	}
+
+	// Convert to change event
+	event := cs.convertToChangeEvent(entry)
+	if event == nil {
+		return nil // Skip unsupported operations
+	}
+
+	// Apply filter if set
+	if cs.filter != nil && !cs.matchesFilter(event) {
+		return nil
+	}
+
+	// Apply pipeline transformations if set
+	if len(cs.options.Pipeline) > 0 {
+		event = cs.applyPipeline(event)
+	}
+	return event
+}
+
+// send delivers an event, giving up on it if the buffer stays full. It
+// returns false once the stream is closed.
+func (cs *ChangeStream) send(event *ChangeEvent) bool {
+	// Send event (non-blocking)
+	select {
+	case cs.events <- event:
+	case <-cs.ctx.Done():
+		return false
+	default:
+		// Buffer full, drop oldest event (or could block)
+		// For now, we'll try to send but not block indefinitely
 		select {
 		case cs.events <- event:
 		case <-cs.ctx.Done():
-			return nil
-		default:
-			// Buffer full, drop oldest event (or could block)
-			// For now, we'll try to send but not block indefinitely
-			select {
-			case cs.events <- event:
-			case <-time.After(100 * time.Millisecond):
-				// Skip this event if buffer is full
-			}
+			return false
+		case <-time.After(100 * time.Millisecond):
+			// Skip this event if buffer is full
 		}
 	}
+	return true
+}
 
-	return nil
+// invalidatedBy reports whether an entry drops or renames the collection the
+// stream watches. Streams over a whole database carry on.
+func (cs *ChangeStream) invalidatedBy(entry *oplog.OplogEntry) bool {
+	if entry.OpType != oplog.OpTypeDropCollection && entry.OpType != oplog.OpTypeRenameCollection {
+		return false
+	}
+	if cs.collection == "" || entry.Collection != cs.collection {
+		return false
+	}
+	return cs.database == "" || entry.Database == cs.database
+}
+
+// invalidateEvent returns the event ending a stream at an entry
+func invalidateEvent(entry *oplog.OplogEntry, token ResumeToken) *ChangeEvent {
+	return &ChangeEvent{
+		ID:            token,
+		OperationType: OperationTypeInvalidate,
+		Timestamp:     entry.Timestamp,
+		Database:      entry.Database,
+		Collection:    entry.Collection,
+	}
+}
+
+// invalidate delivers the invalidate event and ends the stream. Unlike other
+// events it is never filtered out or given up on when the buffer is full.
+func (cs *ChangeStream) invalidate(event *ChangeEvent) {
+	select {
+	case cs.events <- event:
+	case <-cs.ctx.Done():
+		return
+	}
+
+	cs.mu.Lock()
+	cs.invalidated = true
+	cs.mu.Unlock()
+	cs.cancel()
+}
+
+// isInvalidated reports whether the invalidate event was delivered
+func (cs *ChangeStream) isInvalidated() bool {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.invalidated
 }
 
 // convertToChangeEvent converts an oplog entry to a change event
-func (cs *ChangeStream) convertToChangeEvent(entry *replication.OplogEntry) *ChangeEvent {
+func (cs *ChangeStream) convertToChangeEvent(entry *oplog.OplogEntry) *ChangeEvent {
 	event := &ChangeEvent{
-		ID: ResumeToken{OpID: entry.OpID},
+		ID: ResumeToken{OpID: entry.OpID, ClusterTime: entry.ClusterTime},
 		Timestamp: entry.Timestamp,
 		Database: entry.Database,
 		Collection: entry.Collection,
@@ -281,42 +590,52 @@ func (cs *ChangeStream) convertToChangeEvent(entry *replication.OplogEntry) *Cha
 
 	// Map oplog operation type to change stream operation type
 	switch entry.OpType {
-	case replication.OpTypeInsert:
+	case oplog.OpTypeInsert:
 		event.OperationType = OperationTypeInsert
 		event.FullDocument = entry.Document
 		if docID, ok := entry.Document["_id"]; ok {
 			event.DocumentKey = map[string]interface{}{"_id": docID}
 		}
 
-	case replication.OpTypeUpdate:
+	case oplog.OpTypeUpdate:
 		event.OperationType = OperationTypeUpdate
 		if entry.DocID != nil {
 			event.DocumentKey = map[string]interface{}{"_id": entry.DocID}
 		}
 		// Parse update description
 		event.UpdateDescription = cs.parseUpdateDescription(entry.Update)
+		cs.lookupFullDocument(event)
 
-	case replication.OpTypeDelete:
+	case oplog.OpTypeDelete:
 		event.OperationType = OperationTypeDelete
 		if entry.DocID != nil {
 			event.DocumentKey = map[string]interface{}{"_id": entry.DocID}
 		}
 
-	case replication.OpTypeCreateCollection:
+	case oplog.OpTypeCreateCollection:
 		event.OperationType = OperationTypeCreateCollection
 
-	case replication.OpTypeDropCollection:
+	case oplog.OpTypeDropCollection:
 		event.OperationType = OperationTypeDropCollection
 
-	case replication.OpTypeCreateIndex:
+	case oplog.OpTypeRenameCollection:
+		event.OperationType = OperationTypeRename
+		if to, ok := entry.Document["to"].(string); ok {
+			event.To = &Namespace{Database: entry.Database, Collection: to}
+		}
+
+	case oplog.OpTypeTruncateCollection:
+		event.OperationType = OperationTypeTruncate
+
+	case oplog.OpTypeCreateIndex:
 		event.OperationType = OperationTypeCreateIndex
 		event.IndexDefinition = entry.IndexDef
 
-	case replication.OpTypeDropIndex:
+	case oplog.OpTypeDropIndex:
 		event.OperationType = OperationTypeDropIndex
 		event.IndexDefinition = entry.IndexDef
 
-	case replication.OpTypeNoop:
+	case oplog.OpTypeNoop:
 		// Skip noop operations
 		return nil
 
@@ -363,23 +682,7 @@ func (cs *ChangeStream) parseUpdateDescription(update map[string]interface{}) *U
 
 // matchesFilter checks if a change event matches the filter
 func (cs *ChangeStream) matchesFilter(event *ChangeEvent) bool {
-	// Convert event to a document format for query evaluation
-	docMap := map[string]interface{}{
-		"operationType": string(event.OperationType),
-		"database":      event.Database,
-		"collection":    event.Collection,
-	}
-
-	if event.FullDocument != nil {
-		docMap["fullDocument"] = event.FullDocument
-	}
-
-	if event.DocumentKey != nil {
-		docMap["documentKey"] = event.DocumentKey
-	}
-
-	doc := document.NewDocumentFromMap(docMap)
-	matches, err := cs.filter.Matches(doc)
+	matches, err := cs.filter.Matches(eventDocument(event))
 	if err != nil {
 		return false
 	}
@@ -394,15 +697,7 @@ func (cs *ChangeStream) applyPipeline(event *ChangeEvent) *ChangeEvent {
 		if matchStage, ok := stage["$match"].(map[string]interface{}); ok {
 			q := query.NewQuery(matchStage)
 
-			// Convert event to document for matching
-			docMap := map[string]interface{}{
-				"operationType": string(event.OperationType),
-				"database":      event.Database,
-				"collection":    event.Collection,
-			}
-			doc := document.NewDocumentFromMap(docMap)
-
-			matches, err := q.Matches(doc)
+			matches, err := q.Matches(eventDocument(event))
 			if err != nil || !matches {
 				return nil // Filtered out
 			}
@@ -412,16 +707,62 @@ func (cs *ChangeStream) applyPipeline(event *ChangeEvent) *ChangeEvent {
 	return event
 }
 
-// Next returns the next change event (blocking)
+// eventDocument returns the document filters and $match stages are
+// evaluated against. Its fields are named as in the JSON form of the
+// event, except for database and collection, so conditions can reach into
+// the changed document with paths such as "fullDocument.status". Update
+// events only have a fullDocument with FullDocumentUpdateLookup.
+func eventDocument(event *ChangeEvent) *document.Document {
+	docMap := map[string]interface{}{
+		"operationType": string(event.OperationType),
+		"database":      event.Database,
+		"collection":    event.Collection,
+	}
+
+	if event.FullDocument != nil {
+		docMap["fullDocument"] = event.FullDocument
+	}
+
+	if event.DocumentKey != nil {
+		docMap["documentKey"] = event.DocumentKey
+	}
+
+	if event.UpdateDescription != nil {
+		removed := make([]interface{}, len(event.UpdateDescription.RemovedFields))
+		for i, field := range event.UpdateDescription.RemovedFields {
+			removed[i] = field
+		}
+		docMap["updateDescription"] = map[string]interface{}{
+			"updatedFields": event.UpdateDescription.UpdatedFields,
+			"removedFields": removed,
+		}
+	}
+
+	return document.NewDocumentFromMap(docMap)
+}
+
+// Next returns the next change event (blocking). After the invalidate event
+// it returns ErrInvalidated.
 func (cs *ChangeStream) Next(ctx context.Context) (*ChangeEvent, error) {
 	select {
 	case event := <-cs.events:
-		return event, nil
+		return cs.acknowledge(event), nil
 	case err := <-cs.errors:
 		return nil, err
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	case <-cs.ctx.Done():
+		// Events delivered before the stream ended come first
+		select {
+		case event, ok := <-cs.events:
+			if ok {
+				return cs.acknowledge(event), nil
+			}
+		default:
+		}
+		if cs.isInvalidated() {
+			return nil, ErrInvalidated
+		}
 		return nil, fmt.Errorf("change stream closed")
 	}
 }
@@ -430,10 +771,13 @@ func (cs *ChangeStream) Next(ctx context.Context) (*ChangeEvent, error) {
 func (cs *ChangeStream) TryNext() (*ChangeEvent, error) {
 	select {
 	case event := <-cs.events:
-		return event, nil
+		return cs.acknowledge(event), nil
 	case err := <-cs.errors:
 		return nil, err
 	default:
+		if cs.isInvalidated() {
+			return nil, ErrInvalidated
+		}
 		return nil, nil // No event available
 	}
 }
@@ -455,17 +799,27 @@ func (cs *ChangeStream) ResumeToken() ResumeToken {
 	return cs.currentResumeToken
 }
 
-// Close closes the change stream
+// Done returns a channel that is closed when the change stream is closed
+func (cs *ChangeStream) Done() <-chan struct{} {
+	return cs.ctx.Done()
+}
+
+// Close closes the change stream, waiting for the watch loop to stop before
+// closing the event and error channels
 func (cs *ChangeStream) Close() error {
 	cs.mu.Lock()
-	defer cs.mu.Unlock()
-
 	if cs.closed {
+		cs.mu.Unlock()
 		return nil
 	}
-
 	cs.closed = true
+	started := cs.started
+	cs.mu.Unlock()
+
 	cs.cancel()
+	if started {
+		<-cs.done
+	}
 	close(cs.events)
 	close(cs.errors)
 
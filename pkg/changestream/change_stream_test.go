@@ -2,15 +2,17 @@ package changestream
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
-	"github.com/mnohosten/laura-db/pkg/replication"
+	"github.com/mnohosten/laura-db/pkg/oplog"
 )
 
-func setupTestOplog(t *testing.T) (*replication.Oplog, string) {
+func setupTestOplog(t *testing.T) (*oplog.Oplog, string) {
 	// Create temporary directory
 	tmpDir, err := os.MkdirTemp("", "changestream-test-*")
 	if err != nil {
@@ -18,25 +20,25 @@ func setupTestOplog(t *testing.T) (*replication.Oplog, string) {
 	}
 
 	oplogPath := filepath.Join(tmpDir, "oplog.bin")
-	oplog, err := replication.NewOplog(oplogPath)
+	log, err := oplog.NewOplog(oplogPath)
 	if err != nil {
 		os.RemoveAll(tmpDir)
 		t.Fatalf("Failed to create oplog: %v", err)
 	}
 
-	return oplog, tmpDir
+	return log, tmpDir
 }
 
-func cleanupTestOplog(oplog *replication.Oplog, tmpDir string) {
-	oplog.Close()
+func cleanupTestOplog(log *oplog.Oplog, tmpDir string) {
+	log.Close()
 	os.RemoveAll(tmpDir)
 }
 
 func TestNewChangeStream(t *testing.T) {
-	oplog, tmpDir := setupTestOplog(t)
-	defer cleanupTestOplog(oplog, tmpDir)
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
 
-	cs := NewChangeStream(oplog, "testdb", "testcoll", nil)
+	cs := NewChangeStream(log, "testdb", "testcoll", nil)
 	if cs == nil {
 		t.Fatal("Expected change stream to be created")
 	}
@@ -57,11 +59,11 @@ func TestNewChangeStream(t *testing.T) {
 }
 
 func TestChangeStreamInsertEvent(t *testing.T) {
-	oplog, tmpDir := setupTestOplog(t)
-	defer cleanupTestOplog(oplog, tmpDir)
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
 
 	// Create change stream
-	cs := NewChangeStream(oplog, "testdb", "users", nil)
+	cs := NewChangeStream(log, "testdb", "users", nil)
 	if err := cs.Start(); err != nil {
 		t.Fatalf("Failed to start change stream: %v", err)
 	}
@@ -73,8 +75,8 @@ func TestChangeStreamInsertEvent(t *testing.T) {
 		"name": "Alice",
 		"age":  int64(30),
 	}
-	entry := replication.CreateInsertEntry("testdb", "users", doc)
-	if err := oplog.Append(entry); err != nil {
+	entry := oplog.CreateInsertEntry("testdb", "users", doc)
+	if err := log.Append(entry); err != nil {
 		t.Fatalf("Failed to append to oplog: %v", err)
 	}
 
@@ -110,10 +112,10 @@ func TestChangeStreamInsertEvent(t *testing.T) {
 }
 
 func TestChangeStreamUpdateEvent(t *testing.T) {
-	oplog, tmpDir := setupTestOplog(t)
-	defer cleanupTestOplog(oplog, tmpDir)
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
 
-	cs := NewChangeStream(oplog, "testdb", "users", nil)
+	cs := NewChangeStream(log, "testdb", "users", nil)
 	if err := cs.Start(); err != nil {
 		t.Fatalf("Failed to start change stream: %v", err)
 	}
@@ -127,10 +129,10 @@ func TestChangeStreamUpdateEvent(t *testing.T) {
 			"age":  int64(31),
 		},
 	}
-	entry := replication.CreateUpdateEntry("testdb", "users", filter, update)
+	entry := oplog.CreateUpdateEntry("testdb", "users", filter, update)
 	entry.DocID = "user1" // Manually set for testing
 
-	if err := oplog.Append(entry); err != nil {
+	if err := log.Append(entry); err != nil {
 		t.Fatalf("Failed to append to oplog: %v", err)
 	}
 
@@ -158,10 +160,10 @@ func TestChangeStreamUpdateEvent(t *testing.T) {
 }
 
 func TestChangeStreamDeleteEvent(t *testing.T) {
-	oplog, tmpDir := setupTestOplog(t)
-	defer cleanupTestOplog(oplog, tmpDir)
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
 
-	cs := NewChangeStream(oplog, "testdb", "users", nil)
+	cs := NewChangeStream(log, "testdb", "users", nil)
 	if err := cs.Start(); err != nil {
 		t.Fatalf("Failed to start change stream: %v", err)
 	}
@@ -169,10 +171,10 @@ func TestChangeStreamDeleteEvent(t *testing.T) {
 
 	// Create delete entry
 	filter := map[string]interface{}{"_id": "user1"}
-	entry := replication.CreateDeleteEntry("testdb", "users", filter)
+	entry := oplog.CreateDeleteEntry("testdb", "users", filter)
 	entry.DocID = "user1" // Manually set for testing
 
-	if err := oplog.Append(entry); err != nil {
+	if err := log.Append(entry); err != nil {
 		t.Fatalf("Failed to append to oplog: %v", err)
 	}
 
@@ -196,10 +198,10 @@ func TestChangeStreamDeleteEvent(t *testing.T) {
 }
 
 func TestChangeStreamFilter(t *testing.T) {
-	oplog, tmpDir := setupTestOplog(t)
-	defer cleanupTestOplog(oplog, tmpDir)
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
 
-	cs := NewChangeStream(oplog, "testdb", "users", nil)
+	cs := NewChangeStream(log, "testdb", "users", nil)
 
 	// Set filter to only match insert operations
 	filter := map[string]interface{}{
@@ -216,15 +218,15 @@ func TestChangeStreamFilter(t *testing.T) {
 
 	// Insert a document (should match)
 	doc := map[string]interface{}{"_id": "user1", "name": "Alice"}
-	entry := replication.CreateInsertEntry("testdb", "users", doc)
-	if err := oplog.Append(entry); err != nil {
+	entry := oplog.CreateInsertEntry("testdb", "users", doc)
+	if err := log.Append(entry); err != nil {
 		t.Fatalf("Failed to append insert: %v", err)
 	}
 
 	// Delete a document (should not match)
-	deleteEntry := replication.CreateDeleteEntry("testdb", "users", map[string]interface{}{"_id": "user2"})
+	deleteEntry := oplog.CreateDeleteEntry("testdb", "users", map[string]interface{}{"_id": "user2"})
 	deleteEntry.DocID = "user2"
-	if err := oplog.Append(deleteEntry); err != nil {
+	if err := log.Append(deleteEntry); err != nil {
 		t.Fatalf("Failed to append delete: %v", err)
 	}
 
@@ -253,25 +255,25 @@ func TestChangeStreamFilter(t *testing.T) {
 }
 
 func TestChangeStreamResumeToken(t *testing.T) {
-	oplog, tmpDir := setupTestOplog(t)
-	defer cleanupTestOplog(oplog, tmpDir)
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
 
 	// Create first change stream
-	cs1 := NewChangeStream(oplog, "testdb", "users", nil)
+	cs1 := NewChangeStream(log, "testdb", "users", nil)
 	if err := cs1.Start(); err != nil {
 		t.Fatalf("Failed to start change stream: %v", err)
 	}
 
 	// Insert some documents AFTER starting the stream
 	doc1 := map[string]interface{}{"_id": "user1", "name": "Alice"}
-	entry1 := replication.CreateInsertEntry("testdb", "users", doc1)
-	if err := oplog.Append(entry1); err != nil {
+	entry1 := oplog.CreateInsertEntry("testdb", "users", doc1)
+	if err := log.Append(entry1); err != nil {
 		t.Fatalf("Failed to append: %v", err)
 	}
 
 	doc2 := map[string]interface{}{"_id": "user2", "name": "Bob"}
-	entry2 := replication.CreateInsertEntry("testdb", "users", doc2)
-	if err := oplog.Append(entry2); err != nil {
+	entry2 := oplog.CreateInsertEntry("testdb", "users", doc2)
+	if err := log.Append(entry2); err != nil {
 		t.Fatalf("Failed to append: %v", err)
 	}
 
@@ -290,8 +292,8 @@ func TestChangeStreamResumeToken(t *testing.T) {
 
 	// Insert third document
 	doc3 := map[string]interface{}{"_id": "user3", "name": "Charlie"}
-	entry3 := replication.CreateInsertEntry("testdb", "users", doc3)
-	if err := oplog.Append(entry3); err != nil {
+	entry3 := oplog.CreateInsertEntry("testdb", "users", doc3)
+	if err := log.Append(entry3); err != nil {
 		t.Fatalf("Failed to append: %v", err)
 	}
 
@@ -299,7 +301,7 @@ func TestChangeStreamResumeToken(t *testing.T) {
 	options := DefaultChangeStreamOptions()
 	options.ResumeAfter = &resumeToken
 
-	cs2 := NewChangeStream(oplog, "testdb", "users", options)
+	cs2 := NewChangeStream(log, "testdb", "users", options)
 	if err := cs2.Start(); err != nil {
 		t.Fatalf("Failed to start second change stream: %v", err)
 	}
@@ -329,11 +331,11 @@ func TestChangeStreamResumeToken(t *testing.T) {
 }
 
 func TestChangeStreamDatabaseFilter(t *testing.T) {
-	oplog, tmpDir := setupTestOplog(t)
-	defer cleanupTestOplog(oplog, tmpDir)
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
 
 	// Watch only "testdb"
-	cs := NewChangeStream(oplog, "testdb", "", nil)
+	cs := NewChangeStream(log, "testdb", "", nil)
 	if err := cs.Start(); err != nil {
 		t.Fatalf("Failed to start change stream: %v", err)
 	}
@@ -341,15 +343,15 @@ func TestChangeStreamDatabaseFilter(t *testing.T) {
 
 	// Insert into testdb (should match)
 	doc1 := map[string]interface{}{"_id": "user1", "name": "Alice"}
-	entry1 := replication.CreateInsertEntry("testdb", "users", doc1)
-	if err := oplog.Append(entry1); err != nil {
+	entry1 := oplog.CreateInsertEntry("testdb", "users", doc1)
+	if err := log.Append(entry1); err != nil {
 		t.Fatalf("Failed to append: %v", err)
 	}
 
 	// Insert into otherdb (should not match)
 	doc2 := map[string]interface{}{"_id": "user2", "name": "Bob"}
-	entry2 := replication.CreateInsertEntry("otherdb", "users", doc2)
-	if err := oplog.Append(entry2); err != nil {
+	entry2 := oplog.CreateInsertEntry("otherdb", "users", doc2)
+	if err := log.Append(entry2); err != nil {
 		t.Fatalf("Failed to append: %v", err)
 	}
 
@@ -373,11 +375,11 @@ func TestChangeStreamDatabaseFilter(t *testing.T) {
 }
 
 func TestChangeStreamCollectionFilter(t *testing.T) {
-	oplog, tmpDir := setupTestOplog(t)
-	defer cleanupTestOplog(oplog, tmpDir)
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
 
 	// Watch only "users" collection
-	cs := NewChangeStream(oplog, "testdb", "users", nil)
+	cs := NewChangeStream(log, "testdb", "users", nil)
 	if err := cs.Start(); err != nil {
 		t.Fatalf("Failed to start change stream: %v", err)
 	}
@@ -385,15 +387,15 @@ func TestChangeStreamCollectionFilter(t *testing.T) {
 
 	// Insert into users (should match)
 	doc1 := map[string]interface{}{"_id": "user1", "name": "Alice"}
-	entry1 := replication.CreateInsertEntry("testdb", "users", doc1)
-	if err := oplog.Append(entry1); err != nil {
+	entry1 := oplog.CreateInsertEntry("testdb", "users", doc1)
+	if err := log.Append(entry1); err != nil {
 		t.Fatalf("Failed to append: %v", err)
 	}
 
 	// Insert into products (should not match)
 	doc2 := map[string]interface{}{"_id": "prod1", "name": "Widget"}
-	entry2 := replication.CreateInsertEntry("testdb", "products", doc2)
-	if err := oplog.Append(entry2); err != nil {
+	entry2 := oplog.CreateInsertEntry("testdb", "products", doc2)
+	if err := log.Append(entry2); err != nil {
 		t.Fatalf("Failed to append: %v", err)
 	}
 
@@ -417,10 +419,10 @@ func TestChangeStreamCollectionFilter(t *testing.T) {
 }
 
 func TestChangeStreamMultipleEvents(t *testing.T) {
-	oplog, tmpDir := setupTestOplog(t)
-	defer cleanupTestOplog(oplog, tmpDir)
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
 
-	cs := NewChangeStream(oplog, "testdb", "users", nil)
+	cs := NewChangeStream(log, "testdb", "users", nil)
 	if err := cs.Start(); err != nil {
 		t.Fatalf("Failed to start change stream: %v", err)
 	}
@@ -434,8 +436,8 @@ func TestChangeStreamMultipleEvents(t *testing.T) {
 			"name": "User",
 			"num":  int64(i),
 		}
-		entry := replication.CreateInsertEntry("testdb", "users", doc)
-		if err := oplog.Append(entry); err != nil {
+		entry := oplog.CreateInsertEntry("testdb", "users", doc)
+		if err := log.Append(entry); err != nil {
 			t.Fatalf("Failed to append: %v", err)
 		}
 	}
@@ -457,10 +459,10 @@ func TestChangeStreamMultipleEvents(t *testing.T) {
 }
 
 func TestChangeStreamTryNext(t *testing.T) {
-	oplog, tmpDir := setupTestOplog(t)
-	defer cleanupTestOplog(oplog, tmpDir)
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
 
-	cs := NewChangeStream(oplog, "testdb", "users", nil)
+	cs := NewChangeStream(log, "testdb", "users", nil)
 	if err := cs.Start(); err != nil {
 		t.Fatalf("Failed to start change stream: %v", err)
 	}
@@ -478,8 +480,8 @@ func TestChangeStreamTryNext(t *testing.T) {
 
 	// Insert a document
 	doc := map[string]interface{}{"_id": "user1", "name": "Alice"}
-	entry := replication.CreateInsertEntry("testdb", "users", doc)
-	if err := oplog.Append(entry); err != nil {
+	entry := oplog.CreateInsertEntry("testdb", "users", doc)
+	if err := log.Append(entry); err != nil {
 		t.Fatalf("Failed to append: %v", err)
 	}
 
@@ -498,10 +500,10 @@ func TestChangeStreamTryNext(t *testing.T) {
 }
 
 func TestChangeStreamClose(t *testing.T) {
-	oplog, tmpDir := setupTestOplog(t)
-	defer cleanupTestOplog(oplog, tmpDir)
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
 
-	cs := NewChangeStream(oplog, "testdb", "users", nil)
+	cs := NewChangeStream(log, "testdb", "users", nil)
 	if err := cs.Start(); err != nil {
 		t.Fatalf("Failed to start change stream: %v", err)
 	}
@@ -527,10 +529,10 @@ func TestChangeStreamClose(t *testing.T) {
 }
 
 func TestChangeStreamUnsetOperator(t *testing.T) {
-	oplog, tmpDir := setupTestOplog(t)
-	defer cleanupTestOplog(oplog, tmpDir)
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
 
-	cs := NewChangeStream(oplog, "testdb", "users", nil)
+	cs := NewChangeStream(log, "testdb", "users", nil)
 	if err := cs.Start(); err != nil {
 		t.Fatalf("Failed to start change stream: %v", err)
 	}
@@ -544,10 +546,10 @@ func TestChangeStreamUnsetOperator(t *testing.T) {
 			"phone": "",
 		},
 	}
-	entry := replication.CreateUpdateEntry("testdb", "users", filter, update)
+	entry := oplog.CreateUpdateEntry("testdb", "users", filter, update)
 	entry.DocID = "user1"
 
-	if err := oplog.Append(entry); err != nil {
+	if err := log.Append(entry); err != nil {
 		t.Fatalf("Failed to append: %v", err)
 	}
 
@@ -567,8 +569,8 @@ func TestChangeStreamUnsetOperator(t *testing.T) {
 }
 
 func TestChangeStreamPipeline(t *testing.T) {
-	oplog, tmpDir := setupTestOplog(t)
-	defer cleanupTestOplog(oplog, tmpDir)
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
 
 	// Create change stream with pipeline
 	options := DefaultChangeStreamOptions()
@@ -580,7 +582,7 @@ func TestChangeStreamPipeline(t *testing.T) {
 		},
 	}
 
-	cs := NewChangeStream(oplog, "testdb", "users", options)
+	cs := NewChangeStream(log, "testdb", "users", options)
 	if err := cs.Start(); err != nil {
 		t.Fatalf("Failed to start change stream: %v", err)
 	}
@@ -588,16 +590,16 @@ func TestChangeStreamPipeline(t *testing.T) {
 
 	// Insert and update
 	doc := map[string]interface{}{"_id": "user1", "name": "Alice"}
-	insertEntry := replication.CreateInsertEntry("testdb", "users", doc)
-	if err := oplog.Append(insertEntry); err != nil {
+	insertEntry := oplog.CreateInsertEntry("testdb", "users", doc)
+	if err := log.Append(insertEntry); err != nil {
 		t.Fatalf("Failed to append insert: %v", err)
 	}
 
-	updateEntry := replication.CreateUpdateEntry("testdb", "users",
+	updateEntry := oplog.CreateUpdateEntry("testdb", "users",
 		map[string]interface{}{"_id": "user1"},
 		map[string]interface{}{"$set": map[string]interface{}{"name": "Alice Updated"}})
 	updateEntry.DocID = "user1"
-	if err := oplog.Append(updateEntry); err != nil {
+	if err := log.Append(updateEntry); err != nil {
 		t.Fatalf("Failed to append update: %v", err)
 	}
 
@@ -616,11 +618,79 @@ func TestChangeStreamPipeline(t *testing.T) {
 	}
 }
 
+func TestChangeStreamPipelineFullDocument(t *testing.T) {
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
+
+	options := DefaultChangeStreamOptions()
+	options.MaxAwaitTime = 10 * time.Millisecond
+	options.Pipeline = []map[string]interface{}{
+		{"$match": map[string]interface{}{"operationType": "insert"}},
+		{"$match": map[string]interface{}{
+			"fullDocument.shipping.status": "shipped",
+			"fullDocument.total":           map[string]interface{}{"$gte": int64(100)},
+		}},
+	}
+	cs := NewChangeStream(log, "testdb", "orders", options)
+	if err := cs.Start(); err != nil {
+		t.Fatalf("Failed to start change stream: %v", err)
+	}
+	defer cs.Close()
+
+	// The same conditions as a filter
+	filtered := NewChangeStream(log, "testdb", "orders", &ChangeStreamOptions{MaxAwaitTime: 10 * time.Millisecond, BatchSize: 10})
+	filtered.SetFilter(map[string]interface{}{
+		"fullDocument.shipping.status": "shipped",
+		"fullDocument.total":           map[string]interface{}{"$gte": int64(100)},
+	})
+	if err := filtered.Start(); err != nil {
+		t.Fatalf("Failed to start change stream: %v", err)
+	}
+	defer filtered.Close()
+
+	orders := []map[string]interface{}{
+		{"_id": "o1", "total": int64(150), "shipping": map[string]interface{}{"status": "pending"}},
+		{"_id": "o2", "total": int64(50), "shipping": map[string]interface{}{"status": "shipped"}},
+		{"_id": "o3", "total": int64(120), "shipping": map[string]interface{}{"status": "shipped"}},
+		{"_id": "o4", "total": int64(200)},
+	}
+	for _, order := range orders {
+		log.Append(oplog.CreateInsertEntry("testdb", "orders", order))
+	}
+	// Update events carry no full document without updateLookup
+	update := oplog.CreateUpdateEntry("testdb", "orders",
+		map[string]interface{}{"_id": "o3"},
+		map[string]interface{}{"$set": map[string]interface{}{"note": "fragile"}})
+	update.DocID = "o3"
+	log.Append(update)
+	log.Append(oplog.CreateInsertEntry("testdb", "orders", map[string]interface{}{
+		"_id": "o5", "total": int64(100), "shipping": map[string]interface{}{"status": "shipped"},
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	for name, stream := range map[string]*ChangeStream{"pipeline": cs, "filter": filtered} {
+		for _, id := range []string{"o3", "o5"} {
+			event, err := stream.Next(ctx)
+			if err != nil {
+				t.Fatalf("%s: failed to receive event: %v", name, err)
+			}
+			if event.OperationType != OperationTypeInsert || event.FullDocument["_id"] != id {
+				t.Errorf("%s: expected insert of %s, got %s of %v", name, id, event.OperationType, event.FullDocument["_id"])
+			}
+		}
+		if event, _ := stream.TryNext(); event != nil {
+			t.Errorf("%s: expected no more events, got %s of %v", name, event.OperationType, event.DocumentKey)
+		}
+	}
+}
+
 func TestChangeStreamIndexOperations(t *testing.T) {
-	oplog, tmpDir := setupTestOplog(t)
-	defer cleanupTestOplog(oplog, tmpDir)
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
 
-	cs := NewChangeStream(oplog, "testdb", "users", nil)
+	cs := NewChangeStream(log, "testdb", "users", nil)
 	if err := cs.Start(); err != nil {
 		t.Fatalf("Failed to start change stream: %v", err)
 	}
@@ -631,14 +701,14 @@ func TestChangeStreamIndexOperations(t *testing.T) {
 		"name":  "age_idx",
 		"field": "age",
 	}
-	createEntry := replication.CreateIndexEntry("testdb", "users", indexDef, true)
-	if err := oplog.Append(createEntry); err != nil {
+	createEntry := oplog.CreateIndexEntry("testdb", "users", indexDef, true)
+	if err := log.Append(createEntry); err != nil {
 		t.Fatalf("Failed to append create index: %v", err)
 	}
 
 	// Drop index
-	dropEntry := replication.CreateIndexEntry("testdb", "users", indexDef, false)
-	if err := oplog.Append(dropEntry); err != nil {
+	dropEntry := oplog.CreateIndexEntry("testdb", "users", indexDef, false)
+	if err := log.Append(dropEntry); err != nil {
 		t.Fatalf("Failed to append drop index: %v", err)
 	}
 
@@ -664,3 +734,330 @@ func TestChangeStreamIndexOperations(t *testing.T) {
 		t.Errorf("Expected dropIndex, got '%s'", event2.OperationType)
 	}
 }
+
+func TestMergedChangeStreamOrder(t *testing.T) {
+	log1, dir1 := setupTestOplog(t)
+	defer cleanupTestOplog(log1, dir1)
+	log2, dir2 := setupTestOplog(t)
+	defer cleanupTestOplog(log2, dir2)
+
+	var mu sync.Mutex
+	logs := map[string]*oplog.Oplog{"shard-1": log1}
+	source := func() (map[string]*oplog.Oplog, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		result := make(map[string]*oplog.Oplog, len(logs))
+		for shard, log := range logs {
+			result[shard] = log
+		}
+		return result, nil
+	}
+
+	opts := DefaultChangeStreamOptions()
+	opts.MaxAwaitTime = 20 * time.Millisecond
+	cs, err := NewMergedChangeStream(source, "testdb", "users", opts)
+	if err != nil {
+		t.Fatalf("Failed to create merged change stream: %v", err)
+	}
+	if err := cs.Start(); err != nil {
+		t.Fatalf("Failed to start change stream: %v", err)
+	}
+	defer cs.Close()
+
+	// shard-2 joins while the stream is open
+	mu.Lock()
+	logs["shard-2"] = log2
+	mu.Unlock()
+
+	// Interleave writes on both oplogs
+	order := []struct {
+		log *oplog.Oplog
+		id  string
+	}{{log1, "a"}, {log2, "b"}, {log2, "c"}, {log1, "d"}, {log2, "e"}}
+	for _, write := range order {
+		write.log.Append(oplog.CreateInsertEntry("testdb", "users", map[string]interface{}{"_id": write.id}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var last ResumeToken
+	for _, write := range order {
+		event, err := cs.Next(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get event: %v", err)
+		}
+		if event.DocumentKey["_id"] != write.id {
+			t.Errorf("Expected document %s, got %v", write.id, event.DocumentKey["_id"])
+		}
+		if event.ID.ClusterTime <= last.ClusterTime {
+			t.Errorf("Expected increasing cluster time, got %d after %d", event.ID.ClusterTime, last.ClusterTime)
+		}
+		last = event.ID
+	}
+
+	if last.Shards["shard-1"] != 2 || last.Shards["shard-2"] != 3 {
+		t.Errorf("Expected positions shard-1:2 shard-2:3, got %v", last.Shards)
+	}
+}
+
+func TestMergedChangeStreamResume(t *testing.T) {
+	log1, dir1 := setupTestOplog(t)
+	defer cleanupTestOplog(log1, dir1)
+	log2, dir2 := setupTestOplog(t)
+	defer cleanupTestOplog(log2, dir2)
+
+	source := func() (map[string]*oplog.Oplog, error) {
+		return map[string]*oplog.Oplog{"shard-1": log1, "shard-2": log2}, nil
+	}
+
+	log1.Append(oplog.CreateInsertEntry("testdb", "users", map[string]interface{}{"_id": "a"}))
+	log2.Append(oplog.CreateInsertEntry("testdb", "users", map[string]interface{}{"_id": "b"}))
+	log1.Append(oplog.CreateInsertEntry("testdb", "users", map[string]interface{}{"_id": "c"}))
+
+	// Resume after the first event of shard-1 only
+	opts := DefaultChangeStreamOptions()
+	opts.MaxAwaitTime = 20 * time.Millisecond
+	opts.ResumeAfter = &ResumeToken{Shards: map[string]oplog.OpID{"shard-1": 1, "shard-2": 0}}
+	cs, err := NewMergedChangeStream(source, "testdb", "users", opts)
+	if err != nil {
+		t.Fatalf("Failed to create merged change stream: %v", err)
+	}
+	cs.Start()
+	defer cs.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	for _, expected := range []string{"b", "c"} {
+		event, err := cs.Next(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get event: %v", err)
+		}
+		if event.DocumentKey["_id"] != expected {
+			t.Errorf("Expected document %s, got %v", expected, event.DocumentKey["_id"])
+		}
+	}
+}
+
+func TestChangeStreamDropInvalidates(t *testing.T) {
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
+
+	opts := DefaultChangeStreamOptions()
+	opts.MaxAwaitTime = 10 * time.Millisecond
+	cs := NewChangeStream(log, "testdb", "users", opts)
+	// Only inserts pass the filter, but the invalidate event always does
+	cs.SetFilter(map[string]interface{}{"operationType": "insert"})
+	if err := cs.Start(); err != nil {
+		t.Fatalf("Failed to start change stream: %v", err)
+	}
+	defer cs.Close()
+
+	log.Append(oplog.CreateInsertEntry("testdb", "users", map[string]interface{}{"_id": "user1"}))
+	log.Append(oplog.CreateCollectionEntry("testdb", "users", false))
+	log.Append(oplog.CreateInsertEntry("testdb", "users", map[string]interface{}{"_id": "user2"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	event, err := cs.Next(ctx)
+	if err != nil || event.OperationType != OperationTypeInsert {
+		t.Fatalf("Expected insert event, got %v, %v", event, err)
+	}
+	event, err = cs.Next(ctx)
+	if err != nil {
+		t.Fatalf("Failed to receive event: %v", err)
+	}
+	if event.OperationType != OperationTypeInvalidate || event.Collection != "users" {
+		t.Errorf("Expected invalidate of users, got %s of %s", event.OperationType, event.Collection)
+	}
+	if event.ID.OpID != 2 || cs.ResumeToken().OpID != 2 {
+		t.Errorf("Expected the stream to end at the drop, got %d", event.ID.OpID)
+	}
+
+	// The insert into the recreated collection is not delivered
+	if _, err := cs.Next(ctx); err != ErrInvalidated {
+		t.Errorf("Expected ErrInvalidated, got %v", err)
+	}
+	if _, err := cs.TryNext(); err != ErrInvalidated {
+		t.Errorf("Expected ErrInvalidated from TryNext, got %v", err)
+	}
+	select {
+	case <-cs.Done():
+	default:
+		t.Error("Expected the stream to be done")
+	}
+}
+
+func TestChangeStreamRenameInvalidates(t *testing.T) {
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
+
+	opts := DefaultChangeStreamOptions()
+	opts.MaxAwaitTime = 10 * time.Millisecond
+	users := NewChangeStream(log, "testdb", "users", opts)
+	all := NewChangeStream(log, "testdb", "", opts)
+	for _, cs := range []*ChangeStream{users, all} {
+		if err := cs.Start(); err != nil {
+			t.Fatalf("Failed to start change stream: %v", err)
+		}
+		defer cs.Close()
+	}
+
+	log.Append(oplog.CreateRenameEntry("testdb", "users", "people"))
+	log.Append(oplog.CreateInsertEntry("testdb", "people", map[string]interface{}{"_id": "user1"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	event, err := users.Next(ctx)
+	if err != nil {
+		t.Fatalf("Failed to receive event: %v", err)
+	}
+	if event.OperationType != OperationTypeRename {
+		t.Fatalf("Expected rename event, got %s", event.OperationType)
+	}
+	if event.To == nil || event.To.Database != "testdb" || event.To.Collection != "people" {
+		t.Errorf("Expected rename to testdb.people, got %+v", event.To)
+	}
+	if event, err := users.Next(ctx); err != nil || event.OperationType != OperationTypeInvalidate {
+		t.Fatalf("Expected invalidate event, got %v, %v", event, err)
+	}
+	if _, err := users.Next(ctx); err != ErrInvalidated {
+		t.Errorf("Expected ErrInvalidated, got %v", err)
+	}
+
+	// A database stream sees the rename and carries on
+	for _, want := range []OperationType{OperationTypeRename, OperationTypeInsert} {
+		event, err := all.Next(ctx)
+		if err != nil {
+			t.Fatalf("Failed to receive event: %v", err)
+		}
+		if event.OperationType != want {
+			t.Errorf("Expected %s event, got %s", want, event.OperationType)
+		}
+	}
+}
+
+func TestDatabaseChangeStream(t *testing.T) {
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
+
+	opts := DefaultChangeStreamOptions()
+	opts.MaxAwaitTime = 10 * time.Millisecond
+	all := NewDatabaseChangeStream(log, "testdb", opts)
+	if err := all.Start(); err != nil {
+		t.Fatalf("Failed to start change stream: %v", err)
+	}
+	defer all.Close()
+
+	routed := DefaultChangeStreamOptions()
+	routed.MaxAwaitTime = 10 * time.Millisecond
+	routed.Pipeline = []map[string]interface{}{
+		{"$match": map[string]interface{}{
+			"collection": map[string]interface{}{"$in": []interface{}{"users", "orders"}},
+		}},
+	}
+	matched := NewDatabaseChangeStream(log, "testdb", routed)
+	if err := matched.Start(); err != nil {
+		t.Fatalf("Failed to start change stream: %v", err)
+	}
+	defer matched.Close()
+
+	collections := []string{"users", "orders", "products", "orders", "users", "products"}
+	for i, coll := range collections {
+		log.Append(oplog.CreateInsertEntry("testdb", coll, map[string]interface{}{"_id": int64(i)}))
+	}
+	log.Append(oplog.CreateInsertEntry("otherdb", "users", map[string]interface{}{"_id": "other"}))
+	// Dropping one of the collections doesn't end the stream
+	log.Append(oplog.CreateCollectionEntry("testdb", "products", false))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var last oplog.OpID
+	for i, coll := range collections {
+		event, err := all.Next(ctx)
+		if err != nil {
+			t.Fatalf("Failed to receive event %d: %v", i, err)
+		}
+		if event.Collection != coll || event.FullDocument["_id"] != int64(i) {
+			t.Errorf("Event %d: expected insert %d into %s, got %v into %s", i, i, coll, event.FullDocument["_id"], event.Collection)
+		}
+		if event.ID.OpID <= last {
+			t.Errorf("Event %d: OpID %d not after %d", i, event.ID.OpID, last)
+		}
+		last = event.ID.OpID
+	}
+	event, err := all.Next(ctx)
+	if err != nil || event.OperationType != OperationTypeDropCollection {
+		t.Fatalf("Expected drop event, got %v, %v", event, err)
+	}
+
+	for _, i := range []int64{0, 1, 3, 4} {
+		event, err := matched.Next(ctx)
+		if err != nil {
+			t.Fatalf("Failed to receive event: %v", err)
+		}
+		if event.FullDocument["_id"] != i {
+			t.Errorf("Expected insert %d, got %v into %s", i, event.FullDocument["_id"], event.Collection)
+		}
+	}
+	if event, _ := matched.TryNext(); event != nil {
+		t.Errorf("Expected no more matching events, got %s into %s", event.OperationType, event.Collection)
+	}
+}
+
+func TestChangeStreamOplogTrim(t *testing.T) {
+	tmpDir := t.TempDir()
+	log, err := oplog.NewOplogWithConfig(filepath.Join(tmpDir, "oplog.bin"), oplog.OplogConfig{MaxSizeBytes: 1, TrimInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create oplog: %v", err)
+	}
+	defer log.Close()
+
+	for i := 0; i < 5; i++ {
+		log.Append(oplog.CreateInsertEntry("testdb", "users", map[string]interface{}{"_id": int64(i)}))
+	}
+
+	// A running stream keeps the entries it hasn't read
+	opts := DefaultChangeStreamOptions()
+	opts.MaxAwaitTime = time.Hour
+	opts.ResumeAfter = &ResumeToken{OpID: 2}
+	cs := NewChangeStream(log, "testdb", "users", opts)
+	if err := cs.Start(); err != nil {
+		t.Fatalf("Failed to start change stream: %v", err)
+	}
+	if _, err := log.Trim(); err != nil {
+		t.Fatalf("Failed to trim: %v", err)
+	}
+	if oldest := log.OldestOpID(); oldest != 3 {
+		t.Errorf("Expected oldest OpID 3, got %d", oldest)
+	}
+
+	// Closing it releases them
+	cs.Close()
+	if _, err := log.Trim(); err != nil {
+		t.Fatalf("Failed to trim: %v", err)
+	}
+	if oldest := log.OldestOpID(); oldest != 5 {
+		t.Errorf("Expected oldest OpID 5, got %d", oldest)
+	}
+
+	// A stream resumed before the trimmed entries reports it
+	opts = DefaultChangeStreamOptions()
+	opts.MaxAwaitTime = 10 * time.Millisecond
+	opts.ResumeAfter = &ResumeToken{OpID: 2}
+	resumed := NewChangeStream(log, "testdb", "users", opts)
+	if err := resumed.Start(); err != nil {
+		t.Fatalf("Failed to start change stream: %v", err)
+	}
+	defer resumed.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if _, err := resumed.Next(ctx); !errors.Is(err, oplog.ErrTrimmed) {
+		t.Errorf("Expected ErrTrimmed, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package changestream
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// acknowledge records an event as consumed, so SaveResumeToken resumes
+// after it
+func (cs *ChangeStream) acknowledge(event *ChangeEvent) *ChangeEvent {
+	if event == nil {
+		return nil
+	}
+	cs.mu.Lock()
+	cs.acknowledged = event.ID
+	cs.mu.Unlock()
+	return event
+}
+
+// AcknowledgedToken returns the token to resume after the last event
+// returned by Next or TryNext, or the stream's starting position if none
+// was. Unlike ResumeToken it doesn't move past events still buffered for
+// the consumer.
+func (cs *ChangeStream) AcknowledgedToken() ResumeToken {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.acknowledged
+}
+
+// SaveResumeToken writes the AcknowledgedToken to w as JSON. A stream
+// created with the token read back by LoadResumeToken as
+// ChangeStreamOptions.ResumeAfter starts with the first event not yet
+// returned. Consumers reading the Events channel directly should save
+// event.ID with WriteResumeToken instead.
+func (cs *ChangeStream) SaveResumeToken(w io.Writer) error {
+	return WriteResumeToken(w, cs.AcknowledgedToken())
+}
+
+// WriteResumeToken writes a resume token to w as JSON
+func WriteResumeToken(w io.Writer, token ResumeToken) error {
+	if err := json.NewEncoder(w).Encode(token); err != nil {
+		return fmt.Errorf("failed to write resume token: %w", err)
+	}
+	return nil
+}
+
+// LoadResumeToken reads a resume token written by SaveResumeToken or
+// WriteResumeToken
+func LoadResumeToken(r io.Reader) (*ResumeToken, error) {
+	var token ResumeToken
+	if err := json.NewDecoder(r).Decode(&token); err != nil {
+		return nil, fmt.Errorf("failed to read resume token: %w", err)
+	}
+	return &token, nil
+}
@@ -0,0 +1,83 @@
+package changestream
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/oplog"
+)
+
+func TestChangeStreamSaveResumeToken(t *testing.T) {
+	log, tmpDir := setupTestOplog(t)
+	defer cleanupTestOplog(log, tmpDir)
+
+	options := DefaultChangeStreamOptions()
+	options.MaxAwaitTime = 10 * time.Millisecond
+	cs := NewChangeStream(log, "testdb", "users", options)
+	start := cs.AcknowledgedToken()
+	if err := cs.Start(); err != nil {
+		t.Fatalf("Failed to start change stream: %v", err)
+	}
+
+	for _, id := range []string{"user1", "user2", "user3"} {
+		if err := log.Append(oplog.CreateInsertEntry("testdb", "users", map[string]interface{}{"_id": id})); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+
+	// Wait until every event is buffered: the stream position moves past
+	// events the consumer hasn't received yet
+	deadline := time.Now().Add(3 * time.Second)
+	for cs.ResumeToken().OpID != log.GetCurrentID() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !reflect.DeepEqual(cs.AcknowledgedToken(), start) {
+		t.Errorf("Expected the starting position before any event was received, got %+v", cs.AcknowledgedToken())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	event, err := cs.Next(ctx)
+	if err != nil {
+		t.Fatalf("Failed to receive event: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := cs.SaveResumeToken(&buf); err != nil {
+		t.Fatalf("Failed to save resume token: %v", err)
+	}
+	cs.Close()
+
+	token, err := LoadResumeToken(&buf)
+	if err != nil {
+		t.Fatalf("Failed to load resume token: %v", err)
+	}
+	if !reflect.DeepEqual(*token, event.ID) {
+		t.Errorf("Expected the token of the received event %+v, got %+v", event.ID, *token)
+	}
+
+	// Resuming continues with the first event not received
+	options.ResumeAfter = token
+	resumed := NewChangeStream(log, "testdb", "users", options)
+	if err := resumed.Start(); err != nil {
+		t.Fatalf("Failed to start change stream: %v", err)
+	}
+	defer resumed.Close()
+	for _, id := range []string{"user2", "user3"} {
+		event, err := resumed.Next(ctx)
+		if err != nil {
+			t.Fatalf("Failed to receive event: %v", err)
+		}
+		if event.FullDocument["_id"] != id {
+			t.Errorf("Expected %s, got %v", id, event.FullDocument["_id"])
+		}
+	}
+
+	if _, err := LoadResumeToken(strings.NewReader("not json")); err == nil {
+		t.Error("Expected an error for an invalid token")
+	}
+}
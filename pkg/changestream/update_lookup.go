@@ -0,0 +1,50 @@
+package changestream
+
+import (
+	"fmt"
+
+	"github.com/mnohosten/laura-db/pkg/oplog"
+)
+
+// DocumentLookup fetches the current version of documents for streams with
+// FullDocumentUpdateLookup. *database.Database implements it.
+type DocumentLookup interface {
+	// LookupDocument returns the document of a collection with the given
+	// _id, or nil if it no longer exists
+	LookupDocument(database, collection string, id interface{}) (map[string]interface{}, error)
+}
+
+// NewChangeStreamWithDB creates a change stream that, with
+// FullDocumentUpdateLookup, attaches the current version of updated
+// documents from db to update events
+func NewChangeStreamWithDB(oplog *oplog.Oplog, db DocumentLookup, database, collection string, options *ChangeStreamOptions) *ChangeStream {
+	cs := NewChangeStream(oplog, database, collection, options)
+	cs.lookup = db
+	return cs
+}
+
+// lookupFullDocument attaches the current version of the updated document
+// to an update event. The document is read when the event is, so it may
+// include later writes; if it was deleted since, the event is marked with
+// DocumentDeleted instead.
+func (cs *ChangeStream) lookupFullDocument(event *ChangeEvent) {
+	if cs.lookup == nil || cs.options.FullDocument != FullDocumentUpdateLookup || event.DocumentKey == nil {
+		return
+	}
+
+	id := event.DocumentKey["_id"]
+	doc, err := cs.lookup.LookupDocument(event.Database, event.Collection, id)
+	if err != nil {
+		// The event is still delivered, without its document
+		select {
+		case cs.errors <- fmt.Errorf("failed to look up document %v: %w", id, err):
+		default:
+		}
+		return
+	}
+	if doc == nil {
+		event.DocumentDeleted = true
+		return
+	}
+	event.FullDocument = doc
+}
@@ -14,6 +14,7 @@ import (
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	wire       *wireTransport // nil when requests go over HTTP
 }
 
 // Config holds configuration for the client
@@ -28,6 +29,11 @@ type Config struct {
 	MaxIdleConns int
 	// MaxConnsPerHost is the maximum connections per host (default: 10)
 	MaxConnsPerHost int
+	// WirePort, if set, sends requests over a persistent TCP connection
+	// using the binary wire protocol instead of HTTP
+	WirePort int
+	// AuthToken is sent in the wire protocol auth handshake (optional)
+	AuthToken string
 }
 
 // DefaultConfig returns the default client configuration
@@ -79,10 +85,15 @@ func NewClient(config *Config) *Client {
 
 	baseURL := fmt.Sprintf("http://%s:%d", config.Host, config.Port)
 
-	return &Client{
+	client := &Client{
 		baseURL:    baseURL,
 		httpClient: httpClient,
 	}
+	if config.WirePort > 0 {
+		client.wire = newWireTransport(fmt.Sprintf("%s:%d", config.Host, config.WirePort), config.AuthToken, config.Timeout)
+	}
+
+	return client
 }
 
 // NewDefaultClient creates a client with default configuration
@@ -98,20 +109,53 @@ type Response struct {
 	Error   string          `json:"error,omitempty"`
 	Message string          `json:"message,omitempty"`
 	Code    int             `json:"code,omitempty"`
+	Details json.RawMessage `json:"details,omitempty"`
 }
 
-// doRequest performs an HTTP request and returns the response
+// doRequest performs an API request and returns the response
 func (c *Client) doRequest(method, path string, body interface{}) (*Response, error) {
-	// Build URL
-	reqURL := c.baseURL + path
-
 	// Encode request body if provided
-	var reqBody io.Reader
+	var data []byte
 	if body != nil {
-		data, err := json.Marshal(body)
+		var err error
+		data, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to encode request body: %w", err)
 		}
+	}
+
+	var respBody []byte
+	var err error
+	if c.wire != nil {
+		respBody, err = c.wire.roundTrip(method, path, data)
+	} else {
+		respBody, err = c.doHTTP(method, path, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse response
+	var apiResp Response
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Check for API-level errors
+	if !apiResp.OK {
+		return &apiResp, responseError(&apiResp)
+	}
+
+	return &apiResp, nil
+}
+
+// doHTTP sends a request over HTTP and returns the response body
+func (c *Client) doHTTP(method, path string, data []byte) ([]byte, error) {
+	// Build URL
+	reqURL := c.baseURL + path
+
+	var reqBody io.Reader
+	if data != nil {
 		reqBody = bytes.NewReader(data)
 	}
 
@@ -122,7 +166,7 @@ func (c *Client) doRequest(method, path string, body interface{}) (*Response, er
 	}
 
 	// Set headers
-	if body != nil {
+	if data != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
 	req.Header.Set("Accept", "application/json")
@@ -140,18 +184,7 @@ func (c *Client) doRequest(method, path string, body interface{}) (*Response, er
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	// Parse response
-	var apiResp Response
-	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	// Check for API-level errors
-	if !apiResp.OK {
-		return &apiResp, fmt.Errorf("API error: %s - %s", apiResp.Error, apiResp.Message)
-	}
-
-	return &apiResp, nil
+	return respBody, nil
 }
 
 // Health checks the server health
@@ -216,6 +249,12 @@ type IndexInfo struct {
 	Unique bool                   `json:"unique"`
 	Sparse bool                   `json:"sparse"`
 	Stats  map[string]interface{} `json:"stats,omitempty"`
+
+	BuildState    string                 `json:"build_state"`              // "ready", "building" or "failed"
+	BuildProgress map[string]interface{} `json:"build_progress,omitempty"` // While building
+	BuildError    string                 `json:"build_error,omitempty"`    // Why the build failed
+	SizeBytes     int64                  `json:"size_bytes,omitempty"`     // Estimated size of a B+ tree index
+	LastUsed      time.Time              `json:"last_used,omitempty"`      // Zero if no query used the index
 }
 
 // StorageStats represents storage-level statistics
@@ -285,5 +324,8 @@ func (c *Client) DropCollection(name string) error {
 func (c *Client) Close() error {
 	// Close idle connections
 	c.httpClient.CloseIdleConnections()
+	if c.wire != nil {
+		return c.wire.close()
+	}
 	return nil
 }
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -286,6 +287,65 @@ func TestErrorResponse(t *testing.T) {
 	}
 }
 
+func TestErrorKinds(t *testing.T) {
+	responses := map[string]string{
+		"/users/_doc":    `{"ok":false,"error":"DuplicateKey","message":"duplicate key","code":409,"details":{"collection":"users","index":"email_1","fields":["email"],"values":["a@example.com"]}}`,
+		"/users/_doc/u1": `{"ok":false,"error":"ValidationError","message":"cannot set name.first","code":400,"details":{"field":"name.first"}}`,
+		"/_collections":  `{"ok":false,"error":"ReadOnly","message":"database is read-only","code":503}`,
+		"/users/_doc/u2": `{"ok":false,"error":"DocumentNotFound","message":"document not found: u2","code":404}`,
+		"/users/_doc/u3": `{"ok":false,"error":"Conflict","message":"write conflict detected","code":409}`,
+		"/users/_doc/u4": `{"ok":false,"error":"Timeout","message":"timed out","code":408}`,
+		"/users/_doc/u5": `{"ok":false,"error":"InternalError","message":"boom","code":500}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(responses[r.URL.Path]))
+	}))
+	defer server.Close()
+
+	client := NewDefaultClient()
+	client.baseURL = server.URL
+	users := client.Collection("users")
+
+	_, err := users.InsertOne(map[string]interface{}{"email": "a@example.com"})
+	var dupErr *DuplicateKeyError
+	if !errors.As(err, &dupErr) || !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("expected a DuplicateKeyError, got %v", err)
+	}
+	if dupErr.Index != "email_1" || dupErr.Fields[0] != "email" || dupErr.Values[0] != "a@example.com" {
+		t.Errorf("unexpected duplicate key %+v", dupErr)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 409 {
+		t.Errorf("expected an APIError with code 409, got %v", err)
+	}
+
+	err = users.UpdateOne("u1", map[string]interface{}{"$set": map[string]interface{}{"name.first": "A"}})
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || invalid.Field != "name.first" || !errors.Is(err, ErrValidation) {
+		t.Errorf("expected a ValidationError on name.first, got %v", err)
+	}
+
+	if _, err := client.ListCollections(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly, got %v", err)
+	}
+	if _, err := users.FindOne("u2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := users.DeleteOne("u3"); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+	if err := users.DeleteOne("u4"); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+	err = users.DeleteOne("u5")
+	for _, kind := range []error{ErrNotFound, ErrDuplicateKey, ErrValidation, ErrConflict, ErrReadOnly, ErrTimeout} {
+		if errors.Is(err, kind) {
+			t.Errorf("expected an internal error not to match %v", kind)
+		}
+	}
+}
+
 func TestClose(t *testing.T) {
 	client := NewDefaultClient()
 
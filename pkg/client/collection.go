@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"sort"
 )
 
 // Collection represents a database collection
@@ -26,12 +27,16 @@ func (c *Collection) InsertOne(doc map[string]interface{}) (string, error) {
 	}
 
 	var result struct {
-		ID string `json:"_id"`
+		ID         string `json:"id"`
+		DocumentID string `json:"_id"`
 	}
 	if err := json.Unmarshal(resp.Result, &result); err != nil {
 		return "", fmt.Errorf("failed to parse insert response: %w", err)
 	}
 
+	if result.ID == "" {
+		return result.DocumentID, nil
+	}
 	return result.ID, nil
 }
 
@@ -116,14 +121,103 @@ type SearchOptions struct {
 	Filter map[string]interface{} `json:"filter,omitempty"`
 	// Projection specifies which fields to include/exclude
 	Projection map[string]interface{} `json:"projection,omitempty"`
-	// Sort specifies the sort order (e.g., {"age": 1, "name": -1})
+	// Sort specifies the sort order (e.g., {"age": 1, "name": -1}).
+	// Map keys are unordered, so multi-field sorts apply fields by name;
+	// use SortFields when the order matters.
 	Sort map[string]interface{} `json:"sort,omitempty"`
+	// SortFields specifies the sort order as an ordered list; it takes
+	// precedence over Sort
+	SortFields []SortField `json:"-"`
 	// Skip specifies the number of documents to skip
 	Skip int `json:"skip,omitempty"`
 	// Limit specifies the maximum number of documents to return
 	Limit int `json:"limit,omitempty"`
 }
 
+// SortField is one field of a sort order
+type SortField struct {
+	Field     string
+	Ascending bool
+}
+
+// searchRequest is the request body of the _search endpoint
+type searchRequest struct {
+	Filter     map[string]interface{}   `json:"filter,omitempty"`
+	Projection map[string]bool          `json:"projection,omitempty"`
+	Sort       []map[string]interface{} `json:"sort,omitempty"`
+	Skip       int                      `json:"skip,omitempty"`
+	Limit      int                      `json:"limit,omitempty"`
+}
+
+// newSearchRequest converts search options to the format the server expects
+func newSearchRequest(options *SearchOptions) *searchRequest {
+	req := &searchRequest{
+		Filter: options.Filter,
+		Skip:   options.Skip,
+		Limit:  options.Limit,
+	}
+
+	if len(options.Projection) > 0 {
+		req.Projection = make(map[string]bool, len(options.Projection))
+		for field, value := range options.Projection {
+			req.Projection[field] = truthy(value)
+		}
+	}
+
+	sortFields := options.SortFields
+	if len(sortFields) == 0 && len(options.Sort) > 0 {
+		fields := make([]string, 0, len(options.Sort))
+		for field := range options.Sort {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			sortFields = append(sortFields, SortField{Field: field, Ascending: !isNegative(options.Sort[field])})
+		}
+	}
+	for _, f := range sortFields {
+		order := "asc"
+		if !f.Ascending {
+			order = "desc"
+		}
+		req.Sort = append(req.Sort, map[string]interface{}{"field": f.Field, "order": order})
+	}
+
+	return req
+}
+
+// truthy interprets projection values such as 1, 0, true and false
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0
+	default:
+		return value != nil
+	}
+}
+
+// isNegative reports whether a sort value requests descending order
+func isNegative(value interface{}) bool {
+	switch v := value.(type) {
+	case int:
+		return v < 0
+	case int64:
+		return v < 0
+	case float64:
+		return v < 0
+	case string:
+		return v == "desc"
+	default:
+		return false
+	}
+}
+
 // Search performs a query on the collection
 func (c *Collection) Search(options *SearchOptions) ([]map[string]interface{}, error) {
 	path := fmt.Sprintf("/%s/_search", url.PathEscape(c.name))
@@ -132,7 +226,7 @@ func (c *Collection) Search(options *SearchOptions) ([]map[string]interface{}, e
 		options = &SearchOptions{}
 	}
 
-	resp, err := c.client.doRequest("POST", path, options)
+	resp, err := c.client.doRequest("POST", path, newSearchRequest(options))
 	if err != nil {
 		return nil, err
 	}
@@ -191,6 +285,22 @@ func (c *Collection) Count(filter map[string]interface{}) (int, error) {
 	return result.Count, nil
 }
 
+// Explain returns the query plan the server would use for a filter
+func (c *Collection) Explain(filter map[string]interface{}) (map[string]interface{}, error) {
+	path := fmt.Sprintf("/%s/_explain", url.PathEscape(c.name))
+	resp, err := c.client.doRequest("POST", path, map[string]interface{}{"filter": filter})
+	if err != nil {
+		return nil, err
+	}
+
+	var plan map[string]interface{}
+	if err := json.Unmarshal(resp.Result, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse explain result: %w", err)
+	}
+
+	return plan, nil
+}
+
 // Stats retrieves collection statistics
 func (c *Collection) Stats() (*CollectionStats, error) {
 	path := fmt.Sprintf("/%s/_stats", url.PathEscape(c.name))
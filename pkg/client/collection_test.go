@@ -239,6 +239,70 @@ func TestCollectionSearch(t *testing.T) {
 	}
 }
 
+func TestCollectionSearchRequestFormat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Projection map[string]bool          `json:"projection"`
+			Sort       []map[string]interface{} `json:"sort"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+
+		if !req.Projection["name"] || req.Projection["age"] {
+			t.Errorf("expected projection {name: true, age: false}, got %v", req.Projection)
+		}
+		if len(req.Sort) != 2 {
+			t.Fatalf("expected 2 sort fields, got %v", req.Sort)
+		}
+		if req.Sort[0]["field"] != "name" || req.Sort[0]["order"] != "desc" {
+			t.Errorf("expected name desc first, got %v", req.Sort[0])
+		}
+		if req.Sort[1]["field"] != "age" || req.Sort[1]["order"] != "asc" {
+			t.Errorf("expected age asc second, got %v", req.Sort[1])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true, "result": []}`))
+	}))
+	defer server.Close()
+
+	client := NewDefaultClient()
+	client.baseURL = server.URL
+
+	_, err := client.Collection("users").Search(&SearchOptions{
+		Projection: map[string]interface{}{"name": 1, "age": 0},
+		SortFields: []SortField{{Field: "name", Ascending: false}, {Field: "age", Ascending: true}},
+	})
+	if err != nil {
+		t.Fatalf("Search() failed: %v", err)
+	}
+}
+
+func TestCollectionExplain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/_explain" {
+			t.Errorf("expected path '/users/_explain', got '%s'", r.URL.Path)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true, "result": {"useIndex": true, "indexName": "age_1"}}`))
+	}))
+	defer server.Close()
+
+	client := NewDefaultClient()
+	client.baseURL = server.URL
+
+	plan, err := client.Collection("users").Explain(map[string]interface{}{"age": 30})
+	if err != nil {
+		t.Fatalf("Explain() failed: %v", err)
+	}
+	if plan["indexName"] != "age_1" {
+		t.Errorf("expected indexName 'age_1', got %v", plan["indexName"])
+	}
+}
+
 func TestCollectionFind(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,258 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+)
+
+// ErrCursorNotResumable is returned by Cursor.Next when the server lost the
+// cursor and it can't continue from its last position, for example because
+// its resume token expired. The cursor doesn't start over, since that would
+// return documents a second time.
+var ErrCursorNotResumable = errors.New("cursor can't be resumed")
+
+// CursorOptions configures a cursor
+type CursorOptions struct {
+	// SearchOptions holds the filter, projection, sort, skip and limit
+	SearchOptions
+	// BatchSize is the number of documents fetched per request
+	// (default: server default)
+	BatchSize int
+	// Timeout is the server-side idle timeout of the cursor
+	// (default: server default)
+	Timeout time.Duration
+	// MaxReconnects is how many times in a row the cursor tries to
+	// reconnect before giving up (default: 5)
+	MaxReconnects int
+	// ReconnectBackoff is the delay before the first reconnect attempt,
+	// doubled after each failed one (default: 100ms)
+	ReconnectBackoff time.Duration
+}
+
+// Cursor iterates over query results fetched from a server-side cursor in
+// batches. Results are ordered by the sort fields and then by _id, so when
+// the server loses the cursor - it restarted, or the connection dropped - the
+// cursor reconnects and resumes after the last document it returned using
+// the resume token of its last batch. A Cursor is not safe for concurrent
+// use.
+type Cursor struct {
+	coll       *Collection
+	options    CursorOptions
+	id         string // Server cursor ID, empty when it must be reopened
+	count      int
+	batch      []map[string]interface{}
+	hasMore    bool
+	token      string // Resume token for the position after the last batch
+	returned   int    // Documents returned by Next
+	reconnects int
+	err        error
+}
+
+// cursorBatch is the response of the batch endpoint
+type cursorBatch struct {
+	Documents   []map[string]interface{} `json:"documents"`
+	HasMore     bool                     `json:"hasMore"`
+	ResumeToken string                   `json:"resumeToken"`
+}
+
+// Cursor opens a resumable cursor over the documents matching options
+func (c *Collection) Cursor(options *CursorOptions) (*Cursor, error) {
+	if options == nil {
+		options = &CursorOptions{}
+	}
+	opts := *options
+	if opts.MaxReconnects == 0 {
+		opts.MaxReconnects = 5
+	}
+	if opts.ReconnectBackoff == 0 {
+		opts.ReconnectBackoff = 100 * time.Millisecond
+	}
+
+	cursor := &Cursor{
+		coll:    c,
+		options: opts,
+		hasMore: true,
+	}
+	if _, err := cursor.open(); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+// open creates the server cursor, resuming after the last batch if one was
+// fetched
+func (cur *Cursor) open() (*Response, error) {
+	search := newSearchRequest(&cur.options.SearchOptions)
+	req := map[string]interface{}{
+		"collection": cur.coll.name,
+		"resumable":  true,
+	}
+	if search.Filter != nil {
+		req["filter"] = search.Filter
+	}
+	if search.Projection != nil {
+		req["projection"] = search.Projection
+	}
+	if search.Sort != nil {
+		req["sort"] = search.Sort
+	}
+	if cur.options.BatchSize > 0 {
+		req["batchSize"] = cur.options.BatchSize
+	}
+	if cur.options.Timeout > 0 {
+		req["timeout"] = cur.options.Timeout.String()
+	}
+
+	if cur.token == "" {
+		req["skip"] = cur.options.Skip
+		req["limit"] = cur.options.Limit
+	} else {
+		// Skip was applied before the token's position
+		req["resumeAfter"] = cur.token
+		if cur.options.Limit > 0 {
+			req["limit"] = cur.options.Limit - cur.returned
+		}
+	}
+
+	resp, err := cur.coll.client.doRequest("POST", "/_cursors", req)
+	if err != nil {
+		return resp, err
+	}
+
+	var created struct {
+		CursorID string `json:"cursorId"`
+		Count    int    `json:"count"`
+	}
+	if err := json.Unmarshal(resp.Result, &created); err != nil {
+		return resp, fmt.Errorf("failed to parse cursor response: %w", err)
+	}
+	cur.id = created.CursorID
+	if cur.token == "" {
+		cur.count = created.Count
+	}
+	return resp, nil
+}
+
+// Next returns the next document, or io.EOF after the last one. A lost
+// server cursor is reopened after the last document returned; if that
+// isn't possible Next returns an error wrapping ErrCursorNotResumable.
+func (cur *Cursor) Next() (map[string]interface{}, error) {
+	if cur.err != nil {
+		return nil, cur.err
+	}
+
+	for len(cur.batch) == 0 {
+		if !cur.hasMore || (cur.options.Limit > 0 && cur.returned >= cur.options.Limit) {
+			return nil, io.EOF
+		}
+		if err := cur.fetch(); err != nil {
+			cur.err = err
+			return nil, err
+		}
+	}
+
+	doc := cur.batch[0]
+	cur.batch = cur.batch[1:]
+	cur.returned++
+	return doc, nil
+}
+
+// fetch reads the next batch, reconnecting with backoff while the server
+// cursor is lost
+func (cur *Cursor) fetch() error {
+	delay := cur.options.ReconnectBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := cur.fetchOnce()
+		if !retry {
+			return err
+		}
+		if attempt >= cur.options.MaxReconnects {
+			return fmt.Errorf("failed to reconnect cursor after %d attempts: %w", attempt, err)
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// fetchOnce reopens the server cursor if needed and reads a batch,
+// reporting whether a failure may go away by reconnecting
+func (cur *Cursor) fetchOnce() (bool, error) {
+	if cur.id == "" {
+		resp, err := cur.open()
+		if err != nil {
+			if resp == nil {
+				return true, err
+			}
+			if resp.Error == "ResumeTokenExpired" || resp.Error == "InvalidResumeToken" {
+				return false, fmt.Errorf("%w: %s", ErrCursorNotResumable, resp.Message)
+			}
+			return false, err
+		}
+		cur.reconnects++
+	}
+
+	path := fmt.Sprintf("/_cursors/%s/batch", url.PathEscape(cur.id))
+	resp, err := cur.coll.client.doRequest("GET", path, nil)
+	if err != nil {
+		// The server may have moved the cursor past a batch that never
+		// arrived, so resume from the last batch received instead of
+		// fetching again
+		if resp == nil || resp.Error == "CursorNotFound" {
+			cur.id = ""
+			return true, err
+		}
+		return false, err
+	}
+
+	var batch cursorBatch
+	if err := json.Unmarshal(resp.Result, &batch); err != nil {
+		return false, fmt.Errorf("failed to parse cursor batch: %w", err)
+	}
+	cur.batch = batch.Documents
+	cur.hasMore = batch.HasMore
+	if batch.ResumeToken != "" {
+		cur.token = batch.ResumeToken
+	}
+	return false, nil
+}
+
+// Count returns the number of documents the cursor matched when it was
+// opened
+func (cur *Cursor) Count() int {
+	return cur.count
+}
+
+// Reconnects returns how many times the cursor reopened a lost server
+// cursor
+func (cur *Cursor) Reconnects() int {
+	return cur.reconnects
+}
+
+// ResumeToken returns the token for the position after the last batch
+// fetched, empty before the first one
+func (cur *Cursor) ResumeToken() string {
+	return cur.token
+}
+
+// Close closes the server cursor. Next returns an error afterwards.
+func (cur *Cursor) Close() error {
+	if cur.err == nil {
+		cur.err = fmt.Errorf("cursor is closed")
+	}
+	cur.batch = nil
+	if cur.id == "" {
+		return nil
+	}
+
+	path := "/_cursors/" + url.PathEscape(cur.id)
+	cur.id = ""
+	resp, err := cur.coll.client.doRequest("DELETE", path, nil)
+	if err != nil && resp != nil && resp.Error == "CursorNotFound" {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,307 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cursorServer serves the cursor endpoints over a fixed list of documents.
+// Resume tokens are result positions.
+type cursorServer struct {
+	docs    []map[string]interface{}
+	mu      sync.Mutex
+	cursors map[string]*fakeCursor
+	nextID  int
+	drops   int  // Connections to drop before serving batches again
+	expired bool // Reject resume tokens as expired
+	closed  []string
+	creates []map[string]interface{}
+}
+
+type fakeCursor struct {
+	pos, end, batchSize int
+}
+
+func newCursorServer(n int) *cursorServer {
+	s := &cursorServer{cursors: make(map[string]*fakeCursor)}
+	for i := 0; i < n; i++ {
+		s.docs = append(s.docs, map[string]interface{}{"_id": fmt.Sprintf("doc%02d", i)})
+	}
+	return s
+}
+
+// restart loses every cursor
+func (s *cursorServer) restart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cursors = make(map[string]*fakeCursor)
+}
+
+func (s *cursorServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeResult := func(code int, body string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		w.Write([]byte(body))
+	}
+
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/_cursors":
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		s.creates = append(s.creates, req)
+
+		pos := 0
+		if skip, ok := req["skip"].(float64); ok {
+			pos = int(skip)
+		}
+		if token, ok := req["resumeAfter"].(string); ok {
+			if s.expired {
+				writeResult(http.StatusGone, `{"ok":false,"error":"ResumeTokenExpired","message":"resume token expired"}`)
+				return
+			}
+			pos, _ = strconv.Atoi(token)
+		}
+		end := len(s.docs)
+		if limit, ok := req["limit"].(float64); ok && limit > 0 && pos+int(limit) < end {
+			end = pos + int(limit)
+		}
+		batchSize := 100
+		if size, ok := req["batchSize"].(float64); ok {
+			batchSize = int(size)
+		}
+
+		s.nextID++
+		id := fmt.Sprintf("c%d", s.nextID)
+		s.cursors[id] = &fakeCursor{pos: pos, end: end, batchSize: batchSize}
+		writeResult(http.StatusOK, fmt.Sprintf(`{"ok":true,"result":{"cursorId":%q,"count":%d}}`, id, end-pos))
+
+	case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/batch"):
+		if s.drops > 0 {
+			s.drops--
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/_cursors/"), "/batch")
+		cursor, ok := s.cursors[id]
+		if !ok {
+			writeResult(http.StatusNotFound, `{"ok":false,"error":"CursorNotFound","message":"cursor not found"}`)
+			return
+		}
+		end := cursor.pos + cursor.batchSize
+		if end > cursor.end {
+			end = cursor.end
+		}
+		docs, _ := json.Marshal(s.docs[cursor.pos:end])
+		cursor.pos = end
+		writeResult(http.StatusOK, fmt.Sprintf(`{"ok":true,"result":{"documents":%s,"hasMore":%v,"resumeToken":"%d"}}`,
+			docs, cursor.pos < cursor.end, cursor.pos))
+
+	case r.Method == "DELETE":
+		id := strings.TrimPrefix(r.URL.Path, "/_cursors/")
+		s.closed = append(s.closed, id)
+		delete(s.cursors, id)
+		writeResult(http.StatusOK, `{"ok":true,"result":{"ok":true}}`)
+
+	default:
+		writeResult(http.StatusNotFound, `{"ok":false,"error":"NotFound"}`)
+	}
+}
+
+// readAll reads a cursor to the end, returning the IDs read
+func readAll(t *testing.T, cursor *Cursor) ([]string, error) {
+	t.Helper()
+	var ids []string
+	for {
+		doc, err := cursor.Next()
+		if err == io.EOF {
+			return ids, nil
+		}
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, doc["_id"].(string))
+	}
+}
+
+// checkSequence verifies ids are doc<first>..doc<first+n-1> in order
+func checkSequence(t *testing.T, ids []string, first, n int) {
+	t.Helper()
+	if len(ids) != n {
+		t.Fatalf("expected %d documents, got %d: %v", n, len(ids), ids)
+	}
+	for i, id := range ids {
+		if want := fmt.Sprintf("doc%02d", first+i); id != want {
+			t.Fatalf("expected %s at %d, got %s: %v", want, i, id, ids)
+		}
+	}
+}
+
+func TestCursorIteratesBatches(t *testing.T) {
+	fake := newCursorServer(23)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := NewDefaultClient()
+	client.baseURL = server.URL
+
+	cursor, err := client.Collection("users").Cursor(&CursorOptions{
+		SearchOptions: SearchOptions{
+			Filter:     map[string]interface{}{"active": true},
+			SortFields: []SortField{{Field: "age", Ascending: false}},
+			Skip:       2,
+		},
+		BatchSize: 5,
+	})
+	if err != nil {
+		t.Fatalf("Cursor() failed: %v", err)
+	}
+	if cursor.Count() != 21 {
+		t.Errorf("expected count 21, got %d", cursor.Count())
+	}
+
+	ids, err := readAll(t, cursor)
+	if err != nil {
+		t.Fatalf("Next() failed: %v", err)
+	}
+	checkSequence(t, ids, 2, 21)
+
+	req := fake.creates[0]
+	if req["resumable"] != true || req["collection"] != "users" {
+		t.Errorf("expected a resumable cursor on users, got %v", req)
+	}
+	sort := req["sort"].([]interface{})[0].(map[string]interface{})
+	if sort["field"] != "age" || sort["order"] != "desc" {
+		t.Errorf("unexpected sort %v", sort)
+	}
+
+	if err := cursor.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if len(fake.closed) != 1 {
+		t.Errorf("expected the server cursor to be closed, got %v", fake.closed)
+	}
+	if _, err := cursor.Next(); err == nil || err == io.EOF {
+		t.Errorf("expected an error after Close, got %v", err)
+	}
+}
+
+func TestCursorResumesAfterRestart(t *testing.T) {
+	fake := newCursorServer(25)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := NewDefaultClient()
+	client.baseURL = server.URL
+
+	cursor, err := client.Collection("users").Cursor(&CursorOptions{
+		SearchOptions:    SearchOptions{Skip: 1, Limit: 20},
+		BatchSize:        4,
+		ReconnectBackoff: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Cursor() failed: %v", err)
+	}
+
+	var ids []string
+	for i := 0; i < 6; i++ {
+		doc, err := cursor.Next()
+		if err != nil {
+			t.Fatalf("Next() failed: %v", err)
+		}
+		ids = append(ids, doc["_id"].(string))
+	}
+
+	// The server restarts, and the first attempts hit a dropped connection
+	fake.restart()
+	fake.mu.Lock()
+	fake.drops = 1
+	fake.mu.Unlock()
+
+	rest, err := readAll(t, cursor)
+	if err != nil {
+		t.Fatalf("Next() failed after restart: %v", err)
+	}
+	checkSequence(t, append(ids, rest...), 1, 20)
+
+	if cursor.Reconnects() == 0 {
+		t.Error("expected the cursor to reconnect")
+	}
+	resume := fake.creates[len(fake.creates)-1]
+	if resume["resumeAfter"] != "9" || resume["limit"] != float64(12) {
+		t.Errorf("expected to resume after position 9 with 12 left, got %v", resume)
+	}
+}
+
+func TestCursorNotResumable(t *testing.T) {
+	fake := newCursorServer(10)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := NewDefaultClient()
+	client.baseURL = server.URL
+
+	cursor, err := client.Collection("users").Cursor(&CursorOptions{BatchSize: 4, ReconnectBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Cursor() failed: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := cursor.Next(); err != nil {
+			t.Fatalf("Next() failed: %v", err)
+		}
+	}
+
+	fake.restart()
+	fake.mu.Lock()
+	fake.expired = true
+	fake.mu.Unlock()
+
+	if _, err := cursor.Next(); !errors.Is(err, ErrCursorNotResumable) {
+		t.Fatalf("expected ErrCursorNotResumable, got %v", err)
+	}
+	// The cursor doesn't start over
+	if _, err := cursor.Next(); !errors.Is(err, ErrCursorNotResumable) {
+		t.Errorf("expected the error to stick, got %v", err)
+	}
+}
+
+func TestCursorGivesUpReconnecting(t *testing.T) {
+	fake := newCursorServer(10)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := NewDefaultClient()
+	client.baseURL = server.URL
+
+	cursor, err := client.Collection("users").Cursor(&CursorOptions{
+		BatchSize:        4,
+		MaxReconnects:    2,
+		ReconnectBackoff: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Cursor() failed: %v", err)
+	}
+
+	fake.mu.Lock()
+	fake.drops = 100
+	fake.mu.Unlock()
+
+	if _, err := cursor.Next(); err == nil || errors.Is(err, ErrCursorNotResumable) {
+		t.Fatalf("expected a reconnect failure, got %v", err)
+	}
+	if cursor.Reconnects() != 2 {
+		t.Errorf("expected 2 reconnects, got %d", cursor.Reconnects())
+	}
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Errors of a general kind, rebuilt from the error type of a server
+// response. Errors the client returns for an error response match one of
+// them with errors.Is when the server reported that kind of error.
+var (
+	// ErrNotFound matches errors for a document, collection, index or
+	// cursor that doesn't exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrDuplicateKey matches errors for a write rejected by a unique index.
+	// The error is a *DuplicateKeyError.
+	ErrDuplicateKey = errors.New("duplicate key")
+
+	// ErrValidation matches errors for a write rejected because the document
+	// or update is invalid. The error is a *ValidationError.
+	ErrValidation = errors.New("validation failed")
+
+	// ErrConflict matches errors for a write that conflicted with a
+	// concurrent one and may be retried
+	ErrConflict = errors.New("write conflict")
+
+	// ErrReadOnly matches errors for a write to a read-only server
+	ErrReadOnly = errors.New("database is read-only")
+
+	// ErrTimeout matches errors for an operation the server gave up waiting
+	// on
+	ErrTimeout = errors.New("operation timed out")
+)
+
+// errorKinds maps the error types of server responses to their kinds
+var errorKinds = map[string]error{
+	"NotFound":           ErrNotFound,
+	"DocumentNotFound":   ErrNotFound,
+	"CollectionNotFound": ErrNotFound,
+	"CursorNotFound":     ErrNotFound,
+	"DuplicateKey":       ErrDuplicateKey,
+	"ValidationError":    ErrValidation,
+	"Conflict":           ErrConflict,
+	"ReadOnly":           ErrReadOnly,
+	"Timeout":            ErrTimeout,
+}
+
+// APIError is an error response of the server. It matches the kind of its
+// Type, if any, with errors.Is.
+type APIError struct {
+	Code    int    // HTTP status code
+	Type    string // Error type, such as "DuplicateKey"
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error: %s - %s", e.Type, e.Message)
+}
+
+// Unwrap makes APIError match the kind of its type
+func (e *APIError) Unwrap() error {
+	return errorKinds[e.Type]
+}
+
+// DuplicateKeyError is an error response for a write rejected by a unique
+// index. Fields and Values hold the indexed fields and the values that
+// collided, in index order.
+type DuplicateKeyError struct {
+	APIError
+	Collection string        `json:"collection"`
+	Index      string        `json:"index"`
+	Fields     []string      `json:"fields"`
+	Values     []interface{} `json:"values"`
+}
+
+// Unwrap makes DuplicateKeyError match *APIError and ErrDuplicateKey
+func (e *DuplicateKeyError) Unwrap() error {
+	return &e.APIError
+}
+
+// ValidationError is an error response for a write rejected because a field
+// of the document or update is invalid
+type ValidationError struct {
+	APIError
+	Field string `json:"field"`
+}
+
+// Unwrap makes ValidationError match *APIError and ErrValidation
+func (e *ValidationError) Unwrap() error {
+	return &e.APIError
+}
+
+// responseError rebuilds the error of an error response
+func responseError(resp *Response) error {
+	apiErr := APIError{Code: resp.Code, Type: resp.Error, Message: resp.Message}
+	switch resp.Error {
+	case "DuplicateKey":
+		err := &DuplicateKeyError{APIError: apiErr}
+		if len(resp.Details) > 0 {
+			json.Unmarshal(resp.Details, err)
+		}
+		return err
+	case "ValidationError":
+		err := &ValidationError{APIError: apiErr}
+		if len(resp.Details) > 0 {
+			json.Unmarshal(resp.Details, err)
+		}
+		return err
+	default:
+		return &apiErr
+	}
+}
@@ -0,0 +1,218 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/wire"
+)
+
+// wireTransport sends requests over a persistent wire protocol connection.
+// Concurrent requests are pipelined on the same connection and matched to
+// their responses by request ID.
+type wireTransport struct {
+	addr    string
+	token   string
+	timeout time.Duration
+
+	dialMu sync.Mutex // serializes connection setup
+	mu     sync.Mutex
+	conn   *wireConn
+	nextID uint64
+}
+
+// wireConn is one connection with its in-flight requests
+type wireConn struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[uint64]chan *wire.Frame
+	closed  bool
+}
+
+// newWireTransport creates a transport; the connection is opened lazily
+func newWireTransport(addr, token string, timeout time.Duration) *wireTransport {
+	return &wireTransport{
+		addr:    addr,
+		token:   token,
+		timeout: timeout,
+	}
+}
+
+// roundTrip sends a request and returns the JSON response body
+func (t *wireTransport) roundTrip(method, path string, body []byte) ([]byte, error) {
+	wc, err := t.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(&wire.Request{Method: method, Path: path, Body: body})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	resp, err := t.send(wc, wire.OpRequest, payload)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// connection returns the open connection, dialing and authenticating a new
+// one if needed
+func (t *wireTransport) connection() (*wireConn, error) {
+	t.dialMu.Lock()
+	defer t.dialMu.Unlock()
+
+	t.mu.Lock()
+	wc := t.conn
+	t.mu.Unlock()
+	if wc != nil && !wc.isClosed() {
+		return wc, nil
+	}
+
+	conn, err := net.DialTimeout("tcp", t.addr, t.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	wc = &wireConn{
+		conn:    conn,
+		pending: make(map[uint64]chan *wire.Frame),
+	}
+	go wc.readLoop()
+
+	if t.token != "" {
+		resp, err := t.send(wc, wire.OpAuth, []byte(t.token))
+		if err != nil {
+			wc.close()
+			return nil, err
+		}
+		if resp.Status != http.StatusOK {
+			wc.close()
+			return nil, fmt.Errorf("wire authentication failed: %s", resp.Body)
+		}
+	}
+
+	t.mu.Lock()
+	t.conn = wc
+	t.mu.Unlock()
+	return wc, nil
+}
+
+// send writes a frame and waits for the response with the same request ID
+func (t *wireTransport) send(wc *wireConn, op wire.OpCode, payload []byte) (*wire.Response, error) {
+	t.mu.Lock()
+	t.nextID++
+	id := t.nextID
+	t.mu.Unlock()
+
+	ch, err := wc.register(id)
+	if err != nil {
+		return nil, err
+	}
+
+	wc.writeMu.Lock()
+	err = wire.WriteFrame(wc.conn, &wire.Frame{RequestID: id, OpCode: op, Payload: payload})
+	wc.writeMu.Unlock()
+	if err != nil {
+		wc.close()
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+
+	select {
+	case frame, ok := <-ch:
+		if !ok {
+			return nil, fmt.Errorf("request failed: connection closed")
+		}
+		return wire.DecodeResponse(frame)
+	case <-timer.C:
+		wc.unregister(id)
+		return nil, fmt.Errorf("request failed: timed out after %s", t.timeout)
+	}
+}
+
+// close closes the current connection
+func (t *wireTransport) close() error {
+	t.mu.Lock()
+	wc := t.conn
+	t.conn = nil
+	t.mu.Unlock()
+
+	if wc != nil {
+		wc.close()
+	}
+	return nil
+}
+
+// register adds a pending request
+func (wc *wireConn) register(id uint64) (chan *wire.Frame, error) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+
+	if wc.closed {
+		return nil, fmt.Errorf("request failed: connection closed")
+	}
+	ch := make(chan *wire.Frame, 1)
+	wc.pending[id] = ch
+	return ch, nil
+}
+
+// unregister removes a pending request that is no longer waited for
+func (wc *wireConn) unregister(id uint64) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	delete(wc.pending, id)
+}
+
+// readLoop delivers response frames to waiting requests until the
+// connection fails
+func (wc *wireConn) readLoop() {
+	reader := bufio.NewReader(wc.conn)
+	for {
+		frame, err := wire.ReadFrame(reader)
+		if err != nil {
+			wc.close()
+			return
+		}
+
+		wc.mu.Lock()
+		ch, exists := wc.pending[frame.RequestID]
+		delete(wc.pending, frame.RequestID)
+		wc.mu.Unlock()
+
+		if exists {
+			ch <- frame
+		}
+	}
+}
+
+// isClosed reports whether the connection has failed or been closed
+func (wc *wireConn) isClosed() bool {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	return wc.closed
+}
+
+// close closes the connection and fails all pending requests
+func (wc *wireConn) close() {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+
+	if wc.closed {
+		return
+	}
+	wc.closed = true
+	wc.conn.Close()
+	for id, ch := range wc.pending {
+		close(ch)
+		delete(wc.pending, id)
+	}
+}
@@ -467,3 +467,51 @@ func TestCombinedOperators(t *testing.T) {
 		t.Error("updated field should exist")
 	}
 }
+
+func TestUnsetIndexedField(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	users := db.Collection("users")
+	if err := users.CreateIndex("email", true); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+	if err := users.CreateCompoundIndex([]string{"city", "email"}, false); err != nil {
+		t.Fatalf("CreateCompoundIndex failed: %v", err)
+	}
+	users.InsertOne(map[string]interface{}{"name": "Alice", "email": "a@example.com", "city": "SF"})
+
+	err := users.UpdateOne(map[string]interface{}{"name": "Alice"},
+		map[string]interface{}{"$unset": map[string]interface{}{"email": ""}})
+	if err != nil {
+		t.Fatalf("Failed to unset: %v", err)
+	}
+
+	// The index entries of the removed field are gone
+	if size := users.indexes["email_1"].Size(); size != 0 {
+		t.Errorf("Expected an empty email index, got %d entries", size)
+	}
+	if size := users.indexes["city_email_1"].Size(); size != 0 {
+		t.Errorf("Expected an empty compound index, got %d entries", size)
+	}
+	if _, err := users.InsertOne(map[string]interface{}{"name": "Bob", "email": "a@example.com"}); err != nil {
+		t.Errorf("Expected the unset email to be free for reuse, got %v", err)
+	}
+	if count, _ := users.Count(map[string]interface{}{"email": "a@example.com"}); count != 1 {
+		t.Errorf("Expected 1 user with the email, got %d", count)
+	}
+
+	// Unsetting fields that don't exist, even below a scalar, is a no-op
+	err = users.UpdateOne(map[string]interface{}{"name": "Alice"},
+		map[string]interface{}{"$unset": map[string]interface{}{"missing": "", "name.first": "", "email": ""}})
+	if err != nil {
+		t.Fatalf("Expected unsetting missing fields to succeed, got %v", err)
+	}
+	doc, _ := users.FindOne(map[string]interface{}{"name": "Alice"})
+	if city, _ := doc.Get("city"); city != "SF" {
+		t.Errorf("Expected city to be kept, got %v", city)
+	}
+	if len(doc.ToMap()) != 3 {
+		t.Errorf("Expected _id, name and city, got %v", doc.ToMap())
+	}
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/aggregation"
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+// AggregateOptions contains options for aggregation cursors
+type AggregateOptions struct {
+	BatchSize int           // Number of documents per NextBatch (default: 100)
+	Timeout   time.Duration // Cursor idle timeout (default: 10 minutes)
+
+	// MemoryLimit is how many bytes $sort and $group buffer before spilling
+	// to temporary files (0 uses aggregation.DefaultMemoryLimit, negative
+	// never spills)
+	MemoryLimit int64
+
+	// TempDir is where spill files are created ("" uses the system default)
+	TempDir string
+
+	// Hint runs the $match the pipeline must start with on the named index,
+	// or as a collection scan for query.NaturalHint
+	Hint string
+}
+
+// DefaultAggregateOptions returns default aggregation cursor options
+func DefaultAggregateOptions() *AggregateOptions {
+	cursorOptions := DefaultCursorOptions()
+	return &AggregateOptions{
+		BatchSize:   cursorOptions.BatchSize,
+		Timeout:     cursorOptions.Timeout,
+		MemoryLimit: aggregation.DefaultMemoryLimit,
+	}
+}
+
+// AggregateCursor runs an aggregation pipeline and returns a cursor over its
+// results. Documents are read from the collection as the cursor is advanced,
+// unless a leading $match is pushed into an index scan as in
+// AggregateWithOptions, which reads only the matching documents up front.
+// $match, $project, $skip and $limit stream, while $sort and $group buffer
+// their input and spill to disk beyond opts.MemoryLimit. The cursor must be
+// closed to release spill files. A pipeline ending in $out or $merge is run
+// to completion and returns an empty cursor.
+func (c *Collection) AggregateCursor(pipeline []map[string]interface{}, opts *AggregateOptions) (*Cursor, error) {
+	if opts == nil {
+		opts = DefaultAggregateOptions()
+	}
+	cursorOptions := DefaultCursorOptions()
+	if opts.BatchSize > 0 {
+		cursorOptions.BatchSize = opts.BatchSize
+	}
+	if opts.Timeout > 0 {
+		cursorOptions.Timeout = opts.Timeout
+	}
+
+	aggPipeline, err := aggregation.NewPipeline(pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	if aggPipeline.Output() != nil {
+		if err := c.checkWritable(); err != nil {
+			return nil, err
+		}
+	}
+
+	results, aggPipeline, _, err := c.streamAggregation(aggPipeline, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// $out and $merge consume the results; the cursor is empty
+	if output := aggPipeline.Output(); output != nil {
+		if err := c.writeOutput(output, results); err != nil {
+			return nil, err
+		}
+		results = aggregation.NewSliceIterator(nil)
+	}
+	return newStreamCursor(c, results, cursorOptions)
+}
+
+// streamAggregation runs a pipeline over the collection as in
+// AggregateCursor, returning its results, the stages left after any pushed
+// into an index scan, and their statistics
+func (c *Collection) streamAggregation(aggPipeline *aggregation.Pipeline, opts *AggregateOptions) (aggregation.Iterator, *aggregation.Pipeline, []*aggregation.StageStats, error) {
+	var source aggregation.Iterator
+	if opts.Hint != "" {
+		c.mu.RLock()
+		docs, rest, err := c.hintedSource(aggPipeline, opts.Hint)
+		c.mu.RUnlock()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		source, aggPipeline = aggregation.NewSliceIterator(docs), rest
+	} else {
+		c.mu.RLock()
+		var err error
+		if ap := c.planAggregation(aggPipeline); ap.indexed() {
+			// Only the documents the index scan matches enter the pipeline
+			var docs []*document.Document
+			docs, err = c.indexedSource(ap)
+			source, aggPipeline = aggregation.NewSliceIterator(docs), ap.rest
+		} else {
+			var cold []*document.Document
+			cold, err = c.coldDocuments()
+			source = &collectionIterator{coll: c, ids: c.docStore.GetAllIDs(), cold: cold}
+		}
+		c.mu.RUnlock()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	c.bindLookups(aggPipeline)
+	results, stats, err := aggPipeline.StreamWithStats(c.upgradingIterator(source), &aggregation.StreamOptions{
+		MemoryLimit: opts.MemoryLimit,
+		TempDir:     opts.TempDir,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return results, aggPipeline, stats, nil
+}
+
+// hintedSource runs the leading $match of a pipeline through the query
+// planner with a hint, returning the matching documents and the remaining
+// stages
+// Must be called with c.mu held
+func (c *Collection) hintedSource(p *aggregation.Pipeline, hint string) ([]*document.Document, *aggregation.Pipeline, error) {
+	filter, rest := p.LeadingMatch()
+	if filter == nil {
+		return nil, nil, kindOf(ErrInvalidHint, "hint requires the pipeline to start with $match")
+	}
+	docs, err := c.executeQuery(query.NewQuery(filter).WithHint(hint))
+	if err != nil {
+		return nil, nil, err
+	}
+	return docs, rest, nil
+}
+
+// collectionIterator reads the documents of a collection one at a time.
+// Documents deleted after the iterator was created are skipped.
+type collectionIterator struct {
+	coll *Collection
+	ids  []string
+	pos  int
+	cold []*document.Document // Documents of the cold tier, read after ids
+}
+
+func (it *collectionIterator) Next() (*document.Document, error) {
+	for it.pos < len(it.ids) {
+		id := it.ids[it.pos]
+		it.pos++
+
+		it.coll.mu.RLock()
+		if !it.coll.docStore.Exists(id) {
+			it.coll.mu.RUnlock()
+			continue
+		}
+		doc, err := it.coll.docStore.Get(id)
+		it.coll.mu.RUnlock()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get document %s: %w", id, err)
+		}
+		return doc, nil
+	}
+	if len(it.cold) > 0 {
+		doc := it.cold[0]
+		it.cold = it.cold[1:]
+		return doc, nil
+	}
+	return nil, io.EOF
+}
+
+func (it *collectionIterator) Close() error {
+	it.ids = nil
+	it.pos = 0
+	return nil
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestAggregateCursorStreaming(t *testing.T) {
+	coll := createTestCollectionWithData(t, "users", 25)
+
+	cursor, err := coll.AggregateCursor([]map[string]interface{}{
+		{"$match": map[string]interface{}{"age": map[string]interface{}{"$gte": int64(30)}}},
+		{"$sort": map[string]interface{}{"age": 1}},
+		{"$project": map[string]interface{}{"name": true, "age": true}},
+	}, &AggregateOptions{BatchSize: 4})
+	if err != nil {
+		t.Fatalf("Failed to create aggregation cursor: %v", err)
+	}
+	defer cursor.Close()
+
+	var ages []int64
+	for cursor.HasNext() {
+		batch, err := cursor.NextBatch()
+		if err != nil {
+			t.Fatalf("NextBatch failed: %v", err)
+		}
+		if len(batch) > 4 {
+			t.Fatalf("Expected batches of at most 4 documents, got %d", len(batch))
+		}
+		for _, doc := range batch {
+			age, _ := doc.Get("age")
+			ages = append(ages, age.(int64))
+			if _, exists := doc.Get("score"); exists {
+				t.Error("Expected score to be projected out")
+			}
+		}
+	}
+
+	if len(ages) != 15 {
+		t.Fatalf("Expected 15 documents, got %d", len(ages))
+	}
+	for i, age := range ages {
+		if age != int64(30+i) {
+			t.Errorf("Expected age %d at position %d, got %d", 30+i, i, age)
+		}
+	}
+	if !cursor.IsExhausted() {
+		t.Error("Expected cursor to be exhausted")
+	}
+	if cursor.Count() != 15 {
+		t.Errorf("Expected count 15, got %d", cursor.Count())
+	}
+}
+
+func TestAggregateCursorSpill(t *testing.T) {
+	coll := createTestCollectionWithData(t, "users", 100)
+	tmpDir := t.TempDir()
+
+	cursor, err := coll.AggregateCursor([]map[string]interface{}{
+		{"$sort": map[string]interface{}{"score": -1}},
+		{"$limit": 10},
+	}, &AggregateOptions{MemoryLimit: 1024, TempDir: tmpDir})
+	if err != nil {
+		t.Fatalf("Failed to create aggregation cursor: %v", err)
+	}
+
+	first, err := cursor.Next()
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if score, _ := first.Get("score"); score != int64(199) {
+		t.Errorf("Expected highest score 199, got %v", score)
+	}
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) == 0 {
+		t.Error("Expected $sort to spill with a small memory limit")
+	}
+
+	cursor.Close()
+	entries, _ = os.ReadDir(tmpDir)
+	if len(entries) != 0 {
+		t.Errorf("Expected spill files to be removed on close, found %d", len(entries))
+	}
+}
+
+func TestAggregateCursorInvalidPipeline(t *testing.T) {
+	coll := createTestCollectionWithData(t, "users", 1)
+
+	if _, err := coll.AggregateCursor([]map[string]interface{}{{"$bogus": 1}}, nil); err == nil {
+		t.Error("Expected error for unsupported stage")
+	}
+}
+
+func TestExplainAggregateExecution(t *testing.T) {
+	coll := createTestCollectionWithData(t, "users", 100)
+	tmpDir := t.TempDir()
+
+	pipeline := []map[string]interface{}{
+		{"$match": map[string]interface{}{"age": map[string]interface{}{"$gte": int64(30)}}},
+		{"$group": map[string]interface{}{"_id": "$name", "total": map[string]interface{}{"$sum": "$score"}}},
+		{"$sort": map[string]interface{}{"total": -1}},
+	}
+	explain, err := coll.ExplainAggregateExecution(pipeline, &AggregateOptions{MemoryLimit: 1024, TempDir: tmpDir})
+	if err != nil {
+		t.Fatalf("ExplainAggregateExecution failed: %v", err)
+	}
+	if explain["returnedDocuments"] != 90 {
+		t.Errorf("Expected 90 returned documents, got %v", explain["returnedDocuments"])
+	}
+	stages := explain["stages"].([]map[string]interface{})
+	if len(stages) != 3 {
+		t.Fatalf("Expected 3 explained stages, got %d", len(stages))
+	}
+	if stages[0]["spilled"] != false {
+		t.Errorf("Expected $match not to spill, got %v", stages[0])
+	}
+	for _, stage := range stages[1:] {
+		if stage["spilled"] != true || stage["spillFiles"].(int) == 0 {
+			t.Errorf("Expected %s to spill with a small memory limit, got %v", stage["stage"], stage)
+		}
+	}
+	if entries, _ := os.ReadDir(tmpDir); len(entries) != 0 {
+		t.Errorf("Expected spill files to be removed, found %d", len(entries))
+	}
+
+	// Within the default limit nothing spills
+	explain, err = coll.ExplainAggregateExecution(pipeline, nil)
+	if err != nil {
+		t.Fatalf("ExplainAggregateExecution failed: %v", err)
+	}
+	for _, stage := range explain["stages"].([]map[string]interface{}) {
+		if stage["spilled"] != false {
+			t.Errorf("Expected %s not to spill, got %v", stage["stage"], stage)
+		}
+	}
+
+	pipeline = append(pipeline, map[string]interface{}{"$out": "totals"})
+	if _, err := coll.ExplainAggregateExecution(pipeline, nil); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a pipeline with $out to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/mnohosten/laura-db/pkg/aggregation"
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/index"
+)
+
+// collectionLookup is the aggregation.LookupSource of a pipeline run on a
+// collection of a database. A foreign collection with an index on the
+// field is searched through it; otherwise its documents are read once into
+// a table by the field, which serves the rest of the pipeline run. A
+// collection that doesn't exist has no documents to join.
+type collectionLookup struct {
+	db     *Database
+	tables map[[2]string]*aggregation.LookupTable // By collection and field
+}
+
+// bindLookups binds the $lookup stages of a pipeline to the collections of
+// the database. Collections outside a database have none to join.
+func (c *Collection) bindLookups(p *aggregation.Pipeline) {
+	if c.db != nil {
+		p.BindLookups(&collectionLookup{db: c.db, tables: make(map[[2]string]*aggregation.LookupTable)})
+	}
+}
+
+func (l *collectionLookup) Lookup(from, field string, value interface{}) ([]*document.Document, error) {
+	l.db.mu.RLock()
+	coll := l.db.collections[from]
+	l.db.mu.RUnlock()
+	if coll == nil {
+		return nil, nil
+	}
+
+	coll.mu.RLock()
+	defer coll.mu.RUnlock()
+
+	// Documents missing the field aren't indexed, and neither is the cold
+	// tier, so those lookups read the table
+	if idx := coll.lookupIndex(field); idx != nil && value != nil && coll.coldCount() == 0 {
+		idx.Usage().Record()
+		ids := idx.SearchAll(value)
+		if len(ids) == 0 {
+			return nil, nil
+		}
+		docs := make([]*document.Document, 0, len(ids))
+		for _, id := range ids {
+			doc, err := coll.docStore.Get(id.(string))
+			if err != nil {
+				return nil, fmt.Errorf("failed to get document %v: %w", id, err)
+			}
+			docs = append(docs, doc)
+		}
+		return coll.upgradeDocuments(docs)
+	}
+
+	key := [2]string{from, field}
+	table, exists := l.tables[key]
+	if !exists {
+		docs, err := coll.getAllDocuments()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load documents: %w", err)
+		}
+		cold, err := coll.coldDocuments()
+		if err != nil {
+			return nil, err
+		}
+		if docs, err = coll.upgradeDocuments(append(docs, cold...)); err != nil {
+			return nil, err
+		}
+		table = aggregation.NewLookupTable(docs, field)
+		l.tables[key] = table
+	}
+	return table.Find(value), nil
+}
+
+// lookupIndex returns a ready B+ tree index on field alone that holds every
+// document having the field, or nil if there is none
+// Must be called with c.mu held
+func (c *Collection) lookupIndex(field string) *index.Index {
+	for _, idx := range c.indexes {
+		if idx.IsReady() && !idx.IsPartial() && !idx.IsCompound() && idx.FieldPath() == field {
+			return idx
+		}
+	}
+	return nil
+}
+
+// snapshotLookup is the aggregation.LookupSource of a pipeline run on a
+// snapshot: foreign documents are read as of the snapshot, into a table by
+// the field
+type snapshotLookup struct {
+	snapshot *Snapshot
+	tables   map[[2]string]*aggregation.LookupTable // By collection and field
+}
+
+func (l *snapshotLookup) Lookup(from, field string, value interface{}) ([]*document.Document, error) {
+	key := [2]string{from, field}
+	table, exists := l.tables[key]
+	if !exists {
+		docs, err := l.snapshot.documents(from)
+		if err != nil {
+			return nil, err
+		}
+		table = aggregation.NewLookupTable(docs, field)
+		l.tables[key] = table
+	}
+	return table.Find(value), nil
+}
@@ -0,0 +1,169 @@
+package database
+
+import (
+	"testing"
+)
+
+// openLookupTestDB opens a database with users and their orders
+func openLookupTestDB(t *testing.T) *Database {
+	t.Helper()
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Collection("users").InsertMany([]map[string]interface{}{
+		{"_id": "u1", "name": "Alice", "email": "alice@example.com"},
+		{"_id": "u2", "name": "Bob", "email": "bob@example.com"},
+		{"_id": "u3", "name": "Nobody", "email": nil},
+	}); err != nil {
+		t.Fatalf("Failed to insert users: %v", err)
+	}
+	if _, err := db.Collection("orders").InsertMany([]map[string]interface{}{
+		{"_id": "o1", "email": "alice@example.com", "total": int64(10)},
+		{"_id": "o2", "email": "bob@example.com", "total": int64(20)},
+		{"_id": "o3", "email": "carol@example.com", "total": int64(30)},
+		{"_id": "o4", "total": int64(40)},
+	}); err != nil {
+		t.Fatalf("Failed to insert orders: %v", err)
+	}
+	return db
+}
+
+// joinedNames returns the name of each joined user of each order, by order _id
+func joinedNames(t *testing.T, orders []map[string]interface{}) map[interface{}][]interface{} {
+	t.Helper()
+	names := make(map[interface{}][]interface{})
+	for _, order := range orders {
+		users, ok := order["user"].([]interface{})
+		if !ok {
+			t.Fatalf("Expected an array of users in %v", order)
+		}
+		names[order["_id"]] = make([]interface{}, 0, len(users))
+		for _, user := range users {
+			names[order["_id"]] = append(names[order["_id"]], user.(map[string]interface{})["name"])
+		}
+	}
+	return names
+}
+
+func checkJoinedNames(t *testing.T, names map[interface{}][]interface{}) {
+	t.Helper()
+	expected := map[interface{}]interface{}{"o1": "Alice", "o2": "Bob", "o4": "Nobody"}
+	for id, name := range expected {
+		if len(names[id]) != 1 || names[id][0] != name {
+			t.Errorf("Expected order %v joined with %v, got %v", id, name, names[id])
+		}
+	}
+	if users, ok := names["o3"]; !ok || len(users) != 0 {
+		t.Errorf("Expected order o3 joined with no users, got %v", users)
+	}
+}
+
+var lookupUsers = []map[string]interface{}{
+	{"$lookup": map[string]interface{}{
+		"from":         "users",
+		"localField":   "email",
+		"foreignField": "email",
+		"as":           "user",
+	}},
+	{"$sort": map[string]interface{}{"_id": 1}},
+}
+
+func TestAggregateLookup(t *testing.T) {
+	db := openLookupTestDB(t)
+
+	results, err := db.Collection("orders").Aggregate(lookupUsers)
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 orders, got %d", len(results))
+	}
+	orders := make([]map[string]interface{}, len(results))
+	for i, doc := range results {
+		orders[i] = doc.ToMap()
+	}
+	checkJoinedNames(t, joinedNames(t, orders))
+
+	// A collection that doesn't exist joins nothing
+	results, err = db.Collection("orders").Aggregate([]map[string]interface{}{
+		{"$lookup": map[string]interface{}{"from": "missing", "localField": "email", "foreignField": "email", "as": "user"}},
+	})
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	for _, doc := range results {
+		if users, _ := doc.Get("user"); len(users.([]interface{})) != 0 {
+			t.Errorf("Expected no users joined, got %v", users)
+		}
+	}
+}
+
+func TestAggregateLookupIndexed(t *testing.T) {
+	db := openLookupTestDB(t)
+	users := db.Collection("users")
+	if err := users.CreateIndex("email", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	before := users.indexes["email_1"].Usage().Usage().Ops
+
+	cursor, err := db.Collection("orders").AggregateCursor(lookupUsers, nil)
+	if err != nil {
+		t.Fatalf("AggregateCursor failed: %v", err)
+	}
+	defer cursor.Close()
+	orders := make([]map[string]interface{}, 0)
+	for cursor.HasNext() {
+		doc, err := cursor.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		orders = append(orders, doc.ToMap())
+	}
+	checkJoinedNames(t, joinedNames(t, orders))
+
+	// The orders with an email are looked up through the index
+	if accesses := users.indexes["email_1"].Usage().Usage().Ops - before; accesses != 3 {
+		t.Errorf("Expected 3 index lookups, got %d", accesses)
+	}
+}
+
+func TestAggregateLookupSelf(t *testing.T) {
+	db := openLookupTestDB(t)
+
+	results, err := db.Collection("users").Aggregate([]map[string]interface{}{
+		{"$lookup": map[string]interface{}{"from": "users", "localField": "_id", "foreignField": "_id", "as": "self"}},
+	})
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	for _, doc := range results {
+		if self, _ := doc.Get("self"); len(self.([]interface{})) != 1 {
+			t.Errorf("Expected each user joined with itself, got %v", self)
+		}
+	}
+}
+
+func TestSnapshotAggregateLookup(t *testing.T) {
+	db := openLookupTestDB(t)
+
+	snapshot := db.StartReadSnapshot()
+	defer snapshot.Release()
+
+	// Users renamed after the snapshot are joined as they were
+	db.Collection("users").UpdateOne(map[string]interface{}{"_id": "u1"}, map[string]interface{}{
+		"$set": map[string]interface{}{"name": "Alicia"},
+	})
+
+	results, err := snapshot.Aggregate("orders", lookupUsers)
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	orders := make([]map[string]interface{}, len(results))
+	for i, doc := range results {
+		orders[i] = doc.ToMap()
+	}
+	checkJoinedNames(t, joinedNames(t, orders))
+}
@@ -0,0 +1,229 @@
+package database
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mnohosten/laura-db/pkg/aggregation"
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+// writeOutput writes the results of a pipeline to the collection named by its
+// $out or $merge stage. docs is closed when done.
+func (c *Collection) writeOutput(stage aggregation.Stage, docs aggregation.Iterator) error {
+	defer docs.Close()
+
+	if c.db == nil {
+		return fmt.Errorf("%s requires a collection of a database", stage.Type())
+	}
+
+	switch s := stage.(type) {
+	case *aggregation.OutStage:
+		return c.db.replaceCollection(s.Collection, docs)
+	case *aggregation.MergeStage:
+		return c.db.mergeIntoCollection(s, docs)
+	}
+	return fmt.Errorf("unsupported output stage: %s", stage.Type())
+}
+
+// replaceCollection replaces the contents of a collection with docs. The
+// documents are written to a temporary collection with the target's indexes
+// first, and swapped in only once all of them were inserted, so readers see
+// either the old or the new contents. On error the target is left unchanged.
+func (db *Database) replaceCollection(name string, docs aggregation.Iterator) error {
+	db.mu.RLock()
+	if !db.isOpen {
+		db.mu.RUnlock()
+		return ErrDatabaseClosed
+	}
+	target := db.collections[name]
+	db.mu.RUnlock()
+
+	tmp := db.newCollection(name)
+	tmp.changes = nil
+
+	if target != nil {
+		target.mu.RLock()
+		indexes := target.indexBackups()
+		target.mu.RUnlock()
+
+		for _, idx := range indexes {
+			if err := db.createIndexFromBackup(tmp, idx); err != nil {
+				return fmt.Errorf("failed to copy index %s: %w", idx.Name, err)
+			}
+		}
+	}
+
+	if err := writeAll(tmp, docs); err != nil {
+		// Return the pages written so far to the page store
+		tmp.docStore.Truncate()
+		return err
+	}
+
+	db.mu.Lock()
+	if !db.isOpen {
+		db.mu.Unlock()
+		return ErrDatabaseClosed
+	}
+	target, exists := db.collections[name]
+	if !exists {
+		tmp.changes = db.changes
+		db.collections[name] = tmp
+		db.mu.Unlock()
+		return nil
+	}
+
+	// Swap the contents in place so existing handles to the target see the
+	// new documents. The source of the pipeline was read to the end before
+	// the swap, so the target may be the source itself.
+	target.mu.Lock()
+	replaced := target.docStore
+	target.docStore = tmp.docStore
+	target.indexes = tmp.indexes
+	target.textIndexes = tmp.textIndexes
+	target.geoIndexes = tmp.geoIndexes
+	target.ttlIndexes = tmp.ttlIndexes
+	archive := target.archive.Swap(nil)
+	target.queryCache.Clear()
+	target.mu.Unlock()
+	db.mu.Unlock()
+
+	// The replaced documents are no longer reachable: return their pages
+	// and quota, and remove their cold tier
+	if err := replaced.Truncate(); err != nil {
+		return fmt.Errorf("failed to release replaced documents: %w", err)
+	}
+	if archive != nil {
+		if err := archive.destroy(); err != nil {
+			return fmt.Errorf("failed to remove replaced archive: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeAll inserts the documents of a $out stage into the collection
+// replacing its target
+func writeAll(tmp *Collection, docs aggregation.Iterator) error {
+	for {
+		doc, err := docs.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tmp.InsertOne(doc.ToMap()); err != nil {
+			return fmt.Errorf("failed to write $out document: %w", err)
+		}
+	}
+}
+
+// mergeIntoCollection upserts docs into the target collection of a $merge
+// stage. Documents are matched on the stage's On fields; the target is
+// created if it doesn't exist.
+func (db *Database) mergeIntoCollection(stage *aggregation.MergeStage, docs aggregation.Iterator) error {
+	target := db.Collection(stage.Into)
+
+	for {
+		doc, err := docs.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := target.mergeDocument(stage, doc); err != nil {
+			return err
+		}
+	}
+}
+
+// mergeDocument applies one $merge output document
+func (c *Collection) mergeDocument(stage *aggregation.MergeStage, doc *document.Document) error {
+	existing, err := c.findMergeTarget(stage.On, doc)
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		switch stage.WhenNotMatched {
+		case aggregation.MergeWhenNotMatchedDiscard:
+			return nil
+		case aggregation.MergeWhenNotMatchedFail:
+			return fmt.Errorf("$merge found no document matching %v", mergeKey(stage.On, doc))
+		}
+		if _, err := c.InsertOne(doc.ToMap()); err != nil {
+			return fmt.Errorf("failed to insert $merge document: %w", err)
+		}
+		return nil
+	}
+
+	id, _ := existing.Get("_id")
+	update := make(map[string]interface{})
+	switch stage.WhenMatched {
+	case aggregation.MergeWhenMatchedKeepExisting:
+		return nil
+	case aggregation.MergeWhenMatchedFail:
+		return fmt.Errorf("$merge found an existing document matching %v", mergeKey(stage.On, doc))
+	case aggregation.MergeWhenMatchedReplace:
+		// Remove the fields missing from the output document
+		unset := make(map[string]interface{})
+		for _, key := range existing.Keys() {
+			if _, exists := doc.Get(key); !exists && key != "_id" {
+				unset[key] = ""
+			}
+		}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+	}
+	if fields := withoutID(doc.ToMap()); len(fields) > 0 {
+		update["$set"] = fields
+	}
+	if len(update) == 0 {
+		return nil
+	}
+
+	if err := c.UpdateOne(map[string]interface{}{"_id": id}, update); err != nil {
+		return fmt.Errorf("failed to update $merge document: %w", err)
+	}
+	return nil
+}
+
+// findMergeTarget returns the document matching doc on the given fields, or
+// nil if there is none. A document without _id never matches on _id, since
+// it will be assigned a new one.
+func (c *Collection) findMergeTarget(on []string, doc *document.Document) (*document.Document, error) {
+	filter := make(map[string]interface{}, len(on))
+	for _, field := range on {
+		value, exists := doc.Get(field)
+		if !exists {
+			if field == "_id" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("$merge document is missing on field %s", field)
+		}
+		filter[field] = value
+	}
+
+	matches, err := c.Find(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find $merge target: %w", err)
+	}
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return matches[0], nil
+	}
+	return nil, fmt.Errorf("$merge on fields %v match %d documents", on, len(matches))
+}
+
+// mergeKey returns the on field values of doc for error messages
+func mergeKey(on []string, doc *document.Document) map[string]interface{} {
+	key := make(map[string]interface{}, len(on))
+	for _, field := range on {
+		key[field], _ = doc.Get(field)
+	}
+	return key
+}
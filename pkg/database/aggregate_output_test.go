@@ -0,0 +1,322 @@
+package database
+
+import (
+	"os"
+	"testing"
+)
+
+func openOutputTestDB(t *testing.T, dir string) *Database {
+	t.Helper()
+	os.RemoveAll(dir)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	sales := db.Collection("sales")
+	for _, doc := range []map[string]interface{}{
+		{"item": "apple", "qty": int64(5)},
+		{"item": "apple", "qty": int64(3)},
+		{"item": "pear", "qty": int64(2)},
+	} {
+		if _, err := sales.InsertOne(doc); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+	return db
+}
+
+func countDocuments(t *testing.T, coll *Collection) int {
+	t.Helper()
+	n, err := coll.Count(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	return n
+}
+
+func TestAggregateOut(t *testing.T) {
+	db := openOutputTestDB(t, "./test_db_agg_out")
+
+	totals := db.Collection("totals")
+	totals.InsertOne(map[string]interface{}{"_id": "stale", "total": int64(0)})
+	if err := totals.CreateIndex("total", false); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	results, err := db.Collection("sales").Aggregate([]map[string]interface{}{
+		{"$group": map[string]interface{}{
+			"_id":   "$item",
+			"total": map[string]interface{}{"$sum": "$qty"},
+		}},
+		{"$out": "totals"},
+	})
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results from $out, got %d", len(results))
+	}
+
+	// The existing handle sees the new contents
+	docs, err := totals.Find(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("Expected 2 documents after $out, got %d", len(docs))
+	}
+	apple, err := totals.FindOne(map[string]interface{}{"_id": "apple"})
+	if err != nil {
+		t.Fatalf("Expected apple total: %v", err)
+	}
+	if total, _ := apple.Get("total"); total != float64(8) {
+		t.Errorf("Expected apple total 8, got %v", total)
+	}
+	if _, err := totals.FindOne(map[string]interface{}{"_id": "stale"}); err == nil {
+		t.Error("Expected stale document to be replaced")
+	}
+
+	// Indexes of the target are kept and cover the new documents
+	if _, exists := totals.indexes["total_1"]; !exists {
+		t.Error("Expected total_1 index to be kept")
+	}
+	docs, err = totals.Find(map[string]interface{}{"total": float64(2)})
+	if err != nil || len(docs) != 1 {
+		t.Errorf("Expected 1 document with total 2, got %d (%v)", len(docs), err)
+	}
+}
+
+func TestAggregateOutCreatesCollection(t *testing.T) {
+	db := openOutputTestDB(t, "./test_db_agg_out_new")
+
+	_, err := db.Collection("sales").Aggregate([]map[string]interface{}{
+		{"$match": map[string]interface{}{"item": "apple"}},
+		{"$out": map[string]interface{}{"coll": "apples"}},
+	})
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+
+	if countDocuments(t, db.Collection("apples")) != 2 {
+		t.Errorf("Expected 2 documents in apples, got %d", countDocuments(t, db.Collection("apples")))
+	}
+}
+
+func TestAggregateOutFailureKeepsTarget(t *testing.T) {
+	db := openOutputTestDB(t, "./test_db_agg_out_fail")
+
+	target := db.Collection("target")
+	target.InsertOne(map[string]interface{}{"_id": "keep", "item": "plum"})
+	if err := target.CreateIndex("item", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	// Both apple sales violate the unique index copied from the target
+	_, err := db.Collection("sales").Aggregate([]map[string]interface{}{
+		{"$out": "target"},
+	})
+	if err == nil {
+		t.Fatal("Expected $out to fail on a unique index violation")
+	}
+
+	docs, _ := target.Find(map[string]interface{}{})
+	if len(docs) != 1 {
+		t.Fatalf("Expected target to be unchanged, got %d documents", len(docs))
+	}
+	if id, _ := docs[0].Get("_id"); id != "keep" {
+		t.Errorf("Expected document keep, got %v", id)
+	}
+}
+
+func TestAggregateOutIntoSource(t *testing.T) {
+	db := openOutputTestDB(t, "./test_db_agg_out_self")
+	sales := db.Collection("sales")
+
+	// Roll the sales up into themselves, then again through the streaming
+	// path, which reads the source while writing the output
+	rollup := func(key string) []map[string]interface{} {
+		return []map[string]interface{}{
+			{"$group": map[string]interface{}{
+				"_id": key,
+				"qty": map[string]interface{}{"$sum": "$qty"},
+			}},
+			{"$out": "sales"},
+		}
+	}
+	if _, err := sales.Aggregate(rollup("$item")); err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	cursor, err := sales.AggregateCursor(rollup("$_id"), nil)
+	if err != nil {
+		t.Fatalf("AggregateCursor failed: %v", err)
+	}
+	cursor.Close()
+
+	if n := countDocuments(t, sales); n != 2 {
+		t.Fatalf("Expected 2 documents after rolling up twice, got %d", n)
+	}
+	apple, err := sales.FindOne(map[string]interface{}{"_id": "apple"})
+	if err != nil {
+		t.Fatalf("Expected apple rollup: %v", err)
+	}
+	if qty, _ := apple.Get("qty"); qty != float64(8) {
+		t.Errorf("Expected apple qty 8, got %v", qty)
+	}
+}
+
+func TestAggregateOutReplacesColdTier(t *testing.T) {
+	db := openOutputTestDB(t, "./test_db_agg_out_cold")
+
+	target := db.Collection("target")
+	target.InsertOne(map[string]interface{}{"_id": "archived"})
+	if n, err := target.Archive(map[string]interface{}{}); err != nil || n != 1 {
+		t.Fatalf("Expected 1 document archived, got %d, %v", n, err)
+	}
+
+	if _, err := db.Collection("sales").Aggregate([]map[string]interface{}{{"$out": "target"}}); err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if n := countDocuments(t, target); n != 3 {
+		t.Errorf("Expected the 3 sales to replace the archived document, got %d documents", n)
+	}
+	if _, err := target.FindOne(map[string]interface{}{"_id": "archived"}); err == nil {
+		t.Error("Expected the archived document to be replaced")
+	}
+}
+
+func TestAggregateOutMustBeLast(t *testing.T) {
+	db := openOutputTestDB(t, "./test_db_agg_out_last")
+
+	_, err := db.Collection("sales").Aggregate([]map[string]interface{}{
+		{"$out": "target"},
+		{"$match": map[string]interface{}{}},
+	})
+	if err == nil {
+		t.Error("Expected an error for $out before another stage")
+	}
+	if names := db.ListCollections(); len(names) != 1 {
+		t.Errorf("Expected no collection created, got %v", names)
+	}
+}
+
+func TestAggregateMerge(t *testing.T) {
+	db := openOutputTestDB(t, "./test_db_agg_merge")
+
+	totals := db.Collection("totals")
+	totals.InsertOne(map[string]interface{}{"_id": "apple", "total": int64(1), "note": "old"})
+	totals.InsertOne(map[string]interface{}{"_id": "plum", "total": int64(4)})
+
+	group := map[string]interface{}{"$group": map[string]interface{}{
+		"_id":   "$item",
+		"total": map[string]interface{}{"$sum": "$qty"},
+	}}
+
+	// Default: merge matched documents, insert the rest
+	if _, err := db.Collection("sales").Aggregate([]map[string]interface{}{
+		group, {"$merge": "totals"},
+	}); err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if countDocuments(t, totals) != 3 {
+		t.Fatalf("Expected 3 documents after $merge, got %d", countDocuments(t, totals))
+	}
+	apple, _ := totals.FindOne(map[string]interface{}{"_id": "apple"})
+	if total, _ := apple.Get("total"); total != float64(8) {
+		t.Errorf("Expected apple total 8, got %v", total)
+	}
+	if note, _ := apple.Get("note"); note != "old" {
+		t.Errorf("Expected merge to keep note, got %v", note)
+	}
+
+	// replace drops fields missing from the output
+	if _, err := db.Collection("sales").Aggregate([]map[string]interface{}{
+		group,
+		{"$merge": map[string]interface{}{"into": "totals", "whenMatched": "replace", "whenNotMatched": "discard"}},
+	}); err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	apple, _ = totals.FindOne(map[string]interface{}{"_id": "apple"})
+	if _, exists := apple.Get("note"); exists {
+		t.Error("Expected replace to remove note")
+	}
+
+	// fail stops on the first match
+	_, err := db.Collection("sales").Aggregate([]map[string]interface{}{
+		group,
+		{"$merge": map[string]interface{}{"into": "totals", "whenMatched": "fail"}},
+	})
+	if err == nil {
+		t.Error("Expected whenMatched fail to return an error")
+	}
+}
+
+func TestAggregateMergeOnFields(t *testing.T) {
+	db := openOutputTestDB(t, "./test_db_agg_merge_on")
+
+	stock := db.Collection("stock")
+	stock.InsertOne(map[string]interface{}{"item": "apple", "qty": int64(100)})
+
+	if _, err := db.Collection("sales").Aggregate([]map[string]interface{}{
+		{"$project": map[string]interface{}{"_id": false, "item": true, "qty": true}},
+		{"$match": map[string]interface{}{"item": "pear"}},
+		{"$merge": map[string]interface{}{"into": "stock", "on": "item", "whenMatched": "keepExisting"}},
+	}); err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if countDocuments(t, stock) != 2 {
+		t.Errorf("Expected pear to be inserted, got %d documents", countDocuments(t, stock))
+	}
+
+	// Two apple sales match the same stock document on item, which is fine;
+	// keepExisting leaves it alone
+	if _, err := db.Collection("sales").Aggregate([]map[string]interface{}{
+		{"$project": map[string]interface{}{"_id": false, "item": true, "qty": true}},
+		{"$merge": map[string]interface{}{"into": "stock", "on": []interface{}{"item"}, "whenMatched": "keepExisting"}},
+	}); err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	apple, _ := stock.FindOne(map[string]interface{}{"item": "apple"})
+	if qty, _ := apple.Get("qty"); qty != int64(100) {
+		t.Errorf("Expected apple qty 100, got %v", qty)
+	}
+
+	// Output documents must have the on fields
+	_, err := db.Collection("sales").Aggregate([]map[string]interface{}{
+		{"$merge": map[string]interface{}{"into": "stock", "on": "missing"}},
+	})
+	if err == nil {
+		t.Error("Expected error for missing on field")
+	}
+}
+
+func TestAggregateCursorOut(t *testing.T) {
+	db := openOutputTestDB(t, "./test_db_agg_cursor_out")
+
+	cursor, err := db.Collection("sales").AggregateCursor([]map[string]interface{}{
+		{"$sort": map[string]interface{}{"qty": 1}},
+		{"$out": "sorted"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("AggregateCursor failed: %v", err)
+	}
+	defer cursor.Close()
+
+	if cursor.HasNext() {
+		t.Error("Expected empty cursor for $out")
+	}
+	if countDocuments(t, db.Collection("sorted")) != 3 {
+		t.Errorf("Expected 3 documents in sorted, got %d", countDocuments(t, db.Collection("sorted")))
+	}
+}
+
+func TestAggregateOutputOnStandaloneCollection(t *testing.T) {
+	coll := createTestCollectionWithData(t, "users", 1)
+
+	if _, err := coll.Aggregate([]map[string]interface{}{{"$out": "copy"}}); err == nil {
+		t.Error("Expected $out to fail without a database")
+	}
+}
@@ -0,0 +1,244 @@
+package database
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mnohosten/laura-db/pkg/aggregation"
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+// aggregationPlan describes how the leading stages of a pipeline are pushed
+// into an index scan
+type aggregationPlan struct {
+	filter map[string]interface{} // Filter of the leading $match (nil if none)
+	plan   *query.QueryPlan       // Plan for the filter (nil if no $match)
+	sorted []query.SortField      // $sort satisfied by the index order (nil if none)
+	rest   *aggregation.Pipeline  // Stages after those pushed down
+}
+
+// indexed reports whether the leading $match is answered by an index scan
+func (ap *aggregationPlan) indexed() bool {
+	return ap.plan != nil && (ap.plan.UseIndex || ap.plan.UseIntersection)
+}
+
+// planAggregation plans a pipeline's leading $match with the query planner,
+// and the $sort right after it when the index returns documents in that
+// order. Documents in the cold tier aren't in the indexes' order, so with
+// a cold tier no $sort is pushed down.
+// Must be called with c.mu held
+func (c *Collection) planAggregation(p *aggregation.Pipeline) *aggregationPlan {
+	filter, rest := p.LeadingMatch()
+	if filter == nil {
+		return &aggregationPlan{rest: p}
+	}
+
+	q := query.NewQuery(filter)
+	ap := &aggregationPlan{filter: filter, rest: rest}
+	if q.HasTextSearch() || q.NearCondition() != nil {
+		return ap // Not answerable by B+ tree indexes; the $match runs as a stage
+	}
+	ap.plan = c.queryPlanner().Plan(q)
+
+	if !ap.indexed() || ap.plan.UseIntersection || c.coldCount() > 0 {
+		return ap
+	}
+	if sortFields, afterSort := rest.LeadingSort(); indexOrderSatisfies(ap.plan, sortFields) {
+		ap.sorted, ap.rest = sortFields, afterSort
+	}
+	return ap
+}
+
+// indexOrderSatisfies reports whether a single index scan returns documents
+// in the order of sortFields: the fields must be a prefix of the index key,
+// all sorted in the same direction
+func indexOrderSatisfies(plan *query.QueryPlan, sortFields []query.SortField) bool {
+	if len(sortFields) == 0 || plan.Index == nil {
+		return false
+	}
+	fields := plan.Index.FieldPaths()
+	if len(sortFields) > len(fields) {
+		return false
+	}
+	for i, sf := range sortFields {
+		if sf.Field != fields[i] || sf.Ascending != sortFields[0].Ascending {
+			return false
+		}
+	}
+	return true
+}
+
+// aggregationSource returns the documents a pipeline reads and the stages
+// left to run on them. A leading $match the query planner can answer from
+// an index is run as an index scan, so only matching documents enter the
+// pipeline, and a following $sort on the index key is satisfied by the scan
+// order. Other pipelines read the whole collection.
+// Must be called with c.mu held
+func (c *Collection) aggregationSource(p *aggregation.Pipeline) ([]*document.Document, *aggregation.Pipeline, error) {
+	ap := c.planAggregation(p)
+	if !ap.indexed() {
+		docs, err := c.getAllDocuments()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load documents: %w", err)
+		}
+		cold, err := c.coldDocuments()
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(docs, cold...), p, nil
+	}
+
+	docs, err := c.indexedSource(ap)
+	if err != nil {
+		return nil, nil, err
+	}
+	return docs, ap.rest, nil
+}
+
+// indexedSource runs the index scan of a plan whose leading $match is
+// indexed, returning the matching documents in scan order followed by the
+// matching documents of the cold tier
+// Must be called with c.mu held
+func (c *Collection) indexedSource(ap *aggregationPlan) ([]*document.Document, error) {
+	ids, _ := ap.plan.DocumentIDs()
+	if len(ap.sorted) > 0 && !ap.sorted[0].Ascending {
+		for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+			ids[i], ids[j] = ids[j], ids[i]
+		}
+	}
+	ap.plan.RecordIndexUsage()
+
+	q := query.NewQuery(ap.filter)
+	docs := make([]*document.Document, 0, len(ids))
+	for _, id := range ids {
+		if !c.docStore.Exists(id) {
+			continue
+		}
+		doc, err := c.docStore.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get document %s: %w", id, err)
+		}
+		matches, err := q.Matches(doc)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			docs = append(docs, doc)
+		}
+	}
+
+	// The cold tier has no indexes
+	cold, err := c.coldDocuments()
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range cold {
+		matches, err := q.Matches(doc)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+// ExplainAggregate returns how a pipeline would be executed. Each entry of
+// "stages" names a stage and whether an index satisfies it: a leading $match
+// answered by an index scan carries its query plan, and a $sort right after
+// it is satisfied when the scan returns documents in sort order. Stages not
+// satisfied by an index run on the documents the scan returns.
+func (c *Collection) ExplainAggregate(pipeline []map[string]interface{}) (map[string]interface{}, error) {
+	aggPipeline, err := aggregation.NewPipeline(pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pipeline: %w", err)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ap := c.planAggregation(aggPipeline)
+	stages := make([]map[string]interface{}, 0, len(pipeline))
+	if ap.filter != nil {
+		stage := map[string]interface{}{"stage": "$match", "indexed": ap.indexed()}
+		if ap.plan != nil {
+			stage["plan"] = ap.plan.Explain()
+		}
+		stages = append(stages, stage)
+	}
+	if ap.sorted != nil {
+		stages = append(stages, map[string]interface{}{
+			"stage":     "$sort",
+			"indexed":   true,
+			"indexName": ap.plan.IndexName,
+		})
+	}
+	for _, stageType := range ap.rest.StageTypes() {
+		stages = append(stages, map[string]interface{}{"stage": stageType, "indexed": false})
+	}
+
+	return map[string]interface{}{
+		"collection":     c.name,
+		"totalDocuments": c.docStore.Count() + c.coldCount(),
+		"stages":         stages,
+	}, nil
+}
+
+// ExplainAggregateExecution runs a pipeline as AggregateCursor would with
+// opts, discarding its results, and returns the ExplainAggregate output
+// with execution statistics: "returnedDocuments" counts the results, and
+// each stage run after the index scan reports whether it "spilled" to disk
+// beyond opts.MemoryLimit, with the number of "spillFiles" it wrote. A
+// pipeline ending in $out or $merge is rejected, since running it would
+// write its output.
+func (c *Collection) ExplainAggregateExecution(pipeline []map[string]interface{}, opts *AggregateOptions) (map[string]interface{}, error) {
+	if opts == nil {
+		opts = DefaultAggregateOptions()
+	}
+	aggPipeline, err := aggregation.NewPipeline(pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	if aggPipeline.Output() != nil {
+		return nil, kindOf(ErrValidation, "cannot explain the execution of a pipeline with %s", aggPipeline.Output().Type())
+	}
+
+	explanation, err := c.ExplainAggregate(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	results, _, stats, err := c.streamAggregation(aggPipeline, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	returned := 0
+	for {
+		_, err := results.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		returned++
+	}
+	c.persistUpgrades()
+
+	// The stages run after the index scan are the last ones listed
+	stages := explanation["stages"].([]map[string]interface{})
+	offset := len(stages) - len(stats)
+	for i, stageStats := range stats {
+		if offset+i < 0 {
+			continue
+		}
+		stage := stages[offset+i]
+		stage["spilled"] = stageStats.Spilled
+		stage["spillFiles"] = stageStats.SpillFiles
+	}
+	explanation["returnedDocuments"] = returned
+	return explanation, nil
+}
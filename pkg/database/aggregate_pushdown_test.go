@@ -0,0 +1,121 @@
+package database
+
+import (
+	"testing"
+)
+
+// explainedStages returns the stage and indexed entries of ExplainAggregate
+func explainedStages(t *testing.T, coll *Collection, pipeline []map[string]interface{}) []map[string]interface{} {
+	t.Helper()
+
+	explain, err := coll.ExplainAggregate(pipeline)
+	if err != nil {
+		t.Fatalf("ExplainAggregate failed: %v", err)
+	}
+	return explain["stages"].([]map[string]interface{})
+}
+
+func TestAggregateMatchPushdown(t *testing.T) {
+	coll := createTestCollectionWithData(t, "users", 50)
+	if err := coll.CreateIndex("age", false); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+
+	pipeline := []map[string]interface{}{
+		{"$match": map[string]interface{}{"age": map[string]interface{}{"$gte": int64(60), "$lt": int64(65)}}},
+		{"$sort": map[string]interface{}{"age": -1}},
+		{"$project": map[string]interface{}{"name": true, "age": true}},
+	}
+
+	stages := explainedStages(t, coll, pipeline)
+	if len(stages) != 3 {
+		t.Fatalf("Expected 3 explained stages, got %d", len(stages))
+	}
+	want := []struct {
+		stage   string
+		indexed bool
+	}{{"$match", true}, {"$sort", true}, {"$project", false}}
+	for i, w := range want {
+		if stages[i]["stage"] != w.stage || stages[i]["indexed"] != w.indexed {
+			t.Errorf("Stage %d: expected %s indexed=%v, got %v", i, w.stage, w.indexed, stages[i])
+		}
+	}
+	plan := stages[0]["plan"].(map[string]interface{})
+	if plan["indexName"] != "age_1" || plan["scanType"] != "INDEX_RANGE" {
+		t.Errorf("Expected an INDEX_RANGE scan of age_1, got %v", plan)
+	}
+
+	results, err := coll.Aggregate(pipeline)
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if len(results) != 5 {
+		t.Fatalf("Expected 5 results, got %d", len(results))
+	}
+	for i, doc := range results {
+		if age, _ := doc.Get("age"); age != int64(64-i) {
+			t.Errorf("Expected age %d at position %d, got %v", 64-i, i, age)
+		}
+	}
+
+	// The cursor takes the same index scan
+	cursor, err := coll.AggregateCursor(pipeline, nil)
+	if err != nil {
+		t.Fatalf("AggregateCursor failed: %v", err)
+	}
+	defer cursor.Close()
+	count := 0
+	for cursor.HasNext() {
+		if _, err := cursor.Next(); err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		count++
+	}
+	if count != 5 {
+		t.Errorf("Expected 5 cursor results, got %d", count)
+	}
+}
+
+func TestAggregateMatchPushdownFallback(t *testing.T) {
+	coll := createTestCollectionWithData(t, "users", 20)
+	if err := coll.CreateIndex("age", false); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+
+	// A $sort on another field still runs as a stage
+	stages := explainedStages(t, coll, []map[string]interface{}{
+		{"$match": map[string]interface{}{"age": map[string]interface{}{"$gte": int64(30)}}},
+		{"$sort": map[string]interface{}{"score": 1}},
+	})
+	if stages[0]["indexed"] != true || stages[1]["indexed"] != false {
+		t.Errorf("Expected only $match to be indexed, got %v", stages)
+	}
+
+	// Without an index on the filter the $match runs on a collection scan
+	pipeline := []map[string]interface{}{
+		{"$match": map[string]interface{}{"name": "user_3"}},
+		{"$group": map[string]interface{}{"_id": "$name", "total": map[string]interface{}{"$sum": "$score"}}},
+	}
+	stages = explainedStages(t, coll, pipeline)
+	if stages[0]["indexed"] != false {
+		t.Errorf("Expected unindexed $match, got %v", stages[0])
+	}
+	results, err := coll.Aggregate(pipeline)
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 group, got %d", len(results))
+	}
+
+	// A pipeline not starting with $match has nothing to push down
+	stages = explainedStages(t, coll, []map[string]interface{}{
+		{"$sort": map[string]interface{}{"age": 1}},
+		{"$limit": int64(3)},
+	})
+	for _, stage := range stages {
+		if stage["indexed"] != false {
+			t.Errorf("Expected no indexed stages, got %v", stage)
+		}
+	}
+}
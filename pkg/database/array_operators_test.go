@@ -1,7 +1,9 @@
 package database
 
 import (
+	"errors"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -278,3 +280,170 @@ func TestArrayPopOperator(t *testing.T) {
 		t.Errorf("Expected last element to be 4, got %v", numbersArr[2])
 	}
 }
+
+func TestArrayPushSlice(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	coll := db.Collection("test")
+	coll.InsertOne(map[string]interface{}{"name": "Alice", "scores": []interface{}{int64(1), int64(2), int64(3)}})
+
+	scores := func() []interface{} {
+		doc, err := coll.FindOne(map[string]interface{}{"name": "Alice"})
+		if err != nil {
+			t.Fatalf("Failed to find: %v", err)
+		}
+		arr, _ := doc.Get("scores")
+		return arr.([]interface{})
+	}
+	push := func(spec map[string]interface{}) {
+		err := coll.UpdateOne(map[string]interface{}{"name": "Alice"},
+			map[string]interface{}{"$push": map[string]interface{}{"scores": spec}})
+		if err != nil {
+			t.Fatalf("Failed to push: %v", err)
+		}
+	}
+
+	// A negative $slice keeps the last elements
+	push(map[string]interface{}{"$each": []interface{}{int64(4), int64(5)}, "$slice": -4})
+	if got := scores(); !reflect.DeepEqual(got, []interface{}{int64(2), int64(3), int64(4), int64(5)}) {
+		t.Errorf("Expected [2 3 4 5], got %v", got)
+	}
+
+	// A positive $slice keeps the first elements
+	push(map[string]interface{}{"$each": []interface{}{int64(6)}, "$slice": 2})
+	if got := scores(); !reflect.DeepEqual(got, []interface{}{int64(2), int64(3)}) {
+		t.Errorf("Expected [2 3], got %v", got)
+	}
+
+	// $position inserts instead of appending
+	push(map[string]interface{}{"$each": []interface{}{int64(0)}, "$position": 0})
+	if got := scores(); !reflect.DeepEqual(got, []interface{}{int64(0), int64(2), int64(3)}) {
+		t.Errorf("Expected [0 2 3], got %v", got)
+	}
+
+	// $slice: 0 empties the array
+	push(map[string]interface{}{"$each": []interface{}{int64(7)}, "$slice": 0})
+	if got := scores(); len(got) != 0 {
+		t.Errorf("Expected an empty array, got %v", got)
+	}
+
+	// Unknown modifiers are rejected
+	err := coll.UpdateOne(map[string]interface{}{"name": "Alice"}, map[string]interface{}{
+		"$push": map[string]interface{}{"scores": map[string]interface{}{"$each": []interface{}{1}, "$sort": 1}},
+	})
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for $sort, got %v", err)
+	}
+}
+
+func TestArrayPushNonArray(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	coll := db.Collection("test")
+	coll.InsertOne(map[string]interface{}{"name": "Alice", "tags": "go", "count": int64(1)})
+
+	for _, op := range []string{"$push", "$addToSet"} {
+		err := coll.UpdateOne(map[string]interface{}{"name": "Alice"}, map[string]interface{}{
+			"$inc": map[string]interface{}{"count": 1},
+			op:     map[string]interface{}{"tags": "database"},
+		})
+		if !errors.Is(err, ErrValidation) {
+			t.Errorf("Expected a validation error for %s on a string, got %v", op, err)
+		}
+	}
+
+	// The failed updates left the document unchanged
+	doc, _ := coll.FindOne(map[string]interface{}{"name": "Alice"})
+	if tags, _ := doc.Get("tags"); tags != "go" {
+		t.Errorf("Expected tags to stay \"go\", got %v", tags)
+	}
+	if count, _ := doc.Get("count"); count != int64(1) {
+		t.Errorf("Expected count to stay 1, got %v", count)
+	}
+}
+
+func TestArrayPullCondition(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	coll := db.Collection("test")
+	coll.InsertOne(map[string]interface{}{
+		"name":   "Alice",
+		"scores": []interface{}{int64(3), int64(8), int64(5), int64(1)},
+		"items": []interface{}{
+			map[string]interface{}{"sku": "a", "qty": int64(0)},
+			map[string]interface{}{"sku": "b", "qty": int64(4)},
+		},
+	})
+	coll.InsertOne(map[string]interface{}{"name": "Bob", "scores": []interface{}{int64(9), int64(2)}})
+
+	// An operator predicate, across every matching document
+	result, err := coll.UpdateMany(map[string]interface{}{},
+		map[string]interface{}{"$pull": map[string]interface{}{"scores": map[string]interface{}{"$gte": int64(5)}}})
+	if err != nil {
+		t.Fatalf("Failed to pull: %v", err)
+	}
+	if result.ModifiedCount != 2 {
+		t.Errorf("Expected 2 modified documents, got %d", result.ModifiedCount)
+	}
+	alice, _ := coll.FindOne(map[string]interface{}{"name": "Alice"})
+	if scores, _ := alice.Get("scores"); !reflect.DeepEqual(scores, []interface{}{int64(3), int64(1)}) {
+		t.Errorf("Expected Alice's scores [3 1], got %v", scores)
+	}
+	bob, _ := coll.FindOne(map[string]interface{}{"name": "Bob"})
+	if scores, _ := bob.Get("scores"); !reflect.DeepEqual(scores, []interface{}{int64(2)}) {
+		t.Errorf("Expected Bob's scores [2], got %v", scores)
+	}
+
+	// A condition on the fields of embedded documents
+	updated, err := coll.FindOneAndUpdate(map[string]interface{}{"name": "Alice"},
+		map[string]interface{}{"$pull": map[string]interface{}{"items": map[string]interface{}{"qty": map[string]interface{}{"$lt": int64(1)}}}},
+		&FindOneAndUpdateOptions{ReturnNew: true})
+	if err != nil {
+		t.Fatalf("FindOneAndUpdate failed: %v", err)
+	}
+	items, _ := updated.Get("items")
+	if arr := items.([]interface{}); len(arr) != 1 {
+		t.Errorf("Expected 1 item left, got %v", arr)
+	}
+
+	// An invalid operator is reported
+	err = coll.UpdateOne(map[string]interface{}{"name": "Alice"},
+		map[string]interface{}{"$pull": map[string]interface{}{"scores": map[string]interface{}{"$bogus": 1}}})
+	if err == nil {
+		t.Error("Expected an error for an unknown operator")
+	}
+}
+
+func TestArrayUpdateIndexed(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	coll := db.Collection("test")
+	if err := coll.CreateIndex("tags", false); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+	coll.InsertOne(map[string]interface{}{"name": "Alice", "tags": []interface{}{"go"}})
+
+	_, err := coll.FindOneAndUpdate(map[string]interface{}{"name": "Alice"},
+		map[string]interface{}{"$addToSet": map[string]interface{}{"tags": map[string]interface{}{"$each": []interface{}{"go", "db"}}}}, nil)
+	if err != nil {
+		t.Fatalf("FindOneAndUpdate failed: %v", err)
+	}
+
+	// The index holds the new array value, not the old one
+	docs, err := coll.Find(map[string]interface{}{"tags": []interface{}{"go", "db"}})
+	if err != nil || len(docs) != 1 {
+		t.Errorf("Expected the updated array to be found, got %d documents (%v)", len(docs), err)
+	}
+	docs, _ = coll.Find(map[string]interface{}{"tags": []interface{}{"go"}})
+	if len(docs) != 0 {
+		t.Errorf("Expected the old array to be gone, got %d documents", len(docs))
+	}
+	result, err := coll.ExplainExecution(map[string]interface{}{"tags": []interface{}{"go", "db"}}, nil)
+	if err != nil || !result.IndexScan || result.DocsReturned != 1 {
+		t.Errorf("Expected an index scan returning 1 document, got %+v (%v)", result, err)
+	}
+}
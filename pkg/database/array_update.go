@@ -0,0 +1,238 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+// arrayField returns the array held by a field an array update operator
+// writes, or nil if the field doesn't exist yet
+func arrayField(op, field string, current interface{}, exists bool) ([]interface{}, error) {
+	if !exists {
+		return nil, nil
+	}
+	arr, ok := current.([]interface{})
+	if !ok {
+		return nil, &ValidationError{Field: field, Message: op + " requires field " + field + " to be an array"}
+	}
+	// Copy, so the original document keeps its array
+	return append([]interface{}(nil), arr...), nil
+}
+
+// sliceOf returns v as a slice of values, or false if it isn't a slice
+func sliceOf(v interface{}) ([]interface{}, bool) {
+	if values, ok := v.([]interface{}); ok {
+		return values, true
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values, true
+}
+
+// pushModifiers holds the values and modifiers of a $push or $addToSet
+type pushModifiers struct {
+	values   []interface{}
+	position int  // Index to insert at; negative counts from the end
+	atEnd    bool // No $position: append
+	slice    int  // Elements to keep: first n, or last -n if negative
+	hasSlice bool
+}
+
+// parsePush reads the value of a $push or $addToSet: a single value, or
+// {$each: [...]} with the modifiers allowed for op
+func parsePush(op, field string, spec interface{}) (*pushModifiers, error) {
+	mods := &pushModifiers{values: []interface{}{spec}, atEnd: true}
+	specMap, ok := spec.(map[string]interface{})
+	if !ok {
+		return mods, nil
+	}
+	each, hasEach := specMap["$each"]
+	if !hasEach {
+		return mods, nil
+	}
+
+	invalid := func(message string) error {
+		return &ValidationError{Field: field, Message: op + " on field " + field + ": " + message}
+	}
+	values, ok := sliceOf(each)
+	if !ok {
+		return nil, invalid("$each requires an array")
+	}
+	mods.values = values
+
+	for modifier, arg := range specMap {
+		switch {
+		case modifier == "$each":
+		case modifier == "$slice" && op == "$push":
+			n, ok := toInt64(arg)
+			if !ok {
+				return nil, invalid("$slice requires an integer")
+			}
+			mods.slice, mods.hasSlice = int(n), true
+		case modifier == "$position" && op == "$push":
+			n, ok := toInt64(arg)
+			if !ok {
+				return nil, invalid("$position requires an integer")
+			}
+			mods.position, mods.atEnd = int(n), false
+		default:
+			return nil, invalid("unsupported modifier " + modifier)
+		}
+	}
+	return mods, nil
+}
+
+// pushValues applies a $push to the current value of a field: the values
+// are inserted at $position (appended by default), then the array is cut
+// down to $slice elements
+func pushValues(field string, current interface{}, exists bool, spec interface{}) ([]interface{}, error) {
+	arr, err := arrayField("$push", field, current, exists)
+	if err != nil {
+		return nil, err
+	}
+	mods, err := parsePush("$push", field, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := len(arr)
+	if !mods.atEnd {
+		pos = mods.position
+		if pos < 0 {
+			pos += len(arr)
+		}
+		pos = clamp(pos, 0, len(arr))
+	}
+	result := make([]interface{}, 0, len(arr)+len(mods.values))
+	result = append(result, arr[:pos]...)
+	result = append(result, mods.values...)
+	result = append(result, arr[pos:]...)
+
+	if mods.hasSlice {
+		if mods.slice >= 0 {
+			result = result[:clamp(mods.slice, 0, len(result))]
+		} else {
+			result = result[len(result)-clamp(-mods.slice, 0, len(result)):]
+		}
+	}
+	return result, nil
+}
+
+// addToSetValues applies an $addToSet to the current value of a field,
+// appending each value not already in the array
+func addToSetValues(field string, current interface{}, exists bool, spec interface{}) ([]interface{}, error) {
+	arr, err := arrayField("$addToSet", field, current, exists)
+	if err != nil {
+		return nil, err
+	}
+	mods, err := parsePush("$addToSet", field, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if arr == nil {
+		arr = make([]interface{}, 0, len(mods.values))
+	}
+	for _, value := range mods.values {
+		found := false
+		for _, elem := range arr {
+			if elementEqual(elem, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			arr = append(arr, value)
+		}
+	}
+	return arr, nil
+}
+
+// pullValues applies a $pull to an array, removing the elements matching
+// cond: an equality value, an operator expression such as {$gte: 5}, or
+// conditions on the fields of embedded documents such as {qty: {$lt: 1}}
+func pullValues(arr []interface{}, cond interface{}) ([]interface{}, error) {
+	matches, err := pullMatcher(cond)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0, len(arr))
+	for _, elem := range arr {
+		removed, err := matches(elem)
+		if err != nil {
+			return nil, err
+		}
+		if !removed {
+			result = append(result, elem)
+		}
+	}
+	return result, nil
+}
+
+// pullMatcher returns the test for the elements a $pull condition removes
+func pullMatcher(cond interface{}) (func(elem interface{}) (bool, error), error) {
+	condMap, isMap := cond.(map[string]interface{})
+	if isMap && !isOperatorExpression(condMap) {
+		// Conditions on the fields of embedded documents
+		q := query.NewQuery(condMap)
+		return func(elem interface{}) (bool, error) {
+			switch e := elem.(type) {
+			case map[string]interface{}:
+				return q.Matches(document.NewDocumentFromMap(e))
+			case *document.Document:
+				return q.Matches(e)
+			}
+			return false, nil
+		}, nil
+	}
+
+	// An equality value or operator expression, tested on the element
+	q := query.NewQuery(map[string]interface{}{"v": cond})
+	return func(elem interface{}) (bool, error) {
+		return q.Matches(document.NewDocumentFromMap(map[string]interface{}{"v": elem}))
+	}, nil
+}
+
+// isOperatorExpression reports whether a condition holds only field
+// operators, such as {$gt: 1, $lt: 5}
+func isOperatorExpression(cond map[string]interface{}) bool {
+	if len(cond) == 0 {
+		return false
+	}
+	for key := range cond {
+		if !strings.HasPrefix(key, "$") || key == "$and" || key == "$or" || key == "$nor" {
+			return false
+		}
+	}
+	return true
+}
+
+// elementEqual reports whether two array elements are equal: numbers by
+// value, anything else (including documents and arrays) by content
+func elementEqual(a, b interface{}) bool {
+	if aNum, ok := toFloat64(a); ok {
+		bNum, ok := toFloat64(b)
+		return ok && aNum == bNum
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// clamp limits n to [low, high]
+func clamp(n, low, high int) int {
+	if n < low {
+		return low
+	}
+	if n > high {
+		return high
+	}
+	return n
+}
@@ -234,17 +234,23 @@ func TestCreateCompoundIndexBackground(t *testing.T) {
 
 buildComplete:
 	// Verify index is usable
-	// Note: BTree doesn't support duplicate keys, so compound index size = unique combinations
-	// 100 customers x 3 statuses = 300 unique combinations
+	// Every document is indexed, though only 300 combinations are distinct
 	indexes := coll.ListIndexes()
 	for _, idx := range indexes {
 		if idx["name"] == "customer_status_1" {
 			size := idx["size"].(int)
-			if size != 300 {
-				t.Errorf("Expected compound index size 300 (unique combinations), got %d", size)
+			if size != 1000 {
+				t.Errorf("Expected compound index size 1000, got %d", size)
 			}
 		}
 	}
+	results, err := coll.Find(map[string]interface{}{"customer": "customer7", "status": "processing"})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(results) != 4 {
+		t.Errorf("Expected 4 orders of customer7 processing, got %d", len(results))
+	}
 }
 
 func TestBackgroundIndexProgressTracking(t *testing.T) {
@@ -427,20 +433,23 @@ func TestBackgroundIndexWithConcurrentUpdates(t *testing.T) {
 		t.Errorf("Expected state 'ready', got '%s'", progress["state"])
 	}
 
-	// Verify index contains unique priority values
-	// Note: BTree doesn't support duplicate keys
-	// After updates: priority values are 10, 11, 12 (10 + i%3) plus original 0-4 (i%5)
-	// Total unique values depends on which documents were updated
+	// Verify index holds each document once, under its current priority
 	indexes := coll.ListIndexes()
 	for _, idx := range indexes {
 		if idx["name"] == "priority_1" {
 			size := idx["size"].(int)
-			// We expect a small number of unique priority values
-			if size < 3 || size > 10 {
-				t.Errorf("Expected index size between 3-10 (unique priorities), got %d", size)
+			if size != 500 {
+				t.Errorf("Expected index size 500, got %d", size)
 			}
 		}
 	}
+	results, err := coll.Find(map[string]interface{}{"priority": int64(10)})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(results) != 34 {
+		t.Errorf("Expected 34 tasks with priority 10, got %d", len(results))
+	}
 }
 
 func TestBackgroundIndexSmallCollection(t *testing.T) {
@@ -624,3 +633,94 @@ func TestMultipleBackgroundIndexes(t *testing.T) {
 		}
 	}
 }
+
+func TestListIndexesHealth(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("users")
+	for i := 0; i < 50; i++ {
+		coll.InsertOne(map[string]interface{}{"name": fmt.Sprintf("user%d", i), "age": int64(20 + i)})
+	}
+	coll.CreateIndex("age", false)
+	coll.CreateIndex("name", false)
+	coll.CreateTextIndex([]string{"name"})
+	coll.Find(map[string]interface{}{"age": int64(25)})
+
+	indexes := make(map[string]map[string]interface{})
+	for _, info := range coll.ListIndexes() {
+		indexes[info["name"].(string)] = info
+		if info["build_state"] != "ready" {
+			t.Errorf("Expected index %s ready, got %v", info["name"], info["build_state"])
+		}
+	}
+	if size, _ := indexes["age_1"]["size_bytes"].(int64); size <= 0 {
+		t.Errorf("Expected a size for age_1, got %v", indexes["age_1"]["size_bytes"])
+	}
+	if _, ok := indexes["age_1"]["last_used"].(time.Time); !ok {
+		t.Errorf("Expected age_1 to report when it was last used, got %v", indexes["age_1"])
+	}
+	if _, ok := indexes["name_1"]["last_used"]; ok {
+		t.Error("Expected no last use for an unused index")
+	}
+
+	coll.indexes["name_1"].FailBuild("disk full")
+	for _, info := range coll.ListIndexes() {
+		if info["name"] == "name_1" && (info["build_state"] != "failed" || info["build_error"] != "disk full") {
+			t.Errorf("Expected name_1 to report its failed build, got %v", info)
+		}
+	}
+}
+
+func TestFailedIndexBuildIsDropped(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("users")
+	coll.InsertOne(map[string]interface{}{"_id": "u1", "email": "a@example.com", "team": "x"})
+	coll.InsertOne(map[string]interface{}{"_id": "u2", "email": "a@example.com", "team": "x"})
+
+	if err := coll.CreateIndex("email", true); err == nil {
+		t.Fatal("Expected unique index build over duplicates to fail")
+	}
+	if err := coll.CreateCompoundIndex([]string{"email", "team"}, true); err == nil {
+		t.Fatal("Expected unique compound index build over duplicates to fail")
+	}
+	for _, info := range coll.ListIndexes() {
+		if info["name"] == "email_1" || info["name"] == "email_team_1" {
+			t.Errorf("Expected failed index %s not to be listed, got %v", info["name"], info)
+		}
+	}
+
+	// Queries and writes don't go through the half-built index
+	results, err := coll.Find(map[string]interface{}{"email": "a@example.com"})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected both documents with a@example.com, got %d", len(results))
+	}
+	if _, err := coll.InsertOne(map[string]interface{}{"_id": "u3", "email": "a@example.com", "team": "x"}); err != nil {
+		t.Errorf("Expected insert to succeed without the failed unique index: %v", err)
+	}
+	if results, _ := coll.Find(map[string]interface{}{"email": "a@example.com", "team": "x"}); len(results) != 3 {
+		t.Errorf("Expected 3 documents with a@example.com in team x, got %d", len(results))
+	}
+
+	// Once the data is fixed the index can be created
+	if _, err := coll.DeleteMany(map[string]interface{}{"_id": map[string]interface{}{"$in": []interface{}{"u2", "u3"}}}); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if err := coll.CreateIndex("email", true); err != nil {
+		t.Fatalf("Failed to create index after fixing duplicates: %v", err)
+	}
+	if results, _ := coll.Find(map[string]interface{}{"email": "a@example.com"}); len(results) != 1 {
+		t.Errorf("Expected 1 document through the new index, got %d", len(results))
+	}
+}
@@ -2,49 +2,182 @@ package database
 
 import (
 	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/mnohosten/laura-db/pkg/backup"
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/encryption"
 	"github.com/mnohosten/laura-db/pkg/index"
 )
 
-// Backup creates a backup of the entire database
-func (db *Database) Backup() (*backup.BackupFormat, error) {
+// CreateBackup creates a backup of the entire database.
+//
+// The backup is taken online from a read snapshot (see StartReadSnapshot):
+// writes continue while documents are copied, and every collection is
+// copied as it was when the snapshot started, just after the snapshot_start
+// time recorded in the backup metadata. The WAL
+// position at snapshot_start is recorded as wal_lsn, where
+// replication.RestoreFromArchive starts replaying archived WAL; replaying
+// the oplog from snapshot_start (see replication.RestoreToTime) brings a
+// restored database forward from there.
+func (db *Database) CreateBackup() (*backup.BackupFormat, error) {
 	db.mu.RLock()
-	defer db.mu.RUnlock()
-
 	if !db.isOpen {
+		db.mu.RUnlock()
 		return nil, fmt.Errorf("database is closed")
 	}
 
 	// Create backup format
 	backupFormat := backup.NewBackupFormat(db.name)
+	backupFormat.Metadata["wal_lsn"] = strconv.FormatUint(db.storage.WAL().CurrentLSN(), 10)
+	db.mu.RUnlock()
 
-	// Backup each collection
+	// snapshot_start is taken first so that replaying the oplog from it
+	// misses nothing. The snapshot waits for writes in progress, which may
+	// need db.mu.
+	backupFormat.Metadata["snapshot_start"] = time.Now().Format(time.RFC3339Nano)
+	snap := db.StartReadSnapshot()
+	defer snap.Release()
+
+	// Collections created since the snapshot started are backed up empty
+	db.mu.RLock()
+	collections := make(map[string]*Collection, len(db.collections))
 	for name, coll := range db.collections {
-		if err := db.backupCollection(backupFormat, name, coll); err != nil {
+		collections[name] = coll
+	}
+	db.mu.RUnlock()
+
+	names := make([]string, 0, len(collections))
+	for name := range collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Backup each collection
+	for _, name := range names {
+		if err := db.backupCollection(backupFormat, snap, name, collections[name]); err != nil {
 			return nil, fmt.Errorf("failed to backup collection %s: %w", name, err)
 		}
 	}
 
+	backupFormat.Metadata["snapshot_end"] = time.Now().Format(time.RFC3339Nano)
+
 	return backupFormat, nil
 }
 
-// backupCollection backs up a single collection
-func (db *Database) backupCollection(backupFormat *backup.BackupFormat, name string, coll *Collection) error {
+// Backup writes an online backup of the database to w as JSON. The backup
+// is a consistent snapshot of the database taken without blocking writes
+// (see CreateBackup).
+func (db *Database) Backup(w io.Writer) error {
+	backupFormat, err := db.CreateBackup()
+	if err != nil {
+		return err
+	}
+
+	return backup.NewBackuper(false).BackupToWriter(w, backupFormat)
+}
+
+// BackupToEncrypted writes an online backup of the database to w as an
+// encrypted, authenticated archive (see encryption.NewArchiveWriter).
+// config must use AlgorithmAES256GCM; with AlgorithmNone the backup is
+// written as plain JSON like Backup.
+func (db *Database) BackupToEncrypted(w io.Writer, config *encryption.Config) error {
+	archive, err := encryption.NewArchiveWriter(w, config)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt backup: %w", err)
+	}
+	if err := db.Backup(archive); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// Restore rebuilds a database in dataDir from a backup read from r.
+// The returned database is open; the caller is responsible for closing it.
+func Restore(r io.Reader, dataDir string) (*Database, error) {
+	backupFormat, err := backup.NewRestorer().RestoreFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return restoreInto(backupFormat, dataDir)
+}
+
+// RestoreEncrypted rebuilds a database in dataDir from a backup written by
+// BackupToEncrypted with the same key or password. The whole archive is
+// authenticated before the database is created: a wrong key or a modified
+// or truncated archive fails with an error wrapping encryption.ErrArchiveAuth.
+func RestoreEncrypted(r io.Reader, dataDir string, config *encryption.Config) (*Database, error) {
+	archive, err := encryption.NewArchiveReader(r, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt backup: %w", err)
+	}
+
+	backupFormat, err := backup.NewRestorer().RestoreFromReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(io.Discard, archive); err != nil {
+		return nil, fmt.Errorf("failed to decrypt backup: %w", err)
+	}
+
+	return restoreInto(backupFormat, dataDir)
+}
+
+// restoreInto opens a database in dataDir and restores a backup into it
+func restoreInto(backupFormat *backup.BackupFormat, dataDir string) (*Database, error) {
+	db, err := Open(DefaultConfig(dataDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Restore(backupFormat, backup.DefaultRestoreOptions()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// backupCollection backs up a single collection as of snap, including the
+// documents of its cold tier
+func (db *Database) backupCollection(backupFormat *backup.BackupFormat, snap *Snapshot, name string, coll *Collection) error {
 	coll.mu.RLock()
-	defer coll.mu.RUnlock()
+	indexes := coll.indexBackups()
+	coll.mu.RUnlock()
 
-	// Get all documents from document store
-	docs, err := coll.getAllDocuments()
+	docs, cold, err := snap.collectionDocuments(coll)
 	if err != nil {
-		return fmt.Errorf("failed to get documents: %w", err)
+		return err
 	}
+	sortByID(docs)
+	sortByID(cold)
+
+	// Add collection to backup
+	backupFormat.AddCollection(name, docs, indexes)
 
-	// Get all indexes
+	return backupFormat.AddArchivedDocuments(name, cold)
+}
+
+// sortByID sorts documents by _id, so backups of the same data are the same
+func sortByID(docs []*document.Document) {
+	sort.Slice(docs, func(i, j int) bool {
+		a, _ := docs[i].Get("_id")
+		b, _ := docs[j].Get("_id")
+		return fmt.Sprintf("%v", a) < fmt.Sprintf("%v", b)
+	})
+}
+
+// indexBackups returns the definitions of all indexes of the collection
+// Must be called with c.mu held
+func (c *Collection) indexBackups() []backup.IndexBackup {
 	indexes := make([]backup.IndexBackup, 0)
 
 	// Backup B+ tree indexes (skip default _id_ index)
-	for name, idx := range coll.indexes {
+	for name, idx := range c.indexes {
 		if name == "_id_" {
 			continue // Skip default index, it will be recreated automatically
 		}
@@ -53,13 +186,14 @@ func (db *Database) backupCollection(backupFormat *backup.BackupFormat, name str
 	}
 
 	// Backup text indexes
-	for name, textIdx := range coll.textIndexes {
+	for name, textIdx := range c.textIndexes {
 		indexBackup := backup.NewTextIndexBackup(name, textIdx.FieldPaths())
+		indexBackup.Config["weights"] = textIdx.Weights()
 		indexes = append(indexes, indexBackup)
 	}
 
 	// Backup geo indexes
-	for name, geoIdx := range coll.geoIndexes {
+	for name, geoIdx := range c.geoIndexes {
 		var geoType string
 		switch geoIdx.Type() {
 		case index.IndexType2D:
@@ -72,16 +206,13 @@ func (db *Database) backupCollection(backupFormat *backup.BackupFormat, name str
 	}
 
 	// Backup TTL indexes
-	for name, ttlIdx := range coll.ttlIndexes {
+	for name, ttlIdx := range c.ttlIndexes {
 		ttlSeconds := ttlIdx.TTLSeconds()
 		indexBackup := backup.NewTTLIndexBackup(name, ttlIdx.FieldPath(), ttlSeconds)
 		indexes = append(indexes, indexBackup)
 	}
 
-	// Add collection to backup
-	backupFormat.AddCollection(name, docs, indexes)
-
-	return nil
+	return indexes
 }
 
 // Restore restores the database from a backup
@@ -92,6 +223,9 @@ func (db *Database) Restore(backupFormat *backup.BackupFormat, options *backup.R
 	if !db.isOpen {
 		return fmt.Errorf("database is closed")
 	}
+	if err := db.checkWritable(); err != nil {
+		return err
+	}
 
 	if options == nil {
 		options = backup.DefaultRestoreOptions()
@@ -114,9 +248,14 @@ func (db *Database) Restore(backupFormat *backup.BackupFormat, options *backup.R
 
 // restoreCollection restores a single collection from backup
 func (db *Database) restoreCollection(collBackup backup.CollectionBackup, options *backup.RestoreOptions) error {
-	// Drop existing collection if requested
+	// Drop existing collection if requested, with its cold tier
 	if options.DropExisting {
-		if _, exists := db.collections[collBackup.Name]; exists {
+		if existing, exists := db.collections[collBackup.Name]; exists {
+			if archive := existing.archive.Swap(nil); archive != nil {
+				if err := archive.destroy(); err != nil {
+					return fmt.Errorf("failed to remove archive: %w", err)
+				}
+			}
 			delete(db.collections, collBackup.Name)
 		}
 	}
@@ -126,9 +265,7 @@ func (db *Database) restoreCollection(collBackup backup.CollectionBackup, option
 	if existing, exists := db.collections[collBackup.Name]; exists {
 		coll = existing
 	} else {
-		// Create document store for this collection
-		docStore := NewDocumentStore(db.storage.DiskManager(), 1000) // 1000 documents cache
-		coll = NewCollection(collBackup.Name, db.txnMgr, docStore)
+		coll = db.newCollection(collBackup.Name)
 		db.collections[collBackup.Name] = coll
 	}
 
@@ -149,6 +286,17 @@ func (db *Database) restoreCollection(collBackup backup.CollectionBackup, option
 		}
 	}
 
+	// Recreate the cold tier
+	if len(collBackup.Archived) > 0 {
+		db.mu.Unlock()
+		err := coll.restoreArchive(collBackup.Archived)
+		db.mu.Lock()
+
+		if err != nil {
+			return fmt.Errorf("failed to restore archive: %w", err)
+		}
+	}
+
 	// Restore indexes if not skipped
 	if !options.SkipIndexes {
 		if err := db.restoreIndexes(coll, collBackup.Indexes); err != nil {
@@ -159,6 +307,31 @@ func (db *Database) restoreCollection(collBackup backup.CollectionBackup, option
 	return nil
 }
 
+// restoreArchive writes documents from a backup to the cold tier
+func (c *Collection) restoreArchive(docBackups []backup.DocumentBackup) error {
+	docs := make([]*document.Document, 0, len(docBackups))
+	for _, docBackup := range docBackups {
+		docMap, err := backup.ConvertDocumentFromBackup(docBackup)
+		if err != nil {
+			return fmt.Errorf("failed to convert document: %w", err)
+		}
+		docs = append(docs, document.NewDocumentFromMap(docMap))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	archive, err := c.coldArchive()
+	if err != nil {
+		return err
+	}
+	if err := archive.add(docs); err != nil {
+		return err
+	}
+	c.queryCache.Clear()
+	return nil
+}
+
 // restoreIndexes restores indexes for a collection
 func (db *Database) restoreIndexes(coll *Collection, indexes []backup.IndexBackup) error {
 	for _, idxBackup := range indexes {
@@ -192,7 +365,18 @@ func (db *Database) createIndexFromBackup(coll *Collection, idxBackup backup.Ind
 		}
 
 	case "text":
-		return coll.CreateTextIndex(idxBackup.FieldPaths)
+		// Weights round-trip through JSON as numbers
+		weights := make(map[string]int)
+		if raw, ok := idxBackup.Config["weights"].(map[string]interface{}); ok {
+			for field, w := range raw {
+				if f, ok := w.(float64); ok {
+					weights[field] = int(f)
+				}
+			}
+		} else if raw, ok := idxBackup.Config["weights"].(map[string]int); ok {
+			weights = raw
+		}
+		return coll.CreateTextIndexWithWeights(idxBackup.FieldPaths, weights)
 
 	case "geo":
 		if len(idxBackup.FieldPaths) != 1 {
@@ -212,7 +396,7 @@ func (db *Database) createIndexFromBackup(coll *Collection, idxBackup backup.Ind
 		if idxBackup.TTLDuration == nil {
 			return fmt.Errorf("ttl index must have ttl_duration")
 		}
-		return coll.CreateTTLIndex(idxBackup.FieldPaths[0], *idxBackup.TTLDuration)
+		return coll.CreateTTLIndex(idxBackup.FieldPaths[0], time.Duration(*idxBackup.TTLDuration)*time.Second)
 
 	default:
 		return fmt.Errorf("unsupported index type: %s", idxBackup.Type)
@@ -221,7 +405,7 @@ func (db *Database) createIndexFromBackup(coll *Collection, idxBackup backup.Ind
 
 // BackupToFile creates a backup file at the specified path
 func (db *Database) BackupToFile(path string, pretty bool) error {
-	backupFormat, err := db.Backup()
+	backupFormat, err := db.CreateBackup()
 	if err != nil {
 		return err
 	}
@@ -1,6 +1,9 @@
 package database
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -8,6 +11,7 @@ import (
 
 	"github.com/mnohosten/laura-db/pkg/backup"
 	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/encryption"
 )
 
 func TestDatabase_Backup(t *testing.T) {
@@ -44,7 +48,7 @@ func TestDatabase_Backup(t *testing.T) {
 	}
 
 	// Create backup
-	backupFormat, err := db.Backup()
+	backupFormat, err := db.CreateBackup()
 	if err != nil {
 		t.Fatalf("Failed to create backup: %v", err)
 	}
@@ -243,7 +247,7 @@ func TestDatabase_BackupWithMultipleCollections(t *testing.T) {
 	}
 
 	// Backup
-	backupFormat, err := db.Backup()
+	backupFormat, err := db.CreateBackup()
 	if err != nil {
 		t.Fatalf("Failed to backup: %v", err)
 	}
@@ -387,6 +391,41 @@ func TestDatabase_RestoreWithTextIndex(t *testing.T) {
 	}
 }
 
+func TestDatabase_BackupRestoresTextIndexWeights(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("articles")
+	coll.CreateTextIndexWithWeights([]string{"title", "body"}, map[string]int{"title": 5})
+	coll.InsertOne(map[string]interface{}{"_id": "doc1", "title": "Hello", "body": "World"})
+
+	var buf bytes.Buffer
+	if err := db.Backup(&buf); err != nil {
+		t.Fatalf("Failed to back up: %v", err)
+	}
+
+	restored, err := Restore(&buf, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	defer restored.Close()
+
+	restoredColl := restored.Collection("articles")
+	restoredColl.mu.RLock()
+	defer restoredColl.mu.RUnlock()
+	for _, textIdx := range restoredColl.textIndexes {
+		weights := textIdx.Weights()
+		if weights["title"] != 5 || weights["body"] != 1 {
+			t.Errorf("Expected weights title=5 body=1, got %v", weights)
+		}
+		return
+	}
+	t.Error("Expected restored text index")
+}
+
 func TestDatabase_RestoreWithGeoIndex(t *testing.T) {
 	dataDir := t.TempDir()
 	db, err := Open(DefaultConfig(dataDir))
@@ -575,3 +614,277 @@ func TestDatabase_RestoreSkipIndexes(t *testing.T) {
 		t.Errorf("Expected 1 index (only _id_), got %d", indexCount)
 	}
 }
+
+func TestDatabase_BackupToAndRestore(t *testing.T) {
+	db1, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db1.Close()
+
+	coll := db1.Collection("users")
+	for i := 0; i < 10; i++ {
+		if _, err := coll.InsertOne(map[string]interface{}{"name": "user", "n": int64(i)}); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+	if err := coll.CreateIndex("n", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := db1.Backup(&buf); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	db2, err := Restore(&buf, t.TempDir())
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	defer db2.Close()
+
+	count, err := db2.Collection("users").Count(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 10 {
+		t.Errorf("Expected 10 documents, got %d", count)
+	}
+	if len(db2.Collection("users").ListIndexes()) != 2 {
+		t.Errorf("Expected 2 indexes after restore, got %d", len(db2.Collection("users").ListIndexes()))
+	}
+}
+
+func TestDatabase_EncryptedBackup(t *testing.T) {
+	db1, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db1.Close()
+
+	coll := db1.Collection("users")
+	for i := 0; i < 10; i++ {
+		if _, err := coll.InsertOne(map[string]interface{}{"name": "secret-name", "n": int64(i)}); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+
+	config, _ := encryption.NewConfigFromPassword("backup-password", encryption.AlgorithmAES256GCM)
+	var buf bytes.Buffer
+	if err := db1.BackupToEncrypted(&buf, config); err != nil {
+		t.Fatalf("BackupToEncrypted failed: %v", err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("secret-name")) {
+		t.Fatal("Encrypted backup contains plaintext documents")
+	}
+	archive := buf.Bytes()
+
+	// A wrong password is rejected before anything is restored
+	wrong, _ := encryption.NewConfigFromPassword("other-password", encryption.AlgorithmAES256GCM)
+	wrongDir := t.TempDir()
+	if _, err := RestoreEncrypted(bytes.NewReader(archive), wrongDir, wrong); !errors.Is(err, encryption.ErrArchiveAuth) {
+		t.Fatalf("Expected ErrArchiveAuth with the wrong password, got %v", err)
+	}
+	if entries, _ := os.ReadDir(wrongDir); len(entries) != 0 {
+		t.Errorf("Expected no database files after a failed restore, got %d entries", len(entries))
+	}
+
+	// The plain restore path refuses encrypted backups
+	if _, err := Restore(bytes.NewReader(archive), t.TempDir()); err == nil {
+		t.Error("Expected Restore to fail on an encrypted backup")
+	}
+
+	same, _ := encryption.NewConfigFromPassword("backup-password", encryption.AlgorithmAES256GCM)
+	db2, err := RestoreEncrypted(bytes.NewReader(archive), t.TempDir(), same)
+	if err != nil {
+		t.Fatalf("RestoreEncrypted failed: %v", err)
+	}
+	defer db2.Close()
+
+	count, err := db2.Collection("users").Count(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 10 {
+		t.Errorf("Expected 10 documents, got %d", count)
+	}
+}
+
+func TestDatabase_BackupDoesNotBlockWrites(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("events")
+	for i := 0; i < 200; i++ {
+		coll.InsertOne(map[string]interface{}{"n": int64(i)})
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < 200; i++ {
+			if _, err := coll.InsertOne(map[string]interface{}{"n": int64(1000 + i)}); err != nil {
+				done <- err
+				return
+			}
+			if i%2 == 0 {
+				coll.DeleteOne(map[string]interface{}{"n": int64(i)})
+			}
+		}
+		done <- nil
+	}()
+
+	backupFormat, err := db.CreateBackup()
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Concurrent write failed: %v", err)
+	}
+
+	if _, ok := backupFormat.Metadata["snapshot_start"].(string); !ok {
+		t.Error("Expected snapshot_start in backup metadata")
+	}
+	if _, ok := backupFormat.Metadata["snapshot_end"].(string); !ok {
+		t.Error("Expected snapshot_end in backup metadata")
+	}
+	if n := len(backupFormat.Collections[0].Documents); n < 100 {
+		t.Errorf("Expected at least 100 documents in backup, got %d", n)
+	}
+}
+
+func TestDatabase_BackupIsConsistentUnderWrites(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	for _, name := range []string{"checking", "savings"} {
+		for i := 0; i < 50; i++ {
+			db.Collection(name).InsertOne(map[string]interface{}{"_id": fmt.Sprintf("a%d", i), "balance": int64(100)})
+		}
+	}
+
+	// Move money between the collections while the backup runs; the total
+	// stays 10000
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				done <- nil
+				return
+			default:
+			}
+			from, to := "checking", "savings"
+			if i%2 == 1 {
+				from, to = to, from
+			}
+			filter := map[string]interface{}{"_id": fmt.Sprintf("a%d", i%50)}
+			session := db.StartSession()
+			if err := session.UpdateOne(from, filter, map[string]interface{}{"$inc": map[string]interface{}{"balance": int64(-10)}}); err != nil {
+				done <- err
+				return
+			}
+			if err := session.UpdateOne(to, filter, map[string]interface{}{"$inc": map[string]interface{}{"balance": int64(10)}}); err != nil {
+				done <- err
+				return
+			}
+			if err := session.CommitTransaction(); err != nil && !errors.Is(err, ErrConflict) {
+				done <- err
+				return
+			}
+		}
+	}()
+
+	for round := 0; round < 5; round++ {
+		var buf bytes.Buffer
+		if err := db.Backup(&buf); err != nil {
+			t.Fatalf("Backup failed: %v", err)
+		}
+
+		restored, err := Restore(&buf, t.TempDir())
+		if err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		count := 0
+		var total int64
+		for _, name := range []string{"checking", "savings"} {
+			docs, err := restored.Collection(name).Find(map[string]interface{}{})
+			if err != nil {
+				t.Fatalf("Find failed: %v", err)
+			}
+			count += len(docs)
+			for _, doc := range docs {
+				balance, _ := doc.Get("balance")
+				switch v := balance.(type) {
+				case int64:
+					total += v
+				case float64:
+					total += int64(v)
+				}
+			}
+		}
+		restored.Close()
+
+		if count != 100 || total != 10000 {
+			t.Errorf("Round %d: expected 100 accounts totalling 10000, got %d totalling %d", round, count, total)
+		}
+	}
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatalf("Concurrent transfer failed: %v", err)
+	}
+}
+
+func TestDatabase_BackupRestoresColdTier(t *testing.T) {
+	db1, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db1.Close()
+
+	now := time.Now()
+	coll := db1.Collection("readings")
+	if err := coll.CreateIndex("seq", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	insertReadings(t, coll, now, 10)
+	if moved, err := coll.ArchiveOlderThan("ts", now.Add(-5*time.Hour-time.Minute)); err != nil || moved != 5 {
+		t.Fatalf("Expected 5 archived documents, got %d (%v)", moved, err)
+	}
+
+	var buf bytes.Buffer
+	if err := db1.Backup(&buf); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	db2, err := Restore(&buf, t.TempDir())
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	defer db2.Close()
+
+	restored := db2.Collection("readings")
+	count, err := restored.Count(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 10 {
+		t.Errorf("Expected 10 documents, got %d", count)
+	}
+	if restored.docStore.Count() != 5 {
+		t.Errorf("Expected 5 hot documents, got %d", restored.docStore.Count())
+	}
+	if stats := restored.ArchiveStats(); stats["documents"] != 5 {
+		t.Errorf("Expected 5 archived documents, got %v", stats)
+	}
+	if _, err := restored.FindOne(map[string]interface{}{"seq": int64(1)}); err != nil {
+		t.Errorf("Expected archived document after restore: %v", err)
+	}
+}
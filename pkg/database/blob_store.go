@@ -0,0 +1,194 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+const (
+	// blobRefField marks a field whose value is stored in chunks
+	blobRefField = "$blob"
+
+	// blobChunkSize is the size of the chunks a binary field is split into,
+	// small enough for a chunk document to fit in a page
+	blobChunkSize = 3072
+)
+
+// blobStore keeps large binary fields of a collection's documents in a side
+// collection of chunks, GridFS style. The stored document holds a reference
+// {"$blob": <id>, "length": <bytes>} in place of the field, and reads put the
+// field back together from its chunks.
+type blobStore struct {
+	threshold int
+	chunks    *DocumentStore
+}
+
+// blob is a binary field split off a document. Blobs read from references
+// have no data.
+type blob struct {
+	id     string
+	length int
+	data   []byte
+}
+
+// newBlobStore creates a blob store for top-level binary fields of at least
+// threshold bytes
+func newBlobStore(threshold int, chunks *DocumentStore) *blobStore {
+	return &blobStore{
+		threshold: threshold,
+		chunks:    chunks,
+	}
+}
+
+// split returns the document to store, with large binary fields replaced by
+// references, and the blobs to write. doc itself is not modified.
+func (bs *blobStore) split(doc *document.Document) (*document.Document, []blob) {
+	if bs == nil {
+		return doc, nil
+	}
+
+	var stored *document.Document
+	var blobs []blob
+	for _, key := range doc.Keys() {
+		value, _ := doc.Get(key)
+		data, ok := value.([]byte)
+		if !ok || len(data) < bs.threshold {
+			continue
+		}
+		if stored == nil {
+			stored = doc.Clone()
+		}
+		id := document.NewObjectID().Hex()
+		stored.Set(key, map[string]interface{}{
+			blobRefField: id,
+			"length":     int64(len(data)),
+		})
+		blobs = append(blobs, blob{id: id, length: len(data), data: data})
+	}
+
+	if stored == nil {
+		return doc, nil
+	}
+	return stored, blobs
+}
+
+// write stores the chunks of blobs. On failure, the chunks written so far
+// are removed.
+func (bs *blobStore) write(blobs []blob) error {
+	for i, b := range blobs {
+		for n := 0; n*blobChunkSize < len(b.data); n++ {
+			end := (n + 1) * blobChunkSize
+			if end > len(b.data) {
+				end = len(b.data)
+			}
+			chunkID := blobChunkID(b.id, n)
+			chunk := document.NewDocumentFromMap(map[string]interface{}{
+				"_id":  chunkID,
+				"blob": b.id,
+				"n":    int64(n),
+				"data": b.data[n*blobChunkSize : end],
+			})
+			if err := bs.chunks.Insert(chunkID, chunk); err != nil {
+				bs.remove(blobs[:i+1])
+				return fmt.Errorf("failed to store chunk %d of blob %s: %w", n, b.id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// join replaces the blob references of a stored document by the data of
+// their chunks
+func (bs *blobStore) join(stored *document.Document) error {
+	if bs == nil {
+		return nil
+	}
+
+	for _, key := range stored.Keys() {
+		value, _ := stored.Get(key)
+		id, length, ok := blobRef(value)
+		if !ok {
+			continue
+		}
+		data := make([]byte, 0, length)
+		for n := 0; len(data) < length; n++ {
+			chunk, err := bs.chunks.Get(blobChunkID(id, n))
+			if err != nil {
+				return fmt.Errorf("failed to read chunk %d of blob %s: %w", n, id, err)
+			}
+			part, _ := chunk.Get("data")
+			bytes, ok := part.([]byte)
+			if !ok || len(bytes) == 0 {
+				return fmt.Errorf("chunk %d of blob %s is corrupt", n, id)
+			}
+			data = append(data, bytes...)
+		}
+		stored.Set(key, data)
+	}
+	return nil
+}
+
+// refs returns the blobs referenced by a stored document, without their data
+func (bs *blobStore) refs(stored *document.Document) []blob {
+	if bs == nil {
+		return nil
+	}
+
+	var blobs []blob
+	for _, key := range stored.Keys() {
+		value, _ := stored.Get(key)
+		if id, length, ok := blobRef(value); ok {
+			blobs = append(blobs, blob{id: id, length: length})
+		}
+	}
+	return blobs
+}
+
+// remove deletes the chunks of blobs. Missing chunks are ignored.
+func (bs *blobStore) remove(blobs []blob) {
+	if bs == nil {
+		return
+	}
+
+	for _, b := range blobs {
+		for n := 0; n*blobChunkSize < b.length; n++ {
+			bs.chunks.Delete(blobChunkID(b.id, n))
+		}
+	}
+}
+
+// blobRef returns the blob a field value references, if any
+func blobRef(value interface{}) (string, int, bool) {
+	var ref map[string]interface{}
+	switch v := value.(type) {
+	case *document.Document:
+		ref = v.ToMap()
+	case map[string]interface{}:
+		ref = v
+	default:
+		return "", 0, false
+	}
+	if len(ref) != 2 {
+		return "", 0, false
+	}
+
+	id, ok := ref[blobRefField].(string)
+	if !ok {
+		return "", 0, false
+	}
+	switch length := ref["length"].(type) {
+	case int64:
+		return id, int(length), true
+	case int32:
+		return id, int(length), true
+	case int:
+		return id, length, true
+	}
+	return "", 0, false
+}
+
+// blobChunkID returns the _id of the nth chunk of a blob
+func blobChunkID(id string, n int) string {
+	return fmt.Sprintf("%s:%d", id, n)
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMaxDocumentSize(t *testing.T) {
+	dir := "./test_db_max_doc_size"
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	config := DefaultConfig(dir)
+	config.MaxDocumentSize = 512
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("notes")
+	coll.CreateIndex("title", true)
+
+	_, err = coll.InsertOne(map[string]interface{}{"title": "big", "body": strings.Repeat("x", 1000)})
+	if !errors.Is(err, ErrDocumentTooLarge) {
+		t.Fatalf("Expected ErrDocumentTooLarge, got %v", err)
+	}
+	if count, _ := coll.Count(map[string]interface{}{}); count != 0 {
+		t.Errorf("Expected no documents, got %d", count)
+	}
+
+	if _, err := coll.InsertOne(map[string]interface{}{"title": "small", "body": "short"}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+
+	grow := map[string]interface{}{"$set": map[string]interface{}{"body": strings.Repeat("y", 1000)}}
+	if err := coll.UpdateOne(map[string]interface{}{"title": "small"}, grow); !errors.Is(err, ErrDocumentTooLarge) {
+		t.Fatalf("Expected ErrDocumentTooLarge from UpdateOne, got %v", err)
+	}
+	if _, err := coll.UpdateMany(map[string]interface{}{}, grow); !errors.Is(err, ErrDocumentTooLarge) {
+		t.Fatalf("Expected ErrDocumentTooLarge from UpdateMany, got %v", err)
+	}
+
+	// The rejected updates left the document and its index entries alone
+	doc, err := coll.FindOne(map[string]interface{}{"title": "small"})
+	if err != nil {
+		t.Fatalf("FindOne failed: %v", err)
+	}
+	if body, _ := doc.Get("body"); body != "short" {
+		t.Errorf("Expected body 'short', got %v", body)
+	}
+}
+
+func TestBlobStorage(t *testing.T) {
+	dir := "./test_db_blobs"
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	config := DefaultConfig(dir)
+	config.BlobThreshold = 1024
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	files := db.Collection("files")
+	image := bytes.Repeat([]byte("0123456789"), 2000)
+
+	_, err = files.InsertOne(map[string]interface{}{"name": "image.png", "data": image})
+	if err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	chunks := files.docStore.blobs.chunks
+	if chunks.Count() != 7 {
+		t.Errorf("Expected 7 chunks, got %d", chunks.Count())
+	}
+
+	doc, err := files.FindOne(map[string]interface{}{"name": "image.png"})
+	if err != nil {
+		t.Fatalf("FindOne failed: %v", err)
+	}
+	if data, _ := doc.Get("data"); !bytes.Equal(data.([]byte), image) {
+		t.Error("Expected blob to be reassembled")
+	}
+
+	// Updates rewrite the chunks
+	thumbnail := bytes.Repeat([]byte("ab"), 1000)
+	if err := files.UpdateOne(map[string]interface{}{"name": "image.png"}, map[string]interface{}{
+		"$set": map[string]interface{}{"data": thumbnail},
+	}); err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+	if chunks.Count() != 1 {
+		t.Errorf("Expected 1 chunk after update, got %d", chunks.Count())
+	}
+	doc, _ = files.FindOne(map[string]interface{}{"name": "image.png"})
+	if data, _ := doc.Get("data"); !bytes.Equal(data.([]byte), thumbnail) {
+		t.Error("Expected updated blob")
+	}
+
+	// Small binary fields stay in the document
+	if _, err := files.InsertOne(map[string]interface{}{"name": "icon", "data": []byte("tiny")}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	if chunks.Count() != 1 {
+		t.Errorf("Expected small binary field to stay inline, got %d chunks", chunks.Count())
+	}
+
+	if err := files.DeleteOne(map[string]interface{}{"name": "image.png"}); err != nil {
+		t.Fatalf("DeleteOne failed: %v", err)
+	}
+	if chunks.Count() != 0 {
+		t.Errorf("Expected chunks to be deleted with the document, got %d", chunks.Count())
+	}
+}
+
+func TestBlobStorageDisabled(t *testing.T) {
+	dir := "./test_db_blobs_disabled"
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	files := db.Collection("files")
+	_, err = files.InsertOne(map[string]interface{}{"data": make([]byte, 10000)})
+	if !errors.Is(err, ErrDocumentTooLarge) {
+		t.Errorf("Expected ErrDocumentTooLarge, got %v", err)
+	}
+}
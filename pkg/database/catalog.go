@@ -45,14 +45,14 @@ const (
 
 // CollectionCatalog manages the central registry of all collections
 type CollectionCatalog struct {
-	diskMgr     *storage.DiskManager
+	diskMgr     storage.PageStore
 	header      *CatalogHeader
 	collections map[string]*CollectionDirectoryEntry // name -> entry
 	mu          sync.RWMutex
 }
 
 // NewCollectionCatalog creates a new collection catalog
-func NewCollectionCatalog(diskMgr *storage.DiskManager) (*CollectionCatalog, error) {
+func NewCollectionCatalog(diskMgr storage.PageStore) (*CollectionCatalog, error) {
 	catalog := &CollectionCatalog{
 		diskMgr:     diskMgr,
 		collections: make(map[string]*CollectionDirectoryEntry),
@@ -175,7 +175,7 @@ func (c *CollectionCatalog) load() error {
 // saveUnlocked writes the catalog to disk (caller must hold lock)
 func (c *CollectionCatalog) saveUnlocked() error {
 	// Create page
-	page := storage.NewPage(CatalogPageID, storage.PageTypeData)
+	page := storage.NewPageOfSize(CatalogPageID, storage.PageTypeData, c.diskMgr.PageSize())
 	offset := storage.PageHeaderSize
 
 	// Write header
@@ -200,7 +200,7 @@ func (c *CollectionCatalog) saveUnlocked() error {
 	for _, entry := range c.collections {
 		// Check if we have enough space
 		entrySize := 12 + len(entry.Name)
-		if offset+entrySize > storage.PageSize {
+		if offset+entrySize > len(page.Data) {
 			return fmt.Errorf("catalog page full")
 		}
 
@@ -278,7 +278,7 @@ func (c *CollectionCatalog) GetCollection(name string) (*CollectionDirectoryEntr
 
 	entry, exists := c.collections[name]
 	if !exists {
-		return nil, fmt.Errorf("collection %s not found", name)
+		return nil, kindOf(ErrCollectionNotFound, "collection %s not found", name)
 	}
 
 	return entry, nil
@@ -304,7 +304,7 @@ func (c *CollectionCatalog) DropCollection(name string) error {
 	// Check if collection exists
 	entry, exists := c.collections[name]
 	if !exists {
-		return fmt.Errorf("collection %s not found", name)
+		return kindOf(ErrCollectionNotFound, "collection %s not found", name)
 	}
 
 	// Mark as inactive (soft delete)
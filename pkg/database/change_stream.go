@@ -0,0 +1,296 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mnohosten/laura-db/pkg/changestream"
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/oplog"
+	"github.com/mnohosten/laura-db/pkg/storage"
+)
+
+// changeOplogFile is the oplog in the data directory that feeds change streams
+const changeOplogFile = "changes.oplog"
+
+// changeLog records collection writes in an oplog for change streams. The
+// oplog is opened by the first Watch call; until then writes are not logged,
+// unless WAL archiving or durability logs them to the WAL.
+type changeLog struct {
+	path       string
+	mu         sync.RWMutex
+	oplog      *oplog.Oplog
+	streams    map[*changestream.ChangeStream]struct{}
+	closed     bool
+	wal        *storage.WAL
+	archiving  atomic.Bool  // Writes are also logged to the WAL for archiving
+	durability atomic.Int32 // Rank of the default Durability of writes
+	walWrites  atomic.Int64 // Writes in progress that asked to be logged to the WAL
+}
+
+// newChangeLog creates a change log that keeps its oplog at path and logs
+// writes for archiving to wal
+func newChangeLog(path string, wal *storage.WAL) *changeLog {
+	return &changeLog{
+		path:    path,
+		streams: make(map[*changestream.ChangeStream]struct{}),
+		wal:     wal,
+	}
+}
+
+// open opens the oplog if it isn't open yet
+func (l *changeLog) open() (*oplog.Oplog, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil, fmt.Errorf("database is closed")
+	}
+	if l.oplog == nil {
+		log, err := oplog.NewOplog(l.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open change oplog: %w", err)
+		}
+		l.oplog = log
+	}
+	return l.oplog, nil
+}
+
+// active reports whether writes are being logged
+func (l *changeLog) active() bool {
+	if l == nil {
+		return false
+	}
+	if l.toWAL() {
+		return true
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.oplog != nil
+}
+
+// toWAL reports whether writes are logged to the WAL, for archiving or
+// durability
+func (l *changeLog) toWAL() bool {
+	return l.archiving.Load() || l.durability.Load() > 0 || l.walWrites.Load() > 0
+}
+
+// append logs an entry if change streams, WAL archiving or durability need
+// it, syncing the WAL if the default durability is DurabilitySynced. The
+// write it describes has already been applied, so a failure to log it is
+// not reported.
+func (l *changeLog) append(entry *oplog.OplogEntry) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.oplog != nil {
+		l.oplog.Append(entry)
+	}
+	if l.toWAL() {
+		l.logToWAL(entry, l.durability.Load() == DurabilitySynced.rank())
+	}
+}
+
+// register tracks an active stream until it is closed
+func (l *changeLog) register(cs *changestream.ChangeStream) error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return fmt.Errorf("database is closed")
+	}
+	l.streams[cs] = struct{}{}
+	l.mu.Unlock()
+
+	go func() {
+		<-cs.Done()
+		l.mu.Lock()
+		delete(l.streams, cs)
+		l.mu.Unlock()
+	}()
+	return nil
+}
+
+// close closes the active streams and the oplog
+func (l *changeLog) close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	streams := make([]*changestream.ChangeStream, 0, len(l.streams))
+	for cs := range l.streams {
+		streams = append(streams, cs)
+	}
+	log := l.oplog
+	l.oplog = nil
+	l.mu.Unlock()
+
+	for _, cs := range streams {
+		cs.Close()
+	}
+	if log != nil {
+		if err := log.Close(); err != nil {
+			return fmt.Errorf("failed to close change oplog: %w", err)
+		}
+	}
+	return nil
+}
+
+// Watch opens a change stream on the collection. Writes are recorded from
+// the first Watch call on; the stream is closed when the database closes.
+func (c *Collection) Watch(opts *changestream.ChangeStreamOptions) (*changestream.ChangeStream, error) {
+	if c.changes == nil {
+		return nil, fmt.Errorf("collection %s does not belong to a database", c.name)
+	}
+
+	log, err := c.changes.open()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	name := c.name
+	c.mu.RUnlock()
+
+	cs := changestream.NewChangeStreamWithDB(log, c.db, c.database, name, opts)
+	if err := c.changes.start(cs); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// Watch opens a change stream on all collections of the database, in the
+// order their writes happened. Writes are recorded from the first Watch
+// call on; the stream is closed when the database closes.
+func (db *Database) Watch(opts *changestream.ChangeStreamOptions) (*changestream.ChangeStream, error) {
+	log, err := db.changes.open()
+	if err != nil {
+		return nil, err
+	}
+
+	cs := changestream.NewChangeStreamWithDB(log, db, db.name, "", opts)
+	if err := db.changes.start(cs); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// start registers a new stream and starts it, closing it on failure
+func (l *changeLog) start(cs *changestream.ChangeStream) error {
+	if err := l.register(cs); err != nil {
+		cs.Close()
+		return err
+	}
+	if err := cs.Start(); err != nil {
+		cs.Close()
+		return err
+	}
+	return nil
+}
+
+// ChangeOplog returns the oplog that records the database's writes for
+// change streams, opening it if needed. Change streams spanning several
+// databases, such as those over a sharded collection, read it directly.
+func (db *Database) ChangeOplog() (*oplog.Oplog, error) {
+	return db.changes.open()
+}
+
+// LookupDocument returns the current version of the document with the
+// given _id, for change streams with FullDocumentUpdateLookup. It returns
+// nil if the document or its collection no longer exists.
+func (db *Database) LookupDocument(database, collection string, id interface{}) (map[string]interface{}, error) {
+	if database != db.name {
+		return nil, nil
+	}
+	db.mu.RLock()
+	coll, exists := db.collections[collection]
+	db.mu.RUnlock()
+	if !exists {
+		return nil, nil
+	}
+
+	doc, err := coll.FindOne(map[string]interface{}{"_id": id})
+	if errors.Is(err, ErrDocumentNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.ToMap(), nil
+}
+
+// logInsert records an inserted document for change streams
+func (c *Collection) logInsert(doc *document.Document) {
+	c.writes.Add(1)
+	if !c.changes.active() {
+		return
+	}
+	c.changes.append(oplog.CreateInsertEntry(c.database, c.name, doc.ToMap()))
+}
+
+// logUpdate records an update of the document with the given _id
+func (c *Collection) logUpdate(docID interface{}, update map[string]interface{}) {
+	c.writes.Add(1)
+	if !c.changes.active() {
+		return
+	}
+	entry := oplog.CreateUpdateEntry(c.database, c.name, map[string]interface{}{"_id": docID}, update)
+	entry.DocID = docID
+	c.changes.append(entry)
+}
+
+// logDelete records a deleted document
+func (c *Collection) logDelete(docID interface{}) {
+	c.writes.Add(1)
+	if !c.changes.active() {
+		return
+	}
+	entry := oplog.CreateDeleteEntry(c.database, c.name, map[string]interface{}{"_id": docID})
+	entry.DocID = docID
+	c.changes.append(entry)
+}
+
+// logTruncate records that all documents of the collection were deleted
+func (c *Collection) logTruncate() {
+	c.writes.Add(1)
+	if !c.changes.active() {
+		return
+	}
+	c.changes.append(oplog.CreateTruncateEntry(c.database, c.name))
+}
+
+// logDrop records that the collection was dropped
+func (c *Collection) logDrop() {
+	if !c.changes.active() {
+		return
+	}
+	c.changes.append(oplog.CreateCollectionEntry(c.database, c.name, false))
+}
+
+// logRename records that the collection is renamed to newName
+func (c *Collection) logRename(newName string) {
+	if !c.changes.active() {
+		return
+	}
+	c.changes.append(oplog.CreateRenameEntry(c.database, c.name, newName))
+}
+
+// withoutID returns a copy of doc without its _id field
+func withoutID(doc map[string]interface{}) map[string]interface{} {
+	fields := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		if k != "_id" {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// changeLogPath returns where a database in dataDir keeps its change oplog
+func changeLogPath(dataDir string) string {
+	return filepath.Join(dataDir, changeOplogFile)
+}
@@ -0,0 +1,530 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/changestream"
+)
+
+func watchOptions() *changestream.ChangeStreamOptions {
+	opts := changestream.DefaultChangeStreamOptions()
+	opts.MaxAwaitTime = 10 * time.Millisecond
+	return opts
+}
+
+func nextEvent(t *testing.T, cs *changestream.ChangeStream) *changestream.ChangeEvent {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	event, err := cs.Next(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get change event: %v", err)
+	}
+	return event
+}
+
+func TestCollectionWatch(t *testing.T) {
+	dir := "./test_db_watch"
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	cs, err := users.Watch(watchOptions())
+	if err != nil {
+		t.Fatalf("Failed to watch collection: %v", err)
+	}
+	defer cs.Close()
+
+	// Writes to other collections are not part of the stream
+	if _, err := db.Collection("orders").InsertOne(map[string]interface{}{"item": "book"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	if _, err := users.InsertOne(map[string]interface{}{"_id": "u1", "name": "Alice", "age": int64(30)}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := users.UpdateOne(map[string]interface{}{"_id": "u1"}, map[string]interface{}{
+		"$set": map[string]interface{}{"age": int64(31)},
+	}); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if err := users.DeleteOne(map[string]interface{}{"_id": "u1"}); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+
+	event := nextEvent(t, cs)
+	if event.OperationType != changestream.OperationTypeInsert {
+		t.Fatalf("Expected insert event, got %s", event.OperationType)
+	}
+	if event.Collection != "users" {
+		t.Errorf("Expected collection users, got %s", event.Collection)
+	}
+	if event.FullDocument["name"] != "Alice" {
+		t.Errorf("Expected full document with name Alice, got %v", event.FullDocument)
+	}
+
+	event = nextEvent(t, cs)
+	if event.OperationType != changestream.OperationTypeUpdate {
+		t.Fatalf("Expected update event, got %s", event.OperationType)
+	}
+	if event.DocumentKey["_id"] != "u1" {
+		t.Errorf("Expected document key u1, got %v", event.DocumentKey["_id"])
+	}
+	if event.UpdateDescription == nil || event.UpdateDescription.UpdatedFields["age"] != int64(31) {
+		t.Errorf("Expected updated field age=31, got %+v", event.UpdateDescription)
+	}
+
+	event = nextEvent(t, cs)
+	if event.OperationType != changestream.OperationTypeDelete {
+		t.Fatalf("Expected delete event, got %s", event.OperationType)
+	}
+	if event.DocumentKey["_id"] != "u1" {
+		t.Errorf("Expected document key u1, got %v", event.DocumentKey["_id"])
+	}
+}
+
+func TestCollectionWatchSessionCommit(t *testing.T) {
+	dir := "./test_db_watch_session"
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	if _, err := users.InsertOne(map[string]interface{}{"_id": "u1", "name": "Alice"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	cs, err := users.Watch(watchOptions())
+	if err != nil {
+		t.Fatalf("Failed to watch collection: %v", err)
+	}
+	defer cs.Close()
+
+	err = db.WithTransaction(func(session *Session) error {
+		return session.UpdateOne("users", map[string]interface{}{"_id": "u1"}, map[string]interface{}{
+			"$set": map[string]interface{}{"name": "Alicia"},
+		})
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %v", err)
+	}
+
+	event := nextEvent(t, cs)
+	if event.OperationType != changestream.OperationTypeUpdate {
+		t.Fatalf("Expected update event, got %s", event.OperationType)
+	}
+	if event.UpdateDescription.UpdatedFields["name"] != "Alicia" {
+		t.Errorf("Expected updated field name=Alicia, got %v", event.UpdateDescription.UpdatedFields)
+	}
+}
+
+func TestDatabaseCloseClosesChangeStreams(t *testing.T) {
+	dir := "./test_db_watch_close"
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	users := db.Collection("users")
+	cs, err := users.Watch(watchOptions())
+	if err != nil {
+		t.Fatalf("Failed to watch collection: %v", err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+
+	select {
+	case <-cs.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected change stream to be closed with the database")
+	}
+	if _, ok := <-cs.Events(); ok {
+		t.Error("Expected events channel to be closed")
+	}
+
+	if _, err := users.Watch(watchOptions()); err == nil {
+		t.Error("Expected Watch to fail after the database is closed")
+	}
+}
+
+func TestCollectionWatchDropAndRename(t *testing.T) {
+	dir := "./test_db_watch_drop"
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	if _, err := users.InsertOne(map[string]interface{}{"_id": "u1"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	db.Collection("orders")
+
+	renamed, err := users.Watch(watchOptions())
+	if err != nil {
+		t.Fatalf("Failed to watch collection: %v", err)
+	}
+	defer renamed.Close()
+	dropped, err := db.Collection("orders").Watch(watchOptions())
+	if err != nil {
+		t.Fatalf("Failed to watch collection: %v", err)
+	}
+	defer dropped.Close()
+
+	if err := db.RenameCollection("users", "people"); err != nil {
+		t.Fatalf("Failed to rename collection: %v", err)
+	}
+	if err := db.DropCollection("orders"); err != nil {
+		t.Fatalf("Failed to drop collection: %v", err)
+	}
+
+	event := nextEvent(t, renamed)
+	if event.OperationType != changestream.OperationTypeRename || event.To == nil || event.To.Collection != "people" {
+		t.Errorf("Expected rename to people, got %+v", event)
+	}
+	if event := nextEvent(t, renamed); event.OperationType != changestream.OperationTypeInvalidate {
+		t.Errorf("Expected invalidate, got %s", event.OperationType)
+	}
+
+	if event := nextEvent(t, dropped); event.OperationType != changestream.OperationTypeDropCollection {
+		t.Errorf("Expected drop, got %s", event.OperationType)
+	}
+	if event := nextEvent(t, dropped); event.OperationType != changestream.OperationTypeInvalidate {
+		t.Errorf("Expected invalidate, got %s", event.OperationType)
+	}
+}
+
+func TestDatabaseWatch(t *testing.T) {
+	dir := "./test_db_watch_database"
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	cs, err := db.Watch(watchOptions())
+	if err != nil {
+		t.Fatalf("Failed to watch database: %v", err)
+	}
+	defer cs.Close()
+
+	collections := []string{"users", "orders", "products"}
+	for i, name := range collections {
+		if _, err := db.Collection(name).InsertOne(map[string]interface{}{"_id": int64(i)}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+	if err := db.DropCollection("orders"); err != nil {
+		t.Fatalf("Failed to drop collection: %v", err)
+	}
+
+	for i, name := range collections {
+		event := nextEvent(t, cs)
+		if event.OperationType != changestream.OperationTypeInsert || event.Collection != name {
+			t.Errorf("Expected insert into %s, got %s into %s", name, event.OperationType, event.Collection)
+		}
+		if event.FullDocument["_id"] != int64(i) {
+			t.Errorf("Expected document %d, got %v", i, event.FullDocument["_id"])
+		}
+	}
+	if event := nextEvent(t, cs); event.OperationType != changestream.OperationTypeDropCollection || event.Collection != "orders" {
+		t.Errorf("Expected drop of orders, got %s of %s", event.OperationType, event.Collection)
+	}
+}
+
+func TestWatchUpdateLookup(t *testing.T) {
+	dir := "./test_db_watch_lookup"
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	opts := watchOptions()
+	opts.FullDocument = changestream.FullDocumentUpdateLookup
+	cs, err := users.Watch(opts)
+	if err != nil {
+		t.Fatalf("Failed to watch collection: %v", err)
+	}
+	defer cs.Close()
+
+	if _, err := users.InsertOne(map[string]interface{}{"_id": "u1", "name": "Alice", "age": int64(30)}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := users.UpdateOne(map[string]interface{}{"_id": "u1"}, map[string]interface{}{
+		"$set": map[string]interface{}{"age": int64(31)},
+	}); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+
+	nextEvent(t, cs) // Insert
+	event := nextEvent(t, cs)
+	if event.OperationType != changestream.OperationTypeUpdate {
+		t.Fatalf("Expected update event, got %s", event.OperationType)
+	}
+	if event.FullDocument["name"] != "Alice" || event.FullDocument["age"] != int64(31) {
+		t.Errorf("Expected post-update document, got %v", event.FullDocument)
+	}
+	if event.DocumentDeleted {
+		t.Error("Expected document not to be marked deleted")
+	}
+
+	// A document deleted before its update is read can't be looked up
+	log, err := db.ChangeOplog()
+	if err != nil {
+		t.Fatalf("Failed to get change oplog: %v", err)
+	}
+	opts.ResumeAfter = &changestream.ResumeToken{OpID: log.GetCurrentID()}
+	if err := users.UpdateOne(map[string]interface{}{"_id": "u1"}, map[string]interface{}{
+		"$set": map[string]interface{}{"age": int64(32)},
+	}); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if err := users.DeleteOne(map[string]interface{}{"_id": "u1"}); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+
+	resumed, err := users.Watch(opts)
+	if err != nil {
+		t.Fatalf("Failed to watch collection: %v", err)
+	}
+	defer resumed.Close()
+
+	event = nextEvent(t, resumed)
+	if event.OperationType != changestream.OperationTypeUpdate {
+		t.Fatalf("Expected update event, got %s", event.OperationType)
+	}
+	if event.FullDocument != nil || !event.DocumentDeleted {
+		t.Errorf("Expected deleted document without full document, got %v (deleted %v)", event.FullDocument, event.DocumentDeleted)
+	}
+
+	// Without updateLookup, update events carry only the delta
+	plain, err := users.Watch(watchOptions())
+	if err != nil {
+		t.Fatalf("Failed to watch collection: %v", err)
+	}
+	defer plain.Close()
+	if _, err := users.InsertOne(map[string]interface{}{"_id": "u2", "name": "Bob"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := users.UpdateOne(map[string]interface{}{"_id": "u2"}, map[string]interface{}{
+		"$set": map[string]interface{}{"name": "Robert"},
+	}); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	nextEvent(t, plain) // Insert
+	if event := nextEvent(t, plain); event.FullDocument != nil || event.DocumentDeleted {
+		t.Errorf("Expected update event without full document, got %+v", event)
+	}
+}
+
+func TestWatchMatchLookedUpDocument(t *testing.T) {
+	dir := "./test_db_watch_match"
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	orders := db.Collection("orders")
+	opts := watchOptions()
+	opts.FullDocument = changestream.FullDocumentUpdateLookup
+	opts.Pipeline = []map[string]interface{}{
+		{"$match": map[string]interface{}{"fullDocument.shipping.status": "shipped"}},
+	}
+	cs, err := orders.Watch(opts)
+	if err != nil {
+		t.Fatalf("Failed to watch collection: %v", err)
+	}
+	defer cs.Close()
+
+	if _, err := orders.InsertOne(map[string]interface{}{
+		"_id": "o1", "item": "book", "shipping": map[string]interface{}{"status": "pending"},
+	}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := orders.UpdateOne(map[string]interface{}{"_id": "o1"}, map[string]interface{}{
+		"$set": map[string]interface{}{"shipping": map[string]interface{}{"status": "shipped"}},
+	}); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	// This update only touches another field; the looked-up document matches
+	if err := orders.UpdateOne(map[string]interface{}{"_id": "o1"}, map[string]interface{}{
+		"$set": map[string]interface{}{"item": "novel"},
+	}); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		event := nextEvent(t, cs)
+		if event.OperationType != changestream.OperationTypeUpdate || event.DocumentKey["_id"] != "o1" {
+			t.Errorf("Expected update of o1, got %s of %v", event.OperationType, event.DocumentKey)
+		}
+	}
+	if event, _ := cs.TryNext(); event != nil {
+		t.Errorf("Expected the pending insert to be filtered out, got %s", event.OperationType)
+	}
+}
+
+func TestWatchResumeAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	tokenPath := filepath.Join(t.TempDir(), "indexer.token")
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	events := db.Collection("events")
+	cs, err := events.Watch(watchOptions())
+	if err != nil {
+		t.Fatalf("Failed to watch collection: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		events.InsertOne(map[string]interface{}{"seq": int64(i)})
+	}
+	for i := 0; i < 5; i++ {
+		if seq := nextEvent(t, cs).FullDocument["seq"]; seq != int64(i) {
+			t.Fatalf("Expected event %d, got %v", i, seq)
+		}
+	}
+
+	f, err := os.Create(tokenPath)
+	if err != nil {
+		t.Fatalf("Failed to create token file: %v", err)
+	}
+	if err := cs.SaveResumeToken(f); err != nil {
+		t.Fatalf("Failed to save resume token: %v", err)
+	}
+	f.Close()
+	db.Close()
+
+	// Writes after the restart are recorded before anyone watches again
+	db, err = Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+	events = db.Collection("events")
+	for i := 5; i < 8; i++ {
+		events.InsertOne(map[string]interface{}{"seq": int64(i)})
+	}
+
+	f, err = os.Open(tokenPath)
+	if err != nil {
+		t.Fatalf("Failed to open token file: %v", err)
+	}
+	token, err := changestream.LoadResumeToken(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("Failed to load resume token: %v", err)
+	}
+
+	opts := watchOptions()
+	opts.ResumeAfter = token
+	cs, err = events.Watch(opts)
+	if err != nil {
+		t.Fatalf("Failed to resume watching: %v", err)
+	}
+	for i := 5; i < 8; i++ {
+		if seq := nextEvent(t, cs).FullDocument["seq"]; seq != int64(i) {
+			t.Fatalf("Expected event %d after resuming, got %v", i, seq)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if event, _ := cs.TryNext(); event != nil {
+		t.Errorf("Expected no more events, got %+v", event)
+	}
+}
+
+func TestResumeTokenStore(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	events := db.Collection("events")
+	store := NewResumeTokenStore(db.Collection("consumers"))
+	if token, err := store.Load("indexer"); err != nil || token != nil {
+		t.Fatalf("Expected no saved token, got %v, %v", token, err)
+	}
+
+	cs, err := events.Watch(watchOptions())
+	if err != nil {
+		t.Fatalf("Failed to watch collection: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		events.InsertOne(map[string]interface{}{"seq": int64(i)})
+	}
+
+	// Only the events the consumer received count, not those buffered
+	nextEvent(t, cs)
+	nextEvent(t, cs)
+	if err := store.SaveStream("indexer", cs); err != nil {
+		t.Fatalf("Failed to save resume token: %v", err)
+	}
+	cs.Close()
+
+	token, err := store.Load("indexer")
+	if err != nil || token == nil {
+		t.Fatalf("Expected a saved token, got %v, %v", token, err)
+	}
+	opts := watchOptions()
+	opts.ResumeAfter = token
+	cs, err = events.Watch(opts)
+	if err != nil {
+		t.Fatalf("Failed to resume watching: %v", err)
+	}
+	defer cs.Close()
+	for i := 2; i < 4; i++ {
+		if seq := nextEvent(t, cs).FullDocument["seq"]; seq != int64(i) {
+			t.Fatalf("Expected event %d after resuming, got %v", i, seq)
+		}
+	}
+
+	// Saving again replaces the position
+	if err := store.SaveStream("indexer", cs); err != nil {
+		t.Fatalf("Failed to save resume token: %v", err)
+	}
+	if count, _ := db.Collection("consumers").Count(nil); count != 1 {
+		t.Errorf("Expected one document per consumer, got %d", count)
+	}
+	if token, _ := store.Load("indexer"); token == nil || !reflect.DeepEqual(*token, cs.AcknowledgedToken()) {
+		t.Errorf("Expected the latest token %+v, got %+v", cs.AcknowledgedToken(), token)
+	}
+
+	if err := store.Delete("indexer"); err != nil {
+		t.Fatalf("Failed to delete resume token: %v", err)
+	}
+	if token, _ := store.Load("indexer"); token != nil {
+		t.Errorf("Expected the token to be deleted, got %+v", token)
+	}
+}
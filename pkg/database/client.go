@@ -0,0 +1,151 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxDatabaseNameLength is the longest database name a client accepts
+const maxDatabaseNameLength = 64
+
+// Client gives access to the logical databases of an instance. Each
+// database is kept in its own subdirectory of the client's data directory
+// with its own storage, so collections of the same name in two databases
+// are distinct, and the database name is the namespace of its collections
+// in oplog entries and change events.
+type Client struct {
+	config    Config // Template for the configuration of each database
+	databases map[string]*Database
+	mu        sync.Mutex
+	closed    bool
+}
+
+// NewClient creates a client for the databases in config.DataDir. The rest
+// of the configuration applies to every database the client opens.
+func NewClient(config *Config) (*Client, error) {
+	if err := os.MkdirAll(config.DataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	return &Client{
+		config:    *config,
+		databases: make(map[string]*Database),
+	}, nil
+}
+
+// ValidateDatabaseName checks that a name can be used for a database
+func ValidateDatabaseName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: name is empty", ErrInvalidDatabaseName)
+	}
+	if len(name) > maxDatabaseNameLength {
+		return fmt.Errorf("%w: %q is longer than %d bytes", ErrInvalidDatabaseName, name, maxDatabaseNameLength)
+	}
+	if i := strings.IndexAny(name, "/\\. \"$*<>:|?\x00"); i >= 0 {
+		return fmt.Errorf("%w: %q contains %q", ErrInvalidDatabaseName, name, name[i])
+	}
+	return nil
+}
+
+// Database returns the named database, creating it if it doesn't exist
+func (c *Client) Database(name string) (*Database, error) {
+	if err := ValidateDatabaseName(name); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+	if db, exists := c.databases[name]; exists && db.IsOpen() {
+		return db, nil
+	}
+
+	config := c.config
+	config.DataDir = filepath.Join(c.config.DataDir, name)
+	config.Name = name
+	db, err := Open(&config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", name, err)
+	}
+	c.databases[name] = db
+	return db, nil
+}
+
+// ListDatabases returns the names of the databases, including those on disk
+// the client hasn't opened yet, in order
+func (c *Client) ListDatabases() ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+
+	entries, err := os.ReadDir(c.config.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data directory: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() && ValidateDatabaseName(entry.Name()) == nil {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DropDatabase closes the named database and deletes its data
+func (c *Client) DropDatabase(name string) error {
+	if err := ValidateDatabaseName(name); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrClientClosed
+	}
+
+	if db, exists := c.databases[name]; exists {
+		delete(c.databases, name)
+		if err := db.Close(); err != nil {
+			return fmt.Errorf("failed to close database %s: %w", name, err)
+		}
+	}
+
+	if err := os.RemoveAll(filepath.Join(c.config.DataDir, name)); err != nil {
+		return fmt.Errorf("failed to remove database %s: %w", name, err)
+	}
+	return nil
+}
+
+// Close closes every database the client opened
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	var errs []error
+	for name, db := range c.databases {
+		if err := db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("database %s: %w", name, err))
+		}
+	}
+	c.databases = nil
+	return errors.Join(errs...)
+}
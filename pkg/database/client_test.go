@@ -0,0 +1,157 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClientDatabasesAreDistinct(t *testing.T) {
+	client, err := NewClient(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	sales, err := client.Database("sales")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	hr, err := client.Database("hr")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if sales.Name() != "sales" {
+		t.Errorf("Expected name sales, got %s", sales.Name())
+	}
+
+	if _, err := sales.Collection("orders").InsertOne(map[string]interface{}{"item": "book"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	count, err := hr.Collection("orders").Count(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected hr.orders to be empty, got %d documents", count)
+	}
+
+	// The same name returns the same database
+	again, _ := client.Database("sales")
+	if again != sales {
+		t.Error("Expected the open database to be reused")
+	}
+}
+
+func TestClientChangeEventNamespace(t *testing.T) {
+	client, err := NewClient(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	sales, _ := client.Database("sales")
+	cs, err := sales.Collection("orders").Watch(watchOptions())
+	if err != nil {
+		t.Fatalf("Failed to watch collection: %v", err)
+	}
+	defer cs.Close()
+
+	if _, err := sales.Collection("orders").InsertOne(map[string]interface{}{"item": "book"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	event := nextEvent(t, cs)
+	if event.Database != "sales" || event.Collection != "orders" {
+		t.Errorf("Expected namespace sales.orders, got %s.%s", event.Database, event.Collection)
+	}
+}
+
+func TestClientListAndDropDatabases(t *testing.T) {
+	dir := t.TempDir()
+	client, err := NewClient(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	for _, name := range []string{"sales", "hr"} {
+		db, err := client.Database(name)
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		db.Collection("orders").InsertOne(map[string]interface{}{"item": "book"})
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Failed to close client: %v", err)
+	}
+
+	// Databases on disk are listed before they are opened
+	client, err = NewClient(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	names, err := client.ListDatabases()
+	if err != nil {
+		t.Fatalf("Failed to list databases: %v", err)
+	}
+	if len(names) != 2 || names[0] != "hr" || names[1] != "sales" {
+		t.Errorf("Expected [hr sales], got %v", names)
+	}
+
+	sales, err := client.Database("sales")
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+
+	if err := client.DropDatabase("sales"); err != nil {
+		t.Fatalf("Failed to drop database: %v", err)
+	}
+	if sales.IsOpen() {
+		t.Error("Expected dropped database to be closed")
+	}
+	names, _ = client.ListDatabases()
+	if len(names) != 1 || names[0] != "hr" {
+		t.Errorf("Expected [hr], got %v", names)
+	}
+}
+
+func TestClientInvalidDatabaseName(t *testing.T) {
+	client, err := NewClient(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	for _, name := range []string{"", "a.b", "../x", "a/b", "a b", "$db"} {
+		if _, err := client.Database(name); !errors.Is(err, ErrInvalidDatabaseName) {
+			t.Errorf("Expected ErrInvalidDatabaseName for %q, got %v", name, err)
+		}
+	}
+
+	client.Close()
+	if _, err := client.Database("sales"); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected ErrClientClosed, got %v", err)
+	}
+}
+
+func TestOpenWithName(t *testing.T) {
+	config := DefaultConfig(t.TempDir())
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if db.Name() != DefaultDatabaseName {
+		t.Errorf("Expected name %s, got %s", DefaultDatabaseName, db.Name())
+	}
+	db.Close()
+
+	config.Name = "sales"
+	db, err = Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if db.Name() != "sales" {
+		t.Errorf("Expected name sales, got %s", db.Name())
+	}
+}
@@ -2,8 +2,10 @@ package database
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mnohosten/laura-db/pkg/aggregation"
@@ -14,11 +16,14 @@ import (
 	"github.com/mnohosten/laura-db/pkg/index"
 	"github.com/mnohosten/laura-db/pkg/mvcc"
 	"github.com/mnohosten/laura-db/pkg/query"
+	"github.com/mnohosten/laura-db/pkg/storage"
+	"github.com/mnohosten/laura-db/pkg/text"
 )
 
 // Collection represents a collection of documents
 type Collection struct {
 	name        string
+	db          *Database                   // Owning database, nil for standalone collections
 	database    string                      // Database name for audit logging
 	docStore    *DocumentStore              // Disk-based document storage
 	indexes     map[string]*index.Index     // index name -> index
@@ -27,8 +32,23 @@ type Collection struct {
 	ttlIndexes  map[string]*index.TTLIndex  // ttl index name -> ttl index
 	txnMgr      *mvcc.TransactionManager
 	auditLogger *audit.AuditLogger // Audit logger
+	changes     *changeLog         // Change stream oplog, nil outside a database
 	queryCache  *cache.LRUCache    // Query result cache
+	hooks       hookRegistry       // Hooks registered for writes to this collection
 	mu          sync.RWMutex
+
+	schema     atomic.Pointer[Schema] // Versions documents upgraded on read, nil for none
+	upgrades   map[string]bool        // IDs of documents reads upgraded, to persist
+	upgradesMu sync.Mutex
+
+	archive       atomic.Pointer[coldArchive] // Cold tier, nil until documents are archived
+	tieringPolicy *TieringPolicy              // Moves old documents to the cold tier, nil for none
+
+	maxDocumentSize int // Largest document accepted, in bytes (0 uses the most a page holds)
+
+	stats           atomic.Pointer[collectionStats] // Sampled statistics for cost-based planning, nil until gathered
+	writes          atomic.Int64                    // Documents written, for refreshing stats
+	statsRefreshing atomic.Bool
 }
 
 // NewCollection creates a new collection
@@ -59,139 +79,69 @@ func NewCollection(name string, txnMgr *mvcc.TransactionManager, docStore *Docum
 
 // InsertOne inserts a single document
 func (c *Collection) InsertOne(doc map[string]interface{}) (string, error) {
-	start := time.Now()
+	if err := c.checkWritable(); err != nil {
+		return "", err
+	}
+
+	d := document.NewDocumentFromMap(doc)
+	if c.hasHooks() {
+		return c.insertOneWithHooks(d)
+	}
+	return c.insertOne(d)
+}
+
+// insertOne inserts a document without running hooks
+func (c *Collection) insertOne(d *document.Document) (string, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Create document
-	d := document.NewDocumentFromMap(doc)
+	if err := c.checkQuota(quotaChange{doc: d}); err != nil {
+		return "", err
+	}
+	return c.insertDocument(d)
+}
+
+// insertDocument validates, indexes and stores a single document
+// Must be called with c.mu held
+func (c *Collection) insertDocument(d *document.Document) (string, error) {
+	start := time.Now()
 
 	// Generate _id if not provided
-	var id string
-	if idVal, exists := d.Get("_id"); exists {
-		id = fmt.Sprintf("%v", idVal)
-	} else {
-		objectID := document.NewObjectID()
-		d.Set("_id", objectID)
-		id = objectID.Hex()
-	}
+	id := assignID(d)
+	c.stampSchema(d)
 
 	// Check if document already exists
 	if c.docStore.Exists(id) {
-		err := fmt.Errorf("document with _id %s already exists", id)
+		err := c.duplicateIDError(id)
 		if c.auditLogger != nil {
 			c.auditLogger.LogInsert(c.name, c.database, "", false, 0, time.Since(start), err)
 		}
 		return "", err
 	}
 
-	// Insert into indexes
-	for _, idx := range c.indexes {
-		// Check if document matches partial index filter
-		if !c.matchesPartialIndexFilter(d, idx) {
-			continue // Skip this index if document doesn't match filter
-		}
-
-		if idx.IsCompound() {
-			// Compound index
-			if compositeKey, allFieldsExist := c.extractCompositeKey(d, idx.FieldPaths()); allFieldsExist {
-				if err := idx.Insert(compositeKey, id); err != nil {
-					return "", fmt.Errorf("failed to insert into compound index %s: %w", idx.Name(), err)
-				}
-			} else if !idx.IsUnique() {
-				// For non-unique indexes, we could still insert partial keys,
-				// but for now we skip documents with missing fields
-				continue
-			}
-		} else {
-			// Single-field index
-			fieldValue, exists := d.Get(idx.FieldPath())
-			if !exists && idx.IsUnique() {
-				continue // Skip missing fields for unique indexes
-			}
-			if exists {
-				if err := idx.Insert(fieldValue, id); err != nil {
-					return "", fmt.Errorf("failed to insert into index %s: %w", idx.Name(), err)
-				}
-			}
-		}
-	}
-
-	// Insert into text indexes
-	for _, textIdx := range c.textIndexes {
-		texts := make([]string, 0, len(textIdx.FieldPaths()))
-		for _, fieldPath := range textIdx.FieldPaths() {
-			if fieldValue, exists := d.Get(fieldPath); exists {
-				if str, ok := fieldValue.(string); ok {
-					texts = append(texts, str)
-				}
-			}
-		}
-		if len(texts) > 0 {
-			textIdx.Index(id, texts)
-		}
-	}
-
-	// Insert into geo indexes
-	for _, geoIdx := range c.geoIndexes {
-		if fieldValue, exists := d.Get(geoIdx.FieldPath()); exists {
-			if pointMap, ok := fieldValue.(map[string]interface{}); ok {
-				if point, err := geo.ParseGeoJSONPoint(pointMap); err == nil {
-					geoIdx.Index(id, point)
-				}
-			}
+	if err := c.checkDocument(d); err != nil {
+		if c.auditLogger != nil {
+			c.auditLogger.LogInsert(c.name, c.database, "", false, 0, time.Since(start), err)
 		}
+		return "", err
 	}
 
-	// Insert into TTL indexes
-	for _, ttlIdx := range c.ttlIndexes {
-		if fieldValue, exists := d.Get(ttlIdx.FieldPath()); exists {
-			var timestamp time.Time
-			switch v := fieldValue.(type) {
-			case time.Time:
-				timestamp = v
-			case string:
-				if t, err := time.Parse(time.RFC3339, v); err == nil {
-					timestamp = t
-				}
-			case int64:
-				timestamp = time.Unix(v, 0)
-			}
-			if !timestamp.IsZero() {
-				ttlIdx.Index(id, timestamp)
-			}
-		}
+	// Insert into indexes
+	if err := c.indexDocument(id, d); err != nil {
+		return "", err
 	}
 
 	// Store document to disk
 	if err := c.docStore.Insert(id, d); err != nil {
 		// Rollback index entries on failure
-		for _, idx := range c.indexes {
-			if idx.IsCompound() {
-				if compositeKey, allFieldsExist := c.extractCompositeKey(d, idx.FieldPaths()); allFieldsExist {
-					idx.Delete(compositeKey)
-				}
-			} else {
-				if fieldValue, exists := d.Get(idx.FieldPath()); exists {
-					idx.Delete(fieldValue)
-				}
-			}
-		}
-		for _, textIdx := range c.textIndexes {
-			textIdx.Remove(id)
-		}
-		for _, geoIdx := range c.geoIndexes {
-			geoIdx.Remove(id)
-		}
-		for _, ttlIdx := range c.ttlIndexes {
-			ttlIdx.Remove(id)
-		}
+		c.unindexDocument(id, d)
 
 		if c.auditLogger != nil {
 			c.auditLogger.LogInsert(c.name, c.database, "", false, 0, time.Since(start), err)
 		}
 		return "", fmt.Errorf("failed to store document: %w", err)
 	}
+	c.logInsert(d)
 
 	// Invalidate query cache on write
 	c.queryCache.Clear()
@@ -204,21 +154,6 @@ func (c *Collection) InsertOne(doc map[string]interface{}) (string, error) {
 	return id, nil
 }
 
-// InsertMany inserts multiple documents
-func (c *Collection) InsertMany(docs []map[string]interface{}) ([]string, error) {
-	ids := make([]string, 0, len(docs))
-
-	for _, doc := range docs {
-		id, err := c.InsertOne(doc)
-		if err != nil {
-			return ids, err
-		}
-		ids = append(ids, id)
-	}
-
-	return ids, nil
-}
-
 // FindOne finds a single document matching the filter
 func (c *Collection) FindOne(filter map[string]interface{}) (*document.Document, error) {
 	results, err := c.Find(filter)
@@ -236,17 +171,82 @@ func (c *Collection) FindOne(filter map[string]interface{}) (*document.Document,
 // Find finds all documents matching the filter
 func (c *Collection) Find(filter map[string]interface{}) ([]*document.Document, error) {
 	c.mu.RLock()
-	defer c.mu.RUnlock()
+	results, err := c.readQuery(query.NewQuery(filter))
+	c.mu.RUnlock()
+	c.persistUpgrades()
+	return results, err
+}
 
+// FindWithOptions finds documents with query options. A projection either
+// lists the fields to return, {"name": true, "address.city": true}, or
+// those to leave out, {"password": false}; _id is returned unless excluded.
+// A projection mixing both fails with ErrInvalidProjection. A nil options
+// is Find.
+func (c *Collection) FindWithOptions(filter map[string]interface{}, options *QueryOptions) ([]*document.Document, error) {
+	if options == nil {
+		options = &QueryOptions{}
+	}
+	if err := checkProjection(options.Projection); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	results, err := c.findWithOptions(filter, options)
+	c.mu.RUnlock()
+	c.persistUpgrades()
+	return results, err
+}
+
+// checkProjection returns ErrInvalidProjection if a projection both
+// includes and excludes fields other than _id
+func checkProjection(projection map[string]bool) error {
+	var included, excluded string
+	for field, include := range projection {
+		if field == "_id" {
+			continue
+		}
+		if include {
+			included = field
+		} else {
+			excluded = field
+		}
+	}
+	if included != "" && excluded != "" {
+		return fmt.Errorf("%w: cannot include %s and exclude %s in the same projection", ErrInvalidProjection, included, excluded)
+	}
+	return nil
+}
+
+// optionsQuery builds the query for a filter with query options
+func optionsQuery(filter map[string]interface{}, options *QueryOptions) *query.Query {
 	q := query.NewQuery(filter)
-	return c.executeQuery(q)
+
+	if options.Projection != nil {
+		q.WithProjection(options.Projection)
+	}
+	if options.Meta != nil {
+		q.WithMeta(options.Meta)
+	}
+	if options.Sort != nil {
+		q.WithSort(options.Sort)
+	}
+	if options.Limit > 0 {
+		q.WithLimit(options.Limit)
+	}
+	if options.Skip > 0 {
+		q.WithSkip(options.Skip)
+	}
+	if options.Hint != "" {
+		q.WithHint(options.Hint)
+	}
+	return q
 }
 
-// FindWithOptions finds documents with query options
-func (c *Collection) FindWithOptions(filter map[string]interface{}, options *QueryOptions) ([]*document.Document, error) {
+// findWithOptions finds documents with query options, through the query
+// cache
+// Must be called with c.mu held
+func (c *Collection) findWithOptions(filter map[string]interface{}, options *QueryOptions) ([]*document.Document, error) {
 	start := time.Now()
-	c.mu.RLock()
-	defer c.mu.RUnlock()
 
 	// Generate cache key from query parameters
 	var sort []interface{}
@@ -267,6 +267,12 @@ func (c *Collection) FindWithOptions(filter map[string]interface{}, options *Que
 	if options.Projection != nil {
 		projection = options.Projection
 	}
+	if len(options.Meta) > 0 {
+		sort = append(sort, map[string]interface{}{"meta": options.Meta})
+	}
+	if options.Hint != "" {
+		sort = append(sort, map[string]interface{}{"hint": options.Hint})
+	}
 	if options.Skip > 0 {
 		skip = options.Skip
 	}
@@ -285,22 +291,7 @@ func (c *Collection) FindWithOptions(filter map[string]interface{}, options *Que
 	}
 
 	// Cache miss - execute query
-	q := query.NewQuery(filter)
-
-	if options.Projection != nil {
-		q.WithProjection(options.Projection)
-	}
-	if options.Sort != nil {
-		q.WithSort(options.Sort)
-	}
-	if options.Limit > 0 {
-		q.WithLimit(options.Limit)
-	}
-	if options.Skip > 0 {
-		q.WithSkip(options.Skip)
-	}
-
-	results, err := c.executeQuery(q)
+	results, err := c.readQuery(optionsQuery(filter, options))
 	if err != nil {
 		if c.auditLogger != nil {
 			c.auditLogger.LogFind(c.name, c.database, "", false, 0, time.Since(start), filter, err)
@@ -322,23 +313,26 @@ func (c *Collection) FindWithOptions(filter map[string]interface{}, options *Que
 // FindCursor creates a cursor for iterating over query results
 func (c *Collection) FindCursor(filter map[string]interface{}, options *CursorOptions) (*Cursor, error) {
 	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	q := query.NewQuery(filter)
-	return NewCursor(c, q, options)
+	cursor, err := NewCursor(c, query.NewQuery(filter), options)
+	c.mu.RUnlock()
+	c.persistUpgrades()
+	return cursor, err
 }
 
 // FindCursorWithOptions creates a cursor with query options
 func (c *Collection) FindCursorWithOptions(filter map[string]interface{}, queryOptions *QueryOptions, cursorOptions *CursorOptions) (*Cursor, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
 	q := query.NewQuery(filter)
 
 	if queryOptions != nil {
+		if err := checkProjection(queryOptions.Projection); err != nil {
+			return nil, err
+		}
 		if queryOptions.Projection != nil {
 			q.WithProjection(queryOptions.Projection)
 		}
+		if queryOptions.Meta != nil {
+			q.WithMeta(queryOptions.Meta)
+		}
 		if queryOptions.Sort != nil {
 			q.WithSort(queryOptions.Sort)
 		}
@@ -348,27 +342,98 @@ func (c *Collection) FindCursorWithOptions(filter map[string]interface{}, queryO
 		if queryOptions.Skip > 0 {
 			q.WithSkip(queryOptions.Skip)
 		}
+		if queryOptions.Hint != "" {
+			q.WithHint(queryOptions.Hint)
+		}
 	}
 
-	return NewCursor(c, q, cursorOptions)
+	c.mu.RLock()
+	cursor, err := NewCursor(c, q, cursorOptions)
+	c.mu.RUnlock()
+	c.persistUpgrades()
+	return cursor, err
 }
 
 // executeQuery executes a query with query planning and index optimization
 func (c *Collection) executeQuery(q *query.Query) ([]*document.Document, error) {
-	// Load all documents from disk storage
-	docs, err := c.getAllDocuments()
+	// $text queries only consider documents the text index matched
+	if q.HasTextSearch() {
+		if q.GetHint() != "" {
+			return nil, kindOf(ErrInvalidHint, "hint cannot be used with $text queries")
+		}
+		candidates, err := c.textCandidates(q)
+		if err != nil {
+			return nil, err
+		}
+		return query.NewExecutor(candidates).Execute(q)
+	}
+
+	// $near queries backed by a geospatial index only load documents in
+	// range, unless a hint forces another plan
+	if near := q.NearCondition(); near != nil && q.GetHint() == "" {
+		if geoIdx := c.geoIndexForNear(near); geoIdx != nil {
+			geoIdx.Usage().Record()
+			return query.NewExecutor(c.nearCandidates(q, geoIdx, near)).Execute(q)
+		}
+	}
+
+	// Reads span both tiers: the hot tier answers the filter through the
+	// planner, and sorting, skip, limit and projection apply to the merge
+	cold, err := c.coldDocuments()
 	if err != nil {
-		return nil, fmt.Errorf("failed to load documents: %w", err)
+		return nil, err
+	}
+	if len(cold) > 0 {
+		hotQuery := query.NewQuery(q.GetFilter())
+		if hint := q.GetHint(); hint != "" {
+			hotQuery.WithHint(hint)
+		}
+		hot, err := c.executePlannedQuery(hotQuery)
+		if err != nil {
+			return nil, err
+		}
+		coldMatches, err := query.NewExecutor(cold).Execute(query.NewQuery(q.GetFilter()))
+		if err != nil {
+			return nil, err
+		}
+		return query.NewExecutor(append(hot, coldMatches...)).Execute(q)
 	}
 
+	return c.executePlannedQuery(q)
+}
+
+// executePlannedQuery executes a query on the hot tier with query planning
+// and index optimization
+// Must be called with c.mu held
+func (c *Collection) executePlannedQuery(q *query.Query) ([]*document.Document, error) {
 	// Create query planner
-	planner := query.NewQueryPlanner(c.indexes)
+	planner := c.queryPlanner()
 
 	// Generate execution plan
-	plan := planner.Plan(q)
+	plan, err := planner.PlanWithHint(q)
+	if err != nil {
+		return nil, withKind(err, ErrInvalidHint)
+	}
 
 	// Detect if query can be covered by index
 	planner.DetectCoveredQuery(plan, q.GetProjection())
+	plan.RecordIndexUsage()
+
+	// A covered query is answered from index entries without loading documents
+	if plan.IsCovered {
+		return query.NewExecutor(nil).ExecuteWithPlan(q, plan)
+	}
+
+	// An index on the sort fields returns documents already in order
+	if idx := c.sortIndexFor(q, plan); idx != nil {
+		return c.executeSortedByIndex(q, plan, idx)
+	}
+
+	// Load all documents from disk storage
+	docs, err := c.getAllDocuments()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load documents: %w", err)
+	}
 
 	// Create executor with documents
 	executor := query.NewExecutor(docs)
@@ -377,6 +442,98 @@ func (c *Collection) executeQuery(q *query.Query) ([]*document.Document, error)
 	return executor.ExecuteWithPlan(q, plan)
 }
 
+// textCandidates loads the documents matching a $text search, each tagged
+// with its relevance score in query.TextScoreField
+// Must be called with c.mu held
+func (c *Collection) textCandidates(q *query.Query) ([]*document.Document, error) {
+	search, err := q.TextSearch()
+	if err != nil {
+		return nil, err
+	}
+
+	// A collection has at most one text index in practice; use the first
+	var textIdx *index.TextIndex
+	for _, idx := range c.textIndexes {
+		textIdx = idx
+		break
+	}
+	if textIdx == nil {
+		return nil, fmt.Errorf("$text query requires a text index")
+	}
+
+	textIdx.Usage().Record()
+	return c.searchText(textIdx, search), nil
+}
+
+// searchText loads the documents matching a text search, most relevant
+// first, each tagged with its relevance score in query.TextScoreField.
+// The index finds documents containing any of the words; quoted phrases and
+// excluded terms are then checked against each document's indexed text.
+// Must be called with c.mu held
+func (c *Collection) searchText(textIdx *index.TextIndex, search string) []*document.Document {
+	sq := text.ParseSearch(search)
+	results := textIdx.Search(sq.Query())
+	docs := make([]*document.Document, 0, len(results))
+	for _, result := range results {
+		doc, err := c.docStore.Get(result.DocID)
+		if err != nil {
+			// Document might have been deleted, skip it
+			continue
+		}
+		if !sq.Matches(textIdx.Texts(doc)) {
+			continue
+		}
+		docCopy := document.NewDocumentFromMap(doc.ToMap())
+		docCopy.Set(query.TextScoreField, result.Score)
+		docs = append(docs, docCopy)
+	}
+	return docs
+}
+
+// geoIndexForNear returns the geospatial index that can answer a $near
+// condition: a 2d index for legacy coordinate pairs, a 2dsphere index for
+// GeoJSON points. Unbounded spherical searches fall back to a collection scan.
+// Must be called with c.mu held
+func (c *Collection) geoIndexForNear(near *query.NearCondition) *index.GeoIndex {
+	wantType := index.IndexType2D
+	if near.Spherical {
+		if near.MaxDistance <= 0 {
+			return nil
+		}
+		wantType = index.IndexType2DSphere
+	}
+
+	for _, geoIdx := range c.geoIndexes {
+		if geoIdx.FieldPath() == near.Field && geoIdx.Type() == wantType {
+			return geoIdx
+		}
+	}
+	return nil
+}
+
+// nearCandidates loads the documents a geospatial index reports within range
+// of a $near condition, nearest first. When $near is the only condition, with
+// no $minDistance or sort, only skip+limit documents are loaded.
+// Must be called with c.mu held
+func (c *Collection) nearCandidates(q *query.Query, geoIdx *index.GeoIndex, near *query.NearCondition) []*document.Document {
+	limit := 0
+	if len(q.GetFilter()) == 1 && near.MinDistance == 0 && len(q.GetSort()) == 0 && q.GetLimit() > 0 {
+		limit = q.GetSkip() + q.GetLimit()
+	}
+
+	results := geoIdx.Near(near.Center, near.MaxDistance, limit)
+	docs := make([]*document.Document, 0, len(results))
+	for _, result := range results {
+		doc, err := c.docStore.Get(result.DocID)
+		if err != nil {
+			// Document might have been deleted, skip it
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
 // getAllDocuments loads all documents from storage
 func (c *Collection) getAllDocuments() ([]*document.Document, error) {
 	ids := c.docStore.GetAllIDs()
@@ -393,12 +550,92 @@ func (c *Collection) getAllDocuments() ([]*document.Document, error) {
 	return docs, nil
 }
 
+// checkWritable returns ErrReadOnly if the owning database rejects writes
+func (c *Collection) checkWritable() error {
+	if c.db == nil {
+		return nil
+	}
+	return c.db.checkWritable()
+}
+
+// checkDocument verifies a document about to be written: field names must be
+// valid and the document no larger than the collection accepts
+func (c *Collection) checkDocument(d *document.Document) error {
+	if err := checkFieldNames(d); err != nil {
+		return err
+	}
+	return c.checkDocumentSize(d)
+}
+
+// checkFieldNames rejects field names containing dots, at any depth. Dots
+// separate the parts of field paths, so such a field could not be queried
+// or updated.
+func checkFieldNames(value interface{}) error {
+	checkField := func(field string, child interface{}) error {
+		if strings.Contains(field, ".") {
+			return &ValidationError{Field: field, Message: fmt.Sprintf("field name %q must not contain '.'", field)}
+		}
+		return checkFieldNames(child)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for field, child := range v {
+			if err := checkField(field, child); err != nil {
+				return err
+			}
+		}
+	case *document.Document:
+		for _, field := range v.Keys() {
+			child, _ := v.GetValue(field)
+			if err := checkField(field, child.Data); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, elem := range v {
+			if err := checkFieldNames(elem); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkDocumentSize returns ErrDocumentTooLarge if a document is larger than
+// the collection accepts once stored
+func (c *Collection) checkDocumentSize(d *document.Document) error {
+	size, err := c.docStore.storedSize(d)
+	if err != nil {
+		return err
+	}
+	limit := c.maxDocumentSize
+	if limit <= 0 {
+		limit = storage.MaxDocumentSizeForPageSize(c.docStore.diskManager.PageSize())
+	}
+	if size > limit {
+		return fmt.Errorf("%w: %d bytes exceeds the limit of %d bytes", ErrDocumentTooLarge, size, limit)
+	}
+	return nil
+}
+
 // UpdateOne updates a single document matching the filter
 func (c *Collection) UpdateOne(filter map[string]interface{}, update map[string]interface{}) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if c.hasHooks() {
+		return c.updateOneWithHooks(filter, update)
+	}
 	start := time.Now()
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	// Cold documents move to the hot tier before they change
+	if _, err := c.thawMatching(filter); err != nil {
+		return err
+	}
+
 	// Find document
 	doc, err := c.findOneInternal(filter)
 	if err != nil {
@@ -408,34 +645,40 @@ func (c *Collection) UpdateOne(filter map[string]interface{}, update map[string]
 		return err
 	}
 
+	// Reject updates that can't be applied before touching any indexes
+	if err := checkUpdatePaths(doc, update); err != nil {
+		return err
+	}
+	updated := doc.Clone()
+	if err := c.applyUpdate(updated, update); err != nil {
+		return err
+	}
+	if err := c.checkDocument(updated); err != nil {
+		if c.auditLogger != nil {
+			c.auditLogger.LogUpdate(c.name, c.database, "", false, 0, time.Since(start), filter, update, err)
+		}
+		return err
+	}
+
 	// Get document ID for index updates
 	idVal, _ := doc.Get("_id")
 	id := fmt.Sprintf("%v", idVal)
-
-	// Remove old index entries before update
-	for _, idx := range c.indexes {
-		if idx.IsCompound() {
-			// Compound index
-			if compositeKey, allFieldsExist := c.extractCompositeKey(doc, idx.FieldPaths()); allFieldsExist {
-				idx.Delete(compositeKey)
-			}
-		} else {
-			// Single-field index
-			if fieldValue, exists := doc.Get(idx.FieldPath()); exists {
-				idx.Delete(fieldValue)
-			}
+	if err := c.checkUnique(id, updated, nil); err != nil {
+		if c.auditLogger != nil {
+			c.auditLogger.LogUpdate(c.name, c.database, "", false, 0, time.Since(start), filter, update, err)
 		}
+		return err
 	}
-
-	// Remove from text indexes before update
-	for _, textIdx := range c.textIndexes {
-		textIdx.Remove(id)
+	if err := c.checkQuota(c.quotaChange(id, updated)); err != nil {
+		if c.auditLogger != nil {
+			c.auditLogger.LogUpdate(c.name, c.database, "", false, 0, time.Since(start), filter, update, err)
+		}
+		return err
 	}
 
-	// Remove from geo indexes before update
-	for _, geoIdx := range c.geoIndexes {
-		geoIdx.Remove(id)
-	}
+	// Remove old index entries before update. A document outside a partial
+	// index's filter has no entry there to remove.
+	c.unindexDocument(id, doc)
 
 	// Remove from TTL indexes before update
 	for _, ttlIdx := range c.ttlIndexes {
@@ -443,9 +686,7 @@ func (c *Collection) UpdateOne(filter map[string]interface{}, update map[string]
 	}
 
 	// Apply update
-	if err := c.applyUpdate(doc, update); err != nil {
-		return err
-	}
+	doc = updated
 
 	// Write updated document to disk
 	if err := c.docStore.Update(id, doc); err != nil {
@@ -454,6 +695,7 @@ func (c *Collection) UpdateOne(filter map[string]interface{}, update map[string]
 		}
 		return fmt.Errorf("failed to update document on disk: %w", err)
 	}
+	c.logUpdate(idVal, update)
 
 	// Add new index entries after update
 	for _, idx := range c.indexes {
@@ -483,26 +725,14 @@ func (c *Collection) UpdateOne(filter map[string]interface{}, update map[string]
 
 	// Re-index in text indexes after update
 	for _, textIdx := range c.textIndexes {
-		texts := make([]string, 0, len(textIdx.FieldPaths()))
-		for _, fieldPath := range textIdx.FieldPaths() {
-			if fieldValue, exists := doc.Get(fieldPath); exists {
-				if str, ok := fieldValue.(string); ok {
-					texts = append(texts, str)
-				}
-			}
-		}
-		if len(texts) > 0 {
-			textIdx.Index(id, texts)
-		}
+		textIdx.IndexDocument(id, doc)
 	}
 
 	// Re-index in geo indexes after update
 	for _, geoIdx := range c.geoIndexes {
 		if fieldValue, exists := doc.Get(geoIdx.FieldPath()); exists {
-			if pointMap, ok := fieldValue.(map[string]interface{}); ok {
-				if point, err := geo.ParseGeoJSONPoint(pointMap); err == nil {
-					geoIdx.Index(id, point)
-				}
+			if point, err := geo.ParsePoint(fieldValue); err == nil {
+				geoIdx.Index(id, point)
 			}
 		}
 	}
@@ -510,18 +740,7 @@ func (c *Collection) UpdateOne(filter map[string]interface{}, update map[string]
 	// Re-index in TTL indexes after update
 	for _, ttlIdx := range c.ttlIndexes {
 		if fieldValue, exists := doc.Get(ttlIdx.FieldPath()); exists {
-			var timestamp time.Time
-			switch v := fieldValue.(type) {
-			case time.Time:
-				timestamp = v
-			case string:
-				if t, err := time.Parse(time.RFC3339, v); err == nil {
-					timestamp = t
-				}
-			case int64:
-				timestamp = time.Unix(v, 0)
-			}
-			if !timestamp.IsZero() {
+			if timestamp, ok := ttlTimestamp(fieldValue); ok {
 				ttlIdx.Index(id, timestamp)
 			}
 		}
@@ -538,160 +757,53 @@ func (c *Collection) UpdateOne(filter map[string]interface{}, update map[string]
 	return nil
 }
 
-// UpdateMany updates all documents matching the filter
-func (c *Collection) UpdateMany(filter map[string]interface{}, update map[string]interface{}) (int, error) {
+// UpdateMany updates all documents matching the filter. Without hooks the
+// documents are updated as a batch, see updateBatch.
+func (c *Collection) UpdateMany(filter map[string]interface{}, update map[string]interface{}) (*UpdateResult, error) {
+	if err := c.checkWritable(); err != nil {
+		return nil, err
+	}
+	if c.hasHooks() {
+		return c.updateManyWithHooks(filter, update)
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Find documents
+	// Cold documents move to the hot tier before they change
+	if _, err := c.thawMatching(filter); err != nil {
+		return nil, err
+	}
+
 	docs, err := c.findInternal(filter)
 	if err != nil {
-		return 0, err
-	}
-
-	// Update each document
-	count := 0
-	for _, doc := range docs {
-		// Get document ID for index updates
-		idVal, _ := doc.Get("_id")
-		id := fmt.Sprintf("%v", idVal)
-
-		// Remove old index entries before update
-		for _, idx := range c.indexes {
-			if idx.IsCompound() {
-				// Compound index
-				if compositeKey, allFieldsExist := c.extractCompositeKey(doc, idx.FieldPaths()); allFieldsExist {
-					idx.Delete(compositeKey)
-				}
-			} else {
-				// Single-field index
-				if fieldValue, exists := doc.Get(idx.FieldPath()); exists {
-					idx.Delete(fieldValue)
-				}
-			}
-		}
-
-		// Remove from text indexes before update
-		for _, textIdx := range c.textIndexes {
-			textIdx.Remove(id)
-		}
-
-		// Remove from geo indexes before update
-		for _, geoIdx := range c.geoIndexes {
-			geoIdx.Remove(id)
-		}
-
-		// Remove from TTL indexes before update
-		for _, ttlIdx := range c.ttlIndexes {
-			ttlIdx.Remove(id)
-		}
-
-		// Apply update
-		if err := c.applyUpdate(doc, update); err != nil {
-			return count, err
-		}
-
-		// Write updated document to disk
-		if err := c.docStore.Update(id, doc); err != nil {
-			return count, fmt.Errorf("failed to update document %s on disk: %w", id, err)
-		}
-
-		// Add new index entries after update
-		for _, idx := range c.indexes {
-			// Check if document matches partial index filter
-			if !c.matchesPartialIndexFilter(doc, idx) {
-				continue // Skip this index if document doesn't match filter
-			}
-
-			if idx.IsCompound() {
-				// Compound index
-				if compositeKey, allFieldsExist := c.extractCompositeKey(doc, idx.FieldPaths()); allFieldsExist {
-					if err := idx.Insert(compositeKey, id); err != nil {
-						// Log error but continue - index might have duplicate
-						continue
-					}
-				}
-			} else {
-				// Single-field index
-				if fieldValue, exists := doc.Get(idx.FieldPath()); exists {
-					if err := idx.Insert(fieldValue, id); err != nil {
-						// Log error but continue - index might have duplicate
-						continue
-					}
-				}
-			}
-		}
-
-		// Re-index in text indexes after update
-		for _, textIdx := range c.textIndexes {
-			texts := make([]string, 0, len(textIdx.FieldPaths()))
-			for _, fieldPath := range textIdx.FieldPaths() {
-				if fieldValue, exists := doc.Get(fieldPath); exists {
-					if str, ok := fieldValue.(string); ok {
-						texts = append(texts, str)
-					}
-				}
-			}
-			if len(texts) > 0 {
-				textIdx.Index(id, texts)
-			}
-		}
-
-		// Re-index in geo indexes after update
-		for _, geoIdx := range c.geoIndexes {
-			if fieldValue, exists := doc.Get(geoIdx.FieldPath()); exists {
-				if pointMap, ok := fieldValue.(map[string]interface{}); ok {
-					if point, err := geo.ParseGeoJSONPoint(pointMap); err == nil {
-						geoIdx.Index(id, point)
-					}
-				}
-			}
-		}
-
-		// Re-index in TTL indexes after update
-		for _, ttlIdx := range c.ttlIndexes {
-			if fieldValue, exists := doc.Get(ttlIdx.FieldPath()); exists {
-				var timestamp time.Time
-				switch v := fieldValue.(type) {
-				case time.Time:
-					timestamp = v
-				case string:
-					if t, err := time.Parse(time.RFC3339, v); err == nil {
-						timestamp = t
-					}
-				case int64:
-					timestamp = time.Unix(v, 0)
-				}
-				if !timestamp.IsZero() {
-					ttlIdx.Index(id, timestamp)
-				}
-			}
-		}
-
-		count++
+		return nil, err
 	}
-
-	// Invalidate query cache on write
-	c.queryCache.Clear()
-
-	return count, nil
+	return c.updateBatch(docs, update)
 }
 
 // applyUpdate applies an update to a document
 func (c *Collection) applyUpdate(doc *document.Document, update map[string]interface{}) error {
+	// Fields may be dotted paths into embedded documents and arrays
+	var pathErr error
+	set := func(field string, value interface{}) {
+		if err := doc.SetNested(field, value); err != nil && pathErr == nil {
+			pathErr = err
+		}
+	}
+
 	for key, value := range update {
 		if key == "$set" {
 			// $set operator
 			if setMap, ok := value.(map[string]interface{}); ok {
 				for field, val := range setMap {
-					doc.Set(field, val)
+					set(field, val)
 				}
 			}
 		} else if key == "$unset" {
 			// $unset operator
 			if unsetMap, ok := value.(map[string]interface{}); ok {
 				for field := range unsetMap {
-					doc.Delete(field)
+					doc.DeleteNested(field)
 				}
 			}
 		} else if key == "$inc" {
@@ -701,7 +813,7 @@ func (c *Collection) applyUpdate(doc *document.Document, update map[string]inter
 					if currentVal, exists := doc.Get(field); exists {
 						if currentNum, ok := toFloat64(currentVal); ok {
 							if incNum, ok := toFloat64(incVal); ok {
-								doc.Set(field, currentNum+incNum)
+								set(field, currentNum+incNum)
 							}
 						}
 					}
@@ -714,12 +826,12 @@ func (c *Collection) applyUpdate(doc *document.Document, update map[string]inter
 					if currentVal, exists := doc.Get(field); exists {
 						if currentNum, ok := toFloat64(currentVal); ok {
 							if mulNum, ok := toFloat64(mulVal); ok {
-								doc.Set(field, currentNum*mulNum)
+								set(field, currentNum*mulNum)
 							}
 						}
 					} else {
 						// Field doesn't exist, set to 0 (MongoDB behavior)
-						doc.Set(field, 0)
+						set(field, 0)
 					}
 				}
 			}
@@ -731,13 +843,13 @@ func (c *Collection) applyUpdate(doc *document.Document, update map[string]inter
 						if currentNum, ok := toFloat64(currentVal); ok {
 							if minNum, ok := toFloat64(minVal); ok {
 								if minNum < currentNum {
-									doc.Set(field, minNum)
+									set(field, minNum)
 								}
 							}
 						}
 					} else {
 						// Field doesn't exist, set to minVal (MongoDB behavior)
-						doc.Set(field, minVal)
+						set(field, minVal)
 					}
 				}
 			}
@@ -749,61 +861,41 @@ func (c *Collection) applyUpdate(doc *document.Document, update map[string]inter
 						if currentNum, ok := toFloat64(currentVal); ok {
 							if maxNum, ok := toFloat64(maxVal); ok {
 								if maxNum > currentNum {
-									doc.Set(field, maxNum)
+									set(field, maxNum)
 								}
 							}
 						}
 					} else {
 						// Field doesn't exist, set to maxVal (MongoDB behavior)
-						doc.Set(field, maxVal)
+						set(field, maxVal)
 					}
 				}
 			}
 		} else if key == "$push" {
-			// $push operator - add element(s) to array
+			// $push operator - add element(s) to array, with the $each,
+			// $position and $slice modifiers
 			if pushMap, ok := value.(map[string]interface{}); ok {
 				for field, pushVal := range pushMap {
-					// Check if using $each modifier for bulk push
-					var valuesToPush []interface{}
-					if modifierMap, ok := pushVal.(map[string]interface{}); ok {
-						if eachValues, hasEach := modifierMap["$each"]; hasEach {
-							// $each modifier - push multiple values
-							if eachArray, ok := eachValues.([]interface{}); ok {
-								valuesToPush = eachArray
-							}
-						}
-					}
-
-					// If no $each modifier, push single value
-					if valuesToPush == nil {
-						valuesToPush = []interface{}{pushVal}
-					}
-
-					if currentVal, exists := doc.Get(field); exists {
-						// Field exists, append to array
-						if arr, ok := currentVal.([]interface{}); ok {
-							arr = append(arr, valuesToPush...)
-							doc.Set(field, arr)
-						}
-					} else {
-						// Field doesn't exist, create new array
-						doc.Set(field, valuesToPush)
+					currentVal, exists := doc.Get(field)
+					arr, err := pushValues(field, currentVal, exists, pushVal)
+					if err != nil {
+						return err
 					}
+					set(field, arr)
 				}
 			}
 		} else if key == "$pull" {
-			// $pull operator - remove elements matching value
+			// $pull operator - remove elements equal to a value or matching
+			// a condition
 			if pullMap, ok := value.(map[string]interface{}); ok {
 				for field, pullVal := range pullMap {
 					if currentVal, exists := doc.Get(field); exists {
 						if arr, ok := currentVal.([]interface{}); ok {
-							newArr := make([]interface{}, 0)
-							for _, elem := range arr {
-								if !compareValues2(elem, pullVal) {
-									newArr = append(newArr, elem)
-								}
+							newArr, err := pullValues(arr, pullVal)
+							if err != nil {
+								return err
 							}
-							doc.Set(field, newArr)
+							set(field, newArr)
 						}
 					}
 				}
@@ -812,56 +904,12 @@ func (c *Collection) applyUpdate(doc *document.Document, update map[string]inter
 			// $addToSet operator - add element(s) only if not already in array
 			if addMap, ok := value.(map[string]interface{}); ok {
 				for field, addVal := range addMap {
-					// Check if using $each modifier for bulk addToSet
-					var valuesToAdd []interface{}
-					if modifierMap, ok := addVal.(map[string]interface{}); ok {
-						if eachValues, hasEach := modifierMap["$each"]; hasEach {
-							// $each modifier - add multiple unique values
-							if eachArray, ok := eachValues.([]interface{}); ok {
-								valuesToAdd = eachArray
-							}
-						}
-					}
-
-					// If no $each modifier, add single value
-					if valuesToAdd == nil {
-						valuesToAdd = []interface{}{addVal}
-					}
-
-					if currentVal, exists := doc.Get(field); exists {
-						// Field exists, check each value and add if not present
-						if arr, ok := currentVal.([]interface{}); ok {
-							for _, val := range valuesToAdd {
-								found := false
-								for _, elem := range arr {
-									if compareValues2(elem, val) {
-										found = true
-										break
-									}
-								}
-								if !found {
-									arr = append(arr, val)
-								}
-							}
-							doc.Set(field, arr)
-						}
-					} else {
-						// Field doesn't exist, create new array with unique values
-						uniqueVals := make([]interface{}, 0)
-						for _, val := range valuesToAdd {
-							found := false
-							for _, existing := range uniqueVals {
-								if compareValues2(existing, val) {
-									found = true
-									break
-								}
-							}
-							if !found {
-								uniqueVals = append(uniqueVals, val)
-							}
-						}
-						doc.Set(field, uniqueVals)
+					currentVal, exists := doc.Get(field)
+					arr, err := addToSetValues(field, currentVal, exists, addVal)
+					if err != nil {
+						return err
 					}
+					set(field, arr)
 				}
 			}
 		} else if key == "$pop" {
@@ -874,10 +922,10 @@ func (c *Collection) applyUpdate(doc *document.Document, update map[string]inter
 								if popInt, ok := toFloat64(popVal); ok {
 									if popInt < 0 {
 										// Remove first element
-										doc.Set(field, arr[1:])
+										set(field, arr[1:])
 									} else {
 										// Remove last element
-										doc.Set(field, arr[:len(arr)-1])
+										set(field, arr[:len(arr)-1])
 									}
 								}
 							}
@@ -893,9 +941,9 @@ func (c *Collection) applyUpdate(doc *document.Document, update map[string]inter
 						// Get value from old field
 						if val, exists := doc.Get(oldField); exists {
 							// Set new field
-							doc.Set(newField, val)
+							set(newField, val)
 							// Delete old field
-							doc.Delete(oldField)
+							doc.DeleteNested(oldField)
 						}
 					}
 				}
@@ -916,9 +964,9 @@ func (c *Collection) applyUpdate(doc *document.Document, update map[string]inter
 
 					// Set current time
 					if useTimestamp {
-						doc.Set(field, time.Now().Unix())
+						set(field, time.Now().Unix())
 					} else {
-						doc.Set(field, time.Now())
+						set(field, time.Now())
 					}
 				}
 			}
@@ -950,7 +998,7 @@ func (c *Collection) applyUpdate(doc *document.Document, update map[string]inter
 										newArr = append(newArr, elem)
 									}
 								}
-								doc.Set(field, newArr)
+								set(field, newArr)
 							}
 						}
 					}
@@ -986,7 +1034,7 @@ func (c *Collection) applyUpdate(doc *document.Document, update map[string]inter
 									}
 								}
 
-								doc.Set(field, result)
+								set(field, result)
 							}
 						} else {
 							// Field doesn't exist, initialize to 0 and apply operations
@@ -1013,26 +1061,74 @@ func (c *Collection) applyUpdate(doc *document.Document, update map[string]inter
 								}
 							}
 
-							doc.Set(field, result)
+							set(field, result)
 						}
 					}
 				}
 			}
+		} else if key == "$setOnInsert" {
+			// $setOnInsert operator - only applied when an upsert inserts,
+			// see upsertDocument
 		} else {
 			// Direct field update
-			doc.Set(key, value)
+			set(key, value)
 		}
 	}
 
+	return pathErr
+}
+
+// checkUpdatePaths verifies that every field written by an update can be set
+// on doc, so a failing update leaves the document and its indexes unchanged
+func checkUpdatePaths(doc *document.Document, update map[string]interface{}) error {
+	for key, value := range update {
+		if !strings.HasPrefix(key, "$") {
+			if err := doc.CheckPath(key); err != nil {
+				return &ValidationError{Field: key, Message: err.Error()}
+			}
+			continue
+		}
+
+		// $unset of a path that doesn't exist is a no-op, and $setOnInsert
+		// doesn't apply to existing documents
+		if key == "$unset" || key == "$setOnInsert" {
+			continue
+		}
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for field, val := range fields {
+			if key == "$rename" {
+				if newField, ok := val.(string); ok {
+					field = newField
+				}
+			}
+			if err := doc.CheckPath(field); err != nil {
+				return &ValidationError{Field: field, Message: err.Error()}
+			}
+		}
+	}
 	return nil
 }
 
 // DeleteOne deletes a single document matching the filter
 func (c *Collection) DeleteOne(filter map[string]interface{}) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if c.hasHooks() {
+		return c.deleteOneWithHooks(filter)
+	}
 	start := time.Now()
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	// Cold documents move to the hot tier before they are deleted
+	if _, err := c.thawMatching(filter); err != nil {
+		return err
+	}
+
 	doc, err := c.findOneInternal(filter)
 	if err != nil {
 		if c.auditLogger != nil {
@@ -1045,29 +1141,7 @@ func (c *Collection) DeleteOne(filter map[string]interface{}) error {
 	id := fmt.Sprintf("%v", idVal)
 
 	// Remove from indexes
-	for _, idx := range c.indexes {
-		if idx.IsCompound() {
-			// Compound index
-			if compositeKey, allFieldsExist := c.extractCompositeKey(doc, idx.FieldPaths()); allFieldsExist {
-				idx.Delete(compositeKey)
-			}
-		} else {
-			// Single-field index
-			if fieldValue, exists := doc.Get(idx.FieldPath()); exists {
-				idx.Delete(fieldValue)
-			}
-		}
-	}
-
-	// Remove from text indexes
-	for _, textIdx := range c.textIndexes {
-		textIdx.Remove(id)
-	}
-
-	// Remove from geo indexes
-	for _, geoIdx := range c.geoIndexes {
-		geoIdx.Remove(id)
-	}
+	c.unindexDocument(id, doc)
 
 	// Remove from TTL indexes
 	for _, ttlIdx := range c.ttlIndexes {
@@ -1081,6 +1155,7 @@ func (c *Collection) DeleteOne(filter map[string]interface{}) error {
 		}
 		return fmt.Errorf("failed to delete document from disk: %w", err)
 	}
+	c.logDelete(idVal)
 
 	// Invalidate query cache on write
 	c.queryCache.Clear()
@@ -1093,74 +1168,56 @@ func (c *Collection) DeleteOne(filter map[string]interface{}) error {
 	return nil
 }
 
-// DeleteMany deletes all documents matching the filter
-func (c *Collection) DeleteMany(filter map[string]interface{}) (int, error) {
+// DeleteMany deletes all documents matching the filter. Without hooks the
+// documents are deleted as a batch, see deleteBatch.
+func (c *Collection) DeleteMany(filter map[string]interface{}) (*DeleteResult, error) {
+	if err := c.checkWritable(); err != nil {
+		return nil, err
+	}
+	if c.hasHooks() {
+		return c.deleteManyWithHooks(filter)
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	docs, err := c.findInternal(filter)
-	if err != nil {
-		return 0, err
+	// Cold documents move to the hot tier before they are deleted
+	if _, err := c.thawMatching(filter); err != nil {
+		return nil, err
 	}
 
-	count := 0
-	for _, doc := range docs {
-		idVal, _ := doc.Get("_id")
-		id := fmt.Sprintf("%v", idVal)
-
-		// Remove from indexes
-		for _, idx := range c.indexes {
-			if idx.IsCompound() {
-				// Compound index
-				if compositeKey, allFieldsExist := c.extractCompositeKey(doc, idx.FieldPaths()); allFieldsExist {
-					idx.Delete(compositeKey)
-				}
-			} else {
-				// Single-field index
-				if fieldValue, exists := doc.Get(idx.FieldPath()); exists {
-					idx.Delete(fieldValue)
-				}
-			}
-		}
-
-		// Remove from text indexes
-		for _, textIdx := range c.textIndexes {
-			textIdx.Remove(id)
-		}
-
-		// Remove from geo indexes
-		for _, geoIdx := range c.geoIndexes {
-			geoIdx.Remove(id)
-		}
-
-		// Remove from TTL indexes
-		for _, ttlIdx := range c.ttlIndexes {
-			ttlIdx.Remove(id)
-		}
-
-		// Delete document from disk
-		if err := c.docStore.Delete(id); err != nil {
-			return count, fmt.Errorf("failed to delete document %s from disk: %w", id, err)
-		}
-
-		count++
+	docs, err := c.findInternal(filter)
+	if err != nil {
+		return nil, err
 	}
-
-	// Invalidate query cache on write
-	c.queryCache.Clear()
-
-	return count, nil
+	return c.deleteBatch(docs)
 }
 
-// Count returns the number of documents matching the filter
+// Count returns the number of documents matching the filter. An empty or
+// nil filter counts all documents without scanning them.
 func (c *Collection) Count(filter map[string]interface{}) (int, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	if len(filter) == 0 {
+		return c.docStore.Count() + c.coldCount(), nil
+	}
+
 	docs, err := c.findInternal(filter)
 	return len(docs), err
 }
 
+// EstimatedDocumentCount returns the number of documents in the collection
+// from the document store's counter, in constant time and without waiting
+// for writers holding the collection lock. Like MongoDB's fast count, it may
+// be momentarily stale: writes in progress and session transactions that
+// haven't committed yet are not counted.
+func (c *Collection) EstimatedDocumentCount() (int64, error) {
+	if c.db != nil && !c.db.IsOpen() {
+		return 0, ErrDatabaseClosed
+	}
+	return int64(c.docStore.Count() + c.coldCount()), nil
+}
+
 // extractCompositeKey extracts values for a compound index from a document
 // Returns the composite key and a boolean indicating if all fields were present
 func (c *Collection) extractCompositeKey(d *document.Document, fieldPaths []string) (*index.CompositeKey, bool) {
@@ -1199,6 +1256,9 @@ func (c *Collection) CreateIndex(fieldPath string, unique bool) error {
 
 // CreateIndexWithBackground creates an index on a field with optional background building
 func (c *Collection) CreateIndexWithBackground(fieldPath string, unique bool, background bool) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
 	start := time.Now()
 	c.mu.Lock()
 
@@ -1232,20 +1292,30 @@ func (c *Collection) CreateIndexWithBackground(fieldPath string, unique bool, ba
 		c.buildSingleFieldIndexInBackgroundWithSnapshot(idx, snapshots)
 	} else {
 		// Build index synchronously from existing documents
+		var buildErr error
 		ids := c.docStore.GetAllIDs()
 		for _, id := range ids {
 			doc, err := c.docStore.Get(id)
 			if err != nil {
-				c.mu.Unlock()
-				return fmt.Errorf("failed to get document %s: %w", id, err)
+				buildErr = fmt.Errorf("failed to get document %s: %w", id, err)
+				break
 			}
 			if fieldValue, exists := doc.Get(fieldPath); exists {
 				if err := idx.Insert(fieldValue, id); err != nil {
-					c.mu.Unlock()
-					return fmt.Errorf("failed to build index: %w", err)
+					buildErr = fmt.Errorf("failed to build index: %w", err)
+					break
 				}
 			}
 		}
+		if buildErr != nil {
+			// Queries and writes must not use a half-built index
+			delete(c.indexes, indexName)
+			c.mu.Unlock()
+			if c.auditLogger != nil {
+				c.auditLogger.LogIndexOperation(audit.OperationCreateIndex, c.name, c.database, "", indexName, false, time.Since(start), buildErr)
+			}
+			return buildErr
+		}
 		c.mu.Unlock()
 	}
 
@@ -1264,6 +1334,9 @@ func (c *Collection) CreateCompoundIndex(fieldPaths []string, unique bool) error
 
 // CreateCompoundIndexWithBackground creates a compound index on multiple fields with optional background building
 func (c *Collection) CreateCompoundIndexWithBackground(fieldPaths []string, unique bool, background bool) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
 	c.mu.Lock()
 
 	if len(fieldPaths) == 0 {
@@ -1310,6 +1383,7 @@ func (c *Collection) CreateCompoundIndexWithBackground(fieldPaths []string, uniq
 		for _, id := range ids {
 			doc, err := c.docStore.Get(id)
 			if err != nil {
+				delete(c.indexes, indexName)
 				c.mu.Unlock()
 				return fmt.Errorf("failed to get document %s: %w", id, err)
 			}
@@ -1331,6 +1405,7 @@ func (c *Collection) CreateCompoundIndexWithBackground(fieldPaths []string, uniq
 			if allFieldsExist {
 				compositeKey := index.NewCompositeKey(values...)
 				if err := idx.Insert(compositeKey, id); err != nil {
+					delete(c.indexes, indexName)
 					c.mu.Unlock()
 					return fmt.Errorf("failed to build compound index: %w", err)
 				}
@@ -1344,6 +1419,9 @@ func (c *Collection) CreateCompoundIndexWithBackground(fieldPaths []string, uniq
 
 // CreatePartialIndex creates a partial index that only indexes documents matching a filter
 func (c *Collection) CreatePartialIndex(fieldPath string, filter map[string]interface{}, unique bool) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -1392,12 +1470,28 @@ func (c *Collection) CreatePartialIndex(fieldPath string, filter map[string]inte
 
 // CreateTextIndex creates a text search index on one or more text fields
 func (c *Collection) CreateTextIndex(fieldPaths []string) error {
+	return c.CreateTextIndexWithWeights(fieldPaths, nil)
+}
+
+// CreateTextIndexWithWeights creates a text search index where a match in
+// each field counts weights[field] times towards the relevance score, e.g.
+// {"title": 10, "body": 1} ranks title matches above body matches.
+// Fields without a weight default to 1.
+func (c *Collection) CreateTextIndexWithWeights(fieldPaths []string, weights map[string]int) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if len(fieldPaths) == 0 {
 		return fmt.Errorf("text index must have at least one field")
 	}
+	for field, weight := range weights {
+		if weight < 1 {
+			return fmt.Errorf("text index weight for %s must be at least 1", field)
+		}
+	}
 
 	// Generate index name: field1_field2_..._text
 	indexName := ""
@@ -1414,7 +1508,7 @@ func (c *Collection) CreateTextIndex(fieldPaths []string) error {
 	}
 
 	// Create text index
-	textIdx := index.NewTextIndex(indexName, fieldPaths)
+	textIdx := index.NewWeightedTextIndex(indexName, fieldPaths, weights)
 
 	// Build index from existing documents
 	ids := c.docStore.GetAllIDs()
@@ -1424,22 +1518,8 @@ func (c *Collection) CreateTextIndex(fieldPaths []string) error {
 			return fmt.Errorf("failed to get document %s: %w", id, err)
 		}
 
-		// Extract all text field values
-		texts := make([]string, 0, len(fieldPaths))
-
-		for _, fieldPath := range fieldPaths {
-			if fieldValue, exists := doc.Get(fieldPath); exists {
-				// Convert value to string
-				if str, ok := fieldValue.(string); ok {
-					texts = append(texts, str)
-				}
-			}
-		}
-
-		// Only index if we found at least one text field
-		if len(texts) > 0 {
-			textIdx.Index(id, texts)
-		}
+		// Documents without any indexed text field are skipped
+		textIdx.IndexDocument(id, doc)
 	}
 
 	c.textIndexes[indexName] = textIdx
@@ -1448,6 +1528,9 @@ func (c *Collection) CreateTextIndex(fieldPaths []string) error {
 
 // Create2DIndex creates a 2d planar geospatial index on a field
 func (c *Collection) Create2DIndex(fieldPath string) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -1469,11 +1552,9 @@ func (c *Collection) Create2DIndex(fieldPath string) error {
 		}
 
 		if fieldValue, exists := doc.Get(fieldPath); exists {
-			// Try to parse as GeoJSON point
-			if pointMap, ok := fieldValue.(map[string]interface{}); ok {
-				if point, err := geo.ParseGeoJSONPoint(pointMap); err == nil {
-					geoIdx.Index(id, point)
-				}
+			// Points are [lng, lat] arrays or GeoJSON points
+			if point, err := geo.ParsePoint(fieldValue); err == nil {
+				geoIdx.Index(id, point)
 			}
 		}
 	}
@@ -1484,6 +1565,9 @@ func (c *Collection) Create2DIndex(fieldPath string) error {
 
 // Create2DSphereIndex creates a 2dsphere spherical geospatial index on a field
 func (c *Collection) Create2DSphereIndex(fieldPath string) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -1505,11 +1589,9 @@ func (c *Collection) Create2DSphereIndex(fieldPath string) error {
 		}
 
 		if fieldValue, exists := doc.Get(fieldPath); exists {
-			// Try to parse as GeoJSON point
-			if pointMap, ok := fieldValue.(map[string]interface{}); ok {
-				if point, err := geo.ParseGeoJSONPoint(pointMap); err == nil {
-					geoIdx.Index(id, point)
-				}
+			// Points are [lng, lat] arrays or GeoJSON points
+			if point, err := geo.ParsePoint(fieldValue); err == nil {
+				geoIdx.Index(id, point)
 			}
 		}
 	}
@@ -1518,9 +1600,18 @@ func (c *Collection) Create2DSphereIndex(fieldPath string) error {
 	return nil
 }
 
-// CreateTTLIndex creates a TTL (time-to-live) index on a date field
-// Documents will be automatically deleted ttlSeconds after the timestamp in the field
-func (c *Collection) CreateTTLIndex(fieldPath string, ttlSeconds int64) error {
+// CreateTTLIndex creates a TTL (time-to-live) index on a date field.
+// Documents are deleted by the database's background reaper once ttl has
+// passed since the timestamp in the field (a ttl of 0 expires them at the
+// timestamp); see ttlTimestamp for the values it understands. Documents
+// without one never expire.
+func (c *Collection) CreateTTLIndex(fieldPath string, ttl time.Duration) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if ttl < 0 {
+		return kindOf(ErrValidation, "ttl must not be negative, got %v", ttl)
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -1531,7 +1622,7 @@ func (c *Collection) CreateTTLIndex(fieldPath string, ttlSeconds int64) error {
 	}
 
 	// Create TTL index
-	ttlIdx := index.NewTTLIndex(indexName, fieldPath, ttlSeconds)
+	ttlIdx := index.NewTTLIndexWithDuration(indexName, fieldPath, ttl)
 
 	// Build index from existing documents
 	ids := c.docStore.GetAllIDs()
@@ -1542,24 +1633,9 @@ func (c *Collection) CreateTTLIndex(fieldPath string, ttlSeconds int64) error {
 		}
 
 		if fieldValue, exists := doc.Get(fieldPath); exists {
-			// Try to convert to time.Time
-			var timestamp time.Time
-			switch v := fieldValue.(type) {
-			case time.Time:
-				timestamp = v
-			case string:
-				// Try to parse as RFC3339 string
-				if t, err := time.Parse(time.RFC3339, v); err == nil {
-					timestamp = t
-				}
-			case int64:
-				// Unix timestamp in seconds
-				timestamp = time.Unix(v, 0)
-			default:
-				continue // Skip non-time values
+			if timestamp, ok := ttlTimestamp(fieldValue); ok {
+				ttlIdx.Index(id, timestamp)
 			}
-
-			ttlIdx.Index(id, timestamp)
 		}
 	}
 
@@ -1567,8 +1643,28 @@ func (c *Collection) CreateTTLIndex(fieldPath string, ttlSeconds int64) error {
 	return nil
 }
 
+// ttlTimestamp returns the time a TTL index counts from in a field value: a
+// time.Time, an RFC 3339 string or Unix seconds as an int64
+func ttlTimestamp(value interface{}) (time.Time, bool) {
+	var timestamp time.Time
+	switch v := value.(type) {
+	case time.Time:
+		timestamp = v
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			timestamp = t
+		}
+	case int64:
+		timestamp = time.Unix(v, 0)
+	}
+	return timestamp, !timestamp.IsZero()
+}
+
 // DropIndex drops an index (B+ tree, compound, text, geo, or ttl)
 func (c *Collection) DropIndex(indexName string) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
 	start := time.Now()
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -1584,6 +1680,7 @@ func (c *Collection) DropIndex(indexName string) error {
 	// Check if it's a regular index
 	if _, exists := c.indexes[indexName]; exists {
 		delete(c.indexes, indexName)
+		c.forgetIndexUsage(indexName)
 		if c.auditLogger != nil {
 			c.auditLogger.LogIndexOperation(audit.OperationDropIndex, c.name, c.database, "", indexName, true, time.Since(start), nil)
 		}
@@ -1593,6 +1690,7 @@ func (c *Collection) DropIndex(indexName string) error {
 	// Check if it's a text index
 	if _, exists := c.textIndexes[indexName]; exists {
 		delete(c.textIndexes, indexName)
+		c.forgetIndexUsage(indexName)
 		if c.auditLogger != nil {
 			c.auditLogger.LogIndexOperation(audit.OperationDropIndex, c.name, c.database, "", indexName, true, time.Since(start), nil)
 		}
@@ -1602,6 +1700,7 @@ func (c *Collection) DropIndex(indexName string) error {
 	// Check if it's a geo index
 	if _, exists := c.geoIndexes[indexName]; exists {
 		delete(c.geoIndexes, indexName)
+		c.forgetIndexUsage(indexName)
 		if c.auditLogger != nil {
 			c.auditLogger.LogIndexOperation(audit.OperationDropIndex, c.name, c.database, "", indexName, true, time.Since(start), nil)
 		}
@@ -1611,13 +1710,14 @@ func (c *Collection) DropIndex(indexName string) error {
 	// Check if it's a TTL index
 	if _, exists := c.ttlIndexes[indexName]; exists {
 		delete(c.ttlIndexes, indexName)
+		c.forgetIndexUsage(indexName)
 		if c.auditLogger != nil {
 			c.auditLogger.LogIndexOperation(audit.OperationDropIndex, c.name, c.database, "", indexName, true, time.Since(start), nil)
 		}
 		return nil
 	}
 
-	err := fmt.Errorf("index %s does not exist", indexName)
+	err := kindOf(ErrNotFound, "index %s does not exist", indexName)
 	if c.auditLogger != nil {
 		c.auditLogger.LogIndexOperation(audit.OperationDropIndex, c.name, c.database, "", indexName, false, time.Since(start), err)
 	}
@@ -1625,7 +1725,19 @@ func (c *Collection) DropIndex(indexName string) error {
 }
 
 // ListIndexes returns all indexes (B+ tree, compound, text, geo, and ttl)
+// with their operational state. Every entry has a "build_state" of "ready",
+// "building" or "failed"; an index still building carries its
+// "build_progress" and a failed one its "build_error". B+ tree indexes
+// report "size_bytes", the estimated memory of their entries, and indexes
+// queries have used report when they were "last_used".
 func (c *Collection) ListIndexes() []map[string]interface{} {
+	lastUsed := make(map[string]time.Time)
+	for _, stats := range c.IndexStats() {
+		if !stats.LastUsed.IsZero() {
+			lastUsed[stats.Name] = stats.LastUsed
+		}
+	}
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -1633,7 +1745,12 @@ func (c *Collection) ListIndexes() []map[string]interface{} {
 
 	// Add regular indexes
 	for _, idx := range c.indexes {
-		indexes = append(indexes, idx.Stats())
+		info := idx.Stats()
+		info["size_bytes"] = idx.SizeBytes()
+		if buildError := idx.BuildError(); buildError != "" && idx.GetBuildState() == index.IndexStateFailed {
+			info["build_error"] = buildError
+		}
+		indexes = append(indexes, info)
 	}
 
 	// Add text indexes
@@ -1652,32 +1769,80 @@ func (c *Collection) ListIndexes() []map[string]interface{} {
 			"name":       ttlIdx.Name(),
 			"field":      ttlIdx.FieldPath(),
 			"type":       "ttl",
+			"ttl":        ttlIdx.TTL(),
 			"ttlSeconds": ttlIdx.TTLSeconds(),
 			"count":      ttlIdx.Count(),
 		})
 	}
 
+	// Text, geo and TTL indexes are built as they are created
+	for _, info := range indexes {
+		if _, ok := info["build_state"]; !ok {
+			info["build_state"] = index.IndexStateReady.String()
+		}
+		if used, ok := lastUsed[info["name"].(string)]; ok {
+			info["last_used"] = used
+		}
+	}
+
 	return indexes
 }
 
 // Aggregate executes an aggregation pipeline
 func (c *Collection) Aggregate(pipeline []map[string]interface{}) ([]*document.Document, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	return c.AggregateWithOptions(pipeline, nil)
+}
 
-	// Get all documents from disk storage
-	docs, err := c.getAllDocuments()
+// AggregateWithOptions executes an aggregation pipeline in memory. A leading
+// $match an index can answer, and a $sort right after it on the index key,
+// are pushed into an index scan; see ExplainAggregate. $lookup joins
+// collections of the same database. Of the options only Hint applies; see
+// AggregateCursor for streaming execution.
+func (c *Collection) AggregateWithOptions(pipeline []map[string]interface{}, opts *AggregateOptions) ([]*document.Document, error) {
+	aggPipeline, err := aggregation.NewPipeline(pipeline)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load documents: %w", err)
+		return nil, fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	if aggPipeline.Output() != nil {
+		if err := c.checkWritable(); err != nil {
+			return nil, err
+		}
 	}
 
-	// Create and execute pipeline
-	aggPipeline, err := aggregation.NewPipeline(pipeline)
+	c.mu.RLock()
+	var docs []*document.Document
+	if opts != nil && opts.Hint != "" {
+		docs, aggPipeline, err = c.hintedSource(aggPipeline, opts.Hint)
+	} else {
+		docs, aggPipeline, err = c.aggregationSource(aggPipeline)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to create pipeline: %w", err)
+		c.mu.RUnlock()
+		return nil, err
+	}
+	if docs, err = c.upgradeDocuments(docs); err != nil {
+		c.mu.RUnlock()
+		return nil, err
+	}
+	c.mu.RUnlock()
+
+	// The stages run without the lock, as $lookup may read this collection
+	c.bindLookups(aggPipeline)
+	results, err := aggPipeline.Execute(docs)
+	c.persistUpgrades()
+	if err != nil {
+		return nil, err
 	}
 
-	return aggPipeline.Execute(docs)
+	// $out and $merge write the results instead of returning them. The read
+	// lock is released first since the target may be this collection.
+	if output := aggPipeline.Output(); output != nil {
+		if err := c.writeOutput(output, aggregation.NewSliceIterator(results)); err != nil {
+			return nil, err
+		}
+		return []*document.Document{}, nil
+	}
+	return results, nil
 }
 
 // Name returns the collection name
@@ -1692,13 +1857,15 @@ func (c *Collection) Stats() map[string]interface{} {
 
 	return map[string]interface{}{
 		"name":             c.name,
-		"count":            c.docStore.Count(),
+		"count":            c.docStore.Count() + c.coldCount(),
+		"cold_count":       c.coldCount(),
 		"indexes":          len(c.indexes),
 		"index_count":      len(c.indexes),
 		"text_index_count": len(c.textIndexes),
 		"geo_index_count":  len(c.geoIndexes),
 		"ttl_index_count":  len(c.ttlIndexes),
 		"index_details":    c.ListIndexes(),
+		"storage":          c.docStore.freeSpace.Stats(),
 	}
 }
 
@@ -1734,21 +1901,7 @@ func (c *Collection) TextSearch(searchText string, options *QueryOptions) ([]*do
 	}
 
 	// Search the text index
-	results := textIdx.Search(searchText)
-
-	// Convert results to documents
-	docs := make([]*document.Document, 0, len(results))
-	for _, result := range results {
-		doc, err := c.docStore.Get(result.DocID)
-		if err != nil {
-			// Document might have been deleted, skip it
-			continue
-		}
-		// Add relevance score as a metadata field
-		docCopy := document.NewDocumentFromMap(doc.ToMap())
-		docCopy.Set("_textScore", result.Score)
-		docs = append(docs, docCopy)
-	}
+	docs := c.searchText(textIdx, searchText)
 
 	// Apply projection if specified
 	if options != nil && options.Projection != nil {
@@ -1801,20 +1954,59 @@ func (c *Collection) TextSearch(searchText string, options *QueryOptions) ([]*do
 
 // Explain returns the execution plan for a query
 func (c *Collection) Explain(filter map[string]interface{}) map[string]interface{} {
+	return c.ExplainWithOptions(filter, nil)
+}
+
+// ExplainWithOptions returns the execution plan for a query with options.
+// With a hint, "hintHonored" reports whether the hinted plan can be used; if
+// not, "hintError" says why and the plan shown is the one chosen without it.
+func (c *Collection) ExplainWithOptions(filter map[string]interface{}, options *QueryOptions) map[string]interface{} {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	// Create query
 	q := query.NewQuery(filter)
+	if options != nil {
+		if options.Projection != nil {
+			q.WithProjection(options.Projection)
+		}
+		if options.Sort != nil {
+			q.WithSort(options.Sort)
+		}
+		if options.Hint != "" {
+			q.WithHint(options.Hint)
+		}
+	}
 
+	_, explanation := c.explainQuery(q)
+	return explanation
+}
+
+// explainQuery plans a query and returns the plan with its explanation
+// Must be called with c.mu held
+func (c *Collection) explainQuery(q *query.Query) (*query.QueryPlan, map[string]interface{}) {
 	// Create query planner
-	planner := query.NewQueryPlanner(c.indexes)
+	planner := c.queryPlanner()
 
 	// Generate execution plan
-	plan := planner.Plan(q)
+	plan, err := planner.PlanWithHint(q)
+	if err != nil {
+		plan = planner.Plan(q)
+	}
+
+	// Report whether the projection makes it a covered query
+	planner.DetectCoveredQuery(plan, q.GetProjection())
 
 	// Get plan explanation
 	explanation := plan.Explain()
+	if idx := c.sortIndexFor(q, plan); idx != nil && c.coldCount() == 0 {
+		explanation["sortIndex"] = idx.Name()
+	}
+	if err != nil {
+		explanation["hint"] = q.GetHint()
+		explanation["hintHonored"] = false
+		explanation["hintError"] = err.Error()
+	}
 
 	// Add collection info
 	explanation["collection"] = c.name
@@ -1824,7 +2016,7 @@ func (c *Collection) Explain(filter map[string]interface{}) map[string]interface
 		explanation["availableIndexes"] = append(explanation["availableIndexes"].([]string), indexName)
 	}
 
-	return explanation
+	return plan, explanation
 }
 
 // findOneInternal finds one document (caller must hold lock)
@@ -1843,13 +2035,25 @@ func (c *Collection) findOneInternal(filter map[string]interface{}) (*document.D
 func (c *Collection) findInternal(filter map[string]interface{}) ([]*document.Document, error) {
 	q := query.NewQuery(filter)
 
-	// Load all documents from disk storage
+	if q.HasTextSearch() {
+		candidates, err := c.textCandidates(q)
+		if err != nil {
+			return nil, err
+		}
+		return query.NewExecutor(candidates).Execute(q)
+	}
+
+	// Load all documents from disk storage and the cold tier
 	docs, err := c.getAllDocuments()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load documents: %w", err)
 	}
+	cold, err := c.coldDocuments()
+	if err != nil {
+		return nil, err
+	}
 
-	executor := query.NewExecutor(docs)
+	executor := query.NewExecutor(append(docs, cold...))
 	return executor.Execute(q)
 }
 
@@ -2148,17 +2352,20 @@ func (c *Collection) GeoIntersects(fieldPath string, box *geo.BoundingBox, optio
 }
 
 // CleanupExpiredDocuments removes documents that have expired according to TTL indexes
-// Returns the number of documents deleted
+// Returns the number of documents deleted. Documents a session transaction
+// has written or deleted but not committed are left for a later pass, so
+// the reaper never deletes a document under a transaction that may still
+// extend its life or delete it itself.
 func (c *Collection) CleanupExpiredDocuments() int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if len(c.ttlIndexes) == 0 {
+	// Expiry is paused while the database is read-only
+	if len(c.ttlIndexes) == 0 || c.checkWritable() != nil {
 		return 0
 	}
 
 	currentTime := time.Now()
-	deletedCount := 0
 	toDelete := make(map[string]bool) // Use map to avoid duplicates
 
 	// Collect all expired document IDs from all TTL indexes
@@ -2169,56 +2376,28 @@ func (c *Collection) CleanupExpiredDocuments() int {
 		}
 	}
 
-	// Delete each expired document
+	docs := make([]*document.Document, 0, len(toDelete))
 	for docID := range toDelete {
+		if c.txnMgr.HasPendingWrite(fmt.Sprintf("%s:%s", c.name, docID)) {
+			continue
+		}
 		doc, err := c.docStore.Get(docID)
 		if err != nil {
 			// Document doesn't exist, skip
 			continue
 		}
-
-		// Remove from regular indexes
-		for _, idx := range c.indexes {
-			if idx.IsCompound() {
-				if compositeKey, allFieldsExist := c.extractCompositeKey(doc, idx.FieldPaths()); allFieldsExist {
-					idx.Delete(compositeKey)
-				}
-			} else {
-				if fieldValue, exists := doc.Get(idx.FieldPath()); exists {
-					idx.Delete(fieldValue)
-				}
-			}
-		}
-
-		// Remove from text indexes
-		for _, textIdx := range c.textIndexes {
-			textIdx.Remove(docID)
-		}
-
-		// Remove from geo indexes
-		for _, geoIdx := range c.geoIndexes {
-			geoIdx.Remove(docID)
-		}
-
-		// Remove from TTL indexes
-		for _, ttlIdx := range c.ttlIndexes {
-			ttlIdx.Remove(docID)
-		}
-
-		// Delete the document from disk
-		if err := c.docStore.Delete(docID); err != nil {
-			// Log error but continue with other documents
-			continue
-		}
-		deletedCount++
+		docs = append(docs, doc)
 	}
-
-	// Invalidate query cache if documents were deleted
-	if deletedCount > 0 {
-		c.queryCache.Clear()
+	if len(docs) == 0 {
+		return 0
 	}
 
-	return deletedCount
+	// Index entries are removed and deletes logged with the batch
+	result, err := c.deleteBatch(docs)
+	if err != nil {
+		return 0
+	}
+	return result.DeletedCount
 }
 
 // docSnapshot represents a snapshot of a document for background index building
@@ -2288,7 +2467,7 @@ func (c *Collection) buildSingleFieldIndexInBackgroundWithSnapshot(idx *index.In
 			}
 
 			// Insert into index (may fail if already exists due to concurrent write)
-			if err := idx.Insert(snapshot.fieldValue, snapshot.id); err != nil {
+			if err := c.indexSnapshotEntry(idx, snapshot.fieldValue, snapshot.id); err != nil {
 				// Skip duplicate key errors - document was likely added by concurrent write
 				// This can happen when a document from the snapshot gets updated/inserted
 				// after the snapshot but before the background builder processes it
@@ -2311,6 +2490,24 @@ func (c *Collection) buildSingleFieldIndexInBackgroundWithSnapshot(idx *index.In
 	}()
 }
 
+// indexSnapshotEntry inserts the entry a background build captured for a
+// document, unless the document was written since. The write indexed the
+// document as it is now, and the captured key would be stale.
+func (c *Collection) indexSnapshotEntry(idx *index.Index, key interface{}, id string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	doc, err := c.docStore.Get(id)
+	if err != nil {
+		// Deleted since the snapshot
+		return nil
+	}
+	if current, included := c.indexKeyFor(idx, doc); !included || !reflect.DeepEqual(current, key) {
+		return nil
+	}
+	return idx.Insert(key, id)
+}
+
 // captureCompoundIndexSnapshot captures a snapshot of documents for compound index building
 // Must be called while holding c.mu lock
 func (c *Collection) captureCompoundIndexSnapshot(idx *index.Index, fieldPaths []string) []docSnapshot {
@@ -2382,7 +2579,7 @@ func (c *Collection) buildCompoundIndexInBackgroundWithSnapshot(idx *index.Index
 			}
 
 			// Insert into index (may fail if already exists due to concurrent write)
-			if err := idx.Insert(snapshot.compositeKey, snapshot.id); err != nil {
+			if err := c.indexSnapshotEntry(idx, snapshot.compositeKey, snapshot.id); err != nil {
 				// Skip duplicate key errors - document was likely added by concurrent write
 				errMsg := err.Error()
 				if strings.Contains(errMsg, "duplicate") {
@@ -2410,7 +2607,7 @@ func (c *Collection) GetIndexBuildProgress(indexName string) (map[string]interfa
 
 	idx, exists := c.indexes[indexName]
 	if !exists {
-		return nil, fmt.Errorf("index %s not found", indexName)
+		return nil, kindOf(ErrNotFound, "index %s not found", indexName)
 	}
 
 	return idx.GetBuildProgress(), nil
@@ -2436,6 +2633,9 @@ type BulkWriteResult struct {
 // BulkWrite performs multiple insert, update, and delete operations
 // If ordered is true, stops on first error. If false, continues with remaining operations.
 func (c *Collection) BulkWrite(operations []BulkOperation, ordered bool) (*BulkWriteResult, error) {
+	if err := c.checkWritable(); err != nil {
+		return nil, err
+	}
 	result := &BulkWriteResult{
 		InsertedIds: make([]string, 0),
 		Errors:      make([]string, 0),
@@ -2461,10 +2661,10 @@ func (c *Collection) BulkWrite(operations []BulkOperation, ordered bool) (*BulkW
 			if op.Filter == nil || op.Update == nil {
 				err = fmt.Errorf("operation %d: update requires filter and update", i)
 			} else {
-				var count int
-				count, err = c.UpdateMany(op.Filter, op.Update)
+				var updated *UpdateResult
+				updated, err = c.UpdateMany(op.Filter, op.Update)
 				if err == nil {
-					result.ModifiedCount += count
+					result.ModifiedCount += updated.ModifiedCount
 				}
 			}
 
@@ -2472,10 +2672,10 @@ func (c *Collection) BulkWrite(operations []BulkOperation, ordered bool) (*BulkW
 			if op.Filter == nil {
 				err = fmt.Errorf("operation %d: delete requires filter", i)
 			} else {
-				var count int
-				count, err = c.DeleteMany(op.Filter)
+				var deleted *DeleteResult
+				deleted, err = c.DeleteMany(op.Filter)
 				if err == nil {
-					result.DeletedCount += count
+					result.DeletedCount += deleted.DeletedCount
 				}
 			}
 
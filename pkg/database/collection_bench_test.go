@@ -238,3 +238,52 @@ func BenchmarkBulkInsert(b *testing.B) {
 		}
 	}
 }
+
+// BenchmarkBulkInsertOneByOne inserts the documents of BenchmarkBulkInsert
+// one at a time, as a baseline for the batched path
+func BenchmarkBulkInsertOneByOne(b *testing.B) {
+	testDir := "./bench_bulk_one_by_one"
+	defer os.RemoveAll(testDir)
+
+	config := DefaultConfig(testDir)
+	db, err := Open(config)
+	if err != nil {
+		b.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("bench")
+
+	// Prepare docs
+	docs := make([]map[string]interface{}, 100)
+	for i := range docs {
+		docs[i] = map[string]interface{}{
+			"name": fmt.Sprintf("User%d", i),
+			"age":  25 + (i % 50),
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, doc := range docs {
+			if _, err := coll.InsertOne(doc); err != nil {
+				b.Fatalf("Insert failed: %v", err)
+			}
+		}
+	}
+}
+
+// BenchmarkSeedDataset benchmarks generating and inserting the standard
+// dataset
+func BenchmarkSeedDataset(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		db, err := Open(DefaultConfig(b.TempDir()))
+		if err != nil {
+			b.Fatalf("Failed to open database: %v", err)
+		}
+		if err := SeedDataset(db.Collection("bench"), DatasetSpec{Seed: 1, Count: 10000}); err != nil {
+			b.Fatalf("SeedDataset failed: %v", err)
+		}
+		db.Close()
+	}
+}
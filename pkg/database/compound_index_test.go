@@ -313,4 +313,100 @@ func TestCompoundIndexUniqueness(t *testing.T) {
 	if err != nil {
 		t.Errorf("Should allow different compound key: %v", err)
 	}
+
+	// Updating a document onto an existing tuple is rejected too
+	err = coll.UpdateOne(
+		map[string]interface{}{"name": "Alice Alt"},
+		map[string]interface{}{"$set": map[string]interface{}{"username": "alice123"}},
+	)
+	if err == nil {
+		t.Error("Expected duplicate key error when updating onto an existing compound key")
+	}
+
+	count, _ := coll.Count(map[string]interface{}{"username": "alice123"})
+	if count != 1 {
+		t.Errorf("Expected 1 document with the original tuple, got %d", count)
+	}
+}
+
+func TestCompoundIndexPrefixRange(t *testing.T) {
+	dir := "./test_compound_prefix_range"
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("users")
+	if err := coll.CreateCompoundIndex([]string{"country", "age"}, false); err != nil {
+		t.Fatalf("Failed to create compound index: %v", err)
+	}
+
+	for _, country := range []string{"CA", "DE", "US"} {
+		for age := int64(10); age <= 50; age += 10 {
+			if _, err := coll.InsertOne(map[string]interface{}{"country": country, "age": age}); err != nil {
+				t.Fatalf("Failed to insert document: %v", err)
+			}
+		}
+	}
+
+	t.Run("Prefix match", func(t *testing.T) {
+		filter := map[string]interface{}{"country": "US"}
+		plan := coll.Explain(filter)
+		if plan["indexName"] != "country_age_1" {
+			t.Errorf("Expected country_age_1 to serve the query, got %v", plan)
+		}
+
+		results, err := coll.Find(filter)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if len(results) != 5 {
+			t.Errorf("Expected 5 results, got %d", len(results))
+		}
+	})
+
+	t.Run("Prefix with range", func(t *testing.T) {
+		filter := map[string]interface{}{
+			"country": "US",
+			"age":     map[string]interface{}{"$gt": int64(20), "$lte": int64(40)},
+		}
+		plan := coll.Explain(filter)
+		if plan["indexName"] != "country_age_1" || plan["scanType"] != "INDEX_RANGE" {
+			t.Errorf("Expected a range scan of country_age_1, got %v", plan)
+		}
+
+		results, err := coll.Find(filter)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("Expected 2 results, got %d", len(results))
+		}
+		for _, doc := range results {
+			country, _ := doc.Get("country")
+			age, _ := doc.Get("age")
+			if country != "US" || age.(int64) <= 20 || age.(int64) > 40 {
+				t.Errorf("Unexpected result country=%v age=%v", country, age)
+			}
+		}
+	})
+
+	t.Run("Second field alone", func(t *testing.T) {
+		filter := map[string]interface{}{"age": int64(20)}
+		plan := coll.Explain(filter)
+		if plan["scanType"] != "COLLECTION_SCAN" {
+			t.Errorf("Expected a collection scan, got %v", plan)
+		}
+
+		results, err := coll.Find(filter)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if len(results) != 3 {
+			t.Errorf("Expected 3 results, got %d", len(results))
+		}
+	})
 }
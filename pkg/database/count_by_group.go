@@ -0,0 +1,158 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/index"
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+// CountByGroup counts the documents matching the filter per value of field,
+// like a $group on the field summing 1. Documents without the field are
+// counted under nil. Arrays and embedded documents, which can't be map
+// keys, are counted under their string form.
+//
+// When a ready single-field B+ tree index covers the field, and the filter
+// is empty or only constrains the field, the counts are read from the
+// document ids of the index entries without loading documents. Partial indexes, compound indexes and
+// filters on other fields fall back to scanning the matching documents.
+// Cold documents, which have no index entries, are always scanned.
+func (c *Collection) CountByGroup(field string, filter map[string]interface{}) (map[interface{}]int64, error) {
+	if c.db != nil && !c.db.IsOpen() {
+		return nil, ErrDatabaseClosed
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	q := query.NewQuery(filter)
+	var counts map[interface{}]int64
+	var err error
+	counted := false
+	if idx := c.groupIndex(field, filter); idx != nil {
+		counts, counted, err = c.countIndexGroups(idx, q)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !counted {
+		counts, err = c.countDocumentGroups(field, q)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cold, err := c.coldDocuments()
+	if err != nil {
+		return nil, err
+	}
+	if err := countGroups(counts, field, q, cold); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// groupIndex returns the index CountByGroup can read the groups of field
+// from under filter, nil if there is none
+// Must be called with c.mu held
+func (c *Collection) groupIndex(field string, filter map[string]interface{}) *index.Index {
+	for key := range filter {
+		if key != field {
+			return nil
+		}
+	}
+	for _, idx := range c.indexes {
+		if !idx.IsCompound() && !idx.IsPartial() && idx.IsReady() && idx.FieldPath() == field {
+			return idx
+		}
+	}
+	return nil
+}
+
+// countIndexGroups counts the hot documents per key of a single-field
+// index from the document ids of its entries, testing the filter against
+// each key rather than the documents. It returns false, and the documents
+// must be scanned, if the entries don't name each stored document at most
+// once.
+// Must be called with c.mu held
+func (c *Collection) countIndexGroups(idx *index.Index, q *query.Query) (map[interface{}]int64, bool, error) {
+	idx.Usage().Record()
+	field := idx.FieldPath()
+	keys, values := idx.RangeScan(nil, nil)
+
+	counts := make(map[interface{}]int64)
+	seen := make(map[string]bool, len(values))
+	for i, key := range keys {
+		id, ok := values[i].(string)
+		if !ok || seen[id] || !c.docStore.Exists(id) {
+			return nil, false, nil
+		}
+		seen[id] = true
+
+		probe := document.NewDocument()
+		if err := probe.SetNested(field, key); err != nil {
+			return nil, false, err
+		}
+		matches, err := q.Matches(probe)
+		if err != nil {
+			return nil, false, err
+		}
+		if matches {
+			counts[groupKey(key)]++
+		}
+	}
+
+	// Documents without the field have no index entry
+	missing := int64(c.docStore.Count() - len(seen))
+	if missing > 0 {
+		matches, err := q.Matches(document.NewDocument())
+		if err != nil {
+			return nil, false, err
+		}
+		if matches {
+			counts[nil] += missing
+		}
+	}
+	return counts, true, nil
+}
+
+// countDocumentGroups counts the matching hot documents per value of field
+// by scanning them
+// Must be called with c.mu held
+func (c *Collection) countDocumentGroups(field string, q *query.Query) (map[interface{}]int64, error) {
+	docs, err := c.getAllDocuments()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load documents: %w", err)
+	}
+	counts := make(map[interface{}]int64)
+	if err := countGroups(counts, field, q, docs); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// countGroups adds the documents matching q to counts per value of field
+func countGroups(counts map[interface{}]int64, field string, q *query.Query, docs []*document.Document) error {
+	for _, doc := range docs {
+		matches, err := q.Matches(doc)
+		if err != nil {
+			return err
+		}
+		if !matches {
+			continue
+		}
+		value, _ := doc.Get(field)
+		counts[groupKey(value)]++
+	}
+	return nil
+}
+
+// groupKey returns a value usable as a map key: arrays and embedded
+// documents are replaced by their string form
+func groupKey(value interface{}) interface{} {
+	switch value.(type) {
+	case []interface{}, map[string]interface{}, *document.Document, []byte:
+		return fmt.Sprint(value)
+	}
+	return value
+}
@@ -0,0 +1,210 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCountByGroup(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	orders := db.Collection("orders")
+	statuses := []string{"new", "paid", "paid", "shipped", "shipped", "shipped"}
+	for i, status := range statuses {
+		orders.InsertOne(map[string]interface{}{"_id": fmt.Sprintf("o%d", i), "status": status, "total": int64(i * 10)})
+	}
+	orders.InsertOne(map[string]interface{}{"_id": "o6", "total": int64(60)})
+
+	counts, err := orders.CountByGroup("status", nil)
+	if err != nil {
+		t.Fatalf("CountByGroup failed: %v", err)
+	}
+	want := map[interface{}]int64{"new": 1, "paid": 2, "shipped": 3, nil: 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("Expected %v, got %v", want, counts)
+	}
+
+	counts, _ = orders.CountByGroup("status", map[string]interface{}{"total": map[string]interface{}{"$gte": int64(20)}})
+	want = map[interface{}]int64{"paid": 1, "shipped": 3, nil: 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("Expected %v with a filter, got %v", want, counts)
+	}
+}
+
+func TestCountByGroupIndexed(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	regions := db.Collection("regions")
+	for i := 0; i < 5; i++ {
+		regions.InsertOne(map[string]interface{}{"code": fmt.Sprintf("r%d", i), "size": int64(i)})
+	}
+	regions.InsertOne(map[string]interface{}{"size": int64(9)})
+	if err := regions.CreateIndex("code", true); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+	idx := regions.indexes["code_1"]
+
+	// The counts come from the index
+	counts, err := regions.CountByGroup("code", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("CountByGroup failed: %v", err)
+	}
+	want := map[interface{}]int64{"r0": 1, "r1": 1, "r2": 1, "r3": 1, "r4": 1, nil: 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("Expected %v, got %v", want, counts)
+	}
+	if ops := idx.Usage().Usage().Ops; ops != 1 {
+		t.Errorf("Expected the index to be used once, got %d", ops)
+	}
+
+	// A filter on the field is tested against the keys
+	counts, _ = regions.CountByGroup("code", map[string]interface{}{"code": map[string]interface{}{"$in": []interface{}{"r1", "r3"}}})
+	want = map[interface{}]int64{"r1": 1, "r3": 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("Expected %v with a filter on the field, got %v", want, counts)
+	}
+	counts, _ = regions.CountByGroup("code", map[string]interface{}{"code": map[string]interface{}{"$ne": "r0"}})
+	if total, _ := regions.Count(map[string]interface{}{"code": map[string]interface{}{"$ne": "r0"}}); len(counts) != total {
+		t.Errorf("Expected %d groups with $ne, as many as Count matches, got %v", total, counts)
+	}
+	if ops := idx.Usage().Usage().Ops; ops != 3 {
+		t.Errorf("Expected the index to be used 3 times, got %d", ops)
+	}
+
+	// A filter on another field scans the documents
+	counts, _ = regions.CountByGroup("code", map[string]interface{}{"size": map[string]interface{}{"$lt": int64(2)}})
+	want = map[interface{}]int64{"r0": 1, "r1": 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("Expected %v with a filter on size, got %v", want, counts)
+	}
+	if ops := idx.Usage().Usage().Ops; ops != 3 {
+		t.Errorf("Expected the scan not to use the index, got %d uses", ops)
+	}
+
+	// Cold documents are counted too
+	if _, err := regions.Archive(map[string]interface{}{"code": "r4"}); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	counts, _ = regions.CountByGroup("code", nil)
+	if counts["r4"] != 1 || counts[nil] != 1 {
+		t.Errorf("Expected the archived r4 counted once, got %v", counts)
+	}
+}
+
+func TestCountByGroupNonUniqueIndex(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	if err := users.CreateIndex("city", false); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+	for i, city := range []interface{}{"Austin", "Boston", "Chicago", nil} {
+		users.InsertOne(map[string]interface{}{"_id": fmt.Sprintf("u%d", i), "city": city})
+	}
+	users.InsertOne(map[string]interface{}{"_id": "u4"})
+	users.InsertOne(map[string]interface{}{"_id": "u5", "name": "no city"})
+
+	// Documents without the field, or with it null, are counted under nil
+	counts, err := users.CountByGroup("city", nil)
+	if err != nil {
+		t.Fatalf("CountByGroup failed: %v", err)
+	}
+	want := map[interface{}]int64{"Austin": 1, "Boston": 1, "Chicago": 1, nil: 3}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("Expected %v, got %v", want, counts)
+	}
+	if ops := users.indexes["city_1"].Usage().Usage().Ops; ops != 1 {
+		t.Errorf("Expected the index to be used once, got %d", ops)
+	}
+
+	// As for Count, a null filter matches only the explicit null
+	counts, _ = users.CountByGroup("city", map[string]interface{}{"city": nil})
+	want = map[interface{}]int64{nil: 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("Expected %v with a filter on null, got %v", want, counts)
+	}
+}
+
+func TestCountByGroupDuplicateValues(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	members := db.Collection("members")
+	for i, team := range []string{"red", "red", "red", "blue"} {
+		members.InsertOne(map[string]interface{}{"_id": fmt.Sprintf("m%d", i), "team": team})
+	}
+	members.InsertOne(map[string]interface{}{"_id": "m4"})
+	if err := members.CreateIndex("team", false); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+	members.InsertOne(map[string]interface{}{"_id": "m5", "team": "blue"})
+
+	// Repeated values are counted from the index
+	counts, err := members.CountByGroup("team", nil)
+	if err != nil {
+		t.Fatalf("CountByGroup failed: %v", err)
+	}
+	want := map[interface{}]int64{"red": 3, "blue": 2, nil: 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("Expected %v, got %v", want, counts)
+	}
+	if ops := members.indexes["team_1"].Usage().Usage().Ops; ops != 1 {
+		t.Errorf("Expected the index to be used once, got %d", ops)
+	}
+
+	// Writes move documents between groups
+	members.UpdateOne(map[string]interface{}{"_id": "m0"}, map[string]interface{}{"$set": map[string]interface{}{"team": "blue"}})
+	members.DeleteOne(map[string]interface{}{"_id": "m1"})
+	counts, _ = members.CountByGroup("team", map[string]interface{}{"team": map[string]interface{}{"$in": []interface{}{"red", "blue"}}})
+	want = map[interface{}]int64{"red": 1, "blue": 3}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("Expected %v after writes, got %v", want, counts)
+	}
+	if ops := members.indexes["team_1"].Usage().Usage().Ops; ops != 2 {
+		t.Errorf("Expected the index to be used twice, got %d", ops)
+	}
+	if blue, _ := members.Find(map[string]interface{}{"team": "blue"}); len(blue) != 3 {
+		t.Errorf("Expected Find to return the 3 blue members through the index, got %d", len(blue))
+	}
+
+	// Index entries that don't name each stored document once can't be
+	// counted, so the documents are scanned
+	teams := db.Collection("teams")
+	for i := 0; i < 3; i++ {
+		teams.InsertOne(map[string]interface{}{"_id": fmt.Sprintf("t%d", i), "color": fmt.Sprintf("c%d", i)})
+	}
+	teams.InsertOne(map[string]interface{}{"_id": "t3"})
+	if err := teams.CreateIndex("color", false); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+	idx := teams.indexes["color_1"]
+	idx.Insert("c9", "t0") // t0 listed under a second key
+	counts, _ = teams.CountByGroup("color", nil)
+	want = map[interface{}]int64{"c0": 1, "c1": 1, "c2": 1, nil: 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("Expected %v from a scan, got %v", want, counts)
+	}
+
+	idx.Delete("c9")
+	idx.Insert("c9", "t9") // Not a stored document
+	counts, _ = teams.CountByGroup("color", nil)
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("Expected %v from a scan, got %v", want, counts)
+	}
+}
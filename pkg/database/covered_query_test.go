@@ -0,0 +1,125 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+func TestCoveredQueryFromCompoundIndex(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	for i, name := range []string{"alice", "bob", "carol"} {
+		users.InsertOne(map[string]interface{}{
+			"user_id": int64(i + 1),
+			"email":   name + "@example.com",
+			"name":    name,
+		})
+	}
+	if err := users.CreateCompoundIndex([]string{"user_id", "email"}, true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	// "get email by user_id"
+	filter := map[string]interface{}{"user_id": int64(2)}
+	options := &QueryOptions{Projection: map[string]bool{"email": true, "_id": false}}
+
+	explain := users.ExplainWithOptions(filter, options)
+	if explain["isCovered"] != true {
+		t.Fatalf("Expected a covered query, got %v", explain)
+	}
+
+	docs, err := users.FindWithOptions(filter, options)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("Expected 1 document, got %d", len(docs))
+	}
+	if email, _ := docs[0].Get("email"); email != "bob@example.com" {
+		t.Errorf("Expected bob@example.com, got %v", email)
+	}
+	if len(docs[0].Keys()) != 1 {
+		t.Errorf("Expected only the email field, got %v", docs[0].Keys())
+	}
+}
+
+func TestCoveredQueryRangeAndSort(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	for i := 1; i <= 5; i++ {
+		users.InsertOne(map[string]interface{}{"age": int64(20 + i), "name": "user"})
+	}
+	users.CreateIndex("age", true)
+
+	// $gt bounds are rechecked on the index entries
+	filter := map[string]interface{}{"age": map[string]interface{}{"$gt": int64(22)}}
+	options := &QueryOptions{
+		Projection: map[string]bool{"age": true},
+		Sort:       []query.SortField{{Field: "age", Ascending: false}},
+	}
+	if explain := users.ExplainWithOptions(filter, options); explain["isCovered"] != true {
+		t.Fatalf("Expected a covered query, got %v", explain)
+	}
+
+	docs, err := users.FindWithOptions(filter, options)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(docs) != 3 {
+		t.Fatalf("Expected 3 documents, got %d", len(docs))
+	}
+	if age, _ := docs[0].Get("age"); age != int64(25) {
+		t.Errorf("Expected age 25 first, got %v", age)
+	}
+}
+
+func TestCoveredQueryNeedsIndexFields(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	users.InsertOne(map[string]interface{}{"age": int64(30), "name": "alice"})
+	users.CreateIndex("age", true)
+
+	tests := []struct {
+		name    string
+		filter  map[string]interface{}
+		options *QueryOptions
+	}{
+		{"projected field not in index", map[string]interface{}{"age": int64(30)},
+			&QueryOptions{Projection: map[string]bool{"name": true}}},
+		{"filtered field not in index", map[string]interface{}{"age": int64(30), "name": "alice"},
+			&QueryOptions{Projection: map[string]bool{"age": true}}},
+		{"sorted field not in index", map[string]interface{}{"age": int64(30)},
+			&QueryOptions{Projection: map[string]bool{"age": true}, Sort: []query.SortField{{Field: "name", Ascending: true}}}},
+		{"exclusion projection", map[string]interface{}{"age": int64(30)},
+			&QueryOptions{Projection: map[string]bool{"_id": false}}},
+	}
+
+	for _, tt := range tests {
+		if explain := users.ExplainWithOptions(tt.filter, tt.options); explain["isCovered"] != false {
+			t.Errorf("%s: expected query not to be covered", tt.name)
+		}
+		docs, err := users.FindWithOptions(tt.filter, tt.options)
+		if err != nil {
+			t.Fatalf("%s: find failed: %v", tt.name, err)
+		}
+		if len(docs) != 1 {
+			t.Errorf("%s: expected 1 document, got %d", tt.name, len(docs))
+		}
+	}
+}
@@ -4,24 +4,35 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
+	"github.com/mnohosten/laura-db/pkg/aggregation"
 	"github.com/mnohosten/laura-db/pkg/document"
 	"github.com/mnohosten/laura-db/pkg/query"
 )
 
-// Cursor represents an iterator over query results
+// Cursor represents an iterator over query results. Query cursors hold
+// their results in memory; aggregation cursors read them from a streaming
+// pipeline as they are fetched.
 type Cursor struct {
 	id           string
 	collection   *Collection
 	query        *query.Query
 	results      []*document.Document
+	source       aggregation.Iterator // Streaming results, nil for query cursors
+	lookahead    *document.Document   // Next document read from source
+	sourceErr    error                // Error read from source, returned by the next fetch
+	sourceClosed bool
 	position     int
 	batchSize    int
 	timeout      time.Duration
 	lastAccessed time.Time
 	exhausted    bool
+	sortFields   []query.SortField // Order of a resumable cursor, nil otherwise
+	keys         [][]interface{}   // Sort keys of the results of a resumable cursor
+	resumeAfter  string            // Token a resumable cursor started after
 	mu           sync.RWMutex
 }
 
@@ -29,13 +40,24 @@ type Cursor struct {
 type CursorOptions struct {
 	BatchSize int           // Number of documents to fetch per batch (default: 100)
 	Timeout   time.Duration // Cursor idle timeout (default: 10 minutes)
+
+	// Resumable orders results by _id after the sort fields and lets the
+	// cursor issue resume tokens
+	Resumable bool
+	// ResumeAfter is a resume token; the cursor continues after its
+	// position. Implies Resumable.
+	ResumeAfter string
+	// ResumeTokenTTL is how long after issue a resume token is accepted
+	// (default: 1 hour, 0 accepts any age)
+	ResumeTokenTTL time.Duration
 }
 
 // DefaultCursorOptions returns default cursor options
 func DefaultCursorOptions() *CursorOptions {
 	return &CursorOptions{
-		BatchSize: 100,
-		Timeout:   10 * time.Minute,
+		BatchSize:      100,
+		Timeout:        10 * time.Minute,
+		ResumeTokenTTL: time.Hour,
 	}
 }
 
@@ -67,10 +89,17 @@ func NewCursor(collection *Collection, q *query.Query, options *CursorOptions) (
 		exhausted:    false,
 	}
 
+	if options.Resumable || options.ResumeAfter != "" {
+		if err := cursor.executeResumable(q, options); err != nil {
+			return nil, err
+		}
+		return cursor, nil
+	}
+
 	// Execute query and store results
 	// Note: In a production implementation, this would use iterators
 	// to avoid loading all results into memory at once
-	results, err := collection.executeQuery(q)
+	results, err := collection.readQuery(q)
 	if err != nil {
 		return nil, err
 	}
@@ -84,6 +113,82 @@ func NewCursor(collection *Collection, q *query.Query, options *CursorOptions) (
 	return cursor, nil
 }
 
+// newStreamCursor creates a cursor reading documents from source
+func newStreamCursor(collection *Collection, source aggregation.Iterator, options *CursorOptions) (*Cursor, error) {
+	if options == nil {
+		options = DefaultCursorOptions()
+	}
+
+	cursorID, err := generateCursorID()
+	if err != nil {
+		source.Close()
+		return nil, fmt.Errorf("failed to generate cursor ID: %w", err)
+	}
+
+	return &Cursor{
+		id:           cursorID,
+		collection:   collection,
+		source:       source,
+		batchSize:    options.BatchSize,
+		timeout:      options.Timeout,
+		lastAccessed: time.Now(),
+	}, nil
+}
+
+// fill reads the next document of a streaming cursor into the lookahead,
+// reporting whether a document or an error is pending. Must hold c.mu.
+func (c *Cursor) fill() bool {
+	if c.lookahead != nil || c.sourceErr != nil {
+		return true
+	}
+	if c.exhausted || c.source == nil {
+		return false
+	}
+
+	doc, err := c.source.Next()
+	switch {
+	case err == io.EOF:
+		c.exhausted = true
+		c.closeSource()
+		return false
+	case err != nil:
+		c.sourceErr = err
+	default:
+		c.lookahead = doc
+	}
+	return true
+}
+
+// nextFromSource returns the next document of a streaming cursor. Must hold c.mu.
+func (c *Cursor) nextFromSource() (*document.Document, error) {
+	if !c.fill() {
+		return nil, fmt.Errorf("cursor exhausted")
+	}
+	if err := c.sourceErr; err != nil {
+		c.sourceErr = nil
+		c.exhausted = true
+		c.closeSource()
+		return nil, err
+	}
+
+	doc := c.lookahead
+	c.lookahead = nil
+	c.position++
+
+	// Read ahead so the cursor is marked exhausted after its last document
+	c.fill()
+	return doc, nil
+}
+
+// closeSource releases the pipeline of a streaming cursor, removing any
+// spill files. Must hold c.mu.
+func (c *Cursor) closeSource() {
+	if !c.sourceClosed {
+		c.sourceClosed = true
+		c.source.Close()
+	}
+}
+
 // ID returns the cursor's unique identifier
 func (c *Cursor) ID() string {
 	return c.id
@@ -91,6 +196,12 @@ func (c *Cursor) ID() string {
 
 // HasNext returns true if there are more documents to fetch
 func (c *Cursor) HasNext() bool {
+	if c.source != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.fill()
+	}
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return !c.exhausted && c.position < len(c.results)
@@ -103,6 +214,9 @@ func (c *Cursor) Next() (*document.Document, error) {
 
 	c.lastAccessed = time.Now()
 
+	if c.source != nil {
+		return c.nextFromSource()
+	}
 	if c.exhausted {
 		return nil, fmt.Errorf("cursor exhausted")
 	}
@@ -130,6 +244,21 @@ func (c *Cursor) NextBatch() ([]*document.Document, error) {
 
 	c.lastAccessed = time.Now()
 
+	if c.source != nil {
+		batch := make([]*document.Document, 0, c.batchSize)
+		for len(batch) < c.batchSize && c.fill() {
+			if c.sourceErr != nil && len(batch) > 0 {
+				break // Return the error with the next batch
+			}
+			doc, err := c.nextFromSource()
+			if err != nil {
+				return nil, err
+			}
+			batch = append(batch, doc)
+		}
+		return batch, nil
+	}
+
 	// Return empty batch if already exhausted or no more results
 	if c.exhausted || c.position >= len(c.results) {
 		c.exhausted = true
@@ -154,13 +283,26 @@ func (c *Cursor) NextBatch() ([]*document.Document, error) {
 	return batch, nil
 }
 
-// Count returns the total number of documents in the result set
+// Count returns the total number of documents in the result set. For
+// aggregation cursors, whose results are streamed, Count and Remaining only
+// cover the documents read from the pipeline so far.
 func (c *Cursor) Count() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
+	if c.source != nil {
+		return c.position + c.buffered()
+	}
 	return len(c.results)
 }
 
+// buffered returns 1 if a streaming cursor has read ahead a document
+func (c *Cursor) buffered() int {
+	if c.lookahead != nil {
+		return 1
+	}
+	return 0
+}
+
 // Position returns the current position in the result set
 func (c *Cursor) Position() int {
 	c.mu.RLock()
@@ -179,6 +321,9 @@ func (c *Cursor) BatchSize() int {
 func (c *Cursor) Remaining() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
+	if c.source != nil {
+		return c.buffered()
+	}
 	return len(c.results) - c.position
 }
 
@@ -195,6 +340,10 @@ func (c *Cursor) Close() {
 	defer c.mu.Unlock()
 	c.exhausted = true
 	c.results = nil
+	if c.source != nil {
+		c.closeSource()
+		c.lookahead = nil
+	}
 }
 
 // IsTimedOut returns true if the cursor has exceeded its idle timeout
@@ -220,6 +369,9 @@ func NewCursorManager() *CursorManager {
 // CreateCursor creates and registers a new cursor
 func (cm *CursorManager) CreateCursor(collection *Collection, q *query.Query, options *CursorOptions) (*Cursor, error) {
 	cursor, err := NewCursor(collection, q, options)
+	if collection != nil {
+		collection.persistUpgrades()
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -238,13 +390,13 @@ func (cm *CursorManager) GetCursor(cursorID string) (*Cursor, error) {
 	cm.mu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("cursor not found: %s", cursorID)
+		return nil, kindOf(ErrNotFound, "cursor not found: %s", cursorID)
 	}
 
 	// Check if cursor has timed out
 	if cursor.IsTimedOut() {
 		cm.CloseCursor(cursorID)
-		return nil, fmt.Errorf("cursor timed out: %s", cursorID)
+		return nil, kindOf(ErrTimeout, "cursor timed out: %s", cursorID)
 	}
 
 	return cursor, nil
@@ -257,7 +409,7 @@ func (cm *CursorManager) CloseCursor(cursorID string) error {
 
 	cursor, exists := cm.cursors[cursorID]
 	if !exists {
-		return fmt.Errorf("cursor not found: %s", cursorID)
+		return kindOf(ErrNotFound, "cursor not found: %s", cursorID)
 	}
 
 	cursor.Close()
@@ -289,6 +441,19 @@ func (cm *CursorManager) ActiveCursors() int {
 	return len(cm.cursors)
 }
 
+// CloseAll closes and removes every cursor, returning how many were open
+func (cm *CursorManager) CloseAll() int {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	closed := len(cm.cursors)
+	for id, cursor := range cm.cursors {
+		cursor.Close()
+		delete(cm.cursors, id)
+	}
+	return closed
+}
+
 // generateCursorID generates a unique cursor identifier
 func generateCursorID() (string, error) {
 	bytes := make([]byte, 16)
@@ -0,0 +1,313 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+// Resumable cursors order their results by the query's sort fields followed
+// by _id, so every document has a unique position. A resume token records
+// the sort key of the last document returned; a cursor created with
+// ResumeAfter skips every document up to and including that key and
+// continues from there, even after a server restart. Documents inserted
+// before the token's position since it was issued are not returned, and
+// documents that moved past it are returned again.
+
+// missingValue stands for a sort field a document doesn't have. Missing
+// fields sort before every value, as in query sorting.
+type missingValue struct{}
+
+// resumeToken is the decoded form of a cursor resume token
+type resumeToken struct {
+	Sort   []string     `json:"s"` // "field:1" or "field:-1" for each sort field
+	Key    []tokenValue `json:"k"` // Sort key of the last document returned
+	Issued int64        `json:"t"` // Unix nanoseconds
+}
+
+// tokenValue is a typed sort key value, so keys compare the same after a
+// round trip through JSON
+type tokenValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v,omitempty"`
+}
+
+// resumableSort returns the sort fields of a resumable cursor: the query's
+// sort with _id appended as a tie breaker
+func resumableSort(fields []query.SortField) []query.SortField {
+	for _, f := range fields {
+		if f.Field == "_id" {
+			return fields
+		}
+	}
+	sortFields := make([]query.SortField, 0, len(fields)+1)
+	sortFields = append(sortFields, fields...)
+	return append(sortFields, query.SortField{Field: "_id", Ascending: true})
+}
+
+// sortSpec describes sort fields for matching a token to its query
+func sortSpec(fields []query.SortField) []string {
+	spec := make([]string, len(fields))
+	for i, f := range fields {
+		if f.Ascending {
+			spec[i] = f.Field + ":1"
+		} else {
+			spec[i] = f.Field + ":-1"
+		}
+	}
+	return spec
+}
+
+// sortKey returns the values of the sort fields of a document
+func sortKey(doc *document.Document, fields []query.SortField) []interface{} {
+	key := make([]interface{}, len(fields))
+	for i, f := range fields {
+		value, exists := doc.Get(f.Field)
+		if !exists {
+			key[i] = missingValue{}
+			continue
+		}
+		key[i] = value
+	}
+	return key
+}
+
+// compareSortKeys compares two sort keys in the order the fields sort
+func compareSortKeys(a, b []interface{}, fields []query.SortField) int {
+	for i, f := range fields {
+		cmp := compareSortValues(a[i], b[i])
+		if cmp == 0 {
+			continue
+		}
+		if !f.Ascending {
+			cmp = -cmp
+		}
+		return cmp
+	}
+	return 0
+}
+
+// compareSortValues compares sort key values, with missing values first
+func compareSortValues(a, b interface{}) int {
+	_, missingA := a.(missingValue)
+	_, missingB := b.(missingValue)
+	switch {
+	case missingA && missingB:
+		return 0
+	case missingA:
+		return -1
+	case missingB:
+		return 1
+	}
+	return document.CompareValues(a, b)
+}
+
+// encodeResumeToken returns the token for the position after a sort key
+func encodeResumeToken(key []interface{}, fields []query.SortField) (string, error) {
+	token := resumeToken{
+		Sort:   sortSpec(fields),
+		Key:    make([]tokenValue, len(key)),
+		Issued: time.Now().UnixNano(),
+	}
+	for i, value := range key {
+		tv, err := encodeTokenValue(value)
+		if err != nil {
+			return "", err
+		}
+		token.Key[i] = tv
+	}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode resume token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeResumeToken decodes a token issued for the given sort fields,
+// rejecting tokens older than ttl (0 accepts any age)
+func decodeResumeToken(s string, fields []query.SortField, ttl time.Duration) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResumeToken, err)
+	}
+	var token resumeToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResumeToken, err)
+	}
+
+	spec := sortSpec(fields)
+	if len(token.Sort) != len(spec) || len(token.Key) != len(spec) {
+		return nil, fmt.Errorf("%w: issued for a different sort order", ErrInvalidResumeToken)
+	}
+	for i := range spec {
+		if token.Sort[i] != spec[i] {
+			return nil, fmt.Errorf("%w: issued for a different sort order", ErrInvalidResumeToken)
+		}
+	}
+	if ttl > 0 && time.Since(time.Unix(0, token.Issued)) > ttl {
+		return nil, ErrResumeTokenExpired
+	}
+
+	key := make([]interface{}, len(token.Key))
+	for i, tv := range token.Key {
+		value, err := decodeTokenValue(tv)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidResumeToken, err)
+		}
+		key[i] = value
+	}
+	return key, nil
+}
+
+// encodeTokenValue encodes a sort key value with its type. Documents and
+// arrays are kept as JSON, so the types of their elements are only kept as
+// far as JSON does.
+func encodeTokenValue(value interface{}) (tokenValue, error) {
+	switch v := value.(type) {
+	case missingValue:
+		return tokenValue{Type: "missing"}, nil
+	case nil:
+		return tokenValue{Type: "null"}, nil
+	case bool:
+		return tokenValue{Type: "bool", Value: strconv.FormatBool(v)}, nil
+	case int:
+		return tokenValue{Type: "int", Value: strconv.FormatInt(int64(v), 10)}, nil
+	case int32:
+		return tokenValue{Type: "int", Value: strconv.FormatInt(int64(v), 10)}, nil
+	case int64:
+		return tokenValue{Type: "int", Value: strconv.FormatInt(v, 10)}, nil
+	case string:
+		return tokenValue{Type: "string", Value: v}, nil
+	case document.ObjectID:
+		return tokenValue{Type: "oid", Value: v.Hex()}, nil
+	case time.Time:
+		return tokenValue{Type: "date", Value: v.Format(time.RFC3339Nano)}, nil
+	case []byte:
+		return tokenValue{Type: "binary", Value: base64.StdEncoding.EncodeToString(v)}, nil
+	}
+
+	if f, ok := document.ToNumber(value); ok {
+		return tokenValue{Type: "float", Value: strconv.FormatFloat(f, 'g', -1, 64)}, nil
+	}
+	if doc, ok := value.(*document.Document); ok {
+		value = doc.ToMap()
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return tokenValue{}, fmt.Errorf("failed to encode sort key value: %w", err)
+	}
+	return tokenValue{Type: "json", Value: string(data)}, nil
+}
+
+// decodeTokenValue decodes a value encoded by encodeTokenValue
+func decodeTokenValue(tv tokenValue) (interface{}, error) {
+	switch tv.Type {
+	case "missing":
+		return missingValue{}, nil
+	case "null":
+		return nil, nil
+	case "bool":
+		return strconv.ParseBool(tv.Value)
+	case "int":
+		return strconv.ParseInt(tv.Value, 10, 64)
+	case "float":
+		return strconv.ParseFloat(tv.Value, 64)
+	case "string":
+		return tv.Value, nil
+	case "oid":
+		return document.ObjectIDFromHex(tv.Value)
+	case "date":
+		return time.Parse(time.RFC3339Nano, tv.Value)
+	case "binary":
+		return base64.StdEncoding.DecodeString(tv.Value)
+	case "json":
+		var value interface{}
+		if err := json.Unmarshal([]byte(tv.Value), &value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("unknown value type %q", tv.Type)
+}
+
+// executeResumable runs the query of a resumable cursor, starting after
+// options.ResumeAfter if set. Skip only applies to a cursor that doesn't
+// resume, and limit counts from the resume position.
+func (c *Cursor) executeResumable(q *query.Query, options *CursorOptions) error {
+	sortFields := resumableSort(q.GetSort())
+
+	base := query.NewQuery(q.GetFilter()).WithSort(sortFields)
+	if meta := q.GetMeta(); len(meta) > 0 {
+		base = base.WithMeta(meta)
+	}
+	if hint := q.GetHint(); hint != "" {
+		base = base.WithHint(hint)
+	}
+	results, err := c.collection.executeQuery(base)
+	if err != nil {
+		return err
+	}
+	if results, err = c.collection.upgradeDocuments(results); err != nil {
+		return err
+	}
+
+	start := q.GetSkip()
+	if options.ResumeAfter != "" {
+		after, err := decodeResumeToken(options.ResumeAfter, sortFields, options.ResumeTokenTTL)
+		if err != nil {
+			return err
+		}
+		start = 0
+		for start < len(results) && compareSortKeys(sortKey(results[start], sortFields), after, sortFields) <= 0 {
+			start++
+		}
+	}
+	if start > len(results) {
+		start = len(results)
+	}
+	results = results[start:]
+	if limit := q.GetLimit(); limit > 0 && limit < len(results) {
+		results = results[:limit]
+	}
+
+	// Keys are taken before projection, which may drop sort fields
+	c.sortFields = sortFields
+	c.keys = make([][]interface{}, len(results))
+	c.results = make([]*document.Document, len(results))
+	for i, doc := range results {
+		c.keys[i] = sortKey(doc, sortFields)
+		c.results[i] = q.ApplyProjection(doc)
+	}
+	c.resumeAfter = options.ResumeAfter
+	return nil
+}
+
+// Resumable reports whether the cursor issues resume tokens
+func (c *Cursor) Resumable() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sortFields != nil
+}
+
+// ResumeToken returns a token for the position after the last document
+// returned, which a new cursor over the same query can continue from with
+// CursorOptions.ResumeAfter. Before the first document it returns the token
+// the cursor resumed from, or "" if it started from the beginning.
+func (c *Cursor) ResumeToken() (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.sortFields == nil {
+		return "", fmt.Errorf("cursor is not resumable")
+	}
+	if c.position == 0 {
+		return c.resumeAfter, nil
+	}
+	return encodeResumeToken(c.keys[c.position-1], c.sortFields)
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/mnohosten/laura-db/pkg/mvcc"
+	"github.com/mnohosten/laura-db/pkg/query"
 	"github.com/mnohosten/laura-db/pkg/storage"
 )
 
@@ -541,3 +543,98 @@ func TestGenerateCursorID(t *testing.T) {
 		}
 	}
 }
+
+func TestCursorResume(t *testing.T) {
+	coll := createTestCollectionWithData(t, "users", 20)
+	// Equal ages are ordered by _id
+	for i := 0; i < 3; i++ {
+		coll.InsertOne(map[string]interface{}{"name": fmt.Sprintf("dup_%d", i), "age": int64(25)})
+	}
+
+	queryOptions := &QueryOptions{
+		Sort:       []query.SortField{{Field: "age", Ascending: false}},
+		Projection: map[string]bool{"name": true},
+	}
+	cursor, err := coll.FindCursorWithOptions(map[string]interface{}{}, queryOptions, &CursorOptions{BatchSize: 5, Resumable: true})
+	if err != nil {
+		t.Fatalf("Failed to create cursor: %v", err)
+	}
+	if token, err := cursor.ResumeToken(); err != nil || token != "" {
+		t.Errorf("Expected empty token before the first document, got %q, %v", token, err)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 7; i++ {
+		doc, err := cursor.Next()
+		if err != nil {
+			t.Fatalf("Failed to get next document: %v", err)
+		}
+		name, _ := doc.Get("name")
+		seen[name.(string)] = true
+		if _, exists := doc.Get("age"); exists {
+			t.Error("Expected age to be projected out")
+		}
+	}
+	token, err := cursor.ResumeToken()
+	if err != nil || token == "" {
+		t.Fatalf("Failed to get resume token: %q, %v", token, err)
+	}
+	cursor.Close()
+
+	// Writes before the resume position aren't returned, writes after it are
+	coll.InsertOne(map[string]interface{}{"name": "oldest", "age": int64(99)})
+	coll.InsertOne(map[string]interface{}{"name": "youngest", "age": int64(1)})
+
+	resumed, err := coll.FindCursorWithOptions(map[string]interface{}{}, queryOptions, &CursorOptions{BatchSize: 5, ResumeAfter: token})
+	if err != nil {
+		t.Fatalf("Failed to resume cursor: %v", err)
+	}
+	defer resumed.Close()
+
+	if resumed.Count() != 23-7+1 {
+		t.Errorf("Expected %d documents after the resume position, got %d", 23-7+1, resumed.Count())
+	}
+	last := ""
+	for resumed.HasNext() {
+		doc, _ := resumed.Next()
+		name, _ := doc.Get("name")
+		if seen[name.(string)] || name == "oldest" {
+			t.Errorf("Document %v returned again after resuming", name)
+		}
+		last = name.(string)
+	}
+	if last != "youngest" {
+		t.Errorf("Expected the resumed cursor to end with the new youngest document, got %q", last)
+	}
+}
+
+func TestCursorResumeTokenErrors(t *testing.T) {
+	coll := createTestCollectionWithData(t, "users", 5)
+
+	byAge := &QueryOptions{Sort: []query.SortField{{Field: "age", Ascending: true}}}
+	cursor, err := coll.FindCursorWithOptions(map[string]interface{}{}, byAge, &CursorOptions{BatchSize: 5, Resumable: true})
+	if err != nil {
+		t.Fatalf("Failed to create cursor: %v", err)
+	}
+	cursor.Next()
+	token, _ := cursor.ResumeToken()
+
+	// A token only resumes the sort order it was issued for
+	byName := &QueryOptions{Sort: []query.SortField{{Field: "name", Ascending: true}}}
+	if _, err := coll.FindCursorWithOptions(map[string]interface{}{}, byName, &CursorOptions{ResumeAfter: token}); !errors.Is(err, ErrInvalidResumeToken) {
+		t.Errorf("Expected ErrInvalidResumeToken for another sort order, got %v", err)
+	}
+	if _, err := coll.FindCursorWithOptions(map[string]interface{}{}, byAge, &CursorOptions{ResumeAfter: "garbage!"}); !errors.Is(err, ErrInvalidResumeToken) {
+		t.Errorf("Expected ErrInvalidResumeToken for a malformed token, got %v", err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if _, err := coll.FindCursorWithOptions(map[string]interface{}{}, byAge, &CursorOptions{ResumeAfter: token, ResumeTokenTTL: time.Millisecond}); !errors.Is(err, ErrResumeTokenExpired) {
+		t.Errorf("Expected ErrResumeTokenExpired, got %v", err)
+	}
+
+	plain, _ := coll.FindCursor(map[string]interface{}{}, nil)
+	if _, err := plain.ResumeToken(); err == nil {
+		t.Error("Expected an error getting a resume token from a non-resumable cursor")
+	}
+}
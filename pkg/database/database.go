@@ -2,7 +2,9 @@ package database
 
 import (
 	"fmt"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mnohosten/laura-db/pkg/audit"
@@ -13,6 +15,7 @@ import (
 // Database represents a database instance
 type Database struct {
 	name          string
+	dataDir       string
 	collections   map[string]*Collection
 	storage       *storage.StorageEngine
 	txnMgr        *mvcc.TransactionManager
@@ -22,15 +25,132 @@ type Database struct {
 	isOpen        bool
 	ttlStopChan   chan struct{} // Channel to signal TTL cleanup goroutine to stop
 	ttlWaitGroup  sync.WaitGroup
+
+	commitListeners []CommitListener  // Notified of committed session transactions
+	changes         *changeLog        // Oplog and active streams of Collection.Watch
+	indexUsage      *indexUsageStore  // Index usage counters saved across restarts
+	hooks           hookRegistry      // Hooks registered for writes to every collection
+	snapshots       *snapshotRegistry // Open read snapshots
+	quota           *quotaTracker     // Tenant usage and quotas, nil without quotas
+
+	readOnly  atomic.Bool // Writes rejected by SetReadOnly
+	secondary atomic.Bool // Client writes rejected as a replica set secondary
+
+	maxDocumentSize int // Largest document collections accept, in bytes
+	blobThreshold   int // Binary fields of at least this size are stored in chunks (0 disables)
+
+	maxTransactionLifetime time.Duration // Lifetime of read snapshots (0 uses DefaultReadSnapshotLifetime)
+
+	config Config // Configuration opened or last reconfigured with
 }
 
 // Config holds database configuration
 type Config struct {
 	DataDir        string
+	Name           string // Database name in oplog and change event namespaces ("" uses DefaultDatabaseName)
 	BufferPoolSize int
 	AuditConfig    *audit.Config // Optional audit logging configuration
+
+	// CheckpointWALSize checkpoints once the WAL exceeds this many bytes
+	// (0 uses the storage default, negative disables)
+	CheckpointWALSize int64
+
+	// CheckpointInterval checkpoints periodically
+	// (0 uses the storage default, negative disables)
+	CheckpointInterval time.Duration
+
+	// DisableGroupCommit makes every commit issue its own WAL fsync
+	DisableGroupCommit bool
+
+	// GroupCommitLinger is how long a commit waits for concurrent commits
+	// to share its WAL fsync
+	GroupCommitLinger time.Duration
+
+	// DisableFullPageWrites stops logging the image of every page to the
+	// WAL before it is written, trading the repair of pages torn by a crash
+	// for fewer WAL writes and fsyncs
+	DisableFullPageWrites bool
+
+	// TransactionLockTimeout bounds how long a commit or prepare waits for the
+	// transaction manager before failing with mvcc.ErrLockTimeout
+	// (0 uses the default, negative waits forever)
+	TransactionLockTimeout time.Duration
+
+	// MaxTransactionLifetime aborts transactions (and their sessions) open
+	// longer than this with mvcc.ErrTransactionTooOld (0 means unlimited)
+	MaxTransactionLifetime time.Duration
+
+	// MaxTransactionOperations aborts a transaction once it has issued more
+	// than this many writes and deletes (0 means unlimited)
+	MaxTransactionOperations int
+
+	// VersionGCInterval is the time between MVCC version garbage collection
+	// passes (0 uses the mvcc default, negative disables)
+	VersionGCInterval time.Duration
+
+	// VersionGCBatchSize is how many keys a collection pass examines per
+	// version store lock hold (0 uses the mvcc default)
+	VersionGCBatchSize int
+
+	// MaxDocumentSize rejects inserts and updates that would store a
+	// document larger than this many bytes with ErrDocumentTooLarge. A
+	// document must fit in a page, so 0 and larger values use
+	// storage.MaxDocumentSizeForPageSize of the page size.
+	MaxDocumentSize int
+
+	// PageSize is the page size of a new database: 4096 (the default),
+	// 8192, 16384 or 32768 bytes. Larger pages hold larger documents at the
+	// cost of more I/O per page; see the storage package for the tradeoffs.
+	// An existing database keeps the size it was created with, and opening
+	// it with a different non-zero PageSize fails with
+	// storage.ErrPageSizeMismatch. Ignored when PageStore is set.
+	PageSize int
+
+	// BlobThreshold stores top-level binary fields of at least this many
+	// bytes in chunks outside the document, so that they don't count
+	// towards MaxDocumentSize (0 disables)
+	BlobThreshold int
+
+	// InMemory keeps the data pages in a storage.MemoryPageStore instead of
+	// data.db, so documents are lost on Close. The WAL and metadata files
+	// are still written to DataDir.
+	InMemory bool
+
+	// PageStore keeps the data pages in a custom storage.PageStore, such as
+	// an encrypted or memory-mapped one. It takes precedence over InMemory
+	// and is closed with the database.
+	PageStore storage.PageStore
+
+	// WALArchiving logs collection writes to the WAL from the moment the
+	// database opens, so that a replication.WALArchiver started later
+	// ships every write. See SetWALArchiving.
+	WALArchiving bool
+
+	// Durability is the default durability of collection writes: none (the
+	// default), wal or synced. See Durability for what each level loses in
+	// a crash, and SetDurability.
+	Durability Durability
+
+	// Quotas caps the bytes, documents and write rate of each tenant of the
+	// database, rejecting writes beyond them with ErrQuotaExceeded (nil
+	// disables quotas). Usage is saved across restarts.
+	Quotas *QuotaConfig
+
+	// TTLInterval is the time between passes of the background reaper
+	// deleting documents expired by TTL indexes, which also applies tiering
+	// policies (0 uses DefaultTTLInterval)
+	TTLInterval time.Duration
 }
 
+// DefaultDatabaseName names a database opened without Config.Name
+const DefaultDatabaseName = "default"
+
+// DefaultTransactionLockTimeout is used when Config.TransactionLockTimeout is 0
+const DefaultTransactionLockTimeout = 30 * time.Second
+
+// DefaultTTLInterval is used when Config.TTLInterval is 0
+const DefaultTTLInterval = 60 * time.Second
+
 // DefaultConfig returns default configuration
 func DefaultConfig(dataDir string) *Config {
 	return &Config{
@@ -41,9 +161,37 @@ func DefaultConfig(dataDir string) *Config {
 
 // Open opens or creates a database
 func Open(config *Config) (*Database, error) {
+	durability, err := ParseDurability(string(config.Durability))
+	if err != nil {
+		return nil, err
+	}
+
 	// Create storage engine
 	storageConfig := storage.DefaultConfig(config.DataDir)
 	storageConfig.BufferPoolSize = config.BufferPoolSize
+	if config.CheckpointWALSize != 0 {
+		storageConfig.CheckpointWALSize = config.CheckpointWALSize
+	}
+	if config.CheckpointInterval != 0 {
+		storageConfig.CheckpointInterval = config.CheckpointInterval
+	}
+	storageConfig.GroupCommit = !config.DisableGroupCommit
+	storageConfig.GroupCommitLinger = config.GroupCommitLinger
+	storageConfig.FullPageWrites = !config.DisableFullPageWrites
+	storageConfig.PageSize = config.PageSize
+	if config.PageStore != nil {
+		storageConfig.PageStore = config.PageStore
+	} else if config.InMemory {
+		pageSize := config.PageSize
+		if pageSize == 0 {
+			pageSize = storage.PageSize
+		}
+		store, err := storage.NewMemoryPageStoreWithPageSize(pageSize)
+		if err != nil {
+			return nil, err
+		}
+		storageConfig.PageStore = store
+	}
 
 	storageEngine, err := storage.NewStorageEngine(storageConfig)
 	if err != nil {
@@ -52,6 +200,21 @@ func Open(config *Config) (*Database, error) {
 
 	// Create transaction manager
 	txnMgr := mvcc.NewTransactionManager()
+	switch {
+	case config.TransactionLockTimeout > 0:
+		txnMgr.SetLockTimeout(config.TransactionLockTimeout)
+	case config.TransactionLockTimeout == 0:
+		txnMgr.SetLockTimeout(DefaultTransactionLockTimeout)
+	}
+	txnMgr.SetTransactionLimits(config.MaxTransactionLifetime, config.MaxTransactionOperations)
+
+	gcConfig := mvcc.DefaultGCConfig()
+	if config.VersionGCInterval != 0 {
+		gcConfig.Interval = config.VersionGCInterval
+	}
+	if config.VersionGCBatchSize != 0 {
+		gcConfig.BatchSize = config.VersionGCBatchSize
+	}
 
 	// Create audit logger if configured
 	var auditLogger *audit.AuditLogger
@@ -63,23 +226,67 @@ func Open(config *Config) (*Database, error) {
 		}
 	}
 
+	pageDocumentSize := storage.MaxDocumentSizeForPageSize(storageEngine.PageSize())
+	maxDocumentSize := config.MaxDocumentSize
+	if maxDocumentSize <= 0 || maxDocumentSize > pageDocumentSize {
+		maxDocumentSize = pageDocumentSize
+	}
+
+	name := config.Name
+	if name == "" {
+		name = DefaultDatabaseName
+	}
+
 	db := &Database{
-		name:          "default",
-		collections:   make(map[string]*Collection),
-		storage:       storageEngine,
-		txnMgr:        txnMgr,
-		auditLogger:   auditLogger,
-		cursorManager: NewCursorManager(),
-		isOpen:        true,
-		ttlStopChan:   make(chan struct{}),
+		name:            name,
+		dataDir:         config.DataDir,
+		collections:     make(map[string]*Collection),
+		storage:         storageEngine,
+		txnMgr:          txnMgr,
+		auditLogger:     auditLogger,
+		cursorManager:   NewCursorManager(),
+		changes:         newChangeLog(changeLogPath(config.DataDir), storageEngine.WAL()),
+		indexUsage:      loadIndexUsage(indexUsagePath(config.DataDir)),
+		maxDocumentSize: maxDocumentSize,
+		blobThreshold:   config.BlobThreshold,
+		snapshots:       newSnapshotRegistry(),
+		quota:           loadQuotaTracker(config.Quotas, name, tenantUsagePath(config.DataDir)),
+		isOpen:          true,
+		ttlStopChan:     make(chan struct{}),
+
+		maxTransactionLifetime: config.MaxTransactionLifetime,
+		config:                 copyConfig(config),
+	}
+	db.changes.archiving.Store(config.WALArchiving)
+	db.changes.durability.Store(durability.rank())
+
+	// Once a Watch call created the change oplog, writes keep being recorded
+	// across restarts, so resumed streams don't miss any
+	if _, err := os.Stat(db.changes.path); err == nil {
+		if _, err := db.changes.open(); err != nil {
+			storageEngine.Close()
+			return nil, err
+		}
 	}
 
 	// Start TTL cleanup goroutine
-	db.startTTLCleanup()
+	ttlInterval := config.TTLInterval
+	if ttlInterval <= 0 {
+		ttlInterval = DefaultTTLInterval
+	}
+	db.startTTLCleanup(ttlInterval)
 
 	// Start cursor cleanup goroutine
 	db.startCursorCleanup()
 
+	// Start version garbage collection
+	txnMgr.StartGC(gcConfig)
+
+	// Start expired transaction reaper
+	if config.MaxTransactionLifetime > 0 {
+		db.startTransactionReaper(config.MaxTransactionLifetime)
+	}
+
 	return db, nil
 }
 
@@ -92,19 +299,43 @@ func (db *Database) Collection(name string) *Collection {
 		return coll
 	}
 
+	coll := db.newCollection(name)
+	db.collections[name] = coll
+	return coll
+}
+
+// newCollection creates a collection of this database without registering it
+func (db *Database) newCollection(name string) *Collection {
 	// Create document store for this collection
-	docStore := NewDocumentStore(db.storage.DiskManager(), 1000) // 1000 documents cache
+	docStore := NewDocumentStore(db.storage.PageStore(), 1000) // 1000 documents cache
+	docStore.snapshots = db.snapshots
+	docStore.quota = db.quota
+	if db.blobThreshold > 0 {
+		// Chunks of large binary fields are kept in a side store
+		docStore.blobs = newBlobStore(db.blobThreshold, NewDocumentStore(db.storage.PageStore(), 100))
+	}
 
-	// Create new collection
 	coll := NewCollection(name, db.txnMgr, docStore)
+	coll.db = db
+	coll.maxDocumentSize = db.maxDocumentSize
 	coll.database = db.name
 	coll.auditLogger = db.auditLogger
-	db.collections[name] = coll
+	coll.changes = db.changes
+
+	// Reopen the cold tier of a collection archived before
+	if _, err := os.Stat(archiveDir(db.dataDir, name)); err == nil {
+		if archive, err := openColdArchive(archiveDir(db.dataDir, name)); err == nil {
+			coll.archive.Store(archive)
+		}
+	}
 	return coll
 }
 
 // CreateCollection explicitly creates a collection
 func (db *Database) CreateCollection(name string) (*Collection, error) {
+	if err := db.checkWritable(); err != nil {
+		return nil, err
+	}
 	start := time.Now()
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -116,12 +347,7 @@ func (db *Database) CreateCollection(name string) (*Collection, error) {
 		return nil, fmt.Errorf("collection %s already exists", name)
 	}
 
-	// Create document store for this collection
-	docStore := NewDocumentStore(db.storage.DiskManager(), 1000) // 1000 documents cache
-
-	coll := NewCollection(name, db.txnMgr, docStore)
-	coll.database = db.name
-	coll.auditLogger = db.auditLogger
+	coll := db.newCollection(name)
 	db.collections[name] = coll
 
 	// Log successful collection creation
@@ -134,19 +360,31 @@ func (db *Database) CreateCollection(name string) (*Collection, error) {
 
 // DropCollection drops a collection
 func (db *Database) DropCollection(name string) error {
+	if err := db.checkWritable(); err != nil {
+		return err
+	}
 	start := time.Now()
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	if _, exists := db.collections[name]; !exists {
-		err := fmt.Errorf("collection %s does not exist", name)
+	coll, exists := db.collections[name]
+	if !exists {
+		err := kindOf(ErrCollectionNotFound, "collection %s does not exist", name)
 		if db.auditLogger != nil {
 			db.auditLogger.LogOperation(audit.OperationDropCollection, name, db.name, "", false, time.Since(start), err, nil)
 		}
 		return err
 	}
+	if archive := coll.archive.Load(); archive != nil {
+		if err := archive.destroy(); err != nil {
+			return fmt.Errorf("failed to remove archive of %s: %w", name, err)
+		}
+	}
 
 	delete(db.collections, name)
+	db.indexUsage.forgetCollection(name)
+	coll.docStore.releaseQuota()
+	coll.logDrop()
 
 	// Log successful collection drop
 	if db.auditLogger != nil {
@@ -158,6 +396,9 @@ func (db *Database) DropCollection(name string) error {
 
 // RenameCollection renames a collection
 func (db *Database) RenameCollection(oldName, newName string) error {
+	if err := db.checkWritable(); err != nil {
+		return err
+	}
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
@@ -168,7 +409,7 @@ func (db *Database) RenameCollection(oldName, newName string) error {
 	// Check if old collection exists
 	coll, exists := db.collections[oldName]
 	if !exists {
-		return fmt.Errorf("collection %s does not exist", oldName)
+		return kindOf(ErrCollectionNotFound, "collection %s does not exist", oldName)
 	}
 
 	// Check if new collection name already exists
@@ -177,13 +418,49 @@ func (db *Database) RenameCollection(oldName, newName string) error {
 	}
 
 	// Rename the collection
+	if archive := coll.archive.Load(); archive != nil {
+		if err := archive.rename(archiveDir(db.dataDir, newName)); err != nil {
+			return err
+		}
+	}
+	coll.logRename(newName)
 	coll.name = newName
 	db.collections[newName] = coll
 	delete(db.collections, oldName)
+	db.indexUsage.renameCollection(oldName, newName)
 
 	return nil
 }
 
+// SetReadOnly puts the database in read-only (maintenance) mode, or takes it
+// out of it. While read-only, inserts, updates, deletes and changes to
+// indexes and collections fail with ErrReadOnly, and TTL expiry is paused.
+// Reads and cursors are served as usual.
+func (db *Database) SetReadOnly(readOnly bool) {
+	db.readOnly.Store(readOnly)
+}
+
+// SetSecondary marks the database as the data of a replica set secondary,
+// which rejects client writes with ErrReadOnly like read-only mode. The
+// replica set sets it as the node changes role.
+func (db *Database) SetSecondary(secondary bool) {
+	db.secondary.Store(secondary)
+}
+
+// IsReadOnly reports whether the database rejects writes, because of
+// SetReadOnly or as a replica set secondary
+func (db *Database) IsReadOnly() bool {
+	return db.readOnly.Load() || db.secondary.Load()
+}
+
+// checkWritable returns ErrReadOnly if the database rejects writes
+func (db *Database) checkWritable() error {
+	if db.IsReadOnly() {
+		return ErrReadOnly
+	}
+	return nil
+}
+
 // ListCollections returns all collection names
 func (db *Database) ListCollections() []string {
 	db.mu.RLock()
@@ -201,14 +478,23 @@ func (db *Database) BeginTransaction() *mvcc.Transaction {
 	return db.txnMgr.Begin()
 }
 
-// CommitTransaction commits a transaction
+// CommitTransaction commits a transaction and waits for its commit
+// record to be durable in the WAL
 func (db *Database) CommitTransaction(txn *mvcc.Transaction) error {
-	return db.txnMgr.Commit(txn)
+	if err := db.txnMgr.Commit(txn); err != nil {
+		return txnError(err)
+	}
+
+	if _, err := db.storage.LogCommit(uint64(txn.ID)); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 // AbortTransaction aborts a transaction
 func (db *Database) AbortTransaction(txn *mvcc.Transaction) error {
-	return db.txnMgr.Abort(txn)
+	return txnError(db.txnMgr.Abort(txn))
 }
 
 // Close closes the database
@@ -224,6 +510,21 @@ func (db *Database) Close() error {
 	close(db.ttlStopChan)
 	db.ttlWaitGroup.Wait()
 
+	// Close change streams and their oplog
+	if err := db.changes.close(); err != nil {
+		return err
+	}
+
+	if err := db.indexUsage.save(db.collections); err != nil {
+		return err
+	}
+	if err := db.quota.save(); err != nil {
+		return err
+	}
+
+	// Stop version garbage collection
+	db.txnMgr.StopGC()
+
 	// Flush all data
 	if err := db.storage.FlushAll(); err != nil {
 		return fmt.Errorf("failed to flush data: %w", err)
@@ -243,6 +544,52 @@ func (db *Database) Close() error {
 	return nil
 }
 
+// Checkpoint forces a checkpoint: dirty pages are flushed to disk and the
+// WAL is truncated, so recovery after a crash starts from this point
+func (db *Database) Checkpoint() error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if !db.isOpen {
+		return ErrDatabaseClosed
+	}
+
+	if err := db.storage.Checkpoint(); err != nil {
+		return fmt.Errorf("failed to checkpoint: %w", err)
+	}
+
+	// Save index and tenant usage so counters survive a crash after the
+	// checkpoint
+	if err := db.indexUsage.save(db.collections); err != nil {
+		return err
+	}
+	return db.quota.save()
+}
+
+// Name returns the database name
+func (db *Database) Name() string {
+	return db.name
+}
+
+// IsOpen reports whether the database is open (recovery has completed and
+// Close has not been called)
+func (db *Database) IsOpen() bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.isOpen
+}
+
+// StorageStats returns buffer pool, disk and WAL statistics
+func (db *Database) StorageStats() (map[string]interface{}, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if !db.isOpen {
+		return nil, ErrDatabaseClosed
+	}
+	return db.storage.Stats(), nil
+}
+
 // Stats returns database statistics
 func (db *Database) Stats() map[string]interface{} {
 	db.mu.RLock()
@@ -253,32 +600,41 @@ func (db *Database) Stats() map[string]interface{} {
 		collectionStats[name] = coll.Stats()
 	}
 
-	return map[string]interface{}{
-		"name":                  db.name,
-		"collections":           len(db.collections),
-		"collection_stats":      collectionStats,
-		"active_transactions":   db.txnMgr.GetActiveTransactions(),
-		"storage_stats":         db.storage.Stats(),
+	stats := map[string]interface{}{
+		"name":                db.name,
+		"collections":         len(db.collections),
+		"collection_stats":    collectionStats,
+		"active_transactions": db.txnMgr.GetActiveTransactions(),
+		"txn_lock_timeouts":   db.txnMgr.LockTimeouts(),
+		"txn_aborted_too_old": db.txnMgr.TooOldAborts(),
+		"oldest_snapshot_age": db.txnMgr.OldestSnapshotAge().String(),
+		"mvcc_gc":             db.txnMgr.GCStats(),
+		"storage_stats":       db.storage.Stats(),
 	}
+	if db.quota != nil {
+		stats["tenants"] = db.quota.stats()
+	}
+	return stats
 }
 
 // startTTLCleanup starts a background goroutine that periodically cleans up expired documents
-func (db *Database) startTTLCleanup() {
+func (db *Database) startTTLCleanup(interval time.Duration) {
 	db.ttlWaitGroup.Add(1)
-	go db.ttlCleanupLoop()
+	go db.ttlCleanupLoop(interval)
 }
 
-// ttlCleanupLoop runs the TTL cleanup process every 60 seconds
-func (db *Database) ttlCleanupLoop() {
+// ttlCleanupLoop runs the TTL cleanup process every interval
+func (db *Database) ttlCleanupLoop(interval time.Duration) {
 	defer db.ttlWaitGroup.Done()
 
-	ticker := time.NewTicker(60 * time.Second)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
 			db.cleanupExpiredDocuments()
+			db.applyTieringPolicies()
 		case <-db.ttlStopChan:
 			return
 		}
@@ -318,6 +674,35 @@ func (db *Database) cursorCleanupLoop() {
 	}
 }
 
+// startTransactionReaper starts the goroutine aborting expired transactions
+func (db *Database) startTransactionReaper(maxLifetime time.Duration) {
+	db.ttlWaitGroup.Add(1)
+	go db.transactionReaperLoop(maxLifetime)
+}
+
+// transactionReaperLoop aborts transactions that outlived maxLifetime so
+// abandoned sessions don't pin old snapshots. Sessions still in use notice
+// the limit on their next operation; this catches the ones never touched again.
+func (db *Database) transactionReaperLoop(maxLifetime time.Duration) {
+	defer db.ttlWaitGroup.Done()
+
+	interval := maxLifetime / 2
+	if interval > 60*time.Second {
+		interval = 60 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			db.txnMgr.AbortExpired()
+		case <-db.ttlStopChan:
+			return
+		}
+	}
+}
+
 // CursorManager returns the database's cursor manager
 func (db *Database) CursorManager() *CursorManager {
 	return db.cursorManager
@@ -1,10 +1,13 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"testing"
 
+	"github.com/mnohosten/laura-db/pkg/document"
 	"github.com/mnohosten/laura-db/pkg/query"
 )
 
@@ -192,6 +195,156 @@ func TestFindWithOptions(t *testing.T) {
 	}
 }
 
+func TestFindWithOptionsProjection(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	users := db.Collection("users")
+	users.InsertOne(map[string]interface{}{
+		"_id":      "alice",
+		"name":     "Alice",
+		"password": "secret",
+		"address":  map[string]interface{}{"city": "Prague", "street": "Main"},
+	})
+
+	// Nested inclusion returns only the selected subtree, with _id
+	results, err := users.FindWithOptions(nil, &QueryOptions{Projection: map[string]bool{"address.city": true}})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d, %v", len(results), err)
+	}
+	expected := map[string]interface{}{"_id": "alice", "address": map[string]interface{}{"city": "Prague"}}
+	if got := results[0].ToMap(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	results, _ = users.FindWithOptions(nil, &QueryOptions{Projection: map[string]bool{"password": false}})
+	if results[0].Has("password") || !results[0].Has("name") || !results[0].Has("_id") {
+		t.Errorf("Expected only password excluded, got %v", results[0].ToMap())
+	}
+	results, _ = users.FindWithOptions(nil, &QueryOptions{Projection: map[string]bool{"name": true, "_id": false}})
+	if results[0].Has("_id") {
+		t.Errorf("Expected _id excluded, got %v", results[0].ToMap())
+	}
+
+	_, err = users.FindWithOptions(nil, &QueryOptions{Projection: map[string]bool{"name": true, "password": false}})
+	if !errors.Is(err, ErrInvalidProjection) || !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected ErrInvalidProjection for a mixed projection, got %v", err)
+	}
+
+	// Nil options are a plain find
+	if results, err := users.FindWithOptions(nil, nil); err != nil || !results[0].Has("password") {
+		t.Errorf("Expected full documents without options, got %v", err)
+	}
+}
+
+// sortedIDs returns the _id of each document in order
+func sortedIDs(docs []*document.Document) []interface{} {
+	ids := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		ids[i], _ = doc.Get("_id")
+	}
+	return ids
+}
+
+func TestFindWithOptionsCompoundSort(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	products := db.Collection("products")
+	products.InsertMany([]map[string]interface{}{
+		{"_id": "p1", "category": "tools", "price": int64(20)},
+		{"_id": "p2", "category": "books", "price": int64(10)},
+		{"_id": "p3", "category": "tools", "price": int64(35)},
+		{"_id": "p4", "category": "books", "price": int64(15)},
+		{"_id": "p5", "category": "tools", "price": int64(20)},
+	})
+
+	results, err := products.FindWithOptions(nil, &QueryOptions{
+		Sort: []query.SortField{{Field: "category", Ascending: true}, {Field: "price", Ascending: false}},
+	})
+	if err != nil {
+		t.Fatalf("FindWithOptions failed: %v", err)
+	}
+	// p1 and p5 tie on both fields and are ordered by _id
+	expected := []interface{}{"p4", "p2", "p3", "p1", "p5"}
+	if got := sortedIDs(results); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestFindWithOptionsPaging(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	items := db.Collection("items")
+	for i := 0; i < 25; i++ {
+		items.InsertOne(map[string]interface{}{"_id": fmt.Sprintf("item%02d", i), "group": int64(i % 3)})
+	}
+
+	sort := []query.SortField{{Field: "group", Ascending: true}}
+	all, _ := items.FindWithOptions(nil, &QueryOptions{Sort: sort})
+
+	// Pages over a sort with ties cover every document once, in the full order
+	paged := make([]interface{}, 0, len(all))
+	for skip := 0; skip < len(all); skip += 10 {
+		page, err := items.FindWithOptions(nil, &QueryOptions{Sort: sort, Skip: skip, Limit: 10})
+		if err != nil {
+			t.Fatalf("FindWithOptions failed: %v", err)
+		}
+		paged = append(paged, sortedIDs(page)...)
+	}
+	if !reflect.DeepEqual(paged, sortedIDs(all)) {
+		t.Errorf("Expected pages to follow %v, got %v", sortedIDs(all), paged)
+	}
+
+	if page, _ := items.FindWithOptions(nil, &QueryOptions{Sort: sort, Skip: 30, Limit: 10}); len(page) != 0 {
+		t.Errorf("Expected no documents past the end, got %d", len(page))
+	}
+}
+
+func TestFindWithOptionsIndexSort(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	products := db.Collection("products")
+	for i := 0; i < 20; i++ {
+		products.InsertOne(map[string]interface{}{"_id": fmt.Sprintf("p%02d", i), "sku": int64((i * 7) % 20), "stock": int64(i % 2)})
+	}
+	products.CreateIndex("sku", true)
+
+	sort := []query.SortField{{Field: "sku", Ascending: false}}
+	if plan := products.ExplainWithOptions(nil, &QueryOptions{Sort: sort}); plan["sortIndex"] != "sku_1" {
+		t.Errorf("Expected the sort to use sku_1, got %v", plan["sortIndex"])
+	}
+
+	results, err := products.FindWithOptions(
+		map[string]interface{}{"stock": int64(1)},
+		&QueryOptions{Sort: sort, Skip: 2, Limit: 3},
+	)
+	if err != nil {
+		t.Fatalf("FindWithOptions failed: %v", err)
+	}
+	skus := make([]interface{}, len(results))
+	for i, doc := range results {
+		skus[i], _ = doc.Get("sku")
+	}
+	// Odd documents hold the odd SKUs
+	if expected := []interface{}{int64(15), int64(13), int64(11)}; !reflect.DeepEqual(skus, expected) {
+		t.Errorf("Expected SKUs %v, got %v", expected, skus)
+	}
+
+	// A document without the field isn't in the index, so the sort is done
+	// in memory and still returns it, first in descending order
+	products.InsertOne(map[string]interface{}{"_id": "nosku"})
+	if plan := products.ExplainWithOptions(nil, &QueryOptions{Sort: sort}); plan["sortIndex"] != nil {
+		t.Errorf("Expected no sort index, got %v", plan["sortIndex"])
+	}
+	results, _ = products.FindWithOptions(nil, &QueryOptions{Sort: sort})
+	if ids := sortedIDs(results); len(ids) != 21 || ids[0] != "nosku" || ids[1] != "p17" {
+		t.Errorf("Expected 21 documents starting at nosku, p17, got %v", ids)
+	}
+}
+
 func TestUpdateOne(t *testing.T) {
 	dir := "./test_db_update"
 	defer os.RemoveAll(dir)
@@ -235,7 +388,7 @@ func TestUpdateMany(t *testing.T) {
 	users.InsertOne(map[string]interface{}{"status": "pending", "value": int64(20)})
 	users.InsertOne(map[string]interface{}{"status": "active", "value": int64(30)})
 
-	count, err := users.UpdateMany(
+	result, err := users.UpdateMany(
 		map[string]interface{}{"status": "pending"},
 		map[string]interface{}{
 			"$set": map[string]interface{}{
@@ -248,8 +401,8 @@ func TestUpdateMany(t *testing.T) {
 		t.Fatalf("UpdateMany failed: %v", err)
 	}
 
-	if count != 2 {
-		t.Errorf("Expected 2 updates, got %d", count)
+	if result.ModifiedCount != 2 {
+		t.Errorf("Expected 2 updates, got %d", result.ModifiedCount)
 	}
 
 	// Verify all are active now
@@ -299,7 +452,7 @@ func TestDeleteMany(t *testing.T) {
 	users.InsertOne(map[string]interface{}{"age": int64(25)})
 	users.InsertOne(map[string]interface{}{"age": int64(30)})
 
-	count, err := users.DeleteMany(map[string]interface{}{
+	result, err := users.DeleteMany(map[string]interface{}{
 		"age": map[string]interface{}{"$lt": int64(28)},
 	})
 
@@ -307,8 +460,8 @@ func TestDeleteMany(t *testing.T) {
 		t.Fatalf("DeleteMany failed: %v", err)
 	}
 
-	if count != 2 {
-		t.Errorf("Expected 2 deletions, got %d", count)
+	if result.DeletedCount != 2 {
+		t.Errorf("Expected 2 deletions, got %d", result.DeletedCount)
 	}
 
 	remaining, _ := users.Count(map[string]interface{}{})
@@ -390,6 +543,87 @@ func TestAggregate(t *testing.T) {
 	}
 }
 
+func TestAggregateFacet(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	products := db.Collection("products")
+	products.InsertMany([]map[string]interface{}{
+		{"category": "books", "price": int64(15)},
+		{"category": "books", "price": int64(120)},
+		{"category": "tools", "price": int64(45)},
+		{"category": "tools", "price": int64(60)},
+		{"category": "toys", "price": int64(210)},
+		{"category": "toys", "price": int64(5), "discontinued": true},
+	})
+
+	pipeline := []map[string]interface{}{
+		{"$match": map[string]interface{}{"discontinued": map[string]interface{}{"$exists": false}}},
+		{"$facet": map[string]interface{}{
+			// Prices in buckets of 100
+			"priceHistogram": []interface{}{
+				map[string]interface{}{"$group": map[string]interface{}{
+					"_id":   map[string]interface{}{"$subtract": []interface{}{"$price", map[string]interface{}{"$mod": []interface{}{"$price", int64(100)}}}},
+					"count": map[string]interface{}{"$sum": int64(1)},
+				}},
+				map[string]interface{}{"$sort": map[string]interface{}{"_id": 1}},
+			},
+			"byCategory": []interface{}{
+				map[string]interface{}{"$group": map[string]interface{}{
+					"_id":   "$category",
+					"count": map[string]interface{}{"$sum": int64(1)},
+				}},
+				map[string]interface{}{"$sort": map[string]interface{}{"_id": 1}},
+			},
+		}},
+	}
+
+	// The in-memory and streaming paths buffer the input once for both facets
+	results, err := products.Aggregate(pipeline)
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	cursor, err := products.AggregateCursor(pipeline, nil)
+	if err != nil {
+		t.Fatalf("AggregateCursor failed: %v", err)
+	}
+	defer cursor.Close()
+	streamed := make([]*document.Document, 0)
+	for cursor.HasNext() {
+		doc, err := cursor.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		streamed = append(streamed, doc)
+	}
+
+	for _, docs := range [][]*document.Document{results, streamed} {
+		if len(docs) != 1 {
+			t.Fatalf("Expected a single document, got %d", len(docs))
+		}
+		facets := docs[0].ToMap()
+
+		histogram := make(map[float64]float64)
+		for _, bucket := range facets["priceHistogram"].([]interface{}) {
+			b := bucket.(map[string]interface{})
+			price, _ := toFloat64(b["_id"])
+			histogram[price], _ = toFloat64(b["count"])
+		}
+		if expected := map[float64]float64{0: 3, 100: 1, 200: 1}; !reflect.DeepEqual(histogram, expected) {
+			t.Errorf("Expected histogram %v, got %v", expected, histogram)
+		}
+
+		categories := make(map[interface{}]float64)
+		for _, group := range facets["byCategory"].([]interface{}) {
+			g := group.(map[string]interface{})
+			categories[g["_id"]], _ = toFloat64(g["count"])
+		}
+		if expected := map[interface{}]float64{"books": 2, "tools": 2, "toys": 1}; !reflect.DeepEqual(categories, expected) {
+			t.Errorf("Expected categories %v, got %v", expected, categories)
+		}
+	}
+}
+
 func TestCount(t *testing.T) {
 	dir := "./test_db_count"
 	defer os.RemoveAll(dir)
@@ -414,6 +648,38 @@ func TestCount(t *testing.T) {
 	if filtered != 2 {
 		t.Errorf("Expected count 2, got %d", filtered)
 	}
+
+	if total, _ := users.Count(nil); total != 3 {
+		t.Errorf("Expected count 3 for nil filter, got %d", total)
+	}
+}
+
+func TestEstimatedDocumentCount(t *testing.T) {
+	dir := "./test_db_estimated_count"
+	defer os.RemoveAll(dir)
+
+	db, _ := Open(DefaultConfig(dir))
+
+	users := db.Collection("users")
+	if count, err := users.EstimatedDocumentCount(); err != nil || count != 0 {
+		t.Errorf("Expected count 0, got %d (%v)", count, err)
+	}
+
+	users.InsertMany([]map[string]interface{}{
+		{"age": int64(25)},
+		{"age": int64(30)},
+		{"age": int64(35)},
+	})
+	users.DeleteOne(map[string]interface{}{"age": int64(30)})
+
+	if count, err := users.EstimatedDocumentCount(); err != nil || count != 2 {
+		t.Errorf("Expected count 2, got %d (%v)", count, err)
+	}
+
+	db.Close()
+	if _, err := users.EstimatedDocumentCount(); err != ErrDatabaseClosed {
+		t.Errorf("Expected ErrDatabaseClosed, got %v", err)
+	}
 }
 
 func TestListCollections(t *testing.T) {
@@ -688,3 +954,115 @@ func TestExplain(t *testing.T) {
 		t.Error("Expected totalDocuments in explanation")
 	}
 }
+
+func TestFindRegex(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	products := db.Collection("products")
+	for _, sku := range []string{"abc-1", "ABC-2", "abc-3", "abd-4", "xabc-5"} {
+		products.InsertOne(map[string]interface{}{"_id": sku, "sku": sku})
+	}
+	products.InsertOne(map[string]interface{}{"_id": "numeric", "sku": int64(123)})
+	products.CreateIndex("sku", true)
+
+	prefix := map[string]interface{}{"sku": map[string]interface{}{"$regex": "^abc"}}
+	plan := products.Explain(prefix)
+	if plan["useIndex"] != true || plan["indexName"] != "sku_1" || plan["scanType"] != "INDEX_RANGE" {
+		t.Errorf("Expected a range scan of sku_1, got %v", plan)
+	}
+	before := products.indexes["sku_1"].Usage().Usage().Ops
+	results, err := products.Find(prefix)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if ids := sortedIDs(results); !reflect.DeepEqual(ids, []interface{}{"abc-1", "abc-3"}) {
+		t.Errorf("Expected abc-1 and abc-3, got %v", ids)
+	}
+	if products.indexes["sku_1"].Usage().Usage().Ops == before {
+		t.Error("Expected Find to read sku_1")
+	}
+
+	// Case insensitive patterns can't scan the index but still match
+	insensitive := map[string]interface{}{"sku": map[string]interface{}{"$regex": "^abc", "$options": "i"}}
+	if plan := products.Explain(insensitive); plan["useIndex"] == true {
+		t.Errorf("Expected a collection scan, got %v", plan)
+	}
+	if count, err := products.Count(insensitive); err != nil || count != 3 {
+		t.Errorf("Expected 3 case insensitive matches, got %d (%v)", count, err)
+	}
+
+	// The numeric SKU is not a string, so it never matches
+	if count, _ := products.Count(map[string]interface{}{"sku": map[string]interface{}{"$regex": "123"}}); count != 0 {
+		t.Errorf("Expected no match on a numeric field, got %d", count)
+	}
+
+	result, err := products.UpdateMany(prefix, map[string]interface{}{"$set": map[string]interface{}{"line": "abc"}})
+	if err != nil || result.ModifiedCount != 2 {
+		t.Fatalf("Expected 2 updated documents, got %v (%v)", result, err)
+	}
+
+	docs, err := products.Aggregate([]map[string]interface{}{
+		{"$match": map[string]interface{}{"sku": map[string]interface{}{"$regex": "-[34]$"}}},
+	})
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if ids := sortedIDs(docs); len(ids) != 2 {
+		t.Errorf("Expected 2 documents from $match, got %v", ids)
+	}
+}
+
+func TestFindElemMatch(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	orders := db.Collection("orders")
+	orders.InsertOne(map[string]interface{}{"_id": "split", "items": []interface{}{
+		map[string]interface{}{"sku": "X", "qty": int64(0)},
+		map[string]interface{}{"sku": "Y", "qty": int64(3)},
+	}})
+	orders.InsertOne(map[string]interface{}{"_id": "stocked", "items": []interface{}{
+		map[string]interface{}{"sku": "X", "qty": int64(2)},
+	}})
+
+	results, err := orders.Find(map[string]interface{}{
+		"items": map[string]interface{}{
+			"$elemMatch": map[string]interface{}{"sku": "X", "qty": map[string]interface{}{"$gt": int64(0)}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if ids := sortedIDs(results); !reflect.DeepEqual(ids, []interface{}{"stocked"}) {
+		t.Errorf("Expected only stocked, got %v", ids)
+	}
+}
+
+func TestDatabaseCheckpoint(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	db.Collection("users").InsertOne(map[string]interface{}{"name": "Alice"})
+
+	if err := db.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+
+	storageStats := db.Stats()["storage_stats"].(map[string]interface{})
+	walStats := storageStats["wal"].(map[string]interface{})
+	if walStats["checkpoints"].(int64) != 1 {
+		t.Errorf("Expected 1 checkpoint, got %v", walStats["checkpoints"])
+	}
+	if walStats["last_checkpoint_lsn"].(uint64) == 0 {
+		t.Error("Expected non-zero last checkpoint LSN")
+	}
+
+	db.Close()
+
+	if err := db.Checkpoint(); err != ErrDatabaseClosed {
+		t.Errorf("Expected ErrDatabaseClosed, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// FieldKind is the type of values a generated dataset field holds
+type FieldKind int
+
+const (
+	FieldInt      FieldKind = iota // int64
+	FieldFloat                     // float64
+	FieldString                    // string
+	FieldBool                      // bool
+	FieldTime                      // RFC 3339 time in UTC, which sorts by time
+	FieldDocument                  // Embedded document of Fields
+	FieldArray                     // Array of Length values of Fields[0]
+)
+
+// datasetEpoch is the earliest time a FieldTime field holds
+var datasetEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// FieldSpec describes a field of the documents SeedDataset generates
+type FieldSpec struct {
+	Name string
+	Kind FieldKind
+
+	// Cardinality is the number of distinct values of the field. Zero gives
+	// every document its own value for ints and strings, and spreads floats
+	// and times over a wide range.
+	Cardinality int
+
+	Fields []FieldSpec // Fields of an embedded document, or the array element
+	Length int         // Number of array elements, 3 if zero
+}
+
+// DatasetSpec describes a dataset for SeedDataset. The same seed, count and
+// fields always give the same documents.
+type DatasetSpec struct {
+	Seed      int64
+	Count     int
+	BatchSize int         // Documents per InsertMany, 1000 if zero
+	Fields    []FieldSpec // DefaultDatasetFields if empty
+}
+
+// DefaultDatasetFields returns the fields of the standard dataset: a mix of
+// unique, low and high cardinality fields, an embedded document and an array
+func DefaultDatasetFields() []FieldSpec {
+	return []FieldSpec{
+		{Name: "name", Kind: FieldString},
+		{Name: "age", Kind: FieldInt, Cardinality: 80},
+		{Name: "city", Kind: FieldString, Cardinality: 50},
+		{Name: "score", Kind: FieldFloat},
+		{Name: "active", Kind: FieldBool},
+		{Name: "createdAt", Kind: FieldTime},
+		{Name: "address", Kind: FieldDocument, Fields: []FieldSpec{
+			{Name: "street", Kind: FieldString, Cardinality: 10000},
+			{Name: "zip", Kind: FieldInt, Cardinality: 1000},
+		}},
+		{Name: "tags", Kind: FieldArray, Length: 3, Fields: []FieldSpec{
+			{Name: "tag", Kind: FieldString, Cardinality: 20},
+		}},
+	}
+}
+
+// SeedDataset inserts spec.Count generated documents into the collection in
+// batches. Documents get the _ids 0 to Count-1 and field values drawn from
+// a random source seeded with spec.Seed, so a dataset can be reproduced
+// from its seed and size alone.
+func SeedDataset(coll *Collection, spec DatasetSpec) error {
+	if spec.Count < 0 {
+		return fmt.Errorf("dataset count must not be negative: %d", spec.Count)
+	}
+	fields := spec.Fields
+	if len(fields) == 0 {
+		fields = DefaultDatasetFields()
+	}
+	if err := checkFieldSpecs(fields); err != nil {
+		return err
+	}
+	batchSize := spec.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	rng := rand.New(rand.NewSource(spec.Seed))
+	batch := make([]map[string]interface{}, 0, batchSize)
+	for i := 0; i < spec.Count; i++ {
+		doc := generateFields(rng, fields, i)
+		doc["_id"] = int64(i)
+		batch = append(batch, doc)
+
+		if len(batch) == batchSize || i == spec.Count-1 {
+			if _, err := coll.InsertMany(batch); err != nil {
+				return fmt.Errorf("failed to insert documents %d-%d: %w", i+1-len(batch), i, err)
+			}
+			batch = batch[:0]
+		}
+	}
+	return nil
+}
+
+// checkFieldSpecs validates the field specs of a dataset
+func checkFieldSpecs(fields []FieldSpec) error {
+	for _, f := range fields {
+		if f.Name == "" {
+			return fmt.Errorf("dataset field has no name")
+		}
+		if f.Cardinality < 0 {
+			return fmt.Errorf("dataset field %s: cardinality must not be negative", f.Name)
+		}
+		switch f.Kind {
+		case FieldInt, FieldFloat, FieldString, FieldBool, FieldTime:
+		case FieldDocument, FieldArray:
+			if len(f.Fields) == 0 {
+				return fmt.Errorf("dataset field %s: no nested fields", f.Name)
+			}
+			if err := checkFieldSpecs(f.Fields); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("dataset field %s: unknown kind %d", f.Name, f.Kind)
+		}
+	}
+	return nil
+}
+
+// generateFields generates the fields of document n
+func generateFields(rng *rand.Rand, fields []FieldSpec, n int) map[string]interface{} {
+	doc := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		doc[f.Name] = generateValue(rng, f, n)
+	}
+	return doc
+}
+
+// generateValue generates the value of a field in document n
+func generateValue(rng *rand.Rand, f FieldSpec, n int) interface{} {
+	// pick draws one of the field's distinct values, or n for unique values
+	pick := func() int {
+		if f.Cardinality > 0 {
+			return rng.Intn(f.Cardinality)
+		}
+		return n
+	}
+
+	switch f.Kind {
+	case FieldInt:
+		return int64(pick())
+	case FieldFloat:
+		if f.Cardinality > 0 {
+			return float64(rng.Intn(f.Cardinality)) + 0.5
+		}
+		return rng.Float64() * 1000
+	case FieldString:
+		return fmt.Sprintf("%s_%d", f.Name, pick())
+	case FieldBool:
+		return rng.Intn(2) == 0
+	case FieldTime:
+		seconds := 5 * 365 * 24 * 3600
+		if f.Cardinality > 0 {
+			seconds = f.Cardinality
+		}
+		return datasetEpoch.Add(time.Duration(rng.Intn(seconds)) * time.Second).Format(time.RFC3339)
+	case FieldDocument:
+		return generateFields(rng, f.Fields, n)
+	case FieldArray:
+		length := f.Length
+		if length <= 0 {
+			length = 3
+		}
+		values := make([]interface{}, length)
+		for i := range values {
+			values[i] = generateValue(rng, f.Fields[0], n)
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSeedDatasetDeterministic(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	a := db.Collection("a")
+	b := db.Collection("b")
+	if err := SeedDataset(a, DatasetSpec{Seed: 42, Count: 250}); err != nil {
+		t.Fatalf("SeedDataset failed: %v", err)
+	}
+	// The batch size doesn't change the data
+	if err := SeedDataset(b, DatasetSpec{Seed: 42, Count: 250, BatchSize: 7}); err != nil {
+		t.Fatalf("SeedDataset failed: %v", err)
+	}
+
+	count, _ := a.Count(nil)
+	if count != 250 {
+		t.Fatalf("Expected 250 documents, got %d", count)
+	}
+
+	for _, id := range []int64{0, 123, 249} {
+		docA, err := a.FindOne(map[string]interface{}{"_id": id})
+		if err != nil {
+			t.Fatalf("Failed to find %d: %v", id, err)
+		}
+		docB, err := b.FindOne(map[string]interface{}{"_id": id})
+		if err != nil {
+			t.Fatalf("Failed to find %d: %v", id, err)
+		}
+		if !reflect.DeepEqual(docA.ToMap(), docB.ToMap()) {
+			t.Errorf("Expected same document %d for the same seed, got %v and %v", id, docA.ToMap(), docB.ToMap())
+		}
+	}
+
+	c := db.Collection("c")
+	if err := SeedDataset(c, DatasetSpec{Seed: 7, Count: 250}); err != nil {
+		t.Fatalf("SeedDataset failed: %v", err)
+	}
+	docA, _ := a.FindOne(map[string]interface{}{"_id": int64(5)})
+	docC, _ := c.FindOne(map[string]interface{}{"_id": int64(5)})
+	if reflect.DeepEqual(docA.ToMap(), docC.ToMap()) {
+		t.Error("Expected a different seed to give different documents")
+	}
+}
+
+func TestSeedDatasetFields(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("data")
+	spec := DatasetSpec{
+		Seed:  1,
+		Count: 500,
+		Fields: []FieldSpec{
+			{Name: "user", Kind: FieldString},
+			{Name: "status", Kind: FieldString, Cardinality: 3},
+			{Name: "when", Kind: FieldTime},
+			{Name: "profile", Kind: FieldDocument, Fields: []FieldSpec{
+				{Name: "level", Kind: FieldInt, Cardinality: 5},
+			}},
+			{Name: "scores", Kind: FieldArray, Length: 4, Fields: []FieldSpec{
+				{Name: "score", Kind: FieldFloat},
+			}},
+		},
+	}
+	if err := SeedDataset(coll, spec); err != nil {
+		t.Fatalf("SeedDataset failed: %v", err)
+	}
+
+	docs, err := coll.Find(nil)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	users := make(map[interface{}]bool)
+	statuses := make(map[interface{}]bool)
+	levels := make(map[interface{}]bool)
+	for _, doc := range docs {
+		user, _ := doc.Get("user")
+		users[user] = true
+		status, _ := doc.Get("status")
+		statuses[status] = true
+		level, _ := doc.Get("profile.level")
+		levels[level] = true
+
+		when, _ := doc.Get("when")
+		if _, err := time.Parse(time.RFC3339, when.(string)); err != nil {
+			t.Fatalf("Expected when to be an RFC 3339 time, got %v", when)
+		}
+		if scores, _ := doc.Get("scores"); len(scores.([]interface{})) != 4 {
+			t.Fatalf("Expected 4 scores, got %v", scores)
+		}
+	}
+	if len(users) != 500 {
+		t.Errorf("Expected 500 distinct users, got %d", len(users))
+	}
+	if len(statuses) != 3 {
+		t.Errorf("Expected 3 distinct statuses, got %d", len(statuses))
+	}
+	if len(levels) != 5 {
+		t.Errorf("Expected 5 distinct levels, got %d", len(levels))
+	}
+}
+
+func TestSeedDatasetInvalidSpec(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	specs := []DatasetSpec{
+		{Count: -1},
+		{Count: 1, Fields: []FieldSpec{{Kind: FieldInt}}},
+		{Count: 1, Fields: []FieldSpec{{Name: "a", Kind: FieldArray}}},
+		{Count: 1, Fields: []FieldSpec{{Name: "a", Kind: FieldKind(99)}}},
+	}
+	for i, spec := range specs {
+		if err := SeedDataset(db.Collection("data"), spec); err == nil {
+			t.Errorf("Expected error for spec %d", i)
+		}
+	}
+}
@@ -11,23 +11,30 @@ import (
 
 // DocumentLocation represents the location of a document on disk
 type DocumentLocation struct {
-	PageID storage.PageID
-	SlotID uint16
+	PageID      storage.PageID
+	SlotID      uint16
+	Quarantined bool // The page was quarantined before the document could be relocated
 }
 
 // DocumentStore manages disk-based document storage with caching
 type DocumentStore struct {
-	diskManager    *storage.DiskManager
+	diskManager    storage.PageStore
 	pageManager    *storage.DocumentPageManager
 	serializer     *storage.DocumentSerializer
 	locationMap    map[string]*DocumentLocation // _id -> location
 	docCache       *cache.LRUCache              // LRU cache for documents
 	activePagesMap map[storage.PageID]*storage.SlottedPage // Currently active pages
+	pagesMu        sync.Mutex                              // Guards activePagesMap for readers holding mu for reading
+	freeSpace      *storage.FreeSpaceMap                   // Free space of the pages holding documents
+	blobs          *blobStore                              // Large binary fields, nil when disabled
+	snapshots      *snapshotRegistry                       // Read snapshots to preserve versions for, nil outside a database
+	quota          *quotaTracker                           // Tenant usage accounting, nil without quotas
+	charges        map[string]quotaCharge                  // _id -> usage charged to its tenant
 	mu             sync.RWMutex
 }
 
 // NewDocumentStore creates a new document store
-func NewDocumentStore(diskManager *storage.DiskManager, cacheSize int) *DocumentStore {
+func NewDocumentStore(diskManager storage.PageStore, cacheSize int) *DocumentStore {
 	return &DocumentStore{
 		diskManager:    diskManager,
 		pageManager:    storage.NewDocumentPageManager(),
@@ -35,30 +42,53 @@ func NewDocumentStore(diskManager *storage.DiskManager, cacheSize int) *Document
 		locationMap:    make(map[string]*DocumentLocation),
 		docCache:       cache.NewLRUCache(cacheSize, 0), // No TTL for document cache
 		activePagesMap: make(map[storage.PageID]*storage.SlottedPage),
+		freeSpace:      storage.NewFreeSpaceMap(diskManager.PageSize()),
 	}
 }
 
 // Insert inserts a document into disk storage and returns its ID
 func (ds *DocumentStore) Insert(id string, doc *document.Document) error {
+	defer ds.snapshots.preserve(ds, id)()
+	return ds.insert(id, doc)
+}
+
+// InsertThawed inserts a document moved from the cold tier. Open snapshots
+// keep it as it was, rather than as missing.
+func (ds *DocumentStore) InsertThawed(id string, doc *document.Document) error {
+	defer ds.snapshots.preserveVersion(ds, id, doc)()
+	return ds.insert(id, doc)
+}
+
+// insert inserts a document into disk storage
+func (ds *DocumentStore) insert(id string, doc *document.Document) error {
 	ds.mu.Lock()
 	defer ds.mu.Unlock()
 
 	// Check if document already exists
 	if _, exists := ds.locationMap[id]; exists {
-		return fmt.Errorf("document with _id %s already exists", id)
+		return kindOf(ErrDuplicateKey, "document with _id %s already exists", id)
+	}
+
+	// Move large binary fields to chunks
+	stored, blobs := ds.blobs.split(doc)
+	if err := ds.blobs.write(blobs); err != nil {
+		return err
 	}
 
 	// Try to find a page with enough space, or allocate a new one
-	page, err := ds.findOrAllocatePageForDocument(doc)
+	page, err := ds.findOrAllocatePageForDocument(stored)
 	if err != nil {
+		ds.blobs.remove(blobs)
 		return fmt.Errorf("failed to find page for document: %w", err)
 	}
 
 	// Insert document into the page
-	slotID, err := ds.pageManager.InsertDocument(page, doc)
+	slotID, err := ds.pageManager.InsertDocument(page, stored)
 	if err != nil {
+		ds.blobs.remove(blobs)
 		return fmt.Errorf("failed to insert document into page: %w", err)
 	}
+	ds.trackPage(page)
 
 	// Store location
 	ds.locationMap[id] = &DocumentLocation{
@@ -75,10 +105,94 @@ func (ds *DocumentStore) Insert(id string, doc *document.Document) error {
 		delete(ds.locationMap, id)
 		return fmt.Errorf("failed to write page to disk: %w", err)
 	}
+	ds.charge(id, doc)
 
 	return nil
 }
 
+// InsertMany inserts a batch of documents into disk storage. Documents are
+// packed into pages as by Insert, but each page is written to disk once for
+// the whole batch. Either all documents are inserted or none are.
+func (ds *DocumentStore) InsertMany(ids []string, docs []*document.Document) error {
+	defer ds.snapshots.preserve(ds, ids...)()
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if _, exists := ds.locationMap[id]; exists || seen[id] {
+			return kindOf(ErrDuplicateKey, "document with _id %s already exists", id)
+		}
+		seen[id] = true
+	}
+
+	type placement struct {
+		id    string
+		page  *storage.SlottedPage
+		slot  uint16
+		blobs []blob
+	}
+	placed := make([]placement, 0, len(docs))
+	undo := func() {
+		for _, p := range placed {
+			ds.pageManager.DeleteDocument(p.page, p.slot)
+			ds.trackPage(p.page)
+			delete(ds.locationMap, p.id)
+			ds.blobs.remove(p.blobs)
+		}
+	}
+
+	dirty := make(map[storage.PageID]*storage.Page)
+	for i, doc := range docs {
+		// Move large binary fields to chunks
+		stored, blobs := ds.blobs.split(doc)
+		if err := ds.blobs.write(blobs); err != nil {
+			undo()
+			return err
+		}
+
+		page, err := ds.findOrAllocatePageForDocument(stored)
+		if err != nil {
+			ds.blobs.remove(blobs)
+			undo()
+			return fmt.Errorf("failed to find page for document: %w", err)
+		}
+
+		slotID, err := ds.pageManager.InsertDocument(page, stored)
+		if err != nil {
+			ds.blobs.remove(blobs)
+			undo()
+			return fmt.Errorf("failed to insert document into page: %w", err)
+		}
+		ds.trackPage(page)
+
+		ds.locationMap[ids[i]] = &DocumentLocation{
+			PageID: page.GetPage().ID,
+			SlotID: slotID,
+		}
+		placed = append(placed, placement{id: ids[i], page: page, slot: slotID, blobs: blobs})
+		dirty[page.GetPage().ID] = page.GetPage()
+	}
+
+	// Flush the pages of the batch to disk
+	pages := make([]*storage.Page, 0, len(dirty))
+	for _, page := range dirty {
+		pages = append(pages, page)
+	}
+	if err := ds.diskManager.WritePages(pages); err != nil {
+		// Best effort to leave the pages on disk as they were before the batch
+		undo()
+		ds.diskManager.WritePages(pages)
+		return fmt.Errorf("failed to write pages to disk: %w", err)
+	}
+
+	for i, doc := range docs {
+		ds.docCache.Put(ids[i], doc)
+		ds.charge(ids[i], doc)
+	}
+	return nil
+}
+
 // Get retrieves a document by ID
 func (ds *DocumentStore) Get(id string) (*document.Document, error) {
 	ds.mu.RLock()
@@ -94,7 +208,7 @@ func (ds *DocumentStore) Get(id string) (*document.Document, error) {
 	// Get location
 	location, exists := ds.locationMap[id]
 	if !exists {
-		return nil, fmt.Errorf("document not found: %s", id)
+		return nil, kindOf(ErrDocumentNotFound, "document not found: %s", id)
 	}
 
 	// Read from disk
@@ -111,13 +225,17 @@ func (ds *DocumentStore) Get(id string) (*document.Document, error) {
 
 // Update updates an existing document
 func (ds *DocumentStore) Update(id string, doc *document.Document) error {
+	defer ds.snapshots.preserve(ds, id)()
 	ds.mu.Lock()
 	defer ds.mu.Unlock()
 
 	// Get location
 	location, exists := ds.locationMap[id]
 	if !exists {
-		return fmt.Errorf("document not found: %s", id)
+		return kindOf(ErrDocumentNotFound, "document not found: %s", id)
+	}
+	if location.Quarantined {
+		return kindOf(ErrDocumentQuarantined, "document %s is on a quarantined page", id)
 	}
 
 	// Load the page
@@ -126,12 +244,30 @@ func (ds *DocumentStore) Update(id string, doc *document.Document) error {
 		return fmt.Errorf("failed to load page: %w", err)
 	}
 
+	// Remember the chunks of the current version, and move large binary
+	// fields of the new one to chunks
+	var oldBlobs []blob
+	if ds.blobs != nil {
+		if old, err := ds.pageManager.GetDocument(page, location.SlotID); err == nil {
+			oldBlobs = ds.blobs.refs(old)
+		}
+	}
+	stored, blobs := ds.blobs.split(doc)
+	if err := ds.blobs.write(blobs); err != nil {
+		return err
+	}
+
 	// Try to update in place
-	if err := ds.pageManager.UpdateDocument(page, location.SlotID, doc); err != nil {
+	err = ds.pageManager.UpdateDocument(page, location.SlotID, stored)
+	ds.trackPage(page)
+	if err != nil {
+		ds.blobs.remove(blobs)
 		// If update fails (e.g., document too large), we need to delete and reinsert
 		// For now, return the error
 		return fmt.Errorf("failed to update document: %w", err)
 	}
+	ds.blobs.remove(oldBlobs)
+	ds.charge(id, doc)
 
 	// Update cache
 	ds.docCache.Put(id, doc)
@@ -144,15 +280,115 @@ func (ds *DocumentStore) Update(id string, doc *document.Document) error {
 	return nil
 }
 
+// UpdateMany updates a batch of existing documents in place. Each page
+// holding some of them is written to disk once for the whole batch. Either
+// all documents are updated or none are.
+func (ds *DocumentStore) UpdateMany(ids []string, docs []*document.Document) error {
+	defer ds.snapshots.preserve(ds, ids...)()
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	type replacement struct {
+		page     *storage.SlottedPage
+		slot     uint16
+		old      *document.Document // Stored version replaced
+		oldBlobs []blob
+		blobs    []blob
+	}
+	replaced := make([]replacement, 0, len(docs))
+	undo := func() {
+		for i := len(replaced) - 1; i >= 0; i-- {
+			r := replaced[i]
+			ds.pageManager.UpdateDocument(r.page, r.slot, r.old)
+			ds.trackPage(r.page)
+			ds.blobs.remove(r.blobs)
+		}
+	}
+
+	dirty := make(map[storage.PageID]*storage.Page)
+	for i, doc := range docs {
+		location, exists := ds.locationMap[ids[i]]
+		if !exists {
+			undo()
+			return kindOf(ErrDocumentNotFound, "document not found: %s", ids[i])
+		}
+		if location.Quarantined {
+			undo()
+			return kindOf(ErrDocumentQuarantined, "document %s is on a quarantined page", ids[i])
+		}
+
+		// Documents of the batch sharing a page must change the same copy
+		page, err := ds.loadPageForWrite(location.PageID)
+		if err != nil {
+			undo()
+			return fmt.Errorf("failed to load page: %w", err)
+		}
+		old, err := ds.pageManager.GetDocument(page, location.SlotID)
+		if err != nil {
+			undo()
+			return fmt.Errorf("failed to read document %s: %w", ids[i], err)
+		}
+
+		stored, blobs := ds.blobs.split(doc)
+		if err := ds.blobs.write(blobs); err != nil {
+			undo()
+			return err
+		}
+		err = ds.pageManager.UpdateDocument(page, location.SlotID, stored)
+		ds.trackPage(page)
+		if err != nil {
+			ds.blobs.remove(blobs)
+			undo()
+			return fmt.Errorf("failed to update document %s: %w", ids[i], err)
+		}
+
+		replaced = append(replaced, replacement{
+			page:     page,
+			slot:     location.SlotID,
+			old:      old,
+			oldBlobs: ds.blobs.refs(old),
+			blobs:    blobs,
+		})
+		dirty[page.GetPage().ID] = page.GetPage()
+	}
+
+	// Flush the pages of the batch to disk
+	pages := make([]*storage.Page, 0, len(dirty))
+	for _, page := range dirty {
+		pages = append(pages, page)
+	}
+	if err := ds.diskManager.WritePages(pages); err != nil {
+		// Best effort to leave the pages on disk as they were before the batch
+		undo()
+		ds.diskManager.WritePages(pages)
+		return fmt.Errorf("failed to write pages to disk: %w", err)
+	}
+
+	for i, doc := range docs {
+		ds.blobs.remove(replaced[i].oldBlobs)
+		ds.charge(ids[i], doc)
+		ds.docCache.Put(ids[i], doc)
+	}
+	return nil
+}
+
 // Delete deletes a document by ID
 func (ds *DocumentStore) Delete(id string) error {
+	defer ds.snapshots.preserve(ds, id)()
 	ds.mu.Lock()
 	defer ds.mu.Unlock()
 
 	// Get location
 	location, exists := ds.locationMap[id]
 	if !exists {
-		return fmt.Errorf("document not found: %s", id)
+		return kindOf(ErrDocumentNotFound, "document not found: %s", id)
+	}
+
+	// A quarantined document has no slot left to free
+	if location.Quarantined {
+		delete(ds.locationMap, id)
+		ds.uncharge(id)
+		return nil
 	}
 
 	// Load the page
@@ -161,13 +397,24 @@ func (ds *DocumentStore) Delete(id string) error {
 		return fmt.Errorf("failed to load page: %w", err)
 	}
 
+	// Remember the chunks of the document
+	var blobs []blob
+	if ds.blobs != nil {
+		if old, err := ds.pageManager.GetDocument(page, location.SlotID); err == nil {
+			blobs = ds.blobs.refs(old)
+		}
+	}
+
 	// Delete from page
 	if err := ds.pageManager.DeleteDocument(page, location.SlotID); err != nil {
 		return fmt.Errorf("failed to delete document from page: %w", err)
 	}
+	ds.trackPage(page)
+	ds.blobs.remove(blobs)
 
 	// Remove from location map
 	delete(ds.locationMap, id)
+	ds.uncharge(id)
 
 	// Note: We don't explicitly remove from cache - it will be evicted naturally
 	// or expire based on LRU policy. Attempting to Get() a deleted document will
@@ -178,6 +425,116 @@ func (ds *DocumentStore) Delete(id string) error {
 		return fmt.Errorf("failed to write page to disk: %w", err)
 	}
 
+	// Give a page without documents back to the page store
+	if ds.pageManager.GetPageCapacity(page).ActiveSlotCount == 0 {
+		ds.releasePage(location.PageID)
+	}
+
+	return nil
+}
+
+// DeleteMany deletes a batch of documents. Each page holding some of them
+// is written to disk once for the whole batch. If a document isn't stored
+// or its page can't be read, none are deleted.
+func (ds *DocumentStore) DeleteMany(ids []string) error {
+	defer ds.snapshots.preserve(ds, ids...)()
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	pages := make(map[storage.PageID]*storage.SlottedPage)
+	for _, id := range ids {
+		location, exists := ds.locationMap[id]
+		if !exists {
+			return kindOf(ErrDocumentNotFound, "document not found: %s", id)
+		}
+		if _, loaded := pages[location.PageID]; loaded || location.Quarantined {
+			continue
+		}
+		page, err := ds.loadPageForWrite(location.PageID)
+		if err != nil {
+			return fmt.Errorf("failed to load page: %w", err)
+		}
+		pages[location.PageID] = page
+	}
+
+	var blobs []blob
+	for _, id := range ids {
+		location := ds.locationMap[id]
+		if location.Quarantined {
+			delete(ds.locationMap, id)
+			ds.uncharge(id)
+			continue
+		}
+		page := pages[location.PageID]
+
+		// Remember the chunks of the document
+		if ds.blobs != nil {
+			if old, err := ds.pageManager.GetDocument(page, location.SlotID); err == nil {
+				blobs = append(blobs, ds.blobs.refs(old)...)
+			}
+		}
+		if err := ds.pageManager.DeleteDocument(page, location.SlotID); err != nil {
+			return fmt.Errorf("failed to delete document %s from page: %w", id, err)
+		}
+		ds.trackPage(page)
+		delete(ds.locationMap, id)
+		ds.uncharge(id)
+	}
+	ds.blobs.remove(blobs)
+
+	// Flush the pages of the batch to disk
+	written := make([]*storage.Page, 0, len(pages))
+	for _, page := range pages {
+		written = append(written, page.GetPage())
+	}
+	if err := ds.diskManager.WritePages(written); err != nil {
+		return fmt.Errorf("failed to write pages to disk: %w", err)
+	}
+
+	// Give pages without documents back to the page store
+	for pageID, page := range pages {
+		if ds.pageManager.GetPageCapacity(page).ActiveSlotCount == 0 {
+			ds.releasePage(pageID)
+		}
+	}
+	return nil
+}
+
+// Truncate deletes all documents and returns their pages to the page store
+func (ds *DocumentStore) Truncate() error {
+	defer ds.snapshots.preserve(ds, ds.GetAllIDs()...)()
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	pageIDs := make(map[storage.PageID]struct{}, len(ds.activePagesMap))
+	for _, location := range ds.locationMap {
+		if !location.Quarantined {
+			pageIDs[location.PageID] = struct{}{}
+		}
+	}
+	for pageID := range ds.activePagesMap {
+		pageIDs[pageID] = struct{}{}
+	}
+
+	for id := range ds.charges {
+		ds.uncharge(id)
+	}
+	ds.locationMap = make(map[string]*DocumentLocation)
+	ds.activePagesMap = make(map[storage.PageID]*storage.SlottedPage)
+	ds.freeSpace.Reset()
+	ds.docCache.Clear()
+
+	for pageID := range pageIDs {
+		if err := ds.diskManager.DeallocatePage(pageID); err != nil {
+			return fmt.Errorf("failed to deallocate page %d: %w", pageID, err)
+		}
+	}
+
+	if ds.blobs != nil {
+		if err := ds.blobs.chunks.Truncate(); err != nil {
+			return fmt.Errorf("failed to truncate blob chunks: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -190,14 +547,17 @@ func (ds *DocumentStore) Exists(id string) bool {
 	return exists
 }
 
-// GetAllIDs returns all document IDs
+// GetAllIDs returns all document IDs. Documents left on a quarantined page
+// can't be read and are skipped, though Count includes them.
 func (ds *DocumentStore) GetAllIDs() []string {
 	ds.mu.RLock()
 	defer ds.mu.RUnlock()
 
 	ids := make([]string, 0, len(ds.locationMap))
-	for id := range ds.locationMap {
-		ids = append(ids, id)
+	for id, location := range ds.locationMap {
+		if !location.Quarantined {
+			ids = append(ids, id)
+		}
 	}
 	return ids
 }
@@ -210,19 +570,35 @@ func (ds *DocumentStore) Count() int {
 	return len(ds.locationMap)
 }
 
-// findOrAllocatePageForDocument finds a page with enough space or allocates a new one
+// findOrAllocatePageForDocument finds a page with enough space or allocates a new one.
+// Pages with free space left by deletes are reused before the page store
+// grows, picking the fullest page the document fits in.
 func (ds *DocumentStore) findOrAllocatePageForDocument(doc *document.Document) (*storage.SlottedPage, error) {
 	// Estimate document size
 	docSize := ds.serializer.EstimateDocumentSize(doc)
+	needed := docSize + storage.SlotEntrySize
+
+	// Try to reuse a page from the free space map
+	if pageID, space, ok := ds.freeSpace.Find(needed); ok {
+		page, err := ds.loadPageForWrite(pageID)
+		if err == nil {
+			// Deleted documents may have left the space in holes
+			if space.Contiguous < needed {
+				if err := page.Compact(); err != nil {
+					return nil, fmt.Errorf("failed to compact page: %w", err)
+				}
+				ds.trackPage(page)
+			}
+			if int(page.ContiguousFreeSpace()) >= needed {
+				return page, nil
+			}
+			ds.trackPage(page)
+		}
+	}
 
-	// Try to find an active page with enough space
+	// Drop pages that are nearly full from the active pages
 	for pageID, page := range ds.activePagesMap {
-		capacity := ds.pageManager.GetPageCapacity(page)
-		if capacity.ContiguousFreeSpace >= docSize+storage.SlotEntrySize {
-			return page, nil
-		}
-		// If page is nearly full, remove from active pages
-		if capacity.ContiguousFreeSpace < 256 { // Arbitrary threshold
+		if page.TotalFreeSpace() < storage.FreeSpaceMapMinFree {
 			delete(ds.activePagesMap, pageID)
 		}
 	}
@@ -234,7 +610,7 @@ func (ds *DocumentStore) findOrAllocatePageForDocument(doc *document.Document) (
 	}
 
 	// Create a new data page
-	page := storage.NewPage(pageID, storage.PageTypeData)
+	page := storage.NewPageOfSize(pageID, storage.PageTypeData, ds.diskManager.PageSize())
 	slottedPage, err := storage.NewSlottedPage(page)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create slotted page: %w", err)
@@ -246,11 +622,55 @@ func (ds *DocumentStore) findOrAllocatePageForDocument(doc *document.Document) (
 	return slottedPage, nil
 }
 
+// loadPageForWrite returns a page to place documents in. Unlike
+// loadOrGetActivePage it always keeps the page among the active pages, so
+// every document placed in it lands on the same copy.
+func (ds *DocumentStore) loadPageForWrite(pageID storage.PageID) (*storage.SlottedPage, error) {
+	if page, exists := ds.activePagesMap[pageID]; exists {
+		return page, nil
+	}
+
+	page, err := ds.diskManager.ReadPage(pageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page from disk: %w", err)
+	}
+
+	slottedPage, err := storage.LoadSlottedPage(page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load slotted page: %w", err)
+	}
+
+	ds.activePagesMap[pageID] = slottedPage
+	return slottedPage, nil
+}
+
+// trackPage records the free space of a page in the free space map
+func (ds *DocumentStore) trackPage(page *storage.SlottedPage) {
+	ds.freeSpace.Update(page.GetPage().ID, storage.PageSpace{
+		Contiguous: int(page.ContiguousFreeSpace()),
+		Fragmented: int(page.FragmentedSpace()),
+	})
+}
+
+// releasePage returns a page without documents to the page store. The page
+// stays in the free space map if the page store can't take it back.
+func (ds *DocumentStore) releasePage(pageID storage.PageID) {
+	if err := ds.diskManager.DeallocatePage(pageID); err != nil {
+		return
+	}
+	delete(ds.activePagesMap, pageID)
+	ds.freeSpace.Remove(pageID)
+}
+
 // loadOrGetActivePage loads a page from disk or returns it from active pages cache
+// Must be called with ds.mu held; readers may hold it for reading
 func (ds *DocumentStore) loadOrGetActivePage(pageID storage.PageID) (*storage.SlottedPage, error) {
 	// Check if page is already in active pages
-	if page, exists := ds.activePagesMap[pageID]; exists {
-		return page, nil
+	ds.pagesMu.Lock()
+	cached, exists := ds.activePagesMap[pageID]
+	ds.pagesMu.Unlock()
+	if exists {
+		return cached, nil
 	}
 
 	// Load from disk
@@ -265,15 +685,21 @@ func (ds *DocumentStore) loadOrGetActivePage(pageID storage.PageID) (*storage.Sl
 	}
 
 	// Add to active pages cache (with a size limit to prevent unbounded growth)
+	ds.pagesMu.Lock()
 	if len(ds.activePagesMap) < 100 {
 		ds.activePagesMap[pageID] = slottedPage
 	}
+	ds.pagesMu.Unlock()
 
 	return slottedPage, nil
 }
 
 // readDocumentFromDisk reads a document from disk
 func (ds *DocumentStore) readDocumentFromDisk(location *DocumentLocation) (*document.Document, error) {
+	if location.Quarantined {
+		return nil, ErrDocumentQuarantined
+	}
+
 	// Load the page
 	page, err := ds.loadOrGetActivePage(location.PageID)
 	if err != nil {
@@ -286,9 +712,25 @@ func (ds *DocumentStore) readDocumentFromDisk(location *DocumentLocation) (*docu
 		return nil, err
 	}
 
+	// Put large binary fields back together
+	if err := ds.blobs.join(doc); err != nil {
+		return nil, err
+	}
+
 	return doc, nil
 }
 
+// storedSize returns the size of a document as written to its page, after
+// large binary fields are moved to chunks
+func (ds *DocumentStore) storedSize(doc *document.Document) (int, error) {
+	stored, _ := ds.blobs.split(doc)
+	data, err := document.NewEncoder().Encode(stored)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode document: %w", err)
+	}
+	return len(data), nil
+}
+
 // FlushAll flushes all cached pages to disk
 func (ds *DocumentStore) FlushAll() error {
 	ds.mu.Lock()
@@ -309,10 +751,19 @@ func (ds *DocumentStore) Stats() map[string]interface{} {
 	defer ds.mu.RUnlock()
 
 	cacheStats := ds.docCache.Stats()
+	freeSpace := ds.freeSpace.Stats()
+	ds.pagesMu.Lock()
+	activePages := len(ds.activePagesMap)
+	ds.pagesMu.Unlock()
 
 	return map[string]interface{}{
 		"document_count":   len(ds.locationMap),
-		"active_pages":     len(ds.activePagesMap),
+		"active_pages":     activePages,
+		"data_pages":       freeSpace["pages"],
+		"reusable_pages":   freeSpace["reusable_pages"],
+		"free_bytes":       freeSpace["free_bytes"],
+		"fragmented_bytes": freeSpace["fragmented_bytes"],
+		"fragmentation":    freeSpace["fragmentation"],
 		"cache_size":       cacheStats["size"],
 		"cache_capacity":   cacheStats["capacity"],
 		"cache_hit_rate":   cacheStats["hit_rate"],
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/mnohosten/laura-db/pkg/document"
@@ -502,3 +503,104 @@ func TestDocumentStore_Persistence(t *testing.T) {
 		}
 	}
 }
+
+func TestDocumentStore_ReusesFreedSpace(t *testing.T) {
+	docStore, _, cleanup := createTestDocumentStore(t)
+	defer cleanup()
+
+	payload := strings.Repeat("x", 200)
+	insert := func(id string) {
+		doc := document.NewDocumentFromMap(map[string]interface{}{
+			"_id":     id,
+			"payload": payload,
+		})
+		if err := docStore.Insert(id, doc); err != nil {
+			t.Fatalf("Failed to insert document %s: %v", id, err)
+		}
+	}
+
+	for i := 0; i < 200; i++ {
+		insert(fmt.Sprintf("doc%d", i))
+	}
+	pagesBefore := docStore.diskManager.Stats()["next_page_id"]
+
+	// Delete every other document and insert as many new ones: the holes
+	// left by the deletes are reused instead of growing the file
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 200; i += 2 {
+			id := fmt.Sprintf("doc%d", i)
+			if round > 0 {
+				id = fmt.Sprintf("r%d-doc%d", round-1, i)
+			}
+			if err := docStore.Delete(id); err != nil {
+				t.Fatalf("Failed to delete document %s: %v", id, err)
+			}
+		}
+		for i := 0; i < 200; i += 2 {
+			insert(fmt.Sprintf("r%d-doc%d", round, i))
+		}
+	}
+
+	if pagesAfter := docStore.diskManager.Stats()["next_page_id"]; pagesAfter != pagesBefore {
+		t.Errorf("Expected the page store to stay at %v pages, got %v", pagesBefore, pagesAfter)
+	}
+
+	// Every document is still readable after pages were compacted
+	for i := 0; i < 200; i++ {
+		id := fmt.Sprintf("doc%d", i)
+		if i%2 == 0 {
+			id = fmt.Sprintf("r4-doc%d", i)
+		}
+		doc, err := docStore.Get(id)
+		if err != nil {
+			t.Fatalf("Failed to get document %s: %v", id, err)
+		}
+		if got, _ := doc.Get("_id"); got != id {
+			t.Errorf("Expected document %s, got %v", id, got)
+		}
+	}
+	docStore.docCache.Clear()
+	for i := 1; i < 200; i += 2 {
+		id := fmt.Sprintf("doc%d", i)
+		doc, err := docStore.Get(id)
+		if err != nil {
+			t.Fatalf("Failed to read document %s from disk: %v", id, err)
+		}
+		if got, _ := doc.Get("_id"); got != id {
+			t.Errorf("Expected document %s from disk, got %v", id, got)
+		}
+	}
+}
+
+func TestDocumentStore_ReleasesEmptyPages(t *testing.T) {
+	docStore, _, cleanup := createTestDocumentStore(t)
+	defer cleanup()
+
+	for i := 0; i < 50; i++ {
+		doc := document.NewDocumentFromMap(map[string]interface{}{
+			"_id":     fmt.Sprintf("doc%d", i),
+			"payload": strings.Repeat("x", 200),
+		})
+		if err := docStore.Insert(fmt.Sprintf("doc%d", i), doc); err != nil {
+			t.Fatalf("Failed to insert document %d: %v", i, err)
+		}
+	}
+	if pages := docStore.Stats()["data_pages"].(int); pages < 2 {
+		t.Fatalf("Expected documents to span several pages, got %d", pages)
+	}
+
+	for i := 0; i < 50; i++ {
+		if err := docStore.Delete(fmt.Sprintf("doc%d", i)); err != nil {
+			t.Fatalf("Failed to delete document %d: %v", i, err)
+		}
+	}
+
+	stats := docStore.Stats()
+	if stats["data_pages"].(int) != 0 {
+		t.Errorf("Expected no data pages after deleting everything, got %v", stats["data_pages"])
+	}
+	disk := docStore.diskManager.Stats()
+	if disk["free_pages"].(uint32) == 0 {
+		t.Errorf("Expected empty pages back in the page store, got %v", disk)
+	}
+}
@@ -0,0 +1,168 @@
+package database
+
+import "fmt"
+
+// Durability is how far a write must reach before it is acknowledged. It is
+// the single-node counterpart of the journal option of a replication write
+// concern.
+//
+// Documents are always written to their data pages before a write returns,
+// and the pages are only fsynced by checkpoints; what the levels change is
+// whether the write's oplog entry is in the WAL by then, and whether the WAL
+// has been fsynced. If the process crashes, writes the OS already holds
+// survive at every level. If the machine crashes or loses power:
+//
+//   - DurabilityNone loses every write since the last checkpoint, up to
+//     Config.CheckpointInterval. Nothing is logged for the write, so it
+//     costs no WAL I/O.
+//   - DurabilityWAL also loses every write since the WAL was last
+//     fsynced, by a checkpoint, a synced write or a transaction commit. The
+//     write costs an append to the WAL, which WAL archiving can ship.
+//   - DurabilitySynced loses nothing acknowledged: the WAL is fsynced before
+//     the write returns. Concurrent synced writes and commits share fsyncs
+//     under group commit.
+//
+// Crash recovery doesn't replay the oplog entries in the WAL; a write that
+// only survived there is brought back by restoring a WAL archive (see
+// replication.WALArchiver).
+type Durability string
+
+const (
+	// DurabilityNone acknowledges writes once they are in the OS page cache
+	DurabilityNone Durability = "none"
+
+	// DurabilityWAL acknowledges writes once they are appended to the WAL
+	DurabilityWAL Durability = "wal"
+
+	// DurabilitySynced acknowledges writes once the WAL is fsynced
+	DurabilitySynced Durability = "synced"
+)
+
+// ParseDurability parses a durability level. The empty string is
+// DurabilityNone.
+func ParseDurability(s string) (Durability, error) {
+	switch Durability(s) {
+	case "", DurabilityNone:
+		return DurabilityNone, nil
+	case DurabilityWAL, DurabilitySynced:
+		return Durability(s), nil
+	default:
+		return "", fmt.Errorf("invalid durability %q: must be none, wal or synced", s)
+	}
+}
+
+// rank orders the levels from the least to the most durable
+func (d Durability) rank() int32 {
+	switch d {
+	case DurabilityWAL:
+		return 1
+	case DurabilitySynced:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// durabilityOfRank is the level of a rank
+func durabilityOfRank(rank int32) Durability {
+	switch rank {
+	case 1:
+		return DurabilityWAL
+	case 2:
+		return DurabilitySynced
+	default:
+		return DurabilityNone
+	}
+}
+
+// WriteOptions holds options for a single write
+type WriteOptions struct {
+	// Durability the write must reach before it returns. The database
+	// default is a floor: a write may ask for more durability, not less.
+	Durability Durability
+}
+
+// SetDurability sets the default durability of collection writes. See
+// Durability for what each level loses in a crash.
+func (db *Database) SetDurability(durability Durability) error {
+	level, err := ParseDurability(string(durability))
+	if err != nil {
+		return err
+	}
+	db.changes.durability.Store(level.rank())
+	return nil
+}
+
+// Durability returns the default durability of collection writes
+func (db *Database) Durability() Durability {
+	return durabilityOfRank(db.changes.durability.Load())
+}
+
+// durable runs a write with opts, returning once it is as durable as they
+// ask. The change log applies the database default to every entry it logs,
+// so only levels above it need more.
+func (c *Collection) durable(opts *WriteOptions, write func() error) error {
+	if opts == nil || c.changes == nil {
+		return write()
+	}
+	level, err := ParseDurability(string(opts.Durability))
+	if err != nil {
+		return err
+	}
+	if level.rank() <= c.changes.durability.Load() {
+		return write()
+	}
+
+	// Entries are logged to the WAL while a write asking for it runs
+	c.changes.walWrites.Add(1)
+	err = write()
+	c.changes.walWrites.Add(-1)
+	if err != nil || level != DurabilitySynced {
+		return err
+	}
+	if err := c.changes.wal.Sync(); err != nil {
+		return fmt.Errorf("write applied but not synced: %w", err)
+	}
+	return nil
+}
+
+// InsertOneWithOptions inserts a single document, returning once it is as
+// durable as opts ask
+func (c *Collection) InsertOneWithOptions(doc map[string]interface{}, opts *WriteOptions) (string, error) {
+	var id string
+	err := c.durable(opts, func() (err error) {
+		id, err = c.InsertOne(doc)
+		return err
+	})
+	return id, err
+}
+
+// UpdateManyWithOptions updates all documents matching the filter,
+// returning once the updates are as durable as opts ask
+func (c *Collection) UpdateManyWithOptions(filter map[string]interface{}, update map[string]interface{}, opts *WriteOptions) (*UpdateResult, error) {
+	var result *UpdateResult
+	err := c.durable(opts, func() (err error) {
+		result, err = c.UpdateMany(filter, update)
+		return err
+	})
+	return result, err
+}
+
+// DeleteOneWithOptions deletes a single document matching the filter,
+// returning once the delete is as durable as opts ask
+func (c *Collection) DeleteOneWithOptions(filter map[string]interface{}, opts *WriteOptions) error {
+	return c.durable(opts, func() error {
+		return c.DeleteOne(filter)
+	})
+}
+
+// DeleteManyWithOptions deletes all documents matching the filter,
+// returning once the deletes are as durable as opts ask
+func (c *Collection) DeleteManyWithOptions(filter map[string]interface{}, opts *WriteOptions) (*DeleteResult, error) {
+	var result *DeleteResult
+	err := c.durable(opts, func() (err error) {
+		result, err = c.DeleteMany(filter)
+		return err
+	})
+	return result, err
+}
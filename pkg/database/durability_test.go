@@ -0,0 +1,110 @@
+package database
+
+import "testing"
+
+// walSyncs returns how many fsyncs the database's WAL has issued
+func walSyncs(db *Database) int64 {
+	return db.WAL().CommitStats()["syncs"].(int64)
+}
+
+func TestWriteDurability(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if db.Durability() != DurabilityNone {
+		t.Fatalf("Expected default durability none, got %s", db.Durability())
+	}
+
+	coll := db.Collection("events")
+	start := db.WAL().CurrentLSN()
+	syncs := walSyncs(db)
+
+	// none logs nothing to the WAL
+	if _, err := coll.InsertOne(map[string]interface{}{"_id": "e1"}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	if entries := operationRecords(t, db, start); len(entries) != 0 {
+		t.Fatalf("Expected no operation records, got %d", len(entries))
+	}
+
+	// wal appends the write without syncing
+	if _, err := coll.InsertOneWithOptions(map[string]interface{}{"_id": "e2"}, &WriteOptions{Durability: DurabilityWAL}); err != nil {
+		t.Fatalf("InsertOneWithOptions failed: %v", err)
+	}
+	entries := operationRecords(t, db, start)
+	if len(entries) != 1 || entries[0].Document["_id"] != "e2" {
+		t.Fatalf("Expected the insert of e2 in the WAL, got %v", entries)
+	}
+	if got := walSyncs(db); got != syncs {
+		t.Errorf("Expected no WAL sync, got %d", got-syncs)
+	}
+
+	// synced syncs the WAL before returning
+	if _, err := coll.UpdateManyWithOptions(map[string]interface{}{}, map[string]interface{}{"$set": map[string]interface{}{"seen": true}}, &WriteOptions{Durability: DurabilitySynced}); err != nil {
+		t.Fatalf("UpdateManyWithOptions failed: %v", err)
+	}
+	if got := len(operationRecords(t, db, start)); got != 3 {
+		t.Errorf("Expected 3 operation records, got %d", got)
+	}
+	if got := walSyncs(db); got != syncs+1 {
+		t.Errorf("Expected one WAL sync, got %d", got-syncs)
+	}
+
+	if _, err := coll.InsertOneWithOptions(map[string]interface{}{"_id": "e3"}, &WriteOptions{Durability: "fast"}); err == nil {
+		t.Error("Expected an invalid durability to be rejected")
+	}
+	if count, _ := coll.Count(map[string]interface{}{}); count != 2 {
+		t.Errorf("Expected the rejected write not to be applied, got %d documents", count)
+	}
+}
+
+func TestDefaultDurability(t *testing.T) {
+	config := DefaultConfig(t.TempDir())
+	config.Durability = DurabilityWAL
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("events")
+	start := db.WAL().CurrentLSN()
+	syncs := walSyncs(db)
+
+	// Every write follows the default
+	coll.InsertOne(map[string]interface{}{"_id": "e1"})
+	coll.DeleteOne(map[string]interface{}{"_id": "e1"})
+	if got := len(operationRecords(t, db, start)); got != 2 {
+		t.Fatalf("Expected 2 operation records, got %d", got)
+	}
+	if got := walSyncs(db); got != syncs {
+		t.Errorf("Expected no WAL sync, got %d", got-syncs)
+	}
+
+	// The default is a floor: a write asking for none is still synced
+	if err := db.SetDurability(DurabilitySynced); err != nil {
+		t.Fatalf("SetDurability failed: %v", err)
+	}
+	if _, err := coll.InsertOneWithOptions(map[string]interface{}{"_id": "e2"}, &WriteOptions{Durability: DurabilityNone}); err != nil {
+		t.Fatalf("InsertOneWithOptions failed: %v", err)
+	}
+	if got := walSyncs(db); got != syncs+1 {
+		t.Errorf("Expected one WAL sync, got %d", got-syncs)
+	}
+
+	if err := db.SetDurability("journaled"); err == nil {
+		t.Error("Expected an invalid durability to be rejected")
+	}
+	if db.Durability() != DurabilitySynced {
+		t.Errorf("Expected durability to stay synced, got %s", db.Durability())
+	}
+
+	config = DefaultConfig(t.TempDir())
+	config.Durability = "always"
+	if _, err := Open(config); err == nil {
+		t.Error("Expected Open to reject an invalid durability")
+	}
+}
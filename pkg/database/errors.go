@@ -1,14 +1,235 @@
 package database
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
 
+	"github.com/mnohosten/laura-db/pkg/mvcc"
+)
+
+// Errors of a general kind. The errors the package returns match one of
+// them with errors.Is when they fall into it, so that callers can branch on
+// the kind of failure without parsing messages.
 var (
-	// ErrDocumentNotFound is returned when a document is not found
-	ErrDocumentNotFound = errors.New("document not found")
+	// ErrNotFound matches errors for a document, collection, index, cursor
+	// or savepoint that doesn't exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrValidation matches errors for a write that is rejected before it
+	// is applied because the document or update is invalid
+	ErrValidation = errors.New("validation failed")
 
-	// ErrCollectionNotFound is returned when a collection is not found
-	ErrCollectionNotFound = errors.New("collection not found")
+	// ErrConflict matches errors for a write that conflicts with a
+	// concurrent one and may be retried. It is mvcc.ErrConflict.
+	ErrConflict = mvcc.ErrConflict
+
+	// ErrTimeout matches errors for an operation that gave up waiting, such
+	// as a transaction commit waiting for its lock (mvcc.ErrLockTimeout) or
+	// an expired cursor
+	ErrTimeout = errors.New("operation timed out")
+)
+
+var (
+	// ErrDocumentNotFound is returned when a document is not found. It
+	// matches ErrNotFound.
+	ErrDocumentNotFound = kindOf(ErrNotFound, "document not found")
+
+	// ErrCollectionNotFound is returned when a collection is not found. It
+	// matches ErrNotFound.
+	ErrCollectionNotFound = kindOf(ErrNotFound, "collection not found")
 
 	// ErrDatabaseClosed is returned when operating on a closed database
 	ErrDatabaseClosed = errors.New("database is closed")
+
+	// ErrReadOnly is returned for writes while the database is read-only,
+	// either by SetReadOnly or as a replica set secondary
+	ErrReadOnly = errors.New("database is read-only")
+
+	// ErrDocumentTooLarge is returned when an insert or update would store a
+	// document larger than the configured maximum size. It matches
+	// ErrValidation.
+	ErrDocumentTooLarge = kindOf(ErrValidation, "document too large")
+
+	// ErrInvalidDatabaseName is returned for a database name that can't be
+	// used as a namespace or a directory name
+	ErrInvalidDatabaseName = errors.New("invalid database name")
+
+	// ErrClientClosed is returned when opening a database on a closed client
+	ErrClientClosed = errors.New("client is closed")
+
+	// ErrSnapshotReleased is returned when reading a released read snapshot
+	ErrSnapshotReleased = errors.New("snapshot is released")
+
+	// ErrDuplicateKey is returned, as a *DuplicateKeyError, when a write
+	// would store a key a unique index already holds
+	ErrDuplicateKey = errors.New("duplicate key")
+
+	// ErrInvalidReplacement is returned by ReplaceOne for a replacement that
+	// holds update operators or changes the _id of the document. It matches
+	// ErrValidation.
+	ErrInvalidReplacement = kindOf(ErrValidation, "invalid replacement document")
+
+	// ErrInvalidProjection is returned for a projection that both includes
+	// and excludes fields; only _id may be excluded from an inclusion. It
+	// matches ErrValidation.
+	ErrInvalidProjection = kindOf(ErrValidation, "invalid projection")
+
+	// ErrInvalidHint is returned for a query hinting an index that doesn't
+	// exist or can't serve the query. It matches ErrValidation.
+	ErrInvalidHint = kindOf(ErrValidation, "invalid hint")
+
+	// ErrQuotaExceeded is returned, as a *QuotaError, when a write would take
+	// a tenant over its quota or write rate
+	ErrQuotaExceeded = errors.New("quota exceeded")
+
+	// ErrInvalidResumeToken is returned when a cursor resume token can't be
+	// decoded or was issued for a different sort order
+	ErrInvalidResumeToken = errors.New("invalid resume token")
+
+	// ErrResumeTokenExpired is returned when a cursor resume token is older
+	// than the cursor's ResumeTokenTTL
+	ErrResumeTokenExpired = errors.New("resume token expired")
+
+	// ErrRestartRequired is returned, as a *RestartRequiredError, when
+	// reconfiguring a running database or server would change a setting
+	// that only takes effect on restart
+	ErrRestartRequired = errors.New("restart required")
+
+	// ErrDocumentQuarantined is returned when reading or updating a document
+	// whose page was quarantined before an intact copy of it was found. The
+	// document can be restored with Collection.RestoreDocument or deleted.
+	ErrDocumentQuarantined = errors.New("document is on a quarantined page")
 )
+
+// kindError is an error that matches a general kind of error with errors.Is
+// without mentioning it in its message
+type kindError struct {
+	message string
+	kinds   []error
+}
+
+// kindOf returns an error with the message of format that matches kind
+func kindOf(kind error, format string, args ...interface{}) error {
+	return &kindError{message: fmt.Sprintf(format, args...), kinds: []error{kind}}
+}
+
+// withKind makes err match kind as well, keeping its message
+func withKind(err error, kind error) error {
+	if err == nil || errors.Is(err, kind) {
+		return err
+	}
+	return &kindError{message: err.Error(), kinds: []error{err, kind}}
+}
+
+func (e *kindError) Error() string {
+	return e.message
+}
+
+// Unwrap makes kindError match its kinds
+func (e *kindError) Unwrap() []error {
+	return e.kinds
+}
+
+// txnError makes an error of the transaction manager match the kind it
+// falls into
+func txnError(err error) error {
+	if errors.Is(err, mvcc.ErrLockTimeout) {
+		return withKind(err, ErrTimeout)
+	}
+	return err
+}
+
+// DuplicateKeyError reports a write rejected by a unique index. Fields and
+// Values hold the indexed fields and the values that collided, in index
+// order. It matches ErrDuplicateKey with errors.Is.
+type DuplicateKeyError struct {
+	Collection string
+	Index      string
+	Fields     []string
+	Values     []interface{}
+}
+
+func (e *DuplicateKeyError) Error() string {
+	pairs := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		pairs[i] = fmt.Sprintf("%s: %v", field, e.Values[i])
+	}
+	return fmt.Sprintf("duplicate key in unique index %s of collection %s: {%s}", e.Index, e.Collection, strings.Join(pairs, ", "))
+}
+
+// Unwrap makes DuplicateKeyError match ErrDuplicateKey
+func (e *DuplicateKeyError) Unwrap() error {
+	return ErrDuplicateKey
+}
+
+// QuotaError reports a write rejected by a tenant's quota. Resource is
+// "bytes" or "documents" when the write would store Requested of them
+// beyond Limit, or "operations" when it would write Requested documents
+// beyond the Limit per second. It matches ErrQuotaExceeded with errors.Is.
+type QuotaError struct {
+	Tenant    string
+	Resource  string
+	Limit     int64
+	Requested int64
+}
+
+func (e *QuotaError) Error() string {
+	if e.Resource == "operations" {
+		return fmt.Sprintf("quota exceeded for tenant %s: %d writes exceed the limit of %d operations per second", e.Tenant, e.Requested, e.Limit)
+	}
+	return fmt.Sprintf("quota exceeded for tenant %s: %d %s exceed the limit of %d", e.Tenant, e.Requested, e.Resource, e.Limit)
+}
+
+// Unwrap makes QuotaError match ErrQuotaExceeded
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// RestartRequiredError reports a reconfiguration rejected because Fields,
+// the settings it would change, can't change at runtime. It matches
+// ErrRestartRequired with errors.Is.
+type RestartRequiredError struct {
+	Fields []string
+}
+
+func (e *RestartRequiredError) Error() string {
+	return fmt.Sprintf("changing %s requires a restart", strings.Join(e.Fields, ", "))
+}
+
+// Unwrap makes RestartRequiredError match ErrRestartRequired
+func (e *RestartRequiredError) Unwrap() error {
+	return ErrRestartRequired
+}
+
+// ValidationError reports a write rejected because a field of the document
+// or update is invalid. It matches ErrValidation with errors.Is.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// Unwrap makes ValidationError match ErrValidation
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// IndexedError reports the failure of one document of a batch write, with
+// Index its position in the batch. It matches Err with errors.Is.
+type IndexedError struct {
+	Index int
+	Err   error
+}
+
+func (e IndexedError) Error() string {
+	return fmt.Sprintf("document %d: %v", e.Index, e.Err)
+}
+
+// Unwrap makes IndexedError match the error of its document
+func (e IndexedError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/mvcc"
+)
+
+func TestErrorKinds(t *testing.T) {
+	config := DefaultConfig(t.TempDir())
+	config.MaxDocumentSize = 200
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	if _, err := users.InsertOne(map[string]interface{}{"_id": "u1", "name": "alice"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	// A taken _id reports the field and value
+	_, err = users.InsertOne(map[string]interface{}{"_id": "u1"})
+	var dupErr *DuplicateKeyError
+	if !errors.As(err, &dupErr) || dupErr.Index != "_id_" || dupErr.Fields[0] != "_id" || dupErr.Values[0] != "u1" {
+		t.Errorf("Expected a duplicate _id u1, got %v", err)
+	}
+	_, err = users.InsertMany([]map[string]interface{}{{"_id": "u2"}, {"_id": "u1"}})
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("Expected ErrDuplicateKey from InsertMany, got %v", err)
+	}
+
+	// Not found
+	err = users.UpdateOne(map[string]interface{}{"_id": "missing"}, map[string]interface{}{"$set": map[string]interface{}{"a": 1}})
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+	err = db.DropCollection("missing")
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected ErrCollectionNotFound, got %v", err)
+	}
+	if err.Error() != "collection missing does not exist" {
+		t.Errorf("Expected the message to be kept, got %q", err.Error())
+	}
+	if err := users.DropIndex("missing_1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an index, got %v", err)
+	}
+	if _, err := db.CursorManager().GetCursor("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a cursor, got %v", err)
+	}
+
+	// Timeout
+	cursor, err := db.CursorManager().CreateCursor(users, nil, &CursorOptions{BatchSize: 10, Timeout: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create cursor: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := db.CursorManager().GetCursor(cursor.ID()); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected an expired cursor to match ErrTimeout, got %v", err)
+	}
+
+	// Validation
+	err = users.UpdateOne(map[string]interface{}{"_id": "u1"}, map[string]interface{}{"$set": map[string]interface{}{"name.first": "a"}})
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || invalid.Field != "name.first" || !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a ValidationError on name.first, got %v", err)
+	}
+	big := make([]byte, 500)
+	if _, err := users.InsertOne(map[string]interface{}{"data": string(big)}); !errors.Is(err, ErrValidation) || !errors.Is(err, ErrDocumentTooLarge) {
+		t.Errorf("Expected ErrDocumentTooLarge as ErrValidation, got %v", err)
+	}
+	if err := users.ReplaceOne(map[string]interface{}{"_id": "u1"}, map[string]interface{}{"$set": map[string]interface{}{}}, nil); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected ErrInvalidReplacement as ErrValidation, got %v", err)
+	}
+
+	// Read-only
+	db.SetReadOnly(true)
+	if _, err := users.InsertOne(map[string]interface{}{"_id": "u3"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+	db.SetReadOnly(false)
+
+	// Conflict between two transactions writing the same document
+	s1, s2 := db.StartSession(), db.StartSession()
+	s1.UpdateOne("users", map[string]interface{}{"_id": "u1"}, map[string]interface{}{"$set": map[string]interface{}{"n": 1}})
+	s2.UpdateOne("users", map[string]interface{}{"_id": "u1"}, map[string]interface{}{"$set": map[string]interface{}{"n": 2}})
+	if err := s1.CommitTransaction(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if err := s2.CommitTransaction(); !errors.Is(err, ErrConflict) || !errors.Is(err, mvcc.ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+}
+
+func TestTxnErrorTimeout(t *testing.T) {
+	err := txnError(mvcc.ErrLockTimeout)
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, mvcc.ErrLockTimeout) {
+		t.Errorf("Expected a lock timeout to match ErrTimeout, got %v", err)
+	}
+	if err.Error() != mvcc.ErrLockTimeout.Error() {
+		t.Errorf("Expected the message to be kept, got %q", err.Error())
+	}
+
+	if err := txnError(mvcc.ErrConflict); errors.Is(err, ErrTimeout) {
+		t.Error("Expected a conflict not to match ErrTimeout")
+	}
+}
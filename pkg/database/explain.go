@@ -0,0 +1,100 @@
+package database
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+// ExplainResult describes how a query ran: the plan the planner chose and
+// what executing it read and returned
+type ExplainResult struct {
+	Collection string `json:"collection"`
+
+	// Plan chosen: "COLLECTION_SCAN", "INDEX_EXACT", "INDEX_RANGE" or
+	// "INDEX_INTERSECTION"
+	ScanType  string `json:"scanType"`
+	IndexScan bool   `json:"indexScan"`
+	IndexName string `json:"indexName,omitempty"` // Indexes joined with "+" for an intersection
+	Covered   bool   `json:"covered"`             // Answered from index entries alone
+
+	// EstimatedDocsExamined is the planner's estimate, -1 if the collection
+	// has no statistics
+	EstimatedDocsExamined int `json:"estimatedDocsExamined"`
+
+	KeysExamined int           `json:"keysExamined"` // Index entries read
+	DocsExamined int           `json:"docsExamined"` // Documents loaded and matched
+	DocsReturned int           `json:"docsReturned"`
+	ElapsedTime  time.Duration `json:"elapsedNanos"`
+
+	// Plan is the Explain output for the query
+	Plan map[string]interface{} `json:"plan"`
+}
+
+// ExplainExecution runs a query as FindWithOptions would, bypassing the
+// query cache and discarding its results, and returns the plan used with
+// execution statistics. Index scans read only the documents their index
+// entries point to; archived (cold) documents are always scanned. A nil
+// options is no options.
+func (c *Collection) ExplainExecution(filter map[string]interface{}, options *QueryOptions) (*ExplainResult, error) {
+	if options == nil {
+		options = &QueryOptions{}
+	}
+	if err := checkProjection(options.Projection); err != nil {
+		return nil, err
+	}
+	q := optionsQuery(filter, options)
+
+	c.mu.RLock()
+	plan, explanation := c.explainQuery(q)
+	result := c.explainResult(plan, explanation)
+
+	start := time.Now()
+	results, err := c.readQuery(q)
+	result.ElapsedTime = time.Since(start)
+	c.mu.RUnlock()
+	c.persistUpgrades()
+	if err != nil {
+		return nil, err
+	}
+
+	result.DocsReturned = len(results)
+	return result, nil
+}
+
+// explainResult describes a query plan, counting the index entries and
+// documents it reads
+// Must be called with c.mu held
+func (c *Collection) explainResult(plan *query.QueryPlan, explanation map[string]interface{}) *ExplainResult {
+	result := &ExplainResult{
+		Collection:            c.name,
+		IndexScan:             plan.UseIndex || plan.UseIntersection,
+		Covered:               plan.IsCovered,
+		EstimatedDocsExamined: plan.EstimatedDocs,
+		DocsExamined:          c.coldCount(),
+		Plan:                  explanation,
+	}
+	result.ScanType, _ = explanation["scanType"].(string)
+
+	if plan.UseIntersection {
+		names := make([]string, len(plan.IntersectPlans))
+		for i, ip := range plan.IntersectPlans {
+			names[i] = ip.IndexName
+		}
+		result.IndexName = strings.Join(names, "+")
+	} else if plan.UseIndex {
+		result.IndexName = plan.IndexName
+	}
+
+	ids, indexed := plan.DocumentIDs()
+	if !indexed {
+		result.DocsExamined += c.docStore.Count()
+		return result
+	}
+	result.KeysExamined = len(ids)
+	if !plan.IsCovered {
+		result.DocsExamined += len(ids)
+	}
+	return result
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+func TestExplainExecution(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	users := db.Collection("users")
+	for i := 0; i < 20; i++ {
+		users.InsertOne(map[string]interface{}{"name": fmt.Sprintf("user%d", i), "age": int64(20 + i)})
+	}
+
+	filter := map[string]interface{}{"age": map[string]interface{}{"$gte": int64(35)}}
+
+	// Without an index every document is scanned
+	result, err := users.ExplainExecution(filter, nil)
+	if err != nil {
+		t.Fatalf("ExplainExecution failed: %v", err)
+	}
+	if result.IndexScan || result.ScanType != "COLLECTION_SCAN" || result.IndexName != "" {
+		t.Errorf("Expected a collection scan, got %+v", result)
+	}
+	if result.DocsExamined != 20 || result.DocsReturned != 5 || result.KeysExamined != 0 {
+		t.Errorf("Expected 20 examined and 5 returned, got %d and %d", result.DocsExamined, result.DocsReturned)
+	}
+
+	// With an index only the matching range is read
+	if err := users.CreateIndex("age", false); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+	result, err = users.ExplainExecution(filter, &QueryOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("ExplainExecution failed: %v", err)
+	}
+	if !result.IndexScan || result.ScanType != "INDEX_RANGE" || result.IndexName != "age_1" {
+		t.Errorf("Expected a range scan of age_1, got %+v", result)
+	}
+	if result.KeysExamined != 5 || result.DocsExamined != 5 || result.DocsReturned != 2 {
+		t.Errorf("Expected 5 keys and documents examined and 2 returned, got %d, %d and %d",
+			result.KeysExamined, result.DocsExamined, result.DocsReturned)
+	}
+	if result.Collection != "users" || result.ElapsedTime <= 0 || result.Plan["indexName"] != "age_1" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	// A hint forcing a collection scan is honored
+	result, err = users.ExplainExecution(filter, &QueryOptions{Hint: query.NaturalHint})
+	if err != nil {
+		t.Fatalf("ExplainExecution failed: %v", err)
+	}
+	if result.IndexScan || result.DocsExamined != 20 || result.DocsReturned != 5 {
+		t.Errorf("Expected a collection scan returning 5 documents, got %+v", result)
+	}
+
+	// The result serializes for the CLI and HTTP API
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Failed to marshal result: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal result: %v", err)
+	}
+	for _, field := range []string{"scanType", "indexScan", "docsExamined", "docsReturned", "elapsedNanos", "plan"} {
+		if _, ok := decoded[field]; !ok {
+			t.Errorf("Expected %s in JSON output %s", field, data)
+		}
+	}
+}
+
+func TestExplainExecutionErrors(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	users := db.Collection("users")
+	users.InsertOne(map[string]interface{}{"name": "Alice"})
+
+	if _, err := users.ExplainExecution(nil, &QueryOptions{Hint: "missing_1"}); err == nil {
+		t.Error("Expected an error for a hint matching no index")
+	}
+	if _, err := users.ExplainExecution(nil, &QueryOptions{Projection: map[string]bool{"name": true, "age": false}}); err == nil {
+		t.Error("Expected an error for a mixed projection")
+	}
+}
@@ -0,0 +1,329 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+// FindOneAndDeleteOptions holds options for FindOneAndDelete
+type FindOneAndDeleteOptions struct {
+	Sort       []query.SortField // Order of the matches; the first one is deleted
+	Projection map[string]bool   // Fields of the returned document
+}
+
+// FindOneAndDelete deletes the first document matching the filter, in the
+// order of opts.Sort, and returns it. Finding and deleting happen under the
+// collection lock, so concurrent callers never get the same document, which
+// makes it suitable for popping work off a queue. Returns
+// ErrDocumentNotFound if nothing matches. With write hooks registered the
+// hooks run between finding and deleting; if another write takes the
+// document meanwhile, it fails with mvcc.ErrConflict and may be retried.
+func (c *Collection) FindOneAndDelete(filter map[string]interface{}, opts *FindOneAndDeleteOptions) (*document.Document, error) {
+	if err := c.checkWritable(); err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &FindOneAndDeleteOptions{}
+	}
+	if err := checkProjection(opts.Projection); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+
+	var doc *document.Document
+	var err error
+	if c.hasHooks() {
+		doc, err = c.findOneAndDeleteWithHooks(filter, opts)
+	} else {
+		c.mu.Lock()
+		// Cold documents move to the hot tier before they are deleted
+		if _, err = c.thawMatching(filter); err == nil {
+			doc, err = c.findFirst(filter, opts.Sort)
+		}
+		if err == nil {
+			idVal, _ := doc.Get("_id")
+			err = c.removeDocument(fmt.Sprintf("%v", idVal), doc)
+		}
+		c.mu.Unlock()
+	}
+
+	if c.auditLogger != nil {
+		count := 0
+		if err == nil {
+			count = 1
+		}
+		c.auditLogger.LogDelete(c.name, c.database, "", err == nil, count, time.Since(start), filter, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return query.NewQuery(nil).WithProjection(opts.Projection).ApplyProjection(doc), nil
+}
+
+// findOneAndDeleteWithHooks deletes the first match running the delete hooks
+func (c *Collection) findOneAndDeleteWithHooks(filter map[string]interface{}, opts *FindOneAndDeleteOptions) (*document.Document, error) {
+	if err := c.thaw(filter); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	doc, err := c.findFirst(filter, opts.Sort)
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.deleteDocumentWithHooks(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// findFirst returns the first document matching the filter in sort order
+// Must be called with c.mu held
+func (c *Collection) findFirst(filter map[string]interface{}, sort []query.SortField) (*document.Document, error) {
+	q := query.NewQuery(filter).WithLimit(1)
+	if len(sort) > 0 {
+		q.WithSort(sort)
+	}
+	docs, err := c.executeQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, ErrDocumentNotFound
+	}
+	return docs[0], nil
+}
+
+// FindOneAndUpdateOptions holds options for FindOneAndUpdate
+type FindOneAndUpdateOptions struct {
+	Sort       []query.SortField // Order of the matches; the first one is updated
+	Projection map[string]bool   // Fields of the returned document
+	ReturnNew  bool              // Return the document as updated instead of as found
+	Upsert     bool              // Insert a document when nothing matches
+}
+
+// FindOneAndUpdate updates the first document matching the filter, in the
+// order of opts.Sort, and returns it as it was before the update, or as
+// updated with opts.ReturnNew. Finding and updating happen under the
+// collection lock, so no other write comes between them: concurrent callers
+// incrementing a counter never lose an increment. Returns
+// ErrDocumentNotFound if nothing matches.
+//
+// With opts.Upsert, a document built from the equality fields of the filter
+// and the update (including $setOnInsert) is inserted when nothing matches.
+// It is returned with ReturnNew; otherwise there is no document before the
+// update and the result is nil.
+//
+// With write hooks registered the hooks run between finding and updating;
+// if another write changes the document meanwhile, it fails with
+// mvcc.ErrConflict instead of applying the update to a stale document, and
+// may be retried.
+func (c *Collection) FindOneAndUpdate(filter map[string]interface{}, update map[string]interface{}, opts *FindOneAndUpdateOptions) (*document.Document, error) {
+	if err := c.checkWritable(); err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &FindOneAndUpdateOptions{}
+	}
+	if err := checkProjection(opts.Projection); err != nil {
+		return nil, err
+	}
+
+	before, after, err := c.findOneAndUpdate(filter, update, opts.Sort, opts.Upsert)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := before
+	if opts.ReturnNew {
+		doc = after
+	}
+	if doc == nil {
+		return nil, nil
+	}
+	return query.NewQuery(nil).WithProjection(opts.Projection).ApplyProjection(doc), nil
+}
+
+// UpdateOneWithOptions updates the first document matching the filter,
+// returning once the update is as durable as opts ask. Like UpdateOne, it
+// returns ErrDocumentNotFound if nothing matches, unless opts.Upsert is set:
+// a document is then inserted holding the fields the filter matches by
+// equality, with the update applied. Fields matched by operator expressions,
+// like {"age": {"$gt": 5}}, are left out, and $setOnInsert values are only
+// applied to an inserted document. A nil opts is UpdateOne. opts.Multi is
+// only for transactions and is rejected: use UpdateManyWithOptions.
+func (c *Collection) UpdateOneWithOptions(filter map[string]interface{}, update map[string]interface{}, opts *UpdateOptions) (*UpdateResult, error) {
+	if err := c.checkWritable(); err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &UpdateOptions{}
+	}
+	if opts.Multi {
+		return nil, &ValidationError{Field: "multi", Message: "UpdateOneWithOptions updates one document; use UpdateManyWithOptions, or Session.UpdateWithOptions in a transaction"}
+	}
+
+	var before, after *document.Document
+	err := c.durable(&opts.WriteOptions, func() (err error) {
+		before, after, err = c.findOneAndUpdate(filter, update, nil, opts.Upsert)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if before == nil {
+		id, _ := after.Get("_id")
+		return &UpdateResult{UpsertedID: id}, nil
+	}
+	result := &UpdateResult{MatchedCount: 1}
+	if !reflect.DeepEqual(before.ToMap(), after.ToMap()) {
+		result.ModifiedCount = 1
+	}
+	return result, nil
+}
+
+// findOneAndUpdate updates the first document matching the filter in the
+// order of sort, or with upsert inserts one when nothing matches. It returns
+// the document before and after the update; before is nil for an insert.
+func (c *Collection) findOneAndUpdate(filter map[string]interface{}, update map[string]interface{}, sort []query.SortField, upsert bool) (*document.Document, *document.Document, error) {
+	start := time.Now()
+
+	var before, after *document.Document
+	var err error
+	if c.hasHooks() {
+		before, after, err = c.findOneAndUpdateWithHooks(filter, update, sort, upsert)
+	} else {
+		c.mu.Lock()
+		// Cold documents move to the hot tier before they change
+		if _, err = c.thawMatching(filter); err == nil {
+			before, err = c.findFirst(filter, sort)
+		}
+		if err == ErrDocumentNotFound && upsert {
+			after, err = c.upsertFromUpdate(filter, update)
+		} else if err == nil {
+			after, err = c.updateFound(before, update)
+		}
+		c.mu.Unlock()
+	}
+
+	if c.auditLogger != nil {
+		count := 0
+		if err == nil {
+			count = 1
+		}
+		c.auditLogger.LogUpdate(c.name, c.database, "", err == nil, count, time.Since(start), filter, update, err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+// findOneAndUpdateWithHooks updates the first match, or upserts, running the
+// write hooks. It returns the document before and after the update.
+func (c *Collection) findOneAndUpdateWithHooks(filter map[string]interface{}, update map[string]interface{}, sort []query.SortField, upsert bool) (*document.Document, *document.Document, error) {
+	if err := c.thaw(filter); err != nil {
+		return nil, nil, err
+	}
+	c.mu.RLock()
+	doc, err := c.findFirst(filter, sort)
+	c.mu.RUnlock()
+
+	if err == ErrDocumentNotFound && upsert {
+		d, err := c.upsertDocument(filter, update)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := c.insertOneWithHooks(d); err != nil {
+			return nil, nil, err
+		}
+		return nil, d, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	updated, err := c.updateFoundWithHooks(doc, update)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, updated, nil
+}
+
+// updateFound applies an update to a document found under the collection
+// lock and returns it as updated
+// Must be called with c.mu held
+func (c *Collection) updateFound(doc *document.Document, update map[string]interface{}) (*document.Document, error) {
+	if err := checkUpdatePaths(doc, update); err != nil {
+		return nil, err
+	}
+	updated := doc.Clone()
+	if err := c.applyUpdate(updated, update); err != nil {
+		return nil, err
+	}
+	if err := c.checkDocument(updated); err != nil {
+		return nil, err
+	}
+
+	idVal, _ := doc.Get("_id")
+	id := fmt.Sprintf("%v", idVal)
+	if err := c.checkQuota(c.quotaChange(id, updated)); err != nil {
+		return nil, err
+	}
+	if err := c.replaceDocument(id, doc, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// upsertFromUpdate inserts the document an upserting update builds from
+// filter and update, and returns it
+// Must be called with c.mu held
+func (c *Collection) upsertFromUpdate(filter map[string]interface{}, update map[string]interface{}) (*document.Document, error) {
+	d, err := c.upsertDocument(filter, update)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkQuota(quotaChange{doc: d}); err != nil {
+		return nil, err
+	}
+	if _, err := c.insertDocument(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// upsertDocument builds the document an upserting update inserts: the
+// equality fields of the filter, with the update and its $setOnInsert
+// fields applied
+func (c *Collection) upsertDocument(filter map[string]interface{}, update map[string]interface{}) (*document.Document, error) {
+	d := document.NewDocument()
+	for field, value := range filter {
+		if strings.HasPrefix(field, "$") {
+			continue
+		}
+		if cond, ok := value.(map[string]interface{}); ok && isOperatorMap(cond) {
+			continue
+		}
+		if err := d.SetNested(field, value); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.applyUpdate(d, update); err != nil {
+		return nil, err
+	}
+	if setOnInsert, ok := update["$setOnInsert"].(map[string]interface{}); ok {
+		for field, value := range setOnInsert {
+			if err := d.SetNested(field, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,409 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/mvcc"
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+func TestFindOneAndDelete(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	jobs := db.Collection("jobs")
+	for i, created := range []int64{30, 10, 20} {
+		jobs.InsertOne(map[string]interface{}{"_id": int64(i), "status": "pending", "created": created, "payload": "x"})
+	}
+	jobs.InsertOne(map[string]interface{}{"_id": int64(9), "status": "done", "created": int64(1)})
+
+	opts := &FindOneAndDeleteOptions{
+		Sort:       []query.SortField{{Field: "created", Ascending: true}},
+		Projection: map[string]bool{"created": true},
+	}
+
+	// Pops oldest-first among the matches
+	for _, want := range []int64{10, 20, 30} {
+		doc, err := jobs.FindOneAndDelete(map[string]interface{}{"status": "pending"}, opts)
+		if err != nil {
+			t.Fatalf("FindOneAndDelete failed: %v", err)
+		}
+		if created, _ := doc.Get("created"); created != want {
+			t.Errorf("Expected created %d, got %v", want, created)
+		}
+		if _, exists := doc.Get("payload"); exists {
+			t.Error("Expected payload to be projected out")
+		}
+	}
+
+	if _, err := jobs.FindOneAndDelete(map[string]interface{}{"status": "pending"}, opts); err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+	count, _ := jobs.Count(nil)
+	if count != 1 {
+		t.Errorf("Expected only the done job to remain, got %d documents", count)
+	}
+}
+
+func TestFindOneAndDeleteConcurrentWorkers(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	jobs := db.Collection("jobs")
+	const total = 200
+	for i := 0; i < total; i++ {
+		jobs.InsertOne(map[string]interface{}{"_id": int64(i), "seq": int64(i)})
+	}
+
+	var mu sync.Mutex
+	claimed := make(map[interface{}]int)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				doc, err := jobs.FindOneAndDelete(nil, &FindOneAndDeleteOptions{
+					Sort: []query.SortField{{Field: "seq", Ascending: true}},
+				})
+				if err == ErrDocumentNotFound {
+					return
+				}
+				if err != nil {
+					t.Errorf("FindOneAndDelete failed: %v", err)
+					return
+				}
+				id, _ := doc.Get("_id")
+				mu.Lock()
+				claimed[id]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != total {
+		t.Errorf("Expected %d documents claimed, got %d", total, len(claimed))
+	}
+	for id, n := range claimed {
+		if n != 1 {
+			t.Errorf("Expected document %v to be claimed once, got %d", id, n)
+		}
+	}
+}
+
+func TestFindOneAndDeleteWithHooks(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	jobs := db.Collection("jobs")
+	jobs.InsertOne(map[string]interface{}{"_id": "a", "n": int64(1)})
+
+	var deleted interface{}
+	jobs.RegisterHook(HookAfterDelete, func(ctx *HookContext) error {
+		deleted = ctx.DocumentID
+		return nil
+	})
+
+	doc, err := jobs.FindOneAndDelete(map[string]interface{}{"n": int64(1)}, nil)
+	if err != nil {
+		t.Fatalf("FindOneAndDelete failed: %v", err)
+	}
+	if id, _ := doc.Get("_id"); id != "a" {
+		t.Errorf("Expected document a, got %v", id)
+	}
+	if deleted != "a" {
+		t.Errorf("Expected the delete hook to run for a, got %v", deleted)
+	}
+	if count, _ := jobs.Count(nil); count != 0 {
+		t.Errorf("Expected 0 documents, got %d", count)
+	}
+}
+
+func TestFindOneAndUpdate(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	tasks := db.Collection("tasks")
+	for i, priority := range []int64{2, 5, 3} {
+		tasks.InsertOne(map[string]interface{}{"_id": int64(i), "status": "queued", "priority": priority})
+	}
+
+	// The highest priority match is claimed, and returned as found
+	byPriority := []query.SortField{{Field: "priority", Ascending: false}}
+	doc, err := tasks.FindOneAndUpdate(map[string]interface{}{"status": "queued"},
+		map[string]interface{}{"$set": map[string]interface{}{"status": "running"}},
+		&FindOneAndUpdateOptions{Sort: byPriority})
+	if err != nil {
+		t.Fatalf("FindOneAndUpdate failed: %v", err)
+	}
+	if id, _ := doc.Get("_id"); id != int64(1) {
+		t.Errorf("Expected task 1, got %v", id)
+	}
+	if status, _ := doc.Get("status"); status != "queued" {
+		t.Errorf("Expected the pre-image, got status %v", status)
+	}
+
+	// ReturnNew returns the post-image
+	doc, err = tasks.FindOneAndUpdate(map[string]interface{}{"status": "queued"},
+		map[string]interface{}{"$set": map[string]interface{}{"status": "running"}},
+		&FindOneAndUpdateOptions{Sort: byPriority, ReturnNew: true, Projection: map[string]bool{"_id": true, "status": true}})
+	if err != nil {
+		t.Fatalf("FindOneAndUpdate failed: %v", err)
+	}
+	if id, _ := doc.Get("_id"); id != int64(2) {
+		t.Errorf("Expected task 2, got %v", id)
+	}
+	if status, _ := doc.Get("status"); status != "running" {
+		t.Errorf("Expected the post-image, got status %v", status)
+	}
+	if _, exists := doc.Get("priority"); exists {
+		t.Error("Expected priority to be projected out")
+	}
+
+	if _, err := tasks.FindOneAndUpdate(map[string]interface{}{"status": "missing"},
+		map[string]interface{}{"$set": map[string]interface{}{"x": 1}}, nil); err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+
+	// Upserts insert from the filter and the update
+	upsert := map[string]interface{}{
+		"$set":         map[string]interface{}{"status": "queued"},
+		"$setOnInsert": map[string]interface{}{"priority": int64(1)},
+	}
+	doc, err = tasks.FindOneAndUpdate(map[string]interface{}{"_id": int64(7)}, upsert, &FindOneAndUpdateOptions{Upsert: true})
+	if err != nil || doc != nil {
+		t.Errorf("Expected no pre-image for an upsert, got %v, %v", doc, err)
+	}
+	doc, err = tasks.FindOneAndUpdate(map[string]interface{}{"_id": int64(8)}, upsert, &FindOneAndUpdateOptions{Upsert: true, ReturnNew: true})
+	if err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if priority, _ := doc.Get("priority"); priority != int64(1) {
+		t.Errorf("Expected $setOnInsert applied, got priority %v", priority)
+	}
+	if count, _ := tasks.Count(map[string]interface{}{"status": "queued"}); count != 3 {
+		t.Errorf("Expected 3 queued tasks, got %d", count)
+	}
+}
+
+func TestUpdateOneWithOptionsUpsert(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	upsert := &UpdateOptions{Upsert: true}
+	update := map[string]interface{}{
+		"$set":         map[string]interface{}{"status": "active"},
+		"$setOnInsert": map[string]interface{}{"created": "first"},
+	}
+
+	// Operator expressions contribute nothing to the inserted document
+	filter := map[string]interface{}{"name": "alice", "address.city": "SF", "age": map[string]interface{}{"$gt": int64(5)}}
+	result, err := users.UpdateOneWithOptions(filter, update, upsert)
+	if err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if result.MatchedCount != 0 || result.ModifiedCount != 0 || result.UpsertedID == nil {
+		t.Fatalf("Expected an upsert, got %+v", result)
+	}
+	doc, err := users.FindOne(map[string]interface{}{"_id": result.UpsertedID})
+	if err != nil {
+		t.Fatalf("Upserted document not found: %v", err)
+	}
+	expected := map[string]interface{}{"name": "alice", "address.city": "SF", "status": "active", "created": "first"}
+	for field, value := range expected {
+		if got, _ := doc.Get(field); got != value {
+			t.Errorf("Expected %s %v, got %v", field, value, got)
+		}
+	}
+	if _, exists := doc.Get("age"); exists {
+		t.Error("Expected no age from the $gt expression")
+	}
+
+	// $setOnInsert only applies on insert
+	update["$set"] = map[string]interface{}{"status": "seen"}
+	update["$setOnInsert"] = map[string]interface{}{"created": "second"}
+	result, err = users.UpdateOneWithOptions(map[string]interface{}{"name": "alice"}, update, upsert)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if result.MatchedCount != 1 || result.ModifiedCount != 1 || result.UpsertedID != nil {
+		t.Errorf("Expected 1 match and no upsert, got %+v", result)
+	}
+	doc, _ = users.FindOne(map[string]interface{}{"name": "alice"})
+	if created, _ := doc.Get("created"); created != "first" {
+		t.Errorf("Expected $setOnInsert skipped on update, got created %v", created)
+	}
+	if _, exists := doc.Get("$setOnInsert"); exists {
+		t.Error("Expected $setOnInsert not to be stored as a field")
+	}
+
+	// An update leaving the document as it was modifies nothing
+	result, err = users.UpdateOneWithOptions(map[string]interface{}{"name": "alice"}, update, upsert)
+	if err != nil || result.MatchedCount != 1 || result.ModifiedCount != 0 {
+		t.Errorf("Expected 1 match and none modified, got %+v, %v", result, err)
+	}
+
+	// The filter's _id is the upserted one
+	result, err = users.UpdateOneWithOptions(map[string]interface{}{"_id": "bob"}, update, upsert)
+	if err != nil || result.UpsertedID != "bob" {
+		t.Errorf("Expected bob upserted, got %+v, %v", result, err)
+	}
+
+	// Without upsert, nothing matching is an error
+	if _, err := users.UpdateOneWithOptions(map[string]interface{}{"name": "carol"}, update, nil); err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+	if count, _ := users.Count(nil); count != 2 {
+		t.Errorf("Expected 2 users, got %d", count)
+	}
+}
+
+func TestUpdateOneWithOptionsMulti(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	for _, name := range []string{"alice", "bob"} {
+		users.InsertOne(map[string]interface{}{"name": name, "status": "new"})
+	}
+	update := map[string]interface{}{"$set": map[string]interface{}{"status": "active"}}
+
+	// Multi isn't silently dropped outside a transaction
+	_, err = users.UpdateOneWithOptions(map[string]interface{}{}, update, &UpdateOptions{Multi: true})
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("Expected a validation error for Multi, got %v", err)
+	}
+	if count, _ := users.Count(map[string]interface{}{"status": "active"}); count != 0 {
+		t.Errorf("Expected no document updated, got %d", count)
+	}
+
+	// In a transaction it updates every match
+	err = db.WithTransaction(func(s *Session) error {
+		result, err := s.UpdateWithOptions("users", map[string]interface{}{}, update, &UpdateOptions{Multi: true})
+		if err == nil && result.ModifiedCount != 2 {
+			t.Errorf("Expected 2 documents modified, got %+v", result)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %v", err)
+	}
+	if count, _ := users.Count(map[string]interface{}{"status": "active"}); count != 2 {
+		t.Errorf("Expected 2 documents updated, got %d", count)
+	}
+}
+
+func TestFindOneAndUpdateConcurrentIncrements(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	counters := db.Collection("counters")
+	counters.InsertOne(map[string]interface{}{"_id": "hits", "n": int64(0)})
+
+	const workers, increments = 8, 50
+	seen := make(map[float64]bool)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				doc, err := counters.FindOneAndUpdate(map[string]interface{}{"_id": "hits"},
+					map[string]interface{}{"$inc": map[string]interface{}{"n": int64(1)}},
+					&FindOneAndUpdateOptions{ReturnNew: true})
+				if err != nil {
+					t.Errorf("FindOneAndUpdate failed: %v", err)
+					return
+				}
+				n, _ := doc.Get("n")
+				mu.Lock()
+				seen[counterValue(n)] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every increment saw a value no other one saw
+	if len(seen) != workers*increments {
+		t.Errorf("Expected %d distinct counter values, got %d", workers*increments, len(seen))
+	}
+	doc, _ := counters.FindOne(map[string]interface{}{"_id": "hits"})
+	if n, _ := doc.Get("n"); counterValue(n) != workers*increments {
+		t.Errorf("Expected the counter at %d, got %v", workers*increments, n)
+	}
+}
+
+func TestFindOneAndUpdateConflict(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	counters := db.Collection("counters")
+	counters.InsertOne(map[string]interface{}{"_id": "hits", "n": int64(0)})
+
+	// Another write changes the document while the hooks of the first run
+	var interfered atomic.Bool
+	counters.RegisterHook(HookBeforeUpdate, func(ctx *HookContext) error {
+		if interfered.CompareAndSwap(false, true) {
+			return counters.UpdateOne(map[string]interface{}{"_id": "hits"},
+				map[string]interface{}{"$set": map[string]interface{}{"n": int64(100)}})
+		}
+		return nil
+	})
+
+	_, err = counters.FindOneAndUpdate(map[string]interface{}{"_id": "hits"},
+		map[string]interface{}{"$inc": map[string]interface{}{"n": int64(1)}}, nil)
+	if !errors.Is(err, mvcc.ErrConflict) {
+		t.Fatalf("Expected mvcc.ErrConflict, got %v", err)
+	}
+	doc, _ := counters.FindOne(map[string]interface{}{"_id": "hits"})
+	if n, _ := doc.Get("n"); counterValue(n) != 100 {
+		t.Errorf("Expected the stale update not applied, got n=%v", n)
+	}
+
+	// A retry applies to the current document
+	doc, err = counters.FindOneAndUpdate(map[string]interface{}{"_id": "hits"},
+		map[string]interface{}{"$inc": map[string]interface{}{"n": int64(1)}}, &FindOneAndUpdateOptions{ReturnNew: true})
+	if err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+	if n, _ := doc.Get("n"); counterValue(n) != 101 {
+		t.Errorf("Expected n=101 after the retry, got %v", n)
+	}
+}
+
+// counterValue returns a numeric field value as a float64
+func counterValue(v interface{}) float64 {
+	f, _ := toFloat64(v)
+	return f
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/mnohosten/laura-db/pkg/document"
 	"github.com/mnohosten/laura-db/pkg/geo"
 )
 
@@ -720,3 +721,86 @@ func TestGeoIntersects_SkipLimit(t *testing.T) {
 		t.Errorf("Expected 0 results with large skip, got %d", len(results3))
 	}
 }
+
+func TestNearOperatorInFind(t *testing.T) {
+	dir := "./test_geo_near_operator"
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("stores")
+	stores := []struct {
+		name string
+		loc  []interface{}
+	}{
+		{"mission", []interface{}{-122.4194, 37.7599}},
+		{"downtown", []interface{}{-122.4075, 37.7880}},
+		{"oakland", []interface{}{-122.2711, 37.8044}},
+		{"san jose", []interface{}{-121.8863, 37.3382}},
+	}
+	for _, s := range stores {
+		coll.InsertOne(map[string]interface{}{"name": s.name, "loc": s.loc, "open": s.name != "downtown"})
+	}
+
+	filter := map[string]interface{}{
+		"loc": map[string]interface{}{
+			"$near":        []interface{}{-122.4089, 37.7837},
+			"$maxDistance": 0.2,
+		},
+	}
+
+	check := func(label string, filter map[string]interface{}, options *QueryOptions, expected []string) {
+		t.Helper()
+		var results []*document.Document
+		var err error
+		if options != nil {
+			results, err = coll.FindWithOptions(filter, options)
+		} else {
+			results, err = coll.Find(filter)
+		}
+		if err != nil {
+			t.Fatalf("%s: Find failed: %v", label, err)
+		}
+		if len(results) != len(expected) {
+			t.Fatalf("%s: Expected %d results, got %d", label, len(expected), len(results))
+		}
+		for i, doc := range results {
+			if name, _ := doc.Get("name"); name != expected[i] {
+				t.Errorf("%s: Expected %s at position %d, got %v", label, expected[i], i, name)
+			}
+		}
+	}
+
+	// Without an index the matcher filters and sorts by distance
+	check("scan", filter, nil, []string{"downtown", "mission", "oakland"})
+
+	if err := coll.Create2DIndex("loc"); err != nil {
+		t.Fatalf("Failed to create 2d index: %v", err)
+	}
+
+	check("index", filter, nil, []string{"downtown", "mission", "oakland"})
+	check("index limit", filter, &QueryOptions{Limit: 2}, []string{"downtown", "mission"})
+	check("index skip", filter, &QueryOptions{Skip: 1, Limit: 1}, []string{"mission"})
+
+	filter["open"] = true
+	check("index with filter", filter, &QueryOptions{Limit: 1}, []string{"mission"})
+
+	within := map[string]interface{}{
+		"loc": map[string]interface{}{
+			"$geoWithin": map[string]interface{}{
+				"$box": []interface{}{[]interface{}{-122.5, 37.7}, []interface{}{-122.3, 37.8}},
+			},
+		},
+	}
+	count, err := coll.Count(within)
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 stores within box, got %d", count)
+	}
+}
@@ -0,0 +1,513 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/mvcc"
+)
+
+// HookType identifies the write and the moment a hook runs for
+type HookType int
+
+const (
+	HookBeforeInsert HookType = iota
+	HookAfterInsert
+	HookBeforeUpdate
+	HookAfterUpdate
+	HookBeforeDelete
+	HookAfterDelete
+)
+
+// String returns the name of the hook type
+func (t HookType) String() string {
+	switch t {
+	case HookBeforeInsert:
+		return "beforeInsert"
+	case HookAfterInsert:
+		return "afterInsert"
+	case HookBeforeUpdate:
+		return "beforeUpdate"
+	case HookAfterUpdate:
+		return "afterUpdate"
+	case HookBeforeDelete:
+		return "beforeDelete"
+	case HookAfterDelete:
+		return "afterDelete"
+	default:
+		return fmt.Sprintf("HookType(%d)", int(t))
+	}
+}
+
+// HookContext describes the write a hook runs for
+type HookContext struct {
+	Type       HookType
+	Collection string
+	DocumentID interface{}
+
+	// Document is the document being inserted, the document as the update
+	// leaves it, or the document being deleted. Before insert and update
+	// hooks may modify it (except _id) to change what is written.
+	Document *document.Document
+
+	// Previous is the document before an update (nil for inserts and deletes)
+	Previous *document.Document
+
+	// Update holds the update operators of an update (nil otherwise)
+	Update map[string]interface{}
+
+	// Session is the transaction the write belongs to. Writes a hook makes
+	// through it commit or roll back together with the triggering write.
+	// Nil for collections outside a database.
+	Session *Session
+}
+
+// Hook runs application logic on a write, such as validation, derived
+// fields or denormalization. A before hook that returns an error rejects the
+// write. An after hook that returns an error fails the write: a collection
+// write is reverted, and in a session the transaction must be aborted.
+type Hook func(ctx *HookContext) error
+
+// hookRegistry holds the hooks registered for each hook type
+type hookRegistry struct {
+	mu    sync.RWMutex
+	hooks map[HookType][]Hook
+}
+
+// register adds a hook after the hooks already registered for its type
+func (r *hookRegistry) register(hookType HookType, hook Hook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.hooks == nil {
+		r.hooks = make(map[HookType][]Hook)
+	}
+	r.hooks[hookType] = append(r.hooks[hookType], hook)
+}
+
+// get returns the hooks of a type in registration order
+func (r *hookRegistry) get(hookType HookType) []Hook {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.hooks[hookType]
+}
+
+// empty reports whether no hooks are registered
+func (r *hookRegistry) empty() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.hooks) == 0
+}
+
+// RegisterHook registers a hook for writes to every collection of the
+// database. For each write, database hooks run before collection hooks, and
+// hooks of the same registry run in the order they were registered; the
+// first hook to fail stops the rest. Hooks are not persisted and must be
+// registered again after the database is reopened.
+func (db *Database) RegisterHook(hookType HookType, hook Hook) {
+	db.hooks.register(hookType, hook)
+}
+
+// RegisterHook registers a hook for writes to the collection. It runs after
+// the database's hooks of the same type, in registration order.
+//
+// Writes made in a session run their hooks when they join the transaction.
+// Other writes to a collection with hooks run document by document: before
+// hooks, the write, then after hooks, with hooks writing through a session
+// that commits only once the write and every after hook succeeded.
+// InsertMany runs the hooks of the whole batch and stays atomic.
+func (c *Collection) RegisterHook(hookType HookType, hook Hook) {
+	c.hooks.register(hookType, hook)
+}
+
+// hasHooks reports whether writes to the collection run any hooks
+func (c *Collection) hasHooks() bool {
+	return !c.hooks.empty() || (c.db != nil && !c.db.hooks.empty())
+}
+
+// runHooks runs the database and collection hooks of a write in order,
+// stopping at the first error
+func (c *Collection) runHooks(ctx *HookContext) error {
+	var hooks []Hook
+	if c.db != nil {
+		hooks = append(hooks, c.db.hooks.get(ctx.Type)...)
+	}
+	hooks = append(hooks, c.hooks.get(ctx.Type)...)
+
+	for _, hook := range hooks {
+		if err := hook(ctx); err != nil {
+			return fmt.Errorf("%s hook failed: %w", ctx.Type, err)
+		}
+	}
+	return nil
+}
+
+// beginHookSession starts the transaction the hooks of a collection write
+// make their own writes in
+func (c *Collection) beginHookSession() *Session {
+	if c.db == nil {
+		return nil
+	}
+	return c.db.StartSession()
+}
+
+// abortHookSession discards the writes of the hooks of a failed write
+func abortHookSession(session *Session, err error) error {
+	if session != nil {
+		session.AbortTransaction()
+	}
+	return err
+}
+
+// finishHooks runs the after hooks of an applied write and commits the
+// writes of its hooks. If a hook or the commit fails, undo reverts the write.
+func (c *Collection) finishHooks(session *Session, undo func() error, ctxs ...*HookContext) error {
+	var err error
+	for _, ctx := range ctxs {
+		if err = c.runHooks(ctx); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		if session == nil {
+			return nil
+		}
+		if err = session.CommitTransaction(); err == nil {
+			return nil
+		}
+	} else {
+		abortHookSession(session, err)
+	}
+
+	if undoErr := undo(); undoErr != nil {
+		return fmt.Errorf("%w (failed to revert the write: %v)", err, undoErr)
+	}
+	return err
+}
+
+// insertOneWithHooks inserts a document, running its insert hooks
+func (c *Collection) insertOneWithHooks(d *document.Document) (string, error) {
+	assignID(d)
+	idVal, _ := d.Get("_id")
+
+	session := c.beginHookSession()
+	ctx := &HookContext{Type: HookBeforeInsert, Collection: c.name, DocumentID: idVal, Document: d, Session: session}
+	if err := c.runHooks(ctx); err != nil {
+		return "", abortHookSession(session, err)
+	}
+
+	id, err := c.insertOne(d)
+	if err != nil {
+		return "", abortHookSession(session, err)
+	}
+
+	ctx.Type = HookAfterInsert
+	err = c.finishHooks(session, func() error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.removeDocument(id, d)
+	}, ctx)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// insertManyWithHooks inserts a batch of documents, running the insert hooks
+// of each. The batch and the writes of its hooks are applied all or nothing.
+func (c *Collection) insertManyWithHooks(batch []*document.Document) ([]string, error) {
+	session := c.beginHookSession()
+	ctxs := make([]*HookContext, len(batch))
+	for i, d := range batch {
+		assignID(d)
+		idVal, _ := d.Get("_id")
+		ctxs[i] = &HookContext{Type: HookBeforeInsert, Collection: c.name, DocumentID: idVal, Document: d, Session: session}
+		if err := c.runHooks(ctxs[i]); err != nil {
+			return nil, abortHookSession(session, fmt.Errorf("document %d: %w", i, err))
+		}
+	}
+
+	ids, err := c.insertMany(batch)
+	if err != nil {
+		return nil, abortHookSession(session, err)
+	}
+
+	for _, ctx := range ctxs {
+		ctx.Type = HookAfterInsert
+	}
+	err = c.finishHooks(session, func() error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, d := range batch {
+			if err := c.removeDocument(ids[i], d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, ctxs...)
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// updateOneWithHooks updates a document matching the filter, running its
+// update hooks
+func (c *Collection) updateOneWithHooks(filter map[string]interface{}, update map[string]interface{}) error {
+	start := time.Now()
+	if err := c.thaw(filter); err != nil {
+		return err
+	}
+	c.mu.RLock()
+	doc, err := c.findOneInternal(filter)
+	c.mu.RUnlock()
+	if err == nil {
+		err = c.updateDocumentWithHooks(doc, update)
+	}
+
+	if c.auditLogger != nil {
+		count := 0
+		if err == nil {
+			count = 1
+		}
+		c.auditLogger.LogUpdate(c.name, c.database, "", err == nil, count, time.Since(start), filter, update, err)
+	}
+	return err
+}
+
+// updateManyWithHooks updates the documents matching the filter one by one,
+// running the update hooks of each. Unlike a batch, it stops at the first
+// document that fails, leaving those before it updated.
+func (c *Collection) updateManyWithHooks(filter map[string]interface{}, update map[string]interface{}) (*UpdateResult, error) {
+	if err := c.thaw(filter); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	docs, err := c.findInternal(filter)
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UpdateResult{MatchedCount: len(docs)}
+	for _, doc := range docs {
+		updated, err := c.updateFoundWithHooks(doc, update)
+		if err != nil {
+			return result, err
+		}
+		if !reflect.DeepEqual(updated.ToMap(), doc.ToMap()) {
+			result.ModifiedCount++
+		}
+	}
+	return result, nil
+}
+
+// updateDocumentWithHooks applies an update to a document read from the
+// collection, running its update hooks. The hooks run without the
+// collection lock, so they may read and write the collection; if the
+// document changes meanwhile, the update fails with mvcc.ErrConflict.
+func (c *Collection) updateDocumentWithHooks(doc *document.Document, update map[string]interface{}) error {
+	_, err := c.updateFoundWithHooks(doc, update)
+	return err
+}
+
+// updateFoundWithHooks is updateDocumentWithHooks returning the document as
+// updated, including changes the before hooks made to it
+func (c *Collection) updateFoundWithHooks(doc *document.Document, update map[string]interface{}) (*document.Document, error) {
+	if err := checkUpdatePaths(doc, update); err != nil {
+		return nil, err
+	}
+	updated := doc.Clone()
+	if err := c.applyUpdate(updated, update); err != nil {
+		return nil, err
+	}
+
+	idVal, _ := doc.Get("_id")
+	id := fmt.Sprintf("%v", idVal)
+
+	session := c.beginHookSession()
+	ctx := &HookContext{
+		Type:       HookBeforeUpdate,
+		Collection: c.name,
+		DocumentID: idVal,
+		Document:   updated,
+		Previous:   doc.Clone(),
+		Update:     update,
+		Session:    session,
+	}
+	if err := c.runHooks(ctx); err != nil {
+		return nil, abortHookSession(session, err)
+	}
+
+	c.mu.Lock()
+	err := c.checkUnchanged(id, doc)
+	if err == nil {
+		err = c.checkDocument(updated)
+	}
+	if err == nil {
+		err = c.checkQuota(c.quotaChange(id, updated))
+	}
+	if err == nil {
+		err = c.replaceDocument(id, doc, updated)
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return nil, abortHookSession(session, err)
+	}
+
+	ctx.Type = HookAfterUpdate
+	err = c.finishHooks(session, func() error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.replaceDocument(id, updated, doc)
+	}, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// deleteOneWithHooks deletes a document matching the filter, running its
+// delete hooks
+func (c *Collection) deleteOneWithHooks(filter map[string]interface{}) error {
+	start := time.Now()
+	if err := c.thaw(filter); err != nil {
+		return err
+	}
+	c.mu.RLock()
+	doc, err := c.findOneInternal(filter)
+	c.mu.RUnlock()
+	if err == nil {
+		err = c.deleteDocumentWithHooks(doc)
+	}
+
+	if c.auditLogger != nil {
+		count := 0
+		if err == nil {
+			count = 1
+		}
+		c.auditLogger.LogDelete(c.name, c.database, "", err == nil, count, time.Since(start), filter, err)
+	}
+	return err
+}
+
+// deleteManyWithHooks deletes the documents matching the filter one by one,
+// running the delete hooks of each. Unlike a batch, it stops at the first
+// document that fails, leaving those before it deleted.
+func (c *Collection) deleteManyWithHooks(filter map[string]interface{}) (*DeleteResult, error) {
+	if err := c.thaw(filter); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	docs, err := c.findInternal(filter)
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DeleteResult{MatchedCount: len(docs)}
+	for _, doc := range docs {
+		if err := c.deleteDocumentWithHooks(doc); err != nil {
+			return result, err
+		}
+		result.DeletedCount++
+	}
+	return result, nil
+}
+
+// deleteDocumentWithHooks deletes a document read from the collection,
+// running its delete hooks. Like updates, the delete fails with
+// mvcc.ErrConflict if the document changes while before hooks run.
+func (c *Collection) deleteDocumentWithHooks(doc *document.Document) error {
+	idVal, _ := doc.Get("_id")
+	id := fmt.Sprintf("%v", idVal)
+
+	session := c.beginHookSession()
+	ctx := &HookContext{Type: HookBeforeDelete, Collection: c.name, DocumentID: idVal, Document: doc.Clone(), Session: session}
+	if err := c.runHooks(ctx); err != nil {
+		return abortHookSession(session, err)
+	}
+
+	c.mu.Lock()
+	err := c.checkUnchanged(id, doc)
+	if err == nil {
+		err = c.removeDocument(id, doc)
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return abortHookSession(session, err)
+	}
+
+	ctx.Type = HookAfterDelete
+	return c.finishHooks(session, func() error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.restoreDocument(id, doc)
+	}, ctx)
+}
+
+// checkUnchanged returns mvcc.ErrConflict if the stored document is no
+// longer doc, because another write changed or deleted it
+// Must be called with c.mu held
+func (c *Collection) checkUnchanged(id string, doc *document.Document) error {
+	current, err := c.docStore.Get(id)
+	if err != nil || !reflect.DeepEqual(current.ToMap(), doc.ToMap()) {
+		return fmt.Errorf("document %s changed while hooks ran: %w", id, mvcc.ErrConflict)
+	}
+	return nil
+}
+
+// replaceDocument stores updated in place of old, moving its index entries
+// Must be called with c.mu held
+func (c *Collection) replaceDocument(id string, old, updated *document.Document) error {
+	c.unindexDocument(id, old)
+	if err := c.indexDocument(id, updated); err != nil {
+		c.indexDocument(id, old)
+		return err
+	}
+	if err := c.docStore.Update(id, updated); err != nil {
+		c.unindexDocument(id, updated)
+		c.indexDocument(id, old)
+		return fmt.Errorf("failed to update document on disk: %w", err)
+	}
+
+	idVal, _ := updated.Get("_id")
+	c.logUpdate(idVal, replacementUpdate(old, updated))
+	c.queryCache.Clear()
+	return nil
+}
+
+// removeDocument deletes a stored document and its index entries
+// Must be called with c.mu held
+func (c *Collection) removeDocument(id string, d *document.Document) error {
+	if err := c.docStore.Delete(id); err != nil {
+		return fmt.Errorf("failed to delete document from disk: %w", err)
+	}
+	c.unindexDocument(id, d)
+
+	idVal, _ := d.Get("_id")
+	c.logDelete(idVal)
+	c.queryCache.Clear()
+	return nil
+}
+
+// restoreDocument stores a deleted document again
+// Must be called with c.mu held
+func (c *Collection) restoreDocument(id string, d *document.Document) error {
+	if err := c.indexDocument(id, d); err != nil {
+		return err
+	}
+	if err := c.docStore.Insert(id, d); err != nil {
+		c.unindexDocument(id, d)
+		return fmt.Errorf("failed to store document: %w", err)
+	}
+
+	c.logInsert(d)
+	c.queryCache.Clear()
+	return nil
+}
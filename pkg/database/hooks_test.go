@@ -0,0 +1,293 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+var errRejected = errors.New("rejected")
+
+func openHooksTestDB(t *testing.T, dir string) *Database {
+	t.Helper()
+	os.RemoveAll(dir)
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		os.RemoveAll(dir)
+	})
+	return db
+}
+
+func TestHookOrder(t *testing.T) {
+	db := openHooksTestDB(t, "./test_db_hooks_order")
+	coll := db.Collection("users")
+
+	var order []string
+	record := func(name string) Hook {
+		return func(ctx *HookContext) error {
+			order = append(order, name+":"+ctx.Type.String())
+			return nil
+		}
+	}
+	coll.RegisterHook(HookBeforeInsert, record("coll1"))
+	coll.RegisterHook(HookBeforeInsert, record("coll2"))
+	coll.RegisterHook(HookAfterInsert, record("coll1"))
+	db.RegisterHook(HookBeforeInsert, record("db"))
+
+	if _, err := coll.InsertOne(map[string]interface{}{"name": "Alice"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	expected := "db:beforeInsert,coll1:beforeInsert,coll2:beforeInsert,coll1:afterInsert"
+	if got := strings.Join(order, ","); got != expected {
+		t.Errorf("Expected hook order %s, got %s", expected, got)
+	}
+}
+
+func TestHookBeforeInsert(t *testing.T) {
+	db := openHooksTestDB(t, "./test_db_hooks_before_insert")
+	coll := db.Collection("users")
+
+	coll.RegisterHook(HookBeforeInsert, func(ctx *HookContext) error {
+		name, _ := ctx.Document.Get("name")
+		if name == "" {
+			return errRejected
+		}
+		ctx.Document.Set("nameLower", strings.ToLower(name.(string)))
+		return nil
+	})
+
+	if _, err := coll.InsertOne(map[string]interface{}{"name": "Alice"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	doc, err := coll.FindOne(map[string]interface{}{"name": "Alice"})
+	if err != nil {
+		t.Fatalf("Failed to find document: %v", err)
+	}
+	if lower, _ := doc.Get("nameLower"); lower != "alice" {
+		t.Errorf("Expected derived field alice, got %v", lower)
+	}
+
+	_, err = coll.InsertOne(map[string]interface{}{"name": ""})
+	if !errors.Is(err, errRejected) {
+		t.Errorf("Expected rejected insert, got %v", err)
+	}
+	if n, _ := coll.Count(nil); n != 1 {
+		t.Errorf("Expected 1 document, got %d", n)
+	}
+}
+
+func TestHookAfterInsertIsAtomic(t *testing.T) {
+	db := openHooksTestDB(t, "./test_db_hooks_after_insert")
+	users := db.Collection("users")
+	counters := db.Collection("counters")
+
+	users.RegisterHook(HookAfterInsert, func(ctx *HookContext) error {
+		name, _ := ctx.Document.Get("name")
+		if _, err := ctx.Session.InsertOne("counters", map[string]interface{}{"user": name}); err != nil {
+			return err
+		}
+		if name == "Mallory" {
+			return errRejected
+		}
+		return nil
+	})
+
+	if _, err := users.InsertOne(map[string]interface{}{"name": "Alice"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if _, err := users.InsertOne(map[string]interface{}{"name": "Mallory"}); !errors.Is(err, errRejected) {
+		t.Fatalf("Expected rejected insert, got %v", err)
+	}
+
+	// The failed insert and the hook's write are both gone
+	if n, _ := users.Count(nil); n != 1 {
+		t.Errorf("Expected 1 user, got %d", n)
+	}
+	if n, _ := counters.Count(nil); n != 1 {
+		t.Errorf("Expected 1 counter, got %d", n)
+	}
+	if _, err := counters.FindOne(map[string]interface{}{"user": "Alice"}); err != nil {
+		t.Errorf("Expected counter for Alice: %v", err)
+	}
+}
+
+func TestHookInsertManyIsAtomic(t *testing.T) {
+	db := openHooksTestDB(t, "./test_db_hooks_insert_many")
+	coll := db.Collection("users")
+
+	coll.RegisterHook(HookBeforeInsert, func(ctx *HookContext) error {
+		if name, _ := ctx.Document.Get("name"); name == "Mallory" {
+			return errRejected
+		}
+		return nil
+	})
+
+	_, err := coll.InsertMany([]map[string]interface{}{
+		{"name": "Alice"},
+		{"name": "Mallory"},
+		{"name": "Bob"},
+	})
+	if !errors.Is(err, errRejected) {
+		t.Fatalf("Expected rejected batch, got %v", err)
+	}
+	if n, _ := coll.Count(nil); n != 0 {
+		t.Errorf("Expected 0 documents, got %d", n)
+	}
+
+	ids, err := coll.InsertMany([]map[string]interface{}{{"name": "Alice"}, {"name": "Bob"}})
+	if err != nil {
+		t.Fatalf("Failed to insert batch: %v", err)
+	}
+	if len(ids) != 2 {
+		t.Errorf("Expected 2 ids, got %d", len(ids))
+	}
+}
+
+func TestHookUpdate(t *testing.T) {
+	db := openHooksTestDB(t, "./test_db_hooks_update")
+	coll := db.Collection("accounts")
+	if _, err := coll.InsertOne(map[string]interface{}{"owner": "Alice", "balance": int64(100)}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := coll.CreateIndex("balance", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	coll.RegisterHook(HookBeforeUpdate, func(ctx *HookContext) error {
+		balance, _ := ctx.Document.Get("balance")
+		if balance.(int64) < 0 {
+			return errRejected
+		}
+		previous, _ := ctx.Previous.Get("balance")
+		ctx.Document.Set("lastChange", balance.(int64)-previous.(int64))
+		return nil
+	})
+	coll.RegisterHook(HookAfterUpdate, func(ctx *HookContext) error {
+		if balance, _ := ctx.Document.Get("balance"); balance == int64(999) {
+			return errRejected
+		}
+		return nil
+	})
+
+	filter := map[string]interface{}{"owner": "Alice"}
+	if err := coll.UpdateOne(filter, map[string]interface{}{"$set": map[string]interface{}{"balance": int64(150)}}); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	doc, _ := coll.FindOne(filter)
+	if change, _ := doc.Get("lastChange"); change != int64(50) {
+		t.Errorf("Expected lastChange 50, got %v", change)
+	}
+
+	// Rejected before the write
+	err := coll.UpdateOne(filter, map[string]interface{}{"$set": map[string]interface{}{"balance": int64(-1)}})
+	if !errors.Is(err, errRejected) {
+		t.Errorf("Expected rejected update, got %v", err)
+	}
+
+	// Reverted after the write
+	err = coll.UpdateOne(filter, map[string]interface{}{"$set": map[string]interface{}{"balance": int64(999)}})
+	if !errors.Is(err, errRejected) {
+		t.Errorf("Expected rejected update, got %v", err)
+	}
+
+	doc, _ = coll.FindOne(filter)
+	if balance, _ := doc.Get("balance"); balance != int64(150) {
+		t.Errorf("Expected balance 150, got %v", balance)
+	}
+	// Index entries follow the reverted document
+	if _, err := coll.FindOne(map[string]interface{}{"balance": int64(150)}); err != nil {
+		t.Errorf("Expected to find balance 150 through the index: %v", err)
+	}
+	if _, err := coll.FindOne(map[string]interface{}{"balance": int64(999)}); err == nil {
+		t.Error("Expected no document with balance 999")
+	}
+}
+
+func TestHookDelete(t *testing.T) {
+	db := openHooksTestDB(t, "./test_db_hooks_delete")
+	coll := db.Collection("users")
+	for _, name := range []string{"Alice", "Bob", "Admin"} {
+		if _, err := coll.InsertOne(map[string]interface{}{"name": name}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	coll.RegisterHook(HookBeforeDelete, func(ctx *HookContext) error {
+		if name, _ := ctx.Document.Get("name"); name == "Admin" {
+			return errRejected
+		}
+		return nil
+	})
+	coll.RegisterHook(HookAfterDelete, func(ctx *HookContext) error {
+		if name, _ := ctx.Document.Get("name"); name == "Bob" {
+			return errRejected
+		}
+		return nil
+	})
+
+	if err := coll.DeleteOne(map[string]interface{}{"name": "Alice"}); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if err := coll.DeleteOne(map[string]interface{}{"name": "Admin"}); !errors.Is(err, errRejected) {
+		t.Errorf("Expected rejected delete, got %v", err)
+	}
+	if err := coll.DeleteOne(map[string]interface{}{"name": "Bob"}); !errors.Is(err, errRejected) {
+		t.Errorf("Expected rejected delete, got %v", err)
+	}
+
+	if n, _ := coll.Count(nil); n != 2 {
+		t.Errorf("Expected 2 documents, got %d", n)
+	}
+	if _, err := coll.FindOne(map[string]interface{}{"name": "Bob"}); err != nil {
+		t.Errorf("Expected Bob to be restored: %v", err)
+	}
+}
+
+func TestHookInSession(t *testing.T) {
+	db := openHooksTestDB(t, "./test_db_hooks_session")
+	db.Collection("orders")
+	db.Collection("audit")
+
+	var fired []string
+	db.Collection("orders").RegisterHook(HookAfterInsert, func(ctx *HookContext) error {
+		fired = append(fired, ctx.Type.String())
+		item, _ := ctx.Document.Get("item")
+		_, err := ctx.Session.InsertOne("audit", map[string]interface{}{"item": item})
+		return err
+	})
+
+	// Aborting the transaction discards the write and the hook's write
+	session := db.StartSession()
+	if _, err := session.InsertOne("orders", map[string]interface{}{"item": "book"}); err != nil {
+		t.Fatalf("Failed to insert in session: %v", err)
+	}
+	session.AbortTransaction()
+	if n, _ := db.Collection("audit").Count(nil); n != 0 {
+		t.Errorf("Expected 0 audit entries after abort, got %d", n)
+	}
+
+	session = db.StartSession()
+	if _, err := session.InsertOne("orders", map[string]interface{}{"item": "pen"}); err != nil {
+		t.Fatalf("Failed to insert in session: %v", err)
+	}
+	if err := session.CommitTransaction(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	if n, _ := db.Collection("orders").Count(nil); n != 1 {
+		t.Errorf("Expected 1 order, got %d", n)
+	}
+	if n, _ := db.Collection("audit").Count(nil); n != 1 {
+		t.Errorf("Expected 1 audit entry, got %d", n)
+	}
+	// Hooks run once, when the write joins the transaction, not again on commit
+	if len(fired) != 2 {
+		t.Errorf("Expected hook to fire twice, fired %d times", len(fired))
+	}
+}
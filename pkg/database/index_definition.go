@@ -0,0 +1,93 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mnohosten/laura-db/pkg/backup"
+)
+
+// Index definitions describe indexes as plain maps, so they can travel in
+// oplog entries and be recreated on another database. A definition has the
+// layout of backup.IndexBackup: name, type ("btree", "text", "geo" or "ttl"),
+// field_paths, unique and, depending on the type, filter, geo_type,
+// ttl_duration and config (text index weights).
+
+// IndexDefinitions returns the definitions of all indexes of the collection
+// except the default _id_ index
+func (c *Collection) IndexDefinitions() []map[string]interface{} {
+	c.mu.RLock()
+	backups := c.indexBackups()
+	c.mu.RUnlock()
+
+	defs := make([]map[string]interface{}, 0, len(backups))
+	for _, b := range backups {
+		if def, err := indexDefinition(b); err == nil {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+// IndexDefinition returns the definition of the named index
+func (c *Collection) IndexDefinition(name string) (map[string]interface{}, error) {
+	c.mu.RLock()
+	backups := c.indexBackups()
+	c.mu.RUnlock()
+
+	for _, b := range backups {
+		if b.Name == name {
+			return indexDefinition(b)
+		}
+	}
+	return nil, kindOf(ErrNotFound, "index %s does not exist", name)
+}
+
+// CreateIndexFromDefinition creates the index a definition describes. An
+// index of the same name that already exists is kept as it is, so applying
+// a definition twice is harmless.
+func (c *Collection) CreateIndexFromDefinition(def map[string]interface{}) error {
+	data, err := json.Marshal(def)
+	if err != nil {
+		return fmt.Errorf("failed to encode index definition: %w", err)
+	}
+	var b backup.IndexBackup
+	if err := json.Unmarshal(data, &b); err != nil {
+		return fmt.Errorf("failed to decode index definition: %w", err)
+	}
+	if b.Name != "" && c.hasIndex(b.Name) {
+		return nil
+	}
+	return c.db.createIndexFromBackup(c, b)
+}
+
+// hasIndex reports whether the collection has an index of the given name
+func (c *Collection) hasIndex(name string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, exists := c.indexes[name]; exists {
+		return true
+	}
+	if _, exists := c.textIndexes[name]; exists {
+		return true
+	}
+	if _, exists := c.geoIndexes[name]; exists {
+		return true
+	}
+	_, exists := c.ttlIndexes[name]
+	return exists
+}
+
+// indexDefinition converts an index backup to a definition map
+func indexDefinition(b backup.IndexBackup) (map[string]interface{}, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode index definition: %w", err)
+	}
+	var def map[string]interface{}
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil, fmt.Errorf("failed to decode index definition: %w", err)
+	}
+	return def, nil
+}
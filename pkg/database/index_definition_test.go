@@ -0,0 +1,59 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIndexDefinitionRoundTrip(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	src := db.Collection("places")
+	if err := src.CreatePartialIndex("rating", map[string]interface{}{"rating": map[string]interface{}{"$gte": 4}}, false); err != nil {
+		t.Fatalf("Failed to create partial index: %v", err)
+	}
+	if err := src.Create2DSphereIndex("location"); err != nil {
+		t.Fatalf("Failed to create 2dsphere index: %v", err)
+	}
+	if err := src.CreateTTLIndex("expiresAt", 60*time.Second); err != nil {
+		t.Fatalf("Failed to create TTL index: %v", err)
+	}
+
+	defs := src.IndexDefinitions()
+	if len(defs) != 3 {
+		t.Fatalf("Expected 3 index definitions, got %d", len(defs))
+	}
+
+	dst := db.Collection("places_copy")
+	for _, def := range defs {
+		if err := dst.CreateIndexFromDefinition(def); err != nil {
+			t.Fatalf("Failed to create index from %v: %v", def, err)
+		}
+		// Creating the same index again is harmless
+		if err := dst.CreateIndexFromDefinition(def); err != nil {
+			t.Errorf("Expected existing index %v to be kept, got %v", def["name"], err)
+		}
+	}
+
+	for _, name := range []string{"rating_partial", "location_2dsphere", "expiresAt_ttl"} {
+		if !dst.hasIndex(name) {
+			t.Errorf("Expected index %s on the copy", name)
+		}
+	}
+
+	def, err := dst.IndexDefinition("expiresAt_ttl")
+	if err != nil {
+		t.Fatalf("Failed to get index definition: %v", err)
+	}
+	if def["ttl_duration"] != float64(60) {
+		t.Errorf("Expected ttl_duration 60, got %v", def["ttl_duration"])
+	}
+
+	if _, err := dst.IndexDefinition("missing_1"); err == nil {
+		t.Error("Expected error for a missing index")
+	}
+}
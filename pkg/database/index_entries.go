@@ -0,0 +1,124 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/index"
+)
+
+// IndexEntry is a single key -> document id mapping stored in a B+ tree index
+type IndexEntry struct {
+	Key        interface{}
+	DocumentID string
+}
+
+// IndexEntries returns all entries of a B+ tree index (single-field,
+// compound or partial) in key order. Used by maintenance tooling to
+// cross-check index contents against stored documents.
+func (c *Collection) IndexEntries(indexName string) ([]IndexEntry, error) {
+	c.mu.RLock()
+	idx, exists := c.indexes[indexName]
+	c.mu.RUnlock()
+
+	if !exists {
+		return nil, kindOf(ErrNotFound, "index %s does not exist", indexName)
+	}
+
+	keys, values := idx.RangeScan(nil, nil)
+	entries := make([]IndexEntry, 0, len(keys))
+	for i, key := range keys {
+		docID, _ := values[i].(string)
+		entries = append(entries, IndexEntry{Key: key, DocumentID: docID})
+	}
+
+	return entries, nil
+}
+
+// IndexKeyFor returns the key the named index should hold for doc, and
+// whether the document belongs in the index at all. A document is excluded
+// when it fails a partial index filter or lacks any of the indexed fields.
+func (c *Collection) IndexKeyFor(indexName string, doc *document.Document) (interface{}, bool, error) {
+	c.mu.RLock()
+	idx, exists := c.indexes[indexName]
+	c.mu.RUnlock()
+
+	if !exists {
+		return nil, false, kindOf(ErrNotFound, "index %s does not exist", indexName)
+	}
+
+	key, included := c.indexKeyFor(idx, doc)
+	return key, included, nil
+}
+
+// indexKeyFor mirrors the key extraction rules used when inserting documents
+func (c *Collection) indexKeyFor(idx *index.Index, doc *document.Document) (interface{}, bool) {
+	if !c.matchesPartialIndexFilter(doc, idx) {
+		return nil, false
+	}
+
+	if idx.IsCompound() {
+		compositeKey, allFieldsExist := c.extractCompositeKey(doc, idx.FieldPaths())
+		if !allFieldsExist {
+			return nil, false
+		}
+		return compositeKey, true
+	}
+
+	fieldValue, exists := doc.Get(idx.FieldPath())
+	if !exists {
+		return nil, false
+	}
+	return fieldValue, true
+}
+
+// RebuildIndex discards the contents of a single B+ tree index and rebuilds
+// it from the stored documents, keeping its fields, uniqueness and filter.
+// Other indexes on the collection are left untouched.
+func (c *Collection) RebuildIndex(indexName string) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.rebuildIndexLocked(indexName)
+}
+
+// rebuildIndexLocked rebuilds a B+ tree index
+// Must be called with c.mu held
+func (c *Collection) rebuildIndexLocked(indexName string) error {
+	old, exists := c.indexes[indexName]
+	if !exists {
+		return kindOf(ErrNotFound, "index %s does not exist", indexName)
+	}
+
+	idx := index.NewIndex(&index.IndexConfig{
+		Name:       old.Name(),
+		FieldPaths: old.FieldPaths(),
+		Type:       index.IndexTypeBTree,
+		Unique:     old.IsUnique(),
+		Order:      32,
+		Filter:     old.Filter(),
+	})
+
+	for _, id := range c.docStore.GetAllIDs() {
+		doc, err := c.docStore.Get(id)
+		if err != nil {
+			return fmt.Errorf("failed to get document %s: %w", id, err)
+		}
+
+		key, included := c.indexKeyFor(idx, doc)
+		if !included {
+			continue
+		}
+		if err := idx.Insert(key, id); err != nil {
+			return fmt.Errorf("failed to rebuild index %s: %w", indexName, err)
+		}
+	}
+
+	c.indexes[indexName] = idx
+	c.queryCache.Clear()
+
+	return nil
+}
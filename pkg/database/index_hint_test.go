@@ -0,0 +1,185 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+func openHintTestCollection(t *testing.T, dir string) (*Database, *Collection) {
+	t.Helper()
+	os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	users := db.Collection("users")
+	cities := []string{"Prague", "Brno", "Ostrava"}
+	for i, city := range cities {
+		users.InsertOne(map[string]interface{}{
+			"name": "user",
+			"age":  int64(20 + i),
+			"city": city,
+		})
+	}
+	users.CreateIndex("age", false)
+	users.CreateIndex("city", false)
+	return db, users
+}
+
+func TestFindWithHint(t *testing.T) {
+	dir := "./test_db_hint_find"
+	db, users := openHintTestCollection(t, dir)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	filter := map[string]interface{}{"age": int64(20), "city": "Prague"}
+	expected, err := users.Find(filter)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+
+	if len(expected) != 1 {
+		t.Fatalf("Expected 1 document, got %d", len(expected))
+	}
+
+	for _, hint := range []string{"age_1", "city_1", query.NaturalHint} {
+		docs, err := users.FindWithOptions(filter, &QueryOptions{Hint: hint})
+		if err != nil {
+			t.Fatalf("FindWithOptions with hint %s failed: %v", hint, err)
+		}
+		if len(docs) != len(expected) {
+			t.Errorf("Expected %d documents with hint %s, got %d", len(expected), hint, len(docs))
+		}
+	}
+
+	if _, err := users.FindWithOptions(filter, &QueryOptions{Hint: "missing_1"}); err == nil {
+		t.Error("Expected error for unknown index")
+	}
+	if _, err := users.FindWithOptions(map[string]interface{}{"name": "user"}, &QueryOptions{Hint: "age_1"}); err == nil {
+		t.Error("Expected error for index that can't serve the query")
+	}
+}
+
+func TestFindCursorWithHint(t *testing.T) {
+	dir := "./test_db_hint_cursor"
+	db, users := openHintTestCollection(t, dir)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	filter := map[string]interface{}{"age": map[string]interface{}{"$gte": int64(21)}, "city": "Brno"}
+	for _, hint := range []string{"age_1", "city_1", query.NaturalHint} {
+		cursor, err := users.FindCursorWithOptions(filter, &QueryOptions{Hint: hint}, nil)
+		if err != nil {
+			t.Fatalf("FindCursorWithOptions with hint %s failed: %v", hint, err)
+		}
+		if cursor.Count() != 1 {
+			t.Errorf("Expected 1 document with hint %s, got %d", hint, cursor.Count())
+		}
+		cursor.Close()
+
+		// The explain output shows the hinted plan was used
+		result, err := users.ExplainExecution(filter, &QueryOptions{Hint: hint})
+		if err != nil {
+			t.Fatalf("ExplainExecution with hint %s failed: %v", hint, err)
+		}
+		if hint == query.NaturalHint {
+			if result.IndexScan || result.DocsExamined != 3 {
+				t.Errorf("Expected a collection scan of 3 documents, got %+v", result)
+			}
+		} else if result.IndexName != hint || result.Plan["hintHonored"] != true {
+			t.Errorf("Expected a scan of %s, got %+v", hint, result)
+		}
+	}
+
+	// Hints that can't be honored fail rather than falling back
+	_, err := users.FindCursorWithOptions(map[string]interface{}{"name": "user"}, &QueryOptions{Hint: "city_1"}, nil)
+	if !errors.Is(err, ErrInvalidHint) || !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected ErrInvalidHint, got %v", err)
+	}
+	_, err = users.FindWithOptions(filter, &QueryOptions{Hint: "missing_1"})
+	if !errors.Is(err, ErrInvalidHint) {
+		t.Errorf("Expected ErrInvalidHint, got %v", err)
+	}
+}
+
+func TestExplainWithHint(t *testing.T) {
+	dir := "./test_db_hint_explain"
+	db, users := openHintTestCollection(t, dir)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	filter := map[string]interface{}{"age": int64(20), "city": "Prague"}
+
+	explain := users.ExplainWithOptions(filter, &QueryOptions{Hint: "city_1"})
+	if explain["indexName"] != "city_1" || explain["hintHonored"] != true {
+		t.Errorf("Expected honored hint on city_1, got %v", explain)
+	}
+
+	explain = users.ExplainWithOptions(filter, &QueryOptions{Hint: query.NaturalHint})
+	if explain["useIndex"] != false || explain["hintHonored"] != true {
+		t.Errorf("Expected honored collection scan hint, got %v", explain)
+	}
+
+	explain = users.ExplainWithOptions(filter, &QueryOptions{Hint: "missing_1"})
+	if explain["hintHonored"] != false || explain["hintError"] == nil {
+		t.Errorf("Expected hint not to be honored, got %v", explain)
+	}
+
+	if _, exists := users.Explain(filter)["hint"]; exists {
+		t.Error("Expected no hint in Explain without options")
+	}
+}
+
+func TestAggregateWithHint(t *testing.T) {
+	dir := "./test_db_hint_aggregate"
+	db, users := openHintTestCollection(t, dir)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	pipeline := []map[string]interface{}{
+		{"$match": map[string]interface{}{"city": "Brno"}},
+		{"$group": map[string]interface{}{
+			"_id":   "$city",
+			"count": map[string]interface{}{"$sum": 1},
+		}},
+	}
+
+	results, err := users.AggregateWithOptions(pipeline, &AggregateOptions{Hint: "city_1"})
+	if err != nil {
+		t.Fatalf("AggregateWithOptions failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(results))
+	}
+	if id, _ := results[0].Get("_id"); id != "Brno" {
+		t.Errorf("Expected group Brno, got %v", id)
+	}
+
+	cursor, err := users.AggregateCursor(pipeline, &AggregateOptions{Hint: "city_1"})
+	if err != nil {
+		t.Fatalf("AggregateCursor failed: %v", err)
+	}
+	defer cursor.Close()
+	streamed := 0
+	for cursor.HasNext() {
+		if _, err := cursor.Next(); err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		streamed++
+	}
+	if streamed != 1 {
+		t.Errorf("Expected 1 streamed group, got %d", streamed)
+	}
+
+	if _, err := users.AggregateWithOptions(pipeline, &AggregateOptions{Hint: "age_1"}); err == nil {
+		t.Error("Expected error for index that can't serve the $match")
+	}
+	noMatch := []map[string]interface{}{{"$limit": 5}}
+	if _, err := users.AggregateWithOptions(noMatch, &AggregateOptions{Hint: "city_1"}); err == nil {
+		t.Error("Expected error for pipeline without a leading $match")
+	}
+}
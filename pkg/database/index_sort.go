@@ -0,0 +1,96 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/index"
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+// sortIndexFor returns the B+ tree index whose order satisfies the sort of
+// a query on the hot tier, or nil if the documents must be sorted in memory.
+// When the filter is answered by a single index scan, only that index can
+// be used. Otherwise any ready index will do, as long as it isn't partial
+// and holds an entry for every document, i.e. none lacks a sort field. The
+// sort must name each field of the index key in order, all in the same
+// direction; the B+ tree holds one document per key, so documents never tie
+// on the sort and the index order is the order sortDocuments would give.
+// Must be called with c.mu held
+func (c *Collection) sortIndexFor(q *query.Query, plan *query.QueryPlan) *index.Index {
+	sortFields := q.GetSort()
+	if len(sortFields) == 0 || plan.UseIntersection || plan.Hint == query.NaturalHint {
+		return nil
+	}
+
+	if plan.UseIndex {
+		scansInOrder := plan.ScanType == query.ScanTypeIndexExact || plan.ScanType == query.ScanTypeIndexRange
+		if scansInOrder && indexOrderSatisfies(plan, sortFields) && len(sortFields) == len(plan.Index.FieldPaths()) {
+			return plan.Index
+		}
+		return nil
+	}
+
+	for _, idx := range c.indexes {
+		if !idx.IsReady() || idx.IsPartial() || len(idx.FieldPaths()) != len(sortFields) {
+			continue
+		}
+		if indexOrderSatisfies(&query.QueryPlan{Index: idx}, sortFields) && idx.Size() == c.docStore.Count() {
+			return idx
+		}
+	}
+	return nil
+}
+
+// executeSortedByIndex runs a query on the hot tier reading documents in the
+// order of its sort index instead of sorting them in memory. Reading stops
+// once skip+limit documents have matched.
+// Must be called with c.mu held
+func (c *Collection) executeSortedByIndex(q *query.Query, plan *query.QueryPlan, idx *index.Index) ([]*document.Document, error) {
+	var ids []string
+	if plan.UseIndex {
+		ids, _ = plan.DocumentIDs()
+	} else {
+		_, values := idx.RangeScan(nil, nil)
+		ids = make([]string, 0, len(values))
+		for _, v := range values {
+			if id, ok := v.(string); ok {
+				ids = append(ids, id)
+			}
+		}
+		idx.Usage().Record()
+	}
+	if !q.GetSort()[0].Ascending {
+		for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+			ids[i], ids[j] = ids[j], ids[i]
+		}
+	}
+
+	skip, limit := q.GetSkip(), q.GetLimit()
+	results := make([]*document.Document, 0)
+	for _, id := range ids {
+		if limit > 0 && len(results) == limit {
+			break
+		}
+		if !c.docStore.Exists(id) {
+			continue
+		}
+		doc, err := c.docStore.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get document %s: %w", id, err)
+		}
+		matches, err := q.Matches(doc)
+		if err != nil {
+			return nil, err
+		}
+		if !matches {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		results = append(results, q.ApplyProjection(doc))
+	}
+	return results, nil
+}
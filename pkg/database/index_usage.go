@@ -0,0 +1,170 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/mnohosten/laura-db/pkg/index"
+)
+
+// indexUsageFile keeps index usage counters across restarts
+const indexUsageFile = "index_usage.json"
+
+// IndexUsageStats reports how often queries used an index
+type IndexUsageStats struct {
+	Name string
+	Type string // "btree", "text", "2d" or "2dsphere"
+	index.IndexUsage
+}
+
+// indexUsageStore persists index usage counters. Counters loaded at startup
+// are the baseline of each index; the counts of this run are added to them.
+type indexUsageStore struct {
+	path     string
+	mu       sync.Mutex
+	baseline map[string]map[string]index.IndexUsage // collection -> index -> usage
+}
+
+// loadIndexUsage reads the counters saved by a previous run. A missing or
+// unreadable file starts the counters from zero.
+func loadIndexUsage(path string) *indexUsageStore {
+	store := &indexUsageStore{
+		path:     path,
+		baseline: make(map[string]map[string]index.IndexUsage),
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &store.baseline)
+	}
+	return store
+}
+
+// usage combines the saved counters of an index with the counts of this run
+func (s *indexUsageStore) usage(collection, name string, current index.IndexUsage) index.IndexUsage {
+	if s == nil {
+		return current
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if saved, exists := s.baseline[collection][name]; exists {
+		return saved.Add(current)
+	}
+	return current
+}
+
+// forgetIndex drops the saved counters of a dropped index
+func (s *indexUsageStore) forgetIndex(collection, name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.baseline[collection], name)
+}
+
+// forgetCollection drops the saved counters of a dropped collection
+func (s *indexUsageStore) forgetCollection(collection string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.baseline, collection)
+}
+
+// renameCollection moves the saved counters of a renamed collection
+func (s *indexUsageStore) renameCollection(oldName, newName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if saved, exists := s.baseline[oldName]; exists {
+		s.baseline[newName] = saved
+		delete(s.baseline, oldName)
+	}
+}
+
+// save writes the counters of all collections. Saved counters of indexes
+// that don't exist in this run (for example because they are recreated
+// later) are kept.
+// Must be called with db.mu held
+func (s *indexUsageStore) save(collections map[string]*Collection) error {
+	s.mu.Lock()
+	all := make(map[string]map[string]index.IndexUsage, len(s.baseline))
+	for collName, indexes := range s.baseline {
+		all[collName] = make(map[string]index.IndexUsage, len(indexes))
+		for name, usage := range indexes {
+			all[collName][name] = usage
+		}
+	}
+	s.mu.Unlock()
+
+	for collName, coll := range collections {
+		for _, stats := range coll.IndexStats() {
+			if all[collName] == nil {
+				all[collName] = make(map[string]index.IndexUsage)
+			}
+			all[collName][stats.Name] = stats.IndexUsage
+		}
+	}
+
+	data, err := json.Marshal(all)
+	if err != nil {
+		return fmt.Errorf("failed to encode index usage: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write index usage: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write index usage: %w", err)
+	}
+	return nil
+}
+
+// IndexStats returns how often queries used each index of the collection,
+// ordered by index name. Counts include previous runs of the database.
+// TTL indexes are used by expiry rather than queries and are not included.
+func (c *Collection) IndexStats() []IndexUsageStats {
+	c.mu.RLock()
+	stats := make([]IndexUsageStats, 0, len(c.indexes)+len(c.textIndexes)+len(c.geoIndexes))
+	for name, idx := range c.indexes {
+		stats = append(stats, IndexUsageStats{Name: name, Type: "btree", IndexUsage: idx.Usage().Usage()})
+	}
+	for name, idx := range c.textIndexes {
+		stats = append(stats, IndexUsageStats{Name: name, Type: "text", IndexUsage: idx.Usage().Usage()})
+	}
+	for name, idx := range c.geoIndexes {
+		geoType := "2d"
+		if idx.Type() == index.IndexType2DSphere {
+			geoType = "2dsphere"
+		}
+		stats = append(stats, IndexUsageStats{Name: name, Type: geoType, IndexUsage: idx.Usage().Usage()})
+	}
+	c.mu.RUnlock()
+
+	if c.db != nil {
+		for i := range stats {
+			stats[i].IndexUsage = c.db.indexUsage.usage(c.name, stats[i].Name, stats[i].IndexUsage)
+		}
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// forgetIndexUsage drops the saved counters of an index being dropped
+func (c *Collection) forgetIndexUsage(name string) {
+	if c.db != nil {
+		c.db.indexUsage.forgetIndex(c.name, name)
+	}
+}
+
+// indexUsagePath returns where a database in dataDir keeps index usage
+func indexUsagePath(dataDir string) string {
+	return filepath.Join(dataDir, indexUsageFile)
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"os"
+	"testing"
+)
+
+func findIndexStats(t *testing.T, coll *Collection, name string) IndexUsageStats {
+	t.Helper()
+	for _, stats := range coll.IndexStats() {
+		if stats.Name == name {
+			return stats
+		}
+	}
+	t.Fatalf("Index %s not found in IndexStats", name)
+	return IndexUsageStats{}
+}
+
+func TestIndexStats(t *testing.T) {
+	dir := "./test_db_index_usage"
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	for i := 0; i < 10; i++ {
+		users.InsertOne(map[string]interface{}{"name": "user", "age": int64(20 + i)})
+	}
+	users.CreateIndex("age", false)
+	users.CreateIndex("name", false)
+
+	if stats := findIndexStats(t, users, "age_1"); stats.Ops != 0 || !stats.LastUsed.IsZero() {
+		t.Errorf("Expected unused index, got %+v", stats)
+	}
+	if stats := findIndexStats(t, users, "age_1"); stats.Type != "btree" || stats.Since.IsZero() {
+		t.Errorf("Expected btree index with a start time, got %+v", stats)
+	}
+
+	users.Find(map[string]interface{}{"age": int64(25)})
+	users.Find(map[string]interface{}{"age": map[string]interface{}{"$gte": int64(27)}})
+
+	// Explaining a query doesn't use the index
+	users.Explain(map[string]interface{}{"age": int64(25)})
+
+	stats := findIndexStats(t, users, "age_1")
+	if stats.Ops != 2 {
+		t.Errorf("Expected 2 uses of age_1, got %d", stats.Ops)
+	}
+	if stats.LastUsed.IsZero() {
+		t.Error("Expected last used time to be set")
+	}
+	if stats := findIndexStats(t, users, "name_1"); stats.Ops != 0 {
+		t.Errorf("Expected name_1 to be unused, got %d", stats.Ops)
+	}
+}
+
+func TestIndexStatsPersist(t *testing.T) {
+	dir := "./test_db_index_usage_persist"
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	users := db.Collection("users")
+	users.InsertOne(map[string]interface{}{"age": int64(30)})
+	users.CreateIndex("age", false)
+	users.CreateIndex("name", false)
+	for i := 0; i < 3; i++ {
+		users.Find(map[string]interface{}{"age": int64(30)})
+	}
+	users.Find(map[string]interface{}{"name": "x"})
+	since := findIndexStats(t, users, "age_1").Since
+	if err := db.Close(); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+
+	db, err = Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+
+	users = db.Collection("users")
+	users.CreateIndex("age", false)
+	users.Find(map[string]interface{}{"age": int64(30)})
+
+	stats := findIndexStats(t, users, "age_1")
+	if stats.Ops != 4 {
+		t.Errorf("Expected 4 uses across restarts, got %d", stats.Ops)
+	}
+	if !stats.Since.Equal(since) {
+		t.Errorf("Expected counting to start at %v, got %v", since, stats.Since)
+	}
+
+	// A dropped index starts over when it is created again
+	users.CreateIndex("name", false)
+	if stats := findIndexStats(t, users, "name_1"); stats.Ops != 1 {
+		t.Errorf("Expected 1 saved use of name_1, got %d", stats.Ops)
+	}
+	if err := users.DropIndex("name_1"); err != nil {
+		t.Fatalf("Failed to drop index: %v", err)
+	}
+	users.CreateIndex("name", false)
+	if stats := findIndexStats(t, users, "name_1"); stats.Ops != 0 {
+		t.Errorf("Expected recreated name_1 to be unused, got %d", stats.Ops)
+	}
+}
@@ -0,0 +1,305 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/geo"
+	"github.com/mnohosten/laura-db/pkg/index"
+)
+
+// InsertMany inserts multiple documents as one batch. The collection is
+// locked once, the documents are indexed together and the document store
+// writes each page it fills once, instead of once per document. The batch
+// is atomic: if any document is rejected (duplicate _id, unique index
+// conflict, size limit), none are inserted.
+func (c *Collection) InsertMany(docs []map[string]interface{}) ([]string, error) {
+	if err := c.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	batch := make([]*document.Document, len(docs))
+	for i, doc := range docs {
+		batch[i] = document.NewDocumentFromMap(doc)
+	}
+	if c.hasHooks() {
+		return c.insertManyWithHooks(batch)
+	}
+	return c.insertMany(batch)
+}
+
+// InsertManyOptions controls how InsertManyWithOptions handles a document
+// that can't be inserted, and how durable the inserts must be
+type InsertManyOptions struct {
+	// Atomic inserts the batch as a unit, as InsertMany does: if any
+	// document is rejected, none are inserted. Ordered is then ignored.
+	Atomic bool
+
+	// Ordered stops at the first document that fails, leaving those after it
+	// uninserted. Otherwise every other document is still inserted.
+	Ordered bool
+
+	WriteOptions
+}
+
+// InsertManyOption is the options argument of InsertManyWithOptions: an
+// *InsertManyOptions, or a *WriteOptions to insert the batch as a unit
+// with a durability
+type InsertManyOption interface {
+	insertManyOptions() InsertManyOptions
+}
+
+func (o *InsertManyOptions) insertManyOptions() InsertManyOptions {
+	if o == nil {
+		return InsertManyOptions{Atomic: true}
+	}
+	return *o
+}
+
+func (o *WriteOptions) insertManyOptions() InsertManyOptions {
+	if o == nil {
+		return InsertManyOptions{Atomic: true}
+	}
+	return InsertManyOptions{Atomic: true, WriteOptions: *o}
+}
+
+// InsertManyError reports the documents of an InsertManyWithOptions batch
+// that failed, by their position in the batch. It matches the error of
+// each with errors.Is.
+type InsertManyError struct {
+	Ordered bool
+	Errors  []IndexedError
+}
+
+func (e *InsertManyError) Error() string {
+	if e.Ordered {
+		failed := e.Errors[0]
+		return fmt.Sprintf("insert many failed at document %d: %v", failed.Index, failed.Err)
+	}
+	return fmt.Sprintf("insert many completed with %d errors", len(e.Errors))
+}
+
+// Unwrap makes InsertManyError match the errors of its documents
+func (e *InsertManyError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, failed := range e.Errors {
+		errs[i] = failed
+	}
+	return errs
+}
+
+// InsertManyWithOptions inserts multiple documents, returning the _id of
+// each document inserted, in batch order, once they are as durable as opts
+// ask. A nil opts, or a *WriteOptions, inserts the batch as a unit like
+// InsertMany.
+//
+// Otherwise a document that fails (duplicate _id, unique index conflict,
+// size limit) doesn't undo the others. With Ordered, insertion stops at the
+// first failure; without it the remaining documents are still inserted.
+// If any document failed, the error is an *InsertManyError listing them,
+// and the ids returned are those of the documents inserted.
+func (c *Collection) InsertManyWithOptions(docs []map[string]interface{}, opts InsertManyOption) ([]string, error) {
+	options := InsertManyOptions{Atomic: true}
+	if opts != nil {
+		options = opts.insertManyOptions()
+	}
+
+	var ids []string
+	var failed []IndexedError
+	// The documents inserted are made durable even if others failed
+	err := c.durable(&options.WriteOptions, func() (err error) {
+		if options.Atomic {
+			ids, err = c.InsertMany(docs)
+			return err
+		}
+		ids, failed, err = c.insertManyEach(docs, options.Ordered)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(failed) > 0 {
+		return ids, &InsertManyError{Ordered: options.Ordered, Errors: failed}
+	}
+	return ids, nil
+}
+
+// insertManyEach inserts docs one by one for InsertManyWithOptions,
+// returning the ids of those inserted and the errors of those that failed
+func (c *Collection) insertManyEach(docs []map[string]interface{}, ordered bool) ([]string, []IndexedError, error) {
+	if err := c.checkWritable(); err != nil {
+		return nil, nil, err
+	}
+
+	ids := make([]string, 0, len(docs))
+	var failed []IndexedError
+	for i, doc := range docs {
+		d := document.NewDocumentFromMap(doc)
+		assignID(d)
+
+		var id string
+		var err error
+		if c.hasHooks() {
+			id, err = c.insertOneWithHooks(d)
+		} else {
+			id, err = c.insertOne(d)
+		}
+		if err != nil {
+			failed = append(failed, IndexedError{Index: i, Err: err})
+			if ordered {
+				break
+			}
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, failed, nil
+}
+
+// insertMany inserts a batch of documents without running hooks
+func (c *Collection) insertMany(batch []*document.Document) ([]string, error) {
+	start := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ids, err := c.insertBatch(batch)
+	if c.auditLogger != nil {
+		c.auditLogger.LogInsert(c.name, c.database, "", err == nil, len(ids), time.Since(start), err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// insertBatch validates, indexes and stores a batch of documents
+// Must be called with c.mu held
+func (c *Collection) insertBatch(batch []*document.Document) ([]string, error) {
+	ids := make([]string, len(batch))
+	seen := make(map[string]bool, len(batch))
+	for i, d := range batch {
+		id := assignID(d)
+		c.stampSchema(d)
+		if seen[id] || c.docStore.Exists(id) {
+			return nil, fmt.Errorf("document %d: %w", i, c.duplicateIDError(id))
+		}
+		if err := c.checkDocument(d); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		seen[id] = true
+		ids[i] = id
+	}
+
+	changes := make([]quotaChange, len(batch))
+	for i, d := range batch {
+		changes[i] = quotaChange{doc: d}
+	}
+	if err := c.checkQuota(changes...); err != nil {
+		return nil, err
+	}
+
+	// Index the whole batch first, so that a unique index conflict is found
+	// before anything is written
+	for i, d := range batch {
+		if err := c.indexDocument(ids[i], d); err != nil {
+			for j := 0; j < i; j++ {
+				c.unindexDocument(ids[j], batch[j])
+			}
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+	}
+
+	if err := c.docStore.InsertMany(ids, batch); err != nil {
+		for i, d := range batch {
+			c.unindexDocument(ids[i], d)
+		}
+		return nil, fmt.Errorf("failed to store documents: %w", err)
+	}
+
+	for _, d := range batch {
+		c.logInsert(d)
+	}
+	if len(batch) > 0 {
+		c.queryCache.Clear()
+	}
+	return ids, nil
+}
+
+// assignID returns the _id of a new document as a string, generating an
+// ObjectID if the document has none
+func assignID(d *document.Document) string {
+	if idVal, exists := d.Get("_id"); exists {
+		return fmt.Sprintf("%v", idVal)
+	}
+	objectID := document.NewObjectID()
+	d.Set("_id", objectID)
+	return objectID.Hex()
+}
+
+// indexDocument adds a new document to the indexes of the collection. If a
+// B+ tree index rejects it (a unique index conflict), the entries added so
+// far are removed and no other index is touched.
+// Must be called with c.mu held
+func (c *Collection) indexDocument(id string, d *document.Document) error {
+	added := make([]*index.Index, 0, len(c.indexes))
+	for _, idx := range c.indexes {
+		key, included := c.indexKeyFor(idx, d)
+		if !included {
+			continue
+		}
+		if err := idx.Insert(key, id); err != nil {
+			for _, prev := range added {
+				prevKey, _ := c.indexKeyFor(prev, d)
+				prev.DeleteValue(prevKey, id)
+			}
+			if idx.IsUnique() && errors.Is(err, index.ErrDuplicateKey) {
+				return c.duplicateKeyError(idx, d)
+			}
+			return fmt.Errorf("failed to insert into index %s: %w", idx.Name(), err)
+		}
+		added = append(added, idx)
+	}
+
+	for _, textIdx := range c.textIndexes {
+		textIdx.IndexDocument(id, d)
+	}
+
+	for _, geoIdx := range c.geoIndexes {
+		if fieldValue, exists := d.Get(geoIdx.FieldPath()); exists {
+			if point, err := geo.ParsePoint(fieldValue); err == nil {
+				geoIdx.Index(id, point)
+			}
+		}
+	}
+
+	for _, ttlIdx := range c.ttlIndexes {
+		if fieldValue, exists := d.Get(ttlIdx.FieldPath()); exists {
+			if timestamp, ok := ttlTimestamp(fieldValue); ok {
+				ttlIdx.Index(id, timestamp)
+			}
+		}
+	}
+
+	return nil
+}
+
+// unindexDocument removes the entries indexDocument added for a document
+// Must be called with c.mu held
+func (c *Collection) unindexDocument(id string, d *document.Document) {
+	for _, idx := range c.indexes {
+		if key, included := c.indexKeyFor(idx, d); included {
+			idx.DeleteValue(key, id)
+		}
+	}
+	for _, textIdx := range c.textIndexes {
+		textIdx.Remove(id)
+	}
+	for _, geoIdx := range c.geoIndexes {
+		geoIdx.Remove(id)
+	}
+	for _, ttlIdx := range c.ttlIndexes {
+		ttlIdx.Remove(id)
+	}
+}
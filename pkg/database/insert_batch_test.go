@@ -0,0 +1,223 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/changestream"
+)
+
+func TestInsertManyBatch(t *testing.T) {
+	dir := "./test_db_insert_batch"
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	users.CreateIndex("email", true)
+	cs, err := users.Watch(watchOptions())
+	if err != nil {
+		t.Fatalf("Failed to watch collection: %v", err)
+	}
+	defer cs.Close()
+
+	docs := make([]map[string]interface{}, 500)
+	for i := range docs {
+		docs[i] = map[string]interface{}{
+			"email": fmt.Sprintf("user%d@example.com", i),
+			"age":   int64(i % 50),
+		}
+	}
+	ids, err := users.InsertMany(docs)
+	if err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+	if len(ids) != len(docs) {
+		t.Fatalf("Expected %d ids, got %d", len(docs), len(ids))
+	}
+	if count, _ := users.Count(map[string]interface{}{}); count != len(docs) {
+		t.Errorf("Expected %d documents, got %d", len(docs), count)
+	}
+
+	doc, err := users.FindOne(map[string]interface{}{"email": "user321@example.com"})
+	if err != nil {
+		t.Fatalf("FindOne by indexed field failed: %v", err)
+	}
+	if age, _ := doc.Get("age"); age != int64(21) {
+		t.Errorf("Expected age 21, got %v", age)
+	}
+
+	// Every document of the batch is in the change stream
+	for i := 0; i < 3; i++ {
+		event := nextEvent(t, cs)
+		if event.OperationType != changestream.OperationTypeInsert {
+			t.Fatalf("Expected insert event, got %s", event.OperationType)
+		}
+		if event.FullDocument["email"] != docs[i]["email"] {
+			t.Errorf("Expected event for %v, got %v", docs[i]["email"], event.FullDocument["email"])
+		}
+	}
+}
+
+func TestInsertManyAtomic(t *testing.T) {
+	dir := "./test_db_insert_batch_atomic"
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	config := DefaultConfig(dir)
+	config.MaxDocumentSize = 1024
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	users.CreateIndex("email", true)
+	if _, err := users.InsertOne(map[string]interface{}{"_id": "u0", "email": "taken@example.com"}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+
+	batches := map[string][]map[string]interface{}{
+		"unique index conflict": {
+			{"email": "a@example.com"},
+			{"email": "b@example.com"},
+			{"email": "taken@example.com"},
+		},
+		"conflict within the batch": {
+			{"email": "a@example.com"},
+			{"email": "a@example.com"},
+		},
+		"duplicate _id": {
+			{"_id": "u1", "email": "a@example.com"},
+			{"_id": "u0", "email": "b@example.com"},
+		},
+		"document too large": {
+			{"email": "a@example.com"},
+			{"email": "b@example.com", "bio": strings.Repeat("x", 2000)},
+		},
+	}
+	for name, batch := range batches {
+		ids, err := users.InsertMany(batch)
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+		if ids != nil {
+			t.Errorf("%s: expected no ids, got %v", name, ids)
+		}
+		if count, _ := users.Count(map[string]interface{}{}); count != 1 {
+			t.Errorf("%s: expected only the existing document, got %d", name, count)
+		}
+		if entries, _ := users.IndexEntries("email_1"); len(entries) != 1 {
+			t.Errorf("%s: expected 1 index entry, got %d", name, len(entries))
+		}
+	}
+
+	if _, err := users.InsertMany(batches["document too large"]); !errors.Is(err, ErrDocumentTooLarge) {
+		t.Errorf("Expected ErrDocumentTooLarge, got %v", err)
+	}
+
+	// The rejected batches left nothing behind that blocks a valid one
+	if _, err := users.InsertMany([]map[string]interface{}{
+		{"_id": "u1", "email": "a@example.com"},
+		{"_id": "u2", "email": "b@example.com"},
+	}); err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+	if count, _ := users.Count(map[string]interface{}{}); count != 3 {
+		t.Errorf("Expected 3 documents, got %d", count)
+	}
+}
+
+func TestInsertManyWithOptions(t *testing.T) {
+	dir := "./test_db_insert_many_options"
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Document 3 of each batch collides with an existing email
+	batch := func(prefix string) []map[string]interface{} {
+		docs := make([]map[string]interface{}, 6)
+		for i := range docs {
+			docs[i] = map[string]interface{}{"_id": fmt.Sprintf("%s%d", prefix, i), "email": fmt.Sprintf("%s%d@example.com", prefix, i)}
+		}
+		docs[3]["email"] = "taken@example.com"
+		return docs
+	}
+
+	for _, ordered := range []bool{true, false} {
+		name := "unordered"
+		if ordered {
+			name = "ordered"
+		}
+		t.Run(name, func(t *testing.T) {
+			users := db.Collection("users_" + name)
+			users.CreateIndex("email", true)
+			users.InsertOne(map[string]interface{}{"_id": "existing", "email": "taken@example.com"})
+
+			ids, err := users.InsertManyWithOptions(batch(name), &InsertManyOptions{Ordered: ordered})
+			var batchErr *InsertManyError
+			if !errors.As(err, &batchErr) {
+				t.Fatalf("Expected an InsertManyError for the conflicting document, got %v", err)
+			}
+			if len(batchErr.Errors) != 1 || batchErr.Errors[0].Index != 3 {
+				t.Fatalf("Expected document 3 to fail, got %v", batchErr.Errors)
+			}
+			if !errors.Is(batchErr.Errors[0], ErrDuplicateKey) || !errors.Is(err, ErrDuplicateKey) {
+				t.Errorf("Expected a duplicate key error, got %v", batchErr.Errors[0])
+			}
+
+			expected := []string{name + "0", name + "1", name + "2"}
+			if !ordered {
+				expected = append(expected, name+"4", name+"5")
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(expected) {
+				t.Errorf("Expected %v inserted, got %v", expected, ids)
+			}
+			if count, _ := users.Count(nil); count != len(expected)+1 {
+				t.Errorf("Expected %d documents, got %d", len(expected)+1, count)
+			}
+		})
+	}
+
+	// An atomic batch inserts nothing, as do nil options and write options
+	users := db.Collection("users_atomic")
+	users.CreateIndex("email", true)
+	users.InsertOne(map[string]interface{}{"_id": "existing", "email": "taken@example.com"})
+	var writeOpts *WriteOptions
+	for i, opts := range []InsertManyOption{&InsertManyOptions{Atomic: true, Ordered: false}, nil, writeOpts, &WriteOptions{Durability: DurabilityWAL}} {
+		ids, err := users.InsertManyWithOptions(batch(fmt.Sprintf("a%d_", i)), opts)
+		if !errors.Is(err, ErrDuplicateKey) || len(ids) != 0 {
+			t.Errorf("Options %d: expected the batch to be rejected, got %v and %v", i, ids, err)
+		}
+	}
+	if count, _ := users.Count(nil); count != 1 {
+		t.Errorf("Expected no documents from atomic batches, got %d documents", count)
+	}
+
+	// Generated _ids are reported
+	users = db.Collection("users_generated")
+	ids, err := users.InsertManyWithOptions([]map[string]interface{}{{"n": 1}, {"n": 2}}, &InsertManyOptions{Ordered: true})
+	if err != nil {
+		t.Fatalf("InsertManyWithOptions failed: %v", err)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("Expected 2 inserted documents, got %v", ids)
+	}
+	if doc, err := users.FindOne(map[string]interface{}{"n": int64(2)}); err != nil || fmt.Sprint(doc.ToMap()["_id"]) != ids[1] {
+		t.Errorf("Expected the reported _id to name the document, got %v", err)
+	}
+}
@@ -480,7 +480,7 @@ func TestTTLIndexIntegration(t *testing.T) {
 	coll := db.Collection("sessions")
 
 	// Create TTL index (expire after 2 seconds)
-	if err := coll.CreateTTLIndex("createdAt", 2); err != nil { // 2 seconds
+	if err := coll.CreateTTLIndex("createdAt", 2*time.Second); err != nil { // 2 seconds
 		t.Fatalf("Failed to create TTL index: %v", err)
 	}
 
@@ -425,7 +425,7 @@ func readBytes(data []byte, offset int) ([]byte, int) {
 }
 
 // SaveCollectionMetadata saves collection metadata to a disk page
-func SaveCollectionMetadata(diskMgr *storage.DiskManager, pageID storage.PageID, meta *CollectionMetadata) error {
+func SaveCollectionMetadata(diskMgr storage.PageStore, pageID storage.PageID, meta *CollectionMetadata) error {
 	// Serialize metadata
 	data, err := SerializeCollectionMetadata(meta)
 	if err != nil {
@@ -433,10 +433,10 @@ func SaveCollectionMetadata(diskMgr *storage.DiskManager, pageID storage.PageID,
 	}
 
 	// Create or load page
-	page := storage.NewPage(pageID, storage.PageTypeData) // Using PageTypeData for now
+	page := storage.NewPageOfSize(pageID, storage.PageTypeData, diskMgr.PageSize()) // Using PageTypeData for now
 
 	// Write serialized data to page (starting after page header)
-	if len(data) > storage.PageSize-storage.PageHeaderSize {
+	if len(data) > len(page.Data)-storage.PageHeaderSize {
 		return fmt.Errorf("metadata too large for single page: %d bytes", len(data))
 	}
 
@@ -451,7 +451,7 @@ func SaveCollectionMetadata(diskMgr *storage.DiskManager, pageID storage.PageID,
 }
 
 // LoadCollectionMetadata loads collection metadata from a disk page
-func LoadCollectionMetadata(diskMgr *storage.DiskManager, pageID storage.PageID) (*CollectionMetadata, error) {
+func LoadCollectionMetadata(diskMgr storage.PageStore, pageID storage.PageID) (*CollectionMetadata, error) {
 	// Read page from disk
 	page, err := diskMgr.ReadPage(pageID)
 	if err != nil {
@@ -471,7 +471,7 @@ func LoadCollectionMetadata(diskMgr *storage.DiskManager, pageID storage.PageID)
 }
 
 // SaveIndexMetadata saves index metadata to a disk page
-func SaveIndexMetadata(diskMgr *storage.DiskManager, pageID storage.PageID, meta *IndexMetadata) error {
+func SaveIndexMetadata(diskMgr storage.PageStore, pageID storage.PageID, meta *IndexMetadata) error {
 	// Serialize metadata
 	data, err := SerializeIndexMetadata(meta)
 	if err != nil {
@@ -479,10 +479,10 @@ func SaveIndexMetadata(diskMgr *storage.DiskManager, pageID storage.PageID, meta
 	}
 
 	// Create or load page
-	page := storage.NewPage(pageID, storage.PageTypeData)
+	page := storage.NewPageOfSize(pageID, storage.PageTypeData, diskMgr.PageSize())
 
 	// Write serialized data to page
-	if len(data) > storage.PageSize-storage.PageHeaderSize {
+	if len(data) > len(page.Data)-storage.PageHeaderSize {
 		return fmt.Errorf("metadata too large for single page: %d bytes", len(data))
 	}
 
@@ -497,7 +497,7 @@ func SaveIndexMetadata(diskMgr *storage.DiskManager, pageID storage.PageID, meta
 }
 
 // LoadIndexMetadata loads index metadata from a disk page
-func LoadIndexMetadata(diskMgr *storage.DiskManager, pageID storage.PageID) (*IndexMetadata, error) {
+func LoadIndexMetadata(diskMgr storage.PageStore, pageID storage.PageID) (*IndexMetadata, error) {
 	// Read page from disk
 	page, err := diskMgr.ReadPage(pageID)
 	if err != nil {
@@ -673,7 +673,7 @@ func DeserializeIndexStatistics(data []byte) (*IndexStatistics, error) {
 }
 
 // SaveIndexStatistics saves index statistics to a disk page
-func SaveIndexStatistics(diskMgr *storage.DiskManager, pageID storage.PageID, stats *IndexStatistics) error {
+func SaveIndexStatistics(diskMgr storage.PageStore, pageID storage.PageID, stats *IndexStatistics) error {
 	// Serialize statistics
 	data, err := SerializeIndexStatistics(stats)
 	if err != nil {
@@ -681,7 +681,7 @@ func SaveIndexStatistics(diskMgr *storage.DiskManager, pageID storage.PageID, st
 	}
 	
 	// Create page
-	page := storage.NewPage(pageID, storage.PageTypeData)
+	page := storage.NewPageOfSize(pageID, storage.PageTypeData, diskMgr.PageSize())
 	
 	// Check if data fits in page
 	if len(data) > len(page.Data) {
@@ -700,7 +700,7 @@ func SaveIndexStatistics(diskMgr *storage.DiskManager, pageID storage.PageID, st
 }
 
 // LoadIndexStatistics loads index statistics from a disk page
-func LoadIndexStatistics(diskMgr *storage.DiskManager, pageID storage.PageID) (*IndexStatistics, error) {
+func LoadIndexStatistics(diskMgr storage.PageStore, pageID storage.PageID) (*IndexStatistics, error) {
 	// Read page from disk
 	page, err := diskMgr.ReadPage(pageID)
 	if err != nil {
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+// TestDottedPathQueriesAndUpdates tests querying and updating nested fields
+func TestDottedPathQueriesAndUpdates(t *testing.T) {
+	dir := "./test_db_dotted_paths"
+	defer os.RemoveAll(dir)
+
+	db, _ := Open(DefaultConfig(dir))
+	defer db.Close()
+
+	users := db.Collection("users")
+	users.InsertOne(map[string]interface{}{
+		"_id":     "alice",
+		"address": map[string]interface{}{"city": "SF", "street": "Market St"},
+		"items":   []interface{}{map[string]interface{}{"sku": "a", "qty": int64(1)}},
+	})
+	users.InsertOne(map[string]interface{}{
+		"_id":     "bob",
+		"address": map[string]interface{}{"city": "NYC"},
+	})
+
+	results, err := users.Find(map[string]interface{}{"address.city": "SF"})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+
+	err = users.UpdateOne(
+		map[string]interface{}{"_id": "alice"},
+		map[string]interface{}{
+			"$set": map[string]interface{}{"address.zip": int64(94102), "profile.nickname": "al"},
+			"$inc": map[string]interface{}{"items.0.qty": int64(2)},
+		},
+	)
+	if err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+
+	doc, err := users.FindOne(map[string]interface{}{"address.zip": int64(94102)})
+	if err != nil {
+		t.Fatalf("FindOne failed: %v", err)
+	}
+	if street, _ := doc.Get("address.street"); street != "Market St" {
+		t.Errorf("Expected address.street to be preserved, got %v", street)
+	}
+	if nickname, _ := doc.Get("profile.nickname"); nickname != "al" {
+		t.Errorf("Expected profile.nickname 'al', got %v", nickname)
+	}
+	if qty, _ := doc.Get("items.0.qty"); qty != float64(3) {
+		t.Errorf("Expected items.0.qty 3, got %v", qty)
+	}
+
+	err = users.UpdateOne(
+		map[string]interface{}{"_id": "alice"},
+		map[string]interface{}{"$unset": map[string]interface{}{"address.street": ""}},
+	)
+	if err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+	doc, _ = users.FindOne(map[string]interface{}{"_id": "alice"})
+	if doc.Has("address.street") {
+		t.Error("Expected address.street to be removed")
+	}
+	if city, _ := doc.Get("address.city"); city != "SF" {
+		t.Errorf("Expected address.city 'SF', got %v", city)
+	}
+
+	// Setting through a scalar fails and leaves the document unchanged
+	err = users.UpdateOne(
+		map[string]interface{}{"_id": "alice"},
+		map[string]interface{}{"$set": map[string]interface{}{"address.city.name": "x"}},
+	)
+	if err == nil {
+		t.Error("Expected error setting a field inside a string")
+	}
+	doc, _ = users.FindOne(map[string]interface{}{"_id": "alice"})
+	if city, _ := doc.Get("address.city"); city != "SF" {
+		t.Errorf("Expected address.city 'SF', got %v", city)
+	}
+
+	// An array index far past the end is rejected instead of allocated
+	err = users.UpdateOne(
+		map[string]interface{}{"_id": "alice"},
+		map[string]interface{}{"$set": map[string]interface{}{"items.999999999999": int64(1)}},
+	)
+	if err == nil {
+		t.Error("Expected error setting an oversized array index")
+	}
+}
+
+// TestDottedPathIndex tests that indexes on nested fields follow updates
+func TestDottedPathIndex(t *testing.T) {
+	dir := "./test_db_dotted_path_index"
+	defer os.RemoveAll(dir)
+
+	db, _ := Open(DefaultConfig(dir))
+	defer db.Close()
+
+	users := db.Collection("users")
+	if err := users.CreateIndex("address.city", false); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+
+	users.InsertOne(map[string]interface{}{"_id": "alice", "address": map[string]interface{}{"city": "SF"}})
+	users.InsertOne(map[string]interface{}{"_id": "bob", "address": map[string]interface{}{"city": "NYC"}})
+
+	result, err := users.UpdateMany(
+		map[string]interface{}{"address.city": "NYC"},
+		map[string]interface{}{"$set": map[string]interface{}{"address.city": "LA"}},
+	)
+	if err != nil {
+		t.Fatalf("UpdateMany failed: %v", err)
+	}
+	if result.ModifiedCount != 1 {
+		t.Errorf("Expected 1 update, got %d", result.ModifiedCount)
+	}
+
+	results, _ := users.Find(map[string]interface{}{"address.city": "LA"})
+	if len(results) != 1 {
+		t.Errorf("Expected 1 result, got %d", len(results))
+	}
+	results, _ = users.Find(map[string]interface{}{"address.city": "NYC"})
+	if len(results) != 0 {
+		t.Errorf("Expected 0 results, got %d", len(results))
+	}
+}
+
+// TestDottedPathArrayIndex tests array index segments in queries and updates
+func TestDottedPathArrayIndex(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	orders := db.Collection("orders")
+	orders.InsertOne(map[string]interface{}{
+		"_id": "o1",
+		"items": []interface{}{
+			map[string]interface{}{"sku": "a", "qty": int64(1)},
+			map[string]interface{}{"sku": "b", "qty": int64(2)},
+		},
+	})
+
+	if count, _ := orders.Count(map[string]interface{}{"items.1.sku": "b"}); count != 1 {
+		t.Errorf("Expected items.1.sku to match, got %d", count)
+	}
+	if count, _ := orders.Count(map[string]interface{}{"items.0.sku": "b"}); count != 0 {
+		t.Errorf("Expected items.0.sku not to match, got %d", count)
+	}
+	if count, _ := orders.Count(map[string]interface{}{"items.5.sku": map[string]interface{}{"$exists": true}}); count != 0 {
+		t.Errorf("Expected no match past the end of the array, got %d", count)
+	}
+
+	err := orders.UpdateOne(map[string]interface{}{"_id": "o1"}, map[string]interface{}{
+		"$set": map[string]interface{}{"items.1.sku": "c", "shipping.address.zipcode": int64(94000)},
+	})
+	if err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+	doc, err := orders.FindOne(map[string]interface{}{"items.1.sku": "c", "shipping.address.zipcode": int64(94000)})
+	if err != nil {
+		t.Fatalf("FindOne failed: %v", err)
+	}
+	if qty, _ := doc.Get("items.1.qty"); qty != int64(2) {
+		t.Errorf("Expected items.1.qty to be preserved, got %v", qty)
+	}
+	if address, _ := doc.Get("shipping.address"); address == nil {
+		t.Error("Expected intermediate documents to be created")
+	}
+}
+
+// TestDottedFieldNamesRejected tests that dots are reserved for field paths
+func TestDottedFieldNamesRejected(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	users := db.Collection("users")
+	if _, err := users.InsertOne(map[string]interface{}{"address.city": "SF"}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for a dotted field name, got %v", err)
+	}
+	nested := map[string]interface{}{"name": "alice", "profile": map[string]interface{}{"links": []interface{}{
+		map[string]interface{}{"example.com": "home"},
+	}}}
+	if _, err := users.InsertOne(nested); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for a nested dotted field name, got %v", err)
+	}
+
+	users.InsertOne(map[string]interface{}{"name": "alice"})
+	err := users.UpdateOne(map[string]interface{}{"name": "alice"},
+		map[string]interface{}{"$set": map[string]interface{}{"profile": map[string]interface{}{"a.b": 1}}})
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for a dotted field name in an update, got %v", err)
+	}
+	if count, _ := users.Count(nil); count != 1 {
+		t.Errorf("Expected only the valid document, got %d", count)
+	}
+}
@@ -906,3 +906,39 @@ func BenchmarkBitOperatorCombined(b *testing.B) {
 		}
 	}
 }
+
+func TestIndexedQueryAcrossNumericTypes(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("people")
+	coll.InsertOne(map[string]interface{}{"name": "Alice", "age": int64(30)})
+	coll.InsertOne(map[string]interface{}{"name": "Bob", "age": float64(31.5)})
+	coll.InsertOne(map[string]interface{}{"name": "Carol", "age": int64(40)})
+
+	if err := coll.CreateIndex("age", false); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	doc, err := coll.FindOne(map[string]interface{}{"age": 30})
+	if err != nil {
+		t.Fatalf("Expected {age: 30} to match int64(30): %v", err)
+	}
+	if name, _ := doc.Get("name"); name != "Alice" {
+		t.Errorf("Expected Alice, got %v", name)
+	}
+
+	docs, err := coll.Find(map[string]interface{}{"age": map[string]interface{}{"$gt": 30, "$lt": float64(40)}})
+	if err != nil {
+		t.Fatalf("Range query failed: %v", err)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("Expected 1 document in range, got %d", len(docs))
+	}
+	if name, _ := docs[0].Get("name"); name != "Bob" {
+		t.Errorf("Expected Bob, got %v", name)
+	}
+}
@@ -0,0 +1,303 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/storage"
+)
+
+// PageChecksumFailure describes a data page whose contents no longer match
+// the checksum recorded when it was written
+type PageChecksumFailure struct {
+	PageID      storage.PageID
+	Collection  string   // Owning collection ("" if the page holds no known documents)
+	DocumentIDs []string // Documents stored on the page
+	Expected    uint32
+	Actual      uint32
+}
+
+// PageRecovery describes the outcome of quarantining a corrupt page
+type PageRecovery struct {
+	PageID     storage.PageID
+	Collection string
+	Recovered  []string // Documents rewritten from an intact in-memory copy
+	Lost       []string // Documents with no intact copy; kept but unreadable until restored
+}
+
+// VerifyPageChecksums reads every page from disk and reports those whose
+// checksum does not match, together with the collection owning them
+func (db *Database) VerifyPageChecksums() ([]PageChecksumFailure, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if !db.isOpen {
+		return nil, ErrDatabaseClosed
+	}
+
+	verifier, err := db.pageVerifier()
+	if err != nil {
+		return nil, err
+	}
+	mismatches, err := verifier.VerifyAllPages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify pages: %w", err)
+	}
+
+	failures := make([]PageChecksumFailure, 0, len(mismatches))
+	for _, mismatch := range mismatches {
+		failure := PageChecksumFailure{
+			PageID:   mismatch.PageID,
+			Expected: mismatch.Expected,
+			Actual:   mismatch.Actual,
+		}
+		if coll, ids := db.pageOwner(mismatch.PageID); coll != nil {
+			failure.Collection = coll.name
+			failure.DocumentIDs = ids
+		}
+		failures = append(failures, failure)
+	}
+
+	return failures, nil
+}
+
+// QuarantinePage takes a corrupt page out of service and relocates the
+// documents stored on it. Documents still held intact in the document cache
+// are rewritten to new pages; the rest stay in the collection, failing reads
+// with ErrDocumentQuarantined, and are reported as lost so they can be
+// restored from another source (e.g. oplog) with Collection.RestoreDocument.
+func (db *Database) QuarantinePage(pageID storage.PageID) (*PageRecovery, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if !db.isOpen {
+		return nil, ErrDatabaseClosed
+	}
+
+	recovery := &PageRecovery{
+		PageID:    pageID,
+		Recovered: make([]string, 0),
+		Lost:      make([]string, 0),
+	}
+
+	coll, _ := db.pageOwner(pageID)
+
+	verifier, err := db.pageVerifier()
+	if err != nil {
+		return nil, err
+	}
+	if err := verifier.QuarantinePage(pageID); err != nil {
+		return nil, fmt.Errorf("failed to quarantine page %d: %w", pageID, err)
+	}
+
+	if coll == nil {
+		return recovery, nil
+	}
+
+	recovery.Collection = coll.name
+	recovered, lost, err := coll.relocatePage(pageID)
+	if err != nil {
+		return nil, err
+	}
+	recovery.Recovered = recovered
+	recovery.Lost = lost
+
+	return recovery, nil
+}
+
+// RedoPage rewrites a corrupt page from the last image of it in the
+// write-ahead log, as startup recovery does for pages torn by a crash. It
+// returns false if the log holds no such image, in which case the page can
+// only be quarantined.
+func (db *Database) RedoPage(pageID storage.PageID) (bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if !db.isOpen {
+		return false, ErrDatabaseClosed
+	}
+
+	return db.storage.RedoPage(pageID)
+}
+
+// pageVerifier returns the page store's checksum support
+func (db *Database) pageVerifier() (storage.PageVerifier, error) {
+	verifier, ok := db.storage.PageStore().(storage.PageVerifier)
+	if !ok {
+		return nil, fmt.Errorf("page store %T doesn't keep page checksums", db.storage.PageStore())
+	}
+	return verifier, nil
+}
+
+// pageOwner finds the collection whose documents live on a page
+// Must be called with db.mu held
+func (db *Database) pageOwner(pageID storage.PageID) (*Collection, []string) {
+	for _, coll := range db.collections {
+		if ids := coll.docStore.DocumentsOnPage(pageID); len(ids) > 0 {
+			return coll, ids
+		}
+	}
+	return nil, nil
+}
+
+// relocatePage moves documents off a quarantined page. Index entries of the
+// documents that cannot be recovered are kept: the documents remain in the
+// collection until restored or deleted.
+func (c *Collection) relocatePage(pageID storage.PageID) ([]string, []string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	recovered, lost, err := c.docStore.RelocatePage(pageID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.queryCache.Clear()
+	return recovered, lost, nil
+}
+
+// RestoreDocument rewrites a document lost to a quarantined page from
+// another copy of it, such as its insert in the oplog, applying updates made
+// since in order. The index entries kept for the document are left as they
+// are, since they were maintained through the same writes.
+func (c *Collection) RestoreDocument(doc map[string]interface{}, updates ...map[string]interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d := document.NewDocumentFromMap(doc)
+	idValue, exists := d.Get("_id")
+	if !exists {
+		return &ValidationError{Field: "_id", Message: "restored document must have an _id"}
+	}
+	id := fmt.Sprintf("%v", idValue)
+
+	for _, update := range updates {
+		if err := c.applyUpdate(d, update); err != nil {
+			return fmt.Errorf("failed to apply update to document %s: %w", id, err)
+		}
+	}
+
+	if err := c.docStore.Restore(id, d); err != nil {
+		return err
+	}
+
+	c.queryCache.Clear()
+	return nil
+}
+
+// DocumentsOnPage returns the ids of documents stored on a page, sorted
+func (ds *DocumentStore) DocumentsOnPage(pageID storage.PageID) []string {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	ids := make([]string, 0)
+	for id, location := range ds.locationMap {
+		if location.PageID == pageID && !location.Quarantined {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// RelocatePage rewrites documents from a quarantined page to fresh pages
+// using their cached copies. Documents without a cached copy stay in the
+// store, marked quarantined, and are returned as lost.
+func (ds *DocumentStore) RelocatePage(pageID storage.PageID) ([]string, []string, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	// The page contents are no longer trustworthy
+	delete(ds.activePagesMap, pageID)
+	ds.freeSpace.Remove(pageID)
+
+	recovered := make([]string, 0)
+	lost := make([]string, 0)
+
+	ids := make([]string, 0)
+	for id, location := range ds.locationMap {
+		if location.PageID == pageID && !location.Quarantined {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		var doc *document.Document
+		if cached, found := ds.docCache.Get(id); found {
+			doc, _ = cached.(*document.Document)
+		}
+		if doc == nil {
+			// The page is reused once freed, so the location must not be
+			// read again
+			ds.locationMap[id].Quarantined = true
+			lost = append(lost, id)
+			continue
+		}
+
+		if err := ds.rewrite(id, doc); err != nil {
+			return recovered, lost, fmt.Errorf("failed to relocate document %s: %w", id, err)
+		}
+		recovered = append(recovered, id)
+	}
+
+	return recovered, lost, nil
+}
+
+// Restore rewrites a quarantined document from another copy of it
+func (ds *DocumentStore) Restore(id string, doc *document.Document) error {
+	defer ds.snapshots.preserve(ds, id)()
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	location, exists := ds.locationMap[id]
+	if !exists {
+		return kindOf(ErrDocumentNotFound, "document not found: %s", id)
+	}
+	if !location.Quarantined {
+		return fmt.Errorf("document %s is not on a quarantined page", id)
+	}
+
+	if err := ds.rewrite(id, doc); err != nil {
+		return fmt.Errorf("failed to restore document %s: %w", id, err)
+	}
+	ds.docCache.Put(id, doc)
+	ds.charge(id, doc)
+	return nil
+}
+
+// rewrite writes a document to a new slot and points its location there,
+// without reading or freeing the old slot
+// Must be called with ds.mu held
+func (ds *DocumentStore) rewrite(id string, doc *document.Document) error {
+	// Chunks referenced from the old page can't be trusted anymore, so large
+	// binary fields are written to new ones
+	stored, blobs := ds.blobs.split(doc)
+	if err := ds.blobs.write(blobs); err != nil {
+		return err
+	}
+
+	page, err := ds.findOrAllocatePageForDocument(stored)
+	if err != nil {
+		ds.blobs.remove(blobs)
+		return fmt.Errorf("failed to find page for document: %w", err)
+	}
+
+	slotID, err := ds.pageManager.InsertDocument(page, stored)
+	if err != nil {
+		ds.blobs.remove(blobs)
+		return err
+	}
+	ds.trackPage(page)
+
+	ds.locationMap[id] = &DocumentLocation{
+		PageID: page.GetPage().ID,
+		SlotID: slotID,
+	}
+
+	if err := ds.diskManager.WritePage(page.GetPage()); err != nil {
+		return fmt.Errorf("failed to write page to disk: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/storage"
+)
+
+func TestQuarantinePageKeepsLostDocuments(t *testing.T) {
+	tmpDir := t.TempDir()
+	db, err := Open(DefaultConfig(tmpDir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("users")
+	if err := coll.CreateIndex("name", false); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := coll.InsertOne(map[string]interface{}{"_id": fmt.Sprintf("u%d", i), "name": fmt.Sprintf("user%d", i)}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	// No intact copy is left in memory
+	coll.docStore.docCache.Clear()
+
+	file, err := os.OpenFile(filepath.Join(tmpDir, "data.db"), os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open data file: %v", err)
+	}
+	buf := make([]byte, 1)
+	file.ReadAt(buf, storage.PageHeaderSize+8)
+	buf[0] ^= 0xFF
+	file.WriteAt(buf, storage.PageHeaderSize+8)
+	file.Close()
+
+	recovery, err := db.QuarantinePage(0)
+	if err != nil {
+		t.Fatalf("QuarantinePage failed: %v", err)
+	}
+	if len(recovery.Recovered) != 0 || len(recovery.Lost) != 3 {
+		t.Fatalf("Expected 3 lost documents, got %+v", recovery)
+	}
+
+	// Lost documents are reported, not dropped
+	if count := coll.docStore.Count(); count != 3 {
+		t.Errorf("Expected 3 documents after quarantine, got %d", count)
+	}
+	if _, err := coll.docStore.Get("u0"); !errors.Is(err, ErrDocumentQuarantined) {
+		t.Errorf("Expected ErrDocumentQuarantined, got %v", err)
+	}
+	if err := coll.UpdateOne(map[string]interface{}{"_id": "u0"}, map[string]interface{}{"$set": map[string]interface{}{"age": 1}}); err == nil {
+		t.Error("Expected update of a quarantined document to fail")
+	}
+
+	// The freed page is reused without the lost documents reading from it
+	if _, err := coll.InsertOne(map[string]interface{}{"_id": "u3", "name": "user3"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	coll.docStore.docCache.Clear()
+	if _, err := coll.docStore.Get("u1"); !errors.Is(err, ErrDocumentQuarantined) {
+		t.Errorf("Expected ErrDocumentQuarantined, got %v", err)
+	}
+
+	// A restored document is readable again, with later updates applied
+	err = coll.RestoreDocument(
+		map[string]interface{}{"_id": "u0", "name": "user0"},
+		map[string]interface{}{"$set": map[string]interface{}{"age": 30}},
+	)
+	if err != nil {
+		t.Fatalf("RestoreDocument failed: %v", err)
+	}
+	doc, err := coll.FindOne(map[string]interface{}{"name": "user0"})
+	if err != nil {
+		t.Fatalf("Failed to find restored document: %v", err)
+	}
+	if age, _ := doc.Get("age"); age != int64(30) && age != 30 {
+		t.Errorf("Expected age 30, got %v", age)
+	}
+	if err := coll.RestoreDocument(map[string]interface{}{"_id": "u3", "name": "user3"}); err == nil {
+		t.Error("Expected restoring a readable document to fail")
+	}
+
+	// Deleting a lost document leaves the reused page alone
+	if err := coll.docStore.Delete("u1"); err != nil {
+		t.Errorf("Failed to delete quarantined document: %v", err)
+	}
+	if _, err := coll.docStore.Get("u3"); err != nil {
+		t.Errorf("Expected u3 to be readable: %v", err)
+	}
+	if count := coll.docStore.Count(); count != 3 {
+		t.Errorf("Expected 3 documents, got %d", count)
+	}
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/storage"
+)
+
+func TestPageSizeLargeDocuments(t *testing.T) {
+	dir := t.TempDir()
+	config := DefaultConfig(dir)
+	config.PageSize = 16384
+
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	// Too large for a default 4KB page
+	body := strings.Repeat("x", 10000)
+	if _, err := db.Collection("posts").InsertOne(map[string]interface{}{"_id": "p1", "body": body}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	if got := db.storage.PageSize(); got != 16384 {
+		t.Errorf("Expected 16384-byte pages, got %d", got)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Reopening without a page size uses the one the database was created with
+	db, err = Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	if got := db.storage.PageSize(); got != 16384 {
+		t.Errorf("Expected 16384-byte pages after reopen, got %d", got)
+	}
+	if _, err := db.Collection("posts").InsertOne(map[string]interface{}{"_id": "p2", "body": body}); err != nil {
+		t.Errorf("InsertOne after reopen failed: %v", err)
+	}
+	if _, err := db.Collection("posts").InsertOne(map[string]interface{}{"_id": "p3", "body": strings.Repeat("y", 20000)}); !errors.Is(err, ErrDocumentTooLarge) {
+		t.Errorf("Expected ErrDocumentTooLarge beyond the page size, got %v", err)
+	}
+	db.Close()
+
+	// Reopening with another page size is rejected
+	config = DefaultConfig(dir)
+	config.PageSize = 8192
+	if _, err := Open(config); !errors.Is(err, storage.ErrPageSizeMismatch) {
+		t.Errorf("Expected ErrPageSizeMismatch, got %v", err)
+	}
+}
+
+func TestPageSizeDefaultRejectsLargeDocuments(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Collection("posts").InsertOne(map[string]interface{}{"_id": "p1", "body": "short"}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	_, err = db.Collection("posts").InsertOne(map[string]interface{}{"_id": "p2", "body": strings.Repeat("x", 10000)})
+	if !errors.Is(err, ErrDocumentTooLarge) {
+		t.Errorf("Expected ErrDocumentTooLarge, got %v", err)
+	}
+
+	// A 4KB database can't be reopened as a 32KB one
+	db.Close()
+	config := DefaultConfig(db.dataDir)
+	config.PageSize = 32768
+	if _, err := Open(config); !errors.Is(err, storage.ErrPageSizeMismatch) {
+		t.Errorf("Expected ErrPageSizeMismatch, got %v", err)
+	}
+}
+
+func TestPageSizeInvalid(t *testing.T) {
+	for _, size := range []int{1024, 6000, 65536} {
+		config := DefaultConfig(t.TempDir())
+		config.PageSize = size
+		if db, err := Open(config); err == nil {
+			db.Close()
+			t.Errorf("Expected page size %d to be rejected", size)
+		}
+	}
+
+	config := DefaultConfig(t.TempDir())
+	config.InMemory = true
+	config.PageSize = 8192
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Collection("posts").InsertOne(map[string]interface{}{"_id": "p1", "body": strings.Repeat("x", 6000)}); err != nil {
+		t.Errorf("InsertOne failed: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/storage"
+)
+
+func TestOpenInMemory(t *testing.T) {
+	dir := t.TempDir()
+	config := DefaultConfig(dir)
+	config.InMemory = true
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("users")
+	if err := coll.CreateIndex("email", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if _, err := coll.InsertOne(map[string]interface{}{"email": email}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	doc, err := coll.FindOne(map[string]interface{}{"email": "b@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to find document: %v", err)
+	}
+	if email, _ := doc.Get("email"); email != "b@example.com" {
+		t.Errorf("Expected b@example.com, got %v", email)
+	}
+
+	if info, err := os.Stat(filepath.Join(dir, "data.db")); err == nil {
+		t.Errorf("Expected no data file, found one of %d bytes", info.Size())
+	}
+
+	// Checksums are a feature of the file page store
+	if _, err := db.VerifyPageChecksums(); err == nil {
+		t.Error("Expected checksum verification to be unsupported")
+	}
+}
+
+func TestOpenWithPageStore(t *testing.T) {
+	store := storage.NewMemoryPageStore()
+	config := DefaultConfig(t.TempDir())
+	config.PageStore = store
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Collection("users").InsertOne(map[string]interface{}{"name": "Alice"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if writes := store.Stats()["total_writes"].(int64); writes == 0 {
+		t.Error("Expected pages to be written to the custom page store")
+	}
+}
@@ -466,3 +466,114 @@ func TestPartialIndexUpdateMany(t *testing.T) {
 		}
 	}
 }
+
+func TestPartialIndexQueryPlanning(t *testing.T) {
+	dir := "./test_partial_planning"
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("users")
+	if err := coll.CreatePartialIndex("email", map[string]interface{}{"status": "active"}, true); err != nil {
+		t.Fatalf("Failed to create partial index: %v", err)
+	}
+	for _, status := range []string{"active", "inactive", "inactive"} {
+		if _, err := coll.InsertOne(map[string]interface{}{"email": "alice@example.com", "status": status}); err != nil {
+			t.Fatalf("Failed to insert %s user: %v", status, err)
+		}
+	}
+
+	// Without the status condition the index lacks the inactive users
+	filter := map[string]interface{}{"email": "alice@example.com"}
+	if plan := coll.Explain(filter); plan["scanType"] != "COLLECTION_SCAN" {
+		t.Errorf("Expected a collection scan, got %v", plan)
+	}
+	results, err := coll.Find(filter)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("Expected 3 users, got %d", len(results))
+	}
+
+	filter = map[string]interface{}{"email": "alice@example.com", "status": "active"}
+	if plan := coll.Explain(filter); plan["indexName"] != "email_partial" {
+		t.Errorf("Expected email_partial to serve the query, got %v", plan)
+	}
+	results, err = coll.Find(filter)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 active user, got %d", len(results))
+	}
+}
+
+func TestPartialIndexUniqueOutsideFilter(t *testing.T) {
+	dir := "./test_partial_unique_outside"
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("users")
+	if err := coll.CreatePartialIndex("email", map[string]interface{}{"status": "active"}, true); err != nil {
+		t.Fatalf("Failed to create partial index: %v", err)
+	}
+	insert := func(name, status string) error {
+		_, err := coll.InsertOne(map[string]interface{}{"name": name, "email": "bob@example.com", "status": status})
+		return err
+	}
+	if err := insert("active", "active"); err != nil {
+		t.Fatalf("Failed to insert active user: %v", err)
+	}
+	for _, name := range []string{"old1", "old2"} {
+		if err := insert(name, "inactive"); err != nil {
+			t.Fatalf("Duplicate email should be allowed for inactive users: %v", err)
+		}
+	}
+
+	// Changing or deleting an inactive user must leave the active user's
+	// entry in place
+	if err := coll.UpdateOne(
+		map[string]interface{}{"name": "old1"},
+		map[string]interface{}{"$set": map[string]interface{}{"note": "archived"}},
+	); err != nil {
+		t.Fatalf("Failed to update inactive user: %v", err)
+	}
+	if err := coll.DeleteOne(map[string]interface{}{"name": "old2"}); err != nil {
+		t.Fatalf("Failed to delete inactive user: %v", err)
+	}
+	if err := insert("clone", "active"); err == nil {
+		t.Error("Expected duplicate key error for a second active user")
+	}
+
+	// Activating a user with a taken email is rejected
+	if err := coll.UpdateOne(
+		map[string]interface{}{"name": "old1"},
+		map[string]interface{}{"$set": map[string]interface{}{"status": "active"}},
+	); err == nil {
+		t.Error("Expected duplicate key error when activating a user with a taken email")
+	}
+
+	// Deactivating the active user frees the email
+	if err := coll.UpdateOne(
+		map[string]interface{}{"name": "active"},
+		map[string]interface{}{"$set": map[string]interface{}{"status": "inactive"}},
+	); err != nil {
+		t.Fatalf("Failed to deactivate user: %v", err)
+	}
+	if err := coll.UpdateOne(
+		map[string]interface{}{"name": "old1"},
+		map[string]interface{}{"$set": map[string]interface{}{"status": "active"}},
+	); err != nil {
+		t.Errorf("Expected the freed email to be usable: %v", err)
+	}
+}
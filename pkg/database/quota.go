@@ -0,0 +1,362 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+// tenantUsageFile keeps tenant usage counters across restarts
+const tenantUsageFile = "tenant_usage.json"
+
+// Quota limits what a tenant may store and how fast it may write.
+// Zero fields are unlimited.
+type Quota struct {
+	MaxBytes        int64 // Encoded size of the tenant's documents
+	MaxDocuments    int64 // Number of the tenant's documents
+	MaxOpsPerSecond int   // Documents inserted, updated or replaced per second
+}
+
+// QuotaConfig configures the quotas of a database's tenants
+type QuotaConfig struct {
+	// TenantField names the top-level string field that holds the tenant of
+	// a document. Documents without it, and all documents when TenantField
+	// is empty, belong to the tenant named like the database, so that a
+	// Client with quotas limits each of its databases.
+	TenantField string
+
+	// Default is the quota of tenants not listed in Tenants
+	Default Quota
+
+	// Tenants holds the quotas of specific tenants
+	Tenants map[string]Quota
+}
+
+// TenantUsage is what a tenant stores, and how many of its writes were
+// rejected by its quota
+type TenantUsage struct {
+	Bytes          int64 `json:"bytes"`
+	Documents      int64 `json:"documents"`
+	RejectedWrites int64 `json:"rejectedWrites"`
+}
+
+// quotaCharge is the usage a stored document is charged to its tenant
+type quotaCharge struct {
+	tenant string
+	bytes  int64
+}
+
+// quotaChange is a write checked against quotas: doc replaces the document
+// charged old (nil old for an insert, nil doc for a delete)
+type quotaChange struct {
+	old *quotaCharge
+	doc *document.Document
+}
+
+// tokenBucket limits the rate of a tenant's writes, allowing bursts of one
+// second of writes
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// quotaTracker accounts the usage of each tenant of a database and enforces
+// their quotas. Writes are checked against the quota before they are
+// applied; deletes are never rejected, so a tenant over its quota can
+// always free space. A nil tracker disables quotas.
+type quotaTracker struct {
+	config   QuotaConfig
+	database string // Tenant of documents without a tenant field
+	path     string
+	mu       sync.Mutex
+	usage    map[string]*TenantUsage
+	buckets  map[string]*tokenBucket
+}
+
+// loadQuotaTracker creates the tracker of a database, starting from the
+// usage saved by a previous run. A missing or unreadable file starts the
+// counters from zero.
+func loadQuotaTracker(config *QuotaConfig, database, path string) *quotaTracker {
+	if config == nil {
+		return nil
+	}
+	t := &quotaTracker{
+		config:   *config,
+		database: database,
+		path:     path,
+		usage:    make(map[string]*TenantUsage),
+		buckets:  make(map[string]*tokenBucket),
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &t.usage)
+	}
+	return t
+}
+
+// tenantUsagePath returns where a database in dataDir keeps tenant usage
+func tenantUsagePath(dataDir string) string {
+	return filepath.Join(dataDir, tenantUsageFile)
+}
+
+// quota returns the quota of a tenant
+func (t *quotaTracker) quota(tenant string) Quota {
+	if q, exists := t.config.Tenants[tenant]; exists {
+		return q
+	}
+	return t.config.Default
+}
+
+// tenantOf returns the tenant a document belongs to
+func (t *quotaTracker) tenantOf(doc *document.Document) string {
+	if t.config.TenantField != "" {
+		if tenant, ok := doc.ToMap()[t.config.TenantField].(string); ok && tenant != "" {
+			return tenant
+		}
+	}
+	return t.database
+}
+
+// measure returns the charge of a document: its tenant and encoded size
+func (t *quotaTracker) measure(doc *document.Document) quotaCharge {
+	charge := quotaCharge{tenant: t.tenantOf(doc)}
+	if data, err := document.NewEncoder().Encode(doc); err == nil {
+		charge.bytes = int64(len(data))
+	}
+	return charge
+}
+
+// tenantUsage returns the usage counters of a tenant
+// Must be called with t.mu held
+func (t *quotaTracker) tenantUsage(tenant string) *TenantUsage {
+	u, exists := t.usage[tenant]
+	if !exists {
+		u = &TenantUsage{}
+		t.usage[tenant] = u
+	}
+	return u
+}
+
+// admit returns a *QuotaError if the changes would take a tenant over its
+// byte or document quota. With takeOps, each inserted, updated or replaced
+// document also counts against its tenant's operation rate. Usage changes
+// only once the writes are stored.
+func (t *quotaTracker) admit(changes []quotaChange, takeOps bool) error {
+	if t == nil {
+		return nil
+	}
+
+	type delta struct{ bytes, documents, ops int64 }
+	deltas := make(map[string]*delta)
+	get := func(tenant string) *delta {
+		d, exists := deltas[tenant]
+		if !exists {
+			d = &delta{}
+			deltas[tenant] = d
+		}
+		return d
+	}
+	for _, change := range changes {
+		if change.old != nil {
+			d := get(change.old.tenant)
+			d.bytes -= change.old.bytes
+			d.documents--
+		}
+		if change.doc != nil {
+			charge := t.measure(change.doc)
+			d := get(charge.tenant)
+			d.bytes += charge.bytes
+			d.documents++
+			d.ops++
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for tenant, d := range deltas {
+		q := t.quota(tenant)
+		u := t.tenantUsage(tenant)
+		var err error
+		switch {
+		case q.MaxBytes > 0 && d.bytes > 0 && u.Bytes+d.bytes > q.MaxBytes:
+			err = &QuotaError{Tenant: tenant, Resource: "bytes", Limit: q.MaxBytes, Requested: u.Bytes + d.bytes}
+		case q.MaxDocuments > 0 && d.documents > 0 && u.Documents+d.documents > q.MaxDocuments:
+			err = &QuotaError{Tenant: tenant, Resource: "documents", Limit: q.MaxDocuments, Requested: u.Documents + d.documents}
+		case takeOps && q.MaxOpsPerSecond > 0 && d.ops > 0 && t.available(tenant, q, now) < float64(d.ops):
+			err = &QuotaError{Tenant: tenant, Resource: "operations", Limit: int64(q.MaxOpsPerSecond), Requested: d.ops}
+		}
+		if err != nil {
+			u.RejectedWrites++
+			return err
+		}
+	}
+
+	if takeOps {
+		for tenant, d := range deltas {
+			if bucket, exists := t.buckets[tenant]; exists {
+				bucket.tokens -= float64(d.ops)
+			}
+		}
+	}
+	return nil
+}
+
+// available refills the token bucket of a tenant and returns its tokens
+// Must be called with t.mu held
+func (t *quotaTracker) available(tenant string, q Quota, now time.Time) float64 {
+	rate := float64(q.MaxOpsPerSecond)
+	bucket, exists := t.buckets[tenant]
+	if !exists {
+		bucket = &tokenBucket{tokens: rate, last: now}
+		t.buckets[tenant] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.last).Seconds() * rate
+	if bucket.tokens > rate {
+		bucket.tokens = rate
+	}
+	bucket.last = now
+	return bucket.tokens
+}
+
+// add adds a stored document's charge to its tenant, or removes it for a
+// negative sign
+func (t *quotaTracker) add(charge quotaCharge, sign int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u := t.tenantUsage(charge.tenant)
+	u.Bytes += sign * charge.bytes
+	u.Documents += sign
+}
+
+// snapshot returns a copy of the usage of every tenant
+func (t *quotaTracker) snapshot() map[string]TenantUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := make(map[string]TenantUsage, len(t.usage))
+	for tenant, u := range t.usage {
+		usage[tenant] = *u
+	}
+	return usage
+}
+
+// stats returns the usage and quota of every tenant for Database.Stats
+func (t *quotaTracker) stats() map[string]interface{} {
+	stats := make(map[string]interface{})
+	for tenant, u := range t.snapshot() {
+		q := t.quota(tenant)
+		stats[tenant] = map[string]interface{}{
+			"bytes":              u.Bytes,
+			"documents":          u.Documents,
+			"rejected_writes":    u.RejectedWrites,
+			"max_bytes":          q.MaxBytes,
+			"max_documents":      q.MaxDocuments,
+			"max_ops_per_second": q.MaxOpsPerSecond,
+		}
+	}
+	return stats
+}
+
+// save writes the usage counters
+func (t *quotaTracker) save() error {
+	if t == nil {
+		return nil
+	}
+	data, err := json.Marshal(t.snapshot())
+	if err != nil {
+		return fmt.Errorf("failed to encode tenant usage: %w", err)
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write tenant usage: %w", err)
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		return fmt.Errorf("failed to write tenant usage: %w", err)
+	}
+	return nil
+}
+
+// TenantUsage returns the usage of every tenant that stored documents or had
+// writes rejected, or nil when the database has no quotas. Usage includes
+// previous runs of the database.
+func (db *Database) TenantUsage() map[string]TenantUsage {
+	if db.quota == nil {
+		return nil
+	}
+	return db.quota.snapshot()
+}
+
+// quotaChange returns the change of replacing the document stored under id
+// by doc; an id not stored makes it an insert
+func (c *Collection) quotaChange(id string, doc *document.Document) quotaChange {
+	return quotaChange{old: c.docStore.quotaCharge(id), doc: doc}
+}
+
+// checkQuota returns a *QuotaError, matching ErrQuotaExceeded, if the
+// changes would take a tenant over its quota or write rate
+func (c *Collection) checkQuota(changes ...quotaChange) error {
+	return c.docStore.quota.admit(changes, true)
+}
+
+// quotaCharge returns the charge of a stored document, or nil if it isn't
+// charged
+func (ds *DocumentStore) quotaCharge(id string) *quotaCharge {
+	if ds.quota == nil {
+		return nil
+	}
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	if charge, exists := ds.charges[id]; exists {
+		return &charge
+	}
+	return nil
+}
+
+// charge charges a document just stored under id to its tenant, in place of
+// the version it replaced
+// Must be called with ds.mu held
+func (ds *DocumentStore) charge(id string, doc *document.Document) {
+	if ds.quota == nil {
+		return
+	}
+	ds.uncharge(id)
+	if ds.charges == nil {
+		ds.charges = make(map[string]quotaCharge)
+	}
+	charge := ds.quota.measure(doc)
+	ds.charges[id] = charge
+	ds.quota.add(charge, 1)
+}
+
+// uncharge removes the charge of a document no longer stored under id
+// Must be called with ds.mu held
+func (ds *DocumentStore) uncharge(id string) {
+	if ds.quota == nil {
+		return
+	}
+	if charge, exists := ds.charges[id]; exists {
+		ds.quota.add(charge, -1)
+		delete(ds.charges, id)
+	}
+}
+
+// releaseQuota removes the charges of every document, for a dropped collection
+func (ds *DocumentStore) releaseQuota() {
+	if ds.quota == nil {
+		return
+	}
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for id := range ds.charges {
+		ds.uncharge(id)
+	}
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func openQuotaDatabase(t *testing.T, dir string, quotas *QuotaConfig) *Database {
+	t.Helper()
+
+	config := DefaultConfig(dir)
+	config.Quotas = quotas
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	return db
+}
+
+func TestQuotaPerTenantField(t *testing.T) {
+	db := openQuotaDatabase(t, t.TempDir(), &QuotaConfig{
+		TenantField: "tenant",
+		Default:     Quota{MaxDocuments: 3},
+		Tenants:     map[string]Quota{"big": {MaxBytes: 400}},
+	})
+	defer db.Close()
+	coll := db.Collection("items")
+
+	for i := 0; i < 3; i++ {
+		if _, err := coll.InsertOne(map[string]interface{}{"tenant": "acme", "n": int64(i)}); err != nil {
+			t.Fatalf("InsertOne %d failed: %v", i, err)
+		}
+	}
+	_, err := coll.InsertOne(map[string]interface{}{"tenant": "acme", "n": int64(3)})
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Tenant != "acme" || quotaErr.Resource != "documents" {
+		t.Fatalf("Expected a documents QuotaError for acme, got %v", err)
+	}
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+	}
+
+	// Other tenants have their own quota
+	if _, err := coll.InsertOne(map[string]interface{}{"tenant": "other"}); err != nil {
+		t.Errorf("InsertOne for another tenant failed: %v", err)
+	}
+
+	// Deleting frees quota
+	if err := coll.DeleteOne(map[string]interface{}{"tenant": "acme", "n": int64(0)}); err != nil {
+		t.Fatalf("DeleteOne failed: %v", err)
+	}
+	if _, err := coll.InsertOne(map[string]interface{}{"tenant": "acme", "n": int64(3)}); err != nil {
+		t.Errorf("InsertOne after delete failed: %v", err)
+	}
+
+	// Byte quotas count updates that grow a document
+	if _, err := coll.InsertOne(map[string]interface{}{"_id": "b1", "tenant": "big", "body": "small"}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	err = coll.UpdateOne(map[string]interface{}{"_id": "b1"}, map[string]interface{}{
+		"$set": map[string]interface{}{"body": strings.Repeat("x", 500)},
+	})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded for a growing update, got %v", err)
+	}
+	if _, err := coll.InsertMany([]map[string]interface{}{
+		{"tenant": "big", "body": strings.Repeat("y", 200)},
+		{"tenant": "big", "body": strings.Repeat("z", 200)},
+	}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded for a batch, got %v", err)
+	}
+
+	usage := db.TenantUsage()
+	if usage["acme"].Documents != 3 || usage["acme"].RejectedWrites != 1 {
+		t.Errorf("Unexpected acme usage: %+v", usage["acme"])
+	}
+	if usage["big"].Documents != 1 || usage["big"].Bytes <= 0 || usage["big"].Bytes > 400 {
+		t.Errorf("Unexpected big usage: %+v", usage["big"])
+	}
+	tenants := db.Stats()["tenants"].(map[string]interface{})
+	if stats := tenants["big"].(map[string]interface{}); stats["max_bytes"] != int64(400) || stats["rejected_writes"] != int64(2) {
+		t.Errorf("Unexpected big stats: %v", stats)
+	}
+}
+
+func TestQuotaPerDatabase(t *testing.T) {
+	client, err := NewClient(&Config{
+		DataDir:        t.TempDir(),
+		BufferPoolSize: 100,
+		Quotas:         &QuotaConfig{Tenants: map[string]Quota{"small": {MaxDocuments: 1}}},
+	})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	small, _ := client.Database("small")
+	large, _ := client.Database("large")
+	if _, err := small.Collection("a").InsertOne(map[string]interface{}{"x": int64(1)}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	if _, err := small.Collection("b").InsertOne(map[string]interface{}{"x": int64(1)}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected the database quota to span collections, got %v", err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if _, err := large.Collection(name).InsertOne(map[string]interface{}{"x": int64(1)}); err != nil {
+			t.Errorf("InsertOne into an unlimited database failed: %v", err)
+		}
+	}
+
+	// Dropping a collection releases its documents
+	if err := small.DropCollection("a"); err != nil {
+		t.Fatalf("DropCollection failed: %v", err)
+	}
+	if _, err := small.Collection("b").InsertOne(map[string]interface{}{"x": int64(1)}); err != nil {
+		t.Errorf("InsertOne after drop failed: %v", err)
+	}
+}
+
+func TestQuotaRateLimitAndSessions(t *testing.T) {
+	db := openQuotaDatabase(t, t.TempDir(), &QuotaConfig{
+		Default: Quota{MaxOpsPerSecond: 2, MaxDocuments: 10},
+	})
+	coll := db.Collection("events")
+
+	var rejected int
+	for i := 0; i < 5; i++ {
+		if _, err := coll.InsertOne(map[string]interface{}{"n": int64(i)}); errors.Is(err, ErrQuotaExceeded) {
+			rejected++
+		} else if err != nil {
+			t.Fatalf("InsertOne failed: %v", err)
+		}
+	}
+	if rejected == 0 {
+		t.Error("Expected writes beyond 2 per second to be rejected")
+	}
+
+	// A transaction is checked as a whole when it commits
+	db.quota.config.Default.MaxOpsPerSecond = 0
+	db.quota.config.Default.MaxDocuments = db.TenantUsage()[db.Name()].Documents + 1
+	session := db.StartSession()
+	session.InsertOne("events", map[string]interface{}{"n": int64(10)})
+	session.InsertOne("events", map[string]interface{}{"n": int64(11)})
+	if err := session.CommitTransaction(); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected the commit to exceed the quota, got %v", err)
+	}
+
+	// Usage survives a restart
+	want := db.TenantUsage()
+	dir := db.dataDir
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	db = openQuotaDatabase(t, dir, &QuotaConfig{})
+	defer db.Close()
+	if got := db.TenantUsage()[db.Name()]; got != want[db.Name()] {
+		t.Errorf("Expected usage %+v after reopen, got %+v", want[db.Name()], got)
+	}
+}
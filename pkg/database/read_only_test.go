@@ -0,0 +1,176 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestReadOnlyRejectsWrites(t *testing.T) {
+	dir := "./test_db_read_only"
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll, err := db.CreateCollection("users")
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		if _, err := coll.InsertOne(map[string]interface{}{"name": name}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	db.SetReadOnly(true)
+	if !db.IsReadOnly() {
+		t.Fatal("Expected database to be read-only")
+	}
+
+	writes := map[string]func() error{
+		"InsertOne": func() error {
+			_, err := coll.InsertOne(map[string]interface{}{"name": "Dave"})
+			return err
+		},
+		"InsertMany": func() error {
+			_, err := coll.InsertMany([]map[string]interface{}{{"name": "Dave"}})
+			return err
+		},
+		"UpdateOne": func() error {
+			return coll.UpdateOne(map[string]interface{}{"name": "Alice"}, map[string]interface{}{"$set": map[string]interface{}{"age": int64(30)}})
+		},
+		"UpdateMany": func() error {
+			_, err := coll.UpdateMany(map[string]interface{}{}, map[string]interface{}{"$set": map[string]interface{}{"age": int64(30)}})
+			return err
+		},
+		"DeleteOne": func() error {
+			return coll.DeleteOne(map[string]interface{}{"name": "Alice"})
+		},
+		"DeleteMany": func() error {
+			_, err := coll.DeleteMany(map[string]interface{}{})
+			return err
+		},
+		"CreateIndex": func() error {
+			return coll.CreateIndex("name", true)
+		},
+		"CreateCollection": func() error {
+			_, err := db.CreateCollection("orders")
+			return err
+		},
+		"DropCollection": func() error {
+			return db.DropCollection("users")
+		},
+		"Aggregate $out": func() error {
+			_, err := coll.Aggregate([]map[string]interface{}{{"$out": "users_copy"}})
+			return err
+		},
+	}
+	for name, write := range writes {
+		if err := write(); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s: expected ErrReadOnly, got %v", name, err)
+		}
+	}
+
+	// Reads and cursors are still served
+	docs, err := coll.Find(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(docs) != 3 {
+		t.Errorf("Expected 3 documents, got %d", len(docs))
+	}
+
+	cursor, err := coll.FindCursor(map[string]interface{}{}, nil)
+	if err != nil {
+		t.Fatalf("FindCursor failed: %v", err)
+	}
+	count := 0
+	for cursor.HasNext() {
+		if _, err := cursor.Next(); err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		count++
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 documents from cursor, got %d", count)
+	}
+
+	// Writes are accepted again once read-only mode is turned off
+	db.SetReadOnly(false)
+	if _, err := coll.InsertOne(map[string]interface{}{"name": "Dave"}); err != nil {
+		t.Fatalf("Expected insert to succeed, got %v", err)
+	}
+	if n, _ := coll.Count(map[string]interface{}{}); n != 4 {
+		t.Errorf("Expected 4 documents, got %d", n)
+	}
+}
+
+func TestReadOnlyRejectsSessionWrites(t *testing.T) {
+	dir := "./test_db_read_only_session"
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.CreateCollection("users"); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	// A transaction started before read-only mode can't commit its writes
+	session := db.StartSession()
+	if _, err := session.InsertOne("users", map[string]interface{}{"name": "Alice"}); err != nil {
+		t.Fatalf("Failed to insert in session: %v", err)
+	}
+
+	db.SetReadOnly(true)
+	if err := session.CommitTransaction(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly on commit, got %v", err)
+	}
+
+	session = db.StartSession()
+	if _, err := session.InsertOne("users", map[string]interface{}{"name": "Bob"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly on session insert, got %v", err)
+	}
+	session.AbortTransaction()
+
+	if n, _ := db.Collection("users").Count(map[string]interface{}{}); n != 0 {
+		t.Errorf("Expected 0 documents, got %d", n)
+	}
+}
+
+func TestSecondaryIsReadOnly(t *testing.T) {
+	dir := "./test_db_read_only_secondary"
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("users")
+
+	db.SetSecondary(true)
+	if _, err := coll.InsertOne(map[string]interface{}{"name": "Alice"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly on secondary, got %v", err)
+	}
+
+	// Maintenance mode stays on when the node stops being a secondary
+	db.SetReadOnly(true)
+	db.SetSecondary(false)
+	if !db.IsReadOnly() {
+		t.Error("Expected database to stay read-only")
+	}
+
+	db.SetReadOnly(false)
+	if _, err := coll.InsertOne(map[string]interface{}{"name": "Alice"}); err != nil {
+		t.Errorf("Expected insert to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"reflect"
+
+	"github.com/mnohosten/laura-db/pkg/audit"
+)
+
+// reloadableConfig lists the Config fields Reconfigure can change on an open
+// database. Of AuditConfig, only Enabled and MinSeverity can change.
+var reloadableConfig = map[string]bool{
+	"BufferPoolSize": true,
+	"Durability":     true,
+	"WALArchiving":   true,
+	"AuditConfig":    true,
+}
+
+// Reconfigure applies config to the open database without reopening it.
+// BufferPoolSize, Durability, WALArchiving and, for a database opened with
+// an AuditConfig, its Enabled and MinSeverity can change at runtime. A
+// config that changes any other setting, compared with the configuration
+// the database was opened or last reconfigured with, is rejected with a
+// *RestartRequiredError naming them, and nothing is applied.
+//
+// Shrinking the buffer pool evicts unpinned pages, flushing dirty ones.
+func (db *Database) Reconfigure(config *Config) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if !db.isOpen {
+		return ErrDatabaseClosed
+	}
+	if fields := restartFields(&db.config, config); len(fields) > 0 {
+		return &RestartRequiredError{Fields: fields}
+	}
+
+	durability, err := ParseDurability(string(config.Durability))
+	if err != nil {
+		return err
+	}
+	minSeverity := audit.Severity("")
+	if config.AuditConfig != nil && config.AuditConfig.MinSeverity != "" {
+		if minSeverity, err = audit.ParseSeverity(string(config.AuditConfig.MinSeverity)); err != nil {
+			return kindOf(ErrValidation, "%v", err)
+		}
+	}
+	if config.BufferPoolSize != db.config.BufferPoolSize {
+		if config.BufferPoolSize <= 0 {
+			return kindOf(ErrValidation, "buffer pool size must be positive, got %d", config.BufferPoolSize)
+		}
+		if err := db.storage.ResizeBufferPool(config.BufferPoolSize); err != nil {
+			return err
+		}
+	}
+
+	db.changes.durability.Store(durability.rank())
+	db.changes.archiving.Store(config.WALArchiving)
+	if db.auditLogger != nil {
+		db.auditLogger.SetEnabled(config.AuditConfig.Enabled)
+		if minSeverity != "" {
+			db.auditLogger.SetMinSeverity(minSeverity)
+		}
+	}
+	db.config = copyConfig(config)
+	return nil
+}
+
+// restartFields returns the names of the fields of config that differ from
+// current and can't change at runtime
+func restartFields(current, config *Config) []string {
+	var fields []string
+	currentValue, value := reflect.ValueOf(current).Elem(), reflect.ValueOf(config).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		name := value.Type().Field(i).Name
+		if reloadableConfig[name] {
+			continue
+		}
+		if !reflect.DeepEqual(currentValue.Field(i).Interface(), value.Field(i).Interface()) {
+			fields = append(fields, name)
+		}
+	}
+
+	// Auditing can't be turned on or off, nor its output changed
+	if (current.AuditConfig == nil) != (config.AuditConfig == nil) {
+		return append(fields, "AuditConfig")
+	}
+	if current.AuditConfig != nil {
+		currentAudit, newAudit := *current.AuditConfig, *config.AuditConfig
+		currentAudit.Enabled, newAudit.Enabled = false, false
+		currentAudit.MinSeverity, newAudit.MinSeverity = "", ""
+		if !reflect.DeepEqual(currentAudit, newAudit) {
+			fields = append(fields, "AuditConfig")
+		}
+	}
+	return fields
+}
+
+// copyConfig returns a copy of config that changes to config don't affect
+func copyConfig(config *Config) Config {
+	copied := *config
+	if config.AuditConfig != nil {
+		auditConfig := *config.AuditConfig
+		copied.AuditConfig = &auditConfig
+	}
+	return copied
+}
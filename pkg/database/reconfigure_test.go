@@ -0,0 +1,93 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/audit"
+)
+
+// bufferPoolCapacity returns the capacity of the database's buffer pool
+func bufferPoolCapacity(db *Database) int {
+	storageStats := db.Stats()["storage_stats"].(map[string]interface{})
+	return storageStats["buffer_pool"].(map[string]interface{})["capacity"].(int)
+}
+
+func TestReconfigure(t *testing.T) {
+	var auditOutput bytes.Buffer
+	config := DefaultConfig(t.TempDir())
+	config.AuditConfig = &audit.Config{Enabled: true, OutputWriter: &auditOutput, Format: "json", MinSeverity: audit.SeverityInfo}
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	updated := *config
+	updated.BufferPoolSize = 50
+	updated.Durability = DurabilityWAL
+	updated.AuditConfig = &audit.Config{Enabled: true, OutputWriter: &auditOutput, Format: "json", MinSeverity: audit.SeverityError}
+	if err := db.Reconfigure(&updated); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+	if capacity := bufferPoolCapacity(db); capacity != 50 {
+		t.Errorf("Expected a buffer pool of 50 pages, got %d", capacity)
+	}
+	if db.Durability() != DurabilityWAL {
+		t.Errorf("Expected durability wal, got %s", db.Durability())
+	}
+
+	// Successful writes are info events, below the new audit level
+	db.Collection("users").InsertOne(map[string]interface{}{"name": "Alice"})
+	if auditOutput.Len() > 0 {
+		t.Errorf("Expected no audit events below error, got %s", auditOutput.String())
+	}
+
+	// Invalid values are rejected
+	invalid := updated
+	invalid.BufferPoolSize = 0
+	if err := db.Reconfigure(&invalid); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a zero buffer pool to be rejected, got %v", err)
+	}
+	invalid = updated
+	invalid.Durability = "eventually"
+	if err := db.Reconfigure(&invalid); err == nil {
+		t.Error("Expected an unknown durability to be rejected")
+	}
+}
+
+func TestReconfigureRestartRequired(t *testing.T) {
+	config := DefaultConfig(t.TempDir())
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	updated := *config
+	updated.BufferPoolSize = 10
+	updated.PageSize = 8192
+	updated.BlobThreshold = 1024
+	err = db.Reconfigure(&updated)
+	if !errors.Is(err, ErrRestartRequired) {
+		t.Fatalf("Expected ErrRestartRequired, got %v", err)
+	}
+	var restartErr *RestartRequiredError
+	if !errors.As(err, &restartErr) || !reflect.DeepEqual(restartErr.Fields, []string{"PageSize", "BlobThreshold"}) {
+		t.Errorf("Expected PageSize and BlobThreshold to need a restart, got %v", err)
+	}
+
+	// Nothing was applied
+	if capacity := bufferPoolCapacity(db); capacity != config.BufferPoolSize {
+		t.Errorf("Expected the buffer pool left at %d pages, got %d", config.BufferPoolSize, capacity)
+	}
+
+	// Auditing can't be turned on at runtime
+	updated = *config
+	updated.AuditConfig = audit.DefaultConfig()
+	if err := db.Reconfigure(&updated); !errors.Is(err, ErrRestartRequired) {
+		t.Errorf("Expected enabling auditing to need a restart, got %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+// ReplaceOptions holds options for ReplaceOne
+type ReplaceOptions struct {
+	Upsert bool // Insert the replacement when no document matches
+}
+
+// ReplaceOne replaces the first document matching the filter with the
+// replacement, keeping its _id. Unlike UpdateOne, fields missing from the
+// replacement are removed. The replacement may not hold update operators
+// or a different _id; either fails with ErrInvalidReplacement. With Upsert
+// set the replacement is inserted when no document matches, taking its _id
+// from the filter if it has none.
+func (c *Collection) ReplaceOne(filter map[string]interface{}, replacement map[string]interface{}, opts *ReplaceOptions) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if err := checkReplacement(replacement); err != nil {
+		return err
+	}
+	if opts == nil {
+		opts = &ReplaceOptions{}
+	}
+	if c.hasHooks() {
+		return c.replaceOneWithHooks(filter, replacement, opts)
+	}
+	start := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Cold documents move to the hot tier before they change
+	if _, err := c.thawMatching(filter); err != nil {
+		return err
+	}
+
+	doc, err := c.findOneInternal(filter)
+	if err == ErrDocumentNotFound && opts.Upsert {
+		d := upsertReplacement(filter, replacement)
+		if err := c.checkQuota(quotaChange{doc: d}); err != nil {
+			return err
+		}
+		_, err = c.insertDocument(d)
+		return err
+	}
+	if err == nil {
+		err = c.replaceFound(doc, replacement)
+	}
+
+	if c.auditLogger != nil {
+		count := 0
+		if err == nil {
+			count = 1
+		}
+		c.auditLogger.LogUpdate(c.name, c.database, "", err == nil, count, time.Since(start), filter, replacement, err)
+	}
+	return err
+}
+
+// replaceFound stores the replacement in place of doc
+// Must be called with c.mu held
+func (c *Collection) replaceFound(doc *document.Document, replacement map[string]interface{}) error {
+	updated, err := replacementFor(doc, replacement)
+	if err != nil {
+		return err
+	}
+	c.stampSchema(updated)
+	if err := c.checkDocument(updated); err != nil {
+		return err
+	}
+	idVal, _ := doc.Get("_id")
+	id := fmt.Sprintf("%v", idVal)
+	if err := c.checkQuota(c.quotaChange(id, updated)); err != nil {
+		return err
+	}
+	return c.replaceDocument(id, doc, updated)
+}
+
+// replaceOneWithHooks replaces a document matching the filter, running its
+// update hooks, or inserts the replacement running the insert hooks
+func (c *Collection) replaceOneWithHooks(filter map[string]interface{}, replacement map[string]interface{}, opts *ReplaceOptions) error {
+	start := time.Now()
+	if err := c.thaw(filter); err != nil {
+		return err
+	}
+	c.mu.RLock()
+	doc, err := c.findOneInternal(filter)
+	c.mu.RUnlock()
+	if err == ErrDocumentNotFound && opts.Upsert {
+		_, err = c.InsertOne(upsertReplacement(filter, replacement).ToMap())
+		return err
+	}
+	if err == nil {
+		var updated *document.Document
+		if updated, err = replacementFor(doc, replacement); err == nil {
+			c.stampSchema(updated)
+			err = c.updateDocumentWithHooks(doc, replacementUpdate(doc, updated))
+		}
+	}
+
+	if c.auditLogger != nil {
+		count := 0
+		if err == nil {
+			count = 1
+		}
+		c.auditLogger.LogUpdate(c.name, c.database, "", err == nil, count, time.Since(start), filter, replacement, err)
+	}
+	return err
+}
+
+// checkReplacement rejects replacement documents holding update operators
+func checkReplacement(replacement map[string]interface{}) error {
+	for key := range replacement {
+		if strings.HasPrefix(key, "$") {
+			return fmt.Errorf("%w: %s is an update operator, use UpdateOne", ErrInvalidReplacement, key)
+		}
+	}
+	return nil
+}
+
+// replacementFor builds the document that replaces doc, which keeps the _id
+// of doc
+func replacementFor(doc *document.Document, replacement map[string]interface{}) (*document.Document, error) {
+	idVal, _ := doc.Get("_id")
+	if newID, ok := replacement["_id"]; ok && fmt.Sprintf("%v", newID) != fmt.Sprintf("%v", idVal) {
+		return nil, fmt.Errorf("%w: _id may not change from %v to %v", ErrInvalidReplacement, idVal, newID)
+	}
+
+	updated := document.NewDocumentFromMap(replacement)
+	updated.Set("_id", idVal)
+	return updated, nil
+}
+
+// replacementUpdate returns the update that turns old into updated: it sets
+// every field of updated and unsets the fields only old has
+func replacementUpdate(old, updated *document.Document) map[string]interface{} {
+	fields := updated.ToMap()
+	update := map[string]interface{}{"$set": withoutID(fields)}
+
+	unset := make(map[string]interface{})
+	for field := range old.ToMap() {
+		if _, kept := fields[field]; !kept {
+			unset[field] = ""
+		}
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update
+}
+
+// upsertReplacement builds the document an upserting ReplaceOne inserts: the
+// replacement, with the _id of the filter when it names one
+func upsertReplacement(filter map[string]interface{}, replacement map[string]interface{}) *document.Document {
+	d := document.NewDocumentFromMap(replacement)
+	if _, ok := replacement["_id"]; !ok {
+		if id, ok := filter["_id"]; ok {
+			if cond, isMap := id.(map[string]interface{}); !isMap || !isOperatorMap(cond) {
+				d.Set("_id", id)
+			}
+		}
+	}
+	return d
+}
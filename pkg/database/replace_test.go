@@ -0,0 +1,165 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReplaceOne(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("users")
+	if err := coll.CreateIndex("email", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	coll.InsertOne(map[string]interface{}{"_id": "u1", "name": "Alice", "email": "alice@example.com", "age": int64(30)})
+	coll.InsertOne(map[string]interface{}{"_id": "u2", "name": "Bob", "email": "bob@example.com"})
+
+	// FindOne, modify, ReplaceOne
+	doc, err := coll.FindOne(map[string]interface{}{"name": "Alice"})
+	if err != nil {
+		t.Fatalf("FindOne failed: %v", err)
+	}
+	replacement := doc.ToMap()
+	delete(replacement, "age")
+	replacement["email"] = "alice@work.example.com"
+	if err := coll.ReplaceOne(map[string]interface{}{"name": "Alice"}, replacement, nil); err != nil {
+		t.Fatalf("ReplaceOne failed: %v", err)
+	}
+
+	doc, err = coll.FindOne(map[string]interface{}{"_id": "u1"})
+	if err != nil {
+		t.Fatalf("FindOne after replace failed: %v", err)
+	}
+	if _, exists := doc.Get("age"); exists {
+		t.Error("Expected age to be removed by the replacement")
+	}
+	if email, _ := doc.Get("email"); email != "alice@work.example.com" {
+		t.Errorf("Expected new email, got %v", email)
+	}
+
+	// The index follows the replacement
+	if _, err := coll.FindOne(map[string]interface{}{"email": "alice@example.com"}); err != ErrDocumentNotFound {
+		t.Errorf("Expected old email to be gone from the index, got %v", err)
+	}
+
+	// A replacement without _id keeps the document's _id
+	if err := coll.ReplaceOne(map[string]interface{}{"_id": "u2"}, map[string]interface{}{"name": "Robert"}, nil); err != nil {
+		t.Fatalf("ReplaceOne without _id failed: %v", err)
+	}
+	doc, err = coll.FindOne(map[string]interface{}{"_id": "u2"})
+	if err != nil {
+		t.Fatalf("Expected u2 to keep its _id: %v", err)
+	}
+	if name, _ := doc.Get("name"); name != "Robert" {
+		t.Errorf("Expected name Robert, got %v", name)
+	}
+	if _, exists := doc.Get("email"); exists {
+		t.Error("Expected email to be removed by the replacement")
+	}
+
+	// No match without upsert
+	err = coll.ReplaceOne(map[string]interface{}{"_id": "missing"}, map[string]interface{}{"name": "X"}, nil)
+	if err != ErrDocumentNotFound {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+
+	// Unique indexes are enforced
+	err = coll.ReplaceOne(map[string]interface{}{"_id": "u2"}, map[string]interface{}{"email": "alice@work.example.com"}, nil)
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("Expected ErrDuplicateKey, got %v", err)
+	}
+}
+
+func TestReplaceOneRejectsInvalidReplacement(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("users")
+	coll.InsertOne(map[string]interface{}{"_id": "u1", "name": "Alice"})
+
+	err = coll.ReplaceOne(map[string]interface{}{"_id": "u1"}, map[string]interface{}{"$set": map[string]interface{}{"name": "Bob"}}, nil)
+	if !errors.Is(err, ErrInvalidReplacement) {
+		t.Errorf("Expected ErrInvalidReplacement for update operators, got %v", err)
+	}
+
+	err = coll.ReplaceOne(map[string]interface{}{"_id": "u1"}, map[string]interface{}{"_id": "u9", "name": "Bob"}, nil)
+	if !errors.Is(err, ErrInvalidReplacement) {
+		t.Errorf("Expected ErrInvalidReplacement for a changed _id, got %v", err)
+	}
+
+	doc, _ := coll.FindOne(map[string]interface{}{"_id": "u1"})
+	if name, _ := doc.Get("name"); name != "Alice" {
+		t.Errorf("Expected document to be unchanged, got name %v", name)
+	}
+}
+
+func TestReplaceOneUpsert(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("settings")
+	opts := &ReplaceOptions{Upsert: true}
+	if err := coll.ReplaceOne(map[string]interface{}{"_id": "theme"}, map[string]interface{}{"value": "dark"}, opts); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	doc, err := coll.FindOne(map[string]interface{}{"_id": "theme"})
+	if err != nil {
+		t.Fatalf("Expected upserted document with the filter's _id: %v", err)
+	}
+	if value, _ := doc.Get("value"); value != "dark" {
+		t.Errorf("Expected value dark, got %v", value)
+	}
+
+	// A second upsert replaces the document instead of inserting another
+	if err := coll.ReplaceOne(map[string]interface{}{"_id": "theme"}, map[string]interface{}{"value": "light"}, opts); err != nil {
+		t.Fatalf("Second upsert failed: %v", err)
+	}
+	count, _ := coll.Count(nil)
+	if count != 1 {
+		t.Errorf("Expected 1 document, got %d", count)
+	}
+}
+
+func TestReplaceOneWithHooks(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("users")
+	coll.InsertOne(map[string]interface{}{"_id": "u1", "name": "Alice", "age": int64(30)})
+
+	var previous, updated map[string]interface{}
+	coll.RegisterHook(HookAfterUpdate, func(ctx *HookContext) error {
+		previous = ctx.Previous.ToMap()
+		updated = ctx.Document.ToMap()
+		return nil
+	})
+
+	if err := coll.ReplaceOne(map[string]interface{}{"_id": "u1"}, map[string]interface{}{"name": "Alicia"}, nil); err != nil {
+		t.Fatalf("ReplaceOne failed: %v", err)
+	}
+	if previous["age"] != int64(30) {
+		t.Errorf("Expected hook to see the previous document, got %v", previous)
+	}
+	if _, exists := updated["age"]; exists || updated["name"] != "Alicia" {
+		t.Errorf("Expected hook to see the replacement, got %v", updated)
+	}
+
+	doc, _ := coll.FindOne(map[string]interface{}{"_id": "u1"})
+	if _, exists := doc.Get("age"); exists {
+		t.Error("Expected age to be removed by the replacement")
+	}
+}
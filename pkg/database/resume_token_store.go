@@ -0,0 +1,78 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/changestream"
+)
+
+// ResumeTokenStore checkpoints the position of named change stream
+// consumers in a collection, one document per consumer keyed by its name.
+// A consumer that restarts loads its token and passes it as
+// ChangeStreamOptions.ResumeAfter to continue after the last event it saved.
+// Tokens are as durable as the collection's documents; SaveResumeToken
+// writes them to any other storage, such as a file.
+type ResumeTokenStore struct {
+	coll *Collection
+}
+
+// NewResumeTokenStore returns a store keeping resume tokens in coll
+func NewResumeTokenStore(coll *Collection) *ResumeTokenStore {
+	return &ResumeTokenStore{coll: coll}
+}
+
+// Save records token as the position of consumer, replacing the previous one
+func (s *ResumeTokenStore) Save(consumer string, token changestream.ResumeToken) error {
+	var buf bytes.Buffer
+	if err := changestream.WriteResumeToken(&buf, token); err != nil {
+		return err
+	}
+	err := s.coll.ReplaceOne(
+		map[string]interface{}{"_id": consumer},
+		map[string]interface{}{"token": buf.String(), "updatedAt": time.Now()},
+		&ReplaceOptions{Upsert: true},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save resume token of %s: %w", consumer, err)
+	}
+	return nil
+}
+
+// SaveStream records the position after the last event cs returned from
+// Next or TryNext as the position of consumer
+func (s *ResumeTokenStore) SaveStream(consumer string, cs *changestream.ChangeStream) error {
+	return s.Save(consumer, cs.AcknowledgedToken())
+}
+
+// Load returns the saved position of consumer, or nil if it has none
+func (s *ResumeTokenStore) Load(consumer string) (*changestream.ResumeToken, error) {
+	doc, err := s.coll.FindOne(map[string]interface{}{"_id": consumer})
+	if errors.Is(err, ErrDocumentNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	token, ok := doc.Get("token")
+	if !ok {
+		return nil, fmt.Errorf("resume token of %s is missing", consumer)
+	}
+	tokenString, ok := token.(string)
+	if !ok {
+		return nil, fmt.Errorf("resume token of %s is not a string (%T)", consumer, token)
+	}
+	return changestream.LoadResumeToken(strings.NewReader(tokenString))
+}
+
+// Delete removes the saved position of consumer, if any
+func (s *ResumeTokenStore) Delete(consumer string) error {
+	err := s.coll.DeleteOne(map[string]interface{}{"_id": consumer})
+	if errors.Is(err, ErrDocumentNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,317 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/mnohosten/laura-db/pkg/aggregation"
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+// SchemaVersionField holds the schema version of a document. Documents
+// without it are at version 0.
+const SchemaVersionField = "_schema"
+
+// SchemaTransform upgrades a document from one schema version to the next.
+// It may change any field but _id; SchemaVersionField is set to the next
+// version after it returns.
+type SchemaTransform func(doc *document.Document) error
+
+// Schema versions the documents of a collection. Documents older than
+// Version are upgraded lazily: reads return them with the transforms up to
+// Version applied, so a schema change needs no migration of the whole
+// collection up front.
+//
+// Filters, sorts and index scans select documents as they are stored; only
+// the documents a read returns are upgraded, before any projection. A query
+// on a field the transforms change therefore misses documents not upgraded
+// yet, and so does an index on that field, which holds the stored values.
+// Indexes need no rebuild: persisted upgrades are written like updates and
+// move their index entries. To query such a field, upgrade every document
+// first with MigrateSchema.
+type Schema struct {
+	// Version is the current schema version. Inserts and replacements that
+	// don't set SchemaVersionField are stamped with it.
+	Version int64
+
+	// Transforms upgrade documents from the version they are keyed by to
+	// the next. They must cover a contiguous range of versions ending at
+	// Version-1, starting at the oldest version still stored.
+	Transforms map[int64]SchemaTransform
+
+	// Persist writes documents back once a read upgraded them, so each is
+	// upgraded only once. The write emits an update event but runs no hooks.
+	Persist bool
+}
+
+// SetSchema sets the schema of the collection's documents, or removes it if
+// schema is nil. Like hooks, the schema is kept in memory and must be set
+// again after the database is reopened.
+func (c *Collection) SetSchema(schema *Schema) error {
+	if schema == nil {
+		c.schema.Store(nil)
+		c.queryCache.Clear()
+		return nil
+	}
+	if schema.Version < 0 {
+		return fmt.Errorf("invalid schema version %d", schema.Version)
+	}
+
+	// Transforms are copied so that later changes to the map don't race
+	// with reads
+	s := &Schema{Version: schema.Version, Persist: schema.Persist, Transforms: make(map[int64]SchemaTransform, len(schema.Transforms))}
+	for version, transform := range schema.Transforms {
+		if version < 0 || version >= schema.Version {
+			return fmt.Errorf("schema transform from version %d is outside versions 0 to %d", version, schema.Version-1)
+		}
+		if transform == nil {
+			return fmt.Errorf("schema transform from version %d is nil", version)
+		}
+		s.Transforms[version] = transform
+	}
+	for version := schema.Version - int64(len(s.Transforms)); version < schema.Version; version++ {
+		if _, ok := s.Transforms[version]; !ok {
+			return fmt.Errorf("missing schema transform from version %d", version)
+		}
+	}
+
+	c.schema.Store(s)
+	c.queryCache.Clear()
+	return nil
+}
+
+// Schema returns the schema of the collection's documents, nil if it has
+// none
+func (c *Collection) Schema() *Schema {
+	return c.schema.Load()
+}
+
+// schemaVersion returns the schema version of a document
+func schemaVersion(doc *document.Document) (int64, error) {
+	value, ok := doc.Get(SchemaVersionField)
+	if !ok {
+		return 0, nil
+	}
+	version, ok := toInt64(value)
+	if !ok {
+		return 0, kindOf(ErrValidation, "invalid schema version %v", value)
+	}
+	return version, nil
+}
+
+// upgrade returns doc upgraded to the current version and whether it had to
+// be. doc itself is never modified.
+func (s *Schema) upgrade(doc *document.Document) (*document.Document, bool, error) {
+	version, err := schemaVersion(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	if version >= s.Version {
+		return doc, false, nil
+	}
+
+	idVal, _ := doc.Get("_id")
+	upgraded := doc.Clone()
+	for ; version < s.Version; version++ {
+		transform, ok := s.Transforms[version]
+		if !ok {
+			return nil, false, fmt.Errorf("failed to upgrade document %v: no schema transform from version %d", idVal, version)
+		}
+		if err := transform(upgraded); err != nil {
+			return nil, false, fmt.Errorf("failed to upgrade document %v from schema version %d: %w", idVal, version, err)
+		}
+		upgraded.Set(SchemaVersionField, version+1)
+	}
+	upgraded.Set("_id", idVal)
+	return upgraded, true, nil
+}
+
+// stampSchema sets the current schema version on a document being written
+// that doesn't set one
+func (c *Collection) stampSchema(d *document.Document) {
+	if schema := c.schema.Load(); schema != nil && !d.Has(SchemaVersionField) {
+		d.Set(SchemaVersionField, schema.Version)
+	}
+}
+
+// upgradeDocument upgrades a document a read returns, queueing the upgrade
+// to be persisted if the schema asks for it
+func (c *Collection) upgradeDocument(schema *Schema, doc *document.Document) (*document.Document, error) {
+	upgraded, changed, err := schema.upgrade(doc)
+	if err != nil || !changed {
+		return upgraded, err
+	}
+	if schema.Persist {
+		idVal, _ := doc.Get("_id")
+		c.upgradesMu.Lock()
+		if c.upgrades == nil {
+			c.upgrades = make(map[string]bool)
+		}
+		c.upgrades[fmt.Sprintf("%v", idVal)] = true
+		c.upgradesMu.Unlock()
+	}
+	return upgraded, nil
+}
+
+// readQuery executes the query of a read, returning documents upgraded to
+// the current schema version. The projection applies after the upgrade,
+// since it may drop fields the transforms need.
+// Must be called with c.mu held
+func (c *Collection) readQuery(q *query.Query) ([]*document.Document, error) {
+	schema := c.schema.Load()
+	if schema == nil {
+		return c.executeQuery(q)
+	}
+
+	// Text scores are kept under their internal field for the projection
+	base := query.NewQuery(q.GetFilter()).WithMeta(map[string]string{query.TextScoreField: query.MetaTextScore})
+	if sort := q.GetSort(); len(sort) > 0 {
+		base.WithSort(sort)
+	}
+	if skip := q.GetSkip(); skip > 0 {
+		base.WithSkip(skip)
+	}
+	if limit := q.GetLimit(); limit > 0 {
+		base.WithLimit(limit)
+	}
+	if hint := q.GetHint(); hint != "" {
+		base.WithHint(hint)
+	}
+
+	results, err := c.executeQuery(base)
+	if err != nil {
+		return nil, err
+	}
+	for i, doc := range results {
+		upgraded, err := c.upgradeDocument(schema, doc)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = q.ApplyProjection(upgraded)
+	}
+	return results, nil
+}
+
+// upgradeDocuments upgrades the documents a read returns
+func (c *Collection) upgradeDocuments(docs []*document.Document) ([]*document.Document, error) {
+	schema := c.schema.Load()
+	if schema == nil {
+		return docs, nil
+	}
+	upgraded := make([]*document.Document, len(docs))
+	for i, doc := range docs {
+		var err error
+		if upgraded[i], err = c.upgradeDocument(schema, doc); err != nil {
+			return nil, err
+		}
+	}
+	return upgraded, nil
+}
+
+// upgradeIterator upgrades the documents of an iterator as they are read
+type upgradeIterator struct {
+	coll   *Collection
+	schema *Schema
+	source aggregation.Iterator
+}
+
+func (it *upgradeIterator) Next() (*document.Document, error) {
+	doc, err := it.source.Next()
+	if err != nil {
+		return nil, err
+	}
+	return it.coll.upgradeDocument(it.schema, doc)
+}
+
+func (it *upgradeIterator) Close() error {
+	return it.source.Close()
+}
+
+// upgradingIterator returns source upgrading its documents to the current
+// schema version. Upgrades it persists are written by the next read.
+func (c *Collection) upgradingIterator(source aggregation.Iterator) aggregation.Iterator {
+	schema := c.schema.Load()
+	if schema == nil {
+		return source
+	}
+	return &upgradeIterator{coll: c, schema: schema, source: source}
+}
+
+// persistUpgrades writes back the documents reads upgraded. Each document
+// is upgraded again from what is stored now, so writes since the read are
+// kept. Failures are ignored: the document is upgraded again when it is next
+// read. Documents of the cold tier are not persisted.
+// Must be called without c.mu held
+func (c *Collection) persistUpgrades() {
+	c.upgradesMu.Lock()
+	ids := c.upgrades
+	c.upgrades = nil
+	c.upgradesMu.Unlock()
+
+	schema := c.schema.Load()
+	if len(ids) == 0 || schema == nil || c.checkWritable() != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id := range ids {
+		c.storeUpgrade(schema, id)
+	}
+}
+
+// storeUpgrade upgrades a stored document in place, reporting whether it
+// had to be upgraded
+// Must be called with c.mu held
+func (c *Collection) storeUpgrade(schema *Schema, id string) (bool, error) {
+	if !c.docStore.Exists(id) {
+		return false, nil
+	}
+	doc, err := c.docStore.Get(id)
+	if err != nil {
+		return false, err
+	}
+	upgraded, changed, err := schema.upgrade(doc)
+	if err != nil || !changed {
+		return false, err
+	}
+	if err := c.checkDocumentSize(upgraded); err != nil {
+		return false, err
+	}
+	if err := c.checkQuota(c.quotaChange(id, upgraded)); err != nil {
+		return false, err
+	}
+	if err := c.replaceDocument(id, doc, upgraded); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// MigrateSchema upgrades every stored document older than the current
+// schema version, returning how many it upgraded. Run it before relying on
+// a query or an index over a field the transforms change. Documents of the
+// cold tier are upgraded as they are read.
+func (c *Collection) MigrateSchema() (int, error) {
+	schema := c.schema.Load()
+	if schema == nil {
+		return 0, nil
+	}
+	if err := c.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	upgraded := 0
+	for _, id := range c.docStore.GetAllIDs() {
+		changed, err := c.storeUpgrade(schema, id)
+		if err != nil {
+			return upgraded, err
+		}
+		if changed {
+			upgraded++
+		}
+	}
+	return upgraded, nil
+}
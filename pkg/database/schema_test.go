@@ -0,0 +1,211 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+// userSchema splits name into first and last (version 0 to 1) and renames
+// mail to email (version 1 to 2)
+func userSchema(persist bool) *Schema {
+	return &Schema{
+		Version: 2,
+		Persist: persist,
+		Transforms: map[int64]SchemaTransform{
+			0: func(doc *document.Document) error {
+				name, _ := doc.Get("name")
+				parts := strings.SplitN(fmt.Sprint(name), " ", 2)
+				if len(parts) != 2 {
+					return fmt.Errorf("name %q has no last name", name)
+				}
+				doc.Delete("name")
+				doc.Set("first", parts[0])
+				doc.Set("last", parts[1])
+				return nil
+			},
+			1: func(doc *document.Document) error {
+				if mail, ok := doc.Get("mail"); ok {
+					doc.Delete("mail")
+					doc.Set("email", mail)
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// storedDocument returns a document as it is stored
+func storedDocument(t *testing.T, coll *Collection, id string) *document.Document {
+	t.Helper()
+	doc, err := coll.docStore.Get(id)
+	if err != nil {
+		t.Fatalf("Failed to get document %s: %v", id, err)
+	}
+	return doc
+}
+
+func TestSchemaUpgradeOnRead(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	users.InsertOne(map[string]interface{}{"_id": "u0", "name": "Ada Lovelace", "mail": "ada@example.com"})
+	users.InsertOne(map[string]interface{}{"_id": "u1", "_schema": int64(1), "first": "Alan", "last": "Turing", "mail": "alan@example.com"})
+	if err := users.SetSchema(userSchema(false)); err != nil {
+		t.Fatalf("SetSchema failed: %v", err)
+	}
+
+	doc, err := users.FindOne(map[string]interface{}{"_id": "u0"})
+	if err != nil {
+		t.Fatalf("FindOne failed: %v", err)
+	}
+	if first, _ := doc.Get("first"); first != "Ada" {
+		t.Errorf("Expected first Ada, got %v", first)
+	}
+	if email, _ := doc.Get("email"); email != "ada@example.com" {
+		t.Errorf("Expected email ada@example.com, got %v", email)
+	}
+	if version, _ := doc.Get(SchemaVersionField); version != int64(2) {
+		t.Errorf("Expected schema version 2, got %v", version)
+	}
+
+	// Without Persist the stored document is left as it was
+	if stored := storedDocument(t, users, "u0"); stored.Has("first") || stored.Has(SchemaVersionField) {
+		t.Errorf("Expected the stored document not to be upgraded, got %v", stored.ToMap())
+	}
+
+	// The projection applies to the upgraded document
+	results, err := users.FindWithOptions(map[string]interface{}{}, &QueryOptions{Projection: map[string]bool{"email": true}})
+	if err != nil {
+		t.Fatalf("FindWithOptions failed: %v", err)
+	}
+	for _, doc := range results {
+		if !doc.Has("email") || doc.Has("mail") || doc.Has(SchemaVersionField) {
+			t.Errorf("Expected only email projected, got %v", doc.ToMap())
+		}
+	}
+
+	// Filters see the stored form
+	results, _ = users.Find(map[string]interface{}{"mail": "ada@example.com"})
+	if len(results) != 1 {
+		t.Errorf("Expected a filter on mail to match the stored document, got %d", len(results))
+	}
+
+	// Aggregation pipelines receive upgraded documents
+	agg, err := users.Aggregate([]map[string]interface{}{{"$match": map[string]interface{}{"first": "Ada"}}})
+	if err != nil || len(agg) != 1 {
+		t.Errorf("Expected the pipeline to match the upgraded document, got %v, %v", agg, err)
+	}
+
+	cursor, err := users.FindCursor(map[string]interface{}{}, nil)
+	if err != nil {
+		t.Fatalf("FindCursor failed: %v", err)
+	}
+	for cursor.HasNext() {
+		doc, _ := cursor.Next()
+		if !doc.Has("email") {
+			t.Errorf("Expected the cursor to return upgraded documents, got %v", doc.ToMap())
+		}
+	}
+
+	// New documents are stamped with the current version and not upgraded
+	users.InsertOne(map[string]interface{}{"_id": "u2", "first": "Grace", "last": "Hopper", "email": "grace@example.com"})
+	if version, _ := storedDocument(t, users, "u2").Get(SchemaVersionField); version != int64(2) {
+		t.Errorf("Expected the insert stamped with version 2, got %v", version)
+	}
+
+	// A failing transform fails the read
+	users.InsertOne(map[string]interface{}{"_id": "u3", "_schema": int64(0), "name": "Plato"})
+	if _, err := users.Find(map[string]interface{}{}); err == nil || !strings.Contains(err.Error(), "u3") {
+		t.Errorf("Expected the read of u3 to fail, got %v", err)
+	}
+}
+
+func TestSchemaPersist(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	for i := 0; i < 3; i++ {
+		users.InsertOne(map[string]interface{}{"_id": fmt.Sprintf("u%d", i), "name": fmt.Sprintf("User %d", i), "mail": fmt.Sprintf("u%d@example.com", i)})
+	}
+	if err := users.CreateIndex("email", false); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+	if err := users.SetSchema(userSchema(true)); err != nil {
+		t.Fatalf("SetSchema failed: %v", err)
+	}
+
+	// An index on a transformed field misses documents not upgraded yet
+	if results, _ := users.Find(map[string]interface{}{"email": "u0@example.com"}); len(results) != 0 {
+		t.Errorf("Expected no match before the upgrade, got %d", len(results))
+	}
+
+	// A read persists the upgrade, moving the index entries
+	if _, err := users.FindOne(map[string]interface{}{"_id": "u0"}); err != nil {
+		t.Fatalf("FindOne failed: %v", err)
+	}
+	stored := storedDocument(t, users, "u0")
+	if version, _ := stored.Get(SchemaVersionField); version != int64(2) || stored.Has("mail") {
+		t.Errorf("Expected the stored document upgraded, got %v", stored.ToMap())
+	}
+	results, _ := users.Find(map[string]interface{}{"email": "u0@example.com"})
+	if len(results) != 1 {
+		t.Errorf("Expected the upgraded document found through the index, got %d", len(results))
+	}
+	if explain := users.Explain(map[string]interface{}{"email": "u0@example.com"}); explain["indexName"] != "email_1" {
+		t.Errorf("Expected the query to use email_1, got %v", explain)
+	}
+
+	// MigrateSchema upgrades the rest
+	upgraded, err := users.MigrateSchema()
+	if err != nil {
+		t.Fatalf("MigrateSchema failed: %v", err)
+	}
+	if upgraded != 2 {
+		t.Errorf("Expected 2 documents upgraded, got %d", upgraded)
+	}
+	if upgraded, _ := users.MigrateSchema(); upgraded != 0 {
+		t.Errorf("Expected nothing left to upgrade, got %d", upgraded)
+	}
+	if count, _ := users.Count(map[string]interface{}{"email": map[string]interface{}{"$exists": true}}); count != 3 {
+		t.Errorf("Expected every document to have email, got %d", count)
+	}
+}
+
+func TestSetSchemaValidation(t *testing.T) {
+	coll := NewCollection("test", nil, nil)
+	noop := func(doc *document.Document) error { return nil }
+
+	tests := []struct {
+		name   string
+		schema *Schema
+	}{
+		{"negative version", &Schema{Version: -1}},
+		{"transform at the current version", &Schema{Version: 1, Transforms: map[int64]SchemaTransform{1: noop}}},
+		{"gap", &Schema{Version: 3, Transforms: map[int64]SchemaTransform{0: noop, 2: noop}}},
+		{"nil transform", &Schema{Version: 1, Transforms: map[int64]SchemaTransform{0: nil}}},
+	}
+	for _, tt := range tests {
+		if err := coll.SetSchema(tt.schema); err == nil {
+			t.Errorf("%s: expected SetSchema to fail", tt.name)
+		}
+	}
+
+	// Transforms may start after version 0
+	if err := coll.SetSchema(&Schema{Version: 3, Transforms: map[int64]SchemaTransform{1: noop, 2: noop}}); err != nil {
+		t.Errorf("Expected transforms from version 1 to be accepted, got %v", err)
+	}
+	if err := coll.SetSchema(nil); err != nil || coll.Schema() != nil {
+		t.Errorf("Expected the schema removed, got %v", err)
+	}
+}
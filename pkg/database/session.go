@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/mnohosten/laura-db/pkg/document"
 	"github.com/mnohosten/laura-db/pkg/mvcc"
@@ -50,61 +51,215 @@ func (db *Database) StartSession() *Session {
 	}
 }
 
+// CommittedOperation is a write applied when a session transaction commits
+type CommittedOperation struct {
+	Type       string // "insert", "update" or "delete"
+	Collection string
+	DocumentID interface{}            // _id of the written document
+	Document   map[string]interface{} // Document after the write (nil for deletes)
+}
+
+// CommitListener is called with the net writes of each committed session
+// transaction, in the order they were applied. Operations rolled back to a
+// savepoint are not included. Used to feed the oplog for replication.
+type CommitListener func(txnID mvcc.TxnID, ops []CommittedOperation) error
+
+// AddCommitListener registers a listener for committed session transactions
+func (db *Database) AddCommitListener(listener CommitListener) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.commitListeners = append(db.commitListeners, listener)
+}
+
+// notifyCommit passes committed operations to the registered listeners
+func (db *Database) notifyCommit(txnID mvcc.TxnID, ops []CommittedOperation) error {
+	db.mu.RLock()
+	listeners := db.commitListeners
+	db.mu.RUnlock()
+
+	for _, listener := range listeners {
+		if err := listener(txnID, ops); err != nil {
+			return fmt.Errorf("commit listener failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// PrepareTransaction validates the session's transaction for commit without
+// applying it, the first phase of a two-phase commit. Once prepared, the
+// transaction takes no more operations and CommitTransaction doesn't fail
+// for write conflicts: other transactions writing the same documents fail
+// instead until this one commits or aborts.
+func (s *Session) PrepareTransaction() error {
+	if len(s.operations) > 0 {
+		if err := s.db.checkWritable(); err != nil {
+			return err
+		}
+	}
+	return txnError(s.db.txnMgr.Prepare(s.txn))
+}
+
+// HasWrites reports whether the transaction has pending writes
+func (s *Session) HasWrites() bool {
+	return len(s.operations) > 0
+}
+
 // CommitTransaction commits the session's transaction
 // and applies all operations to the collections
 func (s *Session) CommitTransaction() error {
-	// First, check for write conflicts using MVCC
-	if err := s.db.txnMgr.Commit(s.txn); err != nil {
+	// Writes can't be applied while the database is read-only
+	if len(s.operations) > 0 {
+		if err := s.db.checkWritable(); err != nil {
+			return err
+		}
+	}
+
+	// The collections written to stay locked from the constraint checks to
+	// the end of the apply, so no other write can take a unique index entry
+	// in between. Read snapshots don't start until the whole commit is
+	// applied.
+	s.db.snapshots.commits.RLock()
+	operations := s.netOperations()
+	colls, unlock := s.lockCollections(operations)
+	if err := s.checkCommit(colls, operations); err != nil {
+		unlock()
+		s.db.snapshots.commits.RUnlock()
+		s.db.txnMgr.Abort(s.txn)
 		return err
 	}
 
-	// Apply all operations to the collections
-	for _, op := range s.operations {
-		coll := s.db.Collection(op.collection)
+	// Check for write conflicts using MVCC. Transactions taking the same
+	// unique index entry conflict here as well.
+	if err := s.db.txnMgr.Commit(s.txn); err != nil {
+		unlock()
+		s.db.snapshots.commits.RUnlock()
+		return txnError(err)
+	}
+
+	// Release the index entries of updated and deleted documents first, so
+	// that an entry moving between documents of the transaction is free
+	// when it is taken again
+	previous := make(map[*sessionOperation]*document.Document)
+	for i := range operations {
+		op := &operations[i]
+		if op.opType == "insert" {
+			continue
+		}
+		coll := colls[op.collection]
+		coll.thawID(op.docID)
+		if old, err := coll.docStore.Get(op.docID); err == nil {
+			coll.unindexDocument(op.docID, old)
+			previous[op] = old
+		}
+	}
+
+	// Apply the net effect of the operations to the collections
+	applied := make([]CommittedOperation, 0, len(operations))
+	for i := range operations {
+		op := &operations[i]
+		coll := colls[op.collection]
 
 		switch op.opType {
 		case "insert":
-			// Use the collection's InsertOne method to properly maintain indexes
-			// Convert document back to map for InsertOne
+			// Insert through the collection to properly maintain indexes. Hooks
+			// already ran when the insert joined the transaction.
 			docMap := op.doc.ToMap()
-			if _, err := coll.InsertOne(docMap); err != nil {
-				// If insert fails (e.g., duplicate key), we should handle it
-				// For now, we'll continue since this is already committed in MVCC
-				// A better approach would be to validate before MVCC commit
+			if _, err := coll.insertDocument(document.NewDocumentFromMap(docMap)); err != nil {
+				// checkCommit validated the insert, so this is a storage failure
 				continue
 			}
+			idVal, _ := op.doc.Get("_id")
+			applied = append(applied, CommittedOperation{Type: op.opType, Collection: op.collection, DocumentID: idVal, Document: docMap})
 
 		case "update":
-			// Update the document in the collection
-			coll.mu.Lock()
+			if previous[op] == nil {
+				continue
+			}
 			idVal, _ := op.doc.Get("_id")
-			idStr := fmt.Sprintf("%v", idVal)
-			// Update in document store
-			if err := coll.docStore.Update(idStr, op.doc); err != nil {
-				coll.mu.Unlock()
+			coll.indexDocument(op.docID, op.doc)
+			if err := coll.docStore.Update(op.docID, op.doc); err != nil {
+				coll.unindexDocument(op.docID, op.doc)
+				coll.indexDocument(op.docID, previous[op])
 				// Continue even on error since this is already committed in MVCC
 				continue
 			}
-			coll.mu.Unlock()
+			docMap := op.doc.ToMap()
+			coll.logUpdate(idVal, map[string]interface{}{"$set": withoutID(docMap)})
+			coll.queryCache.Clear()
+			applied = append(applied, CommittedOperation{Type: op.opType, Collection: op.collection, DocumentID: idVal, Document: docMap})
 
 		case "delete":
-			// Delete the document from the collection
-			coll.mu.Lock()
 			if err := coll.docStore.Delete(op.docID); err != nil {
-				coll.mu.Unlock()
 				// Continue even on error since this is already committed in MVCC
 				continue
 			}
-			coll.mu.Unlock()
+			var idVal interface{} = op.docID
+			if op.doc != nil {
+				idVal, _ = op.doc.Get("_id")
+			}
+			coll.logDelete(idVal)
+			coll.queryCache.Clear()
+			applied = append(applied, CommittedOperation{Type: op.opType, Collection: op.collection, DocumentID: idVal})
 		}
 	}
+	unlock()
+	s.db.snapshots.commits.RUnlock()
 
-	return nil
+	// Make the commit durable; concurrent sessions share the WAL fsync
+	if _, err := s.db.storage.LogCommit(uint64(s.txn.ID)); err != nil {
+		return err
+	}
+
+	return s.db.notifyCommit(s.txn.ID, applied)
+}
+
+// netOperations collapses the pending operations into one net write per
+// document, ordered by each document's first write. An insert followed by
+// updates becomes a single insert of the final document, a document both
+// inserted and deleted disappears, and repeated updates become one update.
+// Operations rolled back to a savepoint are already gone from s.operations,
+// so they are never applied or passed on to the oplog.
+func (s *Session) netOperations() []sessionOperation {
+	type docKey struct {
+		collection string
+		docID      string
+	}
+
+	order := make([]docKey, 0)
+	existed := make(map[docKey]bool)
+	final := make(map[docKey]sessionOperation)
+
+	for _, op := range s.operations {
+		key := docKey{op.collection, op.docID}
+		if _, seen := final[key]; !seen {
+			order = append(order, key)
+			existed[key] = op.opType != "insert"
+		}
+		final[key] = op
+	}
+
+	net := make([]sessionOperation, 0, len(order))
+	for _, key := range order {
+		op := final[key]
+		switch {
+		case op.opType == "delete" && !existed[key]:
+			// Created and removed within the transaction
+			continue
+		case op.opType == "delete":
+		case existed[key]:
+			op.opType = "update"
+		default:
+			op.opType = "insert"
+		}
+		net = append(net, op)
+	}
+
+	return net
 }
 
 // AbortTransaction aborts the session's transaction
 func (s *Session) AbortTransaction() error {
-	return s.db.txnMgr.Abort(s.txn)
+	return txnError(s.db.txnMgr.Abort(s.txn))
 }
 
 // Transaction returns the underlying MVCC transaction
@@ -114,6 +269,13 @@ func (s *Session) Transaction() *mvcc.Transaction {
 
 // InsertOne inserts a document within the transaction
 func (s *Session) InsertOne(collName string, doc map[string]interface{}) (string, error) {
+	if err := s.db.checkWritable(); err != nil {
+		return "", err
+	}
+	if err := s.db.txnMgr.CheckLimits(s.txn); err != nil {
+		return "", err
+	}
+
 	// Create document
 	d := document.NewDocumentFromMap(doc)
 
@@ -134,14 +296,25 @@ func (s *Session) InsertOne(collName string, doc map[string]interface{}) (string
 	coll.mu.RUnlock()
 
 	if exists {
-		return "", fmt.Errorf("document with _id %s already exists", id)
+		return "", coll.duplicateIDError(id)
 	}
 
+	idVal, _ := d.Get("_id")
+	hookCtx := &HookContext{Type: HookBeforeInsert, Collection: collName, DocumentID: idVal, Document: d, Session: s}
+	if err := coll.runHooks(hookCtx); err != nil {
+		return "", err
+	}
+
+	coll.stampSchema(d)
+
 	// Write to transaction's write set for conflict detection
 	key := fmt.Sprintf("%s:%s", collName, id)
 	if err := s.db.txnMgr.Write(s.txn, key, d); err != nil {
 		return "", err
 	}
+	if err := s.reserveUniqueKeys(coll, id, d); err != nil {
+		return "", err
+	}
 
 	// Add operation to be applied on commit
 	s.operations = append(s.operations, sessionOperation{
@@ -153,11 +326,20 @@ func (s *Session) InsertOne(collName string, doc map[string]interface{}) (string
 
 	s.collections[collName] = true
 
+	hookCtx.Type = HookAfterInsert
+	if err := coll.runHooks(hookCtx); err != nil {
+		return "", err
+	}
+
 	return id, nil
 }
 
 // FindOne finds a document within the transaction
 func (s *Session) FindOne(collName string, filter map[string]interface{}) (*document.Document, error) {
+	if err := s.db.txnMgr.CheckLimits(s.txn); err != nil {
+		return nil, err
+	}
+
 	coll := s.db.Collection(collName)
 
 	// Convert string _id to ObjectID if needed for collection lookup
@@ -227,12 +409,75 @@ func (s *Session) FindOne(collName string, filter map[string]interface{}) (*docu
 
 // UpdateOne updates a document within the transaction
 func (s *Session) UpdateOne(collName string, filter map[string]interface{}, update map[string]interface{}) error {
+	if err := s.db.checkWritable(); err != nil {
+		return err
+	}
+
 	// Find the document to update
 	doc, err := s.FindOne(collName, filter)
 	if err != nil {
 		return err
 	}
 
+	return s.updateDocument(collName, doc, update)
+}
+
+// UpdateWithOptions updates documents within the transaction. With Multi set
+// every matching document is updated; with Upsert set a document built from
+// the filter's equality fields and the update is inserted when none match.
+// All changes join the transaction's write set for conflict detection.
+func (s *Session) UpdateWithOptions(collName string, filter map[string]interface{}, update map[string]interface{}, options *UpdateOptions) (*UpdateResult, error) {
+	if err := s.db.checkWritable(); err != nil {
+		return nil, err
+	}
+	if options == nil {
+		options = &UpdateOptions{}
+	}
+
+	var docs []*document.Document
+	if options.Multi {
+		matched, err := s.findAll(collName, filter)
+		if err != nil {
+			return nil, err
+		}
+		docs = matched
+	} else {
+		doc, err := s.FindOne(collName, filter)
+		if err != nil && err != ErrDocumentNotFound {
+			return nil, err
+		}
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+
+	result := &UpdateResult{MatchedCount: len(docs)}
+
+	if len(docs) == 0 {
+		if !options.Upsert {
+			return result, nil
+		}
+		id, err := s.upsert(collName, filter, update)
+		if err != nil {
+			return nil, err
+		}
+		result.UpsertedID = id
+		return result, nil
+	}
+
+	for _, doc := range docs {
+		if err := s.updateDocument(collName, doc, update); err != nil {
+			return nil, err
+		}
+	}
+	// The transaction writes every matched document
+	result.ModifiedCount = len(docs)
+
+	return result, nil
+}
+
+// updateDocument records an update of doc in the transaction
+func (s *Session) updateDocument(collName string, doc *document.Document, update map[string]interface{}) error {
 	// Get the document ID
 	idVal, exists := doc.Get("_id")
 	if !exists {
@@ -242,23 +487,22 @@ func (s *Session) UpdateOne(collName string, filter map[string]interface{}, upda
 
 	// Create a copy of the document for modification
 	docCopy := document.NewDocumentFromMap(doc.ToMap())
-
-	// Apply updates (simplified - full implementation would use applyUpdate from collection.go)
-	if setOps, ok := update["$set"].(map[string]interface{}); ok {
-		for field, value := range setOps {
-			docCopy.Set(field, value)
-		}
+	if err := applySessionUpdate(docCopy, update); err != nil {
+		return err
 	}
-	if incOps, ok := update["$inc"].(map[string]interface{}); ok {
-		for field, value := range incOps {
-			if currentVal, exists := docCopy.Get(field); exists {
-				if currentInt, ok := currentVal.(int64); ok {
-					if incInt, ok := value.(int64); ok {
-						docCopy.Set(field, currentInt+incInt)
-					}
-				}
-			}
-		}
+
+	coll := s.db.Collection(collName)
+	hookCtx := &HookContext{
+		Type:       HookBeforeUpdate,
+		Collection: collName,
+		DocumentID: idVal,
+		Document:   docCopy,
+		Previous:   doc,
+		Update:     update,
+		Session:    s,
+	}
+	if err := coll.runHooks(hookCtx); err != nil {
+		return err
 	}
 
 	// Write the updated document to the transaction for conflict detection
@@ -266,6 +510,9 @@ func (s *Session) UpdateOne(collName string, filter map[string]interface{}, upda
 	if err := s.db.txnMgr.Write(s.txn, key, docCopy); err != nil {
 		return err
 	}
+	if err := s.reserveUniqueKeys(coll, id, docCopy); err != nil {
+		return err
+	}
 
 	// Add operation to be applied on commit
 	s.operations = append(s.operations, sessionOperation{
@@ -277,11 +524,171 @@ func (s *Session) UpdateOne(collName string, filter map[string]interface{}, upda
 
 	s.collections[collName] = true
 
+	hookCtx.Type = HookAfterUpdate
+	return coll.runHooks(hookCtx)
+}
+
+// upsert inserts a document built from the filter's equality fields with the
+// update (including $setOnInsert) applied
+func (s *Session) upsert(collName string, filter map[string]interface{}, update map[string]interface{}) (string, error) {
+	filterFields := make(map[string]interface{})
+	for field, value := range filter {
+		if strings.HasPrefix(field, "$") {
+			continue
+		}
+		if cond, ok := value.(map[string]interface{}); ok && isOperatorMap(cond) {
+			continue
+		}
+		filterFields[field] = value
+	}
+
+	d := document.NewDocument()
+	for field, value := range filterFields {
+		if err := d.SetNested(field, value); err != nil {
+			return "", err
+		}
+	}
+	if err := applySessionUpdate(d, update); err != nil {
+		return "", err
+	}
+	if setOnInsert, ok := update["$setOnInsert"].(map[string]interface{}); ok {
+		for field, value := range setOnInsert {
+			if err := d.SetNested(field, value); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return s.InsertOne(collName, d.ToMap())
+}
+
+// isOperatorMap reports whether a filter value is an operator expression
+// such as {"$gt": 5} rather than an embedded document
+func isOperatorMap(m map[string]interface{}) bool {
+	for key := range m {
+		if strings.HasPrefix(key, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// applySessionUpdate applies $set and $inc to a document. Fields may be
+// dotted paths into embedded documents and arrays.
+// (simplified - full implementation would use applyUpdate from collection.go)
+func applySessionUpdate(doc *document.Document, update map[string]interface{}) error {
+	if setOps, ok := update["$set"].(map[string]interface{}); ok {
+		for field, value := range setOps {
+			if err := doc.SetNested(field, value); err != nil {
+				return err
+			}
+		}
+	}
+	if incOps, ok := update["$inc"].(map[string]interface{}); ok {
+		for field, value := range incOps {
+			if currentVal, exists := doc.Get(field); exists {
+				if currentInt, ok := currentVal.(int64); ok {
+					if incInt, ok := value.(int64); ok {
+						doc.SetNested(field, currentInt+incInt)
+					}
+				}
+			} else if incInt, ok := value.(int64); ok {
+				// Missing fields start from zero
+				if err := doc.SetNested(field, incInt); err != nil {
+					return err
+				}
+			}
+		}
+	}
 	return nil
 }
 
+// findAll returns every document matching the filter as seen by the
+// transaction: its own pending writes first, then its snapshot of documents
+// already read, then the committed collection data
+func (s *Session) findAll(collName string, filter map[string]interface{}) ([]*document.Document, error) {
+	if err := s.db.txnMgr.CheckLimits(s.txn); err != nil {
+		return nil, err
+	}
+
+	normalizedFilter := normalizeFilter(filter)
+
+	// Latest state of documents written by this transaction (nil if deleted)
+	pending := make(map[string]*document.Document)
+	pendingOrder := make([]string, 0)
+	for _, op := range s.operations {
+		if op.collection != collName {
+			continue
+		}
+		if _, seen := pending[op.docID]; !seen {
+			pendingOrder = append(pendingOrder, op.docID)
+		}
+		if op.opType == "delete" {
+			pending[op.docID] = nil
+		} else {
+			pending[op.docID] = op.doc
+		}
+	}
+
+	collSnapshot := s.snapshotDocs[collName]
+	if collSnapshot == nil {
+		collSnapshot = make(map[string]*document.Document)
+		s.snapshotDocs[collName] = collSnapshot
+	}
+
+	committed, err := s.db.Collection(collName).Find(normalizedFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*document.Document, 0, len(committed))
+	seen := make(map[string]bool)
+
+	for _, doc := range committed {
+		idVal, _ := doc.Get("_id")
+		docID := fmt.Sprintf("%v", idVal)
+		seen[docID] = true
+
+		if _, touched := pending[docID]; touched {
+			continue
+		}
+		if cachedDoc, cached := collSnapshot[docID]; cached {
+			if matchesFilter(cachedDoc, normalizedFilter) {
+				results = append(results, cachedDoc)
+			}
+			continue
+		}
+
+		docCopy := document.NewDocumentFromMap(doc.ToMap())
+		collSnapshot[docID] = docCopy
+		results = append(results, docCopy)
+	}
+
+	// Snapshot documents that changed in the collection since they were read
+	for docID, cachedDoc := range collSnapshot {
+		if _, touched := pending[docID]; touched || seen[docID] {
+			continue
+		}
+		if matchesFilter(cachedDoc, normalizedFilter) {
+			results = append(results, cachedDoc)
+		}
+	}
+
+	for _, docID := range pendingOrder {
+		if doc := pending[docID]; doc != nil && matchesFilter(doc, normalizedFilter) {
+			results = append(results, doc)
+		}
+	}
+
+	return results, nil
+}
+
 // DeleteOne deletes a document within the transaction
 func (s *Session) DeleteOne(collName string, filter map[string]interface{}) error {
+	if err := s.db.checkWritable(); err != nil {
+		return err
+	}
+
 	// Find the document to delete
 	doc, err := s.FindOne(collName, filter)
 	if err != nil {
@@ -295,6 +702,12 @@ func (s *Session) DeleteOne(collName string, filter map[string]interface{}) erro
 	}
 	id := fmt.Sprintf("%v", idVal)
 
+	coll := s.db.Collection(collName)
+	hookCtx := &HookContext{Type: HookBeforeDelete, Collection: collName, DocumentID: idVal, Document: doc, Session: s}
+	if err := coll.runHooks(hookCtx); err != nil {
+		return err
+	}
+
 	// Mark as deleted in the transaction for conflict detection
 	key := fmt.Sprintf("%s:%s", collName, id)
 	if err := s.db.txnMgr.Delete(s.txn, key); err != nil {
@@ -306,11 +719,13 @@ func (s *Session) DeleteOne(collName string, filter map[string]interface{}) erro
 		opType:     "delete",
 		collection: collName,
 		docID:      id,
+		doc:        doc,
 	})
 
 	s.collections[collName] = true
 
-	return nil
+	hookCtx.Type = HookAfterDelete
+	return coll.runHooks(hookCtx)
 }
 
 // normalizeFilter converts string _id values to ObjectID for proper matching
@@ -402,7 +817,7 @@ func (s *Session) RollbackToSavepoint(name string) error {
 
 	sp, exists := s.savepoints[name]
 	if !exists {
-		return fmt.Errorf("savepoint %s does not exist", name)
+		return kindOf(ErrNotFound, "savepoint %s does not exist", name)
 	}
 
 	// Restore the transaction state to the savepoint
@@ -440,7 +855,7 @@ func (s *Session) ReleaseSavepoint(name string) error {
 	}
 
 	if _, exists := s.savepoints[name]; !exists {
-		return fmt.Errorf("savepoint %s does not exist", name)
+		return kindOf(ErrNotFound, "savepoint %s does not exist", name)
 	}
 
 	delete(s.savepoints, name)
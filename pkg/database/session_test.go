@@ -3,7 +3,9 @@ package database
 import (
 	"fmt"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mnohosten/laura-db/pkg/document"
 	"github.com/mnohosten/laura-db/pkg/mvcc"
@@ -447,3 +449,288 @@ func TestSession_Transaction(t *testing.T) {
 
 	session.AbortTransaction()
 }
+
+func TestSessionCommitIsDurable(t *testing.T) {
+	config := DefaultConfig(t.TempDir())
+	config.GroupCommitLinger = time.Millisecond
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	const sessions = 16
+	var wg sync.WaitGroup
+	for i := 0; i < sessions; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			session := db.StartSession()
+			session.InsertOne("users", map[string]interface{}{"n": int64(i)})
+			if err := session.CommitTransaction(); err != nil {
+				t.Errorf("Failed to commit transaction: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	walStats := db.Stats()["storage_stats"].(map[string]interface{})["wal"].(map[string]interface{})
+	commitStats := walStats["commits"].(map[string]interface{})
+	if commitStats["durable_writes"].(int64) != sessions {
+		t.Errorf("Expected %d durable commits, got %v", sessions, commitStats["durable_writes"])
+	}
+	if commitStats["syncs"].(int64) > sessions {
+		t.Errorf("Expected at most %d syncs, got %v", sessions, commitStats["syncs"])
+	}
+}
+
+func TestSessionAbortedWhenTooOld(t *testing.T) {
+	config := DefaultConfig(t.TempDir())
+	config.MaxTransactionLifetime = 50 * time.Millisecond
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// An abandoned session is reaped in the background
+	abandoned := db.StartSession()
+	abandoned.InsertOne("users", map[string]interface{}{"name": "Alice"})
+
+	if age := db.Stats()["oldest_snapshot_age"].(string); age == "0s" {
+		t.Errorf("Expected non-zero oldest snapshot age, got %s", age)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for db.txnMgr.GetActiveTransactions() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := db.txnMgr.GetActiveTransactions(); n != 0 {
+		t.Fatalf("Expected abandoned transaction to be aborted, %d still active", n)
+	}
+
+	if err := abandoned.CommitTransaction(); err != mvcc.ErrTransactionTooOld {
+		t.Errorf("Expected ErrTransactionTooOld on commit, got %v", err)
+	}
+	if _, err := abandoned.InsertOne("users", map[string]interface{}{"name": "Bob"}); err != mvcc.ErrTransactionTooOld {
+		t.Errorf("Expected ErrTransactionTooOld on insert, got %v", err)
+	}
+	if count, _ := db.Collection("users").Count(map[string]interface{}{}); count != 0 {
+		t.Errorf("Expected 0 documents, got %d", count)
+	}
+
+	stats := db.Stats()
+	if stats["txn_aborted_too_old"].(int64) != 1 {
+		t.Errorf("Expected 1 aborted transaction, got %v", stats["txn_aborted_too_old"])
+	}
+	if stats["oldest_snapshot_age"].(string) != "0s" {
+		t.Errorf("Expected oldest snapshot age 0s, got %v", stats["oldest_snapshot_age"])
+	}
+}
+
+func TestSessionAbortedOnTooManyOperations(t *testing.T) {
+	config := DefaultConfig(t.TempDir())
+	config.MaxTransactionOperations = 3
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	session := db.StartSession()
+	for i := 0; i < 3; i++ {
+		if _, err := session.InsertOne("users", map[string]interface{}{"n": int64(i)}); err != nil {
+			t.Fatalf("Insert %d failed: %v", i, err)
+		}
+	}
+
+	if _, err := session.InsertOne("users", map[string]interface{}{"n": int64(3)}); err != mvcc.ErrTransactionTooOld {
+		t.Fatalf("Expected ErrTransactionTooOld, got %v", err)
+	}
+	if err := session.CommitTransaction(); err != mvcc.ErrTransactionTooOld {
+		t.Errorf("Expected ErrTransactionTooOld on commit, got %v", err)
+	}
+	if count, _ := db.Collection("users").Count(map[string]interface{}{}); count != 0 {
+		t.Errorf("Expected 0 documents, got %d", count)
+	}
+}
+
+func TestSessionUpdateWithOptionsUpsert(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	session := db.StartSession()
+	result, err := session.UpdateWithOptions("accounts",
+		map[string]interface{}{"_id": "acc-1"},
+		map[string]interface{}{
+			"$inc":         map[string]interface{}{"balance": int64(100)},
+			"$setOnInsert": map[string]interface{}{"owner": "Alice"},
+		},
+		&UpdateOptions{Upsert: true})
+	if err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if result.MatchedCount != 0 || result.UpsertedID != "acc-1" {
+		t.Errorf("Expected upsert of acc-1 with no matches, got %+v", result)
+	}
+
+	// A second upsert in the same transaction updates the pending document
+	result, err = session.UpdateWithOptions("accounts",
+		map[string]interface{}{"_id": "acc-1"},
+		map[string]interface{}{"$inc": map[string]interface{}{"balance": int64(50)}},
+		&UpdateOptions{Upsert: true})
+	if err != nil {
+		t.Fatalf("Second upsert failed: %v", err)
+	}
+	if result.MatchedCount != 1 || result.UpsertedID != nil {
+		t.Errorf("Expected 1 match and no upsert, got %+v", result)
+	}
+
+	// Nothing is visible until commit
+	if _, err := db.Collection("accounts").FindOne(map[string]interface{}{"_id": "acc-1"}); err == nil {
+		t.Error("Expected upserted document to be invisible before commit")
+	}
+
+	if err := session.CommitTransaction(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	doc, err := db.Collection("accounts").FindOne(map[string]interface{}{"_id": "acc-1"})
+	if err != nil {
+		t.Fatalf("Failed to find upserted document: %v", err)
+	}
+	if balance, _ := doc.Get("balance"); balance != int64(150) {
+		t.Errorf("Expected balance 150, got %v", balance)
+	}
+	if owner, _ := doc.Get("owner"); owner != "Alice" {
+		t.Errorf("Expected owner Alice, got %v", owner)
+	}
+
+	// Without upsert a missing document is simply not matched
+	session = db.StartSession()
+	result, err = session.UpdateWithOptions("accounts",
+		map[string]interface{}{"_id": "acc-2"},
+		map[string]interface{}{"$set": map[string]interface{}{"balance": int64(1)}},
+		nil)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if result.MatchedCount != 0 || result.UpsertedID != nil {
+		t.Errorf("Expected no match and no upsert, got %+v", result)
+	}
+	session.AbortTransaction()
+}
+
+func TestSessionUpdateWithOptionsMulti(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("accounts")
+	for i := 0; i < 3; i++ {
+		coll.InsertOne(map[string]interface{}{"_id": fmt.Sprintf("acc-%d", i), "tier": "gold", "balance": int64(100)})
+	}
+	coll.InsertOne(map[string]interface{}{"_id": "acc-3", "tier": "silver", "balance": int64(100)})
+
+	session := db.StartSession()
+	// Pending insert in the same transaction is included in the multi update
+	session.InsertOne("accounts", map[string]interface{}{"_id": "acc-4", "tier": "gold", "balance": int64(0)})
+	// Pending delete is excluded
+	session.DeleteOne("accounts", map[string]interface{}{"_id": "acc-0"})
+
+	result, err := session.UpdateWithOptions("accounts",
+		map[string]interface{}{"tier": "gold"},
+		map[string]interface{}{"$inc": map[string]interface{}{"balance": int64(10)}},
+		&UpdateOptions{Multi: true})
+	if err != nil {
+		t.Fatalf("Multi update failed: %v", err)
+	}
+	if result.MatchedCount != 3 {
+		t.Errorf("Expected 3 matched documents, got %d", result.MatchedCount)
+	}
+
+	// A concurrent write to a document in the write set causes a conflict
+	other := db.StartSession()
+	if err := other.UpdateOne("accounts", map[string]interface{}{"_id": "acc-1"}, map[string]interface{}{"$set": map[string]interface{}{"balance": int64(0)}}); err != nil {
+		t.Fatalf("Concurrent update failed: %v", err)
+	}
+	if err := other.CommitTransaction(); err != nil {
+		t.Fatalf("Concurrent commit failed: %v", err)
+	}
+	if err := session.CommitTransaction(); err != mvcc.ErrConflict {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+
+	// Retry without interference
+	session = db.StartSession()
+	if _, err := session.UpdateWithOptions("accounts",
+		map[string]interface{}{"tier": "gold"},
+		map[string]interface{}{"$inc": map[string]interface{}{"balance": int64(10)}},
+		&UpdateOptions{Multi: true}); err != nil {
+		t.Fatalf("Multi update failed: %v", err)
+	}
+	if err := session.CommitTransaction(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	expected := map[string]int64{"acc-0": 110, "acc-1": 10, "acc-2": 110, "acc-3": 100}
+	for id, want := range expected {
+		doc, err := coll.FindOne(map[string]interface{}{"_id": id})
+		if err != nil {
+			t.Fatalf("Failed to find %s: %v", id, err)
+		}
+		if balance, _ := doc.Get("balance"); balance != want {
+			t.Errorf("Expected %s balance %d, got %v", id, want, balance)
+		}
+	}
+}
+
+func TestSessionCommitsNetOperations(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	db.Collection("items").InsertOne(map[string]interface{}{"_id": "existing", "n": int64(1)})
+
+	var committed []CommittedOperation
+	db.AddCommitListener(func(txnID mvcc.TxnID, ops []CommittedOperation) error {
+		committed = append(committed, ops...)
+		return nil
+	})
+
+	session := db.StartSession()
+	// Inserted then updated: one insert of the final document
+	session.InsertOne("items", map[string]interface{}{"_id": "a", "n": int64(1)})
+	session.UpdateOne("items", map[string]interface{}{"_id": "a"}, map[string]interface{}{"$inc": map[string]interface{}{"n": int64(1)}})
+	// Inserted then deleted: nothing
+	session.InsertOne("items", map[string]interface{}{"_id": "b", "n": int64(1)})
+	session.DeleteOne("items", map[string]interface{}{"_id": "b"})
+	// Updated twice: one update
+	session.UpdateOne("items", map[string]interface{}{"_id": "existing"}, map[string]interface{}{"$inc": map[string]interface{}{"n": int64(1)}})
+	session.UpdateOne("items", map[string]interface{}{"_id": "existing"}, map[string]interface{}{"$inc": map[string]interface{}{"n": int64(1)}})
+
+	if err := session.CommitTransaction(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	if len(committed) != 2 {
+		t.Fatalf("Expected 2 committed operations, got %d: %+v", len(committed), committed)
+	}
+	if committed[0].Type != "insert" || committed[0].DocumentID != "a" || committed[0].Document["n"] != int64(2) {
+		t.Errorf("Expected insert of a with n=2, got %+v", committed[0])
+	}
+	if committed[1].Type != "update" || committed[1].DocumentID != "existing" || committed[1].Document["n"] != int64(3) {
+		t.Errorf("Expected update of existing with n=3, got %+v", committed[1])
+	}
+
+	if _, err := db.Collection("items").FindOne(map[string]interface{}{"_id": "b"}); err == nil {
+		t.Error("Expected b to not exist")
+	}
+}
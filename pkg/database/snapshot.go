@@ -0,0 +1,319 @@
+package database
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/aggregation"
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/mvcc"
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+// DefaultReadSnapshotLifetime is how long a read snapshot stays open when
+// the database has no MaxTransactionLifetime
+const DefaultReadSnapshotLifetime = 10 * time.Minute
+
+// Snapshot is a point-in-time view of a database. Every read of a snapshot,
+// whatever the collection, sees the documents as they were when the snapshot
+// started, so a report made of several reads is consistent.
+//
+// A snapshot pins its MVCC version like a transaction and must be released
+// with Release. It is released automatically, failing later reads with
+// mvcc.ErrTransactionTooOld, once it outlives the database's
+// MaxTransactionLifetime (or DefaultReadSnapshotLifetime).
+type Snapshot struct {
+	db    *Database
+	txn   *mvcc.Transaction
+	timer *time.Timer
+
+	// preImages holds, per document store, the version at the start of the
+	// snapshot of every document written since (nil if it didn't exist)
+	preImages map[*DocumentStore]map[string]*document.Document
+	mu        sync.Mutex
+	err       error // Set once the snapshot is released
+}
+
+// snapshotRegistry tracks the open snapshots of a database. Writes to
+// document stores preserve the current version of a document in every open
+// snapshot before changing it.
+type snapshotRegistry struct {
+	snapshots map[*Snapshot]struct{}
+	mu        sync.RWMutex // Held for reading across each document write
+
+	// commits is held for reading while a session applies its commit, so a
+	// snapshot never starts in the middle of one
+	commits sync.RWMutex
+}
+
+// newSnapshotRegistry creates an empty snapshot registry
+func newSnapshotRegistry() *snapshotRegistry {
+	return &snapshotRegistry{snapshots: make(map[*Snapshot]struct{})}
+}
+
+// preserve saves the current version of document id of ds in the open
+// snapshots that haven't seen it change yet. It returns a function to call
+// once the write is done; snapshots can't start or be released in between.
+func (r *snapshotRegistry) preserve(ds *DocumentStore, ids ...string) func() {
+	if r == nil {
+		return func() {}
+	}
+	r.mu.RLock()
+	if len(r.snapshots) == 0 {
+		return r.mu.RUnlock
+	}
+
+	for _, id := range ids {
+		var current *document.Document
+		if doc, err := ds.Get(id); err == nil {
+			current = document.NewDocumentFromMap(doc.ToMap())
+		}
+		for snap := range r.snapshots {
+			snap.preserve(ds, id, current)
+		}
+	}
+	return r.mu.RUnlock
+}
+
+// preserveVersion is preserve for a document moving into ds from elsewhere,
+// such as the cold tier, whose current version is doc
+func (r *snapshotRegistry) preserveVersion(ds *DocumentStore, id string, doc *document.Document) func() {
+	if r == nil {
+		return func() {}
+	}
+	r.mu.RLock()
+	if len(r.snapshots) == 0 {
+		return r.mu.RUnlock
+	}
+
+	current := document.NewDocumentFromMap(doc.ToMap())
+	for snap := range r.snapshots {
+		snap.preserve(ds, id, current)
+	}
+	return r.mu.RUnlock
+}
+
+// StartReadSnapshot starts a snapshot of the database as of now
+func (db *Database) StartReadSnapshot() *Snapshot {
+	lifetime := db.maxTransactionLifetime
+	if lifetime <= 0 {
+		lifetime = DefaultReadSnapshotLifetime
+	}
+
+	snap := &Snapshot{
+		db:        db,
+		preImages: make(map[*DocumentStore]map[string]*document.Document),
+	}
+
+	// Wait for commits in progress, and keep writes out while registering
+	r := db.snapshots
+	r.commits.Lock()
+	r.mu.Lock()
+	snap.txn = db.txnMgr.Begin()
+	r.snapshots[snap] = struct{}{}
+	r.mu.Unlock()
+	r.commits.Unlock()
+
+	snap.timer = time.AfterFunc(lifetime, func() {
+		snap.release(mvcc.ErrTransactionTooOld)
+	})
+	return snap
+}
+
+// Release releases the snapshot and the MVCC version it pins. Reads of a
+// released snapshot fail with ErrSnapshotReleased.
+func (s *Snapshot) Release() {
+	s.timer.Stop()
+	s.release(ErrSnapshotReleased)
+}
+
+// release unregisters the snapshot; later reads fail with err
+func (s *Snapshot) release(err error) {
+	r := s.db.snapshots
+	r.mu.Lock()
+	delete(r.snapshots, s)
+	r.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	s.err = err
+	s.preImages = nil
+	s.db.txnMgr.Abort(s.txn)
+}
+
+// preserve records the version of a document at the start of the snapshot,
+// unless an earlier write already did
+func (s *Snapshot) preserve(ds *DocumentStore, id string, doc *document.Document) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return
+	}
+	docs, exists := s.preImages[ds]
+	if !exists {
+		docs = make(map[string]*document.Document)
+		s.preImages[ds] = docs
+	}
+	if _, seen := docs[id]; !seen {
+		docs[id] = doc
+	}
+}
+
+// check returns why the snapshot can no longer be read, if it can't
+func (s *Snapshot) check() error {
+	s.mu.Lock()
+	err := s.err
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := s.db.txnMgr.CheckLimits(s.txn); err != nil {
+		s.timer.Stop()
+		s.release(err)
+		return err
+	}
+	return nil
+}
+
+// documents returns the documents of a collection as of the snapshot
+func (s *Snapshot) documents(collName string) ([]*document.Document, error) {
+	hot, cold, err := s.collectionDocuments(s.db.Collection(collName))
+	if err != nil {
+		return nil, err
+	}
+	return append(hot, cold...), nil
+}
+
+// collectionDocuments returns the documents of coll as of the snapshot: those
+// of the hot tier, and those still in the cold tier unchanged since the
+// snapshot started. Documents moved between the tiers meanwhile are returned
+// as hot.
+func (s *Snapshot) collectionDocuments(coll *Collection) ([]*document.Document, []*document.Document, error) {
+	if err := s.check(); err != nil {
+		return nil, nil, err
+	}
+
+	// Read the current documents first: a document written meanwhile has its
+	// version at the start of the snapshot preserved before the write
+	coll.mu.RLock()
+	ids := coll.docStore.GetAllIDs()
+	current := make(map[string]*document.Document, len(ids))
+	for _, id := range ids {
+		if doc, err := coll.docStore.Get(id); err == nil {
+			current[id] = doc
+		}
+	}
+	cold, err := coll.coldDocuments()
+	coll.mu.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+
+	preImages := s.preImages[coll.docStore]
+	hot := make([]*document.Document, 0, len(current))
+	for _, id := range ids {
+		if _, changed := preImages[id]; !changed && current[id] != nil {
+			hot = append(hot, current[id])
+		}
+	}
+	for _, doc := range preImages {
+		if doc != nil {
+			hot = append(hot, doc)
+		}
+	}
+
+	unchanged := make([]*document.Document, 0, len(cold))
+	for _, doc := range cold {
+		idVal, _ := doc.Get("_id")
+		if _, changed := preImages[fmt.Sprintf("%v", idVal)]; !changed {
+			unchanged = append(unchanged, doc)
+		}
+	}
+	return hot, unchanged, nil
+}
+
+// Find finds the documents of a collection matching the filter
+func (s *Snapshot) Find(collName string, filter map[string]interface{}) ([]*document.Document, error) {
+	return s.FindWithOptions(collName, filter, &QueryOptions{})
+}
+
+// FindWithOptions finds the documents of a collection matching the filter
+// with query options. Index hints are ignored.
+func (s *Snapshot) FindWithOptions(collName string, filter map[string]interface{}, options *QueryOptions) ([]*document.Document, error) {
+	docs, err := s.documents(collName)
+	if err != nil {
+		return nil, err
+	}
+
+	q := query.NewQuery(filter)
+	if options != nil {
+		if options.Projection != nil {
+			q.WithProjection(options.Projection)
+		}
+		if options.Meta != nil {
+			q.WithMeta(options.Meta)
+		}
+		if options.Sort != nil {
+			q.WithSort(options.Sort)
+		}
+		if options.Limit > 0 {
+			q.WithLimit(options.Limit)
+		}
+		if options.Skip > 0 {
+			q.WithSkip(options.Skip)
+		}
+	}
+	return query.NewExecutor(docs).Execute(q)
+}
+
+// FindOne finds the first document of a collection matching the filter
+func (s *Snapshot) FindOne(collName string, filter map[string]interface{}) (*document.Document, error) {
+	results, err := s.FindWithOptions(collName, filter, &QueryOptions{Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, ErrDocumentNotFound
+	}
+	return results[0], nil
+}
+
+// Count counts the documents of a collection matching the filter
+func (s *Snapshot) Count(collName string, filter map[string]interface{}) (int, error) {
+	results, err := s.Find(collName, filter)
+	if err != nil {
+		return 0, err
+	}
+	return len(results), nil
+}
+
+// Aggregate runs an aggregation pipeline on a collection. Snapshots are
+// read-only, so pipelines ending in $out or $merge are rejected. $lookup
+// joins collections as of the snapshot.
+func (s *Snapshot) Aggregate(collName string, pipeline []map[string]interface{}) ([]*document.Document, error) {
+	aggPipeline, err := aggregation.NewPipeline(pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	if aggPipeline.Output() != nil {
+		return nil, fmt.Errorf("snapshot reads can't write output")
+	}
+
+	docs, err := s.documents(collName)
+	if err != nil {
+		return nil, err
+	}
+	aggPipeline.BindLookups(&snapshotLookup{snapshot: s, tables: make(map[[2]string]*aggregation.LookupTable)})
+	return aggPipeline.Execute(docs)
+}
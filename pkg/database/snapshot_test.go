@@ -0,0 +1,198 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/mvcc"
+)
+
+// sumBalances totals the balance of the accounts of a collection in snap
+func sumBalances(t *testing.T, snap *Snapshot, collName string) int64 {
+	t.Helper()
+
+	results, err := snap.Aggregate(collName, []map[string]interface{}{
+		{"$group": map[string]interface{}{
+			"_id":   nil,
+			"total": map[string]interface{}{"$sum": "$balance"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to aggregate %s: %v", collName, err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(results))
+	}
+	total, _ := results[0].Get("total")
+	switch v := total.(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	t.Fatalf("Unexpected total %v", total)
+	return 0
+}
+
+func TestSnapshotConsistentAcrossCollections(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	db.Collection("checking").InsertOne(map[string]interface{}{"_id": "alice", "balance": int64(100)})
+	db.Collection("savings").InsertOne(map[string]interface{}{"_id": "alice", "balance": int64(50)})
+
+	snap := db.StartReadSnapshot()
+	defer snap.Release()
+
+	// Move 30 from checking to savings after the snapshot started
+	session := db.StartSession()
+	if err := session.UpdateOne("checking", map[string]interface{}{"_id": "alice"}, map[string]interface{}{"$inc": map[string]interface{}{"balance": int64(-30)}}); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if err := session.UpdateOne("savings", map[string]interface{}{"_id": "alice"}, map[string]interface{}{"$inc": map[string]interface{}{"balance": int64(30)}}); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if err := session.CommitTransaction(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	if total := sumBalances(t, snap, "checking"); total != 100 {
+		t.Errorf("Expected checking total 100 in snapshot, got %d", total)
+	}
+	if total := sumBalances(t, snap, "savings"); total != 50 {
+		t.Errorf("Expected savings total 50 in snapshot, got %d", total)
+	}
+
+	// A new snapshot sees the transfer
+	later := db.StartReadSnapshot()
+	defer later.Release()
+	if total := sumBalances(t, later, "checking"); total != 70 {
+		t.Errorf("Expected checking total 70 in new snapshot, got %d", total)
+	}
+}
+
+func TestSnapshotInsertsAndDeletes(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("orders")
+	coll.InsertOne(map[string]interface{}{"_id": "o1", "status": "open"})
+	coll.InsertOne(map[string]interface{}{"_id": "o2", "status": "open"})
+
+	snap := db.StartReadSnapshot()
+	defer snap.Release()
+
+	coll.InsertOne(map[string]interface{}{"_id": "o3", "status": "open"})
+	coll.DeleteOne(map[string]interface{}{"_id": "o1"})
+	coll.UpdateOne(map[string]interface{}{"_id": "o2"}, map[string]interface{}{"$set": map[string]interface{}{"status": "closed"}})
+
+	count, err := snap.Count("orders", map[string]interface{}{"status": "open"})
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 open orders in snapshot, got %d", count)
+	}
+	if _, err := snap.FindOne("orders", map[string]interface{}{"_id": "o3"}); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected later insert to be invisible, got %v", err)
+	}
+	doc, err := snap.FindOne("orders", map[string]interface{}{"_id": "o1"})
+	if err != nil {
+		t.Fatalf("Expected deleted document in snapshot: %v", err)
+	}
+	if status, _ := doc.Get("status"); status != "open" {
+		t.Errorf("Expected status open, got %v", status)
+	}
+
+	// The collection itself has moved on
+	count, _ = coll.Count(map[string]interface{}{"status": "open"})
+	if count != 1 {
+		t.Errorf("Expected 1 open order, got %d", count)
+	}
+}
+
+func TestSnapshotRelease(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	snap := db.StartReadSnapshot()
+	if db.txnMgr.GetActiveTransactions() != 1 {
+		t.Errorf("Expected snapshot to pin a transaction, got %d active", db.txnMgr.GetActiveTransactions())
+	}
+
+	snap.Release()
+	if db.txnMgr.GetActiveTransactions() != 0 {
+		t.Errorf("Expected released snapshot to unpin, got %d active", db.txnMgr.GetActiveTransactions())
+	}
+	if _, err := snap.Find("orders", map[string]interface{}{}); !errors.Is(err, ErrSnapshotReleased) {
+		t.Errorf("Expected ErrSnapshotReleased, got %v", err)
+	}
+
+	// Releasing twice is harmless
+	snap.Release()
+}
+
+func TestSnapshotLifetime(t *testing.T) {
+	config := DefaultConfig(t.TempDir())
+	config.MaxTransactionLifetime = 50 * time.Millisecond
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	snap := db.StartReadSnapshot()
+	defer snap.Release()
+
+	time.Sleep(100 * time.Millisecond)
+	if _, err := snap.Find("orders", map[string]interface{}{}); !errors.Is(err, mvcc.ErrTransactionTooOld) {
+		t.Errorf("Expected ErrTransactionTooOld, got %v", err)
+	}
+	if db.txnMgr.GetActiveTransactions() != 0 {
+		t.Errorf("Expected expired snapshot to unpin, got %d active", db.txnMgr.GetActiveTransactions())
+	}
+}
+
+func TestSnapshotAcrossTiers(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	coll := db.Collection("readings")
+	insertReadings(t, coll, now, 10)
+	if _, err := coll.ArchiveOlderThan("ts", now.Add(-5*time.Hour-time.Minute)); err != nil {
+		t.Fatalf("Failed to archive: %v", err)
+	}
+
+	snap := db.StartReadSnapshot()
+	defer snap.Release()
+
+	// Moving documents between the tiers doesn't change the snapshot
+	if n, err := coll.Unarchive(map[string]interface{}{}); err != nil || n != 5 {
+		t.Fatalf("Expected 5 thawed documents, got %d (%v)", n, err)
+	}
+	if _, err := coll.ArchiveOlderThan("ts", now.Add(-2*time.Hour-time.Minute)); err != nil {
+		t.Fatalf("Failed to archive: %v", err)
+	}
+
+	count, err := snap.Count("readings", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 10 {
+		t.Errorf("Expected 10 documents in snapshot, got %d", count)
+	}
+}
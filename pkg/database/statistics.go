@@ -0,0 +1,135 @@
+package database
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/index"
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+const (
+	// StatisticsSampleSize is the number of documents sampled to build a
+	// collection's statistics
+	StatisticsSampleSize = 1000
+
+	// Statistics are refreshed in the background once the documents written
+	// since they were built exceed statisticsRefreshWrites plus
+	// statisticsRefreshShare of the documents they describe
+	statisticsRefreshWrites = 500
+	statisticsRefreshShare  = 0.2
+)
+
+// collectionStats are the statistics a collection's planner uses, with the
+// write count they were built at
+type collectionStats struct {
+	stats  *query.CollectionStatistics
+	writes int64
+}
+
+// UpdateStatistics samples the collection's documents and rebuilds the
+// statistics the query planner estimates plan costs from, like ANALYZE.
+// Index statistics are refreshed too. Until it is first called, plans are
+// chosen by index statistics alone; afterwards the statistics are refreshed
+// in the background as the collection changes.
+func (c *Collection) UpdateStatistics() {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	writes := c.writes.Load()
+
+	ids := c.docStore.GetAllIDs()
+	if len(ids) > StatisticsSampleSize {
+		rand.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+		ids = ids[:StatisticsSampleSize]
+	}
+	sample := make([]*document.Document, 0, len(ids))
+	for _, id := range ids {
+		doc, err := c.docStore.Get(id)
+		if err != nil {
+			// Deleted since the IDs were listed
+			continue
+		}
+		sample = append(sample, doc)
+	}
+
+	stats := query.BuildCollectionStatistics(c.docStore.Count(), sample, c.statisticsFields(sample))
+	c.stats.Store(&collectionStats{stats: stats, writes: writes})
+
+	for _, idx := range c.indexes {
+		idx.Analyze()
+	}
+}
+
+// statisticsFields returns the fields statistics are kept for: the top-level
+// fields of the sample and every indexed field
+func (c *Collection) statisticsFields(sample []*document.Document) []string {
+	seen := make(map[string]bool)
+	for _, doc := range sample {
+		for _, key := range doc.Keys() {
+			seen[key] = true
+		}
+	}
+	for _, idx := range c.indexes {
+		for _, field := range idx.FieldPaths() {
+			seen[field] = true
+		}
+	}
+
+	fields := make([]string, 0, len(seen))
+	for field := range seen {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Statistics returns the collection's statistics, or nil if they were
+// never gathered
+func (c *Collection) Statistics() *query.CollectionStatistics {
+	if cs := c.stats.Load(); cs != nil {
+		return cs.stats
+	}
+	return nil
+}
+
+// queryPlanner returns a planner over the collection's ready indexes,
+// costing plans with its statistics if it has them. An index still being
+// built in the background, or whose build failed, is missing entries and
+// left out. Stale statistics are used while a refresh runs in the
+// background. Must be called with c.mu held.
+func (c *Collection) queryPlanner() *query.QueryPlanner {
+	planner := query.NewQueryPlanner(c.readyIndexes())
+	cs := c.stats.Load()
+	if cs == nil {
+		return planner
+	}
+
+	threshold := statisticsRefreshWrites + int64(float64(cs.stats.TotalDocuments)*statisticsRefreshShare)
+	if c.writes.Load()-cs.writes > threshold && c.statsRefreshing.CompareAndSwap(false, true) {
+		go func() {
+			defer c.statsRefreshing.Store(false)
+			c.UpdateStatistics()
+		}()
+	}
+	return planner.WithStatistics(cs.stats)
+}
+
+// readyIndexes returns the indexes of the collection that are fully built
+// Must be called with c.mu held
+func (c *Collection) readyIndexes() map[string]*index.Index {
+	for _, idx := range c.indexes {
+		if idx.IsReady() {
+			continue
+		}
+		ready := make(map[string]*index.Index, len(c.indexes))
+		for name, idx := range c.indexes {
+			if idx.IsReady() {
+				ready[name] = idx
+			}
+		}
+		return ready
+	}
+	return c.indexes
+}
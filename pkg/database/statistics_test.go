@@ -0,0 +1,90 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCollectionUpdateStatistics(t *testing.T) {
+	dir := "./test_db_statistics"
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	docs := make([]map[string]interface{}, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		docs = append(docs, map[string]interface{}{"email": fmt.Sprintf("user%d@example.com", i), "age": int64(i)})
+	}
+	if _, err := users.InsertMany(docs); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	for _, field := range []string{"email", "age"} {
+		if err := users.CreateIndex(field, false); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+	}
+	users.Analyze()
+
+	// The range matches every document, but index statistics assume 30%
+	filter := map[string]interface{}{"age": map[string]interface{}{"$gte": int64(0)}}
+	if users.Statistics() != nil {
+		t.Fatal("Expected no statistics before UpdateStatistics")
+	}
+	explain := users.Explain(filter)
+	if explain["costModel"] != "index statistics" || explain["indexName"] != "age_1" {
+		t.Errorf("Expected age_1 chosen by index statistics, got %v", explain)
+	}
+
+	users.UpdateStatistics()
+	stats := users.Statistics()
+	if stats == nil || stats.TotalDocuments != 1000 || stats.SampleSize != 1000 {
+		t.Fatalf("Expected statistics of 1000 documents, got %+v", stats)
+	}
+	if _, ok := stats.Fields["email"]; !ok {
+		t.Errorf("Expected statistics of email, got %v", stats.Fields)
+	}
+
+	explain = users.Explain(filter)
+	if explain["costModel"] != "collection statistics" || explain["scanType"] != "COLLECTION_SCAN" {
+		t.Errorf("Expected a collection scan chosen by collection statistics, got %v", explain)
+	}
+	if explain["estimatedDocsExamined"] != 1000 {
+		t.Errorf("Expected 1000 documents examined, got %v", explain["estimatedDocsExamined"])
+	}
+	if _, ok := explain["candidatePlans"]; !ok {
+		t.Error("Expected candidate plans in the explanation")
+	}
+
+	explain = users.Explain(map[string]interface{}{"email": "user7@example.com", "age": map[string]interface{}{"$lt": int64(500)}})
+	if explain["indexName"] != "email_1" || explain["estimatedDocsExamined"] != 1 {
+		t.Errorf("Expected email_1 examining 1 document, got %v", explain)
+	}
+
+	// Enough writes refresh the statistics on the next query
+	more := make([]map[string]interface{}, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		more = append(more, map[string]interface{}{"email": fmt.Sprintf("new%d", i), "age": int64(1000 + i)})
+	}
+	if _, err := users.InsertMany(more); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if _, err := users.Find(map[string]interface{}{"email": "new7"}); err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for users.Statistics().TotalDocuments != 2000 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected statistics to be refreshed, got %d documents", users.Statistics().TotalDocuments)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
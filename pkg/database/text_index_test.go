@@ -374,3 +374,196 @@ func TestMultipleTextFields(t *testing.T) {
 		t.Errorf("Expected 1 result matching tags field, got %d", len(results))
 	}
 }
+
+func TestTextQueryOperator(t *testing.T) {
+	dir := "./test_text_query_operator"
+	defer os.RemoveAll(dir)
+
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("articles")
+
+	// Title matches count ten times as much as body matches
+	err = coll.CreateTextIndexWithWeights([]string{"title", "body"}, map[string]int{"title": 10})
+	if err != nil {
+		t.Fatalf("Failed to create text index: %v", err)
+	}
+
+	coll.InsertOne(map[string]interface{}{
+		"_id":       "body",
+		"title":     "Weekly notes",
+		"body":      "Some thoughts on the coffee we brewed this week",
+		"published": true,
+	})
+	coll.InsertOne(map[string]interface{}{
+		"_id":       "title",
+		"title":     "Brewing better coffee",
+		"body":      "Grind size and water temperature matter most",
+		"published": true,
+	})
+	coll.InsertOne(map[string]interface{}{
+		"_id":       "draft",
+		"title":     "Coffee roasting",
+		"body":      "Draft",
+		"published": false,
+	})
+	coll.InsertOne(map[string]interface{}{
+		"_id":   "unrelated",
+		"title": "Tea ceremonies",
+		"body":  "The history of the tea ceremony",
+	})
+
+	filter := map[string]interface{}{
+		"$text":     map[string]interface{}{"$search": "brewing coffee"},
+		"published": true,
+	}
+	results, err := coll.FindWithOptions(filter, &QueryOptions{
+		Meta: map[string]string{"score": "textScore"},
+	})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	// Ranked by relevance, with the score projected
+	if id, _ := results[0].Get("_id"); id != "title" {
+		t.Errorf("Expected title match first, got %v", id)
+	}
+	score0, ok0 := results[0].Get("score")
+	score1, ok1 := results[1].Get("score")
+	if !ok0 || !ok1 {
+		t.Fatal("Expected score field in results")
+	}
+	if score0.(float64) <= score1.(float64) {
+		t.Errorf("Expected descending scores, got %v then %v", score0, score1)
+	}
+	if results[0].Has("_textScore") {
+		t.Error("Expected internal score field to be removed")
+	}
+
+	// Without a metadata projection the score is not exposed
+	results, _ = coll.Find(filter)
+	if len(results) != 2 || results[0].Has("score") || results[0].Has("_textScore") {
+		t.Errorf("Expected 2 results without score fields, got %v", results)
+	}
+
+	count, err := coll.Count(map[string]interface{}{"$text": map[string]interface{}{"$search": "tea"}})
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 tea article, got %d", count)
+	}
+
+	// Writes can target $text matches
+	err = coll.UpdateOne(
+		map[string]interface{}{"$text": map[string]interface{}{"$search": "roasting"}},
+		map[string]interface{}{"$set": map[string]interface{}{"published": true}},
+	)
+	if err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+	doc, _ := coll.FindOne(map[string]interface{}{"_id": "draft"})
+	if published, _ := doc.Get("published"); published != true {
+		t.Errorf("Expected draft to be published, got %v", published)
+	}
+	if doc.Has("_textScore") {
+		t.Error("Expected internal score field not to be stored")
+	}
+}
+
+func TestTextQueryWithoutIndex(t *testing.T) {
+	dir := "./test_text_query_no_index"
+	defer os.RemoveAll(dir)
+
+	db, _ := Open(DefaultConfig(dir))
+	defer db.Close()
+
+	coll := db.Collection("articles")
+	coll.InsertOne(map[string]interface{}{"title": "Coffee"})
+
+	_, err := coll.Find(map[string]interface{}{"$text": map[string]interface{}{"$search": "coffee"}})
+	if err == nil {
+		t.Error("Expected error for $text without a text index")
+	}
+}
+
+func TestTextQueryPhrasesAndExclusions(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	products := db.Collection("products")
+	if err := products.CreateTextIndex([]string{"description"}); err != nil {
+		t.Fatalf("CreateTextIndex failed: %v", err)
+	}
+	products.InsertOne(map[string]interface{}{"_id": "grinder", "description": "Burr grinder for espresso and pour over coffee"})
+	products.InsertOne(map[string]interface{}{"_id": "beans", "description": "Dark roast coffee beans, ideal for espresso"})
+	products.InsertOne(map[string]interface{}{"_id": "decaf", "description": "Decaf coffee beans"})
+	products.InsertOne(map[string]interface{}{"_id": "kettle", "description": "Gooseneck kettle for pour-over brewing"})
+
+	ids := func(search string) []interface{} {
+		results, err := products.FindWithOptions(
+			map[string]interface{}{"$text": map[string]interface{}{"$search": search}},
+			&QueryOptions{Meta: map[string]string{"score": "textScore"}})
+		if err != nil {
+			t.Fatalf("Find %q failed: %v", search, err)
+		}
+		ids := make([]interface{}, len(results))
+		for i, doc := range results {
+			ids[i], _ = doc.Get("_id")
+			if _, ok := doc.Get("score"); !ok {
+				t.Errorf("Expected a score for %v", ids[i])
+			}
+		}
+		return ids
+	}
+
+	// Any of the words matches, documents with more of them rank higher
+	if got := ids("espresso beans"); len(got) != 3 || got[0] != "beans" {
+		t.Errorf("Expected beans first of 3 results, got %v", got)
+	}
+
+	// Phrases must appear, in order
+	if got := ids(`"pour over"`); len(got) != 2 {
+		t.Errorf("Expected 2 pour over products, got %v", got)
+	}
+	if got := ids(`"roast coffee"`); len(got) != 1 || got[0] != "beans" {
+		t.Errorf("Expected only the dark roast beans, got %v", got)
+	}
+	if got := ids(`"coffee espresso"`); len(got) != 0 {
+		t.Errorf("Expected no product with the words in that order, got %v", got)
+	}
+
+	// Quoting every word requires all of them
+	if got := ids(`"coffee" "espresso"`); len(got) != 2 {
+		t.Errorf("Expected 2 products with coffee and espresso, got %v", got)
+	}
+
+	// Excluded words remove matches, and match nothing alone
+	if got := ids("coffee -decaf"); len(got) != 2 {
+		t.Errorf("Expected 2 coffee products without decaf, got %v", got)
+	}
+	if got := ids("-decaf"); len(got) != 0 {
+		t.Errorf("Expected no results for an exclusion alone, got %v", got)
+	}
+
+	// The index follows updates and deletes
+	products.UpdateOne(map[string]interface{}{"_id": "kettle"},
+		map[string]interface{}{"$set": map[string]interface{}{"description": "Electric kettle"}})
+	products.DeleteOne(map[string]interface{}{"_id": "grinder"})
+	if got := ids(`"pour over"`); len(got) != 0 {
+		t.Errorf("Expected no pour over products left, got %v", got)
+	}
+
+	// TextSearch accepts the same syntax
+	docs, err := products.TextSearch("coffee -decaf", nil)
+	if err != nil || len(docs) != 1 {
+		t.Errorf("Expected 1 TextSearch result, got %d (%v)", len(docs), err)
+	}
+}
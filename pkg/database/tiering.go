@@ -0,0 +1,608 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/compression"
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+// archiveSegmentMaxDocuments caps the documents written to one segment
+const archiveSegmentMaxDocuments = 10000
+
+// archiveSegmentExt is the file extension of archive segments
+const archiveSegmentExt = ".seg"
+
+// TieringPolicy moves documents to the cold tier once the date in Field is
+// older than ColdAfter. Field may hold a time.Time, Unix seconds or an
+// RFC 3339 string; documents without a date stay hot.
+type TieringPolicy struct {
+	Field     string
+	ColdAfter time.Duration
+}
+
+// coldArchive keeps the cold documents of a collection in compressed,
+// immutable segment files. Only the location of each document is kept in
+// memory; segments are decompressed when read, and the last one read is
+// cached.
+type coldArchive struct {
+	dir         string
+	compressor  *compression.Compressor
+	segments    map[int]map[string]struct{} // Segment number -> document IDs
+	locations   map[string]int              // Document ID -> segment number
+	sizes       map[int]int64               // Segment number -> compressed bytes
+	nextSegment int
+
+	cachedSegment int // Segment held in cachedDocs (-1 for none)
+	cachedDocs    []*document.Document
+	mu            sync.Mutex
+}
+
+// archiveDir returns the directory of a collection's archive segments
+func archiveDir(dataDir, collName string) string {
+	return filepath.Join(dataDir, "archive", collName)
+}
+
+// openColdArchive opens the archive in dir, reading the segments written
+// before. The directory is created with the first segment.
+func openColdArchive(dir string) (*coldArchive, error) {
+	compressor, err := compression.NewCompressor(compression.DefaultConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compressor: %w", err)
+	}
+
+	a := &coldArchive{
+		dir:           dir,
+		compressor:    compressor,
+		segments:      make(map[int]map[string]struct{}),
+		locations:     make(map[string]int),
+		sizes:         make(map[int]int64),
+		cachedSegment: -1,
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, archiveSegmentExt) {
+			continue
+		}
+		seg, err := strconv.Atoi(strings.TrimSuffix(name, archiveSegmentExt))
+		if err != nil {
+			continue
+		}
+		docs, size, err := a.readSegment(seg)
+		if err != nil {
+			return nil, err
+		}
+		a.addSegment(seg, docs, size)
+		if seg >= a.nextSegment {
+			a.nextSegment = seg + 1
+		}
+	}
+	return a, nil
+}
+
+// segmentPath returns the file of a segment
+func (a *coldArchive) segmentPath(seg int) string {
+	return filepath.Join(a.dir, fmt.Sprintf("%08d%s", seg, archiveSegmentExt))
+}
+
+// addSegment records the documents of a segment
+// Must be called with a.mu held
+func (a *coldArchive) addSegment(seg int, docs []*document.Document, size int64) {
+	ids := make(map[string]struct{}, len(docs))
+	for _, doc := range docs {
+		idVal, _ := doc.Get("_id")
+		id := fmt.Sprintf("%v", idVal)
+		ids[id] = struct{}{}
+		a.locations[id] = seg
+	}
+	a.segments[seg] = ids
+	a.sizes[seg] = size
+}
+
+// readSegment decodes the documents of a segment file
+// Must be called with a.mu held
+func (a *coldArchive) readSegment(seg int) ([]*document.Document, int64, error) {
+	data, err := os.ReadFile(a.segmentPath(seg))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read archive segment %d: %w", seg, err)
+	}
+	raw, err := a.compressor.Decompress(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decompress archive segment %d: %w", seg, err)
+	}
+
+	var docs []*document.Document
+	for len(raw) > 0 {
+		if len(raw) < 4 {
+			return nil, 0, fmt.Errorf("archive segment %d is truncated", seg)
+		}
+		n := binary.LittleEndian.Uint32(raw)
+		if int(n) > len(raw)-4 {
+			return nil, 0, fmt.Errorf("archive segment %d is truncated", seg)
+		}
+		doc, err := document.NewDecoder(raw[4 : 4+n]).Decode()
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to decode archived document: %w", err)
+		}
+		docs = append(docs, doc)
+		raw = raw[4+n:]
+	}
+	return docs, int64(len(data)), nil
+}
+
+// writeSegment writes documents to a new segment file
+// Must be called with a.mu held
+func (a *coldArchive) writeSegment(docs []*document.Document) error {
+	var buf bytes.Buffer
+	for _, doc := range docs {
+		data, err := document.NewEncoder().Encode(doc)
+		if err != nil {
+			return fmt.Errorf("failed to encode document: %w", err)
+		}
+		binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+		buf.Write(data)
+	}
+	compressed, err := a.compressor.Compress(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to compress archive segment: %w", err)
+	}
+
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	seg := a.nextSegment
+	path := a.segmentPath(seg)
+	if err := os.WriteFile(path+".tmp", compressed, 0644); err != nil {
+		return fmt.Errorf("failed to write archive segment: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write archive segment: %w", err)
+	}
+
+	a.nextSegment++
+	a.addSegment(seg, docs, int64(len(compressed)))
+	return nil
+}
+
+// add writes documents to the archive in new segments
+func (a *coldArchive) add(docs []*document.Document) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for start := 0; start < len(docs); start += archiveSegmentMaxDocuments {
+		end := start + archiveSegmentMaxDocuments
+		if end > len(docs) {
+			end = len(docs)
+		}
+		if err := a.writeSegment(docs[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// segment returns the documents of a segment, from the cache if possible
+// Must be called with a.mu held
+func (a *coldArchive) segment(seg int) ([]*document.Document, error) {
+	if a.cachedSegment == seg {
+		return a.cachedDocs, nil
+	}
+	docs, _, err := a.readSegment(seg)
+	if err != nil {
+		return nil, err
+	}
+	a.cachedSegment, a.cachedDocs = seg, docs
+	return docs, nil
+}
+
+// documents returns every cold document
+func (a *coldArchive) documents() ([]*document.Document, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	segs := make([]int, 0, len(a.segments))
+	for seg := range a.segments {
+		segs = append(segs, seg)
+	}
+	sort.Ints(segs)
+
+	docs := make([]*document.Document, 0, len(a.locations))
+	for _, seg := range segs {
+		segDocs, err := a.segment(seg)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, segDocs...)
+	}
+	return docs, nil
+}
+
+// remove deletes documents from the archive, rewriting the segments that
+// held them
+func (a *coldArchive) remove(ids []string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	affected := make(map[int]bool)
+	for _, id := range ids {
+		if seg, exists := a.locations[id]; exists {
+			affected[seg] = true
+		}
+	}
+
+	removed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		removed[id] = true
+	}
+
+	for seg := range affected {
+		docs, err := a.segment(seg)
+		if err != nil {
+			return err
+		}
+		kept := make([]*document.Document, 0, len(docs))
+		for _, doc := range docs {
+			idVal, _ := doc.Get("_id")
+			if !removed[fmt.Sprintf("%v", idVal)] {
+				kept = append(kept, doc)
+			}
+		}
+
+		// Write the remaining documents before dropping the old segment
+		if len(kept) > 0 {
+			if err := a.writeSegment(kept); err != nil {
+				return err
+			}
+		}
+		if err := os.Remove(a.segmentPath(seg)); err != nil {
+			return fmt.Errorf("failed to remove archive segment %d: %w", seg, err)
+		}
+		for id := range a.segments[seg] {
+			if a.locations[id] == seg {
+				delete(a.locations, id)
+			}
+		}
+		delete(a.segments, seg)
+		delete(a.sizes, seg)
+		if a.cachedSegment == seg {
+			a.cachedSegment, a.cachedDocs = -1, nil
+		}
+	}
+	return nil
+}
+
+// count returns the number of cold documents
+func (a *coldArchive) count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.locations)
+}
+
+// stats returns archive statistics
+func (a *coldArchive) stats() map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var size int64
+	for _, s := range a.sizes {
+		size += s
+	}
+	return map[string]interface{}{
+		"documents":        len(a.locations),
+		"segments":         len(a.segments),
+		"compressed_bytes": size,
+	}
+}
+
+// rename moves the archive to another directory
+func (a *coldArchive) rename(dir string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, err := os.Stat(a.dir); err == nil {
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return fmt.Errorf("failed to create archive directory: %w", err)
+		}
+		if err := os.Rename(a.dir, dir); err != nil {
+			return fmt.Errorf("failed to move archive: %w", err)
+		}
+	}
+	a.dir = dir
+	return nil
+}
+
+// destroy deletes the archive's segments
+func (a *coldArchive) destroy() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.segments = make(map[int]map[string]struct{})
+	a.locations = make(map[string]int)
+	a.sizes = make(map[int]int64)
+	a.cachedSegment, a.cachedDocs = -1, nil
+	return os.RemoveAll(a.dir)
+}
+
+// documentDate returns the date a tiering policy compares, if the value
+// is one
+func documentDate(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case int64:
+		return time.Unix(v, 0), true
+	case int:
+		return time.Unix(int64(v), 0), true
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// coldArchive returns the collection's archive, opening it on first use
+// Must be called with c.mu held for writing
+func (c *Collection) coldArchive() (*coldArchive, error) {
+	if archive := c.archive.Load(); archive != nil {
+		return archive, nil
+	}
+	if c.db == nil {
+		return nil, fmt.Errorf("tiering requires a collection of a database")
+	}
+	archive, err := openColdArchive(archiveDir(c.db.dataDir, c.name))
+	if err != nil {
+		return nil, err
+	}
+	c.archive.Store(archive)
+	return archive, nil
+}
+
+// coldDocuments returns the documents in the cold tier
+// Must be called with c.mu held
+func (c *Collection) coldDocuments() ([]*document.Document, error) {
+	archive := c.archive.Load()
+	if archive == nil || archive.count() == 0 {
+		return nil, nil
+	}
+	return archive.documents()
+}
+
+// coldCount returns the number of documents in the cold tier. It doesn't
+// need the collection lock.
+func (c *Collection) coldCount() int {
+	archive := c.archive.Load()
+	if archive == nil {
+		return 0
+	}
+	return archive.count()
+}
+
+// Archive moves the documents matching the filter to the cold tier: a
+// compressed archive outside the buffer pool. Cold documents are still
+// returned by reads, which merge both tiers, but are slower to read, and are
+// moved back to the hot tier before they are updated or deleted. Archiving
+// isn't a write to the documents, so it emits no change events.
+func (c *Collection) Archive(filter map[string]interface{}) (int, error) {
+	q := query.NewQuery(filter)
+	return c.archiveWhere(func(doc *document.Document) bool {
+		matches, err := q.Matches(doc)
+		return err == nil && matches
+	})
+}
+
+// ArchiveOlderThan moves the documents whose date in field is before cutoff
+// to the cold tier (see Archive). The field may hold a time.Time, Unix
+// seconds or an RFC 3339 string.
+func (c *Collection) ArchiveOlderThan(field string, cutoff time.Time) (int, error) {
+	return c.archiveWhere(func(doc *document.Document) bool {
+		value, exists := doc.Get(field)
+		if !exists {
+			return false
+		}
+		date, ok := documentDate(value)
+		return ok && date.Before(cutoff)
+	})
+}
+
+// archiveWhere moves the hot documents match accepts to the cold tier
+func (c *Collection) archiveWhere(match func(*document.Document) bool) (int, error) {
+	if err := c.checkWritable(); err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	archive, err := c.coldArchive()
+	if err != nil {
+		return 0, err
+	}
+
+	docs, err := c.getAllDocuments()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load documents: %w", err)
+	}
+	cold := make([]*document.Document, 0)
+	for _, doc := range docs {
+		if match(doc) {
+			cold = append(cold, doc)
+		}
+	}
+	if len(cold) == 0 {
+		return 0, nil
+	}
+
+	// The archive is written first, so a failure leaves the documents hot
+	if err := archive.add(cold); err != nil {
+		return 0, err
+	}
+	for _, doc := range cold {
+		idVal, _ := doc.Get("_id")
+		id := fmt.Sprintf("%v", idVal)
+		if err := c.docStore.Delete(id); err != nil {
+			return 0, fmt.Errorf("failed to delete archived document from disk: %w", err)
+		}
+		c.unindexDocument(id, doc)
+	}
+	c.queryCache.Clear()
+	return len(cold), nil
+}
+
+// Unarchive moves the cold documents matching the filter back to the hot
+// tier
+func (c *Collection) Unarchive(filter map[string]interface{}) (int, error) {
+	if err := c.checkWritable(); err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.thawMatching(filter)
+}
+
+// thaw moves the cold documents matching the filter to the hot tier, so a
+// write can change them
+func (c *Collection) thaw(filter map[string]interface{}) error {
+	if c.coldCount() == 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.thawMatching(filter)
+	return err
+}
+
+// thawMatching moves the cold documents matching the filter to the hot tier
+// Must be called with c.mu held for writing
+func (c *Collection) thawMatching(filter map[string]interface{}) (int, error) {
+	cold, err := c.coldDocuments()
+	if err != nil || len(cold) == 0 {
+		return 0, err
+	}
+
+	matched, err := query.NewExecutor(cold).Execute(query.NewQuery(filter))
+	if err != nil {
+		return 0, err
+	}
+	return len(matched), c.thawDocuments(matched)
+}
+
+// thawID moves a cold document to the hot tier, if it is cold
+// Must be called with c.mu held for writing
+func (c *Collection) thawID(id string) error {
+	if c.coldCount() == 0 {
+		return nil
+	}
+	cold, err := c.coldDocuments()
+	if err != nil {
+		return err
+	}
+	for _, doc := range cold {
+		if idVal, _ := doc.Get("_id"); fmt.Sprintf("%v", idVal) == id {
+			return c.thawDocuments([]*document.Document{doc})
+		}
+	}
+	return nil
+}
+
+// thawDocuments stores cold documents in the hot tier and removes them from
+// the archive
+// Must be called with c.mu held for writing
+func (c *Collection) thawDocuments(docs []*document.Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		idVal, _ := doc.Get("_id")
+		id := fmt.Sprintf("%v", idVal)
+		if err := c.indexDocument(id, doc); err != nil {
+			return err
+		}
+		if err := c.docStore.InsertThawed(id, doc); err != nil {
+			c.unindexDocument(id, doc)
+			return fmt.Errorf("failed to store document: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := c.archive.Load().remove(ids); err != nil {
+		return err
+	}
+	c.queryCache.Clear()
+	return nil
+}
+
+// SetTieringPolicy sets the policy the database applies periodically to
+// move old documents to the cold tier (nil removes it)
+func (c *Collection) SetTieringPolicy(policy *TieringPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tieringPolicy = policy
+}
+
+// TieringPolicy returns the collection's tiering policy, nil if it has none
+func (c *Collection) TieringPolicy() *TieringPolicy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tieringPolicy
+}
+
+// ApplyTieringPolicy archives the documents the tiering policy considers
+// cold now, returning how many were moved
+func (c *Collection) ApplyTieringPolicy() (int, error) {
+	policy := c.TieringPolicy()
+	if policy == nil {
+		return 0, nil
+	}
+	return c.ArchiveOlderThan(policy.Field, time.Now().Add(-policy.ColdAfter))
+}
+
+// ArchiveStats returns statistics of the collection's cold tier
+func (c *Collection) ArchiveStats() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	archive := c.archive.Load()
+	if archive == nil {
+		return map[string]interface{}{
+			"documents":        0,
+			"segments":         0,
+			"compressed_bytes": int64(0),
+		}
+	}
+	return archive.stats()
+}
+
+// applyTieringPolicies runs the tiering policies of all collections, except on
+// a secondary, whose documents only change by applying the primary's oplog
+func (db *Database) applyTieringPolicies() {
+	if db.secondary.Load() {
+		return
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, coll := range db.collections {
+		coll.ApplyTieringPolicy()
+	}
+}
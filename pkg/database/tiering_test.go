@@ -0,0 +1,174 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/query"
+)
+
+// insertReadings inserts one reading per hour, the oldest first
+func insertReadings(t *testing.T, coll *Collection, now time.Time, hours int) {
+	t.Helper()
+	for i := hours; i > 0; i-- {
+		_, err := coll.InsertOne(map[string]interface{}{
+			"seq": int64(hours - i),
+			"ts":  now.Add(-time.Duration(i) * time.Hour).Unix(),
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+}
+
+func TestArchiveOlderThan(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	coll := db.Collection("readings")
+	if err := coll.CreateIndex("seq", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	insertReadings(t, coll, now, 10)
+
+	moved, err := coll.ArchiveOlderThan("ts", now.Add(-5*time.Hour-time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to archive: %v", err)
+	}
+	if moved != 5 {
+		t.Fatalf("Expected 5 archived documents, got %d", moved)
+	}
+	if coll.docStore.Count() != 5 {
+		t.Errorf("Expected 5 hot documents, got %d", coll.docStore.Count())
+	}
+	stats := coll.ArchiveStats()
+	if stats["documents"] != 5 || stats["segments"] != 1 {
+		t.Errorf("Expected 5 documents in 1 segment, got %v", stats)
+	}
+
+	// Reads merge both tiers
+	count, _ := coll.Count(map[string]interface{}{})
+	if count != 10 {
+		t.Errorf("Expected 10 documents, got %d", count)
+	}
+	doc, err := coll.FindOne(map[string]interface{}{"seq": int64(1)})
+	if err != nil {
+		t.Fatalf("Expected cold document through the index field: %v", err)
+	}
+	if seq, _ := doc.Get("seq"); seq != int64(1) {
+		t.Errorf("Expected seq 1, got %v", seq)
+	}
+
+	results, err := coll.FindWithOptions(map[string]interface{}{"seq": map[string]interface{}{"$gte": int64(3)}}, &QueryOptions{
+		Sort:  []query.SortField{{Field: "seq", Ascending: true}},
+		Limit: 4,
+	})
+	if err != nil {
+		t.Fatalf("Failed to find: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+	for i, doc := range results {
+		if seq, _ := doc.Get("seq"); seq != int64(3+i) {
+			t.Errorf("Expected seq %d at %d, got %v", 3+i, i, seq)
+		}
+	}
+
+	agg, err := coll.Aggregate([]map[string]interface{}{
+		{"$group": map[string]interface{}{"_id": nil, "n": map[string]interface{}{"$sum": 1}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if n, _ := agg[0].Get("n"); n != float64(10) {
+		t.Errorf("Expected aggregation over 10 documents, got %v", n)
+	}
+}
+
+func TestArchiveWritesThaw(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("readings")
+	insertReadings(t, coll, time.Now(), 4)
+	if _, err := coll.Archive(map[string]interface{}{}); err != nil {
+		t.Fatalf("Failed to archive: %v", err)
+	}
+
+	err = coll.UpdateOne(map[string]interface{}{"seq": int64(0)}, map[string]interface{}{"$set": map[string]interface{}{"checked": true}})
+	if err != nil {
+		t.Fatalf("Failed to update cold document: %v", err)
+	}
+	doc, err := coll.FindOne(map[string]interface{}{"seq": int64(0)})
+	if err != nil {
+		t.Fatalf("Failed to find: %v", err)
+	}
+	if checked, _ := doc.Get("checked"); checked != true {
+		t.Errorf("Expected updated document, got %v", doc.ToMap())
+	}
+
+	if err := coll.DeleteOne(map[string]interface{}{"seq": int64(1)}); err != nil {
+		t.Fatalf("Failed to delete cold document: %v", err)
+	}
+	count, _ := coll.Count(map[string]interface{}{})
+	if count != 3 {
+		t.Errorf("Expected 3 documents, got %d", count)
+	}
+	if cold := coll.ArchiveStats()["documents"]; cold != 2 {
+		t.Errorf("Expected 2 cold documents, got %v", cold)
+	}
+
+	thawed, err := coll.Unarchive(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Failed to unarchive: %v", err)
+	}
+	if thawed != 2 || coll.docStore.Count() != 3 {
+		t.Errorf("Expected 2 thawed and 3 hot documents, got %d and %d", thawed, coll.docStore.Count())
+	}
+}
+
+func TestTieringPolicy(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	coll := db.Collection("readings")
+	insertReadings(t, coll, time.Now(), 6)
+	coll.SetTieringPolicy(&TieringPolicy{Field: "ts", ColdAfter: 90 * time.Minute})
+
+	moved, err := coll.ApplyTieringPolicy()
+	if err != nil {
+		t.Fatalf("Failed to apply tiering policy: %v", err)
+	}
+	if moved != 5 {
+		t.Errorf("Expected 5 documents older than 90 minutes, got %d", moved)
+	}
+	db.Close()
+
+	// The cold tier survives a restart
+	db, err = Open(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+	if cold := db.Collection("readings").ArchiveStats()["documents"]; cold != 5 {
+		t.Errorf("Expected 5 cold documents after reopening, got %v", cold)
+	}
+
+	if err := db.DropCollection("readings"); err != nil {
+		t.Fatalf("Failed to drop collection: %v", err)
+	}
+	if cold := db.Collection("readings").ArchiveStats()["documents"]; cold != 0 {
+		t.Errorf("Expected dropped archive, got %v cold documents", cold)
+	}
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/audit"
+)
+
+// TruncateOptions configures TruncateWithOptions
+type TruncateOptions struct {
+	DropIndexes bool // Drop all indexes except _id_ instead of emptying them
+}
+
+// Truncate deletes all documents of the collection in one operation. Unlike
+// DeleteMany with an empty filter, it doesn't visit each document: the
+// document pages are returned to the page store, the indexes are emptied
+// and a single truncate entry is logged instead of a delete per document.
+// Index definitions are kept. Delete hooks are not run.
+func (c *Collection) Truncate() error {
+	return c.TruncateWithOptions(nil)
+}
+
+// TruncateWithOptions truncates the collection (see Truncate), optionally
+// dropping its indexes
+func (c *Collection) TruncateWithOptions(opts *TruncateOptions) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	start := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.truncate(opts != nil && opts.DropIndexes)
+	if c.auditLogger != nil {
+		c.auditLogger.LogOperation(audit.OperationTruncateCollection, c.name, c.database, "", err == nil, time.Since(start), err, nil)
+	}
+	if err != nil {
+		return err
+	}
+	c.logTruncate()
+	return nil
+}
+
+// truncate deletes all documents from storage, the indexes and the cold tier
+// Must be called with c.mu held
+func (c *Collection) truncate(dropIndexes bool) error {
+	if err := c.docStore.Truncate(); err != nil {
+		return fmt.Errorf("failed to truncate documents: %w", err)
+	}
+	if archive := c.archive.Swap(nil); archive != nil {
+		if err := archive.destroy(); err != nil {
+			return fmt.Errorf("failed to remove archive: %w", err)
+		}
+	}
+
+	for name, idx := range c.indexes {
+		if dropIndexes && name != "_id_" {
+			delete(c.indexes, name)
+			c.forgetIndexUsage(name)
+			continue
+		}
+		idx.Clear()
+	}
+	for name, textIdx := range c.textIndexes {
+		if dropIndexes {
+			delete(c.textIndexes, name)
+			c.forgetIndexUsage(name)
+			continue
+		}
+		textIdx.Clear()
+	}
+	for name, geoIdx := range c.geoIndexes {
+		if dropIndexes {
+			delete(c.geoIndexes, name)
+			c.forgetIndexUsage(name)
+			continue
+		}
+		geoIdx.Clear()
+	}
+	for name, ttlIdx := range c.ttlIndexes {
+		if dropIndexes {
+			delete(c.ttlIndexes, name)
+			c.forgetIndexUsage(name)
+			continue
+		}
+		ttlIdx.Clear()
+	}
+
+	c.queryCache.Clear()
+	return nil
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/changestream"
+)
+
+func TestTruncate(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	if err := users.CreateIndex("email", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := users.CreateTextIndex([]string{"bio"}); err != nil {
+		t.Fatalf("Failed to create text index: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		_, err := users.InsertOne(map[string]interface{}{
+			"email": string(rune('a'+i%26)) + string(rune('a'+i/26)) + "@example.com",
+			"bio":   "database engineer",
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+	indexCount := len(users.ListIndexes())
+
+	cs, err := users.Watch(watchOptions())
+	if err != nil {
+		t.Fatalf("Failed to watch collection: %v", err)
+	}
+	defer cs.Close()
+
+	if err := users.Truncate(); err != nil {
+		t.Fatalf("Failed to truncate: %v", err)
+	}
+
+	count, _ := users.Count(map[string]interface{}{})
+	if count != 0 {
+		t.Errorf("Expected 0 documents after truncate, got %d", count)
+	}
+	if len(users.ListIndexes()) != indexCount {
+		t.Errorf("Expected %d indexes after truncate, got %d", indexCount, len(users.ListIndexes()))
+	}
+	if results, _ := users.TextSearch("engineer", nil); len(results) != 0 {
+		t.Errorf("Expected empty text index, got %d results", len(results))
+	}
+
+	// A single event replaces the per-document deletes
+	event := nextEvent(t, cs)
+	if event.OperationType != changestream.OperationTypeTruncate {
+		t.Errorf("Expected truncate event, got %s", event.OperationType)
+	}
+
+	// The unique index was emptied, so its keys can be used again
+	if _, err := users.InsertOne(map[string]interface{}{"email": "aa@example.com"}); err != nil {
+		t.Fatalf("Failed to insert after truncate: %v", err)
+	}
+	if _, err := users.InsertOne(map[string]interface{}{"email": "aa@example.com"}); err == nil {
+		t.Error("Expected unique index to be enforced after truncate")
+	}
+	doc, err := users.FindOne(map[string]interface{}{"email": "aa@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to find document inserted after truncate: %v", err)
+	}
+	if bio, exists := doc.Get("bio"); exists {
+		t.Errorf("Expected new document, got bio %v", bio)
+	}
+}
+
+func TestTruncateDropIndexes(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := db.Collection("users")
+	if err := users.CreateIndex("email", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if _, err := users.InsertOne(map[string]interface{}{"email": "a@example.com"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	if err := users.TruncateWithOptions(&TruncateOptions{DropIndexes: true}); err != nil {
+		t.Fatalf("Failed to truncate: %v", err)
+	}
+
+	indexes := users.ListIndexes()
+	if len(indexes) != 1 || indexes[0]["name"] != "_id_" {
+		t.Errorf("Expected only the _id_ index, got %v", indexes)
+	}
+}
@@ -27,7 +27,7 @@ func BenchmarkCreateTTLIndex(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		coll.CreateTTLIndex("createdAt", 3600)
+		coll.CreateTTLIndex("createdAt", 3600*time.Second)
 		if i < b.N-1 {
 			coll.DropIndex("createdAt_ttl")
 		}
@@ -44,7 +44,7 @@ func BenchmarkTTLInsert(b *testing.B) {
 	coll := db.Collection("bench_logs")
 
 	// Create TTL index first
-	coll.CreateTTLIndex("timestamp", 300)
+	coll.CreateTTLIndex("timestamp", 300*time.Second)
 
 	now := time.Now()
 
@@ -65,7 +65,7 @@ func BenchmarkTTLUpdate(b *testing.B) {
 	defer db.Close()
 
 	coll := db.Collection("bench_events")
-	coll.CreateTTLIndex("updatedAt", 600)
+	coll.CreateTTLIndex("updatedAt", 600*time.Second)
 
 	// Insert initial documents
 	now := time.Now()
@@ -98,7 +98,7 @@ func BenchmarkTTLCleanup(b *testing.B) {
 	defer db.Close()
 
 	coll := db.Collection("bench_temp")
-	coll.CreateTTLIndex("expiresAt", 1)
+	coll.CreateTTLIndex("expiresAt", 1*time.Second)
 
 	// Insert mix of expired and non-expired documents
 	pastTime := time.Now().Add(-10 * time.Second)
@@ -142,7 +142,7 @@ func BenchmarkTTLCleanupLargeDataset(b *testing.B) {
 	defer db.Close()
 
 	coll := db.Collection("bench_large")
-	coll.CreateTTLIndex("timestamp", 60)
+	coll.CreateTTLIndex("timestamp", 60*time.Second)
 
 	// Insert 10,000 documents
 	now := time.Now()
@@ -186,7 +186,7 @@ func BenchmarkTTLGetExpiredDocuments(b *testing.B) {
 	defer db.Close()
 
 	coll := db.Collection("bench_check")
-	coll.CreateTTLIndex("createdAt", 30)
+	coll.CreateTTLIndex("createdAt", 30*time.Second)
 
 	// Insert documents with various timestamps
 	now := time.Now()
@@ -216,9 +216,9 @@ func BenchmarkTTLMultipleIndexes(b *testing.B) {
 	coll := db.Collection("bench_multi")
 
 	// Create multiple TTL indexes
-	coll.CreateTTLIndex("createdAt", 300)
-	coll.CreateTTLIndex("updatedAt", 600)
-	coll.CreateTTLIndex("expiresAt", 900)
+	coll.CreateTTLIndex("createdAt", 300*time.Second)
+	coll.CreateTTLIndex("updatedAt", 600*time.Second)
+	coll.CreateTTLIndex("expiresAt", 900*time.Second)
 
 	now := time.Now()
 
@@ -241,7 +241,7 @@ func BenchmarkTTLDelete(b *testing.B) {
 	defer db.Close()
 
 	coll := db.Collection("bench_delete")
-	coll.CreateTTLIndex("timestamp", 60)
+	coll.CreateTTLIndex("timestamp", 60*time.Second)
 
 	// Pre-populate for each iteration
 	now := time.Now()
@@ -272,7 +272,7 @@ func BenchmarkTTLIndexComparisonWithout(b *testing.B) {
 	collWithoutTTL := db.Collection("without_ttl")
 
 	// Create TTL index on one collection
-	collWithTTL.CreateTTLIndex("timestamp", 300)
+	collWithTTL.CreateTTLIndex("timestamp", 300*time.Second)
 
 	now := time.Now()
 
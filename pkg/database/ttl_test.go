@@ -29,7 +29,7 @@ func TestCreateTTLIndex(t *testing.T) {
 	}
 
 	// Create TTL index with 60 second expiration
-	err = coll.CreateTTLIndex("createdAt", 60)
+	err = coll.CreateTTLIndex("createdAt", 60*time.Second)
 	if err != nil {
 		t.Fatalf("Failed to create TTL index: %v", err)
 	}
@@ -65,13 +65,13 @@ func TestTTLIndexDuplicateError(t *testing.T) {
 	coll := db.Collection("data")
 
 	// Create first TTL index
-	err := coll.CreateTTLIndex("timestamp", 300)
+	err := coll.CreateTTLIndex("timestamp", 300*time.Second)
 	if err != nil {
 		t.Fatalf("Failed to create first TTL index: %v", err)
 	}
 
 	// Try to create duplicate
-	err = coll.CreateTTLIndex("timestamp", 300)
+	err = coll.CreateTTLIndex("timestamp", 300*time.Second)
 	if err == nil {
 		t.Error("Expected error when creating duplicate TTL index")
 	}
@@ -87,7 +87,7 @@ func TestTTLIndexMaintenance(t *testing.T) {
 	coll := db.Collection("logs")
 
 	// Create TTL index first
-	err := coll.CreateTTLIndex("timestamp", 5)
+	err := coll.CreateTTLIndex("timestamp", 5*time.Second)
 	if err != nil {
 		t.Fatalf("Failed to create TTL index: %v", err)
 	}
@@ -153,7 +153,7 @@ func TestTTLExpirationDetection(t *testing.T) {
 	coll := db.Collection("temp")
 
 	// Create TTL index with 2 second expiration
-	err := coll.CreateTTLIndex("expiresAt", 2)
+	err := coll.CreateTTLIndex("expiresAt", 2*time.Second)
 	if err != nil {
 		t.Fatalf("Failed to create TTL index: %v", err)
 	}
@@ -203,7 +203,7 @@ func TestCleanupExpiredDocuments(t *testing.T) {
 	coll := db.Collection("sessions")
 
 	// Create TTL index with 1 second expiration
-	err := coll.CreateTTLIndex("createdAt", 1)
+	err := coll.CreateTTLIndex("createdAt", 1*time.Second)
 	if err != nil {
 		t.Fatalf("Failed to create TTL index: %v", err)
 	}
@@ -261,12 +261,12 @@ func TestMultipleTTLIndexes(t *testing.T) {
 	coll := db.Collection("events")
 
 	// Create multiple TTL indexes on different fields
-	err := coll.CreateTTLIndex("createdAt", 60)
+	err := coll.CreateTTLIndex("createdAt", 60*time.Second)
 	if err != nil {
 		t.Fatalf("Failed to create first TTL index: %v", err)
 	}
 
-	err = coll.CreateTTLIndex("expiresAt", 120)
+	err = coll.CreateTTLIndex("expiresAt", 120*time.Second)
 	if err != nil {
 		t.Fatalf("Failed to create second TTL index: %v", err)
 	}
@@ -305,7 +305,7 @@ func TestTTLWithDifferentTimeFormats(t *testing.T) {
 	coll := db.Collection("mixed")
 
 	// Create TTL index
-	err := coll.CreateTTLIndex("timestamp", 300)
+	err := coll.CreateTTLIndex("timestamp", 300*time.Second)
 	if err != nil {
 		t.Fatalf("Failed to create TTL index: %v", err)
 	}
@@ -348,7 +348,7 @@ func TestDropTTLIndex(t *testing.T) {
 	coll := db.Collection("test")
 
 	// Create TTL index
-	err := coll.CreateTTLIndex("expires", 60)
+	err := coll.CreateTTLIndex("expires", 60*time.Second)
 	if err != nil {
 		t.Fatalf("Failed to create TTL index: %v", err)
 	}
@@ -380,7 +380,7 @@ func TestTTLCleanupNoExpiredDocs(t *testing.T) {
 	coll := db.Collection("fresh")
 
 	// Create TTL index with long expiration
-	err := coll.CreateTTLIndex("createdAt", 3600)
+	err := coll.CreateTTLIndex("createdAt", 3600*time.Second)
 	if err != nil {
 		t.Fatalf("Failed to create TTL index: %v", err)
 	}
@@ -442,3 +442,95 @@ func TestTTLCleanupNoIndexes(t *testing.T) {
 		t.Errorf("Expected 2 documents, got %d", len(docs))
 	}
 }
+
+func TestTTLReaper(t *testing.T) {
+	config := DefaultConfig(t.TempDir())
+	config.TTLInterval = 10 * time.Millisecond
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	sessions := db.Collection("sessions")
+	if err := sessions.CreateIndex("token", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := sessions.CreateTTLIndex("lastSeen", time.Hour); err != nil {
+		t.Fatalf("Failed to create TTL index: %v", err)
+	}
+	if err := sessions.CreateTTLIndex("expiresAt", -time.Second); err == nil {
+		t.Error("Expected an error for a negative TTL")
+	}
+
+	now := time.Now()
+	sessions.InsertOne(map[string]interface{}{"token": "stale", "lastSeen": now.Add(-2 * time.Hour)})
+	sessions.InsertOne(map[string]interface{}{"token": "stale-unix", "lastSeen": now.Add(-90 * time.Minute).Unix()})
+	sessions.InsertOne(map[string]interface{}{"token": "fresh", "lastSeen": now.Add(-time.Minute)})
+	sessions.InsertOne(map[string]interface{}{"token": "no-timestamp"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		count, _ := sessions.Count(nil)
+		if count == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the reaper to leave 2 documents, found %d", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	results, _ := sessions.Find(nil)
+	tokens := map[interface{}]bool{}
+	for _, doc := range results {
+		token, _ := doc.Get("token")
+		tokens[token] = true
+	}
+	if !tokens["fresh"] || !tokens["no-timestamp"] {
+		t.Errorf("Expected fresh and no-timestamp to remain, got %v", tokens)
+	}
+
+	// The index entries of expired documents are gone, so their keys are free
+	if idx := sessions.indexes["token_1"]; idx.Size() != 2 {
+		t.Errorf("Expected 2 token index entries, got %d", idx.Size())
+	}
+	if _, err := sessions.InsertOne(map[string]interface{}{"token": "stale", "lastSeen": time.Now()}); err != nil {
+		t.Errorf("Expected the token of an expired document to be reusable: %v", err)
+	}
+}
+
+func TestTTLCleanupSkipsPendingTransactions(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	sessions := db.Collection("sessions")
+	sessions.CreateTTLIndex("lastSeen", time.Hour)
+	sessions.InsertOne(map[string]interface{}{"token": "renewed", "lastSeen": time.Now().Add(-2 * time.Hour)})
+	sessions.InsertOne(map[string]interface{}{"token": "stale", "lastSeen": time.Now().Add(-2 * time.Hour)})
+
+	// A transaction renews the session before the reaper runs
+	session := db.StartSession()
+	if err := session.UpdateOne("sessions", map[string]interface{}{"token": "renewed"}, map[string]interface{}{
+		"$set": map[string]interface{}{"lastSeen": time.Now()},
+	}); err != nil {
+		t.Fatalf("Failed to update in transaction: %v", err)
+	}
+
+	if deleted := sessions.CleanupExpiredDocuments(); deleted != 1 {
+		t.Errorf("Expected only the stale document to be deleted, got %d", deleted)
+	}
+	if err := session.CommitTransaction(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	if deleted := sessions.CleanupExpiredDocuments(); deleted != 0 {
+		t.Errorf("Expected the renewed document to survive, got %d deleted", deleted)
+	}
+	if doc, err := sessions.FindOne(map[string]interface{}{"token": "renewed"}); err != nil || doc == nil {
+		t.Errorf("Expected the renewed document to remain: %v", err)
+	}
+}
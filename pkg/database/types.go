@@ -5,7 +5,33 @@ import "github.com/mnohosten/laura-db/pkg/query"
 // QueryOptions holds options for queries
 type QueryOptions struct {
 	Projection map[string]bool
+	Meta       map[string]string // Metadata fields to add, e.g. {"score": "textScore"}
 	Sort       []query.SortField
 	Limit      int
 	Skip       int
+	Hint       string // Index to use, or query.NaturalHint for a collection scan
+}
+
+// UpdateOptions holds options for UpdateOneWithOptions and transactional
+// updates
+type UpdateOptions struct {
+	Upsert bool // Insert a document built from the filter when nothing matches
+	Multi  bool // Update every matching document instead of the first; transactions only, rejected elsewhere
+
+	// Durability of the update; ignored in transactions, which are made
+	// durable by their commit
+	WriteOptions
+}
+
+// UpdateResult describes the outcome of an update
+type UpdateResult struct {
+	MatchedCount  int
+	ModifiedCount int         // Matched documents the update changed
+	UpsertedID    interface{} // _id of the inserted document when an upsert happened
+}
+
+// DeleteResult describes the outcome of a delete of several documents
+type DeleteResult struct {
+	MatchedCount int
+	DeletedCount int
 }
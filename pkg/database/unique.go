@@ -0,0 +1,196 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/index"
+)
+
+// duplicateKeyError describes a document whose key in a unique index is
+// already taken
+func (c *Collection) duplicateKeyError(idx *index.Index, d *document.Document) *DuplicateKeyError {
+	fields := idx.FieldPaths()
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		values[i], _ = d.Get(field)
+	}
+	return &DuplicateKeyError{
+		Collection: c.name,
+		Index:      idx.Name(),
+		Fields:     fields,
+		Values:     values,
+	}
+}
+
+// duplicateIDError describes a document whose _id is already taken
+func (c *Collection) duplicateIDError(id string) *DuplicateKeyError {
+	return &DuplicateKeyError{
+		Collection: c.name,
+		Index:      "_id_",
+		Fields:     []string{"_id"},
+		Values:     []interface{}{id},
+	}
+}
+
+// checkUnique returns a *DuplicateKeyError if storing d as document id would
+// take a key another document holds in a unique index. Documents in released
+// have their keys given up, e.g. by a transaction updating or deleting them.
+// Must be called with c.mu held
+func (c *Collection) checkUnique(id string, d *document.Document, released map[string]bool) error {
+	for _, idx := range c.indexes {
+		if !idx.IsUnique() {
+			continue
+		}
+		key, included := c.indexKeyFor(idx, d)
+		if !included {
+			continue
+		}
+		if owner, exists := idx.Search(key); exists {
+			ownerID := fmt.Sprintf("%v", owner)
+			if ownerID != id && !released[ownerID] {
+				return c.duplicateKeyError(idx, d)
+			}
+		}
+	}
+	return nil
+}
+
+// uniqueKeys returns the MVCC keys standing for the entries d takes in the
+// unique indexes of the collection. A transaction reserves them alongside
+// the document, so two transactions taking the same entry conflict on
+// commit. The _id_ index is left out: the document key already stands for
+// its entry.
+// Must be called with c.mu held
+func (c *Collection) uniqueKeys(d *document.Document) []string {
+	keys := make([]string, 0)
+	for name, idx := range c.indexes {
+		if !idx.IsUnique() || name == "_id_" {
+			continue
+		}
+		if key, included := c.indexKeyFor(idx, d); included {
+			keys = append(keys, fmt.Sprintf("%s:$unique:%s:%v", c.name, idx.Name(), key))
+		}
+	}
+	return keys
+}
+
+// reserveUniqueKeys checks that a document written in the transaction takes
+// no unique index entry held by another document, and reserves the entries
+// it takes in the transaction
+func (s *Session) reserveUniqueKeys(coll *Collection, id string, d *document.Document) error {
+	coll.mu.RLock()
+	err := coll.checkUnique(id, d, s.touched(coll.name))
+	keys := coll.uniqueKeys(d)
+	coll.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := s.db.txnMgr.Reserve(s.txn, key, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touched returns the IDs of the existing documents of a collection the
+// transaction updates or deletes
+func (s *Session) touched(collName string) map[string]bool {
+	ids := make(map[string]bool)
+	for _, op := range s.operations {
+		if op.collection == collName && op.opType != "insert" {
+			ids[op.docID] = true
+		}
+	}
+	return ids
+}
+
+// lockCollections write-locks the collections the operations write to, in
+// name order, and returns them with the function that unlocks them
+func (s *Session) lockCollections(operations []sessionOperation) (map[string]*Collection, func()) {
+	names := make([]string, 0)
+	colls := make(map[string]*Collection)
+	for _, op := range operations {
+		if _, seen := colls[op.collection]; !seen {
+			colls[op.collection] = s.db.Collection(op.collection)
+			names = append(names, op.collection)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		colls[name].mu.Lock()
+	}
+	return colls, func() {
+		for _, name := range names {
+			colls[name].mu.Unlock()
+		}
+	}
+}
+
+// checkCommit validates the net operations of the transaction against the
+// collections before any is applied: inserted _ids must be free, documents
+// must fit the size limit and their tenants' quotas, and unique index entries
+// must not collide with other documents or with each other.
+// Must be called with the collections locked by lockCollections
+func (s *Session) checkCommit(colls map[string]*Collection, operations []sessionOperation) error {
+	released := make(map[string]map[string]bool)
+	for _, op := range operations {
+		if op.opType == "insert" {
+			continue
+		}
+		if released[op.collection] == nil {
+			released[op.collection] = make(map[string]bool)
+		}
+		released[op.collection][op.docID] = true
+	}
+
+	taken := make(map[string]string)
+	for _, op := range operations {
+		if op.opType == "delete" {
+			continue
+		}
+		coll := colls[op.collection]
+		if op.opType == "insert" && coll.docStore.Exists(op.docID) {
+			return coll.duplicateIDError(op.docID)
+		}
+		if err := coll.checkDocument(op.doc); err != nil {
+			return err
+		}
+		if err := coll.checkUnique(op.docID, op.doc, released[op.collection]); err != nil {
+			return err
+		}
+
+		// Two documents of the transaction can't take the same entry either
+		for _, idx := range coll.indexes {
+			if !idx.IsUnique() {
+				continue
+			}
+			key, included := coll.indexKeyFor(idx, op.doc)
+			if !included {
+				continue
+			}
+			entry := fmt.Sprintf("%s:%s:%v", op.collection, idx.Name(), key)
+			if owner, exists := taken[entry]; exists && owner != op.docID {
+				return coll.duplicateKeyError(idx, op.doc)
+			}
+			taken[entry] = op.docID
+		}
+	}
+
+	changes := make([]quotaChange, 0, len(operations))
+	for _, op := range operations {
+		var change quotaChange
+		if op.opType != "insert" {
+			change.old = colls[op.collection].docStore.quotaCharge(op.docID)
+		}
+		if op.opType != "delete" {
+			change.doc = op.doc
+		}
+		changes = append(changes, change)
+	}
+	return s.db.quota.admit(changes, true)
+}
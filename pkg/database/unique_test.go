@@ -0,0 +1,166 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// openTenantUsers opens a database whose users collection is unique on
+// {tenant_id, email}
+func openTenantUsers(t *testing.T) (*Database, *Collection) {
+	t.Helper()
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	users := db.Collection("users")
+	if err := users.CreateCompoundIndex([]string{"tenant_id", "email"}, true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	return db, users
+}
+
+func TestDuplicateKeyError(t *testing.T) {
+	db, users := openTenantUsers(t)
+	defer db.Close()
+
+	if _, err := users.InsertOne(map[string]interface{}{"tenant_id": "t1", "email": "a@example.com"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	// The same email in another tenant is allowed
+	if _, err := users.InsertOne(map[string]interface{}{"_id": "u2", "tenant_id": "t2", "email": "a@example.com"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	_, err := users.InsertOne(map[string]interface{}{"tenant_id": "t1", "email": "a@example.com"})
+	var dupErr *DuplicateKeyError
+	if !errors.As(err, &dupErr) {
+		t.Fatalf("Expected DuplicateKeyError, got %v", err)
+	}
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Error("Expected error to match ErrDuplicateKey")
+	}
+	if dupErr.Collection != "users" || dupErr.Index != "tenant_id_email_1" {
+		t.Errorf("Expected users/tenant_id_email_1, got %s/%s", dupErr.Collection, dupErr.Index)
+	}
+	if len(dupErr.Fields) != 2 || dupErr.Fields[0] != "tenant_id" || dupErr.Fields[1] != "email" {
+		t.Errorf("Expected fields [tenant_id email], got %v", dupErr.Fields)
+	}
+	if len(dupErr.Values) != 2 || dupErr.Values[0] != "t1" || dupErr.Values[1] != "a@example.com" {
+		t.Errorf("Expected values [t1 a@example.com], got %v", dupErr.Values)
+	}
+
+	// Moving a document onto a taken pair is rejected as well
+	err = users.UpdateOne(map[string]interface{}{"_id": "u2"}, map[string]interface{}{
+		"$set": map[string]interface{}{"tenant_id": "t1"},
+	})
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("Expected ErrDuplicateKey on update, got %v", err)
+	}
+	doc, _ := users.FindOne(map[string]interface{}{"_id": "u2"})
+	if tenant, _ := doc.Get("tenant_id"); tenant != "t2" {
+		t.Errorf("Expected rejected update to leave tenant t2, got %v", tenant)
+	}
+}
+
+func TestUniqueConstraintAcrossSessions(t *testing.T) {
+	db, users := openTenantUsers(t)
+	defer db.Close()
+
+	doc := map[string]interface{}{"tenant_id": "t1", "email": "a@example.com"}
+	s1 := db.StartSession()
+	s2 := db.StartSession()
+	if _, err := s1.InsertOne("users", doc); err != nil {
+		t.Fatalf("Failed to insert in first session: %v", err)
+	}
+	if _, err := s2.InsertOne("users", doc); err != nil {
+		t.Fatalf("Failed to insert in second session: %v", err)
+	}
+
+	if err := s1.CommitTransaction(); err != nil {
+		t.Fatalf("Failed to commit first session: %v", err)
+	}
+	err := s2.CommitTransaction()
+	var dupErr *DuplicateKeyError
+	if !errors.As(err, &dupErr) {
+		t.Fatalf("Expected DuplicateKeyError on commit, got %v", err)
+	}
+	if count, _ := users.Count(map[string]interface{}{}); count != 1 {
+		t.Errorf("Expected 1 document, got %d", count)
+	}
+
+	// Once committed, the pair is rejected when the write joins a transaction
+	s3 := db.StartSession()
+	if _, err := s3.InsertOne("users", doc); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("Expected ErrDuplicateKey on insert, got %v", err)
+	}
+	s3.AbortTransaction()
+}
+
+func TestUniqueConstraintConcurrentTransactions(t *testing.T) {
+	db, users := openTenantUsers(t)
+	defer db.Close()
+
+	const writers = 8
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	committed := 0
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := db.WithTransaction(func(s *Session) error {
+				_, err := s.InsertOne("users", map[string]interface{}{"tenant_id": "t1", "email": "a@example.com"})
+				return err
+			})
+			if err == nil {
+				mu.Lock()
+				committed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if committed != 1 {
+		t.Errorf("Expected exactly 1 committed transaction, got %d", committed)
+	}
+	if count, _ := users.Count(map[string]interface{}{}); count != 1 {
+		t.Errorf("Expected 1 document, got %d", count)
+	}
+}
+
+func TestUniqueEntryMovesWithinTransaction(t *testing.T) {
+	db, users := openTenantUsers(t)
+	defer db.Close()
+
+	if _, err := users.InsertOne(map[string]interface{}{"_id": "u1", "tenant_id": "t1", "email": "a@example.com"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	// u1 gives up the pair and u2 takes it in the same transaction
+	err := db.WithTransaction(func(s *Session) error {
+		if err := s.UpdateOne("users", map[string]interface{}{"_id": "u1"}, map[string]interface{}{
+			"$set": map[string]interface{}{"email": "old@example.com"},
+		}); err != nil {
+			return err
+		}
+		_, err := s.InsertOne("users", map[string]interface{}{"_id": "u2", "tenant_id": "t1", "email": "a@example.com"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	doc, err := users.FindOne(map[string]interface{}{"tenant_id": "t1", "email": "a@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to find: %v", err)
+	}
+	if id, _ := doc.Get("_id"); id != "u2" {
+		t.Errorf("Expected u2 to hold the pair, got %v", id)
+	}
+	if _, err := users.InsertOne(map[string]interface{}{"tenant_id": "t1", "email": "old@example.com"}); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("Expected the updated pair of u1 to be indexed, got %v", err)
+	}
+}
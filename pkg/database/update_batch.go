@@ -0,0 +1,115 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+// updateBatch applies an update to documents read from the collection as one
+// batch. Every document is updated and checked before any index or page is
+// touched; the index entries of the batch then move together, and the
+// document store writes each page holding its documents once. The batch is
+// atomic: if any document is rejected (invalid update, size limit, quota,
+// unique index conflict), none are updated. Documents the update leaves as
+// they were are not written.
+// Must be called with c.mu held
+func (c *Collection) updateBatch(docs []*document.Document, update map[string]interface{}) (*UpdateResult, error) {
+	ids := make([]string, 0, len(docs))
+	olds := make([]*document.Document, 0, len(docs))
+	batch := make([]*document.Document, 0, len(docs))
+	changes := make([]quotaChange, 0, len(docs))
+	for _, doc := range docs {
+		// Reject updates that can't be applied before touching any indexes
+		if err := checkUpdatePaths(doc, update); err != nil {
+			return nil, err
+		}
+		updated := doc.Clone()
+		if err := c.applyUpdate(updated, update); err != nil {
+			return nil, err
+		}
+		if reflect.DeepEqual(updated.ToMap(), doc.ToMap()) {
+			continue
+		}
+		if err := c.checkDocument(updated); err != nil {
+			return nil, err
+		}
+
+		idVal, _ := doc.Get("_id")
+		id := fmt.Sprintf("%v", idVal)
+		ids = append(ids, id)
+		olds = append(olds, doc)
+		batch = append(batch, updated)
+		changes = append(changes, c.quotaChange(id, updated))
+	}
+	if err := c.checkQuota(changes...); err != nil {
+		return nil, err
+	}
+
+	if err := c.reindexBatch(ids, olds, batch); err != nil {
+		return nil, err
+	}
+	if err := c.docStore.UpdateMany(ids, batch); err != nil {
+		c.reindexBatch(ids, batch, olds)
+		return nil, fmt.Errorf("failed to update documents on disk: %w", err)
+	}
+
+	for _, d := range batch {
+		idVal, _ := d.Get("_id")
+		c.logUpdate(idVal, update)
+	}
+	if len(batch) > 0 {
+		c.queryCache.Clear()
+	}
+	return &UpdateResult{MatchedCount: len(docs), ModifiedCount: len(batch)}, nil
+}
+
+// reindexBatch moves the index entries of documents from their old versions
+// to their new ones. The old entries are all removed first, so documents of
+// the batch may take keys others in it give up. If a new version is rejected
+// (a unique index conflict), the old entries are restored and the error
+// returned.
+// Must be called with c.mu held
+func (c *Collection) reindexBatch(ids []string, olds, news []*document.Document) error {
+	for i, id := range ids {
+		c.unindexDocument(id, olds[i])
+	}
+	for i, id := range ids {
+		if err := c.indexDocument(id, news[i]); err != nil {
+			for j := 0; j < i; j++ {
+				c.unindexDocument(ids[j], news[j])
+			}
+			for j, id := range ids {
+				c.indexDocument(id, olds[j])
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteBatch deletes documents read from the collection as one batch: the
+// document store writes each page holding them once, then their index
+// entries are removed together. If any document can't be deleted, none are.
+// Must be called with c.mu held
+func (c *Collection) deleteBatch(docs []*document.Document) (*DeleteResult, error) {
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		idVal, _ := doc.Get("_id")
+		ids[i] = fmt.Sprintf("%v", idVal)
+	}
+
+	if err := c.docStore.DeleteMany(ids); err != nil {
+		return nil, fmt.Errorf("failed to delete documents from disk: %w", err)
+	}
+	for i, doc := range docs {
+		c.unindexDocument(ids[i], doc)
+		idVal, _ := doc.Get("_id")
+		c.logDelete(idVal)
+	}
+	if len(docs) > 0 {
+		c.queryCache.Clear()
+	}
+	return &DeleteResult{MatchedCount: len(docs), DeletedCount: len(docs)}, nil
+}
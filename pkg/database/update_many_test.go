@@ -1,6 +1,8 @@
 package database
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -27,7 +29,7 @@ func TestUpdateMany_WithCompoundIndex(t *testing.T) {
 	users.InsertOne(map[string]interface{}{"name": "Charlie", "city": "LA", "age": int64(35)})
 
 	// Update many with compound index
-	count, err := users.UpdateMany(
+	result, err := users.UpdateMany(
 		map[string]interface{}{"city": "NYC"},
 		map[string]interface{}{
 			"$set": map[string]interface{}{
@@ -40,8 +42,8 @@ func TestUpdateMany_WithCompoundIndex(t *testing.T) {
 		t.Fatalf("UpdateMany failed: %v", err)
 	}
 
-	if count != 2 {
-		t.Errorf("Expected 2 updates, got %d", count)
+	if result.ModifiedCount != 2 {
+		t.Errorf("Expected 2 updates, got %d", result.ModifiedCount)
 	}
 
 	// Verify updates
@@ -77,7 +79,7 @@ func TestUpdateMany_WithTextIndex(t *testing.T) {
 	})
 
 	// Update many with text index
-	count, err := articles.UpdateMany(
+	result, err := articles.UpdateMany(
 		map[string]interface{}{"status": "draft"},
 		map[string]interface{}{
 			"$set": map[string]interface{}{
@@ -90,8 +92,8 @@ func TestUpdateMany_WithTextIndex(t *testing.T) {
 		t.Fatalf("UpdateMany failed: %v", err)
 	}
 
-	if count != 2 {
-		t.Errorf("Expected 2 updates, got %d", count)
+	if result.ModifiedCount != 2 {
+		t.Errorf("Expected 2 updates, got %d", result.ModifiedCount)
 	}
 
 	// Verify text search still works after update
@@ -133,7 +135,7 @@ func TestUpdateMany_WithGeoIndex(t *testing.T) {
 	})
 
 	// Update many with geo index
-	count, err := locations.UpdateMany(
+	result, err := locations.UpdateMany(
 		map[string]interface{}{"active": true},
 		map[string]interface{}{
 			"$set": map[string]interface{}{
@@ -146,8 +148,8 @@ func TestUpdateMany_WithGeoIndex(t *testing.T) {
 		t.Fatalf("UpdateMany failed: %v", err)
 	}
 
-	if count != 2 {
-		t.Errorf("Expected 2 updates, got %d", count)
+	if result.ModifiedCount != 2 {
+		t.Errorf("Expected 2 updates, got %d", result.ModifiedCount)
 	}
 
 	// Verify geo queries still work
@@ -169,7 +171,7 @@ func TestUpdateMany_WithTTLIndex(t *testing.T) {
 	sessions := db.Collection("sessions")
 
 	// Create TTL index (expire after 1 hour)
-	sessions.CreateTTLIndex("expiresAt", 3600)
+	sessions.CreateTTLIndex("expiresAt", 3600*time.Second)
 
 	// Insert test data
 	now := time.Now()
@@ -185,7 +187,7 @@ func TestUpdateMany_WithTTLIndex(t *testing.T) {
 	})
 
 	// Update many with TTL index
-	count, err := sessions.UpdateMany(
+	result, err := sessions.UpdateMany(
 		map[string]interface{}{},
 		map[string]interface{}{
 			"$set": map[string]interface{}{
@@ -198,8 +200,8 @@ func TestUpdateMany_WithTTLIndex(t *testing.T) {
 		t.Fatalf("UpdateMany failed: %v", err)
 	}
 
-	if count != 2 {
-		t.Errorf("Expected 2 updates, got %d", count)
+	if result.ModifiedCount != 2 {
+		t.Errorf("Expected 2 updates, got %d", result.ModifiedCount)
 	}
 
 	// Verify updates
@@ -237,7 +239,7 @@ func TestUpdateMany_WithPartialIndex(t *testing.T) {
 	})
 
 	// Update many - change active status
-	count, err := products.UpdateMany(
+	result, err := products.UpdateMany(
 		map[string]interface{}{"active": true},
 		map[string]interface{}{
 			"$set": map[string]interface{}{
@@ -250,8 +252,8 @@ func TestUpdateMany_WithPartialIndex(t *testing.T) {
 		t.Fatalf("UpdateMany failed: %v", err)
 	}
 
-	if count != 1 {
-		t.Errorf("Expected 1 update, got %d", count)
+	if result.ModifiedCount != 1 {
+		t.Errorf("Expected 1 update, got %d", result.ModifiedCount)
 	}
 
 	// Verify we can still query all products (not using the partial index)
@@ -299,7 +301,7 @@ func TestUpdateMany_WithMultipleIndexTypes(t *testing.T) {
 	})
 
 	// Update many affecting multiple indexes
-	count, err := users.UpdateMany(
+	result, err := users.UpdateMany(
 		map[string]interface{}{"plan": "free"},
 		map[string]interface{}{
 			"$set": map[string]interface{}{
@@ -313,8 +315,8 @@ func TestUpdateMany_WithMultipleIndexTypes(t *testing.T) {
 		t.Fatalf("UpdateMany failed: %v", err)
 	}
 
-	if count != 2 {
-		t.Errorf("Expected 2 updates, got %d", count)
+	if result.ModifiedCount != 2 {
+		t.Errorf("Expected 2 updates, got %d", result.ModifiedCount)
 	}
 
 	// Verify all updates worked
@@ -350,7 +352,7 @@ func TestUpdateMany_IndexErrorHandling(t *testing.T) {
 	})
 
 	// Try to update in a way that would cause duplicate - this should handle the error gracefully
-	count, err := users.UpdateMany(
+	result, err := users.UpdateMany(
 		map[string]interface{}{"status": "pending"},
 		map[string]interface{}{
 			"$set": map[string]interface{}{
@@ -364,8 +366,8 @@ func TestUpdateMany_IndexErrorHandling(t *testing.T) {
 		t.Fatalf("UpdateMany failed: %v", err)
 	}
 
-	if count != 2 {
-		t.Errorf("Expected 2 updates, got %d", count)
+	if result.ModifiedCount != 2 {
+		t.Errorf("Expected 2 updates, got %d", result.ModifiedCount)
 	}
 }
 
@@ -383,7 +385,7 @@ func TestUpdateMany_EmptyResult(t *testing.T) {
 	users.InsertOne(map[string]interface{}{"name": "Alice", "age": int64(30)})
 
 	// Try to update documents that don't exist
-	count, err := users.UpdateMany(
+	result, err := users.UpdateMany(
 		map[string]interface{}{"name": "NonExistent"},
 		map[string]interface{}{
 			"$set": map[string]interface{}{
@@ -396,7 +398,122 @@ func TestUpdateMany_EmptyResult(t *testing.T) {
 		t.Fatalf("UpdateMany failed: %v", err)
 	}
 
-	if count != 0 {
-		t.Errorf("Expected 0 updates, got %d", count)
+	if result.ModifiedCount != 0 {
+		t.Errorf("Expected 0 updates, got %d", result.ModifiedCount)
+	}
+}
+
+// TestUpdateMany_RangeReindexes updates a range of documents and checks
+// that the index holds the new version of every one of them
+func TestUpdateMany_RangeReindexes(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	items := db.Collection("items")
+	items.CreateIndex("slot", true)
+	docs := make([]map[string]interface{}, 1200)
+	for i := range docs {
+		docs[i] = map[string]interface{}{"_id": fmt.Sprintf("item-%04d", i), "seq": int64(i), "slot": int64(i), "status": "old"}
+	}
+	if _, err := items.InsertMany(docs); err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+
+	inRange := map[string]interface{}{"seq": map[string]interface{}{"$gte": int64(100), "$lt": int64(1100)}}
+	result, err := items.UpdateMany(inRange, map[string]interface{}{
+		"$inc": map[string]interface{}{"slot": int64(5000)},
+		"$set": map[string]interface{}{"status": "new"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateMany failed: %v", err)
+	}
+	if result.MatchedCount != 1000 || result.ModifiedCount != 1000 {
+		t.Errorf("Expected 1000 matched and modified, got %+v", result)
+	}
+
+	entries, err := items.IndexEntries("slot_1")
+	if err != nil {
+		t.Fatalf("IndexEntries failed: %v", err)
+	}
+	if len(entries) != 1200 {
+		t.Fatalf("Expected 1200 index entries, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		doc, err := items.FindOne(map[string]interface{}{"_id": entry.DocumentID})
+		if err != nil {
+			t.Fatalf("Index entry %v points to %s: %v", entry.Key, entry.DocumentID, err)
+		}
+		seq, _ := doc.Get("seq")
+		slot, _ := doc.Get("slot")
+		if entry.Key != slot {
+			t.Fatalf("Expected index key %v for %s, got %v", slot, entry.DocumentID, entry.Key)
+		}
+		expected := seq.(int64)
+		if expected >= 100 && expected < 1100 {
+			expected += 5000
+		}
+		if value, _ := toFloat64(slot); value != float64(expected) {
+			t.Fatalf("Expected slot %d for %s, got %v", expected, entry.DocumentID, slot)
+		}
+	}
+
+	// Matching documents the update leaves as they were aren't modified
+	result, err = items.UpdateMany(inRange, map[string]interface{}{"$set": map[string]interface{}{"status": "new"}})
+	if err != nil || result.MatchedCount != 1000 || result.ModifiedCount != 0 {
+		t.Errorf("Expected 1000 matched and none modified, got %+v, %v", result, err)
+	}
+}
+
+// TestUpdateMany_Atomic checks that a document rejected midway leaves every
+// document and index entry as it was
+func TestUpdateMany_Atomic(t *testing.T) {
+	db, _ := Open(DefaultConfig(t.TempDir()))
+	defer db.Close()
+
+	items := db.Collection("items")
+	items.CreateIndex("slot", true)
+	for i := 0; i < 20; i++ {
+		items.InsertOne(map[string]interface{}{"seq": int64(i), "slot": int64(i)})
+	}
+
+	// Every match taking the same unique key: the second conflicts
+	_, err := items.UpdateMany(
+		map[string]interface{}{"seq": map[string]interface{}{"$lt": int64(10)}},
+		map[string]interface{}{"$set": map[string]interface{}{"slot": int64(-1), "status": "moved"}},
+	)
+	var dupErr *DuplicateKeyError
+	if !errors.As(err, &dupErr) {
+		t.Fatalf("Expected a duplicate key error, got %v", err)
+	}
+
+	if moved, _ := items.Count(map[string]interface{}{"status": "moved"}); moved != 0 {
+		t.Errorf("Expected no document updated, got %d", moved)
+	}
+	entries, _ := items.IndexEntries("slot_1")
+	if len(entries) != 20 {
+		t.Fatalf("Expected 20 index entries, got %d", len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Key != int64(i) {
+			t.Errorf("Expected index key %d, got %v", i, entry.Key)
+		}
+	}
+
+	// A batch giving up keys others in it take succeeds
+	result, err := items.UpdateMany(map[string]interface{}{}, map[string]interface{}{"$inc": map[string]interface{}{"slot": int64(1)}})
+	if err != nil || result.ModifiedCount != 20 {
+		t.Fatalf("Expected 20 documents shifted, got %+v, %v", result, err)
+	}
+
+	deleted, err := items.DeleteMany(map[string]interface{}{"slot": map[string]interface{}{"$gt": int64(10)}})
+	if err != nil || deleted.MatchedCount != 10 || deleted.DeletedCount != 10 {
+		t.Fatalf("Expected 10 documents deleted, got %+v, %v", deleted, err)
+	}
+	entries, _ = items.IndexEntries("slot_1")
+	if len(entries) != 10 {
+		t.Errorf("Expected 10 index entries left, got %d", len(entries))
+	}
+	if remaining, _ := items.Count(map[string]interface{}{}); remaining != 10 {
+		t.Errorf("Expected 10 documents left, got %d", remaining)
 	}
 }
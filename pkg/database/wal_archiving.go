@@ -0,0 +1,54 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/oplog"
+	"github.com/mnohosten/laura-db/pkg/storage"
+)
+
+// SetWALArchiving turns logging of collection writes to the WAL on or off.
+// Each applied insert, update, delete and truncate is then appended to the
+// WAL as a storage.LogRecordOperation record holding its oplog entry, which
+// a replication.WALArchiver ships off-host. Writes made while it is off are
+// not in the WAL, so leave it on between archiver restarts.
+func (db *Database) SetWALArchiving(enabled bool) {
+	db.changes.archiving.Store(enabled)
+}
+
+// WALArchiving reports whether collection writes are logged to the WAL
+func (db *Database) WALArchiving() bool {
+	return db.changes.archiving.Load()
+}
+
+// WAL returns the database's write-ahead log
+func (db *Database) WAL() *storage.WAL {
+	return db.storage.WAL()
+}
+
+// logToWAL appends an entry to the WAL as an operation record, waiting for
+// it to be synced if sync is set
+// Must be called with l.mu held for reading
+func (l *changeLog) logToWAL(entry *oplog.OplogEntry, sync bool) {
+	if l.closed {
+		return
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	record := &storage.LogRecord{
+		Type: storage.LogRecordOperation,
+		Data: data,
+	}
+	if sync {
+		l.wal.AppendDurable(record)
+	} else {
+		l.wal.Append(record)
+	}
+}
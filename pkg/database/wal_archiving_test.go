@@ -0,0 +1,87 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/oplog"
+	"github.com/mnohosten/laura-db/pkg/storage"
+)
+
+// operationRecords returns the operation entries logged to the WAL after lsn
+func operationRecords(t *testing.T, db *Database, lsn uint64) []*oplog.OplogEntry {
+	t.Helper()
+
+	records, err := db.WAL().RecordsAfter(lsn)
+	if err != nil {
+		t.Fatalf("Failed to read WAL: %v", err)
+	}
+
+	entries := make([]*oplog.OplogEntry, 0)
+	for _, record := range records {
+		if record.Type != storage.LogRecordOperation {
+			continue
+		}
+		var entry oplog.OplogEntry
+		if err := json.Unmarshal(record.Data, &entry); err != nil {
+			t.Fatalf("Invalid operation record: %v", err)
+		}
+		entries = append(entries, &entry)
+	}
+	return entries
+}
+
+func TestWALArchivingLogsWrites(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll := db.Collection("users")
+	start := db.WAL().CurrentLSN()
+
+	// Writes are not logged until archiving is turned on
+	coll.InsertOne(map[string]interface{}{"_id": "u1", "name": "alice"})
+	if entries := operationRecords(t, db, start); len(entries) != 0 {
+		t.Fatalf("Expected no operation records, got %d", len(entries))
+	}
+
+	db.SetWALArchiving(true)
+	if !db.WALArchiving() {
+		t.Fatal("Expected WAL archiving to be on")
+	}
+	coll.InsertOne(map[string]interface{}{"_id": "u2", "name": "bob"})
+	coll.UpdateOne(map[string]interface{}{"_id": "u1"}, map[string]interface{}{"$set": map[string]interface{}{"name": "ann"}})
+	coll.DeleteOne(map[string]interface{}{"_id": "u2"})
+
+	entries := operationRecords(t, db, start)
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 operation records, got %d", len(entries))
+	}
+	wantOps := []oplog.OpType{oplog.OpTypeInsert, oplog.OpTypeUpdate, oplog.OpTypeDelete}
+	for i, entry := range entries {
+		if entry.OpType != wantOps[i] {
+			t.Errorf("Record %d: expected %s, got %s", i, wantOps[i], entry.OpType)
+		}
+		if entry.Collection != "users" || entry.Timestamp.IsZero() {
+			t.Errorf("Record %d: unexpected entry %+v", i, entry)
+		}
+	}
+}
+
+func TestBackupRecordsWALPosition(t *testing.T) {
+	db, err := Open(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	backupFormat, err := db.CreateBackup()
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if _, ok := backupFormat.Metadata["wal_lsn"].(string); !ok {
+		t.Error("Expected wal_lsn in backup metadata")
+	}
+}
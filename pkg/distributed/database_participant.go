@@ -73,17 +73,14 @@ func (dp *DatabaseParticipant) Prepare(ctx context.Context, txnID mvcc.TxnID) (b
 	default:
 	}
 
-	// Validate the transaction can be committed
-	// For LauraDB, we check:
-	// 1. Transaction is still active
-	// 2. No conflicts detected so far
-	txn := session.Transaction()
-	if txn.State != mvcc.TxnStateActive {
-		return false, fmt.Errorf("transaction %d is not active", txnID)
+	// Validate the transaction can be committed: it must still be active and
+	// free of write conflicts. Preparing reserves the documents it writes,
+	// so the commit phase can't fail for a conflict.
+	if err := session.PrepareTransaction(); err != nil {
+		return false, fmt.Errorf("transaction %d cannot be prepared: %w", txnID, err)
 	}
 
 	// Vote YES to prepare - we're ready to commit
-	// The actual conflict detection will happen during commit
 	return true, nil
 }
 
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/mnohosten/laura-db/pkg/metrics"
 	"github.com/mnohosten/laura-db/pkg/mvcc"
 )
 
@@ -31,6 +32,16 @@ const (
 	ParticipantStateAborted
 )
 
+// Reasons a distributed transaction aborts, as reported to the metrics
+// collector
+const (
+	AbortReasonVoteNo       = "vote_no"       // A participant voted NO
+	AbortReasonPrepareError = "prepare_error" // A participant failed to prepare
+	AbortReasonTimeout      = "timeout"       // The prepare phase timed out
+	AbortReasonCommitFailed = "commit_failed" // A participant failed to commit
+	AbortReasonRequested    = "requested"     // Abort was called by the caller
+)
+
 // ParticipantID uniquely identifies a participant in the 2PC protocol
 type ParticipantID string
 
@@ -65,6 +76,8 @@ type Coordinator struct {
 	participants map[ParticipantID]*participantRecord
 	mu           sync.RWMutex
 	timeout      time.Duration
+	metrics      *metrics.MetricsCollector // Optional, records 2PC metrics
+	abortReason  string                    // Why the prepare phase failed, if it did
 }
 
 // NewCoordinator creates a new 2PC coordinator for a transaction
@@ -81,6 +94,14 @@ func NewCoordinator(txnID mvcc.TxnID, timeout time.Duration) *Coordinator {
 	}
 }
 
+// SetMetricsCollector sets the collector the coordinator reports transaction
+// counts and phase timings to
+func (c *Coordinator) SetMetricsCollector(mc *metrics.MetricsCollector) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = mc
+}
+
 // AddParticipant adds a participant to the transaction
 func (c *Coordinator) AddParticipant(participant Participant) error {
 	c.mu.Lock()
@@ -113,8 +134,14 @@ func (c *Coordinator) Prepare(ctx context.Context) (bool, error) {
 		return false, fmt.Errorf("cannot prepare: coordinator not in init state")
 	}
 	c.state = CoordinatorStatePreparing
+	mc := c.metrics
 	c.mu.Unlock()
 
+	if mc != nil {
+		mc.RecordDistributedTransactionStart()
+	}
+	start := time.Now()
+
 	// Create context with timeout
 	prepareCtx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
@@ -135,7 +162,11 @@ func (c *Coordinator) Prepare(ctx context.Context) (bool, error) {
 		go func(pid ParticipantID, rec *participantRecord) {
 			defer wg.Done()
 
+			participantStart := time.Now()
 			vote, err := rec.participant.Prepare(prepareCtx, c.txnID)
+			if mc != nil {
+				mc.RecordParticipantPrepare(string(pid), time.Since(participantStart))
+			}
 
 			rec.mu.Lock()
 			if err == nil {
@@ -174,6 +205,21 @@ func (c *Coordinator) Prepare(ctx context.Context) (bool, error) {
 		}
 	}
 
+	if mc != nil {
+		mc.RecordPreparePhase(time.Since(start))
+	}
+
+	c.mu.Lock()
+	switch {
+	case prepareCtx.Err() == context.DeadlineExceeded:
+		c.abortReason = AbortReasonTimeout
+	case len(prepareErrors) > 0:
+		c.abortReason = AbortReasonPrepareError
+	case !allVotedYes:
+		c.abortReason = AbortReasonVoteNo
+	}
+	c.mu.Unlock()
+
 	if len(prepareErrors) > 0 {
 		return false, fmt.Errorf("prepare phase failed: %v", prepareErrors)
 	}
@@ -190,8 +236,11 @@ func (c *Coordinator) Commit(ctx context.Context) error {
 		return fmt.Errorf("cannot commit: coordinator not in preparing state")
 	}
 	c.state = CoordinatorStateCommitting
+	mc := c.metrics
 	c.mu.Unlock()
 
+	start := time.Now()
+
 	// Create context with timeout
 	commitCtx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
@@ -211,7 +260,11 @@ func (c *Coordinator) Commit(ctx context.Context) error {
 		go func(pid ParticipantID, rec *participantRecord) {
 			defer wg.Done()
 
+			participantStart := time.Now()
 			err := rec.participant.Commit(commitCtx, c.txnID)
+			if mc != nil {
+				mc.RecordParticipantCommit(string(pid), time.Since(participantStart))
+			}
 
 			rec.mu.Lock()
 			if err == nil {
@@ -241,15 +294,25 @@ func (c *Coordinator) Commit(ctx context.Context) error {
 		}
 	}
 
+	if mc != nil {
+		mc.RecordCommitPhase(time.Since(start))
+	}
+
 	c.mu.Lock()
 	if len(commitErrors) > 0 {
 		c.state = CoordinatorStateAborted
 		c.mu.Unlock()
+		if mc != nil {
+			mc.RecordDistributedTransactionAbort(AbortReasonCommitFailed)
+		}
 		return fmt.Errorf("commit phase failed: %v", commitErrors)
 	}
 
 	c.state = CoordinatorStateCommitted
 	c.mu.Unlock()
+	if mc != nil {
+		mc.RecordDistributedTransactionCommit()
+	}
 
 	return nil
 }
@@ -261,9 +324,21 @@ func (c *Coordinator) Abort(ctx context.Context) error {
 		c.mu.Unlock()
 		return fmt.Errorf("cannot abort: transaction already committed")
 	}
+	// Only a prepared transaction is still in flight: one that never
+	// started isn't counted, and Commit records its own outcome
+	inFlight := c.state == CoordinatorStatePreparing
+	reason := c.abortReason
+	if reason == "" {
+		reason = AbortReasonRequested
+	}
+	mc := c.metrics
 	c.state = CoordinatorStateAborting
 	c.mu.Unlock()
 
+	if mc != nil && inFlight {
+		mc.RecordDistributedTransactionAbort(reason)
+	}
+
 	// Create context with timeout
 	abortCtx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/mnohosten/laura-db/pkg/metrics"
 	"github.com/mnohosten/laura-db/pkg/mvcc"
 )
 
@@ -477,3 +478,70 @@ func TestAbortAfterCommit(t *testing.T) {
 		t.Fatal("expected error when aborting committed transaction")
 	}
 }
+
+// TestCoordinatorMetrics tests that transaction outcomes and timings are
+// reported to the metrics collector
+func TestCoordinatorMetrics(t *testing.T) {
+	mc := metrics.NewMetricsCollector()
+	ctx := context.Background()
+
+	run := func(timeout time.Duration, participants ...*MockParticipant) {
+		coord := NewCoordinator(1, timeout)
+		coord.SetMetricsCollector(mc)
+		for _, p := range participants {
+			coord.AddParticipant(p)
+		}
+		coord.Execute(ctx)
+	}
+
+	// Committed
+	run(5*time.Second, NewMockParticipant("p1"), NewMockParticipant("p2"))
+
+	// Aborted by a NO vote
+	no := NewMockParticipant("p2")
+	no.prepareResponse = false
+	run(5*time.Second, NewMockParticipant("p1"), no)
+
+	// Aborted by a slow voter
+	slow := NewMockParticipant("slow")
+	slow.prepareDelay = 200 * time.Millisecond
+	run(50*time.Millisecond, NewMockParticipant("p1"), slow)
+
+	// Aborted by a failed commit
+	failing := NewMockParticipant("p2")
+	failing.commitError = errors.New("commit error")
+	run(5*time.Second, NewMockParticipant("p1"), failing)
+
+	// Aborted before it started: not counted
+	coord := NewCoordinator(1, time.Second)
+	coord.SetMetricsCollector(mc)
+	coord.AddParticipant(NewMockParticipant("p1"))
+	coord.Abort(ctx)
+
+	dtxns := mc.GetMetrics()["distributed_transactions"].(map[string]interface{})
+	if dtxns["started"].(uint64) != 4 {
+		t.Errorf("expected 4 started transactions, got %v", dtxns["started"])
+	}
+	if dtxns["committed"].(uint64) != 1 {
+		t.Errorf("expected 1 committed transaction, got %v", dtxns["committed"])
+	}
+	if dtxns["aborted"].(uint64) != 3 {
+		t.Errorf("expected 3 aborted transactions, got %v", dtxns["aborted"])
+	}
+	if dtxns["in_flight"].(uint64) != 0 {
+		t.Errorf("expected 0 in-flight transactions, got %v", dtxns["in_flight"])
+	}
+
+	reasons := dtxns["abort_reasons"].(map[string]uint64)
+	for _, reason := range []string{AbortReasonVoteNo, AbortReasonTimeout, AbortReasonCommitFailed} {
+		if reasons[reason] != 1 {
+			t.Errorf("expected 1 %s abort, got %d", reason, reasons[reason])
+		}
+	}
+
+	participants := dtxns["participants"].(map[string]interface{})
+	slowTimings := participants["slow"].(map[string]interface{})
+	if p99 := slowTimings["prepare_percentiles"].(map[string]time.Duration)["p99"]; p99 < 50*time.Millisecond {
+		t.Errorf("expected slow participant prepare p99 of at least 50ms, got %v", p99)
+	}
+}
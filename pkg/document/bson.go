@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"time"
 )
 
 // Encoder encodes documents to BSON format
@@ -115,7 +116,8 @@ func (e *Encoder) encodeElement(key string, value *Value) error {
 		}
 		e.buf.Write(subData)
 	case TypeTimestamp:
-		binary.Write(e.buf, binary.LittleEndian, value.Data.(int64))
+		// Timestamp: nanoseconds since the Unix epoch
+		binary.Write(e.buf, binary.LittleEndian, value.Data.(time.Time).UnixNano())
 	default:
 		return fmt.Errorf("unsupported type: %v", value.Type)
 	}
@@ -282,8 +284,10 @@ func (d *Decoder) decodeValue(t Type) (interface{}, error) {
 		return NewDecoder(docBytes).Decode()
 	case TypeTimestamp:
 		var v int64
-		err := binary.Read(d.reader, binary.LittleEndian, &v)
-		return v, err
+		if err := binary.Read(d.reader, binary.LittleEndian, &v); err != nil {
+			return nil, err
+		}
+		return time.Unix(0, v).UTC(), nil
 	default:
 		return nil, fmt.Errorf("unsupported type: %v", t)
 	}
@@ -2,6 +2,7 @@ package document
 
 import (
 	"testing"
+	"time"
 )
 
 func TestBSONEncodeDecode(t *testing.T) {
@@ -179,6 +180,32 @@ func TestBSONEncodeDecodeObjectID(t *testing.T) {
 	}
 }
 
+func TestBSONEncodeDecodeTimestamp(t *testing.T) {
+	doc := NewDocument()
+	createdAt := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.FixedZone("CET", 3600))
+	doc.Set("createdAt", createdAt)
+	doc.Set("history", []interface{}{createdAt})
+
+	data, err := NewEncoder().Encode(doc)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := NewDecoder(data).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	val, _ := decoded.Get("createdAt")
+	decodedTime, ok := val.(time.Time)
+	if !ok || !decodedTime.Equal(createdAt) {
+		t.Errorf("Expected %v, got %v", createdAt, val)
+	}
+	val, _ = decoded.Get("history.0")
+	if decodedTime, ok := val.(time.Time); !ok || !decodedTime.Equal(createdAt) {
+		t.Errorf("Expected %v in array, got %v", createdAt, val)
+	}
+}
+
 // Test BSON with mixed array types
 func TestBSONEncodeDecodeMixedArray(t *testing.T) {
 	doc := NewDocument()
@@ -0,0 +1,263 @@
+package document
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"time"
+)
+
+// Canonical ordering of values of different types, used wherever values are
+// compared (query matching, sorting, index keys):
+//
+//	null < numbers < strings < documents < arrays < binary < ObjectID < booleans < dates
+//
+// All numeric types (int, int32, int64, float64, ...) form a single class and
+// compare by numeric value, so int64(30), float64(30) and 30 are equal.
+// Range operators only match values in the same class as the operand; the
+// ordering across classes only matters for sorting mixed-type fields.
+const (
+	orderNull = iota
+	orderNumber
+	orderString
+	orderDocument
+	orderArray
+	orderBinary
+	orderObjectID
+	orderBoolean
+	orderDate
+	orderUnknown
+)
+
+// TypeOrder returns the canonical sort class of a value. Values are only
+// comparable by range operators when their classes are equal.
+func TypeOrder(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return orderNull
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return orderNumber
+	case string:
+		return orderString
+	case map[string]interface{}, *Document:
+		return orderDocument
+	case []interface{}:
+		return orderArray
+	case []byte:
+		return orderBinary
+	case ObjectID:
+		return orderObjectID
+	case bool:
+		return orderBoolean
+	case time.Time:
+		return orderDate
+	default:
+		return orderUnknown
+	}
+}
+
+// CompareValues compares two values using the canonical ordering
+// Returns: -1 if a < b, 0 if a == b, 1 if a > b
+func CompareValues(a, b interface{}) int {
+	// Fast paths for the common same-type keys
+	switch va := a.(type) {
+	case int64:
+		if vb, ok := b.(int64); ok {
+			return compareInts(va, vb)
+		}
+	case string:
+		if vb, ok := b.(string); ok {
+			return compareStrings(va, vb)
+		}
+	}
+
+	orderA, orderB := TypeOrder(a), TypeOrder(b)
+	if orderA != orderB {
+		return compareInts(int64(orderA), int64(orderB))
+	}
+
+	switch orderA {
+	case orderNumber:
+		return compareNumbers(a, b)
+	case orderString:
+		return compareStrings(a.(string), b.(string))
+	case orderDocument:
+		return compareDocuments(toMap(a), toMap(b))
+	case orderArray:
+		return compareArrays(a.([]interface{}), b.([]interface{}))
+	case orderBinary:
+		return bytes.Compare(a.([]byte), b.([]byte))
+	case orderObjectID:
+		idA, idB := a.(ObjectID), b.(ObjectID)
+		return bytes.Compare(idA[:], idB[:])
+	case orderBoolean:
+		boolA, boolB := a.(bool), b.(bool)
+		if boolA == boolB {
+			return 0
+		}
+		if !boolA {
+			return -1
+		}
+		return 1
+	case orderDate:
+		return a.(time.Time).Compare(b.(time.Time))
+	}
+
+	return 0
+}
+
+// ToNumber converts any numeric value to float64
+func ToNumber(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int8:
+		return float64(val), true
+	case int16:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case uint:
+		return float64(val), true
+	case uint8:
+		return float64(val), true
+	case uint16:
+		return float64(val), true
+	case uint32:
+		return float64(val), true
+	case uint64:
+		return float64(val), true
+	default:
+		return 0, false
+	}
+}
+
+// toInteger converts an integer value to int64, reporting false for floats
+// and for uint64 values too large for int64
+func toInteger(v interface{}) (int64, bool) {
+	switch val := v.(type) {
+	case int:
+		return int64(val), true
+	case int8:
+		return int64(val), true
+	case int16:
+		return int64(val), true
+	case int32:
+		return int64(val), true
+	case int64:
+		return val, true
+	case uint:
+		if uint64(val) > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(val), true
+	case uint8:
+		return int64(val), true
+	case uint16:
+		return int64(val), true
+	case uint32:
+		return int64(val), true
+	case uint64:
+		if val > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(val), true
+	default:
+		return 0, false
+	}
+}
+
+// compareNumbers compares two numeric values. Integers are compared exactly
+// so large int64 values don't collapse when converted to float64. NaN sorts
+// before every other number.
+func compareNumbers(a, b interface{}) int {
+	intA, okA := toInteger(a)
+	intB, okB := toInteger(b)
+	if okA && okB {
+		return compareInts(intA, intB)
+	}
+
+	floatA, _ := ToNumber(a)
+	floatB, _ := ToNumber(b)
+	nanA, nanB := math.IsNaN(floatA), math.IsNaN(floatB)
+	switch {
+	case nanA && nanB:
+		return 0
+	case nanA:
+		return -1
+	case nanB:
+		return 1
+	case floatA < floatB:
+		return -1
+	case floatA > floatB:
+		return 1
+	}
+	return 0
+}
+
+func compareInts(a, b int64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareStrings(a, b string) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// compareArrays compares arrays element by element; a shorter array that is
+// a prefix of a longer one sorts first
+func compareArrays(a, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if cmp := CompareValues(a[i], b[i]); cmp != 0 {
+			return cmp
+		}
+	}
+	return compareInts(int64(len(a)), int64(len(b)))
+}
+
+// compareDocuments compares documents field by field in key order
+func compareDocuments(a, b map[string]interface{}) int {
+	keysA := sortedKeys(a)
+	keysB := sortedKeys(b)
+
+	for i := 0; i < len(keysA) && i < len(keysB); i++ {
+		if cmp := compareStrings(keysA[i], keysB[i]); cmp != 0 {
+			return cmp
+		}
+		if cmp := CompareValues(a[keysA[i]], b[keysB[i]]); cmp != 0 {
+			return cmp
+		}
+	}
+	return compareInts(int64(len(keysA)), int64(len(keysB)))
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toMap(v interface{}) map[string]interface{} {
+	if doc, ok := v.(*Document); ok {
+		return doc.ToMap()
+	}
+	return v.(map[string]interface{})
+}
@@ -0,0 +1,81 @@
+package document
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCompareValuesNumericAcrossTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     interface{}
+		expected int
+	}{
+		{"int vs int64", 30, int64(30), 0},
+		{"int64 vs float64", int64(30), float64(30), 0},
+		{"int32 vs float64", int32(30), float64(30.5), -1},
+		{"float64 vs int64", float64(30.5), int64(30), 1},
+		{"uint8 vs int", uint8(7), 7, 0},
+		{"large int64 exact", int64(math.MaxInt64), int64(math.MaxInt64 - 1), 1},
+		{"large uint64 vs int64", uint64(math.MaxUint64), int64(math.MaxInt64), 1},
+		{"NaN before numbers", math.NaN(), float64(-1e300), -1},
+		{"NaN equals NaN", math.NaN(), math.NaN(), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := CompareValues(tt.a, tt.b); result != tt.expected {
+				t.Errorf("CompareValues(%v, %v) = %d, expected %d", tt.a, tt.b, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompareValuesTypeOrdering(t *testing.T) {
+	// Canonical order: null < numbers < strings < documents < arrays < binary < ObjectID < booleans < dates
+	ordered := []interface{}{
+		nil,
+		int64(-5),
+		float64(2.5),
+		3,
+		"",
+		"abc",
+		map[string]interface{}{"a": 1},
+		[]interface{}{1, 2},
+		[]byte{0x01},
+		NewObjectID(),
+		false,
+		true,
+		time.Unix(0, 0),
+	}
+
+	for i := 0; i < len(ordered); i++ {
+		for j := 0; j < len(ordered); j++ {
+			expected := 0
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			if result := CompareValues(ordered[i], ordered[j]); result != expected {
+				t.Errorf("CompareValues(%v, %v) = %d, expected %d", ordered[i], ordered[j], result, expected)
+			}
+		}
+	}
+}
+
+func TestCompareValuesComposite(t *testing.T) {
+	if CompareValues([]interface{}{1, 2}, []interface{}{int64(1), float64(2)}) != 0 {
+		t.Error("Expected arrays with numerically equal elements to be equal")
+	}
+	if CompareValues([]interface{}{1}, []interface{}{1, 2}) != -1 {
+		t.Error("Expected prefix array to sort first")
+	}
+	if CompareValues(map[string]interface{}{"a": 1}, map[string]interface{}{"a": int64(1)}) != 0 {
+		t.Error("Expected documents with numerically equal fields to be equal")
+	}
+	if CompareValues(map[string]interface{}{"a": 1}, map[string]interface{}{"b": 0}) != -1 {
+		t.Error("Expected documents to compare by field name first")
+	}
+}
@@ -2,6 +2,8 @@ package document
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 // Document represents a BSON-like document (key-value pairs)
@@ -35,8 +37,12 @@ func (d *Document) Set(key string, value interface{}) {
 	d.fields[key] = NewValue(value)
 }
 
-// Get retrieves a field value from the document
+// Get retrieves a field value from the document. Field names don't contain
+// dots, so dotted keys are always resolved as nested paths (see GetNested).
 func (d *Document) Get(key string) (interface{}, bool) {
+	if strings.Contains(key, ".") {
+		return d.GetNested(key)
+	}
 	if v, ok := d.fields[key]; ok {
 		return v.Data, true
 	}
@@ -49,9 +55,9 @@ func (d *Document) GetValue(key string) (*Value, bool) {
 	return v, ok
 }
 
-// Has checks if a field exists in the document
+// Has checks if a field exists in the document (dotted paths allowed)
 func (d *Document) Has(key string) bool {
-	_, ok := d.fields[key]
+	_, ok := d.Get(key)
 	return ok
 }
 
@@ -130,39 +136,281 @@ func (d *Document) Clone() *Document {
 
 // cloneValue creates a deep copy of a value
 func (d *Document) cloneValue(v *Value) interface{} {
-	switch v.Type {
-	case TypeDocument:
-		if doc, ok := v.Data.(*Document); ok {
-			return doc.Clone()
+	return cloneData(v.Data)
+}
+
+// cloneData creates a deep copy of embedded documents, arrays and binary data
+func cloneData(data interface{}) interface{} {
+	switch v := data.(type) {
+	case *Document:
+		return v.Clone()
+	case map[string]interface{}:
+		clone := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			clone[k] = cloneData(val)
 		}
-		if m, ok := v.Data.(map[string]interface{}); ok {
-			clone := make(map[string]interface{})
-			for k, val := range m {
-				clone[k] = val
+		return clone
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, val := range v {
+			clone[i] = cloneData(val)
+		}
+		return clone
+	case []byte:
+		clone := make([]byte, len(v))
+		copy(clone, v)
+		return clone
+	}
+	return data
+}
+
+// GetNested retrieves a value using dot notation (e.g., "user.address.city").
+// Segments traverse embedded documents; numeric segments index into arrays
+// (e.g., "items.0.qty").
+func (d *Document) GetNested(path string) (interface{}, bool) {
+	parts := strings.Split(path, ".")
+
+	v, ok := d.fields[parts[0]]
+	if !ok {
+		return nil, false
+	}
+
+	current := v.Data
+	for _, part := range parts[1:] {
+		next, ok := childValue(current, part)
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+
+	return current, true
+}
+
+// SetNested sets a value using dot notation, creating intermediate embedded
+// documents that don't exist. Numeric segments index into existing arrays,
+// padding them with nulls when the index is past the end. Containers along
+// the path are copied, so values shared with other documents are not modified.
+func (d *Document) SetNested(path string, value interface{}) error {
+	if !strings.Contains(path, ".") {
+		d.Set(path, value)
+		return nil
+	}
+
+	parts := strings.Split(path, ".")
+	var current interface{}
+	if v, ok := d.fields[parts[0]]; ok {
+		current = v.Data
+	}
+
+	updated, err := setPath(current, parts[1:], value)
+	if err != nil {
+		return fmt.Errorf("cannot set %s: %w", path, err)
+	}
+	d.Set(parts[0], updated)
+	return nil
+}
+
+// DeleteNested removes a field using dot notation. Array elements addressed
+// by index are set to null rather than removed, so other indexes stay stable.
+// Missing paths are ignored.
+func (d *Document) DeleteNested(path string) {
+	if !strings.Contains(path, ".") {
+		d.Delete(path)
+		return
+	}
+
+	parts := strings.Split(path, ".")
+	v, ok := d.fields[parts[0]]
+	if !ok {
+		return
+	}
+
+	if updated, changed := deletePath(v.Data, parts[1:]); changed {
+		d.Set(parts[0], updated)
+	}
+}
+
+// CheckPath reports whether a value can be set at a dotted path, i.e. no
+// existing value along the path is a scalar that would have to be replaced
+func (d *Document) CheckPath(path string) error {
+	if !strings.Contains(path, ".") {
+		return nil
+	}
+
+	parts := strings.Split(path, ".")
+	v, ok := d.fields[parts[0]]
+	if !ok {
+		return nil
+	}
+
+	current := v.Data
+	for i, part := range parts[1:] {
+		if current == nil {
+			return nil
+		}
+		switch c := current.(type) {
+		case []interface{}:
+			if _, err := arrayIndex(c, part); err != nil {
+				return fmt.Errorf("cannot set %s: %w", path, err)
 			}
-			return clone
+		case map[string]interface{}, *Document:
+		default:
+			return fmt.Errorf("cannot set %s: %s is not a document or array (%T)", path, strings.Join(parts[:i+1], "."), c)
 		}
-	case TypeArray:
-		if arr, ok := v.Data.([]interface{}); ok {
-			clone := make([]interface{}, len(arr))
-			copy(clone, arr)
-			return clone
+
+		next, ok := childValue(current, part)
+		if !ok {
+			return nil
 		}
-	case TypeBinary:
-		if b, ok := v.Data.([]byte); ok {
-			clone := make([]byte, len(b))
-			copy(clone, b)
-			return clone
+		current = next
+	}
+
+	return nil
+}
+
+// maxArrayPadding is how far past the end of an array a path may set an
+// element; the gap is filled with nulls
+const maxArrayPadding = 1024
+
+// arrayIndex parses a path segment as the index of an element to set in arr
+func arrayIndex(arr []interface{}, key string) (int, error) {
+	idx, err := strconv.Atoi(key)
+	if err != nil || idx < 0 {
+		return 0, fmt.Errorf("cannot index array with %q", key)
+	}
+	if idx > len(arr)+maxArrayPadding {
+		return 0, fmt.Errorf("cannot pad array of %d elements to index %d (at most %d past the end)", len(arr), idx, maxArrayPadding)
+	}
+	return idx, nil
+}
+
+// childValue returns the element of an embedded document or array
+func childValue(container interface{}, key string) (interface{}, bool) {
+	switch c := container.(type) {
+	case map[string]interface{}:
+		v, ok := c[key]
+		return unwrapValue(v), ok
+	case *Document:
+		return c.Get(key)
+	case []interface{}:
+		idx, err := strconv.Atoi(key)
+		if err != nil || idx < 0 || idx >= len(c) {
+			return nil, false
 		}
+		return unwrapValue(c[idx]), true
 	}
-	return v.Data
+	return nil, false
 }
 
-// GetNested retrieves a value using dot notation (e.g., "user.address.city")
-func (d *Document) GetNested(path string) (interface{}, bool) {
-	// Simple implementation without parsing
-	// In production, this would handle arrays and complex paths
-	return d.Get(path)
+// unwrapValue returns the raw data of a *Value stored inside a container
+func unwrapValue(v interface{}) interface{} {
+	if val, ok := v.(*Value); ok {
+		return val.Data
+	}
+	return v
+}
+
+// setPath returns a copy of container with value stored at path
+func setPath(container interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	key := path[0]
+	switch c := container.(type) {
+	case nil:
+		child, err := setPath(nil, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{key: child}, nil
+
+	case map[string]interface{}:
+		updated := make(map[string]interface{}, len(c)+1)
+		for k, v := range c {
+			updated[k] = v
+		}
+		child, err := setPath(unwrapValue(c[key]), path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		updated[key] = child
+		return updated, nil
+
+	case *Document:
+		return setPath(c.ToMap(), path, value)
+
+	case []interface{}:
+		idx, err := arrayIndex(c, key)
+		if err != nil {
+			return nil, err
+		}
+		size := len(c)
+		if idx >= size {
+			size = idx + 1
+		}
+		updated := make([]interface{}, size)
+		copy(updated, c)
+		child, err := setPath(unwrapValue(updated[idx]), path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		updated[idx] = child
+		return updated, nil
+	}
+
+	return nil, fmt.Errorf("cannot create field %q in non-document value %v", key, container)
+}
+
+// deletePath returns a copy of container without the field at path and
+// whether anything was removed
+func deletePath(container interface{}, path []string) (interface{}, bool) {
+	key := path[0]
+	switch c := container.(type) {
+	case map[string]interface{}:
+		child, exists := c[key]
+		if !exists {
+			return container, false
+		}
+		updated := make(map[string]interface{}, len(c))
+		for k, v := range c {
+			updated[k] = v
+		}
+		if len(path) == 1 {
+			delete(updated, key)
+			return updated, true
+		}
+		newChild, changed := deletePath(unwrapValue(child), path[1:])
+		if !changed {
+			return container, false
+		}
+		updated[key] = newChild
+		return updated, true
+
+	case *Document:
+		return deletePath(c.ToMap(), path)
+
+	case []interface{}:
+		idx, err := strconv.Atoi(key)
+		if err != nil || idx < 0 || idx >= len(c) {
+			return container, false
+		}
+		updated := make([]interface{}, len(c))
+		copy(updated, c)
+		if len(path) == 1 {
+			updated[idx] = nil
+			return updated, true
+		}
+		newChild, changed := deletePath(unwrapValue(c[idx]), path[1:])
+		if !changed {
+			return container, false
+		}
+		updated[idx] = newChild
+		return updated, true
+	}
+
+	return container, false
 }
 
 // String returns a string representation of the document
@@ -195,7 +195,6 @@ func TestDocumentGetNested(t *testing.T) {
 	doc.Set("name", "Alice")
 	doc.Set("age", int64(30))
 
-	// GetNested currently just calls Get (simple implementation)
 	val, exists := doc.GetNested("name")
 	if !exists {
 		t.Error("Expected 'name' to exist")
@@ -209,6 +208,122 @@ func TestDocumentGetNested(t *testing.T) {
 	if exists {
 		t.Error("Expected 'nonexistent' to not exist")
 	}
+
+	doc.Set("address", map[string]interface{}{"city": "SF"})
+	doc.Set("items", []interface{}{map[string]interface{}{"qty": int64(2)}})
+
+	val, exists = doc.GetNested("address.city")
+	if !exists || val != "SF" {
+		t.Errorf("Expected address.city 'SF', got %v", val)
+	}
+	val, exists = doc.Get("items.0.qty")
+	if !exists || val != int64(2) {
+		t.Errorf("Expected items.0.qty 2, got %v", val)
+	}
+	if _, exists = doc.Get("items.1.qty"); exists {
+		t.Error("Expected items.1.qty to not exist")
+	}
+
+	// Dotted keys are paths, even if a top-level field has the same name
+	doc.Set("address.city", "NYC")
+	if val, _ = doc.Get("address.city"); val != "SF" {
+		t.Errorf("Expected address.city to resolve as a path to 'SF', got %v", val)
+	}
+	if _, exists = doc.Get("address.city.name"); exists {
+		t.Error("Expected address.city.name to not exist")
+	}
+}
+
+func TestDocumentSetNested(t *testing.T) {
+	address := map[string]interface{}{"city": "SF"}
+	doc := NewDocument()
+	doc.Set("address", address)
+	doc.Set("items", []interface{}{map[string]interface{}{"qty": int64(2)}})
+
+	if err := doc.SetNested("address.zip", int64(94102)); err != nil {
+		t.Fatalf("SetNested failed: %v", err)
+	}
+	if val, _ := doc.Get("address.zip"); val != int64(94102) {
+		t.Errorf("Expected address.zip 94102, got %v", val)
+	}
+	if val, _ := doc.Get("address.city"); val != "SF" {
+		t.Errorf("Expected address.city 'SF', got %v", val)
+	}
+	if _, exists := address["zip"]; exists {
+		t.Error("Expected original embedded document to be left unchanged")
+	}
+
+	// Intermediate documents are created
+	if err := doc.SetNested("profile.contact.email", "a@example.com"); err != nil {
+		t.Fatalf("SetNested failed: %v", err)
+	}
+	if val, _ := doc.Get("profile.contact.email"); val != "a@example.com" {
+		t.Errorf("Expected profile.contact.email, got %v", val)
+	}
+
+	// Array elements by index
+	if err := doc.SetNested("items.0.qty", int64(5)); err != nil {
+		t.Fatalf("SetNested failed: %v", err)
+	}
+	if val, _ := doc.Get("items.0.qty"); val != int64(5) {
+		t.Errorf("Expected items.0.qty 5, got %v", val)
+	}
+	if err := doc.SetNested("items.2", "last"); err != nil {
+		t.Fatalf("SetNested failed: %v", err)
+	}
+	items, _ := doc.Get("items")
+	if arr := items.([]interface{}); len(arr) != 3 || arr[1] != nil || arr[2] != "last" {
+		t.Errorf("Expected items padded to 3 elements, got %v", arr)
+	}
+
+	// Scalars along the path can't be traversed
+	if err := doc.CheckPath("address.city.name"); err == nil {
+		t.Error("Expected CheckPath to fail through a string")
+	}
+	if err := doc.SetNested("address.city.name", "x"); err == nil {
+		t.Error("Expected SetNested to fail through a string")
+	}
+	if err := doc.SetNested("items.first", "x"); err == nil {
+		t.Error("Expected SetNested to fail with a non-numeric array index")
+	}
+	if val, _ := doc.Get("address.city"); val != "SF" {
+		t.Errorf("Expected failed SetNested to leave address.city, got %v", val)
+	}
+
+	// An index far past the end of the array is rejected, not allocated
+	if err := doc.CheckPath("items.999999999999"); err == nil {
+		t.Error("Expected CheckPath to fail with an oversized array index")
+	}
+	if err := doc.SetNested("items.999999999999", "x"); err == nil {
+		t.Error("Expected SetNested to fail with an oversized array index")
+	}
+	if items, _ := doc.Get("items"); len(items.([]interface{})) != 3 {
+		t.Errorf("Expected failed SetNested to leave items, got %v", items)
+	}
+}
+
+func TestDocumentDeleteNested(t *testing.T) {
+	doc := NewDocument()
+	doc.Set("address", map[string]interface{}{"city": "SF", "zip": int64(94102)})
+	doc.Set("tags", []interface{}{"a", "b"})
+
+	doc.DeleteNested("address.zip")
+	if doc.Has("address.zip") {
+		t.Error("Expected address.zip to be removed")
+	}
+	if !doc.Has("address.city") {
+		t.Error("Expected address.city to remain")
+	}
+
+	doc.DeleteNested("tags.0")
+	tags, _ := doc.Get("tags")
+	if arr := tags.([]interface{}); len(arr) != 2 || arr[0] != nil {
+		t.Errorf("Expected tags.0 to be nulled, got %v", arr)
+	}
+
+	// Missing paths are ignored
+	doc.DeleteNested("missing.field")
+	doc.DeleteNested("address.city.name")
 }
 
 // Test String function
@@ -577,3 +692,22 @@ func TestDocumentValueToInterfaceEdgeCases(t *testing.T) {
 		t.Error("Array length incorrect")
 	}
 }
+
+func TestDocumentCloneIsDeep(t *testing.T) {
+	doc := NewDocumentFromMap(map[string]interface{}{
+		"address": map[string]interface{}{"city": "Prague"},
+		"items":   []interface{}{map[string]interface{}{"qty": 1}},
+	})
+
+	clone := doc.Clone()
+	clone.SetNested("address.city", "Brno")
+	items, _ := clone.Get("items")
+	items.([]interface{})[0].(map[string]interface{})["qty"] = 2
+
+	if city, _ := doc.GetNested("address.city"); city != "Prague" {
+		t.Errorf("Expected original city Prague, got %v", city)
+	}
+	if qty, _ := doc.GetNested("items.0.qty"); qty != 1 {
+		t.Errorf("Expected original qty 1, got %v", qty)
+	}
+}
@@ -68,6 +68,11 @@ func (id ObjectID) String() string {
 	return id.Hex()
 }
 
+// MarshalJSON encodes the ObjectID as its hex string
+func (id ObjectID) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + id.Hex() + `"`), nil
+}
+
 // Timestamp returns the timestamp portion of the ObjectID
 func (id ObjectID) Timestamp() time.Time {
 	timestamp := binary.BigEndian.Uint32(id[0:4])
@@ -1,6 +1,7 @@
 package document
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 )
@@ -93,3 +94,16 @@ func TestObjectIDString(t *testing.T) {
 		t.Error("String() and Hex() should return the same value")
 	}
 }
+
+func TestObjectIDMarshalJSON(t *testing.T) {
+	id := NewObjectID()
+	data, err := json.Marshal(map[string]interface{}{"_id": id})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	expected := `{"_id":"` + id.Hex() + `"}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+}
@@ -0,0 +1,373 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// ArchiveMagic starts every encrypted archive
+	ArchiveMagic = "LDBCRYPT"
+
+	// ArchiveChunkSize is the most plaintext sealed into one archive chunk
+	ArchiveChunkSize = 64 * 1024
+
+	// archiveNonceSize is the size of the GCM nonce of each chunk
+	archiveNonceSize = 12
+)
+
+var (
+	// ErrArchiveAuth is returned when an archive can't be authenticated,
+	// because the key is wrong or the archive was modified or truncated
+	ErrArchiveAuth = errors.New("archive authentication failed: wrong key or corrupted archive")
+
+	// ErrArchiveEncrypted is returned when an encrypted archive is read
+	// without a key
+	ErrArchiveEncrypted = errors.New("archive is encrypted")
+
+	// ErrArchiveNotEncrypted is returned when a plaintext archive is read
+	// with a key
+	ErrArchiveNotEncrypted = errors.New("archive is not encrypted")
+)
+
+// Encrypted archives are streams of independently sealed chunks, so backups
+// and exports of any size are encrypted without holding them in memory:
+//
+//	[8-byte magic][1-byte algorithm][1-byte salt length][salt]
+//	chunk: [4-byte sealed length][12-byte nonce][sealed data]
+//
+// Each chunk is sealed with AES-256-GCM over the header, its index and
+// whether it is the last one, so chunks can't be altered, reordered or
+// dropped, and an archive cut short fails to authenticate. The salt is
+// present for password-based configs, letting the key be derived again from
+// the password alone on restore.
+
+// archiveEnabled reports whether a config encrypts archives
+func archiveEnabled(config *Config) bool {
+	return config != nil && config.Algorithm != AlgorithmNone
+}
+
+// archiveAEAD returns the cipher for an archive with the given salt
+func archiveAEAD(config *Config, salt []byte) (cipher.AEAD, error) {
+	if config.Algorithm != AlgorithmAES256GCM {
+		return nil, fmt.Errorf("archives need an authenticated algorithm (%v), got %v", AlgorithmAES256GCM, config.Algorithm)
+	}
+
+	key := config.Key
+	if config.Password != "" && len(salt) > 0 {
+		key = pbkdf2.Key([]byte(config.Password), salt, 100000, 32, sha256.New)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return aead, nil
+}
+
+// chunkAAD returns the additional data a chunk is sealed with
+func chunkAAD(header []byte, index uint64, last bool) []byte {
+	aad := make([]byte, len(header)+9)
+	copy(aad, header)
+	binary.LittleEndian.PutUint64(aad[len(header):], index)
+	if last {
+		aad[len(aad)-1] = 1
+	}
+	return aad
+}
+
+// ArchiveWriter encrypts an archive written through it
+type ArchiveWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	buf    []byte
+	index  uint64
+	err    error
+	closed bool
+}
+
+// NewArchiveWriter returns a writer that encrypts everything written to it
+// into w. Close must be called to write the final chunk; it doesn't close w.
+// A nil config or AlgorithmNone writes the archive unencrypted.
+func NewArchiveWriter(w io.Writer, config *Config) (io.WriteCloser, error) {
+	if !archiveEnabled(config) {
+		return nopWriteCloser{w}, nil
+	}
+
+	var salt []byte
+	if config.Password != "" {
+		salt = config.Salt
+	}
+	if len(salt) > 255 {
+		return nil, fmt.Errorf("salt must be at most 255 bytes, got %d", len(salt))
+	}
+	aead, err := archiveAEAD(config, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(ArchiveMagic)+2+len(salt))
+	header = append(header, ArchiveMagic...)
+	header = append(header, byte(config.Algorithm), byte(len(salt)))
+	header = append(header, salt...)
+	if _, err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write archive header: %w", err)
+	}
+
+	return &ArchiveWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, 0, ArchiveChunkSize),
+	}, nil
+}
+
+// Write buffers p, sealing and writing every full chunk
+func (aw *ArchiveWriter) Write(p []byte) (int, error) {
+	if aw.err != nil {
+		return 0, aw.err
+	}
+	if aw.closed {
+		return 0, fmt.Errorf("archive writer is closed")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n := copy(aw.buf[len(aw.buf):cap(aw.buf)], p)
+		aw.buf = aw.buf[:len(aw.buf)+n]
+		p = p[n:]
+		written += n
+
+		// Keep a full chunk buffered until more data arrives, so the last
+		// chunk is only sealed on Close
+		if len(aw.buf) == cap(aw.buf) && len(p) > 0 {
+			if err := aw.flush(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flush seals and writes the buffered chunk
+func (aw *ArchiveWriter) flush(last bool) error {
+	nonce := make([]byte, archiveNonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		aw.err = fmt.Errorf("failed to generate nonce: %w", err)
+		return aw.err
+	}
+	sealed := aw.aead.Seal(nil, nonce, aw.buf, chunkAAD(aw.header, aw.index, last))
+
+	frame := make([]byte, 4+archiveNonceSize+len(sealed))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(sealed)))
+	copy(frame[4:], nonce)
+	copy(frame[4+archiveNonceSize:], sealed)
+	if _, err := aw.w.Write(frame); err != nil {
+		aw.err = fmt.Errorf("failed to write archive chunk: %w", err)
+		return aw.err
+	}
+
+	aw.index++
+	aw.buf = aw.buf[:0]
+	return nil
+}
+
+// Close seals and writes the final chunk
+func (aw *ArchiveWriter) Close() error {
+	if aw.closed {
+		return aw.err
+	}
+	aw.closed = true
+	if aw.err != nil {
+		return aw.err
+	}
+	return aw.flush(true)
+}
+
+// nopWriteCloser passes writes through for unencrypted archives
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close does nothing
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// ArchiveReader decrypts an archive read through it
+type ArchiveReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	header []byte
+	buf    []byte
+	index  uint64
+	done   bool
+	err    error
+}
+
+// NewArchiveReader returns a reader that decrypts an archive read from r
+// with config. It fails with ErrArchiveEncrypted if config is nil or
+// AlgorithmNone and the archive is encrypted, and with
+// ErrArchiveNotEncrypted if a key is given for a plaintext archive. Reads
+// fail with ErrArchiveAuth if the key is wrong or the archive was tampered
+// with; no data is returned from a chunk that doesn't authenticate.
+func NewArchiveReader(r io.Reader, config *Config) (io.Reader, error) {
+	magic := make([]byte, len(ArchiveMagic))
+	n, err := io.ReadFull(r, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("failed to read archive header: %w", err)
+	}
+	encrypted := n == len(magic) && string(magic) == ArchiveMagic
+
+	if !archiveEnabled(config) {
+		if encrypted {
+			return nil, ErrArchiveEncrypted
+		}
+		return io.MultiReader(bytes.NewReader(magic[:n]), r), nil
+	}
+	if !encrypted {
+		return nil, ErrArchiveNotEncrypted
+	}
+
+	fields := make([]byte, 2)
+	if _, err := io.ReadFull(r, fields); err != nil {
+		return nil, fmt.Errorf("failed to read archive header: %w", err)
+	}
+	if Algorithm(fields[0]) != config.Algorithm {
+		return nil, fmt.Errorf("encryption algorithm mismatch: expected %v, got %v", config.Algorithm, Algorithm(fields[0]))
+	}
+	salt := make([]byte, fields[1])
+	if _, err := io.ReadFull(r, salt); err != nil {
+		return nil, fmt.Errorf("failed to read archive header: %w", err)
+	}
+
+	aead, err := archiveAEAD(config, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(magic)+len(fields)+len(salt))
+	header = append(header, magic...)
+	header = append(header, fields...)
+	header = append(header, salt...)
+
+	return &ArchiveReader{r: r, aead: aead, header: header}, nil
+}
+
+// Read returns decrypted data, reading and opening chunks as needed
+func (ar *ArchiveReader) Read(p []byte) (int, error) {
+	for len(ar.buf) == 0 {
+		if ar.err != nil {
+			return 0, ar.err
+		}
+		if ar.done {
+			return 0, io.EOF
+		}
+		ar.err = ar.next()
+	}
+
+	n := copy(p, ar.buf)
+	ar.buf = ar.buf[n:]
+	return n, nil
+}
+
+// next reads and opens the next chunk
+func (ar *ArchiveReader) next() error {
+	frame := make([]byte, 4+archiveNonceSize)
+	if _, err := io.ReadFull(ar.r, frame); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// The final chunk is missing
+			return ErrArchiveAuth
+		}
+		return fmt.Errorf("failed to read archive chunk: %w", err)
+	}
+
+	size := binary.LittleEndian.Uint32(frame[0:4])
+	if size > ArchiveChunkSize+uint32(ar.aead.Overhead()) {
+		return ErrArchiveAuth
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(ar.r, sealed); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrArchiveAuth
+		}
+		return fmt.Errorf("failed to read archive chunk: %w", err)
+	}
+	nonce := frame[4:]
+
+	// A chunk opens either as a middle chunk or as the last one
+	last := false
+	plaintext, err := ar.aead.Open(nil, nonce, sealed, chunkAAD(ar.header, ar.index, false))
+	if err != nil {
+		plaintext, err = ar.aead.Open(nil, nonce, sealed, chunkAAD(ar.header, ar.index, true))
+		if err != nil {
+			return ErrArchiveAuth
+		}
+		last = true
+	}
+
+	if last {
+		// Nothing may follow the last chunk
+		var extra [1]byte
+		if n, _ := ar.r.Read(extra[:]); n > 0 {
+			return ErrArchiveAuth
+		}
+		ar.done = true
+	}
+
+	ar.index++
+	ar.buf = plaintext
+	return nil
+}
+
+// SealArchive encrypts data as a complete archive. A nil config or
+// AlgorithmNone returns data unchanged.
+func SealArchive(data []byte, config *Config) ([]byte, error) {
+	if !archiveEnabled(config) {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	w, err := NewArchiveWriter(&buf, config)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// OpenArchive decrypts an archive made by SealArchive or an ArchiveWriter
+func OpenArchive(data []byte, config *Config) ([]byte, error) {
+	r, err := NewArchiveReader(bytes.NewReader(data), config)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// IsEncryptedArchive reports whether data starts like an encrypted archive
+func IsEncryptedArchive(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(ArchiveMagic))
+}
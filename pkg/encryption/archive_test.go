@@ -0,0 +1,133 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	config, _ := NewConfigFromKey(key, AlgorithmAES256GCM)
+
+	// Span several chunks, ending on a partial one
+	data := make([]byte, 3*ArchiveChunkSize+123)
+	rand.Read(data)
+
+	var buf bytes.Buffer
+	w, err := NewArchiveWriter(&buf, config)
+	if err != nil {
+		t.Fatalf("NewArchiveWriter() error = %v", err)
+	}
+	for i := 0; i < len(data); i += 1000 {
+		end := i + 1000
+		if end > len(data) {
+			end = len(data)
+		}
+		if _, err := w.Write(data[i:end]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if bytes.Contains(buf.Bytes(), data[:64]) {
+		t.Error("Archive contains plaintext")
+	}
+
+	r, err := NewArchiveReader(bytes.NewReader(buf.Bytes()), config)
+	if err != nil {
+		t.Fatalf("NewArchiveReader() error = %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Decrypted archive doesn't match the original data")
+	}
+}
+
+func TestArchiveWrongKey(t *testing.T) {
+	config1, _ := NewConfigFromPassword("password1", AlgorithmAES256GCM)
+	config2, _ := NewConfigFromPassword("password2", AlgorithmAES256GCM)
+
+	sealed, err := SealArchive([]byte("secret backup"), config1)
+	if err != nil {
+		t.Fatalf("SealArchive() error = %v", err)
+	}
+
+	if _, err := OpenArchive(sealed, config2); !errors.Is(err, ErrArchiveAuth) {
+		t.Errorf("Expected ErrArchiveAuth with the wrong password, got %v", err)
+	}
+
+	// The password alone is enough, the salt is in the archive
+	config3, _ := NewConfigFromPassword("password1", AlgorithmAES256GCM)
+	opened, err := OpenArchive(sealed, config3)
+	if err != nil {
+		t.Fatalf("OpenArchive() error = %v", err)
+	}
+	if string(opened) != "secret backup" {
+		t.Errorf("OpenArchive() = %q, want %q", opened, "secret backup")
+	}
+}
+
+func TestArchiveTampering(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	config, _ := NewConfigFromKey(key, AlgorithmAES256GCM)
+
+	data := make([]byte, 2*ArchiveChunkSize+10)
+	sealed, err := SealArchive(data, config)
+	if err != nil {
+		t.Fatalf("SealArchive() error = %v", err)
+	}
+
+	// Flipped bit
+	modified := append([]byte(nil), sealed...)
+	modified[len(modified)/2] ^= 1
+	if _, err := OpenArchive(modified, config); !errors.Is(err, ErrArchiveAuth) {
+		t.Errorf("Expected ErrArchiveAuth for a modified archive, got %v", err)
+	}
+
+	// Dropped final chunk
+	chunk := 4 + archiveNonceSize + ArchiveChunkSize + 16
+	header := len(ArchiveMagic) + 2
+	truncated := sealed[:header+2*chunk]
+	if _, err := OpenArchive(truncated, config); !errors.Is(err, ErrArchiveAuth) {
+		t.Errorf("Expected ErrArchiveAuth for a truncated archive, got %v", err)
+	}
+}
+
+func TestArchiveEncryptionMismatch(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	config, _ := NewConfigFromKey(key, AlgorithmAES256GCM)
+
+	sealed, _ := SealArchive([]byte("data"), config)
+	if !IsEncryptedArchive(sealed) {
+		t.Error("Expected archive to be recognized as encrypted")
+	}
+	if _, err := OpenArchive(sealed, nil); !errors.Is(err, ErrArchiveEncrypted) {
+		t.Errorf("Expected ErrArchiveEncrypted without a key, got %v", err)
+	}
+	if _, err := OpenArchive([]byte(`{"plain":true}`), config); !errors.Is(err, ErrArchiveNotEncrypted) {
+		t.Errorf("Expected ErrArchiveNotEncrypted for a plaintext archive, got %v", err)
+	}
+
+	// Without encryption the archive passes through unchanged
+	plain, err := OpenArchive([]byte("{}"), DefaultConfig())
+	if err != nil || string(plain) != "{}" {
+		t.Errorf("OpenArchive() = %q, %v, want %q", plain, err, "{}")
+	}
+
+	// Unauthenticated algorithms are refused
+	ctr, _ := NewConfigFromKey(key, AlgorithmAES256CTR)
+	if _, err := SealArchive([]byte("data"), ctr); err == nil {
+		t.Error("Expected an error sealing an archive with AES-256-CTR")
+	}
+}
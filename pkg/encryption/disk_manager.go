@@ -1,6 +1,7 @@
 package encryption
 
 import (
+	"crypto/aes"
 	"encoding/binary"
 	"fmt"
 
@@ -19,12 +20,15 @@ const (
 	EncryptionOverhead = 28
 )
 
-// EncryptedDiskManager wraps a DiskManager with transparent encryption
+// EncryptedDiskManager wraps a page store with transparent encryption. It
+// is itself a storage.PageStore, so it can back a storage engine.
 type EncryptedDiskManager struct {
-	diskMgr   *storage.DiskManager
+	diskMgr   storage.PageStore
 	encryptor *Encryptor
 }
 
+var _ storage.PageStore = (*EncryptedDiskManager)(nil)
+
 // NewEncryptedDiskManager creates a new encrypted disk manager
 func NewEncryptedDiskManager(path string, config *Config) (*EncryptedDiskManager, error) {
 	// Create underlying disk manager
@@ -33,15 +37,25 @@ func NewEncryptedDiskManager(path string, config *Config) (*EncryptedDiskManager
 		return nil, fmt.Errorf("failed to create disk manager: %w", err)
 	}
 
+	edm, err := NewEncryptedPageStore(diskMgr, config)
+	if err != nil {
+		diskMgr.Close()
+		return nil, err
+	}
+	return edm, nil
+}
+
+// NewEncryptedPageStore encrypts the pages of another page store, such as a
+// storage.MmapDiskManager
+func NewEncryptedPageStore(store storage.PageStore, config *Config) (*EncryptedDiskManager, error) {
 	// Create encryptor
 	encryptor, err := NewEncryptor(config)
 	if err != nil {
-		diskMgr.Close()
 		return nil, fmt.Errorf("failed to create encryptor: %w", err)
 	}
 
 	return &EncryptedDiskManager{
-		diskMgr:   diskMgr,
+		diskMgr:   store,
 		encryptor: encryptor,
 	}, nil
 }
@@ -81,6 +95,11 @@ func (edm *EncryptedDiskManager) ReadPage(pageID storage.PageID) (*storage.Page,
 	originalSize := binary.LittleEndian.Uint32(encryptedPage.Data[1:5])
 	encryptedData := encryptedPage.Data[EncryptedPageHeaderSize:]
 
+	// Drop the padding after the ciphertext
+	if size := int(originalSize) + ciphertextOverhead(algorithm); size <= len(encryptedData) {
+		encryptedData = encryptedData[:size]
+	}
+
 	// Decrypt data
 	decryptedData, err := edm.encryptor.Decrypt(encryptedData)
 	if err != nil {
@@ -94,7 +113,7 @@ func (edm *EncryptedDiskManager) ReadPage(pageID storage.PageID) (*storage.Page,
 	}
 
 	// Create properly sized page data buffer and copy decrypted data
-	pageDataSize := storage.PageSize - storage.PageHeaderSize
+	pageDataSize := edm.diskMgr.PageSize() - storage.PageHeaderSize
 	newPageData := make([]byte, pageDataSize)
 	copy(newPageData, decryptedData)
 	encryptedPage.Data = newPageData
@@ -102,6 +121,18 @@ func (edm *EncryptedDiskManager) ReadPage(pageID storage.PageID) (*storage.Page,
 	return encryptedPage, nil
 }
 
+// ciphertextOverhead returns how many bytes algorithm adds to the plaintext
+func ciphertextOverhead(algorithm Algorithm) int {
+	switch algorithm {
+	case AlgorithmAES256GCM:
+		return 12 + 16 // Nonce and authentication tag
+	case AlgorithmAES256CTR:
+		return aes.BlockSize // IV
+	default:
+		return 0
+	}
+}
+
 // WritePage encrypts and writes a page to disk
 func (edm *EncryptedDiskManager) WritePage(page *storage.Page) error {
 	// If encryption is disabled, write as-is
@@ -127,12 +158,12 @@ func (edm *EncryptedDiskManager) WritePage(page *storage.Page) error {
 
 	// Build encrypted page data with header
 	// Header: [1-byte algorithm][4-byte original size]
-	// We need to ensure the page data fits in the page (4KB - header size)
+	// We need to ensure the page data fits in the page (page size - header size)
 	headerSize := EncryptedPageHeaderSize
 	totalEncryptedSize := headerSize + len(encryptedData)
 
 	// If encrypted data is too large, we have a problem
-	pageDataSize := storage.PageSize - storage.PageHeaderSize
+	pageDataSize := edm.diskMgr.PageSize() - storage.PageHeaderSize
 	if totalEncryptedSize > pageDataSize {
 		return fmt.Errorf("encrypted data too large: %d bytes (max %d)", totalEncryptedSize, pageDataSize)
 	}
@@ -146,6 +177,16 @@ func (edm *EncryptedDiskManager) WritePage(page *storage.Page) error {
 	return edm.diskMgr.WritePage(encryptedPage)
 }
 
+// WritePages encrypts and writes a batch of pages
+func (edm *EncryptedDiskManager) WritePages(pages []*storage.Page) error {
+	for _, page := range pages {
+		if err := edm.WritePage(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // AllocatePage allocates a new page
 func (edm *EncryptedDiskManager) AllocatePage() (storage.PageID, error) {
 	return edm.diskMgr.AllocatePage()
@@ -166,6 +207,17 @@ func (edm *EncryptedDiskManager) Close() error {
 	return edm.diskMgr.Close()
 }
 
+// PageSize returns the page size of the underlying store
+func (edm *EncryptedDiskManager) PageSize() int {
+	return edm.diskMgr.PageSize()
+}
+
+// MaxPageDataSize returns the most page data that still fits in a page of
+// the underlying store once encrypted
+func (edm *EncryptedDiskManager) MaxPageDataSize() int {
+	return edm.diskMgr.PageSize() - storage.PageHeaderSize - EncryptedPageHeaderSize - EncryptionOverhead
+}
+
 // Stats returns disk manager statistics
 func (edm *EncryptedDiskManager) Stats() map[string]interface{} {
 	stats := edm.diskMgr.Stats()
@@ -324,3 +324,40 @@ func TestEncryptedDiskManager_WritePage_ErrorPaths(t *testing.T) {
 		}
 	})
 }
+
+// TestEncryptedPageStore tests encrypting the pages of another page store
+func TestEncryptedPageStore(t *testing.T) {
+	config, err := NewConfigFromPassword("test-password", AlgorithmAES256GCM)
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	inner := storage.NewMemoryPageStore()
+	edm, err := NewEncryptedPageStore(inner, config)
+	if err != nil {
+		t.Fatalf("Failed to create encrypted page store: %v", err)
+	}
+	defer edm.Close()
+
+	pageID, _ := edm.AllocatePage()
+	page := storage.NewPage(pageID, storage.PageTypeData)
+	page.Data = page.Data[:100]
+	copy(page.Data, "secret balance")
+	if err := edm.WritePages([]*storage.Page{page}); err != nil {
+		t.Fatalf("Failed to write page: %v", err)
+	}
+
+	// The inner store only holds ciphertext
+	raw, _ := inner.ReadPage(pageID)
+	if string(raw.Data[EncryptedPageHeaderSize:EncryptedPageHeaderSize+14]) == "secret balance" {
+		t.Error("Expected the inner page store to hold encrypted data")
+	}
+
+	read, err := edm.ReadPage(pageID)
+	if err != nil {
+		t.Fatalf("Failed to read page: %v", err)
+	}
+	if string(read.Data[:14]) != "secret balance" {
+		t.Errorf("Expected decrypted data, got %q", read.Data[:14])
+	}
+}
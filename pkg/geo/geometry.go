@@ -3,6 +3,8 @@ package geo
 import (
 	"fmt"
 	"math"
+
+	"github.com/mnohosten/laura-db/pkg/document"
 )
 
 // GeometryType represents the type of geometry
@@ -229,6 +231,28 @@ func ParseGeoJSONPoint(data map[string]interface{}) (*Point, error) {
 		return nil, fmt.Errorf("coordinates must be an array")
 	}
 
+	return parseCoordinatePair(coords)
+}
+
+// ParsePoint parses a point stored either as a legacy [lng, lat] coordinate
+// pair or as a GeoJSON-like point
+func ParsePoint(value interface{}) (*Point, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return ParseGeoJSONPoint(v)
+	case *document.Document:
+		// Embedded documents read back from storage
+		return ParseGeoJSONPoint(v.ToMap())
+	case []interface{}:
+		return parseCoordinatePair(v)
+	case *Point:
+		return v, nil
+	}
+	return nil, fmt.Errorf("point must be a [lng, lat] array or a GeoJSON point")
+}
+
+// parseCoordinatePair parses a [lng, lat] array
+func parseCoordinatePair(coords []interface{}) (*Point, error) {
 	if len(coords) != 2 {
 		return nil, fmt.Errorf("point coordinates must have 2 elements")
 	}
@@ -254,6 +254,33 @@ func TestParseGeoJSONPoint(t *testing.T) {
 	}
 }
 
+func TestParsePoint(t *testing.T) {
+	point, err := ParsePoint([]interface{}{-122.4, int64(37)})
+	if err != nil {
+		t.Fatalf("ParsePoint failed: %v", err)
+	}
+	if point.Lon != -122.4 || point.Lat != 37 {
+		t.Errorf("Expected (-122.4, 37), got (%f, %f)", point.Lon, point.Lat)
+	}
+
+	point, err = ParsePoint(map[string]interface{}{
+		"type":        "Point",
+		"coordinates": []interface{}{1.0, 2.0},
+	})
+	if err != nil {
+		t.Fatalf("ParsePoint failed: %v", err)
+	}
+	if point.Lon != 1.0 || point.Lat != 2.0 {
+		t.Errorf("Expected (1, 2), got (%f, %f)", point.Lon, point.Lat)
+	}
+
+	for _, invalid := range []interface{}{"here", []interface{}{1.0}, []interface{}{"a", 2.0}} {
+		if _, err := ParsePoint(invalid); err == nil {
+			t.Errorf("Expected error parsing %v", invalid)
+		}
+	}
+}
+
 func TestParseGeoJSONPointInvalid(t *testing.T) {
 	tests := []struct {
 		name string
@@ -2,6 +2,8 @@ package geo
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"sync"
 )
 
@@ -94,8 +96,20 @@ func (idx *Index2D) removeFromGrid(docID string, point *Point) {
 
 // getCellKey returns the grid cell key for a point
 func (idx *Index2D) getCellKey(point *Point) string {
-	x := int(point.Lon / idx.gridSize)
-	y := int(point.Lat / idx.gridSize)
+	x, y := idx.cellCoords(point)
+	return cellKey(x, y)
+}
+
+// cellCoords returns the grid coordinates of the cell containing a point.
+// Cell (x, y) covers [x*gridSize, (x+1)*gridSize) on each axis, so negative
+// coordinates round down rather than towards zero.
+func (idx *Index2D) cellCoords(point *Point) (int, int) {
+	x := int(math.Floor(point.Lon / idx.gridSize))
+	y := int(math.Floor(point.Lat / idx.gridSize))
+	return x, y
+}
+
+func cellKey(x, y int) string {
 	return fmt.Sprintf("%d,%d", x, y)
 }
 
@@ -132,33 +146,67 @@ type NearbyResult struct {
 }
 
 // FindNear finds documents near a point within a maximum distance
-// Returns results sorted by distance (ascending)
+// (maxDistance <= 0 means unbounded). Returns results sorted by distance
+// (ascending). Grid cells are searched in rings outward from the cell
+// containing center, stopping as soon as no unvisited cell can hold a point
+// closer than the limit-th result, so nearest-neighbor queries only touch
+// the cells around center.
 func (idx *Index2D) FindNear(center *Point, maxDistance float64, limit int) []NearbyResult {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 
-	// Calculate search bounding box
-	searchBox := &BoundingBox{
-		MinLon: center.Lon - maxDistance,
-		MaxLon: center.Lon + maxDistance,
-		MinLat: center.Lat - maxDistance,
-		MaxLat: center.Lat + maxDistance,
+	results := make([]NearbyResult, 0)
+	if len(idx.docPoints) == 0 {
+		return results
 	}
 
-	// Find all grid cells that intersect with search box
-	results := make([]NearbyResult, 0)
+	collect := func(cell map[string]*Point) {
+		for docID, point := range cell {
+			distance := Distance2D(center, point)
+			if maxDistance <= 0 || distance <= maxDistance {
+				results = append(results, NearbyResult{
+					DocID:    docID,
+					Point:    point,
+					Distance: distance,
+				})
+			}
+		}
+	}
 
-	for cellKey, cell := range idx.grid {
-		// Check if cell might contain relevant points
-		if idx.cellIntersectsBox(cellKey, searchBox) {
-			for docID, point := range cell {
-				distance := Distance2D(center, point)
-				if distance <= maxDistance {
-					results = append(results, NearbyResult{
-						DocID:    docID,
-						Point:    point,
-						Distance: distance,
-					})
+	// Rings needed to reach every indexed point, capped by maxDistance
+	cx, cy := idx.cellCoords(center)
+	minX, minY := idx.cellCoords(&Point{Lon: idx.bounds.MinLon, Lat: idx.bounds.MinLat})
+	maxX, maxY := idx.cellCoords(&Point{Lon: idx.bounds.MaxLon, Lat: idx.bounds.MaxLat})
+	maxRing := max(cx-minX, maxX-cx, cy-minY, maxY-cy, 0)
+	if maxDistance > 0 {
+		maxRing = min(maxRing, int(math.Ceil(maxDistance/idx.gridSize))+1)
+	}
+
+	if limit <= 0 && (2*maxRing+1)*(2*maxRing+1) > len(idx.grid) {
+		// Every match is needed and the index is sparse: scanning the
+		// occupied cells is cheaper than probing every cell in range
+		for _, cell := range idx.grid {
+			collect(cell)
+		}
+	} else {
+		for r := 0; r <= maxRing; r++ {
+			// Points in ring r are at least (r-1)*gridSize from center
+			if limit > 0 && len(results) >= limit {
+				sortByDistance(results)
+				if results[limit-1].Distance <= float64(r-1)*idx.gridSize {
+					break
+				}
+			}
+
+			for dx := -r; dx <= r; dx++ {
+				for dy := -r; dy <= r; dy++ {
+					// Only the cells on the ring's edge are new
+					if dx != -r && dx != r && dy != -r && dy != r {
+						continue
+					}
+					if cell, exists := idx.grid[cellKey(cx+dx, cy+dy)]; exists {
+						collect(cell)
+					}
 				}
 			}
 		}
@@ -232,15 +280,13 @@ func (idx *Index2D) cellIntersectsBox(cellKey string, box *BoundingBox) bool {
 	return cellBox.Intersects(box)
 }
 
-// sortByDistance sorts results by distance using insertion sort (efficient for small lists)
+// sortByDistance sorts results by distance, ties broken by document ID so
+// results are deterministic
 func sortByDistance(results []NearbyResult) {
-	for i := 1; i < len(results); i++ {
-		key := results[i]
-		j := i - 1
-		for j >= 0 && results[j].Distance > key.Distance {
-			results[j+1] = results[j]
-			j--
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
 		}
-		results[j+1] = key
-	}
+		return results[i].DocID < results[j].DocID
+	})
 }
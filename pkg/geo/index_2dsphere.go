@@ -2,6 +2,7 @@ package geo
 
 import (
 	"fmt"
+	"math"
 	"sync"
 )
 
@@ -91,9 +92,9 @@ func (idx *Index2DSphere) removeFromGrid(docID string, point *Point) {
 
 // getCellKey returns the grid cell key for a point
 func (idx *Index2DSphere) getCellKey(point *Point) string {
-	x := int(point.Lon / idx.gridSize)
-	y := int(point.Lat / idx.gridSize)
-	return fmt.Sprintf("%d,%d", x, y)
+	x := int(math.Floor(point.Lon / idx.gridSize))
+	y := int(math.Floor(point.Lat / idx.gridSize))
+	return cellKey(x, y)
 }
 
 // FindNear finds documents near a point within a maximum distance (in meters)
@@ -124,6 +124,45 @@ func TestIndex2D_NearWithLimit(t *testing.T) {
 	}
 }
 
+func TestIndex2D_NearNegativeCoordinates(t *testing.T) {
+	idx := NewIndex2D(1.0)
+
+	idx.Insert("sf", NewPoint(-122.42, 37.77))
+	idx.Insert("oakland", NewPoint(-122.27, 37.80))
+	idx.Insert("la", NewPoint(-118.24, 34.05))
+
+	// Points just across a cell boundary from the center must be found
+	results := idx.FindNear(NewPoint(-121.99, 37.79), 0.5, 10)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].DocID != "oakland" || results[1].DocID != "sf" {
+		t.Errorf("Expected oakland then sf, got %s then %s", results[0].DocID, results[1].DocID)
+	}
+}
+
+func TestIndex2D_NearUnbounded(t *testing.T) {
+	idx := NewIndex2D(1.0)
+
+	for i := 0; i < 50; i++ {
+		idx.Insert(string(rune('A'+i)), NewPoint(float64(i*3), float64(-i*2)))
+	}
+
+	// maxDistance <= 0 searches outward until the limit is reached
+	results := idx.FindNear(NewPoint(100, -70), 0, 2)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].DocID != string(rune('A'+34)) {
+		t.Errorf("Expected nearest point %s, got %s", string(rune('A'+34)), results[0].DocID)
+	}
+
+	results = idx.FindNear(NewPoint(100, -70), 0, 0)
+	if len(results) != 50 {
+		t.Errorf("Expected all 50 points, got %d", len(results))
+	}
+}
+
 func TestIndex2DSphere_InsertAndNear(t *testing.T) {
 	idx := NewIndex2DSphere(1.0)
 
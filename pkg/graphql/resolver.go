@@ -447,14 +447,14 @@ func (r *Resolver) UpdateMany(p graphql.ResolveParams) (interface{}, error) {
 	}
 
 	// Execute update
-	count, err := coll.UpdateMany(filter, update)
+	result, err := coll.UpdateMany(filter, update)
 	if err != nil {
 		return nil, fmt.Errorf("update failed: %w", err)
 	}
 
 	return map[string]interface{}{
-		"matchedCount":  count,
-		"modifiedCount": count,
+		"matchedCount":  result.MatchedCount,
+		"modifiedCount": result.ModifiedCount,
 	}, nil
 }
 
@@ -511,13 +511,13 @@ func (r *Resolver) DeleteMany(p graphql.ResolveParams) (interface{}, error) {
 	}
 
 	// Execute delete
-	count, err := coll.DeleteMany(filter)
+	result, err := coll.DeleteMany(filter)
 	if err != nil {
 		return nil, fmt.Errorf("delete failed: %w", err)
 	}
 
 	return map[string]interface{}{
-		"deletedCount": count,
+		"deletedCount": result.DeletedCount,
 	}, nil
 }
 
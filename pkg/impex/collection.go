@@ -5,6 +5,7 @@ import (
 	"io"
 
 	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/encryption"
 )
 
 // CollectionExporter provides high-level export functionality for collections
@@ -57,8 +58,26 @@ const (
 	FormatCSV Format = "csv"
 )
 
-// Export is a convenience function that exports documents in the specified format
+// Export is a convenience function that exports documents in the specified format.
+// With an "encryption" option (*encryption.Config) the export is written as
+// an encrypted archive that Import reads back with the same option.
 func Export(writer io.Writer, docs []*document.Document, format Format, options map[string]interface{}) error {
+	if config, ok := options["encryption"].(*encryption.Config); ok {
+		archive, err := encryption.NewArchiveWriter(writer, config)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt export: %w", err)
+		}
+		if err := export(archive, docs, format, options); err != nil {
+			return err
+		}
+		return archive.Close()
+	}
+
+	return export(writer, docs, format, options)
+}
+
+// export writes documents in the specified format
+func export(writer io.Writer, docs []*document.Document, format Format, options map[string]interface{}) error {
 	switch format {
 	case FormatJSON:
 		pretty := false
@@ -85,8 +104,30 @@ func Export(writer io.Writer, docs []*document.Document, format Format, options
 	}
 }
 
-// Import is a convenience function that imports documents in the specified format
+// Import is a convenience function that imports documents in the specified format.
+// An export encrypted with the "encryption" option needs the same option;
+// the archive is authenticated in full before any document is returned.
 func Import(reader io.Reader, format Format, options map[string]interface{}) ([]*document.Document, error) {
+	if config, ok := options["encryption"].(*encryption.Config); ok {
+		archive, err := encryption.NewArchiveReader(reader, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt import: %w", err)
+		}
+		docs, err := importFrom(archive, format, options)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(io.Discard, archive); err != nil {
+			return nil, fmt.Errorf("failed to decrypt import: %w", err)
+		}
+		return docs, nil
+	}
+
+	return importFrom(reader, format, options)
+}
+
+// importFrom reads documents in the specified format
+func importFrom(reader io.Reader, format Format, options map[string]interface{}) ([]*document.Document, error) {
 	switch format {
 	case FormatJSON:
 		importer := NewJSONImporter()
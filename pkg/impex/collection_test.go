@@ -2,10 +2,12 @@ package impex
 
 import (
 	"bytes"
+	"errors"
 	"strings"
 	"testing"
 
 	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/encryption"
 )
 
 func TestCollectionExporter(t *testing.T) {
@@ -255,6 +257,37 @@ func TestImportConvenienceFunction(t *testing.T) {
 	})
 }
 
+func TestEncryptedExportImport(t *testing.T) {
+	docs := []*document.Document{
+		document.NewDocumentFromMap(map[string]interface{}{"name": "Alice", "age": int64(30)}),
+		document.NewDocumentFromMap(map[string]interface{}{"name": "Bob", "age": int64(25)}),
+	}
+	config, _ := encryption.NewConfigFromPassword("export-password", encryption.AlgorithmAES256GCM)
+
+	for _, format := range []Format{FormatJSON, FormatCSV} {
+		var buf bytes.Buffer
+		if err := Export(&buf, docs, format, map[string]interface{}{"encryption": config}); err != nil {
+			t.Fatalf("Export(%s) failed: %v", format, err)
+		}
+		if strings.Contains(buf.String(), "Alice") {
+			t.Errorf("Encrypted %s export contains plaintext", format)
+		}
+
+		imported, err := Import(bytes.NewReader(buf.Bytes()), format, map[string]interface{}{"encryption": config})
+		if err != nil {
+			t.Fatalf("Import(%s) failed: %v", format, err)
+		}
+		if len(imported) != 2 {
+			t.Errorf("Expected 2 documents from %s, got %d", format, len(imported))
+		}
+
+		wrong, _ := encryption.NewConfigFromPassword("other-password", encryption.AlgorithmAES256GCM)
+		if _, err := Import(bytes.NewReader(buf.Bytes()), format, map[string]interface{}{"encryption": wrong}); !errors.Is(err, encryption.ErrArchiveAuth) {
+			t.Errorf("Expected ErrArchiveAuth importing %s with the wrong key, got %v", format, err)
+		}
+	}
+}
+
 func TestFormatConstants(t *testing.T) {
 	t.Run("FormatJSON", func(t *testing.T) {
 		if FormatJSON != "json" {
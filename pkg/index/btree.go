@@ -1,11 +1,8 @@
 package index
 
 import (
-	"bytes"
 	"fmt"
 	"sync"
-
-	"github.com/mnohosten/laura-db/pkg/document"
 )
 
 // BTree is a B+ tree index
@@ -337,6 +334,48 @@ func (bt *BTree) RangeScan(start, end interface{}) ([]interface{}, []interface{}
 	return keys, values
 }
 
+// PrefixRangeScan returns the key-value pairs of composite keys starting
+// with prefix whose next value lies in [low, high], in key order. A nil low
+// or high leaves that side of the range open.
+func (bt *BTree) PrefixRangeScan(prefix []interface{}, low, high interface{}) ([]interface{}, []interface{}) {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	keys := make([]interface{}, 0)
+	values := make([]interface{}, 0)
+
+	// Every matching key sorts at or after the prefix extended with low
+	start := make([]interface{}, len(prefix), len(prefix)+1)
+	copy(start, prefix)
+	if low != nil {
+		start = append(start, low)
+	}
+	startKey := NewCompositeKey(start...)
+	prefixKey := NewCompositeKey(prefix...)
+
+	for leaf := bt.findLeaf(bt.root, startKey); leaf != nil; leaf = leaf.next {
+		for i, k := range leaf.keys {
+			ck, ok := k.(*CompositeKey)
+			if !ok || ck.Compare(startKey) < 0 {
+				continue
+			}
+			// Keys are ordered by prefix first, so the first key past the
+			// prefix or past high ends the scan
+			if !ck.MatchesPrefix(prefixKey) {
+				return keys, values
+			}
+			if high != nil && len(ck.Values) > len(prefix) &&
+				compareValues(ck.Values[len(prefix)], high) > 0 {
+				return keys, values
+			}
+			keys = append(keys, k)
+			values = append(values, leaf.values[i])
+		}
+	}
+
+	return keys, values
+}
+
 // findLeaf finds the leaf node that should contain the key
 func (bt *BTree) findLeaf(node *BTreeNode, key interface{}) *BTreeNode {
 	if node.isLeaf {
@@ -369,52 +408,7 @@ func (bt *BTree) compare(a, b interface{}) int {
 		return 0
 	}
 
-	// Need to import document package for ObjectID
-	// So we'll handle it by type assertion
-	switch va := a.(type) {
-	case int64:
-		if vb, ok := b.(int64); ok {
-			if va < vb {
-				return -1
-			} else if va > vb {
-				return 1
-			}
-			return 0
-		}
-	case int32:
-		if vb, ok := b.(int32); ok {
-			if va < vb {
-				return -1
-			} else if va > vb {
-				return 1
-			}
-			return 0
-		}
-	case float64:
-		if vb, ok := b.(float64); ok {
-			if va < vb {
-				return -1
-			} else if va > vb {
-				return 1
-			}
-			return 0
-		}
-	case string:
-		if vb, ok := b.(string); ok {
-			return bytes.Compare([]byte(va), []byte(vb))
-		}
-	case []byte:
-		if vb, ok := b.([]byte); ok {
-			return bytes.Compare(va, vb)
-		}
-	case document.ObjectID:
-		if vb, ok := b.(document.ObjectID); ok {
-			return bytes.Compare(va[:], vb[:])
-		}
-	}
-
-	// Default: treat as equal if types don't match
-	return 0
+	return compareValues(a, b)
 }
 
 // Size returns the number of keys in the tree
@@ -483,8 +483,12 @@ func WriteNodeToDisk(diskMgr interface{}, node *BTreeNode, indexID, collectionID
 		node.pageID = uint32(pageID)
 	}
 
-	// Create page
-	page := storage.NewPage(storage.PageID(node.pageID), storage.PageTypeIndex)
+	// Create page, sized for stores that don't use the default page size
+	pageSize := storage.PageSize
+	if sized, ok := diskMgr.(interface{ PageSize() int }); ok {
+		pageSize = sized.PageSize()
+	}
+	page := storage.NewPageOfSize(storage.PageID(node.pageID), storage.PageTypeIndex, pageSize)
 
 	// Serialize node to page
 	if err := SerializeBTreeNode(node, page, indexID, collectionID); err != nil {
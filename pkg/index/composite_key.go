@@ -1,7 +1,6 @@
 package index
 
 import (
-	"bytes"
 	"fmt"
 
 	"github.com/mnohosten/laura-db/pkg/document"
@@ -68,92 +67,10 @@ func (ck *CompositeKey) String() string {
 	return fmt.Sprintf("%v", ck.Values)
 }
 
-// compareValues compares two values of potentially different types
+// compareValues compares two values of potentially different types using
+// the canonical type ordering (nil sorts before any value, numbers compare
+// by value across int/float types)
 // Returns: -1 if a < b, 0 if a == b, 1 if a > b
 func compareValues(a, b interface{}) int {
-	// Handle nil values
-	if a == nil && b == nil {
-		return 0
-	}
-	if a == nil {
-		return -1 // nil is less than any value
-	}
-	if b == nil {
-		return 1 // any value is greater than nil
-	}
-
-	// Type-specific comparison
-	switch va := a.(type) {
-	case int:
-		if vb, ok := b.(int); ok {
-			if va < vb {
-				return -1
-			} else if va > vb {
-				return 1
-			}
-			return 0
-		}
-	case int64:
-		if vb, ok := b.(int64); ok {
-			if va < vb {
-				return -1
-			} else if va > vb {
-				return 1
-			}
-			return 0
-		}
-		// Try to compare with int
-		if vb, ok := b.(int); ok {
-			vb64 := int64(vb)
-			if va < vb64 {
-				return -1
-			} else if va > vb64 {
-				return 1
-			}
-			return 0
-		}
-	case int32:
-		if vb, ok := b.(int32); ok {
-			if va < vb {
-				return -1
-			} else if va > vb {
-				return 1
-			}
-			return 0
-		}
-	case float64:
-		if vb, ok := b.(float64); ok {
-			if va < vb {
-				return -1
-			} else if va > vb {
-				return 1
-			}
-			return 0
-		}
-	case string:
-		if vb, ok := b.(string); ok {
-			return bytes.Compare([]byte(va), []byte(vb))
-		}
-	case []byte:
-		if vb, ok := b.([]byte); ok {
-			return bytes.Compare(va, vb)
-		}
-	case document.ObjectID:
-		if vb, ok := b.(document.ObjectID); ok {
-			return bytes.Compare(va[:], vb[:])
-		}
-	case bool:
-		if vb, ok := b.(bool); ok {
-			if va == vb {
-				return 0
-			}
-			if !va && vb {
-				return -1 // false < true
-			}
-			return 1
-		}
-	}
-
-	// If types don't match or are unsupported, treat as equal
-	return 0
+	return document.CompareValues(a, b)
 }
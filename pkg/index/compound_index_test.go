@@ -201,3 +201,69 @@ func TestCompositeKeyOrdering(t *testing.T) {
 		t.Errorf("Expected Seattle/28 last, got %v/%v", lastKey.Values[0], lastKey.Values[1])
 	}
 }
+
+func TestBTreePrefixRangeScan(t *testing.T) {
+	// A small order forces the entries across many leaves
+	bt := NewBTree(4)
+	countries := []string{"CA", "DE", "US"}
+	for _, country := range countries {
+		for age := int64(10); age < 60; age += 5 {
+			bt.Insert(NewCompositeKey(country, age), country)
+		}
+	}
+
+	ages := func(keys []interface{}) []int64 {
+		result := make([]int64, len(keys))
+		for i, k := range keys {
+			result[i] = k.(*CompositeKey).Values[1].(int64)
+		}
+		return result
+	}
+
+	t.Run("Prefix only", func(t *testing.T) {
+		keys, values := bt.PrefixRangeScan([]interface{}{"DE"}, nil, nil)
+		if len(keys) != 10 || len(values) != 10 {
+			t.Fatalf("Expected 10 entries, got %d", len(keys))
+		}
+		for _, k := range keys {
+			if k.(*CompositeKey).Values[0] != "DE" {
+				t.Errorf("Expected only DE keys, got %v", k)
+			}
+		}
+	})
+
+	t.Run("Prefix with range", func(t *testing.T) {
+		keys, _ := bt.PrefixRangeScan([]interface{}{"US"}, int64(20), int64(35))
+		got := ages(keys)
+		want := []int64{20, 25, 30, 35}
+		if len(got) != len(want) {
+			t.Fatalf("Expected ages %v, got %v", want, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Expected ages %v, got %v", want, got)
+			}
+		}
+	})
+
+	t.Run("Open ended range", func(t *testing.T) {
+		keys, _ := bt.PrefixRangeScan([]interface{}{"CA"}, int64(50), nil)
+		if got := ages(keys); len(got) != 2 || got[0] != 50 || got[1] != 55 {
+			t.Errorf("Expected ages [50 55], got %v", got)
+		}
+	})
+
+	t.Run("Empty prefix ranges over the first field", func(t *testing.T) {
+		keys, _ := bt.PrefixRangeScan(nil, "D", "E")
+		if len(keys) != 10 {
+			t.Errorf("Expected the 10 DE entries, got %d", len(keys))
+		}
+	})
+
+	t.Run("Missing prefix", func(t *testing.T) {
+		keys, _ := bt.PrefixRangeScan([]interface{}{"FR"}, nil, nil)
+		if len(keys) != 0 {
+			t.Errorf("Expected no entries, got %d", len(keys))
+		}
+	})
+}
@@ -15,6 +15,7 @@ type GeoIndex struct {
 	index2d    *geo.Index2D
 	index2ds   *geo.Index2DSphere
 	stats      *IndexStats
+	usage      *UsageCounter // How often queries used the index
 	mu         sync.RWMutex
 }
 
@@ -25,6 +26,7 @@ func NewGeoIndex(name, fieldPath string, indexType IndexType) *GeoIndex {
 		fieldPath: fieldPath,
 		indexType: indexType,
 		stats:     NewIndexStats(),
+		usage:     NewUsageCounter(),
 	}
 
 	switch indexType {
@@ -74,6 +76,21 @@ func (gi *GeoIndex) Remove(docID string) {
 	gi.stats.Update()
 }
 
+// Clear removes all points from the geospatial index
+func (gi *GeoIndex) Clear() {
+	gi.mu.Lock()
+	defer gi.mu.Unlock()
+
+	switch gi.indexType {
+	case IndexType2D:
+		gi.index2d = geo.NewIndex2D(1.0)
+	case IndexType2DSphere:
+		gi.index2ds = geo.NewIndex2DSphere(1.0)
+	}
+
+	gi.stats.Update()
+}
+
 // Near finds documents near a point within a maximum distance
 // For 2d: distance is in coordinate units
 // For 2dsphere: distance is in meters
@@ -141,6 +158,11 @@ func (gi *GeoIndex) Type() IndexType {
 	return gi.indexType
 }
 
+// Usage returns the counter of queries that used the index
+func (gi *GeoIndex) Usage() *UsageCounter {
+	return gi.usage
+}
+
 // Stats returns index statistics
 func (gi *GeoIndex) Stats() map[string]interface{} {
 	gi.mu.RLock()
@@ -16,6 +16,29 @@ const (
 	IndexType2DSphere // 2dsphere spherical geospatial index
 )
 
+// String returns the name of the index type
+func (t IndexType) String() string {
+	switch t {
+	case IndexTypeBTree:
+		return "btree"
+	case IndexTypeHash:
+		return "hash"
+	case IndexTypeText:
+		return "text"
+	case IndexType2D:
+		return "2d"
+	case IndexType2DSphere:
+		return "2dsphere"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(t))
+	}
+}
+
+// MarshalJSON encodes the index type by name
+func (t IndexType) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + t.String() + `"`), nil
+}
+
 // Index represents a database index
 type Index struct {
 	name          string
@@ -26,9 +49,51 @@ type Index struct {
 	btree         *BTree
 	stats         *IndexStats
 	buildProgress *IndexBuildProgress // Track background index build progress
+	usage         *UsageCounter       // How often queries used the index
+	entries       int                 // Values stored, counting each value of a shared key
 	mu            sync.RWMutex
 }
 
+// postingList holds the values of a key in a non-unique index. Values are
+// document ids, so they can be map keys.
+type postingList struct {
+	values []interface{}
+	pos    map[interface{}]int // Position of each value in values
+}
+
+// newPostingList returns a posting list holding value
+func newPostingList(value interface{}) *postingList {
+	return &postingList{
+		values: []interface{}{value},
+		pos:    map[interface{}]int{value: 0},
+	}
+}
+
+// add appends value, returning false if the list already holds it
+func (p *postingList) add(value interface{}) bool {
+	if _, exists := p.pos[value]; exists {
+		return false
+	}
+	p.pos[value] = len(p.values)
+	p.values = append(p.values, value)
+	return true
+}
+
+// remove removes value, returning false if the list doesn't hold it
+func (p *postingList) remove(value interface{}) bool {
+	i, exists := p.pos[value]
+	if !exists {
+		return false
+	}
+	// Move the last value into the gap
+	last := len(p.values) - 1
+	p.values[i] = p.values[last]
+	p.pos[p.values[i]] = i
+	p.values = p.values[:last]
+	delete(p.pos, value)
+	return true
+}
+
 // IndexConfig holds configuration for creating an index
 type IndexConfig struct {
 	Name       string
@@ -62,6 +127,7 @@ func NewIndex(config *IndexConfig) *Index {
 		filter:        config.Filter,
 		stats:         NewIndexStats(),
 		buildProgress: NewIndexBuildProgress(),
+		usage:         NewUsageCounter(),
 	}
 
 	switch config.Type {
@@ -78,7 +144,9 @@ func NewIndex(config *IndexConfig) *Index {
 	return idx
 }
 
-// Insert inserts a key-value pair into the index
+// Insert inserts a key-value pair into the index. A unique index holds one
+// value per key; a non-unique index holds any number of distinct values per
+// key. Inserting a pair the index already holds returns ErrDuplicateKey.
 func (idx *Index) Insert(key interface{}, value interface{}) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
@@ -86,53 +154,179 @@ func (idx *Index) Insert(key interface{}, value interface{}) error {
 	if idx.isUnique {
 		// Check if key already exists
 		if _, exists := idx.btree.Search(key); exists {
-			return fmt.Errorf("duplicate key in unique index: %v", key)
+			return fmt.Errorf("%w in unique index: %v", ErrDuplicateKey, key)
+		}
+	} else if existing, exists := idx.btree.Search(key); exists {
+		if !existing.(*postingList).add(value) {
+			return fmt.Errorf("%w: %v already indexed under %v", ErrDuplicateKey, value, key)
 		}
+		idx.entries++
+		idx.stats.Update()
+		return nil
 	}
 
-	err := idx.btree.Insert(key, value)
+	stored := value
+	if !idx.isUnique {
+		stored = newPostingList(value)
+	}
+	err := idx.btree.Insert(key, stored)
 	if err == nil {
+		idx.entries++
 		// Mark statistics as stale after successful insert
 		idx.stats.Update()
 	}
 	return err
 }
 
-// Search finds values by key
+// Search finds a value by key. A key of a non-unique index can have several
+// values; Search returns one of them, SearchAll returns them all.
 func (idx *Index) Search(key interface{}) (interface{}, bool) {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 
-	return idx.btree.Search(key)
+	value, exists := idx.btree.Search(key)
+	if list, ok := value.(*postingList); ok && exists {
+		return list.values[0], true
+	}
+	return value, exists
+}
+
+// SearchAll returns every value stored under key
+func (idx *Index) SearchAll(key interface{}) []interface{} {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	value, exists := idx.btree.Search(key)
+	if !exists {
+		return nil
+	}
+	if list, ok := value.(*postingList); ok {
+		return append([]interface{}(nil), list.values...)
+	}
+	return []interface{}{value}
 }
 
-// Delete removes a key from the index
+// Delete removes a key, with all its values, from the index
 func (idx *Index) Delete(key interface{}) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
+	value, exists := idx.btree.Search(key)
+	if !exists {
+		return ErrKeyNotFound
+	}
 	err := idx.btree.Delete(key)
 	if err == nil {
+		if list, ok := value.(*postingList); ok {
+			idx.entries -= len(list.values)
+		} else {
+			idx.entries--
+		}
 		// Mark statistics as stale after successful delete
 		idx.stats.Update()
 	}
 	return err
 }
 
-// RangeScan performs a range query
+// DeleteValue removes one value of a key, and the key once it has no values
+// left. It returns ErrKeyNotFound if the index doesn't hold the pair.
+func (idx *Index) DeleteValue(key interface{}, value interface{}) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	stored, exists := idx.btree.Search(key)
+	if !exists {
+		return ErrKeyNotFound
+	}
+	if list, ok := stored.(*postingList); ok {
+		if !list.remove(value) {
+			return ErrKeyNotFound
+		}
+		if len(list.values) == 0 {
+			idx.btree.Delete(key)
+		}
+	} else {
+		if stored != value {
+			return ErrKeyNotFound
+		}
+		idx.btree.Delete(key)
+	}
+	idx.entries--
+	idx.stats.Update()
+	return nil
+}
+
+// Clear removes all entries from the index, keeping its definition
+func (idx *Index) Clear() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.btree = NewBTree(idx.btree.order)
+	idx.entries = 0
+	idx.stats.Update()
+}
+
+// RangeScan performs a range query. A key with several values is returned
+// once per value.
 func (idx *Index) RangeScan(start, end interface{}) ([]interface{}, []interface{}) {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 
-	return idx.btree.RangeScan(start, end)
+	return expandPostings(idx.btree.RangeScan(start, end))
+}
+
+// PrefixRangeScan returns the entries of a compound index whose leading
+// fields equal prefix and whose next field lies in [low, high]
+func (idx *Index) PrefixRangeScan(prefix []interface{}, low, high interface{}) ([]interface{}, []interface{}) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return expandPostings(idx.btree.PrefixRangeScan(prefix, low, high))
+}
+
+// expandPostings turns the keys and stored values of a B+ tree scan into
+// one key-value pair per value
+func expandPostings(keys, values []interface{}) ([]interface{}, []interface{}) {
+	expanded := false
+	for _, v := range values {
+		if list, ok := v.(*postingList); ok && len(list.values) != 1 {
+			expanded = true
+			break
+		}
+	}
+	if !expanded {
+		for i, v := range values {
+			if list, ok := v.(*postingList); ok {
+				values[i] = list.values[0]
+			}
+		}
+		return keys, values
+	}
+
+	outKeys := make([]interface{}, 0, len(keys))
+	outValues := make([]interface{}, 0, len(values))
+	for i, v := range values {
+		list, ok := v.(*postingList)
+		if !ok {
+			outKeys = append(outKeys, keys[i])
+			outValues = append(outValues, v)
+			continue
+		}
+		for _, value := range list.values {
+			outKeys = append(outKeys, keys[i])
+			outValues = append(outValues, value)
+		}
+	}
+	return outKeys, outValues
 }
 
-// Size returns the number of entries in the index
+// Size returns the number of entries in the index, counting each value of
+// a shared key
 func (idx *Index) Size() int {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 
-	return idx.btree.Size()
+	return idx.entries
 }
 
 // Name returns the index name
@@ -173,6 +367,11 @@ func (idx *Index) Filter() map[string]interface{} {
 	return idx.filter
 }
 
+// Usage returns the counter of queries that used the index
+func (idx *Index) Usage() *UsageCounter {
+	return idx.usage
+}
+
 // Stats returns index statistics
 func (idx *Index) Stats() map[string]interface{} {
 	idx.mu.RLock()
@@ -186,7 +385,7 @@ func (idx *Index) Stats() map[string]interface{} {
 		"is_partial":  idx.IsPartial(),      // Is this a partial index
 		"type":        idx.indexType,
 		"unique":      idx.isUnique,
-		"size":        idx.btree.Size(),
+		"size":        idx.entries,
 		"height":      idx.btree.Height(),
 	}
 
@@ -211,13 +410,48 @@ func (idx *Index) Stats() map[string]interface{} {
 	return stats
 }
 
+// indexEntryOverhead approximates the memory of one B+ tree entry besides
+// its key and value
+const indexEntryOverhead = 32
+
+// SizeBytes estimates the memory held by the index's entries. Indexes live
+// in memory and are rebuilt from the documents when a database is opened,
+// so this is the index's whole footprint.
+func (idx *Index) SizeBytes() int64 {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	keys, values := expandPostings(idx.btree.RangeScan(nil, nil))
+	size := int64(0)
+	for i := range keys {
+		size += indexEntryOverhead + estimateKeySize(keys[i]) + estimateKeySize(values[i])
+	}
+	return size
+}
+
+// estimateKeySize approximates the memory held by an index key or value
+func estimateKeySize(v interface{}) int64 {
+	switch val := v.(type) {
+	case string:
+		return 16 + int64(len(val))
+	case *CompositeKey:
+		size := int64(24)
+		for _, item := range val.Values {
+			size += estimateKeySize(item)
+		}
+		return size
+	default:
+		return 16
+	}
+}
+
 // Analyze recalculates index statistics by scanning the entire index
 func (idx *Index) Analyze() {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 
 	// Collect all keys and values from the index
-	keys, _ := idx.btree.RangeScan(nil, nil)
+	keys, _ := expandPostings(idx.btree.RangeScan(nil, nil))
 
 	if len(keys) == 0 {
 		idx.stats.SetStats(0, 0, nil, nil)
@@ -261,6 +495,16 @@ func (idx *Index) GetBuildState() IndexBuildState {
 	return idx.buildProgress.GetState()
 }
 
+// BuildError returns why the index build failed, or "" if it didn't
+func (idx *Index) BuildError() string {
+	if idx.buildProgress == nil {
+		return ""
+	}
+	idx.buildProgress.mu.RLock()
+	defer idx.buildProgress.mu.RUnlock()
+	return idx.buildProgress.ErrorMessage
+}
+
 // GetBuildProgress returns the build progress information
 func (idx *Index) GetBuildProgress() map[string]interface{} {
 	if idx.buildProgress == nil {
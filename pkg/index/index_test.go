@@ -1,6 +1,8 @@
 package index
 
 import (
+	"errors"
+	"sort"
 	"testing"
 )
 
@@ -81,6 +83,83 @@ func TestIndex_Size(t *testing.T) {
 	}
 }
 
+func TestIndex_NonUniqueDuplicateKeys(t *testing.T) {
+	idx := createTestIndex("test_idx", []string{"status"}, false, nil)
+
+	idx.Insert("active", "doc1")
+	idx.Insert("inactive", "doc2")
+	idx.Insert("active", "doc3")
+	idx.Insert("active", "doc4")
+	if err := idx.Insert("active", "doc3"); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("Expected ErrDuplicateKey inserting the same pair twice, got %v", err)
+	}
+
+	if idx.Size() != 4 {
+		t.Errorf("Expected size 4, got %d", idx.Size())
+	}
+	if values := idx.SearchAll("active"); len(values) != 3 {
+		t.Errorf("Expected 3 values for active, got %v", values)
+	}
+	keys, values := idx.RangeScan(nil, nil)
+	if len(keys) != 4 || len(values) != 4 {
+		t.Errorf("Expected a range scan to return each value, got %v %v", keys, values)
+	}
+
+	// Removing one value keeps the others
+	if err := idx.DeleteValue("active", "doc3"); err != nil {
+		t.Fatalf("DeleteValue failed: %v", err)
+	}
+	if err := idx.DeleteValue("active", "doc3"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound removing a missing value, got %v", err)
+	}
+	values = idx.SearchAll("active")
+	sort.Slice(values, func(i, j int) bool { return values[i].(string) < values[j].(string) })
+	if len(values) != 2 || values[0] != "doc1" || values[1] != "doc4" {
+		t.Errorf("Expected doc1 and doc4 left under active, got %v", values)
+	}
+
+	// The key goes with its last value
+	idx.DeleteValue("inactive", "doc2")
+	if _, exists := idx.Search("inactive"); exists {
+		t.Error("Expected inactive to be removed with its last value")
+	}
+	if idx.Size() != 2 {
+		t.Errorf("Expected size 2, got %d", idx.Size())
+	}
+}
+
+func TestIndex_SizeBytes(t *testing.T) {
+	idx := createTestIndex("test_idx", []string{"name"}, false, nil)
+	if idx.SizeBytes() != 0 {
+		t.Errorf("Expected no size for an empty index, got %d", idx.SizeBytes())
+	}
+
+	idx.Insert("alice", "doc1")
+	small := idx.SizeBytes()
+	idx.Insert("a much longer name than alice", "doc2")
+	if small <= 0 || idx.SizeBytes() <= 2*small {
+		t.Errorf("Expected the size to grow with the entries, got %d then %d", small, idx.SizeBytes())
+	}
+}
+
+func TestIndex_Clear(t *testing.T) {
+	idx := createTestIndex("test_idx", []string{"email"}, true, nil)
+	idx.Insert("a@example.com", "doc1")
+	idx.Insert("b@example.com", "doc2")
+
+	idx.Clear()
+
+	if idx.Size() != 0 {
+		t.Errorf("Expected size 0 after clear, got %d", idx.Size())
+	}
+	if !idx.IsUnique() {
+		t.Error("Expected index to stay unique after clear")
+	}
+	if err := idx.Insert("a@example.com", "doc3"); err != nil {
+		t.Errorf("Expected insert of a cleared key to succeed: %v", err)
+	}
+}
+
 func TestIndex_FieldPath(t *testing.T) {
 	// Single field
 	idx1 := createTestIndex("idx1", []string{"name"}, false, nil)
@@ -401,10 +480,10 @@ func TestCompareValues_AllTypes(t *testing.T) {
 		t.Error("Expected nil == nil")
 	}
 
-	// Test mixed types - returns 0 (equal) for unsupported type combinations
+	// Test mixed types - ordered by type class (numbers < strings)
 	result := compareValues(int64(10), "string")
-	if result != 0 {
-		t.Error("Expected mixed types to be treated as equal (return 0)")
+	if result != -1 {
+		t.Error("Expected numbers to sort before strings")
 	}
 
 	// Test int32 comparison
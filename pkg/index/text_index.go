@@ -3,6 +3,7 @@ package index
 import (
 	"sync"
 
+	"github.com/mnohosten/laura-db/pkg/document"
 	"github.com/mnohosten/laura-db/pkg/text"
 )
 
@@ -10,18 +11,37 @@ import (
 type TextIndex struct {
 	name         string
 	fieldPaths   []string
+	weights      []int // relevance weight of each field in fieldPaths
 	invertedIdx  *text.InvertedIndex
 	stats        *IndexStats
+	usage        *UsageCounter // How often queries used the index
 	mu           sync.RWMutex
 }
 
 // NewTextIndex creates a new text index
 func NewTextIndex(name string, fieldPaths []string) *TextIndex {
+	return NewWeightedTextIndex(name, fieldPaths, nil)
+}
+
+// NewWeightedTextIndex creates a text index where matches in each field count
+// weights[field] times towards a document's relevance score. Fields without
+// a weight default to 1.
+func NewWeightedTextIndex(name string, fieldPaths []string, weights map[string]int) *TextIndex {
+	fieldWeights := make([]int, len(fieldPaths))
+	for i, fieldPath := range fieldPaths {
+		fieldWeights[i] = 1
+		if w, ok := weights[fieldPath]; ok && w > 0 {
+			fieldWeights[i] = w
+		}
+	}
+
 	return &TextIndex{
 		name:        name,
 		fieldPaths:  fieldPaths,
+		weights:     fieldWeights,
 		invertedIdx: text.NewInvertedIndex(),
 		stats:       NewIndexStats(),
+		usage:       NewUsageCounter(),
 	}
 }
 
@@ -45,6 +65,47 @@ func (ti *TextIndex) Index(docID string, texts []string) {
 	ti.stats.Update()
 }
 
+// IndexDocument indexes the string values of the indexed fields of doc,
+// weighting each field. Returns false if doc has no indexed text.
+func (ti *TextIndex) IndexDocument(docID string, doc *document.Document) bool {
+	texts := make([]string, len(ti.fieldPaths))
+	found := false
+	for i, fieldPath := range ti.fieldPaths {
+		if fieldValue, exists := doc.Get(fieldPath); exists {
+			if str, ok := fieldValue.(string); ok {
+				texts[i] = str
+				found = true
+			}
+		}
+	}
+	if !found {
+		return false
+	}
+
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+
+	ti.invertedIdx.IndexWeighted(docID, texts, ti.weights)
+
+	// Mark stats as stale
+	ti.stats.Update()
+	return true
+}
+
+// Texts returns the values of the indexed fields of doc, with "" for
+// fields that are missing or not strings
+func (ti *TextIndex) Texts(doc *document.Document) []string {
+	texts := make([]string, len(ti.fieldPaths))
+	for i, fieldPath := range ti.fieldPaths {
+		if fieldValue, exists := doc.Get(fieldPath); exists {
+			if str, ok := fieldValue.(string); ok {
+				texts[i] = str
+			}
+		}
+	}
+	return texts
+}
+
 // Remove removes a document from the text index
 func (ti *TextIndex) Remove(docID string) {
 	ti.mu.Lock()
@@ -56,6 +117,15 @@ func (ti *TextIndex) Remove(docID string) {
 	ti.stats.Update()
 }
 
+// Clear removes all documents from the text index
+func (ti *TextIndex) Clear() {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+
+	ti.invertedIdx = text.NewInvertedIndex()
+	ti.stats.Update()
+}
+
 // Search performs a text search and returns matching document IDs with scores
 func (ti *TextIndex) Search(query string) []text.SearchResult {
 	ti.mu.RLock()
@@ -78,11 +148,28 @@ func (ti *TextIndex) FieldPaths() []string {
 	return ti.fieldPaths
 }
 
+// Weights returns the relevance weight of each indexed field
+func (ti *TextIndex) Weights() map[string]int {
+	ti.mu.RLock()
+	defer ti.mu.RUnlock()
+
+	weights := make(map[string]int, len(ti.fieldPaths))
+	for i, fieldPath := range ti.fieldPaths {
+		weights[fieldPath] = ti.weights[i]
+	}
+	return weights
+}
+
 // IsCompound returns false for text indexes (they are not compound indexes in the B+ tree sense)
 func (ti *TextIndex) IsCompound() bool {
 	return len(ti.fieldPaths) > 1
 }
 
+// Usage returns the counter of queries that used the index
+func (ti *TextIndex) Usage() *UsageCounter {
+	return ti.usage
+}
+
 // Stats returns statistics about the text index
 func (ti *TextIndex) Stats() map[string]interface{} {
 	ti.mu.RLock()
@@ -93,6 +180,7 @@ func (ti *TextIndex) Stats() map[string]interface{} {
 	return map[string]interface{}{
 		"name":        ti.name,
 		"field_paths": ti.fieldPaths,
+		"weights":     ti.weights,
 		"type":        "text",
 		"is_compound": len(ti.fieldPaths) > 1,
 		"total_documents": stats["total_documents"],
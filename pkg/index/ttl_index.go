@@ -8,9 +8,9 @@ import (
 
 // TTLIndex represents a time-to-live index that tracks document expiration times
 type TTLIndex struct {
-	name       string
-	fieldPath  string
-	ttlSeconds int64 // Documents expire after this many seconds from the timestamp field
+	name      string
+	fieldPath string
+	ttl       time.Duration // Documents expire this long after the timestamp in the field
 
 	// Maps document ID to expiration timestamp
 	expirationTimes map[string]time.Time
@@ -20,10 +20,16 @@ type TTLIndex struct {
 
 // NewTTLIndex creates a new TTL index
 func NewTTLIndex(name, fieldPath string, ttlSeconds int64) *TTLIndex {
+	return NewTTLIndexWithDuration(name, fieldPath, time.Duration(ttlSeconds)*time.Second)
+}
+
+// NewTTLIndexWithDuration creates a new TTL index expiring documents ttl
+// after the timestamp in the field
+func NewTTLIndexWithDuration(name, fieldPath string, ttl time.Duration) *TTLIndex {
 	return &TTLIndex{
 		name:            name,
 		fieldPath:       fieldPath,
-		ttlSeconds:      ttlSeconds,
+		ttl:             ttl,
 		expirationTimes: make(map[string]time.Time),
 	}
 }
@@ -34,7 +40,7 @@ func (idx *TTLIndex) Index(docID string, timestamp time.Time) error {
 	defer idx.mu.Unlock()
 
 	// Calculate expiration time
-	expirationTime := timestamp.Add(time.Duration(idx.ttlSeconds) * time.Second)
+	expirationTime := timestamp.Add(idx.ttl)
 	idx.expirationTimes[docID] = expirationTime
 
 	return nil
@@ -48,6 +54,14 @@ func (idx *TTLIndex) Remove(docID string) {
 	delete(idx.expirationTimes, docID)
 }
 
+// Clear removes all documents from the TTL index
+func (idx *TTLIndex) Clear() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.expirationTimes = make(map[string]time.Time)
+}
+
 // GetExpiredDocuments returns a list of document IDs that have expired
 func (idx *TTLIndex) GetExpiredDocuments(currentTime time.Time) []string {
 	idx.mu.RLock()
@@ -74,9 +88,15 @@ func (idx *TTLIndex) FieldPath() string {
 	return idx.fieldPath
 }
 
-// TTLSeconds returns the TTL duration in seconds
+// TTL returns the time documents live after the timestamp in the field
+func (idx *TTLIndex) TTL() time.Duration {
+	return idx.ttl
+}
+
+// TTLSeconds returns the TTL duration in seconds, rounded up so that a
+// sub-second TTL isn't reported as none
 func (idx *TTLIndex) TTLSeconds() int64 {
-	return idx.ttlSeconds
+	return int64((idx.ttl + time.Second - 1) / time.Second)
 }
 
 // Count returns the number of documents tracked in the TTL index
@@ -102,5 +122,5 @@ func (idx *TTLIndex) String() string {
 	defer idx.mu.RUnlock()
 
 	return fmt.Sprintf("TTLIndex{name: %s, field: %s, ttl: %ds, docs: %d}",
-		idx.name, idx.fieldPath, idx.ttlSeconds, len(idx.expirationTimes))
+		idx.name, idx.fieldPath, idx.TTLSeconds(), len(idx.expirationTimes))
 }
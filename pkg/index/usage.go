@@ -0,0 +1,67 @@
+package index
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// IndexUsage reports how often an index was used by queries
+type IndexUsage struct {
+	Ops      int64     `json:"ops"`       // Number of queries that used the index
+	LastUsed time.Time `json:"last_used"` // When the index was last used (zero if never)
+	Since    time.Time `json:"since"`     // When counting started
+}
+
+// UsageCounter counts how often an index is used. Recording is a pair of
+// atomic stores, so it is cheap enough to do on every query.
+type UsageCounter struct {
+	ops      atomic.Int64
+	lastUsed atomic.Int64 // Unix nanoseconds, 0 if never used
+	since    time.Time
+}
+
+// NewUsageCounter creates a counter starting now
+func NewUsageCounter() *UsageCounter {
+	return &UsageCounter{since: time.Now()}
+}
+
+// Record counts one use of the index
+func (u *UsageCounter) Record() {
+	if u == nil {
+		return
+	}
+	u.ops.Add(1)
+	u.lastUsed.Store(time.Now().UnixNano())
+}
+
+// Usage returns the current counts
+func (u *UsageCounter) Usage() IndexUsage {
+	if u == nil {
+		return IndexUsage{}
+	}
+	usage := IndexUsage{
+		Ops:   u.ops.Load(),
+		Since: u.since,
+	}
+	if last := u.lastUsed.Load(); last != 0 {
+		usage.LastUsed = time.Unix(0, last)
+	}
+	return usage
+}
+
+// Add combines usage counted over two periods, such as before and after a
+// restart
+func (u IndexUsage) Add(other IndexUsage) IndexUsage {
+	combined := IndexUsage{
+		Ops:      u.Ops + other.Ops,
+		LastUsed: u.LastUsed,
+		Since:    u.Since,
+	}
+	if other.LastUsed.After(combined.LastUsed) {
+		combined.LastUsed = other.LastUsed
+	}
+	if combined.Since.IsZero() || (!other.Since.IsZero() && other.Since.Before(combined.Since)) {
+		combined.Since = other.Since
+	}
+	return combined
+}
@@ -33,6 +33,12 @@ type MetricsCollector struct {
 	transactionsCommitted uint64
 	transactionsAborted   uint64
 
+	// Distributed (two-phase commit) transaction metrics
+	distributedStarted   uint64
+	distributedCommitted uint64
+	distributedAborted   uint64
+	distributedInFlight  uint64
+
 	// Cache metrics
 	cacheHits         uint64
 	cacheMisses       uint64
@@ -45,6 +51,12 @@ type MetricsCollector struct {
 	activeConnections uint64
 	totalConnections  uint64
 
+	// Rate limiting metrics (for HTTP server)
+	rateLimitedReads    uint64
+	rateLimitedWrites   uint64
+	concurrencyRejected uint64
+	rateLimitClients    uint64 // clients with a live token bucket
+
 	// Operation timing buckets (histogram)
 	mu               sync.RWMutex
 	queryTimings     *TimingHistogram
@@ -52,6 +64,12 @@ type MetricsCollector struct {
 	updateTimings    *TimingHistogram
 	deleteTimings    *TimingHistogram
 
+	// Two-phase commit phase timings, overall and per participant
+	prepareTimings      *TimingHistogram
+	commitPhaseTimings  *TimingHistogram
+	abortReasons        map[string]uint64
+	participantTimings  map[string]*ParticipantTimings
+
 	// Start time for uptime calculation
 	startTime        time.Time
 }
@@ -71,6 +89,12 @@ type TimingHistogram struct {
 	maxRecentTimings int
 }
 
+// ParticipantTimings stores the two-phase commit timings of one participant
+type ParticipantTimings struct {
+	Prepare *TimingHistogram
+	Commit  *TimingHistogram
+}
+
 // NewMetricsCollector creates a new metrics collector
 func NewMetricsCollector() *MetricsCollector {
 	return &MetricsCollector{
@@ -78,7 +102,13 @@ func NewMetricsCollector() *MetricsCollector {
 		insertTimings: NewTimingHistogram(1000),
 		updateTimings: NewTimingHistogram(1000),
 		deleteTimings: NewTimingHistogram(1000),
-		startTime:     time.Now(),
+
+		prepareTimings:     NewTimingHistogram(1000),
+		commitPhaseTimings: NewTimingHistogram(1000),
+		abortReasons:       make(map[string]uint64),
+		participantTimings: make(map[string]*ParticipantTimings),
+
+		startTime: time.Now(),
 	}
 }
 
@@ -143,6 +173,86 @@ func (mc *MetricsCollector) RecordTransactionAbort() {
 	atomic.AddUint64(&mc.transactionsAborted, 1)
 }
 
+// RecordDistributedTransactionStart records the start of a two-phase commit
+func (mc *MetricsCollector) RecordDistributedTransactionStart() {
+	atomic.AddUint64(&mc.distributedStarted, 1)
+	atomic.AddUint64(&mc.distributedInFlight, 1)
+}
+
+// RecordDistributedTransactionCommit records a committed two-phase commit
+func (mc *MetricsCollector) RecordDistributedTransactionCommit() {
+	atomic.AddUint64(&mc.distributedCommitted, 1)
+	atomic.AddUint64(&mc.distributedInFlight, ^uint64(0))
+}
+
+// RecordDistributedTransactionAbort records an aborted two-phase commit and
+// the reason it aborted
+func (mc *MetricsCollector) RecordDistributedTransactionAbort(reason string) {
+	atomic.AddUint64(&mc.distributedAborted, 1)
+	atomic.AddUint64(&mc.distributedInFlight, ^uint64(0))
+
+	mc.mu.Lock()
+	mc.abortReasons[reason]++
+	mc.mu.Unlock()
+}
+
+// RecordPreparePhase records the duration of a prepare phase
+func (mc *MetricsCollector) RecordPreparePhase(duration time.Duration) {
+	mc.prepareTimings.Record(duration)
+}
+
+// RecordCommitPhase records the duration of a commit phase
+func (mc *MetricsCollector) RecordCommitPhase(duration time.Duration) {
+	mc.commitPhaseTimings.Record(duration)
+}
+
+// RecordParticipantPrepare records how long a participant took to vote
+func (mc *MetricsCollector) RecordParticipantPrepare(participant string, duration time.Duration) {
+	mc.participant(participant).Prepare.Record(duration)
+}
+
+// RecordParticipantCommit records how long a participant took to commit
+func (mc *MetricsCollector) RecordParticipantCommit(participant string, duration time.Duration) {
+	mc.participant(participant).Commit.Record(duration)
+}
+
+// participant returns the timings of a participant, creating them on first use
+func (mc *MetricsCollector) participant(id string) *ParticipantTimings {
+	mc.mu.RLock()
+	pt, exists := mc.participantTimings[id]
+	mc.mu.RUnlock()
+	if exists {
+		return pt
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if pt, exists = mc.participantTimings[id]; !exists {
+		pt = &ParticipantTimings{
+			Prepare: NewTimingHistogram(1000),
+			Commit:  NewTimingHistogram(1000),
+		}
+		mc.participantTimings[id] = pt
+	}
+	return pt
+}
+
+// distributedSnapshot copies the abort reasons and participant timings
+func (mc *MetricsCollector) distributedSnapshot() (map[string]uint64, map[string]*ParticipantTimings) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	reasons := make(map[string]uint64, len(mc.abortReasons))
+	for reason, count := range mc.abortReasons {
+		reasons[reason] = count
+	}
+	participants := make(map[string]*ParticipantTimings, len(mc.participantTimings))
+	for id, pt := range mc.participantTimings {
+		participants[id] = pt
+	}
+	return reasons, participants
+}
+
 // RecordCacheHit records a cache hit
 func (mc *MetricsCollector) RecordCacheHit() {
 	atomic.AddUint64(&mc.cacheHits, 1)
@@ -173,6 +283,25 @@ func (mc *MetricsCollector) RecordConnectionEnd() {
 	atomic.AddUint64(&mc.activeConnections, ^uint64(0)) // Decrement using two's complement
 }
 
+// RecordRateLimited records a request rejected by a read or write token bucket
+func (mc *MetricsCollector) RecordRateLimited(write bool) {
+	if write {
+		atomic.AddUint64(&mc.rateLimitedWrites, 1)
+	} else {
+		atomic.AddUint64(&mc.rateLimitedReads, 1)
+	}
+}
+
+// RecordConcurrencyRejected records a request rejected by the concurrent request limit
+func (mc *MetricsCollector) RecordConcurrencyRejected() {
+	atomic.AddUint64(&mc.concurrencyRejected, 1)
+}
+
+// SetRateLimitClients sets the number of clients currently tracked by the rate limiter
+func (mc *MetricsCollector) SetRateLimitClients(n int) {
+	atomic.StoreUint64(&mc.rateLimitClients, uint64(n))
+}
+
 // Record adds a timing to the histogram
 func (th *TimingHistogram) Record(duration time.Duration) {
 	// Update buckets atomically
@@ -283,6 +412,25 @@ func (mc *MetricsCollector) GetMetrics() map[string]interface{} {
 	activeConnections := atomic.LoadUint64(&mc.activeConnections)
 	totalConnections := atomic.LoadUint64(&mc.totalConnections)
 
+	rateLimitedReads := atomic.LoadUint64(&mc.rateLimitedReads)
+	rateLimitedWrites := atomic.LoadUint64(&mc.rateLimitedWrites)
+	concurrencyRejected := atomic.LoadUint64(&mc.concurrencyRejected)
+	rateLimitClients := atomic.LoadUint64(&mc.rateLimitClients)
+
+	distributedStarted := atomic.LoadUint64(&mc.distributedStarted)
+	distributedCommitted := atomic.LoadUint64(&mc.distributedCommitted)
+	distributedAborted := atomic.LoadUint64(&mc.distributedAborted)
+	distributedInFlight := atomic.LoadUint64(&mc.distributedInFlight)
+	abortReasons, participants := mc.distributedSnapshot()
+
+	participantMetrics := make(map[string]interface{}, len(participants))
+	for id, pt := range participants {
+		participantMetrics[id] = map[string]interface{}{
+			"prepare_percentiles": pt.Prepare.GetPercentiles(),
+			"commit_percentiles":  pt.Commit.GetPercentiles(),
+		}
+	}
+
 	// Calculate averages (prevent division by zero)
 	var avgQueryTime, avgInsertTime, avgUpdateTime, avgDeleteTime float64
 	if queriesExecuted > 0 {
@@ -354,6 +502,19 @@ func (mc *MetricsCollector) GetMetrics() map[string]interface{} {
 			"commit_rate":   calculateSuccessRate(transactionsStarted, transactionsAborted),
 		},
 
+		"distributed_transactions": map[string]interface{}{
+			"started":                  distributedStarted,
+			"committed":                distributedCommitted,
+			"aborted":                  distributedAborted,
+			"in_flight":                distributedInFlight,
+			"abort_reasons":            abortReasons,
+			"prepare_timing_histogram": mc.prepareTimings.GetBuckets(),
+			"prepare_percentiles":      mc.prepareTimings.GetPercentiles(),
+			"commit_timing_histogram":  mc.commitPhaseTimings.GetBuckets(),
+			"commit_percentiles":       mc.commitPhaseTimings.GetPercentiles(),
+			"participants":             participantMetrics,
+		},
+
 		"cache": map[string]interface{}{
 			"hits":        cacheHits,
 			"misses":      cacheMisses,
@@ -370,6 +531,13 @@ func (mc *MetricsCollector) GetMetrics() map[string]interface{} {
 			"active": activeConnections,
 			"total":  totalConnections,
 		},
+
+		"rate_limit": map[string]interface{}{
+			"limited_reads":        rateLimitedReads,
+			"limited_writes":       rateLimitedWrites,
+			"concurrency_rejected": concurrencyRejected,
+			"clients":              rateLimitClients,
+		},
 	}
 }
 
@@ -395,6 +563,11 @@ func (mc *MetricsCollector) Reset() {
 	atomic.StoreUint64(&mc.transactionsCommitted, 0)
 	atomic.StoreUint64(&mc.transactionsAborted, 0)
 
+	atomic.StoreUint64(&mc.distributedStarted, 0)
+	atomic.StoreUint64(&mc.distributedCommitted, 0)
+	atomic.StoreUint64(&mc.distributedAborted, 0)
+	// Don't reset distributedInFlight as it represents current state
+
 	atomic.StoreUint64(&mc.cacheHits, 0)
 	atomic.StoreUint64(&mc.cacheMisses, 0)
 
@@ -404,12 +577,20 @@ func (mc *MetricsCollector) Reset() {
 	atomic.StoreUint64(&mc.totalConnections, 0)
 	// Don't reset activeConnections as it represents current state
 
+	atomic.StoreUint64(&mc.rateLimitedReads, 0)
+	atomic.StoreUint64(&mc.rateLimitedWrites, 0)
+	atomic.StoreUint64(&mc.concurrencyRejected, 0)
+
 	// Reset histograms
 	mc.mu.Lock()
 	mc.queryTimings = NewTimingHistogram(1000)
 	mc.insertTimings = NewTimingHistogram(1000)
 	mc.updateTimings = NewTimingHistogram(1000)
 	mc.deleteTimings = NewTimingHistogram(1000)
+	mc.prepareTimings = NewTimingHistogram(1000)
+	mc.commitPhaseTimings = NewTimingHistogram(1000)
+	mc.abortReasons = make(map[string]uint64)
+	mc.participantTimings = make(map[string]*ParticipantTimings)
 	mc.mu.Unlock()
 
 	// Reset start time
@@ -111,6 +111,61 @@ func TestMetricsCollector_Transactions(t *testing.T) {
 	}
 }
 
+func TestMetricsCollector_DistributedTransactions(t *testing.T) {
+	mc := NewMetricsCollector()
+
+	mc.RecordDistributedTransactionStart()
+	mc.RecordDistributedTransactionStart()
+	mc.RecordDistributedTransactionStart()
+	mc.RecordPreparePhase(5 * time.Millisecond)
+	mc.RecordParticipantPrepare("shard-1", 2*time.Millisecond)
+	mc.RecordParticipantPrepare("shard-2", 500*time.Millisecond)
+	mc.RecordCommitPhase(3 * time.Millisecond)
+	mc.RecordParticipantCommit("shard-1", time.Millisecond)
+	mc.RecordDistributedTransactionCommit()
+	mc.RecordDistributedTransactionAbort("vote_no")
+
+	metrics := mc.GetMetrics()
+	dtxns := metrics["distributed_transactions"].(map[string]interface{})
+
+	if dtxns["started"].(uint64) != 3 {
+		t.Errorf("Expected 3 started transactions, got %v", dtxns["started"])
+	}
+	if dtxns["committed"].(uint64) != 1 {
+		t.Errorf("Expected 1 committed transaction, got %v", dtxns["committed"])
+	}
+	if dtxns["aborted"].(uint64) != 1 {
+		t.Errorf("Expected 1 aborted transaction, got %v", dtxns["aborted"])
+	}
+	if dtxns["in_flight"].(uint64) != 1 {
+		t.Errorf("Expected 1 in-flight transaction, got %v", dtxns["in_flight"])
+	}
+
+	reasons := dtxns["abort_reasons"].(map[string]uint64)
+	if reasons["vote_no"] != 1 {
+		t.Errorf("Expected 1 vote_no abort, got %d", reasons["vote_no"])
+	}
+
+	participants := dtxns["participants"].(map[string]interface{})
+	if len(participants) != 2 {
+		t.Fatalf("Expected 2 participants, got %d", len(participants))
+	}
+	slow := participants["shard-2"].(map[string]interface{})
+	if p99 := slow["prepare_percentiles"].(map[string]time.Duration)["p99"]; p99 != 500*time.Millisecond {
+		t.Errorf("Expected shard-2 prepare p99 of 500ms, got %v", p99)
+	}
+
+	// In-flight transactions survive a reset
+	mc.Reset()
+	dtxns = mc.GetMetrics()["distributed_transactions"].(map[string]interface{})
+	if dtxns["started"].(uint64) != 0 {
+		t.Errorf("Expected 0 started transactions after reset, got %v", dtxns["started"])
+	}
+	if dtxns["in_flight"].(uint64) != 1 {
+		t.Errorf("Expected 1 in-flight transaction after reset, got %v", dtxns["in_flight"])
+	}
+}
+
 func TestMetricsCollector_Cache(t *testing.T) {
 	mc := NewMetricsCollector()
 
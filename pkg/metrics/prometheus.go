@@ -3,6 +3,7 @@ package metrics
 import (
 	"fmt"
 	"io"
+	"sort"
 	"sync/atomic"
 	"time"
 )
@@ -152,6 +153,11 @@ func (pe *PrometheusExporter) WriteMetrics(w io.Writer) error {
 		return err
 	}
 
+	// Distributed transaction metrics
+	if err := pe.writeDistributedMetrics(w); err != nil {
+		return err
+	}
+
 	// Cache metrics
 	cacheHits := atomic.LoadUint64(&pe.collector.cacheHits)
 	cacheMisses := atomic.LoadUint64(&pe.collector.cacheMisses)
@@ -201,6 +207,20 @@ func (pe *PrometheusExporter) WriteMetrics(w io.Writer) error {
 		return err
 	}
 
+	// Rate limiting metrics
+	if err := pe.writeCounter(w, "rate_limited_reads_total", "Total read requests rejected by the rate limiter", atomic.LoadUint64(&pe.collector.rateLimitedReads)); err != nil {
+		return err
+	}
+	if err := pe.writeCounter(w, "rate_limited_writes_total", "Total write requests rejected by the rate limiter", atomic.LoadUint64(&pe.collector.rateLimitedWrites)); err != nil {
+		return err
+	}
+	if err := pe.writeCounter(w, "concurrency_rejected_total", "Total requests rejected by the concurrent request limit", atomic.LoadUint64(&pe.collector.concurrencyRejected)); err != nil {
+		return err
+	}
+	if err := pe.writeGauge(w, "rate_limit_clients", "Number of clients tracked by the rate limiter", float64(atomic.LoadUint64(&pe.collector.rateLimitClients))); err != nil {
+		return err
+	}
+
 	// Resource tracker metrics (if available)
 	if pe.resourceTracker != nil {
 		stats := pe.resourceTracker.GetStats()
@@ -255,6 +275,86 @@ func (pe *PrometheusExporter) WriteMetrics(w io.Writer) error {
 	return nil
 }
 
+// writeDistributedMetrics writes the two-phase commit metrics
+func (pe *PrometheusExporter) writeDistributedMetrics(w io.Writer) error {
+	if err := pe.writeCounter(w, "distributed_transactions_started_total", "Total number of distributed transactions started", atomic.LoadUint64(&pe.collector.distributedStarted)); err != nil {
+		return err
+	}
+	if err := pe.writeCounter(w, "distributed_transactions_committed_total", "Total number of distributed transactions committed", atomic.LoadUint64(&pe.collector.distributedCommitted)); err != nil {
+		return err
+	}
+	if err := pe.writeCounter(w, "distributed_transactions_aborted_total", "Total number of distributed transactions aborted", atomic.LoadUint64(&pe.collector.distributedAborted)); err != nil {
+		return err
+	}
+	if err := pe.writeGauge(w, "distributed_transactions_in_flight", "Current number of distributed transactions in progress", float64(atomic.LoadUint64(&pe.collector.distributedInFlight))); err != nil {
+		return err
+	}
+
+	if err := pe.writeHistogram(w, "distributed_prepare_duration_seconds", "Two-phase commit prepare phase duration histogram", pe.collector.prepareTimings); err != nil {
+		return err
+	}
+	if err := pe.writePercentiles(w, "distributed_prepare_duration_seconds", pe.collector.prepareTimings); err != nil {
+		return err
+	}
+	if err := pe.writeHistogram(w, "distributed_commit_duration_seconds", "Two-phase commit commit phase duration histogram", pe.collector.commitPhaseTimings); err != nil {
+		return err
+	}
+	if err := pe.writePercentiles(w, "distributed_commit_duration_seconds", pe.collector.commitPhaseTimings); err != nil {
+		return err
+	}
+
+	reasons, participants := pe.collector.distributedSnapshot()
+
+	// Aborts by reason
+	metricName := pe.namespace + "_distributed_transaction_aborts_total"
+	if _, err := fmt.Fprintf(w, "# HELP %s Distributed transactions aborted, by reason\n# TYPE %s counter\n", metricName, metricName); err != nil {
+		return err
+	}
+	for _, reason := range sortedKeys(reasons) {
+		if _, err := fmt.Fprintf(w, "%s{reason=%q} %d\n", metricName, reason, reasons[reason]); err != nil {
+			return err
+		}
+	}
+
+	// Per-participant percentiles, to find the slow voter
+	ids := make([]string, 0, len(participants))
+	for id := range participants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, phase := range []string{"prepare", "commit"} {
+		metricName := pe.namespace + "_distributed_participant_" + phase + "_seconds"
+		if _, err := fmt.Fprintf(w, "# HELP %s Two-phase commit %s duration per participant\n# TYPE %s gauge\n", metricName, phase, metricName); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			th := participants[id].Prepare
+			if phase == "commit" {
+				th = participants[id].Commit
+			}
+			percentiles := th.GetPercentiles()
+			for _, q := range []string{"p50", "p95", "p99"} {
+				if _, err := fmt.Fprintf(w, "%s{participant=%q,quantile=\"0.%s\"} %g\n",
+					metricName, id, q[1:], percentiles[q].Seconds()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// sortedKeys returns the keys of a counter map in order
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // writeCounter writes a counter metric
 func (pe *PrometheusExporter) writeCounter(w io.Writer, name, help string, value uint64) error {
 	metricName := pe.namespace + "_" + name
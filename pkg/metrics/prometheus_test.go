@@ -175,6 +175,37 @@ func TestPrometheusExporter_TransactionMetrics(t *testing.T) {
 	}
 }
 
+func TestPrometheusExporter_DistributedTransactionMetrics(t *testing.T) {
+	collector := NewMetricsCollector()
+	exporter := NewPrometheusExporter(collector, nil)
+
+	collector.RecordDistributedTransactionStart()
+	collector.RecordDistributedTransactionStart()
+	collector.RecordPreparePhase(20 * time.Millisecond)
+	collector.RecordParticipantPrepare("shard-1", 20*time.Millisecond)
+	collector.RecordDistributedTransactionAbort("timeout")
+
+	var buf bytes.Buffer
+	if err := exporter.WriteMetrics(&buf); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+	output := buf.String()
+
+	expected := []string{
+		"laura_db_distributed_transactions_started_total 2",
+		"laura_db_distributed_transactions_aborted_total 1",
+		"laura_db_distributed_transactions_in_flight 1",
+		`laura_db_distributed_transaction_aborts_total{reason="timeout"} 1`,
+		`laura_db_distributed_prepare_duration_seconds_bucket{le="0.1"} 1`,
+		`laura_db_distributed_participant_prepare_seconds{participant="shard-1",quantile="0.99"} 0.02`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q", want)
+		}
+	}
+}
+
 func TestPrometheusExporter_CacheMetrics(t *testing.T) {
 	collector := NewMetricsCollector()
 	exporter := NewPrometheusExporter(collector, nil)
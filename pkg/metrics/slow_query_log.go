@@ -87,6 +87,9 @@ func NewSlowQueryLog(config *SlowQueryLogConfig) (*SlowQueryLog, error) {
 
 // LogQuery logs a query if it exceeds the threshold
 func (sql *SlowQueryLog) LogQuery(entry SlowQueryEntry) {
+	sql.mu.Lock()
+	defer sql.mu.Unlock()
+
 	if !sql.enabled {
 		return
 	}
@@ -100,9 +103,6 @@ func (sql *SlowQueryLog) LogQuery(entry SlowQueryEntry) {
 	entry.Timestamp = time.Now()
 	entry.DurationMS = float64(entry.Duration.Nanoseconds()) / 1e6
 
-	sql.mu.Lock()
-	defer sql.mu.Unlock()
-
 	// Add to in-memory buffer
 	if len(sql.entries) >= sql.maxEntries {
 		// Remove oldest entry (FIFO)
@@ -11,4 +11,12 @@ var (
 
 	// ErrKeyNotFound is returned when a key doesn't exist
 	ErrKeyNotFound = errors.New("key not found")
+
+	// ErrLockTimeout is returned when a commit or prepare cannot acquire the
+	// transaction manager lock within the configured lock timeout
+	ErrLockTimeout = errors.New("timed out waiting for transaction lock")
+
+	// ErrTransactionTooOld is returned when a transaction was aborted for
+	// exceeding the maximum lifetime or number of pending operations
+	ErrTransactionTooOld = errors.New("transaction exceeded its lifetime or operation limit and was aborted")
 )
@@ -0,0 +1,137 @@
+package mvcc
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// GCConfig controls background version garbage collection
+type GCConfig struct {
+	Interval  time.Duration // Time between collection passes
+	BatchSize int           // Keys examined per version store lock hold
+}
+
+// DefaultGCConfig returns the default garbage collection configuration
+func DefaultGCConfig() *GCConfig {
+	return &GCConfig{
+		Interval:  time.Second,
+		BatchSize: 1000,
+	}
+}
+
+// versionGC holds the background collector state and its counters
+type versionGC struct {
+	mu        sync.Mutex // Serializes collection passes
+	batchSize int
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+
+	runs           int64
+	reclaimed      int64
+	bytesFreed     int64
+	oldestRetained uint64 // Oldest snapshot version kept by the last pass
+	lastRun        int64  // Unix nanoseconds of the last pass
+}
+
+// StartGC starts a background goroutine that periodically reclaims versions
+// no open transaction can see. Calling it while the collector runs is a no-op.
+func (tm *TransactionManager) StartGC(config *GCConfig) {
+	if config == nil {
+		config = DefaultGCConfig()
+	}
+
+	tm.gc.mu.Lock()
+	defer tm.gc.mu.Unlock()
+
+	if tm.gc.stopCh != nil || config.Interval <= 0 {
+		return
+	}
+
+	tm.gc.batchSize = config.BatchSize
+	tm.gc.stopCh = make(chan struct{})
+	tm.gc.wg.Add(1)
+	go tm.gcLoop(config.Interval, tm.gc.stopCh)
+}
+
+// StopGC stops the background collector and waits for it to exit
+func (tm *TransactionManager) StopGC() {
+	tm.gc.mu.Lock()
+	stopCh := tm.gc.stopCh
+	tm.gc.stopCh = nil
+	tm.gc.mu.Unlock()
+
+	if stopCh != nil {
+		close(stopCh)
+		tm.gc.wg.Wait()
+	}
+}
+
+// gcLoop runs a collection pass every interval until stopped
+func (tm *TransactionManager) gcLoop(interval time.Duration, stopCh chan struct{}) {
+	defer tm.gc.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			tm.GarbageCollect()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// GarbageCollect runs one collection pass. Every version older than the one
+// visible to the oldest active snapshot is reclaimed; versions an open
+// transaction can still read are always kept.
+// Returns the number of versions reclaimed and the estimated bytes freed.
+func (tm *TransactionManager) GarbageCollect() (int, int64) {
+	tm.gc.mu.Lock()
+	defer tm.gc.mu.Unlock()
+
+	minVersion := tm.oldestSnapshot()
+	reclaimed, freed := tm.versionStore.GarbageCollect(minVersion, tm.gc.batchSize)
+
+	atomic.AddInt64(&tm.gc.runs, 1)
+	atomic.AddInt64(&tm.gc.reclaimed, int64(reclaimed))
+	atomic.AddInt64(&tm.gc.bytesFreed, freed)
+	atomic.StoreUint64(&tm.gc.oldestRetained, minVersion)
+	atomic.StoreInt64(&tm.gc.lastRun, time.Now().UnixNano())
+
+	return reclaimed, freed
+}
+
+// oldestSnapshot returns the minimum read version among active transactions,
+// or the next commit version if there are none. Transactions begun later
+// always read at or above it, so it is safe to collect against.
+func (tm *TransactionManager) oldestSnapshot() uint64 {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	minReadVersion := atomic.LoadUint64(&tm.nextVersion)
+	for _, txn := range tm.activeTxns {
+		if txn.ReadVersion < minReadVersion {
+			minReadVersion = txn.ReadVersion
+		}
+	}
+	return minReadVersion
+}
+
+// GCStats returns version garbage collection statistics
+func (tm *TransactionManager) GCStats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"runs":                     atomic.LoadInt64(&tm.gc.runs),
+		"versions_reclaimed":       atomic.LoadInt64(&tm.gc.reclaimed),
+		"bytes_freed":              atomic.LoadInt64(&tm.gc.bytesFreed),
+		"oldest_retained_snapshot": atomic.LoadUint64(&tm.gc.oldestRetained),
+		"oldest_active_snapshot":   tm.oldestSnapshot(),
+		"oldest_snapshot_age":      tm.OldestSnapshotAge().String(),
+	}
+	if lastRun := atomic.LoadInt64(&tm.gc.lastRun); lastRun != 0 {
+		stats["last_run"] = time.Unix(0, lastRun)
+	}
+	return stats
+}
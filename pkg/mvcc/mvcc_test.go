@@ -1,7 +1,9 @@
 package mvcc
 
 import (
+	"errors"
 	"testing"
+	"time"
 )
 
 func TestTransactionBeginCommit(t *testing.T) {
@@ -161,6 +163,82 @@ func TestConcurrentTransactions(t *testing.T) {
 	txnMgr.Commit(t4)
 }
 
+func TestPrepareReservesKeys(t *testing.T) {
+	txnMgr := NewTransactionManager()
+
+	t1 := txnMgr.Begin()
+	t2 := txnMgr.Begin()
+	txnMgr.Write(t1, "balance", int64(100))
+	txnMgr.Write(t2, "balance", int64(200))
+
+	if err := txnMgr.Prepare(t1); err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if t1.State != TxnStatePrepared {
+		t.Errorf("Expected prepared state, got %v", t1.State)
+	}
+
+	// A prepared transaction takes no more writes
+	if err := txnMgr.Write(t1, "other", int64(1)); err != ErrTransactionNotActive {
+		t.Errorf("Expected ErrTransactionNotActive, got %v", err)
+	}
+
+	// Other transactions can't commit or prepare writes to reserved keys
+	if err := txnMgr.Commit(t2); err != ErrConflict {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+	if err := txnMgr.Prepare(t2); err != ErrConflict {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+
+	if err := txnMgr.Commit(t1); err != nil {
+		t.Fatalf("Commit of prepared transaction failed: %v", err)
+	}
+
+	t3 := txnMgr.Begin()
+	val, _, _ := txnMgr.Read(t3, "balance")
+	if val.(int64) != 100 {
+		t.Errorf("Expected 100, got %v", val)
+	}
+
+	// Aborting a prepared transaction releases its keys
+	t4 := txnMgr.Begin()
+	txnMgr.Write(t4, "balance", int64(300))
+	txnMgr.Prepare(t4)
+	if err := txnMgr.Abort(t4); err != nil {
+		t.Fatalf("Abort of prepared transaction failed: %v", err)
+	}
+	txnMgr.Write(t3, "balance", int64(400))
+	if err := txnMgr.Commit(t3); err != nil {
+		t.Errorf("Expected commit after abort to succeed, got %v", err)
+	}
+}
+
+func TestReserveConflicts(t *testing.T) {
+	txnMgr := NewTransactionManager()
+	txnMgr.SetTransactionLimits(0, 1)
+
+	t1 := txnMgr.Begin()
+	t2 := txnMgr.Begin()
+	txnMgr.Write(t1, "doc1", "a")
+	txnMgr.Write(t2, "doc2", "b")
+
+	// Reservations don't count towards the operation limit
+	if err := txnMgr.Reserve(t1, "unique:a@example.com", "doc1"); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if err := txnMgr.Reserve(t2, "unique:a@example.com", "doc2"); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+
+	if err := txnMgr.Commit(t1); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := txnMgr.Commit(t2); err != ErrConflict {
+		t.Errorf("Expected ErrConflict for a key reserved by a committed transaction, got %v", err)
+	}
+}
+
 func TestTransactionDelete(t *testing.T) {
 	txnMgr := NewTransactionManager()
 
@@ -221,6 +299,35 @@ func TestGetActiveTransactions(t *testing.T) {
 }
 
 // Test GetWriteSet and SetWriteSet
+func TestHasPendingWrite(t *testing.T) {
+	txnMgr := NewTransactionManager()
+
+	writer := txnMgr.Begin()
+	txnMgr.Write(writer, "written", "value")
+	deleter := txnMgr.Begin()
+	txnMgr.Delete(deleter, "deleted")
+	if err := txnMgr.Prepare(deleter); err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+
+	for _, key := range []string{"written", "deleted"} {
+		if !txnMgr.HasPendingWrite(key) {
+			t.Errorf("Expected a pending write of %s", key)
+		}
+	}
+	if txnMgr.HasPendingWrite("untouched") {
+		t.Error("Expected no pending write of an untouched key")
+	}
+
+	txnMgr.Commit(writer)
+	txnMgr.Abort(deleter)
+	for _, key := range []string{"written", "deleted"} {
+		if txnMgr.HasPendingWrite(key) {
+			t.Errorf("Expected no pending write of %s once its transaction ended", key)
+		}
+	}
+}
+
 func TestGetAndSetWriteSet(t *testing.T) {
 	txnMgr := NewTransactionManager()
 
@@ -491,3 +598,266 @@ func TestTransactionErrors(t *testing.T) {
 		t.Errorf("Expected ErrTransactionNotActive, got %v", err)
 	}
 }
+
+func TestCommitLockTimeout(t *testing.T) {
+	txnMgr := NewTransactionManager()
+	txnMgr.SetLockTimeout(20 * time.Millisecond)
+
+	txn := txnMgr.Begin()
+	txnMgr.Write(txn, "key1", []byte("value1"))
+
+	// Simulate a commit that never releases the manager lock
+	if err := txnMgr.lock(); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+
+	start := time.Now()
+	err := txnMgr.Commit(txn)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("Expected ErrLockTimeout, got %v", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("Expected commit to give up quickly, took %v", elapsed)
+	}
+	if txnMgr.LockTimeouts() != 1 {
+		t.Errorf("Expected 1 lock timeout, got %d", txnMgr.LockTimeouts())
+	}
+	if txn.State != TxnStateActive {
+		t.Errorf("Expected transaction to stay active after timeout, got %v", txn.State)
+	}
+
+	txnMgr.unlock()
+
+	// Once the lock is free the same transaction can still commit
+	if err := txnMgr.Commit(txn); err != nil {
+		t.Fatalf("Commit failed after lock was released: %v", err)
+	}
+}
+
+func TestAbortWaitsPastLockTimeout(t *testing.T) {
+	txnMgr := NewTransactionManager()
+	txnMgr.SetLockTimeout(10 * time.Millisecond)
+
+	txn := txnMgr.Begin()
+	txnMgr.Write(txn, "key1", []byte("value1"))
+
+	if err := txnMgr.lock(); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- txnMgr.Abort(txn) }()
+
+	// Hold the lock well past the timeout; the abort must keep waiting
+	select {
+	case err := <-done:
+		t.Fatalf("Abort returned while the lock was held: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	txnMgr.unlock()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+	if txn.State != TxnStateAborted {
+		t.Errorf("Expected transaction to be aborted, got %v", txn.State)
+	}
+	if txnMgr.GetActiveTransactions() != 0 {
+		t.Errorf("Expected no active transactions, got %d", txnMgr.GetActiveTransactions())
+	}
+	if txnMgr.LockTimeouts() != 0 {
+		t.Errorf("Expected no lock timeouts, got %d", txnMgr.LockTimeouts())
+	}
+}
+
+func TestCommitLockServesWaitersInOrder(t *testing.T) {
+	txnMgr := NewTransactionManager()
+	txnMgr.SetLockTimeout(time.Second)
+
+	if err := txnMgr.lock(); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+
+	const waiters = 5
+	order := make(chan int, waiters)
+	for i := 0; i < waiters; i++ {
+		go func(i int) {
+			if err := txnMgr.lock(); err != nil {
+				t.Errorf("waiter %d: %v", i, err)
+				return
+			}
+			order <- i
+			txnMgr.unlock()
+		}(i)
+		// Let each waiter queue before starting the next
+		time.Sleep(5 * time.Millisecond)
+	}
+	txnMgr.unlock()
+
+	for want := 0; want < waiters; want++ {
+		if got := <-order; got != want {
+			t.Fatalf("Expected waiter %d to get the lock next, got %d", want, got)
+		}
+	}
+}
+
+func TestAbortExpiredTransactions(t *testing.T) {
+	txnMgr := NewTransactionManager()
+	txnMgr.SetTransactionLimits(20*time.Millisecond, 0)
+
+	old := txnMgr.Begin()
+	time.Sleep(30 * time.Millisecond)
+	fresh := txnMgr.Begin()
+
+	if age := txnMgr.OldestSnapshotAge(); age < 30*time.Millisecond {
+		t.Errorf("Expected oldest snapshot age >= 30ms, got %v", age)
+	}
+
+	aborted, err := txnMgr.AbortExpired()
+	if err != nil {
+		t.Fatalf("AbortExpired failed: %v", err)
+	}
+	if aborted != 1 {
+		t.Errorf("Expected 1 aborted transaction, got %d", aborted)
+	}
+	if old.State != TxnStateAborted {
+		t.Errorf("Expected old transaction to be aborted, got %v", old.State)
+	}
+	if fresh.State != TxnStateActive {
+		t.Errorf("Expected fresh transaction to stay active, got %v", fresh.State)
+	}
+
+	if err := txnMgr.Write(old, "key1", "value1"); err != ErrTransactionTooOld {
+		t.Errorf("Expected ErrTransactionTooOld, got %v", err)
+	}
+	if err := txnMgr.Commit(old); err != ErrTransactionTooOld {
+		t.Errorf("Expected ErrTransactionTooOld, got %v", err)
+	}
+	if txnMgr.TooOldAborts() != 1 {
+		t.Errorf("Expected 1 too-old abort, got %d", txnMgr.TooOldAborts())
+	}
+}
+
+func TestTransactionOperationLimit(t *testing.T) {
+	txnMgr := NewTransactionManager()
+	txnMgr.SetTransactionLimits(0, 2)
+
+	txn := txnMgr.Begin()
+	if err := txnMgr.Write(txn, "key1", "value1"); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := txnMgr.Delete(txn, "key2"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := txnMgr.Write(txn, "key3", "value3"); err != ErrTransactionTooOld {
+		t.Fatalf("Expected ErrTransactionTooOld, got %v", err)
+	}
+	if txnMgr.GetActiveTransactions() != 0 {
+		t.Errorf("Expected 0 active transactions, got %d", txnMgr.GetActiveTransactions())
+	}
+	if _, _, err := txnMgr.Read(txn, "key1"); err != ErrTransactionTooOld {
+		t.Errorf("Expected ErrTransactionTooOld on read, got %v", err)
+	}
+}
+
+func TestGarbageCollectKeepsVisibleVersions(t *testing.T) {
+	txnMgr := NewTransactionManager()
+
+	for _, value := range []string{"v1", "v2"} {
+		txn := txnMgr.Begin()
+		txnMgr.Write(txn, "key1", value)
+		txnMgr.Commit(txn)
+	}
+
+	// Reader's snapshot sees v2
+	reader := txnMgr.Begin()
+
+	for _, value := range []string{"v3", "v4"} {
+		txn := txnMgr.Begin()
+		txnMgr.Write(txn, "key1", value)
+		txnMgr.Commit(txn)
+	}
+
+	reclaimed, freed := txnMgr.GarbageCollect()
+	if reclaimed != 1 {
+		t.Errorf("Expected 1 reclaimed version, got %d", reclaimed)
+	}
+	if freed <= 0 {
+		t.Errorf("Expected bytes freed > 0, got %d", freed)
+	}
+
+	value, exists, err := txnMgr.Read(reader, "key1")
+	if err != nil || !exists || value != "v2" {
+		t.Fatalf("Expected reader to still see v2, got %v (exists=%v, err=%v)", value, exists, err)
+	}
+
+	txnMgr.Commit(reader)
+	txnMgr.GarbageCollect()
+
+	if count := txnMgr.versionStore.GetVersionCount("key1"); count != 1 {
+		t.Errorf("Expected 1 version after reader finished, got %d", count)
+	}
+
+	stats := txnMgr.GCStats()
+	if stats["versions_reclaimed"].(int64) != 3 {
+		t.Errorf("Expected 3 versions reclaimed, got %v", stats["versions_reclaimed"])
+	}
+	if stats["runs"].(int64) != 2 {
+		t.Errorf("Expected 2 runs, got %v", stats["runs"])
+	}
+}
+
+func TestGarbageCollectRemovesDeletedKeys(t *testing.T) {
+	txnMgr := NewTransactionManager()
+
+	txn := txnMgr.Begin()
+	txnMgr.Write(txn, "key1", "value1")
+	txnMgr.Commit(txn)
+
+	txn = txnMgr.Begin()
+	txnMgr.Delete(txn, "key1")
+	txnMgr.Commit(txn)
+
+	// Deletion is the latest version but still the one new snapshots read
+	txnMgr.GarbageCollect()
+	if count := txnMgr.versionStore.GetVersionCount("key1"); count != 1 {
+		t.Errorf("Expected tombstone to be kept, got %d versions", count)
+	}
+
+	// Once a later commit moves every snapshot past it, the key disappears
+	txn = txnMgr.Begin()
+	txnMgr.Write(txn, "key2", "value2")
+	txnMgr.Commit(txn)
+
+	txnMgr.GarbageCollect()
+	if count := txnMgr.versionStore.GetVersionCount("key1"); count != 0 {
+		t.Errorf("Expected deleted key to be removed, got %d versions", count)
+	}
+}
+
+func TestBackgroundGC(t *testing.T) {
+	txnMgr := NewTransactionManager()
+	txnMgr.StartGC(&GCConfig{Interval: 5 * time.Millisecond, BatchSize: 2})
+	defer txnMgr.StopGC()
+
+	for i := 0; i < 5; i++ {
+		txn := txnMgr.Begin()
+		for _, key := range []string{"a", "b", "c"} {
+			txnMgr.Write(txn, key, i)
+		}
+		txnMgr.Commit(txn)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for txnMgr.GCStats()["versions_reclaimed"].(int64) < 12 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for _, key := range []string{"a", "b", "c"} {
+		if count := txnMgr.versionStore.GetVersionCount(key); count != 1 {
+			t.Errorf("Expected 1 version of %s, got %d", key, count)
+		}
+	}
+}
@@ -16,6 +16,7 @@ const (
 	TxnStateActive TxnState = iota
 	TxnStateCommitted
 	TxnStateAborted
+	TxnStatePrepared // Validated by Prepare, waiting for Commit or Abort
 )
 
 // Transaction represents a database transaction
@@ -27,6 +28,8 @@ type Transaction struct {
 	ReadVersion uint64                     // Snapshot version for reads
 	WriteSet    map[string]*VersionedValue // Local changes
 	ReadSet     map[string]uint64          // Tracks versions of keys read (for conflict detection)
+	ops         int                        // Number of writes and deletes issued
+	abortErr    error                      // Why the manager aborted the transaction, if it did
 	mu          sync.RWMutex
 }
 
@@ -45,8 +48,16 @@ type TransactionManager struct {
 	nextVersion    uint64
 	activeTxns     map[TxnID]*Transaction
 	committedTxns  map[TxnID]*Transaction
+	prepared       map[string]TxnID // Keys reserved by prepared transactions
 	mu             sync.RWMutex
+	commitSem      chan struct{} // Queues Commit, Prepare and Abort ahead of mu
 	versionStore   *VersionStore
+	lockTimeout    int64 // Max wait in nanoseconds for Commit/Prepare to acquire mu (0 waits forever)
+	lockTimeouts   int64 // Number of Commit/Prepare calls that gave up waiting
+	maxLifetime    int64 // Max transaction age in nanoseconds (0 means unlimited)
+	maxOps         int64 // Max writes and deletes per transaction (0 means unlimited)
+	tooOldAborts   int64 // Number of transactions aborted for exceeding a limit
+	gc             versionGC
 }
 
 // NewTransactionManager creates a new transaction manager
@@ -56,6 +67,8 @@ func NewTransactionManager() *TransactionManager {
 		nextVersion:   1,
 		activeTxns:    make(map[TxnID]*Transaction),
 		committedTxns: make(map[TxnID]*Transaction),
+		prepared:      make(map[string]TxnID),
+		commitSem:     make(chan struct{}, 1),
 		versionStore:  NewVersionStore(),
 	}
 }
@@ -81,16 +94,285 @@ func (tm *TransactionManager) Begin() *Transaction {
 	return txn
 }
 
-// Commit commits a transaction
-func (tm *TransactionManager) Commit(txn *Transaction) error {
+// SetLockTimeout sets how long Commit and Prepare wait for the transaction
+// manager lock before failing with ErrLockTimeout. Transactions are
+// optimistic and never wait on each other, so a long wait means a commit is
+// stuck; the timeout turns that hang into an error. 0 waits forever. Aborts
+// always wait, so a transaction being abandoned is never left active.
+func (tm *TransactionManager) SetLockTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&tm.lockTimeout, int64(timeout))
+}
+
+// LockTimeouts returns how many Commit/Prepare calls failed with ErrLockTimeout
+func (tm *TransactionManager) LockTimeouts() int64 {
+	return atomic.LoadInt64(&tm.lockTimeouts)
+}
+
+// lock acquires tm.mu for Commit or Prepare, giving up after the configured
+// lock timeout. Callers queue on commitSem, which serves them in arrival
+// order, before taking tm.mu. Release with unlock.
+func (tm *TransactionManager) lock() error {
+	timeout := time.Duration(atomic.LoadInt64(&tm.lockTimeout))
+	if timeout <= 0 {
+		tm.lockWait()
+		return nil
+	}
+
+	select {
+	case tm.commitSem <- struct{}{}:
+	default:
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case tm.commitSem <- struct{}{}:
+		case <-timer.C:
+			atomic.AddInt64(&tm.lockTimeouts, 1)
+			return ErrLockTimeout
+		}
+	}
 	tm.mu.Lock()
-	defer tm.mu.Unlock()
+	return nil
+}
+
+// lockWait acquires tm.mu like lock but waits however long it takes. Aborts
+// use it: giving up would leave the transaction active, pinning its snapshot.
+func (tm *TransactionManager) lockWait() {
+	tm.commitSem <- struct{}{}
+	tm.mu.Lock()
+}
+
+// unlock releases a lock taken with lock or lockWait
+func (tm *TransactionManager) unlock() {
+	tm.mu.Unlock()
+	<-tm.commitSem
+}
+
+// SetTransactionLimits bounds how long a transaction may stay open and how
+// many writes and deletes it may issue. A transaction exceeding either limit
+// is aborted with ErrTransactionTooOld so an abandoned transaction cannot pin
+// its snapshot and block version garbage collection. 0 disables a limit.
+func (tm *TransactionManager) SetTransactionLimits(maxLifetime time.Duration, maxOps int) {
+	atomic.StoreInt64(&tm.maxLifetime, int64(maxLifetime))
+	atomic.StoreInt64(&tm.maxOps, int64(maxOps))
+}
+
+// exceedsLimits reports whether a transaction is past its lifetime or has
+// used up its pending operations, given the operations it is about to add
+// Must be called with txn.mu held
+func (tm *TransactionManager) exceedsLimits(txn *Transaction, now time.Time, adding int) bool {
+	if maxLifetime := time.Duration(atomic.LoadInt64(&tm.maxLifetime)); maxLifetime > 0 && now.Sub(txn.StartTime) > maxLifetime {
+		return true
+	}
+	if maxOps := atomic.LoadInt64(&tm.maxOps); maxOps > 0 && int64(txn.ops+adding) > maxOps {
+		return true
+	}
+	return false
+}
+
+// abortTooOld aborts a transaction that exceeded a limit
+// Must be called with tm.mu and txn.mu held
+func (tm *TransactionManager) abortTooOld(txn *Transaction) {
+	txn.WriteSet = nil
+	txn.State = TxnStateAborted
+	txn.abortErr = ErrTransactionTooOld
+	delete(tm.activeTxns, txn.ID)
+	atomic.AddInt64(&tm.tooOldAborts, 1)
+}
+
+// notActiveErr returns the error for operating on a finished transaction
+// Must be called with txn.mu held
+func (txn *Transaction) notActiveErr() error {
+	if txn.abortErr != nil {
+		return txn.abortErr
+	}
+	return ErrTransactionNotActive
+}
+
+// CheckLimits aborts the transaction if it exceeds the configured limits.
+// Returns ErrTransactionTooOld if the transaction is (or already was) aborted
+// for exceeding them, nil otherwise.
+func (tm *TransactionManager) CheckLimits(txn *Transaction) error {
+	return tm.enforceLimits(txn, 0)
+}
+
+// enforceLimits is CheckLimits for an operation adding pending operations
+func (tm *TransactionManager) enforceLimits(txn *Transaction, adding int) error {
+	txn.mu.RLock()
+	state, abortErr := txn.State, txn.abortErr
+	exceeded := state == TxnStateActive && tm.exceedsLimits(txn, time.Now(), adding)
+	txn.mu.RUnlock()
+
+	if state != TxnStateActive {
+		if abortErr != nil {
+			return abortErr
+		}
+		return nil
+	}
+	if !exceeded {
+		return nil
+	}
+
+	tm.lockWait()
+	defer tm.unlock()
+
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	if txn.State == TxnStateActive {
+		tm.abortTooOld(txn)
+	}
+	return txn.notActiveErr()
+}
+
+// AbortExpired aborts every active transaction older than the maximum
+// lifetime and returns how many were aborted
+func (tm *TransactionManager) AbortExpired() (int, error) {
+	if atomic.LoadInt64(&tm.maxLifetime) <= 0 {
+		return 0, nil
+	}
+
+	tm.lockWait()
+	defer tm.unlock()
+
+	now := time.Now()
+	aborted := 0
+	for _, txn := range tm.activeTxns {
+		txn.mu.Lock()
+		if txn.State == TxnStateActive && tm.exceedsLimits(txn, now, 0) {
+			tm.abortTooOld(txn)
+			aborted++
+		}
+		txn.mu.Unlock()
+	}
+
+	return aborted, nil
+}
+
+// OldestSnapshotAge returns how long the oldest active transaction has been
+// open (0 if there are none). A steadily growing value points at a leaked
+// session holding back garbage collection.
+func (tm *TransactionManager) OldestSnapshotAge() time.Duration {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	var oldest time.Time
+	for _, txn := range tm.activeTxns {
+		if oldest.IsZero() || txn.StartTime.Before(oldest) {
+			oldest = txn.StartTime
+		}
+	}
+
+	if oldest.IsZero() {
+		return 0
+	}
+	return time.Since(oldest)
+}
+
+// TooOldAborts returns how many transactions were aborted for exceeding a limit
+func (tm *TransactionManager) TooOldAborts() int64 {
+	return atomic.LoadInt64(&tm.tooOldAborts)
+}
+
+// Prepare validates a transaction for commit without committing it, the
+// first phase of a two-phase commit. A prepared transaction takes no more
+// reads or writes, and the keys it writes are reserved: other transactions
+// writing them fail to commit with ErrConflict until it commits or aborts.
+// Committing a prepared transaction doesn't check for conflicts again.
+func (tm *TransactionManager) Prepare(txn *Transaction) error {
+	if err := tm.lock(); err != nil {
+		return err
+	}
+	defer tm.unlock()
 
 	txn.mu.Lock()
 	defer txn.mu.Unlock()
 
 	if txn.State != TxnStateActive {
-		return ErrTransactionNotActive
+		return txn.notActiveErr()
+	}
+
+	if tm.exceedsLimits(txn, time.Now(), 0) {
+		tm.abortTooOld(txn)
+		return ErrTransactionTooOld
+	}
+
+	if err := tm.checkConflicts(txn); err != nil {
+		return err
+	}
+
+	for key := range txn.WriteSet {
+		tm.prepared[key] = txn.ID
+	}
+	txn.State = TxnStatePrepared
+
+	return nil
+}
+
+// releasePrepared frees the keys reserved by a prepared transaction
+// Must be called with tm.mu and txn.mu held
+func (tm *TransactionManager) releasePrepared(txn *Transaction) {
+	for key := range txn.WriteSet {
+		if tm.prepared[key] == txn.ID {
+			delete(tm.prepared, key)
+		}
+	}
+}
+
+// Commit commits a transaction
+func (tm *TransactionManager) Commit(txn *Transaction) error {
+	if err := tm.lock(); err != nil {
+		return err
+	}
+	defer tm.unlock()
+
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	switch txn.State {
+	case TxnStateActive:
+		if tm.exceedsLimits(txn, time.Now(), 0) {
+			tm.abortTooOld(txn)
+			return ErrTransactionTooOld
+		}
+		if err := tm.checkConflicts(txn); err != nil {
+			return err
+		}
+	case TxnStatePrepared:
+		tm.releasePrepared(txn)
+	default:
+		return txn.notActiveErr()
+	}
+
+	// Assign commit version
+	commitVersion := atomic.AddUint64(&tm.nextVersion, 1)
+	txn.CommitTime = time.Now()
+
+	// Apply write set to version store
+	for key, versionedValue := range txn.WriteSet {
+		versionedValue.Version = commitVersion
+		versionedValue.CommitTime = txn.CommitTime
+		tm.versionStore.Put(key, versionedValue)
+	}
+
+	// Update transaction state
+	txn.State = TxnStateCommitted
+
+	// Move from active to committed
+	delete(tm.activeTxns, txn.ID)
+	tm.committedTxns[txn.ID] = txn
+
+	return nil
+}
+
+// checkConflicts returns ErrConflict if committing the transaction would
+// overwrite a change made since it started, or a key reserved by another
+// prepared transaction
+// Must be called with tm.mu and txn.mu held
+func (tm *TransactionManager) checkConflicts(txn *Transaction) error {
+	for key := range txn.WriteSet {
+		if owner, reserved := tm.prepared[key]; reserved && owner != txn.ID {
+			return ErrConflict
+		}
 	}
 
 	// Write conflict detection (First-Committer-Wins / Optimistic Concurrency Control)
@@ -124,40 +406,23 @@ func (tm *TransactionManager) Commit(txn *Transaction) error {
 		}
 	}
 
-	// Assign commit version
-	commitVersion := atomic.AddUint64(&tm.nextVersion, 1)
-	txn.CommitTime = time.Now()
-
-	// Apply write set to version store
-	for key, versionedValue := range txn.WriteSet {
-		versionedValue.Version = commitVersion
-		versionedValue.CommitTime = txn.CommitTime
-		tm.versionStore.Put(key, versionedValue)
-	}
-
-	// Update transaction state
-	txn.State = TxnStateCommitted
-
-	// Move from active to committed
-	delete(tm.activeTxns, txn.ID)
-	tm.committedTxns[txn.ID] = txn
-
-	// Trigger garbage collection if needed
-	go tm.maybeGarbageCollect()
-
 	return nil
 }
 
 // Abort aborts a transaction
 func (tm *TransactionManager) Abort(txn *Transaction) error {
-	tm.mu.Lock()
-	defer tm.mu.Unlock()
+	tm.lockWait()
+	defer tm.unlock()
 
 	txn.mu.Lock()
 	defer txn.mu.Unlock()
 
-	if txn.State != TxnStateActive {
-		return ErrTransactionNotActive
+	switch txn.State {
+	case TxnStateActive:
+	case TxnStatePrepared:
+		tm.releasePrepared(txn)
+	default:
+		return txn.notActiveErr()
 	}
 
 	// Discard write set
@@ -172,11 +437,15 @@ func (tm *TransactionManager) Abort(txn *Transaction) error {
 
 // Read reads a value within a transaction using snapshot isolation
 func (tm *TransactionManager) Read(txn *Transaction, key string) (interface{}, bool, error) {
+	if err := tm.enforceLimits(txn, 0); err != nil {
+		return nil, false, err
+	}
+
 	txn.mu.Lock()
 	defer txn.mu.Unlock()
 
 	if txn.State != TxnStateActive {
-		return nil, false, ErrTransactionNotActive
+		return nil, false, txn.notActiveErr()
 	}
 
 	// First check write set (read your own writes)
@@ -210,12 +479,17 @@ func (tm *TransactionManager) Read(txn *Transaction, key string) (interface{}, b
 
 // Write writes a value within a transaction
 func (tm *TransactionManager) Write(txn *Transaction, key string, value interface{}) error {
+	if err := tm.enforceLimits(txn, 1); err != nil {
+		return err
+	}
+
 	txn.mu.Lock()
 	defer txn.mu.Unlock()
 
 	if txn.State != TxnStateActive {
-		return ErrTransactionNotActive
+		return txn.notActiveErr()
 	}
+	txn.ops++
 
 	// Add to write set
 	txn.WriteSet[key] = &VersionedValue{
@@ -227,14 +501,44 @@ func (tm *TransactionManager) Write(txn *Transaction, key string, value interfac
 	return nil
 }
 
+// Reserve adds a key to the transaction's write set without counting it as
+// a write. It stands for something the transaction takes that isn't stored
+// under the key itself, such as a unique index entry, so that concurrent
+// transactions reserving the same key conflict on commit and a prepared
+// transaction holds it until it commits or aborts.
+func (tm *TransactionManager) Reserve(txn *Transaction, key string, value interface{}) error {
+	if err := tm.enforceLimits(txn, 0); err != nil {
+		return err
+	}
+
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	if txn.State != TxnStateActive {
+		return txn.notActiveErr()
+	}
+
+	txn.WriteSet[key] = &VersionedValue{
+		Value:     value,
+		CreatedBy: txn.ID,
+	}
+
+	return nil
+}
+
 // Delete deletes a value within a transaction
 func (tm *TransactionManager) Delete(txn *Transaction, key string) error {
+	if err := tm.enforceLimits(txn, 1); err != nil {
+		return err
+	}
+
 	txn.mu.Lock()
 	defer txn.mu.Unlock()
 
 	if txn.State != TxnStateActive {
-		return ErrTransactionNotActive
+		return txn.notActiveErr()
 	}
+	txn.ops++
 
 	// Mark as deleted in write set
 	txn.WriteSet[key] = &VersionedValue{
@@ -246,21 +550,21 @@ func (tm *TransactionManager) Delete(txn *Transaction, key string) error {
 	return nil
 }
 
-// maybeGarbageCollect triggers garbage collection if needed
-func (tm *TransactionManager) maybeGarbageCollect() {
+// HasPendingWrite reports whether an active or prepared transaction has
+// written or deleted key and not committed yet
+func (tm *TransactionManager) HasPendingWrite(key string) bool {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
 
-	// Find minimum read version among active transactions
-	minReadVersion := atomic.LoadUint64(&tm.nextVersion)
 	for _, txn := range tm.activeTxns {
-		if txn.ReadVersion < minReadVersion {
-			minReadVersion = txn.ReadVersion
+		txn.mu.RLock()
+		_, written := txn.WriteSet[key]
+		txn.mu.RUnlock()
+		if written {
+			return true
 		}
 	}
-
-	// Remove versions older than minReadVersion
-	tm.versionStore.GarbageCollect(minReadVersion)
+	return false
 }
 
 // GetActiveTransactions returns the number of active transactions
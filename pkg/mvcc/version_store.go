@@ -111,41 +111,120 @@ func (vs *VersionStore) GetLatest(key string) (interface{}, bool) {
 	return chain.Head.Value.Value, true
 }
 
-// GarbageCollect removes versions older than minVersion
-func (vs *VersionStore) GarbageCollect(minVersion uint64) {
-	vs.mu.Lock()
-	defer vs.mu.Unlock()
-
-	for key, chain := range vs.data {
-		chain.mu.Lock()
+// GarbageCollect removes versions no snapshot at or after minVersion can see.
+// For each key the newest version with Version <= minVersion is retained
+// (it is what the oldest snapshot reads) and everything older is dropped.
+// Keys are processed batchSize at a time so writers are only blocked briefly.
+// Returns the number of versions reclaimed and their estimated size in bytes.
+func (vs *VersionStore) GarbageCollect(minVersion uint64, batchSize int) (int, int64) {
+	keys := vs.GetAllKeys()
+	if batchSize <= 0 {
+		batchSize = len(keys)
+	}
 
-		// Keep at least one version
-		if chain.Head == nil || chain.Head.Next == nil {
-			chain.mu.Unlock()
-			continue
+	reclaimed := 0
+	var freed int64
+	for start := 0; start < len(keys); start += batchSize {
+		end := start + batchSize
+		if end > len(keys) {
+			end = len(keys)
 		}
 
-		// Traverse and remove old versions
-		prev := chain.Head
-		current := chain.Head.Next
-
-		for current != nil {
-			if current.Value.Version < minVersion {
-				// Remove this version
-				prev.Next = current.Next
-				current = prev.Next
-			} else {
-				prev = current
-				current = current.Next
+		vs.mu.Lock()
+		for _, key := range keys[start:end] {
+			chain, exists := vs.data[key]
+			if !exists {
+				continue
+			}
+			n, bytes, empty := chain.prune(minVersion)
+			reclaimed += n
+			freed += bytes
+			if empty {
+				delete(vs.data, key)
 			}
 		}
+		vs.mu.Unlock()
+	}
+
+	return reclaimed, freed
+}
+
+// prune drops versions of a chain hidden from every snapshot >= minVersion.
+// Reports whether the whole chain can be removed, which is the case when its
+// newest version is a delete older than every snapshot.
+func (chain *VersionChain) prune(minVersion uint64) (int, int64, bool) {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+
+	// Find the newest version visible to the oldest snapshot
+	visible := chain.Head
+	for visible != nil && visible.Value.Version > minVersion {
+		visible = visible.Next
+	}
+	if visible == nil {
+		return 0, 0, false
+	}
+
+	reclaimed := 0
+	var freed int64
+	for node := visible.Next; node != nil; node = node.Next {
+		reclaimed++
+		freed += versionSize(node.Value)
+	}
+	visible.Next = nil
+
+	if visible == chain.Head && visible.Value.DeletedBy != 0 && visible.Value.Version < minVersion {
+		reclaimed++
+		freed += versionSize(visible.Value)
+		chain.Head = nil
+		return reclaimed, freed, true
+	}
 
-		chain.mu.Unlock()
+	return reclaimed, freed, false
+}
+
+// versionNodeOverhead approximates the memory held by a version besides its value
+const versionNodeOverhead = 80
+
+// versionSize estimates the memory held by a version
+func versionSize(value *VersionedValue) int64 {
+	return versionNodeOverhead + estimateSize(value.Value)
+}
 
-		// If chain is empty, remove the key
-		if chain.Head == nil {
-			delete(vs.data, key)
+// estimateSize approximates the in-memory size of a stored value
+func estimateSize(v interface{}) int64 {
+	switch val := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(val))
+	case []byte:
+		return int64(len(val))
+	case bool, int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	case int, int64, uint, uint64, float64:
+		return 8
+	case map[string]interface{}:
+		var size int64
+		for k, item := range val {
+			size += int64(len(k)) + estimateSize(item)
+		}
+		return size
+	case []interface{}:
+		var size int64
+		for _, item := range val {
+			size += estimateSize(item)
 		}
+		return size
+	case interface{ ToMap() map[string]interface{} }:
+		// Documents
+		return estimateSize(val.ToMap())
+	default:
+		return 16
 	}
 }
 
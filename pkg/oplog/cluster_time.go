@@ -0,0 +1,51 @@
+package oplog
+
+import (
+	"sync"
+	"time"
+)
+
+// ClusterTime is a hybrid logical timestamp that orders operations across
+// oplogs. The upper 48 bits hold wall clock milliseconds, the lower 16 bits
+// a counter that keeps the timestamp increasing when the wall clock doesn't.
+type ClusterTime uint64
+
+// clusterTimeLogicalBits is the number of bits of the logical counter
+const clusterTimeLogicalBits = 16
+
+// clusterClock hands out cluster times for all oplogs in the process
+var clusterClock struct {
+	mu   sync.Mutex
+	last ClusterTime
+}
+
+// NextClusterTime returns a cluster time greater than every cluster time
+// returned before or passed to AdvanceClusterTime
+func NextClusterTime() ClusterTime {
+	clusterClock.mu.Lock()
+	defer clusterClock.mu.Unlock()
+
+	now := ClusterTime(time.Now().UnixMilli()) << clusterTimeLogicalBits
+	if now > clusterClock.last {
+		clusterClock.last = now
+	} else {
+		clusterClock.last++
+	}
+	return clusterClock.last
+}
+
+// AdvanceClusterTime moves the clock past a cluster time seen on another
+// node, so that later operations here are ordered after it
+func AdvanceClusterTime(t ClusterTime) {
+	clusterClock.mu.Lock()
+	defer clusterClock.mu.Unlock()
+
+	if t > clusterClock.last {
+		clusterClock.last = t
+	}
+}
+
+// Time returns the wall clock part of the cluster time
+func (t ClusterTime) Time() time.Time {
+	return time.UnixMilli(int64(t >> clusterTimeLogicalBits))
+}
@@ -0,0 +1,560 @@
+// Package oplog implements the operation log: an append-only file of
+// document and collection operations used by replication and change streams.
+package oplog
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+// OpType represents the type of operation in the oplog
+type OpType uint8
+
+const (
+	OpTypeInsert OpType = iota
+	OpTypeUpdate
+	OpTypeDelete
+	OpTypeCreateCollection
+	OpTypeDropCollection
+	OpTypeCreateIndex
+	OpTypeDropIndex
+	OpTypeNoop               // No-operation (used for heartbeats)
+	OpTypeTruncateCollection // All documents of a collection deleted at once
+	OpTypeRenameCollection   // Collection renamed; the new name is in Document["to"]
+)
+
+// String returns the string representation of OpType
+func (t OpType) String() string {
+	switch t {
+	case OpTypeInsert:
+		return "insert"
+	case OpTypeUpdate:
+		return "update"
+	case OpTypeDelete:
+		return "delete"
+	case OpTypeCreateCollection:
+		return "createCollection"
+	case OpTypeDropCollection:
+		return "dropCollection"
+	case OpTypeCreateIndex:
+		return "createIndex"
+	case OpTypeDropIndex:
+		return "dropIndex"
+	case OpTypeNoop:
+		return "noop"
+	case OpTypeTruncateCollection:
+		return "truncateCollection"
+	case OpTypeRenameCollection:
+		return "renameCollection"
+	default:
+		return "unknown"
+	}
+}
+
+// OpID is a unique identifier for an operation
+type OpID uint64
+
+// OplogEntry represents a single operation in the replication log
+type OplogEntry struct {
+	OpID        OpID                   `json:"op_id"`
+	Timestamp   time.Time              `json:"ts"`
+	ClusterTime ClusterTime            `json:"cluster_time,omitempty"` // Orders entries across oplogs
+	OpType      OpType                 `json:"op"`
+	Database    string                 `json:"db"`
+	Collection  string                 `json:"coll"`
+	DocID       interface{}            `json:"doc_id,omitempty"`    // _id of the document
+	Document    map[string]interface{} `json:"doc,omitempty"`       // For insert operations
+	Update      map[string]interface{} `json:"update,omitempty"`    // For update operations
+	Filter      map[string]interface{} `json:"filter,omitempty"`    // For update/delete operations
+	IndexDef    map[string]interface{} `json:"index_def,omitempty"` // For index operations
+	TxnID       OpID                   `json:"txn_id,omitempty"`    // Shared by the entries of a multi-op transaction
+}
+
+// Oplog manages the operation log for replication
+type Oplog struct {
+	file       *os.File
+	mu         sync.RWMutex
+	currentID  OpID
+	path       string
+	entries    []*OplogEntry // In-memory cache of recent entries
+	maxEntries int           // Maximum number of entries to keep in memory
+	appended   chan struct{} // Closed and replaced on each append, to wake waiters
+	closed     bool
+
+	size       int64     // Bytes of entries in the file
+	oldestID   OpID      // Oldest entry in the file, 0 if it has none
+	oldestTime time.Time // Timestamp of the oldest entry
+
+	config     OplogConfig         // Caps kept by Trim
+	stopTrim   chan struct{}       // Closed to stop the background trimmer, nil without one
+	retainers  map[string]Retainer // Consumers whose unread entries Trim keeps
+	retainerMu sync.Mutex
+}
+
+// ErrClosed is returned when waiting on a closed oplog
+var ErrClosed = errors.New("oplog is closed")
+
+// NewOplog creates a new operation log
+func NewOplog(path string) (*Oplog, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open oplog file: %w", err)
+	}
+
+	oplog := &Oplog{
+		file:       file,
+		currentID:  0,
+		path:       path,
+		entries:    make([]*OplogEntry, 0),
+		maxEntries: 10000, // Keep last 10k entries in memory
+		appended:   make(chan struct{}),
+		retainers:  make(map[string]Retainer),
+	}
+
+	// Load existing entries to determine current ID
+	if err := oplog.loadEntries(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to load oplog entries: %w", err)
+	}
+
+	return oplog, nil
+}
+
+// Append adds a new operation to the log
+func (o *Oplog) Append(entry *OplogEntry) error {
+	return o.appendEntries([]*OplogEntry{entry})
+}
+
+// AppendTransaction adds the operations of one transaction to the log as a
+// unit: they get consecutive OpIDs, readers see all of them or none, and,
+// if there is more than one, they share a TxnID (the OpID of the first), so
+// that batches keep them together and replicas apply them atomically.
+func (o *Oplog) AppendTransaction(entries []*OplogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return o.appendEntries(entries)
+}
+
+// appendEntries assigns OpIDs and timestamps to entries and writes them
+// with a single write and wakeup
+func (o *Oplog) appendEntries(entries []*OplogEntry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	// Assign OpIDs and timestamps. The cluster time is taken under the lock
+	// so that readers see entries in cluster time order.
+	var txnID OpID
+	if len(entries) > 1 {
+		txnID = o.currentID + 1
+	}
+	now := time.Now()
+	var data []byte
+	for i, entry := range entries {
+		entry.OpID = o.currentID + OpID(i) + 1
+		entry.Timestamp = now
+		entry.ClusterTime = NextClusterTime()
+		entry.TxnID = txnID
+
+		// Serialize entry
+		buf, err := o.serializeEntry(entry)
+		if err != nil {
+			return fmt.Errorf("failed to serialize entry: %w", err)
+		}
+		data = append(data, buf...)
+	}
+	o.currentID += OpID(len(entries))
+
+	// Write to file
+	if _, err := o.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write oplog entry: %w", err)
+	}
+	o.size += int64(len(data))
+	if o.oldestID == 0 {
+		o.oldestID, o.oldestTime = entries[0].OpID, now
+	}
+
+	// Add to in-memory cache
+	o.entries = append(o.entries, entries...)
+
+	// Trim cache if needed
+	if len(o.entries) > o.maxEntries {
+		o.entries = o.entries[len(o.entries)-o.maxEntries:]
+	}
+
+	close(o.appended)
+	o.appended = make(chan struct{})
+	return nil
+}
+
+// serializeEntry converts an oplog entry to bytes
+// Format: [4-byte length][JSON data]
+func (o *Oplog) serializeEntry(entry *OplogEntry) ([]byte, error) {
+	// Marshal to JSON
+	jsonData, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create buffer with length prefix
+	buf := make([]byte, 4+len(jsonData))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(jsonData)))
+	copy(buf[4:], jsonData)
+
+	return buf, nil
+}
+
+// deserializeEntry converts bytes to an oplog entry
+func (o *Oplog) deserializeEntry(data []byte) (*OplogEntry, error) {
+	var entry OplogEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetEntriesSince returns all entries after the given OpID
+func (o *Oplog) GetEntriesSince(afterID OpID) ([]*OplogEntry, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	// First check in-memory cache
+	result := make([]*OplogEntry, 0)
+	for _, entry := range o.entries {
+		if entry.OpID > afterID {
+			result = append(result, entry)
+		}
+	}
+
+	// If we found entries in cache, return them
+	if len(result) > 0 {
+		return result, nil
+	}
+
+	// Otherwise, read from disk
+	return o.readEntriesFromDisk(afterID)
+}
+
+// GetEntriesBatch returns the entries after the given OpID in order, up to
+// maxEntries entries and maxBytes bytes of serialized entries (0 for no
+// limit). The first entry is returned even if it is larger than maxBytes, so
+// that a reader always makes progress, and a batch is extended past the
+// limits to the end of a transaction rather than split it.
+func (o *Oplog) GetEntriesBatch(afterID OpID, maxEntries, maxBytes int) ([]*OplogEntry, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	// The cache answers when it reaches back to the entry after afterID
+	var entries []*OplogEntry
+	if len(o.entries) > 0 && o.entries[0].OpID <= afterID+1 {
+		start := sort.Search(len(o.entries), func(i int) bool { return o.entries[i].OpID > afterID })
+		entries = o.entries[start:]
+	} else {
+		var err error
+		if entries, err = o.readEntriesFromDisk(afterID); err != nil {
+			return nil, err
+		}
+	}
+
+	n := len(entries)
+	if maxEntries > 0 && n > maxEntries {
+		n = maxEntries
+	}
+	size := 0
+	for i, entry := range entries[:n] {
+		if maxBytes <= 0 {
+			break
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		size += len(data)
+		if size > maxBytes && i > 0 {
+			n = i
+			break
+		}
+	}
+	for n < len(entries) && entries[n].TxnID != 0 && entries[n].TxnID == entries[n-1].TxnID {
+		n++
+	}
+	entries = entries[:n]
+
+	result := make([]*OplogEntry, len(entries))
+	copy(result, entries)
+	return result, nil
+}
+
+// WaitForEntries blocks until the oplog holds an entry after the given OpID,
+// ctx is done or the oplog is closed
+func (o *Oplog) WaitForEntries(ctx context.Context, afterID OpID) error {
+	for {
+		o.mu.RLock()
+		current, appended, closed := o.currentID, o.appended, o.closed
+		o.mu.RUnlock()
+		if current > afterID {
+			return nil
+		}
+		if closed {
+			return ErrClosed
+		}
+
+		select {
+		case <-appended:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// readEntriesFromDisk reads entries from disk that are after the given OpID
+func (o *Oplog) readEntriesFromDisk(afterID OpID) ([]*OplogEntry, error) {
+	// Open file for reading
+	file, err := os.Open(o.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	result := make([]*OplogEntry, 0)
+	lengthBuf := make([]byte, 4)
+
+	for {
+		// Read length
+		n, err := file.Read(lengthBuf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n < 4 {
+			break // Incomplete entry
+		}
+
+		length := binary.LittleEndian.Uint32(lengthBuf)
+
+		// Read data
+		data := make([]byte, length)
+		if _, err := io.ReadFull(file, data); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		// Deserialize
+		entry, err := o.deserializeEntry(data)
+		if err != nil {
+			return nil, err
+		}
+
+		// Add if after specified ID
+		if entry.OpID > afterID {
+			result = append(result, entry)
+		}
+	}
+
+	return result, nil
+}
+
+// loadEntries loads all entries from disk into memory
+func (o *Oplog) loadEntries() error {
+	// Seek to beginning
+	if _, err := o.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	lengthBuf := make([]byte, 4)
+
+	for {
+		// Read length
+		n, err := o.file.Read(lengthBuf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if n < 4 {
+			break // Incomplete entry
+		}
+
+		length := binary.LittleEndian.Uint32(lengthBuf)
+
+		// Read data
+		data := make([]byte, length)
+		if _, err := io.ReadFull(o.file, data); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		// Deserialize
+		entry, err := o.deserializeEntry(data)
+		if err != nil {
+			return err
+		}
+
+		// Update current ID
+		if entry.OpID > o.currentID {
+			o.currentID = entry.OpID
+		}
+		o.size += 4 + int64(length)
+		if o.oldestID == 0 {
+			o.oldestID, o.oldestTime = entry.OpID, entry.Timestamp
+		}
+
+		// Add to cache (keep only recent entries)
+		o.entries = append(o.entries, entry)
+		if len(o.entries) > o.maxEntries {
+			o.entries = o.entries[1:]
+		}
+	}
+
+	// Seek back to end for appending
+	if _, err := o.file.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetCurrentID returns the current OpID
+func (o *Oplog) GetCurrentID() OpID {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.currentID
+}
+
+// Flush ensures all data is written to disk
+func (o *Oplog) Flush() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.file.Sync()
+}
+
+// Close closes the oplog
+func (o *Oplog) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.closed {
+		o.closed = true
+		close(o.appended)
+		if o.stopTrim != nil {
+			close(o.stopTrim)
+		}
+	}
+
+	if err := o.file.Sync(); err != nil {
+		return err
+	}
+	return o.file.Close()
+}
+
+// CreateInsertEntry creates an oplog entry for an insert operation
+func CreateInsertEntry(db, coll string, doc map[string]interface{}) *OplogEntry {
+	docID := doc["_id"]
+	if docID == nil {
+		// Generate new ObjectID if not present
+		docID = document.NewObjectID()
+		doc["_id"] = docID
+	}
+
+	return &OplogEntry{
+		OpType:     OpTypeInsert,
+		Database:   db,
+		Collection: coll,
+		DocID:      docID,
+		Document:   doc,
+	}
+}
+
+// CreateUpdateEntry creates an oplog entry for an update operation
+func CreateUpdateEntry(db, coll string, filter, update map[string]interface{}) *OplogEntry {
+	return &OplogEntry{
+		OpType:     OpTypeUpdate,
+		Database:   db,
+		Collection: coll,
+		Filter:     filter,
+		Update:     update,
+	}
+}
+
+// CreateDeleteEntry creates an oplog entry for a delete operation
+func CreateDeleteEntry(db, coll string, filter map[string]interface{}) *OplogEntry {
+	return &OplogEntry{
+		OpType:     OpTypeDelete,
+		Database:   db,
+		Collection: coll,
+		Filter:     filter,
+	}
+}
+
+// CreateCollectionEntry creates an oplog entry for collection operations
+func CreateCollectionEntry(db, coll string, create bool) *OplogEntry {
+	opType := OpTypeCreateCollection
+	if !create {
+		opType = OpTypeDropCollection
+	}
+	return &OplogEntry{
+		OpType:     opType,
+		Database:   db,
+		Collection: coll,
+	}
+}
+
+// CreateTruncateEntry creates an oplog entry for truncating a collection
+func CreateTruncateEntry(db, coll string) *OplogEntry {
+	return &OplogEntry{
+		OpType:     OpTypeTruncateCollection,
+		Database:   db,
+		Collection: coll,
+	}
+}
+
+// CreateRenameEntry creates an oplog entry for renaming a collection
+func CreateRenameEntry(db, oldColl, newColl string) *OplogEntry {
+	return &OplogEntry{
+		OpType:     OpTypeRenameCollection,
+		Database:   db,
+		Collection: oldColl,
+		Document:   map[string]interface{}{"to": newColl},
+	}
+}
+
+// CreateIndexEntry creates an oplog entry for index operations. Creating
+// takes the index definition (see database.Collection.IndexDefinition),
+// dropping only needs its name.
+func CreateIndexEntry(db, coll string, indexDef map[string]interface{}, create bool) *OplogEntry {
+	opType := OpTypeCreateIndex
+	if !create {
+		opType = OpTypeDropIndex
+	}
+	return &OplogEntry{
+		OpType:     opType,
+		Database:   db,
+		Collection: coll,
+		IndexDef:   indexDef,
+	}
+}
+
+// CreateNoopEntry creates a no-operation entry (for heartbeats)
+func CreateNoopEntry(db string) *OplogEntry {
+	return &OplogEntry{
+		OpType:   OpTypeNoop,
+		Database: db,
+	}
+}
@@ -1,10 +1,14 @@
-package replication
+package oplog
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestOplogBasic(t *testing.T) {
@@ -465,3 +469,178 @@ func BenchmarkOplogGetEntriesSince(b *testing.B) {
 		}
 	}
 }
+
+func TestOplogClusterTime(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	log1, _ := NewOplog(filepath.Join(tmpDir, "oplog1.bin"))
+	defer log1.Close()
+	log2, _ := NewOplog(filepath.Join(tmpDir, "oplog2.bin"))
+	defer log2.Close()
+
+	// Entries of different oplogs share one increasing clock
+	var last ClusterTime
+	for i := 0; i < 100; i++ {
+		log := log1
+		if i%2 == 1 {
+			log = log2
+		}
+		entry := CreateNoopEntry("testdb")
+		if err := log.Append(entry); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+		if entry.ClusterTime <= last {
+			t.Fatalf("Expected cluster time after %d, got %d", last, entry.ClusterTime)
+		}
+		last = entry.ClusterTime
+	}
+
+	// Time received from another node moves the clock forward
+	ahead := last + 1000<<clusterTimeLogicalBits
+	AdvanceClusterTime(ahead)
+	if next := NextClusterTime(); next <= ahead {
+		t.Errorf("Expected cluster time after %d, got %d", ahead, next)
+	}
+}
+
+func TestOplogGetEntriesBatch(t *testing.T) {
+	oplog, err := NewOplog(filepath.Join(t.TempDir(), "oplog.bin"))
+	if err != nil {
+		t.Fatalf("Failed to create oplog: %v", err)
+	}
+	defer oplog.Close()
+
+	for i := 0; i < 10; i++ {
+		if err := oplog.Append(CreateInsertEntry("testdb", "users", map[string]interface{}{"index": int64(i)})); err != nil {
+			t.Fatalf("Failed to append entry: %v", err)
+		}
+	}
+
+	entries, err := oplog.GetEntriesBatch(2, 3, 0)
+	if err != nil {
+		t.Fatalf("GetEntriesBatch failed: %v", err)
+	}
+	if len(entries) != 3 || entries[0].OpID != 3 || entries[2].OpID != 5 {
+		t.Errorf("Expected entries 3 to 5, got %d entries", len(entries))
+	}
+
+	// The byte limit cuts the batch, but never below one entry
+	data, _ := json.Marshal(entries[0])
+	if entries, _ := oplog.GetEntriesBatch(0, 0, 2*len(data)+1); len(entries) != 2 {
+		t.Errorf("Expected 2 entries within the byte limit, got %d", len(entries))
+	}
+	if entries, _ := oplog.GetEntriesBatch(0, 0, 1); len(entries) != 1 || entries[0].OpID != 1 {
+		t.Errorf("Expected the first entry despite the byte limit, got %d entries", len(entries))
+	}
+	if entries, _ := oplog.GetEntriesBatch(10, 5, 0); len(entries) != 0 {
+		t.Errorf("Expected no entries after the last one, got %d", len(entries))
+	}
+
+	// Entries no longer cached are read from disk
+	oplog.maxEntries = 4
+	oplog.Append(CreateInsertEntry("testdb", "users", map[string]interface{}{"index": int64(10)}))
+	entries, err = oplog.GetEntriesBatch(0, 5, 0)
+	if err != nil {
+		t.Fatalf("GetEntriesBatch from disk failed: %v", err)
+	}
+	if len(entries) != 5 || entries[0].OpID != 1 || entries[4].OpID != 5 {
+		t.Errorf("Expected entries 1 to 5 from disk, got %d entries", len(entries))
+	}
+}
+
+func TestOplogAppendTransaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oplog.bin")
+	oplog, err := NewOplog(path)
+	if err != nil {
+		t.Fatalf("Failed to create oplog: %v", err)
+	}
+
+	oplog.Append(CreateInsertEntry("testdb", "users", map[string]interface{}{"_id": "a"}))
+	err = oplog.AppendTransaction([]*OplogEntry{
+		CreateInsertEntry("testdb", "users", map[string]interface{}{"_id": "b"}),
+		CreateInsertEntry("testdb", "users", map[string]interface{}{"_id": "c"}),
+		CreateDeleteEntry("testdb", "users", map[string]interface{}{"_id": "a"}),
+	})
+	if err != nil {
+		t.Fatalf("AppendTransaction failed: %v", err)
+	}
+	oplog.AppendTransaction([]*OplogEntry{CreateInsertEntry("testdb", "users", map[string]interface{}{"_id": "d"})})
+
+	entries, _ := oplog.GetEntriesSince(0)
+	if len(entries) != 5 {
+		t.Fatalf("Expected 5 entries, got %d", len(entries))
+	}
+	for i, want := range []OpID{0, 2, 2, 2, 0} {
+		if entries[i].TxnID != want {
+			t.Errorf("Expected entry %d in transaction %d, got %d", entries[i].OpID, want, entries[i].TxnID)
+		}
+	}
+
+	// Batches are extended to the end of a transaction, never split it
+	if batch, _ := oplog.GetEntriesBatch(0, 2, 0); len(batch) != 4 || batch[3].OpID != 4 {
+		t.Errorf("Expected the batch extended to entry 4, got %d entries", len(batch))
+	}
+	if batch, _ := oplog.GetEntriesBatch(0, 0, 1); len(batch) != 1 {
+		t.Errorf("Expected a batch of the entry before the transaction, got %d entries", len(batch))
+	}
+	if batch, _ := oplog.GetEntriesBatch(1, 1, 0); len(batch) != 3 {
+		t.Errorf("Expected the whole transaction in one batch, got %d entries", len(batch))
+	}
+
+	// The transaction survives a reopen
+	oplog.Close()
+	oplog, err = NewOplog(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen oplog: %v", err)
+	}
+	defer oplog.Close()
+	if batch, _ := oplog.GetEntriesBatch(1, 1, 0); len(batch) != 3 || batch[2].TxnID != 2 {
+		t.Errorf("Expected the transaction after reopening, got %d entries", len(batch))
+	}
+}
+
+func TestOplogWaitForEntries(t *testing.T) {
+	oplog, err := NewOplog(filepath.Join(t.TempDir(), "oplog.bin"))
+	if err != nil {
+		t.Fatalf("Failed to create oplog: %v", err)
+	}
+
+	// An entry already there returns at once
+	oplog.Append(CreateInsertEntry("testdb", "users", map[string]interface{}{"index": int64(0)}))
+	if err := oplog.WaitForEntries(context.Background(), 0); err != nil {
+		t.Errorf("Expected no wait, got %v", err)
+	}
+
+	// An append wakes the waiter
+	done := make(chan error, 1)
+	go func() { done <- oplog.WaitForEntries(context.Background(), 1) }()
+	time.Sleep(20 * time.Millisecond)
+	oplog.Append(CreateInsertEntry("testdb", "users", map[string]interface{}{"index": int64(1)}))
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected the append to end the wait, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the append to wake the waiter")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := oplog.WaitForEntries(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait to time out, got %v", err)
+	}
+
+	// Closing the oplog ends the wait
+	go func() { done <- oplog.WaitForEntries(context.Background(), 2) }()
+	time.Sleep(20 * time.Millisecond)
+	oplog.Close()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Close to wake the waiter")
+	}
+}
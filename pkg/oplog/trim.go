@@ -0,0 +1,260 @@
+package oplog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultTrimInterval is how often the background trimmer checks the caps
+// of an oplog
+const DefaultTrimInterval = time.Minute
+
+// ErrTrimmed is returned to a consumer whose next entry was trimmed from
+// the oplog. It has to resync from a full copy of the data.
+var ErrTrimmed = errors.New("oplog entries were trimmed")
+
+// OplogConfig caps the size and age of an oplog. Once either cap is
+// exceeded, the oldest entries are trimmed, except those registered
+// consumers haven't read yet and the newest entry, which keeps OpIDs
+// increasing across restarts. Zero values don't cap.
+type OplogConfig struct {
+	// MaxSizeBytes is the size of the oplog file to trim down to
+	MaxSizeBytes int64
+
+	// MaxAge is the age of the oldest entry to keep
+	MaxAge time.Duration
+
+	// TrimInterval is how often the background trimmer checks the caps
+	// (0 uses DefaultTrimInterval)
+	TrimInterval time.Duration
+}
+
+// capped reports whether the config caps the oplog at all
+func (c OplogConfig) capped() bool {
+	return c.MaxSizeBytes > 0 || c.MaxAge > 0
+}
+
+// Retainer reports the last entry a consumer of the oplog has read. Trim
+// keeps the entries after it.
+type Retainer func() OpID
+
+// NewOplogWithConfig opens an operation log that a background trimmer keeps
+// within the caps of config
+func NewOplogWithConfig(path string, config OplogConfig) (*Oplog, error) {
+	o, err := NewOplog(path)
+	if err != nil {
+		return nil, err
+	}
+	o.config = config
+	if config.capped() {
+		interval := config.TrimInterval
+		if interval <= 0 {
+			interval = DefaultTrimInterval
+		}
+		o.stopTrim = make(chan struct{})
+		go o.trimLoop(interval, o.stopTrim)
+	}
+	return o, nil
+}
+
+// trimLoop trims the oplog every interval until stop is closed
+func (o *Oplog) trimLoop(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// A failed trim leaves the file as it was; the next one retries
+			o.Trim()
+		}
+	}
+}
+
+// Retain registers a consumer whose unread entries must not be trimmed,
+// replacing the one registered under the same name
+func (o *Oplog) Retain(name string, retainer Retainer) {
+	o.retainerMu.Lock()
+	defer o.retainerMu.Unlock()
+	o.retainers[name] = retainer
+}
+
+// Release unregisters a consumer registered with Retain
+func (o *Oplog) Release(name string) {
+	o.retainerMu.Lock()
+	defer o.retainerMu.Unlock()
+	delete(o.retainers, name)
+}
+
+// retainedAfter returns the last entry read by the consumer furthest
+// behind, or false if no consumer is registered. Retainers are called
+// without o.mu held, as they may take locks held while appending.
+func (o *Oplog) retainedAfter() (OpID, bool) {
+	o.retainerMu.Lock()
+	retainers := make([]Retainer, 0, len(o.retainers))
+	for _, retainer := range o.retainers {
+		retainers = append(retainers, retainer)
+	}
+	o.retainerMu.Unlock()
+
+	var oldest OpID
+	for i, retainer := range retainers {
+		if opID := retainer(); i == 0 || opID < oldest {
+			oldest = opID
+		}
+	}
+	return oldest, len(retainers) > 0
+}
+
+// OldestOpID returns the OpID of the oldest entry in the oplog, or the
+// OpID the next entry will get if it has none. A consumer that has read up
+// to an OpID before OldestOpID()-1 has missed trimmed entries.
+func (o *Oplog) OldestOpID() OpID {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if o.oldestID == 0 {
+		return o.currentID + 1
+	}
+	return o.oldestID
+}
+
+// CheckRetained returns an error wrapping ErrTrimmed if entries after
+// afterID were trimmed
+func (o *Oplog) CheckRetained(afterID OpID) error {
+	if oldest := o.OldestOpID(); afterID+1 < oldest {
+		return fmt.Errorf("entries after %d, before %d: %w", afterID, oldest, ErrTrimmed)
+	}
+	return nil
+}
+
+// Size returns the size of the oplog file in bytes
+func (o *Oplog) Size() int64 {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.size
+}
+
+// Trim removes the oldest entries while the oplog exceeds a cap of its
+// config, and returns how many it removed. It stops at the first entry a
+// registered consumer hasn't read and always keeps the newest entry. The
+// kept entries are written to a new file that replaces the old one.
+func (o *Oplog) Trim() (int, error) {
+	if !o.config.capped() {
+		return 0, nil
+	}
+	retained, hasRetainers := o.retainedAfter()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return 0, ErrClosed
+	}
+	if !o.exceedsCaps() {
+		return 0, nil
+	}
+
+	entries, err := o.readEntriesFromDisk(0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read oplog: %w", err)
+	}
+	data := make([][]byte, len(entries))
+	var size int64
+	for i, entry := range entries {
+		if data[i], err = o.serializeEntry(entry); err != nil {
+			return 0, fmt.Errorf("failed to serialize entry: %w", err)
+		}
+		size += int64(len(data[i]))
+	}
+
+	cutoff := time.Now().Add(-o.config.MaxAge)
+	cut := 0
+	for cut < len(entries)-1 {
+		entry := entries[cut]
+		if hasRetainers && entry.OpID > retained {
+			break
+		}
+		oversized := o.config.MaxSizeBytes > 0 && size > o.config.MaxSizeBytes
+		expired := o.config.MaxAge > 0 && entry.Timestamp.Before(cutoff)
+		if !oversized && !expired {
+			break
+		}
+		size -= int64(len(data[cut]))
+		cut++
+	}
+	if cut == 0 {
+		return 0, nil
+	}
+
+	if err := o.rewrite(data[cut:]); err != nil {
+		return 0, err
+	}
+
+	oldest := entries[cut]
+	o.size = size
+	o.oldestID, o.oldestTime = oldest.OpID, oldest.Timestamp
+	start := 0
+	for start < len(o.entries) && o.entries[start].OpID < oldest.OpID {
+		start++
+	}
+	o.entries = append([]*OplogEntry(nil), o.entries[start:]...)
+	return cut, nil
+}
+
+// exceedsCaps reports whether the oplog is larger or older than its config
+// allows. Must be called with o.mu held.
+func (o *Oplog) exceedsCaps() bool {
+	if o.oldestID == 0 {
+		return false
+	}
+	if o.config.MaxSizeBytes > 0 && o.size > o.config.MaxSizeBytes {
+		return true
+	}
+	return o.config.MaxAge > 0 && time.Since(o.oldestTime) > o.config.MaxAge
+}
+
+// rewrite replaces the oplog file with one holding the given serialized
+// entries. Must be called with o.mu held.
+func (o *Oplog) rewrite(data [][]byte) error {
+	tmpPath := o.path + ".trim"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create trimmed oplog: %w", err)
+	}
+	for _, buf := range data {
+		if _, err := tmp.Write(buf); err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to write trimmed oplog: %w", err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync trimmed oplog: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close trimmed oplog: %w", err)
+	}
+
+	// Open the new file before it replaces the old one, so that a failure
+	// leaves the oplog appending to the old file
+	file, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to open trimmed oplog: %w", err)
+	}
+	if err := os.Rename(tmpPath, o.path); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace oplog: %w", err)
+	}
+	o.file.Close()
+	o.file = file
+	return nil
+}
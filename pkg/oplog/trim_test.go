@@ -0,0 +1,149 @@
+package oplog
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func appendInserts(t *testing.T, o *Oplog, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := o.Append(CreateInsertEntry("testdb", "users", map[string]interface{}{"n": int64(i)})); err != nil {
+			t.Fatalf("Failed to append entry: %v", err)
+		}
+	}
+}
+
+func TestOplogTrimSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oplog.bin")
+	o, err := NewOplogWithConfig(path, OplogConfig{MaxSizeBytes: 1, TrimInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create oplog: %v", err)
+	}
+	defer o.Close()
+
+	appendInserts(t, o, 6)
+	trimmed := o.Size()
+	appendInserts(t, o, 4)
+	// Room for the last 4 entries
+	o.config.MaxSizeBytes = o.Size() - trimmed
+
+	removed, err := o.Trim()
+	if err != nil {
+		t.Fatalf("Failed to trim: %v", err)
+	}
+	if removed != 6 {
+		t.Errorf("Expected 6 entries trimmed, got %d", removed)
+	}
+	if o.Size() > o.config.MaxSizeBytes {
+		t.Errorf("Expected at most %d bytes, got %d", o.config.MaxSizeBytes, o.Size())
+	}
+	if oldest := o.OldestOpID(); oldest != 7 {
+		t.Errorf("Expected oldest OpID 7, got %d", oldest)
+	}
+
+	entries, err := o.GetEntriesSince(0)
+	if err != nil {
+		t.Fatalf("Failed to get entries: %v", err)
+	}
+	if len(entries) != 4 || entries[0].OpID != 7 {
+		t.Errorf("Expected entries 7-10, got %d entries", len(entries))
+	}
+	if err := o.CheckRetained(5); !errors.Is(err, ErrTrimmed) {
+		t.Errorf("Expected ErrTrimmed after 5, got %v", err)
+	}
+	if err := o.CheckRetained(6); err != nil {
+		t.Errorf("Expected entries after 6 to be retained, got %v", err)
+	}
+
+	// Appends go to the new file, and OpIDs carry on after a restart
+	appendInserts(t, o, 1)
+	if err := o.Close(); err != nil {
+		t.Fatalf("Failed to close oplog: %v", err)
+	}
+	reopened, err := NewOplog(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen oplog: %v", err)
+	}
+	defer reopened.Close()
+	if reopened.OldestOpID() != 7 || reopened.GetCurrentID() != 11 {
+		t.Errorf("Expected entries 7-11 after reopening, got %d-%d", reopened.OldestOpID(), reopened.GetCurrentID())
+	}
+}
+
+func TestOplogTrimAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oplog.bin")
+	o, err := NewOplogWithConfig(path, OplogConfig{MaxAge: 50 * time.Millisecond, TrimInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create oplog: %v", err)
+	}
+	defer o.Close()
+
+	appendInserts(t, o, 5)
+	time.Sleep(60 * time.Millisecond)
+	appendInserts(t, o, 2)
+
+	// The background trimmer removes the expired entries
+	deadline := time.Now().Add(2 * time.Second)
+	for o.OldestOpID() != 6 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if oldest := o.OldestOpID(); oldest != 6 {
+		t.Fatalf("Expected oldest OpID 6, got %d", oldest)
+	}
+
+	// Once everything has expired, the newest entry is still kept
+	time.Sleep(60 * time.Millisecond)
+	if _, err := o.Trim(); err != nil {
+		t.Fatalf("Failed to trim: %v", err)
+	}
+	entries, err := o.GetEntriesSince(0)
+	if err != nil {
+		t.Fatalf("Failed to get entries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].OpID != 7 {
+		t.Errorf("Expected only entry 7, got %d entries", len(entries))
+	}
+}
+
+func TestOplogTrimKeepsUnreadEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oplog.bin")
+	o, err := NewOplogWithConfig(path, OplogConfig{MaxSizeBytes: 1, TrimInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create oplog: %v", err)
+	}
+	defer o.Close()
+
+	appendInserts(t, o, 10)
+
+	lagging, current := OpID(3), OpID(9)
+	o.Retain("lagging", func() OpID { return lagging })
+	o.Retain("current", func() OpID { return current })
+
+	removed, err := o.Trim()
+	if err != nil {
+		t.Fatalf("Failed to trim: %v", err)
+	}
+	if removed != 3 || o.OldestOpID() != 4 {
+		t.Errorf("Expected entries up to 3 trimmed, got %d trimmed, oldest %d", removed, o.OldestOpID())
+	}
+
+	// Nothing more is trimmed until the lagging consumer reads on
+	if removed, _ := o.Trim(); removed != 0 {
+		t.Errorf("Expected nothing trimmed, got %d", removed)
+	}
+	lagging = 6
+	if removed, _ := o.Trim(); removed != 3 {
+		t.Errorf("Expected 3 entries trimmed, got %d", removed)
+	}
+
+	o.Release("lagging")
+	if _, err := o.Trim(); err != nil {
+		t.Fatalf("Failed to trim: %v", err)
+	}
+	if oldest := o.OldestOpID(); oldest != 10 {
+		t.Errorf("Expected oldest OpID 10, got %d", oldest)
+	}
+}
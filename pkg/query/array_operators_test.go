@@ -276,3 +276,96 @@ func TestSizeWithFloat(t *testing.T) {
 		t.Error("Document should match: items array has 5 elements")
 	}
 }
+
+func TestElemMatchSameElement(t *testing.T) {
+	// 75 satisfies $lt 90 and 95 satisfies $gte 80, but no single score does both
+	doc := document.NewDocument()
+	doc.Set("scores", []interface{}{int64(75), int64(95)})
+
+	elemMatch := NewQuery(map[string]interface{}{
+		"scores": map[string]interface{}{
+			"$elemMatch": map[string]interface{}{"$gte": 80, "$lt": 90},
+		},
+	})
+	matches, err := elemMatch.Matches(doc)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if matches {
+		t.Error("Document should not match: no single score is in [80, 90)")
+	}
+
+	doc.Set("scores", []interface{}{int64(75), int64(85), int64(95)})
+	if matches, _ := elemMatch.Matches(doc); !matches {
+		t.Error("Document should match: 85 is in [80, 90)")
+	}
+}
+
+func TestElemMatchSubdocuments(t *testing.T) {
+	order := document.NewDocument()
+	order.Set("items", []interface{}{
+		map[string]interface{}{"sku": "X", "qty": int64(0)},
+		map[string]interface{}{"sku": "Y", "qty": int64(5)},
+	})
+
+	// sku X and a positive qty both occur, but in different items
+	inStock := map[string]interface{}{
+		"items": map[string]interface{}{
+			"$elemMatch": map[string]interface{}{
+				"sku": "X",
+				"qty": map[string]interface{}{"$gt": 0},
+			},
+		},
+	}
+	matches, err := NewQuery(inStock).Matches(order)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if matches {
+		t.Error("Document should not match: item X has no stock")
+	}
+
+	order.Set("items", []interface{}{
+		map[string]interface{}{"sku": "Y", "qty": int64(5)},
+		document.NewDocumentFromMap(map[string]interface{}{"sku": "X", "qty": int64(2)}),
+		"loose",
+	})
+	if matches, err := NewQuery(inStock).Matches(order); err != nil || !matches {
+		t.Errorf("Document should match: item X has stock (err %v)", err)
+	}
+
+	// Conditions on embedded documents can use logical operators and paths
+	nested := NewQuery(map[string]interface{}{
+		"items": map[string]interface{}{
+			"$elemMatch": map[string]interface{}{
+				"$or": []interface{}{
+					map[string]interface{}{"sku": "Z"},
+					map[string]interface{}{"qty": map[string]interface{}{"$gte": 5}},
+				},
+			},
+		},
+	})
+	if matches, err := nested.Matches(order); err != nil || !matches {
+		t.Errorf("Document should match: item Y has qty 5 (err %v)", err)
+	}
+}
+
+func TestElemMatchOperatorsOnDocuments(t *testing.T) {
+	// Operator conditions apply to the element itself, so documents never
+	// compare as numbers
+	doc := document.NewDocument()
+	doc.Set("items", []interface{}{map[string]interface{}{"qty": int64(5)}})
+
+	q := NewQuery(map[string]interface{}{
+		"items": map[string]interface{}{
+			"$elemMatch": map[string]interface{}{"$gte": 1},
+		},
+	})
+	matches, err := q.Matches(doc)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if matches {
+		t.Error("Document should not match: elements are documents, not numbers")
+	}
+}
@@ -1,6 +1,7 @@
 package query
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/mnohosten/laura-db/pkg/index"
@@ -299,3 +300,97 @@ func TestCompoundIndexExplain(t *testing.T) {
 		t.Errorf("Expected INDEX_EXACT, got %v", explanation["scanType"])
 	}
 }
+
+func TestCompoundIndexPrefixRange(t *testing.T) {
+	idx := index.NewIndex(&index.IndexConfig{
+		Name:       "country_age_1",
+		FieldPaths: []string{"country", "age"},
+		Type:       index.IndexTypeBTree,
+		Order:      4,
+	})
+	for i, country := range []string{"CA", "US", "DE"} {
+		for age := int64(10); age <= 40; age += 10 {
+			idx.Insert(index.NewCompositeKey(country, age), fmt.Sprintf("%d-%d", i, age))
+		}
+	}
+	idx.Analyze()
+
+	planner := NewQueryPlanner(map[string]*index.Index{"country_age_1": idx})
+
+	t.Run("Range on next field", func(t *testing.T) {
+		query := NewQuery(map[string]interface{}{
+			"country": "US",
+			"age":     map[string]interface{}{"$gt": int64(20)},
+		})
+		plan := planner.Plan(query)
+
+		if !plan.UseIndex || plan.IndexName != "country_age_1" {
+			t.Fatalf("Expected to use country_age_1, got %+v", plan.Explain())
+		}
+		if plan.ScanType != ScanTypeIndexRange {
+			t.Fatalf("Expected range scan, got %v", plan.ScanType)
+		}
+		if plan.PrefixKey == nil || len(plan.PrefixKey.Values) != 1 || plan.PrefixKey.Values[0] != "US" {
+			t.Fatalf("Expected prefix [US], got %v", plan.PrefixKey)
+		}
+		if plan.ScanStart != int64(20) || plan.ScanEnd != nil {
+			t.Errorf("Expected scan from 20, got [%v, %v]", plan.ScanStart, plan.ScanEnd)
+		}
+		if len(plan.FilterSteps) != 0 {
+			t.Errorf("Expected no additional filters, got %v", plan.FilterSteps)
+		}
+
+		ids, ok := indexScanIDs(plan)
+		if !ok {
+			t.Fatal("Expected an index scan")
+		}
+		// The bound is inclusive; the executor re-checks $gt on each document
+		if len(ids) != 3 || ids[0] != "1-20" || ids[2] != "1-40" {
+			t.Errorf("Expected candidates [1-20 1-30 1-40], got %v", ids)
+		}
+	})
+
+	t.Run("Prefix only", func(t *testing.T) {
+		plan := planner.Plan(NewQuery(map[string]interface{}{"country": "DE"}))
+		if plan.PrefixKey == nil || plan.ScanStart != nil || plan.ScanEnd != nil {
+			t.Fatalf("Expected an unbounded prefix scan, got %+v", plan.Explain())
+		}
+		ids, _ := indexScanIDs(plan)
+		if len(ids) != 4 {
+			t.Errorf("Expected the 4 DE entries, got %v", ids)
+		}
+	})
+
+	t.Run("Range on first field", func(t *testing.T) {
+		plan := planner.Plan(NewQuery(map[string]interface{}{
+			"country": map[string]interface{}{"$gte": "D", "$lt": "E"},
+		}))
+		if !plan.UseIndex || plan.PrefixKey == nil || len(plan.PrefixKey.Values) != 0 {
+			t.Fatalf("Expected a range scan with an empty prefix, got %+v", plan.Explain())
+		}
+		ids, _ := indexScanIDs(plan)
+		if len(ids) != 4 {
+			t.Errorf("Expected the 4 DE entries, got %v", ids)
+		}
+	})
+
+	t.Run("Later fields after a range are filtered", func(t *testing.T) {
+		plan := planner.Plan(NewQuery(map[string]interface{}{
+			"country": "US",
+			"age":     map[string]interface{}{"$in": []interface{}{int64(10), int64(20)}},
+		}))
+		if plan.PrefixKey == nil || len(plan.PrefixKey.Values) != 1 {
+			t.Fatalf("Expected a prefix scan on country, got %+v", plan.Explain())
+		}
+		if len(plan.FilterSteps) != 1 || plan.FilterSteps[0] != "age" {
+			t.Errorf("Expected age to remain a filter, got %v", plan.FilterSteps)
+		}
+	})
+
+	t.Run("Second field alone can't use the index", func(t *testing.T) {
+		plan := planner.Plan(NewQuery(map[string]interface{}{"age": int64(20)}))
+		if plan.UseIndex {
+			t.Errorf("Expected a collection scan, got %+v", plan.Explain())
+		}
+	})
+}
@@ -339,3 +339,108 @@ func createTestDoc(id, name string, age int) *document.Document {
 	doc.Set("age", age)
 	return doc
 }
+
+func TestCoveredQueryDetectionQueryFields(t *testing.T) {
+	idx := index.NewIndex(&index.IndexConfig{
+		Name:       "city_age_idx",
+		FieldPaths: []string{"city", "age"},
+		Type:       index.IndexTypeBTree,
+		Unique:     true,
+		Order:      32,
+	})
+	planner := NewQueryPlanner(map[string]*index.Index{"city_age_idx": idx})
+
+	tests := []struct {
+		name          string
+		filter        map[string]interface{}
+		projection    map[string]bool
+		sort          []SortField
+		expectCovered bool
+	}{
+		{
+			name:          "Covered - second key field projected, _id excluded",
+			filter:        map[string]interface{}{"city": "Prague"},
+			projection:    map[string]bool{"age": true, "_id": false},
+			expectCovered: true,
+		},
+		{
+			name:          "Covered - sort on key field",
+			filter:        map[string]interface{}{"city": "Prague"},
+			projection:    map[string]bool{"age": true},
+			sort:          []SortField{{Field: "age", Ascending: true}},
+			expectCovered: true,
+		},
+		{
+			name:          "Not covered - $or on a field outside the index",
+			filter:        map[string]interface{}{"city": "Prague", "$or": []interface{}{map[string]interface{}{"name": "Alice"}}},
+			projection:    map[string]bool{"age": true},
+			expectCovered: false,
+		},
+		{
+			name:          "Not covered - sort on a field outside the index",
+			filter:        map[string]interface{}{"city": "Prague"},
+			projection:    map[string]bool{"age": true},
+			sort:          []SortField{{Field: "name", Ascending: true}},
+			expectCovered: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := NewQuery(tt.filter).WithProjection(tt.projection)
+			if tt.sort != nil {
+				query.WithSort(tt.sort)
+			}
+
+			plan := planner.Plan(query)
+			planner.DetectCoveredQuery(plan, query.GetProjection())
+
+			if plan.IsCovered != tt.expectCovered {
+				t.Errorf("Expected IsCovered=%v, got %v", tt.expectCovered, plan.IsCovered)
+			}
+		})
+	}
+}
+
+func TestCoveredQueryExecutionCompoundIndex(t *testing.T) {
+	idx := index.NewIndex(&index.IndexConfig{
+		Name:       "city_age_idx",
+		FieldPaths: []string{"city", "age"},
+		Type:       index.IndexTypeBTree,
+		Unique:     true,
+		Order:      32,
+	})
+	idx.Insert(index.NewCompositeKey("Prague", int64(25)), "1")
+	idx.Insert(index.NewCompositeKey("Prague", int64(30)), "2")
+	idx.Insert(index.NewCompositeKey("Brno", int64(28)), "3")
+
+	planner := NewQueryPlanner(map[string]*index.Index{"city_age_idx": idx})
+	query := NewQuery(map[string]interface{}{"city": "Prague"}).
+		WithProjection(map[string]bool{"age": true, "_id": true})
+
+	plan := planner.Plan(query)
+	planner.DetectCoveredQuery(plan, query.GetProjection())
+	if !plan.IsCovered {
+		t.Fatal("Expected a covered query")
+	}
+
+	// No documents: results come from the index alone
+	results, err := NewExecutor(nil).ExecuteWithPlan(query, plan)
+	if err != nil {
+		t.Fatalf("Query execution failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	for _, doc := range results {
+		if _, exists := doc.Get("city"); exists {
+			t.Error("Expected city not to be projected")
+		}
+		if _, exists := doc.Get("age"); !exists {
+			t.Error("Expected age in the result")
+		}
+		if _, exists := doc.Get("_id"); !exists {
+			t.Error("Expected _id in the result")
+		}
+	}
+}
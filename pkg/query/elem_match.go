@@ -0,0 +1,79 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+// matchElement evaluates {$elemMatch: conditions} against the value of a
+// field: it matches if a single element of the array satisfies every
+// condition. Conditions made only of operators, like {$gte: 80, $lt: 90},
+// apply to the element itself; otherwise they are a filter on the fields of
+// an embedded document, like {sku: "X", qty: {$gt: 0}}, and elements that
+// aren't documents never match.
+func (q *Query) matchElement(key string, value interface{}, conditions interface{}) (bool, error) {
+	condMap, ok := conditions.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("$elemMatch requires an object with conditions")
+	}
+
+	arrVal := reflect.ValueOf(value)
+	if arrVal.Kind() != reflect.Slice && arrVal.Kind() != reflect.Array {
+		return false, nil
+	}
+	if _, binary := value.([]byte); binary {
+		return false, nil
+	}
+
+	operators := isOperatorExpression(condMap)
+	for i := 0; i < arrVal.Len(); i++ {
+		element := arrVal.Index(i).Interface()
+		if v, ok := element.(*document.Value); ok {
+			element = v.Data
+		}
+
+		var matched bool
+		var err error
+		if operators {
+			matched, err = q.matchOperators(key, element, true, condMap)
+		} else if elemDoc := elementDocument(element); elemDoc != nil {
+			matched, err = q.evaluateFilter(elemDoc, condMap)
+		}
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isOperatorExpression reports whether conditions hold only field operators
+// (and no field names or logical operators)
+func isOperatorExpression(conditions map[string]interface{}) bool {
+	if len(conditions) == 0 {
+		return false
+	}
+	for key := range conditions {
+		if !strings.HasPrefix(key, "$") || key == string(OpAnd) || key == string(OpOr) {
+			return false
+		}
+	}
+	return true
+}
+
+// elementDocument returns an array element as a document, or nil if it isn't
+// one
+func elementDocument(element interface{}) *document.Document {
+	switch e := element.(type) {
+	case *document.Document:
+		return e
+	case map[string]interface{}:
+		return document.NewDocumentFromMap(e)
+	}
+	return nil
+}
@@ -62,9 +62,13 @@ func (e *Executor) Execute(query *Query) ([]*document.Document, error) {
 		}
 	}
 
-	// Sort results
+	// Sort results ($near queries default to nearest first, $text to most relevant)
 	if len(query.GetSort()) > 0 {
 		e.sortDocuments(results, query.GetSort())
+	} else if near := query.NearCondition(); near != nil {
+		sortByDistance(results, near)
+	} else if query.HasTextSearch() {
+		e.sortDocuments(results, []SortField{{Field: TextScoreField, Ascending: false}})
 	}
 
 	// Apply skip
@@ -131,9 +135,13 @@ func (e *Executor) ExecuteWithPlan(query *Query, plan *QueryPlan) ([]*document.D
 		}
 	}
 
-	// Sort results
+	// Sort results ($near queries default to nearest first, $text to most relevant)
 	if len(query.GetSort()) > 0 {
 		e.sortDocuments(results, query.GetSort())
+	} else if near := query.NearCondition(); near != nil {
+		sortByDistance(results, near)
+	} else if query.HasTextSearch() {
+		e.sortDocuments(results, []SortField{{Field: TextScoreField, Ascending: false}})
 	}
 
 	// Apply skip
@@ -172,13 +180,20 @@ func (e *Executor) executeCoveredQuery(query *Query, plan *QueryPlan) ([]*docume
 			searchKey = int64(v)
 		}
 
-		value, exists := plan.Index.Search(searchKey)
-		if exists {
-			keys = []interface{}{searchKey}
-			values = []interface{}{value}
+		values = plan.Index.SearchAll(searchKey)
+		keys = make([]interface{}, len(values))
+		for i := range values {
+			keys[i] = searchKey
 		}
 
 	case ScanTypeIndexRange:
+		// A prefix match on a compound index scans only the keys starting
+		// with the prefix, bounded on the next field
+		if plan.PrefixKey != nil {
+			keys, values = plan.Index.PrefixRangeScan(plan.PrefixKey.Values, plan.ScanStart, plan.ScanEnd)
+			break
+		}
+
 		// Range scan - gets both keys and values
 		// Handle nil start/end (for unbounded ranges)
 		start := plan.ScanStart
@@ -224,50 +239,42 @@ func (e *Executor) executeCoveredQuery(query *Query, plan *QueryPlan) ([]*docume
 			}
 		}
 
-		allKeys, allValues := plan.Index.RangeScan(start, end)
-
-		// If this is a prefix match on compound index, filter by prefix
-		if plan.PrefixKey != nil {
-			keys = make([]interface{}, 0)
-			values = make([]interface{}, 0)
-			for i, key := range allKeys {
-				if compositeKey, ok := key.(*index.CompositeKey); ok {
-					if compositeKey.MatchesPrefix(plan.PrefixKey) {
-						keys = append(keys, key)
-						values = append(values, allValues[i])
-					}
-				}
-			}
-		} else {
-			keys = allKeys
-			values = allValues
-		}
+		keys, values = plan.Index.RangeScan(start, end)
 
 	default:
 		// Should not happen for covered queries
 		return nil, fmt.Errorf("invalid scan type for covered query")
 	}
 
-	// Build documents from index data
+	// Build documents from index data: the key fields and the _id stored
+	// as the entry value
+	fieldPaths := plan.Index.FieldPaths()
 	results := make([]*document.Document, 0, len(keys))
-	projection := query.GetProjection()
 
 	for i := 0; i < len(keys) && i < len(values); i++ {
 		doc := document.NewDocument()
-
-		// Add _id if requested in projection (or if no specific projection given)
-		if projection == nil || projection["_id"] {
-			if idStr, ok := values[i].(string); ok {
-				doc.Set("_id", idStr)
-			}
+		if idStr, ok := values[i].(string); ok {
+			doc.Set("_id", idStr)
 		}
-
-		// Add indexed field if requested in projection (or if no specific projection given)
-		if projection == nil || projection[plan.IndexedField] {
+		if compositeKey, ok := keys[i].(*index.CompositeKey); ok {
+			for j, field := range fieldPaths {
+				if j < len(compositeKey.Values) {
+					doc.Set(field, compositeKey.Values[j])
+				}
+			}
+		} else {
 			doc.Set(plan.IndexedField, keys[i])
 		}
 
-		results = append(results, doc)
+		// The scan bounds are inclusive and other conditions may remain;
+		// all of them can be checked on the index fields
+		matches, err := query.Matches(doc)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			results = append(results, doc)
+		}
 	}
 
 	// Sort results (using the same sorting logic)
@@ -289,69 +296,98 @@ func (e *Executor) executeCoveredQuery(query *Query, plan *QueryPlan) ([]*docume
 		results = results[:query.GetLimit()]
 	}
 
-	// Note: No need to apply projection since we only built the requested fields
+	// Apply projection
+	for i, doc := range results {
+		results[i] = query.ApplyProjection(doc)
+	}
 
 	return results, nil
 }
 
 // executeIndexScan retrieves documents using the index
 func (e *Executor) executeIndexScan(plan *QueryPlan) ([]*document.Document, error) {
+	docIDs, ok := indexScanIDs(plan)
+	if !ok {
+		// Should not happen, but fall back to all documents
+		return e.documents, nil
+	}
+
+	// Convert document IDs to documents
+	docs := make([]*document.Document, 0, len(docIDs))
+	for _, id := range docIDs {
+		if doc, exists := e.documentsMap[id]; exists {
+			docs = append(docs, doc)
+		}
+	}
+
+	return docs, nil
+}
+
+// DocumentIDs returns the IDs of the documents an index-backed plan reads:
+// in index order for a single index scan, in no particular order for an
+// index intersection. It returns false for a collection scan.
+func (plan *QueryPlan) DocumentIDs() ([]string, bool) {
+	if plan.UseIntersection {
+		idSet, err := (&Executor{}).intersectionIDs(plan)
+		if err != nil {
+			return nil, false
+		}
+		ids := make([]string, 0, len(idSet))
+		for id := range idSet {
+			ids = append(ids, id)
+		}
+		return ids, true
+	}
+	if !plan.UseIndex || plan.Index == nil {
+		return nil, false
+	}
+	return indexScanIDs(plan)
+}
+
+// indexScanIDs returns the document IDs a single index scan reads, in index
+// order, or false if the plan's scan type doesn't read an index
+func indexScanIDs(plan *QueryPlan) ([]string, bool) {
 	var docIDs []string
 
 	switch plan.ScanType {
 	case ScanTypeIndexExact:
 		// Exact match scan
-		value, exists := plan.Index.Search(plan.ScanKey)
-		if exists {
+		for _, value := range plan.Index.SearchAll(plan.ScanKey) {
 			if idStr, ok := value.(string); ok {
-				docIDs = []string{idStr}
+				docIDs = append(docIDs, idStr)
 			}
 		}
 
 	case ScanTypeIndexRange:
-		// Range scan
-		keys, values := plan.Index.RangeScan(plan.ScanStart, plan.ScanEnd)
-		docIDs = make([]string, 0, len(values))
-
-		// If this is a prefix match on compound index, filter by prefix
+		// Range scan; a prefix match on a compound index scans only the
+		// keys starting with the prefix, bounded on the next field
+		var values []interface{}
 		if plan.PrefixKey != nil {
-			for i, key := range keys {
-				if compositeKey, ok := key.(*index.CompositeKey); ok {
-					if compositeKey.MatchesPrefix(plan.PrefixKey) {
-						if idStr, ok := values[i].(string); ok {
-							docIDs = append(docIDs, idStr)
-						}
-					}
-				}
-			}
+			_, values = plan.Index.PrefixRangeScan(plan.PrefixKey.Values, plan.ScanStart, plan.ScanEnd)
 		} else {
-			// Normal range scan without prefix filtering
-			for _, v := range values {
-				if idStr, ok := v.(string); ok {
-					docIDs = append(docIDs, idStr)
-				}
+			_, values = plan.Index.RangeScan(plan.ScanStart, plan.ScanEnd)
+		}
+		docIDs = make([]string, 0, len(values))
+		for _, v := range values {
+			if idStr, ok := v.(string); ok {
+				docIDs = append(docIDs, idStr)
 			}
 		}
 
 	default:
-		// Should not happen, but fall back to all documents
-		return e.documents, nil
+		return nil, false
 	}
 
-	// Convert document IDs to documents
-	docs := make([]*document.Document, 0, len(docIDs))
-	for _, id := range docIDs {
-		if doc, exists := e.documentsMap[id]; exists {
-			docs = append(docs, doc)
-		}
-	}
-
-	return docs, nil
+	return docIDs, true
 }
 
-// sortDocuments sorts documents based on sort fields
+// sortDocuments sorts documents based on sort fields. Values of different
+// types compare in the canonical type order of document.CompareValues, and
+// documents missing a field sort after those having it in ascending order.
+// Documents tying on every sort field are ordered by _id, so skip and limit
+// page through the same order on every call.
 func (e *Executor) sortDocuments(docs []*document.Document, sortFields []SortField) {
-	sort.Slice(docs, func(i, j int) bool {
+	sort.SliceStable(docs, func(i, j int) bool {
 		for _, field := range sortFields {
 			vi, existsI := docs[i].Get(field.Field)
 			vj, existsJ := docs[j].Get(field.Field)
@@ -376,39 +412,17 @@ func (e *Executor) sortDocuments(docs []*document.Document, sortFields []SortFie
 				return cmp > 0
 			}
 		}
-		return false
+
+		idI, existsI := docs[i].Get("_id")
+		idJ, existsJ := docs[j].Get("_id")
+		return existsI && existsJ && compareValues(idI, idJ) < 0
 	})
 }
 
-// compareValues compares two values
+// compareValues compares two values using the canonical type ordering
 // Returns: -1 if a < b, 0 if a == b, 1 if a > b
 func compareValues(a, b interface{}) int {
-	// Try numeric comparison
-	aVal, aOk := toFloat64(a)
-	bVal, bOk := toFloat64(b)
-	if aOk && bOk {
-		if aVal < bVal {
-			return -1
-		} else if aVal > bVal {
-			return 1
-		}
-		return 0
-	}
-
-	// String comparison
-	aStr, aOk := a.(string)
-	bStr, bOk := b.(string)
-	if aOk && bOk {
-		if aStr < bStr {
-			return -1
-		} else if aStr > bStr {
-			return 1
-		}
-		return 0
-	}
-
-	// Default: equal
-	return 0
+	return document.CompareValues(a, b)
 }
 
 // Count returns the number of documents matching the query
@@ -466,6 +480,29 @@ func (e *Executor) executeIndexIntersection(plan *QueryPlan) ([]*document.Docume
 		return e.documents, fmt.Errorf("no intersection plans provided")
 	}
 
+	resultIDs, err := e.intersectionIDs(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert document IDs to documents
+	docs := make([]*document.Document, 0, len(resultIDs))
+	for id := range resultIDs {
+		if doc, exists := e.documentsMap[id]; exists {
+			docs = append(docs, doc)
+		}
+	}
+
+	return docs, nil
+}
+
+// intersectionIDs returns the IDs of the documents every index of an
+// intersection plan matches
+func (e *Executor) intersectionIDs(plan *QueryPlan) (map[string]bool, error) {
+	if len(plan.IntersectPlans) == 0 {
+		return nil, fmt.Errorf("no intersection plans provided")
+	}
+
 	// Execute each index scan and collect document IDs
 	idSets := make([]map[string]bool, len(plan.IntersectPlans))
 
@@ -487,17 +524,7 @@ func (e *Executor) executeIndexIntersection(plan *QueryPlan) ([]*document.Docume
 	}
 
 	// Intersect all sets (find common document IDs)
-	resultIDs := e.intersectSets(idSets)
-
-	// Convert document IDs to documents
-	docs := make([]*document.Document, 0, len(resultIDs))
-	for id := range resultIDs {
-		if doc, exists := e.documentsMap[id]; exists {
-			docs = append(docs, doc)
-		}
-	}
-
-	return docs, nil
+	return e.intersectSets(idSets), nil
 }
 
 // executeIntersectIndexScan executes a single index scan for intersection
@@ -507,10 +534,9 @@ func (e *Executor) executeIntersectIndexScan(plan *IndexIntersectPlan) ([]string
 	switch plan.ScanType {
 	case ScanTypeIndexExact:
 		// Exact match scan
-		value, exists := plan.Index.Search(plan.ScanKey)
-		if exists {
+		for _, value := range plan.Index.SearchAll(plan.ScanKey) {
 			if idStr, ok := value.(string); ok {
-				docIDs = []string{idStr}
+				docIDs = append(docIDs, idStr)
 			}
 		}
 
@@ -0,0 +1,237 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/geo"
+)
+
+// earthRadiusMeters converts $centerSphere radii (in radians) to meters
+const earthRadiusMeters = 6371000.0
+
+// NearCondition is a parsed $near expression. Legacy [lng, lat] centers use
+// planar distances in coordinate units; GeoJSON ($geometry) centers use
+// spherical distances in meters.
+type NearCondition struct {
+	Field       string
+	Center      *geo.Point
+	MaxDistance float64 // 0 means unbounded
+	MinDistance float64
+	Spherical   bool
+}
+
+// Distance returns the distance from the condition's center to a point
+func (nc *NearCondition) Distance(point *geo.Point) float64 {
+	if nc.Spherical {
+		return geo.HaversineDistance(nc.Center, point)
+	}
+	return geo.Distance2D(nc.Center, point)
+}
+
+// Matches reports whether a field value is a point within range
+func (nc *NearCondition) Matches(fieldValue interface{}) bool {
+	point, err := geo.ParsePoint(fieldValue)
+	if err != nil {
+		return false
+	}
+	distance := nc.Distance(point)
+	if nc.MaxDistance > 0 && distance > nc.MaxDistance {
+		return false
+	}
+	return distance >= nc.MinDistance
+}
+
+// ParseNear parses the operator map of a field holding $near, e.g.
+// {"$near": [lng, lat], "$maxDistance": 2} or
+// {"$near": {"$geometry": {"type": "Point", "coordinates": [lng, lat]}, "$maxDistance": 500}}
+func ParseNear(field string, operatorMap map[string]interface{}) (*NearCondition, error) {
+	nc := &NearCondition{Field: field}
+
+	nearValue := operatorMap[string(OpNear)]
+	if nearMap, ok := nearValue.(map[string]interface{}); ok {
+		geometry, ok := nearMap["$geometry"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("$near requires a [lng, lat] point or $geometry")
+		}
+		center, err := geo.ParseGeoJSONPoint(geometry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid $near point: %w", err)
+		}
+		nc.Center = center
+		nc.Spherical = true
+
+		// Distance bounds may be given inside the $near document
+		for key, value := range nearMap {
+			if key != "$geometry" {
+				operatorMap = mergeOperator(operatorMap, key, value)
+			}
+		}
+	} else {
+		center, err := geo.ParsePoint(nearValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid $near point: %w", err)
+		}
+		nc.Center = center
+	}
+
+	if value, ok := operatorMap[string(OpMaxDistance)]; ok {
+		maxDistance, ok := toFloat64(value)
+		if !ok || maxDistance < 0 {
+			return nil, fmt.Errorf("$maxDistance must be a non-negative number")
+		}
+		nc.MaxDistance = maxDistance
+	}
+	if value, ok := operatorMap[string(OpMinDistance)]; ok {
+		minDistance, ok := toFloat64(value)
+		if !ok || minDistance < 0 {
+			return nil, fmt.Errorf("$minDistance must be a non-negative number")
+		}
+		nc.MinDistance = minDistance
+	}
+
+	return nc, nil
+}
+
+// mergeOperator returns a copy of operatorMap with key set to value
+func mergeOperator(operatorMap map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(operatorMap)+1)
+	for k, v := range operatorMap {
+		merged[k] = v
+	}
+	merged[key] = value
+	return merged
+}
+
+// NearCondition returns the top-level $near condition of the query, if any.
+// Results of a $near query are ordered by distance unless a sort is given.
+func (q *Query) NearCondition() *NearCondition {
+	for field, value := range q.filter {
+		operatorMap, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, hasNear := operatorMap[string(OpNear)]; !hasNear {
+			continue
+		}
+		if nc, err := ParseNear(field, operatorMap); err == nil {
+			return nc
+		}
+	}
+	return nil
+}
+
+// sortByDistance orders documents by distance from a $near center
+func sortByDistance(docs []*document.Document, nc *NearCondition) {
+	distances := make(map[*document.Document]float64, len(docs))
+	for _, doc := range docs {
+		distance := math.Inf(1)
+		if value, exists := doc.Get(nc.Field); exists {
+			if point, err := geo.ParsePoint(value); err == nil {
+				distance = nc.Distance(point)
+			}
+		}
+		distances[doc] = distance
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		return distances[docs[i]] < distances[docs[j]]
+	})
+}
+
+// evaluateGeoWithin checks whether a point lies within a shape:
+// {"$box": [[minLng, minLat], [maxLng, maxLat]]}, {"$polygon": [[lng, lat], ...]},
+// {"$center": [[lng, lat], radius]}, {"$centerSphere": [[lng, lat], radians]}
+// or {"$geometry": <GeoJSON polygon>}
+func evaluateGeoWithin(fieldValue interface{}, shape interface{}) (bool, error) {
+	shapeMap, ok := shape.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("$geoWithin requires a shape document")
+	}
+
+	point, err := geo.ParsePoint(fieldValue)
+	if err != nil {
+		return false, nil
+	}
+
+	for shapeType, spec := range shapeMap {
+		switch shapeType {
+		case "$box":
+			corners, err := parsePoints(spec)
+			if err != nil || len(corners) != 2 {
+				return false, fmt.Errorf("$box requires two [lng, lat] corners")
+			}
+			box := &geo.BoundingBox{
+				MinLon: math.Min(corners[0].Lon, corners[1].Lon),
+				MinLat: math.Min(corners[0].Lat, corners[1].Lat),
+				MaxLon: math.Max(corners[0].Lon, corners[1].Lon),
+				MaxLat: math.Max(corners[0].Lat, corners[1].Lat),
+			}
+			return box.Contains(point), nil
+
+		case "$polygon":
+			vertices, err := parsePoints(spec)
+			if err != nil || len(vertices) < 3 {
+				return false, fmt.Errorf("$polygon requires at least three [lng, lat] points")
+			}
+			ring := make([]geo.Point, len(vertices))
+			for i, v := range vertices {
+				ring[i] = *v
+			}
+			return geo.PointInPolygon(point, geo.NewPolygon([][]geo.Point{ring})), nil
+
+		case "$center", "$centerSphere":
+			args, ok := spec.([]interface{})
+			if !ok || len(args) != 2 {
+				return false, fmt.Errorf("%s requires [[lng, lat], radius]", shapeType)
+			}
+			center, err := geo.ParsePoint(args[0])
+			if err != nil {
+				return false, fmt.Errorf("invalid %s center: %w", shapeType, err)
+			}
+			radius, ok := toFloat64(args[1])
+			if !ok {
+				return false, fmt.Errorf("%s radius must be a number", shapeType)
+			}
+			if shapeType == "$centerSphere" {
+				return geo.HaversineDistance(center, point) <= radius*earthRadiusMeters, nil
+			}
+			return geo.Distance2D(center, point) <= radius, nil
+
+		case "$geometry":
+			geometry, ok := spec.(map[string]interface{})
+			if !ok {
+				return false, fmt.Errorf("$geometry must be a GeoJSON polygon")
+			}
+			polygon, err := geo.ParseGeoJSONPolygon(geometry)
+			if err != nil {
+				return false, fmt.Errorf("invalid $geometry: %w", err)
+			}
+			return geo.PointInPolygon(point, polygon), nil
+
+		default:
+			return false, fmt.Errorf("unsupported $geoWithin shape: %s", shapeType)
+		}
+	}
+
+	return false, fmt.Errorf("$geoWithin requires a shape")
+}
+
+// parsePoints parses an array of [lng, lat] pairs
+func parsePoints(value interface{}) ([]*geo.Point, error) {
+	arr, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an array of points")
+	}
+	points := make([]*geo.Point, len(arr))
+	for i, v := range arr {
+		point, err := geo.ParsePoint(v)
+		if err != nil {
+			return nil, err
+		}
+		points[i] = point
+	}
+	return points, nil
+}
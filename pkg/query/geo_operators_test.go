@@ -0,0 +1,164 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+func geoTestDocs() []*document.Document {
+	return []*document.Document{
+		document.NewDocumentFromMap(map[string]interface{}{"name": "far", "loc": []interface{}{10.0, 10.0}}),
+		document.NewDocumentFromMap(map[string]interface{}{"name": "origin", "loc": []interface{}{0.0, 0.0}}),
+		document.NewDocumentFromMap(map[string]interface{}{"name": "near", "loc": []interface{}{1.0, 1.0}}),
+		document.NewDocumentFromMap(map[string]interface{}{
+			"name": "geojson",
+			"loc":  map[string]interface{}{"type": "Point", "coordinates": []interface{}{-1.0, 0.5}},
+		}),
+		document.NewDocumentFromMap(map[string]interface{}{"name": "no location"}),
+	}
+}
+
+func names(docs []*document.Document) []string {
+	result := make([]string, len(docs))
+	for i, doc := range docs {
+		name, _ := doc.Get("name")
+		result[i], _ = name.(string)
+	}
+	return result
+}
+
+func TestNearSortsByDistance(t *testing.T) {
+	q := NewQuery(map[string]interface{}{
+		"loc": map[string]interface{}{
+			"$near":        []interface{}{0.2, 0.2},
+			"$maxDistance": 2.0,
+		},
+	})
+
+	results, err := NewExecutor(geoTestDocs()).Execute(q)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	got := names(results)
+	expected := []string{"origin", "near", "geojson"}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, got)
+			break
+		}
+	}
+}
+
+func TestNearMinDistanceAndLimit(t *testing.T) {
+	q := NewQuery(map[string]interface{}{
+		"loc": map[string]interface{}{
+			"$near":        []interface{}{0.0, 0.0},
+			"$minDistance": 0.5,
+		},
+	}).WithLimit(2)
+
+	results, err := NewExecutor(geoTestDocs()).Execute(q)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	got := names(results)
+	if len(got) != 2 || got[0] != "geojson" || got[1] != "near" {
+		t.Errorf("Expected [geojson near], got %v", got)
+	}
+}
+
+func TestNearGeometryUsesMeters(t *testing.T) {
+	// Two stores in San Francisco, one in Oakland (~13km away)
+	docs := []*document.Document{
+		document.NewDocumentFromMap(map[string]interface{}{"name": "mission", "loc": []interface{}{-122.4194, 37.7599}}),
+		document.NewDocumentFromMap(map[string]interface{}{"name": "downtown", "loc": []interface{}{-122.4075, 37.7880}}),
+		document.NewDocumentFromMap(map[string]interface{}{"name": "oakland", "loc": []interface{}{-122.2711, 37.8044}}),
+	}
+
+	q := NewQuery(map[string]interface{}{
+		"loc": map[string]interface{}{
+			"$near": map[string]interface{}{
+				"$geometry":    map[string]interface{}{"type": "Point", "coordinates": []interface{}{-122.4089, 37.7837}},
+				"$maxDistance": 5000.0,
+			},
+		},
+	})
+
+	results, err := NewExecutor(docs).Execute(q)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	got := names(results)
+	if len(got) != 2 || got[0] != "downtown" || got[1] != "mission" {
+		t.Errorf("Expected [downtown mission], got %v", got)
+	}
+}
+
+func TestGeoWithinShapes(t *testing.T) {
+	tests := []struct {
+		name     string
+		shape    map[string]interface{}
+		expected int
+	}{
+		{
+			name:     "box",
+			shape:    map[string]interface{}{"$box": []interface{}{[]interface{}{-0.5, -0.5}, []interface{}{1.5, 1.5}}},
+			expected: 2,
+		},
+		{
+			name: "polygon",
+			shape: map[string]interface{}{"$polygon": []interface{}{
+				[]interface{}{-2.0, -1.0}, []interface{}{2.0, -1.0}, []interface{}{2.0, 2.0}, []interface{}{-2.0, 2.0},
+			}},
+			expected: 3,
+		},
+		{
+			name:     "center",
+			shape:    map[string]interface{}{"$center": []interface{}{[]interface{}{0.0, 0.0}, 1.2}},
+			expected: 2,
+		},
+		{
+			name: "geometry",
+			shape: map[string]interface{}{"$geometry": map[string]interface{}{
+				"type": "Polygon",
+				"coordinates": []interface{}{[]interface{}{
+					[]interface{}{5.0, 5.0}, []interface{}{15.0, 5.0}, []interface{}{15.0, 15.0}, []interface{}{5.0, 15.0}, []interface{}{5.0, 5.0},
+				}},
+			}},
+			expected: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQuery(map[string]interface{}{
+				"loc": map[string]interface{}{"$geoWithin": tt.shape},
+			})
+			results, err := NewExecutor(geoTestDocs()).Execute(q)
+			if err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+			if len(results) != tt.expected {
+				t.Errorf("Expected %d results, got %v", tt.expected, names(results))
+			}
+		})
+	}
+}
+
+func TestGeoWithinInvalidShape(t *testing.T) {
+	q := NewQuery(map[string]interface{}{
+		"loc": map[string]interface{}{"$geoWithin": map[string]interface{}{"$box": []interface{}{1.0}}},
+	})
+	doc := document.NewDocumentFromMap(map[string]interface{}{"loc": []interface{}{0.0, 0.0}})
+
+	if _, err := q.Matches(doc); err == nil {
+		t.Error("Expected error for invalid $box")
+	}
+}
@@ -3,7 +3,8 @@ package query
 import (
 	"fmt"
 	"reflect"
-	"regexp"
+
+	"github.com/mnohosten/laura-db/pkg/document"
 )
 
 // Operator represents a query operator
@@ -32,6 +33,7 @@ const (
 	// Evaluation operators
 	OpRegex Operator = "$regex"
 	OpMod   Operator = "$mod"
+	OpText  Operator = "$text"
 
 	// Array operators
 	OpAll      Operator = "$all"
@@ -42,6 +44,8 @@ const (
 	OpNear          Operator = "$near"
 	OpGeoWithin     Operator = "$geoWithin"
 	OpGeoIntersects Operator = "$geoIntersects"
+	OpMaxDistance   Operator = "$maxDistance"
+	OpMinDistance   Operator = "$minDistance"
 )
 
 // EvaluateOperator evaluates an operator expression
@@ -75,12 +79,15 @@ func EvaluateOperator(op Operator, fieldValue interface{}, operatorValue interfa
 		return evaluateSize(fieldValue, operatorValue), nil
 	case OpElemMatch:
 		return evaluateElemMatch(fieldValue, operatorValue)
+	case OpGeoWithin:
+		return evaluateGeoWithin(fieldValue, operatorValue)
 	default:
 		return false, fmt.Errorf("unsupported operator: %s", op)
 	}
 }
 
-// evaluateEqual checks if two values are equal
+// evaluateEqual checks if two values are equal. Numbers are equal when
+// their numeric values are, whatever their Go types (see document.CompareValues).
 func evaluateEqual(a, b interface{}) bool {
 	if a == nil && b == nil {
 		return true
@@ -94,60 +101,45 @@ func evaluateEqual(a, b interface{}) bool {
 		return true
 	}
 
-	// Handle numeric comparisons across types
-	aVal, aOk := toFloat64(a)
-	bVal, bOk := toFloat64(b)
-	if aOk && bOk {
-		return aVal == bVal
+	// Handle comparisons across numeric types
+	if document.TypeOrder(a) == document.TypeOrder(b) {
+		return document.CompareValues(a, b) == 0
 	}
 
 	return false
 }
 
-// evaluateGreaterThan checks if a > b
-func evaluateGreaterThan(a, b interface{}) bool {
-	aVal, aOk := toFloat64(a)
-	bVal, bOk := toFloat64(b)
-	if aOk && bOk {
-		return aVal > bVal
-	}
-
-	// String comparison
-	aStr, aOk := a.(string)
-	bStr, bOk := b.(string)
-	if aOk && bOk {
-		return aStr > bStr
+// compareOrdered compares a and b for range operators. Values of different
+// type classes (e.g. a number and a string) are not comparable.
+func compareOrdered(a, b interface{}) (int, bool) {
+	if a == nil || b == nil || document.TypeOrder(a) != document.TypeOrder(b) {
+		return 0, false
 	}
+	return document.CompareValues(a, b), true
+}
 
-	return false
+// evaluateGreaterThan checks if a > b
+func evaluateGreaterThan(a, b interface{}) bool {
+	cmp, ok := compareOrdered(a, b)
+	return ok && cmp > 0
 }
 
 // evaluateGreaterThanOrEqual checks if a >= b
 func evaluateGreaterThanOrEqual(a, b interface{}) bool {
-	return evaluateGreaterThan(a, b) || evaluateEqual(a, b)
+	cmp, ok := compareOrdered(a, b)
+	return ok && cmp >= 0
 }
 
 // evaluateLessThan checks if a < b
 func evaluateLessThan(a, b interface{}) bool {
-	aVal, aOk := toFloat64(a)
-	bVal, bOk := toFloat64(b)
-	if aOk && bOk {
-		return aVal < bVal
-	}
-
-	// String comparison
-	aStr, aOk := a.(string)
-	bStr, bOk := b.(string)
-	if aOk && bOk {
-		return aStr < bStr
-	}
-
-	return false
+	cmp, ok := compareOrdered(a, b)
+	return ok && cmp < 0
 }
 
 // evaluateLessThanOrEqual checks if a <= b
 func evaluateLessThanOrEqual(a, b interface{}) bool {
-	return evaluateLessThan(a, b) || evaluateEqual(a, b)
+	cmp, ok := compareOrdered(a, b)
+	return ok && cmp <= 0
 }
 
 // evaluateIn checks if value is in the array
@@ -178,12 +170,12 @@ func evaluateRegex(value interface{}, pattern interface{}) (bool, error) {
 		return false, fmt.Errorf("regex pattern must be a string")
 	}
 
-	matched, err := regexp.MatchString(patternStr, str)
+	re, err := compileRegex(patternStr, "")
 	if err != nil {
-		return false, fmt.Errorf("invalid regex pattern: %w", err)
+		return false, err
 	}
 
-	return matched, nil
+	return re.MatchString(str), nil
 }
 
 // evaluateSize checks array size
@@ -203,26 +195,7 @@ func evaluateSize(value interface{}, size interface{}) bool {
 
 // toFloat64 converts a value to float64
 func toFloat64(v interface{}) (float64, bool) {
-	switch val := v.(type) {
-	case float64:
-		return val, true
-	case float32:
-		return float64(val), true
-	case int:
-		return float64(val), true
-	case int32:
-		return float64(val), true
-	case int64:
-		return float64(val), true
-	case uint:
-		return float64(val), true
-	case uint32:
-		return float64(val), true
-	case uint64:
-		return float64(val), true
-	default:
-		return 0, false
-	}
+	return document.ToNumber(v)
 }
 
 // toInt64 converts a value to int64
@@ -247,45 +220,5 @@ func toInt64(v interface{}) (int64, bool) {
 
 // evaluateElemMatch checks if array contains element matching all conditions
 func evaluateElemMatch(value interface{}, conditions interface{}) (bool, error) {
-	// Value must be an array
-	arrVal := reflect.ValueOf(value)
-	if arrVal.Kind() != reflect.Slice && arrVal.Kind() != reflect.Array {
-		return false, nil
-	}
-
-	// Conditions must be a map of operators
-	condMap, ok := conditions.(map[string]interface{})
-	if !ok {
-		return false, fmt.Errorf("$elemMatch requires an object with conditions")
-	}
-
-	// Check each array element
-	for i := 0; i < arrVal.Len(); i++ {
-		element := arrVal.Index(i).Interface()
-		matchesAll := true
-
-		// Element must match ALL conditions
-		for opStr, opValue := range condMap {
-			op := Operator(opStr)
-
-			// Evaluate the operator against this element
-			matches, err := EvaluateOperator(op, element, opValue)
-			if err != nil {
-				return false, err
-			}
-
-			if !matches {
-				matchesAll = false
-				break
-			}
-		}
-
-		// If this element matches all conditions, return true
-		if matchesAll {
-			return true, nil
-		}
-	}
-
-	// No element matched all conditions
-	return false, nil
+	return (&Query{}).matchElement(string(OpElemMatch), value, conditions)
 }
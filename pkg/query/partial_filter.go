@@ -0,0 +1,177 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+)
+
+// filterImplies reports whether every document matching filter also matches
+// indexFilter, so that a partial index built with indexFilter holds every
+// document the query can return. The check is conservative: a condition it
+// can't reason about is only implied by the same condition in the query.
+func filterImplies(filter, indexFilter map[string]interface{}) bool {
+	conditions := filterConditions(filter, make(map[string][]interface{}))
+	return conditionsImply(conditions, indexFilter)
+}
+
+// conditionsImply reports whether the query conditions, by field, imply
+// every condition of indexFilter
+func conditionsImply(conditions map[string][]interface{}, indexFilter map[string]interface{}) bool {
+	for field, cond := range indexFilter {
+		if field == string(OpAnd) {
+			clauses, ok := andClauses(cond)
+			if !ok {
+				return false
+			}
+			for _, clause := range clauses {
+				if !conditionsImply(conditions, clause) {
+					return false
+				}
+			}
+			continue
+		}
+		if !conditionImplied(conditions[field], cond, strings.HasPrefix(field, "$")) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterConditions collects the conditions a filter places on each field,
+// including those inside $and clauses. All of them hold for a matching
+// document.
+func filterConditions(filter map[string]interface{}, conditions map[string][]interface{}) map[string][]interface{} {
+	for field, cond := range filter {
+		if field == string(OpAnd) {
+			if clauses, ok := andClauses(cond); ok {
+				for _, clause := range clauses {
+					filterConditions(clause, conditions)
+				}
+				continue
+			}
+		}
+		conditions[field] = append(conditions[field], cond)
+	}
+	return conditions
+}
+
+// andClauses returns the filters of an $and
+func andClauses(value interface{}) ([]map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case []map[string]interface{}:
+		return v, true
+	case []interface{}:
+		clauses := make([]map[string]interface{}, len(v))
+		for i, clause := range v {
+			m, ok := clause.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			clauses[i] = m
+		}
+		return clauses, true
+	}
+	return nil, false
+}
+
+// conditionImplied reports whether the query conditions on a field imply
+// cond. Logical operators other than $and are only implied by an identical
+// condition.
+func conditionImplied(queryConds []interface{}, cond interface{}, logical bool) bool {
+	for _, qc := range queryConds {
+		if reflect.DeepEqual(qc, cond) {
+			return true
+		}
+	}
+	if logical {
+		return false
+	}
+
+	operators, ok := cond.(map[string]interface{})
+	if !ok || !isOperatorExpression(operators) {
+		operators = map[string]interface{}{string(OpEqual): cond}
+	}
+	// The query conditions all hold, so each operator may be implied by a
+	// different one
+	for op, operand := range operators {
+		implied := false
+		for _, qc := range queryConds {
+			if conditionImpliesOperator(qc, op, operand) {
+				implied = true
+				break
+			}
+		}
+		if !implied {
+			return false
+		}
+	}
+	return true
+}
+
+// conditionImpliesOperator reports whether a query condition on a field
+// implies the operator op with operand on the same field
+func conditionImpliesOperator(qc interface{}, op string, operand interface{}) bool {
+	qcOperators, isOperator := qc.(map[string]interface{})
+	if !isOperator || !isOperatorExpression(qcOperators) {
+		// The field equals qc: test the operator on that value
+		return valueSatisfies(qc, op, operand)
+	}
+
+	if value, hasEq := qcOperators[string(OpEqual)]; hasEq {
+		return valueSatisfies(value, op, operand)
+	}
+	if values, hasIn := qcOperators[string(OpIn)].([]interface{}); hasIn && len(values) > 0 {
+		// The field equals one of the values: the operator must hold for all
+		for _, value := range values {
+			if !valueSatisfies(value, op, operand) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch Operator(op) {
+	case OpExists:
+		// Range conditions only match fields that exist
+		if exists, ok := operand.(bool); ok && exists {
+			for qop := range qcOperators {
+				switch Operator(qop) {
+				case OpGreaterThan, OpGreaterThanOrEqual, OpLessThan, OpLessThanOrEqual:
+					return true
+				}
+			}
+			return qcOperators[string(OpExists)] == true
+		}
+	case OpGreaterThan, OpGreaterThanOrEqual:
+		return boundImplies(qcOperators, OpGreaterThan, OpGreaterThanOrEqual, Operator(op), operand, 1)
+	case OpLessThan, OpLessThanOrEqual:
+		return boundImplies(qcOperators, OpLessThan, OpLessThanOrEqual, Operator(op), operand, -1)
+	}
+	return false
+}
+
+// boundImplies reports whether a bound of the query (strict or inclusive)
+// is at least as tight as the bound op on operand. direction is 1 for lower
+// bounds and -1 for upper bounds.
+func boundImplies(qcOperators map[string]interface{}, strict, inclusive, op Operator, operand interface{}, direction int) bool {
+	if bound, ok := qcOperators[string(strict)]; ok {
+		if cmp, comparable := compareOrdered(bound, operand); comparable && cmp*direction >= 0 {
+			return true
+		}
+	}
+	if bound, ok := qcOperators[string(inclusive)]; ok {
+		if cmp, comparable := compareOrdered(bound, operand); comparable {
+			if cmp*direction > 0 || (cmp == 0 && op == inclusive) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// valueSatisfies reports whether a field holding value matches the operator
+// op with operand
+func valueSatisfies(value interface{}, op string, operand interface{}) bool {
+	matched, err := (&Query{}).matchOperators("", value, true, map[string]interface{}{op: operand})
+	return err == nil && matched
+}
@@ -0,0 +1,140 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/index"
+)
+
+func TestFilterImplies(t *testing.T) {
+	tests := []struct {
+		name        string
+		filter      map[string]interface{}
+		indexFilter map[string]interface{}
+		want        bool
+	}{
+		{
+			name:        "same equality",
+			filter:      map[string]interface{}{"email": "a@x", "status": "active"},
+			indexFilter: map[string]interface{}{"status": "active"},
+			want:        true,
+		},
+		{
+			name:        "field missing from query",
+			filter:      map[string]interface{}{"email": "a@x"},
+			indexFilter: map[string]interface{}{"status": "active"},
+			want:        false,
+		},
+		{
+			name:        "different equality",
+			filter:      map[string]interface{}{"status": "inactive"},
+			indexFilter: map[string]interface{}{"status": "active"},
+			want:        false,
+		},
+		{
+			name:        "$eq in query",
+			filter:      map[string]interface{}{"status": map[string]interface{}{"$eq": "active"}},
+			indexFilter: map[string]interface{}{"status": "active"},
+			want:        true,
+		},
+		{
+			name:        "equality inside range",
+			filter:      map[string]interface{}{"age": int64(30)},
+			indexFilter: map[string]interface{}{"age": map[string]interface{}{"$gte": 18}},
+			want:        true,
+		},
+		{
+			name:        "tighter range",
+			filter:      map[string]interface{}{"age": map[string]interface{}{"$gt": 21}},
+			indexFilter: map[string]interface{}{"age": map[string]interface{}{"$gte": 18}},
+			want:        true,
+		},
+		{
+			name:        "inclusive bound doesn't imply strict bound",
+			filter:      map[string]interface{}{"age": map[string]interface{}{"$gte": 18}},
+			indexFilter: map[string]interface{}{"age": map[string]interface{}{"$gt": 18}},
+			want:        false,
+		},
+		{
+			name:        "wider range",
+			filter:      map[string]interface{}{"age": map[string]interface{}{"$gt": 10}},
+			indexFilter: map[string]interface{}{"age": map[string]interface{}{"$gte": 18}},
+			want:        false,
+		},
+		{
+			name: "range split across $and",
+			filter: map[string]interface{}{"$and": []interface{}{
+				map[string]interface{}{"age": map[string]interface{}{"$gte": 20}},
+				map[string]interface{}{"age": map[string]interface{}{"$lt": 30}},
+			}},
+			indexFilter: map[string]interface{}{"age": map[string]interface{}{"$gte": 18, "$lte": 65}},
+			want:        true,
+		},
+		{
+			name:        "$in values all match",
+			filter:      map[string]interface{}{"status": map[string]interface{}{"$in": []interface{}{"active", "trial"}}},
+			indexFilter: map[string]interface{}{"status": map[string]interface{}{"$in": []interface{}{"active", "trial", "paid"}}},
+			want:        true,
+		},
+		{
+			name:        "$in value outside filter",
+			filter:      map[string]interface{}{"status": map[string]interface{}{"$in": []interface{}{"active", "closed"}}},
+			indexFilter: map[string]interface{}{"status": "active"},
+			want:        false,
+		},
+		{
+			name:        "range implies $exists",
+			filter:      map[string]interface{}{"score": map[string]interface{}{"$gt": 0}},
+			indexFilter: map[string]interface{}{"score": map[string]interface{}{"$exists": true}},
+			want:        true,
+		},
+		{
+			name:        "$or only implied by the same $or",
+			filter:      map[string]interface{}{"$or": []interface{}{map[string]interface{}{"a": 1}}},
+			indexFilter: map[string]interface{}{"$or": []interface{}{map[string]interface{}{"a": 1}, map[string]interface{}{"b": 1}}},
+			want:        false,
+		},
+		{
+			name:        "incomparable types",
+			filter:      map[string]interface{}{"age": map[string]interface{}{"$gt": "20"}},
+			indexFilter: map[string]interface{}{"age": map[string]interface{}{"$gt": 18}},
+			want:        false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filterImplies(tt.filter, tt.indexFilter); got != tt.want {
+				t.Errorf("filterImplies(%v, %v) = %v, want %v", tt.filter, tt.indexFilter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryPlannerPartialIndex(t *testing.T) {
+	idx := index.NewIndex(&index.IndexConfig{
+		Name:      "email_partial",
+		FieldPath: "email",
+		Type:      index.IndexTypeBTree,
+		Unique:    true,
+		Order:     32,
+		Filter:    map[string]interface{}{"status": "active"},
+	})
+	idx.Insert("a@x", "id1")
+	planner := NewQueryPlanner(map[string]*index.Index{"email_partial": idx})
+
+	plan := planner.Plan(NewQuery(map[string]interface{}{"email": "a@x"}))
+	if plan.UseIndex {
+		t.Error("Expected a collection scan for a query not implying the index filter")
+	}
+
+	plan = planner.Plan(NewQuery(map[string]interface{}{"email": "a@x", "status": "active"}))
+	if !plan.UseIndex || plan.IndexName != "email_partial" {
+		t.Errorf("Expected email_partial to serve the query, got %v", plan.Explain())
+	}
+
+	hinted := NewQuery(map[string]interface{}{"email": "a@x"}).WithHint("email_partial")
+	if _, err := planner.PlanWithHint(hinted); err == nil {
+		t.Error("Expected hinting a partial index that can't serve the query to fail")
+	}
+}
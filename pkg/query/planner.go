@@ -1,9 +1,17 @@
 package query
 
 import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
 	"github.com/mnohosten/laura-db/pkg/index"
 )
 
+// NaturalHint is the hint forcing a collection scan
+const NaturalHint = "$natural"
+
 // QueryPlan represents an execution plan for a query
 type QueryPlan struct {
 	UseIndex      bool
@@ -22,6 +30,25 @@ type QueryPlan struct {
 	// Index intersection support
 	UseIntersection bool                  // True if using multiple indexes
 	IntersectPlans  []*IndexIntersectPlan // Plans for each index in intersection
+
+	Hint string // Index or NaturalHint the plan was forced to use ("" if not hinted)
+
+	// Cost-based planning with collection statistics
+	EstimatedDocs int             // Documents the plan is estimated to examine (-1 without statistics)
+	Candidates    []PlanCandidate // Every plan considered, cheapest first
+	Statistics    *CollectionStatistics
+
+	// Fields the query filters and sorts on, which a covered query must
+	// read from the index
+	queryFields []string
+}
+
+// PlanCandidate is a plan the planner considered, with its estimated cost
+type PlanCandidate struct {
+	Name          string // Index name, indexes joined by "+" for an intersection, or COLLECTION_SCAN
+	ScanType      string
+	EstimatedCost int
+	EstimatedDocs int // -1 without statistics
 }
 
 // IndexIntersectPlan represents a single index scan in an intersection
@@ -47,6 +74,7 @@ const (
 // QueryPlanner plans query execution
 type QueryPlanner struct {
 	indexes map[string]*index.Index
+	stats   *CollectionStatistics // Collection statistics, nil to use index statistics only
 }
 
 // NewQueryPlanner creates a new query planner
@@ -56,14 +84,17 @@ func NewQueryPlanner(indexes map[string]*index.Index) *QueryPlanner {
 	}
 }
 
+// WithStatistics makes the planner cost plans by the number of documents
+// they are estimated to examine, using statistics sampled from the
+// collection. Without them plans are costed by index statistics alone.
+func (qp *QueryPlanner) WithStatistics(stats *CollectionStatistics) *QueryPlanner {
+	qp.stats = stats
+	return qp
+}
+
 // Plan creates an execution plan for a query
 func (qp *QueryPlanner) Plan(q *Query) *QueryPlan {
-	plan := &QueryPlan{
-		UseIndex:      false,
-		ScanType:      ScanTypeCollection,
-		EstimatedCost: 1000000, // High cost for collection scan
-		FilterSteps:   []string{},
-	}
+	plan := qp.collectionScanPlan()
 
 	if len(q.filter) == 0 {
 		// Empty filter - must scan all documents
@@ -73,11 +104,13 @@ func (qp *QueryPlanner) Plan(q *Query) *QueryPlan {
 	// Analyze filter to find usable indexes
 	// Use statistics to choose the most selective index
 	bestPlan := plan
+	candidates := []PlanCandidate{plan.candidate()}
 	for indexName, idx := range qp.indexes {
 		indexPlan := qp.analyzeIndexForFilter(indexName, idx, q.filter)
 		if indexPlan != nil {
 			// Use statistics to refine cost estimate
-			indexPlan.EstimatedCost = qp.estimateCostWithStats(indexPlan, idx)
+			qp.estimateCost(indexPlan, idx)
+			candidates = append(candidates, indexPlan.candidate())
 
 			// Choose index based on cost, but prefer indexes that eliminate more filters
 			// If costs are equal or close, prefer the one with fewer remaining filter steps
@@ -94,15 +127,171 @@ func (qp *QueryPlanner) Plan(q *Query) *QueryPlan {
 
 	// Try index intersection if we have multiple conditions
 	intersectionPlan := qp.planIndexIntersection(q.filter)
-	if intersectionPlan != nil && intersectionPlan.EstimatedCost < bestPlan.EstimatedCost {
-		bestPlan = intersectionPlan
+	if intersectionPlan != nil {
+		candidates = append(candidates, intersectionPlan.candidate())
+		if intersectionPlan.EstimatedCost < bestPlan.EstimatedCost {
+			bestPlan = intersectionPlan
+		}
 	}
 
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].EstimatedCost < candidates[j].EstimatedCost
+	})
+	bestPlan.Candidates = candidates
+	bestPlan.queryFields = queryFields(q)
 	return bestPlan
 }
 
+// collectionScanPlan returns a plan scanning every document. With
+// statistics its cost is the number of documents.
+func (qp *QueryPlanner) collectionScanPlan() *QueryPlan {
+	plan := &QueryPlan{
+		UseIndex:      false,
+		ScanType:      ScanTypeCollection,
+		EstimatedCost: 1000000, // High cost for collection scan
+		EstimatedDocs: -1,
+		FilterSteps:   []string{},
+		Statistics:    qp.stats,
+	}
+	if qp.stats != nil {
+		plan.EstimatedCost = qp.stats.TotalDocuments
+		plan.EstimatedDocs = qp.stats.TotalDocuments
+	}
+	return plan
+}
+
+// candidate describes the plan as a candidate for Explain
+func (plan *QueryPlan) candidate() PlanCandidate {
+	c := PlanCandidate{
+		Name:          plan.IndexName,
+		ScanType:      plan.scanTypeName(),
+		EstimatedCost: plan.EstimatedCost,
+		EstimatedDocs: plan.EstimatedDocs,
+	}
+	if plan.UseIntersection {
+		names := make([]string, len(plan.IntersectPlans))
+		for i, ip := range plan.IntersectPlans {
+			names[i] = ip.IndexName
+		}
+		c.Name = strings.Join(names, "+")
+	} else if !plan.UseIndex {
+		c.Name = "COLLECTION_SCAN"
+	}
+	return c
+}
+
+// scanTypeName returns the name Explain shows for the plan's scan
+func (plan *QueryPlan) scanTypeName() string {
+	switch {
+	case plan.UseIntersection:
+		return "INDEX_INTERSECTION"
+	case !plan.UseIndex:
+		return "COLLECTION_SCAN"
+	case plan.ScanType == ScanTypeIndexExact:
+		return "INDEX_EXACT"
+	case plan.ScanType == ScanTypeIndexRange:
+		return "INDEX_RANGE"
+	}
+	return "COLLECTION_SCAN"
+}
+
+// queryFields returns the fields a query filters and sorts on. Operators
+// other than $and, $or and $nor are returned as is, so that no index
+// covers them.
+func queryFields(q *Query) []string {
+	fields := filterFields(q.filter, nil)
+	for _, sf := range q.sort {
+		fields = append(fields, sf.Field)
+	}
+	return fields
+}
+
+// filterFields appends the fields a filter tests to fields
+func filterFields(filter map[string]interface{}, fields []string) []string {
+	for key, value := range filter {
+		switch key {
+		case "$and", "$or", "$nor":
+			subFilters, ok := value.([]interface{})
+			if !ok {
+				fields = append(fields, key)
+				continue
+			}
+			for _, sub := range subFilters {
+				subFilter, ok := sub.(map[string]interface{})
+				if !ok {
+					fields = append(fields, key)
+					continue
+				}
+				fields = filterFields(subFilter, fields)
+			}
+		default:
+			fields = append(fields, key)
+		}
+	}
+	return fields
+}
+
+// PlanWithHint plans a query, honoring its hint if it has one. A hinted plan
+// always scans the named index, or the whole collection for NaturalHint. An
+// error is returned if the hinted index doesn't exist or can't serve the
+// filter. Queries without a hint are planned as by Plan.
+func (qp *QueryPlanner) PlanWithHint(q *Query) (*QueryPlan, error) {
+	hint := q.hint
+	if hint == "" {
+		return qp.Plan(q), nil
+	}
+
+	if hint == NaturalHint {
+		plan := qp.collectionScanPlan()
+		plan.Hint = hint
+		return plan, nil
+	}
+
+	idx, exists := qp.indexes[hint]
+	if !exists {
+		return nil, fmt.Errorf("hint %s does not match any index", hint)
+	}
+	var plan *QueryPlan
+	if len(q.filter) > 0 {
+		plan = qp.analyzeIndexForFilter(hint, idx, q.filter)
+	}
+	if plan == nil {
+		return nil, fmt.Errorf("hinted index %s cannot serve the query: %s", hint, hintMismatch(idx, q.filter))
+	}
+	qp.estimateCost(plan, idx)
+	plan.Hint = hint
+	plan.queryFields = queryFields(q)
+	return plan, nil
+}
+
+// hintMismatch explains why an index can't serve a filter
+func hintMismatch(idx *index.Index, filter map[string]interface{}) string {
+	field := idx.FieldPath()
+	switch {
+	case len(filter) == 0:
+		return "the filter is empty"
+	case idx.IsPartial() && !filterImplies(filter, idx.Filter()):
+		return fmt.Sprintf("the filter doesn't restrict the query to the partial index filter %v", idx.Filter())
+	case idx.IsCompound():
+		if _, exists := filter[field]; !exists {
+			return fmt.Sprintf("the filter has no condition on %s, the first field of the index", field)
+		}
+	default:
+		if _, exists := filter[field]; !exists {
+			return fmt.Sprintf("the filter has no condition on the indexed field %s", field)
+		}
+	}
+	return fmt.Sprintf("the condition on %s can't be answered from the index", field)
+}
+
 // analyzeIndexForFilter analyzes if an index can be used for a filter
 func (qp *QueryPlanner) analyzeIndexForFilter(indexName string, idx *index.Index, filter map[string]interface{}) *QueryPlan {
+	// A partial index only holds the documents matching its filter, so it
+	// can only serve queries that match no other documents
+	if idx.IsPartial() && !filterImplies(filter, idx.Filter()) {
+		return nil
+	}
+
 	// Handle compound indexes
 	if idx.IsCompound() {
 		return qp.analyzeCompoundIndexForFilter(indexName, idx, filter)
@@ -137,22 +326,47 @@ func (qp *QueryPlanner) analyzeCompoundIndexForFilter(indexName string, idx *ind
 	// For compound indexes, we need to match fields in order (prefix matching)
 	// Example: index on [city, age] can be used for:
 	//   - {city: "NYC"} (prefix match)
+	//   - {city: "NYC", age: {$gt: 30}} (prefix match with range on next field)
 	//   - {city: "NYC", age: 30} (full match)
 	// But NOT for:
 	//   - {age: 30} (doesn't start with first field)
 
-	// Find how many leading fields from the index are in the filter
+	// Collect the equality values of the leading fields, stopping at the
+	// first field that is missing, has a range, or has operators the index
+	// can't serve
 	matchedFields := make([]string, 0)
-	matchedValues := make([]interface{}, 0)
+	compositeKeyValues := make([]interface{}, 0, len(fieldPaths))
+	hasRange := false
+	var rangeStart, rangeEnd interface{}
 
 	for _, fieldPath := range fieldPaths {
-		if filterValue, exists := filter[fieldPath]; exists {
-			matchedFields = append(matchedFields, fieldPath)
-			matchedValues = append(matchedValues, filterValue)
-		} else {
+		filterValue, exists := filter[fieldPath]
+		if !exists {
 			// Stop at first missing field (can't skip fields in compound index)
 			break
 		}
+
+		operatorMap, isOperator := filterValue.(map[string]interface{})
+		if !isOperator {
+			// Direct value (implicit $eq)
+			matchedFields = append(matchedFields, fieldPath)
+			compositeKeyValues = append(compositeKeyValues, filterValue)
+			continue
+		}
+		if eqValue, hasEq := operatorMap["$eq"]; hasEq {
+			matchedFields = append(matchedFields, fieldPath)
+			compositeKeyValues = append(compositeKeyValues, eqValue)
+			continue
+		}
+
+		// A range on this field bounds the scan; later fields can't be used
+		start, end, ok := compoundRangeBounds(operatorMap)
+		if ok {
+			matchedFields = append(matchedFields, fieldPath)
+			hasRange = true
+			rangeStart, rangeEnd = start, end
+		}
+		break
 	}
 
 	// Must match at least the first field to use compound index
@@ -165,84 +379,72 @@ func (qp *QueryPlanner) analyzeCompoundIndexForFilter(indexName string, idx *ind
 		UseIndex:     true,
 		IndexName:    indexName,
 		Index:        idx,
-		FilterSteps:  qp.getRemainingFilters(matchedFields[0], filter), // Will refine this
 		IndexedField: matchedFields[0], // Primary field
 	}
 
-	// Check if all matched fields have equality conditions
-	allEquality := true
-	compositeKeyValues := make([]interface{}, 0, len(matchedFields))
-
-	for i := range matchedFields {
-		value := matchedValues[i]
+	compositeKey := index.NewCompositeKey(compositeKeyValues...)
+	switch {
+	case hasRange:
+		// Prefix match with a range on the next field - scan only the keys
+		// between the bounds within the prefix
+		plan.ScanType = ScanTypeIndexRange
+		plan.ScanStart = rangeStart
+		plan.ScanEnd = rangeEnd
+		plan.PrefixKey = compositeKey
+		plan.EstimatedCost = 30
+		if len(compositeKeyValues) == 0 {
+			plan.EstimatedCost = 50 // Range on the first field only
+		}
+	case len(matchedFields) < len(fieldPaths):
+		// Prefix match - scan only the keys starting with the prefix
+		plan.ScanType = ScanTypeIndexRange
+		plan.PrefixKey = compositeKey
+		plan.EstimatedCost = 20 // Low cost for prefix scan
+	default:
+		// Full match - exact composite key lookup
+		plan.ScanType = ScanTypeIndexExact
+		plan.ScanKey = compositeKey
+		plan.EstimatedCost = 10 // Very low cost for exact match
+	}
 
-		// Check if it's an operator expression
-		if operatorMap, ok := value.(map[string]interface{}); ok {
-			// For compound indexes, only support $eq for non-final fields
-			if eqValue, hasEq := operatorMap["$eq"]; hasEq {
-				compositeKeyValues = append(compositeKeyValues, eqValue)
-			} else if i == len(matchedFields)-1 {
-				// For the last matched field, we can support range operators
-				// But this requires partial composite key matching
-				// For now, only support equality
-				allEquality = false
-				break
-			} else {
-				// Non-final field must be equality
-				allEquality = false
+	// Update remaining filters (exclude all matched fields)
+	remaining := make([]string, 0)
+	for field := range filter {
+		if field == "$and" || field == "$or" {
+			continue
+		}
+		isMatched := false
+		for _, matched := range matchedFields {
+			if field == matched {
+				isMatched = true
 				break
 			}
-		} else {
-			// Direct value (implicit $eq)
-			compositeKeyValues = append(compositeKeyValues, value)
+		}
+		if !isMatched {
+			remaining = append(remaining, field)
 		}
 	}
+	plan.FilterSteps = remaining
 
-	if allEquality && len(compositeKeyValues) == len(matchedFields) {
-		compositeKey := index.NewCompositeKey(compositeKeyValues...)
-
-		// Check if this is a prefix match or full match
-		isPrefix := len(matchedFields) < len(fieldPaths)
-
-		if isPrefix {
-			// Prefix match - use range scan and filter by prefix
-			plan.ScanType = ScanTypeIndexRange
-			plan.ScanStart = nil // Scan from beginning
-			plan.ScanEnd = nil   // Scan to end
-			plan.PrefixKey = compositeKey
-			plan.EstimatedCost = 20 // Low cost for prefix scan
-		} else {
-			// Full match - exact composite key lookup
-			plan.ScanType = ScanTypeIndexExact
-			plan.ScanKey = compositeKey
-			plan.EstimatedCost = 10 // Very low cost for exact match
-		}
+	return plan
+}
 
-		// Update remaining filters (exclude all matched fields)
-		remaining := make([]string, 0)
-		for field := range filter {
-			if field == "$and" || field == "$or" {
-				continue
-			}
-			isMatched := false
-			for _, matched := range matchedFields {
-				if field == matched {
-					isMatched = true
-					break
-				}
-			}
-			if !isMatched {
-				remaining = append(remaining, field)
-			}
+// compoundRangeBounds returns the inclusive bounds of an operator object
+// made only of $gt, $gte, $lt and $lte. Exclusive bounds are widened to
+// inclusive ones; the filter is re-checked on every scanned document.
+func compoundRangeBounds(operatorMap map[string]interface{}) (interface{}, interface{}, bool) {
+	var start, end interface{}
+	for op, value := range operatorMap {
+		switch op {
+		case "$gt", "$gte":
+			start = value
+		case "$lt", "$lte":
+			end = value
+		default:
+			return nil, nil, false
 		}
-		plan.FilterSteps = remaining
-
-		return plan
 	}
-
-	// Partial match or range queries - for now, don't use compound index
-	// (Future enhancement: support range on last field)
-	return nil
+	return start, end, len(operatorMap) > 0
 }
 
 // analyzeSingleFieldFilter analyzes a single field filter
@@ -289,6 +491,15 @@ func (qp *QueryPlanner) analyzeSingleFieldFilter(indexName string, idx *index.In
 				hasLte = true
 				lteValue = opValue
 
+			case "$regex", "$options":
+				// Only a pattern anchored to a literal prefix can scan an index
+				prefix, ok := regexPrefix(operatorMap)
+				if !ok {
+					return nil
+				}
+				hasGte, hasLte = true, true
+				gteValue, lteValue = prefixRange(prefix)
+
 			case "$in":
 				// Could use index for each value, but for now treat as medium cost
 				plan.ScanType = ScanTypeCollection
@@ -344,6 +555,65 @@ func (qp *QueryPlanner) getRemainingFilters(indexedField string, filter map[stri
 	return remaining
 }
 
+// estimateCost sets the estimated cost of a single index plan, from
+// collection statistics if the planner has them and from index statistics
+// otherwise
+func (qp *QueryPlanner) estimateCost(plan *QueryPlan, idx *index.Index) {
+	plan.Statistics = qp.stats
+	if qp.stats == nil {
+		plan.EstimatedCost = qp.estimateCostWithStats(plan, idx)
+		plan.EstimatedDocs = -1
+		return
+	}
+
+	total := float64(qp.stats.TotalDocuments)
+	selectivity := 1.0
+	switch {
+	case plan.ScanType == ScanTypeIndexExact && idx.IsUnique():
+		selectivity = 1 / math.Max(total, 1)
+	case plan.ScanType == ScanTypeIndexExact:
+		selectivity = qp.keySelectivity(idx, plan.ScanKey)
+	case plan.ScanType == ScanTypeIndexRange && plan.PrefixKey != nil:
+		selectivity = qp.keySelectivity(idx, plan.PrefixKey)
+		if plan.ScanStart != nil || plan.ScanEnd != nil {
+			next := idx.FieldPaths()[len(plan.PrefixKey.Values)]
+			selectivity *= qp.stats.RangeSelectivity(next, plan.ScanStart, plan.ScanEnd)
+		}
+	case plan.ScanType == ScanTypeIndexRange:
+		selectivity = qp.stats.RangeSelectivity(idx.FieldPath(), plan.ScanStart, plan.ScanEnd)
+	}
+
+	// Every matching index key is read and its document fetched
+	docs := total * selectivity
+	plan.EstimatedDocs = int(math.Ceil(docs))
+	plan.EstimatedCost = scanCost(docs, docs, 1)
+}
+
+// keySelectivity estimates the share of documents matching an exact key,
+// treating the fields of a composite key as independent
+func (qp *QueryPlanner) keySelectivity(idx *index.Index, key interface{}) float64 {
+	composite, ok := key.(*index.CompositeKey)
+	if !ok {
+		return qp.stats.EqualitySelectivity(idx.FieldPath(), key)
+	}
+	fields := idx.FieldPaths()
+	selectivity := 1.0
+	for i, value := range composite.Values {
+		if i < len(fields) {
+			selectivity *= qp.stats.EqualitySelectivity(fields[i], value)
+		}
+	}
+	return selectivity
+}
+
+// scanCost is the cost of examining docs documents found by reading keys
+// index keys in seeks index lookups. Reading a key costs a tenth of
+// examining a document, so an index matching nearly every document costs
+// more than a collection scan.
+func scanCost(docs, keys float64, seeks int) int {
+	return int(math.Ceil(docs+keys/10)) + seeks
+}
+
 // estimateCostWithStats estimates query cost using index statistics
 func (qp *QueryPlanner) estimateCostWithStats(plan *QueryPlan, idx *index.Index) int {
 	stats := idx.GetStatistics()
@@ -395,41 +665,77 @@ func (qp *QueryPlanner) estimateCostWithStats(plan *QueryPlan, idx *index.Index)
 	}
 }
 
-// DetectCoveredQuery checks if the query can be satisfied entirely from the index
+// DetectCoveredQuery checks if the query can be satisfied entirely from the
+// index: every field the query filters on, sorts on and projects must be a
+// field of the index key or _id, which the index stores as the entry value
 func (qp *QueryPlanner) DetectCoveredQuery(plan *QueryPlan, projection map[string]bool) {
-	// Query must use an index to be covered
-	if !plan.UseIndex {
-		plan.IsCovered = false
+	plan.IsCovered = false
+
+	// Query must use a single index to be covered
+	if !plan.UseIndex || plan.UseIntersection || plan.Index == nil {
 		return
 	}
 
 	// If no projection specified, we need all fields (not covered)
-	if projection == nil || len(projection) == 0 {
-		plan.IsCovered = false
+	if len(projection) == 0 {
 		return
 	}
 
-	// Check if all requested fields are available from index
-	// Index provides: the indexed field and _id (from the index value)
+	available := map[string]bool{"_id": true}
+	for _, field := range plan.Index.FieldPaths() {
+		// Index entries hold values of nested fields, but the executor
+		// can't rebuild the nesting from them
+		if strings.Contains(field, ".") {
+			return
+		}
+		available[field] = true
+	}
+
+	included := false
 	for field, include := range projection {
 		if !include {
-			// Exclusion projection - not supported for covered queries yet
-			plan.IsCovered = false
+			// An exclusion projection returns fields the index doesn't
+			// have; only _id can be excluded from an inclusion
+			if field != "_id" {
+				return
+			}
+			continue
+		}
+		if !available[field] {
 			return
 		}
+		included = true
+	}
+	if !included {
+		return
+	}
 
-		// Check if field is available from index
-		if field != plan.IndexedField && field != "_id" {
-			// Field not available from index
-			plan.IsCovered = false
+	for _, field := range plan.queryFields {
+		if !available[field] {
 			return
 		}
 	}
 
-	// All projected fields are available from index!
+	// All fields the query needs are available from the index
 	plan.IsCovered = true
 }
 
+// RecordIndexUsage counts a use of every index the plan scans. Call it when
+// the plan is executed, not when it is only explained.
+func (plan *QueryPlan) RecordIndexUsage() {
+	if plan.UseIntersection {
+		for _, p := range plan.IntersectPlans {
+			if p.Index != nil {
+				p.Index.Usage().Record()
+			}
+		}
+		return
+	}
+	if plan.UseIndex && plan.Index != nil {
+		plan.Index.Usage().Record()
+	}
+}
+
 // Explain returns a human-readable explanation of the query plan
 func (plan *QueryPlan) Explain() map[string]interface{} {
 	result := map[string]interface{}{
@@ -463,6 +769,9 @@ func (plan *QueryPlan) Explain() map[string]interface{} {
 			result["scanKey"] = plan.ScanKey
 		case ScanTypeIndexRange:
 			result["scanType"] = "INDEX_RANGE"
+			if plan.PrefixKey != nil {
+				result["scanPrefix"] = plan.PrefixKey.Values
+			}
 			if plan.ScanStart != nil {
 				result["scanStart"] = plan.ScanStart
 			}
@@ -483,6 +792,35 @@ func (plan *QueryPlan) Explain() map[string]interface{} {
 	} else {
 		result["scanType"] = "COLLECTION_SCAN"
 		result["reason"] = "No suitable index found"
+		if plan.Hint == NaturalHint {
+			result["reason"] = "Collection scan forced by hint"
+		}
+	}
+
+	if plan.Hint != "" {
+		result["hint"] = plan.Hint
+		result["hintHonored"] = true
+	}
+
+	result["costModel"] = "index statistics"
+	if plan.Statistics != nil {
+		result["costModel"] = "collection statistics"
+		result["estimatedDocsExamined"] = plan.EstimatedDocs
+		result["statisticsUpdated"] = plan.Statistics.UpdatedAt
+	}
+	if len(plan.Candidates) > 0 {
+		candidates := make([]map[string]interface{}, len(plan.Candidates))
+		for i, c := range plan.Candidates {
+			candidates[i] = map[string]interface{}{
+				"plan":          c.Name,
+				"scanType":      c.ScanType,
+				"estimatedCost": c.EstimatedCost,
+			}
+			if c.EstimatedDocs >= 0 {
+				candidates[i]["estimatedDocsExamined"] = c.EstimatedDocs
+			}
+		}
+		result["candidatePlans"] = candidates
 	}
 
 	return result
@@ -514,6 +852,10 @@ func (qp *QueryPlanner) planIndexIntersection(filter map[string]interface{}) *Qu
 			if idx.IsCompound() {
 				continue
 			}
+			// A partial index may miss documents the query matches
+			if idx.IsPartial() && !filterImplies(filter, idx.Filter()) {
+				continue
+			}
 
 			// Check if this index matches the field
 			if idx.FieldPath() != field {
@@ -537,7 +879,10 @@ func (qp *QueryPlanner) planIndexIntersection(filter map[string]interface{}) *Qu
 
 	// Estimate cost of intersection
 	// Cost = sum of index scans + intersection overhead
-	estimatedCost := qp.estimateIntersectionCost(intersectPlans)
+	estimatedCost, estimatedDocs := qp.estimateIntersectionCost(intersectPlans), -1
+	if qp.stats != nil {
+		estimatedCost, estimatedDocs = qp.estimateIntersectionCostFromStatistics(intersectPlans)
+	}
 
 	// Find remaining filters (fields not covered by any index)
 	remainingFilters := make([]string, 0)
@@ -560,9 +905,32 @@ func (qp *QueryPlanner) planIndexIntersection(filter map[string]interface{}) *Qu
 		IntersectPlans:  intersectPlans,
 		ScanType:        ScanTypeCollection, // Not used for intersection
 		EstimatedCost:   estimatedCost,
+		EstimatedDocs:   estimatedDocs,
 		FilterSteps:     remainingFilters,
 		IsCovered:       false, // Intersection requires document fetch
+		Statistics:      qp.stats,
+	}
+}
+
+// estimateIntersectionCostFromStatistics estimates the cost of an
+// intersection and the documents it examines: every index's matching keys
+// are read, and only documents in all of them are fetched, assuming the
+// fields are independent
+func (qp *QueryPlanner) estimateIntersectionCostFromStatistics(plans []*IndexIntersectPlan) (int, int) {
+	total := float64(qp.stats.TotalDocuments)
+	keys, selectivity := 0.0, 1.0
+	for _, p := range plans {
+		var s float64
+		if p.ScanType == ScanTypeIndexExact {
+			s = qp.stats.EqualitySelectivity(p.Field, p.ScanKey)
+		} else {
+			s = qp.stats.RangeSelectivity(p.Field, p.ScanStart, p.ScanEnd)
+		}
+		keys += total * s
+		selectivity *= s
 	}
+	docs := total * selectivity
+	return scanCost(docs, keys, len(plans)), int(math.Ceil(docs))
 }
 
 // createIntersectPlan creates a plan for a single index in an intersection
@@ -601,6 +969,15 @@ func (qp *QueryPlanner) createIntersectPlan(indexName string, idx *index.Index,
 				}
 				return plan
 
+			case "$regex", "$options":
+				prefix, ok := regexPrefix(operatorMap)
+				if !ok {
+					return nil
+				}
+				plan.ScanType = ScanTypeIndexRange
+				plan.ScanStart, plan.ScanEnd = prefixRange(prefix)
+				return plan
+
 			default:
 				// Unsupported operator for intersection
 				return nil
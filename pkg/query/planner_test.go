@@ -1,6 +1,7 @@
 package query
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/mnohosten/laura-db/pkg/index"
@@ -218,3 +219,66 @@ func TestQueryPlanExplain(t *testing.T) {
 		t.Error("Explain should include index name")
 	}
 }
+
+func TestQueryPlannerHint(t *testing.T) {
+	indexes := map[string]*index.Index{
+		"age_1": index.NewIndex(&index.IndexConfig{
+			Name:      "age_1",
+			FieldPath: "age",
+			Type:      index.IndexTypeBTree,
+			Order:     32,
+		}),
+		"city_1": index.NewIndex(&index.IndexConfig{
+			Name:      "city_1",
+			FieldPath: "city",
+			Type:      index.IndexTypeBTree,
+			Order:     32,
+		}),
+	}
+	planner := NewQueryPlanner(indexes)
+	filter := map[string]interface{}{
+		"age":  30,
+		"city": "Prague",
+	}
+
+	plan, err := planner.PlanWithHint(NewQuery(filter).WithHint("city_1"))
+	if err != nil {
+		t.Fatalf("PlanWithHint failed: %v", err)
+	}
+	if !plan.UseIndex || plan.IndexName != "city_1" || plan.Hint != "city_1" {
+		t.Errorf("Expected plan on hinted index city_1, got %+v", plan)
+	}
+
+	plan, err = planner.PlanWithHint(NewQuery(filter).WithHint(NaturalHint))
+	if err != nil {
+		t.Fatalf("PlanWithHint failed: %v", err)
+	}
+	if plan.UseIndex || plan.ScanType != ScanTypeCollection {
+		t.Errorf("Expected collection scan, got %+v", plan)
+	}
+
+	if _, err := planner.PlanWithHint(NewQuery(filter).WithHint("missing_1")); err == nil {
+		t.Error("Expected error for unknown index")
+	}
+	_, err = planner.PlanWithHint(NewQuery(map[string]interface{}{"name": "Alice"}).WithHint("age_1"))
+	if err == nil {
+		t.Error("Expected error for index that can't serve the query")
+	} else if !strings.Contains(err.Error(), "no condition on the indexed field age") {
+		t.Errorf("Expected the error to name the missing field, got %v", err)
+	}
+	_, err = planner.PlanWithHint(NewQuery(map[string]interface{}{
+		"age": map[string]interface{}{"$ne": 30},
+	}).WithHint("age_1"))
+	if err == nil || !strings.Contains(err.Error(), "condition on age can't be answered") {
+		t.Errorf("Expected an error for a condition the index can't answer, got %v", err)
+	}
+
+	// Without a hint the planner picks its own plan
+	plan, err = planner.PlanWithHint(NewQuery(filter))
+	if err != nil {
+		t.Fatalf("PlanWithHint failed: %v", err)
+	}
+	if plan.Hint != "" {
+		t.Errorf("Expected no hint, got %q", plan.Hint)
+	}
+}
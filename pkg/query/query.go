@@ -2,6 +2,9 @@ package query
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/mnohosten/laura-db/pkg/document"
 )
@@ -10,9 +13,12 @@ import (
 type Query struct {
 	filter     map[string]interface{}
 	projection map[string]bool
+	meta       map[string]string // output field -> metadata ("textScore")
 	sort       []SortField
 	limit      int
 	skip       int
+	hint       string // Index the planner must use, or NaturalHint
+	regexes    *regexCache
 }
 
 // SortField represents a field to sort by
@@ -29,6 +35,7 @@ func NewQuery(filter map[string]interface{}) *Query {
 		sort:       nil,
 		limit:      0,
 		skip:       0,
+		regexes:    &regexCache{compiled: make(map[[2]string]*regexp.Regexp)},
 	}
 }
 
@@ -38,6 +45,13 @@ func (q *Query) WithProjection(projection map[string]bool) *Query {
 	return q
 }
 
+// WithMeta projects query metadata into result fields, e.g.
+// {"score": "textScore"} adds the $text relevance score as "score"
+func (q *Query) WithMeta(meta map[string]string) *Query {
+	q.meta = meta
+	return q
+}
+
 // WithSort sets the sort order
 func (q *Query) WithSort(fields []SortField) *Query {
 	q.sort = fields
@@ -56,6 +70,13 @@ func (q *Query) WithSkip(skip int) *Query {
 	return q
 }
 
+// WithHint forces the planner to use the named index, or a collection scan
+// for NaturalHint
+func (q *Query) WithHint(hint string) *Query {
+	q.hint = hint
+	return q
+}
+
 // Matches checks if a document matches the query filter
 func (q *Query) Matches(doc *document.Document) (bool, error) {
 	if len(q.filter) == 0 {
@@ -85,38 +106,22 @@ func (q *Query) evaluateFilter(doc *document.Document, filter map[string]interfa
 			continue
 		}
 
+		// $text candidates come from a text index, which scores every match
+		if key == string(OpText) {
+			if _, scored := doc.Get(TextScoreField); !scored {
+				return false, nil
+			}
+			continue
+		}
+
 		// Field comparison
 		fieldValue, exists := doc.Get(key)
 
 		// Handle operator expressions
 		if operatorMap, ok := value.(map[string]interface{}); ok {
-			for opStr, opValue := range operatorMap {
-				op := Operator(opStr)
-
-				// Special case for $exists
-				if op == OpExists {
-					result, err := EvaluateOperator(op, fieldValue, opValue)
-					if err != nil {
-						return false, err
-					}
-					if !result {
-						return false, nil
-					}
-					continue
-				}
-
-				// For other operators, field must exist
-				if !exists {
-					return false, nil
-				}
-
-				result, err := EvaluateOperator(op, fieldValue, opValue)
-				if err != nil {
-					return false, err
-				}
-				if !result {
-					return false, nil
-				}
+			matched, err := q.matchOperators(key, fieldValue, exists, operatorMap)
+			if err != nil || !matched {
+				return false, err
 			}
 		} else {
 			// Direct equality comparison
@@ -132,6 +137,88 @@ func (q *Query) evaluateFilter(doc *document.Document, filter map[string]interfa
 	return true, nil
 }
 
+// matchOperators evaluates the operators of an operator expression against
+// the value of a field
+func (q *Query) matchOperators(key string, fieldValue interface{}, exists bool, operatorMap map[string]interface{}) (bool, error) {
+	for opStr, opValue := range operatorMap {
+		op := Operator(opStr)
+
+		// Special case for $exists
+		if op == OpExists {
+			result, err := EvaluateOperator(op, fieldValue, opValue)
+			if err != nil {
+				return false, err
+			}
+			if !result {
+				return false, nil
+			}
+			continue
+		}
+
+		// For other operators, field must exist
+		if !exists {
+			return false, nil
+		}
+
+		// $near reads its distance bounds from the same operator map
+		if op == OpNear {
+			nc, err := ParseNear(key, operatorMap)
+			if err != nil {
+				return false, err
+			}
+			if !nc.Matches(fieldValue) {
+				return false, nil
+			}
+			continue
+		}
+		if op == OpMaxDistance || op == OpMinDistance {
+			if _, hasNear := operatorMap[string(OpNear)]; hasNear {
+				continue
+			}
+		}
+
+		// $regex reads its flags from $options in the same operator map
+		if op == OpRegex {
+			matched, err := q.matchRegex(fieldValue, operatorMap)
+			if err != nil {
+				return false, err
+			}
+			if !matched {
+				return false, nil
+			}
+			continue
+		}
+		if op == OpOptions {
+			if _, hasRegex := operatorMap[string(OpRegex)]; !hasRegex {
+				return false, fmt.Errorf("$options requires $regex")
+			}
+			continue
+		}
+
+		// $elemMatch evaluates its conditions as a whole per element
+		if op == OpElemMatch {
+			matched, err := q.matchElement(key, fieldValue, opValue)
+			if err != nil {
+				return false, err
+			}
+			if !matched {
+				return false, nil
+			}
+			continue
+		}
+
+		result, err := EvaluateOperator(op, fieldValue, opValue)
+		if err != nil {
+			return false, err
+		}
+		if !result {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
 // evaluateAnd evaluates $and operator
 func (q *Query) evaluateAnd(doc *document.Document, value interface{}) (bool, error) {
 	conditions, ok := value.([]interface{})
@@ -184,37 +271,68 @@ func (q *Query) evaluateOr(doc *document.Document, value interface{}) (bool, err
 
 // ApplyProjection applies the projection to a document
 func (q *Query) ApplyProjection(doc *document.Document) *document.Document {
-	if q.projection == nil || len(q.projection) == 0 {
+	result := q.project(doc)
+	if score, scored := doc.Get(TextScoreField); scored {
+		result = q.applyTextScore(result, score)
+	}
+	return result
+}
+
+// project applies the field projection to a document. An inclusion
+// projection returns the fields it lists, and _id unless it excludes it; an
+// exclusion projection returns every field but those it lists. Dotted paths
+// select or drop fields of embedded documents, keeping their nesting.
+func (q *Query) project(doc *document.Document) *document.Document {
+	if len(q.projection) == 0 {
 		return doc
 	}
 
 	result := document.NewDocument()
+	if !IsInclusionProjection(q.projection) {
+		for _, key := range doc.Keys() {
+			if include, listed := q.projection[key]; !listed || include {
+				value, _ := doc.Get(key)
+				result.Set(key, value)
+			}
+		}
+		// Removing a nested field copies the embedded documents along its
+		// path, leaving doc as it was
+		for field, include := range q.projection {
+			if !include && strings.Contains(field, ".") {
+				result.DeleteNested(field)
+			}
+		}
+		return result
+	}
 
-	// Determine if this is an inclusion or exclusion projection
-	isInclusion := false
-	for _, include := range q.projection {
-		if include {
-			isInclusion = true
-			break
+	if include, listed := q.projection["_id"]; !listed || include {
+		if id, exists := doc.Get("_id"); exists {
+			result.Set("_id", id)
 		}
 	}
 
-	if isInclusion {
-		// Include only specified fields
-		for field, include := range q.projection {
-			if include {
-				if value, exists := doc.Get(field); exists {
-					result.Set(field, value)
-				}
-			}
+	// Fields are returned in the order of the document
+	nested := make(map[string][]string)
+	for field, include := range q.projection {
+		if include && strings.Contains(field, ".") {
+			top := field[:strings.Index(field, ".")]
+			nested[top] = append(nested[top], field)
 		}
-	} else {
-		// Exclude specified fields
-		for _, key := range doc.Keys() {
-			if exclude, exists := q.projection[key]; !exists || !exclude {
-				if value, exists := doc.Get(key); exists {
-					result.Set(key, value)
-				}
+	}
+	for _, key := range doc.Keys() {
+		if key == "_id" {
+			continue
+		}
+		if q.projection[key] {
+			value, _ := doc.Get(key)
+			result.Set(key, value)
+			continue
+		}
+		paths := nested[key]
+		sort.Strings(paths)
+		for _, path := range paths {
+			if value, exists := doc.GetNested(path); exists {
+				result.SetNested(path, value)
 			}
 		}
 	}
@@ -222,6 +340,23 @@ func (q *Query) ApplyProjection(doc *document.Document) *document.Document {
 	return result
 }
 
+// IsInclusionProjection reports whether a projection lists the fields to
+// return rather than those to leave out. _id may be excluded from an
+// inclusion projection, so it only decides when no other field is listed.
+func IsInclusionProjection(projection map[string]bool) bool {
+	others := false
+	for field, include := range projection {
+		if field == "_id" {
+			continue
+		}
+		if include {
+			return true
+		}
+		others = true
+	}
+	return !others && projection["_id"]
+}
+
 // GetFilter returns the filter
 func (q *Query) GetFilter() map[string]interface{} {
 	return q.filter
@@ -246,3 +381,13 @@ func (q *Query) GetSort() []SortField {
 func (q *Query) GetProjection() map[string]bool {
 	return q.projection
 }
+
+// GetMeta returns the metadata projection
+func (q *Query) GetMeta() map[string]string {
+	return q.meta
+}
+
+// GetHint returns the index hint ("" if none)
+func (q *Query) GetHint() string {
+	return q.hint
+}
@@ -1,6 +1,7 @@
 package query
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/mnohosten/laura-db/pkg/document"
@@ -278,6 +279,30 @@ func TestExecutorSort(t *testing.T) {
 	}
 }
 
+func TestExecutorSortMixedTypes(t *testing.T) {
+	docs := []*document.Document{
+		document.NewDocumentFromMap(map[string]interface{}{"_id": "a", "v": true}),
+		document.NewDocumentFromMap(map[string]interface{}{"_id": "b", "v": "text"}),
+		document.NewDocumentFromMap(map[string]interface{}{"_id": "c"}),
+		document.NewDocumentFromMap(map[string]interface{}{"_id": "d", "v": int64(2)}),
+		document.NewDocumentFromMap(map[string]interface{}{"_id": "e", "v": nil}),
+		document.NewDocumentFromMap(map[string]interface{}{"_id": "f", "v": 1.5}),
+		document.NewDocumentFromMap(map[string]interface{}{"_id": "g", "v": int64(2)}),
+	}
+
+	q := NewQuery(map[string]interface{}{})
+	q.WithSort([]SortField{{Field: "v", Ascending: true}})
+	results, _ := NewExecutor(docs).Execute(q)
+
+	// null < numbers < strings < booleans, missing last, ties by _id
+	expected := []string{"e", "f", "d", "g", "b", "a", "c"}
+	for i, doc := range results {
+		if id, _ := doc.Get("_id"); id != expected[i] {
+			t.Fatalf("Expected order %v, got %v at %d", expected, id, i)
+		}
+	}
+}
+
 func TestExecutorSkipLimit(t *testing.T) {
 	docs := []*document.Document{
 		document.NewDocumentFromMap(map[string]interface{}{"id": int64(1)}),
@@ -497,12 +522,12 @@ func TestCompareValues(t *testing.T) {
 		{"equal float64", float64(3.14), float64(3.14), 0},
 		{"less than float64", float64(1.5), float64(2.5), -1},
 		{"int vs float", int64(10), float64(10.0), 0},
-		{"nil vs value", nil, "value", 0},          // compareValues returns 0 for non-numeric/string types
-		{"value vs nil", "value", nil, 0},          // compareValues returns 0 for non-numeric/string types
-		{"nil vs nil", nil, nil, 0},                // compareValues returns 0 for non-numeric/string types
-		{"bool true vs false", true, false, 0},     // compareValues returns 0 for non-numeric/string types
-		{"bool false vs true", false, true, 0},     // compareValues returns 0 for non-numeric/string types
-		{"bool equal", true, true, 0},              // compareValues returns 0 for non-numeric/string types
+		{"nil vs value", nil, "value", -1}, // null sorts before every other type
+		{"value vs nil", "value", nil, 1},  // null sorts before every other type
+		{"nil vs nil", nil, nil, 0},
+		{"bool true vs false", true, false, 1}, // false < true
+		{"bool false vs true", false, true, -1},
+		{"bool equal", true, true, 0},
 		{"time comparison", "2024-01-01", "2024-01-02", -1},
 	}
 
@@ -632,16 +657,59 @@ func TestApplyProjectionExclusion(t *testing.T) {
 
 	projected := q.ApplyProjection(doc)
 
-	// In exclusion mode, fields NOT mentioned in projection or with explicit false are excluded
+	// In exclusion mode, fields NOT mentioned in projection are kept
 	if !projected.Has("name") {
 		t.Error("Expected 'name' field in projection")
 	}
 	if !projected.Has("email") {
 		t.Error("Expected 'email' field in projection")
 	}
-	// The code excludes fields where exclude is true (line 214: !exists || !exclude)
-	// So when age is false, it should still be included (because !false = true)
-	// This is actually testing the exclusion logic works as implemented
+	if projected.Has("age") || projected.Has("city") {
+		t.Error("Expected 'age' and 'city' fields to be excluded")
+	}
+}
+
+// Test ApplyProjection with dotted paths and the default _id
+func TestApplyProjectionNested(t *testing.T) {
+	doc := document.NewDocumentFromMap(map[string]interface{}{
+		"_id":  "u1",
+		"name": "Alice",
+		"address": map[string]interface{}{
+			"city":   "Prague",
+			"zip":    "11000",
+			"street": "Main",
+		},
+	})
+
+	// Inclusion keeps _id and only the selected subtree
+	projected := NewQuery(nil).WithProjection(map[string]bool{"address.city": true, "address.zip": true}).ApplyProjection(doc)
+	if id, _ := projected.Get("_id"); id != "u1" {
+		t.Errorf("Expected _id included by default, got %v", id)
+	}
+	address, ok := projected.Get("address")
+	if !ok {
+		t.Fatal("Expected the address subtree")
+	}
+	if !reflect.DeepEqual(address, map[string]interface{}{"city": "Prague", "zip": "11000"}) {
+		t.Errorf("Expected only city and zip of the address, got %v", address)
+	}
+	if projected.Has("name") {
+		t.Error("Expected 'name' not to be included")
+	}
+
+	projected = NewQuery(nil).WithProjection(map[string]bool{"name": true, "_id": false}).ApplyProjection(doc)
+	if projected.Has("_id") || projected.Len() != 1 {
+		t.Errorf("Expected only name, got %v", projected.ToMap())
+	}
+
+	// Exclusion drops nested fields without changing the document
+	projected = NewQuery(nil).WithProjection(map[string]bool{"address.street": false}).ApplyProjection(doc)
+	if projected.Has("address.street") || !projected.Has("address.city") || !projected.Has("name") {
+		t.Errorf("Expected only address.street excluded, got %v", projected.ToMap())
+	}
+	if !doc.Has("address.street") {
+		t.Error("Expected the projected document to be left as it was")
+	}
 }
 
 // Test evaluateAnd with error propagation through Query.Matches
@@ -897,3 +965,58 @@ func TestIntersectSetsEdgeCases(t *testing.T) {
 		t.Errorf("Expected 2 items, got %d", len(result))
 	}
 }
+
+// Test that matching treats numbers of different Go types as the same value
+// and never orders values across type classes
+func TestMatchesAcrossNumericTypes(t *testing.T) {
+	docs := []*document.Document{
+		document.NewDocumentFromMap(map[string]interface{}{"age": int64(30)}),
+		document.NewDocumentFromMap(map[string]interface{}{"age": float64(30)}),
+		document.NewDocumentFromMap(map[string]interface{}{"age": int32(30)}),
+	}
+
+	filters := []map[string]interface{}{
+		{"age": 30},
+		{"age": int64(30)},
+		{"age": float64(30)},
+		{"age": map[string]interface{}{"$eq": 30}},
+		{"age": map[string]interface{}{"$gte": float64(29.5), "$lt": 31}},
+		{"age": map[string]interface{}{"$in": []interface{}{"30", 30}}},
+	}
+
+	for _, filter := range filters {
+		q := NewQuery(filter)
+		for _, doc := range docs {
+			matches, err := q.Matches(doc)
+			if err != nil {
+				t.Fatalf("Matches failed: %v", err)
+			}
+			if !matches {
+				age, _ := doc.Get("age")
+				t.Errorf("Expected filter %v to match age %v (%T)", filter, age, age)
+			}
+		}
+	}
+
+	// Range operators don't match across type classes
+	mixed := []struct {
+		value  interface{}
+		filter map[string]interface{}
+	}{
+		{"30", map[string]interface{}{"age": 30}},
+		{"abc", map[string]interface{}{"age": map[string]interface{}{"$gt": 10}}},
+		{int64(5), map[string]interface{}{"age": map[string]interface{}{"$lt": "z"}}},
+		{true, map[string]interface{}{"age": map[string]interface{}{"$gt": 0}}},
+		{true, map[string]interface{}{"age": 1}},
+	}
+	for _, tt := range mixed {
+		doc := document.NewDocumentFromMap(map[string]interface{}{"age": tt.value})
+		matches, err := NewQuery(tt.filter).Matches(doc)
+		if err != nil {
+			t.Fatalf("Matches failed: %v", err)
+		}
+		if matches {
+			t.Errorf("Expected filter %v not to match %v (%T)", tt.filter, tt.value, tt.value)
+		}
+	}
+}
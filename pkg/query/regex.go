@@ -0,0 +1,128 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"sync"
+)
+
+// OpOptions sets the flags of the $regex in the same operator object
+const OpOptions Operator = "$options"
+
+// regexCache holds the patterns of a query compiled, so each is compiled
+// once however many documents the query is matched against
+type regexCache struct {
+	mu       sync.Mutex
+	compiled map[[2]string]*regexp.Regexp // By pattern and options
+}
+
+// compileRegex compiles a $regex pattern with its $options: i (case
+// insensitive), m (^ and $ match at line breaks) and s (. matches newlines)
+func compileRegex(pattern, options string) (*regexp.Regexp, error) {
+	for _, flag := range options {
+		if !strings.ContainsRune("ims", flag) {
+			return nil, fmt.Errorf("unsupported $regex option %q", flag)
+		}
+	}
+	if options != "" {
+		pattern = "(?" + options + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern: %w", err)
+	}
+	return re, nil
+}
+
+// regex returns the compiled form of a $regex pattern and options,
+// compiling it on first use
+func (q *Query) regex(pattern, options string) (*regexp.Regexp, error) {
+	if q.regexes == nil {
+		return compileRegex(pattern, options)
+	}
+
+	q.regexes.mu.Lock()
+	defer q.regexes.mu.Unlock()
+
+	key := [2]string{pattern, options}
+	if re, ok := q.regexes.compiled[key]; ok {
+		return re, nil
+	}
+	re, err := compileRegex(pattern, options)
+	if err != nil {
+		return nil, err
+	}
+	q.regexes.compiled[key] = re
+	return re, nil
+}
+
+// regexOperands reads the pattern and options of the $regex in an operator
+// object
+func regexOperands(operatorMap map[string]interface{}) (string, string, error) {
+	pattern, ok := operatorMap[string(OpRegex)].(string)
+	if !ok {
+		return "", "", fmt.Errorf("regex pattern must be a string")
+	}
+	options := ""
+	if v, exists := operatorMap[string(OpOptions)]; exists {
+		if options, ok = v.(string); !ok {
+			return "", "", fmt.Errorf("$options must be a string")
+		}
+	}
+	return pattern, options, nil
+}
+
+// matchRegex evaluates the $regex of an operator object against a field
+// value. Only strings can match.
+func (q *Query) matchRegex(fieldValue interface{}, operatorMap map[string]interface{}) (bool, error) {
+	pattern, options, err := regexOperands(operatorMap)
+	if err != nil {
+		return false, err
+	}
+	re, err := q.regex(pattern, options)
+	if err != nil {
+		return false, err
+	}
+	str, ok := fieldValue.(string)
+	return ok && re.MatchString(str), nil
+}
+
+// regexPrefix returns the literal text every string matched by a $regex
+// starts with, when the pattern is anchored at the start of the string (as
+// in "^abc"). Case insensitive and multi-line patterns have no such prefix.
+func regexPrefix(operatorMap map[string]interface{}) (string, bool) {
+	pattern, options, err := regexOperands(operatorMap)
+	if err != nil || strings.ContainsAny(options, "im") {
+		return "", false
+	}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+
+	subs := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		subs = re.Sub
+	}
+	if len(subs) == 0 || subs[0].Op != syntax.OpBeginText {
+		return "", false
+	}
+
+	var prefix strings.Builder
+	for _, sub := range subs[1:] {
+		if sub.Op != syntax.OpLiteral || sub.Flags&syntax.FoldCase != 0 {
+			break
+		}
+		prefix.WriteString(string(sub.Rune))
+	}
+	return prefix.String(), prefix.Len() > 0
+}
+
+// prefixRange returns the index range holding every string starting with
+// prefix. No UTF-8 string has the byte 0xff, so the end bound is past all
+// of them.
+func prefixRange(prefix string) (interface{}, interface{}) {
+	return prefix, prefix + "\xff"
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/index"
 )
 
 func TestRegexOperator(t *testing.T) {
@@ -328,3 +329,140 @@ func TestRegexAlternation(t *testing.T) {
 		t.Error("Should not match: status is 'inactive'")
 	}
 }
+
+func TestRegexOptions(t *testing.T) {
+	doc := document.NewDocument()
+	doc.Set("name", "Alice Johnson")
+	doc.Set("bio", "first line\nsecond line")
+
+	tests := []struct {
+		name   string
+		field  string
+		filter map[string]interface{}
+		want   bool
+	}{
+		{"case sensitive", "name", map[string]interface{}{"$regex": "^alice"}, false},
+		{"case insensitive", "name", map[string]interface{}{"$regex": "^alice", "$options": "i"}, true},
+		{"single line anchor", "bio", map[string]interface{}{"$regex": "^second"}, false},
+		{"multi-line anchor", "bio", map[string]interface{}{"$regex": "^second", "$options": "m"}, true},
+		{"dot stops at newline", "bio", map[string]interface{}{"$regex": "line.second"}, false},
+		{"dot matches newline", "bio", map[string]interface{}{"$regex": "line.second", "$options": "s"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQuery(map[string]interface{}{tt.field: tt.filter})
+			// Match twice to go through the compiled pattern cache
+			for i := 0; i < 2; i++ {
+				matches, err := q.Matches(doc)
+				if err != nil {
+					t.Fatalf("Query failed: %v", err)
+				}
+				if matches != tt.want {
+					t.Errorf("Expected match %v, got %v", tt.want, matches)
+				}
+			}
+		})
+	}
+}
+
+func TestRegexNonString(t *testing.T) {
+	doc := document.NewDocument()
+	doc.Set("code", int64(123))
+	doc.Set("tags", []interface{}{"123"})
+
+	for _, field := range []string{"code", "tags"} {
+		q := NewQuery(map[string]interface{}{
+			field: map[string]interface{}{"$regex": "123"},
+		})
+		matches, err := q.Matches(doc)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if matches {
+			t.Errorf("Non-string field %s should not match", field)
+		}
+	}
+}
+
+func TestRegexInvalid(t *testing.T) {
+	doc := document.NewDocument()
+	doc.Set("name", "Alice")
+
+	filters := []map[string]interface{}{
+		{"$regex": "^A", "$options": "x"},
+		{"$regex": "^A", "$options": 1},
+		{"$regex": "(unclosed"},
+		{"$options": "i"},
+	}
+	for _, filter := range filters {
+		q := NewQuery(map[string]interface{}{"name": filter})
+		if _, err := q.Matches(doc); err == nil {
+			t.Errorf("Expected error for %v", filter)
+		}
+	}
+}
+
+func TestRegexPrefix(t *testing.T) {
+	tests := []struct {
+		pattern string
+		options string
+		prefix  string
+		ok      bool
+	}{
+		{"^abc", "", "abc", true},
+		{"^abc.*", "", "abc", true},
+		{"^ab[cd]", "", "ab", true},
+		{"^abc", "s", "abc", true},
+		{"\\Aabc", "", "abc", true},
+		{"abc", "", "", false},
+		{"^abc", "i", "", false},
+		{"^abc", "m", "", false},
+		{"^(?i)abc", "", "", false},
+		{"^abc|^abd", "", "", false},
+		{"^.*", "", "", false},
+	}
+	for _, tt := range tests {
+		operatorMap := map[string]interface{}{"$regex": tt.pattern}
+		if tt.options != "" {
+			operatorMap["$options"] = tt.options
+		}
+		prefix, ok := regexPrefix(operatorMap)
+		if prefix != tt.prefix || ok != tt.ok {
+			t.Errorf("regexPrefix(%q, %q) = %q, %v; want %q, %v", tt.pattern, tt.options, prefix, ok, tt.prefix, tt.ok)
+		}
+	}
+}
+
+func TestQueryPlannerRegexPrefix(t *testing.T) {
+	idx := index.NewIndex(&index.IndexConfig{
+		Name:      "sku_1",
+		FieldPath: "sku",
+		Type:      index.IndexTypeBTree,
+		Order:     32,
+	})
+	planner := NewQueryPlanner(map[string]*index.Index{"sku_1": idx})
+
+	plan := planner.Plan(NewQuery(map[string]interface{}{
+		"sku": map[string]interface{}{"$regex": "^abc"},
+	}))
+	if !plan.UseIndex || plan.ScanType != ScanTypeIndexRange {
+		t.Fatalf("Expected index range scan for prefix regex, got %+v", plan)
+	}
+	if plan.ScanStart != "abc" || plan.ScanEnd != "abc\xff" {
+		t.Errorf("Expected range [abc, abc\\xff], got [%v, %v]", plan.ScanStart, plan.ScanEnd)
+	}
+
+	plan = planner.Plan(NewQuery(map[string]interface{}{
+		"sku": map[string]interface{}{"$regex": "^abc", "$options": "i"},
+	}))
+	if plan.UseIndex {
+		t.Error("Case insensitive regex should not use the index")
+	}
+
+	plan = planner.Plan(NewQuery(map[string]interface{}{
+		"sku": map[string]interface{}{"$regex": "abc"},
+	}))
+	if plan.UseIndex {
+		t.Error("Unanchored regex should not use the index")
+	}
+}
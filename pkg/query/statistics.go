@@ -0,0 +1,246 @@
+package query
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+const (
+	// HistogramBuckets is the number of equi-depth buckets kept per field
+	HistogramBuckets = 32
+
+	// mostCommonValues is the number of frequent values kept per field
+	mostCommonValues = 16
+
+	// Selectivities assumed for fields without statistics
+	defaultEqualitySelectivity = 0.05
+	defaultRangeSelectivity    = 0.3
+)
+
+// CollectionStatistics describes the values of a collection's fields,
+// estimated from a sample of its documents. The planner uses them to
+// estimate how many documents each candidate plan examines.
+type CollectionStatistics struct {
+	TotalDocuments int
+	SampleSize     int
+	Fields         map[string]*FieldStatistics
+	UpdatedAt      time.Time
+}
+
+// FieldStatistics describes the sampled values of one field
+type FieldStatistics struct {
+	// Present is the share of documents that have the field
+	Present float64
+	// Distinct estimates the number of distinct values in the collection
+	Distinct int
+	// MostCommon holds the values seen more than once in the sample, most
+	// frequent first, with the share of documents holding each
+	MostCommon []ValueFrequency
+	// Bounds are the boundaries of an equi-depth histogram: each of the
+	// len(Bounds)-1 buckets holds the same share of the values present
+	Bounds []interface{}
+}
+
+// ValueFrequency is the share of documents holding a value
+type ValueFrequency struct {
+	Value     interface{}
+	Frequency float64
+}
+
+// BuildCollectionStatistics builds statistics for fields from a sample of
+// the total documents of a collection
+func BuildCollectionStatistics(total int, sample []*document.Document, fields []string) *CollectionStatistics {
+	stats := &CollectionStatistics{
+		TotalDocuments: total,
+		SampleSize:     len(sample),
+		Fields:         make(map[string]*FieldStatistics, len(fields)),
+		UpdatedAt:      time.Now(),
+	}
+	if len(sample) == 0 {
+		return stats
+	}
+
+	for _, field := range fields {
+		values := make([]interface{}, 0, len(sample))
+		for _, doc := range sample {
+			if value, exists := doc.Get(field); exists {
+				values = append(values, value)
+			}
+		}
+		stats.Fields[field] = buildFieldStatistics(values, len(sample), total)
+	}
+	return stats
+}
+
+// buildFieldStatistics summarizes the values of a field found in a sample
+// of n of the total documents
+func buildFieldStatistics(values []interface{}, n, total int) *FieldStatistics {
+	fs := &FieldStatistics{Present: float64(len(values)) / float64(n)}
+	if len(values) == 0 {
+		return fs
+	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		return document.CompareValues(values[i], values[j]) < 0
+	})
+
+	// Count runs of equal values
+	distinct, singletons := 0, 0
+	var common []ValueFrequency
+	for start := 0; start < len(values); {
+		end := start + 1
+		for end < len(values) && document.CompareValues(values[start], values[end]) == 0 {
+			end++
+		}
+		distinct++
+		if end-start == 1 {
+			singletons++
+		} else {
+			common = append(common, ValueFrequency{
+				Value:     values[start],
+				Frequency: float64(end-start) / float64(n),
+			})
+		}
+		start = end
+	}
+
+	sort.SliceStable(common, func(i, j int) bool {
+		return common[i].Frequency > common[j].Frequency
+	})
+	if len(common) > mostCommonValues {
+		common = common[:mostCommonValues]
+	}
+	fs.MostCommon = common
+	fs.Distinct = estimateDistinct(len(values), distinct, singletons, total)
+
+	buckets := HistogramBuckets
+	if buckets > len(values) {
+		buckets = len(values)
+	}
+	fs.Bounds = make([]interface{}, buckets+1)
+	for i := 0; i < buckets; i++ {
+		fs.Bounds[i] = values[i*len(values)/buckets]
+	}
+	fs.Bounds[buckets] = values[len(values)-1]
+	return fs
+}
+
+// estimateDistinct scales the distinct values of a sample of n values up to
+// the collection with the Duj1 estimator: values seen once in the sample
+// suggest more that weren't sampled
+func estimateDistinct(n, distinct, singletons, total int) int {
+	if n >= total || total == 0 {
+		return distinct
+	}
+	estimate := float64(n*distinct) / (float64(n-singletons) + float64(singletons*n)/float64(total))
+	if estimate > float64(total) {
+		estimate = float64(total)
+	}
+	if estimate < float64(distinct) {
+		estimate = float64(distinct)
+	}
+	return int(estimate)
+}
+
+// EqualitySelectivity estimates the share of documents whose field equals
+// value
+func (s *CollectionStatistics) EqualitySelectivity(field string, value interface{}) float64 {
+	fs, ok := s.Fields[field]
+	if !ok {
+		return defaultEqualitySelectivity
+	}
+
+	rest := fs.Present
+	for _, common := range fs.MostCommon {
+		if document.CompareValues(common.Value, value) == 0 {
+			return common.Frequency
+		}
+		rest -= common.Frequency
+	}
+
+	// Values not seen more than once share what the common values leave
+	others := fs.Distinct - len(fs.MostCommon)
+	if others < 1 {
+		others = 1
+	}
+	selectivity := rest / float64(others)
+	if minimum := 1 / float64(s.TotalDocuments+1); selectivity < minimum {
+		selectivity = minimum
+	}
+	return selectivity
+}
+
+// RangeSelectivity estimates the share of documents whose field is between
+// start and end. A nil bound leaves that side of the range open.
+func (s *CollectionStatistics) RangeSelectivity(field string, start, end interface{}) float64 {
+	fs, ok := s.Fields[field]
+	if !ok {
+		return defaultRangeSelectivity
+	}
+	if len(fs.Bounds) == 0 {
+		return 0
+	}
+
+	low, high := 0.0, 1.0
+	if start != nil {
+		low = fs.rank(start)
+	}
+	if end != nil {
+		high = fs.rank(end)
+	}
+	if high < low {
+		return 0
+	}
+	return (high - low) * fs.Present
+}
+
+// rank estimates the share of the values present that sort before value,
+// interpolating numbers within their bucket
+func (fs *FieldStatistics) rank(value interface{}) float64 {
+	buckets := len(fs.Bounds) - 1
+	if buckets == 0 || document.CompareValues(value, fs.Bounds[0]) <= 0 {
+		return 0
+	}
+	if document.CompareValues(value, fs.Bounds[buckets]) > 0 {
+		return 1
+	}
+
+	// First bucket whose upper bound is at least value
+	b := sort.Search(buckets, func(i int) bool {
+		return document.CompareValues(fs.Bounds[i+1], value) >= 0
+	})
+
+	within := 0.5
+	lower, lowOk := document.ToNumber(fs.Bounds[b])
+	upper, highOk := document.ToNumber(fs.Bounds[b+1])
+	v, ok := document.ToNumber(value)
+	if lowOk && highOk && ok && upper > lower {
+		within = (v - lower) / (upper - lower)
+	}
+	return (float64(b) + within) / float64(buckets)
+}
+
+// ToMap converts the statistics to a map for display
+func (s *CollectionStatistics) ToMap() map[string]interface{} {
+	fields := make(map[string]interface{}, len(s.Fields))
+	for name, fs := range s.Fields {
+		common := make([]map[string]interface{}, len(fs.MostCommon))
+		for i, vf := range fs.MostCommon {
+			common[i] = map[string]interface{}{"value": vf.Value, "frequency": vf.Frequency}
+		}
+		fields[name] = map[string]interface{}{
+			"present":    fs.Present,
+			"distinct":   fs.Distinct,
+			"mostCommon": common,
+			"histogram":  fs.Bounds,
+		}
+	}
+	return map[string]interface{}{
+		"totalDocuments": s.TotalDocuments,
+		"sampleSize":     s.SampleSize,
+		"updatedAt":      s.UpdatedAt,
+		"fields":         fields,
+	}
+}
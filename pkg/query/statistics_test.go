@@ -0,0 +1,200 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/index"
+)
+
+// skewedUsers returns n users: one in 200 is banned, cities are uniform over
+// 50 values and ages over 20..69
+func skewedUsers(n int) []*document.Document {
+	docs := make([]*document.Document, n)
+	for i := 0; i < n; i++ {
+		status := "active"
+		if i%200 == 0 {
+			status = "banned"
+		}
+		docs[i] = document.NewDocumentFromMap(map[string]interface{}{
+			"_id":    fmt.Sprintf("u%d", i),
+			"status": status,
+			"city":   fmt.Sprintf("city%d", i%50),
+			"age":    int64(20 + i%50),
+		})
+	}
+	return docs
+}
+
+// indexUsers indexes docs on each field, analyzing the indexes
+func indexUsers(docs []*document.Document, fields ...string) map[string]*index.Index {
+	indexes := make(map[string]*index.Index)
+	for _, field := range fields {
+		idx := index.NewIndex(&index.IndexConfig{
+			Name:      field + "_1",
+			FieldPath: field,
+			Type:      index.IndexTypeBTree,
+			Order:     32,
+		})
+		for _, doc := range docs {
+			value, _ := doc.Get(field)
+			id, _ := doc.Get("_id")
+			idx.Insert(value, id.(string))
+		}
+		idx.Analyze()
+		indexes[idx.Name()] = idx
+	}
+	return indexes
+}
+
+func TestCollectionStatisticsSelectivity(t *testing.T) {
+	docs := skewedUsers(2000)
+	stats := BuildCollectionStatistics(len(docs), docs, []string{"status", "city", "age", "missing"})
+
+	near := func(name string, got, want, tolerance float64) {
+		t.Helper()
+		if math.Abs(got-want) > tolerance {
+			t.Errorf("%s: expected about %.4f, got %.4f", name, want, got)
+		}
+	}
+
+	near("status=active", stats.EqualitySelectivity("status", "active"), 0.995, 0.001)
+	near("status=banned", stats.EqualitySelectivity("status", "banned"), 0.005, 0.001)
+	near("city=city7", stats.EqualitySelectivity("city", "city7"), 0.02, 0.001)
+	near("age 30..40", stats.RangeSelectivity("age", int64(30), int64(40)), 0.2, 0.04)
+	near("age >= 60", stats.RangeSelectivity("age", int64(60), nil), 0.2, 0.04)
+	near("age < 20", stats.RangeSelectivity("age", nil, int64(20)), 0, 0.001)
+
+	if got := stats.Fields["city"].Distinct; got != 50 {
+		t.Errorf("Expected 50 distinct cities, got %d", got)
+	}
+	if got := stats.Fields["missing"].Present; got != 0 {
+		t.Errorf("Expected missing field to be absent, got %v", got)
+	}
+	if got := stats.EqualitySelectivity("missing", "x"); got > 0.001 {
+		t.Errorf("Expected a field no document has to match nothing, got %v", got)
+	}
+	if got := stats.EqualitySelectivity("unknown", "x"); got != defaultEqualitySelectivity {
+		t.Errorf("Expected default selectivity for a field without statistics, got %v", got)
+	}
+}
+
+func TestCollectionStatisticsSampledDistinct(t *testing.T) {
+	// Unique values: a sample of 500 of 10000 documents should not
+	// conclude there are only 500 distinct values
+	docs := make([]*document.Document, 500)
+	for i := range docs {
+		docs[i] = document.NewDocumentFromMap(map[string]interface{}{"email": fmt.Sprintf("user%d@example.com", i*20)})
+	}
+	stats := BuildCollectionStatistics(10000, docs, []string{"email"})
+	if got := stats.Fields["email"].Distinct; got != 10000 {
+		t.Errorf("Expected 10000 distinct emails, got %d", got)
+	}
+	if got := stats.EqualitySelectivity("email", "user3@example.com"); got > 0.0002 {
+		t.Errorf("Expected about 1/10000, got %v", got)
+	}
+}
+
+func TestPlannerCostBasedSelection(t *testing.T) {
+	docs := skewedUsers(2000)
+	indexes := indexUsers(docs, "status", "city", "age")
+	stats := BuildCollectionStatistics(len(docs), docs, []string{"status", "city", "age"})
+
+	q := NewQuery(map[string]interface{}{"status": "banned", "city": "city0"})
+
+	// Index statistics only count distinct keys, so the city index wins
+	if plan := NewQueryPlanner(indexes).Plan(q); plan.IndexName != "city_1" {
+		t.Fatalf("Expected index statistics to pick city_1, got %s", plan.IndexName)
+	}
+
+	// Only 10 documents are banned, against 40 in any city. Intersecting
+	// both indexes fetches fewer documents still.
+	plan := NewQueryPlanner(indexes).WithStatistics(stats).Plan(q)
+	if !plan.UseIntersection {
+		t.Fatalf("Expected an intersection, got %+v", plan.Explain())
+	}
+	costs := make(map[string]PlanCandidate)
+	for _, c := range plan.Candidates {
+		costs[c.Name] = c
+	}
+	if costs["status_1"].EstimatedDocs != 10 || costs["city_1"].EstimatedDocs != 40 {
+		t.Errorf("Expected 10 and 40 documents examined, got %v", plan.Candidates)
+	}
+	if costs["status_1"].EstimatedCost >= costs["city_1"].EstimatedCost {
+		t.Errorf("Expected status_1 to cost less than city_1, got %v", plan.Candidates)
+	}
+
+	// $in conditions aren't intersected, so the status index wins alone
+	plan = NewQueryPlanner(indexes).WithStatistics(stats).Plan(NewQuery(map[string]interface{}{
+		"status": "banned",
+		"city":   map[string]interface{}{"$in": []interface{}{"city0", "city1"}},
+	}))
+	if plan.IndexName != "status_1" {
+		t.Errorf("Expected status_1, got %+v", plan.Explain())
+	}
+
+	// A status matching nearly everything is worse than scanning
+	plan = NewQueryPlanner(indexes).WithStatistics(stats).Plan(NewQuery(map[string]interface{}{"status": "active"}))
+	if plan.UseIndex {
+		t.Errorf("Expected a collection scan, got %s", plan.IndexName)
+	}
+
+	// A narrow range beats a common value
+	plan = NewQueryPlanner(indexes).WithStatistics(stats).Plan(NewQuery(map[string]interface{}{
+		"status": "active",
+		"age":    map[string]interface{}{"$gte": int64(68)},
+	}))
+	if plan.IndexName != "age_1" {
+		t.Errorf("Expected age_1, got %+v", plan.Explain())
+	}
+}
+
+func TestPlannerExplainCandidates(t *testing.T) {
+	docs := skewedUsers(2000)
+	indexes := indexUsers(docs, "status", "city")
+	stats := BuildCollectionStatistics(len(docs), docs, []string{"status", "city"})
+
+	plan := NewQueryPlanner(indexes).WithStatistics(stats).Plan(NewQuery(map[string]interface{}{
+		"status": "banned",
+		"city":   "city0",
+	}))
+	explain := plan.Explain()
+
+	if explain["costModel"] != "collection statistics" {
+		t.Errorf("Expected collection statistics cost model, got %v", explain["costModel"])
+	}
+	if explain["estimatedDocsExamined"] != plan.EstimatedDocs {
+		t.Errorf("Expected %d documents examined, got %v", plan.EstimatedDocs, explain["estimatedDocsExamined"])
+	}
+
+	candidates := explain["candidatePlans"].([]map[string]interface{})
+	names := make(map[string]bool)
+	for i, c := range candidates {
+		names[c["plan"].(string)] = true
+		if i > 0 && c["estimatedCost"].(int) < candidates[i-1]["estimatedCost"].(int) {
+			t.Errorf("Expected candidates cheapest first, got %v", candidates)
+		}
+		if _, ok := c["estimatedDocsExamined"]; !ok {
+			t.Errorf("Expected documents examined for %v", c["plan"])
+		}
+	}
+	for _, name := range []string{"COLLECTION_SCAN", "status_1", "city_1"} {
+		if !names[name] {
+			t.Errorf("Expected candidate %s, got %v", name, candidates)
+		}
+	}
+	if candidates[len(candidates)-1]["plan"] != "COLLECTION_SCAN" {
+		t.Errorf("Expected the collection scan to be the most expensive, got %v", candidates)
+	}
+
+	// Without collection statistics the heuristic costs are shown
+	explain = NewQueryPlanner(indexes).Plan(NewQuery(map[string]interface{}{"status": "banned"})).Explain()
+	if explain["costModel"] != "index statistics" {
+		t.Errorf("Expected index statistics cost model, got %v", explain["costModel"])
+	}
+	if _, ok := explain["estimatedDocsExamined"]; ok {
+		t.Error("Expected no document estimate without collection statistics")
+	}
+}
@@ -0,0 +1,53 @@
+package query
+
+import (
+	"fmt"
+
+	"github.com/mnohosten/laura-db/pkg/document"
+)
+
+// TextScoreField holds the relevance score of documents matched by $text
+// while a query executes. It is removed from results and only exposed
+// through a "textScore" metadata projection.
+const TextScoreField = "_textScore"
+
+// MetaTextScore is the metadata projection for the $text relevance score
+const MetaTextScore = "textScore"
+
+// HasTextSearch reports whether the query filter contains $text
+func (q *Query) HasTextSearch() bool {
+	_, ok := q.filter[string(OpText)]
+	return ok
+}
+
+// TextSearch returns the search string of the query's $text condition,
+// e.g. {"$text": {"$search": "coffee shop"}}
+func (q *Query) TextSearch() (string, error) {
+	value, ok := q.filter[string(OpText)]
+	if !ok {
+		return "", fmt.Errorf("query has no $text condition")
+	}
+
+	textMap, ok := value.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("$text requires a document")
+	}
+	search, ok := textMap["$search"].(string)
+	if !ok {
+		return "", fmt.Errorf("$text requires a $search string")
+	}
+	return search, nil
+}
+
+// applyTextScore removes the internal score field from a result and adds
+// any requested textScore metadata fields
+func (q *Query) applyTextScore(doc *document.Document, score interface{}) *document.Document {
+	result := document.NewDocumentFromMap(doc.ToMap())
+	result.Delete(TextScoreField)
+	for field, meta := range q.meta {
+		if meta == MetaTextScore {
+			result.Set(field, score)
+		}
+	}
+	return result
+}
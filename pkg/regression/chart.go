@@ -0,0 +1,243 @@
+package regression
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sparkTicks are the glyphs used to render a sparkline, lowest to highest
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// TrendChart is a chronologically ordered series for a single benchmark,
+// annotated with the point where cumulative drift crossed a threshold
+type TrendChart struct {
+	BenchmarkName string
+	Timestamps    []time.Time
+	Values        []float64 // ns/op, oldest first
+
+	// CrossingIndex is the first index whose value exceeds the first point
+	// by more than the warning threshold, or -1 if no crossing occurred
+	CrossingIndex int
+	// CrossingSeverity is the severity at the crossing point
+	CrossingSeverity Severity
+}
+
+// BuildTrendChart converts results (as returned by GetTrend, newest first)
+// into a chart series ordered oldest to newest and locates the first point
+// where the metric drifted past the thresholds relative to the oldest run.
+//
+// Comparing against the oldest point rather than the previous one catches
+// slow drift that never trips the per-commit regression check.
+func BuildTrendChart(name string, results []*BenchmarkResult, thresholds *Thresholds) *TrendChart {
+	if thresholds == nil {
+		thresholds = DefaultThresholds()
+	}
+
+	ordered := make([]*BenchmarkResult, len(results))
+	copy(ordered, results)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Timestamp.Before(ordered[j].Timestamp)
+	})
+
+	chart := &TrendChart{
+		BenchmarkName: name,
+		Timestamps:    make([]time.Time, len(ordered)),
+		Values:        make([]float64, len(ordered)),
+		CrossingIndex: -1,
+	}
+
+	for i, r := range ordered {
+		chart.Timestamps[i] = r.Timestamp
+		chart.Values[i] = r.NsPerOp
+	}
+
+	if len(chart.Values) < 2 || chart.Values[0] <= 0 {
+		return chart
+	}
+
+	base := chart.Values[0]
+	for i := 1; i < len(chart.Values); i++ {
+		percentChange := ((chart.Values[i] - base) / base) * 100
+		if percentChange > thresholds.TimeRegressionWarning {
+			chart.CrossingIndex = i
+			chart.CrossingSeverity = SeverityWarning
+			if percentChange > thresholds.TimeRegressionCritical {
+				chart.CrossingSeverity = SeverityCritical
+			}
+			break
+		}
+	}
+
+	return chart
+}
+
+// Sparkline renders a series of values as a single line of block glyphs
+func Sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	minVal, maxVal := values[0], values[0]
+	for _, v := range values {
+		minVal = math.Min(minVal, v)
+		maxVal = math.Max(maxVal, v)
+	}
+
+	var sb strings.Builder
+	span := maxVal - minVal
+	for _, v := range values {
+		idx := 0
+		if span > 0 {
+			idx = int(math.Round((v - minVal) / span * float64(len(sparkTicks)-1)))
+		}
+		sb.WriteRune(sparkTicks[idx])
+	}
+
+	return sb.String()
+}
+
+// GenerateSparklineReport renders one ASCII sparkline per benchmark, with a
+// marker under the point where the regression threshold was first crossed
+func GenerateSparklineReport(w io.Writer, trends map[string][]*BenchmarkResult, thresholds *Thresholds) error {
+	fmt.Fprintln(w, "═══════════════════════════════════════════════════════════════════════")
+	fmt.Fprintln(w, "                    PERFORMANCE TREND SPARKLINES")
+	fmt.Fprintln(w, "═══════════════════════════════════════════════════════════════════════")
+	fmt.Fprintln(w)
+
+	names := sortedTrendNames(trends)
+	nameWidth := 0
+	for _, name := range names {
+		if l := len(truncate(name, 40)); l > nameWidth {
+			nameWidth = l
+		}
+	}
+
+	for _, name := range names {
+		chart := BuildTrendChart(name, trends[name], thresholds)
+		if len(chart.Values) == 0 {
+			continue
+		}
+
+		first := chart.Values[0]
+		last := chart.Values[len(chart.Values)-1]
+		change := 0.0
+		if first > 0 {
+			change = ((last - first) / first) * 100
+		}
+
+		fmt.Fprintf(w, "%-*s  %s  %.0f → %.0f ns/op (%+.1f%%)\n",
+			nameWidth, truncate(name, 40), Sparkline(chart.Values), first, last, change)
+
+		if chart.CrossingIndex >= 0 {
+			fmt.Fprintf(w, "%-*s  %s^ %s at %s\n",
+				nameWidth, "",
+				strings.Repeat(" ", chart.CrossingIndex),
+				chart.CrossingSeverity,
+				chart.Timestamps[chart.CrossingIndex].Format("2006-01-02 15:04"),
+			)
+		}
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "═══════════════════════════════════════════════════════════════════════")
+
+	return nil
+}
+
+// GenerateHTMLTrendReport renders a standalone HTML page with one SVG line
+// chart per benchmark; threshold crossings are highlighted in red
+func GenerateHTMLTrendReport(w io.Writer, trends map[string][]*BenchmarkResult, thresholds *Thresholds) error {
+	const (
+		width   = 600
+		height  = 120
+		padding = 10
+	)
+
+	fmt.Fprintln(w, "<!DOCTYPE html>")
+	fmt.Fprintln(w, "<html><head><meta charset=\"utf-8\"><title>LauraDB Performance Trends</title>")
+	fmt.Fprintln(w, "<style>body{font-family:sans-serif;margin:2em}h2{font-size:1em;margin-bottom:0.2em}svg{background:#fafafa;border:1px solid #ddd}</style>")
+	fmt.Fprintln(w, "</head><body>")
+	fmt.Fprintln(w, "<h1>Performance Trends</h1>")
+	fmt.Fprintf(w, "<p>Generated: %s</p>\n", time.Now().Format("2006-01-02 15:04:05"))
+
+	for _, name := range sortedTrendNames(trends) {
+		chart := BuildTrendChart(name, trends[name], thresholds)
+		if len(chart.Values) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "<h2>%s</h2>\n", html.EscapeString(name))
+		fmt.Fprintf(w, "<svg width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", width, height, width, height)
+
+		points := chartPoints(chart.Values, width, height, padding)
+		coords := make([]string, len(points))
+		for i, p := range points {
+			coords[i] = fmt.Sprintf("%.1f,%.1f", p[0], p[1])
+		}
+		fmt.Fprintf(w, "  <polyline fill=\"none\" stroke=\"#36c\" stroke-width=\"2\" points=\"%s\"/>\n", strings.Join(coords, " "))
+
+		for i, p := range points {
+			color := "#36c"
+			radius := 2.5
+			if i == chart.CrossingIndex {
+				color = "#d33"
+				radius = 5
+			}
+			fmt.Fprintf(w, "  <circle cx=\"%.1f\" cy=\"%.1f\" r=\"%.1f\" fill=\"%s\"><title>%s: %.0f ns/op</title></circle>\n",
+				p[0], p[1], radius, color, chart.Timestamps[i].Format("2006-01-02 15:04"), chart.Values[i])
+		}
+
+		fmt.Fprintln(w, "</svg>")
+
+		if chart.CrossingIndex >= 0 {
+			fmt.Fprintf(w, "<p style=\"color:#d33\">%s threshold crossed at %s</p>\n",
+				chart.CrossingSeverity, chart.Timestamps[chart.CrossingIndex].Format("2006-01-02 15:04"))
+		}
+	}
+
+	fmt.Fprintln(w, "</body></html>")
+
+	return nil
+}
+
+// chartPoints scales values into SVG coordinates (y grows downwards)
+func chartPoints(values []float64, width, height, padding int) [][2]float64 {
+	minVal, maxVal := values[0], values[0]
+	for _, v := range values {
+		minVal = math.Min(minVal, v)
+		maxVal = math.Max(maxVal, v)
+	}
+
+	plotW := float64(width - 2*padding)
+	plotH := float64(height - 2*padding)
+	span := maxVal - minVal
+
+	points := make([][2]float64, len(values))
+	for i, v := range values {
+		x := float64(padding)
+		if len(values) > 1 {
+			x += plotW * float64(i) / float64(len(values)-1)
+		}
+		y := float64(padding) + plotH/2
+		if span > 0 {
+			y = float64(padding) + plotH*(1-(v-minVal)/span)
+		}
+		points[i] = [2]float64{x, y}
+	}
+
+	return points
+}
+
+func sortedTrendNames(trends map[string][]*BenchmarkResult) []string {
+	names := make([]string, 0, len(trends))
+	for name := range trends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
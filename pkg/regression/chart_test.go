@@ -0,0 +1,112 @@
+package regression
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func makeTrend(name string, values ...float64) []*BenchmarkResult {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	results := make([]*BenchmarkResult, len(values))
+	// GetTrend returns newest first
+	for i, v := range values {
+		results[len(values)-1-i] = &BenchmarkResult{
+			Name:      name,
+			NsPerOp:   v,
+			Timestamp: start.Add(time.Duration(i) * time.Hour),
+		}
+	}
+	return results
+}
+
+func TestSparkline(t *testing.T) {
+	if got := Sparkline(nil); got != "" {
+		t.Errorf("Expected empty sparkline, got %q", got)
+	}
+
+	got := Sparkline([]float64{1, 2, 3, 4, 5, 6, 7, 8})
+	if got != "▁▂▃▄▅▆▇█" {
+		t.Errorf("Expected full ramp, got %q", got)
+	}
+
+	// Flat series renders at the lowest tick
+	got = Sparkline([]float64{5, 5, 5})
+	if got != "▁▁▁" {
+		t.Errorf("Expected flat sparkline, got %q", got)
+	}
+}
+
+func TestBuildTrendChart_OrdersOldestFirst(t *testing.T) {
+	chart := BuildTrendChart("BenchmarkA", makeTrend("BenchmarkA", 100, 101, 102), nil)
+
+	if len(chart.Values) != 3 {
+		t.Fatalf("Expected 3 values, got %d", len(chart.Values))
+	}
+	if chart.Values[0] != 100 || chart.Values[2] != 102 {
+		t.Errorf("Expected values ordered oldest first, got %v", chart.Values)
+	}
+	if chart.CrossingIndex != -1 {
+		t.Errorf("Expected no crossing, got %d", chart.CrossingIndex)
+	}
+}
+
+func TestBuildTrendChart_DetectsSlowDrift(t *testing.T) {
+	// Each step is ~4%, below the per-commit threshold, but cumulative
+	// drift crosses 10% at index 3 and 25% at index 6
+	values := []float64{100, 104, 108, 112, 117, 121, 126}
+	chart := BuildTrendChart("BenchmarkDrift", makeTrend("BenchmarkDrift", values...), DefaultThresholds())
+
+	if chart.CrossingIndex != 3 {
+		t.Errorf("Expected crossing at index 3, got %d", chart.CrossingIndex)
+	}
+	if chart.CrossingSeverity != SeverityWarning {
+		t.Errorf("Expected warning severity, got %v", chart.CrossingSeverity)
+	}
+}
+
+func TestGenerateSparklineReport(t *testing.T) {
+	trends := map[string][]*BenchmarkResult{
+		"BenchmarkStable": makeTrend("BenchmarkStable", 100, 100, 101),
+		"BenchmarkJump":   makeTrend("BenchmarkJump", 100, 100, 150),
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateSparklineReport(&buf, trends, DefaultThresholds()); err != nil {
+		t.Fatalf("GenerateSparklineReport failed: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "BenchmarkStable") || !strings.Contains(out, "BenchmarkJump") {
+		t.Errorf("Expected both benchmarks in report:\n%s", out)
+	}
+	if strings.Count(out, "^ CRITICAL") != 1 {
+		t.Errorf("Expected a single critical marker:\n%s", out)
+	}
+	if !strings.Contains(out, "+50.0%") {
+		t.Errorf("Expected overall change for jump benchmark:\n%s", out)
+	}
+}
+
+func TestGenerateHTMLTrendReport(t *testing.T) {
+	trends := map[string][]*BenchmarkResult{
+		"Benchmark<Escape>": makeTrend("Benchmark<Escape>", 100, 130),
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateHTMLTrendReport(&buf, trends, nil); err != nil {
+		t.Fatalf("GenerateHTMLTrendReport failed: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "<svg") || !strings.Contains(out, "<polyline") {
+		t.Errorf("Expected SVG chart in output")
+	}
+	if !strings.Contains(out, "Benchmark&lt;Escape&gt;") {
+		t.Errorf("Expected benchmark name to be HTML-escaped")
+	}
+	if !strings.Contains(out, "fill=\"#d33\"") {
+		t.Errorf("Expected threshold crossing to be highlighted")
+	}
+}
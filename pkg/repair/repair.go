@@ -6,20 +6,27 @@ import (
 
 	"github.com/mnohosten/laura-db/pkg/database"
 	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/replication"
+	"github.com/mnohosten/laura-db/pkg/storage"
 )
 
 // IssueType represents the type of issue found during validation
 type IssueType string
 
 const (
-	IssueTypeMissingID           IssueType = "missing_id"
-	IssueTypeInvalidID           IssueType = "invalid_id"
-	IssueTypeOrphanedIndexEntry  IssueType = "orphaned_index_entry"
-	IssueTypeMissingIndexEntry   IssueType = "missing_index_entry"
-	IssueTypeDuplicateUnique     IssueType = "duplicate_unique"
-	IssueTypeCorruptDocument     IssueType = "corrupt_document"
-	IssueTypeInvalidIndexOrder   IssueType = "invalid_index_order"
-	IssueTypeIndexFieldMismatch  IssueType = "index_field_mismatch"
+	IssueTypeMissingID          IssueType = "missing_id"
+	IssueTypeInvalidID          IssueType = "invalid_id"
+	IssueTypeOrphanedIndexEntry IssueType = "orphaned_index_entry"
+	IssueTypeMissingIndexEntry  IssueType = "missing_index_entry"
+	IssueTypeDuplicateUnique    IssueType = "duplicate_unique"
+	IssueTypeCorruptDocument    IssueType = "corrupt_document"
+	IssueTypeInvalidIndexOrder  IssueType = "invalid_index_order"
+	IssueTypeIndexFieldMismatch IssueType = "index_field_mismatch"
+	IssueTypeExcludedIndexEntry IssueType = "excluded_index_entry"
+	IssueTypeChecksumMismatch   IssueType = "checksum_mismatch"
+	IssueTypeUnusedIndex        IssueType = "unused_index"
+	IssueTypeIndexBuilding      IssueType = "index_building"
+	IssueTypeIndexBuildFailed   IssueType = "index_build_failed"
 )
 
 // Issue represents a problem found during validation
@@ -69,6 +76,14 @@ type RepairOptions struct {
 	// UniqueConflictResolution: "first", "last", "fail"
 	UniqueConflictResolution string
 
+	// RedoFromWAL will rewrite pages failing checksum verification from the
+	// last image of them in the write-ahead log, before quarantining
+	RedoFromWAL bool
+
+	// QuarantineCorruptPages will take pages failing checksum verification
+	// out of service and relocate or restore the documents stored on them
+	QuarantineCorruptPages bool
+
 	// DryRun will perform validation but not make changes
 	DryRun bool
 }
@@ -175,6 +190,77 @@ func (v *Validator) ValidateCollection(collectionName string) (*ValidationReport
 	return report, nil
 }
 
+// ValidatePages reads every data page and reports pages whose contents do
+// not match the checksum recorded by the disk manager
+func (v *Validator) ValidatePages() (*ValidationReport, error) {
+	report := &ValidationReport{
+		StartTime:   time.Now(),
+		Collections: v.db.ListCollections(),
+		Issues:      make([]Issue, 0),
+		IsHealthy:   true,
+	}
+
+	failures, err := v.db.VerifyPageChecksums()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, failure := range failures {
+		report.Issues = append(report.Issues, Issue{
+			Type:        IssueTypeChecksumMismatch,
+			Severity:    "critical",
+			Collection:  failure.Collection,
+			Description: fmt.Sprintf("Page %d failed checksum verification", failure.PageID),
+			Details: map[string]interface{}{
+				"page_id":      failure.PageID,
+				"expected":     fmt.Sprintf("%08x", failure.Expected),
+				"actual":       fmt.Sprintf("%08x", failure.Actual),
+				"document_ids": failure.DocumentIDs,
+			},
+		})
+		report.IsHealthy = false
+	}
+
+	report.EndTime = time.Now()
+	return report, nil
+}
+
+// ValidateIndexUsage reports indexes no query has used, as candidates for
+// removal. Indexes whose usage has been counted for less than minAge are
+// skipped, since they may simply not have been needed yet. The _id index is
+// never reported.
+func (v *Validator) ValidateIndexUsage(minAge time.Duration) (*ValidationReport, error) {
+	report := &ValidationReport{
+		StartTime:   time.Now(),
+		Collections: v.db.ListCollections(),
+		Issues:      make([]Issue, 0),
+		IsHealthy:   true,
+	}
+
+	for _, collName := range report.Collections {
+		for _, stats := range v.db.Collection(collName).IndexStats() {
+			report.IndexCount++
+			if stats.Name == "_id_" || stats.Ops > 0 || time.Since(stats.Since) < minAge {
+				continue
+			}
+			report.Issues = append(report.Issues, Issue{
+				Type:        IssueTypeUnusedIndex,
+				Severity:    "info",
+				Collection:  collName,
+				IndexName:   stats.Name,
+				Description: fmt.Sprintf("Index %s has not been used since %s", stats.Name, stats.Since.Format(time.RFC3339)),
+				Details: map[string]interface{}{
+					"type":  stats.Type,
+					"since": stats.Since,
+				},
+			})
+		}
+	}
+
+	report.EndTime = time.Now()
+	return report, nil
+}
+
 // validateDocuments checks document integrity
 func (v *Validator) validateDocuments(coll *database.Collection) ([]Issue, int) {
 	issues := make([]Issue, 0)
@@ -232,41 +318,206 @@ func (v *Validator) validateDocuments(coll *database.Collection) ([]Issue, int)
 func (v *Validator) validateIndexes(coll *database.Collection) ([]Issue, int) {
 	issues := make([]Issue, 0)
 
-	// Get all indexes (using ListIndexes which returns []map[string]interface{})
 	indexList := coll.ListIndexes()
 
-	// Note: With the current public API, we have limited access to index internals.
-	// We perform basic validation:
-	// 1. Check that indexes exist
-	// 2. Validate index list is accessible
+	docs, err := coll.Find(map[string]interface{}{})
+	if err != nil {
+		// Already reported by validateDocuments
+		return issues, len(indexList)
+	}
 
-	// For each index, try a simple query to ensure it's functional
 	for _, indexInfo := range indexList {
 		indexName, ok := indexInfo["name"].(string)
 		if !ok {
 			continue
 		}
 
-		// Skip validating the _id index (it's always present)
-		if indexName == "_id" {
+		// Skip the _id index (it's maintained by the document store itself)
+		if indexName == "_id" || indexName == "_id_" {
 			continue
 		}
 
-		// Basic validation: index exists and is accessible
-		// More detailed validation would require access to internal index structures
+		// Only ready indexes are expected to match the documents
+		switch indexInfo["build_state"] {
+		case "building":
+			issues = append(issues, Issue{
+				Type:        IssueTypeIndexBuilding,
+				Severity:    "info",
+				Collection:  coll.Name(),
+				IndexName:   indexName,
+				Description: fmt.Sprintf("Index %s is still being built and was not checked", indexName),
+				Details: map[string]interface{}{
+					"build_progress": indexInfo["build_progress"],
+				},
+			})
+			continue
+		case "failed":
+			issues = append(issues, Issue{
+				Type:        IssueTypeIndexBuildFailed,
+				Severity:    "critical",
+				Collection:  coll.Name(),
+				IndexName:   indexName,
+				Description: fmt.Sprintf("Index %s failed to build", indexName),
+				Details: map[string]interface{}{
+					"error": indexInfo["build_error"],
+				},
+			})
+			continue
+		}
+
+		// Only B+ tree indexes (single-field, compound, partial) expose entries;
+		// text, geo and TTL indexes are skipped
+		entries, err := coll.IndexEntries(indexName)
+		if err != nil {
+			continue
+		}
+
+		unique, _ := indexInfo["unique"].(bool)
+		issues = append(issues, v.validateIndexEntries(coll, indexName, unique, docs, entries)...)
 	}
 
 	return issues, len(indexList)
 }
 
-// Note: validateIndexEntries has been removed as it required access to
-// internal index structures which are not exposed through the public API.
-// Index validation is now simplified to basic checks.
+// validateIndexEntries cross-checks a single B+ tree index against the
+// collection's documents. Every document must appear under its expected key
+// (or be correctly excluded by a partial filter or missing field), every
+// entry must point at an existing document, and unique keys must not repeat.
+func (v *Validator) validateIndexEntries(coll *database.Collection, indexName string, unique bool, docs []*document.Document, entries []database.IndexEntry) []Issue {
+	issues := make([]Issue, 0)
+
+	// Index contents keyed by document id and by key
+	entryKeyByDoc := make(map[string]string, len(entries))
+	entryDocByKey := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		key := fmt.Sprintf("%v", entry.Key)
+		entryKeyByDoc[entry.DocumentID] = key
+		entryDocByKey[key] = entry.DocumentID
+	}
+
+	docsByID := make(map[string]*document.Document, len(docs))
+	ownerByKey := make(map[string]string)
+
+	for _, doc := range docs {
+		idVal, ok := doc.Get("_id")
+		if !ok {
+			continue // Reported by validateDocuments
+		}
+		docID := fmt.Sprintf("%v", idVal)
+		docsByID[docID] = doc
+
+		keyVal, included, err := coll.IndexKeyFor(indexName, doc)
+		if err != nil || !included {
+			continue
+		}
+		key := fmt.Sprintf("%v", keyVal)
+
+		if unique {
+			if owner, exists := ownerByKey[key]; exists {
+				issues = append(issues, Issue{
+					Type:        IssueTypeDuplicateUnique,
+					Severity:    "critical",
+					Collection:  coll.Name(),
+					DocumentID:  docID,
+					IndexName:   indexName,
+					Description: fmt.Sprintf("Unique index key %s is shared with document %s", key, owner),
+					Details: map[string]interface{}{
+						"key":           key,
+						"conflict_with": owner,
+					},
+				})
+				continue
+			}
+			ownerByKey[key] = docID
+		}
+
+		if entryKeyByDoc[docID] == key {
+			continue
+		}
+
+		// Non-unique B+ tree indexes hold a single document per key, so a key
+		// owned by another document is not a consistency violation
+		if owner, exists := entryDocByKey[key]; exists && owner != docID && !unique {
+			continue
+		}
+
+		issues = append(issues, Issue{
+			Type:        IssueTypeMissingIndexEntry,
+			Severity:    "critical",
+			Collection:  coll.Name(),
+			DocumentID:  docID,
+			IndexName:   indexName,
+			Description: fmt.Sprintf("Document is missing from index under key %s", key),
+			Details: map[string]interface{}{
+				"key": key,
+			},
+		})
+	}
+
+	for _, entry := range entries {
+		key := fmt.Sprintf("%v", entry.Key)
+
+		doc, exists := docsByID[entry.DocumentID]
+		if !exists {
+			issues = append(issues, Issue{
+				Type:        IssueTypeOrphanedIndexEntry,
+				Severity:    "warning",
+				Collection:  coll.Name(),
+				DocumentID:  entry.DocumentID,
+				IndexName:   indexName,
+				Description: fmt.Sprintf("Index entry %s points to a missing document", key),
+				Details: map[string]interface{}{
+					"key": key,
+				},
+			})
+			continue
+		}
+
+		expected, included, err := coll.IndexKeyFor(indexName, doc)
+		if err != nil {
+			continue
+		}
+
+		if !included {
+			issues = append(issues, Issue{
+				Type:        IssueTypeExcludedIndexEntry,
+				Severity:    "warning",
+				Collection:  coll.Name(),
+				DocumentID:  entry.DocumentID,
+				IndexName:   indexName,
+				Description: fmt.Sprintf("Index entry %s belongs to a document excluded from the index", key),
+				Details: map[string]interface{}{
+					"key": key,
+				},
+			})
+			continue
+		}
+
+		if expectedKey := fmt.Sprintf("%v", expected); expectedKey != key {
+			issues = append(issues, Issue{
+				Type:        IssueTypeIndexFieldMismatch,
+				Severity:    "warning",
+				Collection:  coll.Name(),
+				DocumentID:  entry.DocumentID,
+				IndexName:   indexName,
+				Description: fmt.Sprintf("Index entry key %s does not match document key %s", key, expectedKey),
+				Details: map[string]interface{}{
+					"key":          key,
+					"expected_key": expectedKey,
+				},
+			})
+		}
+	}
+
+	return issues
+}
 
 // Repairer performs database repairs
 type Repairer struct {
 	db        *database.Database
 	validator *Validator
+	rebuilt   map[string]error   // collection.index -> rebuild result for the current run
+	oplog     *replication.Oplog // Optional source for restoring documents lost with a corrupt page
 }
 
 // NewRepairer creates a new repairer
@@ -305,28 +556,7 @@ func (r *Repairer) Repair(options *RepairOptions) (*RepairReport, error) {
 	}
 
 	// Fix issues
-	for _, issue := range validationReport.Issues {
-		fixed := false
-
-		switch issue.Type {
-		case IssueTypeMissingIndexEntry:
-			if options.AddMissingEntries {
-				fixed = r.fixMissingIndexEntry(issue)
-			}
-		case IssueTypeOrphanedIndexEntry:
-			if options.RemoveOrphans {
-				fixed = r.fixOrphanedIndexEntry(issue)
-			}
-		}
-
-		if fixed {
-			report.Fixed++
-			report.FixedIssues = append(report.FixedIssues, issue)
-		} else {
-			report.Failed++
-			report.FailedIssues = append(report.FailedIssues, issue)
-		}
-	}
+	r.fixIssues(validationReport.Issues, options, report)
 
 	// Rebuild indexes if requested
 	if options.RebuildIndexes {
@@ -370,6 +600,9 @@ func (r *Repairer) RepairCollection(collectionName string, options *RepairOption
 		return report, nil
 	}
 
+	// Fix issues
+	r.fixIssues(validationReport.Issues, options, report)
+
 	// Rebuild indexes if requested
 	if options.RebuildIndexes {
 		if err := r.rebuildCollectionIndexes(collectionName); err != nil {
@@ -383,18 +616,76 @@ func (r *Repairer) RepairCollection(collectionName string, options *RepairOption
 	return report, nil
 }
 
-// fixMissingIndexEntry adds a missing index entry
+// fixIssues attempts to fix each validation issue according to options
+func (r *Repairer) fixIssues(issues []Issue, options *RepairOptions, report *RepairReport) {
+	r.rebuilt = make(map[string]error)
+
+	for _, issue := range issues {
+		fixed := false
+
+		switch issue.Type {
+		case IssueTypeMissingIndexEntry:
+			if options.AddMissingEntries {
+				fixed = r.fixMissingIndexEntry(issue)
+			}
+		case IssueTypeOrphanedIndexEntry, IssueTypeExcludedIndexEntry, IssueTypeIndexFieldMismatch:
+			if options.RemoveOrphans {
+				fixed = r.fixOrphanedIndexEntry(issue)
+			}
+		case IssueTypeIndexBuildFailed:
+			if options.AddMissingEntries {
+				fixed = r.rebuildAffectedIndex(issue)
+			}
+		}
+
+		if fixed {
+			report.Fixed++
+			report.FixedIssues = append(report.FixedIssues, issue)
+		} else {
+			report.Failed++
+			report.FailedIssues = append(report.FailedIssues, issue)
+		}
+	}
+}
+
+// fixMissingIndexEntry adds a missing index entry by rebuilding the affected index
 func (r *Repairer) fixMissingIndexEntry(issue Issue) bool {
-	// Implementation would update the index
-	// This is a simplified version - actual implementation would need access to collection internals
-	return false
+	return r.rebuildAffectedIndex(issue)
 }
 
-// fixOrphanedIndexEntry removes an orphaned index entry
+// fixOrphanedIndexEntry removes an orphaned or stale index entry by rebuilding the affected index
 func (r *Repairer) fixOrphanedIndexEntry(issue Issue) bool {
-	// Implementation would remove from index
-	// This is a simplified version
-	return false
+	return r.rebuildAffectedIndex(issue)
+}
+
+// rebuildAffectedIndex rebuilds the index named by the issue, at most once per repair run
+func (r *Repairer) rebuildAffectedIndex(issue Issue) bool {
+	if issue.Collection == "" || issue.IndexName == "" {
+		return false
+	}
+
+	key := issue.Collection + "." + issue.IndexName
+	if r.rebuilt == nil {
+		r.rebuilt = make(map[string]error)
+	}
+	err, done := r.rebuilt[key]
+	if !done {
+		err = r.RebuildIndex(issue.Collection, issue.IndexName)
+		r.rebuilt[key] = err
+	}
+
+	return err == nil
+}
+
+// RebuildIndex rebuilds a single index of a collection from its documents,
+// leaving the collection's other indexes untouched
+func (r *Repairer) RebuildIndex(collectionName, indexName string) error {
+	coll := r.db.Collection(collectionName)
+	if coll == nil {
+		return fmt.Errorf("collection not found: %s", collectionName)
+	}
+
+	return coll.RebuildIndex(indexName)
 }
 
 // rebuildCollectionIndexes rebuilds all indexes for a collection
@@ -448,6 +739,188 @@ func (r *Repairer) rebuildCollectionIndexes(collectionName string) error {
 	return nil
 }
 
+// SetOplog sets the oplog used to restore documents lost from quarantined pages
+func (r *Repairer) SetOplog(oplog *replication.Oplog) {
+	r.oplog = oplog
+}
+
+// RepairPages verifies page checksums and repairs the corrupt pages. With
+// RedoFromWAL set, a page is first rewritten from its last image in the
+// write-ahead log, which restores it whole, as after a torn write. Pages the
+// log can't restore are marked unrecoverable in the issue details and, if
+// QuarantineCorruptPages is set, quarantined: documents on the page are
+// rewritten from their cached copies; any still missing are replayed from the
+// oplog when one is configured. Documents that can't be restored are kept,
+// unreadable, and listed as lost. An issue counts as fixed only if no document
+// was lost.
+func (r *Repairer) RepairPages(options *RepairOptions) (*RepairReport, error) {
+	if options == nil {
+		options = DefaultRepairOptions()
+	}
+
+	report := &RepairReport{
+		StartTime:    time.Now(),
+		Issues:       make([]Issue, 0),
+		FixedIssues:  make([]Issue, 0),
+		FailedIssues: make([]Issue, 0),
+	}
+
+	validationReport, err := r.validator.ValidatePages()
+	if err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	report.Issues = validationReport.Issues
+
+	if options.DryRun {
+		report.EndTime = time.Now()
+		return report, nil
+	}
+
+	for _, issue := range validationReport.Issues {
+		fixed := false
+		if issue.Type == IssueTypeChecksumMismatch && options.RedoFromWAL {
+			fixed = r.redoPage(issue)
+		}
+		if !fixed && issue.Type == IssueTypeChecksumMismatch && options.QuarantineCorruptPages {
+			fixed = r.quarantinePage(issue)
+		}
+
+		if fixed {
+			report.Fixed++
+			report.FixedIssues = append(report.FixedIssues, issue)
+		} else {
+			report.Failed++
+			report.FailedIssues = append(report.FailedIssues, issue)
+		}
+	}
+
+	report.EndTime = time.Now()
+	return report, nil
+}
+
+// redoPage rewrites the page named by a checksum issue from the write-ahead
+// log, recording in the issue details whether it could
+func (r *Repairer) redoPage(issue Issue) bool {
+	pageID, ok := issue.Details["page_id"].(storage.PageID)
+	if !ok {
+		return false
+	}
+
+	redone, err := r.db.RedoPage(pageID)
+	if err != nil {
+		issue.Details["error"] = err.Error()
+		return false
+	}
+
+	issue.Details["redone_from_wal"] = redone
+	if !redone {
+		issue.Details["unrecoverable"] = true
+	}
+	return redone
+}
+
+// quarantinePage quarantines the page named by a checksum issue and records
+// which documents were recovered in the issue details
+func (r *Repairer) quarantinePage(issue Issue) bool {
+	pageID, ok := issue.Details["page_id"].(storage.PageID)
+	if !ok {
+		return false
+	}
+
+	recovery, err := r.db.QuarantinePage(pageID)
+	if err != nil {
+		issue.Details["error"] = err.Error()
+		return false
+	}
+
+	lost := make([]string, 0)
+	restored := make([]string, 0)
+	for _, id := range recovery.Lost {
+		if r.restoreFromOplog(recovery.Collection, id) {
+			restored = append(restored, id)
+		} else {
+			lost = append(lost, id)
+		}
+	}
+
+	issue.Details["recovered"] = recovery.Recovered
+	issue.Details["restored_from_oplog"] = restored
+	issue.Details["lost"] = lost
+
+	return len(lost) == 0
+}
+
+// restoreFromOplog rewrites a quarantined document by replaying its insert
+// and any later updates addressed to its _id. Returns false if there is no oplog, the
+// document was never logged, or it was deleted afterwards.
+func (r *Repairer) restoreFromOplog(collectionName, id string) bool {
+	if r.oplog == nil {
+		return false
+	}
+
+	entries, err := r.oplog.GetEntriesSince(0)
+	if err != nil {
+		return false
+	}
+
+	var doc map[string]interface{}
+	updates := make([]*replication.OplogEntry, 0)
+	for _, entry := range entries {
+		if entry.Collection != collectionName {
+			continue
+		}
+
+		switch entry.OpType {
+		case replication.OpTypeInsert:
+			if fmt.Sprintf("%v", entry.DocID) == id {
+				doc = entry.Document
+				updates = updates[:0]
+			}
+		case replication.OpTypeUpdate:
+			if doc != nil && isIDFilter(entry.Filter, id) {
+				updates = append(updates, entry)
+			}
+		case replication.OpTypeDelete:
+			if doc != nil && isIDFilter(entry.Filter, id) {
+				doc = nil
+				updates = updates[:0]
+			}
+		}
+	}
+
+	if doc == nil {
+		return false
+	}
+
+	coll := r.db.Collection(collectionName)
+	if coll == nil {
+		return false
+	}
+
+	restored := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		restored[k] = v
+	}
+	restored["_id"] = id
+
+	changes := make([]map[string]interface{}, 0, len(updates))
+	for _, entry := range updates {
+		changes = append(changes, entry.Update)
+	}
+
+	return coll.RestoreDocument(restored, changes...) == nil
+}
+
+// isIDFilter reports whether a filter selects exactly the document with the given _id
+func isIDFilter(filter map[string]interface{}, id string) bool {
+	if len(filter) != 1 {
+		return false
+	}
+	value, ok := filter["_id"]
+	return ok && fmt.Sprintf("%v", value) == id
+}
+
 // Summary returns a human-readable summary of the validation report
 func (r *ValidationReport) Summary() string {
 	if r.IsHealthy {
@@ -3,9 +3,13 @@ package repair
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/mnohosten/laura-db/pkg/database"
+	"github.com/mnohosten/laura-db/pkg/replication"
+	"github.com/mnohosten/laura-db/pkg/storage"
 )
 
 func setupTestDB(t *testing.T) (*database.Database, func()) {
@@ -755,7 +759,7 @@ func TestDefragmentCollection(t *testing.T) {
 		coll.InsertOne(map[string]interface{}{
 			"name":  "User",
 			"age":   int64(20 + i%50),
-			"email": "user@example.com",
+			"email": fmt.Sprintf("user%d@example.com", i),
 		})
 	}
 
@@ -3868,3 +3872,412 @@ func TestRepairWithRebuildIndexesMultipleCollections(t *testing.T) {
 
 	t.Logf("Repaired %d collections: %s", 3, report.Summary())
 }
+
+func TestValidateUniqueCompoundIndexDuplicate(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	coll := db.Collection("accounts")
+	coll.InsertOne(map[string]interface{}{"_id": "a1", "tenant": "acme", "email": "a@acme.io"})
+	coll.InsertOne(map[string]interface{}{"_id": "a2", "tenant": "acme", "email": "a@acme.io"})
+
+	// A background build skips keys that collide, leaving the unique key duplicated
+	if err := coll.CreateCompoundIndexWithBackground([]string{"tenant", "email"}, true, true); err != nil {
+		t.Fatalf("Failed to create compound index: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		progress, err := coll.GetIndexBuildProgress("tenant_email_1")
+		if err != nil {
+			t.Fatalf("Failed to get build progress: %v", err)
+		}
+		if progress["state"].(string) == "ready" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Compound index build did not finish: %v", progress)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	report, err := NewValidator(db).ValidateCollection("accounts")
+	if err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+
+	if report.IsHealthy {
+		t.Fatal("Expected duplicate unique compound key to be reported")
+	}
+
+	found := false
+	for _, issue := range report.Issues {
+		if issue.Type == IssueTypeDuplicateUnique {
+			found = true
+			if issue.IndexName != "tenant_email_1" {
+				t.Errorf("Expected index name tenant_email_1, got %s", issue.IndexName)
+			}
+			if issue.DocumentID == "" {
+				t.Error("Expected document id on duplicate issue")
+			}
+			if issue.Details["key"] != "[acme a@acme.io]" {
+				t.Errorf("Expected key [acme a@acme.io], got %v", issue.Details["key"])
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected %s issue, got %+v", IssueTypeDuplicateUnique, report.Issues)
+	}
+}
+
+func TestValidatePartialIndexMissingEntry(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	coll := db.Collection("users")
+	coll.InsertOne(map[string]interface{}{"_id": "u1", "age": int64(30), "active": true})
+	coll.InsertOne(map[string]interface{}{"_id": "u2", "age": int64(30), "active": false})
+
+	if err := coll.CreatePartialIndex("age", map[string]interface{}{"active": true}, false); err != nil {
+		t.Fatalf("Failed to create partial index: %v", err)
+	}
+
+	// A healthy partial index excludes u2 and includes u1
+	report, err := NewValidator(db).ValidateCollection("users")
+	if err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+	for _, issue := range report.Issues {
+		if issue.IndexName != "" {
+			t.Fatalf("Expected healthy partial index, got %+v", issue)
+		}
+	}
+
+	// Updating the excluded document leaves u1's entry under the shared key
+	if err := coll.UpdateOne(map[string]interface{}{"_id": "u2"}, map[string]interface{}{
+		"$set": map[string]interface{}{"name": "Bob"},
+	}); err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+
+	report, err = NewValidator(db).ValidateCollection("users")
+	if err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+	for _, issue := range report.Issues {
+		if issue.IndexName != "" {
+			t.Fatalf("Expected no missing entry for the excluded u2, got %+v", issue)
+		}
+	}
+
+	// Repair leaves the partial index as it is
+	repairReport, err := NewRepairer(db).RepairCollection("users", DefaultRepairOptions())
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	for _, issue := range repairReport.FailedIssues {
+		if issue.IndexName != "" {
+			t.Errorf("Expected no index repairs, failed: %+v", issue)
+		}
+	}
+
+	entries, err := coll.IndexEntries("age_partial")
+	if err != nil {
+		t.Fatalf("IndexEntries failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].DocumentID != "u1" {
+		t.Errorf("Expected only u1 in the partial index, got %+v", entries)
+	}
+}
+
+func TestRepairerRebuildIndex(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	coll := db.Collection("users")
+	coll.InsertOne(map[string]interface{}{"name": "Alice", "city": "NYC"})
+	coll.CreateCompoundIndex([]string{"name", "city"}, true)
+
+	repairer := NewRepairer(db)
+	if err := repairer.RebuildIndex("users", "name_city_1"); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+	if err := repairer.RebuildIndex("users", "missing_1"); err == nil {
+		t.Error("Expected error rebuilding unknown index")
+	}
+
+	entries, err := coll.IndexEntries("name_city_1")
+	if err != nil {
+		t.Fatalf("IndexEntries failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected 1 entry after rebuild, got %d", len(entries))
+	}
+}
+
+func TestValidatePagesChecksumMismatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	db, err := database.Open(database.DefaultConfig(tmpDir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	oplog, err := replication.NewOplog(filepath.Join(t.TempDir(), "oplog.log"))
+	if err != nil {
+		t.Fatalf("Failed to open oplog: %v", err)
+	}
+	defer oplog.Close()
+
+	coll, _ := db.CreateCollection("users")
+	for i := 0; i < 3; i++ {
+		doc := map[string]interface{}{"_id": fmt.Sprintf("u%d", i), "name": "user"}
+		coll.InsertOne(doc)
+		oplog.Append(replication.CreateInsertEntry("default", "users", doc))
+	}
+
+	validator := NewValidator(db)
+	report, err := validator.ValidatePages()
+	if err != nil {
+		t.Fatalf("ValidatePages failed: %v", err)
+	}
+	if !report.IsHealthy {
+		t.Fatalf("Expected healthy pages, got %v", report.Issues)
+	}
+
+	// Flip a byte in the page holding the documents
+	file, err := os.OpenFile(filepath.Join(tmpDir, "data.db"), os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open data file: %v", err)
+	}
+	buf := make([]byte, 1)
+	file.ReadAt(buf, storage.PageHeaderSize+8)
+	buf[0] ^= 0xFF
+	file.WriteAt(buf, storage.PageHeaderSize+8)
+	file.Close()
+
+	report, err = validator.ValidatePages()
+	if err != nil {
+		t.Fatalf("ValidatePages failed: %v", err)
+	}
+	if report.IsHealthy || len(report.Issues) != 1 {
+		t.Fatalf("Expected 1 checksum issue, got %v", report.Issues)
+	}
+
+	issue := report.Issues[0]
+	if issue.Type != IssueTypeChecksumMismatch || issue.Severity != "critical" {
+		t.Errorf("Expected critical checksum_mismatch, got %s/%s", issue.Severity, issue.Type)
+	}
+	if issue.Collection != "users" {
+		t.Errorf("Expected collection users, got %q", issue.Collection)
+	}
+	if issue.Details["page_id"] != storage.PageID(0) {
+		t.Errorf("Expected page_id 0, got %v", issue.Details["page_id"])
+	}
+	if ids, _ := issue.Details["document_ids"].([]string); len(ids) != 3 {
+		t.Errorf("Expected 3 affected documents, got %v", issue.Details["document_ids"])
+	}
+
+	repairer := NewRepairer(db)
+	options := DefaultRepairOptions()
+
+	// Without quarantine enabled the issue is reported but left alone
+	repairReport, err := repairer.RepairPages(options)
+	if err != nil {
+		t.Fatalf("RepairPages failed: %v", err)
+	}
+	if repairReport.Fixed != 0 || repairReport.Failed != 1 {
+		t.Errorf("Expected 0 fixed and 1 failed, got %d/%d", repairReport.Fixed, repairReport.Failed)
+	}
+
+	// Quarantine relocates the documents, replaying them from the oplog
+	repairer.SetOplog(oplog)
+	options.QuarantineCorruptPages = true
+	repairReport, err = repairer.RepairPages(options)
+	if err != nil {
+		t.Fatalf("RepairPages failed: %v", err)
+	}
+	if repairReport.Fixed != 1 || repairReport.Failed != 0 {
+		t.Errorf("Expected 1 fixed and 0 failed, got %d/%d", repairReport.Fixed, repairReport.Failed)
+	}
+
+	report, err = validator.ValidatePages()
+	if err != nil {
+		t.Fatalf("ValidatePages failed: %v", err)
+	}
+	if !report.IsHealthy {
+		t.Errorf("Expected healthy pages after quarantine, got %v", report.Issues)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := coll.FindOne(map[string]interface{}{"_id": fmt.Sprintf("u%d", i)}); err != nil {
+			t.Errorf("Expected document u%d to survive quarantine: %v", i, err)
+		}
+	}
+}
+
+func TestRepairPagesRedoFromWAL(t *testing.T) {
+	tmpDir := t.TempDir()
+	db, err := database.Open(database.DefaultConfig(tmpDir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	coll, _ := db.CreateCollection("users")
+	for i := 0; i < 3; i++ {
+		coll.InsertOne(map[string]interface{}{"_id": fmt.Sprintf("u%d", i), "name": "user"})
+	}
+
+	// Flip a byte in the page holding the documents
+	flip := func() {
+		file, err := os.OpenFile(filepath.Join(tmpDir, "data.db"), os.O_RDWR, 0644)
+		if err != nil {
+			t.Fatalf("Failed to open data file: %v", err)
+		}
+		defer file.Close()
+		buf := make([]byte, 1)
+		file.ReadAt(buf, storage.PageHeaderSize+8)
+		buf[0] ^= 0xFF
+		file.WriteAt(buf, storage.PageHeaderSize+8)
+	}
+	flip()
+
+	repairer := NewRepairer(db)
+	options := DefaultRepairOptions()
+	options.RedoFromWAL = true
+
+	// The WAL still holds the image of the page written last
+	report, err := repairer.RepairPages(options)
+	if err != nil {
+		t.Fatalf("RepairPages failed: %v", err)
+	}
+	if report.Fixed != 1 || report.Failed != 0 {
+		t.Fatalf("Expected 1 fixed and 0 failed, got %d/%d", report.Fixed, report.Failed)
+	}
+	if report.FixedIssues[0].Details["redone_from_wal"] != true {
+		t.Errorf("Expected the page redone from the WAL, got %v", report.FixedIssues[0].Details)
+	}
+	validation, err := NewValidator(db).ValidatePages()
+	if err != nil {
+		t.Fatalf("ValidatePages failed: %v", err)
+	}
+	if !validation.IsHealthy {
+		t.Errorf("Expected healthy pages after redo, got %v", validation.Issues)
+	}
+
+	// After a checkpoint the image is gone, and the page is reported as
+	// unrecoverable rather than repaired
+	if err := db.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	flip()
+
+	report, err = repairer.RepairPages(options)
+	if err != nil {
+		t.Fatalf("RepairPages failed: %v", err)
+	}
+	if report.Fixed != 0 || report.Failed != 1 {
+		t.Fatalf("Expected 0 fixed and 1 failed, got %d/%d", report.Fixed, report.Failed)
+	}
+	if report.FailedIssues[0].Details["unrecoverable"] != true {
+		t.Errorf("Expected the page reported unrecoverable, got %v", report.FailedIssues[0].Details)
+	}
+}
+
+func TestRestoreFromOplog(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	oplog, err := replication.NewOplog(filepath.Join(t.TempDir(), "oplog.log"))
+	if err != nil {
+		t.Fatalf("Failed to open oplog: %v", err)
+	}
+	defer oplog.Close()
+
+	coll, _ := db.CreateCollection("users")
+	coll.InsertOne(map[string]interface{}{"_id": "u1", "name": "alice", "age": int64(30)})
+	coll.InsertOne(map[string]interface{}{"_id": "u2", "name": "bob"})
+
+	oplog.Append(replication.CreateInsertEntry("default", "users", map[string]interface{}{"_id": "u1", "name": "alice", "age": int64(29)}))
+	oplog.Append(replication.CreateUpdateEntry("default", "users",
+		map[string]interface{}{"_id": "u1"},
+		map[string]interface{}{"$set": map[string]interface{}{"age": int64(30)}}))
+	oplog.Append(replication.CreateInsertEntry("default", "users", map[string]interface{}{"_id": "u2", "name": "bob"}))
+	oplog.Append(replication.CreateDeleteEntry("default", "users", map[string]interface{}{"_id": "u2"}))
+
+	// Evict both documents from the document cache, then lose their page
+	for i := 0; i < 1000; i++ {
+		coll.InsertOne(map[string]interface{}{"_id": fmt.Sprintf("filler%d", i)})
+	}
+	recovery, err := db.QuarantinePage(0)
+	if err != nil {
+		t.Fatalf("QuarantinePage failed: %v", err)
+	}
+	lost := make(map[string]bool)
+	for _, id := range recovery.Lost {
+		lost[id] = true
+	}
+	if !lost["u1"] || !lost["u2"] {
+		t.Fatalf("Expected u1 and u2 to be lost, got %v", recovery.Lost)
+	}
+
+	repairer := NewRepairer(db)
+	if repairer.restoreFromOplog("users", "u1") {
+		t.Error("Expected restore to fail without an oplog")
+	}
+
+	repairer.SetOplog(oplog)
+	if !repairer.restoreFromOplog("users", "u1") {
+		t.Fatal("Expected u1 to be restored from the oplog")
+	}
+	doc, err := coll.FindOne(map[string]interface{}{"_id": "u1"})
+	if err != nil {
+		t.Fatalf("Expected restored document: %v", err)
+	}
+	if age, _ := doc.Get("age"); age != int64(30) {
+		t.Errorf("Expected age 30 after replaying update, got %v", age)
+	}
+
+	if repairer.restoreFromOplog("users", "u2") {
+		t.Error("Expected deleted document u2 not to be restored")
+	}
+	if repairer.restoreFromOplog("users", "u3") {
+		t.Error("Expected unknown document u3 not to be restored")
+	}
+}
+
+func TestValidateIndexUsage(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	coll := db.Collection("users")
+	coll.InsertOne(map[string]interface{}{"name": "Alice", "age": int64(30)})
+	coll.CreateIndex("name", false)
+	coll.CreateIndex("age", false)
+
+	if _, err := coll.Find(map[string]interface{}{"name": "Alice"}); err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+
+	validator := NewValidator(db)
+	report, err := validator.ValidateIndexUsage(0)
+	if err != nil {
+		t.Fatalf("ValidateIndexUsage failed: %v", err)
+	}
+	if len(report.Issues) != 1 {
+		t.Fatalf("Expected 1 unused index, got %d: %v", len(report.Issues), report.Issues)
+	}
+	issue := report.Issues[0]
+	if issue.Type != IssueTypeUnusedIndex || issue.IndexName != "age_1" {
+		t.Errorf("Expected unused index age_1, got %s %s", issue.Type, issue.IndexName)
+	}
+	if !report.IsHealthy {
+		t.Error("Expected unused indexes not to make the database unhealthy")
+	}
+
+	// Indexes younger than minAge are not reported
+	report, _ = validator.ValidateIndexUsage(time.Hour)
+	if len(report.Issues) != 0 {
+		t.Errorf("Expected no issues for new indexes, got %d", len(report.Issues))
+	}
+}
@@ -0,0 +1,227 @@
+package replication
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/encryption"
+)
+
+// ArchiveSink stores the WAL segments shipped by a WALArchiver
+type ArchiveSink interface {
+	// Put stores a segment under name, replacing any segment with that name
+	Put(name string, data []byte) error
+
+	// Get returns the segment stored under name
+	Get(name string) ([]byte, error)
+
+	// List returns the names of all stored segments, in any order
+	List() ([]string, error)
+}
+
+// DirSink keeps archived WAL segments as files in a local directory, such
+// as a mounted network share
+type DirSink struct {
+	dir string
+}
+
+// NewDirSink creates a sink that stores segments in dir, creating it if needed
+func NewDirSink(dir string) (*DirSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &DirSink{dir: dir}, nil
+}
+
+// Put writes the segment to a temporary file, syncs it and renames it into
+// place, so a reader never sees a partial segment
+func (s *DirSink) Put(name string, data []byte) error {
+	path := filepath.Join(s.dir, name)
+	tmpPath := path + ".tmp"
+
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write segment: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync segment: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close segment: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to store segment: %w", err)
+	}
+	return nil
+}
+
+// Get reads a segment
+func (s *DirSink) Get(name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read segment: %w", err)
+	}
+	return data, nil
+}
+
+// List returns the segment files in the directory
+func (s *DirSink) List() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive directory: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), walSegmentExt) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// HTTPSink stores archived WAL segments on an HTTP object store. Segments
+// are uploaded with PUT and downloaded with GET at baseURL/name, and a GET
+// of baseURL/ must return a JSON array of the stored names. An S3-style
+// bucket behind a small gateway, or a plain WebDAV server with a listing
+// handler, works as the endpoint.
+type HTTPSink struct {
+	baseURL string
+	client  *http.Client
+	header  http.Header
+}
+
+// NewHTTPSink creates a sink for the endpoint at baseURL
+func NewHTTPSink(baseURL string) *HTTPSink {
+	return &HTTPSink{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+		header:  make(http.Header),
+	}
+}
+
+// SetHeader sets a header sent with every request, such as Authorization
+func (s *HTTPSink) SetHeader(key, value string) {
+	s.header.Set(key, value)
+}
+
+// Put uploads a segment
+func (s *HTTPSink) Put(name string, data []byte) error {
+	_, err := s.do(http.MethodPut, s.baseURL+"/"+url.PathEscape(name), data)
+	return err
+}
+
+// Get downloads a segment
+func (s *HTTPSink) Get(name string) ([]byte, error) {
+	return s.do(http.MethodGet, s.baseURL+"/"+url.PathEscape(name), nil)
+}
+
+// List fetches the names of the stored segments
+func (s *HTTPSink) List() ([]string, error) {
+	body, err := s.do(http.MethodGet, s.baseURL+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	if err := json.Unmarshal(body, &names); err != nil {
+		return nil, fmt.Errorf("invalid segment listing: %w", err)
+	}
+	return names, nil
+}
+
+// do sends a request and returns the response body, failing on a non-2xx status
+func (s *HTTPSink) do(method, target string, data []byte) ([]byte, error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range s.header {
+		req.Header[key] = values
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %w", method, target, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s failed: %s", method, target, resp.Status)
+	}
+	return respBody, nil
+}
+
+// EncryptedSink encrypts WAL segments before they leave the host and
+// decrypts them when fetched for a restore, so the archive is protected in
+// transit and at rest. Segments are sealed as encryption archives; one that
+// doesn't authenticate with the sink's key, because the key is wrong or
+// the segment was modified, fails Get with an error wrapping
+// encryption.ErrArchiveAuth.
+type EncryptedSink struct {
+	sink   ArchiveSink
+	config *encryption.Config
+}
+
+// NewEncryptedSink wraps sink so segments are stored encrypted with config,
+// which must use AlgorithmAES256GCM. Plaintext segments already in sink
+// can't be read through it, so start encrypted archiving with a new base
+// backup and an empty sink.
+func NewEncryptedSink(sink ArchiveSink, config *encryption.Config) (*EncryptedSink, error) {
+	if config == nil || config.Algorithm != encryption.AlgorithmAES256GCM {
+		return nil, fmt.Errorf("encrypted sink needs %v encryption", encryption.AlgorithmAES256GCM)
+	}
+	if _, err := encryption.SealArchive(nil, config); err != nil {
+		return nil, err
+	}
+	return &EncryptedSink{sink: sink, config: config}, nil
+}
+
+// Put encrypts and stores a segment
+func (s *EncryptedSink) Put(name string, data []byte) error {
+	sealed, err := encryption.SealArchive(data, s.config)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt segment: %w", err)
+	}
+	return s.sink.Put(name, sealed)
+}
+
+// Get fetches and decrypts a segment
+func (s *EncryptedSink) Get(name string) ([]byte, error) {
+	sealed, err := s.sink.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := encryption.OpenArchive(sealed, s.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt segment %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// List returns the names of the stored segments
+func (s *EncryptedSink) List() ([]string, error) {
+	return s.sink.List()
+}
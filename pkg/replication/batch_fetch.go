@@ -0,0 +1,135 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Defaults for fetching the oplog in batches
+const (
+	DefaultFetchBatchSize  = 1000                 // Entries per batch
+	DefaultFetchBatchBytes = 16 * 1024 * 1024     // Serialized bytes per batch
+	DefaultLongPollTimeout = 5 * time.Second      // How long the master holds an empty fetch
+	DefaultBatchWindow     = 5 * time.Millisecond // How long a woken fetch lingers for more entries
+)
+
+// OplogBatchFetcher is implemented by master clients that can fetch the
+// oplog in bounded batches and long-poll for new entries. A slave whose
+// client implements it fetches whole ranges of the oplog per round trip and,
+// once caught up, waits on the master for new entries instead of polling
+// every PollInterval.
+type OplogBatchFetcher interface {
+	// FetchOplogBatch returns the entries after afterOpID in order, up to
+	// maxEntries entries and maxBytes bytes of serialized entries (0 for no
+	// limit); the first entry is returned even if it is larger. If there is
+	// none yet, the master holds the request up to wait for one to arrive,
+	// returning no entries if none does.
+	FetchOplogBatch(ctx context.Context, afterOpID OpID, maxEntries, maxBytes int, wait time.Duration) ([]*OplogEntry, error)
+}
+
+// FetchOplogBatch returns a batch of the entries after afterOpID, waiting up
+// to wait for one if there is none yet (see OplogBatchFetcher). A fetch that
+// waited lingers up to the batch window for the rest of a burst. It returns
+// an error wrapping ErrOplogTrimmed if entries after afterOpID were trimmed.
+func (m *Master) FetchOplogBatch(ctx context.Context, afterOpID OpID, maxEntries, maxBytes int, wait time.Duration) ([]*OplogEntry, error) {
+	if wait > 0 && m.oplog.GetCurrentID() <= afterOpID {
+		waitCtx, cancel := context.WithTimeout(ctx, wait)
+		err := m.oplog.WaitForEntries(waitCtx, afterOpID)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				return nil, err
+			}
+			// Nothing arrived within wait
+			return []*OplogEntry{}, nil
+		}
+		m.lingerForBatch(ctx, afterOpID, maxEntries)
+	}
+
+	if err := m.oplog.CheckRetained(afterOpID); err != nil {
+		return nil, err
+	}
+	entries, err := m.oplog.GetEntriesBatch(afterOpID, maxEntries, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		m.shippedBatches.Add(1)
+		m.shippedEntries.Add(int64(len(entries)))
+	}
+	return entries, nil
+}
+
+// lingerForBatch holds a fetch woken by a new entry for up to the batch
+// window, or until maxEntries entries are waiting, so that the writes
+// following it ship in the same batch. A slave that is behind doesn't wait
+// for a window: its fetch finds entries already there.
+func (m *Master) lingerForBatch(ctx context.Context, afterOpID OpID, maxEntries int) {
+	if m.config.BatchWindow <= 0 {
+		return
+	}
+	lingerCtx, cancel := context.WithTimeout(ctx, m.config.BatchWindow)
+	defer cancel()
+
+	for {
+		current := m.oplog.GetCurrentID()
+		if maxEntries > 0 && current-afterOpID >= OpID(maxEntries) {
+			return
+		}
+		if err := m.oplog.WaitForEntries(lingerCtx, current); err != nil {
+			return
+		}
+	}
+}
+
+// FetchOplogBatch fetches a batch of oplog entries after the given OpID
+func (c *LocalMasterClient) FetchOplogBatch(ctx context.Context, afterOpID OpID, maxEntries, maxBytes int, wait time.Duration) ([]*OplogEntry, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	return c.master.FetchOplogBatch(ctx, afterOpID, maxEntries, maxBytes, wait)
+}
+
+var _ OplogBatchFetcher = (*LocalMasterClient)(nil)
+
+// fetchEntries fetches the entries after lastOpID from client: a batch,
+// long-polling for it, if the client supports it, everything after lastOpID
+// otherwise
+func (s *Slave) fetchEntries(client MasterClient, lastOpID OpID) ([]*OplogEntry, error) {
+	fetcher, ok := client.(OplogBatchFetcher)
+	if !ok {
+		ctx, cancel := context.WithTimeout(s.stopCtx, s.fetchTimeout())
+		defer cancel()
+		return client.GetOplogEntries(ctx, lastOpID)
+	}
+
+	// The long poll ends in time to notice a stalled sync source, and the
+	// fetch may be held for it on top of the fetch timeout
+	wait := s.config.LongPollTimeout
+	if timeout := s.fetchTimeout(); wait > timeout {
+		wait = timeout
+	}
+	// Nor does it keep held entries waiting past their due time
+	if due, ok := s.nextRelease(); ok && wait > due {
+		wait = due
+	}
+	ctx, cancel := context.WithTimeout(s.stopCtx, s.fetchTimeout()+wait)
+	defer cancel()
+
+	entries, err := fetcher.FetchOplogBatch(ctx, lastOpID, s.config.FetchBatchSize, s.config.FetchBatchBytes, wait)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.fetchedBatches++
+	s.fetchedEntries += int64(len(entries))
+	s.mu.Unlock()
+	return entries, nil
+}
@@ -0,0 +1,202 @@
+package replication
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/database"
+)
+
+func TestMasterFetchOplogBatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	db, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "master")))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	master, err := NewMaster(DefaultMasterConfig(db, filepath.Join(tmpDir, "oplog.bin")))
+	if err != nil {
+		t.Fatalf("Failed to create master: %v", err)
+	}
+	defer master.Stop()
+
+	for i := 0; i < 5; i++ {
+		master.LogOperation(CreateInsertEntry("default", "users", map[string]interface{}{"_id": int64(i)}))
+	}
+
+	entries, err := master.FetchOplogBatch(context.Background(), 1, 3, 0, time.Second)
+	if err != nil {
+		t.Fatalf("FetchOplogBatch failed: %v", err)
+	}
+	if len(entries) != 3 || entries[0].OpID != 2 {
+		t.Errorf("Expected 3 entries from OpID 2, got %d", len(entries))
+	}
+
+	// Caught up, the fetch returns empty once the wait ends
+	start := time.Now()
+	entries, err = master.FetchOplogBatch(context.Background(), 5, 0, 0, 50*time.Millisecond)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Expected no entries after the wait, got %d, %v", len(entries), err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected the fetch held for the wait, returned after %v", elapsed)
+	}
+
+	// An entry logged during the wait is returned at once
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		master.LogOperation(CreateInsertEntry("default", "users", map[string]interface{}{"_id": int64(5)}))
+	}()
+	start = time.Now()
+	entries, err = master.FetchOplogBatch(context.Background(), 5, 0, 0, 5*time.Second)
+	if err != nil || len(entries) != 1 || entries[0].OpID != 6 {
+		t.Errorf("Expected entry 6, got %d entries, %v", len(entries), err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the new entry to end the wait, took %v", elapsed)
+	}
+
+	// A cancelled request ends the wait
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := master.FetchOplogBatch(ctx, 6, 0, 0, 5*time.Second); err == nil {
+		t.Error("Expected an error for a cancelled fetch")
+	}
+}
+
+func TestSlaveFetchesBatches(t *testing.T) {
+	tmpDir := t.TempDir()
+	masterDB, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "master")))
+	if err != nil {
+		t.Fatalf("Failed to open master database: %v", err)
+	}
+	defer masterDB.Close()
+	slaveDB, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "slave")))
+	if err != nil {
+		t.Fatalf("Failed to open slave database: %v", err)
+	}
+	defer slaveDB.Close()
+
+	// A long poll interval: the slave relies on batches and long polling
+	slaveConfig := DefaultSlaveConfig("slave1", slaveDB, nil)
+	slaveConfig.PollInterval = time.Hour
+	slaveConfig.FetchBatchSize = 2
+	slaveConfig.LongPollTimeout = 200 * time.Millisecond
+	pair, err := NewReplicationPair(DefaultMasterConfig(masterDB, filepath.Join(tmpDir, "oplog.bin")), slaveConfig)
+	if err != nil {
+		t.Fatalf("Failed to create replication pair: %v", err)
+	}
+	if err := pair.Start(); err != nil {
+		t.Fatalf("Failed to start replication: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		pair.Master.LogOperation(CreateInsertEntry("default", "users", map[string]interface{}{"_id": int64(i)}))
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for pair.Slave.GetLastAppliedOpID() < 5 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if opID := pair.Slave.GetLastAppliedOpID(); opID != 5 {
+		t.Fatalf("Expected the slave to apply OpID 5, got %d", opID)
+	}
+	if batches := pair.Slave.Stats()["fetched_batches"].(int64); batches < 3 {
+		t.Errorf("Expected at least 3 batches of 2 entries, got %d", batches)
+	}
+	if count, _ := slaveDB.Collection("users").Count(nil); count != 5 {
+		t.Errorf("Expected 5 documents on the slave, got %d", count)
+	}
+
+	// Stop doesn't wait for the long poll to end
+	start := time.Now()
+	pair.Stop()
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Expected Stop to interrupt the long poll, took %v", elapsed)
+	}
+}
+
+func TestBurstReplicatesInOneRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	masterDB, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "master")))
+	if err != nil {
+		t.Fatalf("Failed to open master database: %v", err)
+	}
+	defer masterDB.Close()
+	slaveDB, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "slave")))
+	if err != nil {
+		t.Fatalf("Failed to open slave database: %v", err)
+	}
+	defer slaveDB.Close()
+
+	masterConfig := DefaultMasterConfig(masterDB, filepath.Join(tmpDir, "oplog.bin"))
+	masterConfig.BatchWindow = 500 * time.Millisecond
+	slaveConfig := DefaultSlaveConfig("slave1", slaveDB, nil)
+	slaveConfig.PollInterval = time.Hour
+	slaveConfig.LongPollTimeout = 2 * time.Second
+	slaveConfig.ApplyConcurrency = 1
+	pair, err := NewReplicationPair(masterConfig, slaveConfig)
+	if err != nil {
+		t.Fatalf("Failed to create replication pair: %v", err)
+	}
+	if err := pair.Start(); err != nil {
+		t.Fatalf("Failed to start replication: %v", err)
+	}
+	defer pair.Stop()
+	LogTransactions(masterDB, pair.Master.oplog, "default")
+
+	// Let the slave's fetch start waiting
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		pair.Master.LogOperation(CreateInsertEntry("default", "events", map[string]interface{}{"_id": int64(i)}))
+	}
+	waitForOpID(t, pair.Slave, 100)
+
+	masterStats, slaveStats := pair.Master.Stats(), pair.Slave.Stats()
+	if masterStats["shipped_batches"].(int64) != 1 || masterStats["shipped_entries"].(int64) != 100 {
+		t.Errorf("Expected the burst shipped in one batch, got %v", masterStats)
+	}
+	if slaveStats["fetched_batches"].(int64) != 1 || slaveStats["grouped_inserts"].(int64) != 1 || slaveStats["coalesced_entries"].(int64) != 100 {
+		t.Errorf("Expected the burst fetched once and applied as one grouped insert, got %v", slaveStats)
+	}
+	if count, _ := slaveDB.Collection("events").Count(nil); count != 100 {
+		t.Errorf("Expected 100 documents on the slave, got %d", count)
+	}
+
+	// A transaction replicates as a unit
+	session := masterDB.StartSession()
+	session.InsertOne("accounts", map[string]interface{}{"_id": "a", "balance": int64(100)})
+	session.InsertOne("accounts", map[string]interface{}{"_id": "b", "balance": int64(200)})
+	session.UpdateOne("accounts", map[string]interface{}{"_id": "a"}, map[string]interface{}{"$inc": map[string]interface{}{"balance": int64(50)}})
+	if err := session.CommitTransaction(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	// The update folds into the insert of a: two entries
+	waitForOpID(t, pair.Slave, 102)
+	if txns := pair.Slave.Stats()["applied_transactions"].(int64); txns != 1 {
+		t.Errorf("Expected 1 applied transaction, got %d", txns)
+	}
+	if count, _ := slaveDB.Collection("accounts").Count(nil); count != 2 {
+		t.Errorf("Expected 2 accounts on the slave, got %d", count)
+	}
+	if doc, err := slaveDB.Collection("accounts").FindOne(map[string]interface{}{"_id": "a"}); err != nil {
+		t.Errorf("Expected account a on the slave, got %v", err)
+	} else if balance, _ := doc.Get("balance"); numberValue(balance) != 150 {
+		t.Errorf("Expected balance 150, got %v", balance)
+	}
+}
+
+// waitForOpID waits for a slave to apply the given OpID
+func waitForOpID(t *testing.T, slave *Slave, opID OpID) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for slave.GetLastAppliedOpID() < opID && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := slave.GetLastAppliedOpID(); got != opID {
+		t.Fatalf("Expected the slave to apply OpID %d, got %d", opID, got)
+	}
+}
@@ -0,0 +1,110 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// CausalSession gives a sequence of operations on a replica set causal
+// consistency: every read observes the session's earlier writes and reads,
+// even when it is served by a secondary. The session tracks an operation
+// time, the latest OpID it has written or observed, and passes it as the
+// AfterClusterTime of each read.
+type CausalSession struct {
+	router        *ReadRouter
+	operationTime OpID
+	mu            sync.Mutex
+}
+
+// StartCausalSession starts a causally consistent session on the router
+func (r *ReadRouter) StartCausalSession() *CausalSession {
+	return &CausalSession{router: r}
+}
+
+// OperationTime returns the latest operation the session has written or
+// observed
+func (s *CausalSession) OperationTime() OpID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.operationTime
+}
+
+// AdvanceOperationTime moves the session's operation time forward, for
+// example to continue the causal chain of another session
+func (s *CausalSession) AdvanceOperationTime(opID OpID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if opID > s.operationTime {
+		s.operationTime = opID
+	}
+}
+
+// Write logs a write on the primary with the given write concern and
+// advances the session's operation time to it
+func (s *CausalSession) Write(ctx context.Context, entry *OplogEntry, wc *WriteConcern) (*WriteResult, error) {
+	result, err := s.router.replicaSet.WriteWithConcern(ctx, entry, wc)
+	if result != nil {
+		// The write happened even if the write concern wasn't satisfied
+		s.AdvanceOperationTime(result.OpID)
+	}
+	return result, err
+}
+
+// ReadDocument reads a document from a node that has applied every
+// operation the session has seen
+func (s *CausalSession) ReadDocument(ctx context.Context, collName string, filter map[string]interface{}, pref *ReadPreference) (map[string]interface{}, error) {
+	if err := s.selectNode(ctx, pref); err != nil {
+		return nil, err
+	}
+
+	// Route to appropriate node
+	// In a real implementation, would route to the actual node
+	// For now, we'll read from the local database
+	doc, err := s.router.replicaSet.db.Collection(collName).FindOne(filter)
+	if err != nil {
+		return nil, err
+	}
+	return doc.ToMap(), nil
+}
+
+// ReadDocuments reads documents from a node that has applied every
+// operation the session has seen
+func (s *CausalSession) ReadDocuments(ctx context.Context, collName string, filter map[string]interface{}, pref *ReadPreference) ([]map[string]interface{}, error) {
+	if err := s.selectNode(ctx, pref); err != nil {
+		return nil, err
+	}
+
+	docs, err := s.router.replicaSet.db.Collection(collName).Find(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]map[string]interface{}, len(docs))
+	for i, doc := range docs {
+		result[i] = doc.ToMap()
+	}
+	return result, nil
+}
+
+// selectNode selects a node for a read of the session and advances the
+// operation time to what that node has applied
+func (s *CausalSession) selectNode(ctx context.Context, pref *ReadPreference) error {
+	if pref == nil {
+		pref = Primary()
+	}
+	pref = pref.clone()
+	if after := s.OperationTime(); after > pref.AfterClusterTime {
+		pref.AfterClusterTime = after
+	}
+
+	nodeID, err := s.router.selector.SelectNode(ctx, pref)
+	if err != nil {
+		return fmt.Errorf("failed to select node: %w", err)
+	}
+
+	if opID, ok := s.router.replicaSet.memberLastOpID(nodeID); ok {
+		s.AdvanceOperationTime(opID)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package replication
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/database"
+)
+
+// newCausalTestReplicaSet creates a replica set with node1 as primary and
+// two secondaries that haven't applied anything
+func newCausalTestReplicaSet(t *testing.T) *ReplicaSet {
+	t.Helper()
+
+	db, err := database.Open(database.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	rs, err := NewReplicaSet(DefaultReplicaSetConfig("rs0", "node1", db, t.TempDir()+"/oplog"))
+	if err != nil {
+		t.Fatalf("Failed to create replica set: %v", err)
+	}
+	rs.AddMember("node2", 1, true)
+	rs.AddMember("node3", 1, true)
+	if err := rs.BecomePrimary(); err != nil {
+		t.Fatalf("Failed to become primary: %v", err)
+	}
+	t.Cleanup(func() { rs.Stop() })
+	return rs
+}
+
+func TestReadPreferenceAfterClusterTime(t *testing.T) {
+	rs := newCausalTestReplicaSet(t)
+	rs.UpdateMemberHeartbeat("node2", 5)
+	rs.UpdateMemberHeartbeat("node3", 10)
+
+	selector := NewReadPreferenceSelector(rs)
+	ctx := context.Background()
+
+	// Only node3 has applied operation 8
+	for i := 0; i < 10; i++ {
+		nodeID, err := selector.SelectNode(ctx, Secondary().WithAfterClusterTime(8))
+		if err != nil {
+			t.Fatalf("Failed to select node: %v", err)
+		}
+		if nodeID != "node3" {
+			t.Fatalf("Expected node3, got %s", nodeID)
+		}
+	}
+
+	// No secondary has applied operation 20: fall back to the primary
+	nodeID, err := selector.SelectNode(ctx, SecondaryPreferred().WithAfterClusterTime(20))
+	if err != nil {
+		t.Fatalf("Failed to select node: %v", err)
+	}
+	if nodeID != "node1" {
+		t.Errorf("Expected primary node1, got %s", nodeID)
+	}
+
+	// ReadSecondary waits until the context is done
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := selector.SelectNode(waitCtx, Secondary().WithAfterClusterTime(20)); err == nil {
+		t.Error("Expected selection to time out")
+	}
+}
+
+func TestCausalSessionReadsOwnWrites(t *testing.T) {
+	rs := newCausalTestReplicaSet(t)
+	router := NewReadRouter(rs)
+	session := router.StartCausalSession()
+	ctx := context.Background()
+
+	entry := CreateInsertEntry("testdb", "users", map[string]interface{}{"name": "Alice"})
+	result, err := session.Write(ctx, entry, W1WriteConcern())
+	if err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if session.OperationTime() != result.OpID {
+		t.Errorf("Expected operation time %d, got %d", result.OpID, session.OperationTime())
+	}
+
+	// Secondaries haven't applied the write yet
+	shortCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := session.ReadDocuments(shortCtx, "users", nil, Secondary()); err == nil {
+		t.Error("Expected secondary read to wait for the write and time out")
+	}
+
+	// The read waits for a secondary to catch up
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		rs.UpdateMemberHeartbeat("node2", result.OpID)
+	}()
+	waitCtx, cancelWait := context.WithTimeout(ctx, 2*time.Second)
+	defer cancelWait()
+	if _, err := session.ReadDocuments(waitCtx, "users", nil, Secondary()); err != nil {
+		t.Fatalf("Expected secondary read after catch-up: %v", err)
+	}
+
+	// Reads advance the operation time to what the node had applied
+	rs.UpdateMemberHeartbeat("node2", result.OpID+5)
+	rs.UpdateMemberHeartbeat("node3", result.OpID+5)
+	session.ReadDocuments(ctx, "users", nil, Secondary())
+	if session.OperationTime() != result.OpID+5 {
+		t.Errorf("Expected operation time %d, got %d", result.OpID+5, session.OperationTime())
+	}
+}
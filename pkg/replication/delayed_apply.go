@@ -0,0 +1,65 @@
+package replication
+
+import "time"
+
+// A slave configured with SecondsBehind keeps fetched entries until they
+// are at least that old, then applies them in order. Entries still held
+// aren't applied, so the OpID the slave reports in heartbeats, and with it
+// the write concern acknowledgments it counts toward, only moves past an
+// entry once it is applied.
+
+// delay returns how long entries are held before they are applied
+func (s *Slave) delay() time.Duration {
+	return time.Duration(s.config.SecondsBehind) * time.Second
+}
+
+// fetchAfter returns the OpID to fetch after: the last entry held, or the
+// last applied if none is. Must be called with s.mu held.
+func (s *Slave) fetchAfter() OpID {
+	if n := len(s.held); n > 0 {
+		return s.held[n-1].OpID
+	}
+	return s.lastAppliedOpID
+}
+
+// releaseEntries adds fetched entries to those held and returns the ones
+// old enough to apply, in order. A transaction is released as a whole.
+func (s *Slave) releaseEntries(entries []*OplogEntry) []*OplogEntry {
+	delay := s.delay()
+	if delay <= 0 {
+		return entries
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.held = append(s.held, entries...)
+	cutoff := time.Now().Add(-delay)
+	n := 0
+	for n < len(s.held) && !s.held[n].Timestamp.After(cutoff) {
+		n++
+	}
+	for n > 0 && n < len(s.held) && s.held[n].TxnID != 0 && s.held[n].TxnID == s.held[n-1].TxnID {
+		n--
+	}
+
+	ready := s.held[:n:n]
+	s.held = append([]*OplogEntry(nil), s.held[n:]...)
+	return ready
+}
+
+// nextRelease returns how long until the oldest held entry is due, or false
+// if none is held
+func (s *Slave) nextRelease() (time.Duration, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.held) == 0 {
+		return 0, false
+	}
+	due := time.Until(s.held[0].Timestamp.Add(s.delay()))
+	if due < 0 {
+		due = 0
+	}
+	return due, true
+}
@@ -0,0 +1,114 @@
+package replication
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/database"
+)
+
+func TestDelayedSlaveTrailsPrimary(t *testing.T) {
+	tmpDir := t.TempDir()
+	primaryDB, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "primary")))
+	if err != nil {
+		t.Fatalf("Failed to open primary database: %v", err)
+	}
+	defer primaryDB.Close()
+	delayedDB, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "delayed")))
+	if err != nil {
+		t.Fatalf("Failed to open delayed database: %v", err)
+	}
+	defer delayedDB.Close()
+
+	rs, err := NewReplicaSet(DefaultReplicaSetConfig("rs0", "node1", primaryDB, filepath.Join(tmpDir, "rs-oplog.bin")))
+	if err != nil {
+		t.Fatalf("Failed to create replica set: %v", err)
+	}
+	defer rs.Stop()
+	if err := rs.BecomePrimary(); err != nil {
+		t.Fatalf("Failed to become primary: %v", err)
+	}
+	if err := rs.AddDelayedMember("delayed", true, 1); err != nil {
+		t.Fatalf("Failed to add delayed member: %v", err)
+	}
+
+	for _, member := range rs.GetMembers() {
+		if member.NodeID == "delayed" && (member.SecondsBehind != 1 || member.Priority != 0) {
+			t.Errorf("Expected the delayed member reported 1 second behind with priority 0, got %d, %d",
+				member.SecondsBehind, member.Priority)
+		}
+	}
+
+	// The delayed member replicates from the primary's master, reporting
+	// what it applied as its heartbeat
+	slaveConfig := DefaultSlaveConfig("delayed", delayedDB, NewLocalMasterClient(rs.master))
+	slaveConfig.PollInterval = 50 * time.Millisecond
+	slaveConfig.HeartbeatInterval = 20 * time.Millisecond
+	slaveConfig.SecondsBehind = 1
+	slave, err := NewSlave(slaveConfig)
+	if err != nil {
+		t.Fatalf("Failed to create slave: %v", err)
+	}
+	if err := slave.Start(); err != nil {
+		t.Fatalf("Failed to start slave: %v", err)
+	}
+	defer slave.Stop()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(20 * time.Millisecond):
+				rs.UpdateMemberHeartbeat("delayed", slave.GetLastAppliedOpID())
+			}
+		}
+	}()
+
+	// With only the primary and the delayed member voting, a majority write
+	// waits for the delayed member to apply it
+	start := time.Now()
+	entry := CreateInsertEntry("default", "users", map[string]interface{}{"_id": "alice"})
+	result, err := rs.WriteWithConcern(context.Background(), entry, MajorityWriteConcern().WithTimeout(5*time.Second))
+	if err != nil {
+		t.Fatalf("Majority write failed: %v", err)
+	}
+	elapsed := time.Since(start)
+	if elapsed < time.Second || elapsed > 3*time.Second {
+		t.Errorf("Expected the write acknowledged about 1s later, took %v", elapsed)
+	}
+
+	// Another write is held as long
+	start = time.Now()
+	rs.master.LogOperation(CreateInsertEntry("default", "users", map[string]interface{}{"_id": "bob"}))
+	time.Sleep(500 * time.Millisecond)
+	if opID := slave.GetLastAppliedOpID(); opID != result.OpID {
+		t.Errorf("Expected the delayed member to trail at OpID %d, got %d", result.OpID, opID)
+	}
+	if held := slave.Stats()["held_entries"].(int); held != 1 {
+		t.Errorf("Expected 1 held entry, got %d", held)
+	}
+	waitForOpID(t, slave, result.OpID+1)
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 3*time.Second {
+		t.Errorf("Expected the entry applied about 1s later, took %v", elapsed)
+	}
+	if count, _ := delayedDB.Collection("users").Count(nil); count != 2 {
+		t.Errorf("Expected 2 documents on the delayed member, got %d", count)
+	}
+}
+
+func TestDelayedMemberNotElectable(t *testing.T) {
+	rs := newTestReplicaSet(t, "node1", 1)
+	rs.config.SecondsBehind = 60
+
+	if rs.electable() {
+		t.Error("Expected a delayed node not to be electable")
+	}
+	if err := rs.AddDelayedMember("node2", true, -1); err == nil {
+		t.Error("Expected an error for a negative delay")
+	}
+}
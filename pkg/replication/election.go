@@ -0,0 +1,337 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// VoteRequest asks a member to vote for a candidate in an election
+type VoteRequest struct {
+	Term        int64  // Term the candidate stands in
+	CandidateID string // Node asking for the vote
+	Priority    int    // Priority of the candidate
+	LastOpID    OpID   // Last operation in the candidate's oplog
+}
+
+// VoteResponse is a member's answer to a VoteRequest
+type VoteResponse struct {
+	Term    int64 // Term of the member, so that a stale candidate catches up
+	Granted bool
+}
+
+// HeartbeatRequest is sent by the primary to every member it reaches
+type HeartbeatRequest struct {
+	Term      int64
+	PrimaryID string
+	Priority  int  // Priority of the primary, for priority takeover
+	LastOpID  OpID // Last operation in the primary's oplog
+}
+
+// HeartbeatResponse is a member's answer to a HeartbeatRequest
+type HeartbeatResponse struct {
+	Term     int64 // Term of the member; a higher one makes the primary step down
+	LastOpID OpID  // Last operation the member applied
+}
+
+// MemberClient is how a replica set node reaches another member for
+// elections and heartbeats
+type MemberClient interface {
+	RequestVote(ctx context.Context, req VoteRequest) (VoteResponse, error)
+	Heartbeat(ctx context.Context, req HeartbeatRequest) (HeartbeatResponse, error)
+}
+
+// LocalMemberClient implements MemberClient for a member in the same
+// process. This is useful for testing and embedded scenarios.
+type LocalMemberClient struct {
+	member *ReplicaSet
+}
+
+// NewLocalMemberClient creates a client calling member directly
+func NewLocalMemberClient(member *ReplicaSet) *LocalMemberClient {
+	return &LocalMemberClient{member: member}
+}
+
+// RequestVote asks the member for its vote
+func (c *LocalMemberClient) RequestVote(ctx context.Context, req VoteRequest) (VoteResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return VoteResponse{}, err
+	}
+	return c.member.HandleVoteRequest(req)
+}
+
+// Heartbeat sends the member a heartbeat of the primary
+func (c *LocalMemberClient) Heartbeat(ctx context.Context, req HeartbeatRequest) (HeartbeatResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return HeartbeatResponse{}, err
+	}
+	return c.member.HandleHeartbeat(req)
+}
+
+// ConnectMember sets the client used to reach a member. Connected members
+// are asked for their vote in elections and receive the primary's
+// heartbeats; members that aren't connected never vote.
+func (rs *ReplicaSet) ConnectMember(nodeID string, client MemberClient) error {
+	rs.membersMu.Lock()
+	defer rs.membersMu.Unlock()
+
+	if nodeID == rs.config.NodeID {
+		return fmt.Errorf("cannot connect to self")
+	}
+	if _, exists := rs.members[nodeID]; !exists {
+		return fmt.Errorf("member %s does not exist", nodeID)
+	}
+	rs.clients[nodeID] = client
+	return nil
+}
+
+// electable reports whether this node may stand for election: it votes, its
+// priority is above 0 and it isn't delayed
+func (rs *ReplicaSet) electable() bool {
+	return rs.config.Priority > 0 && rs.config.SecondsBehind == 0 && rs.isVoting()
+}
+
+// isVoting reports whether this node is a voting member
+func (rs *ReplicaSet) isVoting() bool {
+	if len(rs.config.VotingMembers) == 0 {
+		return true
+	}
+	for _, nodeID := range rs.config.VotingMembers {
+		if nodeID == rs.config.NodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// electionDelay returns a random timeout between ElectionTimeout and twice
+// that, so that secondaries losing the primary don't all stand at once
+func (rs *ReplicaSet) electionDelay() time.Duration {
+	timeout := rs.config.ElectionTimeout
+	if timeout <= 0 {
+		return timeout
+	}
+	return timeout + time.Duration(rand.Int63n(int64(timeout)))
+}
+
+// stopped reports whether Stop was called
+func (rs *ReplicaSet) stopped() bool {
+	select {
+	case <-rs.stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// lastOpID returns the last operation in this node's oplog. A primary logs
+// through its master's oplog.
+func (rs *ReplicaSet) lastOpID() OpID {
+	rs.mu.RLock()
+	master := rs.master
+	rs.mu.RUnlock()
+
+	if master != nil {
+		return master.GetCurrentOpID()
+	}
+	return rs.oplog.GetCurrentID()
+}
+
+// observeTerm moves to a newer term seen from another member, stepping
+// down if this node is primary. Must be called with rs.mu held.
+func (rs *ReplicaSet) observeTerm(term int64) {
+	if term <= rs.currentTerm {
+		return
+	}
+	rs.currentTerm = term
+	rs.votedFor = ""
+	if rs.role == RolePrimary {
+		rs.stepDown()
+	}
+}
+
+// requestVotes asks the connected voting members for their vote in term
+// and returns how many granted it
+func (rs *ReplicaSet) requestVotes(term int64, clients map[string]MemberClient) int {
+	req := VoteRequest{
+		Term:        term,
+		CandidateID: rs.config.NodeID,
+		Priority:    rs.config.Priority,
+		LastOpID:    rs.lastOpID(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rs.config.ElectionTimeout)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		granted int
+	)
+	for _, client := range clients {
+		wg.Add(1)
+		go func(client MemberClient) {
+			defer wg.Done()
+			resp, err := client.RequestVote(ctx, req)
+			if err != nil {
+				return // Unreachable members don't vote
+			}
+			if resp.Term > term {
+				rs.mu.Lock()
+				rs.observeTerm(resp.Term)
+				rs.mu.Unlock()
+			}
+			if resp.Granted {
+				mu.Lock()
+				granted++
+				mu.Unlock()
+			}
+		}(client)
+	}
+	wg.Wait()
+	return granted
+}
+
+// HandleVoteRequest answers a candidate's request for this node's vote. A
+// node votes once per term, and only for a candidate whose oplog is at
+// least as recent as its own and whose priority is at least its own. While
+// it follows a live primary, it only votes for a candidate of higher
+// priority, which is taking over.
+func (rs *ReplicaSet) HandleVoteRequest(req VoteRequest) (VoteResponse, error) {
+	lastOpID := rs.lastOpID()
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if !rs.isRunning {
+		return VoteResponse{}, fmt.Errorf("node %s is not running", rs.config.NodeID)
+	}
+
+	rs.observeTerm(req.Term)
+	resp := VoteResponse{Term: rs.currentTerm}
+
+	switch {
+	case req.Term < rs.currentTerm:
+	case rs.votedFor != "" && rs.votedFor != req.CandidateID:
+	case req.LastOpID < lastOpID:
+	case rs.electable() && rs.config.Priority > req.Priority:
+		// This node would make a better primary
+	case rs.currentPrimary != "" && rs.currentPrimary != req.CandidateID &&
+		time.Since(rs.lastHeartbeat) <= rs.config.ElectionTimeout &&
+		req.Priority <= rs.primaryPriority:
+		// The primary is alive and preferred
+	default:
+		rs.votedFor = req.CandidateID
+		resp.Granted = true
+		rs.resetElectionTimer()
+	}
+	return resp, nil
+}
+
+// HandleHeartbeat records a heartbeat of the primary. A heartbeat of an
+// older term is answered with this node's term, making the stale primary
+// step down. An electable node of higher priority than the primary stands
+// for election to take over once it has caught up.
+func (rs *ReplicaSet) HandleHeartbeat(req HeartbeatRequest) (HeartbeatResponse, error) {
+	lastOpID := rs.lastOpID()
+
+	rs.mu.Lock()
+	if !rs.isRunning {
+		rs.mu.Unlock()
+		return HeartbeatResponse{}, fmt.Errorf("node %s is not running", rs.config.NodeID)
+	}
+
+	rs.observeTerm(req.Term)
+	resp := HeartbeatResponse{Term: rs.currentTerm, LastOpID: lastOpID}
+	if req.Term < rs.currentTerm {
+		rs.mu.Unlock()
+		return resp, nil
+	}
+
+	rs.follow(req.PrimaryID)
+	rs.primaryPriority = req.Priority
+	rs.lastHeartbeat = time.Now()
+	rs.resetElectionTimer()
+	takeover := rs.electable() && rs.config.Priority > req.Priority && lastOpID >= req.LastOpID
+	rs.mu.Unlock()
+
+	rs.setMemberRoles(req.PrimaryID)
+	rs.UpdateMemberHeartbeat(req.PrimaryID, req.LastOpID)
+
+	if takeover {
+		go rs.startElection()
+	}
+	return resp, nil
+}
+
+// sendHeartbeats sends the primary's heartbeat to the connected members,
+// recording their progress, and steps down if one has seen a newer term
+func (rs *ReplicaSet) sendHeartbeats() {
+	rs.mu.RLock()
+	if rs.role != RolePrimary || rs.stopped() {
+		rs.mu.RUnlock()
+		return
+	}
+	term := rs.currentTerm
+	rs.mu.RUnlock()
+
+	rs.membersMu.Lock()
+	if member, exists := rs.members[rs.config.NodeID]; exists {
+		member.mu.Lock()
+		member.LastHeartbeat = time.Now()
+		member.mu.Unlock()
+	}
+	clients := make(map[string]MemberClient, len(rs.clients))
+	for nodeID, client := range rs.clients {
+		clients[nodeID] = client
+	}
+	rs.membersMu.Unlock()
+
+	req := HeartbeatRequest{
+		Term:      term,
+		PrimaryID: rs.config.NodeID,
+		Priority:  rs.config.Priority,
+		LastOpID:  rs.lastOpID(),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rs.config.HeartbeatInterval)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for nodeID, client := range clients {
+		wg.Add(1)
+		go func(nodeID string, client MemberClient) {
+			defer wg.Done()
+			resp, err := client.Heartbeat(ctx, req)
+			if err != nil {
+				return // checkMemberHealth marks it unreachable in time
+			}
+			if resp.Term > term {
+				rs.mu.Lock()
+				rs.observeTerm(resp.Term)
+				rs.mu.Unlock()
+				return
+			}
+			rs.UpdateMemberHeartbeat(nodeID, resp.LastOpID)
+		}(nodeID, client)
+	}
+	wg.Wait()
+}
+
+// setMemberRoles records primaryID as the primary among the members and
+// the others as secondaries
+func (rs *ReplicaSet) setMemberRoles(primaryID string) {
+	rs.membersMu.RLock()
+	defer rs.membersMu.RUnlock()
+
+	for nodeID, member := range rs.members {
+		member.mu.Lock()
+		if nodeID == primaryID {
+			member.Role = RolePrimary
+		} else if member.Role == RolePrimary {
+			member.Role = RoleSecondary
+		}
+		member.mu.Unlock()
+	}
+}
@@ -0,0 +1,296 @@
+package replication
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/database"
+)
+
+// newTestReplicaSet creates a stopped node with short election timings
+func newTestReplicaSet(t *testing.T, nodeID string, priority int) *ReplicaSet {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	db, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "db")))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	config := DefaultReplicaSetConfig("rs0", nodeID, db, filepath.Join(tmpDir, "oplog.bin"))
+	config.Priority = priority
+	config.HeartbeatInterval = 20 * time.Millisecond
+	config.ElectionTimeout = 100 * time.Millisecond
+	config.HeartbeatTimeout = 200 * time.Millisecond
+
+	rs, err := NewReplicaSet(config)
+	if err != nil {
+		t.Fatalf("Failed to create replica set: %v", err)
+	}
+	t.Cleanup(func() { rs.Stop() })
+	return rs
+}
+
+// newIdleReplicaSet creates a running node that answers vote requests but
+// doesn't stand for election or time out members on its own
+func newIdleReplicaSet(t *testing.T, nodeID string, priority int) *ReplicaSet {
+	t.Helper()
+	rs := newTestReplicaSet(t, nodeID, priority)
+	rs.config.ElectionTimeout = time.Minute
+	rs.config.HeartbeatTimeout = time.Minute
+	if err := rs.Start(); err != nil {
+		t.Fatalf("Failed to start replica set: %v", err)
+	}
+	return rs
+}
+
+// connectAll makes every node a member of the others, reached directly
+func connectAll(t *testing.T, nodes ...*ReplicaSet) {
+	t.Helper()
+	for _, rs := range nodes {
+		for _, other := range nodes {
+			if other == rs {
+				continue
+			}
+			if err := rs.AddMember(other.config.NodeID, other.config.Priority, other.isVoting()); err != nil {
+				t.Fatalf("Failed to add member: %v", err)
+			}
+			if err := rs.ConnectMember(other.config.NodeID, NewLocalMemberClient(other)); err != nil {
+				t.Fatalf("Failed to connect member: %v", err)
+			}
+		}
+	}
+}
+
+// primaries returns the nodes that consider themselves primary
+func primaries(nodes ...*ReplicaSet) []*ReplicaSet {
+	var result []*ReplicaSet
+	for _, rs := range nodes {
+		if rs.IsPrimary() {
+			result = append(result, rs)
+		}
+	}
+	return result
+}
+
+// waitForPrimary waits until want is the only primary among nodes and the
+// others follow it
+func waitForPrimary(t *testing.T, want *ReplicaSet, nodes ...*ReplicaSet) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		found := primaries(nodes...)
+		settled := len(found) == 1 && found[0] == want
+		for _, rs := range nodes {
+			if rs.GetPrimary() != want.config.NodeID {
+				settled = false
+			}
+		}
+		if settled {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, rs := range nodes {
+		t.Logf("%s: role %s, primary %q", rs.config.NodeID, rs.GetRole(), rs.GetPrimary())
+	}
+	t.Fatalf("Expected %s to become the only primary", want.config.NodeID)
+}
+
+func TestElectionAfterPrimaryLoss(t *testing.T) {
+	rs1 := newTestReplicaSet(t, "node1", 3)
+	rs2 := newTestReplicaSet(t, "node2", 2)
+	rs3 := newTestReplicaSet(t, "node3", 1)
+	connectAll(t, rs1, rs2, rs3)
+
+	for _, rs := range []*ReplicaSet{rs1, rs2, rs3} {
+		if err := rs.Start(); err != nil {
+			t.Fatalf("Failed to start replica set: %v", err)
+		}
+	}
+
+	// The highest priority node is elected without a manual trigger
+	waitForPrimary(t, rs1, rs1, rs2, rs3)
+
+	// Losing it elects the next one among the remaining majority
+	if err := rs1.Stop(); err != nil {
+		t.Fatalf("Failed to stop node1: %v", err)
+	}
+	waitForPrimary(t, rs2, rs2, rs3)
+
+	// The new primary stays the only one
+	time.Sleep(300 * time.Millisecond)
+	if found := primaries(rs2, rs3); len(found) != 1 || found[0] != rs2 {
+		t.Errorf("Expected node2 to remain the only primary, got %d primaries", len(found))
+	}
+}
+
+func TestElectionPriorityTakeover(t *testing.T) {
+	rs1 := newTestReplicaSet(t, "node1", 1)
+	rs2 := newTestReplicaSet(t, "node2", 1)
+	rs3 := newTestReplicaSet(t, "node3", 5)
+	connectAll(t, rs1, rs2, rs3)
+
+	if err := rs1.Start(); err != nil {
+		t.Fatalf("Failed to start node1: %v", err)
+	}
+	if err := rs2.Start(); err != nil {
+		t.Fatalf("Failed to start node2: %v", err)
+	}
+
+	// One of the equal priority nodes wins
+	deadline := time.Now().Add(5 * time.Second)
+	for len(primaries(rs1, rs2)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if found := primaries(rs1, rs2); len(found) != 1 {
+		t.Fatalf("Expected one primary among node1 and node2, got %d", len(found))
+	}
+
+	// A higher priority node joining takes over
+	if err := rs3.Start(); err != nil {
+		t.Fatalf("Failed to start node3: %v", err)
+	}
+	waitForPrimary(t, rs3, rs1, rs2, rs3)
+}
+
+func TestElectionSkipsNonElectableMembers(t *testing.T) {
+	rs1 := newTestReplicaSet(t, "node1", 0) // Never primary
+	rs2 := newTestReplicaSet(t, "node2", 1)
+	rs3 := newTestReplicaSet(t, "node3", 1)
+	for _, rs := range []*ReplicaSet{rs1, rs2, rs3} {
+		rs.config.VotingMembers = []string{"node1", "node2"} // node3 doesn't vote
+	}
+	connectAll(t, rs1, rs2, rs3)
+
+	if rs3.electable() {
+		t.Error("Expected non-voting node3 not to be electable")
+	}
+
+	for _, rs := range []*ReplicaSet{rs1, rs2, rs3} {
+		if err := rs.Start(); err != nil {
+			t.Fatalf("Failed to start replica set: %v", err)
+		}
+	}
+	waitForPrimary(t, rs2, rs1, rs2, rs3)
+
+	// Without node2 the remaining nodes can't elect a primary
+	if err := rs2.Stop(); err != nil {
+		t.Fatalf("Failed to stop node2: %v", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if found := primaries(rs1, rs3); len(found) != 0 {
+		t.Errorf("Expected no primary, got %s", found[0].config.NodeID)
+	}
+}
+
+func TestHandleVoteRequestOncePerTerm(t *testing.T) {
+	rs := newTestReplicaSet(t, "node1", 1)
+	if err := rs.Start(); err != nil {
+		t.Fatalf("Failed to start replica set: %v", err)
+	}
+
+	resp, err := rs.HandleVoteRequest(VoteRequest{Term: 5, CandidateID: "node2", Priority: 1})
+	if err != nil {
+		t.Fatalf("Failed to handle vote request: %v", err)
+	}
+	if !resp.Granted || resp.Term != 5 {
+		t.Errorf("Expected vote granted in term 5, got %+v", resp)
+	}
+
+	resp, _ = rs.HandleVoteRequest(VoteRequest{Term: 5, CandidateID: "node3", Priority: 1})
+	if resp.Granted {
+		t.Error("Expected second vote in the same term to be denied")
+	}
+
+	resp, _ = rs.HandleVoteRequest(VoteRequest{Term: 4, CandidateID: "node3", Priority: 1})
+	if resp.Granted || resp.Term != 5 {
+		t.Errorf("Expected stale term to be denied with term 5, got %+v", resp)
+	}
+}
+
+func TestElectionSkipsPriorityZeroCandidates(t *testing.T) {
+	rs1 := newTestReplicaSet(t, "node1", 1)
+	rs2 := newTestReplicaSet(t, "node2", 0)
+	rs3 := newTestReplicaSet(t, "node3", 0)
+	connectAll(t, rs1, rs2, rs3)
+
+	for _, rs := range []*ReplicaSet{rs1, rs2, rs3} {
+		if err := rs.Start(); err != nil {
+			t.Fatalf("Failed to start replica set: %v", err)
+		}
+	}
+
+	// node1 needs a priority 0 member's vote for its majority
+	waitForPrimary(t, rs1, rs1, rs2, rs3)
+
+	// Without node1 the priority 0 members never stand
+	if err := rs1.Stop(); err != nil {
+		t.Fatalf("Failed to stop node1: %v", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if found := primaries(rs2, rs3); len(found) != 0 {
+		t.Errorf("Expected no primary, got %s", found[0].config.NodeID)
+	}
+	for _, rs := range []*ReplicaSet{rs2, rs3} {
+		rs.mu.RLock()
+		term, votedFor := rs.currentTerm, rs.votedFor
+		rs.mu.RUnlock()
+		if votedFor == rs.config.NodeID {
+			t.Errorf("Expected %s never to vote for itself, did in term %d", rs.config.NodeID, term)
+		}
+	}
+}
+
+func TestElectionPartitionElectsOnePrimary(t *testing.T) {
+	nodes := []*ReplicaSet{
+		newIdleReplicaSet(t, "node1", 1),
+		newIdleReplicaSet(t, "node2", 1),
+		newIdleReplicaSet(t, "node3", 1),
+		newIdleReplicaSet(t, "node4", 1),
+		newIdleReplicaSet(t, "node5", 1),
+	}
+	minority, majority := nodes[:2], nodes[2:]
+
+	// Every node knows every member and last heard from all of them, but
+	// can only reach the members on its own side of the partition
+	for _, side := range [][]*ReplicaSet{minority, majority} {
+		for _, rs := range side {
+			for _, other := range nodes {
+				if other == rs {
+					continue
+				}
+				if err := rs.AddMember(other.config.NodeID, other.config.Priority, true); err != nil {
+					t.Fatalf("Failed to add member: %v", err)
+				}
+				if err := rs.UpdateMemberHeartbeat(other.config.NodeID, 0); err != nil {
+					t.Fatalf("Failed to update heartbeat: %v", err)
+				}
+			}
+			for _, other := range side {
+				if other == rs {
+					continue
+				}
+				if err := rs.ConnectMember(other.config.NodeID, NewLocalMemberClient(other)); err != nil {
+					t.Fatalf("Failed to connect member: %v", err)
+				}
+			}
+		}
+	}
+
+	// A candidate on each side stands in the same term
+	minority[0].startElection()
+	majority[0].startElection()
+
+	if minority[0].IsPrimary() {
+		t.Error("Expected the minority candidate to lose without a majority of granted votes")
+	}
+	if !majority[0].IsPrimary() {
+		t.Error("Expected the majority candidate to win")
+	}
+	if found := primaries(nodes...); len(found) != 1 {
+		t.Errorf("Expected one primary, got %d", len(found))
+	}
+}
@@ -0,0 +1,138 @@
+package replication
+
+import (
+	"fmt"
+
+	"github.com/mnohosten/laura-db/pkg/database"
+)
+
+// DefaultApplyBatchSize is the most consecutive inserts into one collection
+// a slave coalesces into a grouped insert by default
+const DefaultApplyBatchSize = 1000
+
+// applyInOrder applies entries one after another, coalescing consecutive
+// inserts into the same collection into grouped inserts. It stops early if
+// stop (when set) returns true between entries, and returns how many of the
+// entries were applied along with the error of the one that failed.
+func (s *Slave) applyInOrder(entries []*OplogEntry, stop func() bool) (int, error) {
+	applied := 0
+	for applied < len(entries) {
+		if stop != nil && stop() {
+			break
+		}
+
+		n := s.insertRunLength(entries[applied:])
+		if n > 1 && s.applyInserts(entries[applied:applied+n]) {
+			applied += n
+			continue
+		}
+
+		// Not coalesced, or the grouped insert failed without inserting
+		// anything: apply the entries one by one, stopping at the failing one
+		if n < 1 {
+			n = 1
+		}
+		for _, entry := range entries[applied : applied+n] {
+			if err := s.applyEntry(entry); err != nil {
+				return applied, err
+			}
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// insertRunLength returns how many of the leading entries are inserts into
+// the collection of the first that may be coalesced, or 0 if the first
+// entry isn't such an insert
+func (s *Slave) insertRunLength(entries []*OplogEntry) int {
+	first := entries[0]
+	if first.OpType != OpTypeInsert || first.TxnID != 0 || s.config.ApplyBatchSize <= 1 {
+		return 0
+	}
+
+	n := 1
+	for n < len(entries) && n < s.config.ApplyBatchSize {
+		entry := entries[n]
+		if entry.OpType != OpTypeInsert || entry.TxnID != 0 || entry.Collection != first.Collection {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// applyInserts inserts the documents of a run of insert entries with a
+// single grouped insert. InsertMany inserts all of them or none, so on
+// failure it returns false with nothing applied.
+func (s *Slave) applyInserts(run []*OplogEntry) bool {
+	docs := make([]map[string]interface{}, len(run))
+	for i, entry := range run {
+		docs[i] = entry.Document
+	}
+	if _, err := s.db.Collection(run[0].Collection).InsertMany(docs); err != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	s.groupedInserts++
+	s.coalescedEntries += int64(len(run))
+	s.mu.Unlock()
+	return true
+}
+
+// transactionLength returns how many of the leading entries belong to the
+// transaction of the first
+func transactionLength(entries []*OplogEntry) int {
+	n := 1
+	for n < len(entries) && entries[n].TxnID == entries[0].TxnID {
+		n++
+	}
+	return n
+}
+
+// applyTransaction applies the entries of a transaction in a session
+// transaction of their own: the replica sees all of their writes or, if one
+// fails, none
+func (s *Slave) applyTransaction(txn []*OplogEntry) error {
+	err := s.db.WithTransaction(func(session *database.Session) error {
+		for _, entry := range txn {
+			if err := applyInSession(session, entry); err != nil {
+				return fmt.Errorf("failed to apply entry %d: %w", entry.OpID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply transaction %d: %w", txn[0].TxnID, err)
+	}
+
+	for _, entry := range txn {
+		s.markApplied(entry.OpID, false)
+	}
+	s.mu.Lock()
+	s.appliedTxns++
+	s.mu.Unlock()
+	return nil
+}
+
+// applyInSession applies a write logged by a transaction within session
+func applyInSession(session *database.Session, entry *OplogEntry) error {
+	switch entry.OpType {
+	case OpTypeInsert:
+		if _, err := session.InsertOne(entry.Collection, entry.Document); err != nil {
+			return fmt.Errorf("insert failed: %w", err)
+		}
+	case OpTypeUpdate:
+		if err := session.UpdateOne(entry.Collection, entry.Filter, entry.Update); err != nil {
+			return fmt.Errorf("update failed: %w", err)
+		}
+	case OpTypeDelete:
+		if err := session.DeleteOne(entry.Collection, entry.Filter); err != nil {
+			return fmt.Errorf("delete failed: %w", err)
+		}
+	default:
+		return fmt.Errorf("%s is not a transactional operation", entry.OpType)
+	}
+	return nil
+}
@@ -63,8 +63,32 @@ func (c *LocalMasterClient) Unregister(ctx context.Context, slaveID string) erro
 	return c.master.UnregisterSlave(slaveID)
 }
 
-// Verify that LocalMasterClient implements MasterClient
+// GetIndexDefinitions returns the index definitions of each collection on the master
+func (c *LocalMasterClient) GetIndexDefinitions(ctx context.Context) (map[string][]map[string]interface{}, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	return c.master.GetIndexDefinitions(), nil
+}
+
+// GetCurrentOpID returns the newest OpID of the master's oplog
+func (c *LocalMasterClient) GetCurrentOpID(ctx context.Context) (OpID, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	return c.master.GetCurrentOpID(), nil
+}
+
+// Verify that LocalMasterClient implements MasterClient, IndexCatalog and OpIDReporter
 var _ MasterClient = (*LocalMasterClient)(nil)
+var _ IndexCatalog = (*LocalMasterClient)(nil)
+var _ OpIDReporter = (*LocalMasterClient)(nil)
 
 // ReplicationPair represents a master-slave pair for easy setup
 type ReplicationPair struct {
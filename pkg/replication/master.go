@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mnohosten/laura-db/pkg/database"
@@ -15,6 +16,15 @@ type MasterConfig struct {
 	OplogPath        string
 	HeartbeatTimeout time.Duration
 	MaxSlaves        int
+
+	// BatchWindow is how long a long-polled fetch woken by a new entry
+	// lingers for more before returning, so that a burst of writes ships
+	// to the slave in one round trip (0 returns the first entry at once)
+	BatchWindow time.Duration
+
+	// Oplog caps the size and age of the oplog. Entries registered slaves
+	// haven't replicated yet are kept.
+	Oplog OplogConfig
 }
 
 // DefaultMasterConfig returns default master configuration
@@ -24,6 +34,7 @@ func DefaultMasterConfig(db *database.Database, oplogPath string) *MasterConfig
 		OplogPath:        oplogPath,
 		HeartbeatTimeout: 30 * time.Second,
 		MaxSlaves:        10,
+		BatchWindow:      DefaultBatchWindow,
 	}
 }
 
@@ -37,6 +48,9 @@ type Master struct {
 	stopChan        chan struct{}
 	heartbeatTicker *time.Ticker
 	isRunning       bool
+
+	shippedBatches atomic.Int64 // Non-empty batches returned by FetchOplogBatch
+	shippedEntries atomic.Int64 // Entries in those batches
 }
 
 // SlaveInfo tracks information about a connected slave
@@ -51,18 +65,33 @@ type SlaveInfo struct {
 // NewMaster creates a new master node
 func NewMaster(config *MasterConfig) (*Master, error) {
 	// Create oplog
-	oplog, err := NewOplog(config.OplogPath)
+	oplog, err := NewOplogWithConfig(config.OplogPath, config.Oplog)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create oplog: %w", err)
 	}
 
-	return &Master{
+	m := &Master{
 		db:       config.Database,
 		oplog:    oplog,
 		config:   config,
 		slaves:   make(map[string]*SlaveInfo),
 		stopChan: make(chan struct{}),
-	}, nil
+	}
+	oplog.Retain("slaves", m.slowestSlaveOpID)
+	return m, nil
+}
+
+// slowestSlaveOpID returns the last entry replicated by the slave furthest
+// behind, or the current OpID if no slave is registered. Slaves removed for
+// missing heartbeats no longer hold back trimming.
+func (m *Master) slowestSlaveOpID() OpID {
+	slowest := m.oplog.GetCurrentID()
+	for _, slave := range m.GetAllSlaves() {
+		if slave.LastOpID < slowest {
+			slowest = slave.LastOpID
+		}
+	}
+	return slowest
 }
 
 // Start starts the master node
@@ -107,11 +136,28 @@ func (m *Master) LogOperation(entry *OplogEntry) error {
 	return nil
 }
 
-// GetOplogEntries returns oplog entries since the given OpID
+// GetOplogEntries returns oplog entries since the given OpID. It returns an
+// error wrapping ErrOplogTrimmed if some of them were trimmed.
 func (m *Master) GetOplogEntries(sinceID OpID) ([]*OplogEntry, error) {
+	if err := m.oplog.CheckRetained(sinceID); err != nil {
+		return nil, err
+	}
 	return m.oplog.GetEntriesSince(sinceID)
 }
 
+// GetIndexDefinitions returns the index definitions of each collection of
+// the master's database, for the initial sync of new slaves
+func (m *Master) GetIndexDefinitions() map[string][]map[string]interface{} {
+	defs := make(map[string][]map[string]interface{})
+	if m.db == nil {
+		return defs
+	}
+	for _, name := range m.db.ListCollections() {
+		defs[name] = m.db.Collection(name).IndexDefinitions()
+	}
+	return defs
+}
+
 // RegisterSlave registers a new slave
 func (m *Master) RegisterSlave(slaveID string) error {
 	m.mu.Lock()
@@ -276,11 +322,21 @@ func (m *Master) Stats() map[string]interface{} {
 		})
 	}
 
+	shippedBatches, shippedEntries := m.shippedBatches.Load(), m.shippedEntries.Load()
+	entriesPerBatch := 0.0
+	if shippedBatches > 0 {
+		entriesPerBatch = float64(shippedEntries) / float64(shippedBatches)
+	}
+
 	return map[string]interface{}{
-		"current_op_id":  m.oplog.GetCurrentID(),
-		"slave_count":    len(m.slaves),
-		"slaves":         slaveStats,
-		"is_running":     m.isRunning,
+		"current_op_id":     m.oplog.GetCurrentID(),
+		"slave_count":       len(m.slaves),
+		"slaves":            slaveStats,
+		"is_running":        m.isRunning,
+		"batch_window":      m.config.BatchWindow.String(),
+		"shipped_batches":   shippedBatches,
+		"shipped_entries":   shippedEntries,
+		"entries_per_batch": entriesPerBatch,
 	}
 }
 
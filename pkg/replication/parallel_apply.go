@@ -0,0 +1,234 @@
+package replication
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultApplyConcurrency is the number of streams a slave applies oplog
+// entries with by default
+const DefaultApplyConcurrency = 4
+
+// Fetched entries are applied in runs. Within a run every entry is keyed by
+// the document it writes, and entries are hashed by key onto apply streams:
+// a stream applies its entries in oplog order, so two writes to the same
+// document are never reordered, while writes to different documents run
+// concurrently. Entries that can't be tied to a single document - collection
+// and index changes, or updates and deletes with a filter other than _id -
+// end the run and are applied alone once everything before them is done.
+// Collections with a unique index besides _id are keyed by collection
+// instead, since the order of writes to different documents decides which
+// of them hits a duplicate key.
+//
+// Wherever entries are applied in order - serially, or within a stream -
+// consecutive inserts into the same collection are coalesced into one
+// grouped insert, up to ApplyBatchSize documents. The entries of a
+// transaction (those sharing a TxnID) always arrive in one batch; they end
+// the run and are applied together in a single transaction once everything
+// before them is done, so a replica never shows part of one.
+
+// applyEntries applies fetched entries, advancing lastAppliedOpID past each
+// one applied along with everything before it
+func (s *Slave) applyEntries(entries []*OplogEntry) error {
+	start := time.Now()
+	defer func() {
+		s.mu.Lock()
+		s.applyTime += time.Since(start)
+		s.mu.Unlock()
+	}()
+
+	streams := s.config.ApplyConcurrency
+	for len(entries) > 0 {
+		if entries[0].TxnID != 0 {
+			n := transactionLength(entries)
+			if err := s.applyTransaction(entries[:n]); err != nil {
+				return err
+			}
+			entries = entries[n:]
+			continue
+		}
+
+		if streams <= 1 {
+			n := 1
+			for n < len(entries) && entries[n].TxnID == 0 {
+				n++
+			}
+			applied, err := s.applyInOrder(entries[:n], nil)
+			for _, entry := range entries[:applied] {
+				s.markApplied(entry.OpID, false)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to apply entry %d: %w", entries[applied].OpID, err)
+			}
+			entries = entries[n:]
+			continue
+		}
+
+		keys := s.applyRun(entries)
+		if len(keys) == 0 {
+			// Applied alone, after everything before it
+			entry := entries[0]
+			if err := s.applyEntry(entry); err != nil {
+				return fmt.Errorf("failed to apply entry %d: %w", entry.OpID, err)
+			}
+			s.markApplied(entry.OpID, false)
+			s.mu.Lock()
+			s.applyBarriers++
+			s.mu.Unlock()
+			entries = entries[1:]
+			continue
+		}
+
+		if err := s.applyParallel(entries[:len(keys)], keys, streams); err != nil {
+			return err
+		}
+		entries = entries[len(keys):]
+	}
+	return nil
+}
+
+// applyRun returns the keys of the leading entries that can be applied
+// concurrently, stopping at the first entry that must be applied alone
+func (s *Slave) applyRun(entries []*OplogEntry) []string {
+	unique := make(map[string]bool)
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		key, ok := s.applyKey(entry, unique)
+		if !ok {
+			break
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// applyKey returns the key ordering an entry against the others in its run.
+// unique caches which collections have a unique secondary index.
+func (s *Slave) applyKey(entry *OplogEntry, unique map[string]bool) (string, bool) {
+	if entry.TxnID != 0 {
+		return "", false
+	}
+
+	var id interface{}
+	switch entry.OpType {
+	case OpTypeInsert:
+		id = entry.Document["_id"]
+	case OpTypeUpdate, OpTypeDelete:
+		id = filterID(entry)
+	case OpTypeNoop:
+		return "", true
+	default:
+		return "", false
+	}
+	if id == nil {
+		return "", false
+	}
+
+	hasUnique, checked := unique[entry.Collection]
+	if !checked {
+		hasUnique = s.hasUniqueIndex(entry.Collection)
+		unique[entry.Collection] = hasUnique
+	}
+	if hasUnique {
+		return entry.Collection, true
+	}
+	return fmt.Sprintf("%s\x00%v", entry.Collection, id), true
+}
+
+// filterID returns the _id an update or delete writes, or nil if its filter
+// may match other documents
+func filterID(entry *OplogEntry) interface{} {
+	if entry.DocID != nil {
+		return entry.DocID
+	}
+	if len(entry.Filter) != 1 {
+		return nil
+	}
+	id, ok := entry.Filter["_id"]
+	if !ok {
+		return nil
+	}
+	if _, isOperator := id.(map[string]interface{}); isOperator {
+		return nil
+	}
+	return id
+}
+
+// hasUniqueIndex reports whether a collection has a unique index other
+// than the _id index
+func (s *Slave) hasUniqueIndex(collName string) bool {
+	for _, idx := range s.db.Collection(collName).ListIndexes() {
+		if idx["name"] != "_id_" && idx["unique"] == true {
+			return true
+		}
+	}
+	return false
+}
+
+// applyParallel applies a run of entries on up to streams goroutines. On
+// error the remaining entries are abandoned and lastAppliedOpID stops before
+// the first entry that wasn't applied; entries after it that were applied
+// will be applied again with the next fetch.
+func (s *Slave) applyParallel(run []*OplogEntry, keys []string, streams int) error {
+	queues := make([][]int, streams)
+	for i, key := range keys {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		stream := int(h.Sum32() % uint32(streams))
+		queues[stream] = append(queues[stream], i)
+	}
+
+	applied := make([]bool, len(run))
+	errs := make([]error, len(run))
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for _, queue := range queues {
+		if len(queue) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(queue []int) {
+			defer wg.Done()
+			stream := make([]*OplogEntry, len(queue))
+			for j, i := range queue {
+				stream[j] = run[i]
+			}
+			n, err := s.applyInOrder(stream, failed.Load)
+			for _, i := range queue[:n] {
+				applied[i] = true
+			}
+			if err != nil {
+				errs[queue[n]] = err
+				failed.Store(true)
+			}
+		}(queue)
+	}
+	wg.Wait()
+
+	for i, entry := range run {
+		if !applied[i] {
+			break
+		}
+		s.markApplied(entry.OpID, true)
+	}
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("failed to apply entry %d: %w", run[i].OpID, err)
+		}
+	}
+	return nil
+}
+
+// markApplied advances lastAppliedOpID and the apply counters
+func (s *Slave) markApplied(opID OpID, parallel bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastAppliedOpID = opID
+	s.appliedEntries++
+	if parallel {
+		s.parallelEntries++
+	}
+}
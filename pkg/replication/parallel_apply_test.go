@@ -0,0 +1,330 @@
+package replication
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/database"
+)
+
+// newApplySlave returns a slave over a fresh database that applies the
+// given entries, numbering them in order
+func newApplySlave(t *testing.T, dir string, concurrency int, entries []*OplogEntry) (*Slave, *database.Database) {
+	t.Helper()
+
+	for i, entry := range entries {
+		entry.OpID = OpID(i + 1)
+	}
+
+	db, err := database.Open(database.DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	config := DefaultSlaveConfig("slave1", db, &mockMasterClient{entries: entries})
+	config.ApplyConcurrency = concurrency
+	slave, err := NewSlave(config)
+	if err != nil {
+		t.Fatalf("Failed to create slave: %v", err)
+	}
+	return slave, db
+}
+
+// numberValue returns a numeric document field as a float64
+func numberValue(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return -1
+}
+
+func TestParallelApplyPreservesDocumentOrder(t *testing.T) {
+	const (
+		docs    = 40
+		updates = 24
+	)
+
+	for round := 0; round < 5; round++ {
+		rng := rand.New(rand.NewSource(int64(round)))
+
+		// Per-document op sequences, interleaved at random across documents.
+		// Updates alternate $mul and $inc, so any reordering changes the
+		// final value, and every third document is deleted and inserted
+		// again midway, which fails if the re-insert overtakes the delete.
+		pending := make([][]*OplogEntry, docs)
+		expected := make([]float64, docs)
+		for d := 0; d < docs; d++ {
+			id := fmt.Sprintf("doc%d", d)
+			coll := fmt.Sprintf("coll%d", d%3)
+			filter := map[string]interface{}{"_id": id}
+			ops := []*OplogEntry{CreateInsertEntry("default", coll, map[string]interface{}{"_id": id, "v": int64(1)})}
+			v := 1.0
+			for u := 1; u <= updates; u++ {
+				if d%3 == 0 && u == updates/2 {
+					ops = append(ops,
+						CreateDeleteEntry("default", coll, filter),
+						CreateInsertEntry("default", coll, map[string]interface{}{"_id": id, "v": int64(1)}))
+					v = 1
+				}
+				update := map[string]interface{}{"$inc": map[string]interface{}{"v": int64(u)}}
+				v += float64(u)
+				if u%2 == 1 {
+					update = map[string]interface{}{"$mul": map[string]interface{}{"v": int64(2)}}
+					v -= float64(u)
+					v *= 2
+				}
+				ops = append(ops, CreateUpdateEntry("default", coll, filter, update))
+			}
+			pending[d] = ops
+			expected[d] = v
+		}
+
+		var entries []*OplogEntry
+		for remaining := docs; remaining > 0; {
+			d := rng.Intn(docs)
+			if len(pending[d]) == 0 {
+				continue
+			}
+			entries = append(entries, pending[d][0])
+			pending[d] = pending[d][1:]
+			if len(pending[d]) == 0 {
+				remaining--
+			}
+		}
+
+		slave, db := newApplySlave(t, filepath.Join(t.TempDir(), "slave"), 8, entries)
+		if err := slave.fetchAndApplyEntries(); err != nil {
+			t.Fatalf("Round %d: failed to apply entries: %v", round, err)
+		}
+		if got := slave.GetLastAppliedOpID(); got != OpID(len(entries)) {
+			t.Fatalf("Round %d: expected last applied %d, got %d", round, len(entries), got)
+		}
+
+		for d := 0; d < docs; d++ {
+			id := fmt.Sprintf("doc%d", d)
+			doc, err := db.Collection(fmt.Sprintf("coll%d", d%3)).FindOne(map[string]interface{}{"_id": id})
+			if err != nil {
+				t.Fatalf("Round %d: %s not found: %v", round, id, err)
+			}
+			if v, _ := doc.Get("v"); numberValue(v) != expected[d] {
+				t.Fatalf("Round %d: %s has v=%v, want %v: updates were reordered", round, id, v, expected[d])
+			}
+		}
+
+		stats := slave.Stats()
+		if stats["parallel_applied_entries"].(int64) != int64(len(entries)) {
+			t.Errorf("Round %d: expected all %d entries applied in parallel, got %v", round, len(entries), stats["parallel_applied_entries"])
+		}
+	}
+}
+
+func TestParallelApplyBarriers(t *testing.T) {
+	entries := []*OplogEntry{
+		CreateCollectionEntry("default", "users", true),
+		CreateInsertEntry("default", "users", map[string]interface{}{"_id": "u1", "name": "Alice", "age": 30}),
+		CreateInsertEntry("default", "users", map[string]interface{}{"_id": "u2", "name": "Bob", "age": 25}),
+		// Matched by name, so it may touch any document
+		CreateUpdateEntry("default", "users", map[string]interface{}{"name": "Alice"},
+			map[string]interface{}{"$set": map[string]interface{}{"age": 31}}),
+		CreateUpdateEntry("default", "users", map[string]interface{}{"_id": "u1"},
+			map[string]interface{}{"$set": map[string]interface{}{"name": "Alicia"}}),
+		CreateDeleteEntry("default", "users", map[string]interface{}{"_id": "u2"}),
+	}
+
+	slave, db := newApplySlave(t, filepath.Join(t.TempDir(), "slave"), 4, entries)
+	if err := slave.fetchAndApplyEntries(); err != nil {
+		t.Fatalf("Failed to apply entries: %v", err)
+	}
+
+	doc, err := db.Collection("users").FindOne(map[string]interface{}{"_id": "u1"})
+	if err != nil {
+		t.Fatalf("u1 not found: %v", err)
+	}
+	if name, _ := doc.Get("name"); name != "Alicia" {
+		t.Errorf("Expected name Alicia, got %v", name)
+	}
+	if age, _ := doc.Get("age"); fmt.Sprint(age) != "31" {
+		t.Errorf("Expected age 31, got %v", age)
+	}
+	if _, err := db.Collection("users").FindOne(map[string]interface{}{"_id": "u2"}); err == nil {
+		t.Error("Expected u2 to be deleted")
+	}
+
+	stats := slave.Stats()
+	if stats["apply_barriers"].(int64) != 2 {
+		t.Errorf("Expected 2 entries applied alone, got %v", stats["apply_barriers"])
+	}
+	if stats["applied_entries"].(int64) != int64(len(entries)) {
+		t.Errorf("Expected %d applied entries, got %v", len(entries), stats["applied_entries"])
+	}
+}
+
+func TestParallelApplyUniqueIndex(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "slave")
+
+	// The same email moves between documents, which only works in order
+	var entries []*OplogEntry
+	for i := 0; i < 50; i++ {
+		id := fmt.Sprintf("u%d", i)
+		entries = append(entries,
+			CreateInsertEntry("default", "users", map[string]interface{}{"_id": id, "email": "shared@example.com"}),
+			CreateDeleteEntry("default", "users", map[string]interface{}{"_id": id}))
+	}
+
+	slave, db := newApplySlave(t, dir, 8, entries)
+	if err := db.Collection("users").CreateIndex("email", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := slave.fetchAndApplyEntries(); err != nil {
+		t.Fatalf("Failed to apply entries: %v", err)
+	}
+	if count, _ := db.Collection("users").Count(map[string]interface{}{}); count != 0 {
+		t.Errorf("Expected no documents left, got %d", count)
+	}
+}
+
+func TestParallelApplyStopsAtFailure(t *testing.T) {
+	entries := []*OplogEntry{
+		CreateInsertEntry("default", "users", map[string]interface{}{"_id": "u1"}),
+		CreateInsertEntry("default", "users", map[string]interface{}{"_id": "u2"}),
+		CreateUpdateEntry("default", "users", map[string]interface{}{"_id": "missing"},
+			map[string]interface{}{"$set": map[string]interface{}{"x": 1}}),
+		CreateInsertEntry("default", "users", map[string]interface{}{"_id": "u3"}),
+	}
+
+	slave, _ := newApplySlave(t, filepath.Join(t.TempDir(), "slave"), 4, entries)
+	if err := slave.fetchAndApplyEntries(); err == nil {
+		t.Fatal("Expected an error applying an update of a missing document")
+	}
+	// Other streams may stop early, but never past the failed entry
+	if got := slave.GetLastAppliedOpID(); got >= 3 {
+		t.Errorf("Expected last applied OpID before 3, got %d", got)
+	}
+}
+
+func TestSerialApply(t *testing.T) {
+	entries := []*OplogEntry{
+		CreateInsertEntry("default", "users", map[string]interface{}{"_id": "u1"}),
+		CreateInsertEntry("default", "users", map[string]interface{}{"_id": "u2"}),
+		CreateDeleteEntry("default", "users", map[string]interface{}{"_id": "u1"}),
+	}
+
+	slave, db := newApplySlave(t, filepath.Join(t.TempDir(), "slave"), 1, entries)
+	if err := slave.fetchAndApplyEntries(); err != nil {
+		t.Fatalf("Failed to apply entries: %v", err)
+	}
+	if count, _ := db.Collection("users").Count(map[string]interface{}{}); count != 1 {
+		t.Errorf("Expected 1 document, got %d", count)
+	}
+
+	stats := slave.Stats()
+	if stats["parallel_applied_entries"].(int64) != 0 || stats["applied_entries"].(int64) != 3 {
+		t.Errorf("Expected 3 entries applied serially, got %v", stats)
+	}
+}
+
+func TestApplyCoalescesInserts(t *testing.T) {
+	var entries []*OplogEntry
+	for i := 0; i < 100; i++ {
+		entries = append(entries, CreateInsertEntry("default", "events", map[string]interface{}{"_id": fmt.Sprintf("e%d", i), "n": int64(i)}))
+	}
+	entries = append(entries, CreateUpdateEntry("default", "events", map[string]interface{}{"_id": "e0"},
+		map[string]interface{}{"$set": map[string]interface{}{"n": int64(-1)}}))
+	for i := 0; i < 3; i++ {
+		entries = append(entries, CreateInsertEntry("default", "other", map[string]interface{}{"_id": fmt.Sprintf("o%d", i)}))
+	}
+
+	slave, db := newApplySlave(t, filepath.Join(t.TempDir(), "slave"), 1, entries)
+	if err := slave.fetchAndApplyEntries(); err != nil {
+		t.Fatalf("Failed to apply entries: %v", err)
+	}
+	if count, _ := db.Collection("events").Count(nil); count != 100 {
+		t.Errorf("Expected 100 events, got %d", count)
+	}
+	if doc, _ := db.Collection("events").FindOne(map[string]interface{}{"_id": "e0"}); doc == nil {
+		t.Error("Expected e0 on the slave")
+	} else if n, _ := doc.Get("n"); numberValue(n) != -1 {
+		t.Errorf("Expected the update applied after the grouped insert, got n=%v", n)
+	}
+
+	stats := slave.Stats()
+	if stats["grouped_inserts"].(int64) != 2 || stats["coalesced_entries"].(int64) != 103 {
+		t.Errorf("Expected 103 entries coalesced into 2 grouped inserts, got %v", stats)
+	}
+	if stats["applied_entries"].(int64) != int64(len(entries)) || slave.GetLastAppliedOpID() != OpID(len(entries)) {
+		t.Errorf("Expected all %d entries applied, got %v", len(entries), stats["applied_entries"])
+	}
+}
+
+func TestApplyCoalescedInsertFailure(t *testing.T) {
+	var entries []*OplogEntry
+	for i := 1; i <= 5; i++ {
+		entries = append(entries, CreateInsertEntry("default", "users", map[string]interface{}{"_id": fmt.Sprintf("u%d", i)}))
+	}
+
+	slave, db := newApplySlave(t, filepath.Join(t.TempDir(), "slave"), 1, entries)
+	db.Collection("users").InsertOne(map[string]interface{}{"_id": "u3"})
+
+	// The grouped insert fails as a whole, and the entries before the
+	// duplicate are applied one by one
+	if err := slave.fetchAndApplyEntries(); err == nil {
+		t.Fatal("Expected an error inserting a duplicate _id")
+	}
+	if got := slave.GetLastAppliedOpID(); got != 2 {
+		t.Errorf("Expected last applied OpID 2, got %d", got)
+	}
+	if count, _ := db.Collection("users").Count(nil); count != 3 {
+		t.Errorf("Expected u1, u2 and u3, got %d documents", count)
+	}
+	if grouped := slave.Stats()["grouped_inserts"].(int64); grouped != 0 {
+		t.Errorf("Expected no grouped inserts, got %d", grouped)
+	}
+}
+
+func TestApplyTransactions(t *testing.T) {
+	entries := []*OplogEntry{
+		CreateInsertEntry("default", "accounts", map[string]interface{}{"_id": "a", "balance": int64(1)}),
+		CreateInsertEntry("default", "accounts", map[string]interface{}{"_id": "b", "balance": int64(2)}),
+		CreateUpdateEntry("default", "accounts", map[string]interface{}{"_id": "a"},
+			map[string]interface{}{"$set": map[string]interface{}{"balance": int64(10)}}),
+		CreateInsertEntry("default", "accounts", map[string]interface{}{"_id": "c"}),
+		CreateInsertEntry("default", "accounts", map[string]interface{}{"_id": "d"}),
+		// Fails on the duplicate, so d must not be applied either
+		CreateInsertEntry("default", "accounts", map[string]interface{}{"_id": "a"}),
+	}
+	entries[1].TxnID, entries[2].TxnID = 2, 2
+	entries[4].TxnID, entries[5].TxnID = 5, 5
+
+	slave, db := newApplySlave(t, filepath.Join(t.TempDir(), "slave"), 4, entries)
+	if err := slave.fetchAndApplyEntries(); err == nil {
+		t.Fatal("Expected the second transaction to fail")
+	}
+	if got := slave.GetLastAppliedOpID(); got != 4 {
+		t.Errorf("Expected last applied OpID 4, got %d", got)
+	}
+
+	doc, err := db.Collection("accounts").FindOne(map[string]interface{}{"_id": "a"})
+	if err != nil {
+		t.Fatalf("a not found: %v", err)
+	}
+	if balance, _ := doc.Get("balance"); numberValue(balance) != 10 {
+		t.Errorf("Expected the first transaction applied, got balance %v", balance)
+	}
+	if _, err := db.Collection("accounts").FindOne(map[string]interface{}{"_id": "d"}); err == nil {
+		t.Error("Expected no part of the failed transaction applied")
+	}
+	if count, _ := db.Collection("accounts").Count(nil); count != 3 {
+		t.Errorf("Expected a, b and c, got %d documents", count)
+	}
+	if txns := slave.Stats()["applied_transactions"].(int64); txns != 1 {
+		t.Errorf("Expected 1 applied transaction, got %d", txns)
+	}
+}
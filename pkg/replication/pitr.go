@@ -0,0 +1,292 @@
+package replication
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/backup"
+	"github.com/mnohosten/laura-db/pkg/database"
+)
+
+// RestoreToTime performs a point-in-time restore: it rebuilds a database in
+// dataDir from a base backup read from r, then replays oplog entries recorded
+// after the backup's snapshot started, up to and including target.
+// Returns the open database and the number of oplog entries replayed.
+func RestoreToTime(r io.Reader, dataDir string, oplog *Oplog, target time.Time) (*database.Database, int, error) {
+	backupFormat, err := backup.NewRestorer().RestoreFromReader(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start := SnapshotStart(backupFormat)
+	if target.Before(start) {
+		return nil, 0, fmt.Errorf("target time %s is before the backup snapshot (%s)",
+			target.Format(time.RFC3339), start.Format(time.RFC3339))
+	}
+
+	db, err := database.Open(database.DefaultConfig(dataDir))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Restore(backupFormat, backup.DefaultRestoreOptions()); err != nil {
+		db.Close()
+		return nil, 0, fmt.Errorf("failed to restore base backup: %w", err)
+	}
+
+	entries, err := oplog.GetEntriesSince(0)
+	if err != nil {
+		db.Close()
+		return nil, 0, fmt.Errorf("failed to read oplog: %w", err)
+	}
+
+	applied, err := ReplayOplog(db, entries, start, target)
+	if err != nil {
+		db.Close()
+		return nil, applied, err
+	}
+
+	return db, applied, nil
+}
+
+// SnapshotStart returns the time a backup snapshot started. Oplog entries
+// after this time must be replayed on top of the backup.
+func SnapshotStart(backupFormat *backup.BackupFormat) time.Time {
+	if s, ok := backupFormat.Metadata["snapshot_start"].(string); ok {
+		if ts, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return ts
+		}
+	}
+	return backupFormat.Timestamp
+}
+
+// ReplayOplog applies entries with from < timestamp <= until to db in order.
+// Replay is idempotent so that operations already captured by a backup,
+// whose snapshot starts just after snapshot_start, can be applied again: inserts replace an existing document with the
+// same _id, and updates or deletes of missing documents are ignored.
+func ReplayOplog(db *database.Database, entries []*OplogEntry, from, until time.Time) (int, error) {
+	applied := 0
+	for _, entry := range entries {
+		if !entry.Timestamp.After(from) || entry.Timestamp.After(until) {
+			continue
+		}
+
+		if err := replayEntry(db, entry); err != nil {
+			return applied, fmt.Errorf("failed to replay op %d (%s): %w", entry.OpID, entry.OpType, err)
+		}
+		applied++
+	}
+
+	return applied, nil
+}
+
+// replayEntry applies a single oplog entry idempotently
+func replayEntry(db *database.Database, entry *OplogEntry) error {
+	switch entry.OpType {
+	case OpTypeInsert:
+		coll := db.Collection(entry.Collection)
+		doc := make(map[string]interface{}, len(entry.Document))
+		for k, v := range entry.Document {
+			doc[k] = v
+		}
+		if id, ok := doc["_id"]; ok {
+			if err := coll.DeleteOne(map[string]interface{}{"_id": id}); err != nil && !errors.Is(err, database.ErrDocumentNotFound) {
+				return err
+			}
+		}
+		if _, err := coll.InsertOne(doc); err != nil {
+			return err
+		}
+
+	case OpTypeUpdate:
+		err := db.Collection(entry.Collection).UpdateOne(entry.Filter, entry.Update)
+		if err != nil && !errors.Is(err, database.ErrDocumentNotFound) {
+			return err
+		}
+
+	case OpTypeDelete:
+		err := db.Collection(entry.Collection).DeleteOne(entry.Filter)
+		if err != nil && !errors.Is(err, database.ErrDocumentNotFound) {
+			return err
+		}
+
+	case OpTypeCreateCollection:
+		db.Collection(entry.Collection)
+
+	case OpTypeDropCollection:
+		if err := db.DropCollection(entry.Collection); err != nil {
+			// Ignore if collection doesn't exist
+			if err.Error() != fmt.Sprintf("collection %s does not exist", entry.Collection) {
+				return err
+			}
+		}
+
+	case OpTypeTruncateCollection:
+		if err := db.Collection(entry.Collection).Truncate(); err != nil {
+			return err
+		}
+
+	case OpTypeRenameCollection:
+		if err := applyRename(db, entry); err != nil {
+			return err
+		}
+
+	case OpTypeCreateIndex:
+		if err := db.Collection(entry.Collection).CreateIndexFromDefinition(entry.IndexDef); err != nil {
+			return err
+		}
+
+	case OpTypeDropIndex:
+		if err := applyDropIndex(db, entry); err != nil {
+			return err
+		}
+
+	case OpTypeNoop:
+
+	default:
+		return fmt.Errorf("unknown operation type: %d", entry.OpType)
+	}
+
+	return nil
+}
+
+// ArchiveTarget is the point a restore from archived WAL stops at. Writes
+// logged after either bound are not replayed; zero values don't bound.
+type ArchiveTarget struct {
+	LSN  uint64    // Last WAL record to replay
+	Time time.Time // Latest write time to replay
+}
+
+// RestoreFromArchive rebuilds a database in dataDir from a base backup read
+// from r, then replays the collection writes that a WALArchiver shipped to
+// sink after the backup's WAL position, up to target. It fails with an error
+// wrapping ErrArchiveGap if records needed to reach target are missing from
+// the archive. Returns the open database and the number of writes replayed.
+func RestoreFromArchive(r io.Reader, dataDir string, sink ArchiveSink, target ArchiveTarget) (*database.Database, int, error) {
+	backupFormat, err := backup.NewRestorer().RestoreFromReader(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	startLSN, ok := BackupWALLSN(backupFormat)
+	if !ok {
+		return nil, 0, fmt.Errorf("backup has no WAL position to replay archived WAL from")
+	}
+	if target.LSN != 0 && target.LSN < startLSN {
+		return nil, 0, fmt.Errorf("target LSN %d is before the backup's WAL position (%d)", target.LSN, startLSN)
+	}
+	if !target.Time.IsZero() && target.Time.Before(SnapshotStart(backupFormat)) {
+		return nil, 0, fmt.Errorf("target time %s is before the backup snapshot (%s)",
+			target.Time.Format(time.RFC3339), SnapshotStart(backupFormat).Format(time.RFC3339))
+	}
+
+	segments, err := listSegments(sink)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	db, err := database.Open(database.DefaultConfig(dataDir))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Restore(backupFormat, backup.DefaultRestoreOptions()); err != nil {
+		db.Close()
+		return nil, 0, fmt.Errorf("failed to restore base backup: %w", err)
+	}
+
+	applied, err := replayArchive(db, sink, segments, startLSN, target)
+	if err != nil {
+		db.Close()
+		return nil, applied, err
+	}
+
+	return db, applied, nil
+}
+
+// BackupWALLSN returns the WAL position recorded when a backup snapshot
+// started. Archived writes after it must be replayed on top of the backup.
+func BackupWALLSN(backupFormat *backup.BackupFormat) (uint64, bool) {
+	s, ok := backupFormat.Metadata["wal_lsn"].(string)
+	if !ok {
+		return 0, false
+	}
+	lsn, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return lsn, true
+}
+
+// replayArchive applies the archived writes after lsn up to target, walking
+// the segment chain and failing at the first hole in it
+func replayArchive(db *database.Database, sink ArchiveSink, segments []string, lsn uint64, target ArchiveTarget) (int, error) {
+	applied := 0
+	for _, name := range segments {
+		prevLSN, lastLSN, _ := parseSegmentName(name)
+		if lastLSN <= lsn {
+			continue // Covered by the backup or an earlier segment
+		}
+		if target.LSN != 0 && lsn >= target.LSN {
+			return applied, nil
+		}
+		if prevLSN > lsn {
+			return applied, fmt.Errorf("%w: no archived records from LSN %d to %d", ErrArchiveGap, lsn+1, prevLSN)
+		}
+
+		data, err := sink.Get(name)
+		if err != nil {
+			return applied, fmt.Errorf("failed to fetch WAL segment %s: %w", name, err)
+		}
+		var segment WALSegment
+		if err := json.Unmarshal(data, &segment); err != nil {
+			return applied, fmt.Errorf("invalid WAL segment %s: %w", name, err)
+		}
+		if segment.Gap {
+			return applied, fmt.Errorf("%w: WAL records after LSN %d were truncated before shipping", ErrArchiveGap, segment.PrevLSN)
+		}
+
+		for _, record := range segment.Records {
+			if record.LSN <= lsn {
+				continue
+			}
+			if err := convertArchivedEntry(record.Entry); err != nil {
+				return applied, fmt.Errorf("invalid WAL record %d: %w", record.LSN, err)
+			}
+			if target.LSN != 0 && record.LSN > target.LSN {
+				return applied, nil
+			}
+			if !target.Time.IsZero() && record.Entry.Timestamp.After(target.Time) {
+				return applied, nil
+			}
+
+			if err := replayEntry(db, record.Entry); err != nil {
+				return applied, fmt.Errorf("failed to replay WAL record %d (%s): %w", record.LSN, record.Entry.OpType, err)
+			}
+			applied++
+		}
+		lsn = lastLSN
+	}
+
+	return applied, nil
+}
+
+// convertArchivedEntry restores the value types of an entry decoded from a
+// WAL segment the way a backup's documents are restored
+func convertArchivedEntry(entry *OplogEntry) error {
+	for _, fields := range []*map[string]interface{}{&entry.Document, &entry.Update, &entry.Filter} {
+		if *fields == nil {
+			continue
+		}
+		converted, err := backup.ConvertValueFromBackup(*fields)
+		if err != nil {
+			return err
+		}
+		*fields = converted.(map[string]interface{})
+	}
+	return nil
+}
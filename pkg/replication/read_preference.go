@@ -56,6 +56,10 @@ type ReadPreference struct {
 	// Tags - optional tag filters for selecting specific nodes
 	Tags map[string]string
 
+	// AfterClusterTime - only read from nodes that have applied this
+	// operation (0 = no requirement), for causally consistent reads
+	AfterClusterTime OpID
+
 	mu sync.RWMutex
 }
 
@@ -109,6 +113,14 @@ func (rp *ReadPreference) WithTags(tags map[string]string) *ReadPreference {
 	return rp
 }
 
+// WithAfterClusterTime requires reads to observe the given operation
+func (rp *ReadPreference) WithAfterClusterTime(opID OpID) *ReadPreference {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	rp.AfterClusterTime = opID
+	return rp
+}
+
 // GetMode returns the read preference mode
 func (rp *ReadPreference) GetMode() ReadPreferenceMode {
 	rp.mu.RLock()
@@ -136,6 +148,26 @@ func (rp *ReadPreference) GetTags() map[string]string {
 	return tags
 }
 
+// GetAfterClusterTime returns the operation reads must observe
+func (rp *ReadPreference) GetAfterClusterTime() OpID {
+	rp.mu.RLock()
+	defer rp.mu.RUnlock()
+	return rp.AfterClusterTime
+}
+
+// clone returns a copy of the read preference
+func (rp *ReadPreference) clone() *ReadPreference {
+	rp.mu.RLock()
+	defer rp.mu.RUnlock()
+
+	return &ReadPreference{
+		Mode:                rp.Mode,
+		MaxStalenessSeconds: rp.MaxStalenessSeconds,
+		Tags:                rp.Tags,
+		AfterClusterTime:    rp.AfterClusterTime,
+	}
+}
+
 // String returns a string representation of the read preference
 func (rp *ReadPreference) String() string {
 	rp.mu.RLock()
@@ -148,6 +180,9 @@ func (rp *ReadPreference) String() string {
 	if len(rp.Tags) > 0 {
 		s += fmt.Sprintf(", tags=%v", rp.Tags)
 	}
+	if rp.AfterClusterTime > 0 {
+		s += fmt.Sprintf(", afterClusterTime=%d", rp.AfterClusterTime)
+	}
 	s += "}"
 	return s
 }
@@ -160,6 +195,7 @@ type NodeCandidate struct {
 	Lag      time.Duration
 	Latency  time.Duration
 	Tags     map[string]string
+	LastOpID OpID // Last operation the node has applied
 }
 
 // ReadPreferenceSelector selects appropriate nodes based on read preference
@@ -175,12 +211,38 @@ func NewReadPreferenceSelector(rs *ReplicaSet) *ReadPreferenceSelector {
 	}
 }
 
-// SelectNode selects a node based on the read preference
+// SelectNode selects a node based on the read preference. With an
+// AfterClusterTime, secondaries that haven't applied that operation are
+// skipped; modes that allow the primary fall back to it, and ReadSecondary
+// waits for a secondary to catch up until ctx is done.
 func (s *ReadPreferenceSelector) SelectNode(ctx context.Context, pref *ReadPreference) (string, error) {
 	if pref == nil {
 		pref = Primary() // Default to primary
 	}
 
+	nodeID, err := s.selectNode(pref)
+	after := pref.GetAfterClusterTime()
+	if err == nil || after == 0 || pref.GetMode() != ReadSecondary {
+		return nodeID, err
+	}
+
+	// Wait for a secondary to apply the operation
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("no secondary has applied operation %d: %w", after, ctx.Err())
+		case <-ticker.C:
+			if nodeID, err := s.selectNode(pref); err == nil {
+				return nodeID, nil
+			}
+		}
+	}
+}
+
+// selectNode selects a node among the current candidates
+func (s *ReadPreferenceSelector) selectNode(pref *ReadPreference) (string, error) {
 	candidates := s.getCandidates()
 	if len(candidates) == 0 {
 		return "", fmt.Errorf("no nodes available")
@@ -224,18 +286,19 @@ func (s *ReadPreferenceSelector) getCandidates() []*NodeCandidate {
 	candidates := make([]*NodeCandidate, 0, len(members))
 
 	for _, member := range members {
-		// Only include healthy nodes
-		if member.State != StateHealthy {
+		// Only include healthy nodes, and never hidden ones
+		if member.State != StateHealthy || member.Hidden {
 			continue
 		}
 
 		candidates = append(candidates, &NodeCandidate{
-			NodeID:  member.NodeID,
-			Role:    member.Role,
-			State:   member.State,
-			Lag:     member.Lag,
-			Latency: 0, // Would be measured in real implementation
-			Tags:    make(map[string]string), // Would come from node config
+			NodeID:   member.NodeID,
+			Role:     member.Role,
+			State:    member.State,
+			Lag:      member.Lag,
+			Latency:  0,                       // Would be measured in real implementation
+			Tags:     make(map[string]string), // Would come from node config
+			LastOpID: member.LastOpID,
 		})
 	}
 
@@ -269,6 +332,11 @@ func (s *ReadPreferenceSelector) selectSecondary(candidates []*NodeCandidate, pr
 			}
 		}
 
+		// Check causal consistency
+		if candidate.LastOpID < pref.GetAfterClusterTime() {
+			continue // Hasn't applied the operation yet
+		}
+
 		// Check tags (simplified - in real impl would do more complex matching)
 		tags := pref.GetTags()
 		if len(tags) > 0 {
@@ -309,6 +377,11 @@ func (s *ReadPreferenceSelector) selectNearest(candidates []*NodeCandidate, pref
 			}
 		}
 
+		// Check causal consistency for non-primary nodes
+		if candidate.Role != RolePrimary && candidate.LastOpID < pref.GetAfterClusterTime() {
+			continue
+		}
+
 		// Check tags
 		tags := pref.GetTags()
 		if len(tags) > 0 {
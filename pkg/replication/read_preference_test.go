@@ -685,3 +685,63 @@ func TestReadPreferenceSelectorSelectNearest(t *testing.T) {
 		t.Errorf("Expected one of node1/node2/node3, got %v", nodeID)
 	}
 }
+
+func TestReadRouterSkipsHiddenMembers(t *testing.T) {
+	db, err := database.Open(database.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	rs, err := NewReplicaSet(DefaultReplicaSetConfig("rs0", "node1", db, t.TempDir()+"/oplog"))
+	if err != nil {
+		t.Fatalf("Failed to create replica set: %v", err)
+	}
+	defer rs.Stop()
+
+	hidden := DefaultMemberConfig("hidden")
+	hidden.Hidden = true
+	if err := rs.AddMemberWithConfig(hidden); err == nil {
+		t.Error("Expected a hidden member with priority 1 to be rejected")
+	}
+	hidden.Priority = 0
+	if err := rs.AddMemberWithConfig(hidden); err != nil {
+		t.Fatalf("Failed to add hidden member: %v", err)
+	}
+	if err := rs.BecomePrimary(); err != nil {
+		t.Fatalf("Failed to become primary: %v", err)
+	}
+
+	router := NewReadRouter(rs)
+	ctx := context.Background()
+
+	// The hidden member is the only secondary: secondary reads find none
+	if nodeID, err := router.GetSelectedNode(ctx, Secondary()); err == nil {
+		t.Errorf("Expected no secondary, got %s", nodeID)
+	}
+	for i := 0; i < 20; i++ {
+		for _, pref := range []*ReadPreference{SecondaryPreferred(), Nearest()} {
+			nodeID, err := router.GetSelectedNode(ctx, pref)
+			if err != nil {
+				t.Fatalf("Failed to select node for %s: %v", pref, err)
+			}
+			if nodeID != "node1" {
+				t.Errorf("Expected %s to route to node1, got %s", pref, nodeID)
+			}
+		}
+	}
+
+	// A visible secondary is selected instead
+	if err := rs.AddMember("node2", 1, true); err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		nodeID, err := router.GetSelectedNode(ctx, Secondary())
+		if err != nil {
+			t.Fatalf("Failed to select node: %v", err)
+		}
+		if nodeID != "node2" {
+			t.Errorf("Expected secondary reads to route to node2, got %s", nodeID)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package replication
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -61,9 +62,10 @@ type ReplicaSetConfig struct {
 	NodeID            string
 	Priority          int           // Higher priority nodes are preferred as primary
 	HeartbeatInterval time.Duration // How often to send heartbeats
-	ElectionTimeout   time.Duration // Timeout before starting election
+	ElectionTimeout   time.Duration // Time without a primary before standing for election, randomized up to twice that
 	HeartbeatTimeout  time.Duration // Timeout for considering node dead
-	VotingMembers     []string      // List of voting member node IDs
+	VotingMembers     []string      // Voting member node IDs; this node doesn't vote if listed without it (empty: it votes)
+	SecondsBehind     int           // Delay this node applies operations with; a delayed node never becomes primary
 }
 
 // DefaultReplicaSetConfig returns default replica set configuration
@@ -91,6 +93,9 @@ type ReplicaSetMember struct {
 	LastOpID       OpID
 	Lag            time.Duration
 	IsVotingMember bool
+	Hidden         bool // Never selected for reads
+	BuildIndexes   bool // Replicates index builds; see MemberSlaveConfig
+	SecondsBehind  int // Configured apply delay of a delayed member
 	mu             sync.RWMutex
 }
 
@@ -105,9 +110,12 @@ type ReplicaSet struct {
 	currentPrimary string
 	currentTerm    int64 // Election term number
 	votedFor       string // Candidate voted for in current term
+	primaryPriority int   // Priority of the primary followed, from its heartbeats
+	electing       bool   // An election of this node is in progress
 
 	// Members
 	members        map[string]*ReplicaSetMember
+	clients        map[string]MemberClient // Members reachable for votes and heartbeats
 	membersMu      sync.RWMutex
 
 	// Master/Slave components (used based on role)
@@ -140,9 +148,11 @@ func NewReplicaSet(config *ReplicaSetConfig) (*ReplicaSet, error) {
 		oplog:       oplog,
 		role:        RoleSecondary, // Start as secondary
 		members:     make(map[string]*ReplicaSetMember),
+		clients:     make(map[string]MemberClient),
 		stopChan:    make(chan struct{}),
 		currentTerm: 0,
 	}
+	rs.setSecondary(true)
 
 	// Add self as member
 	rs.members[config.NodeID] = &ReplicaSetMember{
@@ -151,7 +161,9 @@ func NewReplicaSet(config *ReplicaSetConfig) (*ReplicaSet, error) {
 		State:          StateHealthy,
 		Priority:       config.Priority,
 		LastHeartbeat:  time.Now(),
-		IsVotingMember: true,
+		IsVotingMember: rs.isVoting(),
+		BuildIndexes:   true,
+		SecondsBehind:  config.SecondsBehind,
 	}
 
 	return rs, nil
@@ -211,6 +223,7 @@ func (rs *ReplicaSet) Stop() error {
 	rs.wg.Wait()
 
 	rs.isRunning = false
+	rs.setSecondary(false)
 
 	return rs.oplog.Close()
 }
@@ -229,17 +242,17 @@ func (rs *ReplicaSet) monitorLoop() {
 	}
 }
 
-// resetElectionTimer resets the election timer with random timeout
+// resetElectionTimer resets the election timer with random timeout. Must
+// be called with rs.mu held.
 func (rs *ReplicaSet) resetElectionTimer() {
 	if rs.electionTimer != nil {
 		rs.electionTimer.Stop()
 	}
 
-	// Use election timeout
-	timeout := rs.config.ElectionTimeout
-
-	rs.electionTimer = time.AfterFunc(timeout, func() {
-		rs.startElection()
+	rs.electionTimer = time.AfterFunc(rs.electionDelay(), func() {
+		if !rs.stopped() {
+			rs.startElection()
+		}
 	})
 }
 
@@ -269,9 +282,20 @@ func (rs *ReplicaSet) checkMemberHealth() {
 	}
 }
 
-// startElection initiates a leader election
+// startElection stands for election in a new term, becoming primary on
+// the votes of a majority of voting members. Nodes that don't vote, have
+// priority 0 or are delayed never stand.
 func (rs *ReplicaSet) startElection() {
+	if !rs.electable() {
+		return
+	}
+
 	rs.mu.Lock()
+	if rs.electing || rs.role == RolePrimary {
+		rs.mu.Unlock()
+		return
+	}
+	rs.electing = true
 
 	// Increment term
 	rs.currentTerm++
@@ -283,7 +307,12 @@ func (rs *ReplicaSet) startElection() {
 
 	rs.mu.Unlock()
 
-	// Count votes (simplified - in real implementation would send vote requests)
+	defer func() {
+		rs.mu.Lock()
+		rs.electing = false
+		rs.mu.Unlock()
+	}()
+
 	votes := rs.collectVotes(term)
 
 	// Need majority to win
@@ -292,43 +321,52 @@ func (rs *ReplicaSet) startElection() {
 	votingMembers := rs.countVotingMembers()
 	majority := (votingMembers / 2) + 1
 
-	if votes >= majority {
-		rs.becomePrimary()
-	} else {
-		// Election failed, reset timer
-		rs.resetElectionTimer()
+	// A newer term seen while collecting votes ends the election
+	rs.mu.RLock()
+	current := rs.currentTerm == term && rs.votedFor == rs.config.NodeID
+	rs.mu.RUnlock()
+
+	if votes >= majority && current && !rs.stopped() {
+		if err := rs.becomePrimary(); err == nil {
+			// Let the members know at once
+			rs.sendHeartbeats()
+			return
+		}
 	}
+
+	// Election failed, reset timer
+	rs.mu.Lock()
+	rs.resetElectionTimer()
+	rs.mu.Unlock()
 }
 
-// collectVotes collects votes from members (simplified version)
+// collectVotes asks the connected voting members for their votes. Members
+// without a client can't be asked, so they don't vote: estimating their
+// votes would let candidates on both sides of a partition count them.
 func (rs *ReplicaSet) collectVotes(term int64) int {
 	votes := 1 // Vote for self
 
+	clients := make(map[string]MemberClient)
 	rs.membersMu.RLock()
-	defer rs.membersMu.RUnlock()
-
-	// In a real implementation, would send VoteRequest RPCs to all members
-	// For now, we'll simulate: healthy members with lower priority vote for us
 	for nodeID, member := range rs.members {
 		if nodeID == rs.config.NodeID {
 			continue // Already voted for self
 		}
 
 		member.mu.RLock()
-		state := member.State
-		priority := member.Priority
 		isVoting := member.IsVotingMember
 		member.mu.RUnlock()
 
-		// Simplified voting logic:
-		// - Member must be healthy and voting
-		// - Members vote for candidates with higher or equal priority
-		if state == StateHealthy && isVoting && priority <= rs.config.Priority {
-			votes++
+		if !isVoting {
+			continue
+		}
+		if client, ok := rs.clients[nodeID]; ok {
+			clients[nodeID] = client
 		}
 	}
+	rs.membersMu.RUnlock()
 
-	return votes
+	return votes + rs.requestVotes(term, clients)
 }
 
 // countVotingMembers counts the number of voting members
@@ -382,15 +420,11 @@ func (rs *ReplicaSet) becomePrimary() error {
 	rs.master = master
 	rs.role = RolePrimary
 	rs.currentPrimary = rs.config.NodeID
+	rs.primaryPriority = rs.config.Priority
+	rs.setSecondary(false)
 
-	// Update member role
-	rs.membersMu.Lock()
-	if member, exists := rs.members[rs.config.NodeID]; exists {
-		member.mu.Lock()
-		member.Role = RolePrimary
-		member.mu.Unlock()
-	}
-	rs.membersMu.Unlock()
+	// Update member roles
+	rs.setMemberRoles(rs.config.NodeID)
 
 	// Stop election timer and start heartbeat timer
 	if rs.electionTimer != nil {
@@ -412,13 +446,23 @@ func (rs *ReplicaSet) becomeSecondary(primaryID string) error {
 		return nil // Already secondary following this primary
 	}
 
+	rs.follow(primaryID)
+	rs.lastHeartbeat = time.Now()
+	rs.resetElectionTimer()
+
+	return nil
+}
+
+// follow makes this node a secondary of primaryID, stopping its master if
+// it was primary. Must be called with rs.mu held.
+func (rs *ReplicaSet) follow(primaryID string) {
 	// Stop master if running
 	if rs.master != nil {
 		rs.master.Stop()
 		rs.master = nil
 	}
 
-	// Stop heartbeat timer and start election timer
+	// Stop heartbeat timer
 	if rs.heartbeatTimer != nil {
 		rs.heartbeatTimer.Stop()
 		rs.heartbeatTimer = nil
@@ -426,14 +470,18 @@ func (rs *ReplicaSet) becomeSecondary(primaryID string) error {
 
 	rs.role = RoleSecondary
 	rs.currentPrimary = primaryID
-	rs.lastHeartbeat = time.Now()
-
-	rs.resetElectionTimer()
+	rs.setSecondary(true)
 
 	// In a real implementation, would create and start slave here
 	// For now, we'll keep it simple
+}
 
-	return nil
+// setSecondary makes the database read-only for client writes while this
+// node is a secondary
+func (rs *ReplicaSet) setSecondary(secondary bool) {
+	if rs.db != nil {
+		rs.db.SetSecondary(secondary)
+	}
 }
 
 // startHeartbeatTimer starts the heartbeat timer for primary
@@ -444,51 +492,112 @@ func (rs *ReplicaSet) startHeartbeatTimer() {
 
 	rs.heartbeatTimer = time.AfterFunc(rs.config.HeartbeatInterval, func() {
 		rs.sendHeartbeats()
-		rs.startHeartbeatTimer() // Restart timer
+
+		// Restart timer, unless the node stepped down meanwhile
+		rs.mu.Lock()
+		if rs.role == RolePrimary && !rs.stopped() {
+			rs.startHeartbeatTimer()
+		}
+		rs.mu.Unlock()
 	})
 }
 
-// sendHeartbeats sends heartbeats to all members (primary only)
-func (rs *ReplicaSet) sendHeartbeats() {
-	rs.mu.RLock()
-	if rs.role != RolePrimary {
-		rs.mu.RUnlock()
-		return
-	}
-	rs.mu.RUnlock()
+// MemberConfig describes a member added to the replica set
+type MemberConfig struct {
+	NodeID        string
+	Priority      int  // Higher priority members are preferred as primary; 0 never stands for election
+	Voting        bool // Votes in elections and counts toward majorities
+	Hidden        bool // Never selected for reads; requires priority 0
+	BuildIndexes  bool // Builds indexes; a member that doesn't, such as a backup, requires priority 0
+	SecondsBehind int  // Delay the member applies operations with; requires priority 0
+}
 
-	// In a real implementation, would send AppendEntries RPCs to all members
-	// For now, we'll just update our own heartbeat
-	rs.membersMu.Lock()
-	if member, exists := rs.members[rs.config.NodeID]; exists {
-		member.mu.Lock()
-		member.LastHeartbeat = time.Now()
-		member.mu.Unlock()
+// DefaultMemberConfig returns the configuration of a voting member with
+// priority 1 that builds indexes
+func DefaultMemberConfig(nodeID string) MemberConfig {
+	return MemberConfig{
+		NodeID:       nodeID,
+		Priority:     1,
+		Voting:       true,
+		BuildIndexes: true,
 	}
-	rs.membersMu.Unlock()
 }
 
 // AddMember adds a member to the replica set
 func (rs *ReplicaSet) AddMember(nodeID string, priority int, isVoting bool) error {
+	config := DefaultMemberConfig(nodeID)
+	config.Priority = priority
+	config.Voting = isVoting
+	return rs.AddMemberWithConfig(config)
+}
+
+// AddDelayedMember adds a member that applies operations secondsBehind
+// seconds after the primary. It has priority 0, so it is never elected, and
+// it acknowledges writes only once it has applied them.
+func (rs *ReplicaSet) AddDelayedMember(nodeID string, isVoting bool, secondsBehind int) error {
+	config := DefaultMemberConfig(nodeID)
+	config.Priority = 0
+	config.Voting = isVoting
+	config.SecondsBehind = secondsBehind
+	return rs.AddMemberWithConfig(config)
+}
+
+// AddMemberWithConfig adds a member to the replica set. Hidden and delayed
+// members, and members that don't build indexes, can't become primary and
+// must have priority 0.
+func (rs *ReplicaSet) AddMemberWithConfig(config MemberConfig) error {
+	if config.Priority < 0 {
+		return fmt.Errorf("invalid priority for member %s: %d", config.NodeID, config.Priority)
+	}
+	if config.SecondsBehind < 0 {
+		return fmt.Errorf("invalid delay for member %s: %d seconds", config.NodeID, config.SecondsBehind)
+	}
+	if config.Priority > 0 && (config.Hidden || !config.BuildIndexes || config.SecondsBehind > 0) {
+		return fmt.Errorf("member %s is hidden, delayed or doesn't build indexes and must have priority 0", config.NodeID)
+	}
+
 	rs.membersMu.Lock()
 	defer rs.membersMu.Unlock()
 
-	if _, exists := rs.members[nodeID]; exists {
-		return fmt.Errorf("member %s already exists", nodeID)
+	if _, exists := rs.members[config.NodeID]; exists {
+		return fmt.Errorf("member %s already exists", config.NodeID)
 	}
 
-	rs.members[nodeID] = &ReplicaSetMember{
-		NodeID:         nodeID,
+	rs.members[config.NodeID] = &ReplicaSetMember{
+		NodeID:         config.NodeID,
 		Role:           RoleSecondary,
 		State:          StateHealthy,
-		Priority:       priority,
+		Priority:       config.Priority,
 		LastHeartbeat:  time.Now(),
-		IsVotingMember: isVoting,
+		IsVotingMember: config.Voting,
+		Hidden:         config.Hidden,
+		BuildIndexes:   config.BuildIndexes,
+		SecondsBehind:  config.SecondsBehind,
 	}
 
 	return nil
 }
 
+// MemberSlaveConfig returns the configuration a member replicates from this
+// node with: a delayed member applies operations its SecondsBehind late, and
+// a member that doesn't build indexes skips them
+func (rs *ReplicaSet) MemberSlaveConfig(nodeID string, db *database.Database, masterClient MasterClient) (*SlaveConfig, error) {
+	rs.membersMu.RLock()
+	member, exists := rs.members[nodeID]
+	rs.membersMu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("member %s not found", nodeID)
+	}
+
+	config := DefaultSlaveConfig(nodeID, db, masterClient)
+	member.mu.RLock()
+	config.SecondsBehind = member.SecondsBehind
+	config.SkipIndexes = !member.BuildIndexes
+	member.mu.RUnlock()
+	return config, nil
+}
+
 // RemoveMember removes a member from the replica set
 func (rs *ReplicaSet) RemoveMember(nodeID string) error {
 	rs.membersMu.Lock()
@@ -517,8 +626,6 @@ func (rs *ReplicaSet) UpdateMemberHeartbeat(nodeID string, opID OpID) error {
 	}
 
 	member.mu.Lock()
-	defer member.mu.Unlock()
-
 	member.LastHeartbeat = time.Now()
 	member.LastOpID = opID
 	member.State = StateHealthy
@@ -530,6 +637,7 @@ func (rs *ReplicaSet) UpdateMemberHeartbeat(nodeID string, opID OpID) error {
 	} else {
 		member.Lag = 0
 	}
+	member.mu.Unlock()
 
 	// Update our last heartbeat time if this is from primary
 	rs.mu.Lock()
@@ -580,6 +688,9 @@ func (rs *ReplicaSet) GetMembers() []*ReplicaSetMember {
 			LastOpID:       member.LastOpID,
 			Lag:            member.Lag,
 			IsVotingMember: member.IsVotingMember,
+			Hidden:         member.Hidden,
+			BuildIndexes:   member.BuildIndexes,
+			SecondsBehind:  member.SecondsBehind,
 		})
 		member.mu.RUnlock()
 	}
@@ -587,6 +698,71 @@ func (rs *ReplicaSet) GetMembers() []*ReplicaSetMember {
 	return result
 }
 
+// memberLastOpID returns the last operation a member has applied
+func (rs *ReplicaSet) memberLastOpID(nodeID string) (OpID, bool) {
+	rs.membersMu.RLock()
+	member, exists := rs.members[nodeID]
+	rs.membersMu.RUnlock()
+
+	if !exists {
+		return 0, false
+	}
+	member.mu.RLock()
+	defer member.mu.RUnlock()
+	return member.LastOpID, true
+}
+
+// HasMajority reports whether this node can reach a majority of voting
+// members (itself included)
+func (rs *ReplicaSet) HasMajority() bool {
+	rs.membersMu.RLock()
+	defer rs.membersMu.RUnlock()
+
+	voting, healthy := 0, 0
+	for nodeID, member := range rs.members {
+		member.mu.RLock()
+		if member.IsVotingMember {
+			voting++
+			if nodeID == rs.config.NodeID || member.State == StateHealthy {
+				healthy++
+			}
+		}
+		member.mu.RUnlock()
+	}
+
+	return healthy >= (voting/2)+1
+}
+
+// ReplicationLag returns how far this node is behind the primary, using
+// the same op-based lag measure as member heartbeats. The primary has no lag.
+func (rs *ReplicaSet) ReplicationLag() time.Duration {
+	rs.mu.RLock()
+	role := rs.role
+	primaryID := rs.currentPrimary
+	rs.mu.RUnlock()
+
+	if role == RolePrimary || primaryID == "" {
+		return 0
+	}
+
+	rs.membersMu.RLock()
+	primary, exists := rs.members[primaryID]
+	rs.membersMu.RUnlock()
+	if !exists {
+		return 0
+	}
+
+	primary.mu.RLock()
+	primaryOpID := primary.LastOpID
+	primary.mu.RUnlock()
+
+	currentOpID := rs.oplog.GetCurrentID()
+	if primaryOpID > currentOpID {
+		return time.Duration(primaryOpID-currentOpID) * time.Millisecond
+	}
+	return 0
+}
+
 // Stats returns statistics about the replica set
 func (rs *ReplicaSet) Stats() map[string]interface{} {
 	rs.mu.RLock()
@@ -622,6 +798,91 @@ func (rs *ReplicaSet) Stats() map[string]interface{} {
 	}
 }
 
+// MemberStatus describes one replica set member in a status report
+type MemberStatus struct {
+	ID            string    `json:"id"`
+	Role          string    `json:"role"`
+	Health        string    `json:"health"`
+	Priority      int       `json:"priority"`
+	Voting        bool      `json:"voting"`
+	LastOpID      OpID      `json:"last_op_id"`
+	LagMillis     int64     `json:"lag_ms"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	Hidden        bool      `json:"hidden,omitempty"`
+	SecondsBehind int       `json:"seconds_behind,omitempty"`
+	Self          bool      `json:"self"`
+}
+
+// ReplicaSetStatus is a point-in-time view of the replica set as seen by
+// this node, in the spirit of MongoDB's rs.status()
+type ReplicaSetStatus struct {
+	Set     string         `json:"set"`
+	NodeID  string         `json:"node_id"`
+	Role    string         `json:"role"`
+	Primary string         `json:"primary"`
+	Term    int64          `json:"term"`
+	Date    time.Time      `json:"date"`
+	Members []MemberStatus `json:"members"`
+}
+
+// Status reports every member's role, health, priority, last applied
+// operation and lag, along with the current primary and term. This node's
+// own entry uses its local oplog position and lag behind the primary.
+// Members are ordered by ID.
+func (rs *ReplicaSet) Status() ReplicaSetStatus {
+	rs.mu.RLock()
+	role := rs.role
+	primary := rs.currentPrimary
+	term := rs.currentTerm
+	master := rs.master
+	rs.mu.RUnlock()
+
+	// A primary logs through its master's oplog
+	currentOpID := rs.oplog.GetCurrentID()
+	if master != nil {
+		currentOpID = master.GetCurrentOpID()
+	}
+	lag := rs.ReplicationLag()
+
+	members := rs.GetMembers()
+	statuses := make([]MemberStatus, 0, len(members))
+	for _, member := range members {
+		status := MemberStatus{
+			ID:            member.NodeID,
+			Role:          member.Role.String(),
+			Health:        member.State.String(),
+			Priority:      member.Priority,
+			Voting:        member.IsVotingMember,
+			LastOpID:      member.LastOpID,
+			LagMillis:     member.Lag.Milliseconds(),
+			LastHeartbeat: member.LastHeartbeat,
+			Hidden:        member.Hidden,
+			SecondsBehind: member.SecondsBehind,
+		}
+		if member.NodeID == rs.config.NodeID {
+			status.Self = true
+			status.Role = role.String()
+			status.Health = StateHealthy.String()
+			status.LastOpID = currentOpID
+			status.LagMillis = lag.Milliseconds()
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ID < statuses[j].ID
+	})
+
+	return ReplicaSetStatus{
+		Set:     rs.config.Name,
+		NodeID:  rs.config.NodeID,
+		Role:    role.String(),
+		Primary: primary,
+		Term:    term,
+		Date:    time.Now(),
+		Members: statuses,
+	}
+}
+
 // StepDown forces the primary to step down (manual failover)
 func (rs *ReplicaSet) StepDown() error {
 	rs.mu.Lock()
@@ -631,25 +892,16 @@ func (rs *ReplicaSet) StepDown() error {
 		return fmt.Errorf("node is not primary")
 	}
 
-	// Stop master
-	if rs.master != nil {
-		rs.master.Stop()
-		rs.master = nil
-	}
-
-	// Become secondary
-	rs.role = RoleSecondary
-	rs.currentPrimary = ""
-
-	// Stop heartbeat timer and start election timer
-	if rs.heartbeatTimer != nil {
-		rs.heartbeatTimer.Stop()
-		rs.heartbeatTimer = nil
-	}
+	rs.stepDown()
+	return nil
+}
 
+// stepDown makes the primary a secondary without a primary until the next
+// election. Must be called with rs.mu held.
+func (rs *ReplicaSet) stepDown() {
+	rs.follow("")
+	rs.setMemberRoles("")
 	rs.resetElectionTimer()
-
-	return nil
 }
 
 // SimulateFailure simulates a node failure (for testing)
@@ -2,6 +2,7 @@ package replication
 
 import (
 	"context"
+	"errors"
 	"path/filepath"
 	"testing"
 	"time"
@@ -294,6 +295,20 @@ func TestReplicaSetElection(t *testing.T) {
 		t.Fatalf("Failed to update heartbeat: %v", err)
 	}
 
+	// Healthy members that can't be asked don't vote
+	rs.startElection()
+	if rs.IsPrimary() {
+		t.Fatal("Expected node to lose without members to ask for votes")
+	}
+
+	// Connect the members so they can grant their votes
+	for _, nodeID := range []string{"node2", "node3"} {
+		member := newIdleReplicaSet(t, nodeID, 1)
+		if err := rs.ConnectMember(nodeID, NewLocalMemberClient(member)); err != nil {
+			t.Fatalf("Failed to connect member: %v", err)
+		}
+	}
+
 	// Start election
 	rs.startElection()
 
@@ -302,9 +317,9 @@ func TestReplicaSetElection(t *testing.T) {
 		t.Error("Expected node to become primary after winning election")
 	}
 
-	// Check term incremented
-	if rs.currentTerm != 1 {
-		t.Errorf("Expected term 1, got %d", rs.currentTerm)
+	// Check term incremented for each election
+	if rs.currentTerm != 2 {
+		t.Errorf("Expected term 2, got %d", rs.currentTerm)
 	}
 }
 
@@ -696,3 +711,244 @@ func TestNodeStateString(t *testing.T) {
 		}
 	}
 }
+
+func TestReplicaSetHealth(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	db, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "rs1")))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	config := DefaultReplicaSetConfig("rs0", "node1", db, filepath.Join(tmpDir, "oplog.bin"))
+	rs, err := NewReplicaSet(config)
+	if err != nil {
+		t.Fatalf("Failed to create replica set: %v", err)
+	}
+	defer rs.Stop()
+
+	rs.AddMember("node2", 1, true)
+	rs.AddMember("node3", 1, true)
+
+	// Losing one of three voting members keeps a majority
+	rs.SimulateFailure("node3")
+	if !rs.HasMajority() {
+		t.Error("Expected majority with 2 of 3 members reachable")
+	}
+	rs.SimulateFailure("node2")
+	if rs.HasMajority() {
+		t.Error("Expected no majority with 1 of 3 members reachable")
+	}
+
+	// A secondary lags by the ops the primary has that it has not applied
+	if err := rs.becomeSecondary("node2"); err != nil {
+		t.Fatalf("Failed to become secondary: %v", err)
+	}
+	primaryOpID := rs.oplog.GetCurrentID() + 50
+	if err := rs.UpdateMemberHeartbeat("node2", primaryOpID); err != nil {
+		t.Fatalf("Failed to update heartbeat: %v", err)
+	}
+	if lag := rs.ReplicationLag(); lag != 50*time.Millisecond {
+		t.Errorf("Expected lag 50ms, got %v", lag)
+	}
+
+	if err := rs.BecomePrimary(); err != nil {
+		t.Fatalf("Failed to become primary: %v", err)
+	}
+	if lag := rs.ReplicationLag(); lag != 0 {
+		t.Errorf("Expected primary lag 0, got %v", lag)
+	}
+}
+
+func TestReplicaSetStatus(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	db, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "rs1")))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	config := DefaultReplicaSetConfig("rs0", "node2", db, filepath.Join(tmpDir, "oplog.bin"))
+	config.Priority = 5
+	rs, err := NewReplicaSet(config)
+	if err != nil {
+		t.Fatalf("Failed to create replica set: %v", err)
+	}
+	defer rs.Stop()
+
+	rs.AddMember("node3", 10, true)
+	rs.AddMember("node1", 0, false)
+
+	// Follow node3, which is 30 ops ahead
+	if err := rs.becomeSecondary("node3"); err != nil {
+		t.Fatalf("Failed to become secondary: %v", err)
+	}
+	currentOpID := rs.oplog.GetCurrentID()
+	if err := rs.UpdateMemberHeartbeat("node3", currentOpID+30); err != nil {
+		t.Fatalf("Failed to update heartbeat: %v", err)
+	}
+	rs.SimulateFailure("node1")
+
+	status := rs.Status()
+	if status.Set != "rs0" || status.NodeID != "node2" {
+		t.Errorf("Unexpected set %q / node %q", status.Set, status.NodeID)
+	}
+	if status.Primary != "node3" || status.Role != "SECONDARY" {
+		t.Errorf("Expected node3 as primary and SECONDARY role, got %q / %q", status.Primary, status.Role)
+	}
+
+	if len(status.Members) != 3 {
+		t.Fatalf("Expected 3 members, got %d", len(status.Members))
+	}
+	for i, id := range []string{"node1", "node2", "node3"} {
+		if status.Members[i].ID != id {
+			t.Errorf("Expected member %d to be %s, got %s", i, id, status.Members[i].ID)
+		}
+	}
+
+	node1, self, node3 := status.Members[0], status.Members[1], status.Members[2]
+	if node1.Health != "UNREACHABLE" || node1.Voting {
+		t.Errorf("Expected node1 UNREACHABLE and non-voting, got %s voting=%v", node1.Health, node1.Voting)
+	}
+	if !self.Self || self.Priority != 5 || self.LastOpID != currentOpID || self.LagMillis != 30 {
+		t.Errorf("Unexpected self status: %+v", self)
+	}
+	if node3.Self || node3.Priority != 10 || node3.LastOpID != currentOpID+30 {
+		t.Errorf("Unexpected node3 status: %+v", node3)
+	}
+
+	// After an election the term and roles follow
+	if err := rs.BecomePrimary(); err != nil {
+		t.Fatalf("Failed to become primary: %v", err)
+	}
+	status = rs.Status()
+	if status.Primary != "node2" || status.Members[1].Role != "PRIMARY" || status.Members[1].LagMillis != 0 {
+		t.Errorf("Expected node2 as PRIMARY without lag, got %+v", status)
+	}
+	if status.Term != rs.currentTerm {
+		t.Errorf("Expected term %d, got %d", rs.currentTerm, status.Term)
+	}
+}
+
+func TestReplicaSetSecondaryRejectsWrites(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	db, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "rs1")))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	config := DefaultReplicaSetConfig("rs0", "node1", db, filepath.Join(tmpDir, "oplog.bin"))
+	rs, err := NewReplicaSet(config)
+	if err != nil {
+		t.Fatalf("Failed to create replica set: %v", err)
+	}
+	defer rs.Stop()
+
+	coll := db.Collection("users")
+
+	// Nodes start as secondary
+	if _, err := coll.InsertOne(map[string]interface{}{"name": "Alice"}); !errors.Is(err, database.ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly on secondary, got %v", err)
+	}
+
+	if err := rs.BecomePrimary(); err != nil {
+		t.Fatalf("Failed to become primary: %v", err)
+	}
+	if _, err := coll.InsertOne(map[string]interface{}{"name": "Alice"}); err != nil {
+		t.Errorf("Expected insert on primary to succeed, got %v", err)
+	}
+
+	if err := rs.StepDown(); err != nil {
+		t.Fatalf("Failed to step down: %v", err)
+	}
+	if _, err := coll.InsertOne(map[string]interface{}{"name": "Bob"}); !errors.Is(err, database.ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly after step down, got %v", err)
+	}
+}
+
+func TestMemberWithoutIndexBuildsSkipsIndexes(t *testing.T) {
+	tmpDir := t.TempDir()
+	rs := newTestReplicaSet(t, "node1", 1)
+
+	backup := DefaultMemberConfig("backup")
+	backup.Priority = 0
+	backup.BuildIndexes = false
+	if err := rs.AddMemberWithConfig(backup); err != nil {
+		t.Fatalf("Failed to add backup member: %v", err)
+	}
+	if err := rs.AddMember("node2", 1, true); err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+
+	if err := rs.BecomePrimary(); err != nil {
+		t.Fatalf("Failed to become primary: %v", err)
+	}
+	master := rs.master
+
+	// An index that isn't in the oplog, copied by the initial sync
+	if err := rs.db.Collection("products").CreateIndex("sku", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	// An index created through the oplog
+	users := rs.db.Collection("users")
+	if err := users.CreateIndex("email", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	def, err := users.IndexDefinition("email_1")
+	if err != nil {
+		t.Fatalf("Failed to get index definition: %v", err)
+	}
+	master.LogOperation(CreateIndexEntry("default", "users", def, true))
+	doc := map[string]interface{}{"_id": "u1", "email": "a@example.com"}
+	users.InsertOne(doc)
+	master.LogOperation(CreateInsertEntry("default", "users", doc))
+
+	replicate := func(nodeID string) *database.Database {
+		db, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, nodeID)))
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		config, err := rs.MemberSlaveConfig(nodeID, db, NewLocalMasterClient(master))
+		if err != nil {
+			t.Fatalf("Failed to get slave config of %s: %v", nodeID, err)
+		}
+		slave, err := NewSlave(config)
+		if err != nil {
+			t.Fatalf("Failed to create slave: %v", err)
+		}
+		if err := slave.InitialSync(context.Background()); err != nil {
+			t.Fatalf("Initial sync of %s failed: %v", nodeID, err)
+		}
+		return db
+	}
+
+	backupDB := replicate("backup")
+	if names := indexNames(backupDB.Collection("products")); names["sku_1"] {
+		t.Errorf("Expected the backup not to copy sku_1, got %v", names)
+	}
+	if names := indexNames(backupDB.Collection("users")); names["email_1"] {
+		t.Errorf("Expected the backup not to build email_1, got %v", names)
+	}
+	if n, _ := backupDB.Collection("users").Count(map[string]interface{}{}); n != 1 {
+		t.Errorf("Expected the backup to replicate 1 document, got %d", n)
+	}
+
+	db2 := replicate("node2")
+	if names := indexNames(db2.Collection("products")); !names["sku_1"] {
+		t.Errorf("Expected node2 to copy sku_1, got %v", names)
+	}
+	if names := indexNames(db2.Collection("users")); !names["email_1"] {
+		t.Errorf("Expected node2 to build email_1, got %v", names)
+	}
+
+	if _, err := rs.MemberSlaveConfig("missing", db2, NewLocalMasterClient(master)); err == nil {
+		t.Error("Expected an error for an unknown member")
+	}
+}
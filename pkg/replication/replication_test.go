@@ -2,6 +2,7 @@ package replication
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -203,6 +204,70 @@ func TestMasterGetOplogEntries(t *testing.T) {
 	}
 }
 
+func TestMasterOplogTrimKeepsSlaveEntries(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	db, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "master")))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	config := DefaultMasterConfig(db, filepath.Join(tmpDir, "oplog.bin"))
+	config.Oplog = OplogConfig{MaxSizeBytes: 1, TrimInterval: time.Hour}
+	master, err := NewMaster(config)
+	if err != nil {
+		t.Fatalf("Failed to create master: %v", err)
+	}
+	if err := master.Start(); err != nil {
+		t.Fatalf("Failed to start master: %v", err)
+	}
+	defer master.Stop()
+
+	for i := 0; i < 10; i++ {
+		if err := master.LogOperation(CreateInsertEntry("testdb", "users", map[string]interface{}{"n": int64(i)})); err != nil {
+			t.Fatalf("Failed to log operation: %v", err)
+		}
+	}
+	if err := master.RegisterSlave("slave1"); err != nil {
+		t.Fatalf("Failed to register slave: %v", err)
+	}
+	if err := master.UpdateSlaveHeartbeat("slave1", 4); err != nil {
+		t.Fatalf("Failed to update heartbeat: %v", err)
+	}
+
+	// The lagging slave's entries stay
+	if _, err := master.oplog.Trim(); err != nil {
+		t.Fatalf("Failed to trim: %v", err)
+	}
+	if oldest := master.oplog.OldestOpID(); oldest != 5 {
+		t.Errorf("Expected oldest OpID 5, got %d", oldest)
+	}
+	entries, err := master.FetchOplogBatch(context.Background(), 4, 0, 0, 0)
+	if err != nil || len(entries) != 6 {
+		t.Fatalf("Expected entries 5-10 for the slave, got %d, %v", len(entries), err)
+	}
+
+	// A slave that fell off the back has to resync
+	if _, err := master.GetOplogEntries(2); !errors.Is(err, ErrOplogTrimmed) {
+		t.Errorf("Expected ErrOplogTrimmed, got %v", err)
+	}
+	if _, err := master.FetchOplogBatch(context.Background(), 0, 0, 0, 0); !errors.Is(err, ErrOplogTrimmed) {
+		t.Errorf("Expected ErrOplogTrimmed from FetchOplogBatch, got %v", err)
+	}
+
+	// Once the slave leaves, only the newest entry is kept
+	if err := master.UnregisterSlave("slave1"); err != nil {
+		t.Fatalf("Failed to unregister slave: %v", err)
+	}
+	if _, err := master.oplog.Trim(); err != nil {
+		t.Fatalf("Failed to trim: %v", err)
+	}
+	if oldest := master.oplog.OldestOpID(); oldest != 10 {
+		t.Errorf("Expected oldest OpID 10, got %d", oldest)
+	}
+}
+
 func TestReplicationPairBasic(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -797,6 +862,8 @@ func TestOpTypeString(t *testing.T) {
 		{OpTypeCreateIndex, "createIndex"},
 		{OpTypeDropIndex, "dropIndex"},
 		{OpTypeNoop, "noop"},
+		{OpTypeTruncateCollection, "truncateCollection"},
+		{OpTypeRenameCollection, "renameCollection"},
 		{OpType(99), "unknown"}, // Invalid op type
 	}
 
@@ -1200,25 +1267,38 @@ func TestSlaveApplyEntry(t *testing.T) {
 		t.Errorf("Failed to apply drop collection entry for non-existent collection: %v", err)
 	}
 
-	// Test OpTypeCreateIndex (no-op for now)
+	// Test OpTypeCreateIndex
 	createIndexEntry := &OplogEntry{
 		OpID:       6,
 		OpType:     OpTypeCreateIndex,
 		Collection: "users",
+		IndexDef: map[string]interface{}{
+			"name":        "age_1",
+			"type":        "btree",
+			"field_paths": []interface{}{"age"},
+			"unique":      false,
+		},
 	}
 	if err := slave.applyEntry(createIndexEntry); err != nil {
 		t.Errorf("Failed to apply create index entry: %v", err)
 	}
+	if !indexNames(slaveDB.Collection("users"))["age_1"] {
+		t.Error("Expected age_1 to be created by the create index entry")
+	}
 
-	// Test OpTypeDropIndex (no-op for now)
+	// Test OpTypeDropIndex
 	dropIndexEntry := &OplogEntry{
 		OpID:       7,
 		OpType:     OpTypeDropIndex,
 		Collection: "users",
+		IndexDef:   map[string]interface{}{"name": "age_1"},
 	}
 	if err := slave.applyEntry(dropIndexEntry); err != nil {
 		t.Errorf("Failed to apply drop index entry: %v", err)
 	}
+	if indexNames(slaveDB.Collection("users"))["age_1"] {
+		t.Error("Expected age_1 to be dropped by the drop index entry")
+	}
 
 	// Test OpTypeNoop
 	noopEntry := &OplogEntry{
@@ -1309,3 +1389,185 @@ func TestSlaveSendHeartbeat(t *testing.T) {
 		t.Errorf("Expected LastOpID 123, got %d", info.LastOpID)
 	}
 }
+
+// indexNames returns the names of the indexes of a collection
+func indexNames(coll *database.Collection) map[string]bool {
+	names := make(map[string]bool)
+	for _, idx := range coll.ListIndexes() {
+		names[idx["name"].(string)] = true
+	}
+	return names
+}
+
+func TestSlaveAppliesDDLEntries(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	masterDB, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "master")))
+	if err != nil {
+		t.Fatalf("Failed to open master database: %v", err)
+	}
+	defer masterDB.Close()
+
+	master, err := NewMaster(DefaultMasterConfig(masterDB, filepath.Join(tmpDir, "oplog.bin")))
+	if err != nil {
+		t.Fatalf("Failed to create master: %v", err)
+	}
+	defer master.Stop()
+	master.Start()
+
+	// Create a collection with indexes on the master and log the DDL
+	if _, err := masterDB.CreateCollection("users"); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	master.LogOperation(CreateCollectionEntry("default", "users", true))
+
+	users := masterDB.Collection("users")
+	if err := users.CreateIndex("email", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := users.CreateCompoundIndex([]string{"city", "age"}, false); err != nil {
+		t.Fatalf("Failed to create compound index: %v", err)
+	}
+	if err := users.CreateTTLIndex("createdAt", 3600*time.Second); err != nil {
+		t.Fatalf("Failed to create TTL index: %v", err)
+	}
+	for _, name := range []string{"email_1", "city_age_1", "createdAt_ttl"} {
+		def, err := users.IndexDefinition(name)
+		if err != nil {
+			t.Fatalf("Failed to get definition of %s: %v", name, err)
+		}
+		master.LogOperation(CreateIndexEntry("default", "users", def, true))
+	}
+
+	doc := map[string]interface{}{"_id": "u1", "email": "a@example.com", "city": "Prague", "age": int64(30)}
+	users.InsertOne(doc)
+	master.LogOperation(CreateInsertEntry("default", "users", doc))
+
+	users.DropIndex("createdAt_ttl")
+	master.LogOperation(CreateIndexEntry("default", "users", map[string]interface{}{"name": "createdAt_ttl"}, false))
+
+	masterDB.RenameCollection("users", "members")
+	master.LogOperation(CreateRenameEntry("default", "users", "members"))
+
+	slaveDB, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "slave")))
+	if err != nil {
+		t.Fatalf("Failed to open slave database: %v", err)
+	}
+	defer slaveDB.Close()
+
+	slave, err := NewSlave(DefaultSlaveConfig("slave1", slaveDB, NewLocalMasterClient(master)))
+	if err != nil {
+		t.Fatalf("Failed to create slave: %v", err)
+	}
+	if err := slave.fetchAndApplyEntries(); err != nil {
+		t.Fatalf("Failed to apply entries: %v", err)
+	}
+
+	for _, name := range slaveDB.ListCollections() {
+		if name == "users" {
+			t.Error("Expected users to be renamed on the slave")
+		}
+	}
+	names := indexNames(slaveDB.Collection("members"))
+	if !names["email_1"] || !names["city_age_1"] {
+		t.Errorf("Expected email_1 and city_1_age_1 on the slave, got %v", names)
+	}
+	if names["createdAt_ttl"] {
+		t.Error("Expected createdAt_ttl to be dropped on the slave")
+	}
+
+	// The replicated unique index is enforced on the slave
+	_, err = slaveDB.Collection("members").InsertOne(map[string]interface{}{"_id": "u2", "email": "a@example.com"})
+	if !errors.Is(err, database.ErrDuplicateKey) {
+		t.Errorf("Expected ErrDuplicateKey, got %v", err)
+	}
+
+	// DDL entries applied twice leave the slave as it is
+	def, _ := masterDB.Collection("members").IndexDefinition("email_1")
+	for _, entry := range []*OplogEntry{
+		CreateIndexEntry("default", "members", def, true),
+		CreateIndexEntry("default", "members", map[string]interface{}{"name": "createdAt_ttl"}, false),
+		CreateRenameEntry("default", "users", "members"),
+		CreateCollectionEntry("default", "members", true),
+	} {
+		if err := slave.applyEntry(entry); err != nil {
+			t.Errorf("Expected %s to be applied again, got %v", entry.OpType, err)
+		}
+	}
+	if count, _ := slaveDB.Collection("members").Count(nil); count != 1 {
+		t.Errorf("Expected 1 document on the slave, got %d", count)
+	}
+}
+
+func TestSlaveInitialSyncCopiesIndexes(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	masterDB, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "master")))
+	if err != nil {
+		t.Fatalf("Failed to open master database: %v", err)
+	}
+	defer masterDB.Close()
+
+	// Indexes and an empty collection created before the oplog existed
+	products := masterDB.Collection("products")
+	if err := products.CreateIndex("sku", true); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := products.CreateTextIndexWithWeights([]string{"title", "body"}, map[string]int{"title": 5}); err != nil {
+		t.Fatalf("Failed to create text index: %v", err)
+	}
+	if _, err := masterDB.CreateCollection("orders"); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	master, err := NewMaster(DefaultMasterConfig(masterDB, filepath.Join(tmpDir, "oplog.bin")))
+	if err != nil {
+		t.Fatalf("Failed to create master: %v", err)
+	}
+	defer master.Stop()
+	master.Start()
+
+	doc := map[string]interface{}{"_id": "p1", "sku": "A-1", "title": "Lamp", "body": "Desk lamp"}
+	products.InsertOne(doc)
+	master.LogOperation(CreateInsertEntry("default", "products", doc))
+
+	slaveDB, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "slave")))
+	if err != nil {
+		t.Fatalf("Failed to open slave database: %v", err)
+	}
+	defer slaveDB.Close()
+
+	slave, err := NewSlave(DefaultSlaveConfig("slave1", slaveDB, NewLocalMasterClient(master)))
+	if err != nil {
+		t.Fatalf("Failed to create slave: %v", err)
+	}
+	if err := slave.InitialSync(context.Background()); err != nil {
+		t.Fatalf("Initial sync failed: %v", err)
+	}
+
+	names := indexNames(slaveDB.Collection("products"))
+	if !names["sku_1"] {
+		t.Errorf("Expected sku_1 on the slave, got %v", names)
+	}
+	if len(names) != len(indexNames(products)) {
+		t.Errorf("Expected %d indexes on the slave, got %v", len(indexNames(products)), names)
+	}
+
+	found := false
+	for _, name := range slaveDB.ListCollections() {
+		if name == "orders" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected orders to be created by the initial sync")
+	}
+
+	results, err := slaveDB.Collection("products").TextSearch("lamp", nil)
+	if err != nil {
+		t.Fatalf("Text search failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 text search result, got %d", len(results))
+	}
+}
@@ -9,6 +9,14 @@ import (
 	"github.com/mnohosten/laura-db/pkg/database"
 )
 
+// IndexCatalog is implemented by master clients that can list the index
+// definitions of every collection on the master. InitialSync uses it to copy
+// collections and indexes the oplog doesn't record.
+type IndexCatalog interface {
+	// GetIndexDefinitions returns the index definitions of each collection
+	GetIndexDefinitions(ctx context.Context) (map[string][]map[string]interface{}, error)
+}
+
 // SlaveConfig holds configuration for a slave node
 type SlaveConfig struct {
 	SlaveID          string
@@ -18,6 +26,47 @@ type SlaveConfig struct {
 	HeartbeatInterval time.Duration
 	RetryInterval    time.Duration
 	MaxRetries       int
+
+	// MasterID names MasterClient as a sync source (DefaultSyncSourceID if empty)
+	MasterID string
+	// SyncSources are the other members the slave may replicate from when
+	// MasterClient stops making progress
+	SyncSources []*SyncSource
+	// SyncSourceTimeout is how long a sync source may go without returning
+	// entries before the slave looks for another one (0 disables switching)
+	SyncSourceTimeout time.Duration
+	// OnSyncSourceChange, if set, is called after each sync source switch
+	OnSyncSourceChange func(SyncSourceChange)
+
+	// ApplyConcurrency is the number of streams oplog entries are applied
+	// with; writes to the same document always stay in order (1 applies
+	// serially)
+	ApplyConcurrency int
+	// ApplyBatchSize caps how many consecutive inserts into one collection
+	// are coalesced into a single grouped insert (0 or 1 inserts each
+	// document on its own)
+	ApplyBatchSize int
+
+	// FetchBatchSize and FetchBatchBytes bound the entries fetched per round
+	// trip from a master client that implements OplogBatchFetcher (0 for no
+	// limit)
+	FetchBatchSize  int
+	FetchBatchBytes int
+	// LongPollTimeout is how long such a master holds a fetch when there
+	// are no new entries. While entries keep coming, or the master long
+	// polls, the slave fetches again right away instead of waiting for
+	// PollInterval (0 polls every PollInterval once caught up).
+	LongPollTimeout time.Duration
+
+	// SecondsBehind keeps the slave that many seconds behind the master:
+	// fetched entries are applied once they are at least that old, leaving
+	// time to recover from an accidental delete (0 applies them right away)
+	SecondsBehind int
+
+	// SkipIndexes leaves out the master's indexes, for a member that doesn't
+	// build them such as a backup: create index entries are skipped and the
+	// initial sync copies collections without their indexes
+	SkipIndexes bool
 }
 
 // DefaultSlaveConfig returns default slave configuration
@@ -30,6 +79,12 @@ func DefaultSlaveConfig(slaveID string, db *database.Database, masterClient Mast
 		HeartbeatInterval: 5 * time.Second,
 		RetryInterval:    5 * time.Second,
 		MaxRetries:       3,
+		SyncSourceTimeout: 30 * time.Second,
+		ApplyConcurrency:  DefaultApplyConcurrency,
+		ApplyBatchSize:    DefaultApplyBatchSize,
+		FetchBatchSize:    DefaultFetchBatchSize,
+		FetchBatchBytes:   DefaultFetchBatchBytes,
+		LongPollTimeout:   DefaultLongPollTimeout,
 	}
 }
 
@@ -56,19 +111,52 @@ type Slave struct {
 	lastAppliedOpID   OpID
 	mu                sync.RWMutex
 	stopChan          chan struct{}
+	stopCtx           context.Context // Canceled on Stop, to end a fetch held by a long poll
+	stopCancel        context.CancelFunc
 	wg                sync.WaitGroup
 	isRunning         bool
 	replicationErrors int
+
+	sources        []*SyncSource      // MasterClient followed by SyncSources
+	sourceID       string             // ID of the source masterClient belongs to
+	lastProgress   time.Time          // Last time the source returned entries
+	sourceSwitches int
+	sourceHistory  []SyncSourceChange // Recent sync source changes
+
+	fetchedBatches   int64         // Batches fetched from an OplogBatchFetcher
+	fetchedEntries   int64         // Entries in those batches
+	appliedEntries   int64         // Entries applied since start
+	parallelEntries  int64         // Entries applied concurrently with others
+	applyBarriers    int64         // Entries that had to be applied alone
+	groupedInserts   int64         // Grouped inserts coalescing several entries
+	coalescedEntries int64         // Entries applied by grouped inserts
+	appliedTxns      int64         // Transactions applied atomically
+	applyTime        time.Duration // Time spent applying entries
+	applyLag         time.Duration // Age of the last entry applied, when it was
+	held             []*OplogEntry // Fetched entries waiting for SecondsBehind
 }
 
 // NewSlave creates a new slave node
 func NewSlave(config *SlaveConfig) (*Slave, error) {
+	masterID := config.MasterID
+	if masterID == "" {
+		masterID = DefaultSyncSourceID
+	}
+	sources := []*SyncSource{{ID: masterID, Client: config.MasterClient}}
+	sources = append(sources, config.SyncSources...)
+	stopCtx, stopCancel := context.WithCancel(context.Background())
+
 	return &Slave{
 		config:          config,
 		db:              config.Database,
 		masterClient:    config.MasterClient,
 		lastAppliedOpID: 0,
 		stopChan:        make(chan struct{}),
+		stopCtx:         stopCtx,
+		stopCancel:      stopCancel,
+		sources:         sources,
+		sourceID:        masterID,
+		lastProgress:    time.Now(),
 	}, nil
 }
 
@@ -105,26 +193,30 @@ func (s *Slave) Start() error {
 // Stop stops the slave replication process
 func (s *Slave) Stop() error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	if !s.isRunning {
+		s.mu.Unlock()
 		return nil
 	}
 
 	// Signal stop
+	s.isRunning = false
 	close(s.stopChan)
+	s.stopCancel()
+	client := s.masterClient
+	s.mu.Unlock()
+
+	// The replication loop takes the lock, so wait for it without holding it
 	s.wg.Wait()
 
 	// Unregister from master
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := s.masterClient.Unregister(ctx, s.config.SlaveID); err != nil {
+	if err := client.Unregister(ctx, s.config.SlaveID); err != nil {
 		// Log error but don't fail
 		fmt.Printf("Warning: failed to unregister from master: %v\n", err)
 	}
 
-	s.isRunning = false
 	return nil
 }
 
@@ -132,17 +224,50 @@ func (s *Slave) Stop() error {
 func (s *Slave) replicationLoop() {
 	defer s.wg.Done()
 
-	ticker := time.NewTicker(s.config.PollInterval)
-	defer ticker.Stop()
+	// A slave long-polling the master fetches from the start
+	delay := s.config.PollInterval
+	s.mu.RLock()
+	_, batched := s.masterClient.(OplogBatchFetcher)
+	s.mu.RUnlock()
+	if batched {
+		delay = 0
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-timer.C:
+			s.mu.RLock()
+			lastOpID := s.lastAppliedOpID
+			s.mu.RUnlock()
+
 			if err := s.fetchAndApplyEntries(); err != nil {
+				select {
+				case <-s.stopChan:
+					return
+				default:
+				}
 				fmt.Printf("Replication error: %v\n", err)
 				s.mu.Lock()
 				s.replicationErrors++
 				s.mu.Unlock()
+				timer.Reset(s.config.PollInterval)
+				continue
+			}
+
+			// A batch that returned entries may have left more behind, and
+			// a long poll already waited for new ones
+			s.mu.RLock()
+			progressed := s.lastAppliedOpID > lastOpID
+			_, batched := s.masterClient.(OplogBatchFetcher)
+			s.mu.RUnlock()
+			if batched && (progressed || s.config.LongPollTimeout > 0) {
+				timer.Reset(0)
+			} else if due, ok := s.nextRelease(); ok && due < s.config.PollInterval {
+				timer.Reset(due)
+			} else {
+				timer.Reset(s.config.PollInterval)
 			}
 		case <-s.stopChan:
 			return
@@ -167,56 +292,62 @@ func (s *Slave) heartbeatLoop() {
 	}
 }
 
-// fetchAndApplyEntries fetches new oplog entries and applies them
+// fetchAndApplyEntries fetches new oplog entries and applies them,
+// switching sync sources when the current one stops making progress
 func (s *Slave) fetchAndApplyEntries() error {
 	s.mu.RLock()
-	lastOpID := s.lastAppliedOpID
+	lastOpID := s.fetchAfter()
+	client := s.masterClient
 	s.mu.RUnlock()
 
 	// Fetch entries from master
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	entries, err := s.masterClient.GetOplogEntries(ctx, lastOpID)
+	entries, err := s.fetchEntries(client, lastOpID)
 	if err != nil {
+		s.checkSyncSource(false)
 		return fmt.Errorf("failed to fetch oplog entries: %w", err)
 	}
+	s.recordFetch(len(entries) > 0)
+	if len(entries) == 0 {
+		s.checkSyncSource(true)
+	}
 
-	// Apply each entry
-	for _, entry := range entries {
-		if err := s.applyEntry(entry); err != nil {
-			return fmt.Errorf("failed to apply entry %d: %w", entry.OpID, err)
-		}
-
+	entries = s.releaseEntries(entries)
+	if len(entries) == 0 {
 		s.mu.Lock()
-		s.lastAppliedOpID = entry.OpID
+		if len(s.held) == 0 {
+			s.applyLag = 0
+		}
 		s.mu.Unlock()
+		return nil
 	}
 
+	if err := s.applyEntries(entries); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.applyLag = time.Since(entries[len(entries)-1].Timestamp)
+	s.mu.Unlock()
 	return nil
 }
 
 // applyEntry applies a single oplog entry to the local database
 func (s *Slave) applyEntry(entry *OplogEntry) error {
-	// Get the collection
-	coll := s.db.Collection(entry.Collection)
-
 	switch entry.OpType {
 	case OpTypeInsert:
 		// Insert document
-		if _, err := coll.InsertOne(entry.Document); err != nil {
+		if _, err := s.db.Collection(entry.Collection).InsertOne(entry.Document); err != nil {
 			return fmt.Errorf("insert failed: %w", err)
 		}
 
 	case OpTypeUpdate:
 		// Update document
-		if err := coll.UpdateOne(entry.Filter, entry.Update); err != nil {
+		if err := s.db.Collection(entry.Collection).UpdateOne(entry.Filter, entry.Update); err != nil {
 			return fmt.Errorf("update failed: %w", err)
 		}
 
 	case OpTypeDelete:
 		// Delete document
-		if err := coll.DeleteOne(entry.Filter); err != nil {
+		if err := s.db.Collection(entry.Collection).DeleteOne(entry.Filter); err != nil {
 			return fmt.Errorf("delete failed: %w", err)
 		}
 
@@ -238,13 +369,30 @@ func (s *Slave) applyEntry(entry *OplogEntry) error {
 			}
 		}
 
+	case OpTypeRenameCollection:
+		if err := applyRename(s.db, entry); err != nil {
+			return fmt.Errorf("rename collection failed: %w", err)
+		}
+
+	case OpTypeTruncateCollection:
+		// Truncate collection, keeping its indexes
+		if err := s.db.Collection(entry.Collection).Truncate(); err != nil {
+			return fmt.Errorf("truncate collection failed: %w", err)
+		}
+
 	case OpTypeCreateIndex:
-		// Create index - simplified for now
-		// In a full implementation, would need to parse index definition
-		// and call appropriate CreateIndex method
+		if s.config.SkipIndexes {
+			break
+		}
+		// Build the index from its definition; an existing index is kept
+		if err := s.db.Collection(entry.Collection).CreateIndexFromDefinition(entry.IndexDef); err != nil {
+			return fmt.Errorf("create index failed: %w", err)
+		}
 
 	case OpTypeDropIndex:
-		// Drop index - simplified for now
+		if err := applyDropIndex(s.db, entry); err != nil {
+			return fmt.Errorf("drop index failed: %w", err)
+		}
 
 	case OpTypeNoop:
 		// No operation - do nothing
@@ -256,16 +404,45 @@ func (s *Slave) applyEntry(entry *OplogEntry) error {
 	return nil
 }
 
+// applyRename renames a collection as a rename entry describes. A rename
+// that was already applied, leaving only the new collection, is skipped.
+func applyRename(db *database.Database, entry *OplogEntry) error {
+	newName, _ := entry.Document["to"].(string)
+	if newName == "" {
+		return fmt.Errorf("rename entry has no target collection")
+	}
+	err := db.RenameCollection(entry.Collection, newName)
+	if err != nil && err.Error() == fmt.Sprintf("collection %s does not exist", entry.Collection) {
+		return nil
+	}
+	return err
+}
+
+// applyDropIndex drops the index a drop index entry names, ignoring an
+// index that doesn't exist
+func applyDropIndex(db *database.Database, entry *OplogEntry) error {
+	name, _ := entry.IndexDef["name"].(string)
+	if name == "" {
+		return fmt.Errorf("drop index entry has no index name")
+	}
+	err := db.Collection(entry.Collection).DropIndex(name)
+	if err != nil && err.Error() == fmt.Sprintf("index %s does not exist", name) {
+		return nil
+	}
+	return err
+}
+
 // sendHeartbeat sends a heartbeat to the master
 func (s *Slave) sendHeartbeat() {
 	s.mu.RLock()
 	lastOpID := s.lastAppliedOpID
+	client := s.masterClient
 	s.mu.RUnlock()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := s.masterClient.SendHeartbeat(ctx, s.config.SlaveID, lastOpID); err != nil {
+	if err := client.SendHeartbeat(ctx, s.config.SlaveID, lastOpID); err != nil {
 		fmt.Printf("Heartbeat error: %v\n", err)
 	}
 }
@@ -289,11 +466,37 @@ func (s *Slave) Stats() map[string]interface{} {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	applyRate := 0.0
+	if s.applyTime > 0 {
+		applyRate = float64(s.appliedEntries) / s.applyTime.Seconds()
+	}
+	entriesPerBatch := 0.0
+	if s.fetchedBatches > 0 {
+		entriesPerBatch = float64(s.fetchedEntries) / float64(s.fetchedBatches)
+	}
+
 	return map[string]interface{}{
 		"slave_id":            s.config.SlaveID,
 		"last_applied_op_id":  s.lastAppliedOpID,
 		"is_running":          s.isRunning,
 		"replication_errors":  s.replicationErrors,
+		"sync_source":         s.sourceID,
+		"sync_source_switches": s.sourceSwitches,
+		"fetched_batches":      s.fetchedBatches,
+		"fetched_entries":      s.fetchedEntries,
+		"entries_per_batch":    entriesPerBatch,
+		"apply_concurrency":        s.config.ApplyConcurrency,
+		"applied_entries":          s.appliedEntries,
+		"parallel_applied_entries": s.parallelEntries,
+		"apply_barriers":           s.applyBarriers,
+		"grouped_inserts":          s.groupedInserts,
+		"coalesced_entries":        s.coalescedEntries,
+		"applied_transactions":     s.appliedTxns,
+		"apply_time":               s.applyTime.String(),
+		"apply_rate":               applyRate,
+		"replication_lag":          s.applyLag.String(),
+		"seconds_behind":           s.config.SecondsBehind,
+		"held_entries":             len(s.held),
 	}
 }
 
@@ -321,6 +524,26 @@ func (s *Slave) InitialSync(ctx context.Context) error {
 		s.lastAppliedOpID = entry.OpID
 	}
 
+	// Copy the master's collections and indexes, including those that
+	// predate the oplog
+	if catalog, ok := s.masterClient.(IndexCatalog); ok {
+		defs, err := catalog.GetIndexDefinitions(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to fetch index definitions for initial sync: %w", err)
+		}
+		for collName, collDefs := range defs {
+			coll := s.db.Collection(collName)
+			if s.config.SkipIndexes {
+				continue
+			}
+			for _, def := range collDefs {
+				if err := coll.CreateIndexFromDefinition(def); err != nil {
+					return fmt.Errorf("failed to create index %v on %s during initial sync: %w", def["name"], collName, err)
+				}
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,185 @@
+package replication
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultSyncSourceID names SlaveConfig.MasterClient when
+// SlaveConfig.MasterID is empty
+const DefaultSyncSourceID = "master"
+
+// maxSyncSourceHistory is how many sync source changes a slave remembers
+const maxSyncSourceHistory = 100
+
+// SyncSource is a member a slave can replicate from. Every sync source of a
+// slave must serve the same oplog under the same OpIDs, such as the primary
+// and secondaries that log the entries they apply in order, so that the
+// slave can resume from its last applied OpID on any of them.
+type SyncSource struct {
+	ID     string
+	Client MasterClient
+}
+
+// OpIDReporter is implemented by master clients that can report the newest
+// OpID of their oplog. A slave uses it to tell a source that is caught up
+// with it from one that is behind.
+type OpIDReporter interface {
+	GetCurrentOpID(ctx context.Context) (OpID, error)
+}
+
+// SyncSourceChange records a slave switching sync sources
+type SyncSourceChange struct {
+	From            string
+	To              string
+	Reason          string // "unreachable" or "stalled"
+	LastAppliedOpID OpID   // Where the slave resumes on the new source
+	Time            time.Time
+}
+
+// SyncSource returns the ID of the member the slave replicates from
+func (s *Slave) SyncSource() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sourceID
+}
+
+// SyncSourceHistory returns the most recent sync source changes, oldest
+// first. Frequent changes mean the slave is flapping between sources.
+func (s *Slave) SyncSourceHistory() []SyncSourceChange {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]SyncSourceChange(nil), s.sourceHistory...)
+}
+
+// fetchTimeout bounds a single oplog fetch, so that a hung source is noticed
+// within the sync source timeout
+func (s *Slave) fetchTimeout() time.Duration {
+	timeout := 30 * time.Second
+	if t := s.config.SyncSourceTimeout; t > 0 && t < timeout {
+		timeout = t
+	}
+	return timeout
+}
+
+// recordFetch notes a successful fetch; progress means it returned entries
+func (s *Slave) recordFetch(progress bool) {
+	if !progress {
+		return
+	}
+	s.mu.Lock()
+	s.lastProgress = time.Now()
+	s.mu.Unlock()
+}
+
+// checkSyncSource switches sync sources once the current one has made no
+// progress for SyncSourceTimeout. An unreachable source is replaced by any
+// healthy source that is not behind the slave; a reachable one that returns
+// nothing new only by a source that has entries the slave hasn't applied.
+func (s *Slave) checkSyncSource(reachable bool) {
+	if s.config.SyncSourceTimeout <= 0 || len(s.sources) < 2 {
+		return
+	}
+
+	s.mu.RLock()
+	stalled := time.Since(s.lastProgress) >= s.config.SyncSourceTimeout
+	lastOpID := s.lastAppliedOpID
+	current := s.sourceID
+	s.mu.RUnlock()
+	if !stalled {
+		return
+	}
+
+	var best *SyncSource
+	var bestOpID OpID
+	for _, candidate := range s.sources {
+		if candidate.ID == current {
+			continue
+		}
+		opID, ok := s.probeSyncSource(candidate, lastOpID)
+		if !ok || opID < lastOpID || (reachable && opID == lastOpID) {
+			continue
+		}
+		if best == nil || opID > bestOpID {
+			best, bestOpID = candidate, opID
+		}
+	}
+
+	if best == nil {
+		// Keep the current source and check again after another timeout
+		s.mu.Lock()
+		s.lastProgress = time.Now()
+		s.mu.Unlock()
+		return
+	}
+
+	reason := "unreachable"
+	if reachable {
+		reason = "stalled"
+	}
+	s.switchSyncSource(best, reason)
+}
+
+// probeSyncSource returns the newest OpID a source can serve, or false if
+// it is unreachable or can't continue from lastOpID without a gap
+func (s *Slave) probeSyncSource(source *SyncSource, lastOpID OpID) (OpID, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.fetchTimeout())
+	defer cancel()
+
+	entries, err := source.Client.GetOplogEntries(ctx, lastOpID)
+	if err != nil {
+		return 0, false
+	}
+	if len(entries) > 0 {
+		// Resuming anywhere but right after lastOpID would skip or repeat writes
+		if entries[0].OpID != lastOpID+1 {
+			return 0, false
+		}
+		return entries[len(entries)-1].OpID, true
+	}
+
+	if reporter, ok := source.Client.(OpIDReporter); ok {
+		current, err := reporter.GetCurrentOpID(ctx)
+		if err != nil {
+			return 0, false
+		}
+		return current, true
+	}
+	return lastOpID, true
+}
+
+// switchSyncSource makes source the slave's sync source. Replication resumes
+// from the last applied OpID, so no entry is lost or applied twice.
+func (s *Slave) switchSyncSource(source *SyncSource, reason string) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.fetchTimeout())
+	defer cancel()
+
+	// Registration is best effort: a source serves oplog entries to
+	// unregistered slaves, and the old source may be unreachable
+	source.Client.Register(ctx, s.config.SlaveID)
+
+	s.mu.Lock()
+	old := s.masterClient
+	change := SyncSourceChange{
+		From:            s.sourceID,
+		To:              source.ID,
+		Reason:          reason,
+		LastAppliedOpID: s.lastAppliedOpID,
+		Time:            time.Now(),
+	}
+	s.masterClient = source.Client
+	s.sourceID = source.ID
+	s.lastProgress = change.Time
+	s.sourceSwitches++
+	s.sourceHistory = append(s.sourceHistory, change)
+	if len(s.sourceHistory) > maxSyncSourceHistory {
+		s.sourceHistory = s.sourceHistory[len(s.sourceHistory)-maxSyncSourceHistory:]
+	}
+	s.mu.Unlock()
+
+	old.Unregister(ctx, s.config.SlaveID)
+
+	if s.config.OnSyncSourceChange != nil {
+		s.config.OnSyncSourceChange(change)
+	}
+}
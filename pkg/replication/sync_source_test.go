@@ -0,0 +1,182 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/database"
+)
+
+// partitionedClient is a master client that fails while partitioned
+type partitionedClient struct {
+	MasterClient
+	partitioned atomic.Bool
+}
+
+func (c *partitionedClient) GetOplogEntries(ctx context.Context, sinceID OpID) ([]*OplogEntry, error) {
+	if c.partitioned.Load() {
+		return nil, errors.New("connection refused")
+	}
+	return c.MasterClient.GetOplogEntries(ctx, sinceID)
+}
+
+// newSyncSourceMaster starts a master without a database whose oplog holds
+// inserts of the given user indexes
+func newSyncSourceMaster(t *testing.T, name string, users ...int) *Master {
+	t.Helper()
+
+	master, err := NewMaster(DefaultMasterConfig(nil, filepath.Join(t.TempDir(), name+".oplog")))
+	if err != nil {
+		t.Fatalf("Failed to create master: %v", err)
+	}
+	if err := master.Start(); err != nil {
+		t.Fatalf("Failed to start master: %v", err)
+	}
+	t.Cleanup(func() { master.Stop() })
+	logUsers(t, master, users...)
+	return master
+}
+
+func logUsers(t *testing.T, master *Master, users ...int) {
+	t.Helper()
+	for _, i := range users {
+		entry := CreateInsertEntry("default", "users", map[string]interface{}{"index": int64(i)})
+		if err := master.LogOperation(entry); err != nil {
+			t.Fatalf("Failed to log operation: %v", err)
+		}
+	}
+}
+
+func newSyncSourceSlave(t *testing.T, config *SlaveConfig) (*Slave, *database.Database) {
+	t.Helper()
+
+	db, err := database.Open(database.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open slave database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	config.Database = db
+
+	slave, err := NewSlave(config)
+	if err != nil {
+		t.Fatalf("Failed to create slave: %v", err)
+	}
+	return slave, db
+}
+
+func TestSlaveSwitchesFromUnreachableSyncSource(t *testing.T) {
+	primary := newSyncSourceMaster(t, "primary", 0, 1, 2)
+	secondary := newSyncSourceMaster(t, "secondary", 0, 1, 2)
+	client := &partitionedClient{MasterClient: NewLocalMasterClient(primary)}
+
+	var changes []SyncSourceChange
+	config := DefaultSlaveConfig("slave1", nil, client)
+	config.MasterID = "primary"
+	config.SyncSources = []*SyncSource{{ID: "secondary", Client: NewLocalMasterClient(secondary)}}
+	config.SyncSourceTimeout = 50 * time.Millisecond
+	config.OnSyncSourceChange = func(change SyncSourceChange) { changes = append(changes, change) }
+	slave, db := newSyncSourceSlave(t, config)
+
+	if err := slave.fetchAndApplyEntries(); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+
+	// The secondary keeps replicating writes the slave can no longer fetch
+	client.partitioned.Store(true)
+	logUsers(t, secondary, 3, 4)
+
+	if err := slave.fetchAndApplyEntries(); err == nil {
+		t.Fatal("Expected fetch from a partitioned source to fail")
+	}
+	if slave.SyncSource() != "primary" {
+		t.Errorf("Expected no switch before the timeout, got %s", slave.SyncSource())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	slave.fetchAndApplyEntries()
+	if slave.SyncSource() != "secondary" {
+		t.Fatalf("Expected switch to secondary, got %s", slave.SyncSource())
+	}
+	if err := slave.fetchAndApplyEntries(); err != nil {
+		t.Fatalf("Fetch from secondary failed: %v", err)
+	}
+
+	// Replication resumed after OpID 3 without losing or repeating entries
+	if slave.GetLastAppliedOpID() != 5 {
+		t.Errorf("Expected last applied OpID 5, got %d", slave.GetLastAppliedOpID())
+	}
+	for i := 0; i < 5; i++ {
+		count, err := db.Collection("users").Count(map[string]interface{}{"index": int64(i)})
+		if err != nil {
+			t.Fatalf("Count failed: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("Expected user %d once, got %d", i, count)
+		}
+	}
+
+	if len(changes) != 1 {
+		t.Fatalf("Expected 1 sync source change, got %d", len(changes))
+	}
+	change := changes[0]
+	if change.From != "primary" || change.To != "secondary" || change.Reason != "unreachable" || change.LastAppliedOpID != 3 {
+		t.Errorf("Unexpected sync source change: %+v", change)
+	}
+	if stats := slave.Stats(); stats["sync_source_switches"] != 1 || stats["sync_source"] != "secondary" {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+	if history := slave.SyncSourceHistory(); len(history) != 1 || history[0] != change {
+		t.Errorf("Unexpected sync source history: %v", history)
+	}
+}
+
+func TestSlaveSwitchesFromStalledSyncSource(t *testing.T) {
+	primary := newSyncSourceMaster(t, "primary", 0, 1, 2)
+	lagging := newSyncSourceMaster(t, "lagging", 0, 1)
+	ahead := newSyncSourceMaster(t, "ahead", 0, 1, 2)
+
+	config := DefaultSlaveConfig("slave1", nil, NewLocalMasterClient(primary))
+	config.SyncSources = []*SyncSource{
+		{ID: "lagging", Client: NewLocalMasterClient(lagging)},
+		{ID: "ahead", Client: NewLocalMasterClient(ahead)},
+	}
+	config.SyncSourceTimeout = 50 * time.Millisecond
+	slave, _ := newSyncSourceSlave(t, config)
+
+	if err := slave.fetchAndApplyEntries(); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+
+	// A reachable source with nothing new is kept while no source is ahead
+	time.Sleep(60 * time.Millisecond)
+	if err := slave.fetchAndApplyEntries(); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if slave.SyncSource() != DefaultSyncSourceID {
+		t.Errorf("Expected to keep the master, got %s", slave.SyncSource())
+	}
+
+	// The lagging source is behind the slave; the other one has new entries
+	logUsers(t, ahead, 3, 4)
+	time.Sleep(60 * time.Millisecond)
+	if err := slave.fetchAndApplyEntries(); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if slave.SyncSource() != "ahead" {
+		t.Fatalf("Expected switch to ahead, got %s", slave.SyncSource())
+	}
+	if history := slave.SyncSourceHistory(); len(history) != 1 || history[0].Reason != "stalled" {
+		t.Errorf("Unexpected sync source history: %v", history)
+	}
+
+	if err := slave.fetchAndApplyEntries(); err != nil {
+		t.Fatalf("Fetch from ahead failed: %v", err)
+	}
+	if slave.GetLastAppliedOpID() != 5 {
+		t.Errorf("Expected last applied OpID 5, got %d", slave.GetLastAppliedOpID())
+	}
+}
@@ -0,0 +1,49 @@
+package replication
+
+import (
+	"github.com/mnohosten/laura-db/pkg/database"
+	"github.com/mnohosten/laura-db/pkg/mvcc"
+)
+
+// LogTransactions appends the net writes of every session transaction
+// committed on db to oplog, so replicas see exactly what the primary applied.
+// Updates are logged as a $set of the full resulting document keyed by _id,
+// which makes them independent of the filter used inside the transaction.
+// The writes of a transaction are appended together, so replicas fetch and
+// apply them as a unit.
+func LogTransactions(db *database.Database, oplog *Oplog, dbName string) {
+	db.AddCommitListener(func(txnID mvcc.TxnID, ops []database.CommittedOperation) error {
+		entries := make([]*OplogEntry, len(ops))
+		for i, op := range ops {
+			entries[i] = CreateCommittedEntry(dbName, op)
+		}
+		return oplog.AppendTransaction(entries)
+	})
+}
+
+// CreateCommittedEntry creates an oplog entry for a write applied by a
+// committed session transaction
+func CreateCommittedEntry(db string, op database.CommittedOperation) *OplogEntry {
+	filter := map[string]interface{}{"_id": op.DocumentID}
+
+	switch op.Type {
+	case "insert":
+		return CreateInsertEntry(db, op.Collection, op.Document)
+
+	case "update":
+		fields := make(map[string]interface{}, len(op.Document))
+		for k, v := range op.Document {
+			if k != "_id" {
+				fields[k] = v
+			}
+		}
+		entry := CreateUpdateEntry(db, op.Collection, filter, map[string]interface{}{"$set": fields})
+		entry.DocID = op.DocumentID
+		return entry
+
+	default:
+		entry := CreateDeleteEntry(db, op.Collection, filter)
+		entry.DocID = op.DocumentID
+		return entry
+	}
+}
@@ -0,0 +1,219 @@
+package replication
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/database"
+)
+
+func TestLogTransactionsReplicatesSessionWrites(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	primary, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "primary")))
+	if err != nil {
+		t.Fatalf("Failed to open primary: %v", err)
+	}
+	defer primary.Close()
+
+	replica, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "replica")))
+	if err != nil {
+		t.Fatalf("Failed to open replica: %v", err)
+	}
+	defer replica.Close()
+
+	oplog, err := NewOplog(filepath.Join(tmpDir, "oplog.bin"))
+	if err != nil {
+		t.Fatalf("Failed to create oplog: %v", err)
+	}
+	defer oplog.Close()
+
+	LogTransactions(primary, oplog, "testdb")
+
+	session := primary.StartSession()
+	session.InsertOne("accounts", map[string]interface{}{"_id": "a", "tier": "gold", "balance": int64(100)})
+	session.InsertOne("accounts", map[string]interface{}{"_id": "b", "tier": "gold", "balance": int64(200)})
+	session.InsertOne("accounts", map[string]interface{}{"_id": "c", "tier": "silver", "balance": int64(300)})
+	if err := session.CommitTransaction(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	session = primary.StartSession()
+	if _, err := session.UpdateWithOptions("accounts",
+		map[string]interface{}{"tier": "gold"},
+		map[string]interface{}{"$inc": map[string]interface{}{"balance": int64(5)}},
+		&database.UpdateOptions{Multi: true}); err != nil {
+		t.Fatalf("Multi update failed: %v", err)
+	}
+	if _, err := session.UpdateWithOptions("accounts",
+		map[string]interface{}{"_id": "d"},
+		map[string]interface{}{"$set": map[string]interface{}{"tier": "bronze", "balance": int64(1)}},
+		&database.UpdateOptions{Upsert: true}); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if err := session.DeleteOne("accounts", map[string]interface{}{"_id": "c"}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := session.CommitTransaction(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	entries, err := oplog.GetEntriesSince(0)
+	if err != nil {
+		t.Fatalf("Failed to read oplog: %v", err)
+	}
+	// 3 inserts, 2 updates, 1 upsert insert, 1 delete
+	if len(entries) != 7 {
+		t.Fatalf("Expected 7 oplog entries, got %d", len(entries))
+	}
+
+	if _, err := ReplayOplog(replica, entries, time.Time{}, time.Now()); err != nil {
+		t.Fatalf("Failed to apply oplog to replica: %v", err)
+	}
+
+	assertSameDocuments(t, primary, replica, "accounts")
+}
+
+// assertSameDocuments checks that a collection holds identical documents on
+// both databases
+func assertSameDocuments(t *testing.T, primary, replica *database.Database, collName string) {
+	t.Helper()
+
+	primaryDocs, err := primary.Collection(collName).Find(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Failed to read primary: %v", err)
+	}
+	replicaDocs, err := replica.Collection(collName).Find(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Failed to read replica: %v", err)
+	}
+	if len(primaryDocs) != len(replicaDocs) {
+		t.Fatalf("Expected %d documents on replica, got %d", len(primaryDocs), len(replicaDocs))
+	}
+
+	for _, doc := range primaryDocs {
+		id, _ := doc.Get("_id")
+		replicaDoc, err := replica.Collection(collName).FindOne(map[string]interface{}{"_id": id})
+		if err != nil {
+			t.Fatalf("Document %v missing on replica: %v", id, err)
+		}
+		for _, key := range doc.Keys() {
+			want, _ := doc.Get(key)
+			got, _ := replicaDoc.Get(key)
+			if got != want {
+				t.Errorf("Document %v field %s: expected %v, got %v", id, key, want, got)
+			}
+		}
+		if len(doc.Keys()) != len(replicaDoc.Keys()) {
+			t.Errorf("Document %v: expected %d fields, got %d", id, len(doc.Keys()), len(replicaDoc.Keys()))
+		}
+	}
+}
+
+func TestLogTransactionsWithSavepoints(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	primary, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "primary")))
+	if err != nil {
+		t.Fatalf("Failed to open primary: %v", err)
+	}
+	defer primary.Close()
+
+	replica, err := database.Open(database.DefaultConfig(filepath.Join(tmpDir, "replica")))
+	if err != nil {
+		t.Fatalf("Failed to open replica: %v", err)
+	}
+	defer replica.Close()
+
+	oplog, err := NewOplog(filepath.Join(tmpDir, "oplog.bin"))
+	if err != nil {
+		t.Fatalf("Failed to create oplog: %v", err)
+	}
+	defer oplog.Close()
+
+	LogTransactions(primary, oplog, "testdb")
+
+	seed := primary.StartSession()
+	seed.InsertOne("accounts", map[string]interface{}{"_id": "a", "balance": int64(100)})
+	seed.InsertOne("accounts", map[string]interface{}{"_id": "b", "balance": int64(200)})
+	if err := seed.CommitTransaction(); err != nil {
+		t.Fatalf("Failed to commit seed: %v", err)
+	}
+	seedEntries := oplog.GetCurrentID()
+
+	session := primary.StartSession()
+	session.InsertOne("accounts", map[string]interface{}{"_id": "x", "balance": int64(1)})
+
+	// Rolled back: update of a, insert of y, delete of b
+	session.CreateSavepoint("sp1")
+	session.UpdateOne("accounts", map[string]interface{}{"_id": "a"}, map[string]interface{}{"$inc": map[string]interface{}{"balance": int64(-50)}})
+	session.InsertOne("accounts", map[string]interface{}{"_id": "y", "balance": int64(2)})
+	session.DeleteOne("accounts", map[string]interface{}{"_id": "b"})
+	if err := session.RollbackToSavepoint("sp1"); err != nil {
+		t.Fatalf("Failed to roll back to sp1: %v", err)
+	}
+
+	session.UpdateOne("accounts", map[string]interface{}{"_id": "a"}, map[string]interface{}{"$inc": map[string]interface{}{"balance": int64(10)}})
+
+	// Released: insert of z is kept
+	session.CreateSavepoint("sp2")
+	session.InsertOne("accounts", map[string]interface{}{"_id": "z", "balance": int64(3)})
+	if err := session.ReleaseSavepoint("sp2"); err != nil {
+		t.Fatalf("Failed to release sp2: %v", err)
+	}
+
+	// Rolled back: update of z
+	session.CreateSavepoint("sp3")
+	session.UpdateOne("accounts", map[string]interface{}{"_id": "z"}, map[string]interface{}{"$set": map[string]interface{}{"balance": int64(999)}})
+	if err := session.RollbackToSavepoint("sp3"); err != nil {
+		t.Fatalf("Failed to roll back to sp3: %v", err)
+	}
+
+	if err := session.CommitTransaction(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	entries, err := oplog.GetEntriesSince(seedEntries)
+	if err != nil {
+		t.Fatalf("Failed to read oplog: %v", err)
+	}
+
+	// Only the net effect is logged: insert x, update a, insert z
+	expected := []struct {
+		op OpType
+		id string
+	}{
+		{OpTypeInsert, "x"},
+		{OpTypeUpdate, "a"},
+		{OpTypeInsert, "z"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d oplog entries, got %d", len(expected), len(entries))
+	}
+	for i, want := range expected {
+		if entries[i].OpType != want.op || entries[i].DocID != want.id {
+			t.Errorf("Entry %d: expected %s of %s, got %s of %v", i, want.op, want.id, entries[i].OpType, entries[i].DocID)
+		}
+	}
+	if balance := entries[2].Document["balance"]; balance != int64(3) {
+		t.Errorf("Expected z to be logged with balance 3, got %v", balance)
+	}
+
+	all, err := oplog.GetEntriesSince(0)
+	if err != nil {
+		t.Fatalf("Failed to read oplog: %v", err)
+	}
+	if _, err := ReplayOplog(replica, all, time.Time{}, time.Now()); err != nil {
+		t.Fatalf("Failed to apply oplog to replica: %v", err)
+	}
+
+	assertSameDocuments(t, primary, replica, "accounts")
+
+	if _, err := replica.Collection("accounts").FindOne(map[string]interface{}{"_id": "y"}); err == nil {
+		t.Error("Expected rolled back document y to be absent on replica")
+	}
+	if _, err := replica.Collection("accounts").FindOne(map[string]interface{}{"_id": "b"}); err != nil {
+		t.Errorf("Expected document b to survive rolled back delete: %v", err)
+	}
+}
@@ -0,0 +1,373 @@
+package replication
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/database"
+	"github.com/mnohosten/laura-db/pkg/storage"
+)
+
+// walSegmentExt is the file extension of archived WAL segments
+const walSegmentExt = ".walseg"
+
+// ErrArchiveGap is returned when WAL records needed for a restore were
+// never archived, because the WAL was truncated before the archiver shipped
+// them or a segment is missing from the sink
+var ErrArchiveGap = errors.New("gap in archived WAL")
+
+// WALSegment is a batch of WAL records shipped to an archive sink. Segments
+// chain by LSN: each one covers the records after PrevLSN up to LastLSN, and
+// PrevLSN is the LastLSN of the segment shipped before it.
+type WALSegment struct {
+	PrevLSN uint64            `json:"prev_lsn"`
+	LastLSN uint64            `json:"last_lsn"`
+	Gap     bool              `json:"gap,omitempty"` // Records after PrevLSN were lost before shipping
+	Created time.Time         `json:"created"`
+	Records []*ArchivedRecord `json:"records"`
+}
+
+// ArchivedRecord is a logged collection write in a WAL segment
+type ArchivedRecord struct {
+	LSN   uint64      `json:"lsn"`
+	Entry *OplogEntry `json:"entry"`
+}
+
+// segmentName returns the name of the segment covering (prevLSN, lastLSN].
+// Names sort in LSN order.
+func segmentName(prevLSN, lastLSN uint64) string {
+	return fmt.Sprintf("%020d-%020d%s", prevLSN, lastLSN, walSegmentExt)
+}
+
+// parseSegmentName returns the LSN range of a segment name
+func parseSegmentName(name string) (prevLSN, lastLSN uint64, ok bool) {
+	prev, last, found := strings.Cut(strings.TrimSuffix(name, walSegmentExt), "-")
+	if !found || !strings.HasSuffix(name, walSegmentExt) {
+		return 0, 0, false
+	}
+	prevLSN, err := strconv.ParseUint(prev, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	lastLSN, err = strconv.ParseUint(last, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return prevLSN, lastLSN, true
+}
+
+// listSegments returns the segment names in a sink in LSN order
+func listSegments(sink ArchiveSink) ([]string, error) {
+	names, err := sink.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive: %w", err)
+	}
+
+	segments := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, _, ok := parseSegmentName(name); ok {
+			segments = append(segments, name)
+		}
+	}
+	sort.Strings(segments)
+	return segments, nil
+}
+
+// WALArchiverConfig configures a WALArchiver
+type WALArchiverConfig struct {
+	// Interval is the time between checks for new WAL records
+	Interval time.Duration
+
+	// MaxSegmentRecords is the most WAL records scanned into one segment
+	MaxSegmentRecords int
+
+	// MaxHoldBytes is how large the WAL may grow while checkpoints keep the
+	// records the archiver hasn't shipped yet. Past it, checkpoints truncate
+	// the WAL as usual so a stalled archiver can't fill the disk, and the
+	// archiver reports a gap.
+	MaxHoldBytes int64
+}
+
+// DefaultWALArchiverConfig returns default archiver configuration
+func DefaultWALArchiverConfig() *WALArchiverConfig {
+	return &WALArchiverConfig{
+		Interval:          time.Second,
+		MaxSegmentRecords: 1000,
+		MaxHoldBytes:      256 * 1024 * 1024,
+	}
+}
+
+// WALArchiver ships the collection writes logged in a database's WAL to an
+// archive sink, for continuous backup. It tails the WAL from a background
+// goroutine through its own file handle, so foreground writes never wait for
+// the sink; an archiver that falls behind only delays WAL truncation, up to
+// MaxHoldBytes. Together with a base backup, the archive restores the
+// database to any later LSN or time (see RestoreFromArchive).
+type WALArchiver struct {
+	db     *database.Database
+	wal    *storage.WAL
+	sink   ArchiveSink
+	config *WALArchiverConfig
+
+	shipMu   sync.Mutex // Serializes shipping
+	chainLSN uint64     // LastLSN of the newest segment in the sink
+	scanLSN  uint64     // Every record up to this LSN has been scanned
+
+	mu          sync.Mutex
+	running     bool
+	archivedLSN uint64 // scanLSN, readable without waiting for a shipment
+	stopCh      chan struct{}
+	wg          sync.WaitGroup
+	segments    int64
+	records     int64
+	gaps        int64
+	lastShipped time.Time
+	lastErr     error
+}
+
+// NewWALArchiver creates an archiver that ships db's WAL to sink. It resumes
+// after the newest segment already in the sink.
+func NewWALArchiver(db *database.Database, sink ArchiveSink, config *WALArchiverConfig) (*WALArchiver, error) {
+	if config == nil {
+		config = DefaultWALArchiverConfig()
+	}
+
+	segments, err := listSegments(sink)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &WALArchiver{
+		db:     db,
+		wal:    db.WAL(),
+		sink:   sink,
+		config: config,
+	}
+	if len(segments) > 0 {
+		_, lastLSN, _ := parseSegmentName(segments[len(segments)-1])
+		a.chainLSN = lastLSN
+	} else {
+		// A new archive starts with the oldest record still in the WAL
+		a.chainLSN = a.wal.DiscardedLSN()
+	}
+	a.advance(a.chainLSN)
+	return a, nil
+}
+
+// Start turns on WAL archiving in the database and starts shipping in the
+// background
+func (a *WALArchiver) Start() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.running {
+		return fmt.Errorf("WAL archiver already running")
+	}
+
+	a.db.SetWALArchiving(true)
+	a.shipMu.Lock()
+	a.wal.Hold(a.scanLSN, a.config.MaxHoldBytes)
+	a.shipMu.Unlock()
+
+	a.running = true
+	a.stopCh = make(chan struct{})
+	a.wg.Add(1)
+	go a.loop(a.stopCh)
+
+	return nil
+}
+
+// Stop ships the remaining records and stops the archiver. The database
+// keeps logging writes to the WAL, so that a gap is detected if they are
+// truncated before the archiver is started again.
+func (a *WALArchiver) Stop() error {
+	a.mu.Lock()
+	if !a.running {
+		a.mu.Unlock()
+		return nil
+	}
+	a.running = false
+	close(a.stopCh)
+	a.mu.Unlock()
+
+	a.wg.Wait()
+
+	err := a.Ship()
+	a.wal.ReleaseHold()
+	return err
+}
+
+// loop ships new records every interval until stopped
+func (a *WALArchiver) loop(stopCh chan struct{}) {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			a.Ship()
+		}
+	}
+}
+
+// Ship writes the WAL records appended since the last call to the sink. It
+// returns an error wrapping ErrArchiveGap if records were truncated from the
+// WAL before they could be shipped; the gap is marked in the archive and
+// shipping continues after it.
+func (a *WALArchiver) Ship() error {
+	a.shipMu.Lock()
+	defer a.shipMu.Unlock()
+
+	err := a.ship()
+
+	a.mu.Lock()
+	a.lastErr = err
+	a.mu.Unlock()
+
+	return err
+}
+
+// ship scans and ships new records
+// Must be called with a.shipMu held
+func (a *WALArchiver) ship() error {
+	if current := a.wal.CurrentLSN(); current < a.scanLSN {
+		return fmt.Errorf("WAL is at LSN %d, behind the archive at LSN %d", current, a.scanLSN)
+	}
+
+	records, err := a.wal.RecordsAfter(a.scanLSN)
+	if err != nil {
+		return err
+	}
+
+	// Records after scanLSN were truncated if the log no longer has them.
+	// If it was read before the truncation, its first record is a removed one.
+	var gapErr error
+	discarded := a.wal.DiscardedLSN()
+	gap := discarded > a.scanLSN && (len(records) == 0 || records[0].LSN > discarded)
+	if gap {
+		gapErr = fmt.Errorf("%w: WAL records %d to %d were truncated before shipping", ErrArchiveGap, a.scanLSN+1, discarded)
+		if err := a.put(gap, discarded, nil); err != nil {
+			return err
+		}
+		a.advance(discarded)
+		a.mu.Lock()
+		a.gaps++
+		a.mu.Unlock()
+	}
+
+	for len(records) > 0 {
+		n := len(records)
+		if a.config.MaxSegmentRecords > 0 && n > a.config.MaxSegmentRecords {
+			n = a.config.MaxSegmentRecords
+		}
+		batch := records[:n]
+		records = records[n:]
+
+		archived := make([]*ArchivedRecord, 0, len(batch))
+		for _, record := range batch {
+			if record.Type != storage.LogRecordOperation {
+				continue
+			}
+			var entry OplogEntry
+			if err := json.Unmarshal(record.Data, &entry); err != nil {
+				return fmt.Errorf("invalid operation record at LSN %d: %w", record.LSN, err)
+			}
+			archived = append(archived, &ArchivedRecord{LSN: record.LSN, Entry: &entry})
+		}
+
+		lastLSN := batch[len(batch)-1].LSN
+		if len(archived) > 0 {
+			if err := a.put(false, lastLSN, archived); err != nil {
+				return err
+			}
+		}
+		a.advance(lastLSN)
+	}
+
+	a.wal.Hold(a.scanLSN, a.config.MaxHoldBytes)
+	return gapErr
+}
+
+// advance records that every record up to lsn has been scanned
+// Must be called with a.shipMu held, or before the archiver is shared
+func (a *WALArchiver) advance(lsn uint64) {
+	a.scanLSN = lsn
+	a.mu.Lock()
+	a.archivedLSN = lsn
+	a.mu.Unlock()
+}
+
+// put stores a segment following the newest one in the sink
+// Must be called with a.shipMu held
+func (a *WALArchiver) put(gap bool, lastLSN uint64, records []*ArchivedRecord) error {
+	segment := &WALSegment{
+		PrevLSN: a.chainLSN,
+		LastLSN: lastLSN,
+		Gap:     gap,
+		Created: time.Now(),
+		Records: records,
+	}
+	data, err := json.Marshal(segment)
+	if err != nil {
+		return fmt.Errorf("failed to encode WAL segment: %w", err)
+	}
+	if err := a.sink.Put(segmentName(segment.PrevLSN, segment.LastLSN), data); err != nil {
+		return fmt.Errorf("failed to ship WAL segment: %w", err)
+	}
+	a.chainLSN = lastLSN
+
+	a.mu.Lock()
+	a.segments++
+	a.records += int64(len(records))
+	a.lastShipped = segment.Created
+	a.mu.Unlock()
+
+	return nil
+}
+
+// ArchivedLSN returns the LSN up to which the WAL has been shipped
+func (a *WALArchiver) ArchivedLSN() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.archivedLSN
+}
+
+// Stats returns archiver statistics
+func (a *WALArchiver) Stats() map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	currentLSN := a.wal.CurrentLSN()
+	lag := uint64(0)
+	if currentLSN > a.archivedLSN {
+		lag = currentLSN - a.archivedLSN
+	}
+
+	stats := map[string]interface{}{
+		"running":      a.running,
+		"current_lsn":  currentLSN,
+		"archived_lsn": a.archivedLSN,
+		"lag_records":  lag,
+		"segments":     a.segments,
+		"records":      a.records,
+		"gaps":         a.gaps,
+	}
+	if !a.lastShipped.IsZero() {
+		stats["last_shipped"] = a.lastShipped
+	}
+	if a.lastErr != nil {
+		stats["last_error"] = a.lastErr.Error()
+	}
+	return stats
+}
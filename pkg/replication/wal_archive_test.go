@@ -0,0 +1,365 @@
+package replication
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/database"
+	"github.com/mnohosten/laura-db/pkg/encryption"
+)
+
+// newArchivedDatabase opens a database whose WAL is archived to sink
+func newArchivedDatabase(t *testing.T, sink ArchiveSink, config *WALArchiverConfig) (*database.Database, *WALArchiver) {
+	t.Helper()
+
+	db, err := database.Open(database.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if config == nil {
+		config = DefaultWALArchiverConfig()
+		config.Interval = time.Hour // Tests ship explicitly
+	}
+	archiver, err := NewWALArchiver(db, sink, config)
+	if err != nil {
+		t.Fatalf("Failed to create archiver: %v", err)
+	}
+	if err := archiver.Start(); err != nil {
+		t.Fatalf("Failed to start archiver: %v", err)
+	}
+	t.Cleanup(func() { archiver.Stop() })
+
+	return db, archiver
+}
+
+func TestRestoreFromArchive(t *testing.T) {
+	sink, err := NewDirSink(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	db, archiver := newArchivedDatabase(t, sink, nil)
+	users := db.Collection("users")
+
+	users.InsertOne(map[string]interface{}{"_id": "u1", "name": "alice", "age": int64(30)})
+	users.InsertOne(map[string]interface{}{"_id": "u2", "name": "bob", "age": int64(40)})
+
+	var base bytes.Buffer
+	if err := db.Backup(&base); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	users.InsertOne(map[string]interface{}{"_id": "u3", "name": "carol", "age": int64(50)})
+	if err := archiver.Ship(); err != nil {
+		t.Fatalf("Ship failed: %v", err)
+	}
+	users.UpdateOne(map[string]interface{}{"_id": "u1"}, map[string]interface{}{"$set": map[string]interface{}{"age": int64(31)}})
+	if err := archiver.Ship(); err != nil {
+		t.Fatalf("Ship failed: %v", err)
+	}
+	targetLSN := archiver.ArchivedLSN()
+
+	time.Sleep(5 * time.Millisecond)
+	users.DeleteOne(map[string]interface{}{"_id": "u2"})
+	if err := archiver.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	segments, _ := sink.List()
+	if len(segments) != 3 {
+		t.Errorf("Expected 3 segments, got %d", len(segments))
+	}
+
+	restored, applied, err := RestoreFromArchive(bytes.NewReader(base.Bytes()), t.TempDir(), sink, ArchiveTarget{LSN: targetLSN})
+	if err != nil {
+		t.Fatalf("RestoreFromArchive failed: %v", err)
+	}
+	defer restored.Close()
+
+	if applied != 2 {
+		t.Errorf("Expected 2 replayed writes, got %d", applied)
+	}
+	count, _ := restored.Collection("users").Count(map[string]interface{}{})
+	if count != 3 {
+		t.Errorf("Expected 3 documents at target LSN, got %d", count)
+	}
+	doc, err := restored.Collection("users").FindOne(map[string]interface{}{"_id": "u1"})
+	if err != nil {
+		t.Fatalf("Expected u1: %v", err)
+	}
+	if age, _ := doc.Get("age"); age != int64(31) {
+		t.Errorf("Expected age 31 for u1, got %v", age)
+	}
+
+	// Without a target every archived write is replayed
+	latest, applied, err := RestoreFromArchive(bytes.NewReader(base.Bytes()), t.TempDir(), sink, ArchiveTarget{})
+	if err != nil {
+		t.Fatalf("RestoreFromArchive failed: %v", err)
+	}
+	defer latest.Close()
+
+	if applied != 3 {
+		t.Errorf("Expected 3 replayed writes, got %d", applied)
+	}
+	if _, err := latest.Collection("users").FindOne(map[string]interface{}{"_id": "u2"}); !errors.Is(err, database.ErrDocumentNotFound) {
+		t.Errorf("Expected u2 to be deleted, got %v", err)
+	}
+}
+
+func TestRestoreFromArchiveToTime(t *testing.T) {
+	sink, _ := NewDirSink(t.TempDir())
+	db, archiver := newArchivedDatabase(t, sink, nil)
+	orders := db.Collection("orders")
+
+	var base bytes.Buffer
+	if err := db.Backup(&base); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	orders.InsertOne(map[string]interface{}{"_id": "o1", "total": int64(10)})
+	time.Sleep(5 * time.Millisecond)
+	target := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	orders.InsertOne(map[string]interface{}{"_id": "o2", "total": int64(20)})
+
+	// Both writes land in one segment; the target splits it
+	if err := archiver.Ship(); err != nil {
+		t.Fatalf("Ship failed: %v", err)
+	}
+
+	restored, applied, err := RestoreFromArchive(&base, t.TempDir(), sink, ArchiveTarget{Time: target})
+	if err != nil {
+		t.Fatalf("RestoreFromArchive failed: %v", err)
+	}
+	defer restored.Close()
+
+	if applied != 1 {
+		t.Errorf("Expected 1 replayed write, got %d", applied)
+	}
+	if count, _ := restored.Collection("orders").Count(map[string]interface{}{}); count != 1 {
+		t.Errorf("Expected 1 order at target time, got %d", count)
+	}
+}
+
+func TestWALArchiverResumesChain(t *testing.T) {
+	sink, _ := NewDirSink(t.TempDir())
+	db, archiver := newArchivedDatabase(t, sink, nil)
+
+	db.Collection("events").InsertOne(map[string]interface{}{"_id": "e1"})
+	if err := archiver.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	// A new archiver continues after the newest segment in the sink
+	resumed, err := NewWALArchiver(db, sink, DefaultWALArchiverConfig())
+	if err != nil {
+		t.Fatalf("Failed to create archiver: %v", err)
+	}
+	if resumed.ArchivedLSN() != archiver.ArchivedLSN() {
+		t.Errorf("Expected resume at LSN %d, got %d", archiver.ArchivedLSN(), resumed.ArchivedLSN())
+	}
+
+	db.Collection("events").InsertOne(map[string]interface{}{"_id": "e2"})
+	if err := resumed.Ship(); err != nil {
+		t.Fatalf("Ship failed: %v", err)
+	}
+
+	names, _ := listSegments(sink)
+	if len(names) != 2 {
+		t.Fatalf("Expected 2 segments, got %d", len(names))
+	}
+	_, firstLast, _ := parseSegmentName(names[0])
+	secondPrev, _, _ := parseSegmentName(names[1])
+	if secondPrev != firstLast {
+		t.Errorf("Expected second segment to follow LSN %d, got %d", firstLast, secondPrev)
+	}
+}
+
+func TestWALArchiverGap(t *testing.T) {
+	sink, _ := NewDirSink(t.TempDir())
+	config := DefaultWALArchiverConfig()
+	config.Interval = time.Hour
+	config.MaxHoldBytes = 1 // Checkpoints truncate unshipped records
+	db, archiver := newArchivedDatabase(t, sink, config)
+
+	var base bytes.Buffer
+	if err := db.Backup(&base); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	db.Collection("items").InsertOne(map[string]interface{}{"_id": "i1"})
+	if err := db.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+
+	if err := archiver.Ship(); !errors.Is(err, ErrArchiveGap) {
+		t.Fatalf("Expected ErrArchiveGap, got %v", err)
+	}
+	if gaps := archiver.Stats()["gaps"]; gaps != int64(1) {
+		t.Errorf("Expected 1 gap in stats, got %v", gaps)
+	}
+
+	// Shipping continues after the gap
+	db.Collection("items").InsertOne(map[string]interface{}{"_id": "i2"})
+	if err := archiver.Ship(); err != nil {
+		t.Fatalf("Ship after gap failed: %v", err)
+	}
+
+	if _, _, err := RestoreFromArchive(&base, t.TempDir(), sink, ArchiveTarget{}); !errors.Is(err, ErrArchiveGap) {
+		t.Errorf("Expected restore across the gap to fail with ErrArchiveGap, got %v", err)
+	}
+
+	// A backup taken after the gap restores from the archive
+	var later bytes.Buffer
+	if err := db.Backup(&later); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	db.Collection("items").InsertOne(map[string]interface{}{"_id": "i3"})
+	if err := archiver.Ship(); err != nil {
+		t.Fatalf("Ship failed: %v", err)
+	}
+
+	restored, applied, err := RestoreFromArchive(&later, t.TempDir(), sink, ArchiveTarget{})
+	if err != nil {
+		t.Fatalf("RestoreFromArchive failed: %v", err)
+	}
+	defer restored.Close()
+	if applied != 1 {
+		t.Errorf("Expected 1 replayed write, got %d", applied)
+	}
+	if count, _ := restored.Collection("items").Count(map[string]interface{}{}); count != 3 {
+		t.Errorf("Expected 3 items, got %d", count)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/archive/")
+		switch {
+		case r.Method == http.MethodGet && name == "":
+			names := make([]string, 0, len(objects))
+			for name := range objects {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			json.NewEncoder(w).Encode(names)
+		case r.Method == http.MethodGet:
+			data, ok := objects[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case r.Method == http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			objects[name] = data
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	sink := NewHTTPSink(server.URL + "/archive/")
+	sink.SetHeader("Authorization", "Bearer secret")
+	db, archiver := newArchivedDatabase(t, sink, nil)
+
+	var base bytes.Buffer
+	if err := db.Backup(&base); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	db.Collection("logs").InsertOne(map[string]interface{}{"_id": "l1", "msg": "hello"})
+	if err := archiver.Ship(); err != nil {
+		t.Fatalf("Ship failed: %v", err)
+	}
+
+	restored, applied, err := RestoreFromArchive(&base, t.TempDir(), sink, ArchiveTarget{})
+	if err != nil {
+		t.Fatalf("RestoreFromArchive failed: %v", err)
+	}
+	defer restored.Close()
+	if applied != 1 {
+		t.Errorf("Expected 1 replayed write, got %d", applied)
+	}
+
+	if _, err := sink.Get("missing" + walSegmentExt); err == nil {
+		t.Error("Expected error fetching a missing segment")
+	}
+}
+
+func TestEncryptedSink(t *testing.T) {
+	dir, err := NewDirSink(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	config, _ := encryption.NewConfigFromPassword("archive-password", encryption.AlgorithmAES256GCM)
+	sink, err := NewEncryptedSink(dir, config)
+	if err != nil {
+		t.Fatalf("Failed to create encrypted sink: %v", err)
+	}
+	db, archiver := newArchivedDatabase(t, sink, nil)
+	users := db.Collection("users")
+
+	users.InsertOne(map[string]interface{}{"_id": "u1", "name": "alice"})
+	var base bytes.Buffer
+	if err := db.BackupToEncrypted(&base, config); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	users.InsertOne(map[string]interface{}{"_id": "u2", "name": "bob"})
+	if err := archiver.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	// Segments are stored encrypted
+	names, _ := dir.List()
+	if len(names) == 0 {
+		t.Fatal("Expected archived segments")
+	}
+	for _, name := range names {
+		data, _ := dir.Get(name)
+		if !encryption.IsEncryptedArchive(data) || bytes.Contains(data, []byte("bob")) {
+			t.Errorf("Segment %s is not encrypted", name)
+		}
+	}
+
+	backup, err := encryption.NewArchiveReader(bytes.NewReader(base.Bytes()), config)
+	if err != nil {
+		t.Fatalf("Failed to open encrypted backup: %v", err)
+	}
+	restored, applied, err := RestoreFromArchive(backup, t.TempDir(), sink, ArchiveTarget{})
+	if err != nil {
+		t.Fatalf("RestoreFromArchive failed: %v", err)
+	}
+	defer restored.Close()
+	if applied != 1 {
+		t.Errorf("Expected 1 replayed write, got %d", applied)
+	}
+	if count, _ := restored.Collection("users").Count(map[string]interface{}{}); count != 2 {
+		t.Errorf("Expected 2 documents, got %d", count)
+	}
+
+	// Segments don't decrypt with another key
+	wrong, _ := encryption.NewConfigFromPassword("other-password", encryption.AlgorithmAES256GCM)
+	wrongSink, _ := NewEncryptedSink(dir, wrong)
+	if _, err := wrongSink.Get(names[0]); !errors.Is(err, encryption.ErrArchiveAuth) {
+		t.Errorf("Expected ErrArchiveAuth with the wrong key, got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/audit"
+	"github.com/mnohosten/laura-db/pkg/database"
+)
+
+// reloadableConfig lists the Config fields Reconfigure can change on a
+// running server
+var reloadableConfig = map[string]bool{
+	"BufferSize":         true,
+	"Durability":         true,
+	"SlowQueryThreshold": true,
+	"AuditLevel":         true,
+	"ReadOnly":           true,
+	"RateLimit":          true,
+}
+
+// RuntimeConfig holds the settings of a running server that Reconfigure can
+// change, as /_admin/config shows and updates them
+type RuntimeConfig struct {
+	BufferSize         int              `json:"bufferSize"`
+	Durability         string           `json:"durability"`
+	SlowQueryThreshold string           `json:"slowQueryThreshold"` // A duration, such as "250ms" ("0s" disables)
+	AuditLevel         string           `json:"auditLevel"`
+	ReadOnly           bool             `json:"readOnly"`
+	RateLimit          *RateLimitConfig `json:"rateLimit"`
+}
+
+// databaseConfig returns the configuration of the database served under
+// config
+func databaseConfig(config *Config) *database.Config {
+	dbConfig := &database.Config{
+		DataDir:        config.DataDir,
+		Name:           config.DatabaseName,
+		BufferPoolSize: config.BufferSize,
+		Durability:     database.Durability(config.Durability),
+	}
+	if config.AuditLevel != "" {
+		dbConfig.AuditConfig = audit.DefaultConfig()
+		dbConfig.AuditConfig.MinSeverity = audit.Severity(config.AuditLevel)
+	}
+	return dbConfig
+}
+
+// Reconfigure applies config to the running server without restarting it.
+// The buffer pool size, durability, slow query threshold, audit level,
+// read-only mode and rate limits can change at runtime, except that
+// turning auditing or rate limiting on or off needs a restart. A config
+// that changes anything else is rejected with a
+// *database.RestartRequiredError naming the fields, and nothing is applied.
+func (s *Server) Reconfigure(config *Config) error {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	if fields := s.restartFields(config); len(fields) > 0 {
+		return &database.RestartRequiredError{Fields: fields}
+	}
+	if config.SlowQueryThreshold < 0 {
+		return fmt.Errorf("slow query threshold must not be negative, got %v", config.SlowQueryThreshold)
+	}
+	if err := s.db.Reconfigure(databaseConfig(config)); err != nil {
+		return err
+	}
+
+	if config.SlowQueryThreshold > 0 {
+		s.slowQueries.SetThreshold(config.SlowQueryThreshold)
+		s.slowQueries.Enable()
+	} else {
+		s.slowQueries.Disable()
+	}
+	s.db.SetReadOnly(config.ReadOnly)
+	if s.rateLimiter != nil {
+		s.rateLimiter.setConfig(config.RateLimit)
+	}
+
+	s.config.BufferSize = config.BufferSize
+	s.config.Durability = config.Durability
+	s.config.SlowQueryThreshold = config.SlowQueryThreshold
+	s.config.AuditLevel = config.AuditLevel
+	s.config.ReadOnly = config.ReadOnly
+	s.config.RateLimit = config.RateLimit
+	return nil
+}
+
+// restartFields returns the names of the fields of config that differ from
+// the running configuration and can't change at runtime
+// Must be called with s.configMu held
+func (s *Server) restartFields(config *Config) []string {
+	var fields []string
+	currentValue, value := reflect.ValueOf(s.config).Elem(), reflect.ValueOf(config).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		name := value.Type().Field(i).Name
+		if reloadableConfig[name] {
+			continue
+		}
+		if !reflect.DeepEqual(currentValue.Field(i).Interface(), value.Field(i).Interface()) {
+			fields = append(fields, name)
+		}
+	}
+
+	if (s.config.AuditLevel == "") != (config.AuditLevel == "") {
+		fields = append(fields, "AuditLevel")
+	}
+	if (s.config.RateLimit == nil) != (config.RateLimit == nil) {
+		fields = append(fields, "RateLimit")
+	}
+	return fields
+}
+
+// RuntimeConfig returns the settings of the server Reconfigure can change
+func (s *Server) RuntimeConfig() RuntimeConfig {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+
+	return RuntimeConfig{
+		BufferSize:         s.config.BufferSize,
+		Durability:         s.config.Durability,
+		SlowQueryThreshold: s.config.SlowQueryThreshold.String(),
+		AuditLevel:         s.config.AuditLevel,
+		ReadOnly:           s.config.ReadOnly,
+		RateLimit:          s.config.RateLimit,
+	}
+}
+
+// handleGetConfig returns the runtime settings of the server
+func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	WriteSuccess(w, s.RuntimeConfig())
+}
+
+// handleUpdateConfig applies the runtime settings in the request body,
+// keeping the current value of those it leaves out, and returns the
+// resulting settings
+func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
+	settings := s.RuntimeConfig()
+	if settings.RateLimit != nil {
+		// Decode into a copy, the running one is never modified
+		rateLimit := *settings.RateLimit
+		rateLimit.Users = maps.Clone(rateLimit.Users)
+		settings.RateLimit = &rateLimit
+	}
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		WriteError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	threshold, err := time.ParseDuration(settings.SlowQueryThreshold)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid slowQueryThreshold: %v", err))
+		return
+	}
+
+	s.configMu.RLock()
+	config := *s.config
+	s.configMu.RUnlock()
+	config.BufferSize = settings.BufferSize
+	config.Durability = settings.Durability
+	config.SlowQueryThreshold = threshold
+	config.AuditLevel = settings.AuditLevel
+	config.ReadOnly = settings.ReadOnly
+	config.RateLimit = settings.RateLimit
+
+	if err := s.Reconfigure(&config); err != nil {
+		if errors.Is(err, database.ErrRestartRequired) {
+			WriteError(w, http.StatusConflict, "RestartRequired", err.Error())
+			return
+		}
+		WriteError(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+	WriteSuccess(w, s.RuntimeConfig())
+}
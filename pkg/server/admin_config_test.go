@@ -0,0 +1,142 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/auth"
+	"github.com/mnohosten/laura-db/pkg/database"
+)
+
+// sendConfig sends a request to /_admin/config with the given token and
+// JSON body
+func sendConfig(srv *Server, method, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/_admin/config", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	srv.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestServerReconfigure(t *testing.T) {
+	srv, cleanup := setupRateLimitedServer(t, &RateLimitConfig{
+		Default: ClientLimits{Read: Limit{Rate: 1, Burst: 1}},
+	})
+	defer cleanup()
+
+	if rr := sendFrom(srv, "GET", "/_collections", "10.0.0.1:1234", nil); rr.Code != http.StatusOK {
+		t.Fatalf("Expected the first request allowed, got %d", rr.Code)
+	}
+	if rr := sendFrom(srv, "GET", "/_collections", "10.0.0.1:1234", nil); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected the second request limited, got %d", rr.Code)
+	}
+
+	config := *srv.config
+	config.BufferSize = 20
+	config.ReadOnly = true
+	config.RateLimit = &RateLimitConfig{}
+	if err := srv.Reconfigure(&config); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+
+	// The new limits apply to the known client at once
+	if rr := sendFrom(srv, "GET", "/_collections", "10.0.0.1:1234", nil); rr.Code != http.StatusOK {
+		t.Errorf("Expected the client unlimited, got %d", rr.Code)
+	}
+	if !srv.db.IsReadOnly() {
+		t.Error("Expected the database to be read-only")
+	}
+	if size := srv.RuntimeConfig().BufferSize; size != 20 {
+		t.Errorf("Expected buffer size 20, got %d", size)
+	}
+
+	// Settings that need a restart are rejected, applying nothing
+	config.Port = 9999
+	config.EnableCORS = !config.EnableCORS
+	config.ReadOnly = false
+	err := srv.Reconfigure(&config)
+	var restartErr *database.RestartRequiredError
+	if !errors.As(err, &restartErr) || !reflect.DeepEqual(restartErr.Fields, []string{"Port", "EnableCORS"}) {
+		t.Errorf("Expected Port and EnableCORS to need a restart, got %v", err)
+	}
+	if !srv.db.IsReadOnly() {
+		t.Error("Expected the database to stay read-only")
+	}
+
+	config = *srv.config
+	config.RateLimit = nil
+	if err := srv.Reconfigure(&config); !errors.Is(err, database.ErrRestartRequired) {
+		t.Errorf("Expected disabling rate limiting to need a restart, got %v", err)
+	}
+}
+
+func TestAdminConfigEndpoint(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+
+	// Without AdminAuth the endpoint doesn't exist
+	if rr := sendConfig(srv, "GET", "", ""); rr.Code == http.StatusOK {
+		t.Errorf("Expected no /_admin/config without AdminAuth, got %d", rr.Code)
+	}
+	config := *srv.config
+	cleanup()
+
+	authManager := auth.NewAuthManager()
+	authManager.CreateUser("reader", "secret", auth.RoleRead)
+	adminToken, _ := authManager.Authenticate("admin", "admin")
+	readerToken, _ := authManager.Authenticate("reader", "secret")
+
+	config.DataDir = t.TempDir()
+	config.AdminAuth = authManager
+	srv, err := New(&config)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer srv.db.Close()
+
+	if rr := sendConfig(srv, "GET", "", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", rr.Code)
+	}
+	if rr := sendConfig(srv, "GET", readerToken, ""); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a reader, got %d", rr.Code)
+	}
+	rr := sendConfig(srv, "GET", adminToken, "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"bufferSize":100`) {
+		t.Errorf("Expected the settings, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Settings left out keep their values
+	rr = sendConfig(srv, "PUT", adminToken, `{"slowQueryThreshold": "1ns", "durability": "wal"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected the update applied, got %d: %s", rr.Code, rr.Body.String())
+	}
+	settings := srv.RuntimeConfig()
+	if settings.SlowQueryThreshold != "1ns" || settings.Durability != "wal" || settings.BufferSize != 100 {
+		t.Errorf("Expected the threshold and durability changed, got %+v", settings)
+	}
+	if srv.db.Durability() != database.DurabilityWAL {
+		t.Errorf("Expected durability wal, got %s", srv.db.Durability())
+	}
+
+	// Requests slower than the threshold are recorded
+	makeRequest(t, srv, "GET", "/_collections", nil)
+	if entries := srv.GetSlowQueryLog().GetEntries(); len(entries) == 0 {
+		t.Error("Expected the request in the slow query log")
+	}
+
+	if rr := sendConfig(srv, "PUT", adminToken, `{"auditLevel": "info"}`); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for enabling auditing, got %d", rr.Code)
+	}
+	if rr := sendConfig(srv, "PUT", adminToken, `{"durability": "eventually"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown durability, got %d", rr.Code)
+	}
+	if rr := sendConfig(srv, "PUT", adminToken, `{"slowQueryThreshold": "soon"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid threshold, got %d", rr.Code)
+	}
+}
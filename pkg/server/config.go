@@ -1,13 +1,19 @@
 package server
 
-import "time"
+import (
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/auth"
+)
 
 // Config holds server configuration settings
 type Config struct {
 	Host           string        // Server host address
 	Port           int           // Server port
 	DataDir        string        // Database data directory - where all database files are stored
+	DatabaseName   string        // Name of the served database in oplog and change event namespaces ("" = "default")
 	BufferSize     int           // Buffer pool size in pages (1 page = 4KB). Default: 1000 pages (~4MB)
+	Durability     string        // Default durability of writes: none, wal or synced ("" = none)
 	DocumentCache  int           // Per-collection document cache size. Default: 1000 documents
 	ReadTimeout    time.Duration // HTTP read timeout
 	WriteTimeout   time.Duration // HTTP write timeout
@@ -27,6 +33,36 @@ type Config struct {
 
 	// GraphQL configuration
 	EnableGraphQL bool // Enable GraphQL API endpoint
+
+	// ShutdownTimeout bounds how long Shutdown waits for in-flight requests,
+	// change streams and cursors to finish before force-closing them
+	ShutdownTimeout time.Duration
+
+	// Readiness thresholds for /_health/ready
+	MinFreeDiskBytes  uint64        // Minimum free space in DataDir
+	MaxReplicationLag time.Duration // Maximum lag behind the primary (0 disables)
+
+	// Rate limiting configuration (nil disables rate limiting)
+	RateLimit *RateLimitConfig
+
+	// SlowQueryThreshold records requests that take longer than this in the
+	// slow query log (0 disables)
+	SlowQueryThreshold time.Duration
+
+	// AuditLevel audits database operations of at least this severity:
+	// info, warning or error ("" disables auditing)
+	AuditLevel string
+
+	// ReadOnly starts the database in read-only (maintenance) mode
+	ReadOnly bool
+
+	// AdminAuth guards the /_admin endpoints, which need a token with
+	// auth.PermissionManageServer. They are disabled when nil.
+	AdminAuth *auth.AuthManager
+
+	// Wire protocol configuration
+	WirePort int               // TCP port for the binary wire protocol (0 disables)
+	WireAuth *auth.AuthManager // Require an auth token handshake on wire connections (nil = no auth)
 }
 
 // DefaultConfig returns a configuration with sensible defaults
@@ -51,5 +87,8 @@ func DefaultConfig() *Config {
 		TLSCertFile:    "",
 		TLSKeyFile:     "",
 		EnableGraphQL:  false, // GraphQL disabled by default (opt-in feature)
+		ShutdownTimeout: 30 * time.Second,
+		MinFreeDiskBytes:  64 * 1024 * 1024, // 64MB
+		MaxReplicationLag: 10 * time.Second,
 	}
 }
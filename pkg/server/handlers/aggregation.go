@@ -38,7 +38,7 @@ func (h *Handlers) Aggregate(w http.ResponseWriter, r *http.Request) {
 
 	docs, err := coll.Aggregate(req.Pipeline)
 	if err != nil {
-		writeError(w, &InternalError{Message: err.Error()})
+		writeError(w, err)
 		return
 	}
 
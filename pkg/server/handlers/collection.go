@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/mnohosten/laura-db/pkg/database"
 )
 
 // CreateCollection creates a new collection
@@ -14,6 +15,11 @@ func (h *Handlers) CreateCollection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if h.db.IsReadOnly() {
+		writeError(w, database.ErrReadOnly)
+		return
+	}
+
 	// Create collection (Collection method creates it if it doesn't exist)
 	h.db.Collection(collectionName)
 
@@ -32,7 +38,7 @@ func (h *Handlers) DropCollection(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.db.DropCollection(collectionName); err != nil {
-		writeError(w, &InternalError{Message: err.Error()})
+		writeError(w, err)
 		return
 	}
 
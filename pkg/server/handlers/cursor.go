@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -19,6 +20,11 @@ type CreateCursorRequest struct {
 	Skip       int                    `json:"skip"`
 	BatchSize  int                    `json:"batchSize"`
 	Timeout    string                 `json:"timeout"` // e.g., "5m", "10m"
+	// Resumable cursors return a resume token with each batch
+	Resumable bool `json:"resumable"`
+	// ResumeAfter continues a resumable cursor after a token's position;
+	// skip is ignored and limit counts from there
+	ResumeAfter string `json:"resumeAfter"`
 }
 
 // CreateCursorResponse represents a cursor creation response
@@ -34,6 +40,8 @@ type FetchBatchResponse struct {
 	Position  int                      `json:"position"`
 	Remaining int                      `json:"remaining"`
 	HasMore   bool                     `json:"hasMore"`
+	// ResumeToken is the position after the batch, for resumable cursors
+	ResumeToken string `json:"resumeToken,omitempty"`
 }
 
 // CreateCursor creates a new server-side cursor
@@ -110,10 +118,16 @@ func (h *Handlers) CreateCursor(w http.ResponseWriter, r *http.Request) {
 		}
 		cursorOpts.Timeout = timeout
 	}
+	cursorOpts.Resumable = req.Resumable
+	cursorOpts.ResumeAfter = req.ResumeAfter
 
 	// Create cursor through cursor manager
 	cursor, err := h.db.CursorManager().CreateCursor(coll, q, cursorOpts)
 	if err != nil {
+		if errors.Is(err, database.ErrInvalidResumeToken) || errors.Is(err, database.ErrResumeTokenExpired) {
+			writeError(w, err)
+			return
+		}
 		writeError(w, &InternalError{Message: err.Error()})
 		return
 	}
@@ -139,7 +153,7 @@ func (h *Handlers) FetchBatch(w http.ResponseWriter, r *http.Request) {
 	// Get cursor from manager
 	cursor, err := h.db.CursorManager().GetCursor(cursorID)
 	if err != nil {
-		writeError(w, &BadRequestError{Message: "cursor not found: " + cursorID})
+		writeError(w, &CursorNotFoundError{CursorID: cursorID})
 		return
 	}
 
@@ -162,6 +176,14 @@ func (h *Handlers) FetchBatch(w http.ResponseWriter, r *http.Request) {
 		Remaining: cursor.Remaining(),
 		HasMore:   cursor.HasNext(),
 	}
+	if cursor.Resumable() {
+		token, err := cursor.ResumeToken()
+		if err != nil {
+			writeError(w, &InternalError{Message: err.Error()})
+			return
+		}
+		response.ResumeToken = token
+	}
 
 	writeSuccess(w, response)
 }
@@ -177,7 +199,7 @@ func (h *Handlers) CloseCursor(w http.ResponseWriter, r *http.Request) {
 	// Close cursor through manager
 	err := h.db.CursorManager().CloseCursor(cursorID)
 	if err != nil {
-		writeError(w, &BadRequestError{Message: "cursor not found: " + cursorID})
+		writeError(w, &CursorNotFoundError{CursorID: cursorID})
 		return
 	}
 
@@ -2,11 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
-	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/mnohosten/laura-db/pkg/database"
+	"github.com/mnohosten/laura-db/pkg/document"
 )
 
 // InsertDocument inserts a new document with auto-generated ID
@@ -25,13 +26,15 @@ func (h *Handlers) InsertDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := coll.InsertOne(doc)
+	opts, err := writeOptions(r)
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate") {
-			writeError(w, &DuplicateKeyError{})
-		} else {
-			writeError(w, &InternalError{Message: err.Error()})
-		}
+		writeError(w, err)
+		return
+	}
+
+	id, err := coll.InsertOneWithOptions(doc, opts)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
@@ -67,13 +70,15 @@ func (h *Handlers) InsertDocumentWithID(w http.ResponseWriter, r *http.Request)
 	// Set the _id field
 	doc["_id"] = id
 
-	insertedID, err := coll.InsertOne(doc)
+	opts, err := writeOptions(r)
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate") {
-			writeError(w, &DuplicateKeyError{})
-		} else {
-			writeError(w, &InternalError{Message: err.Error()})
-		}
+		writeError(w, err)
+		return
+	}
+
+	insertedID, err := coll.InsertOneWithOptions(doc, opts)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
@@ -104,9 +109,7 @@ func (h *Handlers) GetDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	filter := map[string]interface{}{
-		"_id": id,
-	}
+	filter := documentIDFilter(id)
 
 	doc, err := coll.FindOne(filter)
 	if err != nil {
@@ -148,15 +151,23 @@ func (h *Handlers) UpdateDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	filter := map[string]interface{}{
-		"_id": id,
+	opts, err := writeOptions(r)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
 
-	if err := coll.UpdateOne(filter, update); err != nil {
-		if strings.Contains(err.Error(), "not found") {
+	filter := documentIDFilter(id)
+
+	updateOpts := &database.UpdateOptions{}
+	if opts != nil {
+		updateOpts.WriteOptions = *opts
+	}
+	if _, err := coll.UpdateOneWithOptions(filter, update, updateOpts); err != nil {
+		if errors.Is(err, database.ErrDocumentNotFound) {
 			writeError(w, &DocumentNotFoundError{ID: id})
 		} else {
-			writeError(w, &InternalError{Message: err.Error()})
+			writeError(w, err)
 		}
 		return
 	}
@@ -188,15 +199,19 @@ func (h *Handlers) DeleteDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	filter := map[string]interface{}{
-		"_id": id,
+	opts, err := writeOptions(r)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
 
-	if err := coll.DeleteOne(filter); err != nil {
-		if strings.Contains(err.Error(), "not found") {
+	filter := documentIDFilter(id)
+
+	if err := coll.DeleteOneWithOptions(filter, opts); err != nil {
+		if errors.Is(err, database.ErrDocumentNotFound) {
 			writeError(w, &DocumentNotFoundError{ID: id})
 		} else {
-			writeError(w, &InternalError{Message: err.Error()})
+			writeError(w, err)
 		}
 		return
 	}
@@ -229,9 +244,20 @@ func (h *Handlers) BulkInsert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ids, err := coll.InsertMany(docs)
+	opts, err := writeOptions(r)
 	if err != nil {
-		writeError(w, &InternalError{Message: err.Error()})
+		writeError(w, err)
+		return
+	}
+
+	// The batch is inserted as a unit
+	insertOpts := &database.InsertManyOptions{Atomic: true}
+	if opts != nil {
+		insertOpts.WriteOptions = *opts
+	}
+	ids, err := coll.InsertManyWithOptions(docs, insertOpts)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
@@ -309,9 +335,35 @@ func (h *Handlers) BulkWrite(w http.ResponseWriter, r *http.Request) {
 			json.NewEncoder(w).Encode(response)
 			return
 		}
-		writeError(w, &InternalError{Message: err.Error()})
+		writeError(w, err)
 		return
 	}
 
 	writeSuccess(w, result)
 }
+
+// documentIDFilter matches a document by the ID from the URL. Generated IDs
+// are ObjectIDs, which clients see as hex strings, so a hex ID matches either
+// the ObjectID or a custom string ID with the same text.
+func documentIDFilter(id string) map[string]interface{} {
+	if oid, err := document.ObjectIDFromHex(id); err == nil {
+		return map[string]interface{}{
+			"_id": map[string]interface{}{"$in": []interface{}{oid, id}},
+		}
+	}
+	return map[string]interface{}{"_id": id}
+}
+
+// writeOptions reads the durability a write asks for from the durability
+// query parameter (empty uses the database default)
+func writeOptions(r *http.Request) (*database.WriteOptions, error) {
+	value := r.URL.Query().Get("durability")
+	if value == "" {
+		return nil, nil
+	}
+	durability, err := database.ParseDurability(value)
+	if err != nil {
+		return nil, &BadRequestError{Message: err.Error()}
+	}
+	return &database.WriteOptions{Durability: durability}, nil
+}
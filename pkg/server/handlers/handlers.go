@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 
@@ -72,6 +73,14 @@ func (e *CollectionNotFoundError) Error() string {
 	return "collection not found: " + e.Collection
 }
 
+type CursorNotFoundError struct {
+	CursorID string
+}
+
+func (e *CursorNotFoundError) Error() string {
+	return "cursor not found: " + e.CursorID
+}
+
 type DuplicateKeyError struct {
 	Field string
 	Value interface{}
@@ -89,11 +98,31 @@ func (e *InternalError) Error() string {
 	return e.Message
 }
 
+// errorKinds maps the kinds of database errors to status codes and error
+// types, most specific first
+var errorKinds = []struct {
+	kind       error
+	statusCode int
+	errorType  string
+}{
+	{database.ErrReadOnly, http.StatusServiceUnavailable, "ReadOnly"},
+	{database.ErrResumeTokenExpired, http.StatusGone, "ResumeTokenExpired"},
+	{database.ErrInvalidResumeToken, http.StatusBadRequest, "InvalidResumeToken"},
+	{database.ErrDuplicateKey, http.StatusConflict, "DuplicateKey"},
+	{database.ErrDocumentNotFound, http.StatusNotFound, "DocumentNotFound"},
+	{database.ErrCollectionNotFound, http.StatusNotFound, "CollectionNotFound"},
+	{database.ErrNotFound, http.StatusNotFound, "NotFound"},
+	{database.ErrValidation, http.StatusBadRequest, "ValidationError"},
+	{database.ErrConflict, http.StatusConflict, "Conflict"},
+	{database.ErrTimeout, http.StatusRequestTimeout, "Timeout"},
+}
+
 // writeError writes an error response with appropriate HTTP status code
 func writeError(w http.ResponseWriter, err error) {
 	var statusCode int
 	var errorType string
 	var message string
+	var details map[string]interface{}
 
 	switch e := err.(type) {
 	case *BadRequestError:
@@ -108,10 +137,17 @@ func writeError(w http.ResponseWriter, err error) {
 		statusCode = http.StatusNotFound
 		errorType = "CollectionNotFound"
 		message = e.Error()
+	case *CursorNotFoundError:
+		statusCode = http.StatusNotFound
+		errorType = "CursorNotFound"
+		message = e.Error()
 	case *DuplicateKeyError:
 		statusCode = http.StatusConflict
 		errorType = "DuplicateKey"
 		message = e.Error()
+		if e.Field != "" {
+			details = map[string]interface{}{"fields": []string{e.Field}, "values": []interface{}{e.Value}}
+		}
 	case *InternalError:
 		statusCode = http.StatusInternalServerError
 		errorType = "InternalError"
@@ -120,6 +156,14 @@ func writeError(w http.ResponseWriter, err error) {
 		statusCode = http.StatusInternalServerError
 		errorType = "InternalError"
 		message = err.Error()
+		for _, k := range errorKinds {
+			if errors.Is(err, k.kind) {
+				statusCode = k.statusCode
+				errorType = k.errorType
+				break
+			}
+		}
+		details = errorDetails(err)
 	}
 
 	response := map[string]interface{}{
@@ -128,12 +172,34 @@ func writeError(w http.ResponseWriter, err error) {
 		"message": message,
 		"code":    statusCode,
 	}
+	if details != nil {
+		response["details"] = details
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(response)
 }
 
+// errorDetails returns the fields of a typed database error that clients
+// rebuild it from, or nil
+func errorDetails(err error) map[string]interface{} {
+	var dup *database.DuplicateKeyError
+	if errors.As(err, &dup) {
+		return map[string]interface{}{
+			"collection": dup.Collection,
+			"index":      dup.Index,
+			"fields":     dup.Fields,
+			"values":     dup.Values,
+		}
+	}
+	var invalid *database.ValidationError
+	if errors.As(err, &invalid) {
+		return map[string]interface{}{"field": invalid.Field}
+	}
+	return nil
+}
+
 // writeSuccess writes a success response
 func writeSuccess(w http.ResponseWriter, result interface{}) {
 	response := map[string]interface{}{
@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/mnohosten/laura-db/pkg/database"
+	"github.com/mnohosten/laura-db/pkg/mvcc"
 )
 
 // setupTestHandlers creates a test database and handlers for testing
@@ -283,6 +286,39 @@ func TestBulkInsert(t *testing.T) {
 	}
 }
 
+// TestBulkInsertConflict tests that a conflicting document rejects the whole batch
+func TestBulkInsertConflict(t *testing.T) {
+	handlers, cleanup := setupTestHandlers(t)
+	defer cleanup()
+
+	coll, _ := handlers.db.CreateCollection("users")
+	coll.InsertOne(map[string]interface{}{"_id": "u2", "name": "Existing"})
+
+	docs := []map[string]interface{}{
+		{"_id": "u1", "name": "User1"},
+		{"_id": "u2", "name": "User2"},
+		{"_id": "u3", "name": "User3"},
+	}
+	body, _ := json.Marshal(docs)
+
+	req := httptest.NewRequest("POST", "/users/_bulk", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("collection", "users")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	w := httptest.NewRecorder()
+	handlers.BulkInsert(w, req)
+
+	if w.Code == http.StatusOK {
+		t.Errorf("Expected the conflicting batch to fail, got status %d", w.Code)
+	}
+	if count, _ := coll.Count(map[string]interface{}{}); count != 1 {
+		t.Errorf("Expected no documents from the batch to be inserted, got %d documents", count)
+	}
+}
+
 // TestBulkInsertEmptyArray tests bulk insert with empty array
 func TestBulkInsertEmptyArray(t *testing.T) {
 	handlers, cleanup := setupTestHandlers(t)
@@ -352,3 +388,145 @@ func TestInsertDocumentMissingCollection(t *testing.T) {
 		t.Errorf("Expected status 400, got %d", w.Code)
 	}
 }
+
+// TestInsertDocumentReadOnly tests that writes to a read-only database return 503
+func TestInsertDocumentReadOnly(t *testing.T) {
+	handlers, cleanup := setupTestHandlers(t)
+	defer cleanup()
+
+	handlers.db.CreateCollection("users")
+	handlers.db.SetReadOnly(true)
+
+	body, _ := json.Marshal(map[string]interface{}{"name": "Alice"})
+	req := httptest.NewRequest("POST", "/users/_doc", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("collection", "users")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	w := httptest.NewRecorder()
+	handlers.InsertDocument(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response["error"] != "ReadOnly" {
+		t.Errorf("Expected error ReadOnly, got %v", response["error"])
+	}
+
+	// Writes are accepted again once read-only mode is turned off
+	handlers.db.SetReadOnly(false)
+	body, _ = json.Marshal(map[string]interface{}{"name": "Alice"})
+	req = httptest.NewRequest("POST", "/users/_doc", bytes.NewBuffer(body))
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	w = httptest.NewRecorder()
+	handlers.InsertDocument(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+// TestInsertDocumentDurability tests the durability query parameter
+func TestInsertDocumentDurability(t *testing.T) {
+	handlers, cleanup := setupTestHandlers(t)
+	defer cleanup()
+
+	handlers.db.CreateCollection("users")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("collection", "users")
+
+	insert := func(durability string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"name": "Alice"})
+		req := httptest.NewRequest("POST", "/users/_doc?durability="+durability, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handlers.InsertDocument(w, req)
+		return w
+	}
+
+	syncs := handlers.db.WAL().CommitStats()["syncs"].(int64)
+	if w := insert("synced"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if got := handlers.db.WAL().CommitStats()["syncs"].(int64); got != syncs+1 {
+		t.Errorf("Expected the insert to sync the WAL, got %d syncs", got-syncs)
+	}
+
+	if w := insert("eventually"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+// TestWriteErrorKinds tests that typed database errors map to status codes
+func TestWriteErrorKinds(t *testing.T) {
+	handlers, cleanup := setupTestHandlers(t)
+	defer cleanup()
+
+	handlers.db.CreateCollection("users")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("collection", "users")
+	rctx.URLParams.Add("id", "u1")
+
+	insert := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"name": "Alice"})
+		req := httptest.NewRequest("POST", "/users/_doc/u1", bytes.NewBuffer(body))
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handlers.InsertDocumentWithID(w, req)
+		return w
+	}
+	if w := insert(); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	w := insert()
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d", w.Code)
+	}
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response["error"] != "DuplicateKey" {
+		t.Errorf("Expected error DuplicateKey, got %v", response["error"])
+	}
+	details, _ := response["details"].(map[string]interface{})
+	if details["index"] != "_id_" || details["values"].([]interface{})[0] != "u1" {
+		t.Errorf("Expected the duplicate _id in the details, got %v", response["details"])
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"$set": map[string]interface{}{"name.first": "A"}})
+	req := httptest.NewRequest("PUT", "/users/_doc/u1", bytes.NewBuffer(body))
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	w = httptest.NewRecorder()
+	handlers.UpdateDocument(w, req)
+	response = nil
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusBadRequest || response["error"] != "ValidationError" {
+		t.Errorf("Expected a 400 ValidationError, got %d %v", w.Code, response["error"])
+	}
+
+	for _, tc := range []struct {
+		err       error
+		code      int
+		errorType string
+	}{
+		{database.ErrCollectionNotFound, http.StatusNotFound, "CollectionNotFound"},
+		{fmt.Errorf("index x: %w", database.ErrNotFound), http.StatusNotFound, "NotFound"},
+		{mvcc.ErrConflict, http.StatusConflict, "Conflict"},
+		{fmt.Errorf("commit: %w", database.ErrTimeout), http.StatusRequestTimeout, "Timeout"},
+		{errors.New("boom"), http.StatusInternalServerError, "InternalError"},
+	} {
+		w := httptest.NewRecorder()
+		writeError(w, tc.err)
+		response = nil
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if w.Code != tc.code || response["error"] != tc.errorType {
+			t.Errorf("%v: expected %d %s, got %d %v", tc.err, tc.code, tc.errorType, w.Code, response["error"])
+		}
+	}
+}
@@ -38,7 +38,7 @@ func (h *Handlers) CreateIndex(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := coll.CreateIndex(req.Field, req.Unique); err != nil {
-		writeError(w, &InternalError{Message: err.Error()})
+		writeError(w, err)
 		return
 	}
 
@@ -94,7 +94,7 @@ func (h *Handlers) DropIndex(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := coll.DropIndex(indexName); err != nil {
-		writeError(w, &InternalError{Message: err.Error()})
+		writeError(w, err)
 		return
 	}
 
@@ -151,3 +151,31 @@ func (h *Handlers) CountDocumentsWithFilter(w http.ResponseWriter, r *http.Reque
 	}
 	writeSuccess(w, result)
 }
+
+// ExplainQuery returns the query plan for a filter without executing it
+func (h *Handlers) ExplainQuery(w http.ResponseWriter, r *http.Request) {
+	collectionName := chi.URLParam(r, "collection")
+	if collectionName == "" {
+		writeError(w, &BadRequestError{Message: "collection name is required"})
+		return
+	}
+
+	coll, err := h.getCollection(collectionName)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req CountRequest
+	if err := parseJSONBody(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	filter := req.Filter
+	if filter == nil {
+		filter = map[string]interface{}{}
+	}
+
+	writeSuccess(w, coll.Explain(filter))
+}
@@ -78,6 +78,13 @@ func (m *ChangeStreamManager) Close() error {
 	return nil
 }
 
+// ActiveConnections returns the number of open change stream connections
+func (m *ChangeStreamManager) ActiveConnections() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.connections)
+}
+
 // addConnection registers a new connection
 func (m *ChangeStreamManager) addConnection(conn *ChangeStreamConnection) {
 	m.mu.Lock()
@@ -162,9 +169,9 @@ func (h *Handlers) HandleChangeStream(manager *ChangeStreamManager) http.Handler
 			return
 		}
 
-		// Validate database name
+		// Default to the served database
 		if req.Database == "" {
-			req.Database = "default"
+			req.Database = h.db.Name()
 		}
 
 		// Create change stream options
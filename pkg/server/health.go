@@ -0,0 +1,140 @@
+package server
+
+import (
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/replication"
+)
+
+// ReplicationStatus reports replica-set membership state for readiness
+// checks. *replication.ReplicaSet satisfies it.
+type ReplicationStatus interface {
+	IsPrimary() bool
+	HasMajority() bool
+	ReplicationLag() time.Duration
+}
+
+// SetReplicationStatus makes readiness depend on replica-set health: the
+// node is not ready when it cannot reach a majority or lags too far behind
+func (s *Server) SetReplicationStatus(status ReplicationStatus) {
+	s.replStatus = status
+}
+
+// ReplicaSetStatusReporter reports full replica-set status for
+// /_replset/status. *replication.ReplicaSet satisfies it.
+type ReplicaSetStatusReporter interface {
+	Status() replication.ReplicaSetStatus
+}
+
+// handleReplSetStatus reports each member's role, health, priority, last
+// applied operation and lag, with the current primary and term. It needs a
+// replication status registered with SetReplicationStatus that also
+// implements ReplicaSetStatusReporter.
+func (s *Server) handleReplSetStatus(w http.ResponseWriter, r *http.Request) {
+	reporter, ok := s.replStatus.(ReplicaSetStatusReporter)
+	if !ok {
+		WriteError(w, http.StatusNotFound, "NotReplicaSet", "server is not running as a replica set member")
+		return
+	}
+	WriteSuccess(w, reporter.Status())
+}
+
+// handleLiveness reports that the process is up and serving requests
+func (s *Server) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	WriteSuccess(w, map[string]interface{}{
+		"status": "alive",
+		"uptime": time.Since(s.startTime).String(),
+	})
+}
+
+// handleReadiness runs the readiness checks and returns 503 if any fails,
+// so load balancers route traffic away from this node
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	checks, ready := s.readinessChecks()
+
+	status := "ready"
+	code := http.StatusOK
+	if !ready {
+		status = "not_ready"
+		code = http.StatusServiceUnavailable
+	}
+
+	WriteJSON(w, code, map[string]interface{}{
+		"ok": ready,
+		"result": map[string]interface{}{
+			"status": status,
+			"checks": checks,
+		},
+	})
+}
+
+// readinessChecks returns per-check detail and whether all checks passed
+func (s *Server) readinessChecks() (map[string]interface{}, bool) {
+	checks := make(map[string]interface{})
+	ready := true
+
+	add := func(name string, pass bool, detail map[string]interface{}) {
+		detail["status"] = "pass"
+		if !pass {
+			detail["status"] = "fail"
+			ready = false
+		}
+		checks[name] = detail
+	}
+
+	add("lifecycle", !s.draining.Load(), map[string]interface{}{
+		"shutting_down": s.draining.Load(),
+	})
+
+	dbOpen := s.db.IsOpen()
+	add("database", dbOpen, map[string]interface{}{"open": dbOpen})
+
+	if storageStats, err := s.db.StorageStats(); err != nil {
+		add("wal", false, map[string]interface{}{"error": err.Error()})
+	} else {
+		walStats, _ := storageStats["wal"].(map[string]interface{})
+		detail := map[string]interface{}{
+			"size_bytes":  walStats["size_bytes"],
+			"current_lsn": walStats["current_lsn"],
+		}
+		checkpointErr, failed := walStats["last_checkpoint_error"]
+		if failed {
+			detail["error"] = checkpointErr
+		}
+		add("wal", !failed, detail)
+
+		bufferStats, _ := storageStats["buffer_pool"].(map[string]interface{})
+		add("buffer_pool", true, map[string]interface{}{
+			"capacity": bufferStats["capacity"],
+			"size":     bufferStats["size"],
+			"hit_rate": bufferStats["hit_rate"],
+		})
+	}
+
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(s.config.DataDir, &fs); err != nil {
+		add("disk", false, map[string]interface{}{"error": err.Error()})
+	} else {
+		free := fs.Bavail * uint64(fs.Bsize)
+		add("disk", free >= s.config.MinFreeDiskBytes, map[string]interface{}{
+			"free_bytes":     free,
+			"min_free_bytes": s.config.MinFreeDiskBytes,
+		})
+	}
+
+	if s.replStatus != nil {
+		lag := s.replStatus.ReplicationLag()
+		hasMajority := s.replStatus.HasMajority()
+		withinLag := s.config.MaxReplicationLag <= 0 || lag <= s.config.MaxReplicationLag
+		add("replication", hasMajority && withinLag, map[string]interface{}{
+			"primary":      s.replStatus.IsPrimary(),
+			"has_majority": hasMajority,
+			"lag":          lag.String(),
+			"max_lag":      s.config.MaxReplicationLag.String(),
+		})
+	}
+
+	return checks, ready
+}
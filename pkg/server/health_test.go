@@ -0,0 +1,174 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/replication"
+)
+
+var (
+	_ ReplicationStatus        = (*replication.ReplicaSet)(nil)
+	_ ReplicaSetStatusReporter = (*replication.ReplicaSet)(nil)
+)
+
+// fakeReplicationStatus is a ReplicationStatus with fixed values
+type fakeReplicationStatus struct {
+	primary     bool
+	hasMajority bool
+	lag         time.Duration
+}
+
+func (f *fakeReplicationStatus) IsPrimary() bool               { return f.primary }
+func (f *fakeReplicationStatus) HasMajority() bool             { return f.hasMajority }
+func (f *fakeReplicationStatus) ReplicationLag() time.Duration { return f.lag }
+
+func TestLivenessEndpoint(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	rr, resp := makeRequest(t, srv, "GET", "/_health/live", nil)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+	result := resp["result"].(map[string]interface{})
+	if result["status"] != "alive" {
+		t.Errorf("Expected status=alive, got %v", result["status"])
+	}
+}
+
+func TestReadinessEndpoint(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	rr, resp := makeRequest(t, srv, "GET", "/_health/ready", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", rr.Code, resp)
+	}
+	checks := resp["result"].(map[string]interface{})["checks"].(map[string]interface{})
+	for _, name := range []string{"lifecycle", "database", "wal", "buffer_pool", "disk"} {
+		check, ok := checks[name].(map[string]interface{})
+		if !ok {
+			t.Errorf("Expected %s check", name)
+			continue
+		}
+		if check["status"] != "pass" {
+			t.Errorf("Expected %s check to pass, got %v", name, check)
+		}
+	}
+	if _, exists := checks["replication"]; exists {
+		t.Error("Expected no replication check without a replica set")
+	}
+}
+
+func TestReadinessReplicationLag(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+	srv.config.MaxReplicationLag = time.Second
+
+	status := &fakeReplicationStatus{hasMajority: true, lag: 100 * time.Millisecond}
+	srv.SetReplicationStatus(status)
+
+	rr, _ := makeRequest(t, srv, "GET", "/_health/ready", nil)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 within lag threshold, got %d", rr.Code)
+	}
+
+	// A secondary that falls too far behind is not ready
+	status.lag = 5 * time.Second
+	rr, resp := makeRequest(t, srv, "GET", "/_health/ready", nil)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 when lagging, got %d", rr.Code)
+	}
+	result := resp["result"].(map[string]interface{})
+	if result["status"] != "not_ready" {
+		t.Errorf("Expected status=not_ready, got %v", result["status"])
+	}
+	replCheck := result["checks"].(map[string]interface{})["replication"].(map[string]interface{})
+	if replCheck["status"] != "fail" {
+		t.Errorf("Expected replication check to fail, got %v", replCheck)
+	}
+
+	// Losing the majority also makes the node not ready
+	status.lag = 0
+	status.hasMajority = false
+	rr, _ = makeRequest(t, srv, "GET", "/_health/ready", nil)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without majority, got %d", rr.Code)
+	}
+}
+
+// fakeReplicaSet is a ReplicationStatus that also reports full status
+type fakeReplicaSet struct {
+	fakeReplicationStatus
+	status replication.ReplicaSetStatus
+}
+
+func (f *fakeReplicaSet) Status() replication.ReplicaSetStatus { return f.status }
+
+func TestReplSetStatus(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	// Without a replica set there is nothing to report
+	rr, _ := makeRequest(t, srv, "GET", "/_replset/status", nil)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 without a replica set, got %d", rr.Code)
+	}
+
+	// A readiness-only status doesn't report members either
+	srv.SetReplicationStatus(&fakeReplicationStatus{hasMajority: true})
+	rr, _ = makeRequest(t, srv, "GET", "/_replset/status", nil)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 without a status reporter, got %d", rr.Code)
+	}
+
+	srv.SetReplicationStatus(&fakeReplicaSet{
+		fakeReplicationStatus: fakeReplicationStatus{primary: true, hasMajority: true},
+		status: replication.ReplicaSetStatus{
+			Set:     "rs0",
+			NodeID:  "node1",
+			Role:    "PRIMARY",
+			Primary: "node1",
+			Term:    3,
+			Members: []replication.MemberStatus{
+				{ID: "node1", Role: "PRIMARY", Health: "HEALTHY", Priority: 10, Voting: true, LastOpID: 42, Self: true},
+				{ID: "node2", Role: "SECONDARY", Health: "HEALTHY", Priority: 1, Voting: true, LastOpID: 40, LagMillis: 2},
+			},
+		},
+	})
+
+	rr, resp := makeRequest(t, srv, "GET", "/_replset/status", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	result := resp["result"].(map[string]interface{})
+	if result["set"] != "rs0" || result["primary"] != "node1" || result["term"] != float64(3) {
+		t.Errorf("Unexpected replica set status: %v", result)
+	}
+	members := result["members"].([]interface{})
+	if len(members) != 2 {
+		t.Fatalf("Expected 2 members, got %d", len(members))
+	}
+	node2 := members[1].(map[string]interface{})
+	if node2["id"] != "node2" || node2["role"] != "SECONDARY" || node2["health"] != "HEALTHY" ||
+		node2["last_op_id"] != float64(40) || node2["lag_ms"] != float64(2) || node2["priority"] != float64(1) {
+		t.Errorf("Unexpected member status: %v", node2)
+	}
+}
+
+func TestReadinessAfterDatabaseClose(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	srv.db.Close()
+	rr, resp := makeRequest(t, srv, "GET", "/_health/ready", nil)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 with closed database, got %d", rr.Code)
+	}
+	checks := resp["result"].(map[string]interface{})["checks"].(map[string]interface{})
+	if checks["database"].(map[string]interface{})["status"] != "fail" {
+		t.Errorf("Expected database check to fail, got %v", checks["database"])
+	}
+}
@@ -0,0 +1,287 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/auth"
+	"github.com/mnohosten/laura-db/pkg/metrics"
+)
+
+// Limit is a token bucket: Rate requests per second on average, with bursts
+// of up to Burst requests. A Rate of 0 means unlimited.
+type Limit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// ClientLimits holds the read and write limits applied to one client
+type ClientLimits struct {
+	Read  Limit `json:"read"`
+	Write Limit `json:"write"`
+}
+
+// RateLimitConfig configures per-client rate limiting. Clients are identified
+// by authenticated user, then X-API-Key header, then IP address.
+type RateLimitConfig struct {
+	Default               ClientLimits            `json:"default"`               // Limits for clients without an override
+	Users                 map[string]ClientLimits `json:"users,omitempty"`       // Per-user overrides for authenticated users
+	MaxConcurrentRequests int                     `json:"maxConcurrentRequests"` // Server-wide cap on concurrent requests (0 = unlimited)
+	ClientIdleTimeout     time.Duration           `json:"clientIdleTimeout"`     // Forget clients idle for this long
+	AuthManager           *auth.AuthManager       `json:"-"`                     // Resolves bearer tokens to users (optional)
+}
+
+// DefaultRateLimitConfig returns a rate limit configuration with sensible defaults
+func DefaultRateLimitConfig() *RateLimitConfig {
+	return &RateLimitConfig{
+		Default: ClientLimits{
+			Read:  Limit{Rate: 100, Burst: 200},
+			Write: Limit{Rate: 50, Burst: 100},
+		},
+		Users:                 make(map[string]ClientLimits),
+		MaxConcurrentRequests: 256,
+		ClientIdleTimeout:     10 * time.Minute,
+	}
+}
+
+// tokenBucket tracks available tokens for one client and operation kind
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket and consumes a token. If none is available it
+// returns how long until one will be.
+func (b *tokenBucket) take(limit Limit, now time.Time) (bool, time.Duration) {
+	if limit.Rate <= 0 {
+		return true, 0
+	}
+
+	burst := float64(max(limit.Burst, 1))
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	return false, wait
+}
+
+// clientBuckets holds the token buckets of one client
+type clientBuckets struct {
+	user     string
+	limits   ClientLimits
+	read     tokenBucket
+	write    tokenBucket
+	lastSeen time.Time
+}
+
+// rateLimiter enforces per-client token buckets and a concurrency cap
+type rateLimiter struct {
+	config    *RateLimitConfig // Replaced, never modified, by setConfig
+	metrics   *metrics.MetricsCollector
+	mu        sync.Mutex
+	clients   map[string]*clientBuckets
+	lastSweep time.Time
+	slots     chan struct{} // nil when concurrency is unlimited
+	now       func() time.Time
+}
+
+// newRateLimiter creates a rate limiter for the given configuration
+func newRateLimiter(config *RateLimitConfig, mc *metrics.MetricsCollector) *rateLimiter {
+	rl := &rateLimiter{
+		config:  config,
+		metrics: mc,
+		clients: make(map[string]*clientBuckets),
+		now:     time.Now,
+	}
+	if config.MaxConcurrentRequests > 0 {
+		rl.slots = make(chan struct{}, config.MaxConcurrentRequests)
+	}
+	return rl
+}
+
+// allow takes a token from the client's read or write bucket
+func (rl *rateLimiter) allow(key, user string, write bool) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rl.sweep(now)
+
+	client, exists := rl.clients[key]
+	if !exists {
+		limits := rl.limitsFor(user)
+		client = &clientBuckets{
+			user:   user,
+			limits: limits,
+			read:   tokenBucket{tokens: float64(limits.Read.Burst), last: now},
+			write:  tokenBucket{tokens: float64(limits.Write.Burst), last: now},
+		}
+		rl.clients[key] = client
+		if rl.metrics != nil {
+			rl.metrics.SetRateLimitClients(len(rl.clients))
+		}
+	}
+	client.lastSeen = now
+
+	if write {
+		return client.write.take(client.limits.Write, now)
+	}
+	return client.read.take(client.limits.Read, now)
+}
+
+// limitsFor returns the limits of a client authenticated as user ("" for
+// none). Must be called with rl.mu held.
+func (rl *rateLimiter) limitsFor(user string) ClientLimits {
+	if userLimits, ok := rl.config.Users[user]; ok && user != "" {
+		return userLimits
+	}
+	return rl.config.Default
+}
+
+// setConfig replaces the configuration of the rate limiter. Known clients
+// get their new limits at once, keeping the tokens they have left, and the
+// concurrency cap applies to requests that start afterwards.
+func (rl *rateLimiter) setConfig(config *RateLimitConfig) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.config = config
+	for _, client := range rl.clients {
+		client.limits = rl.limitsFor(client.user)
+	}
+	if config.MaxConcurrentRequests != cap(rl.slots) {
+		rl.slots = nil
+		if config.MaxConcurrentRequests > 0 {
+			rl.slots = make(chan struct{}, config.MaxConcurrentRequests)
+		}
+	}
+}
+
+// current returns the configuration and concurrency slots in use
+func (rl *rateLimiter) current() (*RateLimitConfig, chan struct{}) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.config, rl.slots
+}
+
+// sweep forgets clients that have been idle longer than ClientIdleTimeout.
+// Must be called with rl.mu held.
+func (rl *rateLimiter) sweep(now time.Time) {
+	idle := rl.config.ClientIdleTimeout
+	if idle <= 0 || now.Sub(rl.lastSweep) < idle {
+		return
+	}
+	rl.lastSweep = now
+
+	for key, client := range rl.clients {
+		if now.Sub(client.lastSeen) > idle {
+			delete(rl.clients, key)
+		}
+	}
+	if rl.metrics != nil {
+		rl.metrics.SetRateLimitClients(len(rl.clients))
+	}
+}
+
+// clientKey identifies the client of a request, returning the bucket key and
+// the authenticated username, if any
+func (rl *rateLimiter) clientKey(r *http.Request) (string, string) {
+	if session, ok := auth.GetSession(r); ok {
+		return "user:" + session.Username, session.Username
+	}
+	if config, _ := rl.current(); config.AuthManager != nil {
+		if token, err := auth.ParseAuthHeader(r.Header.Get("Authorization")); err == nil {
+			if session, err := config.AuthManager.ValidateSession(token); err == nil {
+				return "user:" + session.Username, session.Username
+			}
+		}
+	}
+	if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+		return "key:" + apiKey, ""
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host, ""
+}
+
+// isWriteRequest reports whether a request modifies data. Queries sent
+// with POST (search, count, explain, aggregate, cursors) count as reads.
+func isWriteRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	for _, suffix := range []string{"/_search", "/_count", "/_explain", "/_aggregate"} {
+		if strings.HasSuffix(path, suffix) {
+			return false
+		}
+	}
+	return !strings.HasPrefix(path, "/_cursors")
+}
+
+// isExemptFromRateLimit reports whether a request bypasses rate limiting.
+// Health probes and metrics scrapes must keep working under load.
+func isExemptFromRateLimit(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/_health") || r.URL.Path == "/_metrics"
+}
+
+// rateLimitMiddleware rejects requests over the client's rate limit with
+// 429 and requests over the concurrency limit with 503, both with Retry-After
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isExemptFromRateLimit(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, user := s.rateLimiter.clientKey(r)
+		write := isWriteRequest(r)
+		if ok, wait := s.rateLimiter.allow(key, user, write); !ok {
+			s.metricsCollector.RecordRateLimited(write)
+			kind := "read"
+			if write {
+				kind = "write"
+			}
+			w.Header().Set("Retry-After", retryAfterSeconds(wait))
+			WriteError(w, http.StatusTooManyRequests, "RateLimitExceeded",
+				fmt.Sprintf("%s rate limit exceeded, retry in %s", kind, wait.Round(time.Millisecond)))
+			return
+		}
+
+		// WebSocket change streams are long-lived and don't hold a slot
+		_, slots := s.rateLimiter.current()
+		if slots != nil && !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			default:
+				s.metricsCollector.RecordConcurrencyRejected()
+				w.Header().Set("Retry-After", "1")
+				WriteError(w, http.StatusServiceUnavailable, "TooManyConcurrentRequests",
+					"server is at its concurrent request limit")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// retryAfterSeconds formats a wait as a Retry-After value in whole seconds
+func retryAfterSeconds(wait time.Duration) string {
+	return fmt.Sprintf("%d", max(int(math.Ceil(wait.Seconds())), 1))
+}
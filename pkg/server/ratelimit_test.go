@@ -0,0 +1,223 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/auth"
+)
+
+// Helper to create a test server with rate limiting enabled
+func setupRateLimitedServer(t *testing.T, rateLimit *RateLimitConfig) (*Server, func()) {
+	tmpDir, err := os.MkdirTemp("", "laura-ratelimit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.DataDir = tmpDir
+	config.BufferSize = 100
+	config.EnableLogging = false
+	config.RateLimit = rateLimit
+
+	srv, err := New(config)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	return srv, func() {
+		srv.db.Close()
+		os.RemoveAll(tmpDir)
+	}
+}
+
+// Helper to send a request from a given client address
+func sendFrom(srv *Server, method, path, remoteAddr string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rr := httptest.NewRecorder()
+	srv.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Now()
+	limit := Limit{Rate: 2, Burst: 2}
+	bucket := tokenBucket{tokens: 2, last: start}
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := bucket.take(limit, start); !ok {
+			t.Fatalf("Expected request %d within burst to be allowed", i)
+		}
+	}
+
+	ok, wait := bucket.take(limit, start)
+	if ok {
+		t.Fatal("Expected request over burst to be rejected")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("Expected wait 500ms, got %v", wait)
+	}
+
+	// Half a second refills one token at 2 req/s
+	if ok, _ := bucket.take(limit, start.Add(500*time.Millisecond)); !ok {
+		t.Error("Expected request after refill to be allowed")
+	}
+
+	// Zero rate is unlimited
+	unlimited := tokenBucket{}
+	if ok, _ := unlimited.take(Limit{}, start); !ok {
+		t.Error("Expected unlimited bucket to allow requests")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	config := DefaultRateLimitConfig()
+	config.Default = ClientLimits{
+		Read:  Limit{Rate: 0.001, Burst: 2},
+		Write: Limit{Rate: 0.001, Burst: 1},
+	}
+	srv, cleanup := setupRateLimitedServer(t, config)
+	defer cleanup()
+
+	const client = "10.0.0.1:5000"
+	for i := 0; i < 2; i++ {
+		if rr := sendFrom(srv, "GET", "/_collections", client, nil); rr.Code != http.StatusOK {
+			t.Fatalf("Expected read %d to succeed, got %d", i, rr.Code)
+		}
+	}
+
+	rr := sendFrom(srv, "GET", "/_collections", client, nil)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+
+	// Writes use a separate bucket
+	if rr := sendFrom(srv, "PUT", "/users", client, nil); rr.Code == http.StatusTooManyRequests {
+		t.Error("Expected write to be allowed after reads are exhausted")
+	}
+	if rr := sendFrom(srv, "PUT", "/orders", client, nil); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected second write to be limited, got %d", rr.Code)
+	}
+
+	// Other clients and health probes are unaffected
+	if rr := sendFrom(srv, "GET", "/_collections", "10.0.0.2:5000", nil); rr.Code != http.StatusOK {
+		t.Errorf("Expected other client to succeed, got %d", rr.Code)
+	}
+	if rr := sendFrom(srv, "GET", "/_health/live", client, nil); rr.Code != http.StatusOK {
+		t.Errorf("Expected health probe to bypass rate limiting, got %d", rr.Code)
+	}
+
+	metrics := srv.metricsCollector.GetMetrics()["rate_limit"].(map[string]interface{})
+	if metrics["limited_reads"] != uint64(1) || metrics["limited_writes"] != uint64(1) {
+		t.Errorf("Expected 1 limited read and 1 limited write, got %v", metrics)
+	}
+	if metrics["clients"] != uint64(2) {
+		t.Errorf("Expected 2 tracked clients, got %v", metrics["clients"])
+	}
+}
+
+func TestRateLimitPerUser(t *testing.T) {
+	authManager := auth.NewAuthManager()
+	authManager.CreateUser("batch", "secret", auth.RoleReadWrite)
+	token, err := authManager.Authenticate("batch", "secret")
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+
+	config := DefaultRateLimitConfig()
+	config.Default = ClientLimits{Read: Limit{Rate: 0.001, Burst: 1}}
+	config.Users["batch"] = ClientLimits{Read: Limit{Rate: 0.001, Burst: 3}}
+	config.AuthManager = authManager
+	srv, cleanup := setupRateLimitedServer(t, config)
+	defer cleanup()
+
+	// Requests are keyed by user, not address
+	header := http.Header{"Authorization": []string{"Bearer " + token}}
+	for i := 0; i < 3; i++ {
+		addr := fmt.Sprintf("10.0.1.%d:5000", i+1)
+		if rr := sendFrom(srv, "GET", "/_collections", addr, header); rr.Code != http.StatusOK {
+			t.Fatalf("Expected request %d for user to succeed, got %d", i, rr.Code)
+		}
+	}
+	if rr := sendFrom(srv, "GET", "/_collections", "10.0.1.9:5000", header); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected user limit to apply, got %d", rr.Code)
+	}
+
+	// API keys get the default limits
+	keyHeader := http.Header{"X-Api-Key": []string{"k1"}}
+	sendFrom(srv, "GET", "/_collections", "10.0.2.1:5000", keyHeader)
+	if rr := sendFrom(srv, "GET", "/_collections", "10.0.2.2:5000", keyHeader); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected API key limit to apply across addresses, got %d", rr.Code)
+	}
+}
+
+func TestRateLimitMaxConcurrentRequests(t *testing.T) {
+	config := DefaultRateLimitConfig()
+	config.MaxConcurrentRequests = 1
+	srv, cleanup := setupRateLimitedServer(t, config)
+	defer cleanup()
+
+	release := make(chan struct{})
+	srv.router.Get("/_test/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		WriteSuccess(w, "done")
+	})
+
+	done := make(chan struct{})
+	go func() {
+		sendFrom(srv, "GET", "/_test/slow", "10.0.0.1:5000", nil)
+		close(done)
+	}()
+	for len(srv.rateLimiter.slots) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	rr := sendFrom(srv, "GET", "/_collections", "10.0.0.2:5000", nil)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 at concurrency limit, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected Retry-After 1, got %q", rr.Header().Get("Retry-After"))
+	}
+
+	close(release)
+	<-done
+	if rr := sendFrom(srv, "GET", "/_collections", "10.0.0.2:5000", nil); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 after slot is released, got %d", rr.Code)
+	}
+}
+
+func TestIsWriteRequest(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		write  bool
+	}{
+		{"GET", "/users/_doc/1", false},
+		{"POST", "/users/_search", false},
+		{"POST", "/users/_count", false},
+		{"POST", "/users/_aggregate", false},
+		{"POST", "/_cursors", false},
+		{"POST", "/users/_doc", true},
+		{"PUT", "/users/_doc/1", true},
+		{"DELETE", "/users", true},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if got := isWriteRequest(req); got != tt.write {
+			t.Errorf("%s %s: expected write=%v, got %v", tt.method, tt.path, tt.write, got)
+		}
+	}
+}
@@ -4,14 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/mnohosten/laura-db/pkg/audit"
+	"github.com/mnohosten/laura-db/pkg/auth"
 	"github.com/mnohosten/laura-db/pkg/database"
 	gql "github.com/mnohosten/laura-db/pkg/graphql"
 	"github.com/mnohosten/laura-db/pkg/metrics"
@@ -29,6 +35,23 @@ type Server struct {
 	resourceTracker      *metrics.ResourceTracker
 	promExporter         *metrics.PrometheusExporter
 	changeStreamManager  *handlers.ChangeStreamManager
+	inFlight             atomic.Int64 // HTTP requests currently being served
+	draining             atomic.Bool  // set once Shutdown begins
+	replStatus           ReplicationStatus
+	rateLimiter          *rateLimiter // nil when rate limiting is disabled
+	slowQueries          *metrics.SlowQueryLog
+	configMu             sync.RWMutex // Guards the settings Reconfigure changes
+	wire                 wireListener
+}
+
+// ShutdownReport describes how Shutdown drained active work
+type ShutdownReport struct {
+	DrainedRequests int // in-flight requests that finished before the timeout
+	ForcedRequests  int // requests still running when connections were closed
+	DrainedStreams  int // change streams closed by their clients
+	ForcedStreams   int // change streams closed by the server
+	DrainedCursors  int // cursors exhausted or closed by their clients
+	ForcedCursors   int // cursors closed by the server
 }
 
 // New creates a new HTTP server instance
@@ -47,15 +70,18 @@ func New(config *Config) (*Server, error) {
 		}
 	}
 
-	// Open database
-	dbConfig := &database.Config{
-		DataDir:        config.DataDir,
-		BufferPoolSize: config.BufferSize,
+	if config.AuditLevel != "" {
+		if _, err := audit.ParseSeverity(config.AuditLevel); err != nil {
+			return nil, err
+		}
 	}
-	db, err := database.Open(dbConfig)
+
+	// Open database
+	db, err := database.Open(databaseConfig(config))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	db.SetReadOnly(config.ReadOnly)
 
 	// Create metrics collector and resource tracker
 	metricsCollector := metrics.NewMetricsCollector()
@@ -72,6 +98,14 @@ func New(config *Config) (*Server, error) {
 		resourceTracker:  resourceTracker,
 		promExporter:     promExporter,
 	}
+	if config.RateLimit != nil {
+		srv.rateLimiter = newRateLimiter(config.RateLimit, metricsCollector)
+	}
+	srv.slowQueries, _ = metrics.NewSlowQueryLog(&metrics.SlowQueryLogConfig{
+		Threshold:  config.SlowQueryThreshold,
+		MaxEntries: 1000,
+		Enabled:    config.SlowQueryThreshold > 0,
+	})
 
 	// Setup middleware
 	srv.setupMiddleware()
@@ -110,6 +144,17 @@ func (s *Server) setupMiddleware() {
 	// Recovery middleware to recover from panics
 	s.router.Use(middleware.Recoverer)
 
+	// In-flight tracking, so shutdown can drain active requests
+	s.router.Use(s.trackInFlightMiddleware)
+
+	// Per-client rate limiting and concurrent request cap
+	if s.rateLimiter != nil {
+		s.router.Use(s.rateLimitMiddleware)
+	}
+
+	// Slow request recording
+	s.router.Use(s.slowQueryMiddleware)
+
 	// Request logging
 	if s.config.EnableLogging {
 		s.router.Use(middleware.Logger)
@@ -153,12 +198,24 @@ func (s *Server) setupRoutes() {
 
 	// Health and admin endpoints (API routes)
 	s.router.Get("/_health", s.jsonContentType(h.Health(s.startTime)))
+	s.router.Get("/_health/live", s.handleLiveness)
+	s.router.Get("/_health/ready", s.handleReadiness)
+	s.router.Get("/_replset/status", s.handleReplSetStatus)
 	s.router.Get("/_stats", s.jsonContentType(h.GetDatabaseStats))
 	s.router.Get("/_collections", s.jsonContentType(h.ListCollections))
 
 	// Prometheus metrics endpoint
 	s.router.Get("/_metrics", s.handlePrometheusMetrics)
 
+	// Runtime configuration, for admins only
+	if s.config.AdminAuth != nil {
+		s.router.Route("/_admin", func(r chi.Router) {
+			r.Use(s.config.AdminAuth.Middleware(auth.PermissionManageServer))
+			r.Get("/config", s.handleGetConfig)
+			r.Put("/config", s.handleUpdateConfig)
+		})
+	}
+
 	// Cursor API endpoints
 	s.router.Post("/_cursors", s.jsonContentType(h.CreateCursor))
 	s.router.Get("/_cursors/{cursorId}/batch", s.jsonContentType(h.FetchBatch))
@@ -189,6 +246,7 @@ func (s *Server) setupRoutes() {
 		r.Post("/_search", h.SearchDocuments)
 		r.Get("/_count", h.CountDocuments)
 		r.Post("/_count", h.CountDocumentsWithFilter)
+		r.Post("/_explain", h.ExplainQuery)
 
 		// Aggregation
 		r.Post("/_aggregate", h.Aggregate)
@@ -262,6 +320,27 @@ func (s *Server) requestSizeLimitMiddleware(next http.Handler) http.Handler {
 }
 
 // handlePrometheusMetrics handles the Prometheus metrics endpoint
+// trackInFlightMiddleware counts active requests and rejects new ones once
+// shutdown has started. WebSocket upgrades are tracked as change streams
+// instead, since they stay open for the lifetime of the stream.
+func (s *Server) trackInFlightMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.draining.Load() {
+			w.Header().Set("Connection", "close")
+			WriteError(w, http.StatusServiceUnavailable, "ShuttingDown", "server is shutting down")
+			return
+		}
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (s *Server) handlePrometheusMetrics(w http.ResponseWriter, r *http.Request) {
 	// Set Prometheus text format content type
 	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
@@ -292,6 +371,21 @@ func (s *Server) Start() error {
 
 	// Start server in goroutine
 	errChan := make(chan error, 1)
+
+	// Start wire protocol listener
+	if s.config.WirePort > 0 {
+		wireAddr := fmt.Sprintf("%s:%d", s.config.Host, s.config.WirePort)
+		listener, err := net.Listen("tcp", wireAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on wire port: %w", err)
+		}
+		fmt.Printf("🔗 Wire protocol listening on %s\n", wireAddr)
+		go func() {
+			if err := s.ServeWire(listener); err != nil {
+				errChan <- err
+			}
+		}()
+	}
 	go func() {
 		var err error
 		if s.config.EnableTLS {
@@ -323,6 +417,11 @@ func (s *Server) GetDatabase() *database.Database {
 	return s.db
 }
 
+// GetSlowQueryLog returns the log of requests slower than SlowQueryThreshold
+func (s *Server) GetSlowQueryLog() *metrics.SlowQueryLog {
+	return s.slowQueries
+}
+
 // GetMetricsCollector returns the metrics collector
 func (s *Server) GetMetricsCollector() *metrics.MetricsCollector {
 	return s.metricsCollector
@@ -333,26 +432,74 @@ func (s *Server) GetResourceTracker() *metrics.ResourceTracker {
 	return s.resourceTracker
 }
 
-// Shutdown gracefully shuts down the server
+// Shutdown gracefully shuts down the server, waiting up to
+// Config.ShutdownTimeout for active work to drain
 func (s *Server) Shutdown() error {
 	fmt.Println("🛑 Shutting down server...")
 
-	// Create shutdown context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	timeout := s.config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	// Shutdown HTTP server
+	report, err := s.ShutdownContext(ctx)
+	fmt.Printf("📊 Drained %d requests (%d force-closed), %d change streams (%d force-closed), %d cursors (%d force-closed)\n",
+		report.DrainedRequests, report.ForcedRequests,
+		report.DrainedStreams, report.ForcedStreams,
+		report.DrainedCursors, report.ForcedCursors)
+	if err != nil {
+		fmt.Printf("❌ Database close error: %v\n", err)
+		return err
+	}
+
+	fmt.Println("✅ Server shutdown complete")
+	return nil
+}
+
+// ShutdownContext stops accepting new requests, waits until in-flight
+// requests, change streams and cursors finish or ctx expires, force-closes
+// whatever is left and then closes the database, which flushes dirty pages
+// and checkpoints the WAL.
+func (s *Server) ShutdownContext(ctx context.Context) (*ShutdownReport, error) {
+	report := &ShutdownReport{}
+	s.draining.Store(true)
+
+	// Stop listening and wait for active requests, including those
+	// pipelined on wire connections
+	pendingRequests := int(s.inFlight.Load())
+	s.closeWireListener()
 	if err := s.httpSrv.Shutdown(ctx); err != nil {
-		fmt.Printf("❌ Server shutdown error: %v\n", err)
+		s.httpSrv.Close()
 	}
+	waitUntil(ctx, func() bool { return s.inFlight.Load() == 0 })
+	report.ForcedRequests = int(s.inFlight.Load())
+	report.DrainedRequests = max(pendingRequests-report.ForcedRequests, 0)
+	s.closeWireConns()
 
-	// Close change stream manager and all active WebSocket connections
+	// Give change streams a chance to be closed by their clients
 	if s.changeStreamManager != nil {
+		pendingStreams := s.changeStreamManager.ActiveConnections()
+		waitUntil(ctx, func() bool { return s.changeStreamManager.ActiveConnections() == 0 })
+		report.ForcedStreams = s.changeStreamManager.ActiveConnections()
+		report.DrainedStreams = max(pendingStreams-report.ForcedStreams, 0)
+
 		if err := s.changeStreamManager.Close(); err != nil {
 			fmt.Printf("⚠️  Warning: Error closing change stream manager: %v\n", err)
 		}
 	}
 
+	// Wait for cursors to be exhausted or closed
+	cursors := s.db.CursorManager()
+	pendingCursors := cursors.ActiveCursors()
+	waitUntil(ctx, func() bool {
+		cursors.CleanupTimedOutCursors()
+		return cursors.ActiveCursors() == 0
+	})
+	report.ForcedCursors = cursors.CloseAll()
+	report.DrainedCursors = max(pendingCursors-report.ForcedCursors, 0)
+
 	// Stop resource tracker
 	if s.resourceTracker != nil {
 		s.resourceTracker.Disable()
@@ -360,12 +507,23 @@ func (s *Server) Shutdown() error {
 
 	// Close database
 	if err := s.db.Close(); err != nil {
-		fmt.Printf("❌ Database close error: %v\n", err)
-		return err
+		return report, err
 	}
+	return report, nil
+}
 
-	fmt.Println("✅ Server shutdown complete")
-	return nil
+// waitUntil polls done until it returns true or ctx expires
+func waitUntil(ctx context.Context, done func() bool) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for !done() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 // WriteJSON writes a JSON response
@@ -2,6 +2,7 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -336,6 +337,34 @@ func TestGetDocumentEndpoint(t *testing.T) {
 	}
 }
 
+// Test generated IDs round-trip through the document endpoints
+func TestGeneratedIDDocumentEndpoints(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	_, insertResp := makeRequest(t, srv, "POST", "/users/_doc", map[string]interface{}{"name": "Alice"})
+	id, ok := insertResp["result"].(map[string]interface{})["id"].(string)
+	if !ok {
+		t.Fatalf("Expected string id, got %v", insertResp["result"])
+	}
+
+	rr, resp := makeRequest(t, srv, "GET", "/users/_doc/"+id, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Response: %v", rr.Code, resp)
+	}
+	if docID := resp["result"].(map[string]interface{})["_id"]; docID != id {
+		t.Errorf("Expected _id %s, got %v", id, docID)
+	}
+
+	update := map[string]interface{}{"$set": map[string]interface{}{"name": "Alicia"}}
+	if rr, resp := makeRequest(t, srv, "PUT", "/users/_doc/"+id, update); rr.Code != http.StatusOK {
+		t.Errorf("Expected update status 200, got %d. Response: %v", rr.Code, resp)
+	}
+	if rr, resp := makeRequest(t, srv, "DELETE", "/users/_doc/"+id, nil); rr.Code != http.StatusOK {
+		t.Errorf("Expected delete status 200, got %d. Response: %v", rr.Code, resp)
+	}
+}
+
 // Test get non-existent document
 func TestGetNonExistentDocument(t *testing.T) {
 	srv, cleanup := setupTestServer(t)
@@ -718,6 +747,30 @@ func TestSearchWithSort(t *testing.T) {
 	}
 }
 
+// Test explain endpoint
+func TestExplainEndpoint(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	makeRequest(t, srv, "POST", "/users/_doc", map[string]interface{}{"age": int64(30)})
+	makeRequest(t, srv, "POST", "/users/_index", map[string]interface{}{"field": "age"})
+
+	rr, resp := makeRequest(t, srv, "POST", "/users/_explain", map[string]interface{}{
+		"filter": map[string]interface{}{"age": int64(30)},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Response: %v", rr.Code, resp)
+	}
+
+	result := resp["result"].(map[string]interface{})
+	if result["useIndex"] != true {
+		t.Errorf("Expected plan to use an index, got %v", result)
+	}
+	if result["collection"] != "users" {
+		t.Errorf("Expected collection users, got %v", result["collection"])
+	}
+}
+
 // Test count documents endpoint
 func TestCountDocumentsEndpoint(t *testing.T) {
 	srv, cleanup := setupTestServer(t)
@@ -2054,3 +2107,154 @@ func TestCursorErrorHandling(t *testing.T) {
 		t.Error("Expected error with invalid timeout format")
 	}
 }
+
+// Test resuming a cursor after the server lost it
+func TestCursorResumeAfterRestart(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	for i := 1; i <= 25; i++ {
+		doc := map[string]interface{}{"name": fmt.Sprintf("User %d", i), "age": int64(20 + i%5)}
+		if _, resp := makeRequest(t, srv, "POST", "/testcoll/_doc", doc); !resp["ok"].(bool) {
+			t.Fatalf("Failed to insert document: %v", resp)
+		}
+	}
+
+	createReq := map[string]interface{}{
+		"collection": "testcoll",
+		"sort":       []map[string]interface{}{{"field": "age", "order": "asc"}},
+		"batchSize":  10,
+		"resumable":  true,
+	}
+	_, resp := makeRequest(t, srv, "POST", "/_cursors", createReq)
+	cursorID := resp["result"].(map[string]interface{})["cursorId"].(string)
+
+	_, resp = makeRequest(t, srv, "GET", "/_cursors/"+cursorID+"/batch", nil)
+	result := resp["result"].(map[string]interface{})
+	token, _ := result["resumeToken"].(string)
+	if token == "" {
+		t.Fatal("Expected a resume token with the batch")
+	}
+	seen := make(map[string]bool)
+	for _, doc := range result["documents"].([]interface{}) {
+		seen[doc.(map[string]interface{})["name"].(string)] = true
+	}
+
+	// A restart loses every cursor
+	srv.db.CursorManager().CloseAll()
+	rr, resp := makeRequest(t, srv, "GET", "/_cursors/"+cursorID+"/batch", nil)
+	if rr.Code != http.StatusNotFound || resp["error"] != "CursorNotFound" {
+		t.Fatalf("Expected 404 CursorNotFound, got %d %v", rr.Code, resp["error"])
+	}
+
+	createReq["resumeAfter"] = token
+	createReq["batchSize"] = 100
+	rr, resp = makeRequest(t, srv, "POST", "/_cursors", createReq)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to resume cursor: %v", resp)
+	}
+	resumedID := resp["result"].(map[string]interface{})["cursorId"].(string)
+	_, resp = makeRequest(t, srv, "GET", "/_cursors/"+resumedID+"/batch", nil)
+	documents := resp["result"].(map[string]interface{})["documents"].([]interface{})
+	if len(documents) != 15 {
+		t.Errorf("Expected the 15 remaining documents, got %d", len(documents))
+	}
+	for _, doc := range documents {
+		if name := doc.(map[string]interface{})["name"].(string); seen[name] {
+			t.Errorf("Document %s returned twice", name)
+		}
+	}
+
+	createReq["resumeAfter"] = "not-a-token"
+	rr, resp = makeRequest(t, srv, "POST", "/_cursors", createReq)
+	if rr.Code != http.StatusBadRequest || resp["error"] != "InvalidResumeToken" {
+		t.Errorf("Expected 400 InvalidResumeToken, got %d %v", rr.Code, resp["error"])
+	}
+}
+
+// Test that shutdown waits for in-flight requests and rejects new ones
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	release := make(chan struct{})
+	srv.router.Get("/_test/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		WriteSuccess(w, "done")
+	})
+
+	slowDone := make(chan int)
+	go func() {
+		rr := httptest.NewRecorder()
+		srv.router.ServeHTTP(rr, httptest.NewRequest("GET", "/_test/slow", nil))
+		slowDone <- rr.Code
+	}()
+	for srv.inFlight.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	type result struct {
+		report *ShutdownReport
+		err    error
+	}
+	shutdownDone := make(chan result)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		report, err := srv.ShutdownContext(ctx)
+		shutdownDone <- result{report, err}
+	}()
+	for !srv.draining.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// New requests are rejected while draining
+	rr, _ := makeRequest(t, srv, "GET", "/_health", nil)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 while draining, got %d", rr.Code)
+	}
+
+	close(release)
+	if code := <-slowDone; code != http.StatusOK {
+		t.Errorf("Expected in-flight request to complete with 200, got %d", code)
+	}
+
+	res := <-shutdownDone
+	if res.err != nil {
+		t.Fatalf("Shutdown failed: %v", res.err)
+	}
+	if res.report.DrainedRequests != 1 || res.report.ForcedRequests != 0 {
+		t.Errorf("Expected 1 drained and 0 forced requests, got %+v", res.report)
+	}
+}
+
+// Test that shutdown force-closes cursors still open at the timeout
+func TestShutdownForceClosesCursors(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	for i := 0; i < 5; i++ {
+		makeRequest(t, srv, "POST", "/items/_doc", map[string]interface{}{"n": i})
+	}
+	rr, resp := makeRequest(t, srv, "POST", "/_cursors", map[string]interface{}{
+		"collection": "items",
+		"filter":     map[string]interface{}{},
+		"batchSize":  2,
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to create cursor: %v", resp)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	report, err := srv.ShutdownContext(ctx)
+	if err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if report.ForcedCursors != 1 || report.DrainedCursors != 0 {
+		t.Errorf("Expected 1 forced and 0 drained cursors, got %+v", report)
+	}
+	if active := srv.db.CursorManager().ActiveCursors(); active != 0 {
+		t.Errorf("Expected 0 active cursors after shutdown, got %d", active)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/metrics"
+)
+
+// slowQueryMiddleware records requests slower than SlowQueryThreshold in the
+// slow query log. Health probes, metrics scrapes, the admin console and
+// change streams are left out.
+func (s *Server) slowQueryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		console := r.URL.Path == "/" || strings.HasPrefix(r.URL.Path, "/static/")
+		if console || isExemptFromRateLimit(r) || strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		s.slowQueries.LogQuery(metrics.SlowQueryEntry{
+			Duration:   time.Since(start),
+			Operation:  requestOperation(r),
+			Collection: requestCollection(r),
+			UserInfo: map[string]string{
+				"remote_addr": r.RemoteAddr,
+				"path":        r.URL.Path,
+			},
+		})
+	})
+}
+
+// requestOperation names the operation of a request for the slow query log
+func requestOperation(r *http.Request) string {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	for _, suffix := range []string{"_search", "_count", "_explain", "_aggregate", "_bulk", "_bulkWrite", "_index"} {
+		if strings.HasSuffix(path, "/"+suffix) {
+			return strings.TrimPrefix(suffix, "_")
+		}
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		return "insert"
+	case http.MethodPut:
+		return "update"
+	case http.MethodDelete:
+		return "delete"
+	}
+	return "query"
+}
+
+// requestCollection returns the collection a request addresses, "" for
+// the server endpoints
+func requestCollection(r *http.Request) string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if strings.HasPrefix(name, "_") {
+		return ""
+	}
+	return name
+}
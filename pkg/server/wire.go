@@ -0,0 +1,222 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/mnohosten/laura-db/pkg/auth"
+	"github.com/mnohosten/laura-db/pkg/wire"
+)
+
+// maxPipelinedRequests bounds how many requests of one wire connection are
+// executed concurrently; further frames wait until a slot frees up
+const maxPipelinedRequests = 64
+
+// wireListener tracks the wire protocol listener and its open connections
+type wireListener struct {
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// ServeWire accepts wire protocol connections on l until the server shuts
+// down. Requests are dispatched through the same router as HTTP requests,
+// so they get the same handlers, rate limits and shutdown draining.
+func (s *Server) ServeWire(l net.Listener) error {
+	s.wire.mu.Lock()
+	if s.wire.closed {
+		s.wire.mu.Unlock()
+		l.Close()
+		return net.ErrClosed
+	}
+	s.wire.listener = l
+	s.wire.conns = make(map[net.Conn]struct{})
+	s.wire.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.wire.mu.Lock()
+			closed := s.wire.closed
+			s.wire.mu.Unlock()
+			if closed || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("wire accept failed: %w", err)
+		}
+
+		s.wire.mu.Lock()
+		s.wire.conns[conn] = struct{}{}
+		s.wire.mu.Unlock()
+
+		go s.serveWireConn(conn)
+	}
+}
+
+// closeWireListener stops accepting new wire connections
+func (s *Server) closeWireListener() {
+	s.wire.mu.Lock()
+	defer s.wire.mu.Unlock()
+
+	s.wire.closed = true
+	if s.wire.listener != nil {
+		s.wire.listener.Close()
+	}
+}
+
+// closeWireConns closes all open wire connections
+func (s *Server) closeWireConns() {
+	s.wire.mu.Lock()
+	defer s.wire.mu.Unlock()
+
+	for conn := range s.wire.conns {
+		conn.Close()
+	}
+}
+
+// serveWireConn reads frames from one connection and executes requests
+// concurrently, writing each response as soon as it is ready
+func (s *Server) serveWireConn(conn net.Conn) {
+	defer func() {
+		s.wire.mu.Lock()
+		delete(s.wire.conns, conn)
+		s.wire.mu.Unlock()
+		conn.Close()
+	}()
+
+	var writeMu sync.Mutex
+	send := func(id uint64, status int, body []byte) {
+		frame, err := wire.EncodeResponse(id, &wire.Response{Status: status, Body: body})
+		if err != nil {
+			return
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		wire.WriteFrame(conn, frame)
+	}
+
+	var session *auth.Session
+	var pending sync.WaitGroup
+	slots := make(chan struct{}, maxPipelinedRequests)
+	reader := bufio.NewReader(conn)
+
+	for {
+		frame, err := wire.ReadFrame(reader)
+		if err != nil {
+			break
+		}
+
+		switch frame.OpCode {
+		case wire.OpAuth:
+			if s.config.WireAuth == nil {
+				send(frame.RequestID, http.StatusOK, wireBody(http.StatusOK, "", ""))
+				continue
+			}
+			validated, err := s.config.WireAuth.ValidateSession(string(frame.Payload))
+			if err != nil {
+				send(frame.RequestID, http.StatusUnauthorized, wireBody(http.StatusUnauthorized, "Unauthorized", "invalid or expired token"))
+				continue
+			}
+			session = validated
+			send(frame.RequestID, http.StatusOK, wireBody(http.StatusOK, "", ""))
+
+		case wire.OpRequest:
+			if s.config.WireAuth != nil && session == nil {
+				send(frame.RequestID, http.StatusUnauthorized, wireBody(http.StatusUnauthorized, "Unauthorized", "authentication required"))
+				continue
+			}
+			req, err := wire.DecodeRequest(frame)
+			if err != nil {
+				send(frame.RequestID, http.StatusBadRequest, wireBody(http.StatusBadRequest, "BadRequest", err.Error()))
+				continue
+			}
+
+			slots <- struct{}{}
+			pending.Add(1)
+			go func(id uint64, req *wire.Request, session *auth.Session) {
+				defer func() {
+					<-slots
+					pending.Done()
+				}()
+				status, body := s.dispatchWireRequest(conn, req, session)
+				send(id, status, body)
+			}(frame.RequestID, req, session)
+
+		default:
+			send(frame.RequestID, http.StatusBadRequest, wireBody(http.StatusBadRequest, "BadRequest", "unexpected opcode "+frame.OpCode.String()))
+		}
+	}
+
+	pending.Wait()
+}
+
+// dispatchWireRequest executes a wire request through the HTTP router
+func (s *Server) dispatchWireRequest(conn net.Conn, req *wire.Request, session *auth.Session) (int, []byte) {
+	ctx := context.Background()
+	if session != nil {
+		ctx = context.WithValue(ctx, auth.ContextKeySession, session)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.Path, bytes.NewReader(req.Body))
+	if err != nil {
+		return http.StatusBadRequest, wireBody(http.StatusBadRequest, "BadRequest", err.Error())
+	}
+	httpReq.RemoteAddr = conn.RemoteAddr().String()
+	if len(req.Body) > 0 {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	rec := &wireResponseWriter{header: make(http.Header)}
+	s.router.ServeHTTP(rec, httpReq)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.status, rec.body.Bytes()
+}
+
+// wireBody builds a response body in the format of the HTTP API
+func wireBody(status int, errorType, message string) []byte {
+	response := map[string]interface{}{"ok": true}
+	if errorType != "" {
+		response = map[string]interface{}{
+			"ok":      false,
+			"error":   errorType,
+			"message": message,
+			"code":    status,
+		}
+	}
+	body, _ := json.Marshal(response)
+	return body
+}
+
+// wireResponseWriter captures a handler's response for a wire reply
+type wireResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *wireResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *wireResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+func (w *wireResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
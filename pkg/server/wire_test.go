@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/auth"
+	"github.com/mnohosten/laura-db/pkg/client"
+)
+
+// Helper to serve the wire protocol on a random local port
+func startWireListener(t testing.TB, srv *Server) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go srv.ServeWire(listener)
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// Helper to create a client that talks the wire protocol
+func newWireClient(port int, token string) *client.Client {
+	return client.NewClient(&client.Config{
+		Host:      "127.0.0.1",
+		WirePort:  port,
+		AuthToken: token,
+		Timeout:   5 * time.Second,
+	})
+}
+
+func TestWireProtocolCRUD(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	c := newWireClient(startWireListener(t, srv), "")
+	defer c.Close()
+
+	if err := c.CreateCollection("users"); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	users := c.Collection("users")
+
+	if err := users.InsertOneWithID("alice", map[string]interface{}{"name": "Alice", "age": 30}); err != nil {
+		t.Fatalf("InsertOneWithID failed: %v", err)
+	}
+	if _, err := users.InsertOne(map[string]interface{}{"name": "Bob", "age": 25}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+
+	doc, err := users.FindOne("alice")
+	if err != nil {
+		t.Fatalf("FindOne failed: %v", err)
+	}
+	if doc["name"] != "Alice" {
+		t.Errorf("Expected name Alice, got %v", doc["name"])
+	}
+
+	if err := users.UpdateOne("alice", map[string]interface{}{"$set": map[string]interface{}{"age": 31}}); err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+	results, err := users.Find(map[string]interface{}{"age": map[string]interface{}{"$gt": 30}})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 result, got %d", len(results))
+	}
+
+	groups, err := users.Aggregate(client.NewPipeline().
+		Group(nil, map[string]interface{}{"total": client.Sum("age")}).
+		Build())
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if len(groups) != 1 || groups[0]["total"] != float64(56) {
+		t.Errorf("Expected total age 56, got %v", groups)
+	}
+
+	if err := users.DeleteOne("alice"); err != nil {
+		t.Fatalf("DeleteOne failed: %v", err)
+	}
+	if _, err := users.FindOne("alice"); err == nil {
+		t.Error("Expected error finding deleted document")
+	}
+}
+
+func TestWireProtocolPipelining(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	c := newWireClient(startWireListener(t, srv), "")
+	defer c.Close()
+	c.CreateCollection("events")
+	events := c.Collection("events")
+
+	// Concurrent requests share one connection
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := events.InsertOneWithID(strconv.Itoa(i), map[string]interface{}{"n": i}); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Insert failed: %v", err)
+	}
+
+	count, err := events.Count(nil)
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 50 {
+		t.Errorf("Expected 50 documents, got %d", count)
+	}
+
+	srv.wire.mu.Lock()
+	conns := len(srv.wire.conns)
+	srv.wire.mu.Unlock()
+	if conns != 1 {
+		t.Errorf("Expected 1 wire connection, got %d", conns)
+	}
+}
+
+func TestWireProtocolAuth(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	authManager := auth.NewAuthManager()
+	srv.config.WireAuth = authManager
+	port := startWireListener(t, srv)
+
+	anonymous := newWireClient(port, "")
+	defer anonymous.Close()
+	if _, err := anonymous.ListCollections(); err == nil {
+		t.Error("Expected error without auth token")
+	}
+
+	invalid := newWireClient(port, "bogus")
+	defer invalid.Close()
+	if _, err := invalid.ListCollections(); err == nil {
+		t.Error("Expected error with invalid auth token")
+	}
+
+	token, err := authManager.Authenticate("admin", "admin")
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	authed := newWireClient(port, token)
+	defer authed.Close()
+	if _, err := authed.ListCollections(); err != nil {
+		t.Errorf("Expected authenticated request to succeed: %v", err)
+	}
+}
+
+func TestWireProtocolShutdown(t *testing.T) {
+	srv, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	c := newWireClient(startWireListener(t, srv), "")
+	defer c.Close()
+	if _, err := c.ListCollections(); err != nil {
+		t.Fatalf("ListCollections failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := srv.ShutdownContext(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	if _, err := c.ListCollections(); err == nil {
+		t.Error("Expected request to fail after shutdown")
+	}
+}
+
+// benchmarkInsert measures concurrent inserts through a client
+func benchmarkInsert(b *testing.B, newClient func(srv *Server) *client.Client) {
+	tmpDir, _ := os.MkdirTemp("", "laura-bench-*")
+	defer os.RemoveAll(tmpDir)
+
+	config := DefaultConfig()
+	config.DataDir = tmpDir
+	config.EnableLogging = false
+
+	srv, _ := New(config)
+	defer srv.db.Close()
+
+	c := newClient(srv)
+	defer c.Close()
+	c.CreateCollection("bench")
+	coll := c.Collection("bench")
+
+	var seq sync.Mutex
+	next := 0
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			seq.Lock()
+			next++
+			id := fmt.Sprintf("doc-%d", next)
+			seq.Unlock()
+			if err := coll.InsertOneWithID(id, map[string]interface{}{"name": "Benchmark User", "age": 30}); err != nil {
+				b.Fatalf("Insert failed: %v", err)
+			}
+		}
+	})
+}
+
+// Benchmark inserts over HTTP
+func BenchmarkInsertHTTP(b *testing.B) {
+	benchmarkInsert(b, func(srv *Server) *client.Client {
+		ts := httptest.NewServer(srv.router)
+		b.Cleanup(ts.Close)
+		u, _ := url.Parse(ts.URL)
+		port, _ := strconv.Atoi(u.Port())
+		return client.NewClient(&client.Config{Host: "127.0.0.1", Port: port, MaxConnsPerHost: 64})
+	})
+}
+
+// Benchmark inserts over the wire protocol
+func BenchmarkInsertWire(b *testing.B) {
+	benchmarkInsert(b, func(srv *Server) *client.Client {
+		return newWireClient(startWireListener(b, srv), "")
+	})
+}
@@ -0,0 +1,46 @@
+package sharding
+
+import (
+	"fmt"
+
+	"github.com/mnohosten/laura-db/pkg/changestream"
+	"github.com/mnohosten/laura-db/pkg/oplog"
+)
+
+// Watch opens a change stream on a sharded collection. Events of all shards
+// are merged in cluster time order, and the resume token records the
+// position in every shard's oplog. Shards added to the router while the
+// stream is open are picked up, including writes made before its next poll:
+// from the first Watch call on, every shard records its writes.
+func (sr *ShardRouter) Watch(collection string, opts *changestream.ChangeStreamOptions) (*changestream.ChangeStream, error) {
+	sr.mu.Lock()
+	sr.watching = true
+	sr.mu.Unlock()
+
+	cs, err := changestream.NewMergedChangeStream(sr.changeOplogs, "", collection, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cs.Start(); err != nil {
+		cs.Close()
+		return nil, err
+	}
+	return cs, nil
+}
+
+// changeOplogs returns the change oplog of each shard
+func (sr *ShardRouter) changeOplogs() (map[string]*oplog.Oplog, error) {
+	shards := sr.GetAllShards()
+	logs := make(map[string]*oplog.Oplog, len(shards))
+	for _, shard := range shards {
+		if shard.Database == nil {
+			continue
+		}
+		log, err := shard.Database.ChangeOplog()
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %w", shard.ID, err)
+		}
+		logs[string(shard.ID)] = log
+	}
+	return logs, nil
+}
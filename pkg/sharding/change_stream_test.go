@@ -0,0 +1,77 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/changestream"
+	"github.com/mnohosten/laura-db/pkg/database"
+)
+
+func TestShardRouterWatch(t *testing.T) {
+	router, _ := NewShardRouter(NewHashShardKey("user_id"))
+	addShard := func(i int) {
+		dir := fmt.Sprintf("/tmp/test-watch-shard%d", i)
+		os.RemoveAll(dir)
+		db, err := database.Open(database.DefaultConfig(dir))
+		if err != nil {
+			t.Fatalf("failed to open shard database: %v", err)
+		}
+		t.Cleanup(func() {
+			db.Close()
+			os.RemoveAll(dir)
+		})
+		router.AddShard(NewShard(ShardID(fmt.Sprintf("shard-%d", i)), db, ""))
+	}
+	addShard(1)
+	addShard(2)
+
+	opts := changestream.DefaultChangeStreamOptions()
+	opts.MaxAwaitTime = 20 * time.Millisecond
+	stream, err := router.Watch("users", opts)
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	defer stream.Close()
+
+	// A shard added while the stream is open is picked up
+	addShard(3)
+
+	var users []string
+	shards := make(map[string]bool)
+	for i := 0; len(shards) < 3; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		doc := map[string]interface{}{"user_id": userID}
+		shard, err := router.Route(doc)
+		if err != nil {
+			t.Fatalf("failed to route: %v", err)
+		}
+		if _, err := shard.Database.Collection("users").InsertOne(doc); err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+		users = append(users, userID)
+		shards[string(shard.ID)] = true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	seen := make(map[string]bool)
+	for _, userID := range users {
+		event, err := stream.Next(ctx)
+		if err != nil {
+			t.Fatalf("failed to get event: %v", err)
+		}
+		// Events arrive in the order the writes were made
+		if got := event.FullDocument["user_id"]; got != userID {
+			t.Errorf("expected event for %s, got %v", userID, got)
+		}
+		seen[event.Shard] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("expected events from 3 shards, got %v", seen)
+	}
+}
@@ -0,0 +1,492 @@
+package sharding
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mnohosten/laura-db/pkg/replication"
+)
+
+var (
+	// ErrConfigServerSecondary is returned when changing metadata on a
+	// config server that is not the primary of its replica set
+	ErrConfigServerSecondary = errors.New("config server is not primary")
+
+	// ErrNoConfigPrimary is returned when a config replica set has no
+	// primary because a majority of its members is unavailable
+	ErrNoConfigPrimary = errors.New("config replica set has no primary")
+
+	// ErrConfigServerUnreachable is returned when using a config server
+	// that is down
+	ErrConfigServerUnreachable = errors.New("config server unreachable")
+
+	// ErrConfigMajorityUnavailable is returned when a metadata change could
+	// not be applied on a majority of config servers
+	ErrConfigMajorityUnavailable = errors.New("config server majority unavailable")
+)
+
+// ConfigReplicaSet runs a group of config servers as a replica set, so that
+// sharding metadata survives the loss of a member. Metadata changes are made
+// on the primary, logged to its oplog and applied by every available member
+// before they are acknowledged. Reads can be served by any member. When the
+// primary is lost, the most up-to-date remaining member takes over, as long
+// as a majority of members is available.
+type ConfigReplicaSet struct {
+	name    string
+	members []*ConfigMember
+	primary *ConfigMember
+	mu      sync.RWMutex
+}
+
+// ConfigMemberConfig describes a member of a config replica set
+type ConfigMemberConfig struct {
+	ID       string
+	DataDir  string
+	Priority int // Higher priority members are preferred as primary
+}
+
+// ConfigMember is a config server in a config replica set
+type ConfigMember struct {
+	ID       string
+	Priority int
+	set      *ConfigReplicaSet
+	server   *ConfigServer
+	oplog    *replication.Oplog
+	version  atomic.Int64 // Metadata version applied by the member
+	down     bool         // Guarded by set.mu
+}
+
+// NewConfigReplicaSet opens the config servers of a replica set and elects
+// a primary. Each member keeps its metadata and oplog in its own directory.
+func NewConfigReplicaSet(name string, configs []ConfigMemberConfig) (*ConfigReplicaSet, error) {
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("config replica set needs at least one member")
+	}
+
+	s := &ConfigReplicaSet{name: name}
+	seen := make(map[string]bool)
+	for _, config := range configs {
+		if seen[config.ID] {
+			s.Close()
+			return nil, fmt.Errorf("duplicate config server member: %s", config.ID)
+		}
+		seen[config.ID] = true
+
+		server, err := NewConfigServer(config.DataDir)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("failed to open config server %s: %w", config.ID, err)
+		}
+		oplog, err := replication.NewOplog(filepath.Join(config.DataDir, "config_oplog.log"))
+		if err != nil {
+			server.Close()
+			s.Close()
+			return nil, fmt.Errorf("failed to open oplog of config server %s: %w", config.ID, err)
+		}
+
+		member := &ConfigMember{
+			ID:       config.ID,
+			Priority: config.Priority,
+			set:      s,
+			server:   server,
+			oplog:    oplog,
+		}
+		member.version.Store(server.GetVersion())
+		server.replicator = member
+		s.members = append(s.members, member)
+	}
+
+	s.mu.Lock()
+	s.elect()
+	s.mu.Unlock()
+
+	// Members that were behind when the set was last stopped catch up now
+	if err := s.syncMembers(); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Name returns the replica set name
+func (s *ConfigReplicaSet) Name() string {
+	return s.name
+}
+
+// Primary returns the config server that accepts metadata changes
+func (s *ConfigReplicaSet) Primary() (*ConfigServer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.primary == nil {
+		return nil, ErrNoConfigPrimary
+	}
+	return s.primary.server, nil
+}
+
+// PrimaryID returns the ID of the primary, or "" if there is none
+func (s *ConfigReplicaSet) PrimaryID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.primary == nil {
+		return ""
+	}
+	return s.primary.ID
+}
+
+// Member returns the config server of a member, for reads
+func (s *ConfigReplicaSet) Member(id string) (*ConfigServer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, member := range s.members {
+		if member.ID == id {
+			if member.down {
+				return nil, fmt.Errorf("config server %s: %w", id, ErrConfigServerUnreachable)
+			}
+			return member.server, nil
+		}
+	}
+	return nil, fmt.Errorf("config server member not found: %s", id)
+}
+
+// StopMember takes a member out of the replica set, as if its node was
+// lost. If it was the primary, another member is elected.
+func (s *ConfigReplicaSet) StopMember(id string) error {
+	s.mu.Lock()
+	member := s.findMember(id)
+	if member == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("config server member not found: %s", id)
+	}
+
+	member.down = true
+	if s.primary == member || s.available() < s.majority() {
+		s.elect()
+	}
+	s.mu.Unlock()
+
+	return s.syncMembers()
+}
+
+// StartMember brings a stopped member back. It catches up with the primary
+// before it serves reads, and takes part in the next election.
+func (s *ConfigReplicaSet) StartMember(id string) error {
+	s.mu.RLock()
+	member := s.findMember(id)
+	primary := s.primary
+	s.mu.RUnlock()
+
+	if member == nil {
+		return fmt.Errorf("config server member not found: %s", id)
+	}
+	if primary != nil {
+		if err := member.syncFrom(primary); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	member.down = false
+	if s.primary == nil {
+		s.elect()
+	}
+	s.mu.Unlock()
+
+	// Catch up with changes made while the member was starting
+	return s.syncMembers()
+}
+
+// findMember returns a member by ID. Must be called with s.mu held.
+func (s *ConfigReplicaSet) findMember(id string) *ConfigMember {
+	for _, member := range s.members {
+		if member.ID == id {
+			return member
+		}
+	}
+	return nil
+}
+
+// available returns the number of members that are up. Must be called with
+// s.mu held.
+func (s *ConfigReplicaSet) available() int {
+	count := 0
+	for _, member := range s.members {
+		if !member.down {
+			count++
+		}
+	}
+	return count
+}
+
+// majority returns the number of members needed to elect a primary and
+// acknowledge a metadata change
+func (s *ConfigReplicaSet) majority() int {
+	return len(s.members)/2 + 1
+}
+
+// elect picks the available member with the newest metadata as primary,
+// preferring higher priority on ties. Without a majority there is no
+// primary. Must be called with s.mu held.
+func (s *ConfigReplicaSet) elect() {
+	s.primary = nil
+	if s.available() < s.majority() {
+		return
+	}
+
+	for _, member := range s.members {
+		if member.down {
+			continue
+		}
+		if s.primary == nil {
+			s.primary = member
+			continue
+		}
+		version, best := member.version.Load(), s.primary.version.Load()
+		if version > best || (version == best && member.Priority > s.primary.Priority) {
+			s.primary = member
+		}
+	}
+}
+
+// syncMembers brings the available members up to date with the primary
+func (s *ConfigReplicaSet) syncMembers() error {
+	s.mu.RLock()
+	primary := s.primary
+	var secondaries []*ConfigMember
+	for _, member := range s.members {
+		if !member.down && member != primary {
+			secondaries = append(secondaries, member)
+		}
+	}
+	s.mu.RUnlock()
+
+	if primary == nil {
+		return nil
+	}
+	for _, member := range secondaries {
+		if err := member.syncFrom(primary); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MemberStatus describes a member of a config replica set
+type MemberStatus struct {
+	ID        string `json:"id"`
+	Primary   bool   `json:"primary"`
+	Available bool   `json:"available"`
+	Version   int64  `json:"version"`
+}
+
+// Status returns the state of each member, in configuration order
+func (s *ConfigReplicaSet) Status() []MemberStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := make([]MemberStatus, 0, len(s.members))
+	for _, member := range s.members {
+		status = append(status, MemberStatus{
+			ID:        member.ID,
+			Primary:   member == s.primary,
+			Available: !member.down,
+			Version:   member.version.Load(),
+		})
+	}
+	return status
+}
+
+// Close shuts down all members
+func (s *ConfigReplicaSet) Close() error {
+	var errs []error
+	for _, member := range s.members {
+		if err := member.server.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("config server %s: %w", member.ID, err))
+		}
+		if err := member.oplog.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("config server %s oplog: %w", member.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkWritable only lets the primary change metadata
+func (m *ConfigMember) checkWritable() error {
+	m.set.mu.RLock()
+	defer m.set.mu.RUnlock()
+
+	switch {
+	case m.down:
+		return ErrConfigServerUnreachable
+	case m.set.primary == nil:
+		return ErrNoConfigPrimary
+	case m.set.primary != m:
+		return ErrConfigServerSecondary
+	}
+	return nil
+}
+
+// replicate logs a metadata change of the primary and applies it on the
+// available secondaries. It is called with the primary's server locked,
+// and holds the replica set lock so that no election runs meanwhile.
+func (m *ConfigMember) replicate(data []byte) error {
+	s := m.set
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.primary != m {
+		return ErrConfigServerSecondary
+	}
+
+	entry, err := newConfigOplogEntry(s.name, data)
+	if err != nil {
+		return err
+	}
+	if err := m.oplog.Append(entry); err != nil {
+		return fmt.Errorf("failed to log metadata change: %w", err)
+	}
+	m.version.Store(m.server.version)
+
+	acknowledged := 1
+	var errs []error
+	for _, member := range s.members {
+		if member == m || member.down {
+			continue
+		}
+		if err := member.apply(entry); err != nil {
+			errs = append(errs, fmt.Errorf("config server %s: %w", member.ID, err))
+			continue
+		}
+		acknowledged++
+	}
+
+	if acknowledged < s.majority() {
+		errs = append(errs, fmt.Errorf("metadata change applied on %d of %d config servers: %w",
+			acknowledged, len(s.members), ErrConfigMajorityUnavailable))
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// syncFrom applies the primary's current metadata if it is newer
+func (m *ConfigMember) syncFrom(primary *ConfigMember) error {
+	primary.server.mu.RLock()
+	data, err := primary.server.encodeMetadata()
+	primary.server.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	entry, err := newConfigOplogEntry(m.set.name, data)
+	if err != nil {
+		return err
+	}
+	if err := m.apply(entry); err != nil {
+		return fmt.Errorf("config server %s failed to catch up: %w", m.ID, err)
+	}
+	return nil
+}
+
+// apply applies a metadata change replicated from the primary and records
+// it in the member's oplog
+func (m *ConfigMember) apply(entry *replication.OplogEntry) error {
+	data, err := json.Marshal(entry.Document)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	version, err := m.server.applyMetadata(data)
+	if err != nil {
+		return err
+	}
+	if version == m.version.Load() {
+		return nil // Already up to date
+	}
+	m.version.Store(version)
+
+	// Append assigns the member's own OpID, so log a copy of the entry
+	logged := *entry
+	if err := m.oplog.Append(&logged); err != nil {
+		return fmt.Errorf("failed to log metadata change: %w", err)
+	}
+	return nil
+}
+
+// newConfigOplogEntry wraps a metadata snapshot in an oplog entry. Each
+// entry carries the full metadata, so applying the newest one is enough to
+// catch up.
+func newConfigOplogEntry(setName string, data []byte) (*replication.OplogEntry, error) {
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	}
+
+	return &replication.OplogEntry{
+		OpType:     replication.OpTypeUpdate,
+		Database:   "config",
+		Collection: setName,
+		Document:   snapshot,
+	}, nil
+}
+
+// ConfigClient gives a router access to a config replica set. Reads go to
+// the member that served the previous read and fail over to the next
+// available member; metadata changes go to the current primary.
+type ConfigClient struct {
+	set     *ConfigReplicaSet
+	current int // Index of the member serving reads
+	mu      sync.Mutex
+}
+
+// NewConfigClient creates a client for a config replica set
+func NewConfigClient(set *ConfigReplicaSet) *ConfigClient {
+	return &ConfigClient{set: set}
+}
+
+// Read runs fn against an available config server
+func (c *ConfigClient) Read(fn func(cs *ConfigServer) error) error {
+	c.mu.Lock()
+	start := c.current
+	c.mu.Unlock()
+
+	members := c.set.members
+	for i := 0; i < len(members); i++ {
+		index := (start + i) % len(members)
+		server, err := c.set.Member(members[index].ID)
+		if err != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		c.current = index
+		c.mu.Unlock()
+		return fn(server)
+	}
+	return fmt.Errorf("no config server available: %w", ErrConfigServerUnreachable)
+}
+
+// Write runs fn against the primary. If the primary changes while fn runs,
+// fn is retried once on the new primary, so it must be safe to repeat.
+func (c *ConfigClient) Write(fn func(cs *ConfigServer) error) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		var primary *ConfigServer
+		primary, err = c.set.Primary()
+		if err != nil {
+			return err
+		}
+
+		err = fn(primary)
+		if !errors.Is(err, ErrConfigServerSecondary) && !errors.Is(err, ErrConfigServerUnreachable) {
+			return err
+		}
+	}
+	return err
+}
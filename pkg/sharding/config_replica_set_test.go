@@ -0,0 +1,177 @@
+package sharding
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// newTestConfigReplicaSet creates a config replica set of three members,
+// cfg-1 having the highest priority
+func newTestConfigReplicaSet(t *testing.T, dir string) *ConfigReplicaSet {
+	t.Helper()
+
+	configs := make([]ConfigMemberConfig, 0, 3)
+	for i := 1; i <= 3; i++ {
+		configs = append(configs, ConfigMemberConfig{
+			ID:       fmt.Sprintf("cfg-%d", i),
+			DataDir:  filepath.Join(dir, fmt.Sprintf("cfg-%d", i)),
+			Priority: 4 - i,
+		})
+	}
+
+	set, err := NewConfigReplicaSet("configRS", configs)
+	if err != nil {
+		t.Fatalf("Failed to create config replica set: %v", err)
+	}
+	return set
+}
+
+func TestConfigReplicaSetReplicatesChanges(t *testing.T) {
+	set := newTestConfigReplicaSet(t, t.TempDir())
+	defer set.Close()
+
+	if primary := set.PrimaryID(); primary != "cfg-1" {
+		t.Fatalf("Expected primary cfg-1, got %s", primary)
+	}
+
+	primary, _ := set.Primary()
+	if err := primary.RegisterShard(NewShard("shard-1", nil, "localhost:27017")); err != nil {
+		t.Fatalf("Failed to register shard: %v", err)
+	}
+
+	// Every member serves the change
+	for _, id := range []string{"cfg-1", "cfg-2", "cfg-3"} {
+		server, err := set.Member(id)
+		if err != nil {
+			t.Fatalf("Failed to get member %s: %v", id, err)
+		}
+		if _, err := server.GetShard("shard-1"); err != nil {
+			t.Errorf("Expected shard on %s: %v", id, err)
+		}
+		if server.GetVersion() != primary.GetVersion() {
+			t.Errorf("Expected version %d on %s, got %d", primary.GetVersion(), id, server.GetVersion())
+		}
+	}
+
+	// Secondaries don't accept changes
+	secondary, _ := set.Member("cfg-2")
+	err := secondary.RegisterShard(NewShard("shard-2", nil, "localhost:27018"))
+	if !errors.Is(err, ErrConfigServerSecondary) {
+		t.Errorf("Expected ErrConfigServerSecondary, got %v", err)
+	}
+}
+
+func TestConfigReplicaSetFailover(t *testing.T) {
+	set := newTestConfigReplicaSet(t, t.TempDir())
+	defer set.Close()
+
+	client := NewConfigClient(set)
+	err := client.Write(func(cs *ConfigServer) error {
+		return cs.RegisterShard(NewShard("shard-1", nil, "localhost:27017"))
+	})
+	if err != nil {
+		t.Fatalf("Failed to register shard: %v", err)
+	}
+
+	// Lose the primary
+	if err := set.StopMember("cfg-1"); err != nil {
+		t.Fatalf("Failed to stop member: %v", err)
+	}
+	if primary := set.PrimaryID(); primary != "cfg-2" {
+		t.Fatalf("Expected primary cfg-2, got %s", primary)
+	}
+
+	// The client fails over for reads and writes
+	err = client.Read(func(cs *ConfigServer) error {
+		_, err := cs.GetShard("shard-1")
+		return err
+	})
+	if err != nil {
+		t.Errorf("Expected metadata to survive failover: %v", err)
+	}
+	err = client.Write(func(cs *ConfigServer) error {
+		return cs.RegisterShard(NewShard("shard-2", nil, "localhost:27018"))
+	})
+	if err != nil {
+		t.Fatalf("Failed to register shard after failover: %v", err)
+	}
+
+	// The old primary catches up when it comes back, as a secondary
+	if err := set.StartMember("cfg-1"); err != nil {
+		t.Fatalf("Failed to start member: %v", err)
+	}
+	if primary := set.PrimaryID(); primary != "cfg-2" {
+		t.Errorf("Expected primary to stay cfg-2, got %s", primary)
+	}
+	server, _ := set.Member("cfg-1")
+	if _, err := server.GetShard("shard-2"); err != nil {
+		t.Errorf("Expected restarted member to catch up: %v", err)
+	}
+}
+
+func TestConfigReplicaSetWithoutMajority(t *testing.T) {
+	set := newTestConfigReplicaSet(t, t.TempDir())
+	defer set.Close()
+
+	primary, _ := set.Primary()
+	if err := primary.RegisterShard(NewShard("shard-1", nil, "localhost:27017")); err != nil {
+		t.Fatalf("Failed to register shard: %v", err)
+	}
+
+	set.StopMember("cfg-2")
+	set.StopMember("cfg-3")
+
+	// No primary without a majority: metadata is read-only
+	if _, err := set.Primary(); !errors.Is(err, ErrNoConfigPrimary) {
+		t.Errorf("Expected ErrNoConfigPrimary, got %v", err)
+	}
+	err := primary.UpdateShardState("shard-1", ShardStateDraining)
+	if !errors.Is(err, ErrNoConfigPrimary) {
+		t.Errorf("Expected ErrNoConfigPrimary, got %v", err)
+	}
+
+	client := NewConfigClient(set)
+	err = client.Read(func(cs *ConfigServer) error {
+		meta, err := cs.GetShard("shard-1")
+		if err == nil && meta.State != ShardStateActive {
+			t.Errorf("Expected state active, got %s", meta.State)
+		}
+		return err
+	})
+	if err != nil {
+		t.Errorf("Expected reads without a majority: %v", err)
+	}
+
+	// A majority elects a primary again
+	if err := set.StartMember("cfg-2"); err != nil {
+		t.Fatalf("Failed to start member: %v", err)
+	}
+	if _, err := set.Primary(); err != nil {
+		t.Errorf("Expected a primary: %v", err)
+	}
+}
+
+func TestConfigReplicaSetReopen(t *testing.T) {
+	dir := t.TempDir()
+	set := newTestConfigReplicaSet(t, dir)
+
+	primary, _ := set.Primary()
+	primary.RegisterShard(NewShard("shard-1", nil, "localhost:27017"))
+
+	// cfg-3 misses a change
+	set.StopMember("cfg-3")
+	primary.RegisterShard(NewShard("shard-2", nil, "localhost:27018"))
+	if err := set.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	set = newTestConfigReplicaSet(t, dir)
+	defer set.Close()
+
+	server, _ := set.Member("cfg-3")
+	if len(server.ListShards()) != 2 {
+		t.Errorf("Expected 2 shards on cfg-3 after reopening, got %d", len(server.ListShards()))
+	}
+}
@@ -10,14 +10,25 @@ import (
 )
 
 // ConfigServer stores and manages sharding metadata
-// In a distributed system, this would be a replicated service
+// Run several of them as a ConfigReplicaSet to survive the loss of one
 type ConfigServer struct {
 	dataDir        string
 	shardRegistry  map[ShardID]*ShardMetadata
 	chunkRegistry  map[string]*ChunkMetadata
 	collectionMeta map[string]*CollectionShardingConfig
 	mu             sync.RWMutex
-	version        int64 // Metadata version for optimistic concurrency
+	version        int64            // Metadata version for optimistic concurrency
+	replicator     configReplicator // Set when the server is a replica set member
+}
+
+// configReplicator replicates metadata changes of a config server to the
+// other members of its replica set
+type configReplicator interface {
+	// checkWritable returns an error if the server can't accept changes
+	checkWritable() error
+
+	// replicate sends the persisted metadata to the other members
+	replicate(data []byte) error
 }
 
 // ShardMetadata contains persistent metadata about a shard
@@ -99,6 +110,10 @@ func (cs *ConfigServer) RegisterShard(shard *Shard) error {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	if err := cs.checkWritable(); err != nil {
+		return err
+	}
+
 	if _, exists := cs.shardRegistry[shard.ID]; exists {
 		return fmt.Errorf("shard already registered: %s", shard.ID)
 	}
@@ -128,6 +143,10 @@ func (cs *ConfigServer) UnregisterShard(shardID ShardID) error {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	if err := cs.checkWritable(); err != nil {
+		return err
+	}
+
 	if _, exists := cs.shardRegistry[shardID]; !exists {
 		return fmt.Errorf("shard not found: %s", shardID)
 	}
@@ -156,6 +175,10 @@ func (cs *ConfigServer) UpdateShardState(shardID ShardID, state ShardState) erro
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	if err := cs.checkWritable(); err != nil {
+		return err
+	}
+
 	meta, exists := cs.shardRegistry[shardID]
 	if !exists {
 		return fmt.Errorf("shard not found: %s", shardID)
@@ -173,6 +196,10 @@ func (cs *ConfigServer) UpdateShardTags(shardID ShardID, tags map[string]string)
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	if err := cs.checkWritable(); err != nil {
+		return err
+	}
+
 	meta, exists := cs.shardRegistry[shardID]
 	if !exists {
 		return fmt.Errorf("shard not found: %s", shardID)
@@ -251,6 +278,10 @@ func (cs *ConfigServer) RegisterChunk(chunk *Chunk) error {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	if err := cs.checkWritable(); err != nil {
+		return err
+	}
+
 	if _, exists := cs.chunkRegistry[chunk.ID]; exists {
 		return fmt.Errorf("chunk already registered: %s", chunk.ID)
 	}
@@ -282,6 +313,10 @@ func (cs *ConfigServer) UnregisterChunk(chunkID string) error {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	if err := cs.checkWritable(); err != nil {
+		return err
+	}
+
 	if _, exists := cs.chunkRegistry[chunkID]; !exists {
 		return fmt.Errorf("chunk not found: %s", chunkID)
 	}
@@ -297,6 +332,10 @@ func (cs *ConfigServer) UpdateChunk(chunkID string, count, size int64) error {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	if err := cs.checkWritable(); err != nil {
+		return err
+	}
+
 	meta, exists := cs.chunkRegistry[chunkID]
 	if !exists {
 		return fmt.Errorf("chunk not found: %s", chunkID)
@@ -315,6 +354,10 @@ func (cs *ConfigServer) MoveChunkMetadata(chunkID string, targetShardID ShardID)
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	if err := cs.checkWritable(); err != nil {
+		return err
+	}
+
 	meta, exists := cs.chunkRegistry[chunkID]
 	if !exists {
 		return fmt.Errorf("chunk not found: %s", chunkID)
@@ -382,6 +425,10 @@ func (cs *ConfigServer) SetCollectionSharding(database, collection string, shard
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	if err := cs.checkWritable(); err != nil {
+		return err
+	}
+
 	key := fmt.Sprintf("%s.%s", database, collection)
 
 	if _, exists := cs.collectionMeta[key]; exists {
@@ -424,6 +471,10 @@ func (cs *ConfigServer) RemoveCollectionSharding(database, collection string) er
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	if err := cs.checkWritable(); err != nil {
+		return err
+	}
+
 	key := fmt.Sprintf("%s.%s", database, collection)
 	if _, exists := cs.collectionMeta[key]; !exists {
 		return fmt.Errorf("collection not sharded: %s", key)
@@ -492,8 +543,30 @@ func (cs *ConfigServer) Stats() map[string]interface{} {
 	}
 }
 
-// persistMetadata saves metadata to disk
+// checkWritable returns an error if metadata changes must go to another
+// member of the server's replica set
+func (cs *ConfigServer) checkWritable() error {
+	if cs.replicator == nil {
+		return nil
+	}
+	return cs.replicator.checkWritable()
+}
+
+// persistMetadata saves metadata to disk and replicates it
 func (cs *ConfigServer) persistMetadata() error {
+	data, err := cs.writeMetadata()
+	if err != nil {
+		return err
+	}
+
+	if cs.replicator != nil {
+		return cs.replicator.replicate(data)
+	}
+	return nil
+}
+
+// encodeMetadata serializes the metadata
+func (cs *ConfigServer) encodeMetadata() ([]byte, error) {
 	metadata := &ConfigServerMetadata{
 		Version:        cs.version,
 		Shards:         cs.shardRegistry,
@@ -504,7 +577,16 @@ func (cs *ConfigServer) persistMetadata() error {
 
 	data, err := json.MarshalIndent(metadata, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	return data, nil
+}
+
+// writeMetadata saves metadata to disk and returns what was written
+func (cs *ConfigServer) writeMetadata() ([]byte, error) {
+	data, err := cs.encodeMetadata()
+	if err != nil {
+		return nil, err
 	}
 
 	metaPath := filepath.Join(cs.dataDir, "config_server_metadata.json")
@@ -512,15 +594,37 @@ func (cs *ConfigServer) persistMetadata() error {
 
 	// Write to temporary file first
 	if err := os.WriteFile(tempPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write metadata file: %w", err)
+		return nil, fmt.Errorf("failed to write metadata file: %w", err)
 	}
 
 	// Atomic rename
 	if err := os.Rename(tempPath, metaPath); err != nil {
-		return fmt.Errorf("failed to rename metadata file: %w", err)
+		return nil, fmt.Errorf("failed to rename metadata file: %w", err)
 	}
 
-	return nil
+	return data, nil
+}
+
+// applyMetadata replaces the metadata with a newer version replicated from
+// the primary and returns the resulting version. Older or equal versions
+// are ignored.
+func (cs *ConfigServer) applyMetadata(data []byte) (int64, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	var metadata ConfigServerMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return cs.version, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	}
+	if metadata.Version <= cs.version {
+		return cs.version, nil
+	}
+
+	cs.setMetadata(&metadata)
+	if _, err := cs.writeMetadata(); err != nil {
+		return cs.version, err
+	}
+	return cs.version, nil
 }
 
 // loadMetadata loads metadata from disk
@@ -543,6 +647,12 @@ func (cs *ConfigServer) loadMetadata() error {
 		return fmt.Errorf("failed to unmarshal metadata: %w", err)
 	}
 
+	cs.setMetadata(&metadata)
+	return nil
+}
+
+// setMetadata replaces the in-memory metadata
+func (cs *ConfigServer) setMetadata(metadata *ConfigServerMetadata) {
 	cs.version = metadata.Version
 	cs.shardRegistry = metadata.Shards
 	cs.chunkRegistry = metadata.Chunks
@@ -558,8 +668,6 @@ func (cs *ConfigServer) loadMetadata() error {
 	if cs.collectionMeta == nil {
 		cs.collectionMeta = make(map[string]*CollectionShardingConfig)
 	}
-
-	return nil
 }
 
 // Close cleanly shuts down the config server
@@ -568,5 +676,6 @@ func (cs *ConfigServer) Close() error {
 	defer cs.mu.Unlock()
 
 	// Final metadata persist
-	return cs.persistMetadata()
+	_, err := cs.writeMetadata()
+	return err
 }
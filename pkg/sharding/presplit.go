@@ -0,0 +1,122 @@
+package sharding
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// PreSplit divides the hash space of a hash-sharded router into numChunks
+// evenly-spaced chunks, assigned round-robin across the shards in order of
+// their IDs, so inserts spread over every shard from the first one. Each
+// chunk covers the hash positions (see ShardKey.HashPosition) from its
+// MinKey up to, excluding, its MaxKey; the first and last chunks are
+// unbounded below and above. The router must have no chunks yet.
+//
+// Once pre-split, documents route by chunk instead of by hash modulo the
+// number of shards, and the chunks can be split and moved like those of
+// range sharding. Shards added later receive no chunks until some are moved
+// to them. With a config client set, the chunks are registered with the
+// config servers before the router uses them.
+func (sr *ShardRouter) PreSplit(numChunks int) ([]*Chunk, error) {
+	if sr.shardKey.Type != ShardKeyTypeHash {
+		return nil, fmt.Errorf("not using hash-based sharding")
+	}
+	if numChunks < 1 {
+		return nil, fmt.Errorf("number of chunks must be positive, got %d", numChunks)
+	}
+
+	bounds := make([]interface{}, numChunks-1)
+	width := math.MaxUint64 / uint64(numChunks)
+	for i := range bounds {
+		bounds[i] = uint64(i+1) * width
+	}
+	return sr.preSplit(bounds)
+}
+
+// PreSplitAt divides the key space of a range-sharded router into chunks
+// at the given split points, which must be strictly increasing. The
+// len(splitPoints)+1 chunks are assigned round-robin across the shards in
+// order of their IDs. The router must have no chunks yet. With a config
+// client set, the chunks are registered with the config servers before the
+// router uses them.
+func (sr *ShardRouter) PreSplitAt(splitPoints []interface{}) ([]*Chunk, error) {
+	if sr.shardKey.Type != ShardKeyTypeRange {
+		return nil, fmt.Errorf("not using range-based sharding")
+	}
+	if len(splitPoints) == 0 {
+		return nil, fmt.Errorf("no split points given")
+	}
+	for i := 1; i < len(splitPoints); i++ {
+		if sr.shardKey.CompareValues(splitPoints[i-1], splitPoints[i]) >= 0 {
+			return nil, fmt.Errorf("split points must be strictly increasing: %v is not after %v", splitPoints[i], splitPoints[i-1])
+		}
+	}
+	return sr.preSplit(splitPoints)
+}
+
+// preSplit creates the chunks between consecutive bounds, registers them
+// with the config servers and installs them in a new chunk manager
+func (sr *ShardRouter) preSplit(bounds []interface{}) ([]*Chunk, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if len(sr.shards) == 0 {
+		return nil, fmt.Errorf("no shards available")
+	}
+	if sr.chunkManager != nil && len(sr.chunkManager.GetAllChunks()) > 0 {
+		return nil, fmt.Errorf("router already has chunks")
+	}
+
+	shardIDs := make([]ShardID, 0, len(sr.shards))
+	for id := range sr.shards {
+		shardIDs = append(shardIDs, id)
+	}
+	sort.Slice(shardIDs, func(i, j int) bool { return shardIDs[i] < shardIDs[j] })
+
+	chunkManager := NewChunkManager(sr.shardKey)
+	chunks := make([]*Chunk, 0, len(bounds)+1)
+	var minKey interface{}
+	for i := 0; i <= len(bounds); i++ {
+		var maxKey interface{}
+		if i < len(bounds) {
+			maxKey = bounds[i]
+		}
+		chunk, err := chunkManager.CreateChunk(shardIDs[i%len(shardIDs)], minKey, maxKey)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+		minKey = maxKey
+	}
+
+	if sr.configClient != nil {
+		if err := registerChunks(sr.configClient, chunks); err != nil {
+			return nil, fmt.Errorf("failed to register chunks: %w", err)
+		}
+	}
+
+	sr.chunkManager = chunkManager
+	return chunks, nil
+}
+
+// registerChunks registers chunks with the config servers. Chunks already
+// registered with the same shard and bounds are skipped, so a write retried
+// on a new primary doesn't fail on those registered the first time.
+func registerChunks(client *ConfigClient, chunks []*Chunk) error {
+	return client.Write(func(cs *ConfigServer) error {
+		for _, chunk := range chunks {
+			if existing, err := cs.GetChunk(chunk.ID); err == nil {
+				if existing.ShardID != chunk.ShardID || !reflect.DeepEqual(existing.MinKey, chunk.MinKey) || !reflect.DeepEqual(existing.MaxKey, chunk.MaxKey) {
+					return fmt.Errorf("chunk already registered: %s", chunk.ID)
+				}
+				continue
+			}
+			if err := cs.RegisterChunk(chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,149 @@
+package sharding
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestShardRouterPreSplit(t *testing.T) {
+	router, _ := NewShardRouter(NewHashShardKey("user_id"))
+	for i := 1; i <= 3; i++ {
+		router.AddShard(NewShard(ShardID(fmt.Sprintf("shard-%d", i)), nil, ""))
+	}
+
+	set := newTestConfigReplicaSet(t, t.TempDir())
+	defer set.Close()
+	client := NewConfigClient(set)
+	err := client.Write(func(cs *ConfigServer) error {
+		for _, shard := range router.GetAllShards() {
+			if err := cs.RegisterShard(shard); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to register shards: %v", err)
+	}
+	router.SetConfigClient(client)
+
+	chunks, err := router.PreSplit(6)
+	if err != nil {
+		t.Fatalf("PreSplit failed: %v", err)
+	}
+	if len(chunks) != 6 {
+		t.Fatalf("Expected 6 chunks, got %d", len(chunks))
+	}
+	if chunks[0].MinKey != nil || chunks[5].MaxKey != nil {
+		t.Error("Expected the outer chunks to be unbounded")
+	}
+	if chunks[1].MinKey != uint64(math.MaxUint64/6) {
+		t.Errorf("Expected the second chunk to start at %d, got %v", uint64(math.MaxUint64/6), chunks[1].MinKey)
+	}
+	for i := 1; i <= 3; i++ {
+		if n := len(router.GetChunksForShard(ShardID(fmt.Sprintf("shard-%d", i)))); n != 2 {
+			t.Errorf("Expected 2 chunks on shard-%d, got %d", i, n)
+		}
+	}
+
+	// Inserts spread over every shard, consistently
+	counts := make(map[ShardID]int)
+	for i := 0; i < 300; i++ {
+		doc := map[string]interface{}{"user_id": fmt.Sprintf("user-%d", i)}
+		shard, err := router.Route(doc)
+		if err != nil {
+			t.Fatalf("Failed to route: %v", err)
+		}
+		again, _ := router.Route(doc)
+		if again != shard {
+			t.Fatalf("Expected %v to route consistently", doc)
+		}
+		counts[shard.ID]++
+	}
+	for id, count := range counts {
+		if count < 50 {
+			t.Errorf("Expected inserts spread evenly, %s got %d of 300", id, count)
+		}
+	}
+	if len(counts) != 3 {
+		t.Errorf("Expected inserts on 3 shards, got %v", counts)
+	}
+
+	// The config servers know the chunks
+	err = client.Read(func(cs *ConfigServer) error {
+		if n := len(cs.ListChunks()); n != 6 {
+			return fmt.Errorf("expected 6 registered chunks, got %d", n)
+		}
+		for _, chunk := range chunks {
+			meta, err := cs.GetChunk(chunk.ID)
+			if err != nil {
+				return err
+			}
+			if meta.ShardID != chunk.ShardID {
+				return fmt.Errorf("expected %s on %s, got %s", chunk.ID, chunk.ShardID, meta.ShardID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Config servers don't reflect the chunks: %v", err)
+	}
+
+	// Pre-split chunks move like range chunks
+	if err := router.MoveChunk(chunks[0].ID, "shard-3"); err != nil {
+		t.Errorf("Failed to move a pre-split chunk: %v", err)
+	}
+	if _, err := router.PreSplit(4); err == nil {
+		t.Error("Expected a second pre-split to fail")
+	}
+}
+
+func TestShardRouterPreSplitAt(t *testing.T) {
+	router, _ := NewShardRouter(NewRangeShardKey("age"))
+	router.AddShard(NewShard("shard-1", nil, ""))
+	router.AddShard(NewShard("shard-2", nil, ""))
+
+	if _, err := router.PreSplitAt([]interface{}{int64(30), int64(20)}); err == nil {
+		t.Error("Expected decreasing split points to be rejected")
+	}
+	if _, err := router.PreSplitAt(nil); err == nil {
+		t.Error("Expected no split points to be rejected")
+	}
+
+	chunks, err := router.PreSplitAt([]interface{}{int64(20), int64(40), int64(60)})
+	if err != nil {
+		t.Fatalf("PreSplitAt failed: %v", err)
+	}
+	if len(chunks) != 4 {
+		t.Fatalf("Expected 4 chunks, got %d", len(chunks))
+	}
+
+	for age, expected := range map[int64]ShardID{10: "shard-1", 20: "shard-2", 45: "shard-1", 99: "shard-2"} {
+		shard, err := router.Route(map[string]interface{}{"age": age})
+		if err != nil {
+			t.Fatalf("Failed to route age %d: %v", age, err)
+		}
+		if shard.ID != expected {
+			t.Errorf("Expected age %d on %s, got %s", age, expected, shard.ID)
+		}
+	}
+
+	if _, err := router.PreSplit(4); err == nil {
+		t.Error("Expected PreSplit to require hash sharding")
+	}
+	if _, err := router.PreSplitAt([]interface{}{int64(80)}); err == nil {
+		t.Error("Expected pre-splitting a router with chunks to fail")
+	}
+}
+
+func TestShardRouterPreSplitWithoutShards(t *testing.T) {
+	router, _ := NewShardRouter(NewHashShardKey("user_id"))
+	if _, err := router.PreSplit(4); err == nil {
+		t.Error("Expected pre-splitting without shards to fail")
+	}
+	router.AddShard(NewShard("shard-1", nil, ""))
+	if _, err := router.PreSplit(0); err == nil {
+		t.Error("Expected zero chunks to be rejected")
+	}
+}
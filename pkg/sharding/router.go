@@ -3,6 +3,8 @@ package sharding
 import (
 	"fmt"
 	"sync"
+
+	"github.com/mnohosten/laura-db/pkg/metrics"
 )
 
 // ShardRouter routes operations to the appropriate shard
@@ -12,6 +14,10 @@ type ShardRouter struct {
 	chunkManager  *ChunkManager // For range-based sharding
 	numShards     int            // For hash-based sharding
 	shardList     []*Shard       // Ordered list for hash-based routing
+	nextTxnID     uint64         // Last distributed transaction ID handed out
+	configClient  *ConfigClient  // Access to sharding metadata, if configured
+	watching      bool           // Whether shards record writes for change streams
+	metrics       *metrics.MetricsCollector // Records two-phase commit metrics, if set
 	mu            sync.RWMutex
 }
 
@@ -34,6 +40,29 @@ func NewShardRouter(shardKey *ShardKey) (*ShardRouter, error) {
 	return sr, nil
 }
 
+// SetConfigClient sets the client the router uses to read and change
+// sharding metadata on a config replica set
+func (sr *ShardRouter) SetConfigClient(client *ConfigClient) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.configClient = client
+}
+
+// ConfigClient returns the router's config replica set client, or nil
+func (sr *ShardRouter) ConfigClient() *ConfigClient {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+	return sr.configClient
+}
+
+// SetMetricsCollector sets the collector cross-shard transactions report
+// their two-phase commit metrics to
+func (sr *ShardRouter) SetMetricsCollector(mc *metrics.MetricsCollector) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.metrics = mc
+}
+
 // AddShard adds a new shard to the router
 func (sr *ShardRouter) AddShard(shard *Shard) error {
 	sr.mu.Lock()
@@ -43,6 +72,13 @@ func (sr *ShardRouter) AddShard(shard *Shard) error {
 		return fmt.Errorf("shard already exists: %s", shard.ID)
 	}
 
+	// Open change streams see the shard's writes from the start
+	if sr.watching && shard.Database != nil {
+		if _, err := shard.Database.ChangeOplog(); err != nil {
+			return fmt.Errorf("failed to open change oplog of shard %s: %w", shard.ID, err)
+		}
+	}
+
 	sr.shards[shard.ID] = shard
 
 	// Update shard list for hash-based sharding
@@ -170,6 +206,20 @@ func (sr *ShardRouter) routeHash(shardKeyValue interface{}) (*Shard, error) {
 		return nil, fmt.Errorf("no shards available")
 	}
 
+	// Pre-split hash ranges map the hash position to a chunk
+	if sr.chunkManager != nil {
+		position := sr.shardKey.HashPosition(shardKeyValue)
+		chunk := sr.chunkManager.FindChunk(position)
+		if chunk == nil {
+			return nil, fmt.Errorf("no chunk found for hash position: %d", position)
+		}
+		shard, ok := sr.shards[chunk.ShardID]
+		if !ok {
+			return nil, fmt.Errorf("shard not found for chunk: %s", chunk.ShardID)
+		}
+		return shard, nil
+	}
+
 	// Compute hash of shard key value
 	hashValue := sr.shardKey.HashValue(shardKeyValue)
 
@@ -227,16 +277,17 @@ func (sr *ShardRouter) CreateChunk(shardID ShardID, minKey, maxKey interface{})
 	return sr.chunkManager.CreateChunk(shardID, minKey, maxKey)
 }
 
-// SplitChunk splits a chunk at the given split key
+// SplitChunk splits a chunk at the given split key. The chunks of hash
+// sharding, created by PreSplit, split at a hash position (see
+// ShardKey.HashPosition).
 func (sr *ShardRouter) SplitChunk(chunkID string, splitKey interface{}) (*Chunk, *Chunk, error) {
-	if sr.shardKey.Type != ShardKeyTypeRange {
-		return nil, nil, fmt.Errorf("not using range-based sharding")
-	}
-
 	sr.mu.Lock()
 	defer sr.mu.Unlock()
 
 	if sr.chunkManager == nil {
+		if sr.shardKey.Type != ShardKeyTypeRange {
+			return nil, nil, fmt.Errorf("not using range-based sharding or pre-split hash sharding")
+		}
 		return nil, nil, fmt.Errorf("chunk manager not initialized")
 	}
 
@@ -245,14 +296,13 @@ func (sr *ShardRouter) SplitChunk(chunkID string, splitKey interface{}) (*Chunk,
 
 // MoveChunk moves a chunk to a different shard
 func (sr *ShardRouter) MoveChunk(chunkID string, targetShardID ShardID) error {
-	if sr.shardKey.Type != ShardKeyTypeRange {
-		return fmt.Errorf("not using range-based sharding")
-	}
-
 	sr.mu.Lock()
 	defer sr.mu.Unlock()
 
 	if sr.chunkManager == nil {
+		if sr.shardKey.Type != ShardKeyTypeRange {
+			return fmt.Errorf("not using range-based sharding or pre-split hash sharding")
+		}
 		return fmt.Errorf("chunk manager not initialized")
 	}
 
@@ -264,22 +314,29 @@ func (sr *ShardRouter) MoveChunk(chunkID string, targetShardID ShardID) error {
 	return sr.chunkManager.MoveChunk(chunkID, targetShardID)
 }
 
-// GetChunks returns all chunks (for range-based sharding)
+// GetChunks returns all chunks (for range-based sharding, or hash-based
+// sharding once pre-split)
 func (sr *ShardRouter) GetChunks() []*Chunk {
-	if sr.shardKey.Type != ShardKeyTypeRange || sr.chunkManager == nil {
+	sr.mu.RLock()
+	chunkManager := sr.chunkManager
+	sr.mu.RUnlock()
+	if chunkManager == nil {
 		return nil
 	}
 
-	return sr.chunkManager.GetAllChunks()
+	return chunkManager.GetAllChunks()
 }
 
 // GetChunksForShard returns all chunks for a specific shard
 func (sr *ShardRouter) GetChunksForShard(shardID ShardID) []*Chunk {
-	if sr.shardKey.Type != ShardKeyTypeRange || sr.chunkManager == nil {
+	sr.mu.RLock()
+	chunkManager := sr.chunkManager
+	sr.mu.RUnlock()
+	if chunkManager == nil {
 		return nil
 	}
 
-	return sr.chunkManager.GetChunksForShard(shardID)
+	return chunkManager.GetChunksForShard(shardID)
 }
 
 // RouteQuery routes a query to the appropriate shards
@@ -325,8 +382,8 @@ func (sr *ShardRouter) Stats() map[string]interface{} {
 		"shards":       shardStats,
 	}
 
-	// Add chunk manager stats for range-based sharding
-	if sr.shardKey.Type == ShardKeyTypeRange && sr.chunkManager != nil {
+	// Add chunk manager stats for range-based and pre-split hash sharding
+	if sr.chunkManager != nil {
 		stats["chunk_manager"] = sr.chunkManager.Stats()
 	}
 
@@ -139,6 +139,21 @@ func (sk *ShardKey) HashValue(value interface{}) uint64 {
 	return h.Sum64()
 }
 
+// HashPosition places a shard key value in the hash space split into
+// chunks by ShardRouter.PreSplit. It mixes the bits of HashValue, whose
+// high bits barely vary between similar values such as consecutive
+// integers or ObjectIDs, so evenly-spaced chunks receive even shares.
+func (sk *ShardKey) HashPosition(value interface{}) uint64 {
+	// SplitMix64 finalizer
+	h := sk.HashValue(value)
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
+
 // HashValueMD5 computes an MD5 hash value (alternative hash function)
 // Some systems prefer MD5 for better distribution characteristics
 func (sk *ShardKey) HashValueMD5(value interface{}) uint64 {
@@ -225,6 +240,14 @@ func (sk *ShardKey) CompareValues(a, b interface{}) int {
 		}
 		return compareFloats(va, vb)
 
+	case uint64:
+		// Hash values, bounding pre-split hash chunks
+		vb, ok := b.(uint64)
+		if !ok {
+			return compareStrings(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+		}
+		return compareUints(va, vb)
+
 	case document.ObjectID:
 		vb, ok := b.(document.ObjectID)
 		if !ok {
@@ -294,6 +317,16 @@ func compareInts(a, b int64) int {
 	return 0
 }
 
+func compareUints(a, b uint64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
 func compareFloats(a, b float64) int {
 	if a < b {
 		return -1
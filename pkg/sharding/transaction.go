@@ -0,0 +1,229 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mnohosten/laura-db/pkg/database"
+	"github.com/mnohosten/laura-db/pkg/distributed"
+	"github.com/mnohosten/laura-db/pkg/document"
+	"github.com/mnohosten/laura-db/pkg/mvcc"
+)
+
+// ErrTransactionFinished is returned when using a shard transaction that was
+// already committed or aborted
+var ErrTransactionFinished = errors.New("shard transaction already committed or aborted")
+
+// ShardTransaction is a transaction over the shards of a router. Each
+// operation is routed by shard key to a session on the owning shard, and
+// Commit applies the writes on every shard or on none: a transaction that
+// wrote to a single shard commits that shard's session directly, one that
+// wrote to several enlists each of them as a participant of a two-phase
+// commit. Like a database session, it is not safe for concurrent use.
+type ShardTransaction struct {
+	router       *ShardRouter
+	txnID        mvcc.TxnID
+	timeout      time.Duration
+	participants map[ShardID]*distributed.DatabaseParticipant
+	sessions     map[ShardID]*database.Session
+	finished     bool
+}
+
+// StartTransaction starts a transaction over the router's shards. timeout
+// bounds each phase of a two-phase commit (0 uses the coordinator default).
+func (sr *ShardRouter) StartTransaction(timeout time.Duration) *ShardTransaction {
+	return &ShardTransaction{
+		router:       sr,
+		txnID:        mvcc.TxnID(atomic.AddUint64(&sr.nextTxnID, 1)),
+		timeout:      timeout,
+		participants: make(map[ShardID]*distributed.DatabaseParticipant),
+		sessions:     make(map[ShardID]*database.Session),
+	}
+}
+
+// ID returns the distributed transaction ID
+func (t *ShardTransaction) ID() mvcc.TxnID {
+	return t.txnID
+}
+
+// session returns the transaction's session on a shard, starting it on
+// first use
+func (t *ShardTransaction) session(shard *Shard) *database.Session {
+	if session, exists := t.sessions[shard.ID]; exists {
+		return session
+	}
+	participant := distributed.NewDatabaseParticipant(string(shard.ID), shard.Database)
+	t.participants[shard.ID] = participant
+	t.sessions[shard.ID] = participant.StartTransaction(t.txnID)
+	return t.sessions[shard.ID]
+}
+
+// InsertOne inserts a document on the shard owning its shard key
+func (t *ShardTransaction) InsertOne(collection string, doc map[string]interface{}) (string, error) {
+	if t.finished {
+		return "", ErrTransactionFinished
+	}
+
+	shard, err := t.router.Route(doc)
+	if err != nil {
+		return "", fmt.Errorf("failed to route document: %w", err)
+	}
+	return t.session(shard).InsertOne(collection, doc)
+}
+
+// FindOne finds a document matching the filter. A filter with the full
+// shard key reads one shard; otherwise every shard is searched.
+func (t *ShardTransaction) FindOne(collection string, filter map[string]interface{}) (*document.Document, error) {
+	if t.finished {
+		return nil, ErrTransactionFinished
+	}
+
+	_, doc, err := t.findOne(collection, filter)
+	return doc, err
+}
+
+// UpdateOne updates a document matching the filter on the shard holding it.
+// Updates can't change the shard key, since that would move the document
+// to another shard.
+func (t *ShardTransaction) UpdateOne(collection string, filter map[string]interface{}, update map[string]interface{}) error {
+	if t.finished {
+		return ErrTransactionFinished
+	}
+
+	for _, fields := range update {
+		if fieldMap, ok := fields.(map[string]interface{}); ok {
+			for field := range fieldMap {
+				if t.changesShardKey(field) {
+					return fmt.Errorf("cannot update shard key field %s", field)
+				}
+			}
+		}
+	}
+
+	shard, _, err := t.findOne(collection, filter)
+	if err != nil {
+		return err
+	}
+	return t.session(shard).UpdateOne(collection, filter, update)
+}
+
+// DeleteOne deletes a document matching the filter from the shard holding it
+func (t *ShardTransaction) DeleteOne(collection string, filter map[string]interface{}) error {
+	if t.finished {
+		return ErrTransactionFinished
+	}
+
+	shard, _, err := t.findOne(collection, filter)
+	if err != nil {
+		return err
+	}
+	return t.session(shard).DeleteOne(collection, filter)
+}
+
+// findOne returns a document matching the filter and the shard holding it
+func (t *ShardTransaction) findOne(collection string, filter map[string]interface{}) (*Shard, *document.Document, error) {
+	shards, err := t.router.RouteQuery(filter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to route query: %w", err)
+	}
+
+	// Search shards in a stable order
+	sort.Slice(shards, func(i, j int) bool {
+		return shards[i].ID < shards[j].ID
+	})
+	for _, shard := range shards {
+		doc, err := t.session(shard).FindOne(collection, filter)
+		if err == nil {
+			return shard, doc, nil
+		}
+		if !errors.Is(err, database.ErrDocumentNotFound) {
+			return nil, nil, fmt.Errorf("shard %s: %w", shard.ID, err)
+		}
+	}
+	return nil, nil, database.ErrDocumentNotFound
+}
+
+// changesShardKey reports whether an update of field changes the shard key
+func (t *ShardTransaction) changesShardKey(field string) bool {
+	for _, keyField := range t.router.shardKey.Fields {
+		if field == keyField || strings.HasPrefix(field, keyField+".") || strings.HasPrefix(keyField, field+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// WrittenShards returns the shards the transaction has written to, in order
+func (t *ShardTransaction) WrittenShards() []ShardID {
+	written := make([]ShardID, 0, len(t.sessions))
+	for id, session := range t.sessions {
+		if session.HasWrites() {
+			written = append(written, id)
+		}
+	}
+	sort.Slice(written, func(i, j int) bool {
+		return written[i] < written[j]
+	})
+	return written
+}
+
+// Commit applies the transaction's writes on all shards, or on none if any
+// shard can't commit. Shards the transaction only read from are released.
+func (t *ShardTransaction) Commit(ctx context.Context) error {
+	if t.finished {
+		return ErrTransactionFinished
+	}
+	t.finished = true
+
+	written := t.WrittenShards()
+	for id, participant := range t.participants {
+		if !t.sessions[id].HasWrites() {
+			participant.Abort(ctx, t.txnID)
+		}
+	}
+
+	switch len(written) {
+	case 0:
+		return nil
+	case 1:
+		// Single-shard fast path: the shard's own commit is atomic
+		if err := t.participants[written[0]].Commit(ctx, t.txnID); err != nil {
+			t.sessions[written[0]].AbortTransaction()
+			return fmt.Errorf("shard %s: %w", written[0], err)
+		}
+		return nil
+	}
+
+	// Participants are distinct shards, so adding them can't fail
+	coordinator := distributed.NewCoordinator(t.txnID, t.timeout)
+	t.router.mu.RLock()
+	if t.router.metrics != nil {
+		coordinator.SetMetricsCollector(t.router.metrics)
+	}
+	t.router.mu.RUnlock()
+	for _, id := range written {
+		coordinator.AddParticipant(t.participants[id])
+	}
+	return coordinator.Execute(ctx)
+}
+
+// Abort discards the transaction's writes on all shards
+func (t *ShardTransaction) Abort(ctx context.Context) error {
+	if t.finished {
+		return ErrTransactionFinished
+	}
+	t.finished = true
+
+	var errs []error
+	for id, participant := range t.participants {
+		if err := participant.Abort(ctx, t.txnID); err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
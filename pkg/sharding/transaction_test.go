@@ -0,0 +1,203 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/mnohosten/laura-db/pkg/database"
+)
+
+// setupTransactionRouter creates a hash-sharded router over two shards with
+// one account on each, and returns the user IDs of the two accounts
+func setupTransactionRouter(t *testing.T, name string) (*ShardRouter, string, string) {
+	t.Helper()
+
+	router, _ := NewShardRouter(NewHashShardKey("user_id"))
+	for i := 1; i <= 2; i++ {
+		dir := fmt.Sprintf("/tmp/test-txn-%s-shard%d", name, i)
+		os.RemoveAll(dir)
+		db, err := database.Open(database.DefaultConfig(dir))
+		if err != nil {
+			t.Fatalf("failed to open shard database: %v", err)
+		}
+		t.Cleanup(func() {
+			db.Close()
+			os.RemoveAll(dir)
+		})
+		router.AddShard(NewShard(ShardID(fmt.Sprintf("shard-%d", i)), db, ""))
+	}
+
+	// Find two users that live on different shards
+	var users []string
+	var first *Shard
+	for i := 0; len(users) < 2; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		shard, err := router.Route(map[string]interface{}{"user_id": userID})
+		if err != nil {
+			t.Fatalf("failed to route: %v", err)
+		}
+		if first == nil || shard != first {
+			first = shard
+			users = append(users, userID)
+			doc := map[string]interface{}{"user_id": userID, "balance": int64(100)}
+			if _, err := shard.Database.Collection("accounts").InsertOne(doc); err != nil {
+				t.Fatalf("failed to insert account: %v", err)
+			}
+		}
+	}
+
+	return router, users[0], users[1]
+}
+
+// balance reads a user's committed balance from its shard
+func balance(t *testing.T, router *ShardRouter, userID string) int64 {
+	t.Helper()
+
+	shard, _ := router.Route(map[string]interface{}{"user_id": userID})
+	doc, err := shard.Database.Collection("accounts").FindOne(map[string]interface{}{"user_id": userID})
+	if err != nil {
+		t.Fatalf("failed to read account %s: %v", userID, err)
+	}
+	value, _ := doc.Get("balance")
+	return value.(int64)
+}
+
+// transfer moves an amount between two accounts in a shard transaction
+func transfer(t *testing.T, txn *ShardTransaction, from, to string, amount int64) {
+	t.Helper()
+
+	debit := map[string]interface{}{"$inc": map[string]interface{}{"balance": -amount}}
+	if err := txn.UpdateOne("accounts", map[string]interface{}{"user_id": from}, debit); err != nil {
+		t.Fatalf("failed to debit: %v", err)
+	}
+	credit := map[string]interface{}{"$inc": map[string]interface{}{"balance": amount}}
+	if err := txn.UpdateOne("accounts", map[string]interface{}{"user_id": to}, credit); err != nil {
+		t.Fatalf("failed to credit: %v", err)
+	}
+}
+
+func TestShardTransactionCrossShardCommit(t *testing.T) {
+	router, alice, bob := setupTransactionRouter(t, "commit")
+
+	txn := router.StartTransaction(0)
+	transfer(t, txn, alice, bob, 30)
+
+	if shards := txn.WrittenShards(); len(shards) != 2 {
+		t.Fatalf("expected writes on 2 shards, got %v", shards)
+	}
+	if err := txn.Commit(context.Background()); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+
+	if got := balance(t, router, alice); got != 70 {
+		t.Errorf("expected balance 70, got %d", got)
+	}
+	if got := balance(t, router, bob); got != 130 {
+		t.Errorf("expected balance 130, got %d", got)
+	}
+
+	if err := txn.Commit(context.Background()); !errors.Is(err, ErrTransactionFinished) {
+		t.Errorf("expected ErrTransactionFinished, got %v", err)
+	}
+}
+
+func TestShardTransactionAllOrNothing(t *testing.T) {
+	router, alice, bob := setupTransactionRouter(t, "abort")
+
+	txn := router.StartTransaction(0)
+	transfer(t, txn, alice, bob, 30)
+
+	// Bob's shard can't prepare: another transaction changed his account
+	bobShard, _ := router.Route(map[string]interface{}{"user_id": bob})
+	other := bobShard.Database.StartSession()
+	update := map[string]interface{}{"$set": map[string]interface{}{"flagged": true}}
+	if err := other.UpdateOne("accounts", map[string]interface{}{"user_id": bob}, update); err != nil {
+		t.Fatalf("failed to update in session: %v", err)
+	}
+	if err := other.CommitTransaction(); err != nil {
+		t.Fatalf("failed to commit session: %v", err)
+	}
+
+	if err := txn.Commit(context.Background()); err == nil {
+		t.Fatal("expected commit to fail")
+	}
+
+	// Neither side of the transfer was applied
+	if got := balance(t, router, alice); got != 100 {
+		t.Errorf("expected balance 100, got %d", got)
+	}
+	if got := balance(t, router, bob); got != 100 {
+		t.Errorf("expected balance 100, got %d", got)
+	}
+}
+
+func TestShardTransactionReadOnlyShardAborts(t *testing.T) {
+	router, alice, bob := setupTransactionRouter(t, "readonly")
+
+	txn := router.StartTransaction(0)
+	transfer(t, txn, alice, bob, 30)
+
+	bobShard, _ := router.Route(map[string]interface{}{"user_id": bob})
+	bobShard.Database.SetReadOnly(true)
+
+	if err := txn.Commit(context.Background()); err == nil {
+		t.Fatal("expected commit to fail")
+	}
+	bobShard.Database.SetReadOnly(false)
+
+	if got := balance(t, router, alice); got != 100 {
+		t.Errorf("expected balance 100, got %d", got)
+	}
+}
+
+func TestShardTransactionSingleShard(t *testing.T) {
+	router, alice, bob := setupTransactionRouter(t, "single")
+
+	txn := router.StartTransaction(0)
+	debit := map[string]interface{}{"$inc": map[string]interface{}{"balance": int64(-10)}}
+	if err := txn.UpdateOne("accounts", map[string]interface{}{"user_id": alice}, debit); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	// Reads don't enlist a shard in the commit
+	if _, err := txn.FindOne("accounts", map[string]interface{}{"user_id": bob}); err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+
+	if shards := txn.WrittenShards(); len(shards) != 1 {
+		t.Fatalf("expected writes on 1 shard, got %v", shards)
+	}
+	if err := txn.Commit(context.Background()); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	if got := balance(t, router, alice); got != 90 {
+		t.Errorf("expected balance 90, got %d", got)
+	}
+}
+
+func TestShardTransactionInsertAndAbort(t *testing.T) {
+	router, alice, _ := setupTransactionRouter(t, "insert")
+
+	txn := router.StartTransaction(0)
+	if _, err := txn.InsertOne("accounts", map[string]interface{}{"user_id": "new-user", "balance": int64(0)}); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+	if _, err := txn.InsertOne("accounts", map[string]interface{}{"balance": int64(0)}); err == nil {
+		t.Error("expected insert without shard key to fail")
+	}
+	update := map[string]interface{}{"$set": map[string]interface{}{"user_id": "renamed"}}
+	if err := txn.UpdateOne("accounts", map[string]interface{}{"user_id": alice}, update); err == nil {
+		t.Error("expected shard key update to fail")
+	}
+
+	if err := txn.Abort(context.Background()); err != nil {
+		t.Fatalf("abort failed: %v", err)
+	}
+
+	shard, _ := router.Route(map[string]interface{}{"user_id": "new-user"})
+	if _, err := shard.Database.Collection("accounts").FindOne(map[string]interface{}{"user_id": "new-user"}); err == nil {
+		t.Error("expected aborted insert to be discarded")
+	}
+}
@@ -13,7 +13,7 @@ type BufferPool struct {
 	pages     map[PageID]*bufferFrame
 	lruList   *list.List
 	mu        sync.RWMutex
-	diskMgr   *DiskManager
+	diskMgr   PageStore
 	evictions int
 	hits      int
 	misses    int
@@ -26,7 +26,7 @@ type bufferFrame struct {
 }
 
 // NewBufferPool creates a new buffer pool
-func NewBufferPool(capacity int, diskMgr *DiskManager) *BufferPool {
+func NewBufferPool(capacity int, diskMgr PageStore) *BufferPool {
 	return &BufferPool{
 		capacity: capacity,
 		pages:    make(map[PageID]*bufferFrame, capacity),
@@ -118,7 +118,7 @@ func (bp *BufferPool) NewPage() (*Page, error) {
 		return nil, fmt.Errorf("failed to allocate page: %w", err)
 	}
 
-	page := NewPage(pageID, PageTypeData)
+	page := NewPageOfSize(pageID, PageTypeData, bp.diskMgr.PageSize())
 	page.MarkDirty()
 
 	// Add to buffer pool
@@ -214,6 +214,34 @@ func (bp *BufferPool) evictPage() error {
 	return fmt.Errorf("no unpinned pages available for eviction")
 }
 
+// Resize changes the number of pages the buffer pool holds. Shrinking it
+// evicts unpinned pages, flushing dirty ones, down to the new capacity;
+// pinned pages stay until they are unpinned and evicted by later fetches.
+func (bp *BufferPool) Resize(capacity int) error {
+	if capacity <= 0 {
+		return fmt.Errorf("buffer pool capacity must be positive, got %d", capacity)
+	}
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	bp.capacity = capacity
+	for len(bp.pages) > bp.capacity {
+		if err := bp.evictPage(); err != nil {
+			// Only pinned pages are left
+			break
+		}
+	}
+	return nil
+}
+
+// Capacity returns the number of pages the buffer pool holds
+func (bp *BufferPool) Capacity() int {
+	bp.mu.RLock()
+	defer bp.mu.RUnlock()
+	return bp.capacity
+}
+
 // DeletePage removes a page from the buffer pool and disk
 func (bp *BufferPool) DeletePage(pageID PageID) error {
 	bp.mu.Lock()
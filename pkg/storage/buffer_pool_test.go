@@ -365,3 +365,43 @@ func TestBufferPoolLRUOrdering(t *testing.T) {
 		t.Error("Expected page1 and page3 to still be in buffer")
 	}
 }
+
+func TestBufferPoolResize(t *testing.T) {
+	diskMgr, err := NewDiskManager(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create disk manager: %v", err)
+	}
+	defer diskMgr.Close()
+
+	bp := NewBufferPool(4, diskMgr)
+	pages := make([]*Page, 4)
+	for i := range pages {
+		pages[i], _ = bp.NewPage()
+	}
+	for _, page := range pages[1:] {
+		bp.UnpinPage(page.ID, true)
+	}
+
+	// Shrinking evicts the unpinned pages, but not the pinned one
+	if err := bp.Resize(1); err != nil {
+		t.Fatalf("Resize failed: %v", err)
+	}
+	if size := bp.Stats()["size"].(int); size != 1 {
+		t.Errorf("Expected 1 page left, got %d", size)
+	}
+	if capacity := bp.Capacity(); capacity != 1 {
+		t.Errorf("Expected capacity 1, got %d", capacity)
+	}
+
+	// The evicted dirty pages were flushed
+	bp.UnpinPage(pages[0].ID, false)
+	page, err := bp.FetchPage(pages[1].ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch evicted page: %v", err)
+	}
+	bp.UnpinPage(page.ID, false)
+
+	if err := bp.Resize(0); err == nil {
+		t.Error("Expected a zero capacity to be rejected")
+	}
+}
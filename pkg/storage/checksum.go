@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+// ChecksumSize is the size of a page checksum entry in the checksum file
+const ChecksumSize = 4
+
+// castagnoliTable is the CRC32C polynomial table used for page checksums
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// PageChecksum computes the CRC32C checksum of a serialized page
+func PageChecksum(data []byte) uint32 {
+	return crc32.Checksum(data, castagnoliTable)
+}
+
+// ChecksumError is returned when a page read from disk does not match the
+// checksum recorded when it was written
+type ChecksumError struct {
+	PageID   PageID
+	Expected uint32
+	Actual   uint32
+}
+
+// Error implements the error interface
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch on page %d: expected %08x, got %08x", e.PageID, e.Expected, e.Actual)
+}
+
+// Checksums are kept in a sidecar file next to the data file (one 4-byte
+// entry per page, indexed by page ID) so the on-disk page layout is unchanged.
+// A zero entry means no checksum was recorded, e.g. for pages written before
+// checksums were introduced; such pages are not verified.
+
+// openChecksumFile opens (or creates) the checksum sidecar for a data file
+func openChecksumFile(dataPath string) (*os.File, error) {
+	file, err := os.OpenFile(dataPath+".crc", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checksum file: %w", err)
+	}
+	return file, nil
+}
+
+// writeChecksum records the checksum for a page in a checksum file
+// Must be called with the page store's lock held
+func writeChecksum(file *os.File, pageID PageID, checksum uint32) error {
+	buf := make([]byte, ChecksumSize)
+	binary.LittleEndian.PutUint32(buf, checksum)
+
+	if _, err := file.WriteAt(buf, int64(pageID)*ChecksumSize); err != nil {
+		return fmt.Errorf("failed to write checksum for page %d: %w", pageID, err)
+	}
+	return nil
+}
+
+// readChecksum returns the recorded checksum for a page (0 if none)
+// Must be called with the page store's lock held
+func readChecksum(file *os.File, pageID PageID) (uint32, error) {
+	buf := make([]byte, ChecksumSize)
+
+	n, err := file.ReadAt(buf, int64(pageID)*ChecksumSize)
+	if n < ChecksumSize {
+		// Past the end of the checksum file: nothing recorded
+		return 0, nil
+	}
+	if err != nil && err.Error() != "EOF" {
+		return 0, fmt.Errorf("failed to read checksum for page %d: %w", pageID, err)
+	}
+
+	return binary.LittleEndian.Uint32(buf), nil
+}
+
+// verifyChecksum compares raw page bytes against the checksum recorded in a
+// checksum file
+// Must be called with the page store's lock held
+func verifyChecksum(file *os.File, pageID PageID, data []byte) error {
+	expected, err := readChecksum(file, pageID)
+	if err != nil {
+		return err
+	}
+	if expected == 0 {
+		return nil
+	}
+
+	if actual := PageChecksum(data); actual != expected {
+		return &ChecksumError{PageID: pageID, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// writeQuarantineRecord appends the raw bytes of a page, prefixed with its
+// 4-byte ID, to the quarantine file next to a data file
+func writeQuarantineRecord(dataPath string, pageID PageID, data []byte) error {
+	quarantine, err := os.OpenFile(dataPath+".quarantine", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open quarantine file: %w", err)
+	}
+
+	record := make([]byte, 4+len(data))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(pageID))
+	copy(record[4:], data)
+
+	_, writeErr := quarantine.Write(record)
+	closeErr := quarantine.Close()
+	if writeErr != nil {
+		return fmt.Errorf("failed to write quarantine record: %w", writeErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to close quarantine file: %w", closeErr)
+	}
+	return nil
+}
+
+// readRawPage reads the raw bytes of a page without verification
+// Returns nil data if the page lies beyond the end of the file
+// Must be called with dm.mu held
+func (dm *DiskManager) readRawPage(pageID PageID) ([]byte, error) {
+	data := make([]byte, dm.pageSize)
+
+	n, err := dm.dataFile.ReadAt(data, dm.pageOffset(pageID))
+	if err != nil && err.Error() != "EOF" {
+		return nil, fmt.Errorf("failed to read page %d: %w", pageID, err)
+	}
+	if n < dm.pageSize {
+		return nil, nil
+	}
+
+	return data, nil
+}
+
+// VerifyPage checks a single page against its recorded checksum
+func (dm *DiskManager) VerifyPage(pageID PageID) error {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	data, err := dm.readRawPage(pageID)
+	if err != nil || data == nil {
+		return err
+	}
+
+	return verifyChecksum(dm.checksumFile, pageID, data)
+}
+
+// VerifyAllPages reads every allocated page and returns a ChecksumError for
+// each page whose contents no longer match the recorded checksum
+func (dm *DiskManager) VerifyAllPages() ([]*ChecksumError, error) {
+	dm.mu.Lock()
+	totalPages := dm.nextPageID
+	dm.mu.Unlock()
+
+	mismatches := make([]*ChecksumError, 0)
+	for pageID := PageID(0); pageID < totalPages; pageID++ {
+		err := dm.VerifyPage(pageID)
+		if err == nil {
+			continue
+		}
+
+		checksumErr, ok := err.(*ChecksumError)
+		if !ok {
+			return mismatches, err
+		}
+		mismatches = append(mismatches, checksumErr)
+	}
+
+	return mismatches, nil
+}
+
+// QuarantinePage moves a corrupt page out of service. The raw page bytes are
+// appended to a quarantine file (prefixed with the 4-byte page ID) for later
+// inspection, the page is overwritten with an empty data page and returned to
+// the free list. Callers are responsible for relocating any documents that
+// lived on the page.
+func (dm *DiskManager) QuarantinePage(pageID PageID) error {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if pageID >= dm.nextPageID {
+		return fmt.Errorf("invalid page ID: %d (next page ID: %d)", pageID, dm.nextPageID)
+	}
+
+	data, err := dm.readRawPage(pageID)
+	if err != nil {
+		return err
+	}
+
+	if data != nil {
+		if err := writeQuarantineRecord(dm.dataFile.Name(), pageID, data); err != nil {
+			return err
+		}
+	}
+
+	if err := dm.writePageInternal(NewPageOfSize(pageID, PageTypeData, dm.pageSize)); err != nil {
+		return fmt.Errorf("failed to reset quarantined page: %w", err)
+	}
+
+	if err := dm.pushFreePage(pageID); err != nil {
+		return fmt.Errorf("failed to free quarantined page: %w", err)
+	}
+
+	dm.quarantinedPages++
+	return nil
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// corruptPage flips a byte in the page body directly in the data file
+func corruptPage(t *testing.T, path string, pageID PageID) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open data file: %v", err)
+	}
+	defer file.Close()
+
+	offset := int64(pageID)*PageSize + PageHeaderSize + 10
+	buf := make([]byte, 1)
+	if _, err := file.ReadAt(buf, offset); err != nil {
+		t.Fatalf("Failed to read data file: %v", err)
+	}
+	buf[0] ^= 0xFF
+	if _, err := file.WriteAt(buf, offset); err != nil {
+		t.Fatalf("Failed to corrupt data file: %v", err)
+	}
+}
+
+func TestPageChecksumVerification(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+
+	dm, err := NewDiskManager(path)
+	if err != nil {
+		t.Fatalf("Failed to create disk manager: %v", err)
+	}
+	defer dm.Close()
+
+	for i := 0; i < 3; i++ {
+		pageID, err := dm.AllocatePage()
+		if err != nil {
+			t.Fatalf("Failed to allocate page: %v", err)
+		}
+		page := NewPage(pageID, PageTypeData)
+		copy(page.Data, []byte("checksummed page contents"))
+		if err := dm.WritePage(page); err != nil {
+			t.Fatalf("Failed to write page: %v", err)
+		}
+	}
+
+	mismatches, err := dm.VerifyAllPages()
+	if err != nil {
+		t.Fatalf("VerifyAllPages failed: %v", err)
+	}
+	if len(mismatches) != 0 {
+		t.Fatalf("Expected no mismatches, got %d", len(mismatches))
+	}
+
+	corruptPage(t, path, 1)
+
+	if err := dm.VerifyPage(0); err != nil {
+		t.Errorf("Expected page 0 to verify, got %v", err)
+	}
+
+	_, err = dm.ReadPage(1)
+	checksumErr, ok := err.(*ChecksumError)
+	if !ok {
+		t.Fatalf("Expected *ChecksumError from ReadPage, got %v", err)
+	}
+	if checksumErr.PageID != 1 {
+		t.Errorf("Expected page ID 1, got %d", checksumErr.PageID)
+	}
+
+	mismatches, err = dm.VerifyAllPages()
+	if err != nil {
+		t.Fatalf("VerifyAllPages failed: %v", err)
+	}
+	if len(mismatches) != 1 || mismatches[0].PageID != 1 {
+		t.Fatalf("Expected a single mismatch on page 1, got %v", mismatches)
+	}
+
+	stats := dm.Stats()
+	if stats["checksum_failures"].(int64) != 1 {
+		t.Errorf("Expected 1 checksum failure, got %v", stats["checksum_failures"])
+	}
+}
+
+func TestQuarantinePage(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+
+	dm, err := NewDiskManager(path)
+	if err != nil {
+		t.Fatalf("Failed to create disk manager: %v", err)
+	}
+	defer dm.Close()
+
+	pageID, err := dm.AllocatePage()
+	if err != nil {
+		t.Fatalf("Failed to allocate page: %v", err)
+	}
+	if err := dm.WritePage(NewPage(pageID, PageTypeData)); err != nil {
+		t.Fatalf("Failed to write page: %v", err)
+	}
+
+	corruptPage(t, path, pageID)
+
+	if err := dm.QuarantinePage(pageID); err != nil {
+		t.Fatalf("QuarantinePage failed: %v", err)
+	}
+
+	if err := dm.VerifyPage(pageID); err != nil {
+		t.Errorf("Expected quarantined page to verify after reset, got %v", err)
+	}
+
+	info, err := os.Stat(path + ".quarantine")
+	if err != nil {
+		t.Fatalf("Expected quarantine file: %v", err)
+	}
+	if info.Size() != 4+PageSize {
+		t.Errorf("Expected quarantine record of %d bytes, got %d", 4+PageSize, info.Size())
+	}
+
+	stats := dm.Stats()
+	if stats["quarantined_pages"].(int64) != 1 {
+		t.Errorf("Expected 1 quarantined page, got %v", stats["quarantined_pages"])
+	}
+
+	// The page is reused by the next allocation
+	reused, err := dm.AllocatePage()
+	if err != nil {
+		t.Fatalf("Failed to allocate page: %v", err)
+	}
+	if reused != pageID {
+		t.Errorf("Expected quarantined page %d to be reused, got %d", pageID, reused)
+	}
+
+	if err := dm.QuarantinePage(100); err == nil {
+		t.Error("Expected error quarantining unallocated page")
+	}
+}
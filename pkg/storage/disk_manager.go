@@ -8,21 +8,43 @@ import (
 
 // DiskManager handles physical disk I/O operations
 type DiskManager struct {
-	dataFile     *os.File
-	nextPageID   PageID
-	freePageList *FreePageList
-	mu           sync.Mutex
-	totalReads   int64
-	totalWrites  int64
+	dataFile         *os.File
+	checksumFile     *os.File // CRC32C checksum per page (see checksum.go)
+	pageSize         int
+	dataOffset       int64 // Offset of page 0, after the file header if any
+	nextPageID       PageID
+	freePageList     *FreePageList
+	mu               sync.Mutex
+	totalReads       int64
+	totalWrites      int64
+	checksumFailures int64
+	quarantinedPages int64
+	imageLog         PageImageLog // Receives page images ahead of writes (see page_image.go)
 }
 
-// NewDiskManager creates a new disk manager
+// NewDiskManager creates a new disk manager. A new data file gets the
+// default page size; an existing one keeps the size it was created with.
 func NewDiskManager(path string) (*DiskManager, error) {
+	return NewDiskManagerWithPageSize(path, 0)
+}
+
+// NewDiskManagerWithPageSize creates a disk manager whose pages are pageSize
+// bytes (see ValidatePageSize). A new data file is created with that size;
+// opening an existing file created with another size fails with
+// ErrPageSizeMismatch. A pageSize of 0 accepts the size of an existing file
+// and uses PageSize for a new one.
+func NewDiskManagerWithPageSize(path string, pageSize int) (*DiskManager, error) {
 	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open data file: %w", err)
 	}
 
+	pageSize, dataOffset, err := openPageFile(file, pageSize)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
 	// Get file size to determine next page ID
 	fileInfo, err := file.Stat()
 	if err != nil {
@@ -30,10 +52,19 @@ func NewDiskManager(path string) (*DiskManager, error) {
 		return nil, fmt.Errorf("failed to stat data file: %w", err)
 	}
 
-	nextPageID := PageID(fileInfo.Size() / PageSize)
+	nextPageID := PageID((fileInfo.Size() - dataOffset) / int64(pageSize))
+
+	checksumFile, err := openChecksumFile(path)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
 
 	dm := &DiskManager{
 		dataFile:     file,
+		checksumFile: checksumFile,
+		pageSize:     pageSize,
+		dataOffset:   dataOffset,
 		nextPageID:   nextPageID,
 		freePageList: NewFreePageList(),
 	}
@@ -61,8 +92,8 @@ func (dm *DiskManager) ReadPage(pageID PageID) (*Page, error) {
 // readPageInternal reads a page from disk without acquiring the lock
 // Must be called with dm.mu held
 func (dm *DiskManager) readPageInternal(pageID PageID) (*Page, error) {
-	offset := int64(pageID) * PageSize
-	data := make([]byte, PageSize)
+	offset := dm.pageOffset(pageID)
+	data := make([]byte, dm.pageSize)
 
 	n, err := dm.dataFile.ReadAt(data, offset)
 	if err != nil && err.Error() != "EOF" {
@@ -70,11 +101,16 @@ func (dm *DiskManager) readPageInternal(pageID PageID) (*Page, error) {
 	}
 
 	// If file is smaller, this is a new page
-	if n < PageSize {
-		return NewPage(pageID, PageTypeData), nil
+	if n < dm.pageSize {
+		return NewPageOfSize(pageID, PageTypeData, dm.pageSize), nil
 	}
 
-	page := NewPage(pageID, PageTypeData)
+	if err := verifyChecksum(dm.checksumFile, pageID, data); err != nil {
+		dm.checksumFailures++
+		return nil, err
+	}
+
+	page := NewPageOfSize(pageID, PageTypeData, dm.pageSize)
 	if err := page.Deserialize(data); err != nil {
 		return nil, fmt.Errorf("failed to deserialize page %d: %w", pageID, err)
 	}
@@ -91,16 +127,59 @@ func (dm *DiskManager) WritePage(page *Page) error {
 	return dm.writePageInternal(page)
 }
 
-// writePageInternal writes a page to disk without acquiring the lock
+// WritePages writes a batch of pages to disk under a single lock acquisition.
+// Their images are logged together, ahead of the first write.
+func (dm *DiskManager) WritePages(pages []*Page) error {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if err := logPageImages(dm.imageLog, pages, dm.pageSize); err != nil {
+		return err
+	}
+	for _, page := range pages {
+		if err := dm.storePage(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetPageImageLog makes the disk manager hand the image of every page to
+// log before writing it (nil stops logging)
+func (dm *DiskManager) SetPageImageLog(log PageImageLog) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	dm.imageLog = log
+}
+
+// writePageInternal logs the image of a page and writes it to disk without
+// acquiring the lock
 // Must be called with dm.mu held
 func (dm *DiskManager) writePageInternal(page *Page) error {
-	offset := int64(page.ID) * PageSize
-	data := page.Serialize()
+	if err := logPageImages(dm.imageLog, []*Page{page}, dm.pageSize); err != nil {
+		return err
+	}
+	return dm.storePage(page)
+}
+
+// storePage writes a page and its checksum to disk
+// Must be called with dm.mu held
+func (dm *DiskManager) storePage(page *Page) error {
+	if page.Size() > dm.pageSize {
+		return fmt.Errorf("page %d holds %d bytes, more than the %d-byte page size", page.ID, PageHeaderSize+len(page.Data), dm.pageSize)
+	}
+	offset := dm.pageOffset(page.ID)
+	data := page.serializeAs(dm.pageSize)
 
 	if _, err := dm.dataFile.WriteAt(data, offset); err != nil {
 		return fmt.Errorf("failed to write page %d: %w", page.ID, err)
 	}
 
+	if err := writeChecksum(dm.checksumFile, page.ID, PageChecksum(data)); err != nil {
+		return err
+	}
+
 	dm.totalWrites++
 	return nil
 }
@@ -160,6 +239,10 @@ func (dm *DiskManager) Sync() error {
 	dm.mu.Lock()
 	defer dm.mu.Unlock()
 
+	if err := dm.checksumFile.Sync(); err != nil {
+		return err
+	}
+
 	return dm.dataFile.Sync()
 }
 
@@ -172,19 +255,41 @@ func (dm *DiskManager) Close() error {
 		return err
 	}
 
+	if err := dm.checksumFile.Sync(); err != nil {
+		return err
+	}
+
+	if err := dm.checksumFile.Close(); err != nil {
+		return err
+	}
+
 	return dm.dataFile.Close()
 }
 
+// PageSize returns the size of the pages in the data file
+func (dm *DiskManager) PageSize() int {
+	return dm.pageSize
+}
+
+// pageOffset returns the file offset of a page
+func (dm *DiskManager) pageOffset(pageID PageID) int64 {
+	return dm.dataOffset + int64(pageID)*int64(dm.pageSize)
+}
+
 // Stats returns disk manager statistics
 func (dm *DiskManager) Stats() map[string]interface{} {
 	dm.mu.Lock()
 	defer dm.mu.Unlock()
 
 	return map[string]interface{}{
-		"next_page_id": dm.nextPageID,
-		"free_pages":   dm.freePageList.PageCount,
-		"total_reads":  dm.totalReads,
-		"total_writes": dm.totalWrites,
+		"page_size":         dm.pageSize,
+		"next_page_id":      dm.nextPageID,
+		"free_pages":        dm.freePageList.PageCount,
+		"used_pages":        uint32(dm.nextPageID) - dm.freePageList.PageCount,
+		"total_reads":       dm.totalReads,
+		"total_writes":      dm.totalWrites,
+		"checksum_failures": dm.checksumFailures,
+		"quarantined_pages": dm.quarantinedPages,
 	}
 }
 
@@ -215,7 +320,7 @@ func (dm *DiskManager) pushFreePage(pageID PageID) error {
 		dm.nextPageID++
 
 		// Initialize it as a free list page
-		page := NewPage(freeListPageID, PageTypeFreeList)
+		page := NewPageOfSize(freeListPageID, PageTypeFreeList, dm.pageSize)
 		InitializeFreeListPage(page)
 
 		// Add the page being freed to this free list page
@@ -257,7 +362,7 @@ func (dm *DiskManager) pushFreePage(pageID PageID) error {
 
 	// Head page is full, need to create a new free list page
 	// Use the page being freed as the new head
-	newHeadPage := NewPage(pageID, PageTypeFreeList)
+	newHeadPage := NewPageOfSize(pageID, PageTypeFreeList, dm.pageSize)
 	InitializeFreeListPage(newHeadPage)
 
 	// Link the new head to the old head
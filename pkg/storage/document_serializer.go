@@ -10,7 +10,7 @@ const (
 	// MaxDocumentSize is the maximum size of a document (16MB, MongoDB-compatible)
 	MaxDocumentSize = 16 * 1024 * 1024
 
-	// MaxSinglePageDocumentSize is the maximum size that can fit in a single page
+	// MaxSinglePageDocumentSize is the maximum size that can fit in a single page of the default size
 	// Accounts for page header (16 bytes), slotted page header (12 bytes), and slot entry (5 bytes)
 	MaxSinglePageDocumentSize = PageSize - PageHeaderSize - SlottedPageHeaderSize - SlotEntrySize
 )
@@ -148,9 +148,9 @@ func (dpm *DocumentPageManager) InsertDocument(page *SlottedPage, doc *document.
 	}
 
 	// Check if document can fit in a single page
-	if len(data) > MaxSinglePageDocumentSize {
+	if limit := MaxDocumentSizeForPageSize(page.GetPage().Size()); len(data) > limit {
 		return 0, fmt.Errorf("document size %d bytes exceeds single page limit %d bytes (overflow pages not yet implemented)",
-			len(data), MaxSinglePageDocumentSize)
+			len(data), limit)
 	}
 
 	// Insert into slotted page
@@ -200,9 +200,9 @@ func (dpm *DocumentPageManager) UpdateDocument(page *SlottedPage, slotID uint16,
 	}
 
 	// Check if document can fit in a single page
-	if len(data) > MaxSinglePageDocumentSize {
+	if limit := MaxDocumentSizeForPageSize(page.GetPage().Size()); len(data) > limit {
 		return fmt.Errorf("document size %d bytes exceeds single page limit %d bytes (overflow pages not yet implemented)",
-			len(data), MaxSinglePageDocumentSize)
+			len(data), limit)
 	}
 
 	// Update slot in page
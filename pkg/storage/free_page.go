@@ -10,10 +10,15 @@ const (
 	FreePageHeaderSize = 8
 
 	// MaxFreePageEntries is the maximum number of free page IDs that can be stored in a single free list page
-	// (PageSize - PageHeaderSize - FreePageHeaderSize) / 4 bytes per PageID
+	// of the default size: (PageSize - PageHeaderSize - FreePageHeaderSize) / 4 bytes per PageID
 	MaxFreePageEntries = (PageSize - PageHeaderSize - FreePageHeaderSize) / 4
 )
 
+// freePageEntries returns the number of free page IDs a free list page holds
+func freePageEntries(page *Page) uint32 {
+	return uint32((len(page.Data) - FreePageHeaderSize) / 4)
+}
+
 // FreePageHeader represents the header of a free page list page
 type FreePageHeader struct {
 	NextFreeListPage PageID // Next page in the free list chain (0 = end of chain)
@@ -64,8 +69,8 @@ func WriteFreePageEntry(page *Page, index uint32, pageID PageID) error {
 	if page.Type != PageTypeFreeList {
 		return fmt.Errorf("invalid page type for free page: %s", page.Type)
 	}
-	if index >= MaxFreePageEntries {
-		return fmt.Errorf("free page entry index %d exceeds maximum %d", index, MaxFreePageEntries)
+	if max := freePageEntries(page); index >= max {
+		return fmt.Errorf("free page entry index %d exceeds maximum %d", index, max)
 	}
 
 	offset := FreePageHeaderSize + int(index)*4
@@ -82,8 +87,8 @@ func ReadFreePageEntry(page *Page, index uint32) (PageID, error) {
 	if page.Type != PageTypeFreeList {
 		return 0, fmt.Errorf("invalid page type for free page: %s", page.Type)
 	}
-	if index >= MaxFreePageEntries {
-		return 0, fmt.Errorf("free page entry index %d exceeds maximum %d", index, MaxFreePageEntries)
+	if max := freePageEntries(page); index >= max {
+		return 0, fmt.Errorf("free page entry index %d exceeds maximum %d", index, max)
 	}
 
 	offset := FreePageHeaderSize + int(index)*4
@@ -133,7 +138,7 @@ func AddFreePageToList(page *Page, pageID PageID) (bool, error) {
 		return false, err
 	}
 
-	if header.EntryCount >= MaxFreePageEntries {
+	if header.EntryCount >= freePageEntries(page) {
 		return false, nil // Page is full
 	}
 
@@ -178,7 +183,7 @@ func IsFreeListPageFull(page *Page) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	return header.EntryCount >= MaxFreePageEntries, nil
+	return header.EntryCount >= freePageEntries(page), nil
 }
 
 // IsFreeListPageEmpty returns true if the free list page is empty
@@ -0,0 +1,169 @@
+package storage
+
+import "sync"
+
+const (
+	// FreeSpaceMapMinFree is the free space below which a page is considered
+	// full and isn't offered for new records
+	FreeSpaceMapMinFree = 256
+
+	// freeSpaceCategories is the number of free space buckets pages are
+	// grouped into
+	freeSpaceCategories = 32
+)
+
+// PageSpace describes the free space of a slotted page
+type PageSpace struct {
+	Contiguous int // Free bytes between the slot directory and the data
+	Fragmented int // Free bytes left behind by deleted or shrunk records
+}
+
+// Free returns the space available to a record once the page is compacted
+func (ps PageSpace) Free() int {
+	return ps.Contiguous + ps.Fragmented
+}
+
+// FreeSpaceMap tracks the free space of the data pages a store owns, so new
+// records can be placed in the holes left by deletes instead of always
+// growing the file. Pages are grouped into buckets by free space and
+// lookups are best-fit: a record goes to the fullest page it fits in,
+// which keeps the emptiest pages available for large records.
+type FreeSpaceMap struct {
+	pageSize   int
+	pages      map[PageID]PageSpace
+	categories [freeSpaceCategories]map[PageID]struct{}
+	mu         sync.Mutex
+}
+
+// NewFreeSpaceMap creates an empty free space map for pages of pageSize bytes
+func NewFreeSpaceMap(pageSize int) *FreeSpaceMap {
+	fsm := &FreeSpaceMap{
+		pageSize: pageSize,
+		pages:    make(map[PageID]PageSpace),
+	}
+	for i := range fsm.categories {
+		fsm.categories[i] = make(map[PageID]struct{})
+	}
+	return fsm
+}
+
+// category returns the bucket for an amount of free space
+func (fsm *FreeSpaceMap) category(free int) int {
+	c := free * freeSpaceCategories / fsm.pageSize
+	if c >= freeSpaceCategories {
+		c = freeSpaceCategories - 1
+	}
+	if c < 0 {
+		c = 0
+	}
+	return c
+}
+
+// Update records the free space of a page, adding it to the map if needed
+func (fsm *FreeSpaceMap) Update(pageID PageID, space PageSpace) {
+	fsm.mu.Lock()
+	defer fsm.mu.Unlock()
+
+	if old, exists := fsm.pages[pageID]; exists {
+		delete(fsm.categories[fsm.category(old.Free())], pageID)
+	}
+	fsm.pages[pageID] = space
+	fsm.categories[fsm.category(space.Free())][pageID] = struct{}{}
+}
+
+// Remove drops a page from the map
+func (fsm *FreeSpaceMap) Remove(pageID PageID) {
+	fsm.mu.Lock()
+	defer fsm.mu.Unlock()
+
+	if old, exists := fsm.pages[pageID]; exists {
+		delete(fsm.categories[fsm.category(old.Free())], pageID)
+		delete(fsm.pages, pageID)
+	}
+}
+
+// Find returns the page with the least free space that still holds size
+// bytes, counting fragmented space the page gets back on compaction. Pages
+// with less than FreeSpaceMapMinFree free are never returned.
+func (fsm *FreeSpaceMap) Find(size int) (PageID, PageSpace, bool) {
+	fsm.mu.Lock()
+	defer fsm.mu.Unlock()
+
+	if size < FreeSpaceMapMinFree {
+		size = FreeSpaceMapMinFree
+	}
+
+	for c := fsm.category(size); c < freeSpaceCategories; c++ {
+		var best PageID
+		var bestSpace PageSpace
+		found := false
+		for pageID := range fsm.categories[c] {
+			space := fsm.pages[pageID]
+			if space.Free() < size {
+				continue
+			}
+			// Prefer the tightest fit, then pages that don't need compacting,
+			// then lower page IDs to keep the file dense
+			if !found || space.Free() < bestSpace.Free() ||
+				(space.Free() == bestSpace.Free() && (space.Contiguous > bestSpace.Contiguous ||
+					(space.Contiguous == bestSpace.Contiguous && pageID < best))) {
+				best, bestSpace, found = pageID, space, true
+			}
+		}
+		if found {
+			return best, bestSpace, true
+		}
+	}
+	return 0, PageSpace{}, false
+}
+
+// Contains reports whether a page is tracked by the map
+func (fsm *FreeSpaceMap) Contains(pageID PageID) bool {
+	fsm.mu.Lock()
+	defer fsm.mu.Unlock()
+
+	_, exists := fsm.pages[pageID]
+	return exists
+}
+
+// Reset drops every page from the map
+func (fsm *FreeSpaceMap) Reset() {
+	fsm.mu.Lock()
+	defer fsm.mu.Unlock()
+
+	fsm.pages = make(map[PageID]PageSpace)
+	for i := range fsm.categories {
+		fsm.categories[i] = make(map[PageID]struct{})
+	}
+}
+
+// Stats returns free space statistics. Fragmentation is the share of page
+// space held by holes that can only be reused after compaction.
+func (fsm *FreeSpaceMap) Stats() map[string]interface{} {
+	fsm.mu.Lock()
+	defer fsm.mu.Unlock()
+
+	freeBytes := 0
+	fragmentedBytes := 0
+	reusable := 0
+	for _, space := range fsm.pages {
+		freeBytes += space.Free()
+		fragmentedBytes += space.Fragmented
+		if space.Free() >= FreeSpaceMapMinFree {
+			reusable++
+		}
+	}
+
+	fragmentation := 0.0
+	if len(fsm.pages) > 0 {
+		fragmentation = float64(fragmentedBytes) / float64(len(fsm.pages)*fsm.pageSize)
+	}
+
+	return map[string]interface{}{
+		"pages":            len(fsm.pages),
+		"reusable_pages":   reusable,
+		"free_bytes":       freeBytes,
+		"fragmented_bytes": fragmentedBytes,
+		"fragmentation":    fragmentation,
+	}
+}
@@ -0,0 +1,64 @@
+package storage
+
+import "testing"
+
+func TestFreeSpaceMapBestFit(t *testing.T) {
+	fsm := NewFreeSpaceMap(PageSize)
+
+	fsm.Update(1, PageSpace{Contiguous: 3000})
+	fsm.Update(2, PageSpace{Contiguous: 600})
+	fsm.Update(3, PageSpace{Contiguous: 100})
+	fsm.Update(4, PageSpace{Contiguous: 200, Fragmented: 900})
+
+	// The tightest page that holds the record wins
+	if pageID, _, ok := fsm.Find(500); !ok || pageID != 2 {
+		t.Errorf("Expected page 2 for 500 bytes, got %d (found %v)", pageID, ok)
+	}
+
+	// Fragmented space counts, since the page can be compacted
+	if pageID, space, ok := fsm.Find(1000); !ok || pageID != 4 || space.Contiguous != 200 {
+		t.Errorf("Expected page 4 for 1000 bytes, got %d (found %v)", pageID, ok)
+	}
+
+	// Large records keep the emptiest page
+	if pageID, _, ok := fsm.Find(2000); !ok || pageID != 1 {
+		t.Errorf("Expected page 1 for 2000 bytes, got %d (found %v)", pageID, ok)
+	}
+
+	if _, _, ok := fsm.Find(4000); ok {
+		t.Error("Expected no page for 4000 bytes")
+	}
+
+	// Nearly full pages are never offered
+	fsm.Remove(2)
+	fsm.Update(1, PageSpace{Contiguous: 50})
+	fsm.Update(4, PageSpace{Contiguous: 50})
+	if pageID, _, ok := fsm.Find(10); ok {
+		t.Errorf("Expected no page for a small record, got %d", pageID)
+	}
+}
+
+func TestFreeSpaceMapStats(t *testing.T) {
+	fsm := NewFreeSpaceMap(PageSize)
+	fsm.Update(1, PageSpace{Contiguous: 1000, Fragmented: 1024})
+	fsm.Update(2, PageSpace{Contiguous: 100})
+
+	stats := fsm.Stats()
+	if stats["pages"].(int) != 2 {
+		t.Errorf("Expected 2 pages, got %v", stats["pages"])
+	}
+	if stats["reusable_pages"].(int) != 1 {
+		t.Errorf("Expected 1 reusable page, got %v", stats["reusable_pages"])
+	}
+	if stats["free_bytes"].(int) != 2124 {
+		t.Errorf("Expected 2124 free bytes, got %v", stats["free_bytes"])
+	}
+	if got := stats["fragmentation"].(float64); got != 1024.0/float64(2*PageSize) {
+		t.Errorf("Unexpected fragmentation %v", got)
+	}
+
+	fsm.Reset()
+	if fsm.Contains(1) || fsm.Stats()["pages"].(int) != 0 {
+		t.Error("Expected empty map after reset")
+	}
+}
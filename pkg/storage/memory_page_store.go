@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MemoryPageStore keeps pages in memory instead of a data file. Its contents
+// are lost on Close, which makes it suited to tests and scratch databases.
+type MemoryPageStore struct {
+	pages       map[PageID][]byte // Serialized pages, so callers can't alias them
+	pageSize    int
+	nextPageID  PageID
+	freePages   []PageID
+	mu          sync.Mutex
+	totalReads  int64
+	totalWrites int64
+	closed      bool
+}
+
+// NewMemoryPageStore creates an empty in-memory page store
+func NewMemoryPageStore() *MemoryPageStore {
+	store, _ := NewMemoryPageStoreWithPageSize(PageSize)
+	return store
+}
+
+// NewMemoryPageStoreWithPageSize creates an empty in-memory page store whose
+// pages are pageSize bytes (see ValidatePageSize)
+func NewMemoryPageStoreWithPageSize(pageSize int) (*MemoryPageStore, error) {
+	if err := ValidatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+	return &MemoryPageStore{
+		pages:    make(map[PageID][]byte),
+		pageSize: pageSize,
+	}, nil
+}
+
+// ReadPage returns a copy of a page
+func (ms *MemoryPageStore) ReadPage(pageID PageID) (*Page, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.closed {
+		return nil, fmt.Errorf("page store is closed")
+	}
+
+	data, exists := ms.pages[pageID]
+	if !exists {
+		return NewPageOfSize(pageID, PageTypeData, ms.pageSize), nil
+	}
+
+	page := NewPageOfSize(pageID, PageTypeData, ms.pageSize)
+	if err := page.Deserialize(data); err != nil {
+		return nil, fmt.Errorf("failed to deserialize page %d: %w", pageID, err)
+	}
+
+	ms.totalReads++
+	return page, nil
+}
+
+// WritePage stores a copy of a page
+func (ms *MemoryPageStore) WritePage(page *Page) error {
+	return ms.WritePages([]*Page{page})
+}
+
+// WritePages stores copies of a batch of pages
+func (ms *MemoryPageStore) WritePages(pages []*Page) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.closed {
+		return fmt.Errorf("page store is closed")
+	}
+
+	for _, page := range pages {
+		if page.Size() > ms.pageSize {
+			return fmt.Errorf("page %d holds %d bytes, more than the %d-byte page size", page.ID, PageHeaderSize+len(page.Data), ms.pageSize)
+		}
+	}
+	for _, page := range pages {
+		ms.pages[page.ID] = page.serializeAs(ms.pageSize)
+		if page.ID >= ms.nextPageID {
+			ms.nextPageID = page.ID + 1
+		}
+		ms.totalWrites++
+	}
+	return nil
+}
+
+// AllocatePage allocates a new page
+func (ms *MemoryPageStore) AllocatePage() (PageID, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if len(ms.freePages) > 0 {
+		pageID := ms.freePages[len(ms.freePages)-1]
+		ms.freePages = ms.freePages[:len(ms.freePages)-1]
+		return pageID, nil
+	}
+
+	pageID := ms.nextPageID
+	ms.nextPageID++
+	return pageID, nil
+}
+
+// DeallocatePage frees a page for reuse
+func (ms *MemoryPageStore) DeallocatePage(pageID PageID) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if pageID >= ms.nextPageID {
+		return fmt.Errorf("invalid page ID: %d (next page ID: %d)", pageID, ms.nextPageID)
+	}
+
+	delete(ms.pages, pageID)
+	ms.freePages = append(ms.freePages, pageID)
+	return nil
+}
+
+// Sync does nothing; memory pages aren't durable
+func (ms *MemoryPageStore) Sync() error {
+	return nil
+}
+
+// Close releases the pages
+func (ms *MemoryPageStore) Close() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.pages = nil
+	ms.freePages = nil
+	ms.closed = true
+	return nil
+}
+
+// PageSize returns the size of the pages in the store
+func (ms *MemoryPageStore) PageSize() int {
+	return ms.pageSize
+}
+
+// Stats returns page store statistics
+func (ms *MemoryPageStore) Stats() map[string]interface{} {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return map[string]interface{}{
+		"page_size":    ms.pageSize,
+		"next_page_id": ms.nextPageID,
+		"free_pages":   len(ms.freePages),
+		"used_pages":   int(ms.nextPageID) - len(ms.freePages),
+		"total_reads":  ms.totalReads,
+		"total_writes": ms.totalWrites,
+		"pages":        len(ms.pages),
+	}
+}
@@ -0,0 +1,96 @@
+package storage
+
+import "testing"
+
+func TestMemoryPageStoreReadWrite(t *testing.T) {
+	ms := NewMemoryPageStore()
+	defer ms.Close()
+
+	pageID, err := ms.AllocatePage()
+	if err != nil {
+		t.Fatalf("Failed to allocate page: %v", err)
+	}
+
+	// A page that was never written reads back empty
+	page, err := ms.ReadPage(pageID)
+	if err != nil {
+		t.Fatalf("Failed to read page: %v", err)
+	}
+	if page.Data[0] != 0 {
+		t.Errorf("Expected empty page, got %d", page.Data[0])
+	}
+
+	page.Data[0] = 42
+	if err := ms.WritePage(page); err != nil {
+		t.Fatalf("Failed to write page: %v", err)
+	}
+
+	// Changes after the write don't reach the store
+	page.Data[0] = 7
+	read, err := ms.ReadPage(pageID)
+	if err != nil {
+		t.Fatalf("Failed to read page: %v", err)
+	}
+	if read.Data[0] != 42 {
+		t.Errorf("Expected 42, got %d", read.Data[0])
+	}
+}
+
+func TestMemoryPageStoreDeallocate(t *testing.T) {
+	ms := NewMemoryPageStore()
+	defer ms.Close()
+
+	first, _ := ms.AllocatePage()
+	second, _ := ms.AllocatePage()
+	if first == second {
+		t.Fatalf("Expected distinct page IDs, got %d twice", first)
+	}
+
+	if err := ms.DeallocatePage(first); err != nil {
+		t.Fatalf("Failed to deallocate page: %v", err)
+	}
+	if reused, _ := ms.AllocatePage(); reused != first {
+		t.Errorf("Expected page %d to be reused, got %d", first, reused)
+	}
+	if err := ms.DeallocatePage(100); err == nil {
+		t.Error("Expected error deallocating an unallocated page")
+	}
+
+	stats := ms.Stats()
+	if stats["next_page_id"] != PageID(2) {
+		t.Errorf("Expected next_page_id 2, got %v", stats["next_page_id"])
+	}
+}
+
+func TestStorageEngineWithMemoryPageStore(t *testing.T) {
+	config := DefaultConfig(t.TempDir())
+	config.PageStore = NewMemoryPageStore()
+	engine, err := NewStorageEngine(config)
+	if err != nil {
+		t.Fatalf("Failed to create storage engine: %v", err)
+	}
+	defer engine.Close()
+
+	if engine.DiskManager() != nil {
+		t.Error("Expected no disk manager with a memory page store")
+	}
+
+	page, err := engine.AllocatePage()
+	if err != nil {
+		t.Fatalf("Failed to allocate page: %v", err)
+	}
+	page.Data[0] = 9
+	pageID := page.ID
+	engine.UnpinPage(pageID, true)
+	if err := engine.FlushPage(pageID); err != nil {
+		t.Fatalf("Failed to flush page: %v", err)
+	}
+
+	read, err := engine.PageStore().ReadPage(pageID)
+	if err != nil {
+		t.Fatalf("Failed to read page: %v", err)
+	}
+	if read.Data[0] != 9 {
+		t.Errorf("Expected 9, got %d", read.Data[0])
+	}
+}
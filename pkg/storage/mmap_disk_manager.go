@@ -12,21 +12,31 @@ import (
 // This provides better performance for read-heavy workloads by mapping the file
 // directly into the process address space, reducing system calls.
 type MmapDiskManager struct {
-	dataFile     *os.File
-	mmapData     []byte
-	mmapSize     int64
-	nextPageID   PageID
-	freePages    []PageID
-	mu           sync.RWMutex
-	totalReads   int64
-	totalWrites  int64
-	useMmap      bool
+	dataFile         *os.File
+	checksumFile     *os.File // CRC32C checksum per page (see checksum.go)
+	mmapData         []byte
+	mmapSize         int64
+	pageSize         int
+	dataOffset       int64 // Offset of page 0, after the file header if any
+	nextPageID       PageID
+	freePages        []PageID
+	mu               sync.RWMutex
+	totalReads       int64
+	totalWrites      int64
+	checksumFailures int64
+	quarantinedPages int64
+	useMmap          bool
+	imageLog         PageImageLog // Receives page images ahead of writes (see page_image.go)
 }
 
 // MmapConfig holds configuration for memory-mapped disk manager
 type MmapConfig struct {
 	InitialSize int64 // Initial mmap size in bytes (default: 256MB)
 	GrowthSize  int64 // Size to grow by when expanding (default: 64MB)
+
+	// PageSize is the page size of a new data file (0 uses PageSize). An
+	// existing file keeps its size; see NewDiskManagerWithPageSize.
+	PageSize int
 }
 
 // DefaultMmapConfig returns default mmap configuration
@@ -48,6 +58,12 @@ func NewMmapDiskManager(path string, config *MmapConfig) (*MmapDiskManager, erro
 		return nil, fmt.Errorf("failed to open data file: %w", err)
 	}
 
+	pageSize, dataOffset, err := openPageFile(file, config.PageSize)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
 	// Get file size to determine next page ID
 	fileInfo, err := file.Stat()
 	if err != nil {
@@ -56,13 +72,22 @@ func NewMmapDiskManager(path string, config *MmapConfig) (*MmapDiskManager, erro
 	}
 
 	currentSize := fileInfo.Size()
-	nextPageID := PageID(currentSize / PageSize)
+	nextPageID := PageID((currentSize - dataOffset) / int64(pageSize))
+
+	checksumFile, err := openChecksumFile(path)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
 
 	dm := &MmapDiskManager{
-		dataFile:   file,
-		nextPageID: nextPageID,
-		freePages:  make([]PageID, 0),
-		useMmap:    true,
+		dataFile:     file,
+		checksumFile: checksumFile,
+		pageSize:     pageSize,
+		dataOffset:   dataOffset,
+		nextPageID:   nextPageID,
+		freePages:    make([]PageID, 0),
+		useMmap:      true,
 	}
 
 	// Initialize mmap
@@ -72,6 +97,7 @@ func NewMmapDiskManager(path string, config *MmapConfig) (*MmapDiskManager, erro
 	}
 
 	if err := dm.expandMmap(mmapSize); err != nil {
+		checksumFile.Close()
 		file.Close()
 		return nil, fmt.Errorf("failed to initialize mmap: %w", err)
 	}
@@ -120,17 +146,23 @@ func (dm *MmapDiskManager) ReadPage(pageID PageID) (*Page, error) {
 		return nil, fmt.Errorf("mmap is disabled")
 	}
 
-	offset := int64(pageID) * PageSize
+	offset := dm.pageOffset(pageID)
+	size := int64(dm.pageSize)
 
 	// Check if page is beyond current mmap region
-	if offset+PageSize > dm.mmapSize {
-		return NewPage(pageID, PageTypeData), nil
+	if offset+size > dm.mmapSize {
+		return NewPageOfSize(pageID, PageTypeData, dm.pageSize), nil
 	}
 
 	// Read directly from memory-mapped region
-	data := dm.mmapData[offset : offset+PageSize]
+	data := dm.mmapData[offset : offset+size]
+
+	if err := verifyChecksum(dm.checksumFile, pageID, data); err != nil {
+		dm.checksumFailures++
+		return nil, err
+	}
 
-	page := NewPage(pageID, PageTypeData)
+	page := NewPageOfSize(pageID, PageTypeData, dm.pageSize)
 	if err := page.Deserialize(data); err != nil {
 		return nil, fmt.Errorf("failed to deserialize page %d: %w", pageID, err)
 	}
@@ -144,17 +176,61 @@ func (dm *MmapDiskManager) WritePage(page *Page) error {
 	dm.mu.Lock()
 	defer dm.mu.Unlock()
 
+	return dm.writePages([]*Page{page})
+}
+
+// WritePages writes a batch of pages to the memory-mapped region under a
+// single lock acquisition. Their images are logged together, ahead of the
+// first write.
+func (dm *MmapDiskManager) WritePages(pages []*Page) error {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	return dm.writePages(pages)
+}
+
+// SetPageImageLog makes the disk manager hand the image of every page to
+// log before writing it (nil stops logging)
+func (dm *MmapDiskManager) SetPageImageLog(log PageImageLog) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	dm.imageLog = log
+}
+
+// writePages logs the images of pages and writes them
+// Must be called with dm.mu held
+func (dm *MmapDiskManager) writePages(pages []*Page) error {
 	if !dm.useMmap {
 		return fmt.Errorf("mmap is disabled")
 	}
 
-	offset := int64(page.ID) * PageSize
+	if err := logPageImages(dm.imageLog, pages, dm.pageSize); err != nil {
+		return err
+	}
+	for _, page := range pages {
+		if err := dm.storePage(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storePage copies a page into the memory-mapped region and records its
+// checksum
+// Must be called with dm.mu held
+func (dm *MmapDiskManager) storePage(page *Page) error {
+	if page.Size() > dm.pageSize {
+		return fmt.Errorf("page %d holds %d bytes, more than the %d-byte page size", page.ID, PageHeaderSize+len(page.Data), dm.pageSize)
+	}
+	offset := dm.pageOffset(page.ID)
+	size := int64(dm.pageSize)
 
 	// Expand mmap if needed
-	if offset+PageSize > dm.mmapSize {
+	if offset+size > dm.mmapSize {
 		newSize := dm.mmapSize + DefaultMmapConfig().GrowthSize
-		if offset+PageSize > newSize {
-			newSize = offset + PageSize + DefaultMmapConfig().GrowthSize
+		if offset+size > newSize {
+			newSize = offset + size + DefaultMmapConfig().GrowthSize
 		}
 		if err := dm.expandMmap(newSize); err != nil {
 			return fmt.Errorf("failed to expand mmap: %w", err)
@@ -162,8 +238,12 @@ func (dm *MmapDiskManager) WritePage(page *Page) error {
 	}
 
 	// Write directly to memory-mapped region
-	data := page.Serialize()
-	copy(dm.mmapData[offset:offset+PageSize], data)
+	data := page.serializeAs(dm.pageSize)
+	copy(dm.mmapData[offset:offset+size], data)
+
+	if err := writeChecksum(dm.checksumFile, page.ID, PageChecksum(data)); err != nil {
+		return err
+	}
 
 	dm.totalWrites++
 	return nil
@@ -186,8 +266,8 @@ func (dm *MmapDiskManager) AllocatePage() (PageID, error) {
 	dm.nextPageID++
 
 	// Ensure mmap is large enough for the new page
-	offset := int64(pageID) * PageSize
-	if offset+PageSize > dm.mmapSize {
+	offset := dm.pageOffset(pageID)
+	if offset+int64(dm.pageSize) > dm.mmapSize {
 		newSize := dm.mmapSize + DefaultMmapConfig().GrowthSize
 		if err := dm.expandMmap(newSize); err != nil {
 			return 0, fmt.Errorf("failed to expand mmap for new page: %w", err)
@@ -221,7 +301,7 @@ func (dm *MmapDiskManager) Sync() error {
 		return fmt.Errorf("failed to msync: %v", errno)
 	}
 
-	return nil
+	return dm.checksumFile.Sync()
 }
 
 // Close closes the memory-mapped file
@@ -242,6 +322,13 @@ func (dm *MmapDiskManager) Close() error {
 		dm.mmapData = nil
 	}
 
+	if err := dm.checksumFile.Sync(); err != nil {
+		return err
+	}
+	if err := dm.checksumFile.Close(); err != nil {
+		return err
+	}
+
 	// Close the file
 	if err := dm.dataFile.Sync(); err != nil {
 		return err
@@ -251,19 +338,111 @@ func (dm *MmapDiskManager) Close() error {
 	return dm.dataFile.Close()
 }
 
+// PageSize returns the size of the pages in the data file
+func (dm *MmapDiskManager) PageSize() int {
+	return dm.pageSize
+}
+
+// pageOffset returns the file offset of a page
+func (dm *MmapDiskManager) pageOffset(pageID PageID) int64 {
+	return dm.dataOffset + int64(pageID)*int64(dm.pageSize)
+}
+
 // Stats returns disk manager statistics
 func (dm *MmapDiskManager) Stats() map[string]interface{} {
 	dm.mu.RLock()
 	defer dm.mu.RUnlock()
 
 	return map[string]interface{}{
-		"next_page_id": dm.nextPageID,
-		"free_pages":   len(dm.freePages),
-		"total_reads":  dm.totalReads,
-		"total_writes": dm.totalWrites,
-		"mmap_size":    dm.mmapSize,
-		"use_mmap":     dm.useMmap,
+		"page_size":         dm.pageSize,
+		"next_page_id":      dm.nextPageID,
+		"free_pages":        len(dm.freePages),
+		"used_pages":        int(dm.nextPageID) - len(dm.freePages),
+		"total_reads":       dm.totalReads,
+		"total_writes":      dm.totalWrites,
+		"checksum_failures": dm.checksumFailures,
+		"quarantined_pages": dm.quarantinedPages,
+		"mmap_size":         dm.mmapSize,
+		"use_mmap":          dm.useMmap,
+	}
+}
+
+// readRawPage returns a copy of the raw bytes of a page without
+// verification, or nil if the page lies beyond the mapped region
+// Must be called with dm.mu held
+func (dm *MmapDiskManager) readRawPage(pageID PageID) []byte {
+	offset := dm.pageOffset(pageID)
+	size := int64(dm.pageSize)
+	if !dm.useMmap || offset+size > dm.mmapSize {
+		return nil
+	}
+
+	data := make([]byte, dm.pageSize)
+	copy(data, dm.mmapData[offset:offset+size])
+	return data
+}
+
+// VerifyPage checks a single page against its recorded checksum
+func (dm *MmapDiskManager) VerifyPage(pageID PageID) error {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+
+	data := dm.readRawPage(pageID)
+	if data == nil {
+		return nil
 	}
+
+	return verifyChecksum(dm.checksumFile, pageID, data)
+}
+
+// VerifyAllPages checks every allocated page and returns a ChecksumError for
+// each page whose contents no longer match the recorded checksum
+func (dm *MmapDiskManager) VerifyAllPages() ([]*ChecksumError, error) {
+	dm.mu.RLock()
+	totalPages := dm.nextPageID
+	dm.mu.RUnlock()
+
+	mismatches := make([]*ChecksumError, 0)
+	for pageID := PageID(0); pageID < totalPages; pageID++ {
+		err := dm.VerifyPage(pageID)
+		if err == nil {
+			continue
+		}
+
+		checksumErr, ok := err.(*ChecksumError)
+		if !ok {
+			return mismatches, err
+		}
+		mismatches = append(mismatches, checksumErr)
+	}
+
+	return mismatches, nil
+}
+
+// QuarantinePage moves a corrupt page out of service like
+// DiskManager.QuarantinePage: the raw bytes are appended to the quarantine
+// file, the page is reset to an empty data page and freed for reuse
+func (dm *MmapDiskManager) QuarantinePage(pageID PageID) error {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if pageID >= dm.nextPageID {
+		return fmt.Errorf("invalid page ID: %d (next page ID: %d)", pageID, dm.nextPageID)
+	}
+
+	if data := dm.readRawPage(pageID); data != nil {
+		if err := writeQuarantineRecord(dm.dataFile.Name(), pageID, data); err != nil {
+			return err
+		}
+	}
+
+	if err := dm.writePages([]*Page{NewPageOfSize(pageID, PageTypeData, dm.pageSize)}); err != nil {
+		return fmt.Errorf("failed to reset quarantined page: %w", err)
+	}
+
+	dm.freePages = append(dm.freePages, pageID)
+	dm.quarantinedPages++
+	return nil
 }
 
 // MadviseRandom hints that page access will be random
@@ -307,8 +486,8 @@ func (dm *MmapDiskManager) MadviseWillNeed(startPage, endPage PageID) error {
 		return fmt.Errorf("mmap not initialized")
 	}
 
-	startOffset := int64(startPage) * PageSize
-	endOffset := int64(endPage) * PageSize
+	startOffset := dm.pageOffset(startPage)
+	endOffset := dm.pageOffset(endPage)
 
 	if startOffset >= dm.mmapSize || endOffset > dm.mmapSize {
 		return fmt.Errorf("page range exceeds mmap size")
@@ -2,11 +2,11 @@ package storage
 
 import (
 	"encoding/binary"
-	"fmt"
 )
 
 const (
-	// PageSize is the size of each page (4KB, typical OS page size)
+	// PageSize is the default size of each page (4KB, typical OS page size).
+	// A database can be created with larger pages; see ValidatePageSize.
 	PageSize = 4096
 
 	// PageHeaderSize is the size of the page header
@@ -53,22 +53,41 @@ type Page struct {
 	PinCount int
 }
 
-// NewPage creates a new page
+// NewPage creates a new page of the default size
 func NewPage(id PageID, pageType PageType) *Page {
+	return NewPageOfSize(id, pageType, PageSize)
+}
+
+// NewPageOfSize creates a new page of pageSize bytes, including the header
+func NewPageOfSize(id PageID, pageType PageType, pageSize int) *Page {
 	return &Page{
 		ID:       id,
 		Type:     pageType,
 		Flags:    0,
 		LSN:      0,
-		Data:     make([]byte, PageSize-PageHeaderSize),
+		Data:     make([]byte, pageSize-PageHeaderSize),
 		IsDirty:  false,
 		PinCount: 0,
 	}
 }
 
-// Serialize converts the page to bytes for storage
+// Size returns the smallest page size that holds the page's header and data
+func (p *Page) Size() int {
+	size := MinPageSize
+	for size < PageHeaderSize+len(p.Data) {
+		size *= 2
+	}
+	return size
+}
+
+// Serialize converts the page to bytes for storage, padded to its Size
 func (p *Page) Serialize() []byte {
-	buf := make([]byte, PageSize)
+	return p.serializeAs(p.Size())
+}
+
+// serializeAs converts the page to pageSize bytes for a store using that size
+func (p *Page) serializeAs(pageSize int) []byte {
+	buf := make([]byte, pageSize)
 
 	// Header: [4-byte ID][1-byte Type][1-byte Flags][8-byte LSN][2-byte reserved]
 	binary.LittleEndian.PutUint32(buf[0:4], uint32(p.ID))
@@ -85,8 +104,8 @@ func (p *Page) Serialize() []byte {
 
 // Deserialize loads page data from bytes
 func (p *Page) Deserialize(data []byte) error {
-	if len(data) != PageSize {
-		return fmt.Errorf("invalid page size: expected %d, got %d", PageSize, len(data))
+	if err := ValidatePageSize(len(data)); err != nil {
+		return err
 	}
 
 	// Read header
@@ -96,7 +115,7 @@ func (p *Page) Deserialize(data []byte) error {
 	p.LSN = binary.LittleEndian.Uint64(data[6:14])
 
 	// Read data
-	p.Data = make([]byte, PageSize-PageHeaderSize)
+	p.Data = make([]byte, len(data)-PageHeaderSize)
 	copy(p.Data, data[PageHeaderSize:])
 
 	return nil
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Full-page writes guard against torn pages. A page is larger than the unit
+// the disk writes atomically, so a crash in the middle of writing one in
+// place can leave it half old and half new; its checksum then no longer
+// matches. To repair such a page, the store hands the complete new image of
+// every page to the WAL, which syncs it, before overwriting the page. On
+// startup, recovery rewrites each page failing its checksum from the last
+// intact image logged for it. Checkpoints sync the page store before
+// truncating the WAL, so the images they drop are no longer needed.
+
+// PageImage is the serialized contents of a page about to be written
+type PageImage struct {
+	PageID PageID
+	Data   []byte
+}
+
+// PageImageLog makes page images durable before the page store writes them
+// in place
+type PageImageLog func(images []PageImage) error
+
+// logPageImages hands the images of pages, serialized at pageSize, to log
+// (if set) ahead of writing them
+func logPageImages(log PageImageLog, pages []*Page, pageSize int) error {
+	if log == nil {
+		return nil
+	}
+
+	images := make([]PageImage, 0, len(pages))
+	for _, page := range pages {
+		if page.Size() > pageSize {
+			return fmt.Errorf("page %d holds %d bytes, more than the %d-byte page size", page.ID, PageHeaderSize+len(page.Data), pageSize)
+		}
+		images = append(images, PageImage{PageID: page.ID, Data: page.serializeAs(pageSize)})
+	}
+
+	if err := log(images); err != nil {
+		return fmt.Errorf("failed to log page images: %w", err)
+	}
+	return nil
+}
+
+// encodePageImage prefixes a page image with its checksum, so an image torn
+// by a crash while it was logged is recognised and skipped
+// Format: [4-byte CRC32C][image]
+func encodePageImage(image []byte) []byte {
+	buf := make([]byte, ChecksumSize+len(image))
+	binary.LittleEndian.PutUint32(buf[0:ChecksumSize], PageChecksum(image))
+	copy(buf[ChecksumSize:], image)
+	return buf
+}
+
+// decodePageImage returns the page image held in a log record, or false if
+// the record holds none of the given page size or it is damaged
+func decodePageImage(record *LogRecord, pageSize int) ([]byte, bool) {
+	if record.Type != LogRecordPageImage || len(record.Data) != ChecksumSize+pageSize {
+		return nil, false
+	}
+
+	image := record.Data[ChecksumSize:]
+	if PageChecksum(image) != binary.LittleEndian.Uint32(record.Data[0:ChecksumSize]) {
+		return nil, false
+	}
+	return image, true
+}
+
+// latestPageImages returns the last intact image logged for each page
+func latestPageImages(records []*LogRecord, pageSize int) map[PageID][]byte {
+	images := make(map[PageID][]byte)
+	for _, record := range records {
+		if image, ok := decodePageImage(record, pageSize); ok {
+			images[record.PageID] = image
+		}
+	}
+	return images
+}
+
+// logPageImages is the engine's PageImageLog: it appends one record per image
+// and returns once they are synced. The sync is not a commit, so it is left
+// out of the WAL's commit statistics.
+func (se *StorageEngine) logPageImages(images []PageImage) error {
+	for _, image := range images {
+		record := &LogRecord{
+			Type:   LogRecordPageImage,
+			PageID: image.PageID,
+			Data:   encodePageImage(image.Data),
+		}
+		if _, err := se.wal.Append(record); err != nil {
+			return err
+		}
+	}
+
+	if err := se.wal.Flush(); err != nil {
+		return err
+	}
+
+	se.wakeCheckpointer()
+	return nil
+}
+
+// redoTornPages rewrites every page that fails checksum verification from
+// its last intact image in records. Pages with no image are left alone, for
+// the repair tools to report.
+func (se *StorageEngine) redoTornPages(records []*LogRecord) error {
+	verifier, ok := se.diskMgr.(PageVerifier)
+	if !ok {
+		return nil
+	}
+
+	pageSize := se.diskMgr.PageSize()
+	images := latestPageImages(records, pageSize)
+	pageIDs := make([]PageID, 0, len(images))
+	for pageID := range images {
+		pageIDs = append(pageIDs, pageID)
+	}
+	sort.Slice(pageIDs, func(i, j int) bool { return pageIDs[i] < pageIDs[j] })
+
+	torn := make([]*Page, 0)
+	for _, pageID := range pageIDs {
+		err := verifier.VerifyPage(pageID)
+		if err == nil {
+			continue
+		}
+		var checksumErr *ChecksumError
+		if !errors.As(err, &checksumErr) {
+			return fmt.Errorf("failed to verify page %d: %w", pageID, err)
+		}
+
+		page := NewPageOfSize(pageID, PageTypeData, pageSize)
+		if err := page.Deserialize(images[pageID]); err != nil {
+			return fmt.Errorf("failed to decode image of page %d: %w", pageID, err)
+		}
+		torn = append(torn, page)
+	}
+
+	if len(torn) == 0 {
+		return nil
+	}
+
+	if err := se.diskMgr.WritePages(torn); err != nil {
+		return fmt.Errorf("failed to redo torn pages: %w", err)
+	}
+	if err := se.diskMgr.Sync(); err != nil {
+		return fmt.Errorf("failed to sync redone pages: %w", err)
+	}
+
+	se.redonePages.Add(int64(len(torn)))
+	return nil
+}
+
+// RedoPage rewrites a page from the last intact image of it in the WAL, as
+// recovery does for pages torn by a crash. It returns false, leaving the page
+// alone, if the WAL holds no such image, e.g. because a checkpoint made it
+// redundant.
+func (se *StorageEngine) RedoPage(pageID PageID) (bool, error) {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+
+	if !se.isOpen {
+		return false, fmt.Errorf("storage engine is closed")
+	}
+
+	records, err := se.wal.Replay()
+	if err != nil {
+		return false, fmt.Errorf("failed to read WAL: %w", err)
+	}
+
+	pageSize := se.diskMgr.PageSize()
+	image, ok := latestPageImages(records, pageSize)[pageID]
+	if !ok {
+		return false, nil
+	}
+
+	page := NewPageOfSize(pageID, PageTypeData, pageSize)
+	if err := page.Deserialize(image); err != nil {
+		return false, fmt.Errorf("failed to decode image of page %d: %w", pageID, err)
+	}
+	if err := se.diskMgr.WritePage(page); err != nil {
+		return false, fmt.Errorf("failed to redo page %d: %w", pageID, err)
+	}
+
+	se.redonePages.Add(1)
+	return true, nil
+}
@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tearPage zeroes the second half of a page directly in the data file, as a
+// crash in the middle of writing it would
+func tearPage(t *testing.T, path string, pageID PageID) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open data file: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteAt(make([]byte, PageSize/2), int64(pageID)*PageSize+PageSize/2); err != nil {
+		t.Fatalf("Failed to tear page: %v", err)
+	}
+}
+
+// fillPage fills the data of a page with a pattern, so tearing it shows
+func fillPage(page *Page) {
+	for i := range page.Data {
+		page.Data[i] = byte(i)
+	}
+}
+
+func TestRecoveryRedoesTornPages(t *testing.T) {
+	stores := map[string]func(path string) (PageStore, error){
+		"disk": func(path string) (PageStore, error) {
+			return NewDiskManager(path)
+		},
+		"mmap": func(path string) (PageStore, error) {
+			return NewMmapDiskManager(path, &MmapConfig{InitialSize: 1024 * 1024, GrowthSize: 1024 * 1024})
+		},
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "pages.db")
+
+			openEngine := func() *StorageEngine {
+				store, err := open(path)
+				if err != nil {
+					t.Fatalf("Failed to open page store: %v", err)
+				}
+				config := DefaultConfig(dir)
+				config.CheckpointInterval = 0
+				config.PageStore = store
+				engine, err := NewStorageEngine(config)
+				if err != nil {
+					t.Fatalf("Failed to open storage engine: %v", err)
+				}
+				return engine
+			}
+
+			engine := openEngine()
+			page, err := engine.AllocatePage()
+			if err != nil {
+				t.Fatalf("Failed to allocate page: %v", err)
+			}
+			fillPage(page)
+			pageID := page.ID
+			engine.UnpinPage(pageID, true)
+			if err := engine.FlushPage(pageID); err != nil {
+				t.Fatalf("Failed to flush page: %v", err)
+			}
+			engine.Close()
+
+			tearPage(t, path, pageID)
+
+			engine = openEngine()
+			defer engine.Close()
+
+			if redone := engine.Stats()["wal"].(map[string]interface{})["redone_pages"].(int64); redone != 1 {
+				t.Errorf("Expected 1 redone page, got %d", redone)
+			}
+			recovered, err := engine.FetchPage(pageID)
+			if err != nil {
+				t.Fatalf("Expected the torn page redone, got %v", err)
+			}
+			defer engine.UnpinPage(pageID, false)
+			for i := range recovered.Data {
+				if recovered.Data[i] != byte(i) {
+					t.Fatalf("Expected the redone page to hold the last write, byte %d differs", i)
+				}
+			}
+		})
+	}
+}
+
+func TestTornPageWithoutImage(t *testing.T) {
+	dir := t.TempDir()
+	config := DefaultConfig(dir)
+	config.CheckpointInterval = 0
+
+	engine, err := NewStorageEngine(config)
+	if err != nil {
+		t.Fatalf("Failed to create storage engine: %v", err)
+	}
+	page, _ := engine.AllocatePage()
+	fillPage(page)
+	pageID := page.ID
+	engine.UnpinPage(pageID, true)
+
+	// The checkpoint syncs the page and drops its image from the WAL
+	if err := engine.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	engine.Close()
+
+	tearPage(t, filepath.Join(dir, "data.db"), pageID)
+
+	// The engine still opens, leaving the page for the repair tools
+	engine, err = NewStorageEngine(config)
+	if err != nil {
+		t.Fatalf("Expected the engine to open with an unrecoverable page, got %v", err)
+	}
+	defer engine.Close()
+
+	if _, ok := engine.DiskManager().VerifyPage(pageID).(*ChecksumError); !ok {
+		t.Error("Expected the page to fail verification")
+	}
+	redone, err := engine.RedoPage(pageID)
+	if err != nil || redone {
+		t.Errorf("Expected no image to redo the page from, got %v, %v", redone, err)
+	}
+
+	// A page written after the checkpoint can be redone from its image
+	page, err = engine.AllocatePage()
+	if err != nil {
+		t.Fatalf("Failed to allocate page: %v", err)
+	}
+	fillPage(page)
+	engine.UnpinPage(page.ID, true)
+	engine.FlushPage(page.ID)
+	tearPage(t, filepath.Join(dir, "data.db"), page.ID)
+	if redone, err := engine.RedoPage(page.ID); err != nil || !redone {
+		t.Errorf("Expected page %d redone, got %v, %v", page.ID, redone, err)
+	}
+	if err := engine.DiskManager().VerifyPage(page.ID); err != nil {
+		t.Errorf("Expected the redone page to verify, got %v", err)
+	}
+}
+
+func TestMmapDiskManagerChecksums(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_mmap.db")
+	dm, err := NewMmapDiskManager(path, &MmapConfig{InitialSize: 1024 * 1024, GrowthSize: 1024 * 1024})
+	if err != nil {
+		t.Fatalf("Failed to create mmap disk manager: %v", err)
+	}
+	defer dm.Close()
+
+	for i := 0; i < 3; i++ {
+		pageID, _ := dm.AllocatePage()
+		page := NewPage(pageID, PageTypeData)
+		copy(page.Data, []byte("checksummed page contents"))
+		if err := dm.WritePage(page); err != nil {
+			t.Fatalf("Failed to write page: %v", err)
+		}
+	}
+
+	// Damage page 1 through the mapping
+	dm.mmapData[dm.pageOffset(1)+PageHeaderSize+10] ^= 0xFF
+
+	if _, err := dm.ReadPage(1); err == nil {
+		t.Fatal("Expected a checksum error reading the damaged page")
+	}
+	mismatches, err := dm.VerifyAllPages()
+	if err != nil {
+		t.Fatalf("VerifyAllPages failed: %v", err)
+	}
+	if len(mismatches) != 1 || mismatches[0].PageID != 1 {
+		t.Fatalf("Expected a single mismatch on page 1, got %v", mismatches)
+	}
+
+	if err := dm.QuarantinePage(1); err != nil {
+		t.Fatalf("QuarantinePage failed: %v", err)
+	}
+	if err := dm.VerifyPage(1); err != nil {
+		t.Errorf("Expected the quarantined page to verify after reset, got %v", err)
+	}
+	if reused, _ := dm.AllocatePage(); reused != 1 {
+		t.Errorf("Expected quarantined page 1 to be reused, got %d", reused)
+	}
+
+	stats := dm.Stats()
+	if stats["checksum_failures"].(int64) != 1 || stats["quarantined_pages"].(int64) != 1 {
+		t.Errorf("Expected 1 checksum failure and 1 quarantined page, got %v", stats)
+	}
+}
+
+func TestWALIncompleteRecordAtEnd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	wal, err := NewWAL(path)
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	wal.Append(&LogRecord{Type: LogRecordOperation, Data: []byte("complete")})
+	wal.Close()
+
+	// A crash cut the next record short
+	partial := (&WAL{}).serializeRecord(&LogRecord{Type: LogRecordOperation, Data: make([]byte, 100)})
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	file.Write(partial[:50])
+	file.Close()
+
+	wal, err = NewWAL(path)
+	if err != nil {
+		t.Fatalf("Expected the WAL to open, got %v", err)
+	}
+	defer wal.Close()
+
+	wal.Append(&LogRecord{Type: LogRecordOperation, Data: []byte("after")})
+	records, err := wal.Replay()
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(records) != 2 || string(records[1].Data) != "after" {
+		t.Errorf("Expected the incomplete record dropped, got %d records", len(records))
+	}
+}
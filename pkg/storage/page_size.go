@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// A database picks its page size once, when its data file is created.
+//
+// Larger pages hold larger documents (see MaxDocumentSizeForPageSize) and
+// give B+ tree nodes more fan-out, so range scans and big documents touch
+// fewer pages. The cost is that every page read, write, checksum and buffer
+// pool frame grows with the page: a point lookup of a small document reads
+// the whole page, and a buffer pool of N pages takes N times the page size
+// of memory. Workloads of many tiny records read at random do best with the
+// default 4KB pages; workloads of large documents or long scans benefit from
+// 16KB or 32KB pages.
+const (
+	// MinPageSize is the smallest page size a database can be created with
+	MinPageSize = PageSize
+
+	// MaxPageSize is the largest page size. Slotted pages and B+ tree nodes
+	// address their contents with 16-bit offsets.
+	MaxPageSize = 32768
+)
+
+// ErrPageSizeMismatch is returned when a data file is opened with a page
+// size other than the one it was created with
+var ErrPageSizeMismatch = errors.New("page size mismatch")
+
+// fileHeaderMagic starts the header of data files whose pages are not of
+// the default size. Files with default-size pages have no header, so they
+// keep the layout of files created before page sizes were configurable.
+var fileHeaderMagic = []byte("LAURAPGS")
+
+// fileHeaderSize is the part of the header page that is used:
+// [8-byte magic][4-byte page size]
+const fileHeaderSize = 12
+
+// ValidatePageSize returns an error unless size is 4KB, 8KB, 16KB or 32KB
+func ValidatePageSize(size int) error {
+	if size < MinPageSize || size > MaxPageSize || size&(size-1) != 0 {
+		return fmt.Errorf("invalid page size %d: must be 4096, 8192, 16384 or 32768", size)
+	}
+	return nil
+}
+
+// MaxDocumentSizeForPageSize returns the largest serialized document that
+// fits in a single page of the given size
+func MaxDocumentSizeForPageSize(pageSize int) int {
+	return pageSize - PageHeaderSize - SlottedPageHeaderSize - SlotEntrySize
+}
+
+// openPageFile returns the page size of a data file and the offset of its
+// first page. A new file gets the requested size (0 uses PageSize) and, for
+// sizes other than the default, a header page recording it. An existing
+// file keeps the size it was created with; requesting another one fails
+// with ErrPageSizeMismatch, while 0 accepts whatever the file uses.
+func openPageFile(file *os.File, requested int) (int, int64, error) {
+	if requested != 0 {
+		if err := ValidatePageSize(requested); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to stat data file: %w", err)
+	}
+
+	if info.Size() == 0 {
+		pageSize := requested
+		if pageSize == 0 || pageSize == PageSize {
+			return PageSize, 0, nil
+		}
+
+		header := make([]byte, pageSize)
+		copy(header, fileHeaderMagic)
+		binary.LittleEndian.PutUint32(header[8:12], uint32(pageSize))
+		if _, err := file.WriteAt(header, 0); err != nil {
+			return 0, 0, fmt.Errorf("failed to write file header: %w", err)
+		}
+		if err := file.Sync(); err != nil {
+			return 0, 0, fmt.Errorf("failed to sync file header: %w", err)
+		}
+		return pageSize, int64(pageSize), nil
+	}
+
+	// A headerless file starts with page 0, whose first bytes are its
+	// zero page ID, so it can't be mistaken for a header
+	pageSize, offset := PageSize, int64(0)
+	header := make([]byte, fileHeaderSize)
+	if n, _ := file.ReadAt(header, 0); n == fileHeaderSize && bytes.Equal(header[:8], fileHeaderMagic) {
+		pageSize = int(binary.LittleEndian.Uint32(header[8:12]))
+		if err := ValidatePageSize(pageSize); err != nil {
+			return 0, 0, fmt.Errorf("corrupt data file header: %w", err)
+		}
+		offset = int64(pageSize)
+	}
+
+	if requested != 0 && requested != pageSize {
+		return 0, 0, fmt.Errorf("%w: data file has %d-byte pages, opened with %d", ErrPageSizeMismatch, pageSize, requested)
+	}
+	return pageSize, offset, nil
+}
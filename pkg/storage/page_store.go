@@ -0,0 +1,56 @@
+package storage
+
+// PageStore is the backend the buffer pool reads pages from and writes them
+// to. The storage engine uses the file-based DiskManager unless
+// Config.PageStore supplies another implementation, such as
+// MemoryPageStore, MmapDiskManager or encryption.EncryptedDiskManager.
+//
+// Implementations must be safe for concurrent use and honour this contract:
+//   - ReadPage returns the page last written with the given ID. A page that
+//     was allocated but never written reads back as a new, empty data page.
+//   - WritePage and WritePages store a copy of the page; changes to the Page
+//     after the call don't reach the store until it is written again.
+//   - AllocatePage returns an ID not in use, reusing deallocated IDs before
+//     growing. DeallocatePage returns an ID to the store for reuse.
+//   - Sync makes every write so far durable; stores without durability
+//     return nil. Close syncs and releases the store.
+//   - PageSize reports the fixed size of every page in the store; WritePage
+//     rejects pages whose contents don't fit in it.
+//   - Stats reports at least "next_page_id", "free_pages", "used_pages",
+//     "total_reads" and "total_writes".
+type PageStore interface {
+	ReadPage(pageID PageID) (*Page, error)
+	WritePage(page *Page) error
+	WritePages(pages []*Page) error
+	AllocatePage() (PageID, error)
+	DeallocatePage(pageID PageID) error
+	Sync() error
+	Close() error
+	PageSize() int
+	Stats() map[string]interface{}
+}
+
+// PageVerifier is implemented by page stores that keep page checksums and
+// can take corrupt pages out of service
+type PageVerifier interface {
+	VerifyPage(pageID PageID) error
+	VerifyAllPages() ([]*ChecksumError, error)
+	QuarantinePage(pageID PageID) error
+}
+
+// PageImageLogger is implemented by page stores that can hand the full image
+// of every page to a PageImageLog before writing it in place, so a page torn
+// by a crash in the middle of the write can be redone from the log
+type PageImageLogger interface {
+	SetPageImageLog(log PageImageLog)
+}
+
+var (
+	_ PageStore       = (*DiskManager)(nil)
+	_ PageVerifier    = (*DiskManager)(nil)
+	_ PageImageLogger = (*DiskManager)(nil)
+	_ PageStore       = (*MmapDiskManager)(nil)
+	_ PageVerifier    = (*MmapDiskManager)(nil)
+	_ PageImageLogger = (*MmapDiskManager)(nil)
+	_ PageStore       = (*MemoryPageStore)(nil)
+)
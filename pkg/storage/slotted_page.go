@@ -12,7 +12,7 @@ const (
 	// SlotEntrySize is the size of each slot directory entry (5 bytes)
 	SlotEntrySize = 5
 
-	// SlottedPageAvailableSpace is the available space for slots and data
+	// SlottedPageAvailableSpace is the available space for slots and data in a default-size page
 	// PageSize - PageHeaderSize - SlottedPageHeaderSize
 	SlottedPageAvailableSpace = PageSize - PageHeaderSize - SlottedPageHeaderSize
 
@@ -173,7 +173,8 @@ func (sp *SlottedPage) deserializeSlots() error {
 	return nil
 }
 
-// InsertSlot inserts data into a new slot and returns the slot ID
+// InsertSlot inserts data into a new slot and returns the slot ID. The entry
+// of a deleted slot is reused before the slot directory grows.
 func (sp *SlottedPage) InsertSlot(data []byte) (uint16, error) {
 	dataLen := len(data)
 	if dataLen == 0 {
@@ -187,9 +188,13 @@ func (sp *SlottedPage) InsertSlot(data []byte) (uint16, error) {
 		}
 	}
 
-	// Calculate space needed
+	// Calculate space needed, reusing the entry of a deleted slot if any
 	spaceNeeded := uint16(dataLen)
+	slotID, reuse := sp.deletedSlot()
 	slotNeeded := SlotEntrySize
+	if reuse {
+		slotNeeded = 0
+	}
 
 	// Check if we have enough contiguous free space
 	contiguousFree := sp.ContiguousFreeSpace()
@@ -198,8 +203,10 @@ func (sp *SlottedPage) InsertSlot(data []byte) (uint16, error) {
 	}
 
 	// Allocate slot
-	slotID := sp.header.SlotCount
-	sp.header.SlotCount++
+	if !reuse {
+		slotID = sp.header.SlotCount
+		sp.header.SlotCount++
+	}
 
 	// Update free space start (slot directory grows down)
 	sp.header.FreeSpaceStart = SlottedPageHeaderSize + sp.header.SlotCount*SlotEntrySize
@@ -216,7 +223,11 @@ func (sp *SlottedPage) InsertSlot(data []byte) (uint16, error) {
 	}
 
 	// Add slot to in-memory array
-	sp.slots = append(sp.slots, slot)
+	if reuse {
+		sp.slots[slotID] = slot
+	} else {
+		sp.slots = append(sp.slots, slot)
+	}
 
 	// Write data to page
 	copy(sp.page.Data[dataOffset:dataOffset+spaceNeeded], data)
@@ -231,6 +242,16 @@ func (sp *SlottedPage) InsertSlot(data []byte) (uint16, error) {
 	return slotID, nil
 }
 
+// deletedSlot returns the first deleted slot, whose entry can be reused
+func (sp *SlottedPage) deletedSlot() (uint16, bool) {
+	for i := uint16(0); i < sp.header.SlotCount; i++ {
+		if sp.slots[i].IsDeleted() {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
 // GetSlot retrieves data from a slot
 func (sp *SlottedPage) GetSlot(slotID uint16) ([]byte, error) {
 	if slotID >= sp.header.SlotCount {
@@ -369,37 +390,23 @@ func (sp *SlottedPage) NeedsCompaction() bool {
 	if sp.header.FragmentedSpace == 0 {
 		return false
 	}
-	pageSize := float64(SlottedPageAvailableSpace)
+	pageSize := float64(len(sp.page.Data) - SlottedPageHeaderSize)
 	fragmented := float64(sp.header.FragmentedSpace)
 	return fragmented/pageSize > PageCompactionThreshold
 }
 
-// Compact defragments the page by removing deleted slots and reorganizing data
+// Compact defragments the page by moving the data of active slots together.
+// Slot IDs stay the same so references to records remain valid: deleted
+// slots are kept as empty entries for InsertSlot to reuse, and only
+// trailing deleted slots are dropped from the slot directory.
 func (sp *SlottedPage) Compact() error {
-	// Create new slots array (only active slots)
-	newSlots := []SlotEntry{}
-	newSlotMapping := make(map[uint16]uint16) // old slot ID -> new slot ID
-
-	// Collect active slots
-	for i := uint16(0); i < sp.header.SlotCount; i++ {
-		if !sp.slots[i].IsDeleted() {
-			newSlotID := uint16(len(newSlots))
-			newSlotMapping[i] = newSlotID
-			newSlots = append(newSlots, sp.slots[i])
-		}
-	}
-
-	// If no active slots, reset page
-	if len(newSlots) == 0 {
-		sp.header.SlotCount = 0
-		sp.header.FreeSpaceStart = SlottedPageHeaderSize
-		sp.header.FreeSpaceEnd = uint16(len(sp.page.Data))
-		sp.header.FragmentedSpace = 0
-		sp.slots = []SlotEntry{}
-		sp.serializeHeader()
-		sp.page.MarkDirty()
-		return nil
+	// Drop trailing deleted slots
+	slotCount := sp.header.SlotCount
+	for slotCount > 0 && sp.slots[slotCount-1].IsDeleted() {
+		slotCount--
 	}
+	newSlots := make([]SlotEntry, slotCount)
+	copy(newSlots, sp.slots[:slotCount])
 
 	// Create temporary buffer for reorganized data
 	tempData := make([]byte, len(sp.page.Data))
@@ -409,8 +416,9 @@ func (sp *SlottedPage) Compact() error {
 	for i := len(newSlots) - 1; i >= 0; i-- {
 		slot := &newSlots[i]
 
-		// Skip deleted slots (shouldn't happen but be safe)
 		if slot.IsDeleted() {
+			slot.Offset = 0
+			slot.Length = 0
 			continue
 		}
 
@@ -426,12 +434,13 @@ func (sp *SlottedPage) Compact() error {
 		newDataEnd = newOffset
 	}
 
-	// Copy reorganized data back to page
-	copy(sp.page.Data, tempData)
+	// Copy reorganized data back to page, clearing the freed space
+	freeSpaceStart := SlottedPageHeaderSize + slotCount*SlotEntrySize
+	copy(sp.page.Data[freeSpaceStart:], tempData[freeSpaceStart:])
 
 	// Update header
-	sp.header.SlotCount = uint16(len(newSlots))
-	sp.header.FreeSpaceStart = SlottedPageHeaderSize + sp.header.SlotCount*SlotEntrySize
+	sp.header.SlotCount = slotCount
+	sp.header.FreeSpaceStart = freeSpaceStart
 	sp.header.FreeSpaceEnd = newDataEnd
 	sp.header.FragmentedSpace = 0
 
@@ -390,23 +390,37 @@ func TestCompaction(t *testing.T) {
 		t.Errorf("Expected FragmentedSpace = 0 after compaction, got %d", sp.header.FragmentedSpace)
 	}
 
-	// Should have only 3 active slots
-	if sp.header.SlotCount != 3 {
-		t.Errorf("Expected SlotCount = 3 after compaction, got %d", sp.header.SlotCount)
+	// Slot IDs survive compaction, deleted slots stay deleted
+	if sp.header.SlotCount != 5 {
+		t.Errorf("Expected SlotCount = 5 after compaction, got %d", sp.header.SlotCount)
 	}
 
 	// Verify remaining data is intact
-	remainingItems := [][]byte{items[0], items[2], items[4]}
-	for i := uint16(0); i < sp.header.SlotCount; i++ {
-		data, err := sp.GetSlot(i)
+	for i, slotID := range slotIDs {
+		data, err := sp.GetSlot(slotID)
+		if i == 1 || i == 3 {
+			if err == nil {
+				t.Errorf("Expected slot %d to stay deleted after compaction", slotID)
+			}
+			continue
+		}
 		if err != nil {
-			t.Fatalf("Failed to get slot %d after compaction: %v", i, err)
+			t.Fatalf("Failed to get slot %d after compaction: %v", slotID, err)
 		}
 
-		if !bytes.Equal(remainingItems[i], data) {
-			t.Errorf("Data mismatch after compaction for slot %d: expected %s, got %s", i, remainingItems[i], data)
+		if !bytes.Equal(items[i], data) {
+			t.Errorf("Data mismatch after compaction for slot %d: expected %s, got %s", slotID, items[i], data)
 		}
 	}
+
+	// A new insert reuses the first deleted slot
+	slotID, err := sp.InsertSlot([]byte("Item 6"))
+	if err != nil {
+		t.Fatalf("Failed to insert slot after compaction: %v", err)
+	}
+	if slotID != slotIDs[1] {
+		t.Errorf("Expected insert to reuse slot %d, got %d", slotIDs[1], slotID)
+	}
 }
 
 func TestCompaction_EmptyPage(t *testing.T) {
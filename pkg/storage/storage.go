@@ -5,29 +5,80 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // StorageEngine manages data persistence with WAL and buffer pool
 type StorageEngine struct {
-	diskMgr    *DiskManager
+	diskMgr    PageStore
 	bufferPool *BufferPool
 	wal        *WAL
 	mu         sync.RWMutex
 	dataDir    string
 	isOpen     bool
+
+	// Checkpointing
+	checkpointWALSize  int64
+	checkpointInterval time.Duration
+	checkpointCh       chan struct{} // Signals the checkpointer that the WAL grew past checkpointWALSize
+	checkpointStop     chan struct{}
+	checkpointStopOnce sync.Once
+	checkpointWg       sync.WaitGroup
+	checkpoints        int64
+	lastCheckpoint     time.Time
+	lastCheckpointErr  error
+
+	// Full-page writes (see page_image.go)
+	fullPageWrites bool
+	redonePages    atomic.Int64 // Torn pages rewritten from their WAL image
 }
 
 // Config holds storage engine configuration
 type Config struct {
 	DataDir        string
 	BufferPoolSize int // Number of pages to cache
+
+	// PageSize is the page size of a new data file: 4096, 8192, 16384 or
+	// 32768 bytes (0 uses PageSize). An existing data file keeps the size it
+	// was created with, and opening it with a different non-zero PageSize
+	// fails with ErrPageSizeMismatch. Ignored when PageStore is set.
+	PageSize int
+
+	// CheckpointWALSize triggers a checkpoint once the WAL grows past this
+	// many bytes (0 disables size-based checkpoints)
+	CheckpointWALSize int64
+
+	// CheckpointInterval triggers a checkpoint periodically
+	// (0 disables interval-based checkpoints)
+	CheckpointInterval time.Duration
+
+	// GroupCommit batches concurrent commits behind a single WAL fsync
+	GroupCommit bool
+
+	// GroupCommitLinger is how long a syncing commit waits for others to
+	// join its group (0 syncs immediately)
+	GroupCommitLinger time.Duration
+
+	// PageStore keeps the pages (nil uses a DiskManager on data.db in
+	// DataDir). The engine closes it on Close.
+	PageStore PageStore
+
+	// FullPageWrites logs the image of every page to the WAL before the
+	// page store writes it in place, so recovery can redo pages torn by a
+	// crash. Ignored for page stores that don't implement PageImageLogger.
+	FullPageWrites bool
 }
 
 // DefaultConfig returns default configuration
 func DefaultConfig(dataDir string) *Config {
 	return &Config{
-		DataDir:        dataDir,
-		BufferPoolSize: 1000, // Cache 1000 pages (~4MB)
+		DataDir:            dataDir,
+		BufferPoolSize:     1000,             // Cache 1000 pages (~4MB)
+		CheckpointWALSize:  64 * 1024 * 1024, // Checkpoint every 64MB of WAL
+		CheckpointInterval: 5 * time.Minute,
+		GroupCommit:        true,
+		FullPageWrites:     true,
 	}
 }
 
@@ -38,11 +89,15 @@ func NewStorageEngine(config *Config) (*StorageEngine, error) {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	// Open disk manager
-	dataPath := filepath.Join(config.DataDir, "data.db")
-	diskMgr, err := NewDiskManager(dataPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create disk manager: %w", err)
+	// Open disk manager unless another page store was given
+	diskMgr := config.PageStore
+	if diskMgr == nil {
+		dataPath := filepath.Join(config.DataDir, "data.db")
+		fileMgr, err := NewDiskManagerWithPageSize(dataPath, config.PageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create disk manager: %w", err)
+		}
+		diskMgr = fileMgr
 	}
 
 	// Open WAL
@@ -53,15 +108,21 @@ func NewStorageEngine(config *Config) (*StorageEngine, error) {
 		return nil, fmt.Errorf("failed to create WAL: %w", err)
 	}
 
+	wal.SetGroupCommit(config.GroupCommit, config.GroupCommitLinger)
+
 	// Create buffer pool
 	bufferPool := NewBufferPool(config.BufferPoolSize, diskMgr)
 
 	engine := &StorageEngine{
-		diskMgr:    diskMgr,
-		bufferPool: bufferPool,
-		wal:        wal,
-		dataDir:    config.DataDir,
-		isOpen:     true,
+		diskMgr:            diskMgr,
+		bufferPool:         bufferPool,
+		wal:                wal,
+		dataDir:            config.DataDir,
+		isOpen:             true,
+		checkpointWALSize:  config.CheckpointWALSize,
+		checkpointInterval: config.CheckpointInterval,
+		checkpointCh:       make(chan struct{}, 1),
+		checkpointStop:     make(chan struct{}),
 	}
 
 	// Perform recovery if needed
@@ -70,16 +131,37 @@ func NewStorageEngine(config *Config) (*StorageEngine, error) {
 		return nil, fmt.Errorf("failed to recover: %w", err)
 	}
 
+	if logger, ok := diskMgr.(PageImageLogger); ok && config.FullPageWrites {
+		logger.SetPageImageLog(engine.logPageImages)
+		engine.fullPageWrites = true
+	}
+
+	engine.startCheckpointer()
+
 	return engine, nil
 }
 
-// recover performs crash recovery by replaying the WAL
+// recover performs crash recovery by replaying the WAL from the last checkpoint
 func (se *StorageEngine) recover() error {
 	records, err := se.wal.Replay()
 	if err != nil {
 		return fmt.Errorf("failed to replay WAL: %w", err)
 	}
 
+	// Pages torn by a crash are rewritten first, so the pages read below are
+	// intact. Every image still in the WAL may be needed (see checkpoint).
+	if err := se.redoTornPages(records); err != nil {
+		return err
+	}
+
+	// Everything before the last checkpoint is already on disk
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Type == LogRecordCheckpoint {
+			records = records[i+1:]
+			break
+		}
+	}
+
 	if len(records) == 0 {
 		return nil // Nothing to recover
 	}
@@ -101,7 +183,6 @@ func (se *StorageEngine) recover() error {
 			se.bufferPool.UnpinPage(record.PageID, true)
 
 		case LogRecordCheckpoint:
-			// Checkpoint - can skip earlier records in production
 			continue
 
 		case LogRecordCommit, LogRecordAbort:
@@ -146,6 +227,12 @@ func (se *StorageEngine) FlushPage(pageID PageID) error {
 	return se.bufferPool.FlushPage(pageID)
 }
 
+// ResizeBufferPool changes the number of pages the buffer pool holds (see
+// BufferPool.Resize)
+func (se *StorageEngine) ResizeBufferPool(capacity int) error {
+	return se.bufferPool.Resize(capacity)
+}
+
 // FlushAll writes all dirty pages to disk
 func (se *StorageEngine) FlushAll() error {
 	if err := se.bufferPool.FlushAllPages(); err != nil {
@@ -156,31 +243,139 @@ func (se *StorageEngine) FlushAll() error {
 
 // LogOperation writes an operation to the WAL
 func (se *StorageEngine) LogOperation(record *LogRecord) (uint64, error) {
-	return se.wal.Append(record)
+	lsn, err := se.wal.Append(record)
+	if err != nil {
+		return 0, err
+	}
+
+	se.wakeCheckpointer()
+	return lsn, nil
+}
+
+// wakeCheckpointer wakes the checkpointer once the WAL grows past the
+// configured size
+func (se *StorageEngine) wakeCheckpointer() {
+	if se.checkpointWALSize > 0 && se.wal.Size() >= se.checkpointWALSize {
+		select {
+		case se.checkpointCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// LogCommit writes a commit record for a transaction and blocks until it is
+// durable. Concurrent commits share fsyncs when group commit is enabled.
+func (se *StorageEngine) LogCommit(txnID uint64) (uint64, error) {
+	lsn, err := se.wal.AppendDurable(&LogRecord{
+		Type:  LogRecordCommit,
+		TxnID: txnID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to log commit: %w", err)
+	}
+
+	if se.checkpointWALSize > 0 && se.wal.Size() >= se.checkpointWALSize {
+		select {
+		case se.checkpointCh <- struct{}{}:
+		default:
+		}
+	}
+
+	return lsn, nil
 }
 
-// Checkpoint creates a checkpoint in the WAL
+// Checkpoint flushes all dirty pages to disk, writes a checkpoint record and
+// truncates the WAL up to it, so recovery only replays later records
 func (se *StorageEngine) Checkpoint() error {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+
+	if !se.isOpen {
+		return fmt.Errorf("storage engine is closed")
+	}
+
+	err := se.checkpoint()
+	se.lastCheckpointErr = err
+	return err
+}
+
+// checkpoint performs a checkpoint
+// Must be called with se.mu held
+func (se *StorageEngine) checkpoint() error {
 	// Flush all dirty pages
 	if err := se.bufferPool.FlushAllPages(); err != nil {
 		return fmt.Errorf("failed to flush pages: %w", err)
 	}
 
+	// Page images logged so far belong to writes the sync below makes
+	// durable. Writes logged later may still be in flight when it runs.
+	syncedLSN := se.wal.CurrentLSN()
+
+	// Sync disk so the checkpoint never points past unsynced pages
+	if err := se.diskMgr.Sync(); err != nil {
+		return fmt.Errorf("failed to sync disk: %w", err)
+	}
+
 	// Write checkpoint record
 	if err := se.wal.Checkpoint(); err != nil {
 		return fmt.Errorf("failed to write checkpoint: %w", err)
 	}
 
-	// Sync disk
-	if err := se.diskMgr.Sync(); err != nil {
-		return fmt.Errorf("failed to sync disk: %w", err)
+	// Drop everything before the checkpoint record, keeping the images of
+	// pages written during the checkpoint
+	truncateLSN := se.wal.LastCheckpointLSN()
+	if se.fullPageWrites && syncedLSN+1 < truncateLSN {
+		truncateLSN = syncedLSN + 1
+	}
+	if err := se.wal.Truncate(truncateLSN); err != nil {
+		return fmt.Errorf("failed to truncate WAL: %w", err)
 	}
 
+	se.checkpoints++
+	se.lastCheckpoint = time.Now()
 	return nil
 }
 
+// startCheckpointer starts the background goroutine that checkpoints by
+// interval and when the WAL exceeds its size limit
+func (se *StorageEngine) startCheckpointer() {
+	if se.checkpointInterval <= 0 && se.checkpointWALSize <= 0 {
+		return
+	}
+
+	se.checkpointWg.Add(1)
+	go se.checkpointLoop()
+}
+
+// checkpointLoop runs checkpoints until the engine is closed
+func (se *StorageEngine) checkpointLoop() {
+	defer se.checkpointWg.Done()
+
+	var tick <-chan time.Time
+	if se.checkpointInterval > 0 {
+		ticker := time.NewTicker(se.checkpointInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-se.checkpointStop:
+			return
+		case <-tick:
+			se.Checkpoint()
+		case <-se.checkpointCh:
+			se.Checkpoint()
+		}
+	}
+}
+
 // Close closes the storage engine
 func (se *StorageEngine) Close() error {
+	// Stop the checkpointer before taking the lock it needs
+	se.checkpointStopOnce.Do(func() { close(se.checkpointStop) })
+	se.checkpointWg.Wait()
+
 	se.mu.Lock()
 	defer se.mu.Unlock()
 
@@ -212,14 +407,58 @@ func (se *StorageEngine) Stats() map[string]interface{} {
 	return map[string]interface{}{
 		"buffer_pool": se.bufferPool.Stats(),
 		"disk":        se.diskMgr.Stats(),
+		"wal":         se.walStats(),
 	}
 }
 
-// DiskManager returns the disk manager
-func (se *StorageEngine) DiskManager() *DiskManager {
+// walStats returns WAL and checkpoint statistics
+func (se *StorageEngine) walStats() map[string]interface{} {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+
+	stats := map[string]interface{}{
+		"size_bytes":          se.wal.Size(),
+		"current_lsn":         se.wal.CurrentLSN(),
+		"last_checkpoint_lsn": se.wal.LastCheckpointLSN(),
+		"checkpoints":         se.checkpoints,
+		"checkpoint_wal_size": se.checkpointWALSize,
+		"checkpoint_interval": se.checkpointInterval.String(),
+		"commits":             se.wal.CommitStats(),
+		"full_page_writes":    se.fullPageWrites,
+		"redone_pages":        se.redonePages.Load(),
+	}
+	if !se.lastCheckpoint.IsZero() {
+		stats["last_checkpoint"] = se.lastCheckpoint
+	}
+	if se.lastCheckpointErr != nil {
+		stats["last_checkpoint_error"] = se.lastCheckpointErr.Error()
+	}
+
+	return stats
+}
+
+// WAL returns the write-ahead log
+func (se *StorageEngine) WAL() *WAL {
+	return se.wal
+}
+
+// PageSize returns the size of the pages in the page store
+func (se *StorageEngine) PageSize() int {
+	return se.diskMgr.PageSize()
+}
+
+// PageStore returns the page store
+func (se *StorageEngine) PageStore() PageStore {
 	return se.diskMgr
 }
 
+// DiskManager returns the disk manager, or nil if the engine uses another
+// page store
+func (se *StorageEngine) DiskManager() *DiskManager {
+	dm, _ := se.diskMgr.(*DiskManager)
+	return dm
+}
+
 // ensureDir creates a directory if it doesn't exist
 func ensureDir(path string) error {
 	return os.MkdirAll(path, 0755)
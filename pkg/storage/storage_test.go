@@ -3,6 +3,7 @@ package storage
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestNewStorageEngine(t *testing.T) {
@@ -640,3 +641,107 @@ func TestFlushAllErrors(t *testing.T) {
 		t.Error("Expected error when flushing all on closed engine")
 	}
 }
+
+func TestCheckpointTruncatesWAL(t *testing.T) {
+	dir := t.TempDir()
+
+	config := DefaultConfig(dir)
+	config.CheckpointInterval = 0
+	config.CheckpointWALSize = 0
+	engine, err := NewStorageEngine(config)
+	if err != nil {
+		t.Fatalf("Failed to create storage engine: %v", err)
+	}
+	defer engine.Close()
+
+	for i := 0; i < 10; i++ {
+		if _, err := engine.LogOperation(&LogRecord{Type: LogRecordInsert, TxnID: uint64(i), Data: []byte("record")}); err != nil {
+			t.Fatalf("LogOperation failed: %v", err)
+		}
+	}
+
+	if err := engine.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+
+	walStats := engine.Stats()["wal"].(map[string]interface{})
+	if walStats["checkpoints"].(int64) != 1 {
+		t.Errorf("Expected 1 checkpoint, got %v", walStats["checkpoints"])
+	}
+	if walStats["size_bytes"].(int64) != 33 {
+		t.Errorf("Expected WAL to hold only the checkpoint record (33 bytes), got %v", walStats["size_bytes"])
+	}
+	if walStats["last_checkpoint_lsn"].(uint64) != walStats["current_lsn"].(uint64) {
+		t.Errorf("Expected last checkpoint LSN %v to equal current LSN %v",
+			walStats["last_checkpoint_lsn"], walStats["current_lsn"])
+	}
+}
+
+func TestCheckpointOnWALSize(t *testing.T) {
+	dir := t.TempDir()
+
+	config := DefaultConfig(dir)
+	config.CheckpointInterval = 0
+	config.CheckpointWALSize = 1024
+	engine, err := NewStorageEngine(config)
+	if err != nil {
+		t.Fatalf("Failed to create storage engine: %v", err)
+	}
+	defer engine.Close()
+
+	data := make([]byte, 100)
+	for i := 0; i < 20; i++ {
+		engine.LogOperation(&LogRecord{Type: LogRecordInsert, TxnID: uint64(i), Data: data})
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for engine.Stats()["wal"].(map[string]interface{})["checkpoints"].(int64) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a checkpoint once the WAL exceeded its size limit")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRecoveryStartsFromCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+
+	config := DefaultConfig(dir)
+	config.CheckpointInterval = 0
+	config.CheckpointWALSize = 0
+	engine, err := NewStorageEngine(config)
+	if err != nil {
+		t.Fatalf("Failed to create storage engine: %v", err)
+	}
+
+	page, _ := engine.AllocatePage()
+	engine.UnpinPage(page.ID, true)
+	for i := 0; i < 5; i++ {
+		engine.LogOperation(&LogRecord{Type: LogRecordUpdate, PageID: page.ID})
+	}
+	if err := engine.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	checkpointLSN := engine.wal.LastCheckpointLSN()
+	engine.LogOperation(&LogRecord{Type: LogRecordUpdate, PageID: page.ID})
+	engine.Close()
+
+	engine, err = NewStorageEngine(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage engine: %v", err)
+	}
+	defer engine.Close()
+
+	if engine.wal.LastCheckpointLSN() != checkpointLSN {
+		t.Errorf("Expected last checkpoint LSN %d, got %d", checkpointLSN, engine.wal.LastCheckpointLSN())
+	}
+
+	// Only the checkpoint and the record after it remain to be replayed
+	records, err := engine.wal.Replay()
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("Expected 2 WAL records after checkpoint, got %d", len(records))
+	}
+}
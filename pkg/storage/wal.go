@@ -1,11 +1,13 @@
 package storage
 
 import (
+	"bufio"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
 	"sync"
+	"time"
 )
 
 // LogRecordType represents the type of WAL record
@@ -18,6 +20,8 @@ const (
 	LogRecordCheckpoint
 	LogRecordCommit
 	LogRecordAbort
+	LogRecordOperation // Logical operation of the layer above, kept for WAL archiving; recovery skips it
+	LogRecordPageImage // Full image of a page about to be written in place; recovery redoes torn pages from it
 )
 
 // LogRecord represents a single WAL entry
@@ -32,11 +36,31 @@ type LogRecord struct {
 
 // WAL (Write-Ahead Log) ensures durability
 type WAL struct {
-	file       *os.File
-	mu         sync.Mutex
-	currentLSN uint64
-	buffer     []byte
-	bufferSize int
+	file              *os.File
+	path              string
+	mu                sync.Mutex
+	currentLSN        uint64
+	buffer            []byte
+	bufferSize        int
+	size              int64  // Current size of the WAL file in bytes
+	lastCheckpointLSN uint64 // LSN of the most recent checkpoint record
+	discardedLSN      uint64 // Highest LSN removed by Truncate
+
+	// Records after holdLSN survive truncation while the log is below
+	// holdMaxBytes, so an archiver reading the log doesn't miss them
+	holding      bool
+	holdLSN      uint64
+	holdMaxBytes int64
+
+	// Group commit: concurrent AppendDurable callers share one fsync
+	groupCommit  bool
+	commitLinger time.Duration // How long a sync leader waits for more commits to join
+	syncMu       sync.Mutex
+	syncCond     *sync.Cond
+	syncing      bool   // A leader is currently syncing
+	durableLSN   uint64 // All records up to this LSN are synced to disk
+	syncs        int64  // Number of fsyncs issued for durable appends
+	durableCount int64  // Number of durable appends
 }
 
 // NewWAL creates a new Write-Ahead Log
@@ -53,12 +77,163 @@ func NewWAL(path string) (*WAL, error) {
 		return nil, fmt.Errorf("failed to seek WAL file: %w", err)
 	}
 
-	return &WAL{
-		file:       file,
-		currentLSN: uint64(pos),
-		buffer:     make([]byte, 0, 4096),
-		bufferSize: 4096,
-	}, nil
+	w := &WAL{
+		file:        file,
+		path:        path,
+		currentLSN:  uint64(pos),
+		buffer:      make([]byte, 0, 4096),
+		bufferSize:  4096,
+		size:        pos,
+		groupCommit: true,
+	}
+	w.syncCond = sync.NewCond(&w.syncMu)
+
+	// LSNs must keep increasing across truncations, so continue from the
+	// highest LSN still in the log if it is ahead of the file size
+	records, err := w.readRecords()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	for _, record := range records {
+		if record.LSN > w.currentLSN {
+			w.currentLSN = record.LSN
+		}
+		if record.Type == LogRecordCheckpoint {
+			w.lastCheckpointLSN = record.LSN
+		}
+	}
+	w.durableLSN = w.currentLSN
+
+	// A crash in the middle of an append leaves an incomplete record at the
+	// end; cut it off so new records follow the last complete one
+	var end int64
+	for _, record := range records {
+		end += int64(33 + len(record.Data))
+	}
+	if end < pos {
+		if err := file.Truncate(end); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to truncate incomplete WAL record: %w", err)
+		}
+		w.size = end
+	}
+
+	// Records before the first one were removed by an earlier truncation
+	if len(records) > 0 {
+		w.discardedLSN = records[0].LSN - 1
+	}
+
+	return w, nil
+}
+
+// SetGroupCommit enables or disables group commit for AppendDurable.
+// With group commit, concurrent callers are batched behind a single fsync;
+// linger makes the syncing caller wait briefly so more commits can join.
+// Without it, every AppendDurable issues its own fsync.
+func (w *WAL) SetGroupCommit(enabled bool, linger time.Duration) {
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+
+	w.groupCommit = enabled
+	w.commitLinger = linger
+}
+
+// AppendDurable writes a log record and blocks until it is synced to disk
+func (w *WAL) AppendDurable(record *LogRecord) (uint64, error) {
+	lsn, err := w.Append(record)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := w.waitDurable(lsn); err != nil {
+		return 0, err
+	}
+
+	return lsn, nil
+}
+
+// Sync blocks until every record appended so far is synced to disk. Like
+// AppendDurable, concurrent callers share an fsync under group commit.
+func (w *WAL) Sync() error {
+	return w.waitDurable(w.CurrentLSN())
+}
+
+// waitDurable blocks until lsn is synced. The first waiter becomes the sync
+// leader and issues one fsync covering every record appended so far; the
+// others wait for it and return without syncing if their record was covered.
+func (w *WAL) waitDurable(lsn uint64) error {
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+
+	w.durableCount++
+
+	if !w.groupCommit {
+		w.syncMu.Unlock()
+		err := w.Flush()
+		w.syncMu.Lock()
+		if err != nil {
+			return fmt.Errorf("failed to sync WAL: %w", err)
+		}
+		w.syncs++
+		if lsn > w.durableLSN {
+			w.durableLSN = lsn
+		}
+		return nil
+	}
+
+	for w.durableLSN < lsn {
+		if w.syncing {
+			w.syncCond.Wait()
+			continue
+		}
+
+		// Become the leader for the next group
+		w.syncing = true
+		linger := w.commitLinger
+		w.syncMu.Unlock()
+
+		if linger > 0 {
+			time.Sleep(linger)
+		}
+		target := w.CurrentLSN()
+		err := w.Flush()
+
+		w.syncMu.Lock()
+		w.syncing = false
+		if err == nil {
+			w.syncs++
+			if target > w.durableLSN {
+				w.durableLSN = target
+			}
+		}
+		w.syncCond.Broadcast()
+
+		if err != nil {
+			return fmt.Errorf("failed to sync WAL: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// CommitStats returns group commit statistics
+func (w *WAL) CommitStats() map[string]interface{} {
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+
+	avgGroupSize := 0.0
+	if w.syncs > 0 {
+		avgGroupSize = float64(w.durableCount) / float64(w.syncs)
+	}
+
+	return map[string]interface{}{
+		"group_commit":   w.groupCommit,
+		"commit_linger":  w.commitLinger.String(),
+		"durable_writes": w.durableCount,
+		"syncs":          w.syncs,
+		"avg_group_size": avgGroupSize,
+	}
 }
 
 // Append writes a log record to the WAL and returns its LSN
@@ -77,6 +252,7 @@ func (w *WAL) Append(record *LogRecord) (uint64, error) {
 	if _, err := w.file.Write(data); err != nil {
 		return 0, fmt.Errorf("failed to write WAL record: %w", err)
 	}
+	w.size += int64(len(data))
 
 	return record.LSN, nil
 }
@@ -136,6 +312,12 @@ func (w *WAL) Replay() ([]*LogRecord, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	return w.readRecords()
+}
+
+// readRecords reads every complete record in the WAL file
+// Must be called with w.mu held
+func (w *WAL) readRecords() ([]*LogRecord, error) {
 	// Seek to beginning
 	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
 		return nil, fmt.Errorf("failed to seek WAL: %w", err)
@@ -143,6 +325,7 @@ func (w *WAL) Replay() ([]*LogRecord, error) {
 
 	records := make([]*LogRecord, 0)
 	buf := make([]byte, 4096)
+	var offset int64
 
 	for {
 		// Read record header
@@ -159,6 +342,9 @@ func (w *WAL) Replay() ([]*LogRecord, error) {
 
 		// Read data length
 		dataLen := binary.LittleEndian.Uint32(buf[29:33])
+		if offset+33+int64(dataLen) > w.size {
+			break // Incomplete record at end
+		}
 
 		// Read full record
 		fullRecord := make([]byte, 33+dataLen)
@@ -177,6 +363,7 @@ func (w *WAL) Replay() ([]*LogRecord, error) {
 		}
 
 		records = append(records, record)
+		offset += int64(len(fullRecord))
 	}
 
 	// Seek back to end
@@ -185,7 +372,7 @@ func (w *WAL) Replay() ([]*LogRecord, error) {
 	return records, nil
 }
 
-// Checkpoint writes a checkpoint record
+// Checkpoint writes a checkpoint record and syncs the WAL
 func (w *WAL) Checkpoint() error {
 	record := &LogRecord{
 		Type:  LogRecordCheckpoint,
@@ -193,21 +380,173 @@ func (w *WAL) Checkpoint() error {
 		Data:  nil,
 	}
 
-	_, err := w.Append(record)
+	lsn, err := w.Append(record)
 	if err != nil {
 		return err
 	}
 
-	return w.Flush()
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.lastCheckpointLSN = lsn
+	w.mu.Unlock()
+
+	return nil
 }
 
-// Truncate removes WAL records before the given LSN
+// Truncate removes WAL records before the given LSN. The remaining records
+// are rewritten to a new file which atomically replaces the old log.
 func (w *WAL) Truncate(beforeLSN uint64) error {
-	// In production, would implement WAL archival and truncation
-	// For educational purposes, we'll just note this is where it would happen
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	records, err := w.readRecords()
+	if err != nil {
+		return err
+	}
+
+	if w.holding && beforeLSN > w.holdLSN+1 && w.size <= w.holdMaxBytes {
+		beforeLSN = w.holdLSN + 1
+	}
+
+	kept := make([]byte, 0)
+	discarded := w.discardedLSN
+	for _, record := range records {
+		if record.LSN >= beforeLSN {
+			kept = append(kept, w.serializeRecord(record)...)
+		} else if record.LSN > discarded {
+			discarded = record.LSN
+		}
+	}
+	if int64(len(kept)) == w.size {
+		return nil // Nothing to remove
+	}
+
+	tmpPath := w.path + ".tmp"
+	if err := os.WriteFile(tmpPath, kept, 0644); err != nil {
+		return fmt.Errorf("failed to write truncated WAL: %w", err)
+	}
+
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open truncated WAL: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync truncated WAL: %w", err)
+	}
+	tmp.Close()
+
+	if err := os.Rename(tmpPath, w.path); err != nil {
+		return fmt.Errorf("failed to replace WAL: %w", err)
+	}
+
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen WAL file: %w", err)
+	}
+	w.file.Close()
+	w.file = file
+	w.size = int64(len(kept))
+	w.discardedLSN = discarded
+
 	return nil
 }
 
+// Hold keeps the records after lsn through truncations, as long as the log
+// is no larger than maxBytes. A reader that has consumed the log up to lsn,
+// such as a WAL archiver, then sees every later record. Checkpoints in a
+// log grown past maxBytes truncate as usual, so a stalled reader can't fill
+// the disk.
+func (w *WAL) Hold(lsn uint64, maxBytes int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.holding = true
+	w.holdLSN = lsn
+	w.holdMaxBytes = maxBytes
+}
+
+// ReleaseHold lets truncations remove records held by Hold
+func (w *WAL) ReleaseHold() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.holding = false
+}
+
+// DiscardedLSN returns the highest LSN of a record removed by Truncate, in
+// this session or before the log was opened. A reader that consumed the log
+// only up to an LSN below it missed records.
+func (w *WAL) DiscardedLSN() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.discardedLSN
+}
+
+// RecordsAfter returns the complete records with an LSN above lsn. It reads
+// the log through its own file handle, so appends continue meanwhile; a
+// record still being written at the end of the log is left out.
+func (w *WAL) RecordsAfter(lsn uint64) ([]*LogRecord, error) {
+	file, err := os.Open(w.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAL for reading: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	records := make([]*LogRecord, 0)
+	header := make([]byte, 33)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			break // End of log, or a record being written
+		}
+		dataLen := binary.LittleEndian.Uint32(header[29:33])
+		data := make([]byte, 33+dataLen)
+		copy(data, header)
+		if _, err := io.ReadFull(reader, data[33:]); err != nil {
+			break
+		}
+
+		record, err := w.deserializeRecord(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize WAL record: %w", err)
+		}
+		if record.LSN > lsn {
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+// Size returns the current size of the WAL file in bytes
+func (w *WAL) Size() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.size
+}
+
+// CurrentLSN returns the LSN of the most recently appended record
+func (w *WAL) CurrentLSN() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.currentLSN
+}
+
+// LastCheckpointLSN returns the LSN of the most recent checkpoint record
+func (w *WAL) LastCheckpointLSN() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.lastCheckpointLSN
+}
+
 // Close closes the WAL file
 func (w *WAL) Close() error {
 	w.mu.Lock()
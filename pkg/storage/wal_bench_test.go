@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// BenchmarkWALCommit compares durable commit throughput with a per-commit
+// fsync against group commit at several concurrency levels. Run with:
+//
+//	go test -bench=BenchmarkWALCommit -run=^$ ./pkg/storage
+func BenchmarkWALCommit(b *testing.B) {
+	modes := []struct {
+		name   string
+		group  bool
+		linger time.Duration
+	}{
+		{"per-commit", false, 0},
+		{"group", true, 0},
+		{"group-linger-200us", true, 200 * time.Microsecond},
+	}
+
+	for _, mode := range modes {
+		for _, concurrency := range []int{1, 4, 16, 64} {
+			b.Run(fmt.Sprintf("%s/writers-%d", mode.name, concurrency), func(b *testing.B) {
+				wal, err := NewWAL(filepath.Join(b.TempDir(), "bench.wal"))
+				if err != nil {
+					b.Fatalf("Failed to create WAL: %v", err)
+				}
+				defer wal.Close()
+
+				wal.SetGroupCommit(mode.group, mode.linger)
+				data := make([]byte, 128)
+
+				// RunParallel uses parallelism*GOMAXPROCS goroutines
+				b.SetParallelism(concurrency)
+				b.ResetTimer()
+				start := time.Now()
+
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						if _, err := wal.AppendDurable(&LogRecord{Type: LogRecordCommit, Data: data}); err != nil {
+							b.Error(err)
+							return
+						}
+					}
+				})
+
+				elapsed := time.Since(start)
+				b.ReportMetric(float64(b.N)/elapsed.Seconds(), "commits/sec")
+
+				stats := wal.CommitStats()
+				b.ReportMetric(stats["avg_group_size"].(float64), "commits/fsync")
+			})
+		}
+	}
+}
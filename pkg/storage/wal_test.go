@@ -1,9 +1,12 @@
 package storage
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewWAL(t *testing.T) {
@@ -540,3 +543,131 @@ func TestWALFlushError(t *testing.T) {
 		t.Error("Expected error when flushing closed WAL")
 	}
 }
+
+func TestWALTruncateRemovesRecords(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.wal")
+
+	wal, err := NewWAL(path)
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+
+	wal.Append(&LogRecord{Type: LogRecordInsert, TxnID: 1, Data: []byte("old")})
+	wal.Append(&LogRecord{Type: LogRecordInsert, TxnID: 2, Data: []byte("old")})
+	if err := wal.Checkpoint(); err != nil {
+		t.Fatalf("Failed to checkpoint: %v", err)
+	}
+	checkpointLSN := wal.LastCheckpointLSN()
+	wal.Append(&LogRecord{Type: LogRecordInsert, TxnID: 3, Data: []byte("new")})
+
+	sizeBefore := wal.Size()
+	if err := wal.Truncate(checkpointLSN); err != nil {
+		t.Fatalf("Failed to truncate: %v", err)
+	}
+	if wal.Size() >= sizeBefore {
+		t.Errorf("Expected WAL to shrink from %d bytes, got %d", sizeBefore, wal.Size())
+	}
+
+	records, err := wal.Replay()
+	if err != nil {
+		t.Fatalf("Failed to replay: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records after truncate, got %d", len(records))
+	}
+	if records[0].Type != LogRecordCheckpoint || records[1].TxnID != 3 {
+		t.Errorf("Unexpected records after truncate: %+v", records)
+	}
+
+	lastLSN := wal.CurrentLSN()
+	wal.Close()
+
+	// LSNs keep increasing after reopening a truncated WAL
+	wal, err = NewWAL(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen WAL: %v", err)
+	}
+	defer wal.Close()
+
+	if wal.LastCheckpointLSN() != checkpointLSN {
+		t.Errorf("Expected last checkpoint LSN %d, got %d", checkpointLSN, wal.LastCheckpointLSN())
+	}
+	lsn, err := wal.Append(&LogRecord{Type: LogRecordInsert, TxnID: 4})
+	if err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	if lsn <= lastLSN {
+		t.Errorf("Expected LSN greater than %d after reopen, got %d", lastLSN, lsn)
+	}
+}
+
+func TestWALGroupCommit(t *testing.T) {
+	wal, err := NewWAL(filepath.Join(t.TempDir(), "test.wal"))
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	defer wal.Close()
+
+	wal.SetGroupCommit(true, 2*time.Millisecond)
+
+	const writers = 32
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			lsn, err := wal.AppendDurable(&LogRecord{Type: LogRecordCommit, TxnID: uint64(i)})
+			if err != nil {
+				errs <- err
+				return
+			}
+			if lsn == 0 {
+				errs <- fmt.Errorf("expected non-zero LSN")
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("AppendDurable failed: %v", err)
+	}
+
+	stats := wal.CommitStats()
+	if stats["durable_writes"].(int64) != writers {
+		t.Errorf("Expected %d durable writes, got %v", writers, stats["durable_writes"])
+	}
+	if stats["syncs"].(int64) >= writers {
+		t.Errorf("Expected fewer than %d syncs with group commit, got %v", writers, stats["syncs"])
+	}
+
+	records, err := wal.Replay()
+	if err != nil {
+		t.Fatalf("Failed to replay: %v", err)
+	}
+	if len(records) != writers {
+		t.Errorf("Expected %d records, got %d", writers, len(records))
+	}
+}
+
+func TestWALPerCommitSync(t *testing.T) {
+	wal, err := NewWAL(filepath.Join(t.TempDir(), "test.wal"))
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	defer wal.Close()
+
+	wal.SetGroupCommit(false, 0)
+
+	for i := 0; i < 5; i++ {
+		if _, err := wal.AppendDurable(&LogRecord{Type: LogRecordCommit, TxnID: uint64(i)}); err != nil {
+			t.Fatalf("AppendDurable failed: %v", err)
+		}
+	}
+
+	stats := wal.CommitStats()
+	if stats["syncs"].(int64) != 5 {
+		t.Errorf("Expected 5 syncs without group commit, got %v", stats["syncs"])
+	}
+}
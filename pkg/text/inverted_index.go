@@ -46,16 +46,31 @@ func NewInvertedIndex() *InvertedIndex {
 
 // Index adds a document to the inverted index
 func (idx *InvertedIndex) Index(docID string, text string) {
+	idx.IndexWeighted(docID, []string{text}, nil)
+}
+
+// IndexWeighted adds a document made of several texts (one per indexed
+// field) to the inverted index. Each occurrence of a term in texts[i] counts
+// weights[i] times towards its term frequency, so matches in heavily
+// weighted fields score higher. A nil weights slice weighs every text 1.
+func (idx *InvertedIndex) IndexWeighted(docID string, texts []string, weights []int) {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	// Analyze text into tokens
-	tokens := idx.analyzer.Analyze(text)
-
-	// Count term frequencies in this document
+	// Analyze texts into tokens and count weighted term frequencies
 	termFreqs := make(map[string]int)
-	for _, token := range tokens {
-		termFreqs[token]++
+	docLength := 0
+	for i, text := range texts {
+		weight := 1
+		if i < len(weights) && weights[i] > 0 {
+			weight = weights[i]
+		}
+
+		tokens := idx.analyzer.Analyze(text)
+		for _, token := range tokens {
+			termFreqs[token] += weight
+		}
+		docLength += len(tokens)
 	}
 
 	// Update inverted index
@@ -78,7 +93,7 @@ func (idx *InvertedIndex) Index(docID string, text string) {
 	}
 
 	// Update document length
-	idx.docLengths[docID] = len(tokens)
+	idx.docLengths[docID] = docLength
 	idx.totalDocs++
 
 	// Recalculate average document length
@@ -225,3 +225,22 @@ func TestCaseInsensitivity(t *testing.T) {
 		t.Fatal("Expected case-insensitive match for uppercase")
 	}
 }
+
+func TestIndexWeighted(t *testing.T) {
+	idx := NewInvertedIndex()
+
+	// Same words, but "database" appears in the heavily weighted first field of doc1 only
+	idx.IndexWeighted("doc1", []string{"database", "guide to storage"}, []int{10, 1})
+	idx.IndexWeighted("doc2", []string{"storage guide", "database"}, []int{10, 1})
+
+	results := idx.Search("database")
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].DocID != "doc1" {
+		t.Errorf("Expected doc1 to rank first, got %s", results[0].DocID)
+	}
+	if results[0].Score <= results[1].Score {
+		t.Errorf("Expected weighted match to score higher: %f <= %f", results[0].Score, results[1].Score)
+	}
+}
@@ -0,0 +1,125 @@
+package text
+
+import "strings"
+
+// SearchQuery is a parsed search string. Documents match if they contain
+// any of its terms, every one of its "quoted phrases", and none of its
+// -negated terms. Quoting single words therefore requires all of them:
+// `"coffee" "shop"`.
+type SearchQuery struct {
+	Terms    []string // Words of which any may match
+	Phrases  []string // Phrases that must all appear
+	Excluded []string // Words that must not appear
+
+	analyzer *Analyzer
+	phrases  [][]string // Analyzed tokens of each phrase
+	excluded []string   // Analyzed excluded words
+}
+
+// ParseSearch parses a search string into terms, "quoted phrases" and
+// -negated terms. An unterminated quote runs to the end of the string.
+func ParseSearch(search string) *SearchQuery {
+	sq := &SearchQuery{analyzer: NewAnalyzer()}
+
+	rest := search
+	for {
+		start := strings.IndexByte(rest, '"')
+		if start < 0 {
+			sq.addWords(rest)
+			break
+		}
+		sq.addWords(rest[:start])
+		rest = rest[start+1:]
+
+		end := strings.IndexByte(rest, '"')
+		if end < 0 {
+			end = len(rest)
+		}
+		if phrase := strings.TrimSpace(rest[:end]); phrase != "" {
+			sq.Phrases = append(sq.Phrases, phrase)
+			if tokens := sq.analyzer.Analyze(phrase); len(tokens) > 0 {
+				sq.phrases = append(sq.phrases, tokens)
+			}
+		}
+		if end == len(rest) {
+			break
+		}
+		rest = rest[end+1:]
+	}
+	return sq
+}
+
+// addWords adds the unquoted words of a search string
+func (sq *SearchQuery) addWords(s string) {
+	for _, word := range strings.Fields(s) {
+		if len(word) > 1 && word[0] == '-' {
+			sq.Excluded = append(sq.Excluded, word[1:])
+			sq.excluded = append(sq.excluded, sq.analyzer.Analyze(word[1:])...)
+			continue
+		}
+		sq.Terms = append(sq.Terms, word)
+	}
+}
+
+// Query returns the words to look up in an index: the terms and the words
+// of the phrases. It is empty when the search has only excluded terms,
+// which match nothing on their own.
+func (sq *SearchQuery) Query() string {
+	return strings.Join(append(append([]string(nil), sq.Terms...), sq.Phrases...), " ")
+}
+
+// Matches reports whether a document with the given indexed texts contains
+// every phrase of the search and none of its excluded terms. Phrases are
+// compared as analyzed tokens, so case, punctuation and stop words between
+// their words don't matter.
+func (sq *SearchQuery) Matches(texts []string) bool {
+	if len(sq.phrases) == 0 && len(sq.excluded) == 0 {
+		return true
+	}
+
+	fields := make([][]string, len(texts))
+	for i, t := range texts {
+		fields[i] = sq.analyzer.Analyze(t)
+	}
+
+	for _, word := range sq.excluded {
+		for _, tokens := range fields {
+			for _, token := range tokens {
+				if token == word {
+					return false
+				}
+			}
+		}
+	}
+
+	for _, phrase := range sq.phrases {
+		found := false
+		for _, tokens := range fields {
+			if containsSequence(tokens, phrase) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// containsSequence reports whether seq appears contiguously in tokens
+func containsSequence(tokens, seq []string) bool {
+	for i := 0; i+len(seq) <= len(tokens); i++ {
+		match := true
+		for j, token := range seq {
+			if tokens[i+j] != token {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
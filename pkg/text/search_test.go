@@ -0,0 +1,51 @@
+package text
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSearch(t *testing.T) {
+	sq := ParseSearch(`espresso "cold brew" -decaf "unterminated phrase`)
+
+	if !reflect.DeepEqual(sq.Terms, []string{"espresso"}) {
+		t.Errorf("Expected terms [espresso], got %v", sq.Terms)
+	}
+	if !reflect.DeepEqual(sq.Phrases, []string{"cold brew", "unterminated phrase"}) {
+		t.Errorf("Expected two phrases, got %v", sq.Phrases)
+	}
+	if !reflect.DeepEqual(sq.Excluded, []string{"decaf"}) {
+		t.Errorf("Expected excluded [decaf], got %v", sq.Excluded)
+	}
+	if sq.Query() != "espresso cold brew unterminated phrase" {
+		t.Errorf("Unexpected index query %q", sq.Query())
+	}
+
+	if q := ParseSearch("-decaf").Query(); q != "" {
+		t.Errorf("Expected no index query for an exclusion, got %q", q)
+	}
+}
+
+func TestSearchQueryMatches(t *testing.T) {
+	tests := []struct {
+		search string
+		texts  []string
+		want   bool
+	}{
+		{"coffee shop", []string{"A tea house"}, true}, // Terms alone don't filter
+		{`"cold brew"`, []string{"Notes", "How to make Cold-Brew at home"}, true},
+		{`"cold brew"`, []string{"Brew it cold"}, false},
+		{`"cup of coffee"`, []string{"The perfect cup of coffee"}, true},
+		{`"coffee" "grinder"`, []string{"coffee beans"}, false},
+		{`"coffee" "grinder"`, []string{"coffee", "burr grinder"}, true},
+		{"coffee -decaf", []string{"Decaf coffee"}, false},
+		{"coffee -decaf", []string{"Strong coffee"}, true},
+		{`"the"`, []string{"anything"}, true}, // Stop words only: no constraint
+	}
+
+	for _, tt := range tests {
+		if got := ParseSearch(tt.search).Matches(tt.texts); got != tt.want {
+			t.Errorf("ParseSearch(%q).Matches(%q) = %v, want %v", tt.search, tt.texts, got, tt.want)
+		}
+	}
+}
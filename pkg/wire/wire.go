@@ -0,0 +1,154 @@
+// Package wire implements LauraDB's binary wire protocol: length-prefixed
+// frames exchanged over a persistent TCP connection.
+//
+// Every frame is laid out as
+//
+//	uint32  length of the rest of the frame (big endian)
+//	uint64  request ID (big endian)
+//	uint8   opcode
+//	[]byte  payload
+//
+// Requests carry an HTTP-style method, path and JSON body, so every
+// operation of the HTTP API is available. Responses echo the request ID,
+// which lets a client pipeline many requests and match replies as they
+// arrive, in any order.
+package wire
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// OpCode identifies the kind of frame
+type OpCode uint8
+
+const (
+	// OpAuth carries an auth token; the payload of the reply is a Response
+	OpAuth OpCode = iota + 1
+	// OpRequest carries a Request
+	OpRequest
+	// OpResponse carries a Response to the request with the same ID
+	OpResponse
+)
+
+// String returns the opcode name
+func (op OpCode) String() string {
+	switch op {
+	case OpAuth:
+		return "AUTH"
+	case OpRequest:
+		return "REQUEST"
+	case OpResponse:
+		return "RESPONSE"
+	default:
+		return fmt.Sprintf("OpCode(%d)", uint8(op))
+	}
+}
+
+// headerSize is the size of the request ID and opcode following the length
+const headerSize = 8 + 1
+
+// MaxFrameSize bounds the size of a single frame
+const MaxFrameSize = 16 * 1024 * 1024
+
+// Frame is a single protocol message
+type Frame struct {
+	RequestID uint64
+	OpCode    OpCode
+	Payload   []byte
+}
+
+// Request is the payload of an OpRequest frame
+type Request struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Response is the payload of an OpResponse frame. Body is the JSON
+// document the HTTP API would have returned for the same request.
+type Response struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// WriteFrame writes a frame to w
+func WriteFrame(w io.Writer, frame *Frame) error {
+	size := headerSize + len(frame.Payload)
+	if size > MaxFrameSize {
+		return fmt.Errorf("frame too large: %d bytes", size)
+	}
+
+	buf := make([]byte, 4+size)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(size))
+	binary.BigEndian.PutUint64(buf[4:12], frame.RequestID)
+	buf[12] = byte(frame.OpCode)
+	copy(buf[13:], frame.Payload)
+
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("failed to write frame: %w", err)
+	}
+	return nil
+}
+
+// ReadFrame reads the next frame from r
+func ReadFrame(r io.Reader) (*Frame, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(lenBuf[:])
+	if size < headerSize || size > MaxFrameSize {
+		return nil, fmt.Errorf("invalid frame size: %d", size)
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("failed to read frame: %w", err)
+	}
+
+	return &Frame{
+		RequestID: binary.BigEndian.Uint64(buf[0:8]),
+		OpCode:    OpCode(buf[8]),
+		Payload:   buf[headerSize:],
+	}, nil
+}
+
+// EncodeRequest builds an OpRequest frame
+func EncodeRequest(id uint64, req *Request) (*Frame, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	return &Frame{RequestID: id, OpCode: OpRequest, Payload: payload}, nil
+}
+
+// EncodeResponse builds an OpResponse frame
+func EncodeResponse(id uint64, resp *Response) (*Frame, error) {
+	payload, err := json.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode response: %w", err)
+	}
+	return &Frame{RequestID: id, OpCode: OpResponse, Payload: payload}, nil
+}
+
+// DecodeRequest parses the payload of an OpRequest frame
+func DecodeRequest(frame *Frame) (*Request, error) {
+	var req Request
+	if err := json.Unmarshal(frame.Payload, &req); err != nil {
+		return nil, fmt.Errorf("failed to decode request: %w", err)
+	}
+	return &req, nil
+}
+
+// DecodeResponse parses the payload of an OpResponse frame
+func DecodeResponse(frame *Frame) (*Response, error) {
+	var resp Response
+	if err := json.Unmarshal(frame.Payload, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,65 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+
+	req := &Request{Method: "POST", Path: "/users/_doc", Body: []byte(`{"name":"alice"}`)}
+	frame, err := EncodeRequest(42, req)
+	if err != nil {
+		t.Fatalf("EncodeRequest failed: %v", err)
+	}
+	if err := WriteFrame(&buf, frame); err != nil {
+		t.Fatalf("WriteFrame failed: %v", err)
+	}
+	if err := WriteFrame(&buf, &Frame{RequestID: 43, OpCode: OpAuth, Payload: []byte("token")}); err != nil {
+		t.Fatalf("WriteFrame failed: %v", err)
+	}
+
+	got, err := ReadFrame(&buf)
+	if err != nil {
+		t.Fatalf("ReadFrame failed: %v", err)
+	}
+	if got.RequestID != 42 || got.OpCode != OpRequest {
+		t.Errorf("Expected request 42 REQUEST, got %d %s", got.RequestID, got.OpCode)
+	}
+	decoded, err := DecodeRequest(got)
+	if err != nil {
+		t.Fatalf("DecodeRequest failed: %v", err)
+	}
+	if decoded.Method != "POST" || decoded.Path != "/users/_doc" || string(decoded.Body) != `{"name":"alice"}` {
+		t.Errorf("Expected original request, got %+v", decoded)
+	}
+
+	got, err = ReadFrame(&buf)
+	if err != nil {
+		t.Fatalf("ReadFrame failed: %v", err)
+	}
+	if got.RequestID != 43 || got.OpCode != OpAuth || string(got.Payload) != "token" {
+		t.Errorf("Expected auth frame 43, got %+v", got)
+	}
+}
+
+func TestReadFrameRejectsInvalidSize(t *testing.T) {
+	for _, size := range []uint32{0, headerSize - 1, MaxFrameSize + 1} {
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.BigEndian, size)
+		buf.Write(make([]byte, 16))
+
+		if _, err := ReadFrame(&buf); err == nil {
+			t.Errorf("Expected error for frame size %d", size)
+		}
+	}
+}
+
+func TestWriteFrameRejectsOversizedPayload(t *testing.T) {
+	frame := &Frame{OpCode: OpRequest, Payload: make([]byte, MaxFrameSize)}
+	if err := WriteFrame(&bytes.Buffer{}, frame); err == nil {
+		t.Error("Expected error for oversized frame")
+	}
+}
@@ -610,8 +610,8 @@ func TestEmbeddedBulkOperations(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to update many: %v", err)
 	}
-	if updated != 3 {
-		t.Errorf("Expected 3 updated documents, got %d", updated)
+	if updated.ModifiedCount != 3 {
+		t.Errorf("Expected 3 updated documents, got %d", updated.ModifiedCount)
 	}
 
 	// Verify updates
@@ -627,8 +627,8 @@ func TestEmbeddedBulkOperations(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to delete many: %v", err)
 	}
-	if deleted != 2 {
-		t.Errorf("Expected 2 deleted documents, got %d", deleted)
+	if deleted.DeletedCount != 2 {
+		t.Errorf("Expected 2 deleted documents, got %d", deleted.DeletedCount)
 	}
 
 	// Verify deletions